@@ -0,0 +1,225 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/google/uuid"
+
+	"github.com/grafana/tempo/pkg/tempopb"
+	"github.com/grafana/tempo/pkg/util"
+	tempodb_backend "github.com/grafana/tempo/tempodb/backend"
+	"github.com/grafana/tempo/tempodb/backend/local"
+	"github.com/grafana/tempo/tempodb/encoding"
+)
+
+// gzipMagic starts every gzip encoded object.  A marshalled trace can't start with it as 0x1f is an invalid proto tag.
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// localTrace collects the parts of a trace found on an ingester host and where they were found.
+type localTrace struct {
+	id      []byte
+	trace   *tempopb.Trace
+	sources []string
+}
+
+// dumpLocalTrace reads a trace directly from the files of an ingester: the head blocks in the wal directory, the
+// blocks being completed in its completed directory and, if blocksPath is set, the blocks of a local backend.  Nothing
+// is modified, so it's safe to run next to an ingester that is wedged.
+func dumpLocalTrace(walPath string, blocksPath string, tenantID string, traceID string) error {
+	id, err := util.HexStringToTraceID(traceID)
+	if err != nil {
+		return err
+	}
+	t := &localTrace{id: id}
+
+	for _, dir := range []string{walPath, path.Join(walPath, "completed")} {
+		if err := t.searchWALDir(dir, tenantID); err != nil {
+			return err
+		}
+	}
+
+	if blocksPath != "" {
+		if err := t.searchBlocks(blocksPath, tenantID); err != nil {
+			return err
+		}
+	}
+
+	if t.trace == nil {
+		return fmt.Errorf("trace %s not found", traceID)
+	}
+
+	for _, source := range t.sources {
+		fmt.Fprintln(os.Stderr, "found in", source)
+	}
+	traceJSON, err := json.Marshal(t.trace)
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(traceJSON))
+	return nil
+}
+
+// searchWALDir scans every wal file of the tenant in dir.  Wal files are named <block id>:<tenant id>.  A file that
+// can't be read to the end, such as one that was being written when the ingester failed, is reported and the objects
+// read before the error are kept.
+func (t *localTrace) searchWALDir(dir string, tenantID string) error {
+	files, err := ioutil.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, f := range files {
+		if f.IsDir() {
+			continue
+		}
+
+		i := strings.Index(f.Name(), ":")
+		if i < 0 {
+			continue
+		}
+		if _, err := uuid.Parse(f.Name()[:i]); err != nil {
+			continue
+		}
+		if tenantID != "" && f.Name()[i+1:] != tenantID {
+			continue
+		}
+
+		name := path.Join(dir, f.Name())
+		if err := t.searchWALFile(name); err != nil {
+			fmt.Fprintf(os.Stderr, "error reading %s, continuing: %v\n", name, err)
+		}
+	}
+
+	return nil
+}
+
+func (t *localTrace) searchWALFile(name string) error {
+	file, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	iter := encoding.NewIterator(file)
+	for {
+		objID, object, err := iter.Next()
+		if err != nil {
+			return err
+		}
+		if objID == nil {
+			return nil
+		}
+		if !bytes.Equal(objID, t.id) {
+			continue
+		}
+
+		// completed files are written with the ingester's block encoding, which isn't recorded next to them
+		enc := encoding.EncNone
+		if bytes.HasPrefix(object, gzipMagic) {
+			enc = encoding.EncGZIP
+		}
+		if err := t.add(name, enc, object); err != nil {
+			return err
+		}
+	}
+}
+
+// searchBlocks looks the trace up in the blocks of a local backend using their indexes.
+func (t *localTrace) searchBlocks(blocksPath string, tenantID string) error {
+	r, _, _, err := local.New(&local.Config{Path: blocksPath})
+	if err != nil {
+		return err
+	}
+
+	tenantIDs := []string{tenantID}
+	if tenantID == "" {
+		tenantIDs, err = r.Tenants(context.Background())
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, tenant := range tenantIDs {
+		blockIDs, err := r.Blocks(context.Background(), tenant)
+		if err != nil {
+			return err
+		}
+
+		for _, blockID := range blockIDs {
+			if err := t.searchBlock(r, tenant, blockID); err != nil {
+				fmt.Fprintf(os.Stderr, "error reading block %s/%v, continuing: %v\n", tenant, blockID, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+func (t *localTrace) searchBlock(r tempodb_backend.Reader, tenantID string, blockID uuid.UUID) error {
+	meta, err := r.BlockMeta(context.Background(), blockID, tenantID)
+	if err == tempodb_backend.ErrMetaDoesNotExist {
+		// compacted or still being written
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if bytes.Compare(t.id, meta.MinID) < 0 || bytes.Compare(t.id, meta.MaxID) > 0 {
+		return nil
+	}
+
+	index, err := r.Index(context.Background(), blockID, tenantID)
+	if err != nil {
+		return err
+	}
+	record, err := encoding.FindRecord(t.id, index)
+	if err != nil || record == nil {
+		return err
+	}
+
+	objects := make([]byte, record.Length)
+	if err := r.Object(context.Background(), blockID, tenantID, record.Start, objects); err != nil {
+		return err
+	}
+
+	iter := encoding.NewIterator(bytes.NewReader(objects))
+	for {
+		objID, object, err := iter.Next()
+		if err != nil {
+			return err
+		}
+		if objID == nil {
+			return nil
+		}
+		if bytes.Equal(objID, t.id) {
+			return t.add(fmt.Sprintf("block %s/%v", tenantID, blockID), meta.Encoding, object)
+		}
+	}
+}
+
+func (t *localTrace) add(source string, enc string, object []byte) error {
+	decoded, err := encoding.DecodeObject(enc, object)
+	if err != nil {
+		return err
+	}
+
+	trace := &tempopb.Trace{}
+	if err := proto.Unmarshal(decoded, trace); err != nil {
+		return err
+	}
+
+	t.trace = util.CombineTraceProtos(t.trace, trace)
+	t.sources = append(t.sources, source)
+	return nil
+}
@@ -10,14 +10,17 @@ import (
 	"os"
 	"sort"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/grafana/tempo/tempodb"
 	tempodb_backend "github.com/grafana/tempo/tempodb/backend"
 	"github.com/grafana/tempo/tempodb/backend/local"
 	"github.com/grafana/tempo/tempodb/encoding"
 
 	"github.com/grafana/tempo/pkg/util"
+	"github.com/grafana/tempo/tempodb/backend/azure"
 	"github.com/grafana/tempo/tempodb/backend/gcs"
 	"github.com/grafana/tempo/tempodb/backend/s3"
 	"github.com/olekukonko/tablewriter"
@@ -28,29 +31,62 @@ var (
 	s3Endpoint  string
 	s3User      string
 	s3Pass      string
+	azureAcct   string
+	azureKey    string
+	azureSAS    string
 	backend     string
 	tenantID    string
 	windowRange time.Duration
 	blockID     string
 
+	compactionPlan       bool
+	maxCompactionObjects int
+	maxBlockBytes        uint64
+
 	queryEndpoint string
 	traceID       string
 	orgID         string
+
+	walPath    string
+	blocksPath string
+
+	migrateFrom       string
+	migrateEndpoint   string
+	migrateStart      string
+	migrateEnd        string
+	migrateLimit      int
+	migrateCheckpoint string
 )
 
 func init() {
-	flag.StringVar(&backend, "backend", "", "backend to connect to (s3/gcs)")
+	flag.StringVar(&backend, "backend", "", "backend to connect to (s3/gcs/azure)")
 	flag.StringVar(&bucket, "bucket", "", "bucket to scan")
 	flag.StringVar(&s3Endpoint, "s3-endpoint", "", "s3 endpoint")
 	flag.StringVar(&s3User, "s3-user", "", "s3 username")
 	flag.StringVar(&s3Pass, "s3-pass", "", "s3 password")
+	flag.StringVar(&azureAcct, "azure-account", "", "azure storage account name, the bucket is the container")
+	flag.StringVar(&azureKey, "azure-key", "", "azure storage account key")
+	flag.StringVar(&azureSAS, "azure-sas", "", "azure sas token, used if no key is given")
 	flag.StringVar(&tenantID, "tenant-id", "", "tenant-id that contains the bucket")
 	flag.StringVar(&blockID, "block-id", "", "block-id to dump (optional)")
 	flag.DurationVar(&windowRange, "window-range", 4*time.Hour, "block time window range for compaction")
+	flag.BoolVar(&compactionPlan, "compaction-plan", false, "print the blocks the compactor would compact next instead of dumping the bucket")
+	flag.IntVar(&maxCompactionObjects, "max-compaction-objects", 6000000, "maximum number of traces in a compacted block, used with -compaction-plan")
+	flag.Uint64Var(&maxBlockBytes, "max-block-bytes", 100*1024*1024*1024, "maximum size of a compacted block in bytes, used with -compaction-plan. 0 is unlimited")
 
 	flag.StringVar(&queryEndpoint, "query-endpoint", "", "tempo query endpoint")
 	flag.StringVar(&traceID, "traceID", "", "traceID to query")
 	flag.StringVar(&orgID, "orgID", "", "orgID to query")
+
+	flag.StringVar(&walPath, "wal-path", "", "ingester wal directory to read -traceID from, without a running ingester. -tenant-id is optional")
+	flag.StringVar(&blocksPath, "blocks-path", "", "local backend directory to also read -traceID from, used with -wal-path")
+
+	flag.StringVar(&migrateFrom, "migrate-from", "", "migrate traces from jaeger or zipkin to blocks of -tenant-id in the backend, one block per -window-range")
+	flag.StringVar(&migrateEndpoint, "migrate-endpoint", "", "grpc address of jaeger query, e.g. jaeger-query:16685, or url of zipkin, e.g. http://zipkin:9411")
+	flag.StringVar(&migrateStart, "migrate-start", "", "RFC3339 time of the oldest traces to migrate")
+	flag.StringVar(&migrateEnd, "migrate-end", "", "RFC3339 time of the newest traces to migrate. defaults to now")
+	flag.IntVar(&migrateLimit, "migrate-limit", 1000, "traces read per query. time ranges with more traces are split")
+	flag.StringVar(&migrateCheckpoint, "migrate-checkpoint", "migration-checkpoint.json", "file the progress of the migration is saved to and resumed from")
 }
 
 func main() {
@@ -70,6 +106,13 @@ func main() {
 		return
 	}
 
+	if len(walPath) > 0 && len(traceID) > 0 {
+		if err := dumpLocalTrace(walPath, blocksPath, tenantID, traceID); err != nil {
+			fmt.Println("error reading local trace, err:", err)
+		}
+		return
+	}
+
 	if len(backend) == 0 {
 		fmt.Println("-backend is required")
 		return
@@ -85,12 +128,21 @@ func main() {
 		return
 	}
 
+	if len(migrateFrom) > 0 {
+		if err := runMigration(); err != nil {
+			fmt.Println("error migrating traces, err:", err)
+		}
+		return
+	}
+
 	r, _, c, err := getBackendUtils(backend, bucket, s3Endpoint, s3User, s3Pass)
 	if err != nil {
 		fmt.Printf("error creating backend utils, please check config")
 	}
 
-	if len(blockID) > 0 {
+	if compactionPlan {
+		err = dumpCompactionPlan(r, tenantID, windowRange, maxCompactionObjects, maxBlockBytes)
+	} else if len(blockID) > 0 {
 		err = dumpBlock(r, c, tenantID, windowRange, blockID)
 	} else {
 		err = dumpBucket(r, c, tenantID, windowRange)
@@ -102,27 +154,50 @@ func main() {
 }
 
 func getBackendUtils(backend, bucket, s3Endpoint, s3User, s3Pass string) (tempodb_backend.Reader, tempodb_backend.Writer, tempodb_backend.Compactor, error) {
+	cfg, err := getStoreConfig(backend, bucket, s3Endpoint, s3User, s3Pass)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	return tempodb.NewBackend(cfg)
+}
+
+// getStoreConfig returns the tempodb config of the backend.  It has no wal.
+func getStoreConfig(backend, bucket, s3Endpoint, s3User, s3Pass string) (*tempodb.Config, error) {
+	cfg := &tempodb.Config{
+		Backend: backend,
+	}
+
 	switch backend {
 	case "s3":
-		return s3.New(&s3.Config{
+		cfg.S3 = &s3.Config{
 			Bucket:    bucket,
 			Endpoint:  s3Endpoint,
 			AccessKey: s3User,
 			SecretKey: s3Pass,
 			Insecure:  true,
-		})
+		}
 	case "gcs":
-		return gcs.New(&gcs.Config{
+		cfg.GCS = &gcs.Config{
 			BucketName:      bucket,
 			ChunkBufferSize: 10 * 1024 * 1024,
-		})
+		}
+	case "azure":
+		cfg.Azure = &azure.Config{
+			ContainerName:      bucket,
+			StorageAccountName: azureAcct,
+			StorageAccountKey:  azureKey,
+			SASToken:           azureSAS,
+		}
 	case "local":
-		return local.New(&local.Config{
+		cfg.Local = &local.Config{
 			Path: bucket,
-		})
+		}
 	default:
-		return nil, nil, nil, fmt.Errorf("unknown backend %s", backend)
+		return nil, fmt.Errorf("unknown backend %s", backend)
 	}
+
+	return cfg, nil
 }
 
 func dumpBlock(r tempodb_backend.Reader, c tempodb_backend.Compactor, tenantID string, windowRange time.Duration, blockID string) error {
@@ -254,6 +329,53 @@ func dumpBucket(r tempodb_backend.Reader, c tempodb_backend.Compactor, tenantID
 	return nil
 }
 
+func dumpCompactionPlan(r tempodb_backend.Reader, tenantID string, windowRange time.Duration, maxObjects int, maxBytes uint64) error {
+	blockIDs, err := r.Blocks(context.Background(), tenantID)
+	if err != nil {
+		return err
+	}
+
+	blocklist := make([]*encoding.BlockMeta, 0, len(blockIDs))
+	for _, id := range blockIDs {
+		meta, err := r.BlockMeta(context.Background(), id, tenantID)
+		if err == tempodb_backend.ErrMetaDoesNotExist {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		blocklist = append(blocklist, meta)
+	}
+
+	plan := tempodb.PlanCompaction(tenantID, blocklist, &tempodb.CompactorConfig{
+		MaxCompactionRange:   windowRange,
+		MaxCompactionObjects: maxObjects,
+		MaxBlockBytes:        maxBytes,
+	}, nil)
+
+	fmt.Println("compaction jobs: ", len(plan.Jobs))
+	jobs := tablewriter.NewWriter(os.Stdout)
+	jobs.SetHeader([]string{"hash", "lvl", "window", "count", "blocks"})
+	for _, j := range plan.Jobs {
+		ids := make([]string, 0, len(j.Blocks))
+		for _, id := range j.Blocks {
+			ids = append(ids, id.String())
+		}
+		jobs.Append([]string{j.HashString, strconv.Itoa(int(j.Level)), strconv.Itoa(int(j.Window)), strconv.Itoa(j.TotalObjects), strings.Join(ids, " ")})
+	}
+	jobs.Render()
+
+	fmt.Println("skipped blocks: ", len(plan.Skipped))
+	skipped := tablewriter.NewWriter(os.Stdout)
+	skipped.SetHeader([]string{"id", "lvl", "window", "count", "reason"})
+	for _, s := range plan.Skipped {
+		skipped.Append([]string{s.BlockID.String(), strconv.Itoa(int(s.Level)), strconv.Itoa(int(s.Window)), strconv.Itoa(s.TotalObjects), s.Reason})
+	}
+	skipped.Render()
+
+	return nil
+}
+
 func blockStats(meta *encoding.BlockMeta, compactedMeta *encoding.CompactedBlockMeta, windowRange time.Duration) (int, uint8, int64, time.Time, time.Time) {
 	if meta != nil {
 		return meta.TotalObjects, meta.CompactionLevel, meta.EndTime.Unix() / int64(windowRange/time.Second), meta.StartTime, meta.EndTime
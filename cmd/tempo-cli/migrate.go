@@ -0,0 +1,559 @@
+package main
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/google/uuid"
+	jaeger "github.com/jaegertracing/jaeger/model"
+	"github.com/jaegertracing/jaeger/proto-gen/api_v2"
+	v1_common "github.com/open-telemetry/opentelemetry-proto/gen/go/common/v1"
+	v1_resource "github.com/open-telemetry/opentelemetry-proto/gen/go/resource/v1"
+	v1_trace "github.com/open-telemetry/opentelemetry-proto/gen/go/trace/v1"
+	ot_pdata "go.opentelemetry.io/collector/consumer/pdata"
+	ot_jaeger "go.opentelemetry.io/collector/translator/trace/jaeger"
+	"google.golang.org/grpc"
+
+	"github.com/grafana/tempo/pkg/blockwriter"
+	"github.com/grafana/tempo/pkg/tempopb"
+	"github.com/grafana/tempo/pkg/util"
+	"github.com/grafana/tempo/tempodb"
+	"github.com/grafana/tempo/tempodb/encoding"
+	"github.com/grafana/tempo/tempodb/wal"
+)
+
+const (
+	migrateFromJaeger = "jaeger"
+	migrateFromZipkin = "zipkin"
+
+	// a range of time that still has more traces than the query limit is migrated as is below this
+	minMigrationStep = time.Second
+)
+
+// migrationSource reads traces from the storage of another tracing system.
+type migrationSource interface {
+	// Traces returns the traces with spans that started between start and end, keyed by trace id.  limited is set if
+	// the source returned as many traces as it was allowed to, so some may be missing.
+	Traces(ctx context.Context, start, end time.Time, limit int) (traces map[string]*tempopb.Trace, limited bool, err error)
+}
+
+// migrationCheckpoint is the progress of a migration, saved after every block so an interrupted migration can be
+// resumed.
+type migrationCheckpoint struct {
+	Source   string    `json:"source"`
+	Endpoint string    `json:"endpoint"`
+	TenantID string    `json:"tenantID"`
+	Start    time.Time `json:"start"`
+	End      time.Time `json:"end"`
+	// Done is the time up to which traces have been migrated.
+	Done   time.Time   `json:"done"`
+	Traces int         `json:"traces"`
+	Spans  int         `json:"spans"`
+	Blocks []uuid.UUID `json:"blocks"`
+}
+
+// runMigration migrates traces with the flags.
+func runMigration() error {
+	if len(migrateEndpoint) == 0 {
+		return fmt.Errorf("-migrate-endpoint is required")
+	}
+
+	var start time.Time
+	var err error
+	if len(migrateStart) > 0 {
+		start, err = time.Parse(time.RFC3339, migrateStart)
+		if err != nil {
+			return fmt.Errorf("invalid -migrate-start: %w", err)
+		}
+	}
+	end := time.Now()
+	if len(migrateEnd) > 0 {
+		end, err = time.Parse(time.RFC3339, migrateEnd)
+		if err != nil {
+			return fmt.Errorf("invalid -migrate-end: %w", err)
+		}
+	}
+
+	cfg, err := getStoreConfig(backend, bucket, s3Endpoint, s3User, s3Pass)
+	if err != nil {
+		return err
+	}
+
+	return migrate(cfg, tenantID, migrateFrom, migrateEndpoint, start, end, windowRange, migrateLimit, migrateCheckpoint)
+}
+
+// migrate copies the traces that started between start and end from a Jaeger Query or Zipkin server to blocks of the
+// tenant in the backend, one block per compaction window.  A migration with a checkpoint file is resumed from it.
+// Traces with spans in two windows are written to both blocks, as are the traces of a window that was being migrated
+// when the migration was interrupted.  The copies are combined when the blocks are compacted.
+func migrate(storeCfg *tempodb.Config, tenantID string, source string, endpoint string, start time.Time, end time.Time, window time.Duration, limit int, checkpointPath string) error {
+	if window < time.Second {
+		return fmt.Errorf("-window-range must be at least 1s")
+	}
+	if limit <= 0 {
+		return fmt.Errorf("-migrate-limit must be positive")
+	}
+
+	cp, err := loadMigrationCheckpoint(checkpointPath)
+	if err != nil {
+		return err
+	}
+	if cp == nil {
+		if start.IsZero() || !start.Before(end) {
+			return fmt.Errorf("-migrate-start is required and must be before -migrate-end")
+		}
+		cp = &migrationCheckpoint{
+			Source:   source,
+			Endpoint: endpoint,
+			TenantID: tenantID,
+			Start:    start,
+			End:      end,
+			Done:     start,
+		}
+	} else {
+		if cp.Source != source || cp.Endpoint != endpoint || cp.TenantID != tenantID {
+			return fmt.Errorf("checkpoint %s is of a migration from %s %s to tenant %s", checkpointPath, cp.Source, cp.Endpoint, cp.TenantID)
+		}
+		fmt.Printf("resuming migration of %s - %s from %s\n", cp.Start.Format(time.RFC3339), cp.End.Format(time.RFC3339), cp.Done.Format(time.RFC3339))
+	}
+
+	var src migrationSource
+	switch source {
+	case migrateFromJaeger:
+		conn, err := grpc.Dial(endpoint, grpc.WithInsecure())
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+		src = &jaegerSource{client: api_v2.NewQueryServiceClient(conn)}
+	case migrateFromZipkin:
+		src = &zipkinSource{endpoint: endpoint, client: http.DefaultClient}
+	default:
+		return fmt.Errorf("unknown migration source %s.  must be %s or %s", source, migrateFromJaeger, migrateFromZipkin)
+	}
+
+	workDir, err := ioutil.TempDir("", "tempo-migrate")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(workDir)
+
+	cfg := *storeCfg
+	cfg.WAL = &wal.Config{
+		Filepath:        workDir,
+		IndexDownsample: 100,
+		BloomFP:         .05,
+	}
+	w, err := blockwriter.New(&cfg, log.NewNopLogger())
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	windowSeconds := int64(window / time.Second)
+	for cp.Done.Before(cp.End) {
+		stepStart := cp.Done
+		stepEnd := time.Unix((stepStart.Unix()/windowSeconds+1)*windowSeconds, 0)
+		if stepEnd.After(cp.End) {
+			stepEnd = cp.End
+		}
+
+		traces, err := migrationTraces(ctx, src, stepStart, stepEnd, limit)
+		if err != nil {
+			return fmt.Errorf("error reading traces of %s - %s: %w", stepStart.Format(time.RFC3339), stepEnd.Format(time.RFC3339), err)
+		}
+
+		blockID := "none"
+		spans := 0
+		if len(traces) > 0 {
+			meta, err := writeMigrationBlock(ctx, w, tenantID, traces)
+			if err != nil {
+				return fmt.Errorf("error writing traces of %s - %s: %w", stepStart.Format(time.RFC3339), stepEnd.Format(time.RFC3339), err)
+			}
+			for _, t := range traces {
+				_, _, n, _, _ := util.SummarizeTrace(t)
+				spans += n
+			}
+			cp.Blocks = append(cp.Blocks, meta.BlockID)
+			blockID = meta.BlockID.String()
+		}
+
+		cp.Done = stepEnd
+		cp.Traces += len(traces)
+		cp.Spans += spans
+		err = saveMigrationCheckpoint(checkpointPath, cp)
+		if err != nil {
+			return err
+		}
+
+		progress := float64(cp.Done.Sub(cp.Start)) / float64(cp.End.Sub(cp.Start)) * 100
+		fmt.Printf("%5.1f%% migrated %s - %s: %d traces, %d spans, block %s\n", progress, stepStart.Format(time.RFC3339), stepEnd.Format(time.RFC3339), len(traces), spans, blockID)
+	}
+
+	fmt.Printf("migration complete: %d traces, %d spans in %d blocks\n", cp.Traces, cp.Spans, len(cp.Blocks))
+	return nil
+}
+
+// migrationTraces reads the traces between start and end.  If the source returns as many traces as the limit the range
+// is split in halves, which are read separately.
+func migrationTraces(ctx context.Context, src migrationSource, start time.Time, end time.Time, limit int) (map[string]*tempopb.Trace, error) {
+	traces, limited, err := src.Traces(ctx, start, end, limit)
+	if err != nil {
+		return nil, err
+	}
+	if !limited {
+		return traces, nil
+	}
+
+	if end.Sub(start) <= minMigrationStep {
+		fmt.Printf("warning: more than %d traces started between %s and %s, some are not migrated\n", limit, start.Format(time.RFC3339Nano), end.Format(time.RFC3339Nano))
+		return traces, nil
+	}
+
+	middle := start.Add(end.Sub(start) / 2)
+	traces, err = migrationTraces(ctx, src, start, middle, limit)
+	if err != nil {
+		return nil, err
+	}
+	second, err := migrationTraces(ctx, src, middle, end, limit)
+	if err != nil {
+		return nil, err
+	}
+	for id, t := range second {
+		if existing, ok := traces[id]; ok {
+			t = util.CombineTraceProtos(existing, t)
+		}
+		traces[id] = t
+	}
+
+	return traces, nil
+}
+
+func writeMigrationBlock(ctx context.Context, w *blockwriter.Writer, tenantID string, traces map[string]*tempopb.Trace) (*encoding.BlockMeta, error) {
+	block, err := w.NewBlock(tenantID)
+	if err != nil {
+		return nil, err
+	}
+
+	for id, t := range traces {
+		err = block.Write([]byte(id), t)
+		if err != nil {
+			_ = block.Clear()
+			return nil, err
+		}
+	}
+
+	return block.Flush(ctx)
+}
+
+func loadMigrationCheckpoint(path string) (*migrationCheckpoint, error) {
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	cp := &migrationCheckpoint{}
+	err = json.Unmarshal(b, cp)
+	if err != nil {
+		return nil, fmt.Errorf("error reading checkpoint %s: %w", path, err)
+	}
+	return cp, nil
+}
+
+// saveMigrationCheckpoint replaces the checkpoint file by renaming a new one over it so it's never half written.
+func saveMigrationCheckpoint(path string, cp *migrationCheckpoint) error {
+	b, err := json.MarshalIndent(cp, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp := filepath.Join(filepath.Dir(path), "."+filepath.Base(path)+".tmp")
+	err = ioutil.WriteFile(tmp, b, 0644)
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// jaegerSource reads traces with the gRPC query API of Jaeger Query, which works with any of Jaeger's storage
+// backends.
+type jaegerSource struct {
+	client api_v2.QueryServiceClient
+}
+
+func (s *jaegerSource) Traces(ctx context.Context, start, end time.Time, limit int) (map[string]*tempopb.Trace, bool, error) {
+	services, err := s.client.GetServices(ctx, &api_v2.GetServicesRequest{})
+	if err != nil {
+		return nil, false, err
+	}
+
+	// traces are found under every service with spans in them
+	spans := map[string][]*jaeger.Span{}
+	limited := false
+	for _, service := range services.Services {
+		stream, err := s.client.FindTraces(ctx, &api_v2.FindTracesRequest{Query: &api_v2.TraceQueryParameters{
+			ServiceName:  service,
+			StartTimeMin: start,
+			StartTimeMax: end,
+			SearchDepth:  int32(limit),
+		}})
+		if err != nil {
+			return nil, false, err
+		}
+
+		// trace id to whether its spans are kept.  a trace found under an earlier service already has all of them
+		found := map[string]bool{}
+		for {
+			chunk, err := stream.Recv()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return nil, false, err
+			}
+
+			for i := range chunk.Spans {
+				span := chunk.Spans[i]
+				id := make([]byte, span.TraceID.Size())
+				_, err = span.TraceID.MarshalTo(id)
+				if err != nil {
+					return nil, false, err
+				}
+
+				key := string(id)
+				keep, ok := found[key]
+				if !ok {
+					_, seen := spans[key]
+					keep = !seen
+					found[key] = keep
+				}
+				if keep {
+					spans[key] = append(spans[key], &span)
+				}
+			}
+		}
+		if len(found) >= limit {
+			limited = true
+		}
+	}
+
+	traces := make(map[string]*tempopb.Trace, len(spans))
+	for id, traceSpans := range spans {
+		traces[id] = jaegerSpansToTrace(traceSpans)
+	}
+	return traces, limited, nil
+}
+
+// jaegerSpansToTrace converts the spans of a trace, which carry their process, to a trace with a batch per process.
+func jaegerSpansToTrace(spans []*jaeger.Span) *tempopb.Trace {
+	batches := []*jaeger.Batch{}
+	for _, span := range spans {
+		var batch *jaeger.Batch
+		for _, b := range batches {
+			if b.Process.Equal(span.Process) {
+				batch = b
+				break
+			}
+		}
+		if batch == nil {
+			batch = &jaeger.Batch{Process: span.Process}
+			batches = append(batches, batch)
+		}
+		batch.Spans = append(batch.Spans, span)
+	}
+
+	return &tempopb.Trace{
+		Batches: ot_pdata.TracesToOtlp(ot_jaeger.ProtoBatchesToInternalTraces(batches)),
+	}
+}
+
+// zipkinSource reads traces with the v2 http api of a Zipkin server.
+type zipkinSource struct {
+	endpoint string
+	client   *http.Client
+}
+
+func (s *zipkinSource) Traces(ctx context.Context, start, end time.Time, limit int) (map[string]*tempopb.Trace, bool, error) {
+	// zipkin returns the traces with spans in the lookback before endTs, in milliseconds
+	url := fmt.Sprintf("%s/api/v2/traces?endTs=%d&lookback=%d&limit=%d", s.endpoint, end.UnixNano()/int64(time.Millisecond), int64(end.Sub(start)/time.Millisecond), limit)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, false, err
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, false, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := ioutil.ReadAll(resp.Body)
+		return nil, false, fmt.Errorf("zipkin returned %d: %s", resp.StatusCode, string(body))
+	}
+
+	var found [][]*zipkinSpan
+	err = json.NewDecoder(resp.Body).Decode(&found)
+	if err != nil {
+		return nil, false, fmt.Errorf("error decoding zipkin traces: %w", err)
+	}
+
+	traces := make(map[string]*tempopb.Trace, len(found))
+	for _, spans := range found {
+		if len(spans) == 0 {
+			continue
+		}
+
+		trace, err := zipkinSpansToTrace(spans)
+		if err != nil {
+			return nil, false, err
+		}
+		traces[string(trace.Batches[0].InstrumentationLibrarySpans[0].Spans[0].TraceId)] = trace
+	}
+
+	return traces, len(found) >= limit, nil
+}
+
+// zipkinSpan is a span of the Zipkin v2 json api.  Times are in microseconds.
+type zipkinSpan struct {
+	TraceID        string            `json:"traceId"`
+	ID             string            `json:"id"`
+	ParentID       string            `json:"parentId"`
+	Name           string            `json:"name"`
+	Kind           string            `json:"kind"`
+	Timestamp      uint64            `json:"timestamp"`
+	Duration       uint64            `json:"duration"`
+	LocalEndpoint  *zipkinEndpoint   `json:"localEndpoint"`
+	RemoteEndpoint *zipkinEndpoint   `json:"remoteEndpoint"`
+	Annotations    []zipkinEvent     `json:"annotations"`
+	Tags           map[string]string `json:"tags"`
+}
+
+type zipkinEndpoint struct {
+	ServiceName string `json:"serviceName"`
+	IPv4        string `json:"ipv4"`
+	IPv6        string `json:"ipv6"`
+	Port        int    `json:"port"`
+}
+
+type zipkinEvent struct {
+	Timestamp uint64 `json:"timestamp"`
+	Value     string `json:"value"`
+}
+
+var zipkinSpanKinds = map[string]v1_trace.Span_SpanKind{
+	"CLIENT":   v1_trace.Span_CLIENT,
+	"SERVER":   v1_trace.Span_SERVER,
+	"PRODUCER": v1_trace.Span_PRODUCER,
+	"CONSUMER": v1_trace.Span_CONSUMER,
+}
+
+// zipkinSpansToTrace converts the spans of a trace to a trace with a batch per local service.  Tags become string
+// attributes, annotations become events and the remote endpoint becomes peer attributes.  Spans with an error tag
+// get an error status.
+func zipkinSpansToTrace(spans []*zipkinSpan) (*tempopb.Trace, error) {
+	trace := &tempopb.Trace{}
+	batches := map[string]*v1_trace.InstrumentationLibrarySpans{}
+	for _, zs := range spans {
+		traceID, err := zipkinID(zs.TraceID, 16)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trace id %q: %w", zs.TraceID, err)
+		}
+		spanID, err := zipkinID(zs.ID, 8)
+		if err != nil {
+			return nil, fmt.Errorf("invalid span id %q: %w", zs.ID, err)
+		}
+
+		span := &v1_trace.Span{
+			TraceId:           traceID,
+			SpanId:            spanID,
+			Name:              zs.Name,
+			Kind:              zipkinSpanKinds[zs.Kind],
+			StartTimeUnixNano: zs.Timestamp * 1000,
+			EndTimeUnixNano:   (zs.Timestamp + zs.Duration) * 1000,
+		}
+		if zs.ParentID != "" {
+			span.ParentSpanId, err = zipkinID(zs.ParentID, 8)
+			if err != nil {
+				return nil, fmt.Errorf("invalid parent id %q: %w", zs.ParentID, err)
+			}
+		}
+		for k, v := range zs.Tags {
+			span.Attributes = append(span.Attributes, stringAttribute(k, v))
+		}
+		if _, ok := zs.Tags["error"]; ok {
+			span.Status = &v1_trace.Status{Code: v1_trace.Status_UnknownError, Message: zs.Tags["error"]}
+		}
+		if e := zs.RemoteEndpoint; e != nil {
+			if e.ServiceName != "" {
+				span.Attributes = append(span.Attributes, stringAttribute("peer.service", e.ServiceName))
+			}
+			if ip := e.IPv4 + e.IPv6; ip != "" {
+				span.Attributes = append(span.Attributes, stringAttribute("net.peer.ip", ip))
+			}
+			if e.Port != 0 {
+				span.Attributes = append(span.Attributes, &v1_common.KeyValue{
+					Key:   "net.peer.port",
+					Value: &v1_common.AnyValue{Value: &v1_common.AnyValue_IntValue{IntValue: int64(e.Port)}},
+				})
+			}
+		}
+		for _, a := range zs.Annotations {
+			span.Events = append(span.Events, &v1_trace.Span_Event{
+				TimeUnixNano: a.Timestamp * 1000,
+				Name:         a.Value,
+			})
+		}
+
+		service := ""
+		if zs.LocalEndpoint != nil {
+			service = zs.LocalEndpoint.ServiceName
+		}
+		ils, ok := batches[service]
+		if !ok {
+			ils = &v1_trace.InstrumentationLibrarySpans{}
+			batches[service] = ils
+			trace.Batches = append(trace.Batches, &v1_trace.ResourceSpans{
+				Resource: &v1_resource.Resource{
+					Attributes: []*v1_common.KeyValue{stringAttribute("service.name", service)},
+				},
+				InstrumentationLibrarySpans: []*v1_trace.InstrumentationLibrarySpans{ils},
+			})
+		}
+		ils.Spans = append(ils.Spans, span)
+	}
+
+	return trace, nil
+}
+
+// zipkinID decodes a hex id, left padding it with zeros to size bytes.  Zipkin trace ids may be 64 or 128 bit.
+func zipkinID(id string, size int) ([]byte, error) {
+	b, err := hex.DecodeString(id)
+	if err != nil {
+		return nil, err
+	}
+	if len(b) > size {
+		return nil, fmt.Errorf("longer than %d bytes", size)
+	}
+
+	return append(make([]byte, size-len(b)), b...), nil
+}
+
+func stringAttribute(key string, value string) *v1_common.KeyValue {
+	return &v1_common.KeyValue{
+		Key:   key,
+		Value: &v1_common.AnyValue{Value: &v1_common.AnyValue_StringValue{StringValue: value}},
+	}
+}
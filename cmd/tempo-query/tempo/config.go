@@ -1,15 +1,24 @@
 package tempo
 
 import (
+	"time"
+
 	"github.com/spf13/viper"
 )
 
 // Config holds the configuration for redbull.
 type Config struct {
-	Backend string `yaml:"backend"`
+	Backend      string        `yaml:"backend"`
+	MaxRetries   int           `yaml:"max_retries"`   // retries of a trace lookup that failed with a connection error or 5xx
+	RetryBackoff time.Duration `yaml:"retry_backoff"` // time to wait between retries
 }
 
 // InitFromViper initializes the options struct with values from Viper
 func (c *Config) InitFromViper(v *viper.Viper) {
+	v.SetDefault("max_retries", 2)
+	v.SetDefault("retry_backoff", 100*time.Millisecond)
+
 	c.Backend = v.GetString("backend")
+	c.MaxRetries = v.GetInt("max_retries")
+	c.RetryBackoff = v.GetDuration("retry_backoff")
 }
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net/http"
 	"time"
@@ -23,11 +24,15 @@ import (
 
 type Backend struct {
 	tempoEndpoint string
+	maxRetries    int
+	retryBackoff  time.Duration
 }
 
 func New(cfg *Config) *Backend {
 	return &Backend{
 		tempoEndpoint: "http://" + cfg.Backend + "/api/traces/",
+		maxRetries:    cfg.MaxRetries,
+		retryBackoff:  cfg.RetryBackoff,
 	}
 }
 
@@ -36,19 +41,8 @@ func (b *Backend) GetDependencies(endTs time.Time, lookback time.Duration) ([]ja
 }
 func (b *Backend) GetTrace(ctx context.Context, traceID jaeger.TraceID) (*jaeger.Trace, error) {
 	hexID := fmt.Sprintf("%016x%016x", traceID.High, traceID.Low)
-	req, err := http.NewRequestWithContext(ctx, "GET", b.tempoEndpoint+hexID, nil)
-	if err != nil {
-		return nil, err
-	}
 
-	// currently Jaeger Query will only propagate bearer token to the grpc backend and no other headers
-	// so we are going to extract the tenant id from the header, if it exists and use it
-	tenantID, found := extractBearerToken(ctx)
-	if found {
-		req.Header.Set(user.OrgIDHeaderName, tenantID)
-	}
-
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := b.getWithRetries(ctx, b.tempoEndpoint+hexID)
 	if err != nil {
 		return nil, fmt.Errorf("failed get to tempo %w", err)
 	}
@@ -97,6 +91,54 @@ func (b *Backend) GetTrace(ctx context.Context, traceID jaeger.TraceID) (*jaeger
 	return jaegerTrace, nil
 }
 
+// getWithRetries performs a GET against tempo.  Connection errors and 5xx responses are retried up to maxRetries
+// times so a single querier restarting doesn't fail the lookup.  Anything else is returned to the caller as is.
+func (b *Backend) getWithRetries(ctx context.Context, url string) (*http.Response, error) {
+	var resp *http.Response
+	var err error
+
+	for attempt := 0; attempt <= b.maxRetries; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-time.After(b.retryBackoff):
+			}
+		}
+
+		var req *http.Request
+		req, err = http.NewRequestWithContext(ctx, "GET", url, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		// currently Jaeger Query will only propagate bearer token to the grpc backend and no other headers
+		// so we are going to extract the tenant id from the header, if it exists and use it
+		tenantID, found := extractBearerToken(ctx)
+		if found {
+			req.Header.Set(user.OrgIDHeaderName, tenantID)
+		}
+
+		resp, err = http.DefaultClient.Do(req)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		if resp.StatusCode/100 != 5 || attempt == b.maxRetries {
+			return resp, nil
+		}
+
+		// drain so the connection can be reused
+		_, _ = io.Copy(ioutil.Discard, resp.Body)
+		resp.Body.Close()
+	}
+
+	return nil, err
+}
+
 func (b *Backend) GetServices(ctx context.Context) ([]string, error) {
 	return nil, nil
 }
@@ -0,0 +1,133 @@
+package tempo
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/golang/protobuf/jsonpb"
+	jaeger "github.com/jaegertracing/jaeger/model"
+	v1_common "github.com/open-telemetry/opentelemetry-proto/gen/go/common/v1"
+	v1_resource "github.com/open-telemetry/opentelemetry-proto/gen/go/resource/v1"
+	v1 "github.com/open-telemetry/opentelemetry-proto/gen/go/trace/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/atomic"
+
+	"github.com/grafana/tempo/pkg/tempopb"
+)
+
+func TestGetWithRetries(t *testing.T) {
+	tests := []struct {
+		name           string
+		failures       int32
+		failStatus     int
+		maxRetries     int
+		expectedStatus int
+		expectedCalls  int32
+	}{
+		{
+			name:           "retried 5xx",
+			failures:       2,
+			failStatus:     http.StatusServiceUnavailable,
+			maxRetries:     2,
+			expectedStatus: http.StatusOK,
+			expectedCalls:  3,
+		},
+		{
+			name:           "retries exhausted",
+			failures:       5,
+			failStatus:     http.StatusInternalServerError,
+			maxRetries:     1,
+			expectedStatus: http.StatusInternalServerError,
+			expectedCalls:  2,
+		},
+		{
+			name:           "4xx not retried",
+			failures:       1,
+			failStatus:     http.StatusNotFound,
+			maxRetries:     2,
+			expectedStatus: http.StatusNotFound,
+			expectedCalls:  1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := atomic.NewInt32(0)
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if calls.Inc() <= tt.failures {
+					w.WriteHeader(tt.failStatus)
+					return
+				}
+				w.WriteHeader(http.StatusOK)
+			}))
+			defer srv.Close()
+
+			b := &Backend{maxRetries: tt.maxRetries}
+			resp, err := b.getWithRetries(context.Background(), srv.URL)
+			assert.NoError(t, err)
+			resp.Body.Close()
+
+			assert.Equal(t, tt.expectedStatus, resp.StatusCode)
+			assert.Equal(t, tt.expectedCalls, calls.Load())
+		})
+	}
+}
+
+func TestGetWithRetriesConnectionError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	url := srv.URL
+	srv.Close()
+
+	b := &Backend{maxRetries: 2}
+	_, err := b.getWithRetries(context.Background(), url)
+	assert.Error(t, err)
+}
+
+func TestGetTraceKeepsTagTypes(t *testing.T) {
+	traceID := []byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0A, 0x0B, 0x0C, 0x0D, 0x0E, 0x0F, 0x10}
+	trace := &tempopb.Trace{
+		Batches: []*v1.ResourceSpans{{
+			Resource: &v1_resource.Resource{
+				Attributes: []*v1_common.KeyValue{
+					{Key: "service.name", Value: &v1_common.AnyValue{Value: &v1_common.AnyValue_StringValue{StringValue: "svc"}}},
+				},
+			},
+			InstrumentationLibrarySpans: []*v1.InstrumentationLibrarySpans{{
+				Spans: []*v1.Span{{
+					TraceId: traceID,
+					SpanId:  []byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08},
+					Name:    "span",
+					Attributes: []*v1_common.KeyValue{
+						{Key: "int", Value: &v1_common.AnyValue{Value: &v1_common.AnyValue_IntValue{IntValue: 1<<53 + 1}}},
+						{Key: "double", Value: &v1_common.AnyValue{Value: &v1_common.AnyValue_DoubleValue{DoubleValue: 1.5}}},
+						{Key: "bool", Value: &v1_common.AnyValue{Value: &v1_common.AnyValue_BoolValue{BoolValue: true}}},
+					},
+				}},
+			}},
+		}},
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, (&jsonpb.Marshaler{}).Marshal(w, trace))
+	}))
+	defer srv.Close()
+
+	b := &Backend{tempoEndpoint: srv.URL + "/api/traces/"}
+	actual, err := b.GetTrace(context.Background(), jaeger.NewTraceID(0x0102030405060708, 0x090A0B0C0D0E0F10))
+	require.NoError(t, err)
+	require.Len(t, actual.Spans, 1)
+
+	tags := map[string]*jaeger.KeyValue{}
+	for i, tag := range actual.Spans[0].Tags {
+		tags[tag.Key] = &actual.Spans[0].Tags[i]
+	}
+	assert.Equal(t, jaeger.Int64Type, tags["int"].VType)
+	assert.Equal(t, int64(1<<53+1), tags["int"].Int64())
+	assert.Equal(t, jaeger.Float64Type, tags["double"].VType)
+	assert.Equal(t, 1.5, tags["double"].Float64())
+	assert.Equal(t, jaeger.BoolType, tags["bool"].VType)
+	assert.True(t, tags["bool"].Bool())
+}
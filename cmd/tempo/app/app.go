@@ -3,10 +3,16 @@ package app
 import (
 	"bytes"
 	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"net/http"
+	"sort"
+	"strings"
+	"time"
 
+	"github.com/NYTimes/gziphandler"
+	cortex_frontend "github.com/cortexproject/cortex/pkg/querier/frontend"
 	"github.com/cortexproject/cortex/pkg/ring"
 	"github.com/cortexproject/cortex/pkg/ring/kv/memberlist"
 	"github.com/cortexproject/cortex/pkg/util"
@@ -15,20 +21,29 @@ import (
 	"github.com/cortexproject/cortex/pkg/util/modules"
 	"github.com/cortexproject/cortex/pkg/util/services"
 	"github.com/go-kit/kit/log/level"
-
+	"github.com/gorilla/mux"
+	"github.com/weaveworks/common/logging"
 	"github.com/weaveworks/common/middleware"
 	"github.com/weaveworks/common/server"
 	"github.com/weaveworks/common/signals"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/health/grpc_health_v1"
 
+	"github.com/grafana/tempo/modules/blockgateway"
 	"github.com/grafana/tempo/modules/compactor"
 	"github.com/grafana/tempo/modules/distributor"
+	"github.com/grafana/tempo/modules/federation"
+	"github.com/grafana/tempo/modules/frontend"
+	"github.com/grafana/tempo/modules/generator"
 	"github.com/grafana/tempo/modules/ingester"
 	ingester_client "github.com/grafana/tempo/modules/ingester/client"
+	"github.com/grafana/tempo/modules/jaeger"
 	"github.com/grafana/tempo/modules/overrides"
 	"github.com/grafana/tempo/modules/querier"
 	"github.com/grafana/tempo/modules/storage"
+	tempo_api_v1 "github.com/grafana/tempo/pkg/api/v1"
+	"github.com/grafana/tempo/pkg/tempopb"
+	"github.com/grafana/tempo/pkg/tenantmetrics"
 	tempo_util "github.com/grafana/tempo/pkg/util"
 )
 
@@ -39,16 +54,34 @@ type Config struct {
 	Target      string `yaml:"target,omitempty"`
 	AuthEnabled bool   `yaml:"auth_enabled,omitempty"`
 	HTTPPrefix  string `yaml:"http_prefix"`
+	// StopHTTPFirst stops the http server as soon as shutdown begins.  The grpc server always stops last.
+	StopHTTPFirst bool `yaml:"stop_http_first"`
+	// Unix domain sockets served in addition to the tcp listeners, e.g. for sidecars.  Disabled if empty.
+	HTTPListenSocket string `yaml:"http_listen_socket"`
+	GRPCListenSocket string `yaml:"grpc_listen_socket"`
+	// ResponseCompression gzips query API responses for clients that accept it.
+	ResponseCompression bool `yaml:"response_compression_enabled"`
+	// GRPCReflection serves gRPC server reflection so clients can discover the services without their proto files.
+	GRPCReflection bool `yaml:"grpc_reflection_enabled"`
+	// ModuleDependencies replaces the dependencies of the modules listed, e.g. to add the metrics generator to all.
+	ModuleDependencies map[string][]string `yaml:"module_dependencies,omitempty"`
 
 	Server         server.Config          `yaml:"server,omitempty"`
+	InternalServer InternalServerConfig   `yaml:"internal_server,omitempty"`
 	Distributor    distributor.Config     `yaml:"distributor,omitempty"`
 	IngesterClient ingester_client.Config `yaml:"ingester_client,omitempty"`
 	Querier        querier.Config         `yaml:"querier,omitempty"`
+	Frontend       frontend.Config        `yaml:"query_frontend,omitempty"`
 	Compactor      compactor.Config       `yaml:"compactor,omitempty"`
+	BlockGateway   blockgateway.Config    `yaml:"block_gateway,omitempty"`
+	Generator      generator.Config       `yaml:"metrics_generator,omitempty"`
+	Federation     federation.Config      `yaml:"federation,omitempty"`
+	JaegerStorage  jaeger.Config          `yaml:"jaeger_storage,omitempty"`
 	Ingester       ingester.Config        `yaml:"ingester,omitempty"`
 	StorageConfig  storage.Config         `yaml:"storage,omitempty"`
 	LimitsConfig   overrides.Limits       `yaml:"overrides,omitempty"`
 	MemberlistKV   memberlist.KVConfig    `yaml:"memberlist,omitempty"`
+	TenantMetrics  tenantmetrics.Config   `yaml:"tenant_metrics,omitempty"`
 }
 
 // RegisterFlagsAndApplyDefaults registers flag.
@@ -61,8 +94,25 @@ func (c *Config) RegisterFlagsAndApplyDefaults(prefix string, f *flag.FlagSet) {
 	// Server settings
 	flagext.DefaultValues(&c.Server)
 	c.Server.LogLevel.RegisterFlags(f)
+	f.StringVar(&c.Server.HTTPListenAddress, "server.http-listen-address", "", "HTTP server listen address. Use :: to listen on IPv4 and IPv6.")
 	f.IntVar(&c.Server.HTTPListenPort, "server.http-listen-port", 80, "HTTP server listen port.")
+	f.StringVar(&c.HTTPListenSocket, "server.http-listen-socket", "", "Path of a unix domain socket the HTTP server also listens on.")
+	f.StringVar(&c.Server.GRPCListenAddress, "server.grpc-listen-address", "", "gRPC server listen address. Use :: to listen on IPv4 and IPv6.")
 	f.IntVar(&c.Server.GRPCListenPort, "server.grpc-listen-port", 9095, "gRPC server listen port.")
+	f.StringVar(&c.GRPCListenSocket, "server.grpc-listen-socket", "", "Path of a unix domain socket the gRPC server also listens on.")
+	f.StringVar(&c.Server.HTTPTLSConfig.TLSCertPath, "server.http-tls-cert-path", "", "HTTP server cert path.")
+	f.StringVar(&c.Server.HTTPTLSConfig.TLSKeyPath, "server.http-tls-key-path", "", "HTTP server key path.")
+	f.StringVar(&c.Server.HTTPTLSConfig.ClientAuth, "server.http-tls-client-auth", "", "HTTP TLS Client Auth type.")
+	f.StringVar(&c.Server.HTTPTLSConfig.ClientCAs, "server.http-tls-ca-path", "", "HTTP TLS Client CA path.")
+	f.StringVar(&c.Server.GRPCTLSConfig.TLSCertPath, "server.grpc-tls-cert-path", "", "GRPC TLS server cert path.")
+	f.StringVar(&c.Server.GRPCTLSConfig.TLSKeyPath, "server.grpc-tls-key-path", "", "GRPC TLS server key path.")
+	f.StringVar(&c.Server.GRPCTLSConfig.ClientAuth, "server.grpc-tls-client-auth", "", "GRPC TLS Client Auth type.")
+	f.StringVar(&c.Server.GRPCTLSConfig.ClientCAs, "server.grpc-tls-ca-path", "", "GRPC TLS Client CA path.")
+	f.DurationVar(&c.Server.ServerGracefulShutdownTimeout, "server.graceful-shutdown-timeout", 30*time.Second, "Timeout for graceful shutdowns of each of the http and grpc servers.")
+	f.BoolVar(&c.StopHTTPFirst, "server.stop-http-first", false, "Stop the http server as soon as shutdown begins rather than after all modules have stopped.")
+	f.BoolVar(&c.ResponseCompression, "server.response-compression-enabled", true, "Gzip query API responses for clients that send Accept-Encoding: gzip.")
+	f.BoolVar(&c.GRPCReflection, "server.grpc-reflection-enabled", false, "Serve gRPC server reflection.")
+	c.InternalServer.RegisterFlagsAndApplyDefaults(tempo_util.PrefixConfig(prefix, "internal-server"), f)
 
 	// Memberlist settings
 	fs := flag.NewFlagSet("", flag.PanicOnError)
@@ -78,9 +128,23 @@ func (c *Config) RegisterFlagsAndApplyDefaults(prefix string, f *flag.FlagSet) {
 	c.Distributor.RegisterFlagsAndApplyDefaults(tempo_util.PrefixConfig(prefix, "distributor"), f)
 	c.Ingester.RegisterFlagsAndApplyDefaults(tempo_util.PrefixConfig(prefix, "ingester"), f)
 	c.Querier.RegisterFlagsAndApplyDefaults(tempo_util.PrefixConfig(prefix, "querier"), f)
+	c.Frontend.RegisterFlagsAndApplyDefaults(tempo_util.PrefixConfig(prefix, "query-frontend"), f)
 	c.Compactor.RegisterFlagsAndApplyDefaults(tempo_util.PrefixConfig(prefix, "compactor"), f)
+	c.BlockGateway.RegisterFlagsAndApplyDefaults(tempo_util.PrefixConfig(prefix, "block-gateway"), f)
+	c.Generator.RegisterFlagsAndApplyDefaults(tempo_util.PrefixConfig(prefix, "metrics-generator"), f)
+	c.Federation.RegisterFlagsAndApplyDefaults(tempo_util.PrefixConfig(prefix, "federation"), f)
+	c.JaegerStorage.RegisterFlagsAndApplyDefaults(tempo_util.PrefixConfig(prefix, "jaeger-storage"), f)
 	c.StorageConfig.RegisterFlagsAndApplyDefaults(tempo_util.PrefixConfig(prefix, "storage"), f)
+	c.TenantMetrics.RegisterFlags(tempo_util.PrefixConfig(prefix, "tenant-metrics"), f)
+
+}
 
+// DefaultConfig returns the config of a Tempo started without flags or a config file, as a base for programs embedding
+// Tempo.
+func DefaultConfig() Config {
+	var cfg Config
+	cfg.RegisterFlagsAndApplyDefaults("", flag.NewFlagSet("", flag.ContinueOnError))
+	return cfg
 }
 
 // CheckConfig checks if config values are suspect.
@@ -101,23 +165,77 @@ func (c *Config) CheckConfig() {
 	}
 }
 
+// Validate returns an error listing every contradictory setting in the config.  Unlike CheckConfig, which only warns
+// about suspect values, Tempo refuses to start with an invalid config.
+func (c *Config) Validate() error {
+	var errs tempo_util.MultiError
+
+	switch c.Target {
+	case All, Distributor, Ingester, Querier, Frontend, Compactor, BlockGateway, Generator, Federation,
+		Ring, Overrides, Server, InternalServer, GatewayRing, GeneratorRing, Store, MemberlistKV:
+	default:
+		errs.Add(fmt.Errorf("target: unknown target %q", c.Target))
+	}
+
+	rf := c.Ingester.LifecyclerConfig.RingConfig.ReplicationFactor
+	if rf < 1 {
+		errs.Add(fmt.Errorf("ingester.lifecycler.ring.replication_factor: must be at least 1, got %d", rf))
+	}
+	// an inmemory ring only holds the ingester of this process
+	if c.Ingester.LifecyclerConfig.RingConfig.KVStore.Store == "inmemory" && rf > 1 {
+		errs.Add(fmt.Errorf("ingester.lifecycler.ring.replication_factor: %d is larger than the inmemory ring, which only holds a single ingester", rf))
+	}
+
+	switch c.StorageConfig.Trace.Backend {
+	case "local", "gcs", "s3", "azure", "inmemory":
+	default:
+		errs.Add(fmt.Errorf("storage.trace.backend: unknown backend %q", c.StorageConfig.Trace.Backend))
+	}
+
+	// the last traces of a block can be max_block_duration old by the time the ingester flushes it
+	if c.Compactor.Compactor.BlockRetention <= c.Ingester.MaxBlockDuration {
+		errs.Add(fmt.Errorf("compactor.compaction.block_retention: %s must be longer than ingester.max_block_duration (%s) or blocks are deleted as soon as they are flushed",
+			c.Compactor.Compactor.BlockRetention, c.Ingester.MaxBlockDuration))
+	}
+
+	if r := c.Distributor.Sampling.Ratio; r <= 0 || r > 1 {
+		errs.Add(fmt.Errorf("distributor.sampling.ratio: must be in (0, 1], got %g", r))
+	}
+
+	if err := c.TenantMetrics.Validate(); err != nil {
+		errs.Add(fmt.Errorf("tenant_metrics.%w", err))
+	}
+
+	return errs.Err()
+}
+
 // App is the root datastructure.
 type App struct {
 	cfg Config
 
-	server       *server.Server
-	ring         *ring.Ring
-	overrides    *overrides.Overrides
-	distributor  *distributor.Distributor
-	querier      *querier.Querier
-	compactor    *compactor.Compactor
-	ingester     *ingester.Ingester
-	store        storage.Store
-	memberlistKV *memberlist.KVInitService
-
-	httpAuthMiddleware middleware.Interface
-	moduleManager      *modules.Manager
-	serviceMap         map[string]services.Service
+	server        *server.Server
+	internal      *server.Server
+	ring          *ring.Ring
+	overrides     *overrides.Overrides
+	distributor   *distributor.Distributor
+	querier       *querier.Querier
+	frontend      *cortex_frontend.Frontend
+	compactor     *compactor.Compactor
+	blockGateway  *blockgateway.Gateway
+	federation    *federation.Federation
+	gatewayRing   *ring.Ring
+	generator     *generator.Generator
+	generatorRing *ring.Ring
+	ingester      *ingester.Ingester
+	store         storage.Store
+	memberlistKV  *memberlist.KVInitService
+
+	httpAuthMiddleware        middleware.Interface
+	httpCompressionMiddleware middleware.Interface
+	moduleManager             *modules.Manager
+	deps                      map[string][]string
+	serviceMap                map[string]services.Service
+	serviceManager            *services.Manager
 }
 
 // New makes a new app.
@@ -127,6 +245,8 @@ func New(cfg Config) (*App, error) {
 	}
 
 	app.setupAuthMiddleware()
+	app.setupCompressionMiddleware()
+	tenantmetrics.Configure(cfg.TenantMetrics)
 
 	if err := app.setupModuleManager(); err != nil {
 		return nil, fmt.Errorf("failed to setup module manager %w", err)
@@ -157,13 +277,30 @@ func (t *App) setupAuthMiddleware() {
 	}
 }
 
-// Run starts, and blocks until a signal is received.
-func (t *App) Run() error {
+// setupCompressionMiddleware compresses the responses of the query API.  Responses smaller than a packet are sent as is.
+func (t *App) setupCompressionMiddleware() {
+	t.httpCompressionMiddleware = middleware.Identity
+	if t.cfg.ResponseCompression {
+		t.httpCompressionMiddleware = middleware.Func(gziphandler.GzipHandler)
+	}
+}
+
+// Start initialises the modules of the target and starts them without blocking.  It may only be called once.  Unlike
+// Run it doesn't handle signals, so programs embedding Tempo stop it with Stop.
+func (t *App) Start(ctx context.Context) error {
+	if err := t.setupDependencies(); err != nil {
+		return err
+	}
+
 	if !t.moduleManager.IsUserVisibleModule(t.cfg.Target) {
 		level.Warn(util.Logger).Log("msg", "selected target is an internal module, is this intended?", "target", t.cfg.Target)
 	}
 
 	serviceMap, err := t.moduleManager.InitModuleServices(t.cfg.Target)
+	var modErr *moduleError
+	if errors.As(err, &modErr) {
+		return t.initError(modErr.module, modErr.err)
+	}
 	if err != nil {
 		return fmt.Errorf("failed to init module services %w", err)
 	}
@@ -179,9 +316,24 @@ func (t *App) Run() error {
 		return fmt.Errorf("failed to start service manager %w", err)
 	}
 
-	// before starting servers, register /ready handler and gRPC health check service.
-	t.server.HTTP.Path("/ready").Handler(t.readyHandler(sm))
-	grpc_health_v1.RegisterHealthServer(t.server.GRPC, healthcheck.New(sm))
+	t.serviceManager = sm
+
+	// before starting servers, register /ready handler and gRPC health check service.  Modules added by programs
+	// embedding Tempo may run without the server.
+	if t.server != nil {
+		t.server.HTTP.Path("/ready").Handler(t.readyHandler(sm))
+		t.server.HTTP.Path("/ready/{module}").Handler(t.moduleReadyHandler())
+		t.internal.HTTP.Path("/services").Handler(t.servicesHandler())
+		grpc_health_v1.RegisterHealthServer(t.server.GRPC, healthcheck.New(sm))
+		if t.cfg.GRPCReflection {
+			registerReflection(t.server.GRPC)
+		}
+	}
+	if t.internal != nil && t.internal != t.server {
+		t.internal.HTTP.Path("/ready").Handler(t.readyHandler(sm))
+		t.internal.HTTP.Path("/ready/{module}").Handler(t.moduleReadyHandler())
+		grpc_health_v1.RegisterHealthServer(t.internal.GRPC, healthcheck.New(sm))
+	}
 
 	// Let's listen for events from this manager, and log them.
 	healthy := func() { level.Info(util.Logger).Log("msg", "Tempo started") }
@@ -196,7 +348,8 @@ func (t *App) Run() error {
 				if service.FailureCase() == util.ErrStopProcess {
 					level.Info(util.Logger).Log("msg", "received stop signal via return error", "module", m, "err", service.FailureCase())
 				} else {
-					level.Error(util.Logger).Log("msg", "module failed", "module", m, "err", service.FailureCase())
+					level.Error(util.Logger).Log("msg", "module failed", "module", m, "dependency_chain", strings.Join(dependencyChain(t.deps, t.cfg.Target, m), " -> "),
+						"config", moduleConfigs[m], "err", service.FailureCase())
 				}
 				return
 			}
@@ -206,21 +359,77 @@ func (t *App) Run() error {
 	}
 	sm.AddListener(services.NewManagerListener(healthy, stopped, serviceFailed))
 
+	// Start all services. This can really only fail if some service is already
+	// in other state than New, which should not be the case.
+	err = sm.StartAsync(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to start service manager %w", err)
+	}
+
+	return nil
+}
+
+// Stop stops every module without waiting for them to terminate.
+func (t *App) Stop() {
+	if t.serviceManager != nil {
+		t.serviceManager.StopAsync()
+	}
+}
+
+// Wait blocks until every module has stopped.  It returns an error if Start wasn't called.
+func (t *App) Wait(ctx context.Context) error {
+	if t.serviceManager == nil {
+		return fmt.Errorf("tempo was not started")
+	}
+	return t.serviceManager.AwaitStopped(ctx)
+}
+
+// Run starts, and blocks until a signal is received.
+func (t *App) Run() error {
+	if err := t.Start(context.Background()); err != nil {
+		return err
+	}
+
 	// Setup signal handler. If signal arrives, we stop the manager, which stops all the services.
-	handler := signals.NewHandler(t.server.Log)
+	handler := signals.NewHandler(logging.GoKit(util.Logger))
 	go func() {
 		handler.Loop()
-		sm.StopAsync()
+		t.Stop()
 	}()
 
-	// Start all services. This can really only fail if some service is already
-	// in other state than New, which should not be the case.
-	err = sm.StartAsync(context.Background())
-	if err != nil {
-		return fmt.Errorf("failed to start service manager %w", err)
+	return t.Wait(context.Background())
+}
+
+// Server returns the HTTP and gRPC server, which other modules register their handlers and services on.  It's nil
+// until Start and if the target doesn't run the server.  The same holds for the other accessors.
+func (t *App) Server() *server.Server {
+	return t.server
+}
+
+// Distributor returns the distributor, which pushes batches to the ingesters.
+func (t *App) Distributor() tempopb.PusherServer {
+	if t.distributor == nil {
+		return nil
 	}
+	return t.distributor
+}
 
-	return sm.AwaitStopped(context.Background())
+// Querier returns the querier, which finds traces in the ingesters and the store.
+func (t *App) Querier() tempo_api_v1.TraceFinder {
+	if t.querier == nil {
+		return nil
+	}
+	return t.querier
+}
+
+// Store returns the trace store, which modules added by programs embedding Tempo can write blocks to directly.
+func (t *App) Store() storage.Store {
+	return t.store
+}
+
+// Overrides returns the per tenant limits.
+func (t *App) Overrides() *overrides.Overrides {
+	return t.overrides
 }
 
 func (t *App) readyHandler(sm *services.Manager) http.HandlerFunc {
@@ -229,9 +438,10 @@ func (t *App) readyHandler(sm *services.Manager) http.HandlerFunc {
 			msg := bytes.Buffer{}
 			msg.WriteString("Some services are not Running:\n")
 
-			byState := sm.ServicesByState()
-			for st, ls := range byState {
-				msg.WriteString(fmt.Sprintf("%v: %d\n", st, len(ls)))
+			for _, m := range t.sortedModules() {
+				if s := t.serviceMap[m]; s.State() != services.Running {
+					msg.WriteString(fmt.Sprintf("%s: %v\n", m, s.State()))
+				}
 			}
 
 			http.Error(w, msg.String(), http.StatusServiceUnavailable)
@@ -250,3 +460,53 @@ func (t *App) readyHandler(sm *services.Manager) http.HandlerFunc {
 		http.Error(w, "ready", http.StatusOK)
 	}
 }
+
+// moduleReadyHandler reports whether a single module is running, for probes of components that can serve some
+// traffic before every module is up.
+func (t *App) moduleReadyHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		m := mux.Vars(r)["module"]
+		s, ok := t.serviceMap[m]
+		if !ok {
+			http.Error(w, fmt.Sprintf("module %s is not running in this process", m), http.StatusNotFound)
+			return
+		}
+
+		if s.State() != services.Running {
+			http.Error(w, serviceDescription(m, s), http.StatusServiceUnavailable)
+			return
+		}
+
+		http.Error(w, "ready", http.StatusOK)
+	}
+}
+
+// servicesHandler lists every module of the process with its state and, if it failed, why.
+func (t *App) servicesHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, _ *http.Request) {
+		msg := bytes.Buffer{}
+		for _, m := range t.sortedModules() {
+			msg.WriteString(serviceDescription(m, t.serviceMap[m]))
+			msg.WriteString("\n")
+		}
+
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		_, _ = w.Write(msg.Bytes())
+	}
+}
+
+func (t *App) sortedModules() []string {
+	ms := make([]string, 0, len(t.serviceMap))
+	for m := range t.serviceMap {
+		ms = append(ms, m)
+	}
+	sort.Strings(ms)
+	return ms
+}
+
+func serviceDescription(module string, s services.Service) string {
+	if err := s.FailureCase(); err != nil {
+		return fmt.Sprintf("%s: %v: %v", module, s.State(), err)
+	}
+	return fmt.Sprintf("%s: %v", module, s.State())
+}
@@ -0,0 +1,168 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/cortexproject/cortex/pkg/util/services"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEmbeddedModule(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Target = "edge"
+
+	tempo, err := New(cfg)
+	require.NoError(t, err)
+
+	var overridesAtInit bool
+	err = tempo.RegisterModule("edge", func() (services.Service, error) {
+		overridesAtInit = tempo.Overrides() != nil
+		return services.NewIdleService(nil, nil), nil
+	}, Overrides)
+	require.NoError(t, err)
+	assert.Error(t, tempo.RegisterModule("edge", nil))
+	assert.Error(t, tempo.RegisterModule(Distributor, nil))
+
+	require.NoError(t, tempo.Start(context.Background()))
+	assert.True(t, overridesAtInit)
+	assert.Error(t, tempo.RegisterModule("late", nil))
+
+	// only the target and its dependencies run
+	assert.Nil(t, tempo.Server())
+	assert.Nil(t, tempo.Distributor())
+	assert.Nil(t, tempo.Querier())
+
+	tempo.Stop()
+	require.NoError(t, tempo.Wait(context.Background()))
+}
+
+func TestWaitBeforeStart(t *testing.T) {
+	tempo, err := New(DefaultConfig())
+	require.NoError(t, err)
+	assert.Error(t, tempo.Wait(context.Background()))
+}
+
+func TestInternalServer(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Target = Server
+	cfg.Server.HTTPListenPort = 0
+	cfg.Server.GRPCListenPort = 0
+	cfg.InternalServer.Enabled = true
+	cfg.InternalServer.HTTPListenPort = 0
+	cfg.InternalServer.GRPCListenPort = 0
+
+	tempo, err := New(cfg)
+	require.NoError(t, err)
+	withTestRegistry(t)
+	require.NoError(t, tempo.Start(context.Background()))
+	require.NotNil(t, tempo.internal)
+	assert.NotEqual(t, tempo.server, tempo.internal)
+
+	get := func(router http.Handler, path string) int {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		return rec.Code
+	}
+	assert.Equal(t, http.StatusNotFound, get(tempo.server.HTTP, "/metrics"))
+	assert.Equal(t, http.StatusOK, get(tempo.internal.HTTP, "/metrics"))
+	assert.Equal(t, http.StatusNotFound, get(tempo.server.HTTP, "/services"))
+	assert.Equal(t, http.StatusOK, get(tempo.internal.HTTP, "/services"))
+
+	tempo.Stop()
+	require.NoError(t, tempo.Wait(context.Background()))
+}
+
+func TestOverridesExporterTarget(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Target = OverridesExporter
+	cfg.Server.HTTPListenPort = 0
+	cfg.Server.GRPCListenPort = 0
+	cfg.InternalServer.Enabled = true
+	cfg.InternalServer.HTTPListenPort = 0
+	cfg.InternalServer.GRPCListenPort = 0
+	cfg.AuthEnabled = true
+
+	tempo, err := New(cfg)
+	require.NoError(t, err)
+	withTestRegistry(t)
+	require.NoError(t, tempo.Start(context.Background()))
+
+	get := func(router http.Handler, path string, orgID string) int {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if orgID != "" {
+			req.Header.Set("X-Scope-OrgID", orgID)
+		}
+		router.ServeHTTP(rec, req)
+		return rec.Code
+	}
+	// every tenant is only listed on the internal server
+	assert.Equal(t, http.StatusNotFound, get(tempo.server.HTTP, "/api/admin/overrides", ""))
+	assert.Equal(t, http.StatusOK, get(tempo.internal.HTTP, "/api/admin/overrides", ""))
+	// tenants need their org id for their own limits
+	assert.Equal(t, http.StatusUnauthorized, get(tempo.server.HTTP, "/api/status/overrides", ""))
+	assert.Equal(t, http.StatusOK, get(tempo.server.HTTP, "/api/status/overrides", "test"))
+
+	tempo.Stop()
+	require.NoError(t, tempo.Wait(context.Background()))
+}
+
+func TestReadyHandlers(t *testing.T) {
+	running := services.NewIdleService(nil, nil)
+	starting := services.NewIdleService(nil, nil)
+	failed := services.NewIdleService(func(context.Context) error {
+		return errors.New("disk full")
+	}, nil)
+
+	tempo := &App{serviceMap: map[string]services.Service{
+		"running":  running,
+		"starting": starting,
+		"failed":   failed,
+	}}
+	sm, err := services.NewManager(running, starting, failed)
+	require.NoError(t, err)
+
+	require.NoError(t, services.StartAndAwaitRunning(context.Background(), running))
+	assert.Error(t, services.StartAndAwaitRunning(context.Background(), failed))
+	defer services.StopAndAwaitTerminated(context.Background(), running) // nolint:errcheck
+
+	router := mux.NewRouter()
+	router.Path("/ready").Handler(tempo.readyHandler(sm))
+	router.Path("/ready/{module}").Handler(tempo.moduleReadyHandler())
+	router.Path("/services").Handler(tempo.servicesHandler())
+	get := func(path string) (int, string) {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		return rec.Code, rec.Body.String()
+	}
+
+	// modules that aren't running are listed
+	code, body := get("/ready")
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, "Some services are not Running:\nfailed: Failed\nstarting: New\n\n", body)
+
+	code, body = get("/ready/running")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "ready\n", body)
+
+	code, body = get("/ready/starting")
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, "starting: New\n", body)
+
+	code, body = get("/ready/failed")
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, "failed: Failed: disk full\n", body)
+
+	code, _ = get("/ready/unknown")
+	assert.Equal(t, http.StatusNotFound, code)
+
+	code, body = get("/services")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "failed: Failed: disk full\nrunning: Running\nstarting: New\n", body)
+}
@@ -0,0 +1,67 @@
+package app
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/cortexproject/cortex/pkg/cortex"
+	"github.com/cortexproject/cortex/pkg/util/services"
+	"github.com/weaveworks/common/server"
+
+	tempo_util "github.com/grafana/tempo/pkg/util"
+)
+
+// InternalServerConfig configures a second server for the endpoints operators and other components use, so they can be
+// firewalled separately from the public push and query APIs.
+type InternalServerConfig struct {
+	Enabled           bool   `yaml:"enabled"`
+	HTTPListenAddress string `yaml:"http_listen_address"`
+	HTTPListenPort    int    `yaml:"http_listen_port"`
+	GRPCListenAddress string `yaml:"grpc_listen_address"`
+	GRPCListenPort    int    `yaml:"grpc_listen_port"`
+}
+
+// RegisterFlagsAndApplyDefaults registers the flags.
+func (cfg *InternalServerConfig) RegisterFlagsAndApplyDefaults(prefix string, f *flag.FlagSet) {
+	f.BoolVar(&cfg.Enabled, prefix+".enabled", false, "Serve the admin, ring and metrics endpoints and the gRPC services between components on separate listeners.")
+	f.StringVar(&cfg.HTTPListenAddress, prefix+".http-listen-address", "", "Internal HTTP server listen address.")
+	f.IntVar(&cfg.HTTPListenPort, prefix+".http-listen-port", 3101, "Internal HTTP server listen port.")
+	f.StringVar(&cfg.GRPCListenAddress, prefix+".grpc-listen-address", "", "Internal gRPC server listen address.")
+	f.IntVar(&cfg.GRPCListenPort, prefix+".grpc-listen-port", 9096, "Internal gRPC server listen port.")
+}
+
+// initInternalServer creates the internal server if it's enabled.  It shares the settings of the server except for its
+// listeners, and its request metrics are prefixed with tempo_internal.  Without it the internal endpoints are served
+// by the server.
+func (t *App) initInternalServer() (services.Service, error) {
+	if !t.cfg.InternalServer.Enabled {
+		return nil, nil
+	}
+
+	cfg := t.cfg.Server
+	cfg.MetricsNamespace = metricsNamespace + "_internal"
+	cfg.ExcludeRequestInLog = true
+	cfg.RegisterInstrumentation = true
+	cfg.HTTPListenAddress = tempo_util.ListenHost(t.cfg.InternalServer.HTTPListenAddress)
+	cfg.HTTPListenPort = t.cfg.InternalServer.HTTPListenPort
+	cfg.GRPCListenAddress = tempo_util.ListenHost(t.cfg.InternalServer.GRPCListenAddress)
+	cfg.GRPCListenPort = t.cfg.InternalServer.GRPCListenPort
+	cortex.DisableSignalHandling(&cfg)
+
+	internal, err := server.New(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create internal server %w", err)
+	}
+	t.internal = internal
+
+	return newServerService(internal, cfg, serverSockets{}, false, t.servicesToWaitFor), nil
+}
+
+// internalGRPCListenPort is the port other components reach the gRPC services of this process on, which is advertised
+// in the rings.
+func (t *App) internalGRPCListenPort() int {
+	if t.cfg.InternalServer.Enabled {
+		return t.cfg.InternalServer.GRPCListenPort
+	}
+	return t.cfg.Server.GRPCListenPort
+}
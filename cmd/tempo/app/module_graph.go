@@ -0,0 +1,133 @@
+package app
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// moduleConfigs are the config blocks of the modules.  Modules registered by programs embedding Tempo have none.
+var moduleConfigs = map[string]string{
+	Server:            "server",
+	InternalServer:    "internal_server",
+	MemberlistKV:      "memberlist",
+	Ring:              "ingester.lifecycler.ring",
+	Overrides:         "overrides",
+	OverridesExporter: "overrides",
+	Distributor:       "distributor",
+	Ingester:          "ingester",
+	Querier:           "querier",
+	Frontend:          "query_frontend",
+	Compactor:         "compactor",
+	Federation:        "federation",
+	BlockGateway:      "block_gateway",
+	GatewayRing:       "block_gateway.ring",
+	Generator:         "metrics_generator",
+	GeneratorRing:     "metrics_generator.ring",
+	Store:             "storage",
+}
+
+// moduleError is an error returned by the init function of a module.
+type moduleError struct {
+	module string
+	err    error
+}
+
+func (e *moduleError) Error() string {
+	return e.err.Error()
+}
+
+func (e *moduleError) Unwrap() error {
+	return e.err
+}
+
+// initError describes the failure of a module of the target: the modules through which the target depends on it, and
+// the config block it's configured by.
+func (t *App) initError(module string, err error) error {
+	msg := fmt.Sprintf("failed to init module %s (%s)", module, strings.Join(dependencyChain(t.deps, t.cfg.Target, module), " -> "))
+	if block, ok := moduleConfigs[module]; ok {
+		msg += fmt.Sprintf(", check the %s config block", block)
+	}
+	return fmt.Errorf("%s: %w", msg, err)
+}
+
+// WriteModules writes every module with its config block and dependencies, and the modules run by the target with every
+// module after its dependencies.  module_dependencies is applied.
+func (t *App) WriteModules(w io.Writer) error {
+	deps, err := t.moduleDependencies()
+	if err != nil {
+		return err
+	}
+	if _, ok := deps[t.cfg.Target]; !ok {
+		return fmt.Errorf("unknown target %s", t.cfg.Target)
+	}
+
+	mods := make([]string, 0, len(deps))
+	for mod := range deps {
+		mods = append(mods, mod)
+	}
+	sort.Strings(mods)
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "MODULE\tCONFIG\tDEPENDENCIES")
+	for _, mod := range mods {
+		block := moduleConfigs[mod]
+		if block == "" {
+			block = "-"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", mod, block, strings.Join(deps[mod], ", "))
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(w, "\nmodules of target %s: %s\n", t.cfg.Target, strings.Join(initOrder(deps, t.cfg.Target), ", "))
+	return err
+}
+
+// dependencyChain returns the shortest chain of modules from target to module, both included.  It returns just the
+// module if target doesn't depend on it.
+func dependencyChain(deps map[string][]string, target string, module string) []string {
+	parents := map[string]string{target: ""}
+	queue := []string{target}
+	for len(queue) > 0 {
+		mod := queue[0]
+		queue = queue[1:]
+		if mod == module {
+			var chain []string
+			for ; mod != ""; mod = parents[mod] {
+				chain = append([]string{mod}, chain...)
+			}
+			return chain
+		}
+		for _, dep := range deps[mod] {
+			if _, ok := parents[dep]; !ok {
+				parents[dep] = mod
+				queue = append(queue, dep)
+			}
+		}
+	}
+	return []string{module}
+}
+
+// initOrder returns the target and the modules it depends on, every module after its dependencies.  Dependencies are
+// visited in the order they're listed, so the order is stable.
+func initOrder(deps map[string][]string, target string) []string {
+	var order []string
+	seen := map[string]bool{}
+	var visit func(mod string)
+	visit = func(mod string) {
+		if seen[mod] {
+			return
+		}
+		seen[mod] = true
+		for _, dep := range deps[mod] {
+			visit(dep)
+		}
+		order = append(order, mod)
+	}
+	visit(target)
+	return order
+}
@@ -0,0 +1,74 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/cortexproject/cortex/pkg/util/services"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDependencyChain(t *testing.T) {
+	deps := map[string][]string{
+		All:      {Querier, Ingester},
+		Querier:  {Store, Ring},
+		Ingester: {Store},
+		Store:    {MemberlistKV},
+		Ring:     {MemberlistKV},
+	}
+
+	assert.Equal(t, []string{All, Querier, Store, MemberlistKV}, dependencyChain(deps, All, MemberlistKV))
+	assert.Equal(t, []string{All, Ingester}, dependencyChain(deps, All, Ingester))
+	assert.Equal(t, []string{All}, dependencyChain(deps, All, All))
+	assert.Equal(t, []string{Compactor}, dependencyChain(deps, All, Compactor))
+
+	assert.Equal(t, []string{MemberlistKV, Store, Ring, Querier, Ingester, All}, initOrder(deps, All))
+	assert.Equal(t, []string{MemberlistKV, Store}, initOrder(deps, Store))
+}
+
+func TestWriteModules(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Target = Querier
+	cfg.ModuleDependencies = map[string][]string{GatewayRing: {Server}}
+
+	tempo, err := New(cfg)
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	require.NoError(t, tempo.WriteModules(&buf))
+	lines := strings.Split(buf.String(), "\n")
+	assert.Equal(t, []string{"MODULE", "CONFIG", "DEPENDENCIES"}, strings.Fields(lines[0]))
+	assert.Contains(t, lines, "block-gateway-ring      block_gateway.ring        server")
+	assert.Contains(t, buf.String(), "\nmodules of target querier: memberlist-kv, store, internal-server, server, ring, block-gateway-ring, overrides, querier\n")
+
+	tempo.cfg.Target = "unknown"
+	assert.EqualError(t, tempo.WriteModules(&buf), "unknown target unknown")
+}
+
+func TestStartFailure(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Target = "edge"
+
+	tempo, err := New(cfg)
+	require.NoError(t, err)
+
+	require.NoError(t, tempo.RegisterModule("edge", nil, "collector"))
+	require.NoError(t, tempo.RegisterModule("collector", func() (services.Service, error) {
+		return nil, errors.New("no receivers")
+	}, Overrides))
+	err = tempo.Start(context.Background())
+	assert.EqualError(t, err, "failed to init module collector (edge -> collector): no receivers")
+
+	// modules of Tempo name their config block.  the server isn't a dependency as its metrics can only be registered once
+	cfg.StorageConfig.Trace.Backend = "unknown"
+	tempo, err = New(cfg)
+	require.NoError(t, err)
+	require.NoError(t, tempo.RegisterModule("edge", nil, Store))
+	err = tempo.Start(context.Background())
+	require.Error(t, err)
+	assert.True(t, strings.HasPrefix(err.Error(), "failed to init module store (edge -> store), check the storage config block: "), err.Error())
+}
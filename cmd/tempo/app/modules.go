@@ -4,8 +4,12 @@ import (
 	"fmt"
 	"net/http"
 	"os"
+	"sort"
+	"strings"
 
 	"github.com/cortexproject/cortex/pkg/cortex"
+	cortex_querier "github.com/cortexproject/cortex/pkg/querier"
+	cortex_frontend "github.com/cortexproject/cortex/pkg/querier/frontend"
 	"github.com/cortexproject/cortex/pkg/ring"
 	"github.com/cortexproject/cortex/pkg/ring/kv/codec"
 	"github.com/cortexproject/cortex/pkg/ring/kv/memberlist"
@@ -13,32 +17,49 @@ import (
 	"github.com/cortexproject/cortex/pkg/util/modules"
 	"github.com/cortexproject/cortex/pkg/util/services"
 	"github.com/google/uuid"
+	"github.com/jaegertracing/jaeger/proto-gen/storage_v1"
 	"github.com/prometheus/client_golang/prometheus"
+	httpgrpc_server "github.com/weaveworks/common/httpgrpc/server"
 	"github.com/weaveworks/common/middleware"
 	"github.com/weaveworks/common/server"
 
+	"github.com/grafana/tempo/modules/blockgateway"
 	"github.com/grafana/tempo/modules/compactor"
 	"github.com/grafana/tempo/modules/distributor"
+	"github.com/grafana/tempo/modules/federation"
+	"github.com/grafana/tempo/modules/frontend"
+	"github.com/grafana/tempo/modules/generator"
 	"github.com/grafana/tempo/modules/ingester"
+	"github.com/grafana/tempo/modules/jaeger"
 	"github.com/grafana/tempo/modules/overrides"
 	"github.com/grafana/tempo/modules/querier"
 	tempo_storage "github.com/grafana/tempo/modules/storage"
+	tempo_api_v1 "github.com/grafana/tempo/pkg/api/v1"
 	tempo_ring "github.com/grafana/tempo/pkg/ring"
 	"github.com/grafana/tempo/pkg/tempopb"
+	tempo_util "github.com/grafana/tempo/pkg/util"
 )
 
 // The various modules that make up tempo.
 const (
-	Ring         string = "ring"
-	Overrides    string = "overrides"
-	Server       string = "server"
-	Distributor  string = "distributor"
-	Ingester     string = "ingester"
-	Querier      string = "querier"
-	Compactor    string = "compactor"
-	Store        string = "store"
-	MemberlistKV string = "memberlist-kv"
-	All          string = "all"
+	Ring              string = "ring"
+	Overrides         string = "overrides"
+	OverridesExporter string = "overrides-exporter"
+	Server            string = "server"
+	InternalServer    string = "internal-server"
+	Distributor       string = "distributor"
+	Ingester          string = "ingester"
+	Querier           string = "querier"
+	Frontend          string = "query-frontend"
+	Compactor         string = "compactor"
+	BlockGateway      string = "block-gateway"
+	GatewayRing       string = "block-gateway-ring"
+	Generator         string = "metrics-generator"
+	GeneratorRing     string = "metrics-generator-ring"
+	Federation        string = "federation"
+	Store             string = "store"
+	MemberlistKV      string = "memberlist-kv"
+	All               string = "all"
 )
 
 func (t *App) initServer() (services.Service, error) {
@@ -46,29 +67,54 @@ func (t *App) initServer() (services.Service, error) {
 	t.cfg.Server.ExcludeRequestInLog = true
 
 	cortex.DisableSignalHandling(&t.cfg.Server)
+	// metrics and profiles are internal endpoints
+	if t.cfg.InternalServer.Enabled {
+		t.cfg.Server.RegisterInstrumentation = false
+	}
+
+	t.cfg.Server.HTTPListenAddress = tempo_util.ListenHost(t.cfg.Server.HTTPListenAddress)
+	t.cfg.Server.GRPCListenAddress = tempo_util.ListenHost(t.cfg.Server.GRPCListenAddress)
 
 	server, err := server.New(t.cfg.Server)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create server %w", err)
 	}
 
-	servicesToWaitFor := func() []services.Service {
-		svs := []services.Service(nil)
-		for m, s := range t.serviceMap {
-			// Server should not wait for itself.
-			if m != Server {
-				svs = append(svs, s)
-			}
+	var sockets serverSockets
+	if t.cfg.HTTPListenSocket != "" {
+		sockets.http, err = tempo_util.ListenUnix(t.cfg.HTTPListenSocket)
+		if err != nil {
+			return nil, fmt.Errorf("failed to listen on http socket %w", err)
+		}
+	}
+	if t.cfg.GRPCListenSocket != "" {
+		sockets.grpc, err = tempo_util.ListenUnix(t.cfg.GRPCListenSocket)
+		if err != nil {
+			return nil, fmt.Errorf("failed to listen on grpc socket %w", err)
 		}
-		return svs
 	}
 
 	t.server = server
-	s := cortex.NewServerService(server, servicesToWaitFor)
+	if t.internal == nil {
+		t.internal = server
+	}
+	s := newServerService(server, t.cfg.Server, sockets, t.cfg.StopHTTPFirst, t.servicesToWaitFor)
 
 	return s, nil
 }
 
+// servicesToWaitFor returns the modules the servers keep serving until they have stopped.
+func (t *App) servicesToWaitFor() []services.Service {
+	svs := []services.Service(nil)
+	for m, s := range t.serviceMap {
+		// servers should not wait for themselves or each other.
+		if m != Server && m != InternalServer {
+			svs = append(svs, s)
+		}
+	}
+	return svs
+}
+
 func (t *App) initRing() (services.Service, error) {
 	ring, err := tempo_ring.New(t.cfg.Ingester.LifecyclerConfig.RingConfig, "ingester", t.cfg.Ingester.OverrideRingKey, prometheus.DefaultRegisterer)
 	if err != nil {
@@ -77,83 +123,322 @@ func (t *App) initRing() (services.Service, error) {
 	t.ring = ring
 
 	prometheus.MustRegister(t.ring)
-	t.server.HTTP.Handle("/ingester/ring", t.ring)
+	t.internal.HTTP.Handle("/ingester/ring", tempo_ring.StatusHandler(t.ring, tempo_ring.Settings{
+		HeartbeatPeriod:  t.cfg.Ingester.LifecyclerConfig.HeartbeatPeriod,
+		HeartbeatTimeout: t.cfg.Ingester.LifecyclerConfig.RingConfig.HeartbeatTimeout,
+		ObservePeriod:    t.cfg.Ingester.LifecyclerConfig.ObservePeriod,
+	}))
 
 	return t.ring, nil
 }
 
 func (t *App) initOverrides() (services.Service, error) {
-	overrides, err := overrides.NewOverrides(t.cfg.LimitsConfig)
+	o, err := overrides.NewOverrides(t.cfg.LimitsConfig)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create overrides %w", err)
 	}
-	t.overrides = overrides
+	t.overrides = o
 
 	return t.overrides, nil
 }
 
+func (t *App) initOverridesExporter() (services.Service, error) {
+	exporter := overrides.NewExporter(t.overrides, t.cfg.Compactor.Compactor.BlockRetention)
+	prometheus.MustRegister(exporter)
+	t.internal.HTTP.Handle("/api/admin/overrides", exporter)
+
+	tenantHandler := middleware.Merge(
+		t.httpAuthMiddleware,
+	).Wrap(http.HandlerFunc(exporter.TenantHandler))
+	t.server.HTTP.Handle("/api/status/overrides", tenantHandler)
+
+	return nil, nil
+}
+
 func (t *App) initDistributor() (services.Service, error) {
+	var generatorRing ring.ReadRing
+	if t.generatorRing != nil {
+		generatorRing = t.generatorRing
+	}
+
 	// todo: make ingester client a module instead of passing the config everywhere
-	distributor, err := distributor.New(t.cfg.Distributor, t.cfg.IngesterClient, t.ring, t.overrides, t.cfg.AuthEnabled, t.cfg.Server.LogLevel)
+	distributor, err := distributor.New(t.cfg.Distributor, t.cfg.IngesterClient, t.ring, generatorRing, t.overrides, t.cfg.AuthEnabled, t.cfg.Server.LogLevel)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create distributor %w", err)
 	}
 	t.distributor = distributor
 
+	validateHandler := middleware.Merge(
+		t.httpAuthMiddleware,
+	).Wrap(http.HandlerFunc(t.distributor.ValidateHandler))
+	t.server.HTTP.Handle("/api/v1/validate", validateHandler)
+	t.internal.HTTP.HandleFunc("/distributor/firehose", t.distributor.FirehoseHandler)
+
 	if distributor.DistributorRing != nil {
 		prometheus.MustRegister(distributor.DistributorRing)
-		t.server.HTTP.Handle("/distributor/ring", distributor.DistributorRing)
+		t.internal.HTTP.Handle("/distributor/ring", tempo_ring.StatusHandler(distributor.DistributorRing, tempo_ring.Settings{
+			HeartbeatPeriod:  t.cfg.Distributor.DistributorRing.HeartbeatPeriod,
+			HeartbeatTimeout: t.cfg.Distributor.DistributorRing.HeartbeatTimeout,
+		}))
+	}
+
+	tempo_api_v1.RegisterPusherServer(t.server.GRPC, tempo_api_v1.NewPusherServer(t.distributor))
+	if t.cfg.JaegerStorage.Enabled {
+		storage_v1.RegisterSpanWriterPluginServer(t.server.GRPC, jaeger.NewWriter(t.distributor))
 	}
 
 	return t.distributor, nil
 }
 
 func (t *App) initIngester() (services.Service, error) {
-	t.cfg.Ingester.LifecyclerConfig.ListenPort = t.cfg.Server.GRPCListenPort
+	t.cfg.Ingester.LifecyclerConfig.ListenPort = t.internalGRPCListenPort()
 	ingester, err := ingester.New(t.cfg.Ingester, t.store, t.overrides)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create ingester %w", err)
 	}
 	t.ingester = ingester
 
-	tempopb.RegisterPusherServer(t.server.GRPC, t.ingester)
-	tempopb.RegisterQuerierServer(t.server.GRPC, t.ingester)
-	t.server.HTTP.Path("/flush").Handler(http.HandlerFunc(t.ingester.FlushHandler))
+	tempopb.RegisterPusherServer(t.internal.GRPC, t.ingester)
+	tempopb.RegisterQuerierServer(t.internal.GRPC, t.ingester)
+	t.internal.HTTP.Path("/flush").Handler(http.HandlerFunc(t.ingester.FlushHandler))
+	t.internal.HTTP.Path("/ingester/read-only").Handler(http.HandlerFunc(t.ingester.ReadOnlyHandler))
+	t.internal.HTTP.Path("/shutdown").Handler(http.HandlerFunc(t.ingester.ShutdownHandler))
 	return t.ingester, nil
 }
 
 func (t *App) initQuerier() (services.Service, error) {
 	// todo: make ingester client a module instead of passing config everywhere
-	querier, err := querier.New(t.cfg.Querier, t.cfg.IngesterClient, t.ring, t.store, t.overrides)
+	var gatewayRing ring.ReadRing
+	if t.gatewayRing != nil {
+		gatewayRing = t.gatewayRing
+	}
+	// traces in blocks older than the compaction window can no longer change
+	if t.cfg.Querier.ResponseCache.ImmutableAfter == 0 {
+		t.cfg.Querier.ResponseCache.ImmutableAfter = t.cfg.Compactor.Compactor.MaxCompactionRange
+	}
+
+	worker, err := cortex_frontend.NewWorker(t.cfg.Querier.FrontendWorker, cortex_querier.Config{}, httpgrpc_server.NewServer(t.server.HTTPServer.Handler), util.Logger)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create frontend worker %w", err)
+	}
+
+	querier, err := querier.New(t.cfg.Querier, t.cfg.IngesterClient, t.ring, gatewayRing, t.store, t.overrides, worker)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create querier %w", err)
 	}
 	t.querier = querier
 
+	tempo_api_v1.RegisterQuerierServer(t.server.GRPC, tempo_api_v1.NewQuerierServer(t.querier))
+	if t.cfg.JaegerStorage.Enabled {
+		jaegerReader := jaeger.NewReader(t.cfg.JaegerStorage, t.querier)
+		storage_v1.RegisterSpanReaderPluginServer(t.server.GRPC, jaegerReader)
+		storage_v1.RegisterDependenciesReaderPluginServer(t.server.GRPC, jaegerReader)
+	}
+
 	tracesHandler := middleware.Merge(
+		t.httpCompressionMiddleware,
 		t.httpAuthMiddleware,
 	).Wrap(http.HandlerFunc(t.querier.TraceByIDHandler))
 
 	t.server.HTTP.Handle("/api/traces/{traceID}", tracesHandler)
 
+	traceSummaryHandler := middleware.Merge(
+		t.httpCompressionMiddleware,
+		t.httpAuthMiddleware,
+	).Wrap(http.HandlerFunc(t.querier.TraceSummaryHandler))
+
+	t.server.HTTP.Handle("/api/traces/{traceID}/summary", traceSummaryHandler)
+
+	recentTracesHandler := middleware.Merge(
+		t.httpCompressionMiddleware,
+		t.httpAuthMiddleware,
+	).Wrap(http.HandlerFunc(t.querier.RecentTracesHandler))
+
+	t.server.HTTP.Handle("/api/recent-traces", recentTracesHandler)
+
+	blockStatsHandler := middleware.Merge(
+		t.httpCompressionMiddleware,
+		t.httpAuthMiddleware,
+	).Wrap(http.HandlerFunc(t.querier.BlockStatsHandler))
+
+	t.server.HTTP.Handle("/api/block-stats", blockStatsHandler)
+
+	searchHandler := middleware.Merge(
+		t.httpCompressionMiddleware,
+		t.httpAuthMiddleware,
+	).Wrap(http.HandlerFunc(t.querier.SearchHandler))
+
+	t.server.HTTP.Handle("/api/search", searchHandler)
+
+	overviewHandler := middleware.Merge(
+		t.httpCompressionMiddleware,
+		t.httpAuthMiddleware,
+	).Wrap(http.HandlerFunc(t.querier.OverviewHandler))
+
+	t.server.HTTP.Handle("/api/overview", overviewHandler)
+
+	// not compressed so every line is flushed to the client as it's written
+	t.server.HTTP.Handle("/api/tail", t.httpAuthMiddleware.Wrap(http.HandlerFunc(t.querier.TailHandler)))
+	t.internal.HTTP.Path("/querier/queries").Handler(http.HandlerFunc(t.querier.ActiveQueriesHandler))
+	t.internal.HTTP.Path("/querier/queries/cancel").Handler(http.HandlerFunc(t.querier.CancelQueriesHandler))
+
 	return t.querier, nil
 }
 
+func (t *App) initQueryFrontend() (services.Service, error) {
+	cortexFrontend, err := cortex_frontend.New(t.cfg.Frontend.Config, util.Logger, prometheus.DefaultRegisterer)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create query frontend %w", err)
+	}
+
+	resultsCache, err := frontend.NewResultsCache(t.cfg.Frontend.ResultsCache, prometheus.DefaultRegisterer, util.Logger)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create query frontend results cache %w", err)
+	}
+
+	tripperware, err := frontend.NewTripperware(t.cfg.Frontend, resultsCache, util.Logger)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create query frontend tripperware %w", err)
+	}
+	cortexFrontend.Wrap(tripperware)
+	t.frontend = cortexFrontend
+
+	cortex_frontend.RegisterFrontendServer(t.internal.GRPC, frontend.NewWorkerServer(t.frontend))
+
+	handler := middleware.Merge(
+		t.httpCompressionMiddleware,
+		t.httpAuthMiddleware,
+	).Wrap(t.frontend.Handler())
+
+	t.server.HTTP.Handle("/api/traces/{traceID}", handler)
+	t.server.HTTP.Handle("/api/traces/{traceID}/summary", handler)
+	t.server.HTTP.Handle("/api/recent-traces", handler)
+	t.server.HTTP.Handle("/api/block-stats", handler)
+	t.server.HTTP.Handle("/api/search", handler)
+	t.server.HTTP.Handle("/api/overview", handler)
+
+	return services.NewIdleService(nil, func(_ error) error {
+		t.frontend.Close()
+		if resultsCache != nil {
+			resultsCache.Stop()
+		}
+		return nil
+	}), nil
+}
+
+func (t *App) initFederation() (services.Service, error) {
+	federation, err := federation.New(t.cfg.Federation, util.Logger)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create federation %w", err)
+	}
+	t.federation = federation
+
+	federationMiddleware := middleware.Merge(
+		t.httpCompressionMiddleware,
+		t.httpAuthMiddleware,
+	)
+	t.server.HTTP.Handle("/api/traces/{traceID}", federationMiddleware.Wrap(http.HandlerFunc(t.federation.TraceByIDHandler)))
+	t.server.HTTP.Handle("/api/search", federationMiddleware.Wrap(http.HandlerFunc(t.federation.SearchHandler)))
+
+	return services.NewIdleService(nil, nil), nil
+}
+
 func (t *App) initCompactor() (services.Service, error) {
-	compactor, err := compactor.New(t.cfg.Compactor, t.store)
+	compactor, err := compactor.New(t.cfg.Compactor, t.store, t.overrides)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create compactor %w", err)
 	}
 	t.compactor = compactor
+	t.internal.HTTP.Path("/compactor/plan").Handler(http.HandlerFunc(t.compactor.PlanHandler))
+	t.internal.HTTP.Path("/api/admin/tenants/{tenant}/delete").Methods(http.MethodPost).Handler(http.HandlerFunc(t.compactor.DeleteTenantHandler))
+	if t.cfg.Compactor.Backfill.Enabled {
+		backfillHandler := middleware.Merge(
+			t.httpAuthMiddleware,
+		).Wrap(http.HandlerFunc(t.compactor.BackfillHandler))
+		t.server.HTTP.Path("/api/backfill").Methods(http.MethodPost).Handler(backfillHandler)
+	}
 
 	if t.compactor.Ring != nil {
 		prometheus.MustRegister(t.compactor.Ring)
-		t.server.HTTP.Handle("/compactor/ring", t.compactor.Ring)
+		t.internal.HTTP.Handle("/compactor/ring", tempo_ring.StatusHandler(t.compactor.Ring, tempo_ring.Settings{
+			HeartbeatPeriod:  t.cfg.Compactor.ShardingRing.HeartbeatPeriod,
+			HeartbeatTimeout: t.cfg.Compactor.ShardingRing.HeartbeatTimeout,
+		}))
 	}
 
 	return t.compactor, nil
 }
 
+func (t *App) initBlockGateway() (services.Service, error) {
+	t.cfg.BlockGateway.ShardingRing.ListenPort = t.internalGRPCListenPort()
+	gateway, err := blockgateway.New(t.cfg.BlockGateway, t.store)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create block gateway %w", err)
+	}
+	t.blockGateway = gateway
+
+	tempopb.RegisterQuerierServer(t.internal.GRPC, t.blockGateway)
+	prometheus.MustRegister(t.blockGateway.Ring)
+	t.internal.HTTP.Handle("/block-gateway/ring", tempo_ring.StatusHandler(t.blockGateway.Ring, tempo_ring.Settings{
+		HeartbeatPeriod:  t.cfg.BlockGateway.ShardingRing.HeartbeatPeriod,
+		HeartbeatTimeout: t.cfg.BlockGateway.ShardingRing.HeartbeatTimeout,
+	}))
+
+	return t.blockGateway, nil
+}
+
+// initGatewayRing creates the ring queriers use to find block gateways.  It is only created if the querier is
+// configured to use them.
+func (t *App) initGatewayRing() (services.Service, error) {
+	if !t.cfg.Querier.UseBlockGateways {
+		return nil, nil
+	}
+
+	lifecyclerCfg := t.cfg.BlockGateway.ShardingRing.ToLifecyclerConfig()
+	gatewayRing, err := ring.New(lifecyclerCfg.RingConfig, "block-gateway-querier", t.cfg.BlockGateway.OverrideRingKey, prometheus.DefaultRegisterer)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create block gateway ring %w", err)
+	}
+	t.gatewayRing = gatewayRing
+
+	return t.gatewayRing, nil
+}
+
+func (t *App) initGenerator() (services.Service, error) {
+	t.cfg.Generator.Ring.ListenPort = t.internalGRPCListenPort()
+	generator, err := generator.New(t.cfg.Generator, t.overrides)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create metrics generator %w", err)
+	}
+	t.generator = generator
+
+	tempopb.RegisterMetricsGeneratorServer(t.internal.GRPC, t.generator)
+	prometheus.MustRegister(t.generator.Ring)
+	t.internal.HTTP.Handle("/metrics-generator/ring", tempo_ring.StatusHandler(t.generator.Ring, tempo_ring.Settings{
+		HeartbeatPeriod:  t.cfg.Generator.Ring.HeartbeatPeriod,
+		HeartbeatTimeout: t.cfg.Generator.Ring.HeartbeatTimeout,
+	}))
+
+	return t.generator, nil
+}
+
+// initGeneratorRing creates the ring distributors use to find metrics generators.  It is only created if the
+// distributor is configured to send spans to them.
+func (t *App) initGeneratorRing() (services.Service, error) {
+	if !t.cfg.Distributor.MetricsGeneratorEnabled {
+		return nil, nil
+	}
+
+	lifecyclerCfg := t.cfg.Generator.Ring.ToLifecyclerConfig()
+	generatorRing, err := ring.New(lifecyclerCfg.RingConfig, "metrics-generator-distributor", t.cfg.Generator.OverrideRingKey, prometheus.DefaultRegisterer)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create metrics generator ring %w", err)
+	}
+	t.generatorRing = generatorRing
+
+	return t.generatorRing, nil
+}
+
 func (t *App) initStore() (services.Service, error) {
 	store, err := tempo_storage.NewStore(t.cfg.StorageConfig, util.Logger)
 	if err != nil {
@@ -169,6 +454,7 @@ func (t *App) initMemberlistKV() (services.Service, error) {
 	t.cfg.MemberlistKV.MetricsNamespace = metricsNamespace
 	t.cfg.MemberlistKV.Codecs = []codec.Codec{
 		ring.GetCodec(),
+		tempo_storage.GetFlushNotifyCodec(),
 	}
 
 	hostname, err := os.Hostname()
@@ -183,44 +469,177 @@ func (t *App) initMemberlistKV() (services.Service, error) {
 	t.cfg.Ingester.LifecyclerConfig.RingConfig.KVStore.MemberlistKV = t.memberlistKV.GetMemberlistKV
 	t.cfg.Distributor.DistributorRing.KVStore.MemberlistKV = t.memberlistKV.GetMemberlistKV
 	t.cfg.Compactor.ShardingRing.KVStore.MemberlistKV = t.memberlistKV.GetMemberlistKV
+	t.cfg.BlockGateway.ShardingRing.KVStore.MemberlistKV = t.memberlistKV.GetMemberlistKV
+	t.cfg.Generator.Ring.KVStore.MemberlistKV = t.memberlistKV.GetMemberlistKV
+	t.cfg.StorageConfig.FlushNotify.KVStore.MemberlistKV = t.memberlistKV.GetMemberlistKV
 
 	return t.memberlistKV, nil
 }
 
+// moduleDependencies returns the dependencies of every module with module_dependencies applied.
+func (t *App) moduleDependencies() (map[string][]string, error) {
+	deps := make(map[string][]string, len(t.deps))
+	for mod, targets := range t.deps {
+		deps[mod] = targets
+	}
+	for mod, targets := range t.cfg.ModuleDependencies {
+		deps[mod] = targets
+	}
+	if err := checkDependencies(deps); err != nil {
+		return nil, fmt.Errorf("module_dependencies: %w", err)
+	}
+	return deps, nil
+}
+
+// checkDependencies returns an error if a module depends on an unknown module or on itself through its dependencies,
+// which the module manager doesn't detect.
+func checkDependencies(deps map[string][]string) error {
+	known := map[string]bool{Server: true, InternalServer: true, Overrides: true, MemberlistKV: true}
+	for mod := range deps {
+		known[mod] = true
+	}
+
+	// modules are visited depth first.  a module reached again while its dependencies are being visited is a cycle
+	const (
+		visiting = 1
+		visited  = 2
+	)
+	state := map[string]int{}
+	var visit func(mod string, path []string) error
+	visit = func(mod string, path []string) error {
+		if !known[mod] {
+			return fmt.Errorf("unknown module %s in dependencies of %s", mod, path[len(path)-1])
+		}
+		path = append(path, mod)
+		switch state[mod] {
+		case visiting:
+			return fmt.Errorf("dependency cycle %s", strings.Join(path, " -> "))
+		case visited:
+			return nil
+		}
+
+		state[mod] = visiting
+		for _, dep := range deps[mod] {
+			if err := visit(dep, path); err != nil {
+				return err
+			}
+		}
+		state[mod] = visited
+		return nil
+	}
+
+	mods := make([]string, 0, len(deps))
+	for mod := range deps {
+		mods = append(mods, mod)
+	}
+	sort.Strings(mods)
+	for _, mod := range mods {
+		if err := visit(mod, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (t *App) setupModuleManager() error {
 	mm := modules.NewManager()
 
-	mm.RegisterModule(Server, t.initServer, modules.UserInvisibleModule)
-	mm.RegisterModule(MemberlistKV, t.initMemberlistKV, modules.UserInvisibleModule)
-	mm.RegisterModule(Ring, t.initRing, modules.UserInvisibleModule)
-	mm.RegisterModule(Overrides, t.initOverrides, modules.UserInvisibleModule)
-	mm.RegisterModule(Distributor, t.initDistributor)
-	mm.RegisterModule(Ingester, t.initIngester)
-	mm.RegisterModule(Querier, t.initQuerier)
-	mm.RegisterModule(Compactor, t.initCompactor)
-	mm.RegisterModule(Store, t.initStore, modules.UserInvisibleModule)
-	mm.RegisterModule(All, nil)
+	t.moduleManager = mm
+	t.addModule(Server, t.initServer, false)
+	t.addModule(InternalServer, t.initInternalServer, false)
+	t.addModule(MemberlistKV, t.initMemberlistKV, false)
+	t.addModule(Ring, t.initRing, false)
+	t.addModule(Overrides, t.initOverrides, false)
+	t.addModule(OverridesExporter, t.initOverridesExporter, true)
+	t.addModule(Distributor, t.initDistributor, true)
+	t.addModule(Ingester, t.initIngester, true)
+	t.addModule(Querier, t.initQuerier, true)
+	t.addModule(Frontend, t.initQueryFrontend, true)
+	t.addModule(Compactor, t.initCompactor, true)
+	t.addModule(Federation, t.initFederation, true)
+	t.addModule(BlockGateway, t.initBlockGateway, true)
+	t.addModule(GatewayRing, t.initGatewayRing, false)
+	t.addModule(Generator, t.initGenerator, true)
+	t.addModule(GeneratorRing, t.initGeneratorRing, false)
+	t.addModule(Store, t.initStore, false)
+	t.addModule(All, nil, true)
 
 	deps := map[string][]string{
-		// Server:       nil,
-		// Overrides:    nil,
-		// Store:        nil,
-		// MemberlistKV: nil,
-		Ring:        {Server, MemberlistKV},
-		Distributor: {Ring, Server, Overrides},
-		Ingester:    {Store, Server, Overrides, MemberlistKV},
-		Querier:     {Store, Ring},
-		Compactor:   {Store, Server, MemberlistKV},
-		All:         {Compactor, Querier, Ingester, Distributor},
-	}
-
-	for mod, targets := range deps {
-		if err := mm.AddDependency(mod, targets...); err != nil {
-			return err
+		// modules register their internal endpoints on the internal server, which is the server unless enabled
+		Server:            {InternalServer},
+		InternalServer:    nil,
+		Overrides:         nil,
+		OverridesExporter: {Server, Overrides},
+		MemberlistKV:      nil,
+		Store:             {MemberlistKV},
+		Ring:              {Server, MemberlistKV},
+		Distributor:       {Ring, Server, Overrides, GeneratorRing},
+		Ingester:          {Store, Server, Overrides, MemberlistKV},
+		Querier:           {Store, Ring, GatewayRing, Overrides},
+		Frontend:          {Server},
+		Federation:        {Server},
+		Compactor:         {Store, Server, Overrides, MemberlistKV},
+		BlockGateway:      {Store, Server, MemberlistKV},
+		GatewayRing:       {Server, MemberlistKV},
+		Generator:         {Server, Overrides, MemberlistKV},
+		GeneratorRing:     {Server, MemberlistKV},
+		All:               {Compactor, Querier, Ingester, Distributor},
+	}
+
+	t.deps = deps
+
+	return nil
+}
+
+// addModule registers a module with the module manager.  Errors of its init function are returned as moduleErrors, so
+// a failed start names the module that failed and not only the modules depending on it.
+func (t *App) addModule(name string, initFn func() (services.Service, error), userVisible bool) {
+	if initFn != nil {
+		fn := initFn
+		initFn = func() (services.Service, error) {
+			s, err := fn()
+			if err != nil {
+				return nil, &moduleError{module: name, err: err}
+			}
+			return s, nil
 		}
 	}
 
-	t.moduleManager = mm
+	if userVisible {
+		t.moduleManager.RegisterModule(name, initFn)
+	} else {
+		t.moduleManager.RegisterModule(name, initFn, modules.UserInvisibleModule)
+	}
+}
 
+// RegisterModule adds a module for programs embedding Tempo, e.g. a collector that writes blocks to Store.  It must be
+// called before Start.  The module may be the target, or be added to the dependencies of Tempo's modules with
+// module_dependencies.  initFn may return a nil service if the module only sets something up.
+func (t *App) RegisterModule(name string, initFn func() (services.Service, error), deps ...string) error {
+	if t.serviceManager != nil {
+		return fmt.Errorf("module %s registered after start", name)
+	}
+	if _, ok := t.deps[name]; ok {
+		return fmt.Errorf("module %s already registered", name)
+	}
+
+	t.addModule(name, initFn, true)
+	t.deps[name] = deps
+	return nil
+}
+
+// setupDependencies applies module_dependencies and adds the dependencies of every module to the module manager.
+func (t *App) setupDependencies() error {
+	deps, err := t.moduleDependencies()
+	if err != nil {
+		return err
+	}
+	t.deps = deps
+
+	for mod, targets := range t.deps {
+		if err := t.moduleManager.AddDependency(mod, targets...); err != nil {
+			return err
+		}
+	}
 	return nil
 }
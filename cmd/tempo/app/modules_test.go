@@ -0,0 +1,123 @@
+package app
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cortexproject/cortex/pkg/util/services"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/weaveworks/common/user"
+)
+
+func TestCheckDependencies(t *testing.T) {
+	assert.NoError(t, checkDependencies(map[string][]string{
+		Querier: {Store, Server},
+		Store:   {MemberlistKV},
+		All:     {Querier, Generator},
+		// modules without dependencies may be left out
+		Generator: {Server, Overrides},
+	}))
+
+	err := checkDependencies(map[string][]string{
+		Querier: {Store},
+	})
+	assert.EqualError(t, err, "unknown module store in dependencies of querier")
+
+	err = checkDependencies(map[string][]string{
+		Querier: {Store},
+		Store:   {Ring},
+		Ring:    {Querier},
+	})
+	assert.EqualError(t, err, "dependency cycle querier -> store -> ring -> querier")
+}
+
+func TestQuerierDependsOnOverrides(t *testing.T) {
+	cfg := querierTestConfig(t)
+	cfg.Target = "probe"
+
+	tempo, err := New(cfg)
+	require.NoError(t, err)
+
+	// the probe resolves the dependencies of the querier without starting it
+	var overridesAtInit bool
+	err = tempo.RegisterModule("probe", func() (services.Service, error) {
+		overridesAtInit = tempo.Overrides() != nil
+		return services.NewIdleService(nil, nil), nil
+	}, tempo.deps[Querier]...)
+	require.NoError(t, err)
+
+	withTestRegistry(t)
+	require.NoError(t, tempo.Start(context.Background()))
+	assert.True(t, overridesAtInit)
+
+	tempo.Stop()
+	require.NoError(t, tempo.Wait(context.Background()))
+}
+
+// querierTestConfig is a config the querier and its dependencies start with without any other process.
+func querierTestConfig(t *testing.T) Config {
+	cfg := DefaultConfig()
+	cfg.Server.HTTPListenPort = 0
+	cfg.Server.GRPCListenPort = 0
+	cfg.Ingester.LifecyclerConfig.RingConfig.KVStore.Store = "inmemory"
+	cfg.StorageConfig.Trace.Backend = "local"
+	cfg.StorageConfig.Trace.Local.Path = filepath.Join(t.TempDir(), "traces")
+	cfg.StorageConfig.Trace.WAL.Filepath = filepath.Join(t.TempDir(), "wal")
+	return cfg
+}
+
+// withTestRegistry registers metrics with a new registry for the rest of the test.  The server registers its metrics
+// with the default registerer, which fails if another test of the package started one.
+func withTestRegistry(t *testing.T) {
+	registerer := prometheus.DefaultRegisterer
+	prometheus.DefaultRegisterer = prometheus.NewRegistry()
+	t.Cleanup(func() {
+		prometheus.DefaultRegisterer = registerer
+	})
+}
+
+func TestQuerierTarget(t *testing.T) {
+	cfg := querierTestConfig(t)
+	cfg.Target = Querier
+	cfg.LimitsConfig.PerTenantOverrideConfig = filepath.Join(t.TempDir(), "overrides.yaml")
+	require.NoError(t, ioutil.WriteFile(cfg.LimitsConfig.PerTenantOverrideConfig, []byte(`
+overrides:
+  disabled:
+    search_disabled: true
+`), os.ModePerm))
+
+	tempo, err := New(cfg)
+	require.NoError(t, err)
+	withTestRegistry(t)
+	require.NoError(t, tempo.Start(context.Background()))
+	defer func() {
+		tempo.Stop()
+		require.NoError(t, tempo.Wait(context.Background()))
+	}()
+	// the per tenant overrides are loaded once the modules are running
+	require.NoError(t, tempo.serviceManager.AwaitHealthy(context.Background()))
+	require.NotNil(t, tempo.Overrides())
+
+	get := func(tenant, path string) int {
+		r := httptest.NewRequest(http.MethodGet, path, nil)
+		r.Header.Set(user.OrgIDHeaderName, tenant)
+		rec := httptest.NewRecorder()
+		tempo.server.HTTP.ServeHTTP(rec, r)
+		return rec.Code
+	}
+	// the tenant's limits are read before the empty ring of ingesters fails the request
+	assert.Equal(t, http.StatusInternalServerError, get("test", "/api/traces/0102"))
+	assert.Equal(t, http.StatusInternalServerError, get("test", "/api/search?tags=service.name=foo"))
+	assert.Equal(t, http.StatusInternalServerError, get("test", "/api/tail?tags=service.name=foo"))
+	assert.Equal(t, http.StatusNotFound, get("test", "/api/traces/0102?mode=blocks"))
+
+	assert.Equal(t, http.StatusForbidden, get("disabled", "/api/search?tags=service.name=foo"))
+	assert.Equal(t, http.StatusForbidden, get("disabled", "/api/tail?tags=service.name=foo"))
+}
@@ -0,0 +1,38 @@
+package app
+
+import (
+	"sync"
+
+	gogo_proto "github.com/gogo/protobuf/proto"
+	golang_proto "github.com/golang/protobuf/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/reflection"
+)
+
+// reflectionFiles are the proto files of the services Tempo serves, dependencies first.
+var reflectionFiles = []string{
+	"opentelemetry/proto/common/v1/common.proto",
+	"opentelemetry/proto/resource/v1/resource.proto",
+	"opentelemetry/proto/trace/v1/trace.proto",
+	"tempo.proto",
+	"api.proto",
+}
+
+var registerReflectionFilesOnce sync.Once
+
+// registerReflection registers the gRPC server reflection service.  The reflection service looks descriptors up in the
+// golang/protobuf registry while Tempo's protos are generated with gogo, so their descriptors are copied over first.
+func registerReflection(s *grpc.Server) {
+	registerReflectionFilesOnce.Do(func() {
+		for _, file := range reflectionFiles {
+			if golang_proto.FileDescriptor(file) != nil {
+				continue
+			}
+			if desc := gogo_proto.FileDescriptor(file); desc != nil {
+				golang_proto.RegisterFile(file, desc)
+			}
+		}
+	})
+
+	reflection.Register(s)
+}
@@ -0,0 +1,59 @@
+package app
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	rpb "google.golang.org/grpc/reflection/grpc_reflection_v1alpha"
+
+	tempo_api_v1 "github.com/grafana/tempo/pkg/api/v1"
+)
+
+func TestRegisterReflection(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	s := grpc.NewServer()
+	tempo_api_v1.RegisterPusherServer(s, tempo_api_v1.NewPusherServer(nil))
+	tempo_api_v1.RegisterQuerierServer(s, tempo_api_v1.NewQuerierServer(nil))
+	registerReflection(s)
+	go func() { _ = s.Serve(lis) }()
+	defer s.Stop()
+
+	conn, err := grpc.Dial(lis.Addr().String(), grpc.WithInsecure())
+	require.NoError(t, err)
+	defer conn.Close()
+
+	stream, err := rpb.NewServerReflectionClient(conn).ServerReflectionInfo(context.Background())
+	require.NoError(t, err)
+
+	require.NoError(t, stream.Send(&rpb.ServerReflectionRequest{
+		MessageRequest: &rpb.ServerReflectionRequest_ListServices{},
+	}))
+	resp, err := stream.Recv()
+	require.NoError(t, err)
+	var services []string
+	for _, s := range resp.GetListServicesResponse().GetService() {
+		services = append(services, s.Name)
+	}
+	assert.Contains(t, services, "tempo.api.v1.Pusher")
+	assert.Contains(t, services, "tempo.api.v1.Querier")
+
+	// the file of the service and the otel protos it imports are found
+	requests := []*rpb.ServerReflectionRequest{
+		{MessageRequest: &rpb.ServerReflectionRequest_FileContainingSymbol{FileContainingSymbol: "tempo.api.v1.Querier"}},
+		{MessageRequest: &rpb.ServerReflectionRequest_FileByFilename{FileByFilename: "opentelemetry/proto/trace/v1/trace.proto"}},
+		{MessageRequest: &rpb.ServerReflectionRequest_FileByFilename{FileByFilename: "opentelemetry/proto/common/v1/common.proto"}},
+	}
+	for _, req := range requests {
+		require.NoError(t, stream.Send(req))
+		resp, err = stream.Recv()
+		require.NoError(t, err)
+		require.Nil(t, resp.GetErrorResponse())
+		assert.Len(t, resp.GetFileDescriptorResponse().GetFileDescriptorProto(), 1)
+	}
+}
@@ -0,0 +1,136 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/cortexproject/cortex/pkg/util"
+	"github.com/cortexproject/cortex/pkg/util/services"
+	"github.com/go-kit/kit/log/level"
+	"github.com/weaveworks/common/server"
+	"google.golang.org/grpc"
+)
+
+const httpGRPCService = "httpgrpc.HTTP"
+
+// serverSockets are unix domain socket listeners served alongside the tcp listeners of the server.  Either may be nil.
+type serverSockets struct {
+	http net.Listener
+	grpc net.Listener
+}
+
+// newServerService is equivalent to cortex.NewServerService but controls the order the listeners are shut down in.
+// The gRPC server always stops last so inter-component traffic such as pushes, flushes and frontend workers keeps
+// flowing while the other modules drain.  If stopHTTPFirst is set the HTTP server stops accepting requests as soon
+// as shutdown begins instead of after every module has terminated.
+//
+// Socket listeners serve the same handlers as the tcp listeners and shut down alongside them.  The http socket serves
+// plain http even if tls is configured.
+func newServerService(serv *server.Server, cfg server.Config, sockets serverSockets, stopHTTPFirst bool, servicesToWaitFor func() []services.Service) services.Service {
+	serverDone := make(chan error, 1)
+
+	// the tcp http.Server is owned by Run, which treats any return from Serve as the server stopping
+	socketServer := &http.Server{
+		Handler:      serv.HTTPServer.Handler,
+		ReadTimeout:  cfg.HTTPServerReadTimeout,
+		WriteTimeout: cfg.HTTPServerWriteTimeout,
+		IdleTimeout:  cfg.HTTPServerIdleTimeout,
+	}
+
+	runFn := func(ctx context.Context) error {
+		go func() {
+			defer close(serverDone)
+			serverDone <- serv.Run()
+		}()
+
+		if sockets.http != nil {
+			go func() {
+				if err := socketServer.Serve(sockets.http); err != nil && err != http.ErrServerClosed {
+					level.Error(util.Logger).Log("msg", "http socket stopped", "err", err)
+				}
+			}()
+		}
+		if sockets.grpc != nil {
+			go func() {
+				// Run registers the http over grpc service and services can't be registered once the server is serving
+				for {
+					if _, ok := serv.GRPC.GetServiceInfo()[httpGRPCService]; ok {
+						break
+					}
+					select {
+					case <-ctx.Done():
+						_ = sockets.grpc.Close()
+						return
+					case <-time.After(10 * time.Millisecond):
+					}
+				}
+
+				if err := serv.GRPC.Serve(sockets.grpc); err != nil && err != grpc.ErrServerStopped {
+					level.Error(util.Logger).Log("msg", "grpc socket stopped", "err", err)
+				}
+			}()
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case err := <-serverDone:
+			if err != nil {
+				return err
+			}
+			return fmt.Errorf("server stopped unexpectedly")
+		}
+	}
+
+	stopHTTP := func() {
+		ctx, cancel := context.WithTimeout(context.Background(), cfg.ServerGracefulShutdownTimeout)
+		defer cancel()
+
+		if err := serv.HTTPServer.Shutdown(ctx); err != nil {
+			level.Warn(util.Logger).Log("msg", "http server did not shut down gracefully", "err", err)
+		}
+		if sockets.http != nil {
+			if err := socketServer.Shutdown(ctx); err != nil {
+				level.Warn(util.Logger).Log("msg", "http socket did not shut down gracefully", "err", err)
+			}
+		}
+		level.Info(util.Logger).Log("msg", "http server stopped")
+	}
+
+	stoppingFn := func(_ error) error {
+		if stopHTTPFirst {
+			stopHTTP()
+		}
+
+		// wait until all modules are done
+		for _, s := range servicesToWaitFor() {
+			_ = s.AwaitTerminated(context.Background())
+		}
+
+		if !stopHTTPFirst {
+			stopHTTP()
+		}
+
+		stopGRPC := make(chan struct{})
+		go func() {
+			serv.GRPC.GracefulStop()
+			close(stopGRPC)
+		}()
+		select {
+		case <-stopGRPC:
+		case <-time.After(cfg.ServerGracefulShutdownTimeout):
+			level.Warn(util.Logger).Log("msg", "grpc server did not shut down gracefully, forcing it to stop")
+			serv.GRPC.Stop()
+		}
+
+		// if not closed yet, wait until server stops.
+		<-serverDone
+		level.Info(util.Logger).Log("msg", "server stopped")
+		return nil
+	}
+
+	return services.NewBasicService(nil, runFn, stoppingFn)
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/grafana/tempo/cmd/tempo/app"
+)
+
+// configFileList collects repeated -config.file flags in the order they were first seen.
+type configFileList []string
+
+func (l *configFileList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *configFileList) Set(s string) error {
+	for _, existing := range *l {
+		if existing == s {
+			return nil
+		}
+	}
+
+	*l = append(*l, s)
+	return nil
+}
+
+// loadConfigFile unmarshals a config file over config.  Fields present in the file replace the current value, nested
+// sections and maps are merged key by key and lists are replaced entirely.  If expandEnv is set ${VAR} references in
+// the file are replaced with the environment first.
+func loadConfigFile(configFile string, strict bool, expandEnv bool, config *app.Config) ([]string, error) {
+	var warnings []string
+
+	buff, err := ioutil.ReadFile(configFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read configFile %s: %w", configFile, err)
+	}
+
+	if expandEnv {
+		buff = expandEnvRefs(buff, os.LookupEnv)
+	}
+
+	if strict {
+		err = yaml.UnmarshalStrict(buff, config)
+	} else {
+		err = yaml.Unmarshal(buff, config)
+		warnings = append(warnings, formatWarnings(configFile, unknownFieldWarnings(buff, &app.Config{}))...)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse configFile %s: %w", configFile, err)
+	}
+
+	deprecated, err := deprecatedFieldWarnings(buff)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse configFile %s: %w", configFile, err)
+	}
+	warnings = append(warnings, formatWarnings(configFile, deprecated)...)
+
+	return warnings, nil
+}
+
+var envRefRegexp = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)(?::([^}]*))?\}`)
+
+// expandEnvRefs replaces ${VAR} with the value of VAR, or the empty string if it's unset, and ${VAR:default} with
+// default if VAR is unset.  A bare $ is left alone so values like passwords don't need escaping.
+func expandEnvRefs(buff []byte, lookup func(string) (string, bool)) []byte {
+	return envRefRegexp.ReplaceAllFunc(buff, func(ref []byte) []byte {
+		match := envRefRegexp.FindSubmatch(ref)
+		if value, ok := lookup(string(match[1])); ok {
+			return []byte(value)
+		}
+		return match[2]
+	})
+}
+
+// deprecatedFields maps the dotted yaml path of a deprecated config field to a hint on what to use instead.
+// Setting a field listed here logs a warning but is otherwise still honored.
+var deprecatedFields = map[string]string{}
+
+// unknownFieldWarnings parses the config file strictly into a scratch value and returns the unknown or
+// mistyped fields with their line numbers.
+func unknownFieldWarnings(buff []byte, out interface{}) []string {
+	err := yaml.UnmarshalStrict(buff, out)
+	if err == nil {
+		return nil
+	}
+
+	if typeErr, ok := err.(*yaml.TypeError); ok {
+		return typeErr.Errors
+	}
+
+	return []string{err.Error()}
+}
+
+// deprecatedFieldWarnings walks the config file and returns a warning for every field in deprecatedFields that is set.
+func deprecatedFieldWarnings(buff []byte) ([]string, error) {
+	if len(deprecatedFields) == 0 {
+		return nil, nil
+	}
+
+	var root yaml.MapSlice
+	err := yaml.Unmarshal(buff, &root)
+	if err != nil {
+		return nil, err
+	}
+
+	var warnings []string
+	walkYAML(root, "", func(path string) {
+		if hint, ok := deprecatedFields[path]; ok {
+			warnings = append(warnings, fmt.Sprintf("%s is deprecated: %s", path, hint))
+		}
+	})
+
+	return warnings, nil
+}
+
+func walkYAML(m yaml.MapSlice, prefix string, fn func(path string)) {
+	for _, item := range m {
+		path := fmt.Sprintf("%v", item.Key)
+		if prefix != "" {
+			path = prefix + "." + path
+		}
+		fn(path)
+
+		if child, ok := item.Value.(yaml.MapSlice); ok {
+			walkYAML(child, path, fn)
+		}
+	}
+}
+
+// flagValues snapshots the current value of every flag registered in fs.  Flags are bound directly to config
+// fields so comparing snapshots taken before and after loading the config file reveals which fields the file set.
+func flagValues(fs *flag.FlagSet) map[string]string {
+	values := map[string]string{}
+	fs.VisitAll(func(f *flag.Flag) {
+		values[f.Name] = f.Value.String()
+	})
+	return values
+}
+
+// conflictWarnings returns a warning for every flag explicitly passed on the command line whose field was also
+// changed by the config file.  The flag wins.
+func conflictWarnings(fs *flag.FlagSet, beforeFile map[string]string, afterFile map[string]string) []string {
+	var warnings []string
+	fs.Visit(func(f *flag.Flag) {
+		if beforeFile[f.Name] != afterFile[f.Name] {
+			warnings = append(warnings, fmt.Sprintf("-%s is set by both the config file (%s) and the command line (%s), using the command line value", f.Name, afterFile[f.Name], f.Value.String()))
+		}
+	})
+
+	sort.Strings(warnings)
+	return warnings
+}
+
+func formatWarnings(configFile string, warnings []string) []string {
+	formatted := make([]string, 0, len(warnings))
+	for _, w := range warnings {
+		formatted = append(formatted, fmt.Sprintf("%s: %s", configFile, strings.TrimSpace(w)))
+	}
+	return formatted
+}
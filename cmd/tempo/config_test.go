@@ -0,0 +1,171 @@
+package main
+
+import (
+	"flag"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/grafana/tempo/cmd/tempo/app"
+)
+
+func TestConflictWarnings(t *testing.T) {
+	var a, b string
+	fs := flag.NewFlagSet("", flag.ContinueOnError)
+	fs.StringVar(&a, "a", "default", "")
+	fs.StringVar(&b, "b", "default", "")
+
+	before := flagValues(fs)
+	a = "file"
+	b = "file"
+	after := flagValues(fs)
+
+	err := fs.Parse([]string{"-a=cli"})
+	assert.NoError(t, err)
+
+	warnings := conflictWarnings(fs, before, after)
+	assert.Len(t, warnings, 1)
+	assert.Contains(t, warnings[0], "-a is set by both")
+}
+
+func TestUnknownFieldWarnings(t *testing.T) {
+	buff := []byte(`
+target: all
+ingester:
+  not_a_field: true
+`)
+
+	warnings := unknownFieldWarnings(buff, &app.Config{})
+	assert.Len(t, warnings, 1)
+	assert.Contains(t, warnings[0], "line 4")
+	assert.Contains(t, warnings[0], "not_a_field")
+}
+
+func TestDeprecatedFieldWarnings(t *testing.T) {
+	deprecatedFields = map[string]string{
+		"ingester.old_field": "use ingester.new_field",
+	}
+	defer func() { deprecatedFields = map[string]string{} }()
+
+	warnings, err := deprecatedFieldWarnings([]byte(`
+ingester:
+  old_field: 1
+  new_field: 1
+`))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"ingester.old_field is deprecated: use ingester.new_field"}, warnings)
+}
+
+func TestLoadConfigFileMerge(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	base := path.Join(dir, "base.yaml")
+	err = ioutil.WriteFile(base, []byte(`
+target: all
+ingester:
+  max_block_duration: 1h
+  trace_idle_period: 10s
+`), 0644)
+	assert.NoError(t, err)
+
+	overlay := path.Join(dir, "overlay.yaml")
+	err = ioutil.WriteFile(overlay, []byte(`
+ingester:
+  trace_idle_period: 30s
+`), 0644)
+	assert.NoError(t, err)
+
+	var files configFileList
+	for _, f := range []string{base, overlay, base} {
+		assert.NoError(t, files.Set(f))
+	}
+	assert.Equal(t, configFileList{base, overlay}, files)
+
+	cfg := &app.Config{}
+	for _, f := range files {
+		_, err = loadConfigFile(f, true, false, cfg)
+		assert.NoError(t, err)
+	}
+
+	assert.Equal(t, "all", cfg.Target)
+	assert.Equal(t, time.Hour, cfg.Ingester.MaxBlockDuration)
+	assert.Equal(t, 30*time.Second, cfg.Ingester.MaxTraceIdle)
+}
+
+func TestExpandEnvRefs(t *testing.T) {
+	env := map[string]string{
+		"BUCKET": "traces",
+		"EMPTY":  "",
+	}
+	lookup := func(name string) (string, bool) {
+		v, ok := env[name]
+		return v, ok
+	}
+
+	actual := expandEnvRefs([]byte(`
+bucket: ${BUCKET}
+empty: ${EMPTY:default}
+endpoint: ${ENDPOINT:s3.amazonaws.com}
+unset: ${UNSET}
+secret: pa$$word$BUCKET
+`), lookup)
+	assert.Equal(t, `
+bucket: traces
+empty: 
+endpoint: s3.amazonaws.com
+unset: 
+secret: pa$$word$BUCKET
+`, string(actual))
+}
+
+func TestLoadConfigFileExpandEnv(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	file := path.Join(dir, "tempo.yaml")
+	err = ioutil.WriteFile(file, []byte(`
+target: ${TEMPO_TEST_TARGET:all}
+`), 0644)
+	assert.NoError(t, err)
+
+	os.Setenv("TEMPO_TEST_TARGET", "compactor")
+	defer os.Unsetenv("TEMPO_TEST_TARGET")
+
+	cfg := &app.Config{}
+	_, err = loadConfigFile(file, true, true, cfg)
+	assert.NoError(t, err)
+	assert.Equal(t, "compactor", cfg.Target)
+
+	// references are left alone unless expansion is enabled
+	_, err = loadConfigFile(file, true, false, cfg)
+	assert.NoError(t, err)
+	assert.Equal(t, "${TEMPO_TEST_TARGET:all}", cfg.Target)
+}
+
+func TestValidate(t *testing.T) {
+	cfg := &app.Config{}
+	cfg.RegisterFlagsAndApplyDefaults("", flag.NewFlagSet("", flag.ContinueOnError))
+	cfg.StorageConfig.Trace.Backend = "local"
+	assert.NoError(t, cfg.Validate())
+
+	cfg.Target = "not-a-target"
+	cfg.StorageConfig.Trace.Backend = "not-a-backend"
+	cfg.Ingester.LifecyclerConfig.RingConfig.KVStore.Store = "inmemory"
+	cfg.Ingester.LifecyclerConfig.RingConfig.ReplicationFactor = 3
+	cfg.Compactor.Compactor.BlockRetention = cfg.Ingester.MaxBlockDuration / 2
+	cfg.Distributor.Sampling.Ratio = 0
+	cfg.TenantMetrics.DisabledMetrics = []string{"tempo_distributor_not_a_metric"}
+
+	err := cfg.Validate()
+	assert.Error(t, err)
+	for _, field := range []string{"target", "replication_factor", "storage.trace.backend", "block_retention", "sampling.ratio", "tenant_metrics.disabled_metrics"} {
+		assert.Contains(t, err.Error(), field)
+	}
+}
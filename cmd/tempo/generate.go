@@ -0,0 +1,140 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+)
+
+const (
+	modeSingleBinary  = "single-binary"
+	modeMicroservices = "microservices"
+)
+
+// runConfigGenerate implements `tempo config generate`.  It writes a commented config skeleton for the requested
+// deployment mode and backend to out.
+func runConfigGenerate(args []string, out io.Writer) error {
+	var mode, backend string
+
+	fs := flag.NewFlagSet("config generate", flag.ContinueOnError)
+	fs.SetOutput(out)
+	fs.StringVar(&mode, "mode", modeSingleBinary, "Deployment mode to generate a config for: single-binary or microservices.")
+	fs.StringVar(&backend, "backend", "local", "Storage backend to generate a config for: local, gcs, s3 or azure.")
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	backendSection, ok := generateBackends[backend]
+	if !ok {
+		return fmt.Errorf("unknown backend %s. must be one of local, gcs, s3 or azure", backend)
+	}
+
+	var sections []string
+	switch mode {
+	case modeSingleBinary:
+		sections = []string{generateHeader, generateDistributor, "ingester:\n", generateIngester, "compactor:\n", generateCompactor}
+	case modeMicroservices:
+		sections = []string{generateHeader, generateMicroservicesNote, generateDistributor, "ingester:\n", generateIngesterRing, generateIngester, "compactor:\n", generateCompactorRing, generateCompactor, generateMemberlist}
+	default:
+		return fmt.Errorf("unknown mode %s. must be one of single-binary or microservices", mode)
+	}
+	sections = append(sections, generateStorage, backendSection)
+
+	_, err = io.WriteString(out, strings.Join(sections, ""))
+	return err
+}
+
+const generateHeader = `auth_enabled: false                  # set to true to require the X-Scope-OrgID header and store data per tenant
+
+server:
+  http_listen_port: 3100
+
+`
+
+const generateMicroservicesNote = `# run each component with the same config and -target=distributor|ingester|querier|compactor
+# components discover each other through the memberlist gossip ring configured at the bottom of this file.
+
+`
+
+const generateDistributor = `distributor:
+  receivers:                           # only enable the receivers you need.  more configuration options can be found at
+    otlp:                              # https://github.com/open-telemetry/opentelemetry-collector/tree/master/receiver
+      protocols:
+        grpc:                          # 4317
+        http:                          # 4318
+    jaeger:
+      protocols:
+        thrift_http:
+        grpc:
+
+`
+
+const generateIngesterRing = `  lifecycler:
+    ring:
+      kvstore:
+        store: memberlist              # ingesters register themselves in the memberlist ring
+      replication_factor: 3            # each trace is written to this many ingesters
+`
+
+const generateIngester = `  trace_idle_period: 10s               # the length of time after a trace has not received spans to consider it complete and flush it
+  traces_per_block: 100000             # cut the head block when it hits this number of traces or ...
+  max_block_duration: 1h               #   this much time passes
+
+`
+
+const generateCompactorRing = `  ring:
+    kvstore:
+      store: memberlist                # multiple compactors share work using a ring stored in memberlist
+`
+
+const generateCompactor = `  compaction:
+    compaction_window: 1h              # blocks in this time window will be compacted together
+    max_compaction_objects: 1000000    # maximum traces in a compacted block
+    block_retention: 336h              # duration to keep blocks
+    compacted_block_retention: 1h      # duration to keep blocks that have been compacted elsewhere
+
+`
+
+const generateMemberlist = `memberlist:
+  abort_if_cluster_join_fails: false
+  bind_port: 7946
+  join_members:                        # addresses of other tempo components. a headless service works well in kubernetes
+  - tempo-gossip-ring:7946
+
+`
+
+const generateStorage = `storage:
+  trace:
+    wal:
+      path: /var/tempo/wal             # where to store the wal locally
+    pool:
+      max_workers: 100                 # the worker pool mainly drives querying, but is also used for polling the blocklist
+      queue_depth: 10000
+`
+
+var generateBackends = map[string]string{
+	"local": `    backend: local
+    local:
+      path: /var/tempo/traces          # where to store blocks. only suitable for a single instance
+`,
+	"gcs": `    backend: gcs
+    gcs:
+      bucket_name: tempo               # credentials are taken from the environment (GOOGLE_APPLICATION_CREDENTIALS)
+      chunk_buffer_size: 10485760
+`,
+	"s3": `    backend: s3
+    s3:
+      bucket: tempo
+      endpoint: s3.dualstack.us-east-1.amazonaws.com
+      region: us-east-1
+      # access_key and secret_key may be set here, otherwise credentials are taken from the environment
+`,
+	"azure": `    backend: azure
+    azure:
+      container_name: tempo
+      storage_account_name: tempo
+      use_managed_identity: true       # or set storage_account_key or sas_token
+`,
+}
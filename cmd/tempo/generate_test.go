@@ -0,0 +1,34 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v2"
+
+	"github.com/grafana/tempo/cmd/tempo/app"
+)
+
+func TestRunConfigGenerate(t *testing.T) {
+	for _, mode := range []string{modeSingleBinary, modeMicroservices} {
+		for backend := range generateBackends {
+			t.Run(mode+"-"+backend, func(t *testing.T) {
+				out := &bytes.Buffer{}
+				err := runConfigGenerate([]string{"-mode=" + mode, "-backend=" + backend}, out)
+				assert.NoError(t, err)
+
+				cfg := &app.Config{}
+				err = yaml.UnmarshalStrict(out.Bytes(), cfg)
+				assert.NoError(t, err)
+				assert.Equal(t, backend, cfg.StorageConfig.Trace.Backend)
+			})
+		}
+	}
+
+	err := runConfigGenerate([]string{"-backend=nope"}, &bytes.Buffer{})
+	assert.Error(t, err)
+
+	err = runConfigGenerate([]string{"-mode=nope"}, &bytes.Buffer{})
+	assert.Error(t, err)
+}
@@ -3,6 +3,7 @@ package main
 import (
 	"flag"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"os"
 	"reflect"
@@ -10,7 +11,6 @@ import (
 
 	"github.com/grafana/tempo/cmd/tempo/app"
 	_ "github.com/grafana/tempo/cmd/tempo/build"
-	"gopkg.in/yaml.v2"
 
 	"github.com/go-kit/kit/log/level"
 
@@ -40,10 +40,28 @@ func init() {
 }
 
 func main() {
+	if len(os.Args) > 2 && os.Args[1] == "config" && os.Args[2] == "generate" {
+		if err := runConfigGenerate(os.Args[3:], os.Stdout); err != nil {
+			fmt.Fprintf(os.Stderr, "failed generating config: %v\n", err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "verify-blocks" {
+		if err := runVerifyBlocks(os.Args[2:], os.Stdout); err != nil {
+			fmt.Fprintf(os.Stderr, "failed verifying blocks: %v\n", err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
 	printVersion := flag.Bool("version", false, "Print this builds version information")
 	ballastMBs := flag.Int("mem-ballast-size-mbs", 0, "Size of memory ballast to allocate in MBs.")
+	verifyConfig := flag.Bool("config.verify", false, "Validate the configuration, log any problems and exit.")
+	printModules := flag.Bool("modules", false, "Print the modules with their config blocks and dependencies, the modules run by the target and exit.")
 
-	config, err := loadConfig()
+	config, warnings, err := loadConfig()
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "failed parsing config: %v\n", err)
 		os.Exit(1)
@@ -52,6 +70,13 @@ func main() {
 		fmt.Println(version.Print(appName))
 		os.Exit(0)
 	}
+	if *printModules {
+		if err := writeModules(*config, os.Stdout); err != nil {
+			fmt.Fprintf(os.Stderr, "failed printing modules: %v\n", err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
 
 	// Init the logger which will honor the log level set in config.Server
 	if reflect.DeepEqual(&config.Server.LogLevel, &logging.Level{}) {
@@ -60,6 +85,20 @@ func main() {
 	}
 	util.InitLogger(&config.Server)
 
+	for _, w := range warnings {
+		level.Warn(util.Logger).Log("msg", "config warning", "warning", w)
+	}
+
+	if err := config.Validate(); err != nil {
+		level.Error(util.Logger).Log("msg", "invalid config", "err", err)
+		os.Exit(1)
+	}
+	if *verifyConfig {
+		config.CheckConfig()
+		level.Info(util.Logger).Log("msg", "config is valid")
+		os.Exit(0)
+	}
+
 	// Setting the environment variable JAEGER_AGENT_HOST enables tracing
 	trace, err := tracing.NewFromEnv(fmt.Sprintf("%s-%s", appName, config.Target))
 	if err != nil {
@@ -97,10 +136,26 @@ func main() {
 	level.Info(util.Logger).Log("msg", "Tempo running")
 }
 
-func loadConfig() (*app.Config, error) {
-	const configFileOption = "config.file"
+// writeModules writes the module graph of the config.
+func writeModules(config app.Config, w io.Writer) error {
+	t, err := app.New(config)
+	if err != nil {
+		return err
+	}
+	return t.WriteModules(w)
+}
+
+func loadConfig() (*app.Config, []string, error) {
+	const (
+		configFileOption      = "config.file"
+		configStrictOption    = "config.strict"
+		configExpandEnvOption = "config.expand-env"
+	)
 
-	var configFile string
+	var configFiles configFileList
+	var configStrict bool
+	var configExpandEnv bool
+	var warnings []string
 
 	args := os.Args[1:]
 	config := &app.Config{}
@@ -109,10 +164,13 @@ func loadConfig() (*app.Config, error) {
 	fs := flag.NewFlagSet("", flag.ContinueOnError)
 	fs.SetOutput(ioutil.Discard)
 
-	fs.StringVar(&configFile, configFileOption, "", "")
+	fs.Var(&configFiles, configFileOption, "")
+	fs.BoolVar(&configStrict, configStrictOption, true, "")
+	fs.BoolVar(&configExpandEnv, configExpandEnvOption, false, "")
 
 	// Try to find -config.file flags. As Parsing stops on the first error, eg. unknown flag, we simply
-	// try remaining parameters until we find config flag, or there are no params left.
+	// try remaining parameters until we find config flag, or there are no params left.  The same flag
+	// may be parsed more than once so configFileList ignores duplicates.
 	// (ContinueOnError just means that flag.Parse doesn't call panic or os.Exit, but it returns error, which we ignore)
 	for len(args) > 0 {
 		_ = fs.Parse(args)
@@ -121,24 +179,26 @@ func loadConfig() (*app.Config, error) {
 
 	// load config defaults and register flags
 	config.RegisterFlagsAndApplyDefaults("", flag.CommandLine)
+	beforeFile := flagValues(flag.CommandLine)
 
-	// overlay with config file if provided
-	if configFile != "" {
-		buff, err := ioutil.ReadFile(configFile)
+	// overlay with config files in the order provided.  later files override earlier ones
+	for _, configFile := range configFiles {
+		fileWarnings, err := loadConfigFile(configFile, configStrict, configExpandEnv, config)
 		if err != nil {
-			return nil, fmt.Errorf("failed to read configFile %s: %w", configFile, err)
-		}
-
-		err = yaml.UnmarshalStrict(buff, config)
-		if err != nil {
-			return nil, fmt.Errorf("failed to parse configFile %s: %w", configFile, err)
+			return nil, nil, err
 		}
+		warnings = append(warnings, fileWarnings...)
 	}
+	afterFile := flagValues(flag.CommandLine)
 
 	// overlay with cli
-	flagext.IgnoredFlag(flag.CommandLine, configFileOption, "Configuration file to load")
+	flagext.IgnoredFlag(flag.CommandLine, configFileOption, "Configuration file to load. May be repeated, later files are deep merged over earlier ones.")
+	flagext.IgnoredFlag(flag.CommandLine, configStrictOption, "Fail on unknown fields in the configuration file. If false unknown fields are logged and ignored.")
+	flagext.IgnoredFlag(flag.CommandLine, configExpandEnvOption, "Replace ${VAR} and ${VAR:default} references in the configuration file with environment variables.")
 	flag.Parse()
 
+	warnings = append(warnings, conflictWarnings(flag.CommandLine, beforeFile, afterFile)...)
+
 	// after loading config, let's force some values if in single binary mode
 	// if we're in single binary mode we're going to force some settings b/c nothing else makes sense
 	if config.Target == app.All {
@@ -147,5 +207,5 @@ func loadConfig() (*app.Config, error) {
 		config.Ingester.LifecyclerConfig.Addr = "127.0.0.1"
 	}
 
-	return config, nil
+	return config, warnings, nil
 }
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/google/uuid"
+
+	"github.com/grafana/tempo/cmd/tempo/app"
+	"github.com/grafana/tempo/tempodb"
+)
+
+// errBlocksUnreadable is returned by runVerifyBlocks if any block failed verification.
+var errBlocksUnreadable = errors.New("unreadable blocks found")
+
+// runVerifyBlocks implements `tempo verify-blocks`.  It opens the backend of the config files and checks the meta,
+// bloom filter and index of each block of a tenant, or of every tenant, writing one line per block to out.  Orphaned
+// blocks are reported but don't fail the command, as blocks that are still being written look the same.
+func runVerifyBlocks(args []string, out io.Writer) error {
+	var (
+		configFiles  configFileList
+		configStrict bool
+		expandEnv    bool
+		tenantID     string
+		blockID      string
+		readData     bool
+		verbose      bool
+	)
+
+	fs := flag.NewFlagSet("verify-blocks", flag.ContinueOnError)
+	fs.SetOutput(out)
+	fs.Var(&configFiles, "config.file", "Configuration file to load. May be repeated, later files are deep merged over earlier ones.")
+	fs.BoolVar(&configStrict, "config.strict", true, "Fail on unknown fields in the configuration file.")
+	fs.BoolVar(&expandEnv, "config.expand-env", false, "Replace ${VAR} and ${VAR:default} references in the configuration file with environment variables.")
+	fs.StringVar(&tenantID, "tenant", "", "Tenant to verify the blocks of. All tenants if empty.")
+	fs.StringVar(&blockID, "block", "", "Block to verify. All blocks of the tenant if empty.  Requires -tenant.")
+	fs.BoolVar(&readData, "read-data", false, "Also read and decode every object.  This reads the whole block from the backend.")
+	fs.BoolVar(&verbose, "verbose", false, "List blocks that are ok and compacted blocks too.")
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+	if blockID != "" && tenantID == "" {
+		return fmt.Errorf("-block requires -tenant")
+	}
+
+	config := &app.Config{}
+	config.RegisterFlagsAndApplyDefaults("", flag.NewFlagSet("", flag.ContinueOnError))
+	for _, configFile := range configFiles {
+		if _, err := loadConfigFile(configFile, configStrict, expandEnv, config); err != nil {
+			return err
+		}
+	}
+
+	r, _, c, err := tempodb.NewBackend(&config.StorageConfig.Trace)
+	if err != nil {
+		return fmt.Errorf("failed to open backend: %w", err)
+	}
+	defer r.Shutdown()
+
+	ctx := context.Background()
+	var results []*tempodb.BlockVerification
+	switch {
+	case blockID != "":
+		id, err := uuid.Parse(blockID)
+		if err != nil {
+			return fmt.Errorf("invalid block id %s: %w", blockID, err)
+		}
+		results = append(results, tempodb.VerifyBlock(ctx, r, c, tenantID, id, readData))
+	default:
+		tenantIDs := []string{tenantID}
+		if tenantID == "" {
+			tenantIDs, err = r.Tenants(ctx)
+			if err != nil {
+				return fmt.Errorf("failed to list tenants: %w", err)
+			}
+		}
+		for _, tenant := range tenantIDs {
+			tenantResults, err := tempodb.VerifyBlocks(ctx, r, c, tenant, readData)
+			if err != nil {
+				return fmt.Errorf("failed to list blocks of tenant %s: %w", tenant, err)
+			}
+			results = append(results, tenantResults...)
+		}
+	}
+
+	counts := map[string]int{}
+	w := tabwriter.NewWriter(out, 0, 8, 2, ' ', 0)
+	for _, result := range results {
+		counts[result.State]++
+		if !verbose && (result.State == tempodb.BlockStateOK || result.State == tempodb.BlockStateCompacted) {
+			continue
+		}
+		fmt.Fprintf(w, "%s\t%v\t%s\t%s\n", result.TenantID, result.BlockID, result.State, strings.Join(result.Problems, "; "))
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(out, "%d blocks: %d ok, %d compacted, %d orphaned, %d unreadable\n", len(results),
+		counts[tempodb.BlockStateOK], counts[tempodb.BlockStateCompacted], counts[tempodb.BlockStateOrphaned], counts[tempodb.BlockStateUnreadable])
+
+	if counts[tempodb.BlockStateUnreadable] > 0 {
+		return errBlocksUnreadable
+	}
+	return nil
+}
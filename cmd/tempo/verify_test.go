@@ -0,0 +1,41 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunVerifyBlocks(t *testing.T) {
+	tempDir, err := ioutil.TempDir("/tmp", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(tempDir)
+
+	blockID := uuid.New()
+	require.NoError(t, os.MkdirAll(path.Join(tempDir, "traces", "single-tenant", blockID.String()), 0700))
+
+	configFile := path.Join(tempDir, "tempo.yaml")
+	require.NoError(t, ioutil.WriteFile(configFile, []byte(`
+storage:
+  trace:
+    backend: local
+    local:
+      path: `+path.Join(tempDir, "traces")+`
+`), 0644))
+
+	// orphaned blocks are reported but don't fail verification
+	out := &bytes.Buffer{}
+	err = runVerifyBlocks([]string{"-config.file=" + configFile}, out)
+	assert.NoError(t, err)
+	assert.Contains(t, out.String(), blockID.String())
+	assert.Contains(t, out.String(), "1 blocks: 0 ok, 0 compacted, 1 orphaned, 0 unreadable")
+
+	err = runVerifyBlocks([]string{"-config.file=" + configFile, "-block=" + blockID.String()}, &bytes.Buffer{})
+	assert.Error(t, err)
+}
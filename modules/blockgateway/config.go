@@ -0,0 +1,32 @@
+package blockgateway
+
+import (
+	"flag"
+	"time"
+
+	cortex_compactor "github.com/cortexproject/cortex/pkg/compactor"
+	"github.com/cortexproject/cortex/pkg/util/flagext"
+	"github.com/grafana/tempo/modules/storage"
+	"github.com/grafana/tempo/pkg/util"
+)
+
+// RingKey is the key under which block gateways are registered.
+const RingKey = "block-gateway"
+
+// Config for a block gateway.
+type Config struct {
+	ShardingRing    cortex_compactor.RingConfig `yaml:"ring,omitempty"`
+	WarmPeriod      time.Duration               `yaml:"warm_period"`
+	OverrideRingKey string                      `yaml:"override_ring_key"`
+}
+
+// RegisterFlagsAndApplyDefaults registers the flags.
+func (cfg *Config) RegisterFlagsAndApplyDefaults(prefix string, f *flag.FlagSet) {
+	flagext.DefaultValues(&cfg.ShardingRing)
+	cfg.ShardingRing.KVStore.Store = "memberlist"
+	f.DurationVar(&cfg.ShardingRing.HeartbeatPeriod, util.PrefixConfig(prefix, "ring.heartbeat-period"), cfg.ShardingRing.HeartbeatPeriod, "Period at which to heartbeat to the ring.")
+	f.DurationVar(&cfg.ShardingRing.HeartbeatTimeout, util.PrefixConfig(prefix, "ring.heartbeat-timeout"), cfg.ShardingRing.HeartbeatTimeout, "The heartbeat timeout after which block gateways are considered unhealthy within the ring.")
+
+	f.DurationVar(&cfg.WarmPeriod, util.PrefixConfig(prefix, "warm-period"), storage.DefaultBlocklistPoll, "Period at which to load the index and bloom filters of owned blocks into the cache.")
+	cfg.OverrideRingKey = RingKey
+}
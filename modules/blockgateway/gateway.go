@@ -0,0 +1,207 @@
+package blockgateway
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"time"
+
+	"github.com/cortexproject/cortex/pkg/ring"
+	"github.com/cortexproject/cortex/pkg/util"
+	"github.com/cortexproject/cortex/pkg/util/services"
+	"github.com/go-kit/kit/log/level"
+	"github.com/gogo/protobuf/proto"
+	"github.com/opentracing/opentracing-go"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/weaveworks/common/user"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/grafana/tempo/modules/storage"
+	"github.com/grafana/tempo/pkg/tempopb"
+	tempo_util "github.com/grafana/tempo/pkg/util"
+	"github.com/grafana/tempo/pkg/validation"
+	"github.com/grafana/tempo/tempodb/encoding"
+)
+
+// Gateway owns a shard of the blocks in the backend.  It keeps their indexes and bloom filters warm in the configured
+// cache and answers trace lookups for them over the Querier grpc service so queriers can stay stateless.
+type Gateway struct {
+	services.Service
+
+	cfg   *Config
+	store storage.Store
+
+	ringLifecycler *ring.Lifecycler
+	Ring           *ring.Ring
+
+	subservices        *services.Manager
+	subservicesWatcher *services.FailureWatcher
+}
+
+// New makes a new Gateway.
+func New(cfg Config, store storage.Store) (*Gateway, error) {
+	g := &Gateway{
+		cfg:   &cfg,
+		store: store,
+	}
+
+	lifecyclerCfg := g.cfg.ShardingRing.ToLifecyclerConfig()
+	lifecycler, err := ring.NewLifecycler(lifecyclerCfg, ring.NewNoopFlushTransferer(), "block-gateway", cfg.OverrideRingKey, false, prometheus.DefaultRegisterer)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to initialize block gateway ring lifecycler")
+	}
+	g.ringLifecycler = lifecycler
+
+	g.Ring, err = ring.New(lifecyclerCfg.RingConfig, "block-gateway", cfg.OverrideRingKey, prometheus.DefaultRegisterer)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to initialize block gateway ring")
+	}
+
+	g.subservices, err = services.NewManager(g.ringLifecycler, g.Ring)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create subservices %w", err)
+	}
+	g.subservicesWatcher = services.NewFailureWatcher()
+	g.subservicesWatcher.WatchManager(g.subservices)
+
+	g.Service = services.NewBasicService(g.starting, g.running, g.stopping)
+
+	return g, nil
+}
+
+func (g *Gateway) starting(ctx context.Context) error {
+	err := services.StartManagerAndAwaitHealthy(ctx, g.subservices)
+	if err != nil {
+		return fmt.Errorf("failed to start subservices %w", err)
+	}
+
+	level.Info(util.Logger).Log("msg", "waiting to be active in the ring")
+	return g.waitRingActive(ctx)
+}
+
+func (g *Gateway) running(ctx context.Context) error {
+	warmTicker := time.NewTicker(g.cfg.WarmPeriod)
+	defer warmTicker.Stop()
+
+	for {
+		select {
+		case <-warmTicker.C:
+			err := g.store.WarmBlocks(ctx, g.owns)
+			if err != nil {
+				level.Error(util.Logger).Log("msg", "failed to warm blocks", "err", err)
+			}
+
+		case <-ctx.Done():
+			return nil
+
+		case err := <-g.subservicesWatcher.Chan():
+			return fmt.Errorf("block gateway subservices failed %w", err)
+		}
+	}
+}
+
+// Called after block gateway is asked to stop via StopAsync.
+func (g *Gateway) stopping(_ error) error {
+	return services.StopManagerAndAwaitStopped(context.Background(), g.subservices)
+}
+
+// FindTraceByID implements tempopb.Querier.  Only blocks owned by this gateway are searched.
+func (g *Gateway) FindTraceByID(ctx context.Context, req *tempopb.TraceByIDRequest) (*tempopb.TraceByIDResponse, error) {
+	if !validation.ValidTraceID(req.TraceID) {
+		return nil, fmt.Errorf("invalid trace id")
+	}
+
+	span, ctx := opentracing.StartSpanFromContext(ctx, "BlockGateway.FindTraceByID")
+	defer span.Finish()
+
+	userID, err := user.ExtractOrgID(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "error extracting org id in BlockGateway.FindTraceByID")
+	}
+
+	foundBytes, _, err := g.store.FindInBlocks(ctx, userID, req.TraceID, g.owns, 0)
+	if err != nil {
+		return nil, errors.Wrap(err, "error querying store in BlockGateway.FindTraceByID")
+	}
+	if foundBytes == nil {
+		return &tempopb.TraceByIDResponse{}, nil
+	}
+
+	trace := &tempopb.Trace{}
+	err = proto.Unmarshal(foundBytes, trace)
+	if err != nil {
+		return nil, err
+	}
+
+	return &tempopb.TraceByIDResponse{
+		Trace: trace,
+	}, nil
+}
+
+// FindTraceByIDStream implements tempopb.Querier.  The trace is sent in chunks of batches.
+func (g *Gateway) FindTraceByIDStream(req *tempopb.TraceByIDRequest, stream tempopb.Querier_FindTraceByIDStreamServer) error {
+	resp, err := g.FindTraceByID(stream.Context(), req)
+	if err != nil {
+		return err
+	}
+
+	return tempo_util.SendTraceInChunks(resp, stream.Send)
+}
+
+// Search implements tempopb.Querier.  Queriers search the search sections of blocks directly so gateways don't serve
+// searches.
+func (g *Gateway) Search(_ context.Context, _ *tempopb.SearchRequest) (*tempopb.SearchResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "search is not served by the block gateway")
+}
+
+// Overview implements tempopb.Querier.  Overviews are computed from recent ingest so gateways don't serve them.
+func (g *Gateway) Overview(_ context.Context, _ *tempopb.OverviewRequest) (*tempopb.OverviewResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "overviews are not served by the block gateway")
+}
+
+// Tail implements tempopb.Querier.  Spans are tailed as they're pushed so gateways don't serve tails.
+func (g *Gateway) Tail(_ *tempopb.TailRequest, _ tempopb.Querier_TailServer) error {
+	return status.Error(codes.Unimplemented, "tails are not served by the block gateway")
+}
+
+// owns returns true if this gateway is the owner of the block in the ring.
+func (g *Gateway) owns(meta *encoding.BlockMeta) bool {
+	hasher := fnv.New32a()
+	_, _ = hasher.Write([]byte(meta.BlockID.String()))
+
+	rs, err := g.Ring.Get(hasher.Sum32(), ring.Read, []ring.IngesterDesc{})
+	if err != nil {
+		level.Error(util.Logger).Log("msg", "failed to get ring", "err", err)
+		return false
+	}
+
+	if len(rs.Ingesters) != 1 {
+		level.Error(util.Logger).Log("msg", "unexpected number of block gateways in the shard", "expected", 1, "got", len(rs.Ingesters))
+		return false
+	}
+
+	return rs.Ingesters[0].Addr == g.ringLifecycler.Addr
+}
+
+func (g *Gateway) waitRingActive(ctx context.Context) error {
+	for {
+		// Check if the gateway is ACTIVE in the ring and our ring client
+		// has detected it.
+		if rs, err := g.Ring.GetAll(ring.Reporting); err == nil {
+			for _, i := range rs.Ingesters {
+				if i.GetAddr() == g.ringLifecycler.Addr && i.GetState() == ring.ACTIVE {
+					return nil
+				}
+			}
+		}
+
+		select {
+		case <-time.After(time.Second):
+			// Nothing to do
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
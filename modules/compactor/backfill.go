@@ -0,0 +1,269 @@
+package compactor
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/cortexproject/cortex/pkg/util"
+	"github.com/go-kit/kit/log/level"
+	"github.com/gogo/protobuf/proto"
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/google/uuid"
+	v1 "github.com/open-telemetry/opentelemetry-proto/gen/go/trace/v1"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/weaveworks/common/user"
+
+	"github.com/grafana/tempo/pkg/blockwriter"
+	"github.com/grafana/tempo/pkg/tempopb"
+	"github.com/grafana/tempo/pkg/tenantmetrics"
+	tempo_util "github.com/grafana/tempo/pkg/util"
+	"github.com/grafana/tempo/pkg/validation"
+	"github.com/grafana/tempo/tempodb/encoding"
+)
+
+const (
+	contentTypeProtobuf  = "application/protobuf"
+	contentTypeXProtobuf = "application/x-protobuf"
+
+	backfillWritten  = "written"
+	backfillRejected = "rejected"
+)
+
+var (
+	metricBackfillSpans = tenantmetrics.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "tempo",
+		Name:      "compactor_backfill_spans_total",
+		Help:      "The total number of backfilled spans written to blocks, or rejected for being past retention, per tenant.",
+	}, []string{"tenant", "result"})
+	metricBackfillBlocks = tenantmetrics.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "tempo",
+		Name:      "compactor_backfill_blocks_total",
+		Help:      "The total number of blocks written by backfills per tenant.",
+	}, []string{"tenant"})
+)
+
+// BackfillConfig is the endpoint writing historical traces directly to blocks.
+type BackfillConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// RoleHeader is the request header the caller's role is read from.  It must be set by a trusted proxy.
+	RoleHeader string `yaml:"role_header"`
+	// AuthorizedRoles may backfill.  Requests of any other role are refused.
+	AuthorizedRoles []string `yaml:"authorized_roles"`
+	// MaxRequestBytes is the largest request body accepted.
+	MaxRequestBytes int `yaml:"max_request_bytes"`
+}
+
+// RegisterFlags registers the flags.  Authorized roles are yaml only.
+func (cfg *BackfillConfig) RegisterFlags(prefix string, f *flag.FlagSet) {
+	f.BoolVar(&cfg.Enabled, tempo_util.PrefixConfig(prefix, "enabled"), false, "Serve the backfill endpoint writing historical traces directly to blocks.")
+	f.StringVar(&cfg.RoleHeader, tempo_util.PrefixConfig(prefix, "role-header"), "X-Tempo-Role", "Request header holding the role of the caller.  Must be set by a trusted proxy.")
+	f.IntVar(&cfg.MaxRequestBytes, tempo_util.PrefixConfig(prefix, "max-request-bytes"), 64<<20, "Largest backfill request body accepted.")
+}
+
+func (cfg *BackfillConfig) authorized(role string) bool {
+	if role == "" {
+		return false
+	}
+
+	for _, r := range cfg.AuthorizedRoles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// BackfillResult reports what a backfill request wrote.
+type BackfillResult struct {
+	Traces int `json:"traces"`
+	Spans  int `json:"spans"`
+	// Traces that ended before the tenant's block retention aren't written since they would be deleted right away.
+	RejectedTraces int         `json:"rejectedTraces"`
+	RejectedSpans  int         `json:"rejectedSpans"`
+	Blocks         []uuid.UUID `json:"blocks"`
+}
+
+// BackfillHandler is a http.HandlerFunc writing the posted traces directly to blocks of the tenant, bypassing the
+// distributors and ingesters.  The traces are accepted as a json or, with a protobuf content type, protobuf encoded
+// tempopb.Trace whose spans may belong to any number of traces.  Every span must have an end time.
+func (c *Compactor) BackfillHandler(w http.ResponseWriter, r *http.Request) {
+	if !c.cfg.Backfill.authorized(r.Header.Get(c.cfg.Backfill.RoleHeader)) {
+		http.Error(w, "backfill is not authorized for the caller's role", http.StatusForbidden)
+		return
+	}
+
+	userID, err := user.ExtractOrgID(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	if !validation.ValidTenantID(userID) {
+		http.Error(w, fmt.Sprintf("invalid tenant id %q", userID), http.StatusBadRequest)
+		return
+	}
+
+	body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, int64(c.cfg.Backfill.MaxRequestBytes)))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	trace := &tempopb.Trace{}
+	switch r.Header.Get("Content-Type") {
+	case contentTypeProtobuf, contentTypeXProtobuf:
+		err = proto.Unmarshal(body, trace)
+	default:
+		err = jsonpb.UnmarshalString(string(body), trace)
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("unable to parse trace: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	traces, err := splitTraces(trace)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	result, err := c.backfill(r.Context(), userID, traces, time.Now())
+	if err != nil {
+		level.Error(util.Logger).Log("msg", "failed to backfill traces", "tenantID", userID, "err", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(result)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// backfill writes the traces to a block per compaction window, going by the end of each trace, so the blocks are
+// compacted with the others of their window.  Traces that ended before the tenant's retention cutoff are rejected.
+func (c *Compactor) backfill(ctx context.Context, tenantID string, traces map[string]*tempopb.Trace, now time.Time) (*BackfillResult, error) {
+	retention := c.cfg.Compactor.BlockRetention
+	if tenantRetention := c.overrides.BlockRetention(tenantID); tenantRetention != 0 {
+		retention = tenantRetention
+	}
+	cutoff := now.Add(-retention)
+	window := c.cfg.Compactor.MaxCompactionRange
+	if tenantWindow := c.overrides.CompactionWindow(tenantID); tenantWindow != 0 {
+		window = tenantWindow
+	}
+	windowSeconds := int64(window / time.Second)
+
+	result := &BackfillResult{}
+	windows := map[int64][]string{}
+	for id, trace := range traces {
+		_, _, spans, _, endNano := tempo_util.SummarizeTrace(trace)
+		end := time.Unix(0, int64(endNano))
+		if end.Before(cutoff) {
+			result.RejectedTraces++
+			result.RejectedSpans += spans
+			continue
+		}
+
+		result.Traces++
+		result.Spans += spans
+		var window int64
+		if windowSeconds > 0 {
+			window = end.Unix() / windowSeconds
+		}
+		windows[window] = append(windows[window], id)
+	}
+	metricBackfillSpans.WithLabelValues(tenantID, backfillRejected).Add(float64(result.RejectedSpans))
+
+	keys := make([]int64, 0, len(windows))
+	for window := range windows {
+		keys = append(keys, window)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+
+	w := blockwriter.FromWriter(c.store)
+	metas := make([]*encoding.BlockMeta, 0, len(keys))
+	for _, window := range keys {
+		meta, err := writeBackfillBlock(ctx, w, tenantID, windows[window], traces)
+		if err != nil {
+			return nil, err
+		}
+		metas = append(metas, meta)
+		result.Blocks = append(result.Blocks, meta.BlockID)
+	}
+
+	// the blocks are found by the queriers and other compactors once they poll the blocklist
+	c.store.AddBlocks(metas)
+	metricBackfillSpans.WithLabelValues(tenantID, backfillWritten).Add(float64(result.Spans))
+	metricBackfillBlocks.WithLabelValues(tenantID).Add(float64(len(metas)))
+
+	return result, nil
+}
+
+func writeBackfillBlock(ctx context.Context, w *blockwriter.Writer, tenantID string, ids []string, traces map[string]*tempopb.Trace) (*encoding.BlockMeta, error) {
+	block, err := w.NewBlock(tenantID)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, id := range ids {
+		err = block.Write([]byte(id), traces[id])
+		if err != nil {
+			_ = block.Clear()
+			return nil, err
+		}
+	}
+
+	return block.Flush(ctx)
+}
+
+// splitTraces groups the spans of trace by their trace id, keeping the resource and instrumentation library of each
+// span.  It returns an error if a span has an invalid trace id or no end time.
+func splitTraces(trace *tempopb.Trace) (map[string]*tempopb.Trace, error) {
+	traces := map[string]*tempopb.Trace{}
+	for _, batch := range trace.Batches {
+		batches := map[string]*v1.ResourceSpans{}
+		for _, ils := range batch.InstrumentationLibrarySpans {
+			libraries := map[string]*v1.InstrumentationLibrarySpans{}
+			for _, span := range ils.Spans {
+				if !validation.ValidTraceID(span.TraceId) {
+					return nil, fmt.Errorf("invalid trace id %x.  trace ids must be 128 bit", span.TraceId)
+				}
+				if span.EndTimeUnixNano == 0 {
+					return nil, fmt.Errorf("span %x of trace %x has no end time", span.SpanId, span.TraceId)
+				}
+
+				key := string(span.TraceId)
+				out, ok := batches[key]
+				if !ok {
+					out = &v1.ResourceSpans{Resource: batch.Resource}
+					batches[key] = out
+
+					t, ok := traces[key]
+					if !ok {
+						t = &tempopb.Trace{}
+						traces[key] = t
+					}
+					t.Batches = append(t.Batches, out)
+				}
+
+				library, ok := libraries[key]
+				if !ok {
+					library = &v1.InstrumentationLibrarySpans{InstrumentationLibrary: ils.InstrumentationLibrary}
+					libraries[key] = library
+					out.InstrumentationLibrarySpans = append(out.InstrumentationLibrarySpans, library)
+				}
+				library.Spans = append(library.Spans, span)
+			}
+		}
+	}
+
+	return traces, nil
+}
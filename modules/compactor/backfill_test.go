@@ -0,0 +1,177 @@
+package compactor
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/cortexproject/cortex/pkg/util/services"
+	"github.com/go-kit/kit/log"
+	"github.com/gogo/protobuf/proto"
+	v1 "github.com/open-telemetry/opentelemetry-proto/gen/go/trace/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/weaveworks/common/user"
+
+	"github.com/grafana/tempo/modules/overrides"
+	"github.com/grafana/tempo/pkg/tempopb"
+	"github.com/grafana/tempo/tempodb"
+	"github.com/grafana/tempo/tempodb/backend/local"
+	"github.com/grafana/tempo/tempodb/wal"
+)
+
+type testStore struct {
+	services.Service
+	tempodb.Reader
+	tempodb.Writer
+	tempodb.Compactor
+}
+
+func backfillStoreConfig(dir string) *tempodb.Config {
+	return &tempodb.Config{
+		Backend: "local",
+		Local: &local.Config{
+			Path: path.Join(dir, "traces"),
+		},
+		WAL: &wal.Config{
+			Filepath:        path.Join(dir, "wal"),
+			IndexDownsample: 3,
+			BloomFP:         .01,
+		},
+	}
+}
+
+func newBackfillCompactor(t *testing.T, dir string) *Compactor {
+	r, w, c, err := tempodb.New(backfillStoreConfig(dir), log.NewNopLogger())
+	require.NoError(t, err)
+
+	o, err := overrides.NewOverrides(overrides.Limits{})
+	require.NoError(t, err)
+
+	return &Compactor{
+		cfg: &Config{
+			Compactor: tempodb.CompactorConfig{
+				BlockRetention:     7 * 24 * time.Hour,
+				MaxCompactionRange: time.Hour,
+			},
+			Backfill: BackfillConfig{
+				RoleHeader:      "X-Tempo-Role",
+				AuthorizedRoles: []string{"admin"},
+				MaxRequestBytes: 1 << 20,
+			},
+		},
+		store:     testStore{Reader: r, Writer: w, Compactor: c},
+		overrides: o,
+	}
+}
+
+// backfillSpan returns a span of the trace ending at end.
+func backfillSpan(traceID byte, spanID byte, end time.Time) *v1.Span {
+	return &v1.Span{
+		TraceId:           append(make([]byte, 15), traceID),
+		SpanId:            []byte{spanID},
+		Name:              "test",
+		StartTimeUnixNano: uint64(end.Add(-time.Second).UnixNano()),
+		EndTimeUnixNano:   uint64(end.UnixNano()),
+	}
+}
+
+func TestSplitTraces(t *testing.T) {
+	end := time.Now()
+	traces, err := splitTraces(&tempopb.Trace{Batches: []*v1.ResourceSpans{
+		{InstrumentationLibrarySpans: []*v1.InstrumentationLibrarySpans{
+			{Spans: []*v1.Span{backfillSpan(1, 1, end), backfillSpan(2, 2, end), backfillSpan(1, 3, end)}},
+			{Spans: []*v1.Span{backfillSpan(1, 4, end)}},
+		}},
+		{InstrumentationLibrarySpans: []*v1.InstrumentationLibrarySpans{
+			{Spans: []*v1.Span{backfillSpan(2, 5, end)}},
+		}},
+	}})
+	require.NoError(t, err)
+	require.Len(t, traces, 2)
+
+	trace := traces[string(append(make([]byte, 15), 1))]
+	require.Len(t, trace.Batches, 1)
+	require.Len(t, trace.Batches[0].InstrumentationLibrarySpans, 2)
+	assert.Len(t, trace.Batches[0].InstrumentationLibrarySpans[0].Spans, 2)
+	assert.Len(t, trace.Batches[0].InstrumentationLibrarySpans[1].Spans, 1)
+	assert.Len(t, traces[string(append(make([]byte, 15), 2))].Batches, 2)
+
+	_, err = splitTraces(&tempopb.Trace{Batches: []*v1.ResourceSpans{{InstrumentationLibrarySpans: []*v1.InstrumentationLibrarySpans{
+		{Spans: []*v1.Span{{TraceId: []byte{0x01}, EndTimeUnixNano: 1}}},
+	}}}})
+	assert.Error(t, err)
+
+	_, err = splitTraces(&tempopb.Trace{Batches: []*v1.ResourceSpans{{InstrumentationLibrarySpans: []*v1.InstrumentationLibrarySpans{
+		{Spans: []*v1.Span{{TraceId: make([]byte, 16)}}},
+	}}}})
+	assert.Error(t, err)
+}
+
+func TestBackfill(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	c := newBackfillCompactor(t, dir)
+	now := time.Now().Truncate(time.Hour)
+
+	trace := &tempopb.Trace{Batches: []*v1.ResourceSpans{{InstrumentationLibrarySpans: []*v1.InstrumentationLibrarySpans{{
+		Spans: []*v1.Span{
+			// two traces in one window, one in another and one past retention
+			backfillSpan(1, 1, now.Add(-48*time.Hour+10*time.Minute)),
+			backfillSpan(2, 2, now.Add(-48*time.Hour+20*time.Minute)),
+			backfillSpan(2, 3, now.Add(-48*time.Hour+30*time.Minute)),
+			backfillSpan(3, 4, now.Add(-24*time.Hour+10*time.Minute)),
+			backfillSpan(4, 5, now.Add(-30*24*time.Hour)),
+		},
+	}}}}}
+	body, err := proto.Marshal(trace)
+	require.NoError(t, err)
+
+	post := func(role string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, "/api/backfill", bytes.NewReader(body))
+		r = r.WithContext(user.InjectOrgID(r.Context(), "test"))
+		r.Header.Set("Content-Type", contentTypeProtobuf)
+		r.Header.Set("X-Tempo-Role", role)
+		w := httptest.NewRecorder()
+		c.BackfillHandler(w, r)
+		return w
+	}
+
+	assert.Equal(t, http.StatusForbidden, post("").Code)
+	assert.Equal(t, http.StatusForbidden, post("viewer").Code)
+
+	w := post("admin")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	result := &BackfillResult{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), result))
+	assert.Equal(t, 3, result.Traces)
+	assert.Equal(t, 4, result.Spans)
+	assert.Equal(t, 1, result.RejectedTraces)
+	assert.Equal(t, 1, result.RejectedSpans)
+	require.Len(t, result.Blocks, 2)
+
+	// the blocks are in the windows of their traces
+	r, _, _, err := tempodb.NewBackend(backfillStoreConfig(dir))
+	require.NoError(t, err)
+	for _, id := range result.Blocks {
+		meta, err := r.BlockMeta(context.Background(), id, "test")
+		require.NoError(t, err)
+		assert.True(t, meta.EndTime.Before(now.Add(-23*time.Hour)), meta.EndTime)
+		assert.True(t, meta.StartTime.Truncate(time.Hour).Equal(meta.EndTime.Truncate(time.Hour)), meta.StartTime)
+	}
+
+	obj, _, err := c.store.Find(context.Background(), "test", append(make([]byte, 15), 2))
+	require.NoError(t, err)
+	found := &tempopb.Trace{}
+	require.NoError(t, proto.Unmarshal(obj, found))
+	assert.Len(t, found.Batches[0].InstrumentationLibrarySpans[0].Spans, 2)
+}
@@ -2,16 +2,23 @@ package compactor
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"hash/fnv"
+	"net/http"
 	"time"
 
 	"github.com/cortexproject/cortex/pkg/ring"
 	"github.com/cortexproject/cortex/pkg/util"
 	"github.com/cortexproject/cortex/pkg/util/services"
 	"github.com/go-kit/kit/log/level"
+	"github.com/gorilla/mux"
+	"github.com/grafana/tempo/modules/overrides"
 	"github.com/grafana/tempo/modules/storage"
 	tempo_util "github.com/grafana/tempo/pkg/util"
+	"github.com/grafana/tempo/pkg/validation"
+	"github.com/grafana/tempo/tempodb"
+	"github.com/grafana/tempo/tempodb/encoding"
 	"github.com/pkg/errors"
 	"github.com/prometheus/client_golang/prometheus"
 )
@@ -23,8 +30,9 @@ const (
 type Compactor struct {
 	services.Service
 
-	cfg   *Config
-	store storage.Store
+	cfg       *Config
+	store     storage.Store
+	overrides *overrides.Overrides
 
 	// Ring used for sharding compactions.
 	ringLifecycler *ring.Lifecycler
@@ -35,10 +43,11 @@ type Compactor struct {
 }
 
 // New makes a new Querier.
-func New(cfg Config, store storage.Store) (*Compactor, error) {
+func New(cfg Config, store storage.Store, overrides *overrides.Overrides) (*Compactor, error) {
 	c := &Compactor{
-		cfg:   &cfg,
-		store: store,
+		cfg:       &cfg,
+		store:     store,
+		overrides: overrides,
 	}
 
 	subservices := []services.Service(nil)
@@ -95,7 +104,7 @@ func (c *Compactor) running(ctx context.Context) error {
 		level.Info(util.Logger).Log("msg", "waiting for compaction ring to settle", "waitDuration", waitOnStartup)
 		time.Sleep(waitOnStartup)
 		level.Info(util.Logger).Log("msg", "enabling compaction")
-		c.store.EnableCompaction(&c.cfg.Compactor, c)
+		c.store.EnableCompaction(&c.cfg.Compactor, c, c)
 	}()
 
 	if c.subservices != nil {
@@ -148,10 +157,75 @@ func (c *Compactor) Owns(hash string) bool {
 	return rs.Ingesters[0].Addr == c.ringLifecycler.Addr
 }
 
+// PlanHandler writes the blocks the compactor would compact next, per tenant, without performing any work.  Pass
+// ?tenant=<id> to restrict the output to a single tenant.
+func (c *Compactor) PlanHandler(w http.ResponseWriter, r *http.Request) {
+	plans, err := c.store.CompactionPlan()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+
+	if tenantID := r.URL.Query().Get("tenant"); tenantID != "" {
+		filtered := make([]*tempodb.CompactionPlan, 0, 1)
+		for _, p := range plans {
+			if p.TenantID == tenantID {
+				filtered = append(filtered, p)
+			}
+		}
+		plans = filtered
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(plans)
+	if err != nil {
+		level.Error(util.Logger).Log("msg", "failed to write compaction plan", "err", err)
+	}
+}
+
+// DeleteTenantHandler marks the tenant for deletion.  The compactors delete its blocks asynchronously and report their
+// progress with the tempodb_tenant_deletion_* metrics.
+func (c *Compactor) DeleteTenantHandler(w http.ResponseWriter, r *http.Request) {
+	tenantID := mux.Vars(r)["tenant"]
+	if !validation.ValidTenantID(tenantID) {
+		http.Error(w, fmt.Sprintf("invalid tenant id %q", tenantID), http.StatusBadRequest)
+		return
+	}
+
+	err := c.store.MarkTenantDeleted(tenantID)
+	if err != nil {
+		level.Error(util.Logger).Log("msg", "failed to mark tenant for deletion", "tenantID", tenantID, "err", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
 func (c *Compactor) Combine(objA []byte, objB []byte) []byte {
 	return tempo_util.CombineTraces(objA, objB)
 }
 
+// SearchEntry implements encoding.ObjectSearcher so compacted blocks are written with search data.
+func (c *Compactor) SearchEntry(obj []byte) (*encoding.SearchEntry, error) {
+	return tempo_util.SearchEntry(obj)
+}
+
+// BlockRetentionForTenant implements tempodb.CompactorOverrides
+func (c *Compactor) BlockRetentionForTenant(tenantID string) time.Duration {
+	return c.overrides.BlockRetention(tenantID)
+}
+
+// CompactionWindowForTenant implements tempodb.CompactorOverrides
+func (c *Compactor) CompactionWindowForTenant(tenantID string) time.Duration {
+	return c.overrides.CompactionWindow(tenantID)
+}
+
+// CompactionDisabledForTenant implements tempodb.CompactorOverrides
+func (c *Compactor) CompactionDisabledForTenant(tenantID string) bool {
+	return c.overrides.CompactionDisabled(tenantID)
+}
+
 func (c *Compactor) waitRingActive(ctx context.Context) error {
 	for {
 		// Check if the ingester is ACTIVE in the ring and our ring client
@@ -15,6 +15,7 @@ type Config struct {
 	ShardingRing    cortex_compactor.RingConfig `yaml:"ring,omitempty"`
 	Compactor       tempodb.CompactorConfig     `yaml:"compaction"`
 	OverrideRingKey string                      `yaml:"override_ring_key"`
+	Backfill        BackfillConfig              `yaml:"backfill"`
 }
 
 // RegisterFlagsAndApplyDefaults registers the flags.
@@ -27,9 +28,26 @@ func (cfg *Config) RegisterFlagsAndApplyDefaults(prefix string, f *flag.FlagSet)
 
 	flagext.DefaultValues(&cfg.ShardingRing)
 	cfg.ShardingRing.KVStore.Store = "" // by default compactor is not sharded
+	f.DurationVar(&cfg.ShardingRing.HeartbeatPeriod, util.PrefixConfig(prefix, "ring.heartbeat-period"), cfg.ShardingRing.HeartbeatPeriod, "Period at which to heartbeat to the ring.")
+	f.DurationVar(&cfg.ShardingRing.HeartbeatTimeout, util.PrefixConfig(prefix, "ring.heartbeat-timeout"), cfg.ShardingRing.HeartbeatTimeout, "The heartbeat timeout after which compactors are considered unhealthy within the ring.")
 
 	f.DurationVar(&cfg.Compactor.BlockRetention, util.PrefixConfig(prefix, "compaction.block-retention"), 14*24*time.Hour, "Duration to keep blocks/traces.")
 	f.IntVar(&cfg.Compactor.MaxCompactionObjects, util.PrefixConfig(prefix, "compaction.max-objects-per-block"), 6000000, "Maximum number of traces in a compacted block.")
+	f.Uint64Var(&cfg.Compactor.MaxBlockBytes, util.PrefixConfig(prefix, "compaction.max-block-bytes"), 100*1024*1024*1024 /* 100GiB */, "Maximum size of a compacted block in bytes. Larger blocks aren't compacted again. 0 is unlimited.")
+	f.IntVar(&cfg.Compactor.IteratorBufferSize, util.PrefixConfig(prefix, "compaction.iterator-buffer-size"), 1000, "Number of objects to prefetch from each input block during compaction. 0 disables prefetching.")
+	f.IntVar(&cfg.Compactor.Level0Concurrency, util.PrefixConfig(prefix, "compaction.level0-concurrency"), 0, "Number of workers compacting level 0 blocks. If this and compaction.higher-level-concurrency are 0 compaction jobs run one at a time.")
+	f.IntVar(&cfg.Compactor.HigherLevelConcurrency, util.PrefixConfig(prefix, "compaction.higher-level-concurrency"), 0, "Number of workers compacting blocks at level 1 and above.")
+	f.IntVar(&cfg.Compactor.ReindexBlocksPerCycle, util.PrefixConfig(prefix, "compaction.reindex-blocks-per-cycle"), 0, "Number of blocks per compaction cycle to rebuild the bloom filter and index for when they were built with different settings. 0 disables reindexing.")
+	f.Float64Var(&cfg.Compactor.ReindexBloomFP, util.PrefixConfig(prefix, "compaction.reindex-bloom-filter-false-positive"), .05, "Bloom filter false positive rate to rebuild existing blocks with.")
+	f.IntVar(&cfg.Compactor.ReindexIndexDownsample, util.PrefixConfig(prefix, "compaction.reindex-index-downsample"), 100, "Number of traces per index record to rebuild existing blocks with.")
+	f.DurationVar(&cfg.Compactor.ThrottleBackoff, util.PrefixConfig(prefix, "compaction.throttle-backoff"), 30*time.Second, "How long compaction pauses after the backend throttles a compaction. 0 disables throttling.")
+	f.DurationVar(&cfg.Compactor.ThrottleMaxBackoff, util.PrefixConfig(prefix, "compaction.throttle-max-backoff"), 10*time.Minute, "Maximum pause after repeated throttling.")
+	f.DurationVar(&cfg.Compactor.OrphanGCInterval, util.PrefixConfig(prefix, "compaction.orphan-gc-interval"), time.Hour, "How often to look for blocks and uploads that were never completed. 0 disables.")
+	f.DurationVar(&cfg.Compactor.OrphanRetention, util.PrefixConfig(prefix, "compaction.orphan-retention"), 24*time.Hour, "Duration after which blocks and uploads that were never completed are deleted.")
+	f.BoolVar(&cfg.Compactor.OrphanGCDryRun, util.PrefixConfig(prefix, "compaction.orphan-gc-dry-run"), false, "Log and count blocks and uploads that were never completed without deleting them.")
+	f.DurationVar(&cfg.Compactor.TenantDeletionGracePeriod, util.PrefixConfig(prefix, "compaction.tenant-deletion-grace-period"), time.Hour, "Duration after a tenant is marked for deletion during which blocks flushed for it are still deleted before the mark is cleared.")
 	f.DurationVar(&cfg.Compactor.MaxCompactionRange, util.PrefixConfig(prefix, "compaction.compaction-window"), 4*time.Hour, "Maximum time window across which to compact blocks.")
 	cfg.OverrideRingKey = ring.CompactorRingKey
+
+	cfg.Backfill.RegisterFlags(util.PrefixConfig(prefix, "backfill"), f)
 }
@@ -0,0 +1,480 @@
+package distributor
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"flag"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	cortex_util "github.com/cortexproject/cortex/pkg/util"
+	"github.com/go-kit/kit/log/level"
+	opentelemetry_proto_trace_v1 "github.com/open-telemetry/opentelemetry-proto/gen/go/trace/v1"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	ingester_client "github.com/grafana/tempo/modules/ingester/client"
+	"github.com/grafana/tempo/pkg/tenantmetrics"
+	"github.com/grafana/tempo/pkg/util"
+)
+
+const asyncSegmentSuffix = ".seg"
+
+var (
+	metricAsyncPushes = tenantmetrics.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "tempo",
+		Name:      "distributor_async_pushes_total",
+		Help:      "The total number of pushes of tenants with async write acks by result. Pushes fall back to waiting for the ingesters when the queue is full.",
+	}, []string{"tenant", "result"})
+	metricAsyncQueueLength = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: "tempo",
+		Name:      "distributor_async_queue_length",
+		Help:      "The number of acknowledged pushes not yet delivered to the ingesters.",
+	})
+	metricAsyncReplayedPushes = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: "tempo",
+		Name:      "distributor_async_replayed_pushes_total",
+		Help:      "The total number of pushes replayed from the async queue on disk at startup.",
+	})
+)
+
+// AsyncAckConfig is the disk queue of tenants with the async_write_ack override.  Their pushes are acknowledged once
+// written to the queue and delivered to the ingesters in the background.  Pushes left in the queue when the
+// distributor stops are replayed when it starts again.
+type AsyncAckConfig struct {
+	// Path of the queue.  Async acks are disabled if empty.
+	Path string `yaml:"path"`
+	// SyncInterval is how often the queue is flushed to disk.  Pushes written since the last sync are lost if the
+	// machine crashes.  0 syncs every push.
+	SyncInterval time.Duration `yaml:"sync_interval"`
+	// MaxSegmentBytes is the size at which a new segment file is started.  A segment is deleted once every push in it
+	// was delivered.
+	MaxSegmentBytes int `yaml:"max_segment_bytes"`
+	// MaxPendingPushes bounds the pushes waiting for delivery.  Further pushes wait for the ingesters.
+	MaxPendingPushes int `yaml:"max_pending_pushes"`
+	// Workers deliver pushes concurrently.
+	Workers int `yaml:"workers"`
+	// Backoff is the wait before a failed delivery is retried, doubled up to a minute for every following failure.
+	Backoff time.Duration `yaml:"backoff"`
+}
+
+// RegisterFlags registers the flags.
+func (cfg *AsyncAckConfig) RegisterFlags(prefix string, f *flag.FlagSet) {
+	f.StringVar(&cfg.Path, util.PrefixConfig(prefix, "path"), "", "Directory of the disk queue of pushes acknowledged before they reach the ingesters, for tenants with the async write ack override. Empty disables async acks.")
+	f.DurationVar(&cfg.SyncInterval, util.PrefixConfig(prefix, "sync-interval"), time.Second, "How often the queue is synced to disk. Pushes written since are lost if the machine crashes. 0 syncs every push.")
+	f.IntVar(&cfg.MaxSegmentBytes, util.PrefixConfig(prefix, "max-segment-bytes"), 64*1024*1024, "Size at which the queue starts a new segment file.")
+	f.IntVar(&cfg.MaxPendingPushes, util.PrefixConfig(prefix, "max-pending-pushes"), 10000, "Most pushes waiting for delivery. Further pushes wait for the ingesters.")
+	f.IntVar(&cfg.Workers, util.PrefixConfig(prefix, "workers"), 10, "Number of pushes delivered to the ingesters at once.")
+	f.DurationVar(&cfg.Backoff, util.PrefixConfig(prefix, "backoff"), 100*time.Millisecond, "Wait before a failed delivery is retried, doubled for every following failure.")
+}
+
+// asyncSegment is a file of the queue.
+type asyncSegment struct {
+	seq     int
+	path    string
+	file    *os.File // nil once the segment is full
+	size    int
+	pending int // pushes not delivered yet
+}
+
+type asyncPush struct {
+	userID  string
+	batch   *opentelemetry_proto_trace_v1.ResourceSpans
+	spans   int
+	segment *asyncSegment
+}
+
+// asyncPushQueue writes pushes to segment files before acknowledging them and delivers them with send.  Delivery is at
+// least once: pushes delivered before a crash but still in a segment are delivered again, and their repeated spans are
+// deduplicated when the trace is combined.
+type asyncPushQueue struct {
+	cfg  AsyncAckConfig
+	send sendFunc
+
+	mtx      sync.Mutex
+	stopped  bool
+	current  *asyncSegment
+	nextSeq  int
+	replay   []*asyncSegment // segments left by the previous run
+	unsynced bool
+
+	queue    chan asyncPush
+	replayed chan asyncPush // pushes of the replayed segments.  unbuffered so they don't take room from new pushes
+	quit     chan struct{}
+	wg       sync.WaitGroup
+}
+
+func newAsyncPushQueue(cfg AsyncAckConfig, send sendFunc) (*asyncPushQueue, error) {
+	if cfg.MaxSegmentBytes <= 0 {
+		cfg.MaxSegmentBytes = 64 * 1024 * 1024
+	}
+	if cfg.MaxPendingPushes <= 0 {
+		cfg.MaxPendingPushes = 10000
+	}
+	if cfg.Workers <= 0 {
+		cfg.Workers = 1
+	}
+	if cfg.Backoff <= 0 {
+		cfg.Backoff = 100 * time.Millisecond
+	}
+
+	if err := os.MkdirAll(cfg.Path, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create async ack queue %s: %w", cfg.Path, err)
+	}
+	files, err := ioutil.ReadDir(cfg.Path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read async ack queue %s: %w", cfg.Path, err)
+	}
+
+	q := &asyncPushQueue{
+		cfg:      cfg,
+		send:     send,
+		queue:    make(chan asyncPush, cfg.MaxPendingPushes),
+		replayed: make(chan asyncPush),
+		quit:     make(chan struct{}),
+	}
+	for _, f := range files {
+		seq, err := strconv.Atoi(strings.TrimSuffix(f.Name(), asyncSegmentSuffix))
+		if err != nil || !strings.HasSuffix(f.Name(), asyncSegmentSuffix) {
+			continue
+		}
+		q.replay = append(q.replay, &asyncSegment{seq: seq, path: filepath.Join(cfg.Path, f.Name())})
+		if seq >= q.nextSeq {
+			q.nextSeq = seq + 1
+		}
+	}
+	sort.Slice(q.replay, func(i, j int) bool {
+		return q.replay[i].seq < q.replay[j].seq
+	})
+
+	return q, nil
+}
+
+// start replays the segments left by the previous run and starts delivering pushes.
+func (q *asyncPushQueue) start() {
+	for i := 0; i < q.cfg.Workers; i++ {
+		q.wg.Add(1)
+		go q.deliverLoop()
+	}
+	if q.cfg.SyncInterval > 0 {
+		q.wg.Add(1)
+		go q.syncLoop()
+	}
+
+	q.wg.Add(1)
+	go q.replaySegments()
+}
+
+// enqueue writes the push to the queue.  It returns false if the push wasn't queued and must be sent to the ingesters
+// instead.  The batch must not be modified afterwards.
+func (q *asyncPushQueue) enqueue(userID string, batch *opentelemetry_proto_trace_v1.ResourceSpans, spanCount int) bool {
+	record, err := marshalAsyncRecord(userID, batch)
+	if err != nil {
+		metricAsyncPushes.WithLabelValues(userID, "fallback").Inc()
+		return false
+	}
+
+	q.mtx.Lock()
+	defer q.mtx.Unlock()
+
+	// only enqueue adds to the queue and holds the lock while doing so, so there is room for the push if there is now
+	if q.stopped || len(q.queue) >= cap(q.queue) {
+		metricAsyncPushes.WithLabelValues(userID, "fallback").Inc()
+		return false
+	}
+
+	segment, err := q.write(record)
+	if err != nil {
+		level.Error(cortex_util.Logger).Log("msg", "failed to write to async ack queue", "err", err)
+		metricAsyncPushes.WithLabelValues(userID, "fallback").Inc()
+		return false
+	}
+
+	segment.pending++
+	metricAsyncQueueLength.Inc()
+	q.queue <- asyncPush{userID: userID, batch: batch, spans: spanCount, segment: segment}
+	metricAsyncPushes.WithLabelValues(userID, "queued").Inc()
+	return true
+}
+
+// write appends the record to the current segment, starting a new one if needed.  Requires q.mtx.
+func (q *asyncPushQueue) write(record []byte) (*asyncSegment, error) {
+	if q.current != nil && q.current.size+len(record) > q.cfg.MaxSegmentBytes && q.current.size > 0 {
+		if err := q.closeCurrent(); err != nil {
+			return nil, err
+		}
+	}
+	if q.current == nil {
+		path := filepath.Join(q.cfg.Path, fmt.Sprintf("%010d%s", q.nextSeq, asyncSegmentSuffix))
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+		if err != nil {
+			return nil, err
+		}
+		q.current = &asyncSegment{seq: q.nextSeq, path: path, file: f}
+		q.nextSeq++
+	}
+
+	if _, err := q.current.file.Write(record); err != nil {
+		// a partial record would hide the records written after it from the replay
+		_ = q.closeCurrent()
+		return nil, err
+	}
+	q.current.size += len(record)
+
+	if q.cfg.SyncInterval <= 0 {
+		if err := q.current.file.Sync(); err != nil {
+			_ = q.closeCurrent()
+			return nil, err
+		}
+	} else {
+		q.unsynced = true
+	}
+	return q.current, nil
+}
+
+// closeCurrent syncs and closes the current segment.  It's deleted once its pushes are delivered.  Requires q.mtx.
+func (q *asyncPushQueue) closeCurrent() error {
+	s := q.current
+	q.current = nil
+
+	err := s.file.Sync()
+	if closeErr := s.file.Close(); err == nil {
+		err = closeErr
+	}
+	s.file = nil
+	q.removeIfDelivered(s)
+	return err
+}
+
+// removeIfDelivered deletes a closed segment without pending pushes.  Requires q.mtx.
+func (q *asyncPushQueue) removeIfDelivered(s *asyncSegment) {
+	if s.file != nil || s.pending > 0 {
+		return
+	}
+	if err := os.Remove(s.path); err != nil && !os.IsNotExist(err) {
+		level.Error(cortex_util.Logger).Log("msg", "failed to remove async ack segment", "segment", s.path, "err", err)
+	}
+}
+
+func (q *asyncPushQueue) syncLoop() {
+	defer q.wg.Done()
+
+	ticker := time.NewTicker(q.cfg.SyncInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			q.mtx.Lock()
+			if q.unsynced && q.current != nil {
+				if err := q.current.file.Sync(); err != nil {
+					level.Error(cortex_util.Logger).Log("msg", "failed to sync async ack queue", "err", err)
+				}
+			}
+			q.unsynced = false
+			q.mtx.Unlock()
+		case <-q.quit:
+			return
+		}
+	}
+}
+
+// replaySegments queues the pushes of the segments left by the previous run.  A segment is read up to its first
+// corrupt record, which is where a crash interrupted a write.
+func (q *asyncPushQueue) replaySegments() {
+	defer q.wg.Done()
+
+	for _, s := range q.replay {
+		pushes, err := readAsyncSegment(s.path)
+		if err != nil {
+			level.Warn(cortex_util.Logger).Log("msg", "async ack segment is corrupt, replaying the pushes before the corruption", "segment", s.path, "pushes", len(pushes), "err", err)
+		}
+
+		q.mtx.Lock()
+		s.pending = len(pushes)
+		q.removeIfDelivered(s)
+		q.mtx.Unlock()
+
+		for _, p := range pushes {
+			p.segment = s
+			metricAsyncQueueLength.Inc()
+			metricAsyncReplayedPushes.Inc()
+			select {
+			case q.replayed <- p:
+			case <-q.quit:
+				metricAsyncQueueLength.Dec()
+				return
+			}
+		}
+	}
+}
+
+func (q *asyncPushQueue) deliverLoop() {
+	defer q.wg.Done()
+
+	for {
+		select {
+		case p := <-q.queue:
+			q.deliver(p)
+		case p := <-q.replayed:
+			q.deliver(p)
+		case <-q.quit:
+			return
+		}
+	}
+}
+
+// deliver sends the push until it's accepted or rejected by the ingesters.  Pushes still failing when the queue stops
+// are left in their segment and replayed on the next start.
+func (q *asyncPushQueue) deliver(p asyncPush) {
+	defer metricAsyncQueueLength.Dec()
+
+	backoff := q.cfg.Backoff
+	for {
+		err := q.send(p.userID, []*opentelemetry_proto_trace_v1.ResourceSpans{p.batch}, p.spans)
+		if err == nil || !retryableDelivery(err) {
+			result := "delivered"
+			if err != nil {
+				// the client was already acknowledged, so spans over a limit are dropped and counted as discarded
+				result = "rejected"
+				level.Warn(cortex_util.Logger).Log("msg", "async push rejected by the ingesters", "tenant", p.userID, "err", err)
+			}
+			metricAsyncPushes.WithLabelValues(p.userID, result).Inc()
+			q.delivered(p.segment)
+			return
+		}
+
+		select {
+		case <-time.After(backoff):
+		case <-q.quit:
+			return
+		}
+		backoff *= 2
+		if backoff > time.Minute {
+			backoff = time.Minute
+		}
+	}
+}
+
+func (q *asyncPushQueue) delivered(s *asyncSegment) {
+	q.mtx.Lock()
+	defer q.mtx.Unlock()
+
+	s.pending--
+	q.removeIfDelivered(s)
+}
+
+// retryableDelivery is false for errors the ingesters would return again, such as exceeded limits.
+func retryableDelivery(err error) bool {
+	if _, ok := ingester_client.LimitErrorDetails(err); ok {
+		return false
+	}
+	return status.Code(err) != codes.InvalidArgument
+}
+
+// stop stops delivering pushes and closes the current segment.  Pushes not delivered yet stay in their segments.
+func (q *asyncPushQueue) stop() {
+	q.mtx.Lock()
+	if q.stopped {
+		q.mtx.Unlock()
+		return
+	}
+	q.stopped = true
+	q.mtx.Unlock()
+
+	close(q.quit)
+	q.wg.Wait()
+
+	q.mtx.Lock()
+	defer q.mtx.Unlock()
+	if q.current != nil {
+		if err := q.closeCurrent(); err != nil {
+			level.Error(cortex_util.Logger).Log("msg", "failed to close async ack segment", "err", err)
+		}
+	}
+	metricAsyncQueueLength.Sub(float64(len(q.queue)))
+}
+
+// Records are a length and crc32 of the payload followed by the payload, the length prefixed tenant id and the
+// marshalled batch.
+const asyncRecordHeaderLength = 8
+
+var errCorruptAsyncRecord = errors.New("corrupt async ack record")
+
+func marshalAsyncRecord(userID string, batch *opentelemetry_proto_trace_v1.ResourceSpans) ([]byte, error) {
+	payload := make([]byte, binary.MaxVarintLen64+len(userID)+batch.Size())
+	n := binary.PutUvarint(payload, uint64(len(userID)))
+	n += copy(payload[n:], userID)
+	size, err := batch.MarshalTo(payload[n:])
+	if err != nil {
+		return nil, err
+	}
+	payload = payload[:n+size]
+
+	record := make([]byte, asyncRecordHeaderLength+len(payload))
+	binary.LittleEndian.PutUint32(record, uint32(len(payload)))
+	binary.LittleEndian.PutUint32(record[4:], crc32.ChecksumIEEE(payload))
+	copy(record[asyncRecordHeaderLength:], payload)
+	return record, nil
+}
+
+// readAsyncSegment returns the pushes of a segment.  On error the pushes read before it are returned.
+func readAsyncSegment(path string) ([]asyncPush, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var pushes []asyncPush
+	r := bufio.NewReader(f)
+	header := make([]byte, asyncRecordHeaderLength)
+	for {
+		if _, err := io.ReadFull(r, header); err == io.EOF {
+			return pushes, nil
+		} else if err != nil {
+			return pushes, errCorruptAsyncRecord
+		}
+
+		payload := make([]byte, binary.LittleEndian.Uint32(header))
+		if _, err := io.ReadFull(r, payload); err != nil || crc32.ChecksumIEEE(payload) != binary.LittleEndian.Uint32(header[4:]) {
+			return pushes, errCorruptAsyncRecord
+		}
+
+		p, err := unmarshalAsyncRecord(payload)
+		if err != nil {
+			return pushes, err
+		}
+		pushes = append(pushes, p)
+	}
+}
+
+func unmarshalAsyncRecord(payload []byte) (asyncPush, error) {
+	length, n := binary.Uvarint(payload)
+	if n <= 0 || length > uint64(len(payload)-n) {
+		return asyncPush{}, errCorruptAsyncRecord
+	}
+	userID := string(payload[n : n+int(length)])
+
+	batch := &opentelemetry_proto_trace_v1.ResourceSpans{}
+	if err := batch.Unmarshal(payload[n+int(length):]); err != nil {
+		return asyncPush{}, errCorruptAsyncRecord
+	}
+
+	spans := 0
+	for _, ils := range batch.InstrumentationLibrarySpans {
+		spans += len(ils.Spans)
+	}
+	return asyncPush{userID: userID, batch: batch, spans: spans}, nil
+}
@@ -0,0 +1,151 @@
+package distributor
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func segmentFiles(t *testing.T, dir string) []string {
+	files, err := filepath.Glob(filepath.Join(dir, "*"+asyncSegmentSuffix))
+	require.NoError(t, err)
+	return files
+}
+
+func (s *recordingSender) sent() int {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	return s.spans
+}
+
+func (s *recordingSender) setErr(err error) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	s.err = err
+}
+
+func TestAsyncPushQueue(t *testing.T) {
+	dir := t.TempDir()
+	sender := &recordingSender{}
+	q, err := newAsyncPushQueue(AsyncAckConfig{Path: dir, MaxSegmentBytes: 100, Workers: 2}, sender.send)
+	require.NoError(t, err)
+	q.start()
+	defer q.stop()
+
+	for i := 0; i < 10; i++ {
+		require.True(t, q.enqueue("test", spansBatch("svc", 2), 2))
+	}
+
+	// every push is delivered and its segment deleted, except the current one
+	require.Eventually(t, func() bool {
+		return sender.sent() == 20 && len(segmentFiles(t, dir)) <= 1
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestAsyncPushQueueReplay(t *testing.T) {
+	dir := t.TempDir()
+
+	// deliveries keep failing until the queue stops
+	failing := &recordingSender{err: status.Error(codes.Unavailable, "no ingesters")}
+	q, err := newAsyncPushQueue(AsyncAckConfig{Path: dir, SyncInterval: time.Hour, Backoff: time.Millisecond}, failing.send)
+	require.NoError(t, err)
+	q.start()
+	require.True(t, q.enqueue("a", spansBatch("svc", 1), 1))
+	require.True(t, q.enqueue("b", spansBatch("svc", 2), 2))
+	q.stop()
+	assert.False(t, q.enqueue("a", spansBatch("svc", 1), 1))
+	require.Len(t, segmentFiles(t, dir), 1)
+
+	// a crash cut the last record short
+	f, err := os.OpenFile(segmentFiles(t, dir)[0], os.O_APPEND|os.O_WRONLY, 0600)
+	require.NoError(t, err)
+	_, err = f.Write([]byte{0x10, 0, 0})
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	sender := &recordingSender{}
+	q, err = newAsyncPushQueue(AsyncAckConfig{Path: dir, SyncInterval: time.Hour}, sender.send)
+	require.NoError(t, err)
+	q.start()
+	defer q.stop()
+
+	require.Eventually(t, func() bool {
+		return sender.sent() == 3 && len(segmentFiles(t, dir)) == 0
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// new pushes go to a new segment
+	require.True(t, q.enqueue("a", spansBatch("svc", 1), 1))
+	require.Eventually(t, func() bool {
+		return sender.sent() == 4
+	}, 5*time.Second, 10*time.Millisecond)
+	files := segmentFiles(t, dir)
+	require.Len(t, files, 1)
+	assert.Equal(t, "0000000001"+asyncSegmentSuffix, filepath.Base(files[0]))
+}
+
+func TestAsyncPushQueueRejected(t *testing.T) {
+	dir := t.TempDir()
+	sender := &recordingSender{err: status.Error(codes.InvalidArgument, "bad batch")}
+	q, err := newAsyncPushQueue(AsyncAckConfig{Path: dir, MaxSegmentBytes: 1, Backoff: time.Millisecond}, sender.send)
+	require.NoError(t, err)
+	q.start()
+	defer q.stop()
+
+	// rejected pushes aren't retried
+	require.True(t, q.enqueue("test", spansBatch("svc", 1), 1))
+	require.True(t, q.enqueue("test", spansBatch("svc", 1), 1))
+	require.Eventually(t, func() bool {
+		return len(segmentFiles(t, dir)) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, 2, sender.sent())
+}
+
+func TestAsyncPushQueueFull(t *testing.T) {
+	dir := t.TempDir()
+	sender := &recordingSender{err: errors.New("ingester down")}
+	q, err := newAsyncPushQueue(AsyncAckConfig{Path: dir, MaxPendingPushes: 2}, sender.send)
+	require.NoError(t, err)
+
+	// not started, so nothing is delivered
+	assert.True(t, q.enqueue("test", spansBatch("svc", 1), 1))
+	assert.True(t, q.enqueue("test", spansBatch("svc", 1), 1))
+	assert.False(t, q.enqueue("test", spansBatch("svc", 1), 1))
+
+	sender.setErr(nil)
+	q.start()
+	defer q.stop()
+	require.Eventually(t, func() bool {
+		return sender.sent() == 2
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.True(t, q.enqueue("test", spansBatch("svc", 1), 1))
+}
+
+func TestAsyncRecord(t *testing.T) {
+	batch := spansBatch("svc", 3)
+	record, err := marshalAsyncRecord("tenant", batch)
+	require.NoError(t, err)
+
+	path := filepath.Join(t.TempDir(), "0000000000"+asyncSegmentSuffix)
+	require.NoError(t, ioutil.WriteFile(path, append(record, record...), 0600))
+	pushes, err := readAsyncSegment(path)
+	require.NoError(t, err)
+	require.Len(t, pushes, 2)
+	assert.Equal(t, "tenant", pushes[1].userID)
+	assert.Equal(t, 3, pushes[1].spans)
+	assert.Equal(t, batch, pushes[1].batch)
+
+	// a flipped bit fails the checksum
+	record[len(record)-1] ^= 1
+	require.NoError(t, ioutil.WriteFile(path, record, 0600))
+	pushes, err = readAsyncSegment(path)
+	assert.Equal(t, errCorruptAsyncRecord, err)
+	assert.Empty(t, pushes)
+}
@@ -0,0 +1,59 @@
+package distributor
+
+import (
+	"sort"
+
+	opentelemetry_proto_common_v1 "github.com/open-telemetry/opentelemetry-proto/gen/go/common/v1"
+	opentelemetry_proto_resource_v1 "github.com/open-telemetry/opentelemetry-proto/gen/go/resource/v1"
+	opentelemetry_proto_trace_v1 "github.com/open-telemetry/opentelemetry-proto/gen/go/trace/v1"
+
+	"github.com/grafana/tempo/modules/distributor/receiver"
+	"github.com/grafana/tempo/modules/overrides"
+)
+
+// applyAttributeRules adds the attributes set by every rule the batch matches to its resource.  Attributes the batch
+// already has are kept.  It returns the number of attributes added.
+func applyAttributeRules(batch *opentelemetry_proto_trace_v1.ResourceSpans, rules []overrides.AttributeRule) int {
+	if batch.Resource == nil {
+		batch.Resource = &opentelemetry_proto_resource_v1.Resource{}
+	}
+
+	values := make(map[string]string, len(batch.Resource.Attributes))
+	for _, attr := range batch.Resource.Attributes {
+		if attr.Value != nil {
+			values[attr.Key] = attr.Value.GetStringValue()
+		} else {
+			values[attr.Key] = ""
+		}
+	}
+
+	added := 0
+	for i := range rules {
+		rule := &rules[i]
+		value, found := values[rule.Attribute]
+		if !rule.MatchesAttribute(value, found) || !rule.MatchesClientIP(values[receiver.AttributeClientIP]) {
+			continue
+		}
+
+		// keys are sorted so batches get their attributes in the same order
+		keys := make([]string, 0, len(rule.Set))
+		for k := range rule.Set {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		for _, k := range keys {
+			if _, ok := values[k]; ok {
+				continue
+			}
+			values[k] = rule.Set[k]
+			batch.Resource.Attributes = append(batch.Resource.Attributes, &opentelemetry_proto_common_v1.KeyValue{
+				Key:   k,
+				Value: &opentelemetry_proto_common_v1.AnyValue{Value: &opentelemetry_proto_common_v1.AnyValue_StringValue{StringValue: rule.Set[k]}},
+			})
+			added++
+		}
+	}
+
+	return added
+}
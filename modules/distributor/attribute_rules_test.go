@@ -0,0 +1,99 @@
+package distributor
+
+import (
+	"testing"
+
+	v1_common "github.com/open-telemetry/opentelemetry-proto/gen/go/common/v1"
+	v1_resource "github.com/open-telemetry/opentelemetry-proto/gen/go/resource/v1"
+	v1 "github.com/open-telemetry/opentelemetry-proto/gen/go/trace/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+
+	"github.com/grafana/tempo/modules/distributor/receiver"
+	"github.com/grafana/tempo/modules/overrides"
+)
+
+const testAttributeRules = `
+- client_cidrs: [10.1.0.0/16, 10.2.0.0/16]
+  set:
+    region: eu-west
+- attribute: k8s.namespace.name
+  value: shop-staging
+  set:
+    environment: staging
+- attribute: k8s.namespace.name
+  set:
+    environment: production
+    region: unknown
+`
+
+func resourceBatch(kvs ...string) *v1.ResourceSpans {
+	resource := &v1_resource.Resource{}
+	for i := 0; i < len(kvs); i += 2 {
+		resource.Attributes = append(resource.Attributes, &v1_common.KeyValue{
+			Key:   kvs[i],
+			Value: &v1_common.AnyValue{Value: &v1_common.AnyValue_StringValue{StringValue: kvs[i+1]}},
+		})
+	}
+	return &v1.ResourceSpans{Resource: resource}
+}
+
+func resourceAttributes(batch *v1.ResourceSpans) map[string]string {
+	m := map[string]string{}
+	for _, attr := range batch.Resource.Attributes {
+		m[attr.Key] = attr.Value.GetStringValue()
+	}
+	return m
+}
+
+func TestApplyAttributeRules(t *testing.T) {
+	var rules []overrides.AttributeRule
+	require.NoError(t, yaml.UnmarshalStrict([]byte(testAttributeRules), &rules))
+
+	tests := []struct {
+		name     string
+		batch    *v1.ResourceSpans
+		expected map[string]string
+		added    int
+	}{
+		{
+			name:     "client ip",
+			batch:    resourceBatch(receiver.AttributeClientIP, "10.2.3.4", "k8s.namespace.name", "shop-staging"),
+			expected: map[string]string{receiver.AttributeClientIP: "10.2.3.4", "k8s.namespace.name": "shop-staging", "region": "eu-west", "environment": "staging"},
+			added:    2,
+		},
+		{
+			name:     "first rule wins",
+			batch:    resourceBatch("k8s.namespace.name", "shop"),
+			expected: map[string]string{"k8s.namespace.name": "shop", "environment": "production", "region": "unknown"},
+			added:    2,
+		},
+		{
+			name:     "existing attributes are kept",
+			batch:    resourceBatch(receiver.AttributeClientIP, "192.168.0.1", "k8s.namespace.name", "shop", "region", "us-east"),
+			expected: map[string]string{receiver.AttributeClientIP: "192.168.0.1", "k8s.namespace.name": "shop", "region": "us-east", "environment": "production"},
+			added:    1,
+		},
+		{
+			name:     "no match",
+			batch:    &v1.ResourceSpans{},
+			expected: map[string]string{},
+			added:    0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.added, applyAttributeRules(tt.batch, rules))
+			assert.Equal(t, tt.expected, resourceAttributes(tt.batch))
+		})
+	}
+}
+
+func TestAttributeRulesValidated(t *testing.T) {
+	var rules []overrides.AttributeRule
+	assert.Error(t, yaml.UnmarshalStrict([]byte(`[{client_cidrs: [10.0.0.0/33], set: {region: eu}}]`), &rules))
+	assert.Error(t, yaml.UnmarshalStrict([]byte(`[{set: {region: eu}}]`), &rules))
+	assert.Error(t, yaml.UnmarshalStrict([]byte(`[{attribute: k8s.namespace.name}]`), &rules))
+}
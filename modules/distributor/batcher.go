@@ -0,0 +1,247 @@
+package distributor
+
+import (
+	"context"
+	"flag"
+	"sync"
+	"time"
+
+	"github.com/gogo/status"
+	opentelemetry_proto_trace_v1 "github.com/open-telemetry/opentelemetry-proto/gen/go/trace/v1"
+	"github.com/opentracing/opentracing-go"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/weaveworks/common/user"
+	"google.golang.org/grpc/codes"
+
+	"github.com/grafana/tempo/pkg/util"
+	"github.com/grafana/tempo/pkg/validation"
+)
+
+var (
+	metricPushesPerBatch = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: "tempo",
+		Name:      "distributor_pushes_per_batch",
+		Help:      "The number of small pushes coalesced into each batch sent to the ingesters.",
+		Buckets:   prometheus.ExponentialBuckets(1, 2, 10),
+	})
+)
+
+// PushBatchingConfig controls coalescing of small pushes.  Chatty clients often export every few spans, and each push
+// otherwise costs at least one ingester request per trace.
+type PushBatchingConfig struct {
+	Window        time.Duration `yaml:"window"`          // how long small pushes are held.  0 disables batching
+	MaxPushSpans  int           `yaml:"max_push_spans"`  // pushes with more spans are sent immediately
+	MaxBatchSpans int           `yaml:"max_batch_spans"` // a batch is sent early once it holds this many spans
+}
+
+// RegisterFlags registers the flags.
+func (cfg *PushBatchingConfig) RegisterFlags(prefix string, f *flag.FlagSet) {
+	f.DurationVar(&cfg.Window, util.PrefixConfig(prefix, "window"), 0, "How long pushes with few spans are held to be sent to the ingesters together with other pushes of the tenant. 0 disables batching.")
+	f.IntVar(&cfg.MaxPushSpans, util.PrefixConfig(prefix, "max-push-spans"), 10, "Pushes with more spans than this are sent immediately.")
+	f.IntVar(&cfg.MaxBatchSpans, util.PrefixConfig(prefix, "max-batch-spans"), 1000, "Number of spans at which a batch is sent before the window has passed.")
+}
+
+type sendFunc func(userID string, batches []*opentelemetry_proto_trace_v1.ResourceSpans, spanCount int) error
+
+// batchSendFunc sends the batches of coalesced pushes and returns the errors of the traces rejected by an ingester limit
+// by trace id.  err is set if sending failed.
+type batchSendFunc func(ctx context.Context, userID string, batches []*opentelemetry_proto_trace_v1.ResourceSpans, spanCount int) (rejected map[string]error, err error)
+
+// pushBatcher coalesces the pushes of each tenant for up to a window.  Every push waits for its batch to be sent and
+// returns the result, so clients still see ingester errors and are slowed down by the ingesters.  A trace rejected by
+// an ingester limit only fails the push it came from, so one client's trace doesn't fail, and have retried, the
+// pushes of the others.
+type pushBatcher struct {
+	cfg  PushBatchingConfig
+	send batchSendFunc
+
+	mtx     sync.Mutex
+	pending map[string]*pendingBatch
+}
+
+type pendingBatch struct {
+	pushes []*batchedPush
+	spans  int
+	done   chan struct{}
+	err    error
+}
+
+type batchedPush struct {
+	ctx   context.Context
+	batch *opentelemetry_proto_trace_v1.ResourceSpans
+	err   error // the error of a rejected trace of the push
+}
+
+func newPushBatcher(cfg PushBatchingConfig, send batchSendFunc) *pushBatcher {
+	return &pushBatcher{
+		cfg:     cfg,
+		send:    send,
+		pending: map[string]*pendingBatch{},
+	}
+}
+
+// push adds batch to the pending batch of the tenant and blocks until it has been sent.
+func (b *pushBatcher) push(ctx context.Context, userID string, batch *opentelemetry_proto_trace_v1.ResourceSpans, spanCount int) error {
+	pushed := &batchedPush{ctx: ctx, batch: batch}
+
+	b.mtx.Lock()
+	p, ok := b.pending[userID]
+	if !ok {
+		p = &pendingBatch{done: make(chan struct{})}
+		b.pending[userID] = p
+		time.AfterFunc(b.cfg.Window, func() { b.flush(userID, p) })
+	}
+	p.pushes = append(p.pushes, pushed)
+	p.spans += spanCount
+	full := p.spans >= b.cfg.MaxBatchSpans
+	b.mtx.Unlock()
+
+	if full {
+		b.flush(userID, p)
+	}
+
+	<-p.done
+	if p.err != nil {
+		return p.err
+	}
+	return pushed.err
+}
+
+// flush sends p if it is still pending.
+func (b *pushBatcher) flush(userID string, p *pendingBatch) {
+	b.mtx.Lock()
+	if b.pending[userID] != p {
+		b.mtx.Unlock()
+		return
+	}
+	delete(b.pending, userID)
+	b.mtx.Unlock()
+
+	batches := make([]*opentelemetry_proto_trace_v1.ResourceSpans, 0, len(p.pushes))
+	ctxs := make([]context.Context, 0, len(p.pushes))
+	for _, pushed := range p.pushes {
+		batches = append(batches, pushed.batch)
+		ctxs = append(ctxs, pushed.ctx)
+	}
+
+	metricPushesPerBatch.Observe(float64(len(p.pushes)))
+	ctx, done := sendContext(userID, ctxs)
+	rejected, err := b.send(ctx, userID, mergeBatches(batches), p.spans)
+	done()
+
+	p.err = err
+	if len(rejected) > 0 {
+		for _, pushed := range p.pushes {
+			pushed.err = rejectedTrace(pushed.batch, rejected)
+		}
+	}
+	close(p.done)
+}
+
+// stop sends all pending batches.
+func (b *pushBatcher) stop() {
+	b.mtx.Lock()
+	pending := make(map[string]*pendingBatch, len(b.pending))
+	for userID, p := range b.pending {
+		pending[userID] = p
+	}
+	b.mtx.Unlock()
+
+	for userID, p := range pending {
+		b.flush(userID, p)
+	}
+}
+
+// sendContext returns the context for sending the pushes of a batch together and a func to call once it's sent.  Its
+// span is a child of the span of the first push and follows from the others.  It is cancelled once the contexts of
+// all pushes are, so a client going away or timing out doesn't fail the pushes of the others.
+func sendContext(userID string, ctxs []context.Context) (context.Context, func()) {
+	ctx, cancel := context.WithCancel(user.InjectOrgID(context.Background(), userID))
+
+	var refs []opentracing.StartSpanOption
+	for _, pushCtx := range ctxs {
+		parent := opentracing.SpanFromContext(pushCtx)
+		switch {
+		case parent == nil:
+		case len(refs) == 0:
+			refs = append(refs, opentracing.ChildOf(parent.Context()))
+		default:
+			refs = append(refs, opentracing.FollowsFrom(parent.Context()))
+		}
+	}
+	span := opentracing.StartSpan("pushBatcher.send", refs...)
+	span.SetTag("pushes", len(ctxs))
+	ctx = opentracing.ContextWithSpan(ctx, span)
+
+	go func() {
+		for _, pushCtx := range ctxs {
+			select {
+			case <-pushCtx.Done():
+			case <-ctx.Done():
+				return
+			}
+		}
+		cancel()
+	}()
+
+	return ctx, func() {
+		cancel()
+		span.Finish()
+	}
+}
+
+// rejectedTrace returns the error of the first trace of the batch that was rejected, or nil if none was.
+func rejectedTrace(batch *opentelemetry_proto_trace_v1.ResourceSpans, rejected map[string]error) error {
+	for _, ils := range batch.InstrumentationLibrarySpans {
+		for _, span := range ils.Spans {
+			if err, ok := rejected[string(span.TraceId)]; ok {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// mergeBatches combines batches with the same resource so spans of a trace pushed separately are sent to the
+// ingesters in one request.
+func mergeBatches(batches []*opentelemetry_proto_trace_v1.ResourceSpans) []*opentelemetry_proto_trace_v1.ResourceSpans {
+	merged := make([]*opentelemetry_proto_trace_v1.ResourceSpans, 0, len(batches))
+	byResource := make(map[string]*opentelemetry_proto_trace_v1.ResourceSpans, len(batches))
+
+	for _, batch := range batches {
+		var key string
+		if batch.Resource != nil {
+			b, err := batch.Resource.Marshal()
+			if err != nil {
+				merged = append(merged, batch)
+				continue
+			}
+			key = string(b)
+		}
+
+		existing, ok := byResource[key]
+		if !ok {
+			existing = &opentelemetry_proto_trace_v1.ResourceSpans{
+				Resource: batch.Resource,
+			}
+			byResource[key] = existing
+			merged = append(merged, existing)
+		}
+		existing.InstrumentationLibrarySpans = append(existing.InstrumentationLibrarySpans, batch.InstrumentationLibrarySpans...)
+	}
+
+	return merged
+}
+
+// validateTraceIDs rejects a push before it is batched so one bad push can't fail the pushes batched with it.
+func validateTraceIDs(batch *opentelemetry_proto_trace_v1.ResourceSpans) error {
+	for _, ils := range batch.InstrumentationLibrarySpans {
+		for _, span := range ils.Spans {
+			if !validation.ValidTraceID(span.TraceId) {
+				return status.Errorf(codes.InvalidArgument, "trace ids must be 128 bit")
+			}
+		}
+	}
+	return nil
+}
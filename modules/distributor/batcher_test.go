@@ -0,0 +1,183 @@
+package distributor
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	v1_common "github.com/open-telemetry/opentelemetry-proto/gen/go/common/v1"
+	v1_resource "github.com/open-telemetry/opentelemetry-proto/gen/go/resource/v1"
+	v1 "github.com/open-telemetry/opentelemetry-proto/gen/go/trace/v1"
+	"github.com/opentracing/opentracing-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/weaveworks/common/user"
+)
+
+type recordingSender struct {
+	mtx     sync.Mutex
+	sends   int
+	batches []*v1.ResourceSpans
+	spans   int
+	err     error
+}
+
+func (s *recordingSender) send(_ string, batches []*v1.ResourceSpans, spanCount int) error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	s.sends++
+	s.batches = append(s.batches, batches...)
+	s.spans += spanCount
+	return s.err
+}
+
+func (s *recordingSender) sendBatch(_ context.Context, userID string, batches []*v1.ResourceSpans, spanCount int) (map[string]error, error) {
+	return nil, s.send(userID, batches, spanCount)
+}
+
+func spansBatch(service string, n int) *v1.ResourceSpans {
+	spans := make([]*v1.Span, 0, n)
+	for i := 0; i < n; i++ {
+		spans = append(spans, &v1.Span{TraceId: make([]byte, 16)})
+	}
+	return &v1.ResourceSpans{
+		Resource: &v1_resource.Resource{
+			Attributes: []*v1_common.KeyValue{
+				{Key: "service.name", Value: &v1_common.AnyValue{Value: &v1_common.AnyValue_StringValue{StringValue: service}}},
+			},
+		},
+		InstrumentationLibrarySpans: []*v1.InstrumentationLibrarySpans{{Spans: spans}},
+	}
+}
+
+func TestPushBatcherCoalesces(t *testing.T) {
+	sender := &recordingSender{}
+	b := newPushBatcher(PushBatchingConfig{Window: 50 * time.Millisecond, MaxBatchSpans: 1000}, sender.sendBatch)
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, b.push(context.Background(), "test", spansBatch("svc", 2), 2))
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, 1, sender.sends)
+	assert.Equal(t, 10, sender.spans)
+	require.Len(t, sender.batches, 1)
+	assert.Len(t, sender.batches[0].InstrumentationLibrarySpans, 5)
+}
+
+func TestPushBatcherFullBatch(t *testing.T) {
+	sender := &recordingSender{err: errors.New("ingester down")}
+	b := newPushBatcher(PushBatchingConfig{Window: time.Hour, MaxBatchSpans: 3}, sender.sendBatch)
+
+	// sent without waiting for the window and every pusher sees the error
+	errs := make(chan error, 2)
+	go func() { errs <- b.push(context.Background(), "test", spansBatch("svc", 1), 1) }()
+	go func() { errs <- b.push(context.Background(), "test", spansBatch("svc", 2), 2) }()
+
+	for i := 0; i < 2; i++ {
+		select {
+		case err := <-errs:
+			assert.EqualError(t, err, "ingester down")
+		case <-time.After(5 * time.Second):
+			t.Fatal("full batch was not sent")
+		}
+	}
+	assert.Equal(t, 1, sender.sends)
+}
+
+func TestPushBatcherStop(t *testing.T) {
+	sender := &recordingSender{}
+	b := newPushBatcher(PushBatchingConfig{Window: time.Hour, MaxBatchSpans: 1000}, sender.sendBatch)
+
+	done := make(chan error)
+	go func() { done <- b.push(context.Background(), "test", spansBatch("svc", 1), 1) }()
+
+	// wait for the push to be pending
+	for {
+		b.mtx.Lock()
+		pending := len(b.pending)
+		b.mtx.Unlock()
+		if pending == 1 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	b.stop()
+	assert.NoError(t, <-done)
+	assert.Equal(t, 1, sender.sends)
+}
+
+func TestPushBatcherRejectsOnlyPushOfTrace(t *testing.T) {
+	rejectedErr := errors.New("trace exceeded max spans per trace")
+	b := newPushBatcher(PushBatchingConfig{Window: time.Hour, MaxBatchSpans: 3}, func(_ context.Context, _ string, batches []*v1.ResourceSpans, _ int) (map[string]error, error) {
+		return map[string]error{string([]byte{0x02}): rejectedErr}, nil
+	})
+
+	batch := func(traceID byte, n int) *v1.ResourceSpans {
+		batch := spansBatch("svc", n)
+		for _, span := range batch.InstrumentationLibrarySpans[0].Spans {
+			span.TraceId = []byte{traceID}
+		}
+		return batch
+	}
+
+	accepted := make(chan error)
+	rejected := make(chan error)
+	go func() { accepted <- b.push(context.Background(), "test", batch(0x01, 1), 1) }()
+	go func() { rejected <- b.push(context.Background(), "test", batch(0x02, 2), 2) }()
+
+	assert.NoError(t, <-accepted)
+	assert.Equal(t, rejectedErr, <-rejected)
+}
+
+func TestSendContext(t *testing.T) {
+	first, cancelFirst := context.WithCancel(context.Background())
+	second, cancelSecond := context.WithCancel(context.Background())
+	_, second = opentracing.StartSpanFromContext(second, "push")
+
+	ctx, done := sendContext("test", []context.Context{first, second})
+	defer done()
+
+	userID, err := user.ExtractOrgID(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "test", userID)
+	assert.NotNil(t, opentracing.SpanFromContext(ctx))
+
+	// the send carries on while any push waits for it
+	cancelFirst()
+	select {
+	case <-ctx.Done():
+		t.Fatal("send cancelled while a push waits for it")
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	cancelSecond()
+	select {
+	case <-ctx.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("send not cancelled once every push was")
+	}
+}
+
+func TestMergeBatches(t *testing.T) {
+	a1 := spansBatch("a", 1)
+	b1 := spansBatch("b", 2)
+	a2 := spansBatch("a", 3)
+	noResource := &v1.ResourceSpans{InstrumentationLibrarySpans: []*v1.InstrumentationLibrarySpans{{}}}
+
+	merged := mergeBatches([]*v1.ResourceSpans{a1, b1, a2, noResource})
+	require.Len(t, merged, 3)
+
+	assert.Equal(t, a1.Resource, merged[0].Resource)
+	assert.Equal(t, append(a1.InstrumentationLibrarySpans, a2.InstrumentationLibrarySpans...), merged[0].InstrumentationLibrarySpans)
+	assert.Equal(t, b1.InstrumentationLibrarySpans, merged[1].InstrumentationLibrarySpans)
+	assert.Nil(t, merged[2].Resource)
+}
@@ -8,6 +8,12 @@ import (
 	"github.com/cortexproject/cortex/pkg/ring"
 	ring_client "github.com/cortexproject/cortex/pkg/ring/client"
 	"github.com/cortexproject/cortex/pkg/util/flagext"
+
+	"github.com/grafana/tempo/modules/distributor/enrichment"
+	"github.com/grafana/tempo/modules/distributor/receiver"
+	"github.com/grafana/tempo/pkg/encryption"
+	"github.com/grafana/tempo/pkg/util"
+	"github.com/grafana/tempo/pkg/watermark"
 )
 
 var defaultReceivers = map[string]interface{}{
@@ -20,6 +26,7 @@ var defaultReceivers = map[string]interface{}{
 	"otlp": map[string]interface{}{
 		"protocols": map[string]interface{}{
 			"grpc": nil,
+			"http": nil,
 		},
 	},
 }
@@ -31,8 +38,32 @@ type Config struct {
 	// receivers map for shim.
 	//  This receivers node is equivalent in format to the receiver node in the
 	//  otel collector: https://github.com/open-telemetry/opentelemetry-collector/tree/master/receiver
-	Receivers       map[string]interface{} `yaml:"receivers"`
-	OverrideRingKey string                 `yaml:"override_ring_key"`
+	Receivers            map[string]interface{}       `yaml:"receivers"`
+	ReceiverMetadata     receiver.MetadataConfig      `yaml:"receiver_metadata"`
+	ReceiverBackpressure receiver.BackpressureConfig  `yaml:"receiver_backpressure"`
+	TenantRouting        receiver.TenantRoutingConfig `yaml:"tenant_routing"`
+	OverrideRingKey      string                       `yaml:"override_ring_key"`
+
+	AttributeEncryption encryption.Config  `yaml:"attribute_encryption"`
+	PushBatching        PushBatchingConfig `yaml:"push_batching"`
+	MemoryWatermarks    watermark.Config   `yaml:"memory_watermarks"`
+	K8sEnrichment       enrichment.Config  `yaml:"k8s_enrichment"`
+	Idempotency         IdempotencyConfig  `yaml:"idempotency"`
+	Sampling            SamplingConfig     `yaml:"sampling"`
+	AsyncAck            AsyncAckConfig     `yaml:"async_ack"`
+
+	LogReceivedSpans LogReceivedSpansConfig `yaml:"log_received_spans"`
+
+	IngesterLoadBalancing IngesterLoadBalancingConfig `yaml:"ingester_load_balancing"`
+	// IngesterOverloadRetries is the number of other ingesters a push rejected by an overloaded ingester is retried on.
+	IngesterOverloadRetries int `yaml:"ingester_overload_retries"`
+
+	// MetricsGeneratorEnabled sends the accepted spans of tenants with metrics generator processors to the metrics
+	// generators.
+	MetricsGeneratorEnabled bool `yaml:"metrics_generator_enabled"`
+
+	// Downstream OTLP endpoints spans of tenants with the forwarders override are also sent to.  Yaml only.
+	Forwarders []ForwarderConfig `yaml:"forwarders"`
 
 	// For testing.
 	factory func(addr string) (ring_client.PoolClient, error) `yaml:"-"`
@@ -42,7 +73,25 @@ type Config struct {
 func (cfg *Config) RegisterFlagsAndApplyDefaults(prefix string, f *flag.FlagSet) {
 	flagext.DefaultValues(&cfg.DistributorRing)
 	cfg.DistributorRing.KVStore.Store = "memberlist"
-	cfg.DistributorRing.HeartbeatTimeout = 5 * time.Minute
+	f.DurationVar(&cfg.DistributorRing.HeartbeatPeriod, util.PrefixConfig(prefix, "ring.heartbeat-period"), cfg.DistributorRing.HeartbeatPeriod, "Period at which to heartbeat to the ring.")
+	f.DurationVar(&cfg.DistributorRing.HeartbeatTimeout, util.PrefixConfig(prefix, "ring.heartbeat-timeout"), 5*time.Minute, "The heartbeat timeout after which distributors are considered unhealthy within the ring.")
 
 	cfg.OverrideRingKey = ring.DistributorRingKey
+
+	f.BoolVar(&cfg.ReceiverMetadata.ResourceAttributes, util.PrefixConfig(prefix, "receiver-metadata.resource-attributes"), false, "Add the receiver, transport, client ip and tenant source to the resource attributes of received batches.")
+	f.BoolVar(&cfg.ReceiverMetadata.TrustForwardedFor, util.PrefixConfig(prefix, "receiver-metadata.trust-forwarded-for"), false, "Take the client ip from the X-Forwarded-For header of gRPC receivers when present.")
+	f.IntVar(&cfg.ReceiverBackpressure.MaxInflightSpans, util.PrefixConfig(prefix, "receiver-backpressure.max-inflight-spans"), 0, "Most spans the receivers hold while pushing them. Requests beyond it are refused with a retryable error. 0 disables the limit.")
+	f.StringVar(&cfg.TenantRouting.DefaultTenant, util.PrefixConfig(prefix, "tenant-routing.default-tenant"), "", "Tenant for batches that match no tenant routing rule.  If empty they keep the tenant of the request.")
+
+	cfg.AttributeEncryption.RegisterFlags(util.PrefixConfig(prefix, "attribute-encryption"), f)
+	cfg.PushBatching.RegisterFlags(util.PrefixConfig(prefix, "push-batching"), f)
+	cfg.MemoryWatermarks.RegisterFlags(util.PrefixConfig(prefix, "memory-watermarks"), f)
+	cfg.K8sEnrichment.RegisterFlags(util.PrefixConfig(prefix, "k8s-enrichment"), f)
+	cfg.Idempotency.RegisterFlags(util.PrefixConfig(prefix, "idempotency"), f)
+	cfg.Sampling.RegisterFlags(util.PrefixConfig(prefix, "sampling"), f)
+	cfg.AsyncAck.RegisterFlags(util.PrefixConfig(prefix, "async-ack"), f)
+	cfg.LogReceivedSpans.RegisterFlags(util.PrefixConfig(prefix, "log-received-spans"), f)
+	cfg.IngesterLoadBalancing.RegisterFlags(util.PrefixConfig(prefix, "ingester-load-balancing"), f)
+	f.IntVar(&cfg.IngesterOverloadRetries, util.PrefixConfig(prefix, "ingester-overload-retries"), 2, "Number of ingesters outside a trace's replicas a push rejected by an overloaded ingester is retried on. 0 disables retries.")
+	f.BoolVar(&cfg.MetricsGeneratorEnabled, util.PrefixConfig(prefix, "metrics-generator-enabled"), false, "Send accepted spans of tenants with metrics generator processors to the metrics generators.")
 }
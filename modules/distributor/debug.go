@@ -0,0 +1,216 @@
+package distributor
+
+import (
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	cortex_util "github.com/cortexproject/cortex/pkg/util"
+	"github.com/go-kit/kit/log/level"
+	opentelemetry_proto_trace_v1 "github.com/open-telemetry/opentelemetry-proto/gen/go/trace/v1"
+
+	"github.com/grafana/tempo/pkg/util"
+)
+
+const (
+	serviceNameAttribute = "service.name"
+
+	defaultFirehoseDuration = time.Minute
+	maxFirehoseDuration     = 5 * time.Minute
+	firehoseBufferSize      = 1000
+)
+
+// LogReceivedSpansConfig logs every span received by the distributor so users can check their instrumentation reaches
+// Tempo.  Spans are logged before sampling and limits are applied.
+type LogReceivedSpansConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// Tenants restricts logging to the listed tenants.  Empty logs every tenant.  Yaml only.
+	Tenants []string `yaml:"tenants"`
+	// SamplingRatio is the fraction of traces logged, chosen by trace id so all spans of a logged trace are logged.
+	SamplingRatio float64 `yaml:"sampling_ratio"`
+}
+
+// RegisterFlags registers the flags.
+func (cfg *LogReceivedSpansConfig) RegisterFlags(prefix string, f *flag.FlagSet) {
+	f.BoolVar(&cfg.Enabled, util.PrefixConfig(prefix, "enabled"), false, "Log the trace id, span id, name and service of every received span.")
+	f.Float64Var(&cfg.SamplingRatio, util.PrefixConfig(prefix, "sampling-ratio"), 1, "Fraction of traces whose spans are logged, chosen by trace id.")
+}
+
+func (cfg *LogReceivedSpansConfig) logsTenant(userID string) bool {
+	if !cfg.Enabled {
+		return false
+	}
+	if len(cfg.Tenants) == 0 {
+		return true
+	}
+	for _, t := range cfg.Tenants {
+		if t == userID {
+			return true
+		}
+	}
+	return false
+}
+
+// logReceivedSpans logs the spans of the batch if the tenant's spans are logged.
+func (d *Distributor) logReceivedSpans(userID string, batch *opentelemetry_proto_trace_v1.ResourceSpans) {
+	cfg := &d.cfg.LogReceivedSpans
+	if !cfg.logsTenant(userID) {
+		return
+	}
+
+	service := ""
+	if batch.Resource != nil {
+		for _, attr := range batch.Resource.Attributes {
+			if attr.Key == serviceNameAttribute {
+				service = attr.Value.GetStringValue()
+			}
+		}
+	}
+
+	for _, ils := range batch.InstrumentationLibrarySpans {
+		for _, span := range ils.Spans {
+			if cfg.SamplingRatio < 1 && !traceSampled(span.TraceId, cfg.SamplingRatio) {
+				continue
+			}
+			level.Info(cortex_util.Logger).Log(
+				"msg", "received span",
+				"tenant", userID,
+				"traceID", hex.EncodeToString(span.TraceId),
+				"spanID", hex.EncodeToString(span.SpanId),
+				"name", span.Name,
+				"service", service,
+				"duration", time.Duration(span.EndTimeUnixNano-span.StartTimeUnixNano),
+			)
+		}
+	}
+}
+
+// firehose fans the ids of accepted traces out to the clients of FirehoseHandler.  Nothing is done while there are no
+// clients.
+type firehose struct {
+	clients int32
+
+	mtx         sync.Mutex
+	subscribers map[*firehoseSubscriber]struct{}
+}
+
+type firehoseSubscriber struct {
+	tenant  string // empty receives every tenant
+	lines   chan string
+	dropped int
+}
+
+func newFirehose() *firehose {
+	return &firehose{
+		subscribers: map[*firehoseSubscriber]struct{}{},
+	}
+}
+
+func (f *firehose) subscribe(tenant string) *firehoseSubscriber {
+	s := &firehoseSubscriber{
+		tenant: tenant,
+		lines:  make(chan string, firehoseBufferSize),
+	}
+
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+	f.subscribers[s] = struct{}{}
+	atomic.AddInt32(&f.clients, 1)
+	return s
+}
+
+// unsubscribe removes the subscriber and returns the number of lines dropped because it didn't keep up.
+func (f *firehose) unsubscribe(s *firehoseSubscriber) int {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+	delete(f.subscribers, s)
+	atomic.AddInt32(&f.clients, -1)
+	return s.dropped
+}
+
+// publish sends a line with the tenant and id of each distinct trace in the batch to the subscribers of the tenant.
+// Lines are dropped for subscribers whose buffer is full so a slow client never holds up a push.
+func (f *firehose) publish(userID string, batch *opentelemetry_proto_trace_v1.ResourceSpans) {
+	if atomic.LoadInt32(&f.clients) == 0 {
+		return
+	}
+
+	var lines []string
+	seen := map[string]struct{}{}
+	for _, ils := range batch.InstrumentationLibrarySpans {
+		for _, span := range ils.Spans {
+			id := hex.EncodeToString(span.TraceId)
+			if _, ok := seen[id]; ok {
+				continue
+			}
+			seen[id] = struct{}{}
+			lines = append(lines, userID+" "+id+"\n")
+		}
+	}
+
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+	for s := range f.subscribers {
+		if s.tenant != "" && s.tenant != userID {
+			continue
+		}
+		for _, line := range lines {
+			select {
+			case s.lines <- line:
+			default:
+				s.dropped++
+			}
+		}
+	}
+}
+
+// FirehoseHandler streams the ids of the traces accepted by this distributor as lines of "<tenant> <trace id>" for
+// the duration query parameter, 1m by default and at most 5m.  The tenant query parameter restricts the stream to
+// one tenant.  A trace pushed in several batches is listed once per batch.
+func (d *Distributor) FirehoseHandler(w http.ResponseWriter, r *http.Request) {
+	duration := defaultFirehoseDuration
+	if s := r.URL.Query().Get("duration"); s != "" {
+		var err error
+		duration, err = time.ParseDuration(s)
+		if err != nil || duration <= 0 || duration > maxFirehoseDuration {
+			http.Error(w, fmt.Sprintf("invalid duration %q, must be positive and at most %s", s, maxFirehoseDuration), http.StatusBadRequest)
+			return
+		}
+	}
+
+	s := d.firehose.subscribe(r.URL.Query().Get("tenant"))
+	defer func() {
+		if dropped := d.firehose.unsubscribe(s); dropped > 0 {
+			fmt.Fprintf(w, "# dropped %d trace ids\n", dropped)
+		}
+	}()
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	flusher, _ := w.(http.Flusher)
+	if flusher != nil {
+		flusher.Flush()
+	}
+
+	timer := time.NewTimer(duration)
+	defer timer.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-timer.C:
+			return
+		case line := <-s.lines:
+			if _, err := w.Write([]byte(line)); err != nil {
+				return
+			}
+			if flusher != nil {
+				flusher.Flush()
+			}
+		}
+	}
+}
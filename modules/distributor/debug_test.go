@@ -0,0 +1,88 @@
+package distributor
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	v1 "github.com/open-telemetry/opentelemetry-proto/gen/go/trace/v1"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLogReceivedSpansTenants(t *testing.T) {
+	cfg := LogReceivedSpansConfig{}
+	assert.False(t, cfg.logsTenant("a"))
+
+	cfg.Enabled = true
+	assert.True(t, cfg.logsTenant("a"))
+
+	cfg.Tenants = []string{"b"}
+	assert.False(t, cfg.logsTenant("a"))
+	assert.True(t, cfg.logsTenant("b"))
+}
+
+func TestFirehose(t *testing.T) {
+	batch := &v1.ResourceSpans{
+		InstrumentationLibrarySpans: []*v1.InstrumentationLibrarySpans{{
+			Spans: []*v1.Span{
+				{TraceId: []byte{0x01}},
+				{TraceId: []byte{0x01}},
+				{TraceId: []byte{0x02}},
+			},
+		}},
+	}
+
+	f := newFirehose()
+	f.publish("a", batch)
+
+	all := f.subscribe("")
+	tenant := f.subscribe("b")
+	f.publish("a", batch)
+	assert.Equal(t, []string{"a 01\n", "a 02\n"}, drain(all.lines))
+	assert.Empty(t, drain(tenant.lines))
+
+	for i := 0; i < firehoseBufferSize; i++ {
+		f.publish("b", batch)
+	}
+	assert.Equal(t, firehoseBufferSize, f.unsubscribe(tenant))
+	assert.Equal(t, firehoseBufferSize, f.unsubscribe(all))
+	assert.Empty(t, f.subscribers)
+}
+
+func TestFirehoseHandler(t *testing.T) {
+	d := &Distributor{firehose: newFirehose()}
+
+	rec := httptest.NewRecorder()
+	d.FirehoseHandler(rec, httptest.NewRequest("GET", "/distributor/firehose?duration=10m", nil))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	go func() {
+		for i := 0; i < 100; i++ {
+			d.firehose.publish("a", &v1.ResourceSpans{
+				InstrumentationLibrarySpans: []*v1.InstrumentationLibrarySpans{{
+					Spans: []*v1.Span{{TraceId: []byte{0x0a}}},
+				}},
+			})
+			time.Sleep(time.Millisecond)
+		}
+	}()
+
+	rec = httptest.NewRecorder()
+	d.FirehoseHandler(rec, httptest.NewRequest("GET", "/distributor/firehose?duration=50ms&tenant=a", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.True(t, strings.HasPrefix(rec.Body.String(), "a 0a\n"), rec.Body.String())
+}
+
+func drain(lines chan string) []string {
+	var drained []string
+	for {
+		select {
+		case line := <-lines:
+			drained = append(drained, line)
+		default:
+			return drained
+		}
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"strconv"
+	"sync"
 	"time"
 
 	"github.com/cortexproject/cortex/pkg/ring"
@@ -11,6 +12,7 @@ import (
 	cortex_util "github.com/cortexproject/cortex/pkg/util"
 	"github.com/cortexproject/cortex/pkg/util/limiter"
 	"github.com/cortexproject/cortex/pkg/util/services"
+	"github.com/go-kit/kit/log/level"
 	"github.com/gogo/status"
 	opentelemetry_proto_trace_v1 "github.com/open-telemetry/opentelemetry-proto/gen/go/trace/v1"
 	"github.com/pkg/errors"
@@ -18,15 +20,21 @@ import (
 	"github.com/prometheus/client_golang/prometheus/promauto"
 	"github.com/weaveworks/common/logging"
 	"github.com/weaveworks/common/user"
+	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
 
+	"github.com/grafana/tempo/modules/distributor/enrichment"
 	"github.com/grafana/tempo/modules/distributor/receiver"
 	ingester_client "github.com/grafana/tempo/modules/ingester/client"
 	"github.com/grafana/tempo/modules/overrides"
+	"github.com/grafana/tempo/pkg/encryption"
 	"github.com/grafana/tempo/pkg/tempopb"
+	"github.com/grafana/tempo/pkg/tenantmetrics"
 	"github.com/grafana/tempo/pkg/util"
 	"github.com/grafana/tempo/pkg/validation"
+	"github.com/grafana/tempo/pkg/watermark"
 )
 
 const (
@@ -35,6 +43,11 @@ const (
 	// RateLimited is one of the values for the reason to discard samples.
 	// Declared here to avoid duplication in ingester and distributor.
 	rateLimited = "rate_limited"
+	// receiverRateLimited spans exceed the ingestion rate limit of the receiver they were received through.
+	receiverRateLimited = "receiver_rate_limited"
+	// overloaded spans are rejected above the push memory limit, or above the push memory watermark for best effort
+	// tenants.
+	overloaded = "overloaded"
 )
 
 var (
@@ -48,7 +61,13 @@ var (
 		Name:      "distributor_ingester_append_failures_total",
 		Help:      "The total number of failed batch appends sent to ingesters.",
 	}, []string{"ingester"})
-	metricSpansIngested = promauto.NewCounterVec(prometheus.CounterOpts{
+	metricIngesterPushDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "tempo",
+		Name:      "distributor_ingester_push_duration_seconds",
+		Help:      "Time spent pushing a batch to an ingester, by ingester, zone and outcome.",
+		Buckets:   prometheus.ExponentialBuckets(0.001, 4, 8),
+	}, []string{"ingester", "zone", "status"})
+	metricSpansIngested = tenantmetrics.NewCounterVec(prometheus.CounterOpts{
 		Namespace: "tempo",
 		Name:      "distributor_spans_received_total",
 		Help:      "The total number of spans received per tenant",
@@ -64,11 +83,36 @@ var (
 		Name:      "distributor_ingester_clients",
 		Help:      "The current number of ingester clients.",
 	})
-	metricDiscardedSpans = promauto.NewCounterVec(prometheus.CounterOpts{
+	metricDiscardedSpans = tenantmetrics.NewCounterVec(prometheus.CounterOpts{
 		Namespace: "tempo",
 		Name:      "discarded_spans_total",
-		Help:      "The total number of samples that were discarded.",
+		Help:      "The total number of spans that were discarded.",
 	}, []string{discardReasonLabel, "tenant"})
+	metricTruncatedAttributes = tenantmetrics.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "tempo",
+		Name:      "distributor_span_attributes_truncated_total",
+		Help:      "The total number of span attributes dropped for exceeding the per span attribute limit.",
+	}, []string{"tenant"})
+	metricEncryptedAttributes = tenantmetrics.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "tempo",
+		Name:      "distributor_attributes_encrypted_total",
+		Help:      "The total number of attribute values encrypted before being written.",
+	}, []string{"tenant"})
+	metricGeneratorClients = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: "tempo",
+		Name:      "distributor_metrics_generator_clients",
+		Help:      "The current number of metrics generator clients.",
+	})
+	metricGeneratorPushFailures = tenantmetrics.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "tempo",
+		Name:      "distributor_metrics_generator_push_failures_total",
+		Help:      "The total number of failed pushes of a tenant's spans to the metrics generators.",
+	}, []string{"tenant"})
+	metricDerivedAttributes = tenantmetrics.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "tempo",
+		Name:      "distributor_attributes_derived_total",
+		Help:      "The total number of resource attributes added by attribute rules.",
+	}, []string{"tenant"})
 )
 
 // Distributor coordinates replicates and distribution of log streams.
@@ -79,18 +123,34 @@ type Distributor struct {
 	clientCfg       ingester_client.Config
 	ingestersRing   ring.ReadRing
 	pool            *ring_client.Pool
+	generatorsRing  ring.ReadRing     // nil if spans aren't sent to metrics generators
+	generatorPool   *ring_client.Pool // nil if spans aren't sent to metrics generators
 	DistributorRing *ring.Ring
+	overrides       *overrides.Overrides
+	encrypter       *encryption.Encrypter
+	pushVersions    *ingester_client.PushVersions
+	ingesterLoads   *ingester_client.IngesterLoads
+	batcher         *pushBatcher     // nil if push batching is disabled
+	asyncQueue      *asyncPushQueue  // nil if async acks are disabled
+	watermarks      *watermark.Guard // nil if disabled
+	forwarders      map[string]*forwarder
+	enricher        *enrichment.Enricher // nil if k8s enrichment is disabled
+	idempotencyKeys *idempotencyKeys     // nil if deduplication is disabled
+	sampler         *sampler
+	firehose        *firehose
 
 	// Per-user rate limiter.
 	ingestionRateLimiter *limiter.RateLimiter
+	// limits the spans of a tenant received through a receiver, keyed by receiverLimitKey
+	receiverRateLimiter *limiter.RateLimiter
 
 	// Manager for subservices
 	subservices        *services.Manager
 	subservicesWatcher *services.FailureWatcher
 }
 
-// New a distributor creates.
-func New(cfg Config, clientCfg ingester_client.Config, ingestersRing ring.ReadRing, o *overrides.Overrides, authEnabled bool, level logging.Level) (*Distributor, error) {
+// New a distributor creates.  generatorsRing may be nil, in which case spans aren't sent to metrics generators.
+func New(cfg Config, clientCfg ingester_client.Config, ingestersRing ring.ReadRing, generatorsRing ring.ReadRing, o *overrides.Overrides, authEnabled bool, level logging.Level) (*Distributor, error) {
 	factory := cfg.factory
 	if factory == nil {
 		factory = func(addr string) (ring_client.PoolClient, error) {
@@ -101,7 +161,7 @@ func New(cfg Config, clientCfg ingester_client.Config, ingestersRing ring.ReadRi
 	subservices := []services.Service(nil)
 
 	// Create the configured ingestion rate limit strategy (local or global).
-	var ingestionRateStrategy limiter.RateLimiterStrategy
+	var ingestionRateStrategy, receiverRateStrategy limiter.RateLimiterStrategy
 	var distributorRing *ring.Ring
 
 	if o.IngestionRateStrategy() == overrides.GlobalIngestionRateStrategy {
@@ -112,6 +172,7 @@ func New(cfg Config, clientCfg ingester_client.Config, ingestersRing ring.ReadRi
 		}
 		subservices = append(subservices, lifecycler)
 		ingestionRateStrategy = newGlobalIngestionRateStrategy(o, lifecycler)
+		receiverRateStrategy = newReceiverRateStrategy(o, lifecycler)
 
 		ring, err := ring.New(lifecyclerCfg.RingConfig, "distributor", cfg.OverrideRingKey, prometheus.DefaultRegisterer)
 		if err != nil {
@@ -121,6 +182,25 @@ func New(cfg Config, clientCfg ingester_client.Config, ingestersRing ring.ReadRi
 		subservices = append(subservices, distributorRing)
 	} else {
 		ingestionRateStrategy = newLocalIngestionRateStrategy(o)
+		receiverRateStrategy = newReceiverRateStrategy(o, nil)
+	}
+
+	encrypter, err := encryption.New(cfg.AttributeEncryption)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to initialize attribute encryption")
+	}
+
+	var enricher *enrichment.Enricher
+	if cfg.K8sEnrichment.Enabled {
+		enricher, err = enrichment.New(cfg.K8sEnrichment)
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to initialize k8s enrichment")
+		}
+	}
+
+	forwarders, err := newForwarders(cfg.Forwarders)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to initialize forwarders")
 	}
 
 	pool := ring_client.NewPool("distributor_pool",
@@ -132,13 +212,52 @@ func New(cfg Config, clientCfg ingester_client.Config, ingestersRing ring.ReadRi
 
 	subservices = append(subservices, pool)
 
+	var generatorPool *ring_client.Pool
+	if generatorsRing != nil {
+		generatorPool = ring_client.NewPool("distributor_metrics_generator_pool",
+			clientCfg.PoolConfig,
+			ring_client.NewRingServiceDiscovery(generatorsRing),
+			factory,
+			metricGeneratorClients,
+			cortex_util.Logger)
+
+		subservices = append(subservices, generatorPool)
+	}
+
 	d := &Distributor{
 		cfg:                  cfg,
 		clientCfg:            clientCfg,
 		ingestersRing:        ingestersRing,
 		pool:                 pool,
+		generatorsRing:       generatorsRing,
+		generatorPool:        generatorPool,
 		DistributorRing:      distributorRing,
+		overrides:            o,
+		encrypter:            encrypter,
+		pushVersions:         ingester_client.NewPushVersions(),
+		ingesterLoads:        ingester_client.NewIngesterLoads(ingesterLoadMaxAge),
+		watermarks:           watermark.New(cfg.MemoryWatermarks, "distributor"),
+		idempotencyKeys:      newIdempotencyKeys(cfg.Idempotency),
+		sampler:              newSampler(cfg.Sampling, o),
+		firehose:             newFirehose(),
+		forwarders:           forwarders,
+		enricher:             enricher,
 		ingestionRateLimiter: limiter.NewRateLimiter(ingestionRateStrategy, 10*time.Second),
+		receiverRateLimiter:  limiter.NewRateLimiter(receiverRateStrategy, 10*time.Second),
+	}
+
+	if cfg.PushBatching.Window > 0 {
+		d.batcher = newPushBatcher(cfg.PushBatching, d.sendTraces)
+	}
+
+	if cfg.AsyncAck.Path != "" {
+		d.asyncQueue, err = newAsyncPushQueue(cfg.AsyncAck, func(userID string, batches []*opentelemetry_proto_trace_v1.ResourceSpans, spanCount int) error {
+			return d.sendToIngesters(user.InjectOrgID(context.Background(), userID), userID, batches, spanCount)
+		})
+		if err != nil {
+			stopForwarders(forwarders)
+			return nil, err
+		}
 	}
 
 	cfgReceivers := cfg.Receivers
@@ -146,8 +265,9 @@ func New(cfg Config, clientCfg ingester_client.Config, ingestersRing ring.ReadRi
 		cfgReceivers = defaultReceivers
 	}
 
-	receivers, err := receiver.New(cfgReceivers, d, authEnabled, level)
+	receivers, err := receiver.New(cfgReceivers, cfg.ReceiverMetadata, cfg.TenantRouting, cfg.ReceiverBackpressure, d, authEnabled, level)
 	if err != nil {
+		stopForwarders(forwarders)
 		return nil, err
 	}
 	subservices = append(subservices, receivers)
@@ -170,6 +290,11 @@ func (d *Distributor) starting(ctx context.Context) error {
 		return fmt.Errorf("failed to start subservices %w", err)
 	}
 
+	// pushes left in the queue are delivered once the ingester clients are running
+	if d.asyncQueue != nil {
+		d.asyncQueue.start()
+	}
+
 	return nil
 }
 
@@ -184,7 +309,16 @@ func (d *Distributor) running(ctx context.Context) error {
 
 // Called after distributor is asked to stop via StopAsync.
 func (d *Distributor) stopping(_ error) error {
-	return services.StopManagerAndAwaitStopped(context.Background(), d.subservices)
+	// pushes not delivered yet stay on disk and are replayed on the next start
+	if d.asyncQueue != nil {
+		d.asyncQueue.stop()
+	}
+	err := services.StopManagerAndAwaitStopped(context.Background(), d.subservices)
+	if d.batcher != nil {
+		d.batcher.stop()
+	}
+	stopForwarders(d.forwarders)
+	return err
 }
 
 // Push a set of streams.
@@ -205,9 +339,44 @@ func (d *Distributor) Push(ctx context.Context, req *tempopb.PushRequest) (*temp
 	if spanCount == 0 {
 		return &tempopb.PushResponse{}, nil
 	}
-	metricSpansIngested.WithLabelValues(userID).Add(float64(spanCount))
+
+	d.logReceivedSpans(userID, req.Batch)
 
 	now := time.Now()
+	idempotencyKey := receiver.IdempotencyKey(ctx)
+	switch d.idempotencyKeys.reserve(userID, idempotencyKey, now) {
+	case keyAccepted:
+		// a retry of a push that was accepted. acknowledge it without ingesting the spans again
+		metricDuplicateSpans.WithLabelValues(userID).Add(float64(spanCount))
+		return nil, nil
+	case keyPending:
+		// the push in progress may still fail, so the retry is neither ingested nor acknowledged
+		return nil, status.Errorf(codes.Aborted, "a push with idempotency key %s is in progress", idempotencyKey)
+	}
+	accepted := false
+	defer func() {
+		if !accepted {
+			d.idempotencyKeys.release(userID, idempotencyKey)
+		}
+	}()
+	metricSpansIngested.WithLabelValues(userID).Add(float64(spanCount))
+
+	// sampled out spans don't count toward the ingestion rate limit
+	spanCount = d.sampler.sample(userID, req.Batch, spanCount, now)
+	if spanCount == 0 {
+		return nil, nil
+	}
+
+	// checked before the tenant limit so a throttled receiver doesn't use up the tenant's limit
+	receiverName, transport := receiver.Source(ctx)
+	if key, ok := d.overrides.ReceiverLimitKey(userID, receiverName, transport); ok {
+		if !d.receiverRateLimiter.AllowN(now, receiverLimitKey(userID, key), spanCount) {
+			metricDiscardedSpans.WithLabelValues(receiverRateLimited, userID).Add(float64(spanCount))
+
+			return nil, status.Errorf(codes.ResourceExhausted, "ingestion rate limit of receiver %s (%d spans) exceeded while adding %d spans", key, int(d.receiverRateLimiter.Limit(now, receiverLimitKey(userID, key))), spanCount)
+		}
+	}
+
 	if !d.ingestionRateLimiter.AllowN(now, userID, spanCount) {
 		// Return a 4xx here to have the client discard the data and not retry. If a client
 		// is sending too much data consistently we will unlikely ever catch up otherwise.
@@ -216,43 +385,275 @@ func (d *Distributor) Push(ctx context.Context, req *tempopb.PushRequest) (*temp
 		return nil, status.Errorf(codes.ResourceExhausted, "ingestion rate limit (%d spans) exceeded while adding %d spans", int(d.ingestionRateLimiter.Limit(now, userID)), spanCount)
 	}
 
-	keys, traces, err := requestsByTraceID(req, userID, spanCount)
+	if d.watermarks.RejectAnyPush() {
+		metricDiscardedSpans.WithLabelValues(overloaded, userID).Add(float64(spanCount))
+
+		return nil, status.Errorf(codes.ResourceExhausted, "distributor memory above push limit, rejecting %d spans", spanCount)
+	}
+
+	if d.overrides.BestEffort(userID) && d.watermarks.RejectPush() {
+		metricDiscardedSpans.WithLabelValues(overloaded, userID).Add(float64(spanCount))
+
+		return nil, status.Errorf(codes.ResourceExhausted, "distributor memory above push watermark, rejecting %d spans for best effort tenant", spanCount)
+	}
+
+	if d.enricher != nil {
+		d.enricher.Enrich(ctx, req.Batch)
+	}
+
+	if rules := d.overrides.AttributeRules(userID); len(rules) > 0 {
+		derived := applyAttributeRules(req.Batch, rules)
+		metricDerivedAttributes.WithLabelValues(userID).Add(float64(derived))
+	}
+
+	if maxAttributes := d.overrides.MaxAttributesPerSpan(userID); maxAttributes > 0 {
+		truncated := truncateSpanAttributes(req.Batch, maxAttributes)
+		metricTruncatedAttributes.WithLabelValues(userID).Add(float64(truncated))
+	}
+
+	if d.encrypter != nil {
+		encrypted, err := d.encrypter.EncryptBatch(userID, req.Batch, d.overrides.EncryptedAttributes(userID))
+		metricEncryptedAttributes.WithLabelValues(userID).Add(float64(encrypted))
+		if err != nil {
+			return nil, status.Errorf(codes.Internal, "failed to encrypt attributes: %v", err)
+		}
+	}
+
+	queued := false
+	if d.asyncQueue != nil && d.overrides.AsyncWriteAck(userID) {
+		// invalid batches would be rejected by every delivery, so they're rejected before being acknowledged
+		if err := validateTraceIDs(req.Batch); err != nil {
+			return nil, err
+		}
+		queued = d.asyncQueue.enqueue(userID, req.Batch, spanCount)
+	}
+
+	switch {
+	case queued:
+	case d.batcher != nil && spanCount <= d.cfg.PushBatching.MaxPushSpans:
+		if err := validateTraceIDs(req.Batch); err != nil {
+			return nil, err
+		}
+		err = d.batcher.push(ctx, userID, req.Batch, spanCount)
+	default:
+		err = d.sendToIngesters(ctx, userID, []*opentelemetry_proto_trace_v1.ResourceSpans{req.Batch}, spanCount)
+	}
 	if err != nil {
 		return nil, err
 	}
 
-	// change push request to take a batch of batches
-	err = ring.DoBatch(ctx, d.ingestersRing, keys, func(ingester ring.IngesterDesc, indexes []int) error {
+	// only spans accepted by the ingesters are forwarded
+	d.firehose.publish(userID, req.Batch)
+	d.forward(userID, req.Batch)
+	d.sendToGenerators(userID, req.Batch, spanCount)
+	d.idempotencyKeys.commit(userID, idempotencyKey, now)
+	accepted = true
+
+	// PushRequest is ignored, so no reason to create one
+	return nil, nil
+}
+
+// forward queues the batch for each forwarder of the tenant.  Unknown forwarders are ignored so overrides can be
+// rolled out before the distributor config.
+func (d *Distributor) forward(userID string, batch *opentelemetry_proto_trace_v1.ResourceSpans) {
+	for _, name := range d.overrides.Forwarders(userID) {
+		if f, ok := d.forwarders[name]; ok {
+			f.forward(userID, batch)
+		}
+	}
+}
+
+// sendToGenerators shards the spans of the batch by trace id and pushes them to the metrics generators owning each
+// trace, so every span of a trace reaches the same generator.  Metrics are best effort: failures are counted but
+// never fail the push.
+func (d *Distributor) sendToGenerators(userID string, batch *opentelemetry_proto_trace_v1.ResourceSpans, spanCount int) {
+	if d.generatorsRing == nil || len(d.overrides.MetricsGeneratorProcessors(userID)) == 0 {
+		return
+	}
+
+	keys, traces, err := requestsByTraceID(&tempopb.PushRequest{Batch: batch}, userID, spanCount)
+	if err != nil {
+		metricGeneratorPushFailures.WithLabelValues(userID).Inc()
+		return
+	}
+
+	err = ring.DoBatch(context.Background(), d.generatorsRing, keys, func(generator ring.IngesterDesc, indexes []int) error {
 		localCtx, cancel := context.WithTimeout(context.Background(), d.clientCfg.RemoteTimeout)
 		defer cancel()
 		localCtx = user.InjectOrgID(localCtx, userID)
 
-		var err error
+		c, err := d.generatorPool.GetClientFor(generator.Addr)
+		if err != nil {
+			return err
+		}
+
 		for _, idx := range indexes {
-			pushRequest := traces[idx]
-			err = d.send(localCtx, ingester.Addr, pushRequest)
+			_, err = c.(tempopb.MetricsGeneratorClient).PushSpans(localCtx, traces[idx])
 			if err != nil {
-				break
+				return err
 			}
 		}
+		return nil
+	}, func() {})
+	if err != nil {
+		metricGeneratorPushFailures.WithLabelValues(userID).Inc()
+		level.Debug(cortex_util.Logger).Log("msg", "failed to push spans to metrics generators", "tenant", userID, "err", err)
+	}
+}
 
+// sendToIngesters shards the spans of the batches by trace id and pushes them to the ingesters owning each trace.
+// sendToIngesters sends the batches to the ingesters.  A trace rejected by an ingester limit fails the push with the
+// limit error so the client receives a 4xx naming the trace.
+func (d *Distributor) sendToIngesters(ctx context.Context, userID string, batches []*opentelemetry_proto_trace_v1.ResourceSpans, spanCount int) error {
+	rejected, err := d.sendTraces(ctx, userID, batches, spanCount)
+	if err != nil {
 		return err
+	}
+	return firstRejection(rejected)
+}
+
+// sendTraces sends the batches to the ingesters and returns the errors of the traces rejected by an ingester limit by
+// trace id.  A rejected trace doesn't stop the other traces from being sent.  The spans of rejected traces are counted
+// as discarded.
+func (d *Distributor) sendTraces(ctx context.Context, userID string, batches []*opentelemetry_proto_trace_v1.ResourceSpans, spanCount int) (map[string]error, error) {
+	var keys []uint32
+	var traces []*tempopb.PushRequest
+	for _, batch := range batches {
+		batchKeys, batchTraces, err := requestsByTraceID(&tempopb.PushRequest{Batch: batch}, userID, spanCount)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, batchKeys...)
+		traces = append(traces, batchTraces...)
+		metricTracesPerBatch.Observe(float64(len(batchTraces)))
+	}
+
+	// resolve limits once so every ingester enforces the same values
+	limits := ingester_client.PushLimits{
+		MaxLocalTracesPerUser:  d.overrides.MaxLocalTracesPerUser(userID),
+		MaxGlobalTracesPerUser: d.overrides.MaxGlobalTracesPerUser(userID),
+		MaxSpansPerTrace:       d.overrides.MaxSpansPerTrace(userID),
+		MaxBytesPerTrace:       d.overrides.MaxBytesPerTrace(userID),
+		MaxAttributeKeys:       d.overrides.MaxAttributeKeysPerBlock(userID),
+	}
+
+	tenantRing, err := util.TenantRing(d.ingestersRing, userID, d.overrides.IngestionTenantShardSize(userID))
+	if err != nil {
+		return nil, err
+	}
+	ingestersRing := tenantRing
+	if d.cfg.IngesterLoadBalancing.Enabled {
+		ingestersRing = newLoadBalancedRing(ingestersRing, d.cfg.IngesterLoadBalancing, d.ingesterLoads)
+	}
+
+	var (
+		rejectedMtx sync.Mutex
+		rejected    = map[string]error{}
+	)
+	reject := func(req *tempopb.PushRequest, details *tempopb.PushErrorDetails, err error) {
+		rejectedMtx.Lock()
+		defer rejectedMtx.Unlock()
+
+		traceIDs := [][]byte{details.TraceID}
+		if len(details.TraceID) == 0 {
+			traceIDs = pushTraceIDs(req)
+		}
+		for _, traceID := range traceIDs {
+			// every replica rejects the trace, but its spans are discarded once
+			if _, ok := rejected[string(traceID)]; !ok {
+				rejected[string(traceID)] = err
+				metricDiscardedSpans.WithLabelValues(details.Reason, userID).Add(float64(details.Spans))
+			}
+		}
+	}
+
+	// change push request to take a batch of batches
+	err = ring.DoBatch(ctx, ingestersRing, keys, func(ingester ring.IngesterDesc, indexes []int) error {
+		localCtx, cancel := context.WithTimeout(context.Background(), d.clientCfg.RemoteTimeout)
+		defer cancel()
+		localCtx = user.InjectOrgID(localCtx, userID)
+		localCtx = ingester_client.InjectPushLimits(localCtx, limits)
+
+		for _, idx := range indexes {
+			pushRequest := traces[idx]
+			err := d.send(localCtx, ingester, pushRequest)
+			if err != nil {
+				err = d.retryOverloaded(localCtx, tenantRing, ingester, keys[idx], pushRequest, err)
+			}
+			// the ingester rejects the trace however often it's sent, so it doesn't fail the other traces
+			if details, ok := ingester_client.LimitErrorDetails(err); ok {
+				reject(pushRequest, details, err)
+				continue
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return nil
 	}, func() {})
+	if err != nil {
+		return nil, err
+	}
+
+	rejectedMtx.Lock()
+	defer rejectedMtx.Unlock()
+	return rejected, nil
+}
 
-	return nil, err // PushRequest is ignored, so no reason to create one
+// firstRejection returns the error of the rejected trace with the lowest id, or nil if no trace was rejected.
+func firstRejection(rejected map[string]error) error {
+	var first string
+	var err error
+	for traceID, traceErr := range rejected {
+		if err == nil || traceID < first {
+			first, err = traceID, traceErr
+		}
+	}
+	return err
 }
 
-func (d *Distributor) send(ctx context.Context, ingesterAddr string, req *tempopb.PushRequest) error {
-	c, err := d.pool.GetClientFor(ingesterAddr)
+// pushTraceIDs returns the ids of the traces in the request.
+func pushTraceIDs(req *tempopb.PushRequest) [][]byte {
+	var traceIDs [][]byte
+	seen := map[string]struct{}{}
+	for _, ils := range req.Batch.InstrumentationLibrarySpans {
+		for _, span := range ils.Spans {
+			if _, ok := seen[string(span.TraceId)]; !ok {
+				seen[string(span.TraceId)] = struct{}{}
+				traceIDs = append(traceIDs, span.TraceId)
+			}
+		}
+	}
+	return traceIDs
+}
+
+func (d *Distributor) send(ctx context.Context, ingester ring.IngesterDesc, req *tempopb.PushRequest) error {
+	c, err := d.pool.GetClientFor(ingester.Addr)
 	if err != nil {
 		return err
 	}
 
-	_, err = c.(tempopb.PusherClient).Push(ctx, req)
-	metricIngesterAppends.WithLabelValues(ingesterAddr).Inc()
+	// encode with the version negotiated with the ingester.  on error it is forgotten so an ingester that restarted
+	// with an older build is sent the oldest version until it advertises again.
+	ctx = ingester_client.InjectPushVersion(ctx, d.pushVersions.Get(ingester.Addr))
+
+	start := time.Now()
+	var header metadata.MD
+	_, err = c.(tempopb.PusherClient).Push(ctx, req, grpc.Header(&header))
+	metricIngesterAppends.WithLabelValues(ingester.Addr).Inc()
+
+	result := "success"
 	if err != nil {
-		metricIngesterAppendFailures.WithLabelValues(ingesterAddr).Inc()
+		result = "error"
+		metricIngesterAppendFailures.WithLabelValues(ingester.Addr).Inc()
+		d.pushVersions.Forget(ingester.Addr)
+		d.ingesterLoads.Forget(ingester.Addr)
+	} else {
+		d.pushVersions.Observe(ingester.Addr, header)
+		d.ingesterLoads.Observe(ingester.Addr, header)
 	}
+	metricIngesterPushDuration.WithLabelValues(ingester.Addr, ingester.Zone, result).Observe(time.Since(start).Seconds())
+
 	return err
 }
 
@@ -261,6 +662,26 @@ func (*Distributor) Check(_ context.Context, _ *grpc_health_v1.HealthCheckReques
 	return &grpc_health_v1.HealthCheckResponse{Status: grpc_health_v1.HealthCheckResponse_SERVING}, nil
 }
 
+// truncateSpanAttributes keeps the first max attributes of every span in the batch.  Dropped attributes are added to
+// the span's dropped attributes count.  It returns the total number of attributes dropped.
+func truncateSpanAttributes(batch *opentelemetry_proto_trace_v1.ResourceSpans, max int) int {
+	truncated := 0
+	for _, ils := range batch.InstrumentationLibrarySpans {
+		for _, span := range ils.Spans {
+			if len(span.Attributes) <= max {
+				continue
+			}
+
+			dropped := len(span.Attributes) - max
+			span.Attributes = span.Attributes[:max]
+			span.DroppedAttributesCount += uint32(dropped)
+			truncated += dropped
+		}
+	}
+
+	return truncated
+}
+
 func requestsByTraceID(req *tempopb.PushRequest, userID string, spanCount int) ([]uint32, []*tempopb.PushRequest, error) {
 	const expectedTracesPerBatch = 10 // roughly what we're seeing through metrics
 	expectedSpansPerTrace := spanCount / expectedTracesPerBatch
@@ -308,8 +729,6 @@ func requestsByTraceID(req *tempopb.PushRequest, userID string, spanCount int) (
 		}
 	}
 
-	metricTracesPerBatch.Observe(float64(len(requestsByTrace)))
-
 	keys := make([]uint32, 0, len(requestsByTrace))
 	pushRequests := make([]*tempopb.PushRequest, 0, len(requestsByTrace))
 
@@ -5,12 +5,15 @@ import (
 	"fmt"
 	"math/rand"
 	"strconv"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/cortexproject/cortex/pkg/ring"
 	ring_client "github.com/cortexproject/cortex/pkg/ring/client"
 	"github.com/cortexproject/cortex/pkg/ring/kv"
+	cortex_util "github.com/cortexproject/cortex/pkg/util"
 	"github.com/cortexproject/cortex/pkg/util/flagext"
 	"github.com/gogo/status"
 	v1_common "github.com/open-telemetry/opentelemetry-proto/gen/go/common/v1"
@@ -19,14 +22,17 @@ import (
 
 	"github.com/golang/protobuf/proto"
 	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"github.com/weaveworks/common/logging"
 	"github.com/weaveworks/common/user"
+	"go.opentelemetry.io/collector/obsreport"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/health/grpc_health_v1"
 
+	"github.com/grafana/tempo/modules/distributor/receiver"
 	ingester_client "github.com/grafana/tempo/modules/ingester/client"
 	"github.com/grafana/tempo/modules/overrides"
 	"github.com/grafana/tempo/pkg/tempopb"
@@ -297,6 +303,30 @@ func TestRequestsByTraceID(t *testing.T) {
 	}
 }
 
+func TestTruncateSpanAttributes(t *testing.T) {
+	attributes := func(n int) []*v1_common.KeyValue {
+		kvs := make([]*v1_common.KeyValue, 0, n)
+		for i := 0; i < n; i++ {
+			kvs = append(kvs, &v1_common.KeyValue{Key: strconv.Itoa(i)})
+		}
+		return kvs
+	}
+
+	short := &v1.Span{Attributes: attributes(2)}
+	long := &v1.Span{Attributes: attributes(5), DroppedAttributesCount: 1}
+	batch := &v1.ResourceSpans{
+		InstrumentationLibrarySpans: []*v1.InstrumentationLibrarySpans{
+			{Spans: []*v1.Span{short, long}},
+		},
+	}
+
+	assert.Equal(t, 2, truncateSpanAttributes(batch, 3))
+	assert.Equal(t, attributes(2), short.Attributes)
+	assert.Equal(t, uint32(0), short.DroppedAttributesCount)
+	assert.Equal(t, attributes(3), long.Attributes)
+	assert.Equal(t, uint32(3), long.DroppedAttributesCount)
+}
+
 func TestDistributor(t *testing.T) {
 	for i, tc := range []struct {
 		lines            int
@@ -328,6 +358,269 @@ func TestDistributor(t *testing.T) {
 	}
 }
 
+func TestDistributorTenantShardSize(t *testing.T) {
+	for _, tc := range []struct {
+		shardSize         int
+		expectedIngesters int
+	}{
+		{shardSize: 0, expectedIngesters: numIngesters},
+		{shardSize: 4, expectedIngesters: 4},
+		// shards are never smaller than the replication factor
+		{shardSize: 1, expectedIngesters: 3},
+	} {
+		t.Run(strconv.Itoa(tc.shardSize), func(t *testing.T) {
+			limits := &overrides.Limits{}
+			flagext.DefaultValues(limits)
+			limits.IngestionTenantShardSize = tc.shardSize
+			d := prepare(t, limits, nil)
+
+			ctx := user.InjectOrgID(context.Background(), "test")
+			for i := 0; i < 50; i++ {
+				_, err := d.Push(ctx, test.MakeRequest(1, nil))
+				require.NoError(t, err)
+			}
+
+			ingestersPushedTo := 0
+			for i := 0; i < numIngesters; i++ {
+				c, err := d.pool.GetClientFor(fmt.Sprintf("ingester%d", i))
+				require.NoError(t, err)
+				if atomic.LoadInt32(&c.(*mockIngester).pushes) > 0 {
+					ingestersPushedTo++
+				}
+			}
+			assert.Equal(t, tc.expectedIngesters, ingestersPushedTo)
+		})
+	}
+}
+
+func TestDistributorReceiverLimits(t *testing.T) {
+	limits := &overrides.Limits{}
+	flagext.DefaultValues(limits)
+	limits.ReceiverLimits = map[string]overrides.ReceiverLimit{
+		"zipkin": {IngestionRateSpans: 1, IngestionMaxBatchSize: 10},
+	}
+	d := prepare(t, limits, nil)
+
+	zipkinCtx := obsreport.ReceiverContext(ctx, "zipkin", "http", "")
+	otlpCtx := obsreport.ReceiverContext(ctx, "otlp", "grpc", "")
+	discarded := metricDiscardedSpans.WithLabelValues(receiverRateLimited, "test")
+	before := counterValue(t, discarded)
+
+	_, err := d.Push(zipkinCtx, test.MakeRequest(10, nil))
+	require.NoError(t, err)
+
+	// the zipkin receiver is throttled, other receivers aren't
+	_, err = d.Push(zipkinCtx, test.MakeRequest(10, nil))
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+	assert.Contains(t, err.Error(), "receiver zipkin")
+	assert.Equal(t, 10.0, counterValue(t, discarded)-before)
+
+	_, err = d.Push(otlpCtx, test.MakeRequest(10, nil))
+	require.NoError(t, err)
+	_, err = d.Push(ctx, test.MakeRequest(10, nil))
+	require.NoError(t, err)
+}
+
+func TestDistributorIngesterLimitError(t *testing.T) {
+	limits := &overrides.Limits{}
+	flagext.DefaultValues(limits)
+	d := prepare(t, limits, nil)
+
+	traceID := []byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0A, 0x0B, 0x0C, 0x0D, 0x0E, 0x0F, 0x10}
+	limitErr := ingester_client.LimitError(ingester_client.ReasonMaxBytesPerTrace, traceID, 10, "trace %x exceeded max bytes per trace", traceID)
+	for i := 0; i < numIngesters; i++ {
+		c, err := d.pool.GetClientFor(fmt.Sprintf("ingester%d", i))
+		require.NoError(t, err)
+		c.(*mockIngester).setErr(limitErr)
+	}
+
+	discarded := metricDiscardedSpans.WithLabelValues(ingester_client.ReasonMaxBytesPerTrace, "test")
+	before := counterValue(t, discarded)
+
+	_, err := d.Push(ctx, test.MakeRequest(10, traceID))
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+	assert.Contains(t, err.Error(), "0102030405060708090a0b0c0d0e0f10")
+	assert.Equal(t, 10.0, counterValue(t, discarded)-before)
+
+	details, ok := ingester_client.LimitErrorDetails(err)
+	require.True(t, ok)
+	assert.Equal(t, traceID, details.TraceID)
+}
+
+func TestDistributorRetriesOverloadedIngesters(t *testing.T) {
+	traceID := []byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0A, 0x0B, 0x0C, 0x0D, 0x0E, 0x0F, 0x10}
+
+	// prepareFailing returns a distributor whose replicas of the trace fail with err and the pushes to the other
+	// ingesters
+	prepareFailing := func(err error, retries int) (*Distributor, *int32) {
+		limits := &overrides.Limits{}
+		flagext.DefaultValues(limits)
+		d := prepare(t, limits, nil)
+		d.cfg.IngesterOverloadRetries = retries
+
+		replicas, ringErr := d.ingestersRing.Get(util.TokenFor("test", traceID), ring.Write, nil)
+		require.NoError(t, ringErr)
+		failing := map[string]struct{}{}
+		for _, ing := range replicas.Ingesters {
+			failing[ing.Addr] = struct{}{}
+		}
+
+		others := new(int32)
+		for i := 0; i < numIngesters; i++ {
+			addr := fmt.Sprintf("ingester%d", i)
+			c, poolErr := d.pool.GetClientFor(addr)
+			require.NoError(t, poolErr)
+			if _, ok := failing[addr]; ok {
+				c.(*mockIngester).setErr(err)
+			} else {
+				c.(*mockIngester).setOnPush(func() { atomic.AddInt32(others, 1) })
+			}
+		}
+		return d, others
+	}
+
+	// pushes rejected by overloaded replicas land on the other ingesters
+	overloaded := status.Error(codes.ResourceExhausted, "ingester holds its max live traces")
+	d, others := prepareFailing(overloaded, 1)
+	_, err := d.Push(ctx, test.MakeRequest(10, traceID))
+	assert.NoError(t, err)
+	assert.GreaterOrEqual(t, atomic.LoadInt32(others), int32(2))
+
+	d, others = prepareFailing(overloaded, 0)
+	_, err = d.Push(ctx, test.MakeRequest(10, traceID))
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+	assert.Equal(t, int32(0), atomic.LoadInt32(others))
+
+	// other errors aren't retried
+	d, others = prepareFailing(status.Error(codes.Unavailable, "ingester unavailable"), 1)
+	_, err = d.Push(ctx, test.MakeRequest(10, traceID))
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+	assert.Equal(t, int32(0), atomic.LoadInt32(others))
+}
+
+func TestDistributorIdempotencyKey(t *testing.T) {
+	limits := &overrides.Limits{}
+	flagext.DefaultValues(limits)
+	d := prepare(t, limits, nil)
+	d.idempotencyKeys = newIdempotencyKeys(IdempotencyConfig{KeysPerTenant: 10, Window: time.Minute})
+
+	c, err := d.pool.GetClientFor("ingester0")
+	require.NoError(t, err)
+	ingester0 := c.(*mockIngester)
+
+	ingested := metricSpansIngested.WithLabelValues("test")
+	duplicates := metricDuplicateSpans.WithLabelValues("test")
+	ingestedBefore, duplicatesBefore := counterValue(t, ingested), counterValue(t, duplicates)
+
+	traceID := []byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0A, 0x0B, 0x0C, 0x0D, 0x0E, 0x0F, 0x10}
+	keyCtx := receiver.WithIdempotencyKey(ctx, "retry/0")
+
+	// a failed push can be retried with the same key
+	for i := 0; i < numIngesters; i++ {
+		c, err := d.pool.GetClientFor(fmt.Sprintf("ingester%d", i))
+		require.NoError(t, err)
+		c.(*mockIngester).setErr(status.Error(codes.Unavailable, "unavailable"))
+	}
+	_, err = d.Push(keyCtx, test.MakeRequest(10, traceID))
+	require.Error(t, err)
+	for i := 0; i < numIngesters; i++ {
+		c, _ := d.pool.GetClientFor(fmt.Sprintf("ingester%d", i))
+		c.(*mockIngester).setErr(nil)
+	}
+
+	_, err = d.Push(keyCtx, test.MakeRequest(10, traceID))
+	require.NoError(t, err)
+	pushes := atomic.LoadInt32(&ingester0.pushes)
+
+	// once accepted, repeats are acknowledged without being ingested
+	_, err = d.Push(keyCtx, test.MakeRequest(10, traceID))
+	require.NoError(t, err)
+	assert.Equal(t, pushes, atomic.LoadInt32(&ingester0.pushes))
+	assert.Equal(t, 20.0, counterValue(t, ingested)-ingestedBefore)
+	assert.Equal(t, 10.0, counterValue(t, duplicates)-duplicatesBefore)
+
+	// pushes without a key are never dropped
+	_, err = d.Push(ctx, test.MakeRequest(10, traceID))
+	require.NoError(t, err)
+	assert.Equal(t, 30.0, counterValue(t, ingested)-ingestedBefore)
+
+	// a retry arriving while the push with its key is in progress is refused so it can be retried
+	unblock := make(chan struct{})
+	for i := 0; i < numIngesters; i++ {
+		c, _ := d.pool.GetClientFor(fmt.Sprintf("ingester%d", i))
+		c.(*mockIngester).setOnPush(func() { <-unblock })
+	}
+	inProgressCtx := receiver.WithIdempotencyKey(ctx, "retry/1")
+	done := make(chan error)
+	go func() {
+		_, err := d.Push(inProgressCtx, test.MakeRequest(10, traceID))
+		done <- err
+	}()
+	require.Eventually(t, func() bool {
+		d.idempotencyKeys.mtx.Lock()
+		defer d.idempotencyKeys.mtx.Unlock()
+		e, ok := d.idempotencyKeys.tenants["test"].items["retry/1"]
+		return ok && e.Value.(*acceptedKey).pending
+	}, time.Second, time.Millisecond)
+	_, err = d.Push(inProgressCtx, test.MakeRequest(10, traceID))
+	assert.Equal(t, codes.Aborted, status.Code(err))
+	close(unblock)
+	require.NoError(t, <-done)
+}
+
+func TestDistributorSendsToGenerators(t *testing.T) {
+	traceIDs := [][]byte{
+		{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0A, 0x0B, 0x0C, 0x0D, 0x0E, 0x0F, 0x10},
+		{0x10, 0x0F, 0x0E, 0x0D, 0x0C, 0x0B, 0x0A, 0x09, 0x08, 0x07, 0x06, 0x05, 0x04, 0x03, 0x02, 0x01},
+	}
+
+	for _, processors := range [][]string{nil, {"span-metrics"}} {
+		limits := &overrides.Limits{}
+		flagext.DefaultValues(limits)
+		limits.MetricsGeneratorProcessors = processors
+		d := prepare(t, limits, nil)
+
+		generators := map[string]*mockGenerator{}
+		generatorsRing := &mockGeneratorsRing{mockRing{replicationFactor: 1}}
+		for i := 0; i < 3; i++ {
+			addr := fmt.Sprintf("generator%d", i)
+			generators[addr] = &mockGenerator{spans: map[string]int{}}
+			generatorsRing.ingesters = append(generatorsRing.ingesters, ring.IngesterDesc{Addr: addr})
+		}
+		d.generatorsRing = generatorsRing
+		d.generatorPool = ring_client.NewPool("test", ring_client.PoolConfig{}, nil, func(addr string) (ring_client.PoolClient, error) {
+			return generators[addr], nil
+		}, metricGeneratorClients, cortex_util.Logger)
+
+		for _, traceID := range traceIDs {
+			for i := 0; i < 2; i++ {
+				_, err := d.Push(ctx, test.MakeRequest(5, traceID))
+				require.NoError(t, err)
+			}
+		}
+
+		// every span of a trace reaches the same generator
+		spans := map[string]int{}
+		for _, g := range generators {
+			for traceID, n := range g.spans {
+				assert.Equal(t, 0, spans[traceID], "trace sent to several generators")
+				spans[traceID] = n
+			}
+		}
+		if len(processors) == 0 {
+			assert.Empty(t, spans)
+			continue
+		}
+		assert.Equal(t, map[string]int{string(traceIDs[0]): 10, string(traceIDs[1]): 10}, spans)
+	}
+}
+
+func counterValue(t *testing.T, c prometheus.Counter) float64 {
+	m := &dto.Metric{}
+	require.NoError(t, c.Write(m))
+	return m.GetCounter().GetValue()
+}
+
 func prepare(t *testing.T, limits *overrides.Limits, kvStore kv.Client) *Distributor {
 	var (
 		distributorConfig Config
@@ -353,6 +646,7 @@ func prepare(t *testing.T, limits *overrides.Limits, kvStore kv.Client) *Distrib
 		})
 	}
 
+	distributorConfig.Sampling.Ratio = 1
 	distributorConfig.DistributorRing.HeartbeatPeriod = 100 * time.Millisecond
 	distributorConfig.DistributorRing.InstanceID = strconv.Itoa(rand.Int())
 	distributorConfig.DistributorRing.KVStore.Mock = kvStore
@@ -363,7 +657,7 @@ func prepare(t *testing.T, limits *overrides.Limits, kvStore kv.Client) *Distrib
 
 	l := logging.Level{}
 	_ = l.Set("error")
-	d, err := New(distributorConfig, clientConfig, ingestersRing, overrides, true, l)
+	d, err := New(distributorConfig, clientConfig, ingestersRing, nil, overrides, true, l)
 	require.NoError(t, err)
 
 	return d
@@ -372,16 +666,74 @@ func prepare(t *testing.T, limits *overrides.Limits, kvStore kv.Client) *Distrib
 type mockIngester struct {
 	grpc_health_v1.HealthClient
 	tempopb.PusherClient
+
+	pushes int32
+
+	// err and onPush are changed while the sends of earlier pushes may still be running
+	mtx    sync.Mutex
+	err    error
+	onPush func()
+}
+
+func (i *mockIngester) setErr(err error) {
+	i.mtx.Lock()
+	defer i.mtx.Unlock()
+	i.err = err
+}
+
+func (i *mockIngester) setOnPush(onPush func()) {
+	i.mtx.Lock()
+	defer i.mtx.Unlock()
+	i.onPush = onPush
 }
 
 func (i *mockIngester) Push(ctx context.Context, in *tempopb.PushRequest, opts ...grpc.CallOption) (*tempopb.PushResponse, error) {
-	return nil, nil
+	atomic.AddInt32(&i.pushes, 1)
+
+	i.mtx.Lock()
+	onPush, err := i.onPush, i.err
+	i.mtx.Unlock()
+	if onPush != nil {
+		onPush()
+	}
+	return nil, err
 }
 
 func (i *mockIngester) Close() error {
 	return nil
 }
 
+// mockGeneratorsRing writes to a single generator, which must succeed.
+type mockGeneratorsRing struct {
+	mockRing
+}
+
+func (r mockGeneratorsRing) Get(key uint32, op ring.Operation, buf []ring.IngesterDesc) (ring.ReplicationSet, error) {
+	result, err := r.mockRing.Get(key, op, buf)
+	result.MaxErrors = 0
+	return result, err
+}
+
+type mockGenerator struct {
+	grpc_health_v1.HealthClient
+	tempopb.MetricsGeneratorClient
+
+	spans map[string]int
+}
+
+func (g *mockGenerator) PushSpans(ctx context.Context, in *tempopb.PushRequest, opts ...grpc.CallOption) (*tempopb.PushResponse, error) {
+	for _, ils := range in.Batch.InstrumentationLibrarySpans {
+		for _, span := range ils.Spans {
+			g.spans[string(span.TraceId)]++
+		}
+	}
+	return &tempopb.PushResponse{}, nil
+}
+
+func (g *mockGenerator) Close() error {
+	return nil
+}
+
 // Copied from Cortex; TODO(twilkie) - factor this our and share it.
 // mockRing doesn't do virtual nodes, just returns mod(key) + replicationFactor
 // ingesters.
@@ -418,6 +770,13 @@ func (r mockRing) IngesterCount() int {
 	return len(r.ingesters)
 }
 
+// Subring returns the n ingesters following key.
 func (r mockRing) Subring(key uint32, n int) (ring.ReadRing, error) {
-	return r, nil
+	sub := mockRing{
+		replicationFactor: r.replicationFactor,
+	}
+	for i := 0; i < n && i < len(r.ingesters); i++ {
+		sub.ingesters = append(sub.ingesters, r.ingesters[(int(key%uint32(len(r.ingesters)))+i)%len(r.ingesters)])
+	}
+	return sub, nil
 }
@@ -0,0 +1,37 @@
+package enrichment
+
+import (
+	"flag"
+	"time"
+
+	"github.com/grafana/tempo/pkg/util"
+)
+
+const (
+	serviceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount"
+)
+
+// Config for decorating received spans with metadata of the pod that sent them, looked up from the Kubernetes API.
+type Config struct {
+	Enabled bool `yaml:"enabled"`
+	// APIURL is the Kubernetes API server.  The defaults work for a distributor running in the cluster.
+	APIURL    string `yaml:"api_url"`
+	TokenFile string `yaml:"token_file"` // bearer token.  read for every lookup so rotated tokens are picked up
+	CAFile    string `yaml:"ca_file"`    // CA of the API server.  the system roots are used if empty
+	// PodLabels are the pod labels copied to the resource as k8s.pod.label.<label>.  Configured in yaml only.
+	PodLabels []string      `yaml:"pod_labels"`
+	CacheTTL  time.Duration `yaml:"cache_ttl"` // how long pods, and pods that weren't found, are cached
+	CacheSize int           `yaml:"cache_size"`
+	Timeout   time.Duration `yaml:"timeout"` // bounds each lookup.  spans are pushed without enrichment if it fails
+}
+
+// RegisterFlags registers the flags.
+func (cfg *Config) RegisterFlags(prefix string, f *flag.FlagSet) {
+	f.BoolVar(&cfg.Enabled, util.PrefixConfig(prefix, "enabled"), false, "Add the node, owner and labels of the pod that sent spans to their resource attributes.")
+	f.StringVar(&cfg.APIURL, util.PrefixConfig(prefix, "api-url"), "https://kubernetes.default.svc", "Url of the Kubernetes API server.")
+	f.StringVar(&cfg.TokenFile, util.PrefixConfig(prefix, "token-file"), serviceAccountDir+"/token", "File holding the bearer token for the Kubernetes API. Not sent if empty.")
+	f.StringVar(&cfg.CAFile, util.PrefixConfig(prefix, "ca-file"), serviceAccountDir+"/ca.crt", "CA of the Kubernetes API server. The system roots are used if empty.")
+	f.DurationVar(&cfg.CacheTTL, util.PrefixConfig(prefix, "cache-ttl"), 5*time.Minute, "How long pods are cached.")
+	f.IntVar(&cfg.CacheSize, util.PrefixConfig(prefix, "cache-size"), 10000, "Maximum number of pods cached.")
+	f.DurationVar(&cfg.Timeout, util.PrefixConfig(prefix, "timeout"), time.Second, "Timeout of each Kubernetes API request.")
+}
@@ -0,0 +1,374 @@
+package enrichment
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cortexproject/cortex/pkg/util"
+	"github.com/go-kit/kit/log/level"
+	v1_common "github.com/open-telemetry/opentelemetry-proto/gen/go/common/v1"
+	v1 "github.com/open-telemetry/opentelemetry-proto/gen/go/trace/v1"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"github.com/grafana/tempo/modules/distributor/receiver"
+)
+
+const (
+	attributePodName        = "k8s.pod.name"
+	attributeNamespaceName  = "k8s.namespace.name"
+	attributePodIP          = "k8s.pod.ip"
+	attributeNodeName       = "k8s.node.name"
+	attributeDeploymentName = "k8s.deployment.name"
+	attributePodLabelPrefix = "k8s.pod.label."
+
+	podTemplateHashLabel = "pod-template-hash"
+
+	// errorTTL is how long failed lookups are cached so an unavailable API server isn't asked for every push.
+	errorTTL = 10 * time.Second
+)
+
+var (
+	metricLookups = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "tempo",
+		Name:      "distributor_enrichment_lookups_total",
+		Help:      "The total number of pod lookups for span enrichment by result.",
+	}, []string{"result"})
+)
+
+// Enricher decorates batches with the node, owner and labels of the pod that sent them.  The pod is identified by the
+// k8s.pod.name and k8s.namespace.name resource attributes, or else by the k8s.pod.ip or client ip attribute.  Pods
+// are cached so the API server is asked about each pod at most once per cache ttl.
+type Enricher struct {
+	cfg    Config
+	apiURL *url.URL
+	client *http.Client
+	now    func() time.Time
+
+	mtx   sync.Mutex
+	cache map[string]*cacheEntry
+}
+
+type cacheEntry struct {
+	ready   chan struct{} // closed once the lookup is done
+	attrs   []*v1_common.KeyValue
+	expires time.Time
+}
+
+// pod is the part of a Kubernetes pod used for enrichment.
+type pod struct {
+	Metadata struct {
+		Name            string            `json:"name"`
+		Namespace       string            `json:"namespace"`
+		Labels          map[string]string `json:"labels"`
+		OwnerReferences []struct {
+			Kind       string `json:"kind"`
+			Name       string `json:"name"`
+			Controller bool   `json:"controller"`
+		} `json:"ownerReferences"`
+	} `json:"metadata"`
+	Spec struct {
+		NodeName    string `json:"nodeName"`
+		HostNetwork bool   `json:"hostNetwork"`
+	} `json:"spec"`
+	Status struct {
+		Phase string `json:"phase"`
+	} `json:"status"`
+}
+
+type podList struct {
+	Items []pod `json:"items"`
+}
+
+// New makes a new Enricher.
+func New(cfg Config) (*Enricher, error) {
+	u, err := url.Parse(cfg.APIURL)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return nil, fmt.Errorf("invalid kubernetes api url %q", cfg.APIURL)
+	}
+
+	tlsConfig := &tls.Config{}
+	if cfg.CAFile != "" {
+		ca, err := ioutil.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read kubernetes ca: %w", err)
+		}
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("no certificates found in %s", cfg.CAFile)
+		}
+	}
+
+	return &Enricher{
+		cfg:    cfg,
+		apiURL: u,
+		client: &http.Client{
+			Timeout:   cfg.Timeout,
+			Transport: &http.Transport{TLSClientConfig: tlsConfig},
+		},
+		now:   time.Now,
+		cache: map[string]*cacheEntry{},
+	}, nil
+}
+
+// Enrich adds the metadata of the pod that sent the batch to its resource.  Attributes the batch already has are kept.
+// Batches are left as is if the pod can't be identified or looked up.
+func (e *Enricher) Enrich(ctx context.Context, batch *v1.ResourceSpans) {
+	if batch == nil || batch.Resource == nil {
+		return
+	}
+
+	key := lookupKey(batch.Resource.Attributes)
+	if key == "" {
+		return
+	}
+
+	attrs, ok := e.lookup(ctx, key)
+	if !ok {
+		return
+	}
+
+	// cached attributes are copied as later stages such as encryption modify values in place
+	for _, attr := range attrs {
+		if hasAttribute(batch.Resource.Attributes, attr.Key) {
+			continue
+		}
+		batch.Resource.Attributes = append(batch.Resource.Attributes, stringAttribute(attr.Key, attr.Value.GetStringValue()))
+	}
+}
+
+// lookup returns the attributes of the pod from the cache or the API server.  Concurrent lookups of the same pod wait
+// for the first.  ok is false if the context is done first.
+func (e *Enricher) lookup(ctx context.Context, key string) ([]*v1_common.KeyValue, bool) {
+	now := e.now()
+
+	e.mtx.Lock()
+	entry, found := e.cache[key]
+	if found && isDone(entry.ready) && now.After(entry.expires) {
+		found = false
+	}
+	if !found {
+		if e.cfg.CacheSize > 0 && len(e.cache) >= e.cfg.CacheSize {
+			e.purge(now)
+		}
+		entry = &cacheEntry{ready: make(chan struct{})}
+		e.cache[key] = entry
+	}
+	e.mtx.Unlock()
+
+	if found {
+		select {
+		case <-entry.ready:
+			metricLookups.WithLabelValues("hit").Inc()
+			return entry.attrs, true
+		case <-ctx.Done():
+			return nil, false
+		}
+	}
+
+	// the lookup isn't bound to the push so a cancelled push doesn't fail it for everyone waiting
+	attrs, ttl := e.fetch(key)
+	entry.attrs = attrs
+	entry.expires = e.now().Add(ttl)
+	close(entry.ready)
+
+	return attrs, true
+}
+
+// purge removes expired entries.  If the cache is still full it's emptied.  Must be called with mtx held.
+func (e *Enricher) purge(now time.Time) {
+	for k, entry := range e.cache {
+		if isDone(entry.ready) && now.After(entry.expires) {
+			delete(e.cache, k)
+		}
+	}
+	if len(e.cache) >= e.cfg.CacheSize {
+		e.cache = map[string]*cacheEntry{}
+	}
+}
+
+// fetch looks up the pod and returns its attributes and how long to cache them.
+func (e *Enricher) fetch(key string) ([]*v1_common.KeyValue, time.Duration) {
+	p, err := e.fetchPod(key)
+	if err != nil {
+		metricLookups.WithLabelValues("error").Inc()
+		level.Warn(util.Logger).Log("msg", "failed to look up pod for span enrichment", "pod", key, "err", err)
+		return nil, errorTTL
+	}
+	if p == nil {
+		metricLookups.WithLabelValues("not_found").Inc()
+		return nil, e.cfg.CacheTTL
+	}
+
+	metricLookups.WithLabelValues("found").Inc()
+	return e.podAttributes(p), e.cfg.CacheTTL
+}
+
+func (e *Enricher) fetchPod(key string) (*pod, error) {
+	kind, id := splitKey(key)
+
+	u := *e.apiURL
+	if kind == "pod" {
+		namespace, name := splitKey(id)
+		u.Path = strings.TrimSuffix(u.Path, "/") + "/api/v1/namespaces/" + url.PathEscape(namespace) + "/pods/" + url.PathEscape(name)
+
+		p := &pod{}
+		found, err := e.get(u, p)
+		if err != nil || !found {
+			return nil, err
+		}
+		return p, nil
+	}
+
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/api/v1/pods"
+	u.RawQuery = url.Values{"fieldSelector": []string{"status.podIP=" + id}}.Encode()
+
+	list := &podList{}
+	if _, err := e.get(u, list); err != nil {
+		return nil, err
+	}
+	for i := range list.Items {
+		p := &list.Items[i]
+		// host network pods share the ip of the node and finished pods may share it with a running one
+		if p.Spec.HostNetwork || p.Status.Phase == "Succeeded" || p.Status.Phase == "Failed" {
+			continue
+		}
+		return p, nil
+	}
+	return nil, nil
+}
+
+// get decodes the json response into v.  found is false on a 404.
+func (e *Enricher) get(u url.URL, v interface{}) (bool, error) {
+	req, err := http.NewRequest(http.MethodGet, u.String(), nil)
+	if err != nil {
+		return false, err
+	}
+	if e.cfg.TokenFile != "" {
+		token, err := ioutil.ReadFile(e.cfg.TokenFile)
+		if err != nil {
+			return false, fmt.Errorf("failed to read kubernetes token: %w", err)
+		}
+		req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+	}
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return true, json.NewDecoder(resp.Body).Decode(v)
+	case http.StatusNotFound:
+		_, _ = io.Copy(ioutil.Discard, resp.Body)
+		return false, nil
+	default:
+		_, _ = io.Copy(ioutil.Discard, resp.Body)
+		return false, fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+}
+
+// podAttributes returns the resource attributes of the pod, following the OpenTelemetry semantic conventions.
+func (e *Enricher) podAttributes(p *pod) []*v1_common.KeyValue {
+	attrs := []*v1_common.KeyValue{
+		stringAttribute(attributePodName, p.Metadata.Name),
+		stringAttribute(attributeNamespaceName, p.Metadata.Namespace),
+	}
+	if p.Spec.NodeName != "" {
+		attrs = append(attrs, stringAttribute(attributeNodeName, p.Spec.NodeName))
+	}
+
+	for _, owner := range p.Metadata.OwnerReferences {
+		if !owner.Controller || owner.Kind == "" {
+			continue
+		}
+		attrs = append(attrs, stringAttribute("k8s."+strings.ToLower(owner.Kind)+".name", owner.Name))
+
+		// deployments own their pods through a replica set named after the deployment and the pod template hash
+		hash := p.Metadata.Labels[podTemplateHashLabel]
+		if owner.Kind == "ReplicaSet" && hash != "" && strings.HasSuffix(owner.Name, "-"+hash) {
+			attrs = append(attrs, stringAttribute(attributeDeploymentName, strings.TrimSuffix(owner.Name, "-"+hash)))
+		}
+	}
+
+	for _, label := range e.cfg.PodLabels {
+		if v, ok := p.Metadata.Labels[label]; ok {
+			attrs = append(attrs, stringAttribute(attributePodLabelPrefix+label, v))
+		}
+	}
+
+	return attrs
+}
+
+// lookupKey identifies the pod that sent a batch, preferring its name over its ip.  It's empty if the batch has
+// neither.
+func lookupKey(attrs []*v1_common.KeyValue) string {
+	name := stringValue(attrs, attributePodName)
+	namespace := stringValue(attrs, attributeNamespaceName)
+	if name != "" && namespace != "" {
+		return "pod/" + namespace + "/" + name
+	}
+
+	if ip := stringValue(attrs, attributePodIP); ip != "" {
+		return "ip/" + ip
+	}
+	// spans sent directly by a pod come from its ip
+	if ip := stringValue(attrs, receiver.AttributeClientIP); ip != "" {
+		return "ip/" + ip
+	}
+	return ""
+}
+
+func splitKey(key string) (string, string) {
+	parts := strings.SplitN(key, "/", 2)
+	if len(parts) < 2 {
+		return parts[0], ""
+	}
+	return parts[0], parts[1]
+}
+
+func stringValue(attrs []*v1_common.KeyValue, key string) string {
+	for _, attr := range attrs {
+		if attr.Key == key && attr.Value != nil {
+			return attr.Value.GetStringValue()
+		}
+	}
+	return ""
+}
+
+func hasAttribute(attrs []*v1_common.KeyValue, key string) bool {
+	for _, attr := range attrs {
+		if attr.Key == key {
+			return true
+		}
+	}
+	return false
+}
+
+func stringAttribute(key string, value string) *v1_common.KeyValue {
+	return &v1_common.KeyValue{
+		Key:   key,
+		Value: &v1_common.AnyValue{Value: &v1_common.AnyValue_StringValue{StringValue: value}},
+	}
+}
+
+func isDone(c chan struct{}) bool {
+	select {
+	case <-c:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,177 @@
+package enrichment
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"sync"
+	"testing"
+	"time"
+
+	v1_common "github.com/open-telemetry/opentelemetry-proto/gen/go/common/v1"
+	v1_resource "github.com/open-telemetry/opentelemetry-proto/gen/go/resource/v1"
+	v1 "github.com/open-telemetry/opentelemetry-proto/gen/go/trace/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/tempo/modules/distributor/receiver"
+)
+
+const testPod = `{
+	"metadata": {
+		"name": "api-7d4b9c-x2x9z",
+		"namespace": "shop",
+		"labels": {"app": "api", "team": "payments", "pod-template-hash": "7d4b9c"},
+		"ownerReferences": [{"kind": "ReplicaSet", "name": "api-7d4b9c", "controller": true}]
+	},
+	"spec": {"nodeName": "node-1"},
+	"status": {"phase": "Running"}
+}`
+
+// fakeAPIServer serves testPod by name and by ip and counts the requests it gets.
+type fakeAPIServer struct {
+	mtx      sync.Mutex
+	requests int
+	auth     []string
+}
+
+func (s *fakeAPIServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mtx.Lock()
+	s.requests++
+	s.auth = append(s.auth, r.Header.Get("Authorization"))
+	s.mtx.Unlock()
+
+	switch {
+	case r.URL.Path == "/api/v1/namespaces/shop/pods/api-7d4b9c-x2x9z":
+		_, _ = w.Write([]byte(testPod))
+	case r.URL.Path == "/api/v1/pods" && r.URL.Query().Get("fieldSelector") == "status.podIP=10.0.0.1":
+		_, _ = w.Write([]byte(`{"items": [{"spec": {"hostNetwork": true}}, ` + testPod + `]}`))
+	case r.URL.Path == "/api/v1/pods":
+		_, _ = w.Write([]byte(`{"items": []}`))
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (s *fakeAPIServer) requestCount() int {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	return s.requests
+}
+
+func newTestEnricher(t *testing.T, url string, tokenFile string) *Enricher {
+	e, err := New(Config{
+		Enabled:   true,
+		APIURL:    url,
+		TokenFile: tokenFile,
+		PodLabels: []string{"team", "missing"},
+		CacheTTL:  time.Minute,
+		CacheSize: 10,
+		Timeout:   time.Second,
+	})
+	require.NoError(t, err)
+	return e
+}
+
+func batchWithAttributes(kvs ...string) *v1.ResourceSpans {
+	resource := &v1_resource.Resource{}
+	for i := 0; i < len(kvs); i += 2 {
+		resource.Attributes = append(resource.Attributes, stringAttribute(kvs[i], kvs[i+1]))
+	}
+	return &v1.ResourceSpans{Resource: resource}
+}
+
+func attributeMap(attrs []*v1_common.KeyValue) map[string]string {
+	m := map[string]string{}
+	for _, attr := range attrs {
+		m[attr.Key] = attr.Value.GetStringValue()
+	}
+	return m
+}
+
+func TestEnrichByName(t *testing.T) {
+	tempDir, err := ioutil.TempDir("/tmp", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(tempDir)
+	tokenFile := path.Join(tempDir, "token")
+	require.NoError(t, ioutil.WriteFile(tokenFile, []byte("secret\n"), 0644))
+
+	api := &fakeAPIServer{}
+	srv := httptest.NewServer(api)
+	defer srv.Close()
+	e := newTestEnricher(t, srv.URL, tokenFile)
+
+	batch := batchWithAttributes(attributePodName, "api-7d4b9c-x2x9z", attributeNamespaceName, "shop", attributeNodeName, "set-by-sdk")
+	e.Enrich(context.Background(), batch)
+
+	assert.Equal(t, map[string]string{
+		attributePodName:        "api-7d4b9c-x2x9z",
+		attributeNamespaceName:  "shop",
+		attributeNodeName:       "set-by-sdk",
+		"k8s.replicaset.name":   "api-7d4b9c",
+		attributeDeploymentName: "api",
+		"k8s.pod.label.team":    "payments",
+	}, attributeMap(batch.Resource.Attributes))
+	assert.Equal(t, []string{"Bearer secret"}, api.auth)
+
+	// the pod is cached
+	batch = batchWithAttributes(attributePodName, "api-7d4b9c-x2x9z", attributeNamespaceName, "shop")
+	e.Enrich(context.Background(), batch)
+	assert.Equal(t, "node-1", attributeMap(batch.Resource.Attributes)[attributeNodeName])
+	assert.Equal(t, 1, api.requestCount())
+
+	// until it expires
+	e.now = func() time.Time { return time.Now().Add(2 * time.Minute) }
+	e.Enrich(context.Background(), batchWithAttributes(attributePodName, "api-7d4b9c-x2x9z", attributeNamespaceName, "shop"))
+	assert.Equal(t, 2, api.requestCount())
+}
+
+func TestEnrichByIP(t *testing.T) {
+	api := &fakeAPIServer{}
+	srv := httptest.NewServer(api)
+	defer srv.Close()
+	e := newTestEnricher(t, srv.URL, "")
+
+	// host network pods are skipped
+	batch := batchWithAttributes(receiver.AttributeClientIP, "10.0.0.1")
+	e.Enrich(context.Background(), batch)
+	assert.Equal(t, "api-7d4b9c-x2x9z", attributeMap(batch.Resource.Attributes)[attributePodName])
+	assert.Equal(t, []string{""}, api.auth)
+
+	// unknown pods are left as is and cached
+	for i := 0; i < 2; i++ {
+		batch = batchWithAttributes(attributePodIP, "10.0.0.2")
+		e.Enrich(context.Background(), batch)
+		assert.Len(t, batch.Resource.Attributes, 1)
+	}
+	assert.Equal(t, 2, api.requestCount())
+
+	// batches that don't identify a pod aren't looked up
+	batch = batchWithAttributes("service.name", "api")
+	e.Enrich(context.Background(), batch)
+	assert.Len(t, batch.Resource.Attributes, 1)
+	assert.Equal(t, 2, api.requestCount())
+}
+
+func TestEnrichFailure(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "forbidden", http.StatusForbidden)
+	}))
+	defer srv.Close()
+	e := newTestEnricher(t, srv.URL, "")
+
+	batch := batchWithAttributes(attributePodIP, "10.0.0.1")
+	e.Enrich(context.Background(), batch)
+	assert.Len(t, batch.Resource.Attributes, 1)
+}
+
+func TestNewValidates(t *testing.T) {
+	_, err := New(Config{APIURL: "not a url"})
+	assert.Error(t, err)
+
+	_, err = New(Config{APIURL: "https://kubernetes.default.svc", CAFile: "/does/not/exist"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,229 @@
+package distributor
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"sync"
+	"time"
+
+	cortex_util "github.com/cortexproject/cortex/pkg/util"
+	"github.com/go-kit/kit/log/level"
+	opentelemetry_proto_trace_v1 "github.com/open-telemetry/opentelemetry-proto/gen/go/trace/v1"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/weaveworks/common/user"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/status"
+
+	"github.com/grafana/tempo/pkg/tempopb"
+)
+
+// otlpExportMethod is the OTLP trace export rpc.  tempopb.Trace is wire compatible with its request and
+// tempopb.PushResponse with its empty response.
+const otlpExportMethod = "/opentelemetry.proto.collector.trace.v1.TraceService/Export"
+
+var (
+	metricForwardedBatches = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "tempo",
+		Name:      "distributor_forwarded_batches_total",
+		Help:      "The total number of batches forwarded to each downstream OTLP endpoint by result.",
+	}, []string{"forwarder", "result"})
+)
+
+// ForwarderConfig is a downstream OTLP endpoint accepted spans can be forwarded to, e.g. another tracing backend
+// during a migration.  Tenants opt in with the forwarders override.
+type ForwarderConfig struct {
+	Name       string        `yaml:"name"`        // referenced by the forwarders override
+	Endpoint   string        `yaml:"endpoint"`    // host:port of an OTLP gRPC receiver
+	Insecure   bool          `yaml:"insecure"`    // connect without TLS
+	QueueSize  int           `yaml:"queue_size"`  // batches waiting to be sent.  Batches are dropped once it's full
+	Workers    int           `yaml:"workers"`     // batches sent concurrently
+	MaxRetries int           `yaml:"max_retries"` // retries of a batch failing with a retryable error
+	Backoff    time.Duration `yaml:"backoff"`     // wait before the first retry, doubled for every following one
+	Timeout    time.Duration `yaml:"timeout"`     // bounds each attempt
+}
+
+func (cfg *ForwarderConfig) applyDefaults() {
+	if cfg.QueueSize <= 0 {
+		cfg.QueueSize = 1000
+	}
+	if cfg.Workers <= 0 {
+		cfg.Workers = 2
+	}
+	if cfg.MaxRetries < 0 {
+		cfg.MaxRetries = 0
+	}
+	if cfg.Backoff <= 0 {
+		cfg.Backoff = 100 * time.Millisecond
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 5 * time.Second
+	}
+}
+
+type forwardRequest struct {
+	userID string
+	batch  *opentelemetry_proto_trace_v1.ResourceSpans
+}
+
+// forwarder sends batches to a downstream OTLP endpoint in the background.  Forwarding never slows down or fails a
+// push: batches that don't fit in the queue or keep failing are dropped and counted.
+type forwarder struct {
+	cfg  ForwarderConfig
+	conn *grpc.ClientConn
+
+	mtx     sync.RWMutex
+	stopped bool
+	queue   chan forwardRequest
+	quit    chan struct{}
+	wg      sync.WaitGroup
+}
+
+func newForwarder(cfg ForwarderConfig) (*forwarder, error) {
+	if cfg.Name == "" {
+		return nil, fmt.Errorf("forwarder to %s has no name", cfg.Endpoint)
+	}
+	if cfg.Endpoint == "" {
+		return nil, fmt.Errorf("forwarder %s has no endpoint", cfg.Name)
+	}
+	cfg.applyDefaults()
+
+	creds := grpc.WithTransportCredentials(credentials.NewTLS(&tls.Config{}))
+	if cfg.Insecure {
+		creds = grpc.WithInsecure()
+	}
+	// dialing doesn't block, so an unavailable endpoint only fails the batches sent to it
+	conn, err := grpc.Dial(cfg.Endpoint, creds)
+	if err != nil {
+		return nil, fmt.Errorf("failed to dial forwarder %s: %w", cfg.Name, err)
+	}
+
+	f := &forwarder{
+		cfg:   cfg,
+		conn:  conn,
+		queue: make(chan forwardRequest, cfg.QueueSize),
+		quit:  make(chan struct{}),
+	}
+	for i := 0; i < cfg.Workers; i++ {
+		f.wg.Add(1)
+		go f.run()
+	}
+
+	return f, nil
+}
+
+// newForwarders makes a forwarder for each config by name.
+func newForwarders(cfgs []ForwarderConfig) (map[string]*forwarder, error) {
+	forwarders := map[string]*forwarder{}
+	for _, cfg := range cfgs {
+		if _, ok := forwarders[cfg.Name]; ok {
+			stopForwarders(forwarders)
+			return nil, fmt.Errorf("forwarder %s is configured more than once", cfg.Name)
+		}
+		f, err := newForwarder(cfg)
+		if err != nil {
+			stopForwarders(forwarders)
+			return nil, err
+		}
+		forwarders[cfg.Name] = f
+	}
+	return forwarders, nil
+}
+
+func stopForwarders(forwarders map[string]*forwarder) {
+	for _, f := range forwarders {
+		f.stop()
+	}
+}
+
+// forward queues the batch without blocking.  The batch must not be modified afterwards.
+func (f *forwarder) forward(userID string, batch *opentelemetry_proto_trace_v1.ResourceSpans) {
+	f.mtx.RLock()
+	defer f.mtx.RUnlock()
+
+	if f.stopped {
+		metricForwardedBatches.WithLabelValues(f.cfg.Name, "dropped").Inc()
+		return
+	}
+
+	select {
+	case f.queue <- forwardRequest{userID: userID, batch: batch}:
+	default:
+		metricForwardedBatches.WithLabelValues(f.cfg.Name, "dropped").Inc()
+	}
+}
+
+func (f *forwarder) run() {
+	defer f.wg.Done()
+
+	for req := range f.queue {
+		if err := f.send(req); err != nil {
+			metricForwardedBatches.WithLabelValues(f.cfg.Name, "failed").Inc()
+			level.Warn(cortex_util.Logger).Log("msg", "failed to forward batch", "forwarder", f.cfg.Name, "tenant", req.userID, "err", err)
+			continue
+		}
+		metricForwardedBatches.WithLabelValues(f.cfg.Name, "success").Inc()
+	}
+}
+
+// send exports the batch, retrying with backoff while the error is retryable.  Retries are abandoned once the
+// forwarder is stopping.
+func (f *forwarder) send(req forwardRequest) error {
+	backoff := f.cfg.Backoff
+	for attempt := 0; ; attempt++ {
+		err := f.export(req)
+		if err == nil || attempt >= f.cfg.MaxRetries || !retryable(err) {
+			return err
+		}
+
+		select {
+		case <-time.After(backoff):
+		case <-f.quit:
+			return err
+		}
+		backoff *= 2
+	}
+}
+
+func (f *forwarder) export(req forwardRequest) error {
+	ctx, cancel := context.WithTimeout(context.Background(), f.cfg.Timeout)
+	defer cancel()
+
+	// downstream Tempo and other multi tenant backends take the tenant from the org id header
+	ctx, err := user.InjectIntoGRPCRequest(user.InjectOrgID(ctx, req.userID))
+	if err != nil {
+		return err
+	}
+
+	return f.conn.Invoke(ctx, otlpExportMethod, &tempopb.Trace{
+		Batches: []*opentelemetry_proto_trace_v1.ResourceSpans{req.batch},
+	}, &tempopb.PushResponse{})
+}
+
+func retryable(err error) bool {
+	switch status.Code(err) {
+	case codes.Unavailable, codes.ResourceExhausted, codes.DeadlineExceeded, codes.Aborted:
+		return true
+	default:
+		return false
+	}
+}
+
+// stop sends the batches already queued and closes the connection.
+func (f *forwarder) stop() {
+	f.mtx.Lock()
+	if f.stopped {
+		f.mtx.Unlock()
+		return
+	}
+	f.stopped = true
+	close(f.queue)
+	f.mtx.Unlock()
+
+	close(f.quit)
+	f.wg.Wait()
+	_ = f.conn.Close()
+}
@@ -0,0 +1,149 @@
+package distributor
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/weaveworks/common/user"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/grafana/tempo/pkg/tempopb"
+)
+
+// fakeOTLPReceiver records the exports it receives and fails the first failures of them.
+type fakeOTLPReceiver struct {
+	mtx      sync.Mutex
+	failures int
+	code     codes.Code
+	attempts int
+	tenants  []string
+	traces   []*tempopb.Trace
+}
+
+func (r *fakeOTLPReceiver) export(ctx context.Context, trace *tempopb.Trace) error {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	r.attempts++
+	if r.attempts <= r.failures {
+		return status.Error(r.code, "failed")
+	}
+
+	tenant, _, _ := user.ExtractFromGRPCRequest(ctx)
+	r.tenants = append(r.tenants, tenant)
+	r.traces = append(r.traces, trace)
+	return nil
+}
+
+func (r *fakeOTLPReceiver) received() ([]string, int, int) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	return r.tenants, len(r.traces), r.attempts
+}
+
+func startFakeOTLPReceiver(t *testing.T, r *fakeOTLPReceiver) string {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	srv := grpc.NewServer()
+	srv.RegisterService(&grpc.ServiceDesc{
+		ServiceName: "opentelemetry.proto.collector.trace.v1.TraceService",
+		HandlerType: (*interface{})(nil),
+		Methods: []grpc.MethodDesc{{
+			MethodName: "Export",
+			Handler: func(_ interface{}, ctx context.Context, dec func(interface{}) error, _ grpc.UnaryServerInterceptor) (interface{}, error) {
+				trace := &tempopb.Trace{}
+				if err := dec(trace); err != nil {
+					return nil, err
+				}
+				return &tempopb.PushResponse{}, r.export(ctx, trace)
+			},
+		}},
+	}, r)
+	go func() {
+		_ = srv.Serve(lis)
+	}()
+	t.Cleanup(srv.Stop)
+
+	return lis.Addr().String()
+}
+
+func TestForwarderForwards(t *testing.T) {
+	r := &fakeOTLPReceiver{failures: 2, code: codes.Unavailable}
+	f, err := newForwarder(ForwarderConfig{
+		Name:       "test",
+		Endpoint:   startFakeOTLPReceiver(t, r),
+		Insecure:   true,
+		MaxRetries: 2,
+		Backoff:    time.Millisecond,
+	})
+	require.NoError(t, err)
+
+	f.forward("tenant", spansBatch("svc", 2))
+
+	// the batch is sent once the retryable failures are over, with the tenant as org id
+	assert.Eventually(t, func() bool {
+		_, traces, _ := r.received()
+		return traces == 1
+	}, time.Second, time.Millisecond)
+	f.stop()
+
+	tenants, traces, attempts := r.received()
+	assert.Equal(t, []string{"tenant"}, tenants)
+	assert.Equal(t, 1, traces)
+	assert.Equal(t, 3, attempts)
+
+	// forwarding after stopping is dropped
+	f.forward("tenant", spansBatch("svc", 2))
+	_, traces, _ = r.received()
+	assert.Equal(t, 1, traces)
+}
+
+func TestForwarderGivesUp(t *testing.T) {
+	r := &fakeOTLPReceiver{failures: 10, code: codes.InvalidArgument}
+	f, err := newForwarder(ForwarderConfig{
+		Name:       "test",
+		Endpoint:   startFakeOTLPReceiver(t, r),
+		Insecure:   true,
+		MaxRetries: 5,
+		Backoff:    time.Millisecond,
+	})
+	require.NoError(t, err)
+
+	f.forward("tenant", spansBatch("svc", 2))
+	f.stop()
+
+	// errors that aren't retryable aren't retried
+	_, traces, attempts := r.received()
+	assert.Equal(t, 0, traces)
+	assert.Equal(t, 1, attempts)
+}
+
+func TestNewForwardersValidates(t *testing.T) {
+	_, err := newForwarders([]ForwarderConfig{{Endpoint: "localhost:4317"}})
+	assert.Error(t, err)
+
+	_, err = newForwarders([]ForwarderConfig{{Name: "a"}})
+	assert.Error(t, err)
+
+	_, err = newForwarders([]ForwarderConfig{
+		{Name: "a", Endpoint: "localhost:4317", Insecure: true},
+		{Name: "a", Endpoint: "localhost:4318", Insecure: true},
+	})
+	assert.Error(t, err)
+
+	forwarders, err := newForwarders([]ForwarderConfig{
+		{Name: "a", Endpoint: "localhost:4317", Insecure: true},
+		{Name: "b", Endpoint: "localhost:4318"},
+	})
+	require.NoError(t, err)
+	assert.Len(t, forwarders, 2)
+	stopForwarders(forwarders)
+}
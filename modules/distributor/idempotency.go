@@ -0,0 +1,155 @@
+package distributor
+
+import (
+	"container/list"
+	"flag"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/grafana/tempo/pkg/tenantmetrics"
+	"github.com/grafana/tempo/pkg/util"
+)
+
+var (
+	metricDuplicateSpans = tenantmetrics.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "tempo",
+		Name:      "distributor_duplicate_spans_total",
+		Help:      "The total number of spans dropped because a push with the same idempotency key was already accepted.",
+	}, []string{"tenant"})
+)
+
+// IdempotencyConfig controls deduplication of pushes carrying an Idempotency-Key header.  A client that retries a push
+// after a network timeout doesn't know whether the first attempt was accepted.  With a key the retry is acknowledged
+// without ingesting, and counting, the spans again.
+type IdempotencyConfig struct {
+	KeysPerTenant int           `yaml:"keys_per_tenant"` // accepted keys remembered per tenant.  0 disables deduplication
+	Window        time.Duration `yaml:"window"`          // how long an accepted key is remembered
+}
+
+// RegisterFlags registers the flags.
+func (cfg *IdempotencyConfig) RegisterFlags(prefix string, f *flag.FlagSet) {
+	f.IntVar(&cfg.KeysPerTenant, util.PrefixConfig(prefix, "keys-per-tenant"), 0, "Number of accepted idempotency keys remembered per tenant. Pushes repeating a remembered key are acknowledged without being ingested. 0 disables deduplication.")
+	f.DurationVar(&cfg.Window, util.PrefixConfig(prefix, "window"), 10*time.Minute, "How long an accepted idempotency key is remembered.")
+}
+
+// idempotencyKeys is a bounded LRU per tenant of the keys of accepted pushes and when they were accepted, and of the
+// keys of pushes in progress.  A nil *idempotencyKeys is valid and remembers nothing.
+type idempotencyKeys struct {
+	cfg IdempotencyConfig
+
+	mtx     sync.Mutex
+	tenants map[string]*tenantIdempotencyKeys
+}
+
+type tenantIdempotencyKeys struct {
+	ll    *list.List
+	items map[string]*list.Element
+}
+
+type acceptedKey struct {
+	key      string
+	accepted time.Time
+	pending  bool // a push with the key is in progress
+}
+
+// keyState is what reserve found for a key.
+type keyState int
+
+const (
+	keyReserved keyState = iota // the push may go ahead and must commit or release the key
+	keyAccepted                 // a push with the key was accepted within the window
+	keyPending                  // a push with the key is in progress
+)
+
+func newIdempotencyKeys(cfg IdempotencyConfig) *idempotencyKeys {
+	if cfg.KeysPerTenant <= 0 {
+		return nil
+	}
+
+	return &idempotencyKeys{
+		cfg:     cfg,
+		tenants: map[string]*tenantIdempotencyKeys{},
+	}
+}
+
+// reserve marks the key of a push of the tenant as pending, unless a push with the key was accepted within the window
+// or is in progress.  Checking and marking the key at once keeps concurrent retries from both being ingested.  Pushes
+// without a key, or with deduplication disabled, are always reserved.
+func (k *idempotencyKeys) reserve(userID string, key string, now time.Time) keyState {
+	if k == nil || key == "" {
+		return keyReserved
+	}
+
+	k.mtx.Lock()
+	defer k.mtx.Unlock()
+
+	keys, ok := k.tenants[userID]
+	if !ok {
+		keys = &tenantIdempotencyKeys{
+			ll:    list.New(),
+			items: map[string]*list.Element{},
+		}
+		k.tenants[userID] = keys
+	}
+
+	if e, ok := keys.items[key]; ok {
+		accepted := e.Value.(*acceptedKey)
+		switch {
+		case accepted.pending:
+			return keyPending
+		case now.Sub(accepted.accepted) <= k.cfg.Window:
+			keys.ll.MoveToFront(e)
+			return keyAccepted
+		}
+		accepted.pending = true
+		keys.ll.MoveToFront(e)
+		return keyReserved
+	}
+
+	keys.items[key] = keys.ll.PushFront(&acceptedKey{key: key, pending: true})
+	if keys.ll.Len() > k.cfg.KeysPerTenant {
+		oldest := keys.ll.Back()
+		keys.ll.Remove(oldest)
+		delete(keys.items, oldest.Value.(*acceptedKey).key)
+	}
+	return keyReserved
+}
+
+// commit remembers that the push holding the key was accepted.  Keys are only committed once the spans have been
+// ingested.
+func (k *idempotencyKeys) commit(userID string, key string, now time.Time) {
+	if k == nil || key == "" {
+		return
+	}
+
+	k.mtx.Lock()
+	defer k.mtx.Unlock()
+
+	// a pending key evicted by the keys of other pushes isn't remembered
+	if keys, ok := k.tenants[userID]; ok {
+		if e, ok := keys.items[key]; ok {
+			accepted := e.Value.(*acceptedKey)
+			accepted.pending = false
+			accepted.accepted = now
+		}
+	}
+}
+
+// release forgets the key of a push that wasn't accepted so it can be retried.
+func (k *idempotencyKeys) release(userID string, key string) {
+	if k == nil || key == "" {
+		return
+	}
+
+	k.mtx.Lock()
+	defer k.mtx.Unlock()
+
+	if keys, ok := k.tenants[userID]; ok {
+		if e, ok := keys.items[key]; ok && e.Value.(*acceptedKey).pending {
+			keys.ll.Remove(e)
+			delete(keys.items, key)
+		}
+	}
+}
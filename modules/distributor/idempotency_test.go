@@ -0,0 +1,66 @@
+package distributor
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIdempotencyKeys(t *testing.T) {
+	keys := newIdempotencyKeys(IdempotencyConfig{KeysPerTenant: 2, Window: time.Minute})
+	now := time.Now()
+
+	accept := func(userID, key string) {
+		assert.Equal(t, keyReserved, keys.reserve(userID, key, now))
+		keys.commit(userID, key, now)
+	}
+
+	accept("a", "1")
+	assert.Equal(t, keyAccepted, keys.reserve("a", "1", now))
+	assert.Equal(t, keyReserved, keys.reserve("b", "1", now), "keys are per tenant")
+	keys.release("b", "1")
+
+	// the least recently seen key is evicted
+	accept("a", "2")
+	assert.Equal(t, keyAccepted, keys.reserve("a", "1", now))
+	accept("a", "3")
+	assert.Equal(t, keyAccepted, keys.reserve("a", "1", now))
+	assert.Equal(t, keyAccepted, keys.reserve("a", "3", now))
+	assert.Equal(t, keyReserved, keys.reserve("a", "2", now))
+	keys.release("a", "2")
+
+	// keys are forgotten after the window
+	assert.Equal(t, keyReserved, keys.reserve("a", "1", now.Add(2*time.Minute)))
+	keys.release("a", "1")
+
+	// no key is never a repeat
+	assert.Equal(t, keyReserved, keys.reserve("a", "", now))
+	keys.commit("a", "", now)
+	assert.Equal(t, keyReserved, keys.reserve("a", "", now))
+
+	var disabled *idempotencyKeys
+	assert.Equal(t, keyReserved, disabled.reserve("a", "1", now))
+	disabled.commit("a", "1", now)
+	assert.Equal(t, keyReserved, disabled.reserve("a", "1", now))
+	assert.Nil(t, newIdempotencyKeys(IdempotencyConfig{}))
+}
+
+func TestIdempotencyKeysPending(t *testing.T) {
+	keys := newIdempotencyKeys(IdempotencyConfig{KeysPerTenant: 10, Window: time.Minute})
+	now := time.Now()
+
+	// a concurrent retry isn't ingested while the first push is in progress
+	assert.Equal(t, keyReserved, keys.reserve("a", "1", now))
+	assert.Equal(t, keyPending, keys.reserve("a", "1", now))
+
+	// a failed push releases the key for retries
+	keys.release("a", "1")
+	assert.Equal(t, keyReserved, keys.reserve("a", "1", now))
+	keys.commit("a", "1", now)
+	assert.Equal(t, keyAccepted, keys.reserve("a", "1", now))
+
+	// accepted keys aren't released
+	keys.release("a", "1")
+	assert.Equal(t, keyAccepted, keys.reserve("a", "1", now))
+}
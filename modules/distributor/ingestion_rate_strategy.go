@@ -1,6 +1,8 @@
 package distributor
 
 import (
+	"strings"
+
 	"github.com/cortexproject/cortex/pkg/util/limiter"
 	"github.com/grafana/tempo/modules/overrides"
 )
@@ -55,3 +57,55 @@ func (s *globalStrategy) Burst(userID string) int {
 	// to keep it easier to understand for users / operators.
 	return s.limits.IngestionMaxBatchSize(userID)
 }
+
+// receiverLimitKeySeparator separates the tenant from the receiver limits key in the keys of the receiver rate limiter.
+// Tenant ids can't hold it.
+const receiverLimitKeySeparator = "\x00"
+
+func receiverLimitKey(userID string, key string) string {
+	return userID + receiverLimitKeySeparator + key
+}
+
+// receiverStrategy limits the spans of a tenant received through a receiver.  Its keys are built by receiverLimitKey.
+// Like the tenant limit, a global limit is shared evenly across the distributors.
+type receiverStrategy struct {
+	limits *overrides.Overrides
+	ring   ReadLifecycler // nil for the local strategy
+}
+
+func newReceiverRateStrategy(limits *overrides.Overrides, ring ReadLifecycler) limiter.RateLimiterStrategy {
+	return &receiverStrategy{
+		limits: limits,
+		ring:   ring,
+	}
+}
+
+func (s *receiverStrategy) Limit(key string) float64 {
+	userID, receiverKey := splitReceiverLimitKey(key)
+	limit := float64(s.limits.ReceiverLimit(userID, receiverKey).IngestionRateSpans)
+	if s.ring == nil {
+		return limit
+	}
+
+	numDistributors := s.ring.HealthyInstancesCount()
+	if numDistributors == 0 {
+		return limit
+	}
+	return limit / float64(numDistributors)
+}
+
+func (s *receiverStrategy) Burst(key string) int {
+	userID, receiverKey := splitReceiverLimitKey(key)
+	if burst := s.limits.ReceiverLimit(userID, receiverKey).IngestionMaxBatchSize; burst > 0 {
+		return burst
+	}
+	return s.limits.IngestionMaxBatchSize(userID)
+}
+
+func splitReceiverLimitKey(key string) (string, string) {
+	parts := strings.SplitN(key, receiverLimitKeySeparator, 2)
+	if len(parts) != 2 {
+		return key, ""
+	}
+	return parts[0], parts[1]
+}
@@ -70,6 +70,29 @@ func TestIngestionRateStrategy(t *testing.T) {
 	}
 }
 
+func TestReceiverRateStrategy(t *testing.T) {
+	o, err := overrides.NewOverrides(overrides.Limits{
+		IngestionMaxBatchSize: 2,
+		ReceiverLimits: map[string]overrides.ReceiverLimit{
+			"zipkin":    {IngestionRateSpans: 10},
+			"otlp/grpc": {IngestionRateSpans: 5, IngestionMaxBatchSize: 3},
+		},
+	})
+	require.NoError(t, err)
+
+	local := newReceiverRateStrategy(o, nil)
+	assert.Equal(t, 10.0, local.Limit(receiverLimitKey("test", "zipkin")))
+	assert.Equal(t, 2, local.Burst(receiverLimitKey("test", "zipkin")))
+	assert.Equal(t, 5.0, local.Limit(receiverLimitKey("test", "otlp/grpc")))
+	assert.Equal(t, 3, local.Burst(receiverLimitKey("test", "otlp/grpc")))
+
+	ring := newReadLifecyclerMock()
+	ring.On("HealthyInstancesCount").Return(2)
+	global := newReceiverRateStrategy(o, ring)
+	assert.Equal(t, 5.0, global.Limit(receiverLimitKey("test", "zipkin")))
+	assert.Equal(t, 2, global.Burst(receiverLimitKey("test", "zipkin")))
+}
+
 type readLifecyclerMock struct {
 	mock.Mock
 }
@@ -0,0 +1,129 @@
+package distributor
+
+import (
+	"flag"
+	"sort"
+	"time"
+
+	"github.com/cortexproject/cortex/pkg/ring"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	ingester_client "github.com/grafana/tempo/modules/ingester/client"
+	"github.com/grafana/tempo/pkg/util"
+)
+
+// ingesterLoadMaxAge is how long the load advertised by an ingester is trusted.  Ingesters advertise their load in the
+// response to every push, so a load this old belongs to an ingester that isn't being pushed to.
+const ingesterLoadMaxAge = time.Minute
+
+var (
+	metricIngesterReplicasSkipped = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "tempo",
+		Name:      "distributor_ingester_replicas_skipped_total",
+		Help:      "The total number of traces not pushed to a replica because it held many more live traces than the other replicas.",
+	}, []string{"ingester"})
+)
+
+// IngesterLoadBalancingConfig skips the busiest replicas of a trace when pushing, smoothing hot spots caused by an
+// uneven spread of live traces.  Only as many replicas are skipped as the write can lose and still succeed, so skipped
+// traces have fewer copies until they're compacted.  Ingesters advertise their live traces in the response to every
+// push.
+type IngesterLoadBalancingConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// MaxLoadRatio is the multiple of the live traces of the least loaded replica above which a replica is skipped.
+	MaxLoadRatio float64 `yaml:"max_load_ratio"`
+	// MinLiveTraces is the live traces below which a replica is never skipped.
+	MinLiveTraces int `yaml:"min_live_traces"`
+}
+
+// RegisterFlags registers the flags.
+func (cfg *IngesterLoadBalancingConfig) RegisterFlags(prefix string, f *flag.FlagSet) {
+	f.BoolVar(&cfg.Enabled, util.PrefixConfig(prefix, "enabled"), false, "Skip replicas holding many more live traces than the other replicas of a trace, as long as the write still succeeds.")
+	f.Float64Var(&cfg.MaxLoadRatio, util.PrefixConfig(prefix, "max-load-ratio"), 1.5, "Multiple of the live traces of the least loaded replica above which a replica is skipped.")
+	f.IntVar(&cfg.MinLiveTraces, util.PrefixConfig(prefix, "min-live-traces"), 1000, "Live traces below which a replica is never skipped.")
+}
+
+// loadBalancedRing drops the busiest ingesters from the replica sets of writes.
+type loadBalancedRing struct {
+	ring.ReadRing
+
+	cfg   IngesterLoadBalancingConfig
+	loads *ingester_client.IngesterLoads
+}
+
+func newLoadBalancedRing(r ring.ReadRing, cfg IngesterLoadBalancingConfig, loads *ingester_client.IngesterLoads) ring.ReadRing {
+	return &loadBalancedRing{
+		ReadRing: r,
+		cfg:      cfg,
+		loads:    loads,
+	}
+}
+
+// Get implements ring.ReadRing.  Up to MaxErrors replicas above the load ratio are dropped from write replica sets,
+// busiest first.  The errors the write tolerates drop with them.  Replicas of unknown load are always kept.
+func (r *loadBalancedRing) Get(key uint32, op ring.Operation, buf []ring.IngesterDesc) (ring.ReplicationSet, error) {
+	set, err := r.ReadRing.Get(key, op, buf)
+	if err != nil || op != ring.Write || set.MaxErrors <= 0 {
+		return set, err
+	}
+
+	loads := make([]int, len(set.Ingesters))
+	least := -1
+	for i, ing := range set.Ingesters {
+		load, ok := r.loads.Get(ing.Addr)
+		if !ok {
+			loads[i] = -1
+			continue
+		}
+		loads[i] = load
+		if least < 0 || load < least {
+			least = load
+		}
+	}
+	if least < 0 {
+		return set, nil
+	}
+
+	var busy []int
+	for i, load := range loads {
+		if load >= r.cfg.MinLiveTraces && float64(load) > float64(least)*r.cfg.MaxLoadRatio {
+			busy = append(busy, i)
+		}
+	}
+	if len(busy) == 0 {
+		return set, nil
+	}
+	sort.Slice(busy, func(i, j int) bool {
+		return loads[busy[i]] > loads[busy[j]]
+	})
+	if len(busy) > set.MaxErrors {
+		busy = busy[:set.MaxErrors]
+	}
+
+	skip := map[int]struct{}{}
+	for _, i := range busy {
+		skip[i] = struct{}{}
+		metricIngesterReplicasSkipped.WithLabelValues(set.Ingesters[i].Addr).Inc()
+	}
+	kept := make([]ring.IngesterDesc, 0, len(set.Ingesters)-len(skip))
+	for i, ing := range set.Ingesters {
+		if _, ok := skip[i]; !ok {
+			kept = append(kept, ing)
+		}
+	}
+
+	return ring.ReplicationSet{
+		Ingesters: kept,
+		MaxErrors: set.MaxErrors - len(skip),
+	}, nil
+}
+
+// Subring implements ring.ReadRing.
+func (r *loadBalancedRing) Subring(key uint32, n int) (ring.ReadRing, error) {
+	sub, err := r.ReadRing.Subring(key, n)
+	if err != nil {
+		return nil, err
+	}
+	return newLoadBalancedRing(sub, r.cfg, r.loads), nil
+}
@@ -0,0 +1,68 @@
+package distributor
+
+import (
+	"testing"
+
+	"github.com/cortexproject/cortex/pkg/ring"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/metadata"
+
+	ingester_client "github.com/grafana/tempo/modules/ingester/client"
+)
+
+func TestLoadBalancedRing(t *testing.T) {
+	base := mockRing{
+		ingesters: []ring.IngesterDesc{
+			{Addr: "a"},
+			{Addr: "b"},
+			{Addr: "c"},
+		},
+		replicationFactor: 3,
+	}
+	loads := ingester_client.NewIngesterLoads(ingesterLoadMaxAge)
+	r := newLoadBalancedRing(base, IngesterLoadBalancingConfig{
+		MaxLoadRatio:  1.5,
+		MinLiveTraces: 1000,
+	}, loads)
+
+	// unknown loads keep every replica
+	set, err := r.Get(0, ring.Write, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a", "b", "c"}, addrs(set))
+	assert.Equal(t, 1, set.MaxErrors)
+
+	loads.Observe("a", metadata.Pairs("x-tempo-ingester-load", "100"))
+	loads.Observe("b", metadata.Pairs("x-tempo-ingester-load", "5000"))
+	loads.Observe("c", metadata.Pairs("x-tempo-ingester-load", "20000"))
+
+	// only as many replicas as the write can lose are skipped, busiest first
+	set, err = r.Get(0, ring.Write, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a", "b"}, addrs(set))
+	assert.Equal(t, 0, set.MaxErrors)
+
+	set, err = r.Get(0, ring.Read, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a", "b", "c"}, addrs(set))
+
+	sub, err := r.Subring(0, 3)
+	require.NoError(t, err)
+	set, err = sub.Get(0, ring.Write, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a", "b"}, addrs(set))
+
+	// replicas below the minimum live traces are never skipped
+	loads.Observe("c", metadata.Pairs("x-tempo-ingester-load", "900"))
+	set, err = r.Get(0, ring.Write, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a", "c"}, addrs(set))
+}
+
+func addrs(set ring.ReplicationSet) []string {
+	var addrs []string
+	for _, ing := range set.Ingesters {
+		addrs = append(addrs, ing.Addr)
+	}
+	return addrs
+}
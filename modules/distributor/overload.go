@@ -0,0 +1,70 @@
+package distributor
+
+import (
+	"context"
+	"sort"
+
+	"github.com/cortexproject/cortex/pkg/ring"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/grafana/tempo/pkg/tempopb"
+)
+
+var (
+	metricIngesterOverloadRetries = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "tempo",
+		Name:      "distributor_ingester_overload_retries_total",
+		Help:      "The total number of pushes rejected by an overloaded ingester that were retried on another ingester, by result.",
+	}, []string{"result"})
+)
+
+// retryOverloaded pushes a trace rejected by an overloaded ingester to up to IngesterOverloadRetries ingesters that
+// aren't replicas of the trace.  Ingesters reject pushes with ResourceExhausted when they hold their max live traces or
+// their heap is above the push limit.  Queriers ask every ingester for a trace, so its spans are still found.  The
+// ingesters are picked from the trace's token, so the retried pushes of a trace reach the same ingesters.  The
+// original error is returned if every retry fails.
+func (d *Distributor) retryOverloaded(ctx context.Context, r ring.ReadRing, rejected ring.IngesterDesc, key uint32, req *tempopb.PushRequest, err error) error {
+	if d.cfg.IngesterOverloadRetries <= 0 || status.Code(err) != codes.ResourceExhausted {
+		return err
+	}
+
+	replicas, ringErr := r.Get(key, ring.Write, nil)
+	if ringErr != nil {
+		return err
+	}
+	all, ringErr := r.GetAll(ring.Write)
+	if ringErr != nil {
+		return err
+	}
+
+	skip := map[string]struct{}{rejected.Addr: {}}
+	for _, ing := range replicas.Ingesters {
+		skip[ing.Addr] = struct{}{}
+	}
+	candidates := make([]ring.IngesterDesc, 0, len(all.Ingesters))
+	for _, ing := range all.Ingesters {
+		if _, ok := skip[ing.Addr]; !ok {
+			candidates = append(candidates, ing)
+		}
+	}
+	if len(candidates) == 0 {
+		return err
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[i].Addr < candidates[j].Addr
+	})
+
+	start := int(key % uint32(len(candidates)))
+	for n := 0; n < d.cfg.IngesterOverloadRetries && n < len(candidates); n++ {
+		if d.send(ctx, candidates[(start+n)%len(candidates)], req) == nil {
+			metricIngesterOverloadRetries.WithLabelValues("success").Inc()
+			return nil
+		}
+		metricIngesterOverloadRetries.WithLabelValues("failure").Inc()
+	}
+
+	return err
+}
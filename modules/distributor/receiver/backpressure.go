@@ -0,0 +1,69 @@
+package receiver
+
+import (
+	"github.com/gogo/status"
+	prom_client "github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"go.uber.org/atomic"
+	"google.golang.org/grpc/codes"
+)
+
+var (
+	metricInflightSpans = promauto.NewGauge(prom_client.GaugeOpts{
+		Namespace: "tempo",
+		Name:      "distributor_receiver_inflight_spans",
+		Help:      "The number of received spans that haven't been accepted or refused yet.",
+	})
+	metricRefusedSpans = promauto.NewCounterVec(prom_client.CounterOpts{
+		Namespace: "tempo",
+		Name:      "distributor_receiver_spans_refused_total",
+		Help:      "The total number of received spans refused per receiver, transport and grpc status code.",
+	}, []string{"receiver", "transport", "code"})
+	metricSpansPerRequest = promauto.NewHistogramVec(prom_client.HistogramOpts{
+		Namespace: "tempo",
+		Name:      "distributor_receiver_spans_per_request",
+		Help:      "The number of spans in each request per receiver.",
+		Buckets:   prom_client.ExponentialBuckets(1, 4, 8),
+	}, []string{"receiver"})
+)
+
+// BackpressureConfig limits the spans the receivers hold while they are pushed.  Once the limit is reached requests
+// are refused with a retryable error instead of being buffered, so clients back off and retry while the distributor
+// keeps its memory.
+type BackpressureConfig struct {
+	// MaxInflightSpans is the most spans held by the receivers at once.  0 disables the limit.
+	MaxInflightSpans int `yaml:"max_inflight_spans"`
+}
+
+// errBackpressure is Unavailable, which OTLP and Jaeger gRPC clients retry with backoff and the OTLP/HTTP receiver
+// returns as a 503.
+var errBackpressure = status.Error(codes.Unavailable, "too many spans in flight in the distributor receivers, retry later")
+
+// inflightLimiter counts the spans held by the receivers.
+type inflightLimiter struct {
+	max      int64
+	inflight atomic.Int64
+}
+
+func newInflightLimiter(cfg BackpressureConfig) *inflightLimiter {
+	return &inflightLimiter{
+		max: int64(cfg.MaxInflightSpans),
+	}
+}
+
+// acquire reserves spans and returns errBackpressure if that would exceed the limit.  A single request larger than
+// the limit is let through while nothing else is in flight, so it isn't refused forever.
+func (l *inflightLimiter) acquire(spans int) error {
+	n := l.inflight.Add(int64(spans))
+	if l.max > 0 && n > l.max && n != int64(spans) {
+		l.inflight.Sub(int64(spans))
+		return errBackpressure
+	}
+	metricInflightSpans.Add(float64(spans))
+	return nil
+}
+
+func (l *inflightLimiter) release(spans int) {
+	l.inflight.Sub(int64(spans))
+	metricInflightSpans.Sub(float64(spans))
+}
@@ -0,0 +1,103 @@
+package receiver
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/cortexproject/cortex/pkg/util/services"
+	"github.com/gogo/status"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/weaveworks/common/logging"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+
+	"github.com/grafana/tempo/pkg/tempopb"
+)
+
+func TestInflightLimiter(t *testing.T) {
+	l := newInflightLimiter(BackpressureConfig{MaxInflightSpans: 10})
+
+	assert.NoError(t, l.acquire(6))
+	assert.NoError(t, l.acquire(4))
+	assert.Equal(t, errBackpressure, l.acquire(1))
+	l.release(4)
+	assert.NoError(t, l.acquire(3))
+	l.release(3)
+	l.release(6)
+
+	// a request larger than the limit is let through alone
+	assert.NoError(t, l.acquire(20))
+	assert.Equal(t, errBackpressure, l.acquire(1))
+	l.release(20)
+
+	unlimited := newInflightLimiter(BackpressureConfig{})
+	assert.NoError(t, unlimited.acquire(1000))
+}
+
+// blockingPusher holds every push until released.
+type blockingPusher struct {
+	release chan struct{}
+}
+
+func (p *blockingPusher) Push(ctx context.Context, req *tempopb.PushRequest) (*tempopb.PushResponse, error) {
+	<-p.release
+	return &tempopb.PushResponse{}, nil
+}
+
+// TestOTLPBackpressure checks that otlp requests are refused with retryable errors while too many spans are in flight.
+func TestOTLPBackpressure(t *testing.T) {
+	grpcEndpoint := freeAddress(t)
+	httpEndpoint := freeAddress(t)
+	pusher := &blockingPusher{release: make(chan struct{})}
+
+	l := logging.Level{}
+	require.NoError(t, l.Set("error"))
+	shim, err := New(map[string]interface{}{
+		"otlp": map[string]interface{}{
+			"protocols": map[string]interface{}{
+				"grpc": map[string]interface{}{"endpoint": grpcEndpoint},
+				"http": map[string]interface{}{"endpoint": httpEndpoint},
+			},
+		},
+	}, MetadataConfig{}, TenantRoutingConfig{}, BackpressureConfig{MaxInflightSpans: 1}, pusher, false, l)
+	require.NoError(t, err)
+	require.NoError(t, services.StartAndAwaitRunning(context.Background(), shim))
+	defer func() {
+		_ = services.StopAndAwaitTerminated(context.Background(), shim)
+	}()
+
+	conn, err := grpc.Dial(grpcEndpoint, grpc.WithInsecure())
+	require.NoError(t, err)
+	defer conn.Close()
+	export := func() error {
+		return conn.Invoke(context.Background(), "/opentelemetry.proto.collector.trace.v1.TraceService/Export",
+			exportRequest("team-a"), &tempopb.PushResponse{})
+	}
+
+	// the first request holds the only span allowed in flight
+	done := make(chan error)
+	go func() {
+		done <- export()
+	}()
+	require.Eventually(t, func() bool {
+		return shim.(*receiversShim).inflight.inflight.Load() == 1
+	}, 5*time.Second, 10*time.Millisecond)
+
+	err = export()
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+
+	body, err := exportRequest("team-a").Marshal()
+	require.NoError(t, err)
+	resp, err := http.Post("http://"+httpEndpoint+"/v1/trace", "application/x-protobuf", bytes.NewReader(body))
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+
+	close(pusher.release)
+	require.NoError(t, <-done)
+	assert.NoError(t, export())
+}
@@ -0,0 +1,12 @@
+package datadog
+
+import (
+	"go.opentelemetry.io/collector/config/confighttp"
+	"go.opentelemetry.io/collector/config/configmodels"
+)
+
+// Config configures the datadog trace agent intake receiver.
+type Config struct {
+	configmodels.ReceiverSettings `mapstructure:",squash"`
+	confighttp.HTTPServerSettings `mapstructure:",squash"`
+}
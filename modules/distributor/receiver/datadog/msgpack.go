@@ -0,0 +1,205 @@
+package datadog
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// maxMsgpackDepth bounds nesting so a malicious payload can't exhaust the stack.  Traces are nested three deep and
+// span meta and metrics one more.
+const maxMsgpackDepth = 16
+
+var errMsgpackShort = errors.New("msgpack: unexpected end of data")
+
+// decodeMsgpack decodes a single msgpack value.  Maps are returned as map[string]interface{}, arrays as
+// []interface{}, integers as int64 or uint64, floats as float64 and str and bin as string.  Extension types are not
+// used by the trace agent protocol and are rejected.
+func decodeMsgpack(b []byte) (interface{}, error) {
+	d := &msgpackDecoder{b: b}
+	v, err := d.value(0)
+	if err != nil {
+		return nil, err
+	}
+	if d.pos != len(d.b) {
+		return nil, fmt.Errorf("msgpack: %d trailing bytes", len(d.b)-d.pos)
+	}
+	return v, nil
+}
+
+type msgpackDecoder struct {
+	b   []byte
+	pos int
+}
+
+func (d *msgpackDecoder) next(n int) ([]byte, error) {
+	if n < 0 || len(d.b)-d.pos < n {
+		return nil, errMsgpackShort
+	}
+	b := d.b[d.pos : d.pos+n]
+	d.pos += n
+	return b, nil
+}
+
+func (d *msgpackDecoder) uint(n int) (uint64, error) {
+	b, err := d.next(n)
+	if err != nil {
+		return 0, err
+	}
+	switch n {
+	case 1:
+		return uint64(b[0]), nil
+	case 2:
+		return uint64(binary.BigEndian.Uint16(b)), nil
+	case 4:
+		return uint64(binary.BigEndian.Uint32(b)), nil
+	default:
+		return binary.BigEndian.Uint64(b), nil
+	}
+}
+
+func (d *msgpackDecoder) value(depth int) (interface{}, error) {
+	if depth > maxMsgpackDepth {
+		return nil, errors.New("msgpack: maximum depth exceeded")
+	}
+
+	b, err := d.next(1)
+	if err != nil {
+		return nil, err
+	}
+	t := b[0]
+
+	switch {
+	case t <= 0x7f:
+		return int64(t), nil
+	case t >= 0xe0:
+		return int64(int8(t)), nil
+	case t&0xf0 == 0x80:
+		return d.mapOf(int(t&0x0f), depth)
+	case t&0xf0 == 0x90:
+		return d.arrayOf(int(t&0x0f), depth)
+	case t&0xe0 == 0xa0:
+		return d.str(int(t & 0x1f))
+	}
+
+	switch t {
+	case 0xc0:
+		return nil, nil
+	case 0xc2:
+		return false, nil
+	case 0xc3:
+		return true, nil
+	case 0xc4, 0xd9:
+		return d.sizedStr(1)
+	case 0xc5, 0xda:
+		return d.sizedStr(2)
+	case 0xc6, 0xdb:
+		return d.sizedStr(4)
+	case 0xca:
+		v, err := d.uint(4)
+		return float64(math.Float32frombits(uint32(v))), err
+	case 0xcb:
+		v, err := d.uint(8)
+		return math.Float64frombits(v), err
+	case 0xcc:
+		return d.uint(1)
+	case 0xcd:
+		return d.uint(2)
+	case 0xce:
+		return d.uint(4)
+	case 0xcf:
+		return d.uint(8)
+	case 0xd0:
+		v, err := d.uint(1)
+		return int64(int8(v)), err
+	case 0xd1:
+		v, err := d.uint(2)
+		return int64(int16(v)), err
+	case 0xd2:
+		v, err := d.uint(4)
+		return int64(int32(v)), err
+	case 0xd3:
+		v, err := d.uint(8)
+		return int64(v), err
+	case 0xdc:
+		n, err := d.uint(2)
+		if err != nil {
+			return nil, err
+		}
+		return d.arrayOf(int(n), depth)
+	case 0xdd:
+		n, err := d.uint(4)
+		if err != nil {
+			return nil, err
+		}
+		return d.arrayOf(int(n), depth)
+	case 0xde:
+		n, err := d.uint(2)
+		if err != nil {
+			return nil, err
+		}
+		return d.mapOf(int(n), depth)
+	case 0xdf:
+		n, err := d.uint(4)
+		if err != nil {
+			return nil, err
+		}
+		return d.mapOf(int(n), depth)
+	}
+
+	return nil, fmt.Errorf("msgpack: unsupported type 0x%x", t)
+}
+
+func (d *msgpackDecoder) sizedStr(sizeBytes int) (interface{}, error) {
+	n, err := d.uint(sizeBytes)
+	if err != nil {
+		return nil, err
+	}
+	return d.str(int(n))
+}
+
+func (d *msgpackDecoder) str(n int) (interface{}, error) {
+	b, err := d.next(n)
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+func (d *msgpackDecoder) arrayOf(n int, depth int) (interface{}, error) {
+	// every element is at least one byte.  don't allocate more than the remaining data could hold
+	if n > len(d.b)-d.pos {
+		return nil, errMsgpackShort
+	}
+
+	a := make([]interface{}, 0, n)
+	for i := 0; i < n; i++ {
+		v, err := d.value(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		a = append(a, v)
+	}
+	return a, nil
+}
+
+func (d *msgpackDecoder) mapOf(n int, depth int) (interface{}, error) {
+	if n*2 > len(d.b)-d.pos {
+		return nil, errMsgpackShort
+	}
+
+	m := make(map[string]interface{}, n)
+	for i := 0; i < n; i++ {
+		k, err := d.value(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		v, err := d.value(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		m[fmt.Sprint(k)] = v
+	}
+	return m, nil
+}
@@ -0,0 +1,116 @@
+package datadog
+
+import (
+	"encoding/binary"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// appendMsgpack is a minimal encoder covering the types used by the trace agent protocol
+func appendMsgpack(b []byte, v interface{}) []byte {
+	switch v := v.(type) {
+	case nil:
+		return append(b, 0xc0)
+	case string:
+		b = append(b, 0xdb)
+		b = appendUint32(b, uint32(len(v)))
+		return append(b, v...)
+	case uint64:
+		b = append(b, 0xcf)
+		return appendUint64(b, v)
+	case int64:
+		b = append(b, 0xd3)
+		return appendUint64(b, uint64(v))
+	case int:
+		if v >= 0 && v <= 0x7f {
+			return append(b, byte(v))
+		}
+		return appendMsgpack(b, int64(v))
+	case float64:
+		b = append(b, 0xcb)
+		return appendUint64(b, math.Float64bits(v))
+	case []interface{}:
+		b = append(b, 0xdd)
+		b = appendUint32(b, uint32(len(v)))
+		for _, e := range v {
+			b = appendMsgpack(b, e)
+		}
+		return b
+	case map[string]interface{}:
+		b = append(b, 0xdf)
+		b = appendUint32(b, uint32(len(v)))
+		for k, e := range v {
+			b = appendMsgpack(b, k)
+			b = appendMsgpack(b, e)
+		}
+		return b
+	}
+	panic("unsupported type")
+}
+
+func appendUint32(b []byte, v uint32) []byte {
+	var buf [4]byte
+	binary.BigEndian.PutUint32(buf[:], v)
+	return append(b, buf[:]...)
+}
+
+func appendUint64(b []byte, v uint64) []byte {
+	var buf [8]byte
+	binary.BigEndian.PutUint64(buf[:], v)
+	return append(b, buf[:]...)
+}
+
+func TestDecodeMsgpack(t *testing.T) {
+	v := map[string]interface{}{
+		"str":    "value",
+		"uint":   uint64(math.MaxUint64),
+		"int":    int64(-5),
+		"fixint": 7,
+		"float":  1.5,
+		"nil":    nil,
+		"array":  []interface{}{"a", 1},
+	}
+
+	decoded, err := decodeMsgpack(appendMsgpack(nil, v))
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"str":    "value",
+		"uint":   uint64(math.MaxUint64),
+		"int":    int64(-5),
+		"fixint": int64(7),
+		"float":  1.5,
+		"nil":    nil,
+		"array":  []interface{}{"a", int64(1)},
+	}, decoded)
+
+	// compact encodings
+	decoded, err = decodeMsgpack([]byte{0x93, 0xa2, 'h', 'i', 0xff, 0x81, 0xa1, 'k', 0xc3})
+	require.NoError(t, err)
+	assert.Equal(t, []interface{}{"hi", int64(-1), map[string]interface{}{"k": true}}, decoded)
+}
+
+func TestDecodeMsgpackErrors(t *testing.T) {
+	for name, b := range map[string][]byte{
+		"truncated string":   {0xa5, 'a'},
+		"oversized array":    {0xdd, 0xff, 0xff, 0xff, 0xff},
+		"trailing bytes":     {0xc0, 0xc0},
+		"unsupported ext":    {0xd4, 0x01, 0x01},
+		"truncated uint":     {0xcf, 0x01},
+		"missing map value":  {0x81, 0xa1, 'k'},
+		"excessively nested": bytesOf(0x91, maxMsgpackDepth+2),
+	} {
+		_, err := decodeMsgpack(b)
+		assert.Error(t, err, name)
+	}
+}
+
+func bytesOf(b byte, n int) []byte {
+	out := make([]byte, n)
+	for i := range out {
+		out[i] = b
+	}
+	return out
+}
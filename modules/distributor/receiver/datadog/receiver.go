@@ -0,0 +1,148 @@
+package datadog
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+
+	v1 "github.com/open-telemetry/opentelemetry-proto/gen/go/trace/v1"
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/component/componenterror"
+	"go.opentelemetry.io/collector/consumer"
+	"go.opentelemetry.io/collector/consumer/pdata"
+	"go.opentelemetry.io/collector/obsreport"
+
+	"github.com/grafana/tempo/modules/distributor/receiver/intake"
+)
+
+const (
+	transport = "http"
+	format    = "datadog"
+
+	// the trace agent accepts payloads up to this size
+	maxPayloadBytes = 50 << 20
+
+	contentTypeMsgpack = "application/msgpack"
+)
+
+type receiver struct {
+	cfg          *Config
+	nextConsumer consumer.TraceConsumer
+
+	startOnce sync.Once
+	stopOnce  sync.Once
+	server    *http.Server
+}
+
+func newReceiver(cfg *Config, nextConsumer consumer.TraceConsumer) (*receiver, error) {
+	if nextConsumer == nil {
+		return nil, componenterror.ErrNilNextConsumer
+	}
+
+	return &receiver{
+		cfg:          cfg,
+		nextConsumer: nextConsumer,
+	}, nil
+}
+
+// Start implements component.Receiver
+func (r *receiver) Start(_ context.Context, host component.Host) error {
+	if host == nil {
+		return errors.New("nil host")
+	}
+
+	err := componenterror.ErrAlreadyStarted
+	r.startOnce.Do(func() {
+		mux := http.NewServeMux()
+		mux.HandleFunc("/v0.3/traces", r.handleTraces)
+		mux.HandleFunc("/v0.4/traces", r.handleTraces)
+		r.server = r.cfg.HTTPServerSettings.ToServer(mux)
+
+		listener, listenErr := r.cfg.HTTPServerSettings.ToListener()
+		if listenErr != nil {
+			err = listenErr
+			return
+		}
+		err = nil
+
+		go func() {
+			if serveErr := r.server.Serve(listener); serveErr != http.ErrServerClosed {
+				host.ReportFatalError(serveErr)
+			}
+		}()
+	})
+
+	return err
+}
+
+// Shutdown implements component.Receiver
+func (r *receiver) Shutdown(context.Context) error {
+	err := componenterror.ErrAlreadyStopped
+	r.stopOnce.Do(func() {
+		err = nil
+		if r.server != nil {
+			err = r.server.Close()
+		}
+	})
+	return err
+}
+
+func (r *receiver) handleTraces(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPut && req.Method != http.MethodPost {
+		http.Error(w, "traces must be sent with PUT or POST", http.StatusMethodNotAllowed)
+		return
+	}
+
+	ctx := intake.RequestContext(req)
+	ctx = obsreport.ReceiverContext(ctx, r.cfg.Name(), transport, "")
+	ctx = obsreport.StartTraceDataReceiveOp(ctx, r.cfg.Name(), transport)
+
+	batches, spanCount, err := decodePayload(req)
+	if err != nil {
+		obsreport.EndTraceDataReceiveOp(ctx, format, 0, err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	err = r.nextConsumer.ConsumeTraces(ctx, pdata.TracesFromOtlp(batches))
+	obsreport.EndTraceDataReceiveOp(ctx, format, spanCount, err)
+	if err != nil {
+		intake.WriteConsumeError(w, err)
+		return
+	}
+
+	// tracers adjust their sampling rates from the response.  an empty set keeps their defaults
+	if strings.HasPrefix(req.URL.Path, "/v0.4/") {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"rate_by_service":{}}`))
+		return
+	}
+	_, _ = w.Write([]byte("OK"))
+}
+
+func decodePayload(req *http.Request) ([]*v1.ResourceSpans, int, error) {
+	body, err := ioutil.ReadAll(http.MaxBytesReader(nil, req.Body, maxPayloadBytes))
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var payload interface{}
+	if strings.HasPrefix(req.Header.Get("Content-Type"), contentTypeMsgpack) {
+		payload, err = decodeMsgpack(body)
+	} else {
+		decoder := json.NewDecoder(bytes.NewReader(body))
+		decoder.UseNumber()
+		err = decoder.Decode(&payload)
+	}
+	if err != nil {
+		return nil, 0, fmt.Errorf("unable to decode traces: %w", err)
+	}
+
+	return toResourceSpans(payload)
+}
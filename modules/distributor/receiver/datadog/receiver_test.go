@@ -0,0 +1,116 @@
+package datadog
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	v1 "github.com/open-telemetry/opentelemetry-proto/gen/go/trace/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/weaveworks/common/user"
+	"go.opentelemetry.io/collector/consumer/pdata"
+)
+
+type traceSink struct {
+	traces  []pdata.Traces
+	tenants []string
+}
+
+func (s *traceSink) ConsumeTraces(ctx context.Context, td pdata.Traces) error {
+	s.traces = append(s.traces, td)
+	_, ctx, _ = user.ExtractFromGRPCRequest(ctx)
+	tenant, _ := user.ExtractOrgID(ctx)
+	s.tenants = append(s.tenants, tenant)
+	return nil
+}
+
+func testPayload() []interface{} {
+	return []interface{}{
+		[]interface{}{
+			map[string]interface{}{
+				"service":   "web",
+				"name":      "http.request",
+				"resource":  "GET /users",
+				"trace_id":  uint64(1234),
+				"span_id":   uint64(1),
+				"parent_id": uint64(0),
+				"start":     int64(1000),
+				"duration":  int64(500),
+				"error":     int64(1),
+				"meta":      map[string]interface{}{"span.kind": "server", "error.msg": "boom"},
+				"metrics":   map[string]interface{}{"_sampling_priority_v1": 1.0},
+			},
+			map[string]interface{}{
+				"service":   "db",
+				"name":      "postgres.query",
+				"trace_id":  uint64(1234),
+				"span_id":   uint64(2),
+				"parent_id": uint64(1),
+				"start":     int64(1100),
+				"duration":  int64(100),
+			},
+		},
+	}
+}
+
+func TestHandleTraces(t *testing.T) {
+	sink := &traceSink{}
+	r, err := newReceiver(createDefaultConfig().(*Config), sink)
+	require.NoError(t, err)
+
+	req := httptest.NewRequest(http.MethodPut, "/v0.4/traces", bytes.NewReader(appendMsgpack(nil, testPayload())))
+	req.Header.Set("Content-Type", contentTypeMsgpack)
+	req.Header.Set(user.OrgIDHeaderName, "tenant-a")
+	w := httptest.NewRecorder()
+	r.handleTraces(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"rate_by_service":{}}`, w.Body.String())
+	require.Len(t, sink.traces, 1)
+	assert.Equal(t, []string{"tenant-a"}, sink.tenants)
+
+	batches := pdata.TracesToOtlp(sink.traces[0])
+	require.Len(t, batches, 2)
+
+	// batches are sorted by service
+	assert.Equal(t, "db", batches[0].Resource.Attributes[0].Value.GetStringValue())
+	web := batches[1].InstrumentationLibrarySpans[0].Spans[0]
+	assert.Equal(t, "http.request", web.Name)
+	assert.Equal(t, uint64(1234), binary.BigEndian.Uint64(web.TraceId[8:]))
+	assert.Len(t, web.TraceId, 16)
+	assert.Nil(t, web.ParentSpanId)
+	assert.Equal(t, uint64(1500), web.EndTimeUnixNano)
+	assert.Equal(t, v1.Span_SERVER, web.Kind)
+	assert.Equal(t, v1.Status_UnknownError, web.Status.Code)
+	assert.Equal(t, "boom", web.Status.Message)
+
+	db := batches[0].InstrumentationLibrarySpans[0].Spans[0]
+	assert.Equal(t, uint64(1), binary.BigEndian.Uint64(db.ParentSpanId))
+	assert.Nil(t, db.Status)
+}
+
+func TestHandleTracesJSON(t *testing.T) {
+	sink := &traceSink{}
+	r, err := newReceiver(createDefaultConfig().(*Config), sink)
+	require.NoError(t, err)
+
+	body := `[[{"service":"web","name":"op","trace_id":18446744073709551615,"span_id":1,"start":1,"duration":2}]]`
+	req := httptest.NewRequest(http.MethodPost, "/v0.3/traces", bytes.NewReader([]byte(body)))
+	w := httptest.NewRecorder()
+	r.handleTraces(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	require.Len(t, sink.traces, 1)
+	span := pdata.TracesToOtlp(sink.traces[0])[0].InstrumentationLibrarySpans[0].Spans[0]
+	assert.Equal(t, uint64(18446744073709551615), binary.BigEndian.Uint64(span.TraceId[8:]))
+
+	// malformed
+	req = httptest.NewRequest(http.MethodPost, "/v0.3/traces", bytes.NewReader([]byte(`{"not":"traces"}`)))
+	w = httptest.NewRecorder()
+	r.handleTraces(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
@@ -0,0 +1,200 @@
+package datadog
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+
+	v1_common "github.com/open-telemetry/opentelemetry-proto/gen/go/common/v1"
+	v1_resource "github.com/open-telemetry/opentelemetry-proto/gen/go/resource/v1"
+	v1 "github.com/open-telemetry/opentelemetry-proto/gen/go/trace/v1"
+)
+
+const (
+	attributeServiceName = "service.name"
+	attributeResource    = "resource.name"
+	attributeSpanType    = "span.type"
+	attributeErrorMsg    = "error.msg"
+	metaSpanKind         = "span.kind"
+)
+
+var spanKinds = map[string]v1.Span_SpanKind{
+	"server":   v1.Span_SERVER,
+	"client":   v1.Span_CLIENT,
+	"producer": v1.Span_PRODUCER,
+	"consumer": v1.Span_CONSUMER,
+	"internal": v1.Span_INTERNAL,
+}
+
+// toResourceSpans converts the traces of a trace agent payload, an array of traces each an array of spans, to one
+// batch per service.
+func toResourceSpans(payload interface{}) ([]*v1.ResourceSpans, int, error) {
+	traces, ok := payload.([]interface{})
+	if !ok {
+		return nil, 0, fmt.Errorf("expected an array of traces, got %T", payload)
+	}
+
+	byService := map[string]*v1.ResourceSpans{}
+	spanCount := 0
+	for _, t := range traces {
+		spans, ok := t.([]interface{})
+		if !ok {
+			return nil, 0, fmt.Errorf("expected an array of spans, got %T", t)
+		}
+
+		for _, s := range spans {
+			fields, ok := s.(map[string]interface{})
+			if !ok {
+				return nil, 0, fmt.Errorf("expected a span, got %T", s)
+			}
+
+			service := toString(fields["service"])
+			batch, ok := byService[service]
+			if !ok {
+				batch = &v1.ResourceSpans{
+					Resource: &v1_resource.Resource{
+						Attributes: []*v1_common.KeyValue{stringAttribute(attributeServiceName, service)},
+					},
+					InstrumentationLibrarySpans: []*v1.InstrumentationLibrarySpans{{}},
+				}
+				byService[service] = batch
+			}
+
+			ils := batch.InstrumentationLibrarySpans[0]
+			ils.Spans = append(ils.Spans, toSpan(fields))
+			spanCount++
+		}
+	}
+
+	services := make([]string, 0, len(byService))
+	for service := range byService {
+		services = append(services, service)
+	}
+	sort.Strings(services)
+
+	batches := make([]*v1.ResourceSpans, 0, len(byService))
+	for _, service := range services {
+		batches = append(batches, byService[service])
+	}
+
+	return batches, spanCount, nil
+}
+
+func toSpan(fields map[string]interface{}) *v1.Span {
+	start := toUint64(fields["start"])
+
+	span := &v1.Span{
+		// datadog ids are 64 bit.  the trace id is left padded to 128
+		TraceId:           make([]byte, 16),
+		SpanId:            make([]byte, 8),
+		Name:              toString(fields["name"]),
+		StartTimeUnixNano: start,
+		EndTimeUnixNano:   start + toUint64(fields["duration"]),
+	}
+	binary.BigEndian.PutUint64(span.TraceId[8:], toUint64(fields["trace_id"]))
+	binary.BigEndian.PutUint64(span.SpanId, toUint64(fields["span_id"]))
+	if parentID := toUint64(fields["parent_id"]); parentID != 0 {
+		span.ParentSpanId = make([]byte, 8)
+		binary.BigEndian.PutUint64(span.ParentSpanId, parentID)
+	}
+
+	if resource := toString(fields["resource"]); resource != "" {
+		span.Attributes = append(span.Attributes, stringAttribute(attributeResource, resource))
+	}
+	if spanType := toString(fields["type"]); spanType != "" {
+		span.Attributes = append(span.Attributes, stringAttribute(attributeSpanType, spanType))
+	}
+
+	meta, _ := fields["meta"].(map[string]interface{})
+	for _, k := range sortedKeys(meta) {
+		v := toString(meta[k])
+		if k == metaSpanKind {
+			span.Kind = spanKinds[v]
+		}
+		span.Attributes = append(span.Attributes, stringAttribute(k, v))
+	}
+
+	metrics, _ := fields["metrics"].(map[string]interface{})
+	for _, k := range sortedKeys(metrics) {
+		span.Attributes = append(span.Attributes, &v1_common.KeyValue{
+			Key:   k,
+			Value: &v1_common.AnyValue{Value: &v1_common.AnyValue_DoubleValue{DoubleValue: toFloat64(metrics[k])}},
+		})
+	}
+
+	if toUint64(fields["error"]) != 0 {
+		span.Status = &v1.Status{
+			Code:    v1.Status_UnknownError,
+			Message: toString(meta[attributeErrorMsg]),
+		}
+	}
+
+	return span
+}
+
+func stringAttribute(k string, v string) *v1_common.KeyValue {
+	return &v1_common.KeyValue{
+		Key:   k,
+		Value: &v1_common.AnyValue{Value: &v1_common.AnyValue_StringValue{StringValue: v}},
+	}
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// the conversions below accept the types produced by both decodeMsgpack and encoding/json with UseNumber
+
+func toString(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case nil:
+		return ""
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+func toUint64(v interface{}) uint64 {
+	switch v := v.(type) {
+	case uint64:
+		return v
+	case int64:
+		return uint64(v)
+	case float64:
+		return uint64(v)
+	case json.Number:
+		if u, err := strconv.ParseUint(string(v), 10, 64); err == nil {
+			return u
+		}
+		if i, err := v.Int64(); err == nil {
+			return uint64(i)
+		}
+		f, _ := v.Float64()
+		return uint64(f)
+	}
+	return 0
+}
+
+func toFloat64(v interface{}) float64 {
+	switch v := v.(type) {
+	case float64:
+		return v
+	case uint64:
+		return float64(v)
+	case int64:
+		return float64(v)
+	case json.Number:
+		f, _ := v.Float64()
+		return f
+	}
+	return 0
+}
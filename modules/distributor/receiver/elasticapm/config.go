@@ -0,0 +1,12 @@
+package elasticapm
+
+import (
+	"go.opentelemetry.io/collector/config/confighttp"
+	"go.opentelemetry.io/collector/config/configmodels"
+)
+
+// Config configures the Elastic APM intake receiver.
+type Config struct {
+	configmodels.ReceiverSettings `mapstructure:",squash"`
+	confighttp.HTTPServerSettings `mapstructure:",squash"`
+}
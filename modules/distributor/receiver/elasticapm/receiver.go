@@ -0,0 +1,123 @@
+package elasticapm
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+
+	v1 "github.com/open-telemetry/opentelemetry-proto/gen/go/trace/v1"
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/component/componenterror"
+	"go.opentelemetry.io/collector/consumer"
+	"go.opentelemetry.io/collector/consumer/pdata"
+	"go.opentelemetry.io/collector/obsreport"
+
+	"github.com/grafana/tempo/modules/distributor/receiver/intake"
+)
+
+const (
+	transport = "http"
+	format    = "elasticapm"
+
+	maxPayloadBytes = 10 << 20
+)
+
+type receiver struct {
+	cfg          *Config
+	nextConsumer consumer.TraceConsumer
+
+	startOnce sync.Once
+	stopOnce  sync.Once
+	server    *http.Server
+}
+
+func newReceiver(cfg *Config, nextConsumer consumer.TraceConsumer) (*receiver, error) {
+	if nextConsumer == nil {
+		return nil, componenterror.ErrNilNextConsumer
+	}
+
+	return &receiver{
+		cfg:          cfg,
+		nextConsumer: nextConsumer,
+	}, nil
+}
+
+// Start implements component.Receiver
+func (r *receiver) Start(_ context.Context, host component.Host) error {
+	if host == nil {
+		return errors.New("nil host")
+	}
+
+	err := componenterror.ErrAlreadyStarted
+	r.startOnce.Do(func() {
+		mux := http.NewServeMux()
+		mux.HandleFunc("/intake/v2/events", r.handleEvents)
+		r.server = r.cfg.HTTPServerSettings.ToServer(mux)
+
+		listener, listenErr := r.cfg.HTTPServerSettings.ToListener()
+		if listenErr != nil {
+			err = listenErr
+			return
+		}
+		err = nil
+
+		go func() {
+			if serveErr := r.server.Serve(listener); serveErr != http.ErrServerClosed {
+				host.ReportFatalError(serveErr)
+			}
+		}()
+	})
+
+	return err
+}
+
+// Shutdown implements component.Receiver
+func (r *receiver) Shutdown(context.Context) error {
+	err := componenterror.ErrAlreadyStopped
+	r.stopOnce.Do(func() {
+		err = nil
+		if r.server != nil {
+			err = r.server.Close()
+		}
+	})
+	return err
+}
+
+// handleEvents accepts the intake v2 protocol used by the Elastic APM agents.  Only transactions and spans are kept.
+func (r *receiver) handleEvents(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		http.Error(w, "events must be sent with POST", http.StatusMethodNotAllowed)
+		return
+	}
+
+	ctx := intake.RequestContext(req)
+	ctx = obsreport.ReceiverContext(ctx, r.cfg.Name(), transport, "")
+	ctx = obsreport.StartTraceDataReceiveOp(ctx, r.cfg.Name(), transport)
+
+	body, err := intake.ReadBody(req, maxPayloadBytes)
+	if err != nil {
+		obsreport.EndTraceDataReceiveOp(ctx, format, 0, err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	batch, err := toResourceSpans(body)
+	if err != nil {
+		obsreport.EndTraceDataReceiveOp(ctx, format, 0, err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	spans := len(batch.InstrumentationLibrarySpans[0].Spans)
+	if spans > 0 {
+		err = r.nextConsumer.ConsumeTraces(ctx, pdata.TracesFromOtlp([]*v1.ResourceSpans{batch}))
+	}
+	obsreport.EndTraceDataReceiveOp(ctx, format, spans, err)
+	if err != nil {
+		intake.WriteConsumeError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
@@ -0,0 +1,54 @@
+package elasticapm
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/weaveworks/common/user"
+	"go.opentelemetry.io/collector/consumer/pdata"
+)
+
+type traceSink struct {
+	traces  []pdata.Traces
+	tenants []string
+}
+
+func (s *traceSink) ConsumeTraces(ctx context.Context, td pdata.Traces) error {
+	_, ctx, _ = user.ExtractFromGRPCRequest(ctx)
+	tenant, _ := user.ExtractOrgID(ctx)
+	s.tenants = append(s.tenants, tenant)
+	s.traces = append(s.traces, td)
+	return nil
+}
+
+func TestHandleEvents(t *testing.T) {
+	sink := &traceSink{}
+	r, err := newReceiver(&Config{}, sink)
+	require.NoError(t, err)
+
+	req := httptest.NewRequest(http.MethodPost, "/intake/v2/events", strings.NewReader(testEvents))
+	req.Header.Set(user.OrgIDHeaderName, "tenant-a")
+	w := httptest.NewRecorder()
+	r.handleEvents(w, req)
+
+	assert.Equal(t, http.StatusAccepted, w.Code)
+	require.Len(t, sink.traces, 1)
+	assert.Equal(t, 3, sink.traces[0].SpanCount())
+	assert.Equal(t, []string{"tenant-a"}, sink.tenants)
+
+	req = httptest.NewRequest(http.MethodPost, "/intake/v2/events", strings.NewReader(`{"span": {}}`))
+	w = httptest.NewRecorder()
+	r.handleEvents(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	req = httptest.NewRequest(http.MethodGet, "/intake/v2/events", nil)
+	w = httptest.NewRecorder()
+	r.handleEvents(w, req)
+	assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+	assert.Len(t, sink.traces, 1)
+}
@@ -0,0 +1,275 @@
+package elasticapm
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+
+	v1_common "github.com/open-telemetry/opentelemetry-proto/gen/go/common/v1"
+	v1_resource "github.com/open-telemetry/opentelemetry-proto/gen/go/resource/v1"
+	v1 "github.com/open-telemetry/opentelemetry-proto/gen/go/trace/v1"
+)
+
+const (
+	attributeServiceName    = "service.name"
+	attributeServiceVersion = "service.version"
+	attributeEnvironment    = "deployment.environment"
+	attributeAgentName      = "elastic.agent.name"
+	attributeType           = "elastic.type"
+	attributeSubtype        = "elastic.subtype"
+	attributeAction         = "elastic.action"
+	attributeResult         = "elastic.result"
+	attributeHTTPMethod     = "http.method"
+	attributeHTTPURL        = "http.url"
+	attributeHTTPStatusCode = "http.status_code"
+	attributeDBStatement    = "db.statement"
+
+	outcomeFailure = "failure"
+
+	// a single event line may not exceed this
+	maxEventBytes = 1 << 20
+)
+
+// event is one line of the intake v2 ndjson stream.  Errors, metricsets and any other event types are ignored.
+type event struct {
+	Metadata    *metadata `json:"metadata"`
+	Transaction *apmEvent `json:"transaction"`
+	Span        *apmEvent `json:"span"`
+}
+
+type metadata struct {
+	Service struct {
+		Name        string `json:"name"`
+		Version     string `json:"version"`
+		Environment string `json:"environment"`
+		Agent       struct {
+			Name string `json:"name"`
+		} `json:"agent"`
+	} `json:"service"`
+}
+
+// apmEvent holds the fields shared by transactions and spans
+type apmEvent struct {
+	ID            string   `json:"id"`
+	TraceID       string   `json:"trace_id"`
+	ParentID      string   `json:"parent_id"`
+	TransactionID string   `json:"transaction_id"`
+	Name          string   `json:"name"`
+	Type          string   `json:"type"`
+	Subtype       string   `json:"subtype"`
+	Action        string   `json:"action"`
+	Result        string   `json:"result"`
+	Outcome       string   `json:"outcome"`
+	Timestamp     int64    `json:"timestamp"` // microseconds since the epoch
+	Start         *float64 `json:"start"`     // milliseconds since the transaction started.  only used by old agents
+	Duration      float64  `json:"duration"`  // milliseconds
+	Context       struct {
+		Request *struct {
+			Method string `json:"method"`
+			URL    struct {
+				Full string `json:"full"`
+			} `json:"url"`
+		} `json:"request"`
+		Response *struct {
+			StatusCode int64 `json:"status_code"`
+		} `json:"response"`
+		HTTP *struct {
+			Method     string `json:"method"`
+			URL        string `json:"url"`
+			StatusCode int64  `json:"status_code"`
+		} `json:"http"`
+		DB *struct {
+			Statement string `json:"statement"`
+		} `json:"db"`
+		Destination *json.RawMessage `json:"destination"`
+	} `json:"context"`
+}
+
+// toResourceSpans converts an intake v2 event stream to a batch.  The first line must be the metadata describing the
+// service that sent the events.
+func toResourceSpans(body []byte) (*v1.ResourceSpans, error) {
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	scanner.Buffer(make([]byte, 0, 64*1024), maxEventBytes)
+
+	var md *metadata
+	var transactions, spans []*apmEvent
+	for line := 0; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+
+		e := event{}
+		err := json.Unmarshal(scanner.Bytes(), &e)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line+1, err)
+		}
+
+		switch {
+		case md == nil && e.Metadata == nil:
+			return nil, errors.New("the first event must be metadata")
+		case e.Metadata != nil:
+			md = e.Metadata
+		case e.Transaction != nil:
+			transactions = append(transactions, e.Transaction)
+		case e.Span != nil:
+			spans = append(spans, e.Span)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if md == nil {
+		return nil, errors.New("missing metadata")
+	}
+
+	// spans from old agents are timed relative to their transaction
+	transactionStarts := map[string]int64{}
+	for _, t := range transactions {
+		transactionStarts[t.ID] = t.Timestamp
+	}
+
+	ils := &v1.InstrumentationLibrarySpans{}
+	for _, t := range transactions {
+		span, err := toSpan(t, v1.Span_SERVER, t.Timestamp)
+		if err != nil {
+			return nil, err
+		}
+		ils.Spans = append(ils.Spans, span)
+	}
+	for _, s := range spans {
+		timestamp := s.Timestamp
+		if timestamp == 0 && s.Start != nil {
+			timestamp = transactionStarts[s.TransactionID] + int64(*s.Start*1000)
+		}
+
+		kind := v1.Span_INTERNAL
+		if s.Context.Destination != nil || s.Type == "external" || s.Type == "db" {
+			kind = v1.Span_CLIENT
+		}
+
+		span, err := toSpan(s, kind, timestamp)
+		if err != nil {
+			return nil, err
+		}
+		ils.Spans = append(ils.Spans, span)
+	}
+
+	resource := &v1_resource.Resource{}
+	for k, v := range map[string]string{
+		attributeServiceName:    md.Service.Name,
+		attributeServiceVersion: md.Service.Version,
+		attributeEnvironment:    md.Service.Environment,
+		attributeAgentName:      md.Service.Agent.Name,
+	} {
+		if v != "" {
+			resource.Attributes = append(resource.Attributes, stringAttribute(k, v))
+		}
+	}
+	sortAttributes(resource.Attributes)
+
+	return &v1.ResourceSpans{
+		Resource:                    resource,
+		InstrumentationLibrarySpans: []*v1.InstrumentationLibrarySpans{ils},
+	}, nil
+}
+
+func toSpan(e *apmEvent, kind v1.Span_SpanKind, timestampMicros int64) (*v1.Span, error) {
+	traceID, err := decodeID(e.TraceID, 16)
+	if err != nil {
+		return nil, fmt.Errorf("invalid trace id: %w", err)
+	}
+	spanID, err := decodeID(e.ID, 8)
+	if err != nil {
+		return nil, fmt.Errorf("invalid id: %w", err)
+	}
+	var parentID []byte
+	if e.ParentID != "" {
+		parentID, err = decodeID(e.ParentID, 8)
+		if err != nil {
+			return nil, fmt.Errorf("invalid parent id: %w", err)
+		}
+	}
+
+	start := uint64(timestampMicros) * 1000
+	span := &v1.Span{
+		TraceId:           traceID,
+		SpanId:            spanID,
+		ParentSpanId:      parentID,
+		Name:              e.Name,
+		Kind:              kind,
+		StartTimeUnixNano: start,
+		EndTimeUnixNano:   start + uint64(e.Duration*1e6),
+	}
+
+	for k, v := range map[string]string{
+		attributeType:    e.Type,
+		attributeSubtype: e.Subtype,
+		attributeAction:  e.Action,
+		attributeResult:  e.Result,
+	} {
+		if v != "" {
+			span.Attributes = append(span.Attributes, stringAttribute(k, v))
+		}
+	}
+
+	method, url, status := "", "", int64(0)
+	if r := e.Context.Request; r != nil {
+		method, url = r.Method, r.URL.Full
+	}
+	if r := e.Context.Response; r != nil {
+		status = r.StatusCode
+	}
+	if h := e.Context.HTTP; h != nil {
+		method, url, status = h.Method, h.URL, h.StatusCode
+	}
+	if method != "" {
+		span.Attributes = append(span.Attributes, stringAttribute(attributeHTTPMethod, method))
+	}
+	if url != "" {
+		span.Attributes = append(span.Attributes, stringAttribute(attributeHTTPURL, url))
+	}
+	if status != 0 {
+		span.Attributes = append(span.Attributes, &v1_common.KeyValue{
+			Key:   attributeHTTPStatusCode,
+			Value: &v1_common.AnyValue{Value: &v1_common.AnyValue_IntValue{IntValue: status}},
+		})
+	}
+	if db := e.Context.DB; db != nil && db.Statement != "" {
+		span.Attributes = append(span.Attributes, stringAttribute(attributeDBStatement, db.Statement))
+	}
+	sortAttributes(span.Attributes)
+
+	if e.Outcome == outcomeFailure {
+		span.Status = &v1.Status{Code: v1.Status_UnknownError}
+	}
+
+	return span, nil
+}
+
+func decodeID(id string, length int) ([]byte, error) {
+	b, err := hex.DecodeString(id)
+	if err != nil {
+		return nil, err
+	}
+	if len(b) != length {
+		return nil, fmt.Errorf("%q is not %d bytes", id, length)
+	}
+	return b, nil
+}
+
+func stringAttribute(k string, v string) *v1_common.KeyValue {
+	return &v1_common.KeyValue{
+		Key:   k,
+		Value: &v1_common.AnyValue{Value: &v1_common.AnyValue_StringValue{StringValue: v}},
+	}
+}
+
+func sortAttributes(attributes []*v1_common.KeyValue) {
+	sort.Slice(attributes, func(i, j int) bool {
+		return attributes[i].Key < attributes[j].Key
+	})
+}
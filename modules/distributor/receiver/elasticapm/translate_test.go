@@ -0,0 +1,79 @@
+package elasticapm
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	v1 "github.com/open-telemetry/opentelemetry-proto/gen/go/trace/v1"
+)
+
+const testEvents = `{"metadata": {"service": {"name": "checkout", "version": "1.2.0", "agent": {"name": "go"}}}}
+{"transaction": {"id": "0102030405060708", "trace_id": "0102030405060708090a0b0c0d0e0f10", "name": "GET /cart", "type": "request", "result": "HTTP 5xx", "outcome": "failure", "timestamp": 1600000000000000, "duration": 12.5, "context": {"request": {"method": "GET", "url": {"full": "http://shop/cart"}}, "response": {"status_code": 500}}}}
+{"span": {"id": "1112131415161718", "trace_id": "0102030405060708090a0b0c0d0e0f10", "parent_id": "0102030405060708", "transaction_id": "0102030405060708", "name": "SELECT cart", "type": "db", "subtype": "postgresql", "start": 2, "duration": 3, "context": {"db": {"statement": "SELECT * FROM cart"}}}}
+{"error": {"id": "2122232425262728"}}
+{"span": {"id": "3132333435363738", "trace_id": "0102030405060708090a0b0c0d0e0f10", "parent_id": "0102030405060708", "name": "render", "type": "template", "timestamp": 1600000000005000, "duration": 1}}
+`
+
+func TestToResourceSpans(t *testing.T) {
+	batch, err := toResourceSpans([]byte(testEvents))
+	require.NoError(t, err)
+
+	require.Len(t, batch.Resource.Attributes, 3)
+	assert.Equal(t, attributeAgentName, batch.Resource.Attributes[0].Key)
+	assert.Equal(t, "checkout", batch.Resource.Attributes[1].Value.GetStringValue())
+
+	spans := batch.InstrumentationLibrarySpans[0].Spans
+	require.Len(t, spans, 3)
+
+	tx := spans[0]
+	assert.Equal(t, v1.Span_SERVER, tx.Kind)
+	assert.Equal(t, uint64(1600000000000000000), tx.StartTimeUnixNano)
+	assert.Equal(t, uint64(1600000000012500000), tx.EndTimeUnixNano)
+	assert.Equal(t, v1.Status_UnknownError, tx.Status.Code)
+	assert.Nil(t, tx.ParentSpanId)
+
+	// old agents time spans relative to their transaction
+	db := spans[1]
+	assert.Equal(t, v1.Span_CLIENT, db.Kind)
+	assert.Equal(t, tx.SpanId, db.ParentSpanId)
+	assert.Equal(t, uint64(1600000000002000000), db.StartTimeUnixNano)
+	assert.Equal(t, uint64(1600000000005000000), db.EndTimeUnixNano)
+	assert.Nil(t, db.Status)
+
+	render := spans[2]
+	assert.Equal(t, v1.Span_INTERNAL, render.Kind)
+	assert.Equal(t, uint64(1600000000005000000), render.StartTimeUnixNano)
+}
+
+func TestToResourceSpansErrors(t *testing.T) {
+	tcs := []struct {
+		name string
+		body string
+	}{
+		{
+			name: "missing metadata",
+			body: `{"transaction": {"id": "0102030405060708", "trace_id": "0102030405060708090a0b0c0d0e0f10"}}`,
+		},
+		{
+			name: "empty",
+			body: ``,
+		},
+		{
+			name: "invalid json",
+			body: `{"metadata": {}}` + "\n{",
+		},
+		{
+			name: "short trace id",
+			body: `{"metadata": {}}` + "\n" + `{"transaction": {"id": "0102030405060708", "trace_id": "0102"}}`,
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := toResourceSpans([]byte(tc.body))
+			assert.Error(t, err)
+		})
+	}
+}
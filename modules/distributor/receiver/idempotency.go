@@ -0,0 +1,49 @@
+package receiver
+
+import (
+	"context"
+	"strconv"
+	"strings"
+
+	"google.golang.org/grpc/metadata"
+)
+
+// IdempotencyKeyHeader is the header clients set to the same value when retrying a push.  OTLP/HTTP clients send it
+// as Grpc-Metadata-Idempotency-Key, as the otlp receiver only passes headers with that prefix on.
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+type idempotencyKeyContextKey struct{}
+
+// IdempotencyKey returns the key of the push the context belongs to, or an empty string if the client didn't set one.
+// Each batch of a request has its own key, derived from the header and the batch's position in the request.
+func IdempotencyKey(ctx context.Context) string {
+	key, _ := ctx.Value(idempotencyKeyContextKey{}).(string)
+	return key
+}
+
+// idempotencyKeyFromMetadata reads the header from the incoming gRPC metadata.
+func idempotencyKeyFromMetadata(ctx context.Context) string {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ""
+	}
+	keys := md.Get(strings.ToLower(IdempotencyKeyHeader))
+	if len(keys) == 0 {
+		return ""
+	}
+	return keys[0]
+}
+
+// WithIdempotencyKey returns a context holding the idempotency key of a push.
+func WithIdempotencyKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, idempotencyKeyContextKey{}, key)
+}
+
+// withBatchIdempotencyKey returns a context holding the key of the i-th batch of a request with the key prefix.
+// Nothing is added if the request has no key.
+func withBatchIdempotencyKey(ctx context.Context, prefix string, i int) context.Context {
+	if prefix == "" {
+		return ctx
+	}
+	return WithIdempotencyKey(ctx, prefix+"/"+strconv.Itoa(i))
+}
@@ -0,0 +1,39 @@
+package receiver
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/metadata"
+
+	"github.com/grafana/tempo/pkg/tempopb"
+)
+
+type idempotencyKeyRecorder struct {
+	keys []string
+}
+
+func (r *idempotencyKeyRecorder) Push(ctx context.Context, req *tempopb.PushRequest) (*tempopb.PushResponse, error) {
+	r.keys = append(r.keys, IdempotencyKey(ctx))
+	return &tempopb.PushResponse{}, nil
+}
+
+func TestConsumeTracesKeysBatches(t *testing.T) {
+	for _, routing := range []bool{false, true} {
+		pusher := &idempotencyKeyRecorder{}
+		shim := &receiversShim{pusher: pusher, inflight: newInflightLimiter(BackpressureConfig{})}
+		if routing {
+			shim.routingCfg = TenantRoutingConfig{Rules: []TenantRoutingRule{{Attribute: "k8s.namespace.name"}}}
+		}
+
+		ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("idempotency-key", "abc"))
+		require.NoError(t, shim.ConsumeTraces(ctx, tracesWithNamespaces("team-a", "team-b")))
+		assert.Equal(t, []string{"abc/0", "abc/1"}, pusher.keys)
+
+		pusher.keys = nil
+		require.NoError(t, shim.ConsumeTraces(context.Background(), tracesWithNamespaces("team-a")))
+		assert.Equal(t, []string{""}, pusher.keys)
+	}
+}
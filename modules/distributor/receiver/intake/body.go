@@ -0,0 +1,44 @@
+package intake
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+)
+
+// ReadBody reads at most maxBytes of the request body, decompressing it according to its Content-Encoding.  Agents
+// commonly compress their payloads.  The limit applies to the decompressed body.
+func ReadBody(req *http.Request, maxBytes int64) ([]byte, error) {
+	var r io.Reader = req.Body
+	switch req.Header.Get("Content-Encoding") {
+	case "", "identity":
+	case "gzip":
+		gz, err := gzip.NewReader(req.Body)
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close()
+		r = gz
+	case "deflate":
+		zr, err := zlib.NewReader(req.Body)
+		if err != nil {
+			return nil, err
+		}
+		defer zr.Close()
+		r = zr
+	default:
+		return nil, fmt.Errorf("unsupported content encoding %q", req.Header.Get("Content-Encoding"))
+	}
+
+	body, err := ioutil.ReadAll(io.LimitReader(r, maxBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(body)) > maxBytes {
+		return nil, fmt.Errorf("request body exceeds %d bytes", maxBytes)
+	}
+	return body, nil
+}
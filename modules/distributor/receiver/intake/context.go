@@ -0,0 +1,37 @@
+// Package intake holds helpers shared by the receivers that accept traces over plain http.
+package intake
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/weaveworks/common/user"
+	"go.opentelemetry.io/collector/client"
+	"google.golang.org/grpc/metadata"
+)
+
+// idempotencyKeyHeader matches receiver.IdempotencyKeyHeader, which can't be imported here as the receiver package
+// imports the receivers.
+const idempotencyKeyHeader = "Idempotency-Key"
+
+// RequestContext returns the context of req carrying the client address, tenant and idempotency key.  The distributor
+// looks for the tenant and key in incoming gRPC metadata, so the X-Scope-OrgID and Idempotency-Key headers are copied
+// there.
+func RequestContext(req *http.Request) context.Context {
+	ctx := req.Context()
+	if c, ok := client.FromHTTP(req); ok {
+		ctx = client.NewContext(ctx, c)
+	}
+
+	md := metadata.MD{}
+	for _, header := range []string{user.OrgIDHeaderName, idempotencyKeyHeader} {
+		if v := req.Header.Get(header); v != "" {
+			md.Set(strings.ToLower(header), v)
+		}
+	}
+	if len(md) > 0 {
+		ctx = metadata.NewIncomingContext(ctx, md)
+	}
+	return ctx
+}
@@ -0,0 +1,25 @@
+package intake
+
+import (
+	"net/http"
+
+	"github.com/gogo/status"
+	"google.golang.org/grpc/codes"
+)
+
+// WriteConsumeError responds to a request whose spans couldn't be consumed with the http status matching the grpc
+// status of err, so agents back off and retry when the distributor is overloaded or rate limited and drop requests
+// that will never be accepted.
+func WriteConsumeError(w http.ResponseWriter, err error) {
+	code := http.StatusInternalServerError
+	switch status.Code(err) {
+	case codes.Unavailable:
+		code = http.StatusServiceUnavailable
+	case codes.ResourceExhausted:
+		code = http.StatusTooManyRequests
+	case codes.InvalidArgument, codes.FailedPrecondition:
+		code = http.StatusBadRequest
+	}
+
+	http.Error(w, err.Error(), code)
+}
@@ -0,0 +1,34 @@
+package logbridge
+
+import (
+	"go.opentelemetry.io/collector/config/confighttp"
+	"go.opentelemetry.io/collector/config/configmodels"
+)
+
+// Config configures the log bridge receiver.
+type Config struct {
+	configmodels.ReceiverSettings `mapstructure:",squash"`
+	confighttp.HTTPServerSettings `mapstructure:",squash"`
+
+	// Fields maps the fields of a log line to the parts of its span.
+	Fields FieldsConfig `mapstructure:"fields"`
+	// TimeUnit is the unit of numeric times and durations: s, ms, us or ns.
+	TimeUnit string `mapstructure:"time_unit"`
+}
+
+// FieldsConfig names the fields of a log line holding each part of a span.  Nested fields are named by their path
+// joined with dots, e.g. span.id.  Lines without the trace id or span id field aren't spans and are skipped.
+type FieldsConfig struct {
+	TraceID      string `mapstructure:"trace_id"`
+	SpanID       string `mapstructure:"span_id"`
+	ParentSpanID string `mapstructure:"parent_span_id"`
+	Name         string `mapstructure:"name"`
+	ServiceName  string `mapstructure:"service_name"`
+	Kind         string `mapstructure:"kind"`
+	StartTime    string `mapstructure:"start_time"`
+	EndTime      string `mapstructure:"end_time"`
+	Duration     string `mapstructure:"duration"`
+	Error        string `mapstructure:"error"`
+	// Attributes are the fields copied to the attributes of the span under the same name.
+	Attributes []string `mapstructure:"attributes"`
+}
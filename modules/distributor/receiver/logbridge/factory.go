@@ -0,0 +1,54 @@
+package logbridge
+
+import (
+	"context"
+
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/config/confighttp"
+	"go.opentelemetry.io/collector/config/configmodels"
+	"go.opentelemetry.io/collector/consumer"
+	"go.opentelemetry.io/collector/receiver/receiverhelper"
+)
+
+const (
+	typeStr = "logbridge"
+
+	defaultEndpoint = "0.0.0.0:4319"
+)
+
+// NewFactory creates a factory for the log bridge receiver.
+func NewFactory() component.ReceiverFactory {
+	return receiverhelper.NewFactory(
+		typeStr,
+		createDefaultConfig,
+		receiverhelper.WithTraces(createTraceReceiver))
+}
+
+func createDefaultConfig() configmodels.Receiver {
+	return &Config{
+		ReceiverSettings: configmodels.ReceiverSettings{
+			TypeVal: typeStr,
+			NameVal: typeStr,
+		},
+		HTTPServerSettings: confighttp.HTTPServerSettings{
+			Endpoint: defaultEndpoint,
+		},
+		Fields: FieldsConfig{
+			TraceID:      "trace_id",
+			SpanID:       "span_id",
+			ParentSpanID: "parent_span_id",
+			Name:         "name",
+			ServiceName:  "service_name",
+			Kind:         "kind",
+			StartTime:    "start_time",
+			EndTime:      "end_time",
+			Duration:     "duration",
+			Error:        "error",
+		},
+		TimeUnit: "ms",
+	}
+}
+
+func createTraceReceiver(_ context.Context, _ component.ReceiverCreateParams, cfg configmodels.Receiver, nextConsumer consumer.TraceConsumer) (component.TraceReceiver, error) {
+	return newReceiver(cfg.(*Config), nextConsumer)
+}
@@ -0,0 +1,170 @@
+package logbridge
+
+import (
+	"context"
+	"errors"
+	"mime"
+	"net/http"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/component/componenterror"
+	"go.opentelemetry.io/collector/consumer"
+	"go.opentelemetry.io/collector/consumer/pdata"
+	"go.opentelemetry.io/collector/obsreport"
+
+	"github.com/grafana/tempo/modules/distributor/receiver/intake"
+)
+
+const (
+	transport = "http"
+	format    = "logbridge"
+
+	maxPayloadBytes = 10 << 20
+)
+
+var (
+	metricSkippedLines = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: "tempo",
+		Name:      "distributor_logbridge_lines_skipped_total",
+		Help:      "The total number of log lines received by the log bridge that carry no span.",
+	})
+)
+
+type receiver struct {
+	cfg          *Config
+	translator   *translator
+	nextConsumer consumer.TraceConsumer
+
+	startOnce sync.Once
+	stopOnce  sync.Once
+	server    *http.Server
+}
+
+func newReceiver(cfg *Config, nextConsumer consumer.TraceConsumer) (*receiver, error) {
+	if nextConsumer == nil {
+		return nil, componenterror.ErrNilNextConsumer
+	}
+
+	translator, err := newTranslator(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return &receiver{
+		cfg:          cfg,
+		translator:   translator,
+		nextConsumer: nextConsumer,
+	}, nil
+}
+
+// Start implements component.Receiver
+func (r *receiver) Start(_ context.Context, host component.Host) error {
+	if host == nil {
+		return errors.New("nil host")
+	}
+
+	err := componenterror.ErrAlreadyStarted
+	r.startOnce.Do(func() {
+		mux := http.NewServeMux()
+		mux.HandleFunc("/v1/logs", r.handleOTLPLogs)
+		mux.HandleFunc("/v1/log-lines", r.handleLogLines)
+		r.server = r.cfg.HTTPServerSettings.ToServer(mux)
+
+		listener, listenErr := r.cfg.HTTPServerSettings.ToListener()
+		if listenErr != nil {
+			err = listenErr
+			return
+		}
+		err = nil
+
+		go func() {
+			if serveErr := r.server.Serve(listener); serveErr != http.ErrServerClosed {
+				host.ReportFatalError(serveErr)
+			}
+		}()
+	})
+
+	return err
+}
+
+// Shutdown implements component.Receiver
+func (r *receiver) Shutdown(context.Context) error {
+	err := componenterror.ErrAlreadyStopped
+	r.stopOnce.Do(func() {
+		err = nil
+		if r.server != nil {
+			err = r.server.Close()
+		}
+	})
+	return err
+}
+
+// handleOTLPLogs accepts OTLP/HTTP logs export requests encoded as json.  The logs protos aren't available, so
+// protobuf requests are refused.
+func (r *receiver) handleOTLPLogs(w http.ResponseWriter, req *http.Request) {
+	if contentType, _, _ := mime.ParseMediaType(req.Header.Get("Content-Type")); contentType != "application/json" {
+		http.Error(w, "logs must be sent as OTLP/JSON", http.StatusUnsupportedMediaType)
+		return
+	}
+
+	r.handle(w, req, http.StatusOK, otlpLogGroups)
+}
+
+// handleLogLines accepts newline delimited json log lines.
+func (r *receiver) handleLogLines(w http.ResponseWriter, req *http.Request) {
+	r.handle(w, req, http.StatusAccepted, func(body []byte) ([]logGroup, error) {
+		group, err := ndjsonLogGroup(body)
+		return []logGroup{group}, err
+	})
+}
+
+func (r *receiver) handle(w http.ResponseWriter, req *http.Request, okStatus int, parse func([]byte) ([]logGroup, error)) {
+	if req.Method != http.MethodPost {
+		http.Error(w, "logs must be sent with POST", http.StatusMethodNotAllowed)
+		return
+	}
+
+	ctx := intake.RequestContext(req)
+	ctx = obsreport.ReceiverContext(ctx, r.cfg.Name(), transport, "")
+	ctx = obsreport.StartTraceDataReceiveOp(ctx, r.cfg.Name(), transport)
+
+	body, err := intake.ReadBody(req, maxPayloadBytes)
+	if err != nil {
+		obsreport.EndTraceDataReceiveOp(ctx, format, 0, err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	groups, err := parse(body)
+	if err != nil {
+		obsreport.EndTraceDataReceiveOp(ctx, format, 0, err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	batches, skipped, err := r.translator.toResourceSpans(groups)
+	if err != nil {
+		obsreport.EndTraceDataReceiveOp(ctx, format, 0, err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	metricSkippedLines.Add(float64(skipped))
+
+	spans := 0
+	for _, batch := range batches {
+		spans += len(batch.InstrumentationLibrarySpans[0].Spans)
+	}
+	if spans > 0 {
+		err = r.nextConsumer.ConsumeTraces(ctx, pdata.TracesFromOtlp(batches))
+	}
+	obsreport.EndTraceDataReceiveOp(ctx, format, spans, err)
+	if err != nil {
+		intake.WriteConsumeError(w, err)
+		return
+	}
+
+	w.WriteHeader(okStatus)
+}
@@ -0,0 +1,74 @@
+package logbridge
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/weaveworks/common/user"
+	"go.opentelemetry.io/collector/consumer/pdata"
+)
+
+type traceSink struct {
+	traces  []pdata.Traces
+	tenants []string
+}
+
+func (s *traceSink) ConsumeTraces(ctx context.Context, td pdata.Traces) error {
+	_, ctx, _ = user.ExtractFromGRPCRequest(ctx)
+	tenant, _ := user.ExtractOrgID(ctx)
+	s.tenants = append(s.tenants, tenant)
+	s.traces = append(s.traces, td)
+	return nil
+}
+
+func TestHandleLogs(t *testing.T) {
+	sink := &traceSink{}
+	r, err := newReceiver(testConfig(), sink)
+	require.NoError(t, err)
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/log-lines", strings.NewReader(testLines))
+	req.Header.Set(user.OrgIDHeaderName, "tenant-a")
+	w := httptest.NewRecorder()
+	r.handleLogLines(w, req)
+	assert.Equal(t, http.StatusAccepted, w.Code)
+	require.Len(t, sink.traces, 1)
+	assert.Equal(t, 3, sink.traces[0].SpanCount())
+	assert.Equal(t, []string{"tenant-a"}, sink.tenants)
+
+	// lines without spans are accepted and dropped
+	req = httptest.NewRequest(http.MethodPost, "/v1/log-lines", strings.NewReader(`{"msg": "hello"}`))
+	w = httptest.NewRecorder()
+	r.handleLogLines(w, req)
+	assert.Equal(t, http.StatusAccepted, w.Code)
+	assert.Len(t, sink.traces, 1)
+
+	req = httptest.NewRequest(http.MethodPost, "/v1/log-lines", strings.NewReader(`{"trace_id": "xyz", "span_id": "01"}`))
+	w = httptest.NewRecorder()
+	r.handleLogLines(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	req = httptest.NewRequest(http.MethodPost, "/v1/logs", strings.NewReader(`{"resourceLogs": [{"instrumentationLibraryLogs": [{"logs": [{"body": {"stringValue": "{\"trace_id\": \"01\", \"span_id\": \"02\", \"start_time\": 1}"}}]}]}]}`))
+	req.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	r.handleOTLPLogs(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	require.Len(t, sink.traces, 2)
+	assert.Equal(t, 1, sink.traces[1].SpanCount())
+
+	req = httptest.NewRequest(http.MethodPost, "/v1/logs", strings.NewReader(""))
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	w = httptest.NewRecorder()
+	r.handleOTLPLogs(w, req)
+	assert.Equal(t, http.StatusUnsupportedMediaType, w.Code)
+
+	req = httptest.NewRequest(http.MethodGet, "/v1/log-lines", nil)
+	w = httptest.NewRecorder()
+	r.handleLogLines(w, req)
+	assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+	assert.Len(t, sink.traces, 2)
+}
@@ -0,0 +1,497 @@
+package logbridge
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	v1_common "github.com/open-telemetry/opentelemetry-proto/gen/go/common/v1"
+	v1_resource "github.com/open-telemetry/opentelemetry-proto/gen/go/resource/v1"
+	v1 "github.com/open-telemetry/opentelemetry-proto/gen/go/trace/v1"
+)
+
+const (
+	attributeServiceName = "service.name"
+
+	// a single log line may not exceed this
+	maxLineBytes = 1 << 20
+)
+
+var timeUnits = map[string]time.Duration{
+	"s":  time.Second,
+	"ms": time.Millisecond,
+	"us": time.Microsecond,
+	"ns": time.Nanosecond,
+}
+
+// exportLogsRequest is the json encoding of an OTLP logs export request.  Only the parts the bridge reads are declared.
+type exportLogsRequest struct {
+	ResourceLogs []struct {
+		Resource struct {
+			Attributes []keyValue `json:"attributes"`
+		} `json:"resource"`
+		InstrumentationLibraryLogs []struct {
+			Logs []struct {
+				TimeUnixNano json.Number `json:"timeUnixNano"`
+				Body         anyValue    `json:"body"`
+				Attributes   []keyValue  `json:"attributes"`
+			} `json:"logs"`
+		} `json:"instrumentationLibraryLogs"`
+	} `json:"resourceLogs"`
+}
+
+type keyValue struct {
+	Key   string   `json:"key"`
+	Value anyValue `json:"value"`
+}
+
+// anyValue is the json encoding of an OTLP AnyValue.  64 bit integers may be encoded as strings.
+type anyValue struct {
+	StringValue *string      `json:"stringValue"`
+	BoolValue   *bool        `json:"boolValue"`
+	IntValue    *json.Number `json:"intValue"`
+	DoubleValue *float64     `json:"doubleValue"`
+	KvlistValue *struct {
+		Values []keyValue `json:"values"`
+	} `json:"kvlistValue"`
+	ArrayValue *struct {
+		Values []anyValue `json:"values"`
+	} `json:"arrayValue"`
+}
+
+// value returns v as a value decoded from json: a string, bool, json.Number, float64, map or slice.
+func (v anyValue) value() interface{} {
+	switch {
+	case v.StringValue != nil:
+		return *v.StringValue
+	case v.BoolValue != nil:
+		return *v.BoolValue
+	case v.IntValue != nil:
+		return *v.IntValue
+	case v.DoubleValue != nil:
+		return *v.DoubleValue
+	case v.KvlistValue != nil:
+		return keyValues(v.KvlistValue.Values)
+	case v.ArrayValue != nil:
+		values := make([]interface{}, 0, len(v.ArrayValue.Values))
+		for _, av := range v.ArrayValue.Values {
+			values = append(values, av.value())
+		}
+		return values
+	}
+	return nil
+}
+
+func keyValues(kvs []keyValue) map[string]interface{} {
+	m := make(map[string]interface{}, len(kvs))
+	for _, kv := range kvs {
+		m[kv.Key] = kv.Value.value()
+	}
+	return m
+}
+
+// logLine is a structured log line, which may carry a span.
+type logLine struct {
+	fields map[string]interface{}
+	// when the line was logged in nanoseconds since the epoch, or 0 if not known
+	timestamp uint64
+}
+
+// logGroup holds lines logged by the same resource.
+type logGroup struct {
+	resource map[string]interface{}
+	lines    []logLine
+}
+
+// otlpLogGroups returns the lines of an OTLP/JSON logs export request.  A body holding a json object, as a string or
+// as a key value list, provides the fields of its line.  The attributes of the record fill in the fields the body
+// doesn't have.
+func otlpLogGroups(body []byte) ([]logGroup, error) {
+	req := exportLogsRequest{}
+	err := json.Unmarshal(body, &req)
+	if err != nil {
+		return nil, err
+	}
+
+	groups := make([]logGroup, 0, len(req.ResourceLogs))
+	for _, rl := range req.ResourceLogs {
+		group := logGroup{
+			resource: keyValues(rl.Resource.Attributes),
+		}
+		for _, ill := range rl.InstrumentationLibraryLogs {
+			for _, record := range ill.Logs {
+				fields, _ := record.Body.value().(map[string]interface{})
+				if s, ok := record.Body.value().(string); ok {
+					fields, _ = decodeObject([]byte(s))
+				}
+				if fields == nil {
+					fields = map[string]interface{}{}
+				}
+				for k, v := range keyValues(record.Attributes) {
+					if _, ok := fields[k]; !ok {
+						fields[k] = v
+					}
+				}
+
+				line := logLine{fields: fields}
+				if record.TimeUnixNano != "" {
+					line.timestamp, err = strconv.ParseUint(record.TimeUnixNano.String(), 10, 64)
+					if err != nil {
+						return nil, fmt.Errorf("invalid log timestamp: %w", err)
+					}
+				}
+				group.lines = append(group.lines, line)
+			}
+		}
+		groups = append(groups, group)
+	}
+
+	return groups, nil
+}
+
+// ndjsonLogGroup returns the lines of newline delimited json objects.  Lines that aren't json objects have no fields.
+func ndjsonLogGroup(body []byte) (logGroup, error) {
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLineBytes)
+
+	group := logGroup{}
+	for scanner.Scan() {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		fields, _ := decodeObject(scanner.Bytes())
+		group.lines = append(group.lines, logLine{fields: fields})
+	}
+
+	return group, scanner.Err()
+}
+
+func decodeObject(b []byte) (map[string]interface{}, bool) {
+	b = bytes.TrimSpace(b)
+	if len(b) == 0 || b[0] != '{' {
+		return nil, false
+	}
+
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.UseNumber()
+	fields := map[string]interface{}{}
+	if err := d.Decode(&fields); err != nil {
+		return nil, false
+	}
+	return fields, true
+}
+
+type translator struct {
+	fields FieldsConfig
+	unit   time.Duration
+}
+
+func newTranslator(cfg *Config) (*translator, error) {
+	unit, ok := timeUnits[cfg.TimeUnit]
+	if !ok {
+		return nil, fmt.Errorf("unknown time_unit %q, must be s, ms, us or ns", cfg.TimeUnit)
+	}
+	if cfg.Fields.TraceID == "" || cfg.Fields.SpanID == "" {
+		return nil, fmt.Errorf("the trace_id and span_id fields must be set")
+	}
+
+	return &translator{
+		fields: cfg.Fields,
+		unit:   unit,
+	}, nil
+}
+
+// toResourceSpans converts the lines carrying a span to batches, one per resource and service.  It also returns the
+// number of lines skipped because they carry no span.  A line with a trace and span id whose span can't be read is an
+// error.
+func (t *translator) toResourceSpans(groups []logGroup) ([]*v1.ResourceSpans, int, error) {
+	var batches []*v1.ResourceSpans
+	skipped := 0
+	for _, group := range groups {
+		byService := map[string]*v1.ResourceSpans{}
+		resourceService, _ := group.resource[attributeServiceName].(string)
+
+		for i, line := range group.lines {
+			span, ok, err := t.toSpan(line)
+			if err != nil {
+				return nil, 0, fmt.Errorf("line %d: %w", i+1, err)
+			}
+			if !ok {
+				skipped++
+				continue
+			}
+
+			service, _ := t.lookup(line.fields, t.fields.ServiceName).(string)
+			if service == "" {
+				service = resourceService
+			}
+
+			batch, ok := byService[service]
+			if !ok {
+				batch = newResourceSpans(group.resource, service)
+				byService[service] = batch
+				batches = append(batches, batch)
+			}
+			batch.InstrumentationLibrarySpans[0].Spans = append(batch.InstrumentationLibrarySpans[0].Spans, span)
+		}
+	}
+
+	return batches, skipped, nil
+}
+
+func newResourceSpans(attributes map[string]interface{}, service string) *v1.ResourceSpans {
+	resource := &v1_resource.Resource{}
+	for k, v := range attributes {
+		if k != attributeServiceName {
+			resource.Attributes = append(resource.Attributes, attribute(k, v))
+		}
+	}
+	if service != "" {
+		resource.Attributes = append(resource.Attributes, attribute(attributeServiceName, service))
+	}
+	sortAttributes(resource.Attributes)
+
+	return &v1.ResourceSpans{
+		Resource:                    resource,
+		InstrumentationLibrarySpans: []*v1.InstrumentationLibrarySpans{{}},
+	}
+}
+
+// toSpan returns the span of a line, or false if the line has no trace or span id.  Without a start time, the line is
+// taken to be logged when the span ended.
+func (t *translator) toSpan(line logLine) (*v1.Span, bool, error) {
+	traceIDValue, _ := t.lookup(line.fields, t.fields.TraceID).(string)
+	spanIDValue, _ := t.lookup(line.fields, t.fields.SpanID).(string)
+	if traceIDValue == "" || spanIDValue == "" {
+		return nil, false, nil
+	}
+
+	traceID, err := decodeID(traceIDValue, 16)
+	if err != nil {
+		return nil, false, fmt.Errorf("invalid trace id: %w", err)
+	}
+	spanID, err := decodeID(spanIDValue, 8)
+	if err != nil {
+		return nil, false, fmt.Errorf("invalid span id: %w", err)
+	}
+	var parentID []byte
+	if v, _ := t.lookup(line.fields, t.fields.ParentSpanID).(string); v != "" {
+		parentID, err = decodeID(v, 8)
+		if err != nil {
+			return nil, false, fmt.Errorf("invalid parent span id: %w", err)
+		}
+	}
+
+	start, err := t.timestamp(t.lookup(line.fields, t.fields.StartTime))
+	if err != nil {
+		return nil, false, fmt.Errorf("invalid start time: %w", err)
+	}
+	end, err := t.timestamp(t.lookup(line.fields, t.fields.EndTime))
+	if err != nil {
+		return nil, false, fmt.Errorf("invalid end time: %w", err)
+	}
+	duration, err := t.duration(t.lookup(line.fields, t.fields.Duration))
+	if err != nil {
+		return nil, false, fmt.Errorf("invalid duration: %w", err)
+	}
+	if start == 0 && end == 0 {
+		end = line.timestamp
+	}
+	if start == 0 && end >= duration {
+		start = end - duration
+	}
+	if end == 0 {
+		end = start + duration
+	}
+	if start == 0 {
+		return nil, false, fmt.Errorf("missing start time")
+	}
+
+	name, _ := t.lookup(line.fields, t.fields.Name).(string)
+	kind, _ := t.lookup(line.fields, t.fields.Kind).(string)
+	span := &v1.Span{
+		TraceId:           traceID,
+		SpanId:            spanID,
+		ParentSpanId:      parentID,
+		Name:              name,
+		Kind:              spanKind(kind),
+		StartTimeUnixNano: start,
+		EndTimeUnixNano:   end,
+	}
+
+	if failed, message := isError(t.lookup(line.fields, t.fields.Error)); failed {
+		span.Status = &v1.Status{Code: v1.Status_UnknownError, Message: message}
+	}
+
+	for _, name := range t.fields.Attributes {
+		if v := t.lookup(line.fields, name); v != nil {
+			span.Attributes = append(span.Attributes, attribute(name, v))
+		}
+	}
+	sortAttributes(span.Attributes)
+
+	return span, true, nil
+}
+
+// lookup returns the field of the given name.  A name not found as is is looked up as a path through nested objects.
+func (t *translator) lookup(fields map[string]interface{}, name string) interface{} {
+	if name == "" {
+		return nil
+	}
+	if v, ok := fields[name]; ok {
+		return v
+	}
+
+	parts := strings.SplitN(name, ".", 2)
+	if len(parts) < 2 {
+		return nil
+	}
+	nested, ok := fields[parts[0]].(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	return t.lookup(nested, parts[1])
+}
+
+// timestamp converts an RFC3339 time or a number of time units since the epoch to nanoseconds since the epoch.
+func (t *translator) timestamp(v interface{}) (uint64, error) {
+	if s, ok := v.(string); ok {
+		if ts, err := time.Parse(time.RFC3339Nano, s); err == nil {
+			return uint64(ts.UnixNano()), nil
+		}
+	}
+
+	return t.number(v)
+}
+
+// duration converts a duration such as 1.5s or a number of time units to nanoseconds.
+func (t *translator) duration(v interface{}) (uint64, error) {
+	if s, ok := v.(string); ok {
+		if d, err := time.ParseDuration(s); err == nil {
+			return uint64(d), nil
+		}
+	}
+
+	return t.number(v)
+}
+
+// number converts a number of time units to nanoseconds.  Whole numbers are converted exactly.  A missing value is 0.
+func (t *translator) number(v interface{}) (uint64, error) {
+	var s string
+	switch v := v.(type) {
+	case nil:
+		return 0, nil
+	case json.Number:
+		s = v.String()
+	case float64:
+		s = strconv.FormatFloat(v, 'f', -1, 64)
+	case string:
+		s = v
+	default:
+		return 0, fmt.Errorf("%v is not a number", v)
+	}
+
+	if n, err := strconv.ParseUint(s, 10, 64); err == nil {
+		return n * uint64(t.unit), nil
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, err
+	}
+	if f < 0 {
+		return 0, fmt.Errorf("%v is negative", v)
+	}
+	return uint64(f * float64(t.unit)), nil
+}
+
+func spanKind(kind string) v1.Span_SpanKind {
+	switch strings.TrimPrefix(strings.ToLower(kind), "span_kind_") {
+	case "server":
+		return v1.Span_SERVER
+	case "client":
+		return v1.Span_CLIENT
+	case "producer":
+		return v1.Span_PRODUCER
+	case "consumer":
+		return v1.Span_CONSUMER
+	case "internal":
+		return v1.Span_INTERNAL
+	}
+	return v1.Span_SPAN_KIND_UNSPECIFIED
+}
+
+// isError returns whether the error field marks the span as failed and, if it holds one, the error message.  True,
+// non-zero numbers and messages other than "false" mark spans as failed.
+func isError(v interface{}) (bool, string) {
+	switch v := v.(type) {
+	case bool:
+		return v, ""
+	case json.Number:
+		f, err := v.Float64()
+		return err == nil && f != 0, ""
+	case float64:
+		return v != 0, ""
+	case string:
+		switch strings.ToLower(v) {
+		case "", "false", "0":
+			return false, ""
+		case "true", "1":
+			return true, ""
+		}
+		return true, v
+	}
+	return false, ""
+}
+
+// decodeID decodes a hex id, ignoring dashes so uuids can be used.  Shorter ids are left padded with zeros.
+func decodeID(id string, length int) ([]byte, error) {
+	b, err := hex.DecodeString(strings.ReplaceAll(id, "-", ""))
+	if err != nil {
+		return nil, err
+	}
+	if len(b) > length {
+		return nil, fmt.Errorf("%q is longer than %d bytes", id, length)
+	}
+	return append(make([]byte, length-len(b)), b...), nil
+}
+
+// attribute converts a value decoded from json to an attribute.  Objects and arrays are kept as their json encoding.
+func attribute(k string, v interface{}) *v1_common.KeyValue {
+	value := &v1_common.AnyValue{}
+	switch v := v.(type) {
+	case string:
+		value.Value = &v1_common.AnyValue_StringValue{StringValue: v}
+	case bool:
+		value.Value = &v1_common.AnyValue_BoolValue{BoolValue: v}
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			value.Value = &v1_common.AnyValue_IntValue{IntValue: i}
+		} else if f, err := v.Float64(); err == nil {
+			value.Value = &v1_common.AnyValue_DoubleValue{DoubleValue: f}
+		} else {
+			value.Value = &v1_common.AnyValue_StringValue{StringValue: v.String()}
+		}
+	case float64:
+		value.Value = &v1_common.AnyValue_DoubleValue{DoubleValue: v}
+	default:
+		b, _ := json.Marshal(v)
+		value.Value = &v1_common.AnyValue_StringValue{StringValue: string(b)}
+	}
+
+	return &v1_common.KeyValue{
+		Key:   k,
+		Value: value,
+	}
+}
+
+func sortAttributes(attributes []*v1_common.KeyValue) {
+	sort.Slice(attributes, func(i, j int) bool {
+		return attributes[i].Key < attributes[j].Key
+	})
+}
@@ -0,0 +1,152 @@
+package logbridge
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	v1 "github.com/open-telemetry/opentelemetry-proto/gen/go/trace/v1"
+)
+
+const testLines = `{"msg": "request done", "trace_id": "0102030405060708090a0b0c0d0e0f10", "span_id": "0102030405060708", "name": "GET /cart", "service_name": "checkout", "kind": "server", "start_time": "2020-09-13T12:26:40Z", "duration": 12.5, "error": "connection reset", "http": {"status_code": 500}}
+{"msg": "cache miss"}
+not json
+{"trace_id": "0708090a0b0c0d0e", "span_id": "1112131415161718", "parent_span_id": "0102030405060708", "name": "SELECT cart", "service_name": "checkout", "kind": "SPAN_KIND_CLIENT", "start_time": 1600000000002, "end_time": 1600000000005, "error": false}
+{"trace_id": "01020304-0506-0708-090a-0b0c0d0e0f10", "span_id": "2122232425262728", "name": "render", "service_name": "templates", "start_time": 1600000000005, "duration": "1ms"}
+`
+
+func testConfig() *Config {
+	cfg := createDefaultConfig().(*Config)
+	cfg.Fields.Attributes = []string{"http.status_code", "msg"}
+	return cfg
+}
+
+func TestToResourceSpansLines(t *testing.T) {
+	tr, err := newTranslator(testConfig())
+	require.NoError(t, err)
+
+	group, err := ndjsonLogGroup([]byte(testLines))
+	require.NoError(t, err)
+	batches, skipped, err := tr.toResourceSpans([]logGroup{group})
+	require.NoError(t, err)
+	assert.Equal(t, 2, skipped)
+
+	require.Len(t, batches, 2)
+	require.Len(t, batches[0].Resource.Attributes, 1)
+	assert.Equal(t, "checkout", batches[0].Resource.Attributes[0].Value.GetStringValue())
+	assert.Equal(t, "templates", batches[1].Resource.Attributes[0].Value.GetStringValue())
+
+	spans := batches[0].InstrumentationLibrarySpans[0].Spans
+	require.Len(t, spans, 2)
+
+	cart := spans[0]
+	assert.Equal(t, []byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16}, cart.TraceId)
+	assert.Nil(t, cart.ParentSpanId)
+	assert.Equal(t, v1.Span_SERVER, cart.Kind)
+	assert.Equal(t, uint64(1600000000000000000), cart.StartTimeUnixNano)
+	assert.Equal(t, uint64(1600000000012500000), cart.EndTimeUnixNano)
+	assert.Equal(t, &v1.Status{Code: v1.Status_UnknownError, Message: "connection reset"}, cart.Status)
+	require.Len(t, cart.Attributes, 2)
+	assert.Equal(t, "http.status_code", cart.Attributes[0].Key)
+	assert.Equal(t, int64(500), cart.Attributes[0].Value.GetIntValue())
+	assert.Equal(t, "request done", cart.Attributes[1].Value.GetStringValue())
+
+	// 64 bit trace ids are padded
+	db := spans[1]
+	assert.Equal(t, append(make([]byte, 8), 7, 8, 9, 10, 11, 12, 13, 14), db.TraceId)
+	assert.Equal(t, cart.SpanId, db.ParentSpanId)
+	assert.Equal(t, v1.Span_CLIENT, db.Kind)
+	assert.Equal(t, uint64(1600000000002000000), db.StartTimeUnixNano)
+	assert.Equal(t, uint64(1600000000005000000), db.EndTimeUnixNano)
+	assert.Nil(t, db.Status)
+
+	render := batches[1].InstrumentationLibrarySpans[0].Spans[0]
+	assert.Equal(t, cart.TraceId, render.TraceId)
+	assert.Equal(t, v1.Span_SPAN_KIND_UNSPECIFIED, render.Kind)
+	assert.Equal(t, uint64(1600000000006000000), render.EndTimeUnixNano)
+}
+
+const testOTLPLogs = `{"resourceLogs": [{
+	"resource": {"attributes": [{"key": "service.name", "value": {"stringValue": "legacy"}}, {"key": "host.name", "value": {"stringValue": "vm-1"}}]},
+	"instrumentationLibraryLogs": [{"logs": [
+		{"timeUnixNano": "1600000000010000000", "body": {"stringValue": "{\"span\": {\"trace_id\": \"0102030405060708090a0b0c0d0e0f10\", \"id\": \"0102030405060708\"}, \"op\": \"GET /\", \"took\": 10}"}},
+		{"timeUnixNano": 1600000000020000000, "body": {"kvlistValue": {"values": [{"key": "op", "value": {"stringValue": "POST /"}}, {"key": "took", "value": {"intValue": "5"}}]}},
+		 "attributes": [{"key": "span.trace_id", "value": {"stringValue": "0102030405060708090a0b0c0d0e0f10"}}, {"key": "span.id", "value": {"stringValue": "1112131415161718"}}]},
+		{"body": {"stringValue": "plain text"}}
+	]}]
+}]}`
+
+func TestToResourceSpansOTLP(t *testing.T) {
+	cfg := createDefaultConfig().(*Config)
+	cfg.Fields = FieldsConfig{
+		TraceID:  "span.trace_id",
+		SpanID:   "span.id",
+		Name:     "op",
+		Duration: "took",
+	}
+	tr, err := newTranslator(cfg)
+	require.NoError(t, err)
+
+	groups, err := otlpLogGroups([]byte(testOTLPLogs))
+	require.NoError(t, err)
+	batches, skipped, err := tr.toResourceSpans(groups)
+	require.NoError(t, err)
+	assert.Equal(t, 1, skipped)
+
+	require.Len(t, batches, 1)
+	require.Len(t, batches[0].Resource.Attributes, 2)
+	assert.Equal(t, "vm-1", batches[0].Resource.Attributes[0].Value.GetStringValue())
+	assert.Equal(t, "legacy", batches[0].Resource.Attributes[1].Value.GetStringValue())
+
+	// without a start time the line is logged when the span ends
+	spans := batches[0].InstrumentationLibrarySpans[0].Spans
+	require.Len(t, spans, 2)
+	assert.Equal(t, "GET /", spans[0].Name)
+	assert.Equal(t, uint64(1600000000000000000), spans[0].StartTimeUnixNano)
+	assert.Equal(t, uint64(1600000000010000000), spans[0].EndTimeUnixNano)
+	assert.Equal(t, "POST /", spans[1].Name)
+	assert.Equal(t, []byte{0x11, 0x12, 0x13, 0x14, 0x15, 0x16, 0x17, 0x18}, spans[1].SpanId)
+	assert.Equal(t, uint64(1600000000015000000), spans[1].StartTimeUnixNano)
+}
+
+func TestToResourceSpansErrors(t *testing.T) {
+	tr, err := newTranslator(testConfig())
+	require.NoError(t, err)
+
+	tcs := []struct {
+		name string
+		line string
+	}{
+		{
+			name: "invalid trace id",
+			line: `{"trace_id": "xyz", "span_id": "0102030405060708", "start_time": 1}`,
+		},
+		{
+			name: "long span id",
+			line: `{"trace_id": "01", "span_id": "010203040506070809", "start_time": 1}`,
+		},
+		{
+			name: "missing start time",
+			line: `{"trace_id": "01", "span_id": "01", "duration": 1}`,
+		},
+		{
+			name: "invalid duration",
+			line: `{"trace_id": "01", "span_id": "01", "start_time": 1, "duration": "long"}`,
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			group, err := ndjsonLogGroup([]byte(tc.line))
+			require.NoError(t, err)
+			_, _, err = tr.toResourceSpans([]logGroup{group})
+			assert.Error(t, err)
+		})
+	}
+
+	cfg := testConfig()
+	cfg.TimeUnit = "days"
+	_, err = newTranslator(cfg)
+	assert.Error(t, err)
+}
@@ -0,0 +1,110 @@
+package receiver
+
+import (
+	"context"
+	"strings"
+
+	"go.opencensus.io/tag"
+	"go.opentelemetry.io/collector/client"
+	"go.opentelemetry.io/collector/consumer/pdata"
+	"go.opentelemetry.io/collector/obsreport"
+	"google.golang.org/grpc/metadata"
+)
+
+const (
+	tenantSourceHeader  = "header"
+	tenantSourceDefault = "default"
+
+	forwardedForHeader = "x-forwarded-for"
+
+	attributeReceiver     = "tempo.receiver"
+	attributeTransport    = "tempo.receiver.transport"
+	attributeTenantSource = "tempo.tenant.source"
+)
+
+// AttributeClientIP is the resource attribute holding the ip of the client that sent a batch.  It's only added if
+// resource attributes are enabled in the receiver metadata config.
+const AttributeClientIP = "tempo.client.ip"
+
+var (
+	tagKeyReceiver  = tag.MustNewKey(obsreport.ReceiverKey)
+	tagKeyTransport = tag.MustNewKey(obsreport.TransportKey)
+)
+
+// MetadataConfig controls what is recorded about the source of received spans.
+type MetadataConfig struct {
+	// ResourceAttributes adds the receiver, transport, client ip and tenant source to the resource of every batch.
+	ResourceAttributes bool `yaml:"resource_attributes"`
+	// TrustForwardedFor takes the client ip from the X-Forwarded-For header when present. Only enable this if
+	//  every client connects through a proxy that sets the header.
+	TrustForwardedFor bool `yaml:"trust_forwarded_for"`
+}
+
+type receiverMetadata struct {
+	receiver     string
+	transport    string
+	clientIP     string
+	tenantSource string
+}
+
+// metadataFromContext reads the receiver and client details the otel receivers store in the context.
+func metadataFromContext(ctx context.Context, tenantSource string, trustForwardedFor bool) receiverMetadata {
+	md := receiverMetadata{
+		tenantSource: tenantSource,
+	}
+
+	if tags := tag.FromContext(ctx); tags != nil {
+		md.receiver, _ = tags.Value(tagKeyReceiver)
+		md.transport, _ = tags.Value(tagKeyTransport)
+	}
+
+	if c, ok := client.FromContext(ctx); ok {
+		md.clientIP = c.IP
+	}
+
+	if trustForwardedFor {
+		if grpcMD, ok := metadata.FromIncomingContext(ctx); ok {
+			if forwarded := grpcMD.Get(forwardedForHeader); len(forwarded) > 0 {
+				// the first address is the original client. subsequent addresses are proxies
+				md.clientIP = strings.TrimSpace(strings.Split(forwarded[0], ",")[0])
+			}
+		}
+	}
+
+	return md
+}
+
+// Source returns the receiver and transport the spans of the context were received through.  Both are empty for spans
+// that weren't received by a receiver.
+func Source(ctx context.Context) (receiver string, transport string) {
+	md := metadataFromContext(ctx, "", false)
+	return md.receiver, md.transport
+}
+
+func (md receiverMetadata) addResourceAttributes(td pdata.Traces) {
+	rss := td.ResourceSpans()
+	for i := 0; i < rss.Len(); i++ {
+		rs := rss.At(i)
+		if rs.IsNil() {
+			continue
+		}
+
+		resource := rs.Resource()
+		if resource.IsNil() {
+			resource.InitEmpty()
+		}
+
+		attrs := resource.Attributes()
+		upsertNonEmpty(attrs, attributeReceiver, md.receiver)
+		upsertNonEmpty(attrs, attributeTransport, md.transport)
+		upsertNonEmpty(attrs, AttributeClientIP, md.clientIP)
+		upsertNonEmpty(attrs, attributeTenantSource, md.tenantSource)
+	}
+}
+
+func upsertNonEmpty(attrs pdata.AttributeMap, k string, v string) {
+	if v == "" {
+		return
+	}
+	attrs.UpsertString(k, v)
+}
@@ -0,0 +1,66 @@
+package receiver
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/collector/client"
+	"go.opentelemetry.io/collector/consumer/pdata"
+	"go.opentelemetry.io/collector/obsreport"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestMetadataFromContext(t *testing.T) {
+	ctx := obsreport.ReceiverContext(context.Background(), "otlp", "grpc", "")
+	ctx = client.NewContext(ctx, &client.Client{IP: "10.0.0.1"})
+	ctx = metadata.NewIncomingContext(ctx, metadata.Pairs(forwardedForHeader, "192.168.1.1, 10.0.0.2"))
+
+	md := metadataFromContext(ctx, tenantSourceHeader, false)
+	assert.Equal(t, receiverMetadata{
+		receiver:     "otlp",
+		transport:    "grpc",
+		clientIP:     "10.0.0.1",
+		tenantSource: tenantSourceHeader,
+	}, md)
+
+	md = metadataFromContext(ctx, tenantSourceHeader, true)
+	assert.Equal(t, "192.168.1.1", md.clientIP)
+
+	md = metadataFromContext(context.Background(), tenantSourceDefault, true)
+	assert.Equal(t, receiverMetadata{tenantSource: tenantSourceDefault}, md)
+}
+
+func TestSource(t *testing.T) {
+	receiver, transport := Source(obsreport.ReceiverContext(context.Background(), "zipkin", "http", ""))
+	assert.Equal(t, "zipkin", receiver)
+	assert.Equal(t, "http", transport)
+
+	receiver, transport = Source(context.Background())
+	assert.Empty(t, receiver)
+	assert.Empty(t, transport)
+}
+
+func TestAddResourceAttributes(t *testing.T) {
+	td := pdata.NewTraces()
+	td.ResourceSpans().Resize(1)
+
+	md := receiverMetadata{
+		receiver:     "jaeger",
+		clientIP:     "10.0.0.1",
+		tenantSource: tenantSourceDefault,
+	}
+	md.addResourceAttributes(td)
+
+	attrs := td.ResourceSpans().At(0).Resource().Attributes()
+	assert.Equal(t, 3, attrs.Len())
+
+	v, ok := attrs.Get(attributeReceiver)
+	assert.True(t, ok)
+	assert.Equal(t, "jaeger", v.StringVal())
+	v, ok = attrs.Get(AttributeClientIP)
+	assert.True(t, ok)
+	assert.Equal(t, "10.0.0.1", v.StringVal())
+	_, ok = attrs.Get(attributeTransport)
+	assert.False(t, ok)
+}
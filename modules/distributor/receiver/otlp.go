@@ -0,0 +1,95 @@
+package receiver
+
+const (
+	otlpReceiver = "otlp"
+
+	// DefaultOTLPGRPCEndpoint and DefaultOTLPHTTPEndpoint are the IANA registered OTLP ports.  The vendored collector
+	// still defaults to the legacy 55680/55681.
+	DefaultOTLPGRPCEndpoint = "0.0.0.0:4317"
+	DefaultOTLPHTTPEndpoint = "0.0.0.0:4318"
+)
+
+var otlpDefaultEndpoints = map[string]string{
+	"grpc": DefaultOTLPGRPCEndpoint,
+	"http": DefaultOTLPHTTPEndpoint,
+}
+
+// applyOTLPDefaults returns a copy of the receiver config in which every enabled otlp protocol without an explicit
+// endpoint listens on its standard port.  Yaml decodes nested nodes as map[interface{}]interface{} while flags and
+// code use map[string]interface{} so both are handled.
+func applyOTLPDefaults(receiverCfg map[string]interface{}) map[string]interface{} {
+	otlp, ok := receiverCfg[otlpReceiver]
+	if !ok {
+		return receiverCfg
+	}
+
+	protocols, ok := lookup(otlp, "protocols")
+	if !ok || protocols == nil {
+		return receiverCfg
+	}
+
+	newProtocols := map[string]interface{}{}
+	for _, name := range keys(protocols) {
+		settings, _ := lookup(protocols, name)
+
+		endpoint, hasDefault := otlpDefaultEndpoints[name]
+		if !hasDefault {
+			newProtocols[name] = settings
+			continue
+		}
+		if current, _ := lookup(settings, "endpoint"); current != nil && current != "" {
+			newProtocols[name] = settings
+			continue
+		}
+
+		newSettings := map[string]interface{}{}
+		for _, k := range keys(settings) {
+			newSettings[k], _ = lookup(settings, k)
+		}
+		newSettings["endpoint"] = endpoint
+		newProtocols[name] = newSettings
+	}
+
+	newOTLP := map[string]interface{}{}
+	for _, k := range keys(otlp) {
+		newOTLP[k], _ = lookup(otlp, k)
+	}
+	newOTLP["protocols"] = newProtocols
+
+	newCfg := make(map[string]interface{}, len(receiverCfg))
+	for k, v := range receiverCfg {
+		newCfg[k] = v
+	}
+	newCfg[otlpReceiver] = newOTLP
+
+	return newCfg
+}
+
+func lookup(node interface{}, key string) (interface{}, bool) {
+	switch m := node.(type) {
+	case map[string]interface{}:
+		v, ok := m[key]
+		return v, ok
+	case map[interface{}]interface{}:
+		v, ok := m[key]
+		return v, ok
+	}
+	return nil, false
+}
+
+func keys(node interface{}) []string {
+	var ks []string
+	switch m := node.(type) {
+	case map[string]interface{}:
+		for k := range m {
+			ks = append(ks, k)
+		}
+	case map[interface{}]interface{}:
+		for k := range m {
+			if s, ok := k.(string); ok {
+				ks = append(ks, s)
+			}
+		}
+	}
+	return ks
+}
@@ -0,0 +1,161 @@
+package receiver
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/cortexproject/cortex/pkg/util/services"
+	v1_common "github.com/open-telemetry/opentelemetry-proto/gen/go/common/v1"
+	v1_resource "github.com/open-telemetry/opentelemetry-proto/gen/go/resource/v1"
+	v1 "github.com/open-telemetry/opentelemetry-proto/gen/go/trace/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/weaveworks/common/logging"
+	"google.golang.org/grpc"
+
+	"github.com/grafana/tempo/pkg/tempopb"
+)
+
+func TestApplyOTLPDefaults(t *testing.T) {
+	tests := []struct {
+		name     string
+		cfg      map[string]interface{}
+		expected map[string]interface{}
+	}{
+		{
+			name:     "no otlp",
+			cfg:      map[string]interface{}{"zipkin": nil},
+			expected: map[string]interface{}{"zipkin": nil},
+		},
+		{
+			name: "empty protocols",
+			cfg: map[string]interface{}{
+				"otlp": map[string]interface{}{
+					"protocols": map[string]interface{}{
+						"grpc": nil,
+						"http": nil,
+					},
+				},
+			},
+			expected: map[string]interface{}{
+				"otlp": map[string]interface{}{
+					"protocols": map[string]interface{}{
+						"grpc": map[string]interface{}{"endpoint": DefaultOTLPGRPCEndpoint},
+						"http": map[string]interface{}{"endpoint": DefaultOTLPHTTPEndpoint},
+					},
+				},
+			},
+		},
+		{
+			name: "yaml maps and explicit endpoint",
+			cfg: map[string]interface{}{
+				"otlp": map[interface{}]interface{}{
+					"protocols": map[interface{}]interface{}{
+						"grpc": map[interface{}]interface{}{
+							"endpoint": "0.0.0.0:55680",
+						},
+						"http": map[interface{}]interface{}{
+							"cors_allowed_origins": []interface{}{"*"},
+						},
+					},
+				},
+			},
+			expected: map[string]interface{}{
+				"otlp": map[string]interface{}{
+					"protocols": map[string]interface{}{
+						"grpc": map[interface{}]interface{}{
+							"endpoint": "0.0.0.0:55680",
+						},
+						"http": map[string]interface{}{
+							"endpoint":             DefaultOTLPHTTPEndpoint,
+							"cors_allowed_origins": []interface{}{"*"},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, applyOTLPDefaults(tt.cfg))
+		})
+	}
+}
+
+// TestOTLPReceiver checks that batches exported over otlp grpc and http reach the pusher and are routed to a tenant
+// by resource attribute.
+func TestOTLPReceiver(t *testing.T) {
+	grpcEndpoint := freeAddress(t)
+	httpEndpoint := freeAddress(t)
+	pusher := &requestRecorder{}
+
+	l := logging.Level{}
+	require.NoError(t, l.Set("error"))
+	shim, err := New(map[string]interface{}{
+		"otlp": map[string]interface{}{
+			"protocols": map[string]interface{}{
+				"grpc": map[string]interface{}{"endpoint": grpcEndpoint},
+				"http": map[string]interface{}{"endpoint": httpEndpoint},
+			},
+		},
+	}, MetadataConfig{}, TenantRoutingConfig{
+		Rules: []TenantRoutingRule{{Attribute: "k8s.namespace.name"}},
+	}, BackpressureConfig{}, pusher, false, l)
+	require.NoError(t, err)
+	require.NoError(t, services.StartAndAwaitRunning(context.Background(), shim))
+	defer func() {
+		_ = services.StopAndAwaitTerminated(context.Background(), shim)
+	}()
+
+	// tempopb.Trace is wire compatible with ExportTraceServiceRequest: both hold the resource spans in field 1
+	conn, err := grpc.Dial(grpcEndpoint, grpc.WithInsecure())
+	require.NoError(t, err)
+	defer conn.Close()
+	err = conn.Invoke(context.Background(), "/opentelemetry.proto.collector.trace.v1.TraceService/Export",
+		exportRequest("team-a", "team-b"), &tempopb.PushResponse{})
+	require.NoError(t, err)
+
+	body, err := exportRequest("team-c").Marshal()
+	require.NoError(t, err)
+	resp, err := http.Post("http://"+httpEndpoint+"/v1/trace", "application/x-protobuf", bytes.NewReader(body))
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	require.Eventually(t, func() bool {
+		return len(pusher.requests()) == 3
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, []string{"team-a", "team-b", "team-c"}, pusher.requestTenants())
+}
+
+func exportRequest(namespaces ...string) *tempopb.Trace {
+	req := &tempopb.Trace{}
+	for i, ns := range namespaces {
+		req.Batches = append(req.Batches, &v1.ResourceSpans{
+			Resource: &v1_resource.Resource{
+				Attributes: []*v1_common.KeyValue{
+					{
+						Key:   "k8s.namespace.name",
+						Value: &v1_common.AnyValue{Value: &v1_common.AnyValue_StringValue{StringValue: ns}},
+					},
+				},
+			},
+			InstrumentationLibrarySpans: []*v1.InstrumentationLibrarySpans{
+				{
+					Spans: []*v1.Span{
+						{
+							TraceId: []byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0a, 0x0b, 0x0c, 0x0d, 0x0e, 0x0f, byte(i)},
+							SpanId:  []byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, byte(i)},
+							Name:    "span",
+						},
+					},
+				},
+			},
+		})
+	}
+	return req
+}
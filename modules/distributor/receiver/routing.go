@@ -0,0 +1,74 @@
+package receiver
+
+import (
+	"fmt"
+
+	"go.opentelemetry.io/collector/consumer/pdata"
+)
+
+const (
+	tenantSourceAttribute = "attribute"
+)
+
+// TenantRoutingConfig selects the tenant of each batch from its resource attributes.  This allows soft multi-tenancy
+// when several clusters share one collector pipeline.
+type TenantRoutingConfig struct {
+	// Rules are evaluated in order against the resource attributes of every batch.  The first match wins.
+	Rules []TenantRoutingRule `yaml:"rules"`
+	// DefaultTenant is used for batches no rule matches.  If empty they keep the tenant of the request.
+	DefaultTenant string `yaml:"default_tenant"`
+}
+
+// TenantRoutingRule matches a single string resource attribute.
+type TenantRoutingRule struct {
+	// Attribute is the resource attribute to inspect, e.g. k8s.namespace.name
+	Attribute string `yaml:"attribute"`
+	// Value restricts the rule to a single attribute value.  If empty any non-empty value matches.
+	Value string `yaml:"value"`
+	// Tenant the batch is routed to.  If empty the attribute value is used as the tenant.
+	Tenant string `yaml:"tenant"`
+}
+
+func (cfg *TenantRoutingConfig) enabled() bool {
+	return len(cfg.Rules) > 0 || cfg.DefaultTenant != ""
+}
+
+func (cfg *TenantRoutingConfig) validate() error {
+	for i, rule := range cfg.Rules {
+		if rule.Attribute == "" {
+			return fmt.Errorf("tenant routing rule %d: attribute must be set", i)
+		}
+	}
+
+	return nil
+}
+
+// route returns the tenant and tenant source for the batch.  ok is false if neither a rule nor the default tenant
+// applies and the batch should keep the tenant of the request.
+func (cfg *TenantRoutingConfig) route(rs pdata.ResourceSpans) (tenant string, source string, ok bool) {
+	if !rs.IsNil() && !rs.Resource().IsNil() {
+		attrs := rs.Resource().Attributes()
+		for _, rule := range cfg.Rules {
+			v, found := attrs.Get(rule.Attribute)
+			if !found || v.Type() != pdata.AttributeValueSTRING || v.StringVal() == "" {
+				continue
+			}
+
+			if rule.Value != "" && rule.Value != v.StringVal() {
+				continue
+			}
+
+			tenant := rule.Tenant
+			if tenant == "" {
+				tenant = v.StringVal()
+			}
+			return tenant, tenantSourceAttribute, true
+		}
+	}
+
+	if cfg.DefaultTenant != "" {
+		return cfg.DefaultTenant, tenantSourceDefault, true
+	}
+
+	return "", "", false
+}
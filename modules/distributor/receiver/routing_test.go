@@ -0,0 +1,87 @@
+package receiver
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/weaveworks/common/user"
+	"go.opentelemetry.io/collector/consumer/pdata"
+
+	"github.com/grafana/tempo/pkg/tempopb"
+)
+
+type tenantRecorder struct {
+	tenants []string
+}
+
+func (r *tenantRecorder) Push(ctx context.Context, req *tempopb.PushRequest) (*tempopb.PushResponse, error) {
+	tenant, err := user.ExtractOrgID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	r.tenants = append(r.tenants, tenant)
+	return &tempopb.PushResponse{}, nil
+}
+
+func tracesWithNamespaces(namespaces ...string) pdata.Traces {
+	td := pdata.NewTraces()
+	td.ResourceSpans().Resize(len(namespaces))
+	for i, ns := range namespaces {
+		resource := td.ResourceSpans().At(i).Resource()
+		resource.InitEmpty()
+		if ns != "" {
+			resource.Attributes().UpsertString("k8s.namespace.name", ns)
+		}
+	}
+	return td
+}
+
+func TestTenantRoutingRoute(t *testing.T) {
+	cfg := TenantRoutingConfig{
+		Rules: []TenantRoutingRule{
+			{Attribute: "k8s.namespace.name", Value: "kube-system", Tenant: "platform"},
+			{Attribute: "k8s.namespace.name"},
+		},
+	}
+	require.NoError(t, cfg.validate())
+
+	rss := tracesWithNamespaces("kube-system", "team-a", "").ResourceSpans()
+
+	tenant, source, ok := cfg.route(rss.At(0))
+	assert.True(t, ok)
+	assert.Equal(t, "platform", tenant)
+	assert.Equal(t, tenantSourceAttribute, source)
+
+	tenant, _, ok = cfg.route(rss.At(1))
+	assert.True(t, ok)
+	assert.Equal(t, "team-a", tenant)
+
+	_, _, ok = cfg.route(rss.At(2))
+	assert.False(t, ok)
+
+	cfg.DefaultTenant = "unrouted"
+	tenant, source, ok = cfg.route(rss.At(2))
+	assert.True(t, ok)
+	assert.Equal(t, "unrouted", tenant)
+	assert.Equal(t, tenantSourceDefault, source)
+
+	cfg.Rules = append(cfg.Rules, TenantRoutingRule{Tenant: "missing-attribute"})
+	assert.Error(t, cfg.validate())
+}
+
+func TestConsumeTracesRoutesBatches(t *testing.T) {
+	pusher := &tenantRecorder{}
+	shim := &receiversShim{
+		routingCfg: TenantRoutingConfig{
+			Rules: []TenantRoutingRule{{Attribute: "k8s.namespace.name"}},
+		},
+		inflight: newInflightLimiter(BackpressureConfig{}),
+		pusher:   pusher,
+	}
+
+	err := shim.ConsumeTraces(context.Background(), tracesWithNamespaces("team-a", "", "team-b"))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"team-a", "single-tenant", "team-b"}, pusher.tenants)
+}
@@ -2,7 +2,9 @@ package receiver
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net/http"
 	"os"
 	"time"
 
@@ -10,8 +12,10 @@ import (
 	"github.com/cortexproject/cortex/pkg/util"
 	"github.com/cortexproject/cortex/pkg/util/services"
 	"github.com/go-kit/kit/log/level"
+	"github.com/gogo/status"
 	zaplogfmt "github.com/jsternberg/zap-logfmt"
 	prom_client "github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/viper"
 	"github.com/weaveworks/common/logging"
@@ -31,7 +35,13 @@ import (
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 
+	"github.com/grafana/tempo/modules/distributor/receiver/datadog"
+	"github.com/grafana/tempo/modules/distributor/receiver/elasticapm"
+	"github.com/grafana/tempo/modules/distributor/receiver/logbridge"
+	"github.com/grafana/tempo/modules/distributor/receiver/skywalking"
+	"github.com/grafana/tempo/modules/distributor/receiver/xray"
 	"github.com/grafana/tempo/pkg/tempopb"
+	"github.com/grafana/tempo/pkg/tenantmetrics"
 	tempo_util "github.com/grafana/tempo/pkg/util"
 )
 
@@ -39,26 +49,54 @@ const (
 	logsPerSecond = 10
 )
 
+var (
+	metricReceivedSpans = promauto.NewCounterVec(prom_client.CounterOpts{
+		Namespace: "tempo",
+		Name:      "distributor_receiver_spans_received_total",
+		Help:      "The total number of spans received per receiver, transport and tenant source.",
+	}, []string{"receiver", "transport", "tenant_source"})
+	metricTenantAcceptedSpans = tenantmetrics.NewCounterVec(prom_client.CounterOpts{
+		Namespace: "tempo",
+		Name:      "distributor_receiver_tenant_spans_accepted_total",
+		Help:      "The total number of received spans accepted by the distributor per receiver and tenant.",
+	}, []string{"receiver", "tenant"})
+	metricTenantRefusedSpans = tenantmetrics.NewCounterVec(prom_client.CounterOpts{
+		Namespace: "tempo",
+		Name:      "distributor_receiver_tenant_spans_refused_total",
+		Help:      "The total number of received spans refused by the distributor per receiver and tenant.",
+	}, []string{"receiver", "tenant"})
+)
+
 type receiversShim struct {
 	services.Service
 
 	authEnabled bool
+	metadataCfg MetadataConfig
+	routingCfg  TenantRoutingConfig
+	inflight    *inflightLimiter
 	receivers   []component.Receiver
 	pusher      tempopb.PusherServer
 	logger      *tempo_util.RateLimitedLogger
 	metricViews []*view.View
 }
 
-func New(receiverCfg map[string]interface{}, pusher tempopb.PusherServer, authEnabled bool, logLevel logging.Level) (services.Service, error) {
+func New(receiverCfg map[string]interface{}, metadataCfg MetadataConfig, routingCfg TenantRoutingConfig, backpressureCfg BackpressureConfig, pusher tempopb.PusherServer, authEnabled bool, logLevel logging.Level) (services.Service, error) {
+	if err := routingCfg.validate(); err != nil {
+		return nil, err
+	}
+
 	shim := &receiversShim{
 		authEnabled: authEnabled,
+		metadataCfg: metadataCfg,
+		routingCfg:  routingCfg,
+		inflight:    newInflightLimiter(backpressureCfg),
 		pusher:      pusher,
 		logger:      tempo_util.NewRateLimitedLogger(logsPerSecond, level.Error(util.Logger)),
 	}
 
 	v := viper.New()
 	err := v.MergeConfigMap(map[string]interface{}{
-		"receivers": receiverCfg,
+		"receivers": applyOTLPDefaults(receiverCfg),
 	})
 	if err != nil {
 		return nil, err
@@ -77,6 +115,11 @@ func New(receiverCfg map[string]interface{}, pusher tempopb.PusherServer, authEn
 		&zipkinreceiver.Factory{},
 		&opencensusreceiver.Factory{},
 		otlpreceiver.NewFactory(),
+		datadog.NewFactory(),
+		xray.NewFactory(),
+		elasticapm.NewFactory(),
+		skywalking.NewFactory(),
+		logbridge.NewFactory(),
 	)
 	if err != nil {
 		return nil, err
@@ -154,9 +197,29 @@ func (r *receiversShim) stopping(_ error) error {
 	return nil
 }
 
-// implements consumer.TraceConsumer
+// implements consumer.TraceConsumer.  Refused requests are counted by the status code of the error, which the receivers
+// use to tell clients whether to retry.
 func (r *receiversShim) ConsumeTraces(ctx context.Context, td pdata.Traces) error {
+	md := metadataFromContext(ctx, "", false)
+	spanCount := td.SpanCount()
+	metricSpansPerRequest.WithLabelValues(md.receiver).Observe(float64(spanCount))
+
+	err := r.inflight.acquire(spanCount)
+	if err == nil {
+		err = r.consumeTraces(ctx, td)
+		r.inflight.release(spanCount)
+	}
+	if err != nil {
+		metricRefusedSpans.WithLabelValues(md.receiver, md.transport, status.Code(err).String()).Add(float64(spanCount))
+	}
+	return err
+}
+
+func (r *receiversShim) consumeTraces(ctx context.Context, td pdata.Traces) error {
+	idempotencyKey := idempotencyKeyFromMetadata(ctx)
+	tenantSource := tenantSourceHeader
 	if !r.authEnabled {
+		tenantSource = tenantSourceDefault
 		ctx = user.InjectOrgID(ctx, tempo_util.FakeTenantID)
 	} else {
 		var err error
@@ -167,22 +230,70 @@ func (r *receiversShim) ConsumeTraces(ctx context.Context, td pdata.Traces) erro
 		}
 	}
 
+	if !r.routingCfg.enabled() {
+		return r.push(ctx, td, tenantSource, idempotencyKey, 0)
+	}
+
+	// routing may send each batch to a different tenant
+	batches := pdata.TracesToOtlp(td)
+	rss := td.ResourceSpans()
+	for i := 0; i < rss.Len(); i++ {
+		batchCtx, batchTenantSource := ctx, tenantSource
+		if tenant, source, ok := r.routingCfg.route(rss.At(i)); ok {
+			batchCtx, batchTenantSource = user.InjectOrgID(ctx, tenant), source
+		}
+
+		err := r.push(batchCtx, pdata.TracesFromOtlp(batches[i:i+1]), batchTenantSource, idempotencyKey, i)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// push sends each batch of td to the pusher.  If the request has an idempotency key each batch is keyed by it and the
+// batch's position in the request, starting at firstBatch.
+func (r *receiversShim) push(ctx context.Context, td pdata.Traces, tenantSource string, idempotencyKey string, firstBatch int) error {
+	md := metadataFromContext(ctx, tenantSource, r.metadataCfg.TrustForwardedFor)
+	metricReceivedSpans.WithLabelValues(md.receiver, md.transport, md.tenantSource).Add(float64(td.SpanCount()))
+	if r.metadataCfg.ResourceAttributes {
+		md.addResourceAttributes(td)
+	}
+
+	// the tenant is always set by consumeTraces
+	tenant, _ := user.ExtractOrgID(ctx)
+	spanCount := td.SpanCount()
+	accepted := 0
+
 	var err error
-	for _, resourceSpan := range pdata.TracesToOtlp(td) {
-		_, err = r.pusher.Push(ctx, &tempopb.PushRequest{
+	for i, resourceSpan := range pdata.TracesToOtlp(td) {
+		_, err = r.pusher.Push(withBatchIdempotencyKey(ctx, idempotencyKey, firstBatch+i), &tempopb.PushRequest{
 			Batch: resourceSpan,
 		})
 		if err != nil {
 			r.logger.Log("msg", "pusher failed to consume trace data", "err", err)
 			break
 		}
+		for _, ils := range resourceSpan.InstrumentationLibrarySpans {
+			accepted += len(ils.Spans)
+		}
 	}
 
+	metricTenantAcceptedSpans.WithLabelValues(md.receiver, tenant).Add(float64(accepted))
+	if err != nil {
+		metricTenantRefusedSpans.WithLabelValues(md.receiver, tenant).Add(float64(spanCount - accepted))
+	}
 	return err
 }
 
 // implements component.Host
 func (r *receiversShim) ReportFatalError(err error) {
+	// some receivers, such as zipkin, report their http server closing on shutdown as an error
+	if errors.Is(err, http.ErrServerClosed) {
+		return
+	}
+
 	level.Error(util.Logger).Log("msg", "fatal error reported", "err", err)
 	panic(fmt.Sprintf("Fatal error %v", err))
 }
@@ -0,0 +1,12 @@
+package skywalking
+
+import (
+	"go.opentelemetry.io/collector/config/confighttp"
+	"go.opentelemetry.io/collector/config/configmodels"
+)
+
+// Config configures the SkyWalking segment receiver.
+type Config struct {
+	configmodels.ReceiverSettings `mapstructure:",squash"`
+	confighttp.HTTPServerSettings `mapstructure:",squash"`
+}
@@ -0,0 +1,147 @@
+package skywalking
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/component/componenterror"
+	"go.opentelemetry.io/collector/consumer"
+	"go.opentelemetry.io/collector/consumer/pdata"
+	"go.opentelemetry.io/collector/obsreport"
+
+	"github.com/grafana/tempo/modules/distributor/receiver/intake"
+)
+
+const (
+	transport = "http"
+	format    = "skywalking"
+
+	maxPayloadBytes = 10 << 20
+)
+
+type receiver struct {
+	cfg          *Config
+	nextConsumer consumer.TraceConsumer
+
+	startOnce sync.Once
+	stopOnce  sync.Once
+	server    *http.Server
+}
+
+func newReceiver(cfg *Config, nextConsumer consumer.TraceConsumer) (*receiver, error) {
+	if nextConsumer == nil {
+		return nil, componenterror.ErrNilNextConsumer
+	}
+
+	return &receiver{
+		cfg:          cfg,
+		nextConsumer: nextConsumer,
+	}, nil
+}
+
+// Start implements component.Receiver
+func (r *receiver) Start(_ context.Context, host component.Host) error {
+	if host == nil {
+		return errors.New("nil host")
+	}
+
+	err := componenterror.ErrAlreadyStarted
+	r.startOnce.Do(func() {
+		mux := http.NewServeMux()
+		mux.HandleFunc("/v3/segments", r.handleSegments)
+		mux.HandleFunc("/v3/segment", r.handleSegment)
+		r.server = r.cfg.HTTPServerSettings.ToServer(mux)
+
+		listener, listenErr := r.cfg.HTTPServerSettings.ToListener()
+		if listenErr != nil {
+			err = listenErr
+			return
+		}
+		err = nil
+
+		go func() {
+			if serveErr := r.server.Serve(listener); serveErr != http.ErrServerClosed {
+				host.ReportFatalError(serveErr)
+			}
+		}()
+	})
+
+	return err
+}
+
+// Shutdown implements component.Receiver
+func (r *receiver) Shutdown(context.Context) error {
+	err := componenterror.ErrAlreadyStopped
+	r.stopOnce.Do(func() {
+		err = nil
+		if r.server != nil {
+			err = r.server.Close()
+		}
+	})
+	return err
+}
+
+// handleSegments accepts a json array of segments as sent by the SkyWalking http reporter.
+func (r *receiver) handleSegments(w http.ResponseWriter, req *http.Request) {
+	r.handle(w, req, func(body []byte) ([]*segment, error) {
+		var segments []*segment
+		err := json.Unmarshal(body, &segments)
+		return segments, err
+	})
+}
+
+// handleSegment accepts a single json segment.
+func (r *receiver) handleSegment(w http.ResponseWriter, req *http.Request) {
+	r.handle(w, req, func(body []byte) ([]*segment, error) {
+		seg := &segment{}
+		if err := json.Unmarshal(body, seg); err != nil {
+			return nil, err
+		}
+		return []*segment{seg}, nil
+	})
+}
+
+func (r *receiver) handle(w http.ResponseWriter, req *http.Request, decode func([]byte) ([]*segment, error)) {
+	if req.Method != http.MethodPost {
+		http.Error(w, "segments must be sent with POST", http.StatusMethodNotAllowed)
+		return
+	}
+
+	ctx := intake.RequestContext(req)
+	ctx = obsreport.ReceiverContext(ctx, r.cfg.Name(), transport, "")
+	ctx = obsreport.StartTraceDataReceiveOp(ctx, r.cfg.Name(), transport)
+
+	body, err := intake.ReadBody(req, maxPayloadBytes)
+	if err != nil {
+		obsreport.EndTraceDataReceiveOp(ctx, format, 0, err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	segments, err := decode(body)
+	if err != nil {
+		obsreport.EndTraceDataReceiveOp(ctx, format, 0, err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	batches := toResourceSpans(segments)
+	spans := 0
+	for _, b := range batches {
+		spans += len(b.InstrumentationLibrarySpans[0].Spans)
+	}
+	if spans > 0 {
+		err = r.nextConsumer.ConsumeTraces(ctx, pdata.TracesFromOtlp(batches))
+	}
+	obsreport.EndTraceDataReceiveOp(ctx, format, spans, err)
+	if err != nil {
+		intake.WriteConsumeError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
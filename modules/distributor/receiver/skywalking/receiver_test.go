@@ -0,0 +1,62 @@
+package skywalking
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/weaveworks/common/user"
+	"go.opentelemetry.io/collector/consumer/pdata"
+)
+
+type traceSink struct {
+	traces  []pdata.Traces
+	tenants []string
+}
+
+func (s *traceSink) ConsumeTraces(ctx context.Context, td pdata.Traces) error {
+	_, ctx, _ = user.ExtractFromGRPCRequest(ctx)
+	tenant, _ := user.ExtractOrgID(ctx)
+	s.tenants = append(s.tenants, tenant)
+	s.traces = append(s.traces, td)
+	return nil
+}
+
+func TestHandleSegments(t *testing.T) {
+	sink := &traceSink{}
+	r, err := newReceiver(&Config{}, sink)
+	require.NoError(t, err)
+
+	req := httptest.NewRequest(http.MethodPost, "/v3/segments", strings.NewReader(testSegments))
+	req.Header.Set(user.OrgIDHeaderName, "tenant-a")
+	w := httptest.NewRecorder()
+	r.handleSegments(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	require.Len(t, sink.traces, 1)
+	assert.Equal(t, 4, sink.traces[0].SpanCount())
+	assert.Equal(t, []string{"tenant-a"}, sink.tenants)
+
+	req = httptest.NewRequest(http.MethodPost, "/v3/segment", strings.NewReader(`{"traceId": "1", "traceSegmentId": "2", "service": "s", "spans": [{"spanId": 0, "parentSpanId": -1}]}`))
+	w = httptest.NewRecorder()
+	r.handleSegment(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	require.Len(t, sink.traces, 2)
+	assert.Equal(t, 1, sink.traces[1].SpanCount())
+
+	req = httptest.NewRequest(http.MethodPost, "/v3/segments", strings.NewReader(`{`))
+	w = httptest.NewRecorder()
+	r.handleSegments(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	req = httptest.NewRequest(http.MethodGet, "/v3/segments", nil)
+	w = httptest.NewRecorder()
+	r.handleSegments(w, req)
+	assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+	assert.Len(t, sink.traces, 2)
+}
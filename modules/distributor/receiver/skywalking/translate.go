@@ -0,0 +1,167 @@
+package skywalking
+
+import (
+	"encoding/binary"
+	"hash/fnv"
+	"sort"
+	"strconv"
+
+	v1_common "github.com/open-telemetry/opentelemetry-proto/gen/go/common/v1"
+	v1_resource "github.com/open-telemetry/opentelemetry-proto/gen/go/resource/v1"
+	v1 "github.com/open-telemetry/opentelemetry-proto/gen/go/trace/v1"
+)
+
+const (
+	attributeServiceName     = "service.name"
+	attributeServiceInstance = "service.instance.id"
+	attributeTraceID         = "skywalking.trace_id"
+	attributeSegmentID       = "skywalking.segment_id"
+	attributePeer            = "skywalking.peer"
+	attributeLayer           = "skywalking.layer"
+	attributeComponentID     = "skywalking.component_id"
+
+	spanTypeEntry = "Entry"
+	spanTypeExit  = "Exit"
+)
+
+// segment is the json form of a SkyWalking v3 SegmentObject.  A segment holds the spans of one trace produced by one
+// thread of a service instance.
+type segment struct {
+	TraceID         string `json:"traceId"`
+	TraceSegmentID  string `json:"traceSegmentId"`
+	Service         string `json:"service"`
+	ServiceInstance string `json:"serviceInstance"`
+	Spans           []struct {
+		SpanID        int32  `json:"spanId"`
+		ParentSpanID  int32  `json:"parentSpanId"`
+		StartTime     int64  `json:"startTime"` // milliseconds since the epoch
+		EndTime       int64  `json:"endTime"`
+		OperationName string `json:"operationName"`
+		Peer          string `json:"peer"`
+		SpanType      string `json:"spanType"`
+		SpanLayer     string `json:"spanLayer"`
+		ComponentID   int32  `json:"componentId"`
+		IsError       bool   `json:"isError"`
+		Refs          []struct {
+			ParentTraceSegmentID string `json:"parentTraceSegmentId"`
+			ParentSpanID         int32  `json:"parentSpanId"`
+		} `json:"refs"`
+		Tags []struct {
+			Key   string `json:"key"`
+			Value string `json:"value"`
+		} `json:"tags"`
+	} `json:"spans"`
+}
+
+// toResourceSpans converts segments to one batch per service instance.  SkyWalking ids are arbitrary strings so they
+// are hashed to the 128 bit trace ids and 64 bit span ids used by Tempo.  The original ids are kept as attributes.
+func toResourceSpans(segments []*segment) []*v1.ResourceSpans {
+	type instance struct{ service, instance string }
+	batches := map[instance]*v1.ResourceSpans{}
+	var order []instance
+
+	for _, seg := range segments {
+		key := instance{seg.Service, seg.ServiceInstance}
+		batch, ok := batches[key]
+		if !ok {
+			resource := &v1_resource.Resource{
+				Attributes: []*v1_common.KeyValue{stringAttribute(attributeServiceName, seg.Service)},
+			}
+			if seg.ServiceInstance != "" {
+				resource.Attributes = append(resource.Attributes, stringAttribute(attributeServiceInstance, seg.ServiceInstance))
+			}
+			batch = &v1.ResourceSpans{
+				Resource:                    resource,
+				InstrumentationLibrarySpans: []*v1.InstrumentationLibrarySpans{{}},
+			}
+			batches[key] = batch
+			order = append(order, key)
+		}
+
+		ils := batch.InstrumentationLibrarySpans[0]
+		traceID := hashTraceID(seg.TraceID)
+		for _, s := range seg.Spans {
+			span := &v1.Span{
+				TraceId:           traceID,
+				SpanId:            hashSpanID(seg.TraceSegmentID, s.SpanID),
+				Name:              s.OperationName,
+				StartTimeUnixNano: uint64(s.StartTime) * 1e6,
+				EndTimeUnixNano:   uint64(s.EndTime) * 1e6,
+				Attributes: []*v1_common.KeyValue{
+					stringAttribute(attributeTraceID, seg.TraceID),
+					stringAttribute(attributeSegmentID, seg.TraceSegmentID),
+				},
+			}
+
+			// a parent in the same segment is referenced by span id, otherwise the first ref points across segments
+			if s.ParentSpanID >= 0 {
+				span.ParentSpanId = hashSpanID(seg.TraceSegmentID, s.ParentSpanID)
+			} else if len(s.Refs) > 0 {
+				span.ParentSpanId = hashSpanID(s.Refs[0].ParentTraceSegmentID, s.Refs[0].ParentSpanID)
+			}
+
+			switch s.SpanType {
+			case spanTypeEntry:
+				span.Kind = v1.Span_SERVER
+			case spanTypeExit:
+				span.Kind = v1.Span_CLIENT
+			default:
+				span.Kind = v1.Span_INTERNAL
+			}
+
+			if s.Peer != "" {
+				span.Attributes = append(span.Attributes, stringAttribute(attributePeer, s.Peer))
+			}
+			if s.SpanLayer != "" {
+				span.Attributes = append(span.Attributes, stringAttribute(attributeLayer, s.SpanLayer))
+			}
+			if s.ComponentID != 0 {
+				span.Attributes = append(span.Attributes, stringAttribute(attributeComponentID, strconv.Itoa(int(s.ComponentID))))
+			}
+			for _, tag := range s.Tags {
+				span.Attributes = append(span.Attributes, stringAttribute(tag.Key, tag.Value))
+			}
+			if s.IsError {
+				span.Status = &v1.Status{Code: v1.Status_UnknownError}
+			}
+
+			ils.Spans = append(ils.Spans, span)
+		}
+	}
+
+	sort.Slice(order, func(i, j int) bool {
+		if order[i].service != order[j].service {
+			return order[i].service < order[j].service
+		}
+		return order[i].instance < order[j].instance
+	})
+	result := make([]*v1.ResourceSpans, 0, len(order))
+	for _, key := range order {
+		result = append(result, batches[key])
+	}
+	return result
+}
+
+func hashTraceID(id string) []byte {
+	h := fnv.New128a()
+	_, _ = h.Write([]byte(id))
+	return h.Sum(nil)
+}
+
+func hashSpanID(segmentID string, spanID int32) []byte {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(segmentID))
+
+	var b [4]byte
+	binary.BigEndian.PutUint32(b[:], uint32(spanID))
+	_, _ = h.Write(b[:])
+
+	return h.Sum(nil)
+}
+
+func stringAttribute(k string, v string) *v1_common.KeyValue {
+	return &v1_common.KeyValue{
+		Key:   k,
+		Value: &v1_common.AnyValue{Value: &v1_common.AnyValue_StringValue{StringValue: v}},
+	}
+}
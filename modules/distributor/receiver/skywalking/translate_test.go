@@ -0,0 +1,71 @@
+package skywalking
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	v1 "github.com/open-telemetry/opentelemetry-proto/gen/go/trace/v1"
+)
+
+const testSegments = `[
+	{
+		"traceId": "a8e3b1c2.41.16000000000000001",
+		"traceSegmentId": "a8e3b1c2.41.16000000000000000",
+		"service": "gateway",
+		"serviceInstance": "gateway-0",
+		"spans": [
+			{"spanId": 0, "parentSpanId": -1, "startTime": 1600000000000, "endTime": 1600000000050, "operationName": "/order", "spanType": "Entry", "spanLayer": "Http", "componentId": 1},
+			{"spanId": 1, "parentSpanId": 0, "startTime": 1600000000010, "endTime": 1600000000040, "operationName": "/order/create", "spanType": "Exit", "peer": "order:8080", "isError": true, "tags": [{"key": "http.method", "value": "POST"}]}
+		]
+	},
+	{
+		"traceId": "a8e3b1c2.41.16000000000000001",
+		"traceSegmentId": "b7f2c3d4.12.16000000000000000",
+		"service": "order",
+		"serviceInstance": "order-0",
+		"spans": [
+			{"spanId": 0, "parentSpanId": -1, "startTime": 1600000000015, "endTime": 1600000000035, "operationName": "/order/create", "spanType": "Entry", "refs": [{"parentTraceSegmentId": "a8e3b1c2.41.16000000000000000", "parentSpanId": 1}]},
+			{"spanId": 1, "parentSpanId": 0, "startTime": 1600000000020, "endTime": 1600000000030, "operationName": "validate", "spanType": "Local"}
+		]
+	}
+]`
+
+func TestToResourceSpans(t *testing.T) {
+	var segments []*segment
+	require.NoError(t, json.Unmarshal([]byte(testSegments), &segments))
+
+	batches := toResourceSpans(segments)
+	require.Len(t, batches, 2)
+	assert.Equal(t, "gateway", batches[0].Resource.Attributes[0].Value.GetStringValue())
+	assert.Equal(t, "order", batches[1].Resource.Attributes[0].Value.GetStringValue())
+
+	gateway := batches[0].InstrumentationLibrarySpans[0].Spans
+	order := batches[1].InstrumentationLibrarySpans[0].Spans
+	require.Len(t, gateway, 2)
+	require.Len(t, order, 2)
+
+	// every span shares the hashed trace id
+	for _, s := range append(gateway, order...) {
+		assert.Len(t, s.TraceId, 16)
+		assert.Equal(t, gateway[0].TraceId, s.TraceId)
+		assert.Len(t, s.SpanId, 8)
+	}
+
+	assert.Equal(t, v1.Span_SERVER, gateway[0].Kind)
+	assert.Nil(t, gateway[0].ParentSpanId)
+	assert.Equal(t, uint64(1600000000000000000), gateway[0].StartTimeUnixNano)
+	assert.Equal(t, uint64(1600000000050000000), gateway[0].EndTimeUnixNano)
+
+	assert.Equal(t, v1.Span_CLIENT, gateway[1].Kind)
+	assert.Equal(t, gateway[0].SpanId, gateway[1].ParentSpanId)
+	assert.Equal(t, v1.Status_UnknownError, gateway[1].Status.Code)
+
+	// the entry span of the downstream segment is parented across segments by its ref
+	assert.Equal(t, gateway[1].SpanId, order[0].ParentSpanId)
+	assert.Equal(t, v1.Span_INTERNAL, order[1].Kind)
+	assert.Equal(t, order[0].SpanId, order[1].ParentSpanId)
+	assert.NotEqual(t, gateway[0].SpanId, order[0].SpanId)
+}
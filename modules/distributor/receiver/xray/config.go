@@ -0,0 +1,15 @@
+package xray
+
+import (
+	"go.opentelemetry.io/collector/config/configmodels"
+)
+
+// Config configures the X-Ray segment receiver.  Either endpoint may be left empty to disable it.
+type Config struct {
+	configmodels.ReceiverSettings `mapstructure:",squash"`
+
+	// UDPEndpoint receives segments sent with the X-Ray daemon protocol, as emitted by the X-Ray SDKs.
+	UDPEndpoint string `mapstructure:"udp_endpoint"`
+	// HTTPEndpoint receives PutTraceSegments requests, the X-Ray API called by the daemon.
+	HTTPEndpoint string `mapstructure:"http_endpoint"`
+}
@@ -0,0 +1,39 @@
+package xray
+
+import (
+	"context"
+
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/config/configmodels"
+	"go.opentelemetry.io/collector/consumer"
+	"go.opentelemetry.io/collector/receiver/receiverhelper"
+)
+
+const (
+	typeStr = "xray"
+
+	// the address the X-Ray SDKs send segments to by default
+	defaultUDPEndpoint = "0.0.0.0:2000"
+)
+
+// NewFactory creates a factory for the X-Ray segment receiver.
+func NewFactory() component.ReceiverFactory {
+	return receiverhelper.NewFactory(
+		typeStr,
+		createDefaultConfig,
+		receiverhelper.WithTraces(createTraceReceiver))
+}
+
+func createDefaultConfig() configmodels.Receiver {
+	return &Config{
+		ReceiverSettings: configmodels.ReceiverSettings{
+			TypeVal: typeStr,
+			NameVal: typeStr,
+		},
+		UDPEndpoint: defaultUDPEndpoint,
+	}
+}
+
+func createTraceReceiver(_ context.Context, params component.ReceiverCreateParams, cfg configmodels.Receiver, nextConsumer consumer.TraceConsumer) (component.TraceReceiver, error) {
+	return newReceiver(cfg.(*Config), params.Logger, nextConsumer)
+}
@@ -0,0 +1,271 @@
+package xray
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"sync"
+
+	v1 "github.com/open-telemetry/opentelemetry-proto/gen/go/trace/v1"
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/component/componenterror"
+	"go.opentelemetry.io/collector/consumer"
+	"go.opentelemetry.io/collector/consumer/pdata"
+	"go.opentelemetry.io/collector/obsreport"
+	"go.uber.org/zap"
+
+	"github.com/grafana/tempo/modules/distributor/receiver/intake"
+)
+
+const (
+	transportUDP  = "udp"
+	transportHTTP = "http"
+	format        = "xray"
+
+	// segments sent over udp must fit in a single datagram
+	maxDatagramBytes = 64 * 1024
+	// PutTraceSegments accepts at most 5MB per request
+	maxRequestBytes = 5 << 20
+
+	daemonHeaderFormat = "json"
+)
+
+type daemonHeader struct {
+	Format  string `json:"format"`
+	Version int    `json:"version"`
+}
+
+type putTraceSegmentsRequest struct {
+	TraceSegmentDocuments []string `json:"TraceSegmentDocuments"`
+}
+
+type unprocessedSegment struct {
+	ID        string `json:"Id,omitempty"`
+	ErrorCode string `json:"ErrorCode"`
+	Message   string `json:"Message"`
+}
+
+type receiver struct {
+	cfg          *Config
+	logger       *zap.Logger
+	nextConsumer consumer.TraceConsumer
+
+	startOnce sync.Once
+	stopOnce  sync.Once
+	conn      net.PacketConn
+	server    *http.Server
+}
+
+func newReceiver(cfg *Config, logger *zap.Logger, nextConsumer consumer.TraceConsumer) (*receiver, error) {
+	if nextConsumer == nil {
+		return nil, componenterror.ErrNilNextConsumer
+	}
+	if cfg.UDPEndpoint == "" && cfg.HTTPEndpoint == "" {
+		return nil, errors.New("xray receiver requires a udp or http endpoint")
+	}
+
+	return &receiver{
+		cfg:          cfg,
+		logger:       logger,
+		nextConsumer: nextConsumer,
+	}, nil
+}
+
+// Start implements component.Receiver
+func (r *receiver) Start(_ context.Context, host component.Host) error {
+	if host == nil {
+		return errors.New("nil host")
+	}
+
+	err := componenterror.ErrAlreadyStarted
+	r.startOnce.Do(func() {
+		err = r.start(host)
+	})
+	return err
+}
+
+func (r *receiver) start(host component.Host) error {
+	if r.cfg.UDPEndpoint != "" {
+		conn, err := net.ListenPacket("udp", r.cfg.UDPEndpoint)
+		if err != nil {
+			return err
+		}
+		r.conn = conn
+		go r.readDatagrams()
+	}
+
+	if r.cfg.HTTPEndpoint != "" {
+		listener, err := net.Listen("tcp", r.cfg.HTTPEndpoint)
+		if err != nil {
+			return err
+		}
+
+		mux := http.NewServeMux()
+		mux.HandleFunc("/TraceSegments", r.handlePutTraceSegments)
+		r.server = &http.Server{Handler: mux}
+		go func() {
+			if err := r.server.Serve(listener); err != http.ErrServerClosed {
+				host.ReportFatalError(err)
+			}
+		}()
+	}
+
+	return nil
+}
+
+// Shutdown implements component.Receiver
+func (r *receiver) Shutdown(context.Context) error {
+	err := componenterror.ErrAlreadyStopped
+	r.stopOnce.Do(func() {
+		var errs []error
+		if r.conn != nil {
+			if closeErr := r.conn.Close(); closeErr != nil {
+				errs = append(errs, closeErr)
+			}
+		}
+		if r.server != nil {
+			if closeErr := r.server.Close(); closeErr != nil {
+				errs = append(errs, closeErr)
+			}
+		}
+		err = componenterror.CombineErrors(errs)
+	})
+	return err
+}
+
+// readDatagrams receives segments sent with the daemon protocol.  Each datagram is a json header, a newline and a
+// single segment document.  Udp has no way to report failures so they are only logged.
+func (r *receiver) readDatagrams() {
+	buffer := make([]byte, maxDatagramBytes)
+	for {
+		n, _, err := r.conn.ReadFrom(buffer)
+		if err != nil {
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Temporary() {
+				continue
+			}
+			// the connection was closed on shutdown
+			return
+		}
+
+		ctx := obsreport.ReceiverContext(context.Background(), r.cfg.Name(), transportUDP, "")
+		ctx = obsreport.StartTraceDataReceiveOp(ctx, r.cfg.Name(), transportUDP)
+
+		batch, err := decodeDatagram(buffer[:n])
+		if err != nil {
+			obsreport.EndTraceDataReceiveOp(ctx, format, 0, err)
+			r.logger.Debug("dropping malformed segment", zap.Error(err))
+			continue
+		}
+
+		err = r.consume(ctx, []*v1.ResourceSpans{batch})
+		obsreport.EndTraceDataReceiveOp(ctx, format, spanCount(batch), err)
+		if err != nil {
+			r.logger.Debug("failed to consume segment", zap.Error(err))
+		}
+	}
+}
+
+func decodeDatagram(datagram []byte) (*v1.ResourceSpans, error) {
+	newline := bytes.IndexByte(datagram, '\n')
+	if newline < 0 {
+		return nil, errors.New("missing daemon protocol header")
+	}
+
+	header := daemonHeader{}
+	err := json.Unmarshal(datagram[:newline], &header)
+	if err != nil {
+		return nil, fmt.Errorf("invalid daemon protocol header: %w", err)
+	}
+	if header.Format != daemonHeaderFormat {
+		return nil, fmt.Errorf("unsupported segment format %q", header.Format)
+	}
+
+	return toResourceSpans(datagram[newline+1:])
+}
+
+// handlePutTraceSegments accepts the same request and response bodies as the X-Ray PutTraceSegments API.  Segments
+// that can't be converted are reported back as unprocessed while the rest are still stored.
+func (r *receiver) handlePutTraceSegments(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		http.Error(w, "segments must be sent with POST", http.StatusMethodNotAllowed)
+		return
+	}
+
+	ctx := intake.RequestContext(req)
+	ctx = obsreport.ReceiverContext(ctx, r.cfg.Name(), transportHTTP, "")
+	ctx = obsreport.StartTraceDataReceiveOp(ctx, r.cfg.Name(), transportHTTP)
+
+	body, err := ioutil.ReadAll(http.MaxBytesReader(w, req.Body, maxRequestBytes))
+	if err != nil {
+		obsreport.EndTraceDataReceiveOp(ctx, format, 0, err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	put := putTraceSegmentsRequest{}
+	err = json.Unmarshal(body, &put)
+	if err != nil {
+		obsreport.EndTraceDataReceiveOp(ctx, format, 0, err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	batches := make([]*v1.ResourceSpans, 0, len(put.TraceSegmentDocuments))
+	unprocessed := []unprocessedSegment{}
+	spans := 0
+	for _, document := range put.TraceSegmentDocuments {
+		batch, err := toResourceSpans([]byte(document))
+		if err != nil {
+			unprocessed = append(unprocessed, unprocessedSegment{
+				ID:        segmentID(document),
+				ErrorCode: "InvalidSegment",
+				Message:   err.Error(),
+			})
+			continue
+		}
+		batches = append(batches, batch)
+		spans += spanCount(batch)
+	}
+
+	err = r.consume(ctx, batches)
+	obsreport.EndTraceDataReceiveOp(ctx, format, spans, err)
+	if err != nil {
+		intake.WriteConsumeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(struct {
+		UnprocessedTraceSegments []unprocessedSegment `json:"UnprocessedTraceSegments"`
+	}{unprocessed})
+}
+
+func (r *receiver) consume(ctx context.Context, batches []*v1.ResourceSpans) error {
+	if len(batches) == 0 {
+		return nil
+	}
+	return r.nextConsumer.ConsumeTraces(ctx, pdata.TracesFromOtlp(batches))
+}
+
+// segmentID makes a best effort to identify a document that failed to convert
+func segmentID(document string) string {
+	seg := struct {
+		ID string `json:"id"`
+	}{}
+	_ = json.Unmarshal([]byte(document), &seg)
+	return seg.ID
+}
+
+func spanCount(batch *v1.ResourceSpans) int {
+	count := 0
+	for _, ils := range batch.InstrumentationLibrarySpans {
+		count += len(ils.Spans)
+	}
+	return count
+}
@@ -0,0 +1,102 @@
+package xray
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/weaveworks/common/user"
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/consumer/pdata"
+	"go.uber.org/zap"
+)
+
+type traceSink struct {
+	traces  chan pdata.Traces
+	tenants chan string
+}
+
+func newTraceSink() *traceSink {
+	return &traceSink{
+		traces:  make(chan pdata.Traces, 10),
+		tenants: make(chan string, 10),
+	}
+}
+
+func (s *traceSink) ConsumeTraces(ctx context.Context, td pdata.Traces) error {
+	_, ctx, _ = user.ExtractFromGRPCRequest(ctx)
+	tenant, _ := user.ExtractOrgID(ctx)
+	s.tenants <- tenant
+	s.traces <- td
+	return nil
+}
+
+type nopHost struct {
+	component.Host
+}
+
+func (nopHost) ReportFatalError(error) {}
+
+func TestReceiveDatagram(t *testing.T) {
+	sink := newTraceSink()
+	r, err := newReceiver(&Config{UDPEndpoint: "127.0.0.1:0"}, zap.NewNop(), sink)
+	require.NoError(t, err)
+	require.NoError(t, r.Start(context.Background(), nopHost{}))
+	defer r.Shutdown(context.Background()) // nolint:errcheck
+
+	conn, err := net.Dial("udp", r.conn.LocalAddr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+
+	// malformed datagrams are dropped without stopping the receiver
+	_, err = conn.Write([]byte("no header"))
+	require.NoError(t, err)
+	_, err = conn.Write([]byte(`{"format": "json", "version": 1}` + "\n" + testSegment))
+	require.NoError(t, err)
+
+	select {
+	case td := <-sink.traces:
+		assert.Equal(t, 3, td.SpanCount())
+	case <-time.After(5 * time.Second):
+		t.Fatal("segment not received")
+	}
+}
+
+func TestPutTraceSegments(t *testing.T) {
+	sink := newTraceSink()
+	r, err := newReceiver(&Config{HTTPEndpoint: "127.0.0.1:0"}, zap.NewNop(), sink)
+	require.NoError(t, err)
+
+	body, err := json.Marshal(putTraceSegmentsRequest{
+		TraceSegmentDocuments: []string{testSegment, `{"id": "0000000000000001", "trace_id": "bad"}`},
+	})
+	require.NoError(t, err)
+
+	req := httptest.NewRequest(http.MethodPost, "/TraceSegments", bytes.NewReader(body))
+	req.Header.Set(user.OrgIDHeaderName, "tenant-a")
+	w := httptest.NewRecorder()
+	r.handlePutTraceSegments(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "tenant-a", <-sink.tenants)
+	assert.Equal(t, 3, (<-sink.traces).SpanCount())
+
+	resp := struct {
+		UnprocessedTraceSegments []unprocessedSegment
+	}{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	require.Len(t, resp.UnprocessedTraceSegments, 1)
+	assert.Equal(t, "0000000000000001", resp.UnprocessedTraceSegments[0].ID)
+}
+
+func TestNewReceiverRequiresEndpoint(t *testing.T) {
+	_, err := newReceiver(&Config{}, zap.NewNop(), newTraceSink())
+	assert.Error(t, err)
+}
@@ -0,0 +1,223 @@
+package xray
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	v1_common "github.com/open-telemetry/opentelemetry-proto/gen/go/common/v1"
+	v1_resource "github.com/open-telemetry/opentelemetry-proto/gen/go/resource/v1"
+	v1 "github.com/open-telemetry/opentelemetry-proto/gen/go/trace/v1"
+)
+
+const (
+	attributeServiceName    = "service.name"
+	attributeOrigin         = "xray.origin"
+	attributeHTTPMethod     = "http.method"
+	attributeHTTPURL        = "http.url"
+	attributeHTTPStatusCode = "http.status_code"
+
+	namespaceAWS    = "aws"
+	namespaceRemote = "remote"
+)
+
+// segment is an X-Ray segment document or one of its subsegments.  Only the fields mapped to spans are decoded.
+type segment struct {
+	Name       string  `json:"name"`
+	ID         string  `json:"id"`
+	TraceID    string  `json:"trace_id"`
+	ParentID   string  `json:"parent_id"`
+	StartTime  float64 `json:"start_time"`
+	EndTime    float64 `json:"end_time"`
+	InProgress bool    `json:"in_progress"`
+	Type       string  `json:"type"`
+	Namespace  string  `json:"namespace"`
+	Origin     string  `json:"origin"`
+	Error      bool    `json:"error"`
+	Fault      bool    `json:"fault"`
+	Throttle   bool    `json:"throttle"`
+	HTTP       *struct {
+		Request *struct {
+			Method string `json:"method"`
+			URL    string `json:"url"`
+		} `json:"request"`
+		Response *struct {
+			Status int64 `json:"status"`
+		} `json:"response"`
+	} `json:"http"`
+	Annotations map[string]interface{} `json:"annotations"`
+	Subsegments []*segment             `json:"subsegments"`
+}
+
+// toResourceSpans converts a segment document to a batch.  The document's name is the service and every subsegment
+// becomes a child span of the segment or subsegment containing it.
+func toResourceSpans(document []byte) (*v1.ResourceSpans, error) {
+	// numbers are decoded as json.Number so integer annotations stay integers
+	seg := &segment{}
+	decoder := json.NewDecoder(bytes.NewReader(document))
+	decoder.UseNumber()
+	err := decoder.Decode(seg)
+	if err != nil {
+		return nil, err
+	}
+
+	traceID, err := parseTraceID(seg.TraceID)
+	if err != nil {
+		return nil, err
+	}
+
+	var parentID []byte
+	if seg.ParentID != "" {
+		parentID, err = parseSpanID(seg.ParentID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// an independent subsegment is sent on its own when it completes after its parent segment
+	kind := v1.Span_SERVER
+	if seg.Type == "subsegment" {
+		kind = subsegmentKind(seg)
+	}
+
+	ils := &v1.InstrumentationLibrarySpans{}
+	err = appendSpans(ils, seg, traceID, parentID, kind)
+	if err != nil {
+		return nil, err
+	}
+
+	return &v1.ResourceSpans{
+		Resource: &v1_resource.Resource{
+			Attributes: []*v1_common.KeyValue{stringAttribute(attributeServiceName, seg.Name)},
+		},
+		InstrumentationLibrarySpans: []*v1.InstrumentationLibrarySpans{ils},
+	}, nil
+}
+
+func appendSpans(ils *v1.InstrumentationLibrarySpans, seg *segment, traceID []byte, parentID []byte, kind v1.Span_SpanKind) error {
+	spanID, err := parseSpanID(seg.ID)
+	if err != nil {
+		return err
+	}
+
+	span := &v1.Span{
+		TraceId:           traceID,
+		SpanId:            spanID,
+		ParentSpanId:      parentID,
+		Name:              seg.Name,
+		Kind:              kind,
+		StartTimeUnixNano: secondsToNanos(seg.StartTime),
+		EndTimeUnixNano:   secondsToNanos(seg.EndTime),
+	}
+	if seg.InProgress || seg.EndTime < seg.StartTime {
+		span.EndTimeUnixNano = span.StartTimeUnixNano
+	}
+
+	if seg.Origin != "" {
+		span.Attributes = append(span.Attributes, stringAttribute(attributeOrigin, seg.Origin))
+	}
+	if seg.HTTP != nil && seg.HTTP.Request != nil {
+		span.Attributes = append(span.Attributes,
+			stringAttribute(attributeHTTPMethod, seg.HTTP.Request.Method),
+			stringAttribute(attributeHTTPURL, seg.HTTP.Request.URL))
+	}
+	if seg.HTTP != nil && seg.HTTP.Response != nil {
+		span.Attributes = append(span.Attributes, &v1_common.KeyValue{
+			Key:   attributeHTTPStatusCode,
+			Value: &v1_common.AnyValue{Value: &v1_common.AnyValue_IntValue{IntValue: seg.HTTP.Response.Status}},
+		})
+	}
+	span.Attributes = append(span.Attributes, annotationAttributes(seg.Annotations)...)
+
+	// faults are server side errors, errors are client side and throttles are a kind of error
+	switch {
+	case seg.Fault:
+		span.Status = &v1.Status{Code: v1.Status_InternalError}
+	case seg.Throttle:
+		span.Status = &v1.Status{Code: v1.Status_ResourceExhausted}
+	case seg.Error:
+		span.Status = &v1.Status{Code: v1.Status_UnknownError}
+	}
+
+	ils.Spans = append(ils.Spans, span)
+
+	for _, sub := range seg.Subsegments {
+		if sub == nil {
+			continue
+		}
+		err = appendSpans(ils, sub, traceID, spanID, subsegmentKind(sub))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// subsegmentKind treats calls to AWS services and remote hosts as client spans.
+func subsegmentKind(seg *segment) v1.Span_SpanKind {
+	if seg.Namespace == namespaceAWS || seg.Namespace == namespaceRemote {
+		return v1.Span_CLIENT
+	}
+	return v1.Span_INTERNAL
+}
+
+func annotationAttributes(annotations map[string]interface{}) []*v1_common.KeyValue {
+	keys := make([]string, 0, len(annotations))
+	for k := range annotations {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	attributes := make([]*v1_common.KeyValue, 0, len(keys))
+	for _, k := range keys {
+		switch v := annotations[k].(type) {
+		case string:
+			attributes = append(attributes, stringAttribute(k, v))
+		case bool:
+			attributes = append(attributes, &v1_common.KeyValue{Key: k, Value: &v1_common.AnyValue{Value: &v1_common.AnyValue_BoolValue{BoolValue: v}}})
+		case json.Number:
+			if i, err := v.Int64(); err == nil {
+				attributes = append(attributes, &v1_common.KeyValue{Key: k, Value: &v1_common.AnyValue{Value: &v1_common.AnyValue_IntValue{IntValue: i}}})
+			} else if f, err := v.Float64(); err == nil {
+				attributes = append(attributes, &v1_common.KeyValue{Key: k, Value: &v1_common.AnyValue{Value: &v1_common.AnyValue_DoubleValue{DoubleValue: f}}})
+			}
+		}
+	}
+	return attributes
+}
+
+// parseTraceID converts an X-Ray trace id, 1-<8 hex digit epoch>-<24 hex digits>, to 128 bits.
+func parseTraceID(id string) ([]byte, error) {
+	parts := strings.Split(id, "-")
+	if len(parts) != 3 || parts[0] != "1" || len(parts[1]) != 8 || len(parts[2]) != 24 {
+		return nil, fmt.Errorf("invalid trace id %q", id)
+	}
+
+	return hex.DecodeString(parts[1] + parts[2])
+}
+
+func parseSpanID(id string) ([]byte, error) {
+	b, err := hex.DecodeString(id)
+	if err != nil || len(b) != 8 {
+		return nil, fmt.Errorf("invalid segment id %q", id)
+	}
+	return b, nil
+}
+
+func secondsToNanos(s float64) uint64 {
+	if s <= 0 {
+		return 0
+	}
+	return uint64(s * 1e9)
+}
+
+func stringAttribute(k string, v string) *v1_common.KeyValue {
+	return &v1_common.KeyValue{
+		Key:   k,
+		Value: &v1_common.AnyValue{Value: &v1_common.AnyValue_StringValue{StringValue: v}},
+	}
+}
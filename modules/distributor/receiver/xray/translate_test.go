@@ -0,0 +1,92 @@
+package xray
+
+import (
+	"encoding/hex"
+	"testing"
+
+	v1 "github.com/open-telemetry/opentelemetry-proto/gen/go/trace/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testSegment = `{
+	"name": "checkout",
+	"id": "70de5b6f19ff9a0a",
+	"trace_id": "1-581cf771-a006649127e371903a2de979",
+	"start_time": 1478293361.271,
+	"end_time": 1478293361.449,
+	"origin": "AWS::Lambda::Function",
+	"http": {"request": {"method": "POST", "url": "https://example.com/checkout"}, "response": {"status": 500}},
+	"fault": true,
+	"annotations": {"customer": "123", "premium": true, "items": 3, "ratio": 0.25},
+	"subsegments": [
+		{
+			"name": "DynamoDB",
+			"id": "53995c3f42cd8ad8",
+			"start_time": 1478293361.3,
+			"end_time": 1478293361.4,
+			"namespace": "aws",
+			"subsegments": [
+				{"name": "marshal", "id": "43995c3f42cd8ad8", "start_time": 1478293361.31, "in_progress": true}
+			]
+		}
+	]
+}`
+
+func TestToResourceSpans(t *testing.T) {
+	batch, err := toResourceSpans([]byte(testSegment))
+	require.NoError(t, err)
+
+	assert.Equal(t, "checkout", batch.Resource.Attributes[0].Value.GetStringValue())
+	spans := batch.InstrumentationLibrarySpans[0].Spans
+	require.Len(t, spans, 3)
+
+	traceID, _ := hex.DecodeString("581cf771a006649127e371903a2de979")
+	for _, s := range spans {
+		assert.Equal(t, traceID, s.TraceId)
+	}
+
+	root := spans[0]
+	assert.Nil(t, root.ParentSpanId)
+	assert.Equal(t, v1.Span_SERVER, root.Kind)
+	assert.Equal(t, v1.Status_InternalError, root.Status.Code)
+	assert.InDelta(t, 178e6, float64(root.EndTimeUnixNano-root.StartTimeUnixNano), 1e3)
+
+	// origin, http method, url and status and four annotations.  annotations keep their types
+	require.Len(t, root.Attributes, 8)
+	assert.Equal(t, attributeHTTPStatusCode, root.Attributes[3].Key)
+	assert.Equal(t, int64(500), root.Attributes[3].Value.GetIntValue())
+	assert.Equal(t, "customer", root.Attributes[4].Key)
+	assert.Equal(t, "123", root.Attributes[4].Value.GetStringValue())
+	assert.Equal(t, "items", root.Attributes[5].Key)
+	assert.Equal(t, int64(3), root.Attributes[5].Value.GetIntValue())
+	assert.Equal(t, "premium", root.Attributes[6].Key)
+	assert.Equal(t, true, root.Attributes[6].Value.GetBoolValue())
+	assert.Equal(t, "ratio", root.Attributes[7].Key)
+	assert.Equal(t, 0.25, root.Attributes[7].Value.GetDoubleValue())
+
+	dynamo := spans[1]
+	assert.Equal(t, root.SpanId, dynamo.ParentSpanId)
+	assert.Equal(t, v1.Span_CLIENT, dynamo.Kind)
+	assert.Nil(t, dynamo.Status)
+
+	marshal := spans[2]
+	assert.Equal(t, dynamo.SpanId, marshal.ParentSpanId)
+	assert.Equal(t, v1.Span_INTERNAL, marshal.Kind)
+	assert.Equal(t, marshal.StartTimeUnixNano, marshal.EndTimeUnixNano)
+}
+
+func TestToResourceSpansInvalid(t *testing.T) {
+	for name, document := range map[string]string{
+		"not json":         `{`,
+		"bad trace id":     `{"name": "a", "id": "70de5b6f19ff9a0a", "trace_id": "581cf771a006649127e371903a2de979"}`,
+		"bad segment id":   `{"name": "a", "id": "70de", "trace_id": "1-581cf771-a006649127e371903a2de979"}`,
+		"bad subsegment":   `{"name": "a", "id": "70de5b6f19ff9a0a", "trace_id": "1-581cf771-a006649127e371903a2de979", "subsegments": [{"id": "zz"}]}`,
+		"bad parent id":    `{"name": "a", "id": "70de5b6f19ff9a0a", "trace_id": "1-581cf771-a006649127e371903a2de979", "parent_id": "x"}`,
+		"unversioned id":   `{"name": "a", "id": "70de5b6f19ff9a0a", "trace_id": "2-581cf771-a006649127e371903a2de979"}`,
+		"short trace part": `{"name": "a", "id": "70de5b6f19ff9a0a", "trace_id": "1-581cf77-a006649127e371903a2de979"}`,
+	} {
+		_, err := toResourceSpans([]byte(document))
+		assert.Error(t, err, name)
+	}
+}
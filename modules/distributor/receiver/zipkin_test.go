@@ -0,0 +1,125 @@
+package receiver
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cortexproject/cortex/pkg/util/services"
+	"github.com/golang/protobuf/proto"
+	zipkinproto "github.com/openzipkin/zipkin-go/proto/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/weaveworks/common/logging"
+	"github.com/weaveworks/common/user"
+
+	"github.com/grafana/tempo/pkg/tempopb"
+)
+
+type requestRecorder struct {
+	mtx     sync.Mutex
+	reqs    []*tempopb.PushRequest
+	tenants []string
+}
+
+func (r *requestRecorder) Push(ctx context.Context, req *tempopb.PushRequest) (*tempopb.PushResponse, error) {
+	tenant, _ := user.ExtractOrgID(ctx)
+
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	r.reqs = append(r.reqs, req)
+	r.tenants = append(r.tenants, tenant)
+	return &tempopb.PushResponse{}, nil
+}
+
+func (r *requestRecorder) requests() []*tempopb.PushRequest {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	return append([]*tempopb.PushRequest(nil), r.reqs...)
+}
+
+func (r *requestRecorder) requestTenants() []string {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	return append([]string(nil), r.tenants...)
+}
+
+// TestZipkinReceiver checks that zipkin v2 spans posted as json and protobuf reach the pusher as otel batches.
+func TestZipkinReceiver(t *testing.T) {
+	endpoint := freeAddress(t)
+	pusher := &requestRecorder{}
+
+	l := logging.Level{}
+	require.NoError(t, l.Set("error"))
+	shim, err := New(map[string]interface{}{
+		"zipkin": map[string]interface{}{
+			"endpoint": endpoint,
+		},
+	}, MetadataConfig{}, TenantRoutingConfig{}, BackpressureConfig{}, pusher, false, l)
+	require.NoError(t, err)
+	require.NoError(t, services.StartAndAwaitRunning(context.Background(), shim))
+	defer func() {
+		_ = services.StopAndAwaitTerminated(context.Background(), shim)
+	}()
+
+	traceID := []byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0a, 0x0b, 0x0c, 0x0d, 0x0e, 0x0f, 0x10}
+	jsonSpans := `[{
+		"traceId": "0102030405060708090a0b0c0d0e0f10",
+		"id": "0102030405060708",
+		"name": "get",
+		"kind": "SERVER",
+		"timestamp": 1600000000000000,
+		"duration": 1000,
+		"localEndpoint": {"serviceName": "frontend"}
+	}]`
+	postSpans(t, endpoint, "application/json", []byte(jsonSpans))
+
+	protoSpans, err := proto.Marshal(&zipkinproto.ListOfSpans{
+		Spans: []*zipkinproto.Span{
+			{
+				TraceId:       traceID,
+				Id:            []byte{0x11, 0x12, 0x13, 0x14, 0x15, 0x16, 0x17, 0x18},
+				Name:          "query",
+				Kind:          zipkinproto.Span_CLIENT,
+				Timestamp:     1600000000000000,
+				Duration:      500,
+				LocalEndpoint: &zipkinproto.Endpoint{ServiceName: "backend"},
+			},
+		},
+	})
+	require.NoError(t, err)
+	postSpans(t, endpoint, "application/x-protobuf", protoSpans)
+
+	require.Eventually(t, func() bool {
+		return len(pusher.requests()) == 2
+	}, 5*time.Second, 10*time.Millisecond)
+
+	names := []string{}
+	for _, req := range pusher.requests() {
+		for _, ils := range req.Batch.InstrumentationLibrarySpans {
+			for _, span := range ils.Spans {
+				assert.Equal(t, traceID, span.TraceId)
+				names = append(names, span.Name)
+			}
+		}
+	}
+	assert.ElementsMatch(t, []string{"get", "query"}, names)
+}
+
+func postSpans(t *testing.T, endpoint string, contentType string, body []byte) {
+	resp, err := http.Post("http://"+endpoint+"/api/v2/spans", contentType, bytes.NewReader(body))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusAccepted, resp.StatusCode)
+}
+
+func freeAddress(t *testing.T) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer l.Close()
+	return l.Addr().String()
+}
@@ -0,0 +1,177 @@
+package distributor
+
+import (
+	"flag"
+	"hash/fnv"
+	"math"
+	"sync"
+	"time"
+
+	opentelemetry_proto_trace_v1 "github.com/open-telemetry/opentelemetry-proto/gen/go/trace/v1"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/grafana/tempo/pkg/tenantmetrics"
+	"github.com/grafana/tempo/pkg/util"
+)
+
+const (
+	sampled = "sampled"
+	dropped = "dropped"
+
+	// samplingRateWindow is the period over which the received spans of a tenant are counted to estimate its rate.
+	samplingRateWindow = 10 * time.Second
+)
+
+var (
+	metricSamplingSpans = tenantmetrics.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "tempo",
+		Name:      "distributor_sampling_spans_total",
+		Help:      "The total number of spans kept (sampled) or dropped by head sampling per tenant.",
+	}, []string{"tenant", "decision"})
+	metricSamplingRatio = tenantmetrics.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "tempo",
+		Name:      "distributor_sampling_ratio",
+		Help:      "The fraction of traces currently kept by head sampling per tenant.",
+	}, []string{"tenant"})
+)
+
+// SamplingConfig is the head sampling of traces in the distributors.  Traces are kept or dropped by their trace id so
+// every distributor makes the same decision for all spans of a trace.
+type SamplingConfig struct {
+	// Ratio is the fraction of traces kept.  1 keeps every trace.
+	Ratio float64 `yaml:"ratio"`
+	// MaxSpansPerSecond lowers the ratio of a tenant further so that each distributor keeps about this many spans per
+	// second.  0 disables the limit.
+	MaxSpansPerSecond int `yaml:"max_spans_per_second"`
+}
+
+// RegisterFlags registers the flags.
+func (cfg *SamplingConfig) RegisterFlags(prefix string, f *flag.FlagSet) {
+	f.Float64Var(&cfg.Ratio, util.PrefixConfig(prefix, "ratio"), 1, "Fraction of traces kept by head sampling, chosen by trace id. 1 keeps every trace.")
+	f.IntVar(&cfg.MaxSpansPerSecond, util.PrefixConfig(prefix, "max-spans-per-second"), 0, "Spans per second each distributor keeps per tenant. Lowers the sampling ratio of tenants sending more. 0 disables the limit.")
+}
+
+// samplingOverrides are the per tenant overrides of the sampling config.
+type samplingOverrides interface {
+	SamplingRatio(userID string) float64
+	SamplingMaxSpansPerSecond(userID string) int
+}
+
+// sampler drops the spans of traces that aren't sampled.
+type sampler struct {
+	cfg       SamplingConfig
+	overrides samplingOverrides
+
+	mtx     sync.Mutex
+	tenants map[string]*tenantSpanRate
+}
+
+// tenantSpanRate estimates the spans per second received for a tenant from the spans counted over the last window.
+type tenantSpanRate struct {
+	windowStart time.Time
+	spans       int
+	rate        float64
+}
+
+// newSampler returns a sampler applying the overrides of each tenant over cfg.
+func newSampler(cfg SamplingConfig, overrides samplingOverrides) *sampler {
+	return &sampler{
+		cfg:       cfg,
+		overrides: overrides,
+		tenants:   map[string]*tenantSpanRate{},
+	}
+}
+
+// ratio returns the fraction of the tenant's traces to keep.  spans are counted toward the tenant's rate.
+func (s *sampler) ratio(userID string, spans int, now time.Time) float64 {
+	ratio := s.overrides.SamplingRatio(userID)
+	if ratio <= 0 {
+		ratio = s.cfg.Ratio
+	}
+	maxSpans := s.overrides.SamplingMaxSpansPerSecond(userID)
+	if maxSpans <= 0 {
+		maxSpans = s.cfg.MaxSpansPerSecond
+	}
+	if maxSpans <= 0 {
+		return ratio
+	}
+
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	t, ok := s.tenants[userID]
+	if !ok {
+		t = &tenantSpanRate{windowStart: now}
+		s.tenants[userID] = t
+	}
+	if elapsed := now.Sub(t.windowStart); elapsed >= samplingRateWindow {
+		t.rate = float64(t.spans) / elapsed.Seconds()
+		t.windowStart = now
+		t.spans = 0
+	}
+	t.spans += spans
+
+	// until a window has passed the rate of the current one is used
+	rate := t.rate
+	if rate == 0 {
+		rate = float64(t.spans) / math.Max(now.Sub(t.windowStart).Seconds(), 1)
+	}
+	if limited := float64(maxSpans) / rate; limited < ratio {
+		ratio = limited
+	}
+	return ratio
+}
+
+// sample removes the spans of traces that aren't sampled from the batch and returns the number of spans left.
+func (s *sampler) sample(userID string, batch *opentelemetry_proto_trace_v1.ResourceSpans, spanCount int, now time.Time) int {
+	ratio := s.ratio(userID, spanCount, now)
+	metricSamplingRatio.WithLabelValues(userID).Set(math.Min(ratio, 1))
+	if ratio >= 1 {
+		metricSamplingSpans.WithLabelValues(userID, sampled).Add(float64(spanCount))
+		return spanCount
+	}
+
+	kept := 0
+	newILS := batch.InstrumentationLibrarySpans[:0]
+	for _, ils := range batch.InstrumentationLibrarySpans {
+		newSpans := ils.Spans[:0]
+		for _, span := range ils.Spans {
+			if traceSampled(span.TraceId, ratio) {
+				newSpans = append(newSpans, span)
+			}
+		}
+
+		kept += len(newSpans)
+		if len(newSpans) > 0 {
+			ils.Spans = newSpans
+			newILS = append(newILS, ils)
+		}
+	}
+	batch.InstrumentationLibrarySpans = newILS
+
+	metricSamplingSpans.WithLabelValues(userID, sampled).Add(float64(kept))
+	metricSamplingSpans.WithLabelValues(userID, dropped).Add(float64(spanCount - kept))
+	return kept
+}
+
+// traceSampled decides by the trace id alone, so spans of the trace received at any time and by any distributor are
+// kept or dropped together while the ratio is the same.  The trace id is hashed since not every client generates
+// random ids.  A different hash than the ring token is used so the traces kept are spread over all ingesters.
+func traceSampled(traceID []byte, ratio float64) bool {
+	h := fnv.New64a()
+	_, _ = h.Write(traceID)
+
+	// the top 53 bits of the hash as a float in [0, 1)
+	return float64(mix64(h.Sum64())>>11)/(1<<53) < ratio
+}
+
+// mix64 is the murmur3 finalizer.  The high bits of fnv barely change with the last bytes of the input, which are the
+// ones that differ between sequential ids.
+func mix64(h uint64) uint64 {
+	h ^= h >> 33
+	h *= 0xff51afd7ed558ccd
+	h ^= h >> 33
+	h *= 0xc4ceb9fe1a85ec53
+	h ^= h >> 33
+	return h
+}
@@ -0,0 +1,102 @@
+package distributor
+
+import (
+	"encoding/binary"
+	"testing"
+	"time"
+
+	v1 "github.com/open-telemetry/opentelemetry-proto/gen/go/trace/v1"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeSamplingOverrides struct {
+	ratio    float64
+	maxSpans int
+}
+
+func (o fakeSamplingOverrides) SamplingRatio(string) float64 {
+	return o.ratio
+}
+
+func (o fakeSamplingOverrides) SamplingMaxSpansPerSecond(string) int {
+	return o.maxSpans
+}
+
+// makeSamplingBatch returns a batch with a span of each of n traces.
+func makeSamplingBatch(n int) *v1.ResourceSpans {
+	ils := &v1.InstrumentationLibrarySpans{}
+	for i := 0; i < n; i++ {
+		traceID := make([]byte, 16)
+		binary.BigEndian.PutUint64(traceID[8:], uint64(i))
+		ils.Spans = append(ils.Spans, &v1.Span{TraceId: traceID})
+	}
+	return &v1.ResourceSpans{InstrumentationLibrarySpans: []*v1.InstrumentationLibrarySpans{ils}}
+}
+
+func TestSamplerRatio(t *testing.T) {
+	now := time.Now()
+	s := newSampler(SamplingConfig{Ratio: 0.5}, fakeSamplingOverrides{})
+
+	batch := makeSamplingBatch(10000)
+	kept := s.sample("test", batch, 10000, now)
+	assert.InDelta(t, 5000, kept, 250)
+	assert.Equal(t, kept, len(batch.InstrumentationLibrarySpans[0].Spans))
+
+	// the same traces are kept every time
+	for _, span := range batch.InstrumentationLibrarySpans[0].Spans {
+		assert.True(t, traceSampled(span.TraceId, 0.5))
+	}
+	again := makeSamplingBatch(10000)
+	assert.Equal(t, kept, s.sample("test", again, 10000, now))
+	assert.Equal(t, batch, again)
+
+	// a batch without sampled traces is emptied
+	traceID := []byte{0x01}
+	for traceSampled(traceID, 0.5) {
+		traceID[0]++
+	}
+	batch = &v1.ResourceSpans{InstrumentationLibrarySpans: []*v1.InstrumentationLibrarySpans{{
+		Spans: []*v1.Span{{TraceId: traceID}},
+	}}}
+	assert.Equal(t, 0, s.sample("test", batch, 1, now))
+	assert.Empty(t, batch.InstrumentationLibrarySpans)
+}
+
+func TestSamplerKeepsAll(t *testing.T) {
+	s := newSampler(SamplingConfig{Ratio: 1}, fakeSamplingOverrides{})
+
+	batch := makeSamplingBatch(100)
+	assert.Equal(t, 100, s.sample("test", batch, 100, time.Now()))
+	assert.Equal(t, makeSamplingBatch(100), batch)
+}
+
+func TestSamplerOverrides(t *testing.T) {
+	now := time.Now()
+
+	s := newSampler(SamplingConfig{Ratio: 1}, fakeSamplingOverrides{ratio: 0.25})
+	assert.Equal(t, 0.25, s.ratio("test", 1, now))
+
+	s = newSampler(SamplingConfig{Ratio: 1, MaxSpansPerSecond: 1000}, fakeSamplingOverrides{maxSpans: 100})
+	assert.Equal(t, 0.1, s.ratio("test", 1000, now))
+}
+
+func TestSamplerMaxSpansPerSecond(t *testing.T) {
+	now := time.Now()
+	s := newSampler(SamplingConfig{Ratio: 1, MaxSpansPerSecond: 100}, fakeSamplingOverrides{})
+
+	// under the limit
+	assert.Equal(t, 1.0, s.ratio("other", 50, now))
+
+	// 1000 spans per second over the window lowers the ratio to a tenth
+	for i := 0; i < 10; i++ {
+		s.ratio("test", 1000, now.Add(time.Duration(i)*time.Second))
+	}
+	assert.InDelta(t, 0.1, s.ratio("test", 1000, now.Add(samplingRateWindow)), 0.001)
+
+	// tenants are limited separately
+	assert.Equal(t, 1.0, s.ratio("other", 50, now.Add(time.Second)))
+
+	batch := makeSamplingBatch(10000)
+	kept := s.sample("test", batch, 10000, now.Add(samplingRateWindow+time.Second))
+	assert.InDelta(t, 1000, kept, 200)
+}
@@ -0,0 +1,193 @@
+package distributor
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/golang/protobuf/jsonpb"
+	opentelemetry_proto_trace_v1 "github.com/open-telemetry/opentelemetry-proto/gen/go/trace/v1"
+	"github.com/weaveworks/common/user"
+
+	"github.com/grafana/tempo/pkg/tempopb"
+	"github.com/grafana/tempo/pkg/validation"
+)
+
+const (
+	maxValidateBodyBytes = 16 << 20
+	contentTypeProtobuf  = "application/protobuf"
+	contentTypeXProtobuf = "application/x-protobuf"
+
+	validateReasonInvalidTraceID  = "invalid_trace_id"
+	validateReasonBatchTooLarge   = "batch_too_large"
+	validateReasonTooManySpans    = "max_spans_per_trace"
+	validateReasonTruncatedAttrs  = "max_attributes_per_span"
+	validateReasonTooManyAttrKeys = "max_attribute_keys_per_block"
+)
+
+// ValidationProblem is a reason some of a validated trace would not be stored as sent.
+type ValidationProblem struct {
+	Reason  string `json:"reason"`
+	TraceID string `json:"traceID,omitempty"`
+	Spans   int    `json:"spans,omitempty"`
+	Message string `json:"message"`
+}
+
+// ValidationResult is what ingest would do with a trace.
+type ValidationResult struct {
+	ReceivedSpans       int                 `json:"receivedSpans"`
+	AcceptedSpans       int                 `json:"acceptedSpans"`
+	RejectedSpans       int                 `json:"rejectedSpans"`
+	TruncatedAttributes int                 `json:"truncatedAttributes"`
+	Problems            []ValidationProblem `json:"problems"`
+}
+
+// Validate applies the checks and limits ingest would apply to the batches of trace for a tenant without storing
+// anything or consuming the tenant's rate limit.  Limits that depend on the state of the ingesters, such as the
+// number of live traces, are not checked and ingester limits are evaluated against this trace alone.  trace is
+// modified as attributes are truncated.
+func (d *Distributor) Validate(userID string, trace *tempopb.Trace) *ValidationResult {
+	result := &ValidationResult{
+		Problems: []ValidationProblem{},
+	}
+
+	maxBatchSize := d.overrides.IngestionMaxBatchSize(userID)
+	maxAttributes := d.overrides.MaxAttributesPerSpan(userID)
+	maxSpans := d.overrides.MaxSpansPerTrace(userID)
+	maxAttributeKeys := d.overrides.MaxAttributeKeysPerBlock(userID)
+
+	spansByTrace := map[string]int{}
+	attributeKeys := map[string]struct{}{}
+	for _, batch := range trace.Batches {
+		if batch == nil {
+			continue
+		}
+
+		spanCount := 0
+		for _, ils := range batch.InstrumentationLibrarySpans {
+			spanCount += len(ils.Spans)
+		}
+		result.ReceivedSpans += spanCount
+
+		// the distributor rejects whole batches
+		if spanCount > maxBatchSize {
+			result.reject(ValidationProblem{
+				Reason:  validateReasonBatchTooLarge,
+				Spans:   spanCount,
+				Message: fmt.Sprintf("batch of %d spans exceeds the ingestion burst of %d spans. the sustained limit is %d spans per second", spanCount, maxBatchSize, int(d.ingestionRateLimiter.Limit(time.Now(), userID))),
+			})
+			continue
+		}
+		if invalid := invalidTraceIDs(batch.InstrumentationLibrarySpans); invalid > 0 {
+			result.reject(ValidationProblem{
+				Reason:  validateReasonInvalidTraceID,
+				Spans:   spanCount,
+				Message: fmt.Sprintf("%d spans have trace ids that are not 128 bit. the whole batch is rejected", invalid),
+			})
+			continue
+		}
+
+		if maxAttributes > 0 {
+			if truncated := truncateSpanAttributes(batch, maxAttributes); truncated > 0 {
+				result.TruncatedAttributes += truncated
+				result.Problems = append(result.Problems, ValidationProblem{
+					Reason:  validateReasonTruncatedAttrs,
+					Message: fmt.Sprintf("%d attributes were truncated from spans with more than %d attributes", truncated, maxAttributes),
+				})
+			}
+		}
+
+		for _, ils := range batch.InstrumentationLibrarySpans {
+			for _, span := range ils.Spans {
+				spansByTrace[string(span.TraceId)]++
+				for _, kv := range span.Attributes {
+					attributeKeys[kv.Key] = struct{}{}
+				}
+			}
+		}
+	}
+
+	// ingesters reject the push that takes a trace past the limit
+	for traceID, spans := range spansByTrace {
+		if maxSpans > 0 && spans > maxSpans {
+			result.reject(ValidationProblem{
+				Reason:  validateReasonTooManySpans,
+				TraceID: hex.EncodeToString([]byte(traceID)),
+				Spans:   spans,
+				Message: fmt.Sprintf("trace has %d spans which exceeds the limit of %d spans per trace", spans, maxSpans),
+			})
+			continue
+		}
+		result.AcceptedSpans += spans
+	}
+
+	if maxAttributeKeys > 0 && len(attributeKeys) > maxAttributeKeys {
+		result.Problems = append(result.Problems, ValidationProblem{
+			Reason:  validateReasonTooManyAttrKeys,
+			Message: fmt.Sprintf("spans have %d unique attribute keys. once a block holds %d keys attributes with new keys are dropped", len(attributeKeys), maxAttributeKeys),
+		})
+	}
+
+	return result
+}
+
+func (r *ValidationResult) reject(problem ValidationProblem) {
+	r.RejectedSpans += problem.Spans
+	r.Problems = append(r.Problems, problem)
+}
+
+// ValidateHandler is a http.HandlerFunc that reports what ingest would do with the posted trace.  The trace is
+// accepted as json or, with a protobuf content type, as a protobuf encoded tempopb.Trace.
+func (d *Distributor) ValidateHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "traces must be validated with POST", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, err := user.ExtractOrgID(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxValidateBodyBytes))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	trace := &tempopb.Trace{}
+	switch r.Header.Get("Content-Type") {
+	case contentTypeProtobuf, contentTypeXProtobuf:
+		err = proto.Unmarshal(body, trace)
+	default:
+		err = jsonpb.UnmarshalString(string(body), trace)
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("unable to parse trace: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(d.Validate(userID, trace))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+func invalidTraceIDs(ilss []*opentelemetry_proto_trace_v1.InstrumentationLibrarySpans) int {
+	invalid := 0
+	for _, ils := range ilss {
+		for _, span := range ils.Spans {
+			if !validation.ValidTraceID(span.TraceId) {
+				invalid++
+			}
+		}
+	}
+	return invalid
+}
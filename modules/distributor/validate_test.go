@@ -0,0 +1,49 @@
+package distributor
+
+import (
+	"testing"
+
+	"github.com/cortexproject/cortex/pkg/util/flagext"
+	v1_common "github.com/open-telemetry/opentelemetry-proto/gen/go/common/v1"
+	v1 "github.com/open-telemetry/opentelemetry-proto/gen/go/trace/v1"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/grafana/tempo/modules/overrides"
+	"github.com/grafana/tempo/pkg/tempopb"
+	"github.com/grafana/tempo/pkg/util/test"
+)
+
+func TestValidate(t *testing.T) {
+	limits := &overrides.Limits{}
+	flagext.DefaultValues(limits)
+	limits.IngestionMaxBatchSize = 20
+	limits.MaxSpansPerTrace = 10
+	limits.MaxAttributesPerSpan = 1
+	d := prepare(t, limits, nil)
+
+	valid := test.MakeRequest(5, nil).Batch
+	valid.InstrumentationLibrarySpans[0].Spans[0].Attributes = []*v1_common.KeyValue{{Key: "a"}, {Key: "b"}}
+	tooLarge := test.MakeRequest(25, nil).Batch
+	tooManySpans := test.MakeRequest(15, nil).Batch
+	invalidID := test.MakeRequest(3, []byte{0x01}).Batch
+
+	result := d.Validate("test", &tempopb.Trace{
+		Batches: []*v1.ResourceSpans{valid, tooLarge, tooManySpans, invalidID},
+	})
+
+	assert.Equal(t, 48, result.ReceivedSpans)
+	assert.Equal(t, 5, result.AcceptedSpans)
+	assert.Equal(t, 43, result.RejectedSpans)
+	assert.Equal(t, 1, result.TruncatedAttributes)
+
+	reasons := []string{}
+	for _, p := range result.Problems {
+		reasons = append(reasons, p.Reason)
+	}
+	assert.ElementsMatch(t, []string{
+		validateReasonBatchTooLarge,
+		validateReasonInvalidTraceID,
+		validateReasonTruncatedAttrs,
+		validateReasonTooManySpans,
+	}, reasons)
+}
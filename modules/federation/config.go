@@ -0,0 +1,29 @@
+package federation
+
+import (
+	"flag"
+	"time"
+
+	"github.com/grafana/tempo/pkg/util"
+)
+
+// ClusterConfig is a Tempo cluster queried by the federation proxy.
+type ClusterConfig struct {
+	// Name identifies the cluster in errors and metrics.
+	Name string `yaml:"name"`
+	// URL is the base url of the cluster's query frontend or querier, e.g. http://tempo-eu:3100.
+	URL string `yaml:"url"`
+}
+
+// Config for the federation proxy.
+type Config struct {
+	// Clusters are configured in yaml only.
+	Clusters []ClusterConfig `yaml:"clusters"`
+	// Timeout bounds each request to a cluster.
+	Timeout time.Duration `yaml:"timeout"`
+}
+
+// RegisterFlagsAndApplyDefaults registers flags and applies defaults
+func (cfg *Config) RegisterFlagsAndApplyDefaults(prefix string, f *flag.FlagSet) {
+	f.DurationVar(&cfg.Timeout, util.PrefixConfig(prefix, "timeout"), 30*time.Second, "Timeout of each request to a federated cluster.")
+}
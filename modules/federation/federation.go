@@ -0,0 +1,297 @@
+package federation
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/weaveworks/common/user"
+
+	"github.com/grafana/tempo/modules/querier"
+	"github.com/grafana/tempo/pkg/tempopb"
+	"github.com/grafana/tempo/pkg/util"
+)
+
+// ErrorHeader is added to a response once for every cluster that failed, as <cluster>: <error>.
+const ErrorHeader = "X-Tempo-Federation-Error"
+
+// maxErrorLength bounds the part of a cluster's error response that is reported.
+const maxErrorLength = 256
+
+var (
+	metricClusterRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "tempo",
+		Name:      "federation_cluster_requests_total",
+		Help:      "The total number of requests sent to each federated cluster by result.",
+	}, []string{"cluster", "result"})
+)
+
+// Federation is a stateless proxy that sends trace lookups and searches to several Tempo clusters and merges the
+// results, so several regions can be read from one endpoint.
+type Federation struct {
+	clusters []cluster
+	client   *http.Client
+	logger   log.Logger
+}
+
+type cluster struct {
+	name string
+	url  *url.URL
+}
+
+// clusterResponse is the body of a successful response from a cluster, or the reason it failed.  A trace that isn't
+// found is neither.
+type clusterResponse struct {
+	cluster string
+	body    []byte
+	status  int
+	err     error
+}
+
+// New makes a new Federation.
+func New(cfg Config, logger log.Logger) (*Federation, error) {
+	if len(cfg.Clusters) == 0 {
+		return nil, fmt.Errorf("at least one cluster must be configured")
+	}
+
+	f := &Federation{
+		client: &http.Client{Timeout: cfg.Timeout},
+		logger: logger,
+	}
+
+	seen := map[string]struct{}{}
+	for _, c := range cfg.Clusters {
+		if c.Name == "" {
+			return nil, fmt.Errorf("cluster %s has no name", c.URL)
+		}
+		if _, ok := seen[c.Name]; ok {
+			return nil, fmt.Errorf("cluster %s is configured more than once", c.Name)
+		}
+		seen[c.Name] = struct{}{}
+
+		u, err := url.Parse(c.URL)
+		if err != nil || u.Scheme == "" || u.Host == "" {
+			return nil, fmt.Errorf("cluster %s has an invalid url %q", c.Name, c.URL)
+		}
+		f.clusters = append(f.clusters, cluster{name: c.Name, url: u})
+	}
+
+	return f, nil
+}
+
+// TraceByIDHandler is a http.HandlerFunc that looks up a trace in every cluster and combines the spans found.  The
+// trace is returned if any cluster found it, with a header for each cluster that failed.
+func (f *Federation) TraceByIDHandler(w http.ResponseWriter, r *http.Request) {
+	traceID, ok := mux.Vars(r)[querier.TraceIDVar]
+	if !ok {
+		http.Error(w, "please provide a traceID", http.StatusBadRequest)
+		return
+	}
+	if _, err := util.HexStringToTraceID(traceID); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var (
+		combined *tempopb.Trace
+		failed   []clusterResponse
+	)
+	for _, resp := range f.fanOut(r.Context(), r, "/api/traces/"+traceID) {
+		if resp.err != nil {
+			failed = append(failed, resp)
+			continue
+		}
+		if resp.body == nil {
+			continue
+		}
+
+		trace := &tempopb.Trace{}
+		if err := jsonpb.Unmarshal(bytes.NewReader(resp.body), trace); err != nil {
+			resp.err = fmt.Errorf("failed to unmarshal trace: %w", err)
+			failed = append(failed, resp)
+			continue
+		}
+		combined = util.CombineTraceProtos(combined, trace)
+	}
+
+	if combined == nil {
+		if len(failed) > 0 {
+			writeFailure(w, failed)
+			return
+		}
+		http.Error(w, fmt.Sprintf("Unable to find %s", traceID), http.StatusNotFound)
+		return
+	}
+
+	addErrorHeaders(w, failed)
+	w.Header().Set("Content-Type", "application/json")
+	if err := (&jsonpb.Marshaler{}).Marshal(w, combined); err != nil {
+		level.Warn(f.logger).Log("msg", "failed to write trace", "traceID", traceID, "err", err)
+	}
+}
+
+// SearchHandler is a http.HandlerFunc that searches every cluster and merges the traces found.  Results are returned
+// if any cluster answered, with a header for each cluster that failed.
+func (f *Federation) SearchHandler(w http.ResponseWriter, r *http.Request) {
+	// invalid limits are rejected by the clusters
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+
+	var (
+		resps  []*tempopb.SearchResponse
+		failed []clusterResponse
+	)
+	for _, resp := range f.fanOut(r.Context(), r, "/api/search") {
+		if resp.err == nil && resp.body == nil {
+			resp.err = fmt.Errorf("unexpected status code %d", http.StatusNotFound)
+			resp.status = http.StatusNotFound
+		}
+		if resp.err != nil {
+			failed = append(failed, resp)
+			continue
+		}
+
+		found := &tempopb.SearchResponse{}
+		if err := jsonpb.Unmarshal(bytes.NewReader(resp.body), found); err != nil {
+			resp.err = fmt.Errorf("failed to unmarshal search response: %w", err)
+			failed = append(failed, resp)
+			continue
+		}
+		resps = append(resps, found)
+	}
+
+	if len(resps) == 0 {
+		writeFailure(w, failed)
+		return
+	}
+
+	addErrorHeaders(w, failed)
+	w.Header().Set("Content-Type", "application/json")
+	if err := (&jsonpb.Marshaler{}).Marshal(w, querier.CombineSearchResponses(resps, limit)); err != nil {
+		level.Warn(f.logger).Log("msg", "failed to write search response", "err", err)
+	}
+}
+
+// fanOut sends a GET of path with the query of r to every cluster in parallel.  The tenant of the request is passed
+// along.
+func (f *Federation) fanOut(ctx context.Context, r *http.Request, path string) []clusterResponse {
+	results := make(chan clusterResponse, len(f.clusters))
+	for _, c := range f.clusters {
+		go func(c cluster) {
+			results <- f.get(ctx, c, path, r.URL.RawQuery)
+		}(c)
+	}
+
+	resps := make([]clusterResponse, 0, len(f.clusters))
+	for range f.clusters {
+		resp := <-results
+
+		result := "success"
+		switch {
+		case resp.err != nil:
+			result = "error"
+			level.Warn(f.logger).Log("msg", "federated cluster request failed", "cluster", resp.cluster, "path", path, "err", resp.err)
+		case resp.body == nil:
+			result = "not_found"
+		}
+		metricClusterRequests.WithLabelValues(resp.cluster, result).Inc()
+
+		resps = append(resps, resp)
+	}
+
+	return resps
+}
+
+func (f *Federation) get(ctx context.Context, c cluster, path string, rawQuery string) clusterResponse {
+	u := *c.url
+	u.Path = strings.TrimSuffix(u.Path, "/") + path
+	u.RawQuery = rawQuery
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return clusterResponse{cluster: c.name, err: err}
+	}
+	// without auth the org id is ignored by the cluster
+	if orgID, err := user.ExtractOrgID(ctx); err == nil {
+		req.Header.Set(user.OrgIDHeaderName, orgID)
+	}
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return clusterResponse{cluster: c.name, err: err}
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		body, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			return clusterResponse{cluster: c.name, err: err}
+		}
+		return clusterResponse{cluster: c.name, body: body}
+	case http.StatusNotFound:
+		_, _ = io.Copy(ioutil.Discard, resp.Body)
+		return clusterResponse{cluster: c.name}
+	default:
+		msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, maxErrorLength))
+		return clusterResponse{
+			cluster: c.name,
+			status:  resp.StatusCode,
+			err:     fmt.Errorf("status code %d: %s", resp.StatusCode, strings.TrimSpace(string(msg))),
+		}
+	}
+}
+
+// writeFailure responds when no cluster answered.  If every cluster failed with the same status, such as a 400 for a
+// bad request, it's passed on.  Otherwise it's a 502.
+func writeFailure(w http.ResponseWriter, failed []clusterResponse) {
+	code := http.StatusBadGateway
+	if len(failed) > 0 && failed[0].status != 0 {
+		code = failed[0].status
+		for _, resp := range failed {
+			if resp.status != code {
+				code = http.StatusBadGateway
+				break
+			}
+		}
+	}
+
+	msgs := make([]string, 0, len(failed))
+	for _, resp := range failed {
+		msgs = append(msgs, errorMessage(resp))
+	}
+
+	addErrorHeaders(w, failed)
+	http.Error(w, strings.Join(msgs, "\n"), code)
+}
+
+func addErrorHeaders(w http.ResponseWriter, failed []clusterResponse) {
+	for _, resp := range failed {
+		w.Header().Add(ErrorHeader, errorMessage(resp))
+	}
+}
+
+// errorMessage is safe to use as a header value.
+func errorMessage(resp clusterResponse) string {
+	msg := resp.cluster + ": " + resp.err.Error()
+	if len(msg) > maxErrorLength {
+		msg = msg[:maxErrorLength]
+	}
+	return strings.Map(func(r rune) rune {
+		if r < ' ' || r == 0x7f {
+			return ' '
+		}
+		return r
+	}, msg)
+}
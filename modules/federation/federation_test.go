@@ -0,0 +1,137 @@
+package federation
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/go-kit/kit/log"
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/weaveworks/common/user"
+
+	"github.com/grafana/tempo/modules/querier"
+	"github.com/grafana/tempo/pkg/tempopb"
+	"github.com/grafana/tempo/pkg/util/test"
+)
+
+// orgIDsMtx guards the org ids recorded by fake clusters, which are called in parallel
+var orgIDsMtx sync.Mutex
+
+// fakeCluster responds to every request with the status and message, and records the org ids it was sent.
+func fakeCluster(t *testing.T, status int, msg *tempopb.SearchResponse, trace *tempopb.Trace, orgIDs *[]string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		orgIDsMtx.Lock()
+		*orgIDs = append(*orgIDs, r.Header.Get(user.OrgIDHeaderName))
+		orgIDsMtx.Unlock()
+
+		if status != http.StatusOK {
+			http.Error(w, "failed", status)
+			return
+		}
+
+		buffer := &bytes.Buffer{}
+		if r.URL.Path == "/api/search" {
+			require.NoError(t, (&jsonpb.Marshaler{}).Marshal(buffer, msg))
+		} else {
+			require.NoError(t, (&jsonpb.Marshaler{}).Marshal(buffer, trace))
+		}
+		_, _ = w.Write(buffer.Bytes())
+	}))
+}
+
+func newFederation(t *testing.T, urls ...string) *Federation {
+	cfg := Config{}
+	for i, u := range urls {
+		cfg.Clusters = append(cfg.Clusters, ClusterConfig{Name: string(rune('a' + i)), URL: u})
+	}
+
+	f, err := New(cfg, log.NewNopLogger())
+	require.NoError(t, err)
+	return f
+}
+
+func traceRequest() *http.Request {
+	r := httptest.NewRequest(http.MethodGet, "/api/traces/0102", nil)
+	r = r.WithContext(user.InjectOrgID(r.Context(), "tenant"))
+	return mux.SetURLVars(r, map[string]string{querier.TraceIDVar: "0102"})
+}
+
+func TestNewValidates(t *testing.T) {
+	_, err := New(Config{}, log.NewNopLogger())
+	assert.Error(t, err)
+
+	_, err = New(Config{Clusters: []ClusterConfig{{Name: "a", URL: "not a url"}}}, log.NewNopLogger())
+	assert.Error(t, err)
+
+	_, err = New(Config{Clusters: []ClusterConfig{{Name: "a", URL: "http://a"}, {Name: "a", URL: "http://b"}}}, log.NewNopLogger())
+	assert.Error(t, err)
+}
+
+func TestTraceByID(t *testing.T) {
+	var orgIDs []string
+	found := fakeCluster(t, http.StatusOK, nil, test.MakeTrace(2, []byte{0x01, 0x02}), &orgIDs)
+	defer found.Close()
+	notFound := fakeCluster(t, http.StatusNotFound, nil, nil, &orgIDs)
+	defer notFound.Close()
+	failed := fakeCluster(t, http.StatusInternalServerError, nil, nil, &orgIDs)
+	defer failed.Close()
+
+	// the trace is returned with the failure of the third cluster
+	w := httptest.NewRecorder()
+	newFederation(t, found.URL, notFound.URL, failed.URL).TraceByIDHandler(w, traceRequest())
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, []string{"c: status code 500: failed"}, w.Header()[ErrorHeader])
+	assert.Equal(t, []string{"tenant", "tenant", "tenant"}, orgIDs)
+
+	actual := &tempopb.Trace{}
+	require.NoError(t, jsonpb.Unmarshal(w.Body, actual))
+	assert.Len(t, actual.Batches, 2)
+
+	// not found anywhere
+	w = httptest.NewRecorder()
+	newFederation(t, notFound.URL, notFound.URL).TraceByIDHandler(w, traceRequest())
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	// the trace may be in the cluster that failed
+	w = httptest.NewRecorder()
+	newFederation(t, notFound.URL, failed.URL).TraceByIDHandler(w, traceRequest())
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Equal(t, []string{"b: status code 500: failed"}, w.Header()[ErrorHeader])
+}
+
+func TestSearch(t *testing.T) {
+	var orgIDs []string
+	a := fakeCluster(t, http.StatusOK, &tempopb.SearchResponse{Traces: []*tempopb.TraceSearchMetadata{
+		{TraceID: "01", StartTimeUnixNano: 1},
+		{TraceID: "02", StartTimeUnixNano: 2},
+	}}, nil, &orgIDs)
+	defer a.Close()
+	b := fakeCluster(t, http.StatusOK, &tempopb.SearchResponse{Traces: []*tempopb.TraceSearchMetadata{
+		{TraceID: "02", StartTimeUnixNano: 2},
+		{TraceID: "03", StartTimeUnixNano: 3},
+	}}, nil, &orgIDs)
+	defer b.Close()
+	throttled := fakeCluster(t, http.StatusTooManyRequests, nil, nil, &orgIDs)
+	defer throttled.Close()
+
+	w := httptest.NewRecorder()
+	newFederation(t, a.URL, b.URL, throttled.URL).SearchHandler(w, httptest.NewRequest(http.MethodGet, "/api/search?limit=2", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, []string{"c: status code 429: failed"}, w.Header()[ErrorHeader])
+
+	actual := &tempopb.SearchResponse{}
+	require.NoError(t, jsonpb.Unmarshal(w.Body, actual))
+	require.Len(t, actual.Traces, 2)
+	assert.Equal(t, "03", actual.Traces[0].TraceID)
+	assert.Equal(t, "02", actual.Traces[1].TraceID)
+
+	// every cluster failed the same way
+	w = httptest.NewRecorder()
+	newFederation(t, throttled.URL, throttled.URL).SearchHandler(w, httptest.NewRequest(http.MethodGet, "/api/search", nil))
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+}
@@ -0,0 +1,165 @@
+package frontend
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/cortexproject/cortex/pkg/chunk/cache"
+	cortex_frontend "github.com/cortexproject/cortex/pkg/querier/frontend"
+	"github.com/go-kit/kit/log"
+	"github.com/gogo/protobuf/proto"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/weaveworks/common/httpgrpc"
+	"github.com/weaveworks/common/user"
+
+	"github.com/grafana/tempo/modules/querier"
+	backend_cache "github.com/grafana/tempo/tempodb/backend/cache"
+)
+
+var (
+	metricResultsCacheRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "tempo",
+		Name:      "query_frontend_results_cache_requests_total",
+		Help:      "The total number of lookup shards and searches checked against the results cache by result.",
+	}, []string{"result"})
+	metricResultsCacheStores = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: "tempo",
+		Name:      "query_frontend_results_cache_stores_total",
+		Help:      "The total number of responses stored in the results cache.",
+	})
+)
+
+// ResultsCacheConfig caches the responses to block shards of trace lookups and to searches.  With memcached the cache
+// is shared by every frontend, so a repeated query is served from the cache whichever frontend receives it.
+type ResultsCacheConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// TTL bounds how stale a cached response can be.  Blocks flushed or compacted after a response was cached aren't
+	// seen until it expires.
+	TTL time.Duration `yaml:"ttl"`
+	// MaxSizeBytes bounds the in memory cache.  A unit suffix (KB, MB, GB) may be applied.
+	MaxSizeBytes string `yaml:"max_size_bytes"`
+	// Memcached shares the cache between frontends instead of keeping it in memory.
+	Memcached *cache.MemcachedClientConfig `yaml:"memcached"`
+	// BestEffort bounds the latency memcached can add to a query.
+	BestEffort backend_cache.BestEffortConfig `yaml:",inline"`
+}
+
+// ResultsCache holds querier responses keyed by tenant and request.
+type ResultsCache struct {
+	cache cache.Cache
+}
+
+// NewResultsCache returns nil if the cache is disabled.
+func NewResultsCache(cfg ResultsCacheConfig, reg prometheus.Registerer, logger log.Logger) (*ResultsCache, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+	if cfg.TTL <= 0 {
+		return nil, fmt.Errorf("frontend results cache ttl must be positive")
+	}
+
+	var c cache.Cache
+	if cfg.Memcached != nil {
+		breaker := backend_cache.NewCircuitBreaker("frontend-results", cfg.BestEffort.CircuitBreakerFailures, cfg.BestEffort.CircuitBreakerDuration, logger)
+		client := backend_cache.NewMemcachedClient(*cfg.Memcached, "frontend-results", breaker, reg, logger)
+		memcached := cache.NewMemcached(cache.MemcachedConfig{Expiration: cfg.TTL}, client, "frontend-results", reg, logger)
+		c = backend_cache.NewBestEffort(memcached, cfg.BestEffort.Timeout, breaker)
+	} else {
+		c = cache.NewFifoCache("frontend-results", cache.FifoCacheConfig{
+			MaxSizeBytes: cfg.MaxSizeBytes,
+			Validity:     cfg.TTL,
+		}, reg, logger)
+	}
+
+	return &ResultsCache{cache: c}, nil
+}
+
+// Stop stops the cache.
+func (c *ResultsCache) Stop() {
+	c.cache.Stop()
+}
+
+// wrap returns a RoundTripper that serves ok and not found responses from the cache and caches those of next.
+// Requests without a tenant and lookup shards of the ingesters, whose responses change with every push, are passed
+// through.  A nil cache passes every request through.
+func (c *ResultsCache) wrap(next http.RoundTripper) http.RoundTripper {
+	if c == nil {
+		return next
+	}
+
+	return cortex_frontend.RoundTripFunc(func(r *http.Request) (*http.Response, error) {
+		userID, err := user.ExtractOrgID(r.Context())
+		if err != nil || r.URL.Query().Get(querier.QueryModeKey) == querier.QueryModeIngesters {
+			return next.RoundTrip(r)
+		}
+
+		key := resultsCacheKey(userID, r)
+		if resp, ok := c.fetch(r.Context(), key); ok {
+			return resp, nil
+		}
+
+		resp, err := next.RoundTrip(r)
+		if err != nil || (resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNotFound) {
+			return resp, err
+		}
+
+		body, err := ioutil.ReadAll(resp.Body)
+		_ = resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+		c.store(r.Context(), key, resp, body)
+		return resp, nil
+	})
+}
+
+// resultsCacheKey hashes the tenant, path and sorted query parameters, which include the block id range of a shard.
+func resultsCacheKey(userID string, r *http.Request) string {
+	return cache.HashKey(fmt.Sprintf("%s:%s?%s", userID, r.URL.Path, r.URL.Query().Encode()))
+}
+
+func (c *ResultsCache) fetch(ctx context.Context, key string) (*http.Response, bool) {
+	found, bufs, _ := c.cache.Fetch(ctx, []string{key})
+	if len(found) == 0 {
+		metricResultsCacheRequests.WithLabelValues("miss").Inc()
+		return nil, false
+	}
+
+	cached := &httpgrpc.HTTPResponse{}
+	if err := proto.Unmarshal(bufs[0], cached); err != nil {
+		metricResultsCacheRequests.WithLabelValues("miss").Inc()
+		return nil, false
+	}
+
+	metricResultsCacheRequests.WithLabelValues("hit").Inc()
+	resp := newResponse(int(cached.Code), cached.Body)
+	for _, h := range cached.Headers {
+		resp.Header[h.Key] = h.Values
+	}
+	return resp, true
+}
+
+func (c *ResultsCache) store(ctx context.Context, key string, resp *http.Response, body []byte) {
+	cached := &httpgrpc.HTTPResponse{
+		Code: int32(resp.StatusCode),
+		Body: body,
+	}
+	for k, v := range resp.Header {
+		cached.Headers = append(cached.Headers, &httpgrpc.Header{Key: k, Values: v})
+	}
+
+	buf, err := proto.Marshal(cached)
+	if err != nil {
+		return
+	}
+
+	c.cache.Store(ctx, []string{key}, [][]byte{buf})
+	metricResultsCacheStores.Inc()
+}
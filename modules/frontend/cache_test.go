@@ -0,0 +1,121 @@
+package frontend
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	cortex_frontend "github.com/cortexproject/cortex/pkg/querier/frontend"
+	"github.com/go-kit/kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/weaveworks/common/user"
+
+	"github.com/grafana/tempo/modules/querier"
+	"github.com/grafana/tempo/pkg/util/test"
+)
+
+func TestResultsCacheSharedBetweenFrontends(t *testing.T) {
+	resultsCache, err := NewResultsCache(ResultsCacheConfig{Enabled: true, TTL: time.Minute, MaxSizeBytes: "1MB"}, prometheus.NewRegistry(), log.NewNopLogger())
+	require.NoError(t, err)
+	defer resultsCache.Stop()
+
+	var (
+		mtx   sync.Mutex
+		calls = map[string]int{}
+	)
+	next := cortex_frontend.RoundTripFunc(func(r *http.Request) (*http.Response, error) {
+		mode := r.URL.Query().Get(querier.QueryModeKey)
+		mtx.Lock()
+		calls[mode]++
+		mtx.Unlock()
+
+		switch {
+		case r.URL.Path == searchPath:
+			resp := newResponse(http.StatusOK, []byte(`{"traces":[]}`))
+			resp.Header.Set("Content-Type", "application/json")
+			return resp, nil
+		case mode == querier.QueryModeBlocks && r.URL.Query().Get(querier.BlockStartKey) == "00000000-0000-0000-0000-000000000000":
+			return traceResponse(t, test.MakeTrace(1, []byte{0x01})), nil
+		default:
+			return newResponse(http.StatusNotFound, nil), nil
+		}
+	})
+
+	// two frontends of an ha pair sharing the cache
+	frontends := make([]http.RoundTripper, 2)
+	for i := range frontends {
+		tripperware, err := NewTripperware(Config{QueryShards: 2}, resultsCache, log.NewNopLogger())
+		require.NoError(t, err)
+		frontends[i] = tripperware(next)
+	}
+
+	for _, frontend := range frontends {
+		resp, err := frontend.RoundTrip(withOrgID(traceRequest(http.MethodGet), "test"))
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+		resp, err = frontend.RoundTrip(withOrgID(httptest.NewRequest(http.MethodGet, "/api/search?tags=service.name%3Dapp&limit=20", nil), "test"))
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, "application/json", resp.Header.Get("Content-Type"))
+		body, err := ioutil.ReadAll(resp.Body)
+		require.NoError(t, err)
+		assert.Equal(t, `{"traces":[]}`, string(body))
+	}
+
+	// the ingesters are asked every time, the block shards and the search only once
+	assert.Equal(t, map[string]int{
+		querier.QueryModeIngesters: 2,
+		querier.QueryModeBlocks:    2,
+		"":                         1,
+	}, calls)
+
+	// other tenants don't share results
+	_, err = frontends[0].RoundTrip(withOrgID(httptest.NewRequest(http.MethodGet, "/api/search?limit=20&tags=service.name%3Dapp", nil), "other"))
+	require.NoError(t, err)
+	assert.Equal(t, 2, calls[""])
+
+	// query parameters are compared regardless of order
+	_, err = frontends[1].RoundTrip(withOrgID(httptest.NewRequest(http.MethodGet, "/api/search?limit=20&tags=service.name%3Dapp", nil), "test"))
+	require.NoError(t, err)
+	assert.Equal(t, 2, calls[""])
+}
+
+func TestResultsCacheSkipsErrors(t *testing.T) {
+	resultsCache, err := NewResultsCache(ResultsCacheConfig{Enabled: true, TTL: time.Minute, MaxSizeBytes: "1MB"}, prometheus.NewRegistry(), log.NewNopLogger())
+	require.NoError(t, err)
+	defer resultsCache.Stop()
+
+	calls := 0
+	next := cortex_frontend.RoundTripFunc(func(r *http.Request) (*http.Response, error) {
+		calls++
+		return newResponse(http.StatusTooManyRequests, nil), nil
+	})
+
+	tripperware, err := NewTripperware(Config{QueryShards: 1}, resultsCache, log.NewNopLogger())
+	require.NoError(t, err)
+	for i := 0; i < 2; i++ {
+		resp, err := tripperware(next).RoundTrip(withOrgID(httptest.NewRequest(http.MethodGet, "/api/search", nil), "test"))
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+	}
+	assert.Equal(t, 2, calls)
+}
+
+func TestNewResultsCache(t *testing.T) {
+	c, err := NewResultsCache(ResultsCacheConfig{}, prometheus.NewRegistry(), log.NewNopLogger())
+	assert.NoError(t, err)
+	assert.Nil(t, c)
+
+	_, err = NewResultsCache(ResultsCacheConfig{Enabled: true}, prometheus.NewRegistry(), log.NewNopLogger())
+	assert.Error(t, err)
+}
+
+func withOrgID(r *http.Request, orgID string) *http.Request {
+	return r.WithContext(user.InjectOrgID(r.Context(), orgID))
+}
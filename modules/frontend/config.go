@@ -0,0 +1,42 @@
+package frontend
+
+import (
+	"flag"
+	"time"
+
+	cortex_frontend "github.com/cortexproject/cortex/pkg/querier/frontend"
+
+	"github.com/grafana/tempo/pkg/util"
+	"github.com/grafana/tempo/tempodb/backend/cache"
+)
+
+// Config for the query frontend.
+type Config struct {
+	cortex_frontend.Config `yaml:",inline"`
+
+	// QueryShards is the number of block id ranges a trace lookup is split into.  The ingesters are searched by a
+	// separate request.
+	QueryShards int `yaml:"query_shards"`
+	// MaxRetries is the number of times a failed lookup shard is retried.
+	MaxRetries int `yaml:"max_retries"`
+
+	ResultsCache ResultsCacheConfig `yaml:"results_cache"`
+}
+
+// RegisterFlagsAndApplyDefaults registers flags and applies defaults
+func (cfg *Config) RegisterFlagsAndApplyDefaults(prefix string, f *flag.FlagSet) {
+	cfg.Config.CompressResponses = false
+	cfg.Config.DownstreamURL = ""
+	cfg.Config.LogQueriesLongerThan = 0
+
+	f.IntVar(&cfg.Config.MaxOutstandingPerTenant, util.PrefixConfig(prefix, "max-outstanding-per-tenant"), 100, "Maximum number of outstanding lookup shards per tenant.  Requests beyond this fail with a 429.")
+	f.IntVar(&cfg.QueryShards, util.PrefixConfig(prefix, "query-shards"), 2, "Number of block id ranges each trace lookup is split into.")
+	f.IntVar(&cfg.MaxRetries, util.PrefixConfig(prefix, "max-retries"), 2, "Number of times a failed lookup shard is retried.")
+
+	f.BoolVar(&cfg.ResultsCache.Enabled, util.PrefixConfig(prefix, "results-cache.enabled"), false, "Cache the responses to block shards of trace lookups and to searches.")
+	f.DurationVar(&cfg.ResultsCache.TTL, util.PrefixConfig(prefix, "results-cache.ttl"), time.Minute, "How long cached responses are kept.  Newly flushed blocks aren't seen by cached queries until they expire.")
+	f.StringVar(&cfg.ResultsCache.MaxSizeBytes, util.PrefixConfig(prefix, "results-cache.max-size-bytes"), "100MB", "Maximum size of the in memory results cache.  A unit suffix (KB, MB, GB) may be applied.")
+	f.DurationVar(&cfg.ResultsCache.BestEffort.Timeout, util.PrefixConfig(prefix, "results-cache.timeout"), cache.DefaultTimeout, "Lookups in memcached that take longer than this are treated as misses.")
+	f.IntVar(&cfg.ResultsCache.BestEffort.CircuitBreakerFailures, util.PrefixConfig(prefix, "results-cache.circuit-breaker-failures"), cache.DefaultCircuitBreakerFailures, "Failed memcached requests in a row after which memcached isn't used for the circuit breaker duration.")
+	f.DurationVar(&cfg.ResultsCache.BestEffort.CircuitBreakerDuration, util.PrefixConfig(prefix, "results-cache.circuit-breaker-duration"), cache.DefaultCircuitBreakerDuration, "How long memcached isn't used after repeated failures.")
+}
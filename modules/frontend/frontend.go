@@ -0,0 +1,286 @@
+package frontend
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+
+	cortex_frontend "github.com/cortexproject/cortex/pkg/querier/frontend"
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/weaveworks/common/httpgrpc"
+
+	"github.com/grafana/tempo/modules/querier"
+	"github.com/grafana/tempo/pkg/tempopb"
+	"github.com/grafana/tempo/pkg/util"
+)
+
+const (
+	maxQueryShards = 256
+
+	searchPath = "/api/search"
+)
+
+var (
+	metricShardRetries = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: "tempo",
+		Name:      "query_frontend_shard_retries_total",
+		Help:      "The total number of trace lookup shards retried after failing.",
+	})
+	metricShardsFound = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: "tempo",
+		Name:      "query_frontend_shards_found",
+		Help:      "The number of shards of each trace lookup that found part of the trace.",
+		Buckets:   prometheus.LinearBuckets(0, 1, 5),
+	})
+)
+
+// shard is the part of a trace lookup a single querier performs.  Block shards only search blocks whose ids fall
+// between start and end.
+type shard struct {
+	mode  string
+	start uuid.UUID
+	end   uuid.UUID
+}
+
+// NewTripperware returns a Tripperware that splits each trace lookup into one request to the ingesters and
+// cfg.QueryShards requests that each search a range of block ids.  Failed shards are retried and the partial traces
+// combined.  Block shards and searches are served from the results cache if it isn't nil.  Other requests are passed
+// through unchanged.
+func NewTripperware(cfg Config, resultsCache *ResultsCache, logger log.Logger) (cortex_frontend.Tripperware, error) {
+	if cfg.QueryShards < 1 || cfg.QueryShards > maxQueryShards {
+		return nil, fmt.Errorf("frontend query shards must be between 1 and %d", maxQueryShards)
+	}
+	if cfg.MaxRetries < 0 {
+		return nil, errors.New("frontend max retries must not be negative")
+	}
+
+	shards := createShards(cfg.QueryShards)
+
+	return func(next http.RoundTripper) http.RoundTripper {
+		shardNext := resultsCache.wrap(newRetryWare(next, cfg.MaxRetries, logger))
+		searchNext := resultsCache.wrap(next)
+
+		return cortex_frontend.RoundTripFunc(func(r *http.Request) (*http.Response, error) {
+			if r.Method != http.MethodGet {
+				return next.RoundTrip(r)
+			}
+			if r.URL.Path == searchPath {
+				return searchNext.RoundTrip(r)
+			}
+			// a summary can't be combined from shards, so a single querier looks the trace up
+			if strings.HasSuffix(r.URL.Path, querier.TraceSummarySuffix) {
+				return next.RoundTrip(r)
+			}
+
+			traceID, ok := mux.Vars(r)[querier.TraceIDVar]
+			if !ok {
+				return next.RoundTrip(r)
+			}
+
+			return lookupShards(r, traceID, shards, shardNext)
+		})
+	}, nil
+}
+
+// createShards splits the block id space into n equal ranges by the first 4 bytes of the id.
+func createShards(n int) []shard {
+	shards := make([]shard, 0, n+1)
+	shards = append(shards, shard{mode: querier.QueryModeIngesters})
+
+	for i := 0; i < n; i++ {
+		var start, end uuid.UUID
+		binary.BigEndian.PutUint32(start[:4], uint32(uint64(i)<<32/uint64(n)))
+
+		for j := range end {
+			end[j] = 0xff
+		}
+		if i < n-1 {
+			binary.BigEndian.PutUint32(end[:4], uint32(uint64(i+1)<<32/uint64(n))-1)
+		}
+
+		shards = append(shards, shard{
+			mode:  querier.QueryModeBlocks,
+			start: start,
+			end:   end,
+		})
+	}
+
+	return shards
+}
+
+func lookupShards(r *http.Request, traceID string, shards []shard, next http.RoundTripper) (*http.Response, error) {
+	type result struct {
+		resp *http.Response
+		err  error
+	}
+
+	results := make(chan result, len(shards))
+	for _, s := range shards {
+		go func(req *http.Request) {
+			resp, err := next.RoundTrip(req)
+			results <- result{resp, err}
+		}(shardRequest(r, s))
+	}
+
+	var (
+		combiner       = &util.TraceCombiner{}
+		combinedFrom   int
+		incomplete     bool
+		errResp        *http.Response
+		err            error
+		found          int
+		externalLabels string
+	)
+	for range shards {
+		res := <-results
+		if res.err != nil {
+			err = res.err
+			continue
+		}
+
+		resp := res.resp
+		if resp.StatusCode == http.StatusNotFound || errResp != nil || err != nil {
+			drain(resp)
+			continue
+		}
+		if resp.StatusCode != http.StatusOK {
+			errResp = resp
+			continue
+		}
+
+		trace := &tempopb.Trace{}
+		unmarshalErr := jsonpb.Unmarshal(resp.Body, trace)
+		drain(resp)
+		if unmarshalErr != nil {
+			err = unmarshalErr
+			continue
+		}
+
+		combiner.Combine(trace)
+		combinedFrom += shardCombinedFrom(resp)
+		incomplete = incomplete || resp.Header.Get(querier.IncompleteHeader) == "true"
+		externalLabels = resp.Header.Get(querier.ExternalLabelsHeader)
+		found++
+	}
+
+	if err != nil {
+		if errResp != nil {
+			drain(errResp)
+		}
+		return nil, err
+	}
+	if errResp != nil {
+		return errResp, nil
+	}
+
+	metricShardsFound.Observe(float64(found))
+	combined := combiner.Result()
+	if combined == nil {
+		return newResponse(http.StatusNotFound, []byte(fmt.Sprintf("Unable to find %s\n", traceID))), nil
+	}
+
+	buffer := &bytes.Buffer{}
+	marshaller := &jsonpb.Marshaler{}
+	err = marshaller.Marshal(buffer, combined)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := newResponse(http.StatusOK, buffer.Bytes())
+	if externalLabels != "" {
+		resp.Header.Set(querier.ExternalLabelsHeader, externalLabels)
+	}
+	resp.Header.Set(querier.CombinedFromHeader, strconv.Itoa(combinedFrom))
+	if incomplete {
+		resp.Header.Set(querier.IncompleteHeader, "true")
+	}
+	return resp, nil
+}
+
+// shardCombinedFrom returns the number of partial traces a shard's querier combined.  Queriers that don't report it
+// count as one.
+func shardCombinedFrom(resp *http.Response) int {
+	n, err := strconv.Atoi(resp.Header.Get(querier.CombinedFromHeader))
+	if err != nil || n < 1 {
+		return 1
+	}
+	return n
+}
+
+// shardRequest copies r and adds the parameters that restrict the querier to the shard.
+func shardRequest(r *http.Request, s shard) *http.Request {
+	req := r.Clone(r.Context())
+
+	q := req.URL.Query()
+	q.Set(querier.QueryModeKey, s.mode)
+	if s.mode == querier.QueryModeBlocks {
+		q.Set(querier.BlockStartKey, s.start.String())
+		q.Set(querier.BlockEndKey, s.end.String())
+	}
+	req.URL.RawQuery = q.Encode()
+	req.RequestURI = req.URL.RequestURI()
+	req.Body = http.NoBody
+	// shard responses are combined here, the response to the client is compressed after
+	req.Header.Del("Accept-Encoding")
+
+	return req
+}
+
+// newRetryWare retries requests that fail or respond with a 5xx up to maxRetries times.
+func newRetryWare(next http.RoundTripper, maxRetries int, logger log.Logger) http.RoundTripper {
+	return cortex_frontend.RoundTripFunc(func(r *http.Request) (*http.Response, error) {
+		var (
+			resp *http.Response
+			err  error
+		)
+		for attempt := 0; ; attempt++ {
+			resp, err = next.RoundTrip(r)
+			if !retryable(resp, err) || attempt >= maxRetries || r.Context().Err() != nil {
+				return resp, err
+			}
+
+			level.Warn(logger).Log("msg", "retrying trace lookup shard", "url", r.URL.String(), "attempt", attempt+1, "err", err)
+			metricShardRetries.Inc()
+			if resp != nil {
+				drain(resp)
+			}
+		}
+	})
+}
+
+func retryable(resp *http.Response, err error) bool {
+	if err != nil {
+		// errors from the frontend such as a full queue carry an http status
+		if errResp, ok := httpgrpc.HTTPResponseFromError(err); ok {
+			return errResp.Code/100 == 5
+		}
+		return true
+	}
+
+	return resp.StatusCode/100 == 5
+}
+
+func newResponse(code int, body []byte) *http.Response {
+	return &http.Response{
+		StatusCode: code,
+		Header:     http.Header{},
+		Body:       ioutil.NopCloser(bytes.NewReader(body)),
+	}
+}
+
+func drain(resp *http.Response) {
+	_, _ = io.Copy(ioutil.Discard, resp.Body)
+	_ = resp.Body.Close()
+}
@@ -0,0 +1,314 @@
+package frontend
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	cortex_frontend "github.com/cortexproject/cortex/pkg/querier/frontend"
+	"github.com/go-kit/kit/log"
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	v1_common "github.com/open-telemetry/opentelemetry-proto/gen/go/common/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/tempo/modules/querier"
+	"github.com/grafana/tempo/pkg/tempopb"
+	"github.com/grafana/tempo/pkg/util/test"
+)
+
+func TestCreateShards(t *testing.T) {
+	shards := createShards(3)
+	require.Len(t, shards, 4)
+
+	assert.Equal(t, querier.QueryModeIngesters, shards[0].mode)
+	assert.Equal(t, uuid.MustParse("00000000-0000-0000-0000-000000000000"), shards[1].start)
+	assert.Equal(t, uuid.MustParse("55555554-ffff-ffff-ffff-ffffffffffff"), shards[1].end)
+	assert.Equal(t, uuid.MustParse("55555555-0000-0000-0000-000000000000"), shards[2].start)
+	assert.Equal(t, uuid.MustParse("aaaaaaa9-ffff-ffff-ffff-ffffffffffff"), shards[2].end)
+	assert.Equal(t, uuid.MustParse("aaaaaaaa-0000-0000-0000-000000000000"), shards[3].start)
+	assert.Equal(t, uuid.MustParse("ffffffff-ffff-ffff-ffff-ffffffffffff"), shards[3].end)
+
+	for _, s := range shards[1:] {
+		assert.Equal(t, querier.QueryModeBlocks, s.mode)
+	}
+}
+
+func TestNewTripperwareValidates(t *testing.T) {
+	_, err := NewTripperware(Config{QueryShards: 0}, nil, log.NewNopLogger())
+	assert.Error(t, err)
+
+	_, err = NewTripperware(Config{QueryShards: 1, MaxRetries: -1}, nil, log.NewNopLogger())
+	assert.Error(t, err)
+}
+
+func TestTraceLookupIsSharded(t *testing.T) {
+	ingesterTrace := test.MakeTrace(1, []byte{0x01})
+	blockTrace := test.MakeTrace(1, []byte{0x01})
+
+	var (
+		mtx  sync.Mutex
+		seen []string
+	)
+	next := cortex_frontend.RoundTripFunc(func(r *http.Request) (*http.Response, error) {
+		mtx.Lock()
+		seen = append(seen, r.URL.Query().Get(querier.QueryModeKey))
+		mtx.Unlock()
+
+		switch {
+		case r.URL.Query().Get(querier.QueryModeKey) == querier.QueryModeIngesters:
+			return traceResponse(t, ingesterTrace), nil
+		case r.URL.Query().Get(querier.BlockStartKey) == "00000000-0000-0000-0000-000000000000":
+			return traceResponse(t, blockTrace), nil
+		default:
+			return newResponse(http.StatusNotFound, nil), nil
+		}
+	})
+
+	resp := roundTrip(t, Config{QueryShards: 2}, next, http.MethodGet)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.ElementsMatch(t, []string{querier.QueryModeIngesters, querier.QueryModeBlocks, querier.QueryModeBlocks}, seen)
+
+	actual := &tempopb.Trace{}
+	require.NoError(t, jsonpb.Unmarshal(resp.Body, actual))
+	assert.Len(t, actual.Batches, len(ingesterTrace.Batches)+len(blockTrace.Batches))
+}
+
+func TestTraceLookupPreservesAttributeTypes(t *testing.T) {
+	trace := test.MakeTrace(1, []byte{0x01})
+	span := trace.Batches[0].InstrumentationLibrarySpans[0].Spans[0]
+	span.Attributes = []*v1_common.KeyValue{
+		{Key: "int", Value: &v1_common.AnyValue{Value: &v1_common.AnyValue_IntValue{IntValue: 1<<53 + 1}}},
+		{Key: "double", Value: &v1_common.AnyValue{Value: &v1_common.AnyValue_DoubleValue{DoubleValue: 1.5}}},
+		{Key: "bool", Value: &v1_common.AnyValue{Value: &v1_common.AnyValue_BoolValue{BoolValue: true}}},
+		{Key: "array", Value: &v1_common.AnyValue{Value: &v1_common.AnyValue_ArrayValue{ArrayValue: &v1_common.ArrayValue{
+			Values: []*v1_common.AnyValue{{Value: &v1_common.AnyValue_IntValue{IntValue: 2}}},
+		}}}},
+	}
+
+	next := cortex_frontend.RoundTripFunc(func(r *http.Request) (*http.Response, error) {
+		if r.URL.Query().Get(querier.QueryModeKey) == querier.QueryModeIngesters {
+			return traceResponse(t, trace), nil
+		}
+		return newResponse(http.StatusNotFound, nil), nil
+	})
+
+	resp := roundTrip(t, Config{QueryShards: 2}, next, http.MethodGet)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	actual := &tempopb.Trace{}
+	require.NoError(t, jsonpb.Unmarshal(resp.Body, actual))
+	assert.Equal(t, span.Attributes, actual.Batches[0].InstrumentationLibrarySpans[0].Spans[0].Attributes)
+}
+
+func TestTraceLookupKeepsExternalLabels(t *testing.T) {
+	next := cortex_frontend.RoundTripFunc(func(r *http.Request) (*http.Response, error) {
+		if r.URL.Query().Get(querier.QueryModeKey) == querier.QueryModeIngesters {
+			resp := traceResponse(t, test.MakeTrace(1, []byte{0x01}))
+			resp.Header.Set(querier.ExternalLabelsHeader, "cluster=eu-1")
+			return resp, nil
+		}
+		return newResponse(http.StatusNotFound, nil), nil
+	})
+
+	resp := roundTrip(t, Config{QueryShards: 2}, next, http.MethodGet)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "cluster=eu-1", resp.Header.Get(querier.ExternalLabelsHeader))
+}
+
+func TestTraceLookupIncomplete(t *testing.T) {
+	next := cortex_frontend.RoundTripFunc(func(r *http.Request) (*http.Response, error) {
+		resp := traceResponse(t, test.MakeTrace(1, []byte{0x01}))
+		if r.URL.Query().Get(querier.QueryModeKey) == querier.QueryModeIngesters {
+			resp.Header.Set(querier.IncompleteHeader, "true")
+		}
+		return resp, nil
+	})
+
+	resp := roundTrip(t, Config{QueryShards: 2}, next, http.MethodGet)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "true", resp.Header.Get(querier.IncompleteHeader))
+}
+
+func TestTraceLookupDeduplicatesSpans(t *testing.T) {
+	trace := test.MakeTrace(2, []byte{0x01})
+	next := cortex_frontend.RoundTripFunc(func(r *http.Request) (*http.Response, error) {
+		switch {
+		case r.URL.Query().Get(querier.QueryModeKey) == querier.QueryModeIngesters:
+			resp := traceResponse(t, trace)
+			resp.Header.Set(querier.CombinedFromHeader, "3")
+			return resp, nil
+		case r.URL.Query().Get(querier.BlockStartKey) == "00000000-0000-0000-0000-000000000000":
+			// a querier that doesn't report how many partial traces it combined
+			return traceResponse(t, trace), nil
+		default:
+			return newResponse(http.StatusNotFound, nil), nil
+		}
+	})
+
+	resp := roundTrip(t, Config{QueryShards: 2}, next, http.MethodGet)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "4", resp.Header.Get(querier.CombinedFromHeader))
+
+	actual := &tempopb.Trace{}
+	require.NoError(t, jsonpb.Unmarshal(resp.Body, actual))
+	assert.Len(t, actual.Batches, len(trace.Batches))
+}
+
+func TestTraceSummaryIsNotSharded(t *testing.T) {
+	var queries []string
+	next := cortex_frontend.RoundTripFunc(func(r *http.Request) (*http.Response, error) {
+		queries = append(queries, r.URL.RawQuery)
+		return newResponse(http.StatusOK, []byte(`{"traceID":"0102","spanCount":1}`)), nil
+	})
+
+	tripperware, err := NewTripperware(Config{QueryShards: 2}, nil, log.NewNopLogger())
+	require.NoError(t, err)
+
+	r := httptest.NewRequest(http.MethodGet, "/api/traces/0102/summary", nil)
+	r = mux.SetURLVars(r, map[string]string{querier.TraceIDVar: "0102"})
+	resp, err := tripperware(next).RoundTrip(r)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, []string{""}, queries)
+}
+
+func TestTraceLookupNotFound(t *testing.T) {
+	next := cortex_frontend.RoundTripFunc(func(r *http.Request) (*http.Response, error) {
+		return newResponse(http.StatusNotFound, nil), nil
+	})
+
+	resp := roundTrip(t, Config{QueryShards: 2}, next, http.MethodGet)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
+
+func TestTraceLookupRetries(t *testing.T) {
+	tests := []struct {
+		name         string
+		failures     int
+		maxRetries   int
+		expectedCode int
+	}{
+		{
+			name:         "succeeds after retry",
+			failures:     2,
+			maxRetries:   2,
+			expectedCode: http.StatusNotFound,
+		},
+		{
+			name:         "out of retries",
+			failures:     3,
+			maxRetries:   2,
+			expectedCode: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var (
+				mtx      sync.Mutex
+				attempts int
+			)
+			next := cortex_frontend.RoundTripFunc(func(r *http.Request) (*http.Response, error) {
+				// only the ingester shard fails
+				if r.URL.Query().Get(querier.QueryModeKey) != querier.QueryModeIngesters {
+					return newResponse(http.StatusNotFound, nil), nil
+				}
+
+				mtx.Lock()
+				defer mtx.Unlock()
+				attempts++
+				if attempts <= tt.failures {
+					return newResponse(http.StatusInternalServerError, []byte("failed")), nil
+				}
+				return newResponse(http.StatusNotFound, nil), nil
+			})
+
+			resp := roundTrip(t, Config{QueryShards: 1, MaxRetries: tt.maxRetries}, next, http.MethodGet)
+			assert.Equal(t, tt.expectedCode, resp.StatusCode)
+		})
+	}
+}
+
+func TestTraceLookupError(t *testing.T) {
+	next := cortex_frontend.RoundTripFunc(func(r *http.Request) (*http.Response, error) {
+		if r.URL.Query().Get(querier.QueryModeKey) == querier.QueryModeIngesters {
+			return newResponse(http.StatusTooManyRequests, []byte("quota exceeded")), nil
+		}
+		return newResponse(http.StatusNotFound, nil), nil
+	})
+
+	resp := roundTrip(t, Config{QueryShards: 2, MaxRetries: 3}, next, http.MethodGet)
+	assert.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+
+	next = cortex_frontend.RoundTripFunc(func(r *http.Request) (*http.Response, error) {
+		return nil, errors.New("failed")
+	})
+
+	tripperware, err := NewTripperware(Config{QueryShards: 2}, nil, log.NewNopLogger())
+	require.NoError(t, err)
+	_, err = tripperware(next).RoundTrip(traceRequest(http.MethodGet))
+	assert.Error(t, err)
+}
+
+func TestTraceLookupShardsAreUncompressed(t *testing.T) {
+	next := cortex_frontend.RoundTripFunc(func(r *http.Request) (*http.Response, error) {
+		assert.Empty(t, r.Header.Get("Accept-Encoding"))
+		return traceResponse(t, test.MakeTrace(1, []byte{0x01})), nil
+	})
+
+	tripperware, err := NewTripperware(Config{QueryShards: 2}, nil, log.NewNopLogger())
+	require.NoError(t, err)
+
+	req := traceRequest(http.MethodGet)
+	req.Header.Set("Accept-Encoding", "gzip")
+	resp, err := tripperware(next).RoundTrip(req)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "gzip", req.Header.Get("Accept-Encoding"))
+}
+
+func TestOtherRequestsPassThrough(t *testing.T) {
+	calls := 0
+	next := cortex_frontend.RoundTripFunc(func(r *http.Request) (*http.Response, error) {
+		calls++
+		assert.Empty(t, r.URL.Query().Get(querier.QueryModeKey))
+		return newResponse(http.StatusOK, nil), nil
+	})
+
+	resp := roundTrip(t, Config{QueryShards: 2}, next, http.MethodHead)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, 1, calls)
+}
+
+func roundTrip(t *testing.T, cfg Config, next http.RoundTripper, method string) *http.Response {
+	tripperware, err := NewTripperware(cfg, nil, log.NewNopLogger())
+	require.NoError(t, err)
+
+	resp, err := tripperware(next).RoundTrip(traceRequest(method))
+	require.NoError(t, err)
+
+	return resp
+}
+
+func traceRequest(method string) *http.Request {
+	r := httptest.NewRequest(method, "/api/traces/0102", nil)
+	return mux.SetURLVars(r, map[string]string{querier.TraceIDVar: "0102"})
+}
+
+func traceResponse(t *testing.T, trace *tempopb.Trace) *http.Response {
+	buffer := &bytes.Buffer{}
+	require.NoError(t, (&jsonpb.Marshaler{}).Marshal(buffer, trace))
+
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{},
+		Body:       ioutil.NopCloser(buffer),
+	}
+}
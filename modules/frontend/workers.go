@@ -0,0 +1,85 @@
+package frontend
+
+import (
+	"net"
+	"sync"
+
+	cortex_frontend "github.com/cortexproject/cortex/pkg/querier/frontend"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"google.golang.org/grpc/peer"
+)
+
+var (
+	metricConnectedWorkers = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: "tempo",
+		Name:      "query_frontend_connected_workers",
+		Help:      "The number of querier workers pulling requests from the frontend, which is the number of requests the connected queriers process at once.",
+	})
+	metricConnectedQueriers = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: "tempo",
+		Name:      "query_frontend_connected_queriers",
+		Help:      "The number of addresses querier workers connect to the frontend from. Queriers behind NAT share an address.",
+	})
+)
+
+// WorkerServer reports the querier workers connected to a frontend.  Queriers dial out to the frontend and open a
+// stream per request they process at once, so together with the queue length the streams tell whether the queriers
+// keep up.
+type WorkerServer struct {
+	cortex_frontend.FrontendServer
+
+	mtx      sync.Mutex
+	queriers map[string]int // open streams by querier host
+}
+
+// NewWorkerServer wraps the frontend server s.
+func NewWorkerServer(s cortex_frontend.FrontendServer) *WorkerServer {
+	return &WorkerServer{
+		FrontendServer: s,
+		queriers:       map[string]int{},
+	}
+}
+
+// Process implements cortex_frontend.FrontendServer.
+func (s *WorkerServer) Process(server cortex_frontend.Frontend_ProcessServer) error {
+	host := querierHost(server)
+	s.connect(host)
+	defer s.disconnect(host)
+
+	return s.FrontendServer.Process(server)
+}
+
+func (s *WorkerServer) connect(host string) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	s.queriers[host]++
+	metricConnectedWorkers.Inc()
+	metricConnectedQueriers.Set(float64(len(s.queriers)))
+}
+
+func (s *WorkerServer) disconnect(host string) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	s.queriers[host]--
+	if s.queriers[host] <= 0 {
+		delete(s.queriers, host)
+	}
+	metricConnectedWorkers.Dec()
+	metricConnectedQueriers.Set(float64(len(s.queriers)))
+}
+
+// querierHost returns the host of the querier of the stream, without the port which differs per stream.
+func querierHost(server cortex_frontend.Frontend_ProcessServer) string {
+	p, ok := peer.FromContext(server.Context())
+	if !ok || p.Addr == nil {
+		return ""
+	}
+	host, _, err := net.SplitHostPort(p.Addr.String())
+	if err != nil {
+		return p.Addr.String()
+	}
+	return host
+}
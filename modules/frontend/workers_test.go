@@ -0,0 +1,62 @@
+package frontend
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	cortex_frontend "github.com/cortexproject/cortex/pkg/querier/frontend"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/peer"
+)
+
+type blockingFrontendServer struct {
+	started chan struct{}
+	release chan struct{}
+}
+
+func (s *blockingFrontendServer) Process(cortex_frontend.Frontend_ProcessServer) error {
+	s.started <- struct{}{}
+	<-s.release
+	return nil
+}
+
+type mockProcessServer struct {
+	cortex_frontend.Frontend_ProcessServer
+	ctx context.Context
+}
+
+func (m *mockProcessServer) Context() context.Context {
+	return m.ctx
+}
+
+func TestWorkerServer(t *testing.T) {
+	inner := &blockingFrontendServer{
+		started: make(chan struct{}),
+		release: make(chan struct{}),
+	}
+	s := NewWorkerServer(inner)
+
+	streams := []string{"10.0.0.1:1000", "10.0.0.1:1001", "10.0.0.2:1000"}
+	errs := make(chan error)
+	for _, addr := range streams {
+		tcpAddr, err := net.ResolveTCPAddr("tcp", addr)
+		require.NoError(t, err)
+		server := &mockProcessServer{ctx: peer.NewContext(context.Background(), &peer.Peer{Addr: tcpAddr})}
+		go func() {
+			errs <- s.Process(server)
+		}()
+		<-inner.started
+	}
+
+	s.mtx.Lock()
+	assert.Equal(t, map[string]int{"10.0.0.1": 2, "10.0.0.2": 1}, s.queriers)
+	s.mtx.Unlock()
+
+	for range streams {
+		inner.release <- struct{}{}
+		require.NoError(t, <-errs)
+	}
+	assert.Empty(t, s.queriers)
+}
@@ -0,0 +1,79 @@
+package generator
+
+import (
+	"flag"
+	"time"
+
+	cortex_compactor "github.com/cortexproject/cortex/pkg/compactor"
+	"github.com/cortexproject/cortex/pkg/util/flagext"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/grafana/tempo/pkg/util"
+)
+
+// RingKey is the key under which metrics generators are registered.
+const RingKey = "metrics-generator"
+
+// Processors a tenant enables with the metrics_generator_processors override.
+const (
+	ProcessorSpanMetrics   = "span-metrics"
+	ProcessorServiceGraphs = "service-graphs"
+)
+
+// Config for a metrics generator.
+type Config struct {
+	Ring            cortex_compactor.RingConfig `yaml:"ring,omitempty"`
+	OverrideRingKey string                      `yaml:"override_ring_key"`
+
+	// CollectionInterval is how often the metrics of every tenant are remote written.
+	CollectionInterval time.Duration `yaml:"collection_interval"`
+	// StaleDuration is how long a series is written after it was last updated.
+	StaleDuration time.Duration `yaml:"stale_duration"`
+	// ExternalLabels are added to every series written.
+	ExternalLabels map[string]string `yaml:"external_labels"`
+
+	SpanMetrics   SpanMetricsConfig   `yaml:"span_metrics"`
+	ServiceGraphs ServiceGraphsConfig `yaml:"service_graphs"`
+	RemoteWrite   RemoteWriteConfig   `yaml:"remote_write"`
+}
+
+// SpanMetricsConfig configures the request, error and duration metrics of each service and span name.
+type SpanMetricsConfig struct {
+	HistogramBuckets []float64 `yaml:"histogram_buckets"` // upper bounds of the duration buckets in seconds
+}
+
+// ServiceGraphsConfig configures the edges between services, found by pairing client spans with the server spans
+// that are their children.
+type ServiceGraphsConfig struct {
+	Wait             time.Duration `yaml:"wait"`              // how long a span waits for the other side of its edge
+	MaxItems         int           `yaml:"max_items"`         // edges waiting per tenant.  New edges are dropped once full
+	HistogramBuckets []float64     `yaml:"histogram_buckets"` // upper bounds of the duration buckets in seconds
+}
+
+// RemoteWriteConfig is the Prometheus remote write endpoint metrics are sent to.  The tenant is sent in the
+// X-Scope-OrgID header.
+type RemoteWriteConfig struct {
+	URL     string            `yaml:"url"`
+	Timeout time.Duration     `yaml:"timeout"`
+	Headers map[string]string `yaml:"headers"`
+}
+
+// RegisterFlagsAndApplyDefaults registers the flags.
+func (cfg *Config) RegisterFlagsAndApplyDefaults(prefix string, f *flag.FlagSet) {
+	flagext.DefaultValues(&cfg.Ring)
+	cfg.Ring.KVStore.Store = "memberlist"
+	f.DurationVar(&cfg.Ring.HeartbeatPeriod, util.PrefixConfig(prefix, "ring.heartbeat-period"), cfg.Ring.HeartbeatPeriod, "Period at which to heartbeat to the ring.")
+	f.DurationVar(&cfg.Ring.HeartbeatTimeout, util.PrefixConfig(prefix, "ring.heartbeat-timeout"), cfg.Ring.HeartbeatTimeout, "The heartbeat timeout after which metrics generators are considered unhealthy within the ring.")
+	cfg.OverrideRingKey = RingKey
+
+	f.DurationVar(&cfg.CollectionInterval, util.PrefixConfig(prefix, "collection-interval"), 15*time.Second, "How often metrics are remote written.")
+	f.DurationVar(&cfg.StaleDuration, util.PrefixConfig(prefix, "stale-duration"), 15*time.Minute, "How long a series keeps being written after it was last updated.")
+
+	cfg.SpanMetrics.HistogramBuckets = prometheus.ExponentialBuckets(0.002, 2, 14)
+	f.DurationVar(&cfg.ServiceGraphs.Wait, util.PrefixConfig(prefix, "service-graphs.wait"), 10*time.Second, "How long a span waits for the other side of its service graph edge.")
+	f.IntVar(&cfg.ServiceGraphs.MaxItems, util.PrefixConfig(prefix, "service-graphs.max-items"), 10000, "Service graph edges waiting for their other side per tenant.")
+	cfg.ServiceGraphs.HistogramBuckets = prometheus.ExponentialBuckets(0.1, 2, 8)
+
+	f.StringVar(&cfg.RemoteWrite.URL, util.PrefixConfig(prefix, "remote-write.url"), "", "URL of the Prometheus remote write endpoint metrics are sent to.")
+	f.DurationVar(&cfg.RemoteWrite.Timeout, util.PrefixConfig(prefix, "remote-write.timeout"), 30*time.Second, "Timeout of each remote write request.")
+}
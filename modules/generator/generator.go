@@ -0,0 +1,233 @@
+package generator
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/cortexproject/cortex/pkg/ring"
+	"github.com/cortexproject/cortex/pkg/util"
+	"github.com/cortexproject/cortex/pkg/util/services"
+	"github.com/go-kit/kit/log/level"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/prometheus/prompb"
+	"github.com/weaveworks/common/user"
+
+	"github.com/grafana/tempo/modules/overrides"
+	"github.com/grafana/tempo/pkg/tempopb"
+	"github.com/grafana/tempo/pkg/tenantmetrics"
+)
+
+// instanceLabel is added to every series so series of the same tenant written by different generators don't collide.
+const instanceLabel = "metrics_generator_instance"
+
+var (
+	metricSpansReceived = tenantmetrics.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "tempo",
+		Name:      "metrics_generator_spans_received_total",
+		Help:      "The total number of spans received per tenant.",
+	}, []string{"tenant"})
+	metricSamplesWritten = tenantmetrics.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "tempo",
+		Name:      "metrics_generator_samples_written_total",
+		Help:      "The total number of samples remote written per tenant.",
+	}, []string{"tenant"})
+	metricRemoteWriteFailures = tenantmetrics.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "tempo",
+		Name:      "metrics_generator_remote_write_failures_total",
+		Help:      "The total number of failed remote writes per tenant.",
+	}, []string{"tenant"})
+	metricActiveSeries = tenantmetrics.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "tempo",
+		Name:      "metrics_generator_active_series",
+		Help:      "The number of series written at the last collection per tenant.",
+	}, []string{"tenant"})
+)
+
+// Generator derives request, error and duration metrics and service graphs from the spans the distributors send it
+// and remote writes them to Prometheus.  Generators register in a ring so the distributors send every span of a
+// trace to the same generator.
+type Generator struct {
+	services.Service
+
+	cfg         *Config
+	overrides   *overrides.Overrides
+	remoteWrite *remoteWriteClient
+
+	instancesMtx sync.RWMutex
+	instances    map[string]*instance
+
+	ringLifecycler *ring.Lifecycler
+	Ring           *ring.Ring
+
+	subservices        *services.Manager
+	subservicesWatcher *services.FailureWatcher
+}
+
+// New makes a new Generator.
+func New(cfg Config, o *overrides.Overrides) (*Generator, error) {
+	if cfg.RemoteWrite.URL == "" {
+		return nil, fmt.Errorf("metrics generator remote write url is required")
+	}
+	if cfg.CollectionInterval <= 0 {
+		return nil, fmt.Errorf("metrics generator collection interval must be positive")
+	}
+
+	g := &Generator{
+		cfg:         &cfg,
+		overrides:   o,
+		remoteWrite: newRemoteWriteClient(cfg.RemoteWrite),
+		instances:   map[string]*instance{},
+	}
+
+	lifecyclerCfg := g.cfg.Ring.ToLifecyclerConfig()
+	lifecycler, err := ring.NewLifecycler(lifecyclerCfg, ring.NewNoopFlushTransferer(), "metrics-generator", cfg.OverrideRingKey, false, prometheus.DefaultRegisterer)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to initialize metrics generator ring lifecycler")
+	}
+	g.ringLifecycler = lifecycler
+
+	g.Ring, err = ring.New(lifecyclerCfg.RingConfig, "metrics-generator", cfg.OverrideRingKey, prometheus.DefaultRegisterer)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to initialize metrics generator ring")
+	}
+
+	g.subservices, err = services.NewManager(g.ringLifecycler, g.Ring)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create subservices %w", err)
+	}
+	g.subservicesWatcher = services.NewFailureWatcher()
+	g.subservicesWatcher.WatchManager(g.subservices)
+
+	g.Service = services.NewBasicService(g.starting, g.running, g.stopping)
+
+	return g, nil
+}
+
+func (g *Generator) starting(ctx context.Context) error {
+	err := services.StartManagerAndAwaitHealthy(ctx, g.subservices)
+	if err != nil {
+		return fmt.Errorf("failed to start subservices %w", err)
+	}
+
+	return nil
+}
+
+func (g *Generator) running(ctx context.Context) error {
+	collectTicker := time.NewTicker(g.cfg.CollectionInterval)
+	defer collectTicker.Stop()
+
+	for {
+		select {
+		case <-collectTicker.C:
+			g.collect(ctx)
+
+		case <-ctx.Done():
+			return nil
+
+		case err := <-g.subservicesWatcher.Chan():
+			return fmt.Errorf("metrics generator subservices failed %w", err)
+		}
+	}
+}
+
+// Called after metrics generator is asked to stop via StopAsync.  The generator leaves the ring before the metrics
+// are written a last time.
+func (g *Generator) stopping(_ error) error {
+	err := services.StopManagerAndAwaitStopped(context.Background(), g.subservices)
+
+	ctx, cancel := context.WithTimeout(context.Background(), g.cfg.RemoteWrite.Timeout)
+	defer cancel()
+	g.collect(ctx)
+
+	return err
+}
+
+// PushSpans implements tempopb.MetricsGenerator.  Spans of tenants without processors are dropped.
+func (g *Generator) PushSpans(ctx context.Context, req *tempopb.PushRequest) (*tempopb.PushResponse, error) {
+	userID, err := user.ExtractOrgID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	processors := g.overrides.MetricsGeneratorProcessors(userID)
+	if req.Batch == nil || len(processors) == 0 {
+		return &tempopb.PushResponse{}, nil
+	}
+
+	spanCount := 0
+	for _, ils := range req.Batch.InstrumentationLibrarySpans {
+		spanCount += len(ils.Spans)
+	}
+	metricSpansReceived.WithLabelValues(userID).Add(float64(spanCount))
+
+	g.push(userID, req, processors, time.Now())
+
+	return &tempopb.PushResponse{}, nil
+}
+
+func (g *Generator) push(userID string, req *tempopb.PushRequest, processors []string, now time.Time) {
+	// the read lock is held while pushing so an idle instance isn't removed during a push
+	g.instancesMtx.RLock()
+	inst, ok := g.instances[userID]
+	if ok {
+		inst.push(req.Batch, processors, now)
+		g.instancesMtx.RUnlock()
+		return
+	}
+	g.instancesMtx.RUnlock()
+
+	g.instancesMtx.Lock()
+	defer g.instancesMtx.Unlock()
+	inst, ok = g.instances[userID]
+	if !ok {
+		inst = newInstance(g.cfg, userID)
+		g.instances[userID] = inst
+	}
+	inst.push(req.Batch, processors, now)
+}
+
+// collect remote writes the series of every tenant.  Tenants without series are removed.
+func (g *Generator) collect(ctx context.Context) {
+	now := time.Now()
+	extra := newLabels(instanceLabel, g.ringLifecycler.ID)
+	for k, v := range g.cfg.ExternalLabels {
+		extra = append(extra, prompb.Label{Name: k, Value: v})
+	}
+
+	g.instancesMtx.RLock()
+	instances := make(map[string]*instance, len(g.instances))
+	for userID, inst := range g.instances {
+		instances[userID] = inst
+	}
+	g.instancesMtx.RUnlock()
+
+	for userID, inst := range instances {
+		series := inst.collect(now, g.cfg.StaleDuration, extra)
+		metricActiveSeries.WithLabelValues(userID).Set(float64(len(series)))
+		if len(series) == 0 {
+			g.removeIdle(userID)
+			continue
+		}
+
+		err := g.remoteWrite.write(ctx, userID, series)
+		if err != nil {
+			metricRemoteWriteFailures.WithLabelValues(userID).Inc()
+			level.Error(util.Logger).Log("msg", "failed to remote write metrics", "tenant", userID, "err", err)
+			continue
+		}
+		metricSamplesWritten.WithLabelValues(userID).Add(float64(len(series)))
+	}
+}
+
+func (g *Generator) removeIdle(userID string) {
+	g.instancesMtx.Lock()
+	defer g.instancesMtx.Unlock()
+
+	if inst, ok := g.instances[userID]; ok && inst.idle() {
+		delete(g.instances, userID)
+		metricActiveSeries.DeleteLabelValues(userID)
+	}
+}
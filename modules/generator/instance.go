@@ -0,0 +1,49 @@
+package generator
+
+import (
+	"time"
+
+	v1 "github.com/open-telemetry/opentelemetry-proto/gen/go/trace/v1"
+	"github.com/prometheus/prometheus/prompb"
+)
+
+// instance derives the metrics of a tenant.
+type instance struct {
+	userID        string
+	registry      *registry
+	spanMetrics   *spanMetrics
+	serviceGraphs *serviceGraphs
+}
+
+func newInstance(cfg *Config, userID string) *instance {
+	registry := newRegistry()
+	return &instance{
+		userID:        userID,
+		registry:      registry,
+		spanMetrics:   newSpanMetrics(cfg.SpanMetrics, registry),
+		serviceGraphs: newServiceGraphs(cfg.ServiceGraphs, userID, registry),
+	}
+}
+
+// push runs the batch through the processors.  Unknown processors are ignored.
+func (i *instance) push(batch *v1.ResourceSpans, processors []string, now time.Time) {
+	for _, p := range processors {
+		switch p {
+		case ProcessorSpanMetrics:
+			i.spanMetrics.consume(batch, now)
+		case ProcessorServiceGraphs:
+			i.serviceGraphs.consume(batch, now)
+		}
+	}
+}
+
+// collect expires service graph edges and returns the current samples of the tenant's series.
+func (i *instance) collect(now time.Time, staleDuration time.Duration, extra []prompb.Label) []prompb.TimeSeries {
+	i.serviceGraphs.expire(now)
+	return i.registry.collect(now, staleDuration, extra)
+}
+
+// idle returns true once the tenant has no series and no edges waiting.
+func (i *instance) idle() bool {
+	return i.registry.len() == 0 && i.serviceGraphs.len() == 0
+}
@@ -0,0 +1,175 @@
+package generator
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/prometheus/prompb"
+)
+
+// registry holds the counters and histograms of a tenant between collections.  Series keep their values across
+// collections, like Prometheus client metrics, and are dropped once they haven't been updated for the stale duration.
+type registry struct {
+	mtx    sync.Mutex
+	series map[string]*series
+}
+
+type series struct {
+	name   string
+	labels []prompb.Label
+
+	value float64 // counters
+
+	bounds []float64 // histograms
+	counts []uint64  // per bucket, the last one counts observations above every bound
+	sum    float64
+	count  uint64
+
+	updated time.Time
+}
+
+func newRegistry() *registry {
+	return &registry{
+		series: map[string]*series{},
+	}
+}
+
+// newLabels returns the labels of the name and value pairs sorted by name.
+func newLabels(nameValues ...string) []prompb.Label {
+	labels := make([]prompb.Label, 0, len(nameValues)/2)
+	for i := 0; i+1 < len(nameValues); i += 2 {
+		labels = append(labels, prompb.Label{Name: nameValues[i], Value: nameValues[i+1]})
+	}
+	sort.Slice(labels, func(i, j int) bool {
+		return labels[i].Name < labels[j].Name
+	})
+	return labels
+}
+
+func seriesKey(name string, labels []prompb.Label) string {
+	var b strings.Builder
+	b.WriteString(name)
+	for _, l := range labels {
+		b.WriteByte(0xff)
+		b.WriteString(l.Name)
+		b.WriteByte(0xff)
+		b.WriteString(l.Value)
+	}
+	return b.String()
+}
+
+// getOrCreate must be called with the lock held.
+func (r *registry) getOrCreate(name string, labels []prompb.Label, bounds []float64) *series {
+	key := seriesKey(name, labels)
+	s, ok := r.series[key]
+	if !ok {
+		s = &series{
+			name:   name,
+			labels: labels,
+		}
+		if bounds != nil {
+			s.bounds = bounds
+			s.counts = make([]uint64, len(bounds)+1)
+		}
+		r.series[key] = s
+	}
+	return s
+}
+
+// addCounter adds v to the counter with the name and labels.
+func (r *registry) addCounter(name string, labels []prompb.Label, v float64, now time.Time) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	s := r.getOrCreate(name, labels, nil)
+	s.value += v
+	s.updated = now
+}
+
+// observe adds v to the histogram with the name and labels.  The bounds of a histogram must not change.
+func (r *registry) observe(name string, labels []prompb.Label, bounds []float64, v float64, now time.Time) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	s := r.getOrCreate(name, labels, bounds)
+	s.counts[sort.SearchFloat64s(s.bounds, v)]++
+	s.sum += v
+	s.count++
+	s.updated = now
+}
+
+// collect returns a sample at now of every series updated within the stale duration, with the extra labels added.
+// Histograms are written as the _bucket, _sum and _count series Prometheus expects.  Stale series are dropped.
+func (r *registry) collect(now time.Time, staleDuration time.Duration, extra []prompb.Label) []prompb.TimeSeries {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	ts := now.UnixNano() / int64(time.Millisecond)
+	var timeSeries []prompb.TimeSeries
+	add := func(s *series, name string, value float64, more ...prompb.Label) {
+		labels := make([]prompb.Label, 0, len(s.labels)+len(extra)+len(more)+1)
+		labels = append(labels, prompb.Label{Name: "__name__", Value: name})
+		labels = append(labels, more...)
+		labels = append(labels, s.labels...)
+		for _, l := range extra {
+			if !hasLabel(s.labels, l.Name) {
+				labels = append(labels, l)
+			}
+		}
+		sort.Slice(labels, func(i, j int) bool {
+			return labels[i].Name < labels[j].Name
+		})
+
+		timeSeries = append(timeSeries, prompb.TimeSeries{
+			Labels:  labels,
+			Samples: []prompb.Sample{{Value: value, Timestamp: ts}},
+		})
+	}
+
+	for key, s := range r.series {
+		if now.Sub(s.updated) > staleDuration {
+			delete(r.series, key)
+			continue
+		}
+
+		if s.bounds == nil {
+			add(s, s.name, s.value)
+			continue
+		}
+
+		var cumulative uint64
+		for i, n := range s.counts {
+			cumulative += n
+			le := math.Inf(1)
+			if i < len(s.bounds) {
+				le = s.bounds[i]
+			}
+			add(s, s.name+"_bucket", float64(cumulative), prompb.Label{Name: "le", Value: strconv.FormatFloat(le, 'f', -1, 64)})
+		}
+		add(s, s.name+"_sum", s.sum)
+		add(s, s.name+"_count", float64(s.count))
+	}
+
+	return timeSeries
+}
+
+// len returns the number of series.
+func (r *registry) len() int {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	return len(r.series)
+}
+
+func hasLabel(labels []prompb.Label, name string) bool {
+	for _, l := range labels {
+		if l.Name == name {
+			return true
+		}
+	}
+	return false
+}
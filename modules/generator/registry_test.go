@@ -0,0 +1,66 @@
+package generator
+
+import (
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/prometheus/prompb"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegistry(t *testing.T) {
+	r := newRegistry()
+	now := time.Unix(100, 0)
+
+	labels := newLabels("service", "app", "span_name", "GET")
+	r.addCounter("calls_total", labels, 1, now)
+	r.addCounter("calls_total", newLabels("span_name", "GET", "service", "app"), 2, now)
+	for _, v := range []float64{0.25, 0.5, 2} {
+		r.observe("latency", labels, []float64{0.1, 1}, v, now)
+	}
+
+	series := r.collect(now, time.Minute, newLabels("cluster", "eu", "service", "ignored"))
+	assert.Equal(t, map[string]float64{
+		`calls_total{cluster="eu",service="app",span_name="GET"}`:              3,
+		`latency_bucket{cluster="eu",le="0.1",service="app",span_name="GET"}`:  0,
+		`latency_bucket{cluster="eu",le="1",service="app",span_name="GET"}`:    2,
+		`latency_bucket{cluster="eu",le="+Inf",service="app",span_name="GET"}`: 3,
+		`latency_sum{cluster="eu",service="app",span_name="GET"}`:              2.75,
+		`latency_count{cluster="eu",service="app",span_name="GET"}`:            3,
+	}, samples(t, series, now))
+
+	// values are kept across collections until the series is stale
+	r.addCounter("calls_total", labels, 1, now.Add(30*time.Second))
+	series = r.collect(now.Add(90*time.Second), time.Minute, nil)
+	assert.Equal(t, map[string]float64{
+		`calls_total{service="app",span_name="GET"}`: 4,
+	}, samples(t, series, now.Add(90*time.Second)))
+	assert.Equal(t, 1, r.len())
+
+	assert.Empty(t, r.collect(now.Add(time.Hour), time.Minute, nil))
+	assert.Equal(t, 0, r.len())
+}
+
+// samples returns the value of each series by its name and sorted labels, checking the timestamps.
+func samples(t *testing.T, series []prompb.TimeSeries, now time.Time) map[string]float64 {
+	values := map[string]float64{}
+	for _, s := range series {
+		assert.True(t, sort.SliceIsSorted(s.Labels, func(i, j int) bool { return s.Labels[i].Name < s.Labels[j].Name }))
+		assert.Len(t, s.Samples, 1)
+		assert.Equal(t, now.UnixNano()/int64(time.Millisecond), s.Samples[0].Timestamp)
+
+		var name string
+		var labels []string
+		for _, l := range s.Labels {
+			if l.Name == "__name__" {
+				name = l.Value
+				continue
+			}
+			labels = append(labels, l.Name+`="`+l.Value+`"`)
+		}
+		values[name+"{"+strings.Join(labels, ",")+"}"] = s.Samples[0].Value
+	}
+	return values
+}
@@ -0,0 +1,67 @@
+package generator
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/cortexproject/cortex/pkg/ring/kv/codec"
+	"github.com/prometheus/prometheus/prompb"
+	"github.com/weaveworks/common/user"
+)
+
+// writeRequestCodec marshals write requests and snappy compresses them with the block format remote write expects.
+var writeRequestCodec = codec.NewProtoCodec("remote-write", nil)
+
+// remoteWriteClient sends the series of a tenant to a Prometheus remote write endpoint.
+type remoteWriteClient struct {
+	cfg    RemoteWriteConfig
+	client *http.Client
+}
+
+func newRemoteWriteClient(cfg RemoteWriteConfig) *remoteWriteClient {
+	return &remoteWriteClient{
+		cfg: cfg,
+		client: &http.Client{
+			Timeout: cfg.Timeout,
+		},
+	}
+}
+
+func (c *remoteWriteClient) write(ctx context.Context, userID string, series []prompb.TimeSeries) error {
+	body, err := writeRequestCodec.Encode(&prompb.WriteRequest{Timeseries: series})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.cfg.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	for k, v := range c.cfg.Headers {
+		req.Header.Set(k, v)
+	}
+	req.Header.Set("Content-Encoding", "snappy")
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	req.Header.Set("User-Agent", "tempo-metrics-generator")
+	req.Header.Set("X-Prometheus-Remote-Write-Version", "0.1.0")
+	req.Header.Set(user.OrgIDHeaderName, userID)
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_, _ = io.Copy(ioutil.Discard, resp.Body)
+		_ = resp.Body.Close()
+	}()
+
+	if resp.StatusCode/100 != 2 {
+		msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("remote write returned %s: %s", resp.Status, bytes.TrimSpace(msg))
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+package generator
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/cortexproject/cortex/pkg/ring/kv/codec"
+	"github.com/gogo/protobuf/proto"
+	"github.com/prometheus/prometheus/prompb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRemoteWrite(t *testing.T) {
+	decoder := codec.NewProtoCodec("remote-write", func() proto.Message { return &prompb.WriteRequest{} })
+
+	var received *prompb.WriteRequest
+	var headers http.Header
+	status := http.StatusOK
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		headers = r.Header
+		body, err := ioutil.ReadAll(r.Body)
+		require.NoError(t, err)
+		msg, err := decoder.Decode(body)
+		require.NoError(t, err)
+		received = msg.(*prompb.WriteRequest)
+
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte("out of order sample\n"))
+	}))
+	defer srv.Close()
+
+	c := newRemoteWriteClient(RemoteWriteConfig{
+		URL:     srv.URL,
+		Timeout: time.Second,
+		Headers: map[string]string{"Authorization": "Bearer token"},
+	})
+	series := []prompb.TimeSeries{
+		{
+			Labels:  newLabels("__name__", "calls_total", "service", "app"),
+			Samples: []prompb.Sample{{Value: 1, Timestamp: 1000}},
+		},
+	}
+
+	require.NoError(t, c.write(context.Background(), "tenant", series))
+	assert.Equal(t, series, received.Timeseries)
+	assert.Equal(t, "snappy", headers.Get("Content-Encoding"))
+	assert.Equal(t, "application/x-protobuf", headers.Get("Content-Type"))
+	assert.Equal(t, "0.1.0", headers.Get("X-Prometheus-Remote-Write-Version"))
+	assert.Equal(t, "tenant", headers.Get("X-Scope-OrgID"))
+	assert.Equal(t, "Bearer token", headers.Get("Authorization"))
+
+	status = http.StatusBadRequest
+	err := c.write(context.Background(), "tenant", series)
+	assert.EqualError(t, err, "remote write returned 400 Bad Request: out of order sample")
+}
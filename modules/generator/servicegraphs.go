@@ -0,0 +1,145 @@
+package generator
+
+import (
+	"sync"
+	"time"
+
+	v1 "github.com/open-telemetry/opentelemetry-proto/gen/go/trace/v1"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/grafana/tempo/pkg/tenantmetrics"
+)
+
+const (
+	metricServiceGraphRequests       = "traces_service_graph_request_total"
+	metricServiceGraphFailedRequests = "traces_service_graph_request_failed_total"
+	metricServiceGraphServerLatency  = "traces_service_graph_request_server_seconds"
+	metricServiceGraphClientLatency  = "traces_service_graph_request_client_seconds"
+)
+
+var (
+	metricExpiredEdges = tenantmetrics.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "tempo",
+		Name:      "metrics_generator_service_graph_expired_edges_total",
+		Help:      "The total number of service graph edges that expired before both of their spans were seen.",
+	}, []string{"tenant"})
+	metricDroppedEdges = tenantmetrics.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "tempo",
+		Name:      "metrics_generator_service_graph_dropped_edges_total",
+		Help:      "The total number of service graph edges dropped because too many were waiting.",
+	}, []string{"tenant"})
+)
+
+// serviceGraphs records a request between two services for every client span whose child is a server span.  The
+// first span of an edge waits for the other until it expires.  The distributors send every span of a trace to the
+// same generator, so both spans are seen here.
+type serviceGraphs struct {
+	cfg      ServiceGraphsConfig
+	userID   string
+	registry *registry
+
+	mtx   sync.Mutex
+	edges map[string]*edge
+}
+
+// edge is keyed by the trace id and the span id of the client span, which is the parent span id of the server span.
+type edge struct {
+	clientService string
+	serverService string
+	client        bool
+	server        bool
+	failed        bool
+	clientLatency time.Duration
+	serverLatency time.Duration
+	expiry        time.Time
+}
+
+func newServiceGraphs(cfg ServiceGraphsConfig, userID string, registry *registry) *serviceGraphs {
+	return &serviceGraphs{
+		cfg:      cfg,
+		userID:   userID,
+		registry: registry,
+		edges:    map[string]*edge{},
+	}
+}
+
+func (p *serviceGraphs) consume(batch *v1.ResourceSpans, now time.Time) {
+	service := serviceName(batch)
+
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	for _, ils := range batch.InstrumentationLibrarySpans {
+		for _, span := range ils.Spans {
+			var key string
+			switch span.Kind {
+			case v1.Span_CLIENT:
+				key = string(span.TraceId) + string(span.SpanId)
+			case v1.Span_SERVER:
+				if len(span.ParentSpanId) == 0 {
+					continue
+				}
+				key = string(span.TraceId) + string(span.ParentSpanId)
+			default:
+				continue
+			}
+
+			e, ok := p.edges[key]
+			if !ok {
+				if len(p.edges) >= p.cfg.MaxItems {
+					metricDroppedEdges.WithLabelValues(p.userID).Inc()
+					continue
+				}
+				e = &edge{expiry: now.Add(p.cfg.Wait)}
+				p.edges[key] = e
+			}
+
+			if span.Kind == v1.Span_CLIENT {
+				e.client = true
+				e.clientService = service
+				e.clientLatency = spanDuration(span)
+			} else {
+				e.server = true
+				e.serverService = service
+				e.serverLatency = spanDuration(span)
+			}
+			e.failed = e.failed || spanFailed(span)
+
+			if e.client && e.server {
+				p.complete(e, now)
+				delete(p.edges, key)
+			}
+		}
+	}
+}
+
+func (p *serviceGraphs) complete(e *edge, now time.Time) {
+	labels := newLabels("client", e.clientService, "server", e.serverService)
+	p.registry.addCounter(metricServiceGraphRequests, labels, 1, now)
+	if e.failed {
+		p.registry.addCounter(metricServiceGraphFailedRequests, labels, 1, now)
+	}
+	p.registry.observe(metricServiceGraphServerLatency, labels, p.cfg.HistogramBuckets, e.serverLatency.Seconds(), now)
+	p.registry.observe(metricServiceGraphClientLatency, labels, p.cfg.HistogramBuckets, e.clientLatency.Seconds(), now)
+}
+
+// expire drops the edges whose other span hasn't been seen in time.
+func (p *serviceGraphs) expire(now time.Time) {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	for key, e := range p.edges {
+		if now.After(e.expiry) {
+			delete(p.edges, key)
+			metricExpiredEdges.WithLabelValues(p.userID).Inc()
+		}
+	}
+}
+
+// len returns the number of edges waiting for their other span.
+func (p *serviceGraphs) len() int {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	return len(p.edges)
+}
@@ -0,0 +1,90 @@
+package generator
+
+import (
+	"testing"
+	"time"
+
+	v1_common "github.com/open-telemetry/opentelemetry-proto/gen/go/common/v1"
+	v1_resource "github.com/open-telemetry/opentelemetry-proto/gen/go/resource/v1"
+	v1 "github.com/open-telemetry/opentelemetry-proto/gen/go/trace/v1"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServiceGraphs(t *testing.T) {
+	r := newRegistry()
+	p := newServiceGraphs(ServiceGraphsConfig{Wait: time.Second, MaxItems: 2, HistogramBuckets: []float64{1}}, "test", r)
+	now := time.Unix(100, 0)
+
+	traceID := []byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0A, 0x0B, 0x0C, 0x0D, 0x0E, 0x0F, 0x10}
+	client := makeSpan(traceID, []byte{0x01}, nil, v1.Span_CLIENT, "GET /api", 2*time.Second, v1.Status_Ok)
+	server := makeSpan(traceID, []byte{0x02}, []byte{0x01}, v1.Span_SERVER, "GET /api", time.Second, v1.Status_InternalError)
+
+	// the server span arrives first and waits for its parent
+	p.consume(makeBatch("api", server), now)
+	assert.Equal(t, 1, p.len())
+	p.consume(makeBatch("frontend", client), now)
+	assert.Equal(t, 0, p.len())
+
+	assert.Equal(t, map[string]float64{
+		`traces_service_graph_request_total{client="frontend",server="api"}`:                           1,
+		`traces_service_graph_request_failed_total{client="frontend",server="api"}`:                    1,
+		`traces_service_graph_request_server_seconds_bucket{client="frontend",le="1",server="api"}`:    1,
+		`traces_service_graph_request_server_seconds_bucket{client="frontend",le="+Inf",server="api"}`: 1,
+		`traces_service_graph_request_server_seconds_sum{client="frontend",server="api"}`:              1,
+		`traces_service_graph_request_server_seconds_count{client="frontend",server="api"}`:            1,
+		`traces_service_graph_request_client_seconds_bucket{client="frontend",le="1",server="api"}`:    0,
+		`traces_service_graph_request_client_seconds_bucket{client="frontend",le="+Inf",server="api"}`: 1,
+		`traces_service_graph_request_client_seconds_sum{client="frontend",server="api"}`:              2,
+		`traces_service_graph_request_client_seconds_count{client="frontend",server="api"}`:            1,
+	}, samples(t, r.collect(now, time.Minute, nil), now))
+
+	// root server spans and internal spans have no edge
+	p.consume(makeBatch("api",
+		makeSpan(traceID, []byte{0x03}, nil, v1.Span_SERVER, "GET /", time.Second, v1.Status_Ok),
+		makeSpan(traceID, []byte{0x04}, []byte{0x03}, v1.Span_INTERNAL, "work", time.Second, v1.Status_Ok),
+	), now)
+	assert.Equal(t, 0, p.len())
+
+	// edges are dropped once too many are waiting and expire if the other span isn't seen
+	p.consume(makeBatch("frontend",
+		makeSpan(traceID, []byte{0x05}, nil, v1.Span_CLIENT, "a", time.Second, v1.Status_Ok),
+		makeSpan(traceID, []byte{0x06}, nil, v1.Span_CLIENT, "b", time.Second, v1.Status_Ok),
+		makeSpan(traceID, []byte{0x07}, nil, v1.Span_CLIENT, "c", time.Second, v1.Status_Ok),
+	), now)
+	assert.Equal(t, 2, p.len())
+
+	p.expire(now.Add(time.Second))
+	assert.Equal(t, 2, p.len())
+	p.expire(now.Add(2 * time.Second))
+	assert.Equal(t, 0, p.len())
+}
+
+func makeBatch(service string, spans ...*v1.Span) *v1.ResourceSpans {
+	return &v1.ResourceSpans{
+		Resource: &v1_resource.Resource{
+			Attributes: []*v1_common.KeyValue{
+				{
+					Key:   serviceNameAttribute,
+					Value: &v1_common.AnyValue{Value: &v1_common.AnyValue_StringValue{StringValue: service}},
+				},
+			},
+		},
+		InstrumentationLibrarySpans: []*v1.InstrumentationLibrarySpans{
+			{Spans: spans},
+		},
+	}
+}
+
+func makeSpan(traceID []byte, spanID []byte, parentSpanID []byte, kind v1.Span_SpanKind, name string, duration time.Duration, code v1.Status_StatusCode) *v1.Span {
+	start := uint64(time.Unix(100, 0).UnixNano())
+	return &v1.Span{
+		TraceId:           traceID,
+		SpanId:            spanID,
+		ParentSpanId:      parentSpanID,
+		Kind:              kind,
+		Name:              name,
+		StartTimeUnixNano: start,
+		EndTimeUnixNano:   start + uint64(duration),
+		Status:            &v1.Status{Code: code},
+	}
+}
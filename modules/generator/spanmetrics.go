@@ -0,0 +1,67 @@
+package generator
+
+import (
+	"time"
+
+	v1 "github.com/open-telemetry/opentelemetry-proto/gen/go/trace/v1"
+)
+
+const (
+	metricSpanMetricsCalls   = "traces_spanmetrics_calls_total"
+	metricSpanMetricsLatency = "traces_spanmetrics_latency"
+
+	serviceNameAttribute = "service.name"
+)
+
+// spanMetrics counts the spans of each service, span name, kind and status and records their durations.  Errors
+// are the calls with a status other than Ok.
+type spanMetrics struct {
+	cfg      SpanMetricsConfig
+	registry *registry
+}
+
+func newSpanMetrics(cfg SpanMetricsConfig, registry *registry) *spanMetrics {
+	return &spanMetrics{
+		cfg:      cfg,
+		registry: registry,
+	}
+}
+
+func (p *spanMetrics) consume(batch *v1.ResourceSpans, now time.Time) {
+	service := serviceName(batch)
+	for _, ils := range batch.InstrumentationLibrarySpans {
+		for _, span := range ils.Spans {
+			labels := newLabels(
+				"service", service,
+				"span_name", span.Name,
+				"span_kind", span.Kind.String(),
+				"status_code", span.GetStatus().GetCode().String(),
+			)
+			p.registry.addCounter(metricSpanMetricsCalls, labels, 1, now)
+			p.registry.observe(metricSpanMetricsLatency, labels, p.cfg.HistogramBuckets, spanDuration(span).Seconds(), now)
+		}
+	}
+}
+
+func serviceName(batch *v1.ResourceSpans) string {
+	if batch.Resource == nil {
+		return ""
+	}
+	for _, attr := range batch.Resource.Attributes {
+		if attr.Key == serviceNameAttribute {
+			return attr.Value.GetStringValue()
+		}
+	}
+	return ""
+}
+
+func spanDuration(span *v1.Span) time.Duration {
+	if span.EndTimeUnixNano <= span.StartTimeUnixNano {
+		return 0
+	}
+	return time.Duration(span.EndTimeUnixNano - span.StartTimeUnixNano)
+}
+
+func spanFailed(span *v1.Span) bool {
+	return span.Status != nil && span.Status.Code != v1.Status_Ok
+}
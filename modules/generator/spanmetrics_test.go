@@ -0,0 +1,37 @@
+package generator
+
+import (
+	"testing"
+	"time"
+
+	v1 "github.com/open-telemetry/opentelemetry-proto/gen/go/trace/v1"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSpanMetrics(t *testing.T) {
+	r := newRegistry()
+	p := newSpanMetrics(SpanMetricsConfig{HistogramBuckets: []float64{1}}, r)
+	now := time.Unix(100, 0)
+
+	traceID := []byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0A, 0x0B, 0x0C, 0x0D, 0x0E, 0x0F, 0x10}
+	p.consume(makeBatch("api",
+		makeSpan(traceID, []byte{0x01}, nil, v1.Span_SERVER, "GET /", 500*time.Millisecond, v1.Status_Ok),
+		makeSpan(traceID, []byte{0x02}, nil, v1.Span_SERVER, "GET /", 2*time.Second, v1.Status_Ok),
+		makeSpan(traceID, []byte{0x03}, nil, v1.Span_SERVER, "GET /", time.Second, v1.Status_InternalError),
+	), now)
+
+	ok := `service="api",span_kind="SERVER",span_name="GET /",status_code="Ok"`
+	failed := `service="api",span_kind="SERVER",span_name="GET /",status_code="InternalError"`
+	assert.Equal(t, map[string]float64{
+		`traces_spanmetrics_calls_total{` + ok + `}`:                  2,
+		`traces_spanmetrics_latency_bucket{le="1",` + ok + `}`:        1,
+		`traces_spanmetrics_latency_bucket{le="+Inf",` + ok + `}`:     2,
+		`traces_spanmetrics_latency_sum{` + ok + `}`:                  2.5,
+		`traces_spanmetrics_latency_count{` + ok + `}`:                2,
+		`traces_spanmetrics_calls_total{` + failed + `}`:              1,
+		`traces_spanmetrics_latency_bucket{le="1",` + failed + `}`:    1,
+		`traces_spanmetrics_latency_bucket{le="+Inf",` + failed + `}`: 1,
+		`traces_spanmetrics_latency_sum{` + failed + `}`:              1,
+		`traces_spanmetrics_latency_count{` + failed + `}`:            1,
+	}, samples(t, r.collect(now, time.Minute, nil), now))
+}
@@ -0,0 +1,68 @@
+package ingester
+
+import (
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/grafana/tempo/pkg/tempopb"
+	"github.com/grafana/tempo/pkg/tenantmetrics"
+)
+
+var metricAttributesDropped = tenantmetrics.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "tempo",
+	Name:      "ingester_attributes_dropped_total",
+	Help:      "The total number of span attributes dropped for exceeding the unique attribute keys per block limit.",
+}, []string{"tenant"})
+
+// blockAttributeKeys accumulates the unique span attribute keys pushed since the head block was last cut.  Traces are
+// written to the head block when they are cut so this is an approximation of the keys in the block.
+type blockAttributeKeys struct {
+	mtx  sync.Mutex
+	keys map[string]struct{}
+}
+
+func newBlockAttributeKeys() *blockAttributeKeys {
+	return &blockAttributeKeys{
+		keys: map[string]struct{}{},
+	}
+}
+
+func (b *blockAttributeKeys) reset() {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	b.keys = map[string]struct{}{}
+}
+
+// filter records the span attribute keys in req.  Once max keys have been seen, attributes with new keys are removed
+// from their spans and added to the span's dropped attributes count.  It returns the number of attributes dropped.
+func (b *blockAttributeKeys) filter(req *tempopb.PushRequest, max int) int {
+	if max <= 0 || req.Batch == nil {
+		return 0
+	}
+
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	dropped := 0
+	for _, ils := range req.Batch.InstrumentationLibrarySpans {
+		for _, span := range ils.Spans {
+			kept := span.Attributes[:0]
+			for _, kv := range span.Attributes {
+				if _, ok := b.keys[kv.Key]; !ok {
+					if len(b.keys) >= max {
+						dropped++
+						span.DroppedAttributesCount++
+						continue
+					}
+					b.keys[kv.Key] = struct{}{}
+				}
+				kept = append(kept, kv)
+			}
+			span.Attributes = kept
+		}
+	}
+
+	return dropped
+}
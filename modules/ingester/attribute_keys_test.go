@@ -0,0 +1,59 @@
+package ingester
+
+import (
+	"testing"
+
+	v1_common "github.com/open-telemetry/opentelemetry-proto/gen/go/common/v1"
+	v1 "github.com/open-telemetry/opentelemetry-proto/gen/go/trace/v1"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/grafana/tempo/pkg/tempopb"
+)
+
+func requestWithAttributeKeys(keys ...string) (*tempopb.PushRequest, *v1.Span) {
+	span := &v1.Span{}
+	for _, k := range keys {
+		span.Attributes = append(span.Attributes, &v1_common.KeyValue{Key: k})
+	}
+
+	return &tempopb.PushRequest{
+		Batch: &v1.ResourceSpans{
+			InstrumentationLibrarySpans: []*v1.InstrumentationLibrarySpans{
+				{Spans: []*v1.Span{span}},
+			},
+		},
+	}, span
+}
+
+func attributeKeys(span *v1.Span) []string {
+	keys := []string{}
+	for _, kv := range span.Attributes {
+		keys = append(keys, kv.Key)
+	}
+	return keys
+}
+
+func TestBlockAttributeKeys(t *testing.T) {
+	b := newBlockAttributeKeys()
+
+	req, span := requestWithAttributeKeys("a", "b")
+	assert.Equal(t, 0, b.filter(req, 3))
+	assert.Equal(t, []string{"a", "b"}, attributeKeys(span))
+
+	// known keys are always kept.  only one new key fits
+	req, span = requestWithAttributeKeys("c", "a", "d", "e")
+	assert.Equal(t, 2, b.filter(req, 3))
+	assert.Equal(t, []string{"c", "a"}, attributeKeys(span))
+	assert.Equal(t, uint32(2), span.DroppedAttributesCount)
+
+	// disabled
+	req, span = requestWithAttributeKeys("f")
+	assert.Equal(t, 0, b.filter(req, 0))
+	assert.Equal(t, []string{"f"}, attributeKeys(span))
+
+	// cutting the head block starts over
+	b.reset()
+	req, span = requestWithAttributeKeys("x", "y", "z")
+	assert.Equal(t, 0, b.filter(req, 3))
+	assert.Equal(t, []string{"x", "y", "z"}, attributeKeys(span))
+}
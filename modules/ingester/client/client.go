@@ -14,18 +14,24 @@ import (
 	"google.golang.org/grpc/health/grpc_health_v1"
 
 	"github.com/grafana/tempo/pkg/tempopb"
+	"github.com/grafana/tempo/pkg/util"
 )
 
 // Config for an ingester client.
 type Config struct {
-	PoolConfig       ring_client.PoolConfig `yaml:"pool_config,omitempty"`
-	RemoteTimeout    time.Duration          `yaml:"remote_timeout,omitempty"`
-	GRPCClientConfig grpcclient.Config      `yaml:"grpc_client_config"`
+	PoolConfig       ring_client.PoolConfig   `yaml:"pool_config,omitempty"`
+	RemoteTimeout    time.Duration            `yaml:"remote_timeout,omitempty"`
+	GRPCClientConfig grpcclient.ConfigWithTLS `yaml:"grpc_client_config"`
+	TLS              TLSConfig                `yaml:"tls"`
+	// SocketOverrides maps ingester ring addresses to the unix domain sockets they are dialed on instead, e.g. to
+	// reach an ingester in the same pod.  Addresses prefixed with unix: are always dialed as sockets.
+	SocketOverrides map[string]string `yaml:"socket_overrides"`
 }
 
 type Client struct {
 	tempopb.PusherClient
 	tempopb.QuerierClient
+	tempopb.MetricsGeneratorClient
 	grpc_health_v1.HealthClient
 	io.Closer
 }
@@ -33,6 +39,7 @@ type Client struct {
 // RegisterFlags registers flags.
 func (cfg *Config) RegisterFlags(f *flag.FlagSet) {
 	cfg.GRPCClientConfig.RegisterFlagsWithPrefix("ingester.client", f)
+	cfg.TLS.RegisterFlagsWithPrefix("ingester.client", f)
 
 	f.DurationVar(&cfg.PoolConfig.HealthCheckTimeout, "ingester.client.healthcheck-timeout", 1*time.Second, "Timeout for healthcheck rpcs.")
 	f.DurationVar(&cfg.PoolConfig.CheckInterval, "ingester.client.healthcheck-interval", 15*time.Second, "Interval to healthcheck ingesters")
@@ -40,33 +47,52 @@ func (cfg *Config) RegisterFlags(f *flag.FlagSet) {
 	f.DurationVar(&cfg.RemoteTimeout, "ingester.client.timeout", 5*time.Second, "Timeout for ingester client RPCs.")
 }
 
-// New returns a new ingester client.
+// New returns a new ingester client.  It is also used to reach block gateways and metrics generators.
 func New(addr string, cfg Config) (*Client, error) {
-	opts := []grpc.DialOption{
-		grpc.WithInsecure(),
-		grpc.WithDefaultCallOptions(
-			grpc.UseCompressor("gzip"),
-		),
+	if path, ok := cfg.SocketOverrides[addr]; ok {
+		addr = util.UnixPrefix + path
 	}
-	opts = append(opts, cfg.GRPCClientConfig.DialOption(instrumentation())...)
+
+	opts, err := dialOptions(cfg)
+	if err != nil {
+		return nil, err
+	}
+	opts = append(opts, grpc.WithDefaultCallOptions(
+		grpc.UseCompressor("gzip"),
+	), grpc.WithContextDialer(util.DialContext))
 	conn, err := grpc.Dial(addr, opts...)
 	if err != nil {
 		return nil, err
 	}
 	return &Client{
-		PusherClient:  tempopb.NewPusherClient(conn),
-		QuerierClient: tempopb.NewQuerierClient(conn),
-		HealthClient:  grpc_health_v1.NewHealthClient(conn),
-		Closer:        conn,
+		PusherClient:           tempopb.NewPusherClient(conn),
+		QuerierClient:          tempopb.NewQuerierClient(conn),
+		MetricsGeneratorClient: tempopb.NewMetricsGeneratorClient(conn),
+		HealthClient:           grpc_health_v1.NewHealthClient(conn),
+		Closer:                 conn,
 	}, nil
 }
 
+// dialOptions prefers the tls config.  Otherwise the grpc client config dials insecure unless a client tls cert, key
+// and ca are all configured, in which case the server certificate isn't verified.
+func dialOptions(cfg Config) ([]grpc.DialOption, error) {
+	if !cfg.TLS.Enabled {
+		return cfg.GRPCClientConfig.DialOption(instrumentation())
+	}
+
+	creds, err := cfg.TLS.credentials()
+	if err != nil {
+		return nil, err
+	}
+	return append(cfg.GRPCClientConfig.GRPC.DialOption(instrumentation()), grpc.WithTransportCredentials(creds)), nil
+}
+
 func instrumentation() ([]grpc.UnaryClientInterceptor, []grpc.StreamClientInterceptor) {
 	return []grpc.UnaryClientInterceptor{
-			otgrpc.OpenTracingClientInterceptor(opentracing.GlobalTracer()),
-			middleware.ClientUserHeaderInterceptor,
-		}, []grpc.StreamClientInterceptor{
-			otgrpc.OpenTracingStreamClientInterceptor(opentracing.GlobalTracer()),
-			middleware.StreamClientUserHeaderInterceptor,
-		}
+		otgrpc.OpenTracingClientInterceptor(opentracing.GlobalTracer()),
+		middleware.ClientUserHeaderInterceptor,
+	}, []grpc.StreamClientInterceptor{
+		otgrpc.OpenTracingStreamClientInterceptor(opentracing.GlobalTracer()),
+		middleware.StreamClientUserHeaderInterceptor,
+	}
 }
@@ -0,0 +1,50 @@
+package client
+
+import (
+	"context"
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/weaveworks/common/user"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	"google.golang.org/grpc/health/grpc_health_v1"
+
+	"github.com/grafana/tempo/pkg/util"
+)
+
+func TestSocketOverride(t *testing.T) {
+	dir, err := ioutil.TempDir("", "client")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "grpc.sock")
+	l, err := util.ListenUnix(path)
+	require.NoError(t, err)
+
+	srv := grpc.NewServer()
+	grpc_health_v1.RegisterHealthServer(srv, health.NewServer())
+	go func() {
+		_ = srv.Serve(l)
+	}()
+	defer srv.Stop()
+
+	cfg := Config{}
+	cfg.RegisterFlags(flag.NewFlagSet("", flag.PanicOnError))
+	cfg.SocketOverrides = map[string]string{"ingester-0:9095": path}
+
+	for _, addr := range []string{"ingester-0:9095", util.UnixPrefix + path} {
+		c, err := New(addr, cfg)
+		require.NoError(t, err)
+
+		resp, err := c.Check(user.InjectOrgID(context.Background(), "test"), &grpc_health_v1.HealthCheckRequest{})
+		require.NoError(t, err)
+		assert.Equal(t, grpc_health_v1.HealthCheckResponse_SERVING, resp.Status)
+		require.NoError(t, c.Close())
+	}
+}
@@ -0,0 +1,45 @@
+package client
+
+import (
+	"github.com/gogo/status"
+	"google.golang.org/grpc/codes"
+
+	"github.com/grafana/tempo/pkg/tempopb"
+)
+
+// Reasons of pushes rejected by an ingester limit.  They are also the reason label of tempo_discarded_spans_total.
+const (
+	ReasonMaxSpansPerTrace = "max_spans_per_trace"
+	ReasonMaxBytesPerTrace = "max_bytes_per_trace"
+	ReasonMaxLiveTraces    = "max_live_traces"
+)
+
+// LimitError returns the error of a push rejected by a per tenant limit.  It's a FailedPrecondition status, which
+// clients receive as a 4xx and don't retry, with tempopb.PushErrorDetails attached.
+func LimitError(reason string, traceID []byte, spans int, format string, args ...interface{}) error {
+	st := status.Newf(codes.FailedPrecondition, format, args...)
+	withDetails, err := st.WithDetails(&tempopb.PushErrorDetails{
+		Reason:  reason,
+		TraceID: traceID,
+		Spans:   uint32(spans),
+	})
+	if err != nil {
+		return st.Err()
+	}
+	return withDetails.Err()
+}
+
+// LimitErrorDetails returns the details of an error created by LimitError, also after it was returned over gRPC.
+func LimitErrorDetails(err error) (*tempopb.PushErrorDetails, bool) {
+	st, ok := status.FromError(err)
+	if !ok || st.Code() != codes.FailedPrecondition {
+		return nil, false
+	}
+
+	for _, detail := range st.Details() {
+		if details, ok := detail.(*tempopb.PushErrorDetails); ok {
+			return details, true
+		}
+	}
+	return nil, false
+}
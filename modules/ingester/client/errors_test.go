@@ -0,0 +1,29 @@
+package client
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	grpc_status "google.golang.org/grpc/status"
+)
+
+func TestLimitError(t *testing.T) {
+	traceID := []byte{0x01, 0x02}
+	err := LimitError(ReasonMaxSpansPerTrace, traceID, 5, "trace %x exceeded max spans", traceID)
+	assert.Equal(t, "rpc error: code = FailedPrecondition desc = trace 0102 exceeded max spans", err.Error())
+
+	// the details survive the conversion to and from the status sent over grpc
+	sent := grpc_status.Convert(err)
+	assert.Equal(t, codes.FailedPrecondition, sent.Code())
+
+	details, ok := LimitErrorDetails(sent.Err())
+	require.True(t, ok)
+	assert.Equal(t, ReasonMaxSpansPerTrace, details.Reason)
+	assert.Equal(t, traceID, details.TraceID)
+	assert.Equal(t, uint32(5), details.Spans)
+
+	_, ok = LimitErrorDetails(grpc_status.Error(codes.FailedPrecondition, "no details"))
+	assert.False(t, ok)
+}
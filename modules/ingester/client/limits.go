@@ -0,0 +1,69 @@
+package client
+
+import (
+	"context"
+	"strconv"
+
+	"google.golang.org/grpc/metadata"
+)
+
+// gRPC metadata keys used by the distributor to pass the limits it resolved for a tenant to the ingesters.  This
+// keeps every ingester enforcing the same values even if their runtime overrides are briefly out of sync.
+const (
+	metadataMaxLocalTracesPerUser  = "x-tempo-max-local-traces-per-user"
+	metadataMaxGlobalTracesPerUser = "x-tempo-max-global-traces-per-user"
+	metadataMaxSpansPerTrace       = "x-tempo-max-spans-per-trace"
+	metadataMaxBytesPerTrace       = "x-tempo-max-bytes-per-trace"
+	metadataMaxAttributeKeys       = "x-tempo-max-attribute-keys-per-block"
+)
+
+// PushLimits are the per tenant limits that apply to a push request.
+type PushLimits struct {
+	MaxLocalTracesPerUser  int
+	MaxGlobalTracesPerUser int
+	MaxSpansPerTrace       int
+	MaxBytesPerTrace       int
+	MaxAttributeKeys       int
+}
+
+// InjectPushLimits adds limits to the outgoing gRPC metadata of ctx.
+func InjectPushLimits(ctx context.Context, limits PushLimits) context.Context {
+	return metadata.AppendToOutgoingContext(ctx,
+		metadataMaxLocalTracesPerUser, strconv.Itoa(limits.MaxLocalTracesPerUser),
+		metadataMaxGlobalTracesPerUser, strconv.Itoa(limits.MaxGlobalTracesPerUser),
+		metadataMaxSpansPerTrace, strconv.Itoa(limits.MaxSpansPerTrace),
+		metadataMaxBytesPerTrace, strconv.Itoa(limits.MaxBytesPerTrace),
+		metadataMaxAttributeKeys, strconv.Itoa(limits.MaxAttributeKeys),
+	)
+}
+
+// ExtractPushLimits reads limits from the incoming gRPC metadata of ctx.  It returns false if the limits are missing
+// or malformed in which case the receiver should resolve limits itself.
+func ExtractPushLimits(ctx context.Context) (PushLimits, bool) {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return PushLimits{}, false
+	}
+
+	var limits PushLimits
+	for key, dest := range map[string]*int{
+		metadataMaxLocalTracesPerUser:  &limits.MaxLocalTracesPerUser,
+		metadataMaxGlobalTracesPerUser: &limits.MaxGlobalTracesPerUser,
+		metadataMaxSpansPerTrace:       &limits.MaxSpansPerTrace,
+		metadataMaxBytesPerTrace:       &limits.MaxBytesPerTrace,
+		metadataMaxAttributeKeys:       &limits.MaxAttributeKeys,
+	} {
+		vals := md.Get(key)
+		if len(vals) == 0 {
+			return PushLimits{}, false
+		}
+
+		v, err := strconv.Atoi(vals[0])
+		if err != nil {
+			return PushLimits{}, false
+		}
+		*dest = v
+	}
+
+	return limits, true
+}
@@ -0,0 +1,80 @@
+package client
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// metadataLoad carries the number of live traces of the ingester back in the response header of a push.
+const metadataLoad = "x-tempo-ingester-load"
+
+// AdvertiseLoad reports the live traces of the ingester in the response header of the push.
+func AdvertiseLoad(ctx context.Context, liveTraces int) {
+	_ = grpc.SetHeader(ctx, metadata.Pairs(metadataLoad, strconv.Itoa(liveTraces)))
+}
+
+// IngesterLoads tracks the load last advertised by each ingester.  Loads older than the max age are unknown, so an
+// ingester that stopped receiving pushes isn't avoided forever.
+type IngesterLoads struct {
+	maxAge time.Duration
+
+	mtx   sync.Mutex
+	loads map[string]ingesterLoad
+}
+
+type ingesterLoad struct {
+	liveTraces int
+	observed   time.Time
+}
+
+// NewIngesterLoads creates an empty IngesterLoads.
+func NewIngesterLoads(maxAge time.Duration) *IngesterLoads {
+	return &IngesterLoads{
+		maxAge: maxAge,
+		loads:  map[string]ingesterLoad{},
+	}
+}
+
+// Get returns the live traces of the ingester at addr and false if its load is unknown.
+func (l *IngesterLoads) Get(addr string) (int, bool) {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	load, ok := l.loads[addr]
+	if !ok || time.Since(load.observed) > l.maxAge {
+		return 0, false
+	}
+	return load.liveTraces, true
+}
+
+// Observe records the load advertised in the response header of a push to the ingester at addr.  Responses without a
+// load come from ingesters that predate load hints.
+func (l *IngesterLoads) Observe(addr string, header metadata.MD) {
+	vals := header.Get(metadataLoad)
+	if len(vals) == 0 {
+		return
+	}
+	liveTraces, err := strconv.Atoi(vals[0])
+	if err != nil {
+		return
+	}
+
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+	l.loads[addr] = ingesterLoad{
+		liveTraces: liveTraces,
+		observed:   time.Now(),
+	}
+}
+
+// Forget drops the load of the ingester at addr.
+func (l *IngesterLoads) Forget(addr string) {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+	delete(l.loads, addr)
+}
@@ -0,0 +1,37 @@
+package client
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestIngesterLoads(t *testing.T) {
+	l := NewIngesterLoads(time.Minute)
+
+	_, ok := l.Get("a")
+	assert.False(t, ok)
+
+	l.Observe("a", metadata.Pairs(metadataLoad, "10"))
+	l.Observe("b", metadata.Pairs("foo", "bar"))
+	l.Observe("c", metadata.Pairs(metadataLoad, "many"))
+
+	load, ok := l.Get("a")
+	assert.True(t, ok)
+	assert.Equal(t, 10, load)
+	_, ok = l.Get("b")
+	assert.False(t, ok)
+	_, ok = l.Get("c")
+	assert.False(t, ok)
+
+	l.Forget("a")
+	_, ok = l.Get("a")
+	assert.False(t, ok)
+
+	stale := NewIngesterLoads(-time.Second)
+	stale.Observe("a", metadata.Pairs(metadataLoad, "10"))
+	_, ok = stale.Get("a")
+	assert.False(t, ok)
+}
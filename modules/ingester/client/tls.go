@@ -0,0 +1,63 @@
+package client
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"flag"
+	"fmt"
+	"io/ioutil"
+
+	"google.golang.org/grpc/credentials"
+)
+
+// TLSConfig configures TLS for the connections to ingesters.  Unlike the tls options of grpc_client_config the
+// certificate of the ingester is verified, against the CA or else the system roots, and a client certificate is only
+// needed for mTLS.
+type TLSConfig struct {
+	Enabled  bool   `yaml:"enabled"`
+	CertPath string `yaml:"cert_path"`
+	KeyPath  string `yaml:"key_path"`
+	CAPath   string `yaml:"ca_path"`
+	// ServerName is verified instead of the address the ingester registered in the ring, which usually is an ip.
+	ServerName         string `yaml:"server_name"`
+	InsecureSkipVerify bool   `yaml:"insecure_skip_verify"`
+}
+
+// RegisterFlagsWithPrefix registers flags with prefix.
+func (cfg *TLSConfig) RegisterFlagsWithPrefix(prefix string, f *flag.FlagSet) {
+	f.BoolVar(&cfg.Enabled, prefix+".tls-enabled", false, "Connect with TLS, verifying the server certificate. Takes precedence over the tls paths of the grpc client config.")
+	f.StringVar(&cfg.CertPath, prefix+".tls-client-cert-path", "", "Client certificate presented for mTLS.")
+	f.StringVar(&cfg.KeyPath, prefix+".tls-client-key-path", "", "Key of the client certificate.")
+	f.StringVar(&cfg.CAPath, prefix+".tls-server-ca-path", "", "CA the server certificate is verified against. The system roots are used if empty.")
+	f.StringVar(&cfg.ServerName, prefix+".tls-server-name", "", "Name the server certificate is verified for. The dialed address is used if empty.")
+	f.BoolVar(&cfg.InsecureSkipVerify, prefix+".tls-insecure-skip-verify", false, "Skip verifying the server certificate.")
+}
+
+// credentials reads the certificates and returns the transport credentials to dial with.
+func (cfg *TLSConfig) credentials() (credentials.TransportCredentials, error) {
+	tlsConfig := &tls.Config{
+		ServerName:         cfg.ServerName,
+		InsecureSkipVerify: cfg.InsecureSkipVerify,
+	}
+
+	if cfg.CertPath != "" || cfg.KeyPath != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.CertPath, cfg.KeyPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load tls client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	if cfg.CAPath != "" {
+		ca, err := ioutil.ReadFile(cfg.CAPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read tls ca: %w", err)
+		}
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("no certificates found in %s", cfg.CAPath)
+		}
+	}
+
+	return credentials.NewTLS(tlsConfig), nil
+}
@@ -0,0 +1,178 @@
+package client
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"flag"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/weaveworks/common/user"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/health"
+	"google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// testCA issues certificates and writes them to dir.
+type testCA struct {
+	dir  string
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+func newTestCA(t *testing.T, dir string) *testCA {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "tempo test ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	ca := &testCA{dir: dir, cert: cert, key: key}
+	ca.write(t, "ca.crt", "CERTIFICATE", der)
+	return ca
+}
+
+// issue writes name.crt and name.key for a certificate valid for dnsName.
+func (ca *testCA) issue(t *testing.T, name string, dnsName string, usage x509.ExtKeyUsage) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: dnsName},
+		DNSNames:     []string{dnsName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	ca.write(t, name+".crt", "CERTIFICATE", der)
+	ca.write(t, name+".key", "EC PRIVATE KEY", keyDER)
+}
+
+func (ca *testCA) write(t *testing.T, name string, blockType string, der []byte) {
+	pemBytes := pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der})
+	require.NoError(t, ioutil.WriteFile(ca.path(name), pemBytes, 0600))
+}
+
+func (ca *testCA) path(name string) string {
+	return filepath.Join(ca.dir, name)
+}
+
+func TestTLS(t *testing.T) {
+	dir, err := ioutil.TempDir("", "client")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	ca := newTestCA(t, dir)
+	ca.issue(t, "server", "ingester.tempo", x509.ExtKeyUsageServerAuth)
+	ca.issue(t, "client", "distributor.tempo", x509.ExtKeyUsageClientAuth)
+
+	// the ingester requires a client certificate signed by the ca
+	serverCert, err := tls.LoadX509KeyPair(ca.path("server.crt"), ca.path("server.key"))
+	require.NoError(t, err)
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(ca.cert)
+	srv := grpc.NewServer(grpc.Creds(credentials.NewTLS(&tls.Config{
+		Certificates: []tls.Certificate{serverCert},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    clientCAs,
+	})))
+	grpc_health_v1.RegisterHealthServer(srv, health.NewServer())
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go func() {
+		_ = srv.Serve(l)
+	}()
+	defer srv.Stop()
+
+	for _, tc := range []struct {
+		name        string
+		tls         TLSConfig
+		expectError bool
+	}{
+		{
+			name: "mtls",
+			tls:  TLSConfig{Enabled: true, CertPath: ca.path("client.crt"), KeyPath: ca.path("client.key"), CAPath: ca.path("ca.crt"), ServerName: "ingester.tempo"},
+		},
+		{
+			name:        "no client certificate",
+			tls:         TLSConfig{Enabled: true, CAPath: ca.path("ca.crt"), ServerName: "ingester.tempo"},
+			expectError: true,
+		},
+		{
+			name:        "address not in server certificate",
+			tls:         TLSConfig{Enabled: true, CertPath: ca.path("client.crt"), KeyPath: ca.path("client.key"), CAPath: ca.path("ca.crt")},
+			expectError: true,
+		},
+		{
+			name:        "unknown ca",
+			tls:         TLSConfig{Enabled: true, CertPath: ca.path("client.crt"), KeyPath: ca.path("client.key"), ServerName: "ingester.tempo"},
+			expectError: true,
+		},
+		{
+			name: "insecure skip verify",
+			tls:  TLSConfig{Enabled: true, CertPath: ca.path("client.crt"), KeyPath: ca.path("client.key"), InsecureSkipVerify: true},
+		},
+		{
+			name:        "plaintext",
+			expectError: true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := Config{}
+			cfg.RegisterFlags(flag.NewFlagSet("", flag.PanicOnError))
+			cfg.TLS = tc.tls
+
+			c, err := New(l.Addr().String(), cfg)
+			require.NoError(t, err)
+			defer c.Close()
+
+			ctx, cancel := context.WithTimeout(user.InjectOrgID(context.Background(), "test"), 5*time.Second)
+			defer cancel()
+			_, err = c.Check(ctx, &grpc_health_v1.HealthCheckRequest{})
+			if tc.expectError {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+
+	// certificates are read when dialing
+	cfg := Config{}
+	cfg.RegisterFlags(flag.NewFlagSet("", flag.PanicOnError))
+	cfg.TLS = TLSConfig{Enabled: true, CAPath: ca.path("missing.crt")}
+	_, err = New(l.Addr().String(), cfg)
+	assert.Error(t, err)
+}
@@ -0,0 +1,107 @@
+package client
+
+import (
+	"context"
+	"strconv"
+	"sync"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// Versions of the push request schema.  A request is unmarshalled before any handler or interceptor can inspect it, so
+// a distributor must never send a version the ingester hasn't advertised.  Bump PushVersion when PushRequest changes
+// in a way older ingesters can't decode and keep encoding the older versions until every ingester advertises the new
+// one.
+const (
+	PushVersion1 = 1
+
+	// PushVersion is the newest push request schema this build can send and receive.
+	PushVersion = PushVersion1
+)
+
+// metadataPushVersion carries the schema version of a push request from the distributor and the newest version the
+// ingester supports back in the response header.
+const metadataPushVersion = "x-tempo-push-version"
+
+// InjectPushVersion adds the schema version of the push request to the outgoing gRPC metadata of ctx.
+func InjectPushVersion(ctx context.Context, version int) context.Context {
+	return metadata.AppendToOutgoingContext(ctx, metadataPushVersion, strconv.Itoa(version))
+}
+
+// CheckPushVersion validates the schema version in the incoming gRPC metadata of ctx and advertises PushVersion in the
+// response header.  Requests without a version come from distributors that predate negotiation and use PushVersion1.
+func CheckPushVersion(ctx context.Context) error {
+	// the header is advertised even if the request is rejected so the distributor can downgrade
+	_ = grpc.SetHeader(ctx, metadata.Pairs(metadataPushVersion, strconv.Itoa(PushVersion)))
+
+	version := PushVersion1
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if vals := md.Get(metadataPushVersion); len(vals) > 0 {
+			v, err := strconv.Atoi(vals[0])
+			if err != nil {
+				return status.Errorf(codes.InvalidArgument, "invalid push version %q", vals[0])
+			}
+			version = v
+		}
+	}
+
+	if version < PushVersion1 || version > PushVersion {
+		return status.Errorf(codes.FailedPrecondition, "unsupported push version %d, supported versions %d to %d", version, PushVersion1, PushVersion)
+	}
+
+	return nil
+}
+
+// PushVersions tracks the push schema version negotiated with each ingester.  Ingesters that haven't responded yet are
+// sent PushVersion1 which every ingester can decode.
+type PushVersions struct {
+	mtx      sync.Mutex
+	versions map[string]int
+}
+
+// NewPushVersions creates an empty PushVersions.
+func NewPushVersions() *PushVersions {
+	return &PushVersions{
+		versions: map[string]int{},
+	}
+}
+
+// Get returns the version to encode push requests to the ingester at addr with.
+func (p *PushVersions) Get(addr string) int {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	if v, ok := p.versions[addr]; ok {
+		return v
+	}
+	return PushVersion1
+}
+
+// Observe records the version advertised in the response header of a push to the ingester at addr.  The negotiated
+// version is the lower of the advertised version and PushVersion.  Responses without a version come from ingesters
+// that predate negotiation.
+func (p *PushVersions) Observe(addr string, header metadata.MD) {
+	version := PushVersion1
+	if vals := header.Get(metadataPushVersion); len(vals) > 0 {
+		if v, err := strconv.Atoi(vals[0]); err == nil && v > version {
+			version = v
+		}
+	}
+	if version > PushVersion {
+		version = PushVersion
+	}
+
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	p.versions[addr] = version
+}
+
+// Forget drops the version negotiated with the ingester at addr.
+func (p *PushVersions) Forget(addr string) {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	delete(p.versions, addr)
+}
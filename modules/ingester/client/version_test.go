@@ -0,0 +1,82 @@
+package client
+
+import (
+	"context"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestCheckPushVersion(t *testing.T) {
+	tests := []struct {
+		name string
+		md   metadata.MD
+		code codes.Code
+	}{
+		{
+			name: "no metadata",
+		},
+		{
+			name: "no version",
+			md:   metadata.Pairs("foo", "bar"),
+		},
+		{
+			name: "current version",
+			md:   metadata.Pairs(metadataPushVersion, strconv.Itoa(PushVersion)),
+		},
+		{
+			name: "newer version",
+			md:   metadata.Pairs(metadataPushVersion, strconv.Itoa(PushVersion+1)),
+			code: codes.FailedPrecondition,
+		},
+		{
+			name: "invalid version",
+			md:   metadata.Pairs(metadataPushVersion, "v1"),
+			code: codes.InvalidArgument,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.md != nil {
+				ctx = metadata.NewIncomingContext(ctx, tt.md)
+			}
+
+			err := CheckPushVersion(ctx)
+			if tt.code == codes.OK {
+				assert.NoError(t, err)
+			} else {
+				assert.Equal(t, tt.code, status.Code(err))
+			}
+		})
+	}
+}
+
+func TestPushVersions(t *testing.T) {
+	versions := NewPushVersions()
+	assert.Equal(t, PushVersion1, versions.Get("ingester"))
+
+	// newer ingesters are capped to the version this build speaks
+	versions.Observe("ingester", metadata.Pairs(metadataPushVersion, strconv.Itoa(PushVersion+1)))
+	assert.Equal(t, PushVersion, versions.Get("ingester"))
+
+	// ingesters that predate negotiation don't advertise
+	versions.Observe("old", metadata.MD{})
+	assert.Equal(t, PushVersion1, versions.Get("old"))
+
+	versions.Forget("ingester")
+	assert.Equal(t, PushVersion1, versions.Get("ingester"))
+}
+
+func TestInjectPushVersion(t *testing.T) {
+	ctx := InjectPushVersion(context.Background(), PushVersion)
+
+	md, ok := metadata.FromOutgoingContext(ctx)
+	assert.True(t, ok)
+	assert.Equal(t, []string{strconv.Itoa(PushVersion)}, md.Get(metadataPushVersion))
+}
@@ -7,6 +7,8 @@ import (
 	"github.com/cortexproject/cortex/pkg/ring"
 	"github.com/cortexproject/cortex/pkg/util/flagext"
 	"github.com/grafana/tempo/modules/storage"
+	"github.com/grafana/tempo/pkg/util"
+	"github.com/grafana/tempo/pkg/watermark"
 )
 
 // Config for an ingester.
@@ -16,11 +18,28 @@ type Config struct {
 	ConcurrentFlushes    int           `yaml:"concurrent_flushes"`
 	FlushCheckPeriod     time.Duration `yaml:"flush_check_period"`
 	FlushOpTimeout       time.Duration `yaml:"flush_op_timeout"`
+	FlushRetryMinBackoff time.Duration `yaml:"flush_retry_min_backoff"`
+	FlushRetryMaxBackoff time.Duration `yaml:"flush_retry_max_backoff"`
 	MaxTraceIdle         time.Duration `yaml:"trace_idle_period"`
 	MaxTracesPerBlock    int           `yaml:"traces_per_block"`
 	MaxBlockDuration     time.Duration `yaml:"max_block_duration"`
+	// FloodMinBlockBytes is the size the head block of a tenant flagged for creating too many blocks must reach before
+	// it's cut on traces_per_block.  See storage.trace.blocklist_max_new_blocks
+	FloodMinBlockBytes   int           `yaml:"flood_min_block_bytes"`
 	CompleteBlockTimeout time.Duration `yaml:"complete_block_timeout"`
 	OverrideRingKey      string        `yaml:"override_ring_key"`
+	// UnregisterOnShutdown removes the ingester from the ring when it stops.  Disable it to keep the tokens of ingesters
+	// that are restarted with the same id, e.g. with a wal_dir on a persistent volume.
+	UnregisterOnShutdown bool `yaml:"unregister_on_shutdown"`
+
+	// WALDir is where batches of live traces are recorded so they can be replayed after a crash.  Empty disables it.
+	WALDir           string        `yaml:"wal_dir"`
+	WALFsyncInterval time.Duration `yaml:"wal_fsync_interval"`
+
+	// MaxLiveTraces bounds the live traces of all tenants.  Pushes of new traces are rejected with a retryable error
+	// once it's reached.  0 disables
+	MaxLiveTraces    int              `yaml:"max_live_traces"`
+	MemoryWatermarks watermark.Config `yaml:"memory_watermarks"`
 }
 
 // RegisterFlagsAndApplyDefaults registers the flags.
@@ -29,15 +48,26 @@ func (cfg *Config) RegisterFlagsAndApplyDefaults(prefix string, f *flag.FlagSet)
 	flagext.DefaultValues(&cfg.LifecyclerConfig)
 	cfg.LifecyclerConfig.RingConfig.KVStore.Store = "memberlist"
 	cfg.LifecyclerConfig.RingConfig.ReplicationFactor = 1
-	cfg.LifecyclerConfig.RingConfig.HeartbeatTimeout = 5 * time.Minute
+	f.DurationVar(&cfg.LifecyclerConfig.HeartbeatPeriod, util.PrefixConfig(prefix, "lifecycler.heartbeat-period"), cfg.LifecyclerConfig.HeartbeatPeriod, "Period at which to heartbeat to the ring.")
+	f.DurationVar(&cfg.LifecyclerConfig.ObservePeriod, util.PrefixConfig(prefix, "lifecycler.observe-period"), cfg.LifecyclerConfig.ObservePeriod, "Period to observe tokens after joining the ring to resolve conflicts. 0 to disable.")
+	f.StringVar(&cfg.LifecyclerConfig.Zone, util.PrefixConfig(prefix, "lifecycler.availability-zone"), "", "The availability zone of the ingester. Replicas of a trace are written to ingesters in distinct zones. Empty disables zone awareness.")
+	f.DurationVar(&cfg.LifecyclerConfig.RingConfig.HeartbeatTimeout, util.PrefixConfig(prefix, "lifecycler.ring.heartbeat-timeout"), 5*time.Minute, "The heartbeat timeout after which ingesters are considered unhealthy within the ring.")
 
 	cfg.ConcurrentFlushes = 16
 	cfg.FlushCheckPeriod = 30 * time.Second
 	cfg.FlushOpTimeout = 5 * time.Minute
 
+	f.DurationVar(&cfg.FlushRetryMinBackoff, "ingester.flush-retry-min-backoff", 10*time.Second, "Backoff before retrying a failed flush. Doubles with every consecutive failure of the tenant.")
+	f.DurationVar(&cfg.FlushRetryMaxBackoff, "ingester.flush-retry-max-backoff", 5*time.Minute, "Maximum backoff before retrying a failed flush.")
 	f.DurationVar(&cfg.MaxTraceIdle, "ingester.trace-idle-period", 30*time.Second, "Duration after which to consider a trace complete if no spans have been received")
 	f.IntVar(&cfg.MaxTracesPerBlock, "ingester.traces-per-block", 50000, "Maximum number of traces allowed in the head block before cutting it")
 	f.DurationVar(&cfg.MaxBlockDuration, "ingester.max-block-duration", time.Hour, "Maximum duration which the head block can be appended to before cutting it.")
+	f.IntVar(&cfg.FloodMinBlockBytes, "ingester.flood-min-block-bytes", 100*1024*1024, "Size the head block of a tenant that created more blocks than storage.trace.blocklist-max-new-blocks must reach before it's cut on the number of traces.")
 	f.DurationVar(&cfg.CompleteBlockTimeout, "ingester.complete-block-timeout", storage.DefaultBlocklistPoll, "Duration to keep the headb blocks in the ingester after it has been cut.")
+	f.BoolVar(&cfg.UnregisterOnShutdown, "ingester.unregister-on-shutdown", true, "Remove the ingester from the ring when it shuts down. Shutdowns requested with /shutdown always remove it.")
+	f.StringVar(&cfg.WALDir, "ingester.wal-dir", "", "Directory to record live traces in so they are replayed after a crash. Empty disables the wal.")
+	f.DurationVar(&cfg.WALFsyncInterval, "ingester.wal-fsync-interval", time.Second, "How often the wal is fsynced. 0 fsyncs after every push.")
+	f.IntVar(&cfg.MaxLiveTraces, "ingester.max-live-traces", 0, "Maximum live traces of all tenants. Pushes of new traces are rejected so distributors retry them on other ingesters. 0 to disable.")
+	cfg.MemoryWatermarks.RegisterFlags(util.PrefixConfig(prefix, "memory-watermarks"), f)
 	cfg.OverrideRingKey = ring.IngesterRingKey
 }
@@ -3,7 +3,11 @@ package ingester
 import (
 	"context"
 	"fmt"
+	"hash/fnv"
+	"math/rand"
 	"net/http"
+	"strconv"
+	"sync/atomic"
 	"time"
 
 	"github.com/cortexproject/cortex/pkg/util"
@@ -30,13 +34,25 @@ var (
 		Help:      "Records the amount of time to flush a complete block.",
 		Buckets:   prometheus.ExponentialBuckets(1, 2, 10),
 	})
+	metricFlushRetries = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: "tempo",
+		Name:      "ingester_flush_retries_total",
+		Help:      "The total number of failed flushes that were scheduled to be retried.",
+	})
+	metricFlushRetryQueueLength = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: "tempo",
+		Name:      "ingester_flush_retry_queue_length",
+		Help:      "The number of tenants whose last flush failed and is waiting to be retried.",
+	})
 )
 
-const (
-	// Backoff for retrying 'immediate' flushes. Only counts for queue
-	// position, not wallclock time.
-	flushBackoff = 1 * time.Second
-)
+const flushWaitPollPeriod = 100 * time.Millisecond
+
+// flushRetry tracks the failed flushes of a tenant since its last successful flush.
+type flushRetry struct {
+	attempts int
+	retryAt  time.Time
+}
 
 // Flush triggers a flush of all in memory traces to disk.  This is called
 // by the lifecycler on shutdown and will put our traces in the WAL to be
@@ -52,33 +68,91 @@ func (i *Ingester) Flush() {
 	}
 }
 
-// FlushHandler triggers a flush of all in memory chunks.  Mainly used for
-// local testing.
-func (i *Ingester) FlushHandler(w http.ResponseWriter, _ *http.Request) {
+// FlushHandler cuts all live traces and head blocks and queues every complete block to be flushed, skipping the
+// backoff of tenants whose flushes are failing.  With wait=true it only responds once every block has been flushed,
+// e.g. before a planned scale down, or with 503 if the request is cancelled first.
+func (i *Ingester) FlushHandler(w http.ResponseWriter, r *http.Request) {
+	wait := false
+	if s := r.FormValue("wait"); s != "" {
+		var err error
+		wait, err = strconv.ParseBool(s)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("invalid wait %s: %v", s, err), http.StatusBadRequest)
+			return
+		}
+	}
+
 	i.sweepUsers(true)
+
+	if wait {
+		err := i.waitForFlush(r.Context())
+		if err != nil {
+			http.Error(w, fmt.Sprintf("blocks still waiting to be flushed: %v", err), http.StatusServiceUnavailable)
+			return
+		}
+	}
+
 	w.WriteHeader(http.StatusNoContent)
 }
 
+// waitForFlush returns once no instance is completing a block or holds a complete block that hasn't been flushed.
+// Blocks are completed in the background so those that complete while waiting are queued here.
+func (i *Ingester) waitForFlush(ctx context.Context) error {
+	ticker := time.NewTicker(flushWaitPollPeriod)
+	defer ticker.Stop()
+
+	for {
+		flushed := true
+		for _, instance := range i.getInstances() {
+			if instance.completing() {
+				flushed = false
+				continue
+			}
+			if instance.GetBlockToBeFlushed() != nil {
+				flushed = false
+				if !i.flushBackingOff(instance.instanceID) {
+					i.enqueueFlush(instance.instanceID, time.Now().Unix())
+				}
+			}
+		}
+		if flushed {
+			return nil
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
 type flushOp struct {
-	from   int64
-	userID string
+	from     int64
+	userID   string
+	priority int
 }
 
 func (o *flushOp) Key() string {
 	return o.userID
 }
 
+// Priority orders ops by the tenant's flush priority and then by age.  from is in unix seconds and fits well
+// within 32 bits so the tenant priority always dominates.
 func (o *flushOp) Priority() int64 {
-	return -o.from
+	return int64(o.priority)<<32 - o.from
 }
 
 // sweepUsers periodically schedules series for flushing and garbage collects users with no series
 func (i *Ingester) sweepUsers(immediate bool) {
 	instances := i.getInstances()
 
+	liveTraces := 0
 	for _, instance := range instances {
 		i.sweepInstance(instance, immediate)
+		liveTraces += instance.liveTraceCount()
 	}
+	atomic.StoreInt64(&i.liveTraces, int64(liveTraces))
 }
 
 func (i *Ingester) sweepInstance(instance *instance, immediate bool) {
@@ -89,8 +163,13 @@ func (i *Ingester) sweepInstance(instance *instance, immediate bool) {
 		return
 	}
 
-	// see if it's ready to cut a block?
-	err = instance.CutBlockIfReady(i.cfg.MaxTracesPerBlock, i.cfg.MaxBlockDuration, immediate)
+	// see if it's ready to cut a block?  tenants creating too many blocks keep appending to theirs until it's large
+	// enough to consolidate their writes
+	minBlockBytes := 0
+	if i.store.NewBlocksExceeded(instance.instanceID) {
+		minBlockBytes = i.cfg.FloodMinBlockBytes
+	}
+	err = instance.CutBlockIfReady(i.cfg.MaxTracesPerBlock, minBlockBytes, i.cfg.MaxBlockDuration, immediate)
 	if err != nil {
 		level.Error(util.WithUserID(instance.instanceID, util.Logger)).Log("msg", "failed to cut block", "err", err)
 		return
@@ -102,17 +181,26 @@ func (i *Ingester) sweepInstance(instance *instance, immediate bool) {
 		level.Error(util.WithUserID(instance.instanceID, util.Logger)).Log("msg", "failed to complete block", "err", err)
 	}
 
-	// see if any complete blocks are ready to be flushed
-	if instance.GetBlockToBeFlushed() != nil {
-		i.flushQueueIndex++
-		flushQueueIndex := i.flushQueueIndex % i.cfg.ConcurrentFlushes
-		i.flushQueues[flushQueueIndex].Enqueue(&flushOp{
-			time.Now().Unix(),
-			instance.instanceID,
-		})
+	// see if any complete blocks are ready to be flushed.  tenants whose flushes are failing wait for their retry
+	if instance.GetBlockToBeFlushed() != nil && (immediate || !i.flushBackingOff(instance.instanceID)) {
+		i.enqueueFlush(instance.instanceID, time.Now().Unix())
 	}
 }
 
+// enqueueFlush queues a flush for the tenant.  A tenant always maps to the same queue so its blocks are never
+// flushed by two workers at once and a tenant with a large backlog can only occupy a single flush worker.
+func (i *Ingester) enqueueFlush(userID string, from int64) {
+	hasher := fnv.New32a()
+	_, _ = hasher.Write([]byte(userID))
+	flushQueueIndex := int(hasher.Sum32() % uint32(i.cfg.ConcurrentFlushes))
+
+	i.flushQueues[flushQueueIndex].Enqueue(&flushOp{
+		from:     from,
+		userID:   userID,
+		priority: i.limiter.limits.FlushPriority(userID),
+	})
+}
+
 func (i *Ingester) flushLoop(j int) {
 	defer func() {
 		level.Debug(util.Logger).Log("msg", "Ingester.flushLoop() exited")
@@ -128,47 +216,109 @@ func (i *Ingester) flushLoop(j int) {
 
 		level.Debug(util.Logger).Log("msg", "flushing stream", "userid", op.userID, "fp")
 
-		err := i.flushUserTraces(op.userID)
+		more, err := i.flushUserBlock(op.userID)
 		if err != nil {
-			level.Error(util.WithUserID(op.userID, util.Logger)).Log("msg", "failed to flush user", "err", err)
+			backoff := i.flushFailed(op.userID)
+			level.Error(util.WithUserID(op.userID, util.Logger)).Log("msg", "failed to flush user", "retryIn", backoff, "err", err)
+
+			// the op keeps its age so the retry isn't queued behind newer blocks
+			time.AfterFunc(backoff, func() {
+				i.flushQueues[j].Enqueue(op)
+			})
+			continue
 		}
 
-		if err != nil {
-			op.from += int64(flushBackoff)
-			i.flushQueues[j].Enqueue(op)
+		i.flushSucceeded(op.userID)
+		if more {
+			// requeue behind anything that has been waiting longer so other tenants get a turn
+			i.enqueueFlush(op.userID, time.Now().Unix())
 		}
 	}
 }
 
-func (i *Ingester) flushUserTraces(userID string) error {
+// flushFailed records a failed flush of the tenant and returns how long to wait before retrying it.  The backoff
+// doubles with every consecutive failure, up to the max, and is jittered so the flushes of many tenants against a
+// recovering backend are spread out.
+func (i *Ingester) flushFailed(userID string) time.Duration {
+	i.flushRetriesMtx.Lock()
+	defer i.flushRetriesMtx.Unlock()
+
+	retry, ok := i.flushRetries[userID]
+	if !ok {
+		retry = &flushRetry{}
+		i.flushRetries[userID] = retry
+	}
+	retry.attempts++
+
+	backoff := i.cfg.FlushRetryMinBackoff
+	for n := 1; n < retry.attempts && backoff < i.cfg.FlushRetryMaxBackoff; n++ {
+		backoff *= 2
+	}
+	if backoff > i.cfg.FlushRetryMaxBackoff {
+		backoff = i.cfg.FlushRetryMaxBackoff
+	}
+	if backoff > 0 {
+		backoff = backoff/2 + time.Duration(rand.Int63n(int64(backoff/2)+1))
+	}
+	retry.retryAt = time.Now().Add(backoff)
+
+	metricFlushRetries.Inc()
+	metricFlushRetryQueueLength.Set(float64(len(i.flushRetries)))
+	return backoff
+}
+
+func (i *Ingester) flushSucceeded(userID string) {
+	i.flushRetriesMtx.Lock()
+	defer i.flushRetriesMtx.Unlock()
+
+	delete(i.flushRetries, userID)
+	metricFlushRetryQueueLength.Set(float64(len(i.flushRetries)))
+}
+
+// flushBackingOff returns true if the tenant's last flush failed and its retry isn't due yet.
+func (i *Ingester) flushBackingOff(userID string) bool {
+	i.flushRetriesMtx.Lock()
+	defer i.flushRetriesMtx.Unlock()
+
+	retry, ok := i.flushRetries[userID]
+	return ok && time.Now().Before(retry.retryAt)
+}
+
+// flushUserBlock flushes a single complete block for the tenant and returns true if more blocks are waiting.
+func (i *Ingester) flushUserBlock(userID string) (bool, error) {
 	instance, err := i.getOrCreateInstance(userID)
 	if err != nil {
-		return err
+		return false, err
 	}
 
 	if instance == nil {
-		return fmt.Errorf("instance id %s not found", userID)
+		return false, fmt.Errorf("instance id %s not found", userID)
 	}
 
-	for {
-		block := instance.GetBlockToBeFlushed()
-		if block == nil {
-			break
-		}
+	block := instance.GetBlockToBeFlushed()
+	if block == nil {
+		return false, nil
+	}
 
-		ctx := user.InjectOrgID(context.Background(), userID)
-		ctx, cancel := context.WithTimeout(ctx, i.cfg.FlushOpTimeout)
-		defer cancel()
+	ctx := user.InjectOrgID(context.Background(), userID)
+	ctx, cancel := context.WithTimeout(ctx, i.cfg.FlushOpTimeout)
+	defer cancel()
 
-		start := time.Now()
-		err = i.store.WriteBlock(ctx, block)
-		metricFlushDuration.Observe(time.Since(start).Seconds())
-		if err != nil {
-			metricFailedFlushes.Inc()
-			return err
-		}
-		metricBlocksFlushed.Inc()
+	block.BlockMeta().Labels = i.limiter.limits.CostAttributionLabels(userID)
+
+	start := time.Now()
+	err = i.store.WriteBlock(ctx, block)
+	metricFlushDuration.Observe(time.Since(start).Seconds())
+	if err != nil {
+		metricFailedFlushes.Inc()
+		return false, err
+	}
+	metricBlocksFlushed.Inc()
+
+	err = i.truncateTraceWAL()
+	if err != nil {
+		level.Error(util.Logger).Log("msg", "failed to truncate trace wal", "err", err)
 	}
 
-	return nil
+	return instance.GetBlockToBeFlushed() != nil, nil
 }
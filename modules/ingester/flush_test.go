@@ -0,0 +1,49 @@
+package ingester
+
+import (
+	"testing"
+	"time"
+
+	"github.com/cortexproject/cortex/pkg/util"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFlushOpPriority(t *testing.T) {
+	pq := util.NewPriorityQueue(nil)
+
+	pq.Enqueue(&flushOp{from: 200, userID: "new"})
+	pq.Enqueue(&flushOp{from: 100, userID: "old"})
+	pq.Enqueue(&flushOp{from: 300, userID: "important", priority: 1})
+	pq.Enqueue(&flushOp{from: 50, userID: "unimportant", priority: -1})
+
+	for _, expected := range []string{"important", "old", "new", "unimportant"} {
+		op := pq.Dequeue().(*flushOp)
+		assert.Equal(t, expected, op.userID)
+	}
+}
+
+func TestFlushRetryBackoff(t *testing.T) {
+	i := &Ingester{
+		cfg: Config{
+			FlushRetryMinBackoff: time.Second,
+			FlushRetryMaxBackoff: 5 * time.Second,
+		},
+		flushRetries: map[string]*flushRetry{},
+	}
+
+	assert.False(t, i.flushBackingOff("test"))
+
+	// the backoff doubles and is jittered down to half of it
+	for _, expected := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second} {
+		backoff := i.flushFailed("test")
+		assert.GreaterOrEqual(t, int64(backoff), int64(expected/2))
+		assert.LessOrEqual(t, int64(backoff), int64(expected))
+		assert.True(t, i.flushBackingOff("test"))
+	}
+	assert.False(t, i.flushBackingOff("other"))
+
+	i.flushSucceeded("test")
+	assert.False(t, i.flushBackingOff("test"))
+	backoff := i.flushFailed("test")
+	assert.LessOrEqual(t, int64(backoff), int64(time.Second))
+}
@@ -5,23 +5,31 @@ import (
 	"errors"
 	"fmt"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/go-kit/kit/log/level"
+	"github.com/gogo/protobuf/proto"
+	"github.com/gogo/status"
 	"github.com/opentracing/opentracing-go"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
 	"github.com/weaveworks/common/user"
+	"google.golang.org/grpc/codes"
 
 	"github.com/cortexproject/cortex/pkg/ring"
 	"github.com/cortexproject/cortex/pkg/util"
 	"github.com/cortexproject/cortex/pkg/util/services"
 
+	"github.com/grafana/tempo/modules/ingester/client"
 	"github.com/grafana/tempo/modules/overrides"
 	"github.com/grafana/tempo/modules/storage"
 	"github.com/grafana/tempo/pkg/tempopb"
+	tempo_util "github.com/grafana/tempo/pkg/util"
 	"github.com/grafana/tempo/pkg/validation"
+	"github.com/grafana/tempo/pkg/watermark"
 	tempodb_wal "github.com/grafana/tempo/tempodb/wal"
+	v1_trace "github.com/open-telemetry/opentelemetry-proto/gen/go/trace/v1"
 )
 
 // ErrReadOnly is returned when the ingester is shutting down and a push was
@@ -44,15 +52,31 @@ type Ingester struct {
 	instances    map[string]*instance
 	readonly     bool
 
+	readOnlyMtx   sync.Mutex
+	readOnlySince time.Time
+
+	shutdownOnce      sync.Once
+	shutdownRequested chan struct{}
+
 	lifecycler *ring.Lifecycler
 	store      storage.Store
 
 	// One queue per flush thread.
 	flushQueues     []*util.PriorityQueue
-	flushQueueIndex int
 	flushQueuesDone sync.WaitGroup
 
-	limiter *Limiter
+	flushRetriesMtx sync.Mutex
+	flushRetries    map[string]*flushRetry
+
+	limiter  *Limiter
+	traceWAL *traceWAL // nil if disabled
+
+	watermarks *watermark.Guard // nil if disabled
+
+	// live traces across every tenant as of the last sweep, advertised to the distributors as a load hint
+	liveTraces int64
+
+	tails *tails
 
 	subservicesWatcher *services.FailureWatcher
 }
@@ -60,10 +84,15 @@ type Ingester struct {
 // New makes a new Ingester.
 func New(cfg Config, store storage.Store, limits *overrides.Overrides) (*Ingester, error) {
 	i := &Ingester{
-		cfg:         cfg,
-		instances:   map[string]*instance{},
-		store:       store,
-		flushQueues: make([]*util.PriorityQueue, cfg.ConcurrentFlushes),
+		cfg:          cfg,
+		instances:    map[string]*instance{},
+		store:        store,
+		flushQueues:  make([]*util.PriorityQueue, cfg.ConcurrentFlushes),
+		flushRetries: map[string]*flushRetry{},
+		watermarks:   watermark.New(cfg.MemoryWatermarks, "ingester"),
+		tails:        newTails(),
+
+		shutdownRequested: make(chan struct{}),
 	}
 
 	i.flushQueuesDone.Add(cfg.ConcurrentFlushes)
@@ -73,6 +102,14 @@ func New(cfg Config, store storage.Store, limits *overrides.Overrides) (*Ingeste
 	}
 
 	var err error
+	if cfg.WALDir != "" {
+		i.traceWAL, err = newTraceWAL(cfg.WALDir, cfg.WALFsyncInterval)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open wal %w", err)
+		}
+	}
+
+	cfg.LifecyclerConfig.SkipUnregister = !cfg.UnregisterOnShutdown
 	i.lifecycler, err = ring.NewLifecycler(cfg.LifecyclerConfig, i, "ingester", cfg.OverrideRingKey, true, prometheus.DefaultRegisterer)
 	if err != nil {
 		return nil, fmt.Errorf("NewLifecycler failed %w", err)
@@ -81,6 +118,7 @@ func New(cfg Config, store storage.Store, limits *overrides.Overrides) (*Ingeste
 	// Now that the lifecycler has been created, we can create the limiter
 	// which depends on it.
 	i.limiter = NewLimiter(limits, i.lifecycler, cfg.LifecyclerConfig.RingConfig.ReplicationFactor)
+	i.limiter.maxLiveTraces = cfg.MaxLiveTraces
 
 	i.subservicesWatcher = services.NewFailureWatcher()
 	i.subservicesWatcher.WatchService(i.lifecycler)
@@ -104,6 +142,11 @@ func (i *Ingester) starting(ctx context.Context) error {
 		return fmt.Errorf("failed to replay wal %w", err)
 	}
 
+	err = i.replayTraceWAL()
+	if err != nil {
+		return fmt.Errorf("failed to replay trace wal %w", err)
+	}
+
 	return nil
 }
 
@@ -114,8 +157,20 @@ func (i *Ingester) loop(ctx context.Context) error {
 	for {
 		select {
 		case <-flushTicker.C:
+			if readOnly, since := i.isReadOnly(); readOnly {
+				if i.sweepReadOnly(since) {
+					level.Info(util.Logger).Log("msg", "read only ingester has flushed all blocks.  exiting")
+					return util.ErrStopProcess
+				}
+				continue
+			}
+
 			i.sweepUsers(false)
 
+		case <-i.shutdownRequested:
+			level.Info(util.Logger).Log("msg", "ingester has flushed all blocks for the requested shutdown.  exiting")
+			return util.ErrStopProcess
+
 		case <-ctx.Done():
 			return nil
 
@@ -129,14 +184,26 @@ func (i *Ingester) loop(ctx context.Context) error {
 func (i *Ingester) stopping(_ error) error {
 	// This will prevent us accepting any more samples
 	i.stopIncomingRequests()
+	i.tails.close()
 
 	// Lifecycler can be nil if the ingester is for a flusher.
 	if i.lifecycler != nil {
 		// Next initiate our graceful exit from the ring.
-		return services.StopAndAwaitTerminated(context.Background(), i.lifecycler)
+		err := services.StopAndAwaitTerminated(context.Background(), i.lifecycler)
+		if err != nil {
+			return err
+		}
+
+		// a requested shutdown always leaves the ring
+		if !i.cfg.UnregisterOnShutdown && i.isShutdownRequested() {
+			err = i.unregister(context.Background())
+			if err != nil {
+				return fmt.Errorf("failed to unregister from the ring %w", err)
+			}
+		}
 	}
 
-	return nil
+	return i.closeTraceWAL()
 }
 
 // Push implements tempopb.Pusher.
@@ -148,12 +215,28 @@ func (i *Ingester) Push(ctx context.Context, req *tempopb.PushRequest) (*tempopb
 		return nil, ErrReadOnly
 	}
 
+	if err := client.CheckPushVersion(ctx); err != nil {
+		return nil, err
+	}
+
+	if i.limiter.BestEffort(instanceID) && i.watermarks.RejectPush() {
+		return nil, status.Errorf(codes.ResourceExhausted, "ingester memory above push watermark, rejecting push for best effort tenant %s", instanceID)
+	}
+	if i.watermarks.RejectAnyPush() {
+		return nil, status.Error(codes.ResourceExhausted, "ingester memory above push limit, rejecting push")
+	}
+
 	instance, err := i.getOrCreateInstance(instanceID)
 	if err != nil {
 		return nil, err
 	}
 
+	client.AdvertiseLoad(ctx, int(atomic.LoadInt64(&i.liveTraces)))
+
 	err = instance.Push(ctx, req)
+	if err == nil {
+		i.tails.publish(instanceID, req.Batch)
+	}
 	return &tempopb.PushResponse{}, err
 }
 
@@ -181,11 +264,74 @@ func (i *Ingester) FindTraceByID(ctx context.Context, req *tempopb.TraceByIDRequ
 		return nil, err
 	}
 
+	// spans received within the trace idle period mean the trace may still be growing
+	var incomplete bool
+	if lastSeen, ok := inst.TraceLastSeen(req.TraceID); ok && trace != nil {
+		incomplete = time.Since(lastSeen) < i.cfg.MaxTraceIdle
+	}
+
 	return &tempopb.TraceByIDResponse{
-		Trace: trace,
+		Trace:      trace,
+		Incomplete: incomplete,
 	}, nil
 }
 
+// FindTraceByIDStream implements tempopb.Querier.  The trace is sent in chunks of batches.
+func (i *Ingester) FindTraceByIDStream(req *tempopb.TraceByIDRequest, stream tempopb.Querier_FindTraceByIDStreamServer) error {
+	resp, err := i.FindTraceByID(stream.Context(), req)
+	if err != nil {
+		return err
+	}
+
+	return tempo_util.SendTraceInChunks(resp, stream.Send)
+}
+
+// Search implements tempopb.Querier.  It searches live traces and the blocks not yet cleared from the ingester.
+func (i *Ingester) Search(ctx context.Context, req *tempopb.SearchRequest) (*tempopb.SearchResponse, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "ingester.Search")
+	defer span.Finish()
+
+	instanceID, err := user.ExtractOrgID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if i.watermarks.RejectSearch() {
+		return nil, status.Error(codes.ResourceExhausted, "ingester memory above search watermark, rejecting search")
+	}
+
+	inst, ok := i.getInstanceByID(instanceID)
+	if !ok || inst == nil {
+		return &tempopb.SearchResponse{}, nil
+	}
+
+	found := inst.Search(tempo_util.SearchQueryFromRequest(req))
+	resp := &tempopb.SearchResponse{
+		Traces: make([]*tempopb.TraceSearchMetadata, 0, len(found)),
+	}
+	for idx := range found {
+		resp.Traces = append(resp.Traces, tempo_util.SearchMetadata(&found[idx]))
+	}
+
+	return resp, nil
+}
+
+// Overview implements tempopb.Querier.  It summarizes the spans pushed to the ingester for the tenant over roughly the
+// last hour per service.
+func (i *Ingester) Overview(ctx context.Context, _ *tempopb.OverviewRequest) (*tempopb.OverviewResponse, error) {
+	instanceID, err := user.ExtractOrgID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	inst, ok := i.getInstanceByID(instanceID)
+	if !ok || inst == nil {
+		return &tempopb.OverviewResponse{}, nil
+	}
+
+	return inst.overview.overview(time.Now()), nil
+}
+
 func (i *Ingester) CheckReady(ctx context.Context) error {
 	if err := i.lifecycler.CheckReady(ctx); err != nil {
 		return fmt.Errorf("ingester check ready failed %w", err)
@@ -209,6 +355,7 @@ func (i *Ingester) getOrCreateInstance(instanceID string) (*instance, error) {
 		if err != nil {
 			return nil, err
 		}
+		inst.traceWAL = i.traceWAL
 		i.instances[instanceID] = inst
 	}
 	return inst, nil
@@ -302,3 +449,64 @@ func (i *Ingester) replayBlock(b *tempodb_wal.ReplayBlock, instance *instance) e
 
 	return nil
 }
+
+// replayTraceWAL restores the live traces recorded before a restart.  It runs after the head blocks are replayed so
+// batches of traces that were cut before the restart are combined with them when the block is completed.
+func (i *Ingester) replayTraceWAL() error {
+	if i.traceWAL == nil {
+		return nil
+	}
+
+	level.Info(util.Logger).Log("msg", "beginning trace wal replay")
+
+	return i.traceWAL.Replay(func(segment int, tenantID string, traceID []byte, b []byte) error {
+		batch := &v1_trace.ResourceSpans{}
+		err := proto.Unmarshal(b, batch)
+		if err != nil {
+			// the record passed its checksum so skip it rather than the rest of the wal
+			level.Error(util.Logger).Log("msg", "failed to unmarshal batch in trace wal", "tenantID", tenantID, "err", err)
+			return nil
+		}
+
+		instance, err := i.getOrCreateInstance(tenantID)
+		if err != nil {
+			return err
+		}
+
+		return instance.replayPush(traceID, batch, segment)
+	})
+}
+
+// truncateTraceWAL removes the trace wal segments that no longer hold batches of live traces.  Traces that have been cut
+// are in the head blocks, which have their own wal.
+func (i *Ingester) truncateTraceWAL() error {
+	if i.traceWAL == nil {
+		return nil
+	}
+
+	keep := i.traceWAL.Segment()
+	for _, instance := range i.getInstances() {
+		if oldest := instance.oldestWALSegment(); oldest >= 0 && oldest < keep {
+			keep = oldest
+		}
+	}
+
+	return i.traceWAL.Truncate(keep)
+}
+
+// closeTraceWAL closes the trace wal on shutdown.  Live traces are cut by Flush so usually every segment is removed.
+func (i *Ingester) closeTraceWAL() error {
+	if i.traceWAL == nil {
+		return nil
+	}
+
+	err := i.traceWAL.Rotate()
+	if err == nil {
+		err = i.truncateTraceWAL()
+	}
+	if err != nil {
+		level.Error(util.Logger).Log("msg", "failed to truncate trace wal on shutdown", "err", err)
+	}
+
+	return i.traceWAL.Close()
+}
@@ -2,23 +2,32 @@ package ingester
 
 import (
 	"context"
+	"io"
 	"io/ioutil"
 	"math/rand"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"path"
 	"testing"
 	"time"
 
 	"github.com/cortexproject/cortex/pkg/ring"
 	"github.com/cortexproject/cortex/pkg/ring/kv/consul"
+	"github.com/cortexproject/cortex/pkg/util"
 	"github.com/cortexproject/cortex/pkg/util/flagext"
 	"github.com/go-kit/kit/log"
 	"github.com/golang/protobuf/proto"
 	"github.com/stretchr/testify/assert"
 	"github.com/weaveworks/common/user"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 
 	"github.com/grafana/tempo/modules/overrides"
 	"github.com/grafana/tempo/modules/storage"
 	"github.com/grafana/tempo/pkg/tempopb"
+	tempo_util "github.com/grafana/tempo/pkg/util"
 	"github.com/grafana/tempo/pkg/util/test"
 	"github.com/grafana/tempo/tempodb"
 	"github.com/grafana/tempo/tempodb/backend/local"
@@ -58,6 +67,57 @@ func TestPushQuery(t *testing.T) {
 	}
 }
 
+type traceStream struct {
+	grpc.ServerStream
+	ctx       context.Context
+	responses []*tempopb.TraceByIDResponse
+}
+
+func (s *traceStream) Context() context.Context { return s.ctx }
+
+func (s *traceStream) Send(resp *tempopb.TraceByIDResponse) error {
+	s.responses = append(s.responses, resp)
+	return nil
+}
+
+func (s *traceStream) Recv() (*tempopb.TraceByIDResponse, error) {
+	if len(s.responses) == 0 {
+		return nil, io.EOF
+	}
+	resp := s.responses[0]
+	s.responses = s.responses[1:]
+	return resp, nil
+}
+
+func TestFindTraceByIDStream(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("/tmp", "")
+	assert.NoError(t, err, "unexpected error getting tempdir")
+	defer os.RemoveAll(tmpDir)
+
+	ctx := user.InjectOrgID(context.Background(), "test")
+	ingester, traces, traceIDs := defaultIngester(t, tmpDir)
+
+	for pos, traceID := range traceIDs {
+		stream := &traceStream{ctx: ctx}
+		err := ingester.FindTraceByIDStream(&tempopb.TraceByIDRequest{
+			TraceID: traceID,
+		}, stream)
+		assert.NoError(t, err, "unexpected error querying")
+
+		foundTrace, err := tempo_util.ReceiveTrace(stream)
+		assert.NoError(t, err)
+		assert.Equal(t, traces[pos], foundTrace.Trace)
+	}
+
+	// unknown traces send nothing
+	stream := &traceStream{ctx: ctx}
+	err = ingester.FindTraceByIDStream(&tempopb.TraceByIDRequest{
+		TraceID: make([]byte, 16),
+	}, stream)
+	assert.NoError(t, err)
+	assert.Empty(t, stream.responses)
+}
+
 func TestFullTraceReturned(t *testing.T) {
 	tmpDir, err := ioutil.TempDir("/tmp", "")
 	assert.NoError(t, err, "unexpected error getting tempdir")
@@ -106,6 +166,48 @@ func TestFullTraceReturned(t *testing.T) {
 	assert.True(t, equal)
 }
 
+func TestTraceIncomplete(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("/tmp", "")
+	assert.NoError(t, err, "unexpected error getting tempdir")
+	defer os.RemoveAll(tmpDir)
+
+	ctx := user.InjectOrgID(context.Background(), "test")
+	ingester, _, traceIDs := defaultIngester(t, tmpDir)
+	req := &tempopb.TraceByIDRequest{
+		TraceID: traceIDs[0],
+	}
+
+	// spans were pushed within the trace idle period
+	foundTrace, err := ingester.FindTraceByID(ctx, req)
+	assert.NoError(t, err)
+	assert.True(t, foundTrace.Incomplete)
+
+	// cutting the trace to the head block doesn't reset the last seen time
+	for _, instance := range ingester.instances {
+		err = instance.CutCompleteTraces(ingester.cfg.MaxTraceIdle, false)
+		assert.NoError(t, err, "unexpected error cutting traces")
+	}
+	foundTrace, err = ingester.FindTraceByID(ctx, req)
+	assert.NoError(t, err)
+	assert.True(t, foundTrace.Incomplete)
+
+	for _, instance := range ingester.instances {
+		err = instance.CutCompleteTraces(0, true)
+		assert.NoError(t, err, "unexpected error cutting traces")
+	}
+	foundTrace, err = ingester.FindTraceByID(ctx, req)
+	assert.NoError(t, err)
+	assert.NotNil(t, foundTrace.Trace)
+	assert.False(t, foundTrace.Incomplete)
+
+	// unknown traces aren't incomplete
+	foundTrace, err = ingester.FindTraceByID(ctx, &tempopb.TraceByIDRequest{
+		TraceID: make([]byte, 16),
+	})
+	assert.NoError(t, err)
+	assert.False(t, foundTrace.Incomplete)
+}
+
 func TestWal(t *testing.T) {
 	tmpDir, err := ioutil.TempDir("/tmp", "")
 	assert.NoError(t, err, "unexpected error getting tempdir")
@@ -142,6 +244,72 @@ func TestWal(t *testing.T) {
 	}
 }
 
+func TestTraceWAL(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("/tmp", "")
+	assert.NoError(t, err, "unexpected error getting tempdir")
+	defer os.RemoveAll(tmpDir)
+
+	cfg := defaultIngesterTestConfig()
+	cfg.WALDir = path.Join(tmpDir, "trace-wal")
+	cfg.WALFsyncInterval = time.Hour
+
+	ingester, traces, traceIDs := ingesterWithConfig(t, tmpDir, cfg)
+	assert.Len(t, ingester.instances["test"].traces, len(traces))
+
+	// create new ingester without stopping the old one.  the live traces should be replayed
+	ingester, _, _ = ingesterWithConfig(t, tmpDir, cfg)
+
+	inst := ingester.instances["test"]
+	for i, traceID := range traceIDs {
+		liveTrace, ok := inst.traces[tempo_util.TokenForTraceID(traceID)]
+		if assert.True(t, ok) {
+			assert.True(t, proto.Equal(traces[i], liveTrace.trace))
+		}
+	}
+
+	// segments are removed once their traces are cut
+	segments, err := ingester.traceWAL.segments()
+	assert.NoError(t, err)
+	assert.Len(t, segments, 2)
+
+	for _, instance := range ingester.instances {
+		err := instance.CutCompleteTraces(0, true)
+		assert.NoError(t, err, "unexpected error cutting traces")
+	}
+	assert.NoError(t, ingester.truncateTraceWAL())
+
+	segments, err = ingester.traceWAL.segments()
+	assert.NoError(t, err)
+	assert.Equal(t, []int{1}, segments)
+}
+
+func TestMemoryWatermarks(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("/tmp", "")
+	assert.NoError(t, err, "unexpected error getting tempdir")
+	defer os.RemoveAll(tmpDir)
+
+	// every heap is above a watermark of one byte
+	cfg := defaultIngesterTestConfig()
+	cfg.MemoryWatermarks.SearchBytes = 1
+	cfg.MemoryWatermarks.PushBytes = 1
+
+	// pushes of tenants that aren't best effort are accepted
+	ingester, traces, _ := ingesterWithConfig(t, tmpDir, cfg)
+	ctx := user.InjectOrgID(context.Background(), "test")
+
+	_, err = ingester.Search(ctx, &tempopb.SearchRequest{})
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+
+	limits := defaultLimitsTestConfig()
+	limits.BestEffort = true
+	o, err := overrides.NewOverrides(limits)
+	assert.NoError(t, err)
+	ingester.limiter = NewLimiter(o, ingester.lifecycler, 1)
+
+	_, err = ingester.Push(ctx, &tempopb.PushRequest{Batch: traces[0].Batches[0]})
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+}
+
 func TestFlush(t *testing.T) {
 	tmpDir, err := ioutil.TempDir("/tmp", "")
 	assert.NoError(t, err, "unexpected error getting tempdir")
@@ -176,8 +344,99 @@ func TestFlush(t *testing.T) {
 	}
 }
 
+func TestFlushHandler(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("/tmp", "")
+	assert.NoError(t, err, "unexpected error getting tempdir")
+	defer os.RemoveAll(tmpDir)
+
+	ingester, _, _ := defaultIngester(t, tmpDir)
+
+	rec := httptest.NewRecorder()
+	ingester.FlushHandler(rec, httptest.NewRequest(http.MethodPost, "/flush?wait=maybe", nil))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec = httptest.NewRecorder()
+	ingester.FlushHandler(rec, httptest.NewRequest(http.MethodPost, "/flush?wait=true", nil))
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+
+	// every live trace was cut into a block that has been flushed
+	inst, ok := ingester.getInstanceByID("test")
+	assert.True(t, ok)
+	assert.Nil(t, inst.GetBlockToBeFlushed())
+	assert.Len(t, inst.completeBlocks, 1)
+	assert.False(t, inst.completeBlocks[0].FlushedTime().IsZero())
+}
+
+func TestShutdownHandler(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("/tmp", "")
+	assert.NoError(t, err, "unexpected error getting tempdir")
+	defer os.RemoveAll(tmpDir)
+
+	cfg := defaultIngesterTestConfig()
+	cfg.UnregisterOnShutdown = false
+	ingester, _, _ := ingesterWithConfig(t, tmpDir, cfg)
+
+	rec := httptest.NewRecorder()
+	ingester.ShutdownHandler(rec, httptest.NewRequest(http.MethodGet, "/shutdown", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+	assert.False(t, ingester.isShutdownRequested())
+
+	rec = httptest.NewRecorder()
+	ingester.ShutdownHandler(rec, httptest.NewRequest(http.MethodPost, "/shutdown", nil))
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+	assert.True(t, ingester.isShutdownRequested())
+	assert.Equal(t, ring.LEAVING, ingester.lifecycler.GetState())
+
+	inst, ok := ingester.getInstanceByID("test")
+	assert.True(t, ok)
+	assert.Nil(t, inst.GetBlockToBeFlushed())
+	assert.Len(t, inst.completeBlocks, 1)
+
+	// the loop exits and the ingester leaves the ring although unregister on shutdown is disabled
+	assert.Equal(t, util.ErrStopProcess, ingester.loop(context.Background()))
+	assert.NoError(t, ingester.stopping(nil))
+
+	desc, err := ingester.lifecycler.KVStore.Get(context.Background(), cfg.OverrideRingKey)
+	assert.NoError(t, err)
+	assert.NotContains(t, desc.(*ring.Desc).Ingesters, cfg.LifecyclerConfig.ID)
+}
+
+func TestReadOnly(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("/tmp", "")
+	assert.NoError(t, err, "unexpected error getting tempdir")
+	defer os.RemoveAll(tmpDir)
+
+	ctx := user.InjectOrgID(context.Background(), "test")
+	ingester, traces, traceIDs := defaultIngester(t, tmpDir)
+	ingester.cfg.CompleteBlockTimeout = 0
+
+	err = ingester.enterReadOnly(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, ring.LEAVING, ingester.lifecycler.GetState())
+
+	readOnly, since := ingester.isReadOnly()
+	assert.True(t, readOnly)
+
+	// still serving queries
+	for pos, traceID := range traceIDs {
+		foundTrace, err := ingester.FindTraceByID(ctx, &tempopb.TraceByIDRequest{
+			TraceID: traceID,
+		})
+		assert.NoError(t, err, "unexpected error querying")
+		assert.Equal(t, foundTrace.Trace, traces[pos])
+	}
+
+	// everything is eventually flushed and cleared
+	assert.Eventually(t, func() bool {
+		return ingester.sweepReadOnly(since)
+	}, 10*time.Second, 10*time.Millisecond)
+}
+
 func defaultIngester(t *testing.T, tmpDir string) (*Ingester, []*tempopb.Trace, [][]byte) {
-	ingesterConfig := defaultIngesterTestConfig()
+	return ingesterWithConfig(t, tmpDir, defaultIngesterTestConfig())
+}
+
+func ingesterWithConfig(t *testing.T, tmpDir string, ingesterConfig Config) (*Ingester, []*tempopb.Trace, [][]byte) {
 	limits, err := overrides.NewOverrides(defaultLimitsTestConfig())
 	assert.NoError(t, err, "unexpected error creating overrides")
 
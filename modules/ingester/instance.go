@@ -16,10 +16,13 @@ import (
 	"github.com/prometheus/client_golang/prometheus/promauto"
 	"google.golang.org/grpc/codes"
 
+	"github.com/grafana/tempo/modules/ingester/client"
 	"github.com/grafana/tempo/pkg/tempopb"
+	"github.com/grafana/tempo/pkg/tenantmetrics"
 	"github.com/grafana/tempo/pkg/util"
 	tempodb_encoding "github.com/grafana/tempo/tempodb/encoding"
 	tempodb_wal "github.com/grafana/tempo/tempodb/wal"
+	v1_trace "github.com/open-telemetry/opentelemetry-proto/gen/go/trace/v1"
 )
 
 // Errors returned on Query.
@@ -28,7 +31,7 @@ var (
 )
 
 var (
-	metricTracesCreatedTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+	metricTracesCreatedTotal = tenantmetrics.NewCounterVec(prometheus.CounterOpts{
 		Namespace: "tempo",
 		Name:      "ingester_traces_created_total",
 		Help:      "The total number of traces created per tenant.",
@@ -43,22 +46,31 @@ var (
 type instance struct {
 	tracesMtx sync.Mutex
 	traces    map[uint32]*trace
+	// lastSeen is when spans of each trace were last pushed.  Unlike traces it outlives cutting the trace to the head
+	// block until the trace has been idle for the trace idle period.
+	lastSeen map[uint32]time.Time
 
 	blocksMtx       sync.RWMutex
 	headBlock       *tempodb_wal.AppendBlock
 	completingBlock *tempodb_wal.AppendBlock
 	completeBlocks  []*tempodb_wal.CompleteBlock
 	lastBlockCut    time.Time
+	attributeKeys   *blockAttributeKeys
+	overview        *recentOverview
 
 	instanceID         string
 	tracesCreatedTotal prometheus.Counter
 	limiter            *Limiter
 	wal                *tempodb_wal.WAL
+	traceWAL           *traceWAL // nil if live traces aren't recorded
 }
 
 func newInstance(instanceID string, limiter *Limiter, wal *tempodb_wal.WAL) (*instance, error) {
 	i := &instance{
-		traces: map[uint32]*trace{},
+		traces:        map[uint32]*trace{},
+		lastSeen:      map[uint32]time.Time{},
+		attributeKeys: newBlockAttributeKeys(),
+		overview:      newRecentOverview(time.Now()),
 
 		instanceID:         instanceID,
 		tracesCreatedTotal: metricTracesCreatedTotal.WithLabelValues(instanceID),
@@ -76,23 +88,83 @@ func (i *instance) Push(ctx context.Context, req *tempopb.PushRequest) error {
 	i.tracesMtx.Lock()
 	defer i.tracesMtx.Unlock()
 
-	trace, err := i.getOrCreateTrace(req)
+	trace, err := i.getOrCreateTrace(ctx, req)
 	if err != nil {
 		return err
 	}
 
-	if err := trace.Push(ctx, req); err != nil {
+	maxKeys := i.limiter.MaxAttributeKeysPerBlock(ctx, i.instanceID)
+	if dropped := i.attributeKeys.filter(req, maxKeys); dropped > 0 {
+		metricAttributesDropped.WithLabelValues(i.instanceID).Add(float64(dropped))
+	}
+
+	maxSpans := i.limiter.MaxSpansPerTrace(ctx, i.instanceID)
+	maxBytes := i.limiter.MaxBytesPerTrace(ctx, i.instanceID)
+	if err := trace.Push(ctx, req, maxSpans, maxBytes); err != nil {
 		return err
 	}
+	i.lastSeen[trace.token] = trace.lastAppend
+	i.overview.record(req, time.Now())
+
+	if i.traceWAL != nil {
+		batch, err := proto.Marshal(req.Batch)
+		if err != nil {
+			return err
+		}
+		segment, err := i.traceWAL.Append(i.instanceID, trace.traceID, batch)
+		if err != nil {
+			return status.Errorf(codes.Internal, "failed to write wal: %v", err)
+		}
+		if trace.walSegment < 0 {
+			trace.walSegment = segment
+		}
+	}
 
 	return nil
 }
 
+// replayPush restores a batch of a live trace from the trace wal.  Limits aren't applied since the batch was accepted
+// before the restart.
+func (i *instance) replayPush(traceID []byte, batch *v1_trace.ResourceSpans, segment int) error {
+	i.tracesMtx.Lock()
+	defer i.tracesMtx.Unlock()
+
+	fp := util.TokenForTraceID(traceID)
+	trace, ok := i.traces[fp]
+	if !ok {
+		trace = newTrace(fp, traceID)
+		trace.walSegment = segment
+		i.traces[fp] = trace
+		i.tracesCreatedTotal.Inc()
+		i.limiter.liveTraces.Inc()
+	}
+
+	return trace.Push(context.Background(), &tempopb.PushRequest{Batch: batch}, 0, 0)
+}
+
+// oldestWALSegment returns the oldest trace wal segment holding a batch of a live trace or -1 if there is none.
+func (i *instance) oldestWALSegment() int {
+	i.tracesMtx.Lock()
+	defer i.tracesMtx.Unlock()
+
+	oldest := -1
+	for _, trace := range i.traces {
+		if trace.walSegment >= 0 && (oldest < 0 || trace.walSegment < oldest) {
+			oldest = trace.walSegment
+		}
+	}
+	return oldest
+}
+
 // PushBytes is used by the wal replay code and so it can push directly into the head block with 0 shenanigans
 func (i *instance) PushBytes(ctx context.Context, id tempodb_encoding.ID, object []byte) error {
 	i.tracesMtx.Lock()
 	defer i.tracesMtx.Unlock()
 
+	// the head block is read by queries under the blocks lock
+	i.blocksMtx.Lock()
+	defer i.blocksMtx.Unlock()
+
 	return i.headBlock.Write(id, object)
 }
 
@@ -116,15 +188,36 @@ func (i *instance) CutCompleteTraces(cutoff time.Duration, immediate bool) error
 			if err != nil {
 				return err
 			}
+			i.headBlock.Summarize(util.SummarizeTrace(trace.trace))
+			i.headBlock.AddSearch(trace.traceID, util.SearchEntryForTrace(trace.trace))
 
 			delete(i.traces, key)
+			i.limiter.liveTraces.Dec()
+		}
+	}
+
+	for key, seen := range i.lastSeen {
+		if now.Sub(seen) >= cutoff || immediate {
+			delete(i.lastSeen, key)
 		}
 	}
 
 	return nil
 }
 
-func (i *instance) CutBlockIfReady(maxTracesPerBlock int, maxBlockLifetime time.Duration, immediate bool) error {
+// TraceLastSeen returns when spans of the trace were last pushed.  false if none were pushed within the trace idle
+// period.
+func (i *instance) TraceLastSeen(id []byte) (time.Time, bool) {
+	i.tracesMtx.Lock()
+	defer i.tracesMtx.Unlock()
+
+	seen, ok := i.lastSeen[util.TokenForTraceID(id)]
+	return seen, ok
+}
+
+// CutBlockIfReady cuts the head block once it holds maxTracesPerBlock traces and at least minBlockBytes of data, once
+// it's older than maxBlockLifetime or if immediate is set.
+func (i *instance) CutBlockIfReady(maxTracesPerBlock int, minBlockBytes int, maxBlockLifetime time.Duration, immediate bool) error {
 	i.blocksMtx.Lock()
 	defer i.blocksMtx.Unlock()
 
@@ -133,7 +226,8 @@ func (i *instance) CutBlockIfReady(maxTracesPerBlock int, maxBlockLifetime time.
 	}
 
 	now := time.Now()
-	if i.headBlock.Length() >= maxTracesPerBlock || i.lastBlockCut.Add(maxBlockLifetime).Before(now) || immediate {
+	full := i.headBlock.Length() >= maxTracesPerBlock && i.headBlock.DataLength() >= minBlockBytes
+	if full || i.lastBlockCut.Add(maxBlockLifetime).Before(now) || immediate {
 		if i.completingBlock != nil {
 			return fmt.Errorf("unable to complete head block for %s b/c there is already a completing block.  Will try again next cycle", i.instanceID)
 		}
@@ -166,6 +260,14 @@ func (i *instance) CutBlockIfReady(maxTracesPerBlock int, maxBlockLifetime time.
 	return nil
 }
 
+// completing returns true if a cut head block is still being completed.
+func (i *instance) completing() bool {
+	i.blocksMtx.Lock()
+	defer i.blocksMtx.Unlock()
+
+	return i.completingBlock != nil
+}
+
 func (i *instance) GetBlockToBeFlushed() *tempodb_wal.CompleteBlock {
 	i.blocksMtx.Lock()
 	defer i.blocksMtx.Unlock()
@@ -204,21 +306,47 @@ func (i *instance) ClearFlushedBlocks(completeBlockTimeout time.Duration) error
 	return err
 }
 
+// empty returns true if the instance holds no live traces and no blocks.  Flushed blocks are only removed after the
+// complete block timeout so an empty instance has nothing left that queriers can't find in the backend.
+// liveTraceCount returns the number of traces not yet cut to the head block.
+func (i *instance) liveTraceCount() int {
+	i.tracesMtx.Lock()
+	defer i.tracesMtx.Unlock()
+	return len(i.traces)
+}
+
+func (i *instance) empty() bool {
+	i.tracesMtx.Lock()
+	defer i.tracesMtx.Unlock()
+
+	i.blocksMtx.RLock()
+	defer i.blocksMtx.RUnlock()
+
+	return len(i.traces) == 0 &&
+		(i.headBlock == nil || i.headBlock.Length() == 0) &&
+		i.completingBlock == nil &&
+		len(i.completeBlocks) == 0
+}
+
 func (i *instance) FindTraceByID(id []byte) (*tempopb.Trace, error) {
 	var allBytes []byte
 
-	// live traces
+	// live traces.  marshalled from a snapshot so pushes aren't held up
+	var liveTrace *tempopb.Trace
 	i.tracesMtx.Lock()
-	if liveTrace, ok := i.traces[util.TokenForTraceID(id)]; ok {
-		foundBytes, err := proto.Marshal(liveTrace.trace)
+	if t, ok := i.traces[util.TokenForTraceID(id)]; ok {
+		liveTrace = t.snapshot()
+	}
+	i.tracesMtx.Unlock()
+
+	if liveTrace != nil {
+		foundBytes, err := proto.Marshal(liveTrace)
 		if err != nil {
-			i.tracesMtx.Unlock()
 			return nil, fmt.Errorf("unable to marshal liveTrace: %w", err)
 		}
 
 		allBytes = i.Combine(foundBytes, allBytes)
 	}
-	i.tracesMtx.Unlock()
 
 	i.blocksMtx.Lock()
 	defer i.blocksMtx.Unlock()
@@ -263,7 +391,67 @@ func (i *instance) FindTraceByID(id []byte) (*tempopb.Trace, error) {
 	return nil, nil
 }
 
-func (i *instance) getOrCreateTrace(req *tempopb.PushRequest) (*trace, error) {
+// Search returns traces of live traces and blocks that match the query.  A trace may be returned more than once if it
+// was written to more than one block.  If q.MostRecent is set the newest matching live traces are returned first.
+func (i *instance) Search(q *tempodb_encoding.SearchQuery) []tempodb_encoding.SearchTrace {
+	var results []tempodb_encoding.SearchTrace
+	full := func() bool {
+		return q.Limit > 0 && len(results) >= q.Limit
+	}
+
+	// live traces.  matched against snapshots so pushes aren't held up
+	i.tracesMtx.Lock()
+	liveTraces := make([]*trace, 0, len(i.traces))
+	snapshots := make([]*tempopb.Trace, 0, len(i.traces))
+	for _, liveTrace := range i.traces {
+		liveTraces = append(liveTraces, liveTrace)
+		snapshots = append(snapshots, liveTrace.snapshot())
+	}
+	i.tracesMtx.Unlock()
+
+	for j, liveTrace := range liveTraces {
+		if full() && !q.MostRecent {
+			break
+		}
+
+		if found := util.MatchTrace(liveTrace.traceID, snapshots[j], q); found != nil {
+			results = append(results, *found)
+		}
+	}
+	if q.MostRecent {
+		// live traces are the newest, so the blocks only fill in if too few of them match
+		results = tempodb_encoding.MostRecent(results, q.Limit)
+	}
+
+	i.blocksMtx.RLock()
+	defer i.blocksMtx.RUnlock()
+
+	search := func(found []tempodb_encoding.SearchTrace) {
+		if q.Limit > 0 && len(results)+len(found) > q.Limit {
+			found = found[:q.Limit-len(results)]
+		}
+		results = append(results, found...)
+	}
+
+	if !full() {
+		search(i.headBlock.Search(q))
+	}
+	if !full() && i.completingBlock != nil {
+		search(i.completingBlock.Search(q))
+	}
+	for _, c := range i.completeBlocks {
+		if full() {
+			break
+		}
+		if d := c.SearchData(); d != nil {
+			search(d.Search(q))
+		}
+	}
+
+	return results
+}
+
+func (i *instance) getOrCreateTrace(ctx context.Context, req *tempopb.PushRequest) (*trace, error) {
 	traceID, err := pushRequestTraceID(req)
 	if err != nil {
 		return nil, status.Errorf(codes.InvalidArgument, "unable to extract traceID: %v", err)
@@ -275,15 +463,19 @@ func (i *instance) getOrCreateTrace(req *tempopb.PushRequest) (*trace, error) {
 		return trace, nil
 	}
 
-	err = i.limiter.AssertMaxTracesPerUser(i.instanceID, len(i.traces))
+	err = i.limiter.AssertMaxTracesPerUser(ctx, i.instanceID, len(i.traces))
 	if err != nil {
-		return nil, status.Errorf(codes.FailedPrecondition, "max live traces per tenant exceeded: %v", err)
+		return nil, client.LimitError(client.ReasonMaxLiveTraces, traceID, requestSpans(req), "max live traces per tenant exceeded, rejecting trace %x: %v", traceID, err)
+	}
+	err = i.limiter.AssertMaxLiveTraces(traceID)
+	if err != nil {
+		return nil, err
 	}
 
-	maxSpans := i.limiter.limits.MaxSpansPerTrace(i.instanceID)
-	trace = newTrace(maxSpans, fp, traceID)
+	trace = newTrace(fp, traceID)
 	i.traces[fp] = trace
 	i.tracesCreatedTotal.Inc()
+	i.limiter.liveTraces.Inc()
 
 	return trace, nil
 }
@@ -293,6 +485,7 @@ func (i *instance) resetHeadBlock() error {
 	var err error
 	i.headBlock, err = i.wal.NewBlock(uuid.New(), i.instanceID)
 	i.lastBlockCut = time.Now()
+	i.attributeKeys.reset()
 	return err
 }
 
@@ -300,6 +493,11 @@ func (i *instance) Combine(objA []byte, objB []byte) []byte {
 	return util.CombineTraces(objA, objB)
 }
 
+// SearchEntry implements tempodb_encoding.ObjectSearcher so completed blocks are written with search data.
+func (i *instance) SearchEntry(obj []byte) (*tempodb_encoding.SearchEntry, error) {
+	return util.SearchEntry(obj)
+}
+
 // pushRequestTraceID gets the TraceID of the first span in the batch and assumes its the trace ID throughout
 //  this assumption should hold b/c the distributors make sure each batch all belong to the same trace
 func pushRequestTraceID(req *tempopb.PushRequest) ([]byte, error) {
@@ -2,16 +2,25 @@ package ingester
 
 import (
 	"context"
+	"fmt"
 	"io/ioutil"
 	"os"
 	"testing"
 	"time"
 
+	"github.com/grafana/tempo/modules/ingester/client"
 	"github.com/grafana/tempo/modules/overrides"
 	"github.com/grafana/tempo/pkg/tempopb"
 	"github.com/grafana/tempo/pkg/util/test"
+	tempodb_encoding "github.com/grafana/tempo/tempodb/encoding"
 
+	v1_common "github.com/open-telemetry/opentelemetry-proto/gen/go/common/v1"
+	v1_resource "github.com/open-telemetry/opentelemetry-proto/gen/go/resource/v1"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
 )
 
 type ringCountMock struct {
@@ -44,7 +53,7 @@ func TestInstance(t *testing.T) {
 	err = i.CutCompleteTraces(0, true)
 	assert.NoError(t, err)
 
-	err = i.CutBlockIfReady(0, 0, false)
+	err = i.CutBlockIfReady(0, 0, 0, false)
 	assert.NoError(t, err, "unexpected error cutting block")
 
 	// try a few times while the block gets completed
@@ -59,6 +68,9 @@ func TestInstance(t *testing.T) {
 	assert.NotNil(t, block)
 	assert.Nil(t, i.completingBlock)
 	assert.Len(t, i.completeBlocks, 1)
+	if assert.NotNil(t, block.BlockMeta().Summary) {
+		assert.Equal(t, 1, block.BlockMeta().Summary.Traces)
+	}
 
 	err = ingester.store.WriteBlock(context.Background(), block)
 	assert.NoError(t, err)
@@ -75,6 +87,36 @@ func TestInstance(t *testing.T) {
 	assert.NoError(t, err, "unexpected error resetting block")
 }
 
+func TestInstanceCutBlockMinBytes(t *testing.T) {
+	limits, err := overrides.NewOverrides(overrides.Limits{})
+	assert.NoError(t, err, "unexpected error creating limits")
+	limiter := NewLimiter(limits, &ringCountMock{count: 1}, 1)
+
+	tempDir, err := ioutil.TempDir("/tmp", "")
+	assert.NoError(t, err, "unexpected error getting temp dir")
+	defer os.RemoveAll(tempDir)
+
+	ingester, _, _ := defaultIngester(t, tempDir)
+
+	i, err := newInstance("fake", limiter, ingester.store.WAL())
+	assert.NoError(t, err, "unexpected error creating new instance")
+	err = i.Push(context.Background(), test.MakeRequest(10, []byte{}))
+	assert.NoError(t, err)
+	err = i.CutCompleteTraces(0, true)
+	assert.NoError(t, err)
+
+	// enough traces but too little data
+	err = i.CutBlockIfReady(1, i.headBlock.DataLength()+1, time.Hour, false)
+	assert.NoError(t, err)
+	assert.Nil(t, i.completingBlock)
+
+	err = i.CutBlockIfReady(1, i.headBlock.DataLength(), time.Hour, false)
+	assert.NoError(t, err)
+	i.blocksMtx.Lock()
+	assert.Equal(t, 0, i.headBlock.Length())
+	i.blocksMtx.Unlock()
+}
+
 func TestInstanceFind(t *testing.T) {
 	limits, err := overrides.NewOverrides(overrides.Limits{})
 	assert.NoError(t, err, "unexpected error creating limits")
@@ -106,7 +148,7 @@ func TestInstanceFind(t *testing.T) {
 	assert.NotNil(t, trace)
 	assert.NoError(t, err)
 
-	err = i.CutBlockIfReady(0, 0, false)
+	err = i.CutBlockIfReady(0, 0, 0, false)
 	assert.NoError(t, err)
 
 	trace, err = i.FindTraceByID(traceID)
@@ -114,6 +156,59 @@ func TestInstanceFind(t *testing.T) {
 	assert.NoError(t, err)
 }
 
+func TestInstanceSearch(t *testing.T) {
+	limits, err := overrides.NewOverrides(overrides.Limits{})
+	assert.NoError(t, err, "unexpected error creating limits")
+	limiter := NewLimiter(limits, &ringCountMock{count: 1}, 1)
+
+	tempDir, err := ioutil.TempDir("/tmp", "")
+	assert.NoError(t, err, "unexpected error getting temp dir")
+	defer os.RemoveAll(tempDir)
+
+	ingester, _, _ := defaultIngester(t, tempDir)
+	wal := ingester.store.WAL()
+
+	request := test.MakeRequest(10, []byte{})
+	request.Batch.Resource = &v1_resource.Resource{
+		Attributes: []*v1_common.KeyValue{
+			{Key: serviceNameAttribute, Value: &v1_common.AnyValue{Value: &v1_common.AnyValue_StringValue{StringValue: "foo"}}},
+		},
+	}
+	traceID := test.MustTraceID(request)
+
+	i, err := newInstance("fake", limiter, wal)
+	assert.NoError(t, err, "unexpected error creating new instance")
+	err = i.Push(context.Background(), request)
+	assert.NoError(t, err)
+
+	q := &tempodb_encoding.SearchQuery{Tags: map[string]string{serviceNameAttribute: "foo"}}
+	assertFound := func() {
+		found := i.Search(q)
+		if assert.Len(t, found, 1) {
+			assert.Equal(t, tempodb_encoding.ID(traceID), found[0].TraceID)
+		}
+		assert.Empty(t, i.Search(&tempodb_encoding.SearchQuery{Tags: map[string]string{serviceNameAttribute: "bar"}}))
+	}
+
+	// live trace, head block and complete block
+	assertFound()
+
+	err = i.CutCompleteTraces(0, true)
+	assert.NoError(t, err)
+	assertFound()
+
+	err = i.CutBlockIfReady(0, 0, 0, true)
+	assert.NoError(t, err)
+	for j := 0; j < 50 && i.GetBlockToBeFlushed() == nil; j++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	block := i.GetBlockToBeFlushed()
+	if assert.NotNil(t, block) {
+		assert.True(t, block.BlockMeta().Searchable)
+	}
+	assertFound()
+}
+
 func TestInstanceDoesNotRace(t *testing.T) {
 	limits, err := overrides.NewOverrides(overrides.Limits{})
 	assert.NoError(t, err, "unexpected error creating limits")
@@ -151,7 +246,7 @@ func TestInstanceDoesNotRace(t *testing.T) {
 	})
 
 	go concurrent(func() {
-		_ = i.CutBlockIfReady(0, 0, false)
+		_ = i.CutBlockIfReady(0, 0, 0, false)
 	})
 
 	go concurrent(func() {
@@ -173,6 +268,52 @@ func TestInstanceDoesNotRace(t *testing.T) {
 	close(end)
 }
 
+func TestInstanceFindWhilePushing(t *testing.T) {
+	limits, err := overrides.NewOverrides(overrides.Limits{})
+	assert.NoError(t, err, "unexpected error creating limits")
+	limiter := NewLimiter(limits, &ringCountMock{count: 1}, 1)
+
+	tempDir, err := ioutil.TempDir("/tmp", "")
+	assert.NoError(t, err, "unexpected error getting temp dir")
+	defer os.RemoveAll(tempDir)
+
+	ingester, _, _ := defaultIngester(t, tempDir)
+	i, err := newInstance("fake", limiter, ingester.store.WAL())
+	assert.NoError(t, err, "unexpected error creating new instance")
+
+	const pushes = 100
+	traceID := []byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0A, 0x0B, 0x0C, 0x0D, 0x0E, 0x0F, 0x10}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for j := 0; j < pushes; j++ {
+			_ = i.Push(context.Background(), test.MakeRequest(10, traceID))
+		}
+	}()
+
+	// every read sees whole batches, and never fewer than the read before
+	seen := 0
+	for finished := false; !finished; {
+		select {
+		case <-done:
+			finished = true
+		default:
+		}
+
+		trace, err := i.FindTraceByID(traceID)
+		require.NoError(t, err)
+		if trace == nil {
+			continue
+		}
+		for _, b := range trace.Batches {
+			require.Equal(t, 10, requestSpans(&tempopb.PushRequest{Batch: b}))
+		}
+		require.GreaterOrEqual(t, len(trace.Batches), seen)
+		seen = len(trace.Batches)
+	}
+	assert.Equal(t, pushes, seen)
+}
+
 func TestInstanceLimits(t *testing.T) {
 	limits, err := overrides.NewOverrides(overrides.Limits{
 		MaxSpansPerTrace: 10,
@@ -252,3 +393,119 @@ func TestInstanceLimits(t *testing.T) {
 		})
 	}
 }
+
+func TestInstanceLimitsFromMetadata(t *testing.T) {
+	limits, err := overrides.NewOverrides(overrides.Limits{
+		MaxSpansPerTrace: 10,
+	})
+	assert.NoError(t, err, "unexpected error creating limits")
+	limiter := NewLimiter(limits, &ringCountMock{count: 1}, 1)
+
+	tempDir, err := ioutil.TempDir("/tmp", "")
+	assert.NoError(t, err, "unexpected error getting temp dir")
+	defer os.RemoveAll(tempDir)
+
+	ingester, _, _ := defaultIngester(t, tempDir)
+	wal := ingester.store.WAL()
+
+	i, err := newInstance("fake", limiter, wal)
+	assert.NoError(t, err, "unexpected error creating new instance")
+
+	// limits set by the distributor take precedence over the local overrides
+	outgoing := client.InjectPushLimits(context.Background(), client.PushLimits{
+		MaxLocalTracesPerUser: 1,
+		MaxSpansPerTrace:      4,
+	})
+	md, _ := metadata.FromOutgoingContext(outgoing)
+	ctx := metadata.NewIncomingContext(context.Background(), md)
+
+	err = i.Push(ctx, test.MakeRequest(3, []byte{0x01}))
+	assert.NoError(t, err)
+
+	err = i.Push(ctx, test.MakeRequest(3, []byte{0x01}))
+	assert.Error(t, err, "max spans per trace")
+	details, ok := client.LimitErrorDetails(err)
+	assert.True(t, ok)
+	assert.Equal(t, client.ReasonMaxSpansPerTrace, details.Reason)
+	assert.Equal(t, uint32(3), details.Spans)
+
+	err = i.Push(ctx, test.MakeRequest(3, []byte{0x02}))
+	assert.Error(t, err, "max traces per user")
+	details, ok = client.LimitErrorDetails(err)
+	assert.True(t, ok)
+	assert.Equal(t, client.ReasonMaxLiveTraces, details.Reason)
+
+	// without metadata the overrides are used
+	err = i.Push(context.Background(), test.MakeRequest(5, []byte{0x03}))
+	assert.NoError(t, err)
+}
+
+func TestInstanceMaxBytesPerTrace(t *testing.T) {
+	limits, err := overrides.NewOverrides(overrides.Limits{})
+	assert.NoError(t, err, "unexpected error creating limits")
+	limiter := NewLimiter(limits, &ringCountMock{count: 1}, 1)
+
+	tempDir, err := ioutil.TempDir("/tmp", "")
+	assert.NoError(t, err, "unexpected error getting temp dir")
+	defer os.RemoveAll(tempDir)
+
+	ingester, _, _ := defaultIngester(t, tempDir)
+	i, err := newInstance("fake", limiter, ingester.store.WAL())
+	assert.NoError(t, err, "unexpected error creating new instance")
+
+	withLimits := func(limits client.PushLimits) context.Context {
+		md, _ := metadata.FromOutgoingContext(client.InjectPushLimits(context.Background(), limits))
+		return metadata.NewIncomingContext(context.Background(), md)
+	}
+
+	req := test.MakeRequest(3, []byte{0x01})
+	size := req.Batch.Size()
+
+	err = i.Push(withLimits(client.PushLimits{MaxBytesPerTrace: 2*size - 1}), req)
+	assert.NoError(t, err)
+	err = i.Push(withLimits(client.PushLimits{MaxBytesPerTrace: 2*size - 1}), req)
+	assert.Error(t, err, "max bytes per trace")
+	details, ok := client.LimitErrorDetails(err)
+	assert.True(t, ok)
+	assert.Equal(t, client.ReasonMaxBytesPerTrace, details.Reason)
+	assert.Contains(t, err.Error(), fmt.Sprintf("%x", details.TraceID))
+
+	// limits are resolved on every push so a raised limit applies to the live trace
+	err = i.Push(withLimits(client.PushLimits{MaxBytesPerTrace: 2 * size}), req)
+	assert.NoError(t, err)
+}
+
+func TestInstanceMaxLiveTraces(t *testing.T) {
+	limits, err := overrides.NewOverrides(overrides.Limits{})
+	assert.NoError(t, err, "unexpected error creating limits")
+	limiter := NewLimiter(limits, &ringCountMock{count: 1}, 1)
+	limiter.maxLiveTraces = 1
+
+	tempDir, err := ioutil.TempDir("/tmp", "")
+	assert.NoError(t, err, "unexpected error getting temp dir")
+	defer os.RemoveAll(tempDir)
+
+	ingester, _, _ := defaultIngester(t, tempDir)
+	i, err := newInstance("fake", limiter, ingester.store.WAL())
+	assert.NoError(t, err, "unexpected error creating new instance")
+	other, err := newInstance("other", limiter, ingester.store.WAL())
+	assert.NoError(t, err, "unexpected error creating new instance")
+
+	err = i.Push(context.Background(), test.MakeRequest(1, []byte{0x01}))
+	assert.NoError(t, err)
+
+	// the limit covers every tenant and is retryable
+	err = other.Push(context.Background(), test.MakeRequest(1, []byte{0x02}))
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+	_, ok := client.LimitErrorDetails(err)
+	assert.False(t, ok)
+
+	// live traces can still grow
+	err = i.Push(context.Background(), test.MakeRequest(1, []byte{0x01}))
+	assert.NoError(t, err)
+
+	err = i.CutCompleteTraces(0, true)
+	assert.NoError(t, err)
+	err = other.Push(context.Background(), test.MakeRequest(1, []byte{0x02}))
+	assert.NoError(t, err)
+}
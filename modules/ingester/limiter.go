@@ -1,9 +1,15 @@
 package ingester
 
 import (
+	"context"
 	"fmt"
 	"math"
 
+	"go.uber.org/atomic"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/grafana/tempo/modules/ingester/client"
 	"github.com/grafana/tempo/modules/overrides"
 )
 
@@ -23,6 +29,10 @@ type Limiter struct {
 	limits            *overrides.Overrides
 	ring              RingCount
 	replicationFactor int
+
+	// maxLiveTraces bounds the live traces of all tenants of the ingester.  0 disables
+	maxLiveTraces int
+	liveTraces    atomic.Int64
 }
 
 // NewLimiter makes a new limiter
@@ -36,25 +46,70 @@ func NewLimiter(limits *overrides.Overrides, ring RingCount, replicationFactor i
 
 // AssertMaxTracesPerUser ensures limit has not been reached compared to the current
 // number of streams in input and returns an error if so.
-func (l *Limiter) AssertMaxTracesPerUser(userID string, traces int) error {
-	actualLimit := l.maxTracesPerUser(userID)
+func (l *Limiter) AssertMaxTracesPerUser(ctx context.Context, userID string, traces int) error {
+	limits := l.limitsFor(ctx, userID)
+
+	actualLimit := l.maxTracesPerUser(userID, limits)
 	if traces < actualLimit {
 		return nil
 	}
 
-	localLimit := l.limits.MaxLocalTracesPerUser(userID)
-	globalLimit := l.limits.MaxGlobalTracesPerUser(userID)
+	return fmt.Errorf(errMaxTracesPerUserLimitExceeded, limits.MaxLocalTracesPerUser, limits.MaxGlobalTracesPerUser, actualLimit)
+}
+
+// AssertMaxLiveTraces returns a ResourceExhausted error if the ingester holds its maximum number of live traces.  The
+// error isn't the tenant's fault, so distributors retry the push on other ingesters.
+func (l *Limiter) AssertMaxLiveTraces(traceID []byte) error {
+	if l.maxLiveTraces <= 0 || l.liveTraces.Load() < int64(l.maxLiveTraces) {
+		return nil
+	}
+
+	return status.Errorf(codes.ResourceExhausted, "ingester holds its max live traces (%d), rejecting trace %x", l.maxLiveTraces, traceID)
+}
+
+// MaxSpansPerTrace returns the maximum number of spans a single trace may hold for a tenant.
+func (l *Limiter) MaxSpansPerTrace(ctx context.Context, userID string) int {
+	return l.limitsFor(ctx, userID).MaxSpansPerTrace
+}
+
+// MaxBytesPerTrace returns the maximum size in bytes a single trace may grow to for a tenant.
+func (l *Limiter) MaxBytesPerTrace(ctx context.Context, userID string) int {
+	return l.limitsFor(ctx, userID).MaxBytesPerTrace
+}
+
+// MaxAttributeKeysPerBlock returns the maximum number of unique span attribute keys in a head block for a tenant.
+func (l *Limiter) MaxAttributeKeysPerBlock(ctx context.Context, userID string) int {
+	return l.limitsFor(ctx, userID).MaxAttributeKeys
+}
 
-	return fmt.Errorf(errMaxTracesPerUserLimitExceeded, localLimit, globalLimit, actualLimit)
+// BestEffort returns true if the tenant's pushes are rejected first when the ingester is short of memory.
+func (l *Limiter) BestEffort(userID string) bool {
+	return l.limits.BestEffort(userID)
 }
 
-func (l *Limiter) maxTracesPerUser(userID string) int {
-	localLimit := l.limits.MaxLocalTracesPerUser(userID)
+// limitsFor prefers the limits resolved by the distributor and passed along with the request.
+// Requests without them, such as those from older distributors, fall back to the local overrides.
+func (l *Limiter) limitsFor(ctx context.Context, userID string) client.PushLimits {
+	if limits, ok := client.ExtractPushLimits(ctx); ok {
+		return limits
+	}
+
+	return client.PushLimits{
+		MaxLocalTracesPerUser:  l.limits.MaxLocalTracesPerUser(userID),
+		MaxGlobalTracesPerUser: l.limits.MaxGlobalTracesPerUser(userID),
+		MaxSpansPerTrace:       l.limits.MaxSpansPerTrace(userID),
+		MaxBytesPerTrace:       l.limits.MaxBytesPerTrace(userID),
+		MaxAttributeKeys:       l.limits.MaxAttributeKeysPerBlock(userID),
+	}
+}
+
+func (l *Limiter) maxTracesPerUser(userID string, limits client.PushLimits) int {
+	localLimit := limits.MaxLocalTracesPerUser
 
 	// We can assume that traces are evenly distributed across ingesters
 	// so we do convert the global limit into a local limit
-	globalLimit := l.limits.MaxGlobalTracesPerUser(userID)
-	localLimit = l.minNonZero(localLimit, l.convertGlobalToLocalLimit(globalLimit))
+	globalLimit := limits.MaxGlobalTracesPerUser
+	localLimit = l.minNonZero(localLimit, l.convertGlobalToLocalLimit(userID, globalLimit))
 
 	// If both the local and global limits are disabled, we just
 	// use the largest int value
@@ -65,7 +120,7 @@ func (l *Limiter) maxTracesPerUser(userID string) int {
 	return localLimit
 }
 
-func (l *Limiter) convertGlobalToLocalLimit(globalLimit int) int {
+func (l *Limiter) convertGlobalToLocalLimit(userID string, globalLimit int) int {
 	if globalLimit == 0 {
 		return 0
 	}
@@ -76,6 +131,16 @@ func (l *Limiter) convertGlobalToLocalLimit(globalLimit int) int {
 	// (global limit / number of ingesters) * replication factor
 	numIngesters := l.ring.HealthyInstancesCount()
 
+	// shuffle sharded tenants only push to the ingesters of their shard
+	if shardSize := l.limits.IngestionTenantShardSize(userID); shardSize > 0 {
+		if shardSize < l.replicationFactor {
+			shardSize = l.replicationFactor
+		}
+		if shardSize < numIngesters {
+			numIngesters = shardSize
+		}
+	}
+
 	// May happen because the number of ingesters is asynchronously updated.
 	// If happens, we just temporarily ignore the global limit.
 	if numIngesters > 0 {
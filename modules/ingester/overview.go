@@ -0,0 +1,121 @@
+package ingester
+
+import (
+	"sync"
+	"time"
+
+	"github.com/grafana/tempo/pkg/tempopb"
+	"github.com/grafana/tempo/pkg/util"
+)
+
+const (
+	// overviewSlotDuration is the granularity the overview window moves by.  The window is the current slot and the
+	// slots before it, covering between 50 and 60 minutes.
+	overviewSlotDuration = 10 * time.Minute
+	overviewSlots        = 6
+
+	// maxOverviewServices bounds the services counted per slot.  Spans of further services aren't counted.
+	maxOverviewServices = 1000
+)
+
+type overviewSlot struct {
+	start    time.Time
+	services map[string]*tempopb.ServiceOverview
+}
+
+// recentOverview summarizes the spans pushed to an instance per service over roughly the last hour.  Spans are
+// counted in slots of ten minutes by the time they are pushed, not by their timestamps.
+type recentOverview struct {
+	mtx   sync.Mutex
+	slots [overviewSlots]overviewSlot
+	since time.Time
+}
+
+func newRecentOverview(now time.Time) *recentOverview {
+	return &recentOverview{
+		since: now,
+	}
+}
+
+func (o *recentOverview) record(req *tempopb.PushRequest, now time.Time) {
+	if req.Batch == nil {
+		return
+	}
+
+	name := ""
+	if req.Batch.Resource != nil {
+		for _, attr := range req.Batch.Resource.Attributes {
+			if attr.Key == serviceNameAttribute {
+				name = attr.Value.GetStringValue()
+				break
+			}
+		}
+	}
+
+	o.mtx.Lock()
+	defer o.mtx.Unlock()
+
+	slot := o.slot(now)
+	service, ok := slot.services[name]
+	if !ok {
+		if len(slot.services) >= maxOverviewServices {
+			return
+		}
+		service = &tempopb.ServiceOverview{Name: name}
+		slot.services[name] = service
+	}
+
+	for _, ils := range req.Batch.InstrumentationLibrarySpans {
+		for _, span := range ils.Spans {
+			util.AddOverviewSpan(service, span)
+		}
+	}
+}
+
+// slot returns the slot for now, resetting it if it was last used a window ago.
+func (o *recentOverview) slot(now time.Time) *overviewSlot {
+	start := now.Truncate(overviewSlotDuration)
+	slot := &o.slots[start.Unix()/int64(overviewSlotDuration/time.Second)%overviewSlots]
+	if !slot.start.Equal(start) {
+		slot.start = start
+		slot.services = map[string]*tempopb.ServiceOverview{}
+	}
+	return slot
+}
+
+// overview combines the slots of the window ending now.
+func (o *recentOverview) overview(now time.Time) *tempopb.OverviewResponse {
+	o.mtx.Lock()
+	defer o.mtx.Unlock()
+
+	oldest := now.Truncate(overviewSlotDuration).Add(-(overviewSlots - 1) * overviewSlotDuration)
+	windowStart := oldest
+	if o.since.After(windowStart) {
+		windowStart = o.since
+	}
+
+	services := map[string]*tempopb.ServiceOverview{}
+	for i := range o.slots {
+		slot := &o.slots[i]
+		if slot.start.Before(oldest) || slot.start.After(now) {
+			continue
+		}
+		for name, s := range slot.services {
+			combined, ok := services[name]
+			if !ok {
+				combined = &tempopb.ServiceOverview{Name: name}
+				services[name] = combined
+			}
+			util.CombineServiceOverviews(combined, s)
+		}
+	}
+
+	resp := &tempopb.OverviewResponse{
+		Services:      make([]*tempopb.ServiceOverview, 0, len(services)),
+		WindowSeconds: uint32(now.Sub(windowStart) / time.Second),
+	}
+	for _, s := range services {
+		resp.Services = append(resp.Services, s)
+	}
+	return resp
+}
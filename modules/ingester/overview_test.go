@@ -0,0 +1,69 @@
+package ingester
+
+import (
+	"testing"
+	"time"
+
+	v1_common "github.com/open-telemetry/opentelemetry-proto/gen/go/common/v1"
+	v1_resource "github.com/open-telemetry/opentelemetry-proto/gen/go/resource/v1"
+	v1 "github.com/open-telemetry/opentelemetry-proto/gen/go/trace/v1"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/grafana/tempo/pkg/tempopb"
+)
+
+func requestForService(service string, spans int, code v1.Status_StatusCode) *tempopb.PushRequest {
+	ils := &v1.InstrumentationLibrarySpans{}
+	for i := 0; i < spans; i++ {
+		ils.Spans = append(ils.Spans, &v1.Span{Status: &v1.Status{Code: code}})
+	}
+
+	return &tempopb.PushRequest{
+		Batch: &v1.ResourceSpans{
+			Resource: &v1_resource.Resource{
+				Attributes: []*v1_common.KeyValue{
+					{
+						Key:   serviceNameAttribute,
+						Value: &v1_common.AnyValue{Value: &v1_common.AnyValue_StringValue{StringValue: service}},
+					},
+				},
+			},
+			InstrumentationLibrarySpans: []*v1.InstrumentationLibrarySpans{ils},
+		},
+	}
+}
+
+func TestRecentOverview(t *testing.T) {
+	start := time.Date(2021, 1, 1, 12, 0, 0, 0, time.UTC)
+	o := newRecentOverview(start)
+
+	o.record(requestForService("a", 3, v1.Status_Ok), start)
+	o.record(requestForService("b", 1, v1.Status_InternalError), start.Add(time.Minute))
+	o.record(requestForService("a", 2, v1.Status_UnknownError), start.Add(25*time.Minute))
+
+	services := func(resp *tempopb.OverviewResponse) map[string][2]uint64 {
+		m := map[string][2]uint64{}
+		for _, s := range resp.Services {
+			m[s.Name] = [2]uint64{s.Spans, s.Errors}
+		}
+		return m
+	}
+
+	// the window only reaches back to the first push
+	resp := o.overview(start.Add(30 * time.Minute))
+	assert.Equal(t, uint32(30*60), resp.WindowSeconds)
+	assert.Equal(t, map[string][2]uint64{"a": {5, 2}, "b": {1, 1}}, services(resp))
+
+	// the first slot has left the window
+	resp = o.overview(start.Add(time.Hour))
+	assert.Equal(t, uint32(50*60), resp.WindowSeconds)
+	assert.Equal(t, map[string][2]uint64{"a": {2, 2}}, services(resp))
+
+	// slots are reused once they leave the window
+	o.record(requestForService("c", 1, v1.Status_Ok), start.Add(time.Hour+time.Minute))
+	resp = o.overview(start.Add(time.Hour + 2*time.Minute))
+	assert.Equal(t, map[string][2]uint64{"a": {2, 2}, "c": {1, 0}}, services(resp))
+
+	resp = o.overview(start.Add(3 * time.Hour))
+	assert.Empty(t, resp.Services)
+}
@@ -0,0 +1,86 @@
+package ingester
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/cortexproject/cortex/pkg/ring"
+	"github.com/cortexproject/cortex/pkg/util"
+	"github.com/go-kit/kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var metricReadOnly = promauto.NewGauge(prometheus.GaugeOpts{
+	Namespace: "tempo",
+	Name:      "ingester_read_only",
+	Help:      "1 if the ingester has been placed in read only mode ahead of a scale down.",
+})
+
+// ReadOnlyHandler places the ingester in read only mode ahead of a scale down.  The ingester is marked LEAVING in the
+// ring so distributors stop sending it writes, but it keeps answering queries.  All traces are cut and flushed and,
+// once the flushed blocks have been held for the complete block timeout so queriers have picked them up from the
+// backend, the process exits.
+func (i *Ingester) ReadOnlyHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "read only mode must be requested with POST", http.StatusMethodNotAllowed)
+		return
+	}
+
+	err := i.enterReadOnly(r.Context())
+	if err != nil {
+		level.Error(util.Logger).Log("msg", "failed to enter read only mode", "err", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (i *Ingester) enterReadOnly(ctx context.Context) error {
+	i.readOnlyMtx.Lock()
+	defer i.readOnlyMtx.Unlock()
+
+	if !i.readOnlySince.IsZero() {
+		return nil
+	}
+
+	// LEAVING ingesters are skipped for writes but are still included in reads
+	err := i.lifecycler.ChangeState(ctx, ring.LEAVING)
+	if err != nil {
+		return err
+	}
+
+	i.readOnlySince = time.Now()
+	metricReadOnly.Set(1)
+	level.Info(util.Logger).Log("msg", "ingester entered read only mode.  flushing all traces before exiting")
+
+	return nil
+}
+
+func (i *Ingester) isReadOnly() (bool, time.Time) {
+	i.readOnlyMtx.Lock()
+	defer i.readOnlyMtx.Unlock()
+
+	return !i.readOnlySince.IsZero(), i.readOnlySince
+}
+
+// sweepReadOnly flushes everything the ingester holds and returns true once it is safe to exit.  This requires that
+// every instance is empty and that the ingester has been read only for at least the complete block timeout so
+// distributors have seen the ring change and writes that raced it have also been flushed.
+func (i *Ingester) sweepReadOnly(since time.Time) bool {
+	i.sweepUsers(true)
+
+	if time.Since(since) < i.cfg.CompleteBlockTimeout {
+		return false
+	}
+
+	for _, instance := range i.getInstances() {
+		if !instance.empty() {
+			return false
+		}
+	}
+
+	return true
+}
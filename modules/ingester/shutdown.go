@@ -0,0 +1,67 @@
+package ingester
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/cortexproject/cortex/pkg/ring"
+	"github.com/cortexproject/cortex/pkg/util"
+	"github.com/go-kit/kit/log/level"
+)
+
+// ShutdownHandler gracefully shuts the ingester down, e.g. during a rolling restart.  The ingester is marked LEAVING in
+// the ring so distributors stop sending it writes, all traces are cut and every block is flushed to the backend.  Only
+// then does the process exit, removing the ingester from the ring even if unregister_on_shutdown is disabled.  If the
+// request is cancelled before every block is flushed the ingester keeps running, LEAVING, and the request can be retried.
+func (i *Ingester) ShutdownHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "shutdown must be requested with POST", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if i.lifecycler.GetState() != ring.LEAVING {
+		err := i.lifecycler.ChangeState(r.Context(), ring.LEAVING)
+		if err != nil {
+			level.Error(util.Logger).Log("msg", "failed to leave the ring for shutdown", "err", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	level.Info(util.Logger).Log("msg", "shutdown requested.  flushing all blocks before exiting")
+	i.sweepUsers(true)
+	err := i.waitForFlush(r.Context())
+	if err != nil {
+		http.Error(w, fmt.Sprintf("blocks still waiting to be flushed: %v", err), http.StatusServiceUnavailable)
+		return
+	}
+
+	i.shutdownOnce.Do(func() {
+		close(i.shutdownRequested)
+	})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (i *Ingester) isShutdownRequested() bool {
+	select {
+	case <-i.shutdownRequested:
+		return true
+	default:
+		return false
+	}
+}
+
+// unregister removes the ingester from the ring.  It's called after the lifecycler has stopped heartbeating, which
+// would otherwise add it back.
+func (i *Ingester) unregister(ctx context.Context) error {
+	return i.lifecycler.KVStore.CAS(ctx, i.cfg.OverrideRingKey, func(in interface{}) (out interface{}, retry bool, err error) {
+		if in == nil {
+			return nil, false, nil
+		}
+
+		ringDesc := in.(*ring.Desc)
+		ringDesc.RemoveIngester(i.cfg.LifecyclerConfig.ID)
+		return ringDesc, true, nil
+	})
+}
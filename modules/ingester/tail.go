@@ -0,0 +1,137 @@
+package ingester
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"github.com/weaveworks/common/user"
+
+	"github.com/grafana/tempo/pkg/tempopb"
+	tempo_util "github.com/grafana/tempo/pkg/util"
+	v1_trace "github.com/open-telemetry/opentelemetry-proto/gen/go/trace/v1"
+)
+
+const (
+	tailBufferSize          = 100
+	maxTailBatchesPerResult = 100
+)
+
+// tails fans the spans pushed to the ingester out to the streams of Tail.  Nothing is done while there are no streams.
+type tails struct {
+	streams int32
+
+	mtx         sync.Mutex
+	subscribers map[*tailSubscriber]struct{}
+	done        chan struct{}
+	closed      bool
+}
+
+type tailSubscriber struct {
+	tenant  string
+	tags    map[string]string
+	batches chan *v1_trace.ResourceSpans
+	dropped uint32
+}
+
+func newTails() *tails {
+	return &tails{
+		subscribers: map[*tailSubscriber]struct{}{},
+		done:        make(chan struct{}),
+	}
+}
+
+func (t *tails) subscribe(tenant string, tags map[string]string) *tailSubscriber {
+	s := &tailSubscriber{
+		tenant:  tenant,
+		tags:    tags,
+		batches: make(chan *v1_trace.ResourceSpans, tailBufferSize),
+	}
+
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	t.subscribers[s] = struct{}{}
+	atomic.AddInt32(&t.streams, 1)
+	return s
+}
+
+func (t *tails) unsubscribe(s *tailSubscriber) {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	delete(t.subscribers, s)
+	atomic.AddInt32(&t.streams, -1)
+}
+
+// publish sends the matching spans of a batch pushed for the tenant to its subscribers.  Spans are dropped for
+// subscribers whose buffer is full so a slow stream never holds up a push.
+func (t *tails) publish(tenant string, batch *v1_trace.ResourceSpans) {
+	if atomic.LoadInt32(&t.streams) == 0 {
+		return
+	}
+
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	for s := range t.subscribers {
+		if s.tenant != tenant {
+			continue
+		}
+		matched, spans := tempo_util.MatchingSpans(batch, s.tags)
+		if matched == nil {
+			continue
+		}
+		select {
+		case s.batches <- matched:
+		default:
+			atomic.AddUint32(&s.dropped, uint32(spans))
+		}
+	}
+}
+
+// close ends the streams so the gRPC server can stop.
+func (t *tails) close() {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	if !t.closed {
+		t.closed = true
+		close(t.done)
+	}
+}
+
+// Tail implements tempopb.Querier.  Matching spans are sent as they're pushed, batched while the stream is busy, until
+// the stream is cancelled or the ingester stops.  Spans are pushed to as many ingesters as the replication factor, so
+// the querier drops the copies.
+func (i *Ingester) Tail(req *tempopb.TailRequest, stream tempopb.Querier_TailServer) error {
+	instanceID, err := user.ExtractOrgID(stream.Context())
+	if err != nil {
+		return err
+	}
+
+	s := i.tails.subscribe(instanceID, req.Tags)
+	defer i.tails.unsubscribe(s)
+
+	for {
+		var batch *v1_trace.ResourceSpans
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case <-i.tails.done:
+			return nil
+		case batch = <-s.batches:
+		}
+
+		resp := &tempopb.TailResponse{Batches: []*v1_trace.ResourceSpans{batch}}
+	drain:
+		for len(resp.Batches) < maxTailBatchesPerResult {
+			select {
+			case batch = <-s.batches:
+				resp.Batches = append(resp.Batches, batch)
+			default:
+				break drain
+			}
+		}
+		resp.Dropped = atomic.SwapUint32(&s.dropped, 0)
+
+		if err := stream.Send(resp); err != nil {
+			return err
+		}
+	}
+}
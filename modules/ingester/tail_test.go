@@ -0,0 +1,101 @@
+package ingester
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/weaveworks/common/user"
+	"google.golang.org/grpc"
+
+	"github.com/grafana/tempo/pkg/tempopb"
+	"github.com/grafana/tempo/pkg/util/test"
+	v1_common "github.com/open-telemetry/opentelemetry-proto/gen/go/common/v1"
+	v1_resource "github.com/open-telemetry/opentelemetry-proto/gen/go/resource/v1"
+)
+
+type tailStream struct {
+	grpc.ServerStream
+	ctx       context.Context
+	responses chan *tempopb.TailResponse
+}
+
+func (s *tailStream) Context() context.Context { return s.ctx }
+
+func (s *tailStream) Send(resp *tempopb.TailResponse) error {
+	s.responses <- resp
+	return nil
+}
+
+func serviceRequest(service string, spans int) *tempopb.PushRequest {
+	req := test.MakeRequest(spans, nil)
+	req.Batch.Resource = &v1_resource.Resource{
+		Attributes: []*v1_common.KeyValue{
+			{Key: "service.name", Value: &v1_common.AnyValue{Value: &v1_common.AnyValue_StringValue{StringValue: service}}},
+		},
+	}
+	return req
+}
+
+func TestTail(t *testing.T) {
+	ingester, _, _ := defaultIngester(t, t.TempDir())
+
+	ctx, cancel := context.WithCancel(user.InjectOrgID(context.Background(), "test"))
+	stream := &tailStream{ctx: ctx, responses: make(chan *tempopb.TailResponse, 10)}
+	done := make(chan error)
+	go func() {
+		done <- ingester.Tail(&tempopb.TailRequest{Tags: map[string]string{"service.name": "api"}}, stream)
+	}()
+	require.Eventually(t, func() bool {
+		return atomic.LoadInt32(&ingester.tails.streams) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// only matching spans of the tenant are sent
+	matching := serviceRequest("api", 3)
+	_, err := ingester.Push(ctx, serviceRequest("web", 2))
+	require.NoError(t, err)
+	_, err = ingester.Push(user.InjectOrgID(context.Background(), "other"), serviceRequest("api", 2))
+	require.NoError(t, err)
+	_, err = ingester.Push(ctx, matching)
+	require.NoError(t, err)
+
+	select {
+	case resp := <-stream.responses:
+		require.Len(t, resp.Batches, 1)
+		assert.Equal(t, matching.Batch, resp.Batches[0])
+		assert.Zero(t, resp.Dropped)
+	case <-time.After(5 * time.Second):
+		t.Fatal("no spans tailed")
+	}
+
+	cancel()
+	require.NoError(t, <-done)
+	assert.Zero(t, atomic.LoadInt32(&ingester.tails.streams))
+}
+
+func TestTailsDropSpans(t *testing.T) {
+	tails := newTails()
+	s := tails.subscribe("test", nil)
+
+	for i := 0; i < tailBufferSize+2; i++ {
+		tails.publish("test", serviceRequest("api", 2).Batch)
+	}
+	assert.Len(t, s.batches, tailBufferSize)
+	assert.Equal(t, uint32(4), s.dropped)
+
+	tails.unsubscribe(s)
+	tails.publish("test", serviceRequest("api", 2).Batch)
+	assert.Len(t, s.batches, tailBufferSize)
+
+	// closing ends the streams
+	s = tails.subscribe("test", nil)
+	tails.close()
+	tails.close()
+	ingester := &Ingester{tails: tails}
+	err := ingester.Tail(&tempopb.TailRequest{}, &tailStream{ctx: user.InjectOrgID(context.Background(), "test")})
+	assert.NoError(t, err)
+	tails.unsubscribe(s)
+}
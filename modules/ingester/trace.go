@@ -4,47 +4,69 @@ import (
 	"context"
 	"time"
 
-	"github.com/gogo/status"
+	"github.com/grafana/tempo/modules/ingester/client"
 	"github.com/grafana/tempo/pkg/tempopb"
-	"google.golang.org/grpc/codes"
 )
 
+const serviceNameAttribute = "service.name"
+
 type trace struct {
 	trace        *tempopb.Trace
 	token        uint32
 	lastAppend   time.Time
 	traceID      []byte
-	maxSpans     int
 	currentSpans int
+	currentBytes int
+
+	// walSegment is the first segment of the trace wal holding a batch of the trace.  -1 if none.
+	walSegment int
 }
 
-func newTrace(maxSpans int, token uint32, traceID []byte) *trace {
+func newTrace(token uint32, traceID []byte) *trace {
 	return &trace{
 		token:      token,
 		trace:      &tempopb.Trace{},
 		lastAppend: time.Now(),
 		traceID:    traceID,
-		maxSpans:   maxSpans,
+		walSegment: -1,
 	}
 }
 
-func (t *trace) Push(_ context.Context, req *tempopb.PushRequest) error {
-	if t.maxSpans != 0 {
-		// count spans
-		spanCount := 0
-		for _, ils := range req.Batch.InstrumentationLibrarySpans {
-			spanCount += len(ils.Spans)
-		}
-
-		if t.currentSpans+spanCount > t.maxSpans {
-			return status.Errorf(codes.FailedPrecondition, "totalSpans (%d) exceeded while adding %d spans", t.maxSpans, spanCount)
-		}
+// Push appends the batch of req to the trace.  The limits are passed on every push so changed overrides apply to
+// live traces as well.  0 disables a limit.
+func (t *trace) Push(_ context.Context, req *tempopb.PushRequest, maxSpans int, maxBytes int) error {
+	spanCount := requestSpans(req)
+	if maxSpans != 0 && t.currentSpans+spanCount > maxSpans {
+		return client.LimitError(client.ReasonMaxSpansPerTrace, t.traceID, spanCount, "trace %x exceeded max spans per trace (%d) while adding %d spans", t.traceID, maxSpans, spanCount)
+	}
 
-		t.currentSpans += spanCount
+	size := req.Batch.Size()
+	if maxBytes != 0 && t.currentBytes+size > maxBytes {
+		return client.LimitError(client.ReasonMaxBytesPerTrace, t.traceID, spanCount, "trace %x exceeded max bytes per trace (%d) while adding %d bytes", t.traceID, maxBytes, size)
 	}
 
+	t.currentSpans += spanCount
+	t.currentBytes += size
 	t.trace.Batches = append(t.trace.Batches, req.Batch)
 	t.lastAppend = time.Now()
 
 	return nil
 }
+
+// snapshot returns the trace as of the last push.  Pushed batches are never modified and the batches of a trace are only
+// appended to, so the snapshot stays consistent while pushes continue and can be read without holding the instance's
+// lock.
+func (t *trace) snapshot() *tempopb.Trace {
+	n := len(t.trace.Batches)
+	return &tempopb.Trace{
+		Batches: t.trace.Batches[:n:n],
+	}
+}
+
+func requestSpans(req *tempopb.PushRequest) int {
+	spans := 0
+	for _, ils := range req.Batch.InstrumentationLibrarySpans {
+		spans += len(ils.Spans)
+	}
+	return spans
+}
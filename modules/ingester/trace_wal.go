@@ -0,0 +1,373 @@
+package ingester
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/cortexproject/cortex/pkg/util"
+	"github.com/go-kit/kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const (
+	// traceWALSegmentSize is the size after which a new segment is started.  Only complete segments are truncated.
+	traceWALSegmentSize = 64 * 1024 * 1024
+
+	traceWALHeaderSize = 8 // payload length and crc
+)
+
+var (
+	metricTraceWALReplayed = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: "tempo",
+		Name:      "ingester_trace_wal_replayed_records_total",
+		Help:      "The total number of batches of live traces replayed from the wal on startup.",
+	})
+	metricTraceWALCorrupt = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: "tempo",
+		Name:      "ingester_trace_wal_corrupt_segments_total",
+		Help:      "The total number of wal segments that could only be partially replayed.",
+	})
+	metricTraceWALTruncated = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: "tempo",
+		Name:      "ingester_trace_wal_truncated_segments_total",
+		Help:      "The total number of wal segments removed after their traces were flushed.",
+	})
+)
+
+var errTraceWALCorrupt = errors.New("corrupt wal record")
+
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+// traceWAL records the batches pushed to live traces.  Live traces are only held in memory until they are cut to the
+// head block, which has its own wal, so these records let an ingester restore them after a crash.  Records are
+// appended to numbered segments and a segment is removed once no live trace has records in it.
+type traceWAL struct {
+	dir          string
+	syncInterval time.Duration
+
+	mtx     sync.Mutex
+	segment int // the segment being written
+	file    *os.File
+	size    int64
+	dirty   bool
+
+	replaySegments []int // segments present on startup
+
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+// newTraceWAL opens the wal in dir.  Segments left by a previous run are kept for replay and new records are written to
+// a new segment.  Records are fsynced every syncInterval, or after every record if it is 0.
+func newTraceWAL(dir string, syncInterval time.Duration) (*traceWAL, error) {
+	err := os.MkdirAll(dir, os.ModePerm)
+	if err != nil {
+		return nil, err
+	}
+
+	w := &traceWAL{
+		dir:          dir,
+		syncInterval: syncInterval,
+		done:         make(chan struct{}),
+	}
+
+	w.replaySegments, err = w.segments()
+	if err != nil {
+		return nil, err
+	}
+	if len(w.replaySegments) > 0 {
+		w.segment = w.replaySegments[len(w.replaySegments)-1] + 1
+	}
+
+	err = w.openSegment()
+	if err != nil {
+		return nil, err
+	}
+
+	if syncInterval > 0 {
+		w.wg.Add(1)
+		go w.syncLoop()
+	}
+
+	return w, nil
+}
+
+// Append records a batch pushed to a live trace and returns the segment it was written to.
+func (w *traceWAL) Append(tenantID string, traceID []byte, batch []byte) (int, error) {
+	payload := make([]byte, 0, 2*binary.MaxVarintLen64+len(tenantID)+len(traceID)+len(batch))
+	payload = appendUvarintBytes(payload, []byte(tenantID))
+	payload = appendUvarintBytes(payload, traceID)
+	payload = append(payload, batch...)
+
+	record := make([]byte, traceWALHeaderSize+len(payload))
+	binary.LittleEndian.PutUint32(record[0:], uint32(len(payload)))
+	binary.LittleEndian.PutUint32(record[4:], crc32.Checksum(payload, castagnoli))
+	copy(record[traceWALHeaderSize:], payload)
+
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+
+	if w.file == nil {
+		return 0, errors.New("wal is closed")
+	}
+
+	// written without buffering so records survive a crash of the process.  fsync protects them from a crash of the host
+	_, err := w.file.Write(record)
+	if err != nil {
+		return 0, err
+	}
+	segment := w.segment
+	w.size += int64(len(record))
+	w.dirty = true
+
+	if w.syncInterval == 0 {
+		err = w.syncLocked()
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	if w.size >= traceWALSegmentSize {
+		err = w.rotateLocked()
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	return segment, nil
+}
+
+// Segment returns the segment being written.  Records appended later are written to it or a later segment.
+func (w *traceWAL) Segment() int {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+
+	return w.segment
+}
+
+// Rotate starts a new segment so all records appended so far can be truncated.
+func (w *traceWAL) Rotate() error {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+
+	return w.rotateLocked()
+}
+
+// Truncate removes the segments before segment.  The segment being written is never removed.
+func (w *traceWAL) Truncate(segment int) error {
+	segments, err := w.segments()
+	if err != nil {
+		return err
+	}
+
+	current := w.Segment()
+	for _, s := range segments {
+		if s >= segment || s >= current {
+			break
+		}
+
+		err = os.Remove(w.segmentPath(s))
+		if err != nil {
+			return err
+		}
+		metricTraceWALTruncated.Inc()
+	}
+
+	return nil
+}
+
+// Replay calls fn with every record of the segments present when the wal was opened.  A segment is read up to its
+// first corrupt or incomplete record, which is expected at the end of the last segment after a crash.
+func (w *traceWAL) Replay(fn func(segment int, tenantID string, traceID []byte, batch []byte) error) error {
+	for _, segment := range w.replaySegments {
+		b, err := ioutil.ReadFile(w.segmentPath(segment))
+		if err != nil {
+			return err
+		}
+
+		for len(b) > 0 {
+			var tenantID string
+			var traceID, batch []byte
+			tenantID, traceID, batch, b, err = decodeTraceWALRecord(b)
+			if err != nil {
+				metricTraceWALCorrupt.Inc()
+				level.Warn(util.Logger).Log("msg", "stopped replaying wal segment at corrupt record", "segment", segment, "err", err)
+				break
+			}
+
+			err = fn(segment, tenantID, traceID, batch)
+			if err != nil {
+				return err
+			}
+			metricTraceWALReplayed.Inc()
+		}
+	}
+
+	return nil
+}
+
+// Close syncs and closes the segment being written.
+func (w *traceWAL) Close() error {
+	close(w.done)
+	w.wg.Wait()
+
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+
+	if w.file == nil {
+		return nil
+	}
+
+	err := w.syncLocked()
+	if err != nil {
+		return err
+	}
+	err = w.file.Close()
+	w.file = nil
+	return err
+}
+
+func (w *traceWAL) syncLoop() {
+	defer w.wg.Done()
+
+	ticker := time.NewTicker(w.syncInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			w.mtx.Lock()
+			err := w.syncLocked()
+			w.mtx.Unlock()
+			if err != nil {
+				level.Error(util.Logger).Log("msg", "failed to sync wal", "err", err)
+			}
+		case <-w.done:
+			return
+		}
+	}
+}
+
+func (w *traceWAL) syncLocked() error {
+	if !w.dirty || w.file == nil {
+		return nil
+	}
+
+	err := w.file.Sync()
+	if err != nil {
+		return err
+	}
+	w.dirty = false
+	return nil
+}
+
+func (w *traceWAL) rotateLocked() error {
+	if w.file == nil {
+		return nil
+	}
+
+	err := w.syncLocked()
+	if err != nil {
+		return err
+	}
+	err = w.file.Close()
+	if err != nil {
+		return err
+	}
+
+	w.segment++
+	return w.openSegment()
+}
+
+func (w *traceWAL) openSegment() error {
+	f, err := os.OpenFile(w.segmentPath(w.segment), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+
+	w.file = f
+	w.size = 0
+	return nil
+}
+
+// segments returns the numbers of the segments in the wal in ascending order.
+func (w *traceWAL) segments() ([]int, error) {
+	files, err := ioutil.ReadDir(w.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var segments []int
+	for _, f := range files {
+		segment, err := strconv.Atoi(f.Name())
+		if err != nil || f.IsDir() {
+			continue
+		}
+		segments = append(segments, segment)
+	}
+	sort.Ints(segments)
+
+	return segments, nil
+}
+
+func (w *traceWAL) segmentPath(segment int) string {
+	return filepath.Join(w.dir, fmt.Sprintf("%08d", segment))
+}
+
+func appendUvarintBytes(b []byte, v []byte) []byte {
+	var buf [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(buf[:], uint64(len(v)))
+	b = append(b, buf[:n]...)
+	return append(b, v...)
+}
+
+// decodeTraceWALRecord decodes the first record of b and returns the rest.
+func decodeTraceWALRecord(b []byte) (tenantID string, traceID []byte, batch []byte, rest []byte, err error) {
+	if len(b) < traceWALHeaderSize {
+		return "", nil, nil, nil, io.ErrUnexpectedEOF
+	}
+
+	length := binary.LittleEndian.Uint32(b[0:])
+	checksum := binary.LittleEndian.Uint32(b[4:])
+	b = b[traceWALHeaderSize:]
+	if uint64(len(b)) < uint64(length) {
+		return "", nil, nil, nil, io.ErrUnexpectedEOF
+	}
+
+	payload, rest := b[:length], b[length:]
+	if crc32.Checksum(payload, castagnoli) != checksum {
+		return "", nil, nil, nil, errTraceWALCorrupt
+	}
+
+	tenant, payload, ok := readUvarintBytes(payload)
+	if !ok {
+		return "", nil, nil, nil, errTraceWALCorrupt
+	}
+	traceID, batch, ok = readUvarintBytes(payload)
+	if !ok {
+		return "", nil, nil, nil, errTraceWALCorrupt
+	}
+
+	return string(tenant), traceID, batch, rest, nil
+}
+
+func readUvarintBytes(b []byte) (v []byte, rest []byte, ok bool) {
+	length, n := binary.Uvarint(b)
+	if n <= 0 || uint64(len(b)-n) < length {
+		return nil, nil, false
+	}
+
+	b = b[n:]
+	return b[:length], b[length:], true
+}
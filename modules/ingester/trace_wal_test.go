@@ -0,0 +1,101 @@
+package ingester
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type walRecord struct {
+	segment  int
+	tenantID string
+	traceID  []byte
+	batch    []byte
+}
+
+func replayAll(t *testing.T, w *traceWAL) []walRecord {
+	var records []walRecord
+	err := w.Replay(func(segment int, tenantID string, traceID []byte, batch []byte) error {
+		records = append(records, walRecord{segment, tenantID, traceID, batch})
+		return nil
+	})
+	require.NoError(t, err)
+	return records
+}
+
+func TestTraceWALReplay(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("/tmp", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(tmpDir)
+
+	w, err := newTraceWAL(tmpDir, 0)
+	require.NoError(t, err)
+
+	segment, err := w.Append("tenant-a", []byte{0x01}, []byte("batch-1"))
+	require.NoError(t, err)
+	assert.Equal(t, 0, segment)
+	require.NoError(t, w.Rotate())
+	segment, err = w.Append("tenant-b", []byte{0x02}, []byte("batch-2"))
+	require.NoError(t, err)
+	assert.Equal(t, 1, segment)
+	_, err = w.Append("tenant-b", []byte{0x02}, nil)
+	require.NoError(t, err)
+
+	// a crash partway through a record leaves a torn tail
+	_, err = w.file.Write([]byte{0xff, 0x00, 0x00})
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	w, err = newTraceWAL(tmpDir, 0)
+	require.NoError(t, err)
+	defer w.Close()
+
+	assert.Equal(t, 2, w.Segment())
+	assert.Equal(t, []walRecord{
+		{0, "tenant-a", []byte{0x01}, []byte("batch-1")},
+		{1, "tenant-b", []byte{0x02}, []byte("batch-2")},
+		{1, "tenant-b", []byte{0x02}, []byte{}},
+	}, replayAll(t, w))
+
+	// the segment being written is never truncated
+	require.NoError(t, w.Truncate(1))
+	segments, err := w.segments()
+	require.NoError(t, err)
+	assert.Equal(t, []int{1, 2}, segments)
+
+	require.NoError(t, w.Truncate(100))
+	segments, err = w.segments()
+	require.NoError(t, err)
+	assert.Equal(t, []int{2}, segments)
+}
+
+func TestTraceWALCorruptRecord(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("/tmp", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(tmpDir)
+
+	w, err := newTraceWAL(tmpDir, 0)
+	require.NoError(t, err)
+	_, err = w.Append("tenant", []byte{0x01}, []byte("batch-1"))
+	require.NoError(t, err)
+	_, err = w.Append("tenant", []byte{0x01}, []byte("batch-2"))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	// flip a byte of the second record
+	b, err := ioutil.ReadFile(w.segmentPath(0))
+	require.NoError(t, err)
+	b[len(b)-1] ^= 0xff
+	require.NoError(t, ioutil.WriteFile(w.segmentPath(0), b, 0644))
+
+	w, err = newTraceWAL(tmpDir, 0)
+	require.NoError(t, err)
+	defer w.Close()
+
+	assert.Equal(t, []walRecord{
+		{0, "tenant", []byte{0x01}, []byte("batch-1")},
+	}, replayAll(t, w))
+}
@@ -0,0 +1,20 @@
+package jaeger
+
+import (
+	"flag"
+
+	"github.com/grafana/tempo/pkg/util"
+)
+
+// Config for the Jaeger storage plugin services.
+type Config struct {
+	Enabled bool `yaml:"enabled"`
+	// MaxServices is the number of services listed, the ones with the most spans first.
+	MaxServices int `yaml:"max_services"`
+}
+
+// RegisterFlagsAndApplyDefaults registers flags and applies defaults
+func (cfg *Config) RegisterFlagsAndApplyDefaults(prefix string, f *flag.FlagSet) {
+	f.BoolVar(&cfg.Enabled, util.PrefixConfig(prefix, "enabled"), false, "Serve the Jaeger storage plugin gRPC services on the queriers and distributors.")
+	f.IntVar(&cfg.MaxServices, util.PrefixConfig(prefix, "max-services"), 100, "Number of services listed to Jaeger Query.")
+}
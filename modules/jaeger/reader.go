@@ -0,0 +1,300 @@
+// Package jaeger serves the gRPC services of the Jaeger storage plugin on top of the querier and distributor, so Jaeger
+// Query can use Tempo as its span store directly instead of through tempo-query.
+//
+// Tempo only indexes the root span of each trace, so the operations of a service are the root span names of its
+// recent traces and searches by operation match the root span.  Services are the ones the ingesters received spans
+// of over roughly the last hour.  Dependencies aren't supported and none are returned.
+package jaeger
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"sort"
+
+	"github.com/jaegertracing/jaeger/model"
+	"github.com/jaegertracing/jaeger/proto-gen/storage_v1"
+	"github.com/jaegertracing/jaeger/storage/spanstore"
+	ot_pdata "go.opentelemetry.io/collector/consumer/pdata"
+	ot_jaeger "go.opentelemetry.io/collector/translator/trace/jaeger"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/grafana/tempo/modules/querier"
+	"github.com/grafana/tempo/pkg/tempopb"
+)
+
+const (
+	serviceNameTag = "service.name"
+
+	// spans sent per message of a stream, as the plugin does
+	spanBatchSize = 1000
+
+	// traces searched for the operations of a service, or for traces of an operation before they are filtered
+	maxOperationSearch = 1000
+)
+
+// Querier is the part of the querier the span reader needs.
+type Querier interface {
+	FindTraceByID(ctx context.Context, req *tempopb.TraceByIDRequest) (*tempopb.TraceByIDResponse, error)
+	Search(ctx context.Context, req *tempopb.SearchRequest) (*tempopb.SearchResponse, error)
+	Overview(ctx context.Context, limit int) (*querier.Overview, error)
+}
+
+// Reader implements the span and dependencies reader services of the plugin with a querier.
+type Reader struct {
+	cfg     Config
+	querier Querier
+}
+
+var _ storage_v1.SpanReaderPluginServer = (*Reader)(nil)
+var _ storage_v1.DependenciesReaderPluginServer = (*Reader)(nil)
+
+// NewReader returns a Reader finding traces with q.
+func NewReader(cfg Config, q Querier) *Reader {
+	return &Reader{
+		cfg:     cfg,
+		querier: q,
+	}
+}
+
+// GetTrace streams the spans of a trace.
+func (r *Reader) GetTrace(req *storage_v1.GetTraceRequest, stream storage_v1.SpanReaderPlugin_GetTraceServer) error {
+	trace, err := r.getTrace(stream.Context(), req.TraceID)
+	if err != nil {
+		return err
+	}
+	if trace == nil {
+		return status.Error(codes.NotFound, spanstore.ErrTraceNotFound.Error())
+	}
+
+	return sendSpans(trace.Spans, stream.Send)
+}
+
+// GetServices returns the services with the most spans received over roughly the last hour.
+func (r *Reader) GetServices(ctx context.Context, _ *storage_v1.GetServicesRequest) (*storage_v1.GetServicesResponse, error) {
+	overview, err := r.querier.Overview(ctx, r.cfg.MaxServices)
+	if err != nil {
+		return nil, err
+	}
+
+	services := make([]string, 0, len(overview.Services))
+	for _, s := range overview.Services {
+		services = append(services, s.ServiceName)
+	}
+	sort.Strings(services)
+
+	return &storage_v1.GetServicesResponse{
+		Services: services,
+	}, nil
+}
+
+// GetOperations returns the root span names of the recent traces started by the service.  The span kind isn't known
+// and is ignored.
+func (r *Reader) GetOperations(ctx context.Context, req *storage_v1.GetOperationsRequest) (*storage_v1.GetOperationsResponse, error) {
+	resp, err := r.querier.Search(ctx, &tempopb.SearchRequest{
+		Tags:  map[string]string{serviceNameTag: req.Service},
+		Limit: maxOperationSearch,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	seen := map[string]struct{}{}
+	for _, t := range resp.Traces {
+		if t.RootServiceName == req.Service && t.RootTraceName != "" {
+			seen[t.RootTraceName] = struct{}{}
+		}
+	}
+
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	operations := make([]*storage_v1.Operation, 0, len(names))
+	for _, name := range names {
+		operations = append(operations, &storage_v1.Operation{Name: name})
+	}
+
+	return &storage_v1.GetOperationsResponse{
+		OperationNames: names,
+		Operations:     operations,
+	}, nil
+}
+
+// FindTraces streams the spans of the traces matching the query, one trace after the other.  Traces found by the
+// search but no longer found by id are skipped.
+func (r *Reader) FindTraces(req *storage_v1.FindTracesRequest, stream storage_v1.SpanReaderPlugin_FindTracesServer) error {
+	ids, err := r.findTraceIDs(stream.Context(), req.Query)
+	if err != nil {
+		return err
+	}
+
+	for _, id := range ids {
+		trace, err := r.getTrace(stream.Context(), id)
+		if err != nil {
+			return err
+		}
+		if trace == nil {
+			continue
+		}
+
+		err = sendSpans(trace.Spans, stream.Send)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// FindTraceIDs returns the ids of the traces matching the query, newest first.
+func (r *Reader) FindTraceIDs(ctx context.Context, req *storage_v1.FindTraceIDsRequest) (*storage_v1.FindTraceIDsResponse, error) {
+	ids, err := r.findTraceIDs(ctx, req.Query)
+	if err != nil {
+		return nil, err
+	}
+
+	return &storage_v1.FindTraceIDsResponse{
+		TraceIDs: ids,
+	}, nil
+}
+
+// GetDependencies returns no dependencies since Tempo doesn't compute them.
+func (r *Reader) GetDependencies(context.Context, *storage_v1.GetDependenciesRequest) (*storage_v1.GetDependenciesResponse, error) {
+	return &storage_v1.GetDependenciesResponse{}, nil
+}
+
+// findTraceIDs searches for the traces of the query.  The service name and tags are searched as tags and the operation
+// name is matched against the root span of the traces found.
+func (r *Reader) findTraceIDs(ctx context.Context, query *storage_v1.TraceQueryParameters) ([]model.TraceID, error) {
+	if query == nil {
+		return nil, status.Error(codes.InvalidArgument, "missing query")
+	}
+
+	req := searchRequest(query)
+	limit := int(req.Limit)
+	if query.OperationName != "" {
+		// the operation is filtered after the search so search for more traces to have enough left
+		req.Limit = maxOperationSearch
+	}
+
+	resp, err := r.querier.Search(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]model.TraceID, 0, len(resp.Traces))
+	for _, t := range resp.Traces {
+		if query.OperationName != "" && (t.RootTraceName != query.OperationName || t.RootServiceName != query.ServiceName) {
+			continue
+		}
+		if limit > 0 && len(ids) >= limit {
+			break
+		}
+
+		b, err := hex.DecodeString(t.TraceID)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trace id %s found: %w", t.TraceID, err)
+		}
+		id, err := model.TraceIDFromBytes(b)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trace id %s found: %w", t.TraceID, err)
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, nil
+}
+
+// getTrace finds a trace and converts it to Jaeger spans.  It returns nil if the trace isn't found.
+func (r *Reader) getTrace(ctx context.Context, traceID model.TraceID) (*model.Trace, error) {
+	id := make([]byte, traceID.Size())
+	_, err := traceID.MarshalTo(id)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := r.querier.FindTraceByID(ctx, &tempopb.TraceByIDRequest{TraceID: id})
+	if err != nil {
+		return nil, err
+	}
+	if resp.Trace == nil || len(resp.Trace.Batches) == 0 {
+		return nil, nil
+	}
+
+	return TraceToJaeger(resp.Trace)
+}
+
+// TraceToJaeger converts a trace to Jaeger spans.  Each span carries its process.
+func TraceToJaeger(trace *tempopb.Trace) (*model.Trace, error) {
+	batches, err := ot_jaeger.InternalTracesToJaegerProto(ot_pdata.TracesFromOtlp(trace.Batches))
+	if err != nil {
+		return nil, fmt.Errorf("error translating to jaeger batches: %w", err)
+	}
+
+	jaegerTrace := &model.Trace{
+		Spans:      []*model.Span{},
+		ProcessMap: []model.Trace_ProcessMapping{},
+	}
+	for _, batch := range batches {
+		// otel proto conversion doesn't set jaeger spans for some reason.
+		for _, s := range batch.Spans {
+			s.Process = batch.Process
+		}
+
+		jaegerTrace.Spans = append(jaegerTrace.Spans, batch.Spans...)
+		jaegerTrace.ProcessMap = append(jaegerTrace.ProcessMap, model.Trace_ProcessMapping{
+			Process:   *batch.Process,
+			ProcessID: batch.Process.ServiceName,
+		})
+	}
+
+	return jaegerTrace, nil
+}
+
+// searchRequest converts the query to a search.  The operation name isn't searchable and is left out.
+func searchRequest(query *storage_v1.TraceQueryParameters) *tempopb.SearchRequest {
+	tags := make(map[string]string, len(query.Tags)+1)
+	for k, v := range query.Tags {
+		tags[k] = v
+	}
+	if query.ServiceName != "" {
+		tags[serviceNameTag] = query.ServiceName
+	}
+
+	req := &tempopb.SearchRequest{
+		Tags:          tags,
+		MinDurationMs: uint32(query.DurationMin.Milliseconds()),
+		MaxDurationMs: uint32(query.DurationMax.Milliseconds()),
+	}
+	if query.NumTraces > 0 {
+		req.Limit = uint32(query.NumTraces)
+	}
+	if !query.StartTimeMin.IsZero() {
+		req.Start = uint32(query.StartTimeMin.Unix())
+	}
+	if !query.StartTimeMax.IsZero() {
+		req.End = uint32(query.StartTimeMax.Unix())
+	}
+
+	return req
+}
+
+func sendSpans(spans []*model.Span, send func(*storage_v1.SpansResponseChunk) error) error {
+	for i := 0; i < len(spans); i += spanBatchSize {
+		chunk := make([]model.Span, 0, spanBatchSize)
+		for j := i; j < len(spans) && j < i+spanBatchSize; j++ {
+			chunk = append(chunk, *spans[j])
+		}
+
+		err := send(&storage_v1.SpansResponseChunk{Spans: chunk})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
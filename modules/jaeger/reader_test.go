@@ -0,0 +1,161 @@
+package jaeger
+
+import (
+	"context"
+	"encoding/hex"
+	"testing"
+	"time"
+
+	"github.com/jaegertracing/jaeger/model"
+	"github.com/jaegertracing/jaeger/proto-gen/storage_v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/grafana/tempo/modules/querier"
+	"github.com/grafana/tempo/pkg/tempopb"
+	"github.com/grafana/tempo/pkg/util/test"
+)
+
+type fakeQuerier struct {
+	traces   map[string]*tempopb.Trace
+	found    []*tempopb.TraceSearchMetadata
+	searches []*tempopb.SearchRequest
+	services []string
+}
+
+func (q *fakeQuerier) FindTraceByID(_ context.Context, req *tempopb.TraceByIDRequest) (*tempopb.TraceByIDResponse, error) {
+	return &tempopb.TraceByIDResponse{Trace: q.traces[hex.EncodeToString(req.TraceID)]}, nil
+}
+
+func (q *fakeQuerier) Search(_ context.Context, req *tempopb.SearchRequest) (*tempopb.SearchResponse, error) {
+	q.searches = append(q.searches, req)
+	return &tempopb.SearchResponse{Traces: q.found}, nil
+}
+
+func (q *fakeQuerier) Overview(context.Context, int) (*querier.Overview, error) {
+	overview := &querier.Overview{}
+	for _, s := range q.services {
+		overview.Services = append(overview.Services, querier.ServiceOverview{ServiceName: s})
+	}
+	return overview, nil
+}
+
+type fakeStream struct {
+	grpc.ServerStream
+	spans []model.Span
+}
+
+func (s *fakeStream) Context() context.Context {
+	return context.Background()
+}
+
+func (s *fakeStream) Send(chunk *storage_v1.SpansResponseChunk) error {
+	s.spans = append(s.spans, chunk.Spans...)
+	return nil
+}
+
+func traceID(b byte) model.TraceID {
+	id, _ := model.TraceIDFromBytes(append(make([]byte, 15), b))
+	return id
+}
+
+func newFakeQuerier() *fakeQuerier {
+	q := &fakeQuerier{traces: map[string]*tempopb.Trace{}}
+	for i := byte(1); i <= 3; i++ {
+		id := append(make([]byte, 15), i)
+		q.traces[hex.EncodeToString(id)] = test.MakeTrace(2, id)
+		q.found = append(q.found, &tempopb.TraceSearchMetadata{
+			TraceID:         hex.EncodeToString(id),
+			RootServiceName: "frontend",
+			RootTraceName:   "GET /" + string('a'+i%2),
+		})
+	}
+	// found by the search but since evicted
+	q.found = append(q.found, &tempopb.TraceSearchMetadata{
+		TraceID:         hex.EncodeToString(append(make([]byte, 15), 4)),
+		RootServiceName: "frontend",
+		RootTraceName:   "GET /a",
+	})
+	return q
+}
+
+func TestGetTrace(t *testing.T) {
+	q := newFakeQuerier()
+	r := NewReader(Config{}, q)
+
+	stream := &fakeStream{}
+	require.NoError(t, r.GetTrace(&storage_v1.GetTraceRequest{TraceID: traceID(1)}, stream))
+	expected, err := TraceToJaeger(q.traces[hex.EncodeToString(append(make([]byte, 15), 1))])
+	require.NoError(t, err)
+	require.Len(t, stream.spans, len(expected.Spans))
+	for _, s := range stream.spans {
+		assert.Equal(t, traceID(1), s.TraceID)
+		assert.NotNil(t, s.Process)
+	}
+
+	err = r.GetTrace(&storage_v1.GetTraceRequest{TraceID: traceID(4)}, &fakeStream{})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+}
+
+func TestFindTraces(t *testing.T) {
+	q := newFakeQuerier()
+	r := NewReader(Config{}, q)
+
+	start := time.Unix(1600000000, 0)
+	query := &storage_v1.TraceQueryParameters{
+		ServiceName:  "frontend",
+		Tags:         map[string]string{"http.status_code": "500"},
+		StartTimeMin: start,
+		StartTimeMax: start.Add(time.Hour),
+		DurationMin:  time.Second,
+		NumTraces:    2,
+	}
+	resp, err := r.FindTraceIDs(context.Background(), &storage_v1.FindTraceIDsRequest{Query: query})
+	require.NoError(t, err)
+	assert.Equal(t, []model.TraceID{traceID(1), traceID(2)}, resp.TraceIDs)
+	assert.Equal(t, &tempopb.SearchRequest{
+		Tags:          map[string]string{"http.status_code": "500", serviceNameTag: "frontend"},
+		MinDurationMs: 1000,
+		Limit:         2,
+		Start:         1600000000,
+		End:           1600003600,
+	}, q.searches[0])
+
+	// operations are matched against the root span after searching for more traces
+	query.OperationName = "GET /a"
+	query.NumTraces = 0
+	resp, err = r.FindTraceIDs(context.Background(), &storage_v1.FindTraceIDsRequest{Query: query})
+	require.NoError(t, err)
+	assert.Equal(t, []model.TraceID{traceID(2), traceID(4)}, resp.TraceIDs)
+	assert.Equal(t, uint32(maxOperationSearch), q.searches[1].Limit)
+
+	stream := &fakeStream{}
+	require.NoError(t, r.FindTraces(&storage_v1.FindTracesRequest{Query: query}, stream))
+	require.NotEmpty(t, stream.spans)
+	for _, s := range stream.spans {
+		assert.Equal(t, traceID(2), s.TraceID)
+	}
+}
+
+func TestGetServicesAndOperations(t *testing.T) {
+	q := newFakeQuerier()
+	q.services = []string{"frontend", "backend"}
+	r := NewReader(Config{MaxServices: 10}, q)
+
+	services, err := r.GetServices(context.Background(), &storage_v1.GetServicesRequest{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"backend", "frontend"}, services.Services)
+
+	operations, err := r.GetOperations(context.Background(), &storage_v1.GetOperationsRequest{Service: "frontend"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"GET /a", "GET /b"}, operations.OperationNames)
+	require.Len(t, operations.Operations, 2)
+	assert.Equal(t, map[string]string{serviceNameTag: "frontend"}, q.searches[0].Tags)
+
+	operations, err = r.GetOperations(context.Background(), &storage_v1.GetOperationsRequest{Service: "backend"})
+	require.NoError(t, err)
+	assert.Empty(t, operations.OperationNames)
+}
@@ -0,0 +1,48 @@
+package jaeger
+
+import (
+	"context"
+
+	"github.com/jaegertracing/jaeger/model"
+	"github.com/jaegertracing/jaeger/proto-gen/storage_v1"
+	ot_pdata "go.opentelemetry.io/collector/consumer/pdata"
+	ot_jaeger "go.opentelemetry.io/collector/translator/trace/jaeger"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/grafana/tempo/pkg/tempopb"
+)
+
+// Writer implements the span writer service of the plugin with a pusher, i.e. the distributor.
+type Writer struct {
+	pusher tempopb.PusherServer
+}
+
+var _ storage_v1.SpanWriterPluginServer = (*Writer)(nil)
+
+// NewWriter returns a Writer pushing spans with pusher.
+func NewWriter(pusher tempopb.PusherServer) *Writer {
+	return &Writer{
+		pusher: pusher,
+	}
+}
+
+// WriteSpan converts the span and its process to a batch and pushes it.
+func (w *Writer) WriteSpan(ctx context.Context, req *storage_v1.WriteSpanRequest) (*storage_v1.WriteSpanResponse, error) {
+	if req.Span == nil {
+		return nil, status.Error(codes.InvalidArgument, "missing span")
+	}
+
+	traces := ot_jaeger.ProtoBatchToInternalTraces(model.Batch{
+		Spans:   []*model.Span{req.Span},
+		Process: req.Span.Process,
+	})
+	for _, batch := range ot_pdata.TracesToOtlp(traces) {
+		_, err := w.pusher.Push(ctx, &tempopb.PushRequest{Batch: batch})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &storage_v1.WriteSpanResponse{}, nil
+}
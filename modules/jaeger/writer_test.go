@@ -0,0 +1,52 @@
+package jaeger
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jaegertracing/jaeger/model"
+	"github.com/jaegertracing/jaeger/proto-gen/storage_v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/tempo/pkg/tempopb"
+)
+
+type fakePusher struct {
+	reqs []*tempopb.PushRequest
+}
+
+func (p *fakePusher) Push(_ context.Context, req *tempopb.PushRequest) (*tempopb.PushResponse, error) {
+	p.reqs = append(p.reqs, req)
+	return &tempopb.PushResponse{}, nil
+}
+
+func TestWriteSpan(t *testing.T) {
+	pusher := &fakePusher{}
+	w := NewWriter(pusher)
+
+	_, err := w.WriteSpan(context.Background(), &storage_v1.WriteSpanRequest{})
+	assert.Error(t, err)
+
+	start := time.Unix(1600000000, 0)
+	_, err = w.WriteSpan(context.Background(), &storage_v1.WriteSpanRequest{Span: &model.Span{
+		TraceID:       traceID(1),
+		SpanID:        model.NewSpanID(2),
+		OperationName: "GET /a",
+		StartTime:     start,
+		Duration:      time.Second,
+		Process:       model.NewProcess("frontend", nil),
+	}})
+	require.NoError(t, err)
+	require.Len(t, pusher.reqs, 1)
+
+	batch := pusher.reqs[0].Batch
+	assert.Equal(t, "service.name", batch.Resource.Attributes[0].Key)
+	assert.Equal(t, "frontend", batch.Resource.Attributes[0].Value.GetStringValue())
+	span := batch.InstrumentationLibrarySpans[0].Spans[0]
+	assert.Equal(t, append(make([]byte, 15), 1), span.TraceId)
+	assert.Equal(t, "GET /a", span.Name)
+	assert.Equal(t, uint64(start.UnixNano()), span.StartTimeUnixNano)
+	assert.Equal(t, uint64(start.Add(time.Second).UnixNano()), span.EndTimeUnixNano)
+}
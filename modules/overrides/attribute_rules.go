@@ -0,0 +1,90 @@
+package overrides
+
+import (
+	"fmt"
+	"net"
+)
+
+// AttributeRule derives resource attributes, such as a region or environment, for the batches of a tenant that match
+// it.  A batch matches if it matches every matcher that is set.
+type AttributeRule struct {
+	// ClientCIDRs matches batches sent from an ip in one of the ranges.  Requires the receivers to record the client ip
+	// in the resource attributes.
+	ClientCIDRs []string `yaml:"client_cidrs"`
+	// Attribute matches batches with this resource attribute.  Value restricts it to a single value, otherwise any
+	// non-empty value matches.
+	Attribute string `yaml:"attribute"`
+	Value     string `yaml:"value"`
+	// Set are the resource attributes added to matching batches.  Attributes a batch already has are kept, so the first
+	// matching rule that sets an attribute wins.
+	Set map[string]string `yaml:"set"`
+
+	networks []*net.IPNet
+}
+
+// UnmarshalYAML implements yaml.Unmarshaler.  The ranges are parsed once so invalid rules are rejected when the
+// overrides are loaded.
+func (r *AttributeRule) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	type plain AttributeRule
+	if err := unmarshal((*plain)(r)); err != nil {
+		return err
+	}
+
+	if len(r.Set) == 0 {
+		return fmt.Errorf("attribute rule sets no attributes")
+	}
+	if len(r.ClientCIDRs) == 0 && r.Attribute == "" {
+		return fmt.Errorf("attribute rule has no client_cidrs or attribute to match")
+	}
+
+	r.networks = make([]*net.IPNet, 0, len(r.ClientCIDRs))
+	for _, cidr := range r.ClientCIDRs {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return fmt.Errorf("attribute rule has an invalid cidr: %w", err)
+		}
+		r.networks = append(r.networks, network)
+	}
+
+	return nil
+}
+
+// MatchesClientIP returns true if the rule has no client ranges or ip is in one of them.
+func (r *AttributeRule) MatchesClientIP(ip string) bool {
+	if len(r.ClientCIDRs) == 0 {
+		return true
+	}
+
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return false
+	}
+
+	networks := r.networks
+	if networks == nil {
+		// rules that weren't unmarshalled, such as in tests, are parsed on every call
+		for _, cidr := range r.ClientCIDRs {
+			if _, network, err := net.ParseCIDR(cidr); err == nil {
+				networks = append(networks, network)
+			}
+		}
+	}
+	for _, network := range networks {
+		if network.Contains(parsed) {
+			return true
+		}
+	}
+	return false
+}
+
+// MatchesAttribute returns true if the rule has no attribute or value matches it.  found is false if the batch doesn't
+// have the attribute.
+func (r *AttributeRule) MatchesAttribute(value string, found bool) bool {
+	if r.Attribute == "" {
+		return true
+	}
+	if !found || value == "" {
+		return false
+	}
+	return r.Value == "" || r.Value == value
+}
@@ -0,0 +1,168 @@
+package overrides
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/weaveworks/common/user"
+
+	"github.com/grafana/tempo/pkg/tenantmetrics"
+)
+
+var (
+	limitsDefaultsDesc = prometheus.NewDesc(
+		"tempo_limits_defaults",
+		"The default limits of every tenant.",
+		[]string{"limit_name"}, nil)
+	limitsOverridesDesc = prometheus.NewDesc(
+		"tempo_limits_overrides",
+		"The limits in effect for each tenant of the per tenant overrides file.",
+		[]string{"limit_name", "tenant"}, nil)
+	limitsOverridesTenants = tenantmetrics.NewConstMetric("tempo_limits_overrides", []string{"limit_name", "tenant"})
+)
+
+// ResolvedLimits are the limits in effect for a tenant, its overrides merged with the defaults.
+type ResolvedLimits struct {
+	IngestionRateLimit     int    `json:"ingestion_rate_limit"`
+	IngestionMaxBatchSize  int    `json:"ingestion_max_batch_size"`
+	MaxTracesPerUser       int    `json:"max_traces_per_user"`
+	MaxGlobalTracesPerUser int    `json:"max_global_traces_per_user"`
+	MaxSpansPerTrace       int    `json:"max_spans_per_trace"`
+	MaxBytesPerTrace       int    `json:"max_bytes_per_trace"`
+	BlockRetention         string `json:"block_retention"`
+
+	blockRetention time.Duration
+}
+
+// exportedLimits are the limits exported as metrics, by limit name.
+var exportedLimits = []struct {
+	name  string
+	value func(l ResolvedLimits) float64
+}{
+	{"ingestion_rate_limit", func(l ResolvedLimits) float64 { return float64(l.IngestionRateLimit) }},
+	{"ingestion_max_batch_size", func(l ResolvedLimits) float64 { return float64(l.IngestionMaxBatchSize) }},
+	{"max_traces_per_user", func(l ResolvedLimits) float64 { return float64(l.MaxTracesPerUser) }},
+	{"max_global_traces_per_user", func(l ResolvedLimits) float64 { return float64(l.MaxGlobalTracesPerUser) }},
+	{"max_spans_per_trace", func(l ResolvedLimits) float64 { return float64(l.MaxSpansPerTrace) }},
+	{"max_bytes_per_trace", func(l ResolvedLimits) float64 { return float64(l.MaxBytesPerTrace) }},
+	{"block_retention_seconds", func(l ResolvedLimits) float64 { return l.blockRetention.Seconds() }},
+}
+
+// Exporter exposes the limits in effect for every tenant, so operators and tenants don't need to read the overrides
+// file.  It serves them as JSON and collects them as metrics.  Tenants outside the overrides file have the default
+// limits and are only exported as the defaults.  Tenants are exported within the tenant metrics limits.
+type Exporter struct {
+	overrides      *Overrides
+	blockRetention time.Duration
+}
+
+// NewExporter exports the limits of o.  blockRetention is the compactor's retention, which applies to tenants without
+// a block retention override.
+func NewExporter(o *Overrides, blockRetention time.Duration) *Exporter {
+	return &Exporter{
+		overrides:      o,
+		blockRetention: blockRetention,
+	}
+}
+
+// Limits returns the limits in effect for the tenant.
+func (e *Exporter) Limits(userID string) ResolvedLimits {
+	return e.resolve(e.overrides.getOverridesForUser(userID))
+}
+
+// DefaultLimits returns the limits of tenants outside the overrides file.
+func (e *Exporter) DefaultLimits() ResolvedLimits {
+	return e.resolve(e.overrides.defaultLimits)
+}
+
+func (e *Exporter) resolve(l *Limits) ResolvedLimits {
+	retention := l.BlockRetention
+	if retention == 0 {
+		retention = e.blockRetention
+	}
+
+	return ResolvedLimits{
+		IngestionRateLimit:     l.IngestionRateSpans,
+		IngestionMaxBatchSize:  l.IngestionMaxBatchSize,
+		MaxTracesPerUser:       l.MaxLocalTracesPerUser,
+		MaxGlobalTracesPerUser: l.MaxGlobalTracesPerUser,
+		MaxSpansPerTrace:       l.MaxSpansPerTrace,
+		MaxBytesPerTrace:       l.MaxBytesPerTrace,
+		BlockRetention:         retention.String(),
+		blockRetention:         retention,
+	}
+}
+
+type overridesStatus struct {
+	IngestionRateStrategy string                    `json:"ingestion_rate_strategy"`
+	Defaults              ResolvedLimits            `json:"defaults"`
+	Tenants               map[string]ResolvedLimits `json:"tenants"`
+}
+
+// ServeHTTP returns the default limits and the limits of every tenant of the overrides file.  The tenant parameter
+// returns the limits of a single tenant instead, whether or not it is in the overrides file.  As it lists every
+// tenant it's meant for operators.
+func (e *Exporter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	tenants := map[string]ResolvedLimits{}
+	if tenantID := r.URL.Query().Get("tenant"); tenantID != "" {
+		tenants[tenantID] = e.Limits(tenantID)
+	} else {
+		for _, tenantID := range e.overrides.TenantIDs() {
+			tenants[tenantID] = e.Limits(tenantID)
+		}
+	}
+
+	e.writeStatus(w, tenants)
+}
+
+// TenantHandler returns the default limits and the limits of the tenant of the request, so tenants only see their own.
+// It must be served behind the auth middleware.
+func (e *Exporter) TenantHandler(w http.ResponseWriter, r *http.Request) {
+	tenantID, err := user.ExtractOrgID(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	e.writeStatus(w, map[string]ResolvedLimits{tenantID: e.Limits(tenantID)})
+}
+
+func (e *Exporter) writeStatus(w http.ResponseWriter, tenants map[string]ResolvedLimits) {
+	status := overridesStatus{
+		IngestionRateStrategy: e.overrides.IngestionRateStrategy(),
+		Defaults:              e.DefaultLimits(),
+		Tenants:               tenants,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(status); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// Describe implements prometheus.Collector.
+func (e *Exporter) Describe(ch chan<- *prometheus.Desc) {
+	ch <- limitsDefaultsDesc
+	ch <- limitsOverridesDesc
+}
+
+// Collect implements prometheus.Collector.
+func (e *Exporter) Collect(ch chan<- prometheus.Metric) {
+	defaults := e.DefaultLimits()
+	for _, limit := range exportedLimits {
+		ch <- prometheus.MustNewConstMetric(limitsDefaultsDesc, prometheus.GaugeValue, limit.value(defaults), limit.name)
+	}
+
+	for _, tenantID := range e.overrides.TenantIDs() {
+		if !limitsOverridesTenants.Exported(tenantID) {
+			continue
+		}
+
+		limits := e.Limits(tenantID)
+		for _, limit := range exportedLimits {
+			ch <- prometheus.MustNewConstMetric(limitsOverridesDesc, prometheus.GaugeValue, limit.value(limits), limit.name, tenantID)
+		}
+	}
+}
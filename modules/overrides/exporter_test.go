@@ -0,0 +1,125 @@
+package overrides
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/cortexproject/cortex/pkg/util/services"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/weaveworks/common/user"
+
+	"github.com/grafana/tempo/pkg/tenantmetrics"
+)
+
+func TestExporter(t *testing.T) {
+	overridesFile := filepath.Join(t.TempDir(), "overrides.yaml")
+	require.NoError(t, ioutil.WriteFile(overridesFile, []byte(`
+overrides:
+  user2:
+    max_traces_per_user: 20
+  user1:
+    ingestion_rate_limit: 10
+    block_retention: 48h
+`), os.ModePerm))
+
+	// the runtime config manager registers its metrics with the default registerer
+	defaultRegisterer := prometheus.DefaultRegisterer
+	prometheus.DefaultRegisterer = prometheus.NewRegistry()
+	defer func() { prometheus.DefaultRegisterer = defaultRegisterer }()
+
+	o, err := NewOverrides(Limits{
+		IngestionRateStrategy:   LocalIngestionRateStrategy,
+		IngestionRateSpans:      5,
+		MaxLocalTracesPerUser:   2,
+		PerTenantOverrideConfig: overridesFile,
+		PerTenantOverridePeriod: time.Hour,
+	})
+	require.NoError(t, err)
+	require.NoError(t, services.StartAndAwaitRunning(context.Background(), o))
+	defer func() {
+		require.NoError(t, services.StopAndAwaitTerminated(context.Background(), o))
+	}()
+
+	assert.Equal(t, []string{"user1", "user2"}, o.TenantIDs())
+
+	e := NewExporter(o, 24*time.Hour)
+
+	// the status lists the tenants of the overrides file
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest("GET", "/api/status/overrides", nil))
+	var status overridesStatus
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &status))
+	assert.Equal(t, LocalIngestionRateStrategy, status.IngestionRateStrategy)
+	assert.Equal(t, 5, status.Defaults.IngestionRateLimit)
+	assert.Equal(t, "24h0m0s", status.Defaults.BlockRetention)
+	require.Len(t, status.Tenants, 2)
+	assert.Equal(t, 10, status.Tenants["user1"].IngestionRateLimit)
+	assert.Equal(t, 2, status.Tenants["user1"].MaxTracesPerUser)
+	assert.Equal(t, "48h0m0s", status.Tenants["user1"].BlockRetention)
+	assert.Equal(t, 20, status.Tenants["user2"].MaxTracesPerUser)
+	assert.Equal(t, "24h0m0s", status.Tenants["user2"].BlockRetention)
+
+	// a tenant outside the overrides file has the defaults
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest("GET", "/api/status/overrides?tenant=user3", nil))
+	status = overridesStatus{}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &status))
+	require.Len(t, status.Tenants, 1)
+	assert.Equal(t, status.Defaults, status.Tenants["user3"])
+
+	// tenants only see their own limits
+	rec = httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/api/status/overrides", nil)
+	e.TenantHandler(rec, req.WithContext(user.InjectOrgID(req.Context(), "user1")))
+	status = overridesStatus{}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &status))
+	require.Len(t, status.Tenants, 1)
+	assert.Equal(t, 10, status.Tenants["user1"].IngestionRateLimit)
+	assert.Equal(t, 5, status.Defaults.IngestionRateLimit)
+
+	rec = httptest.NewRecorder()
+	e.TenantHandler(rec, httptest.NewRequest("GET", "/api/status/overrides?tenant=user2", nil))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	// metrics
+	reg := prometheus.NewRegistry()
+	require.NoError(t, reg.Register(e))
+	families, err := reg.Gather()
+	require.NoError(t, err)
+
+	values := map[string]float64{}
+	for _, family := range families {
+		for _, m := range family.GetMetric() {
+			key := family.GetName()
+			for _, l := range m.GetLabel() {
+				key += "," + l.GetValue()
+			}
+			values[key] = m.GetGauge().GetValue()
+		}
+	}
+	assert.Len(t, values, 3*len(exportedLimits))
+	assert.Equal(t, float64(5), values["tempo_limits_defaults,ingestion_rate_limit"])
+	assert.Equal(t, float64(10), values["tempo_limits_overrides,ingestion_rate_limit,user1"])
+	assert.Equal(t, float64(48*60*60), values["tempo_limits_overrides,block_retention_seconds,user1"])
+	assert.Equal(t, float64(20), values["tempo_limits_overrides,max_traces_per_user,user2"])
+
+	// tenants over the tenant metrics limit aren't exported
+	tenantmetrics.Configure(tenantmetrics.Config{MaxTenants: 1})
+	defer tenantmetrics.Configure(tenantmetrics.Config{})
+	families, err = reg.Gather()
+	require.NoError(t, err)
+	for _, family := range families {
+		if family.GetName() == "tempo_limits_overrides" {
+			assert.Len(t, family.GetMetric(), len(exportedLimits))
+		}
+	}
+}
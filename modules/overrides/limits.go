@@ -20,28 +20,124 @@ type Limits struct {
 	IngestionRateStrategy string `yaml:"ingestion_rate_strategy"`
 	IngestionRateSpans    int    `yaml:"ingestion_rate_limit"`
 	IngestionMaxBatchSize int    `yaml:"ingestion_max_batch_size"`
+	MaxAttributesPerSpan  int    `yaml:"max_attributes_per_span"`
+	// Span and resource attributes whose string values are encrypted before they are written.  Requires the
+	// distributor attribute encryption to be configured.
+	EncryptedAttributes []string `yaml:"encrypted_attributes"`
+	// Ingestion rate limits of the spans received through a receiver, keyed by receiver (e.g. zipkin) or by receiver and
+	// transport (e.g. otlp/grpc).  Applied on top of the tenant's ingestion rate limit.  Yaml only.
+	ReceiverLimits map[string]ReceiverLimit `yaml:"receiver_limits"`
+	// Names of the distributor forwarders accepted spans are also sent to.
+	Forwarders []string `yaml:"forwarders"`
+	// Rules deriving resource attributes from the client ip or other attributes before batches are written.
+	AttributeRules []AttributeRule `yaml:"attribute_rules"`
+	// Number of ingesters the tenant's traces are spread across.  0 to use all ingesters.
+	IngestionTenantShardSize int `yaml:"ingestion_tenant_shard_size"`
+	// Head sampling of the tenant's traces.  0 uses the distributor's sampling config.
+	SamplingRatio             float64 `yaml:"sampling_ratio"`
+	SamplingMaxSpansPerSecond int     `yaml:"sampling_max_spans_per_second"`
+	// Acknowledge pushes once they're written to the distributor's disk queue instead of once the ingesters accepted
+	// them.  Requires the distributor async ack queue to be configured.
+	AsyncWriteAck bool `yaml:"async_write_ack"`
 
 	// Ingester enforced limits.
 	MaxLocalTracesPerUser  int `yaml:"max_traces_per_user"`
 	MaxGlobalTracesPerUser int `yaml:"max_global_traces_per_user"`
 	MaxSpansPerTrace       int `yaml:"max_spans_per_trace"`
+	MaxBytesPerTrace       int `yaml:"max_bytes_per_trace"`
+	FlushPriority          int `yaml:"flush_priority"`
+
+	// BestEffort tenants have their pushes rejected first when a distributor or ingester is above its push memory
+	// watermark.
+	BestEffort bool `yaml:"best_effort"`
+
+	// MaxAttributeKeysPerBlock bounds the unique span attribute keys accumulated in an ingester head block.  Once
+	// reached attributes with new keys are dropped.
+	MaxAttributeKeysPerBlock int `yaml:"max_attribute_keys_per_block"`
+
+	// Querier enforced limits.
+	MaxBytesScannedPerHour int `yaml:"max_bytes_scanned_per_hour"`
+	MaxBytesScannedPerDay  int `yaml:"max_bytes_scanned_per_day"`
+	// MaxBytesPerTraceQuery bounds the size of the partial traces a single trace lookup receives, replicas included.
+	MaxBytesPerTraceQuery int `yaml:"max_bytes_per_trace_query"`
+	// MaxConcurrentQueries bounds the trace lookups and searches of the tenant running at once on each querier.
+	MaxConcurrentQueries int `yaml:"max_concurrent_queries"`
+	// SearchDisabled refuses the tenant's searches.  An emergency switch for when a query pattern is overloading the
+	// cluster, trace lookups are still served.
+	SearchDisabled bool `yaml:"search_disabled"`
+
+	// Processors of the metrics generators, span-metrics and service-graphs, run for the tenant's spans.  Spans are
+	// only sent to the generators for tenants with processors.
+	MetricsGeneratorProcessors []string `yaml:"metrics_generator_processors"`
+
+	// Compactor enforced limits.
+	BlockRetention     time.Duration `yaml:"block_retention"`     // 0 keeps blocks for the compactor's block_retention
+	CompactionWindow   time.Duration `yaml:"compaction_window"`   // 0 compacts across the compactor's compaction_window
+	CompactionDisabled bool          `yaml:"compaction_disabled"` // stops compacting and reindexing the tenant's blocks
+
+	// Operator defined labels such as team or environment.  Recorded in the meta of every block written for the
+	// tenant and exposed in blocklist metrics for cost attribution.
+	CostAttributionLabels map[string]string `yaml:"cost_attribution_labels"`
 
 	// Config for overrides, convenient if it goes here.
 	PerTenantOverrideConfig string        `yaml:"per_tenant_override_config"`
 	PerTenantOverridePeriod time.Duration `yaml:"per_tenant_override_period"`
 }
 
+// ReceiverLimit is the ingestion rate limit of the spans a tenant sends through a receiver.
+type ReceiverLimit struct {
+	// IngestionRateSpans is the spans per second accepted through the receiver.  0 doesn't limit the receiver.
+	IngestionRateSpans int `yaml:"ingestion_rate_limit"`
+	// IngestionMaxBatchSize is the burst size in spans.  0 uses the tenant's ingestion max batch size.
+	IngestionMaxBatchSize int `yaml:"ingestion_max_batch_size"`
+}
+
+// defaultLimits are the limits per tenant overrides start from.  Set by NewOverrides.
+var defaultLimits *Limits
+
+// UnmarshalYAML implements yaml.Unmarshaler.  Fields a tenant doesn't override keep the default limits instead of
+// their zero values.
+func (l *Limits) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	if defaultLimits != nil {
+		*l = *defaultLimits
+	}
+
+	type plain Limits
+	return unmarshal((*plain)(l))
+}
+
 // RegisterFlags adds the flags required to config this to the given FlagSet
 func (l *Limits) RegisterFlags(f *flag.FlagSet) {
 	// Distributor Limits
 	f.StringVar(&l.IngestionRateStrategy, "distributor.rate-limit-strategy", "local", "Whether the various ingestion rate limits should be applied individually to each distributor instance (local), or evenly shared across the cluster (global).")
 	f.IntVar(&l.IngestionRateSpans, "distributor.ingestion-rate-limit", 100000, "Per-user ingestion rate limit in spans per second.")
 	f.IntVar(&l.IngestionMaxBatchSize, "distributor.ingestion-max-batch-size", 1000, "Per-user allowed ingestion max batch size (in number of spans).")
+	f.IntVar(&l.MaxAttributesPerSpan, "distributor.max-attributes-per-span", 0, "Maximum number of attributes per span.  Attributes past the limit are truncated.  0 to disable.")
+	f.IntVar(&l.IngestionTenantShardSize, "distributor.ingestion-tenant-shard-size", 0, "Number of ingesters each user's traces are spread across.  0 to spread them across all ingesters.")
+	f.BoolVar(&l.AsyncWriteAck, "distributor.async-write-ack", false, "Acknowledge this user's pushes once they're written to the distributor's disk queue rather than once the ingesters accepted them.")
+	f.Float64Var(&l.SamplingRatio, "distributor.tenant-sampling-ratio", 0, "Per-user fraction of traces kept by head sampling. 0 to use the distributor's sampling ratio.")
+	f.IntVar(&l.SamplingMaxSpansPerSecond, "distributor.tenant-sampling-max-spans-per-second", 0, "Per-user spans per second each distributor keeps by head sampling. 0 to use the distributor's limit.")
 
 	// Ingester limits
 	f.IntVar(&l.MaxLocalTracesPerUser, "ingester.max-traces-per-user", 10e3, "Maximum number of active traces per user, per ingester. 0 to disable.")
 	f.IntVar(&l.MaxGlobalTracesPerUser, "ingester.max-global-traces-per-user", 0, "Maximum number of active traces per user, across the cluster. 0 to disable.")
 	f.IntVar(&l.MaxSpansPerTrace, "ingester.max-spans-per-trace", 50e3, "Maximum number of spans per trace.  0 to disable.")
+	f.IntVar(&l.MaxBytesPerTrace, "ingester.max-bytes-per-trace", 0, "Maximum size of a live trace in bytes.  0 to disable.")
+	f.IntVar(&l.FlushPriority, "ingester.flush-priority", 0, "Priority of this user's blocks in the ingester flush queues. Blocks with a higher priority are flushed first.")
+	f.BoolVar(&l.BestEffort, "ingester.best-effort", false, "Reject this user's pushes when a distributor or ingester is above its push memory watermark.")
+	f.IntVar(&l.MaxAttributeKeysPerBlock, "ingester.max-attribute-keys-per-block", 0, "Maximum number of unique span attribute keys per head block.  Attributes with new keys past the limit are dropped.  0 to disable.")
+
+	// Querier limits
+	f.IntVar(&l.MaxBytesScannedPerHour, "querier.max-bytes-scanned-per-hour", 0, "Maximum number of bytes a user's queries may read from the backend per hour, per querier. 0 to disable.")
+	f.IntVar(&l.MaxBytesScannedPerDay, "querier.max-bytes-scanned-per-day", 0, "Maximum number of bytes a user's queries may read from the backend per day, per querier. 0 to disable.")
+	f.IntVar(&l.MaxBytesPerTraceQuery, "querier.max-bytes-per-trace-query", 0, "Maximum size in bytes of the partial traces, replicas included, a single trace lookup of a user may receive. 0 to disable.")
+	f.IntVar(&l.MaxConcurrentQueries, "querier.max-concurrent-queries", 0, "Maximum number of trace lookups and searches of a user running at once, per querier. 0 to disable.")
+	f.BoolVar(&l.SearchDisabled, "querier.search-disabled", false, "Refuse this user's searches.")
+
+	// Compactor limits
+	f.DurationVar(&l.BlockRetention, "compactor.tenant-block-retention", 0, "Per-user duration to keep blocks/traces. 0 to use the compactor's block retention.")
+	f.DurationVar(&l.CompactionWindow, "compactor.tenant-compaction-window", 0, "Per-user time window across which blocks are compacted. 0 to use the compactor's compaction window.")
+	f.BoolVar(&l.CompactionDisabled, "compactor.tenant-compaction-disabled", false, "Stop compacting and reindexing this user's blocks. Retention still applies.")
 
 	f.StringVar(&l.PerTenantOverrideConfig, "limits.per-user-override-config", "", "File name of per-user overrides.")
 	f.DurationVar(&l.PerTenantOverridePeriod, "limits.per-user-override-period", 10*time.Second, "Period with this to reload the overrides.")
@@ -4,6 +4,8 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"sort"
+	"time"
 
 	"github.com/cortexproject/cortex/pkg/util/runtimeconfig"
 	"github.com/cortexproject/cortex/pkg/util/services"
@@ -40,6 +42,7 @@ type Overrides struct {
 
 	defaultLimits *Limits
 	tenantLimits  TenantLimits
+	tenantIDs     func() []string
 
 	// Manager for subservices
 	subservices        *services.Manager
@@ -51,7 +54,17 @@ type Overrides struct {
 // are defaulted to those values.  As such, the last call to NewOverrides will
 // become the new global defaults.
 func NewOverrides(defaults Limits) (*Overrides, error) {
+	// an unknown strategy would silently fall back to local limits, which scale with the number of distributors
+	switch defaults.IngestionRateStrategy {
+	case "", LocalIngestionRateStrategy, GlobalIngestionRateStrategy:
+	default:
+		return nil, fmt.Errorf("invalid ingestion rate strategy %q. must be %s or %s", defaults.IngestionRateStrategy, LocalIngestionRateStrategy, GlobalIngestionRateStrategy)
+	}
+
+	defaultLimits = &defaults
+
 	var tenantLimits TenantLimits
+	var tenantIDs func() []string
 	subservices := []services.Service(nil)
 
 	if defaults.PerTenantOverrideConfig != "" {
@@ -65,11 +78,13 @@ func NewOverrides(defaults Limits) (*Overrides, error) {
 			return nil, fmt.Errorf("failed to create runtime config manager %w", err)
 		}
 		tenantLimits = tenantLimitsFromRuntimeConfig(runtimeCfgMgr)
+		tenantIDs = tenantIDsFromRuntimeConfig(runtimeCfgMgr)
 		subservices = append(subservices, runtimeCfgMgr)
 	}
 
 	o := &Overrides{
 		tenantLimits:  tenantLimits,
+		tenantIDs:     tenantIDs,
 		defaultLimits: &defaults,
 	}
 
@@ -144,6 +159,86 @@ func (o *Overrides) MaxSpansPerTrace(userID string) int {
 	return o.getOverridesForUser(userID).MaxSpansPerTrace
 }
 
+// MaxBytesPerTrace returns the maximum size in bytes of a live trace.
+func (o *Overrides) MaxBytesPerTrace(userID string) int {
+	return o.getOverridesForUser(userID).MaxBytesPerTrace
+}
+
+// MaxAttributeKeysPerBlock returns the maximum number of unique span attribute keys in an ingester head block.
+func (o *Overrides) MaxAttributeKeysPerBlock(userID string) int {
+	return o.getOverridesForUser(userID).MaxAttributeKeysPerBlock
+}
+
+// FlushPriority is the priority of this tenant's blocks in the ingester flush queues.  Higher is flushed first.
+func (o *Overrides) FlushPriority(userID string) int {
+	return o.getOverridesForUser(userID).FlushPriority
+}
+
+// BestEffort returns true if this tenant's pushes are rejected first when memory runs short.
+func (o *Overrides) BestEffort(userID string) bool {
+	return o.getOverridesForUser(userID).BestEffort
+}
+
+// CostAttributionLabels returns a copy of the operator defined labels for this tenant.
+func (o *Overrides) CostAttributionLabels(userID string) map[string]string {
+	labels := o.getOverridesForUser(userID).CostAttributionLabels
+	if len(labels) == 0 {
+		return nil
+	}
+
+	copied := make(map[string]string, len(labels))
+	for k, v := range labels {
+		copied[k] = v
+	}
+	return copied
+}
+
+// MaxBytesScannedPerHour is the number of bytes a tenant's queries may read from the backend per hour.
+func (o *Overrides) MaxBytesScannedPerHour(userID string) int {
+	return o.getOverridesForUser(userID).MaxBytesScannedPerHour
+}
+
+// MaxBytesScannedPerDay is the number of bytes a tenant's queries may read from the backend per day.
+func (o *Overrides) MaxBytesScannedPerDay(userID string) int {
+	return o.getOverridesForUser(userID).MaxBytesScannedPerDay
+}
+
+// MaxBytesPerTraceQuery is the size of the partial traces a single trace lookup of the tenant may receive.
+func (o *Overrides) MaxBytesPerTraceQuery(userID string) int {
+	return o.getOverridesForUser(userID).MaxBytesPerTraceQuery
+}
+
+// MaxConcurrentQueries is the number of trace lookups and searches of the tenant that may run at once on a querier.
+func (o *Overrides) MaxConcurrentQueries(userID string) int {
+	return o.getOverridesForUser(userID).MaxConcurrentQueries
+}
+
+// SearchDisabled is true if the tenant's searches are refused.
+func (o *Overrides) SearchDisabled(userID string) bool {
+	return o.getOverridesForUser(userID).SearchDisabled
+}
+
+// MetricsGeneratorProcessors are the metrics generator processors run for this tenant's spans.
+func (o *Overrides) MetricsGeneratorProcessors(userID string) []string {
+	return o.getOverridesForUser(userID).MetricsGeneratorProcessors
+}
+
+// BlockRetention is how long the compactor keeps this tenant's blocks.  0 if the compactor's retention applies.
+func (o *Overrides) BlockRetention(userID string) time.Duration {
+	return o.getOverridesForUser(userID).BlockRetention
+}
+
+// CompactionWindow is the time window across which this tenant's blocks are compacted.  0 if the compactor's window
+// applies.
+func (o *Overrides) CompactionWindow(userID string) time.Duration {
+	return o.getOverridesForUser(userID).CompactionWindow
+}
+
+// CompactionDisabled is true if this tenant's blocks must not be compacted or reindexed.
+func (o *Overrides) CompactionDisabled(userID string) bool {
+	return o.getOverridesForUser(userID).CompactionDisabled
+}
+
 // IngestionRateSpans is the number of spans per second allowed for this tenant
 func (o *Overrides) IngestionRateSpans(userID string) float64 {
 	return float64(o.getOverridesForUser(userID).IngestionRateSpans)
@@ -154,6 +249,79 @@ func (o *Overrides) IngestionMaxBatchSize(userID string) int {
 	return o.getOverridesForUser(userID).IngestionMaxBatchSize
 }
 
+// SamplingRatio is the fraction of this tenant's traces kept by head sampling.  0 if the distributor's ratio applies.
+func (o *Overrides) SamplingRatio(userID string) float64 {
+	return o.getOverridesForUser(userID).SamplingRatio
+}
+
+// SamplingMaxSpansPerSecond is the spans per second each distributor keeps of this tenant.  0 if the distributor's
+// limit applies.
+func (o *Overrides) SamplingMaxSpansPerSecond(userID string) int {
+	return o.getOverridesForUser(userID).SamplingMaxSpansPerSecond
+}
+
+// AsyncWriteAck is true if this tenant's pushes are acknowledged once they're queued on the distributor's disk.
+func (o *Overrides) AsyncWriteAck(userID string) bool {
+	return o.getOverridesForUser(userID).AsyncWriteAck
+}
+
+// MaxAttributesPerSpan is the number of attributes a span may carry for this tenant.
+func (o *Overrides) MaxAttributesPerSpan(userID string) int {
+	return o.getOverridesForUser(userID).MaxAttributesPerSpan
+}
+
+// EncryptedAttributes are the attribute keys whose values are encrypted for this tenant.
+func (o *Overrides) EncryptedAttributes(userID string) []string {
+	return o.getOverridesForUser(userID).EncryptedAttributes
+}
+
+// ReceiverLimitKey returns the key of the receiver limits that limits the spans the tenant sends through the receiver
+// and transport.  A limit of the receiver and transport takes precedence over a limit of the receiver.  ok is false if
+// the spans aren't limited.
+func (o *Overrides) ReceiverLimitKey(userID string, receiver string, transport string) (key string, ok bool) {
+	if receiver == "" {
+		return "", false
+	}
+
+	limits := o.getOverridesForUser(userID).ReceiverLimits
+	for _, key := range []string{receiver + "/" + transport, receiver} {
+		if limit, ok := limits[key]; ok && limit.IngestionRateSpans > 0 {
+			return key, true
+		}
+	}
+	return "", false
+}
+
+// ReceiverLimit is the ingestion rate limit of the receiver limits key of this tenant.
+func (o *Overrides) ReceiverLimit(userID string, key string) ReceiverLimit {
+	return o.getOverridesForUser(userID).ReceiverLimits[key]
+}
+
+// Forwarders are the names of the distributor forwarders this tenant's spans are also sent to.
+func (o *Overrides) Forwarders(userID string) []string {
+	return o.getOverridesForUser(userID).Forwarders
+}
+
+// AttributeRules are the rules deriving resource attributes for this tenant's batches.
+func (o *Overrides) AttributeRules(userID string) []AttributeRule {
+	return o.getOverridesForUser(userID).AttributeRules
+}
+
+// IngestionTenantShardSize is the number of ingesters this tenant's traces are spread across.  0 if they are spread
+// across all ingesters.
+func (o *Overrides) IngestionTenantShardSize(userID string) int {
+	return o.getOverridesForUser(userID).IngestionTenantShardSize
+}
+
+// TenantIDs returns the sorted ids of the tenants with limits in the per tenant overrides file.  All other tenants have
+// the default limits.
+func (o *Overrides) TenantIDs() []string {
+	if o.tenantIDs == nil {
+		return nil
+	}
+	return o.tenantIDs()
+}
+
 func (o *Overrides) getOverridesForUser(userID string) *Limits {
 	if o.tenantLimits != nil {
 		l := o.tenantLimits(userID)
@@ -177,3 +345,19 @@ func tenantLimitsFromRuntimeConfig(c *runtimeconfig.Manager) TenantLimits {
 		return cfg.TenantLimits[userID]
 	}
 }
+
+func tenantIDsFromRuntimeConfig(c *runtimeconfig.Manager) func() []string {
+	return func() []string {
+		cfg, ok := c.GetConfig().(*perTenantOverrides)
+		if !ok || cfg == nil {
+			return nil
+		}
+
+		tenantIDs := make([]string, 0, len(cfg.TenantLimits))
+		for tenantID := range cfg.TenantLimits {
+			tenantIDs = append(tenantIDs, tenantID)
+		}
+		sort.Strings(tenantIDs)
+		return tenantIDs
+	}
+}
@@ -9,6 +9,7 @@ import (
 	"time"
 
 	"github.com/cortexproject/cortex/pkg/util/services"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"gopkg.in/yaml.v2"
@@ -112,3 +113,88 @@ func TestOverrides(t *testing.T) {
 		})
 	}
 }
+
+func TestInvalidIngestionRateStrategy(t *testing.T) {
+	_, err := NewOverrides(Limits{IngestionRateStrategy: "Global"})
+	assert.Error(t, err)
+
+	o, err := NewOverrides(Limits{IngestionRateStrategy: GlobalIngestionRateStrategy})
+	require.NoError(t, err)
+	assert.Equal(t, GlobalIngestionRateStrategy, o.IngestionRateStrategy())
+}
+
+func TestReceiverLimitKey(t *testing.T) {
+	o, err := NewOverrides(Limits{
+		ReceiverLimits: map[string]ReceiverLimit{
+			"zipkin":    {IngestionRateSpans: 10},
+			"otlp/http": {IngestionRateSpans: 20, IngestionMaxBatchSize: 5},
+			"jaeger":    {},
+		},
+	})
+	require.NoError(t, err)
+
+	for _, tc := range []struct {
+		receiver, transport string
+		key                 string
+	}{
+		{"zipkin", "http", "zipkin"},
+		{"otlp", "http", "otlp/http"},
+		{"otlp", "grpc", ""},
+		{"jaeger", "grpc", ""},
+		{"", "", ""},
+	} {
+		key, ok := o.ReceiverLimitKey("user1", tc.receiver, tc.transport)
+		assert.Equal(t, tc.key, key, tc.receiver+"/"+tc.transport)
+		assert.Equal(t, tc.key != "", ok, tc.receiver+"/"+tc.transport)
+	}
+	assert.Equal(t, ReceiverLimit{IngestionRateSpans: 20, IngestionMaxBatchSize: 5}, o.ReceiverLimit("user1", "otlp/http"))
+}
+
+func TestOverridesReload(t *testing.T) {
+	overridesFile := filepath.Join(t.TempDir(), "overrides.yaml")
+	writeOverrides := func(yaml string) {
+		require.NoError(t, ioutil.WriteFile(overridesFile, []byte(yaml), os.ModePerm))
+	}
+	writeOverrides(`
+overrides:
+  user1:
+    max_spans_per_trace: 8
+`)
+
+	// the runtime config manager registers its metrics with the default registerer
+	defaultRegisterer := prometheus.DefaultRegisterer
+	prometheus.DefaultRegisterer = prometheus.NewRegistry()
+	defer func() { prometheus.DefaultRegisterer = defaultRegisterer }()
+
+	overrides, err := NewOverrides(Limits{
+		MaxSpansPerTrace:        3,
+		MaxBytesPerTrace:        100,
+		IngestionRateSpans:      5,
+		PerTenantOverrideConfig: overridesFile,
+		PerTenantOverridePeriod: 10 * time.Millisecond,
+	})
+	require.NoError(t, err)
+	require.NoError(t, services.StartAndAwaitRunning(context.Background(), overrides))
+	defer func() {
+		require.NoError(t, services.StopAndAwaitTerminated(context.Background(), overrides))
+	}()
+
+	// limits not set for the tenant keep their defaults
+	assert.Equal(t, 8, overrides.MaxSpansPerTrace("user1"))
+	assert.Equal(t, 100, overrides.MaxBytesPerTrace("user1"))
+	assert.Equal(t, float64(5), overrides.IngestionRateSpans("user1"))
+	assert.Equal(t, 3, overrides.MaxSpansPerTrace("user2"))
+
+	writeOverrides(`
+overrides:
+  user1:
+    max_bytes_per_trace: 50
+    ingestion_rate_limit: 10
+`)
+
+	assert.Eventually(t, func() bool {
+		return overrides.MaxBytesPerTrace("user1") == 50
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, 3, overrides.MaxSpansPerTrace("user1"))
+	assert.Equal(t, float64(10), overrides.IngestionRateSpans("user1"))
+}
@@ -0,0 +1,254 @@
+package querier
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"path"
+	"sync"
+	"time"
+
+	cortex_util "github.com/cortexproject/cortex/pkg/util"
+	"github.com/go-kit/kit/log/level"
+	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/weaveworks/common/user"
+
+	"github.com/grafana/tempo/pkg/tenantmetrics"
+	"github.com/grafana/tempo/pkg/util"
+	"github.com/grafana/tempo/tempodb"
+	"github.com/grafana/tempo/tempodb/backend"
+	"github.com/grafana/tempo/tempodb/backend/azure"
+	"github.com/grafana/tempo/tempodb/backend/gcs"
+	"github.com/grafana/tempo/tempodb/backend/local"
+	"github.com/grafana/tempo/tempodb/backend/s3"
+)
+
+const accessLogWriteTimeout = time.Minute
+
+var (
+	metricAccessLogEntries = tenantmetrics.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "tempo",
+		Name:      "querier_access_log_entries_total",
+		Help:      "The total number of trace lookups written to the access log.",
+	}, []string{"tenant"})
+	metricAccessLogFailures = tenantmetrics.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "tempo",
+		Name:      "querier_access_log_failures_total",
+		Help:      "The total number of trace lookups lost because their access log object failed to be written.",
+	}, []string{"tenant"})
+)
+
+// AccessLogConfig records who looked up which traces of each tenant and when, for retention in object storage.  The
+// access log is written to its own backend so it can be kept apart from, and longer than, the blocks.
+type AccessLogConfig struct {
+	Backend string        `yaml:"backend"` // empty disables the access log
+	Local   *local.Config `yaml:"local"`
+	GCS     *gcs.Config   `yaml:"gcs"`
+	S3      *s3.Config    `yaml:"s3"`
+	Azure   *azure.Config `yaml:"azure"`
+
+	// Prefix is prepended to the name of every object.
+	Prefix string `yaml:"prefix"`
+	// FlushInterval is how often buffered entries are written.  A tenant's entries are also written once MaxEntries are
+	// buffered.
+	FlushInterval time.Duration `yaml:"flush_interval"`
+	MaxEntries    int           `yaml:"max_entries"`
+	// UserHeader is the request header identifying the user behind a lookup, set by the proxy in front of Tempo.
+	UserHeader string `yaml:"user_header"`
+}
+
+// RegisterFlags registers the flags.
+func (cfg *AccessLogConfig) RegisterFlags(prefix string, f *flag.FlagSet) {
+	cfg.Local = &local.Config{}
+	cfg.GCS = &gcs.Config{ChunkBufferSize: 10 * 1024 * 1024}
+	cfg.S3 = &s3.Config{}
+	cfg.Azure = &azure.Config{BufferSize: 4 * 1024 * 1024, Parallelism: 16}
+
+	f.StringVar(&cfg.Backend, util.PrefixConfig(prefix, "backend"), "", "Backend access logs are written to (s3, gcs, azure, local). Disabled if empty.")
+	f.StringVar(&cfg.S3.Bucket, util.PrefixConfig(prefix, "s3.bucket"), "", "s3 bucket to write access logs to.")
+	f.StringVar(&cfg.S3.Endpoint, util.PrefixConfig(prefix, "s3.endpoint"), "", "s3 endpoint to write access logs to.")
+	f.StringVar(&cfg.GCS.BucketName, util.PrefixConfig(prefix, "gcs.bucket"), "", "gcs bucket to write access logs to.")
+	f.StringVar(&cfg.Azure.ContainerName, util.PrefixConfig(prefix, "azure.container-name"), "", "azure container to write access logs to.")
+	f.StringVar(&cfg.Local.Path, util.PrefixConfig(prefix, "local.path"), "", "path to write access logs at.")
+	f.StringVar(&cfg.Prefix, util.PrefixConfig(prefix, "prefix"), "access-logs", "Prefix of the access log objects.")
+	f.DurationVar(&cfg.FlushInterval, util.PrefixConfig(prefix, "flush-interval"), time.Minute, "How often buffered access log entries are written.")
+	f.IntVar(&cfg.MaxEntries, util.PrefixConfig(prefix, "max-entries"), 10000, "Entries of a tenant buffered before they're written without waiting for the flush interval.")
+	f.StringVar(&cfg.UserHeader, util.PrefixConfig(prefix, "user-header"), "X-Grafana-User", "Request header identifying the user behind a lookup.")
+}
+
+// accessLogEntry is a line of an access log object.
+type accessLogEntry struct {
+	Time       time.Time `json:"time"`
+	OrgID      string    `json:"orgID"` // differs from the tenant of the object for lookups across several tenants
+	User       string    `json:"user,omitempty"`
+	RemoteAddr string    `json:"remoteAddr,omitempty"`
+	QueryID    string    `json:"queryID"`
+	TraceID    string    `json:"traceID"`
+}
+
+// accessLog buffers the entries of each tenant and writes them as gzipped objects of one json entry per line, named
+// <prefix>/<tenant>/<yyyy-mm-dd>/<unix nanos>-<uuid>.json.gz.  Entries that fail to be written are dropped and counted.
+// A nil *accessLog records nothing.
+type accessLog struct {
+	cfg    AccessLogConfig
+	writer backend.ObjectWriter
+
+	mtx     sync.Mutex
+	pending map[string][]accessLogEntry
+
+	full chan string // tenants whose buffer reached MaxEntries
+	quit chan struct{}
+	done chan struct{}
+}
+
+// newAccessLog returns nil if the access log is disabled.
+func newAccessLog(cfg AccessLogConfig) (*accessLog, error) {
+	if cfg.Backend == "" {
+		return nil, nil
+	}
+
+	w, err := tempodb.NewObjectWriter(cfg.Backend, cfg.Local, cfg.GCS, cfg.S3, cfg.Azure)
+	if err != nil {
+		return nil, err
+	}
+	return newAccessLogWithWriter(cfg, w), nil
+}
+
+func newAccessLogWithWriter(cfg AccessLogConfig, w backend.ObjectWriter) *accessLog {
+	a := &accessLog{
+		cfg:     cfg,
+		writer:  w,
+		pending: map[string][]accessLogEntry{},
+		full:    make(chan string, 1),
+		quit:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	go a.loop()
+	return a
+}
+
+// record buffers an entry for each of the tenants of the lookup.
+func (a *accessLog) record(r *http.Request, tenantIDs []string, orgID, queryID, traceID string) {
+	if a == nil {
+		return
+	}
+
+	remoteAddr := r.Header.Get("X-Forwarded-For")
+	if remoteAddr == "" {
+		remoteAddr = r.RemoteAddr
+	}
+	entry := accessLogEntry{
+		Time:       time.Now(),
+		OrgID:      orgID,
+		User:       r.Header.Get(a.cfg.UserHeader),
+		RemoteAddr: remoteAddr,
+		QueryID:    queryID,
+		TraceID:    traceID,
+	}
+
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+	for _, tenantID := range tenantIDs {
+		a.pending[tenantID] = append(a.pending[tenantID], entry)
+		if a.cfg.MaxEntries > 0 && len(a.pending[tenantID]) == a.cfg.MaxEntries {
+			select {
+			case a.full <- tenantID:
+			default:
+			}
+		}
+	}
+}
+
+func (a *accessLog) loop() {
+	defer close(a.done)
+
+	ticker := time.NewTicker(a.cfg.FlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			a.flush(false)
+		case <-a.full:
+			a.flush(true)
+		case <-a.quit:
+			a.flush(false)
+			return
+		}
+	}
+}
+
+// flush writes the buffered entries of every tenant, or only of the tenants with a full buffer.
+func (a *accessLog) flush(onlyFull bool) {
+	a.mtx.Lock()
+	flushing := map[string][]accessLogEntry{}
+	for tenantID, entries := range a.pending {
+		if onlyFull && len(entries) < a.cfg.MaxEntries {
+			continue
+		}
+		flushing[tenantID] = entries
+		delete(a.pending, tenantID)
+	}
+	a.mtx.Unlock()
+
+	for tenantID, entries := range flushing {
+		if err := a.write(tenantID, entries); err != nil {
+			metricAccessLogFailures.WithLabelValues(tenantID).Add(float64(len(entries)))
+			level.Error(cortex_util.Logger).Log("msg", "failed to write access log", "tenant", tenantID, "entries", len(entries), "err", err)
+			continue
+		}
+		metricAccessLogEntries.WithLabelValues(tenantID).Add(float64(len(entries)))
+	}
+}
+
+func (a *accessLog) write(tenantID string, entries []accessLogEntry) error {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	enc := json.NewEncoder(gz)
+	for _, e := range entries {
+		if err := enc.Encode(e); err != nil {
+			return err
+		}
+	}
+	if err := gz.Close(); err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	name := path.Join(a.cfg.Prefix, tenantID, now.Format("2006-01-02"), fmt.Sprintf("%d-%s.json.gz", now.UnixNano(), uuid.New()))
+
+	ctx, cancel := context.WithTimeout(context.Background(), accessLogWriteTimeout)
+	defer cancel()
+	return a.writer.WriteObject(ctx, name, buf.Bytes())
+}
+
+// recordAccess records a lookup of the trace in the access log of every tenant it's looked up in.
+func (q *Querier) recordAccess(ctx context.Context, r *http.Request, queryID, traceID string) error {
+	if q.accessLog == nil {
+		return nil
+	}
+
+	orgID, err := user.ExtractOrgID(ctx)
+	if err != nil {
+		return err
+	}
+	tenantIDs, err := q.tenantIDs(ctx)
+	if err != nil {
+		return err
+	}
+	q.accessLog.record(r, tenantIDs, orgID, queryID, traceID)
+	return nil
+}
+
+// stop writes the buffered entries and stops flushing.
+func (a *accessLog) stop() {
+	if a == nil {
+		return
+	}
+	close(a.quit)
+	<-a.done
+}
@@ -0,0 +1,104 @@
+package querier
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type mockObjectWriter struct {
+	mtx     sync.Mutex
+	objects map[string][]byte
+	err     error
+}
+
+func (m *mockObjectWriter) WriteObject(_ context.Context, name string, b []byte) error {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	if m.err != nil {
+		return m.err
+	}
+	m.objects[name] = b
+	return nil
+}
+
+func (m *mockObjectWriter) entries(t *testing.T, prefix string) []accessLogEntry {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	var entries []accessLogEntry
+	for name, b := range m.objects {
+		if !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		require.True(t, strings.HasSuffix(name, ".json.gz"), name)
+		gz, err := gzip.NewReader(bytes.NewReader(b))
+		require.NoError(t, err)
+		scanner := bufio.NewScanner(gz)
+		for scanner.Scan() {
+			var e accessLogEntry
+			require.NoError(t, json.Unmarshal(scanner.Bytes(), &e))
+			entries = append(entries, e)
+		}
+		require.NoError(t, scanner.Err())
+	}
+	return entries
+}
+
+func TestAccessLog(t *testing.T) {
+	w := &mockObjectWriter{objects: map[string][]byte{}}
+	a := newAccessLogWithWriter(AccessLogConfig{
+		Prefix:        "logs",
+		FlushInterval: time.Hour,
+		MaxEntries:    2,
+		UserHeader:    "X-User",
+	}, w)
+
+	r := httptest.NewRequest("GET", "/api/traces/01", nil)
+	r.Header.Set("X-User", "alice")
+	a.record(r, []string{"a"}, "a", "q1", "01")
+	a.record(r, []string{"a", "b"}, "a|b", "q2", "02")
+
+	// a's buffer is full and written without waiting for the interval
+	require.Eventually(t, func() bool {
+		return len(w.entries(t, "logs/a/")) == 2
+	}, time.Second, 10*time.Millisecond)
+	assert.Empty(t, w.entries(t, "logs/b/"))
+
+	entries := w.entries(t, "logs/a/")
+	traceIDs := []string{entries[0].TraceID, entries[1].TraceID}
+	assert.ElementsMatch(t, []string{"01", "02"}, traceIDs)
+	assert.Equal(t, "alice", entries[0].User)
+
+	// the remaining entries are written on stop
+	a.stop()
+	entries = w.entries(t, "logs/b/")
+	require.Len(t, entries, 1)
+	assert.Equal(t, "a|b", entries[0].OrgID)
+	assert.Equal(t, "q2", entries[0].QueryID)
+}
+
+func TestAccessLogWriteFailure(t *testing.T) {
+	w := &mockObjectWriter{objects: map[string][]byte{}, err: errors.New("unavailable")}
+	a := newAccessLogWithWriter(AccessLogConfig{FlushInterval: time.Hour}, w)
+
+	a.record(httptest.NewRequest("GET", "/api/traces/01", nil), []string{"a"}, "a", "q1", "01")
+	a.stop()
+	assert.Empty(t, w.objects)
+	assert.Empty(t, a.pending)
+
+	var disabled *accessLog
+	disabled.record(httptest.NewRequest("GET", "/api/traces/01", nil), []string{"a"}, "a", "q1", "01")
+	disabled.stop()
+}
@@ -0,0 +1,118 @@
+package querier
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/cortexproject/cortex/pkg/chunk/cache"
+	"github.com/go-kit/kit/log"
+	"github.com/gogo/protobuf/proto"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"github.com/grafana/tempo/pkg/tempopb"
+	backend_cache "github.com/grafana/tempo/tempodb/backend/cache"
+)
+
+var (
+	metricResponseCacheRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "tempo",
+		Name:      "querier_response_cache_requests_total",
+		Help:      "The total number of trace lookups checked against the response cache by result.",
+	}, []string{"result"})
+	metricResponseCacheStores = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: "tempo",
+		Name:      "querier_response_cache_stores_total",
+		Help:      "The total number of traces stored in the response cache.",
+	})
+)
+
+// ResponseCacheConfig caches traces that were served only from blocks old enough that they will no longer change.
+type ResponseCacheConfig struct {
+	Enabled bool          `yaml:"enabled"`
+	TTL     time.Duration `yaml:"ttl"`
+	// ImmutableAfter is the age past which blocks are considered immutable.  Defaults to the compaction window.
+	ImmutableAfter time.Duration `yaml:"immutable_after"`
+	// MaxSizeBytes bounds the in memory cache.  A unit suffix (KB, MB, GB) may be applied.
+	MaxSizeBytes string `yaml:"max_size_bytes"`
+	// Memcached shares the cache between queriers instead of keeping it in memory.
+	Memcached *cache.MemcachedClientConfig `yaml:"memcached"`
+	// BestEffort bounds the latency memcached can add to a lookup.
+	BestEffort backend_cache.BestEffortConfig `yaml:",inline"`
+}
+
+// responseCache holds marshalled traces keyed by tenant, trace id and lookup shard.
+type responseCache struct {
+	cache          cache.Cache
+	immutableAfter time.Duration
+}
+
+// newResponseCache returns nil if the cache is disabled.
+func newResponseCache(cfg ResponseCacheConfig, reg prometheus.Registerer, logger log.Logger) (*responseCache, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+	if cfg.ImmutableAfter <= 0 {
+		return nil, fmt.Errorf("response cache immutable after must be positive")
+	}
+
+	var c cache.Cache
+	if cfg.Memcached != nil {
+		breaker := backend_cache.NewCircuitBreaker("querier-response", cfg.BestEffort.CircuitBreakerFailures, cfg.BestEffort.CircuitBreakerDuration, logger)
+		client := backend_cache.NewMemcachedClient(*cfg.Memcached, "querier-response", breaker, reg, logger)
+		memcached := cache.NewMemcached(cache.MemcachedConfig{Expiration: cfg.TTL}, client, "querier-response", reg, logger)
+		c = backend_cache.NewBestEffort(memcached, cfg.BestEffort.Timeout, breaker)
+	} else {
+		c = cache.NewFifoCache("querier-response", cache.FifoCacheConfig{
+			MaxSizeBytes: cfg.MaxSizeBytes,
+			Validity:     cfg.TTL,
+		}, reg, logger)
+	}
+
+	return &responseCache{
+		cache:          c,
+		immutableAfter: cfg.ImmutableAfter,
+	}, nil
+}
+
+func responseCacheKey(userID string, traceID []byte, shard lookupShard) string {
+	return fmt.Sprintf("%s:%x:%s:%s:%s", userID, traceID, shard.mode, shard.blockStart, shard.blockEnd)
+}
+
+func (c *responseCache) fetch(ctx context.Context, key string) (*tempopb.Trace, bool) {
+	found, bufs, _ := c.cache.Fetch(ctx, []string{key})
+	if len(found) == 0 {
+		metricResponseCacheRequests.WithLabelValues("miss").Inc()
+		return nil, false
+	}
+
+	trace := &tempopb.Trace{}
+	if err := proto.Unmarshal(bufs[0], trace); err != nil {
+		metricResponseCacheRequests.WithLabelValues("miss").Inc()
+		return nil, false
+	}
+
+	metricResponseCacheRequests.WithLabelValues("hit").Inc()
+	return trace, true
+}
+
+// store caches the trace if every block it was found in ended before the immutable age.  foundBlockEnd is the end
+// time of the newest of those blocks.
+func (c *responseCache) store(ctx context.Context, key string, trace *tempopb.Trace, foundBlockEnd time.Time, now time.Time) {
+	if foundBlockEnd.IsZero() || foundBlockEnd.After(now.Add(-c.immutableAfter)) {
+		return
+	}
+
+	buf, err := proto.Marshal(trace)
+	if err != nil {
+		return
+	}
+
+	c.cache.Store(ctx, []string{key}, [][]byte{buf})
+	metricResponseCacheStores.Inc()
+}
+
+func (c *responseCache) stop() {
+	c.cache.Stop()
+}
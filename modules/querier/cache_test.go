@@ -0,0 +1,64 @@
+package querier
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/golang/protobuf/proto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/tempo/pkg/util/test"
+)
+
+func TestResponseCache(t *testing.T) {
+	c, err := newResponseCache(ResponseCacheConfig{
+		Enabled:        true,
+		TTL:            time.Hour,
+		ImmutableAfter: time.Hour,
+		MaxSizeBytes:   "1MB",
+	}, nil, log.NewNopLogger())
+	require.NoError(t, err)
+	defer c.stop()
+
+	ctx := context.Background()
+	now := time.Now()
+	trace := test.MakeTrace(2, []byte{0x01})
+
+	// recent blocks may still change
+	recentKey := responseCacheKey("tenant", []byte{0x01}, lookupShard{mode: QueryModeAll})
+	c.store(ctx, recentKey, trace, now.Add(-time.Minute), now)
+	_, ok := c.fetch(ctx, recentKey)
+	assert.False(t, ok)
+
+	// blocks with no known end time aren't cached
+	c.store(ctx, recentKey, trace, time.Time{}, now)
+	_, ok = c.fetch(ctx, recentKey)
+	assert.False(t, ok)
+
+	c.store(ctx, recentKey, trace, now.Add(-2*time.Hour), now)
+	cached, ok := c.fetch(ctx, recentKey)
+	require.True(t, ok)
+	assert.True(t, proto.Equal(trace, cached))
+
+	// shards are cached separately
+	shardKey := responseCacheKey("tenant", []byte{0x01}, lookupShard{mode: QueryModeBlocks, blockStart: "a", blockEnd: "b"})
+	assert.NotEqual(t, recentKey, shardKey)
+	_, ok = c.fetch(ctx, shardKey)
+	assert.False(t, ok)
+
+	// as are tenants
+	_, ok = c.fetch(ctx, responseCacheKey("other", []byte{0x01}, lookupShard{mode: QueryModeAll}))
+	assert.False(t, ok)
+}
+
+func TestResponseCacheDisabled(t *testing.T) {
+	c, err := newResponseCache(ResponseCacheConfig{}, nil, log.NewNopLogger())
+	require.NoError(t, err)
+	assert.Nil(t, c)
+
+	_, err = newResponseCache(ResponseCacheConfig{Enabled: true}, nil, log.NewNopLogger())
+	assert.Error(t, err)
+}
@@ -3,16 +3,88 @@ package querier
 import (
 	"flag"
 	"time"
+
+	cortex_frontend "github.com/cortexproject/cortex/pkg/querier/frontend"
+	"github.com/cortexproject/cortex/pkg/util/flagext"
+
+	"github.com/grafana/tempo/pkg/encryption"
+	"github.com/grafana/tempo/pkg/util"
+	"github.com/grafana/tempo/pkg/watermark"
+	"github.com/grafana/tempo/tempodb/backend/cache"
 )
 
 // Config for a querier.
 type Config struct {
 	QueryTimeout    time.Duration `yaml:"query_timeout"`
 	ExtraQueryDelay time.Duration `yaml:"extra_query_delay,omitempty"`
+
+	// QueryBackendWorkers is the number of blocks a trace lookup searches at once.  0 is limited only by the pool of the
+	// storage config.
+	QueryBackendWorkers int `yaml:"query_backend_workers"`
+
+	UseBlockGateways bool `yaml:"use_block_gateways"`
+
+	// SearchScanUnindexedBlocks searches blocks written without search data by scanning their objects.
+	SearchScanUnindexedBlocks bool `yaml:"search_scan_unindexed_blocks"`
+
+	// MultiTenantQueriesEnabled allows an org id of several tenants separated by | to query all of them at once.
+	MultiTenantQueriesEnabled bool `yaml:"multi_tenant_queries_enabled"`
+
+	// FrontendWorker pulls trace lookups from the query frontends.  Disabled if no frontend address is set.
+	FrontendWorker cortex_frontend.WorkerConfig `yaml:"frontend_worker"`
+
+	AttributeEncryption encryption.Config `yaml:"attribute_encryption"`
+
+	ResponseCache ResponseCacheConfig `yaml:"response_cache"`
+
+	MemoryWatermarks watermark.Config `yaml:"memory_watermarks"`
+
+	// ExternalLabels identify this cluster to proxies federating several clusters.  They are added to the resource
+	// attributes of returned traces and listed in a header of query responses.
+	ExternalLabels map[string]string `yaml:"external_labels"`
+
+	// TraceSpill writes the batches of large traces to disk while they're assembled.  Traces looked up across several
+	// tenants are always held in memory.
+	TraceSpill TraceSpillConfig `yaml:"trace_spill"`
+
+	AccessLog AccessLogConfig `yaml:"access_log"`
+
+	// TailMaxDuration caps how long a tail runs.  0 lets tails run until the client goes away.
+	TailMaxDuration time.Duration `yaml:"tail_max_duration"`
+	// MaxConcurrentTails is the number of tails the querier runs at once.  0 is unlimited.
+	MaxConcurrentTails int `yaml:"max_concurrent_tails"`
 }
 
 // RegisterFlagsAndApplyDefaults register flags.
 func (cfg *Config) RegisterFlagsAndApplyDefaults(prefix string, f *flag.FlagSet) {
 	cfg.QueryTimeout = 10 * time.Second
 	cfg.ExtraQueryDelay = 0
+	f.IntVar(&cfg.QueryBackendWorkers, util.PrefixConfig(prefix, "query-backend-workers"), 0, "Number of blocks each trace lookup searches at once, leaving the rest of the storage worker pool to other lookups. 0 uses the whole pool.")
+
+	flagext.DefaultValues(&cfg.FrontendWorker)
+	f.StringVar(&cfg.FrontendWorker.Address, util.PrefixConfig(prefix, "frontend-address"), "", "Address of the query frontend to pull trace lookups from, in host:port format.")
+	f.IntVar(&cfg.FrontendWorker.Parallelism, util.PrefixConfig(prefix, "frontend-worker-parallelism"), 2, "Number of lookups to process in parallel per query frontend.")
+
+	f.BoolVar(&cfg.UseBlockGateways, util.PrefixConfig(prefix, "use-block-gateways"), false, "Search the backend through the block gateways instead of reading blocks directly.")
+	f.BoolVar(&cfg.SearchScanUnindexedBlocks, util.PrefixConfig(prefix, "search-scan-unindexed-blocks"), false, "Search blocks written without search data by reading and matching all of their objects. Otherwise their traces aren't found by searches.")
+	f.BoolVar(&cfg.MultiTenantQueriesEnabled, util.PrefixConfig(prefix, "multi-tenant-queries-enabled"), false, "Query every tenant of an org id of several tenants separated by |, e.g. tenant-a|tenant-b, and merge the results.")
+	cfg.AttributeEncryption.RegisterFlags(util.PrefixConfig(prefix, "attribute-encryption"), f)
+
+	f.BoolVar(&cfg.ResponseCache.Enabled, util.PrefixConfig(prefix, "response-cache.enabled"), false, "Cache traces served only from blocks that will no longer change.")
+	f.DurationVar(&cfg.ResponseCache.TTL, util.PrefixConfig(prefix, "response-cache.ttl"), 24*time.Hour, "How long cached traces are kept.")
+	f.DurationVar(&cfg.ResponseCache.ImmutableAfter, util.PrefixConfig(prefix, "response-cache.immutable-after"), 0, "Age after which blocks no longer change and traces found only in them are cached.  Defaults to the compaction window.")
+	f.StringVar(&cfg.ResponseCache.MaxSizeBytes, util.PrefixConfig(prefix, "response-cache.max-size-bytes"), "100MB", "Maximum size of the in memory response cache.  A unit suffix (KB, MB, GB) may be applied.")
+	f.DurationVar(&cfg.ResponseCache.BestEffort.Timeout, util.PrefixConfig(prefix, "response-cache.timeout"), cache.DefaultTimeout, "Lookups in memcached that take longer than this are treated as misses.")
+	f.IntVar(&cfg.ResponseCache.BestEffort.CircuitBreakerFailures, util.PrefixConfig(prefix, "response-cache.circuit-breaker-failures"), cache.DefaultCircuitBreakerFailures, "Failed memcached requests in a row after which memcached isn't used for the circuit breaker duration.")
+	f.DurationVar(&cfg.ResponseCache.BestEffort.CircuitBreakerDuration, util.PrefixConfig(prefix, "response-cache.circuit-breaker-duration"), cache.DefaultCircuitBreakerDuration, "How long memcached isn't used after repeated failures.")
+
+	cfg.MemoryWatermarks.RegisterFlags(util.PrefixConfig(prefix, "memory-watermarks"), f)
+
+	f.IntVar(&cfg.TraceSpill.ThresholdBytes, util.PrefixConfig(prefix, "trace-spill.threshold-bytes"), 0, "Size of the batches of a trace held in memory while it's assembled after which they're written to a spill file. 0 disables spilling.")
+	f.StringVar(&cfg.TraceSpill.Path, util.PrefixConfig(prefix, "trace-spill.path"), "", "Directory of trace spill files. Defaults to the system temp directory.")
+
+	cfg.AccessLog.RegisterFlags(util.PrefixConfig(prefix, "access-log"), f)
+
+	f.DurationVar(&cfg.TailMaxDuration, util.PrefixConfig(prefix, "tail-max-duration"), 15*time.Minute, "Maximum time a tail streams spans for. 0 streams until the client goes away.")
+	f.IntVar(&cfg.MaxConcurrentTails, util.PrefixConfig(prefix, "max-concurrent-tails"), 20, "Maximum number of tails the querier runs at once. 0 is unlimited.")
 }
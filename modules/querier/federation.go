@@ -0,0 +1,115 @@
+package querier
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/weaveworks/common/user"
+
+	"github.com/grafana/tempo/pkg/tempopb"
+	tempo_util "github.com/grafana/tempo/pkg/util"
+)
+
+// tenantSeparator separates the tenants of a federated query in the org id, e.g. tenant-a|tenant-b.
+const tenantSeparator = "|"
+
+// tenantIDs returns the tenants a query is for.  The org id is split into several tenants only if multi tenant queries
+// are enabled.  Otherwise it's a single tenant, whatever it contains.
+func (q *Querier) tenantIDs(ctx context.Context) ([]string, error) {
+	orgID, err := user.ExtractOrgID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if !q.cfg.MultiTenantQueriesEnabled || !strings.Contains(orgID, tenantSeparator) {
+		return []string{orgID}, nil
+	}
+
+	var tenantIDs []string
+	seen := map[string]struct{}{}
+	for _, tenantID := range strings.Split(orgID, tenantSeparator) {
+		if tenantID == "" {
+			return nil, fmt.Errorf("empty tenant in org id %q", orgID)
+		}
+		if _, ok := seen[tenantID]; ok {
+			continue
+		}
+		seen[tenantID] = struct{}{}
+		tenantIDs = append(tenantIDs, tenantID)
+	}
+
+	return tenantIDs, nil
+}
+
+// forEachTenant calls f in parallel with a context for each tenant and returns the first error.  Errors are returned as
+// is to preserve their grpc status.
+func forEachTenant(ctx context.Context, tenantIDs []string, f func(ctx context.Context, tenantID string) (interface{}, error)) ([]interface{}, error) {
+	results := make([]interface{}, len(tenantIDs))
+	errs := make([]error, len(tenantIDs))
+
+	var wg sync.WaitGroup
+	for i, tenantID := range tenantIDs {
+		wg.Add(1)
+		go func(i int, tenantID string) {
+			defer wg.Done()
+			results[i], errs[i] = f(user.InjectOrgID(ctx, tenantID), tenantID)
+		}(i, tenantID)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return results, nil
+}
+
+// findTraceByIDFederated looks up the trace in each tenant and combines the spans found.
+func (q *Querier) findTraceByIDFederated(ctx context.Context, req *tempopb.TraceByIDRequest, shard lookupShard, tenantIDs []string) (*tempopb.TraceByIDResponse, error) {
+	results, err := forEachTenant(ctx, tenantIDs, func(ctx context.Context, _ string) (interface{}, error) {
+		return q.findTraceByID(ctx, req, shard, nil)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	combiner := &tempo_util.TraceCombiner{}
+	combinedFrom := uint32(0)
+	incomplete := false
+	for _, r := range results {
+		resp := r.(*tempopb.TraceByIDResponse)
+		if resp.Trace != nil && len(resp.Trace.Batches) > 0 {
+			combiner.Combine(resp.Trace)
+			combinedFrom += resp.CombinedFrom
+			incomplete = incomplete || resp.Incomplete
+		}
+	}
+	metricDuplicateSpans.Add(float64(combiner.DuplicateSpans()))
+
+	return &tempopb.TraceByIDResponse{
+		Trace:        combiner.Result(),
+		CombinedFrom: combinedFrom,
+		Incomplete:   incomplete,
+	}, nil
+}
+
+// searchFederated searches each tenant and merges the traces found, newest first.
+func (q *Querier) searchFederated(ctx context.Context, req *tempopb.SearchRequest, tenantIDs []string) (*tempopb.SearchResponse, error) {
+	results, err := forEachTenant(ctx, tenantIDs, func(ctx context.Context, _ string) (interface{}, error) {
+		return q.Search(ctx, req)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	resps := make([]*tempopb.SearchResponse, 0, len(results))
+	for _, r := range results {
+		resps = append(resps, r.(*tempopb.SearchResponse))
+	}
+
+	return CombineSearchResponses(resps, int(req.Limit)), nil
+}
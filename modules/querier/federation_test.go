@@ -0,0 +1,78 @@
+package querier
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/weaveworks/common/user"
+)
+
+func TestTenantIDs(t *testing.T) {
+	tests := []struct {
+		name     string
+		enabled  bool
+		orgID    string
+		expected []string
+		err      bool
+	}{
+		{
+			name:     "single",
+			enabled:  true,
+			orgID:    "tenant-a",
+			expected: []string{"tenant-a"},
+		},
+		{
+			name:     "disabled",
+			orgID:    "tenant-a|tenant-b",
+			expected: []string{"tenant-a|tenant-b"},
+		},
+		{
+			name:     "several",
+			enabled:  true,
+			orgID:    "tenant-a|tenant-b|tenant-a",
+			expected: []string{"tenant-a", "tenant-b"},
+		},
+		{
+			name:    "empty tenant",
+			enabled: true,
+			orgID:   "tenant-a||tenant-b",
+			err:     true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := &Querier{cfg: Config{MultiTenantQueriesEnabled: tt.enabled}}
+
+			actual, err := q.tenantIDs(user.InjectOrgID(context.Background(), tt.orgID))
+			if tt.err {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, actual)
+		})
+	}
+}
+
+func TestForEachTenant(t *testing.T) {
+	results, err := forEachTenant(context.Background(), []string{"tenant-a", "tenant-b"}, func(ctx context.Context, tenantID string) (interface{}, error) {
+		orgID, err := user.ExtractOrgID(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, tenantID, orgID)
+		return orgID, nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []interface{}{"tenant-a", "tenant-b"}, results)
+
+	_, err = forEachTenant(context.Background(), []string{"tenant-a", "tenant-b"}, func(ctx context.Context, tenantID string) (interface{}, error) {
+		if tenantID == "tenant-b" {
+			return nil, errors.New("failed")
+		}
+		return nil, nil
+	})
+	assert.EqualError(t, err, "failed")
+}
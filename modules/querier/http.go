@@ -2,26 +2,73 @@ package querier
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"math"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
+	cortex_util "github.com/cortexproject/cortex/pkg/util"
+	"github.com/go-kit/kit/log/level"
 	"github.com/golang/protobuf/jsonpb"
+	"github.com/google/uuid"
 	"github.com/gorilla/mux"
 	"github.com/grafana/tempo/pkg/tempopb"
 	"github.com/grafana/tempo/pkg/util"
+	"github.com/grafana/tempo/tempodb"
+	v1 "github.com/open-telemetry/opentelemetry-proto/gen/go/trace/v1"
+	"github.com/weaveworks/common/user"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 const (
 	TraceIDVar = "traceID"
+
+	// Query parameters the query frontend uses to split a trace lookup between queriers.  Without them the ingesters
+	// are searched first and the store only if they don't have the trace.
+	QueryModeKey       = "mode"
+	QueryModeIngesters = "ingesters"
+	QueryModeBlocks    = "blocks"
+	QueryModeAll       = "all"
+	BlockStartKey      = "blockStart"
+	BlockEndKey        = "blockEnd"
+
+	// CombinedFromHeader holds the number of partial traces, e.g. from each ingester holding a replica, merged into
+	// the returned trace.
+	CombinedFromHeader = "X-Tempo-Combined-From"
+	// IncompleteHeader is set to true if an ingester received spans of the trace within its trace idle period, so the
+	// trace may still be receiving spans and is worth fetching again.
+	IncompleteHeader = "X-Tempo-Trace-Incomplete"
 )
 
-// TraceByIDHandler is a http.HandlerFunc to retrieve traces
+// TraceByIDHandler is a http.HandlerFunc to retrieve traces.  A HEAD request only checks whether the trace is
+// likely to exist, responding 200 or 404 without a body.
 func (q *Querier) TraceByIDHandler(w http.ResponseWriter, r *http.Request) {
+	q.traceByID(w, r, false)
+}
+
+// TraceSummaryHandler is a http.HandlerFunc returning the span count, time range, root span and services of a trace
+// instead of its spans.  The trace is looked up like by TraceByIDHandler.
+func (q *Querier) TraceSummaryHandler(w http.ResponseWriter, r *http.Request) {
+	q.traceByID(w, r, true)
+}
+
+func (q *Querier) traceByID(w http.ResponseWriter, r *http.Request, summary bool) {
+	ctx, queryID, done := q.queries.start(r.Context(), queryKindTraceByID, r)
+	defer done()
+	w.Header().Set(QueryIDHeader, queryID)
+
 	// Enforce the query timeout while querying backends
-	ctx, cancel := context.WithDeadline(r.Context(), time.Now().Add(q.cfg.QueryTimeout))
+	ctx, cancel := context.WithDeadline(ctx, time.Now().Add(q.cfg.QueryTimeout))
 	defer cancel()
 
+	q.setExternalLabelsHeader(w)
+
 	vars := mux.Vars(r)
 	traceID, ok := vars[TraceIDVar]
 
@@ -36,24 +83,444 @@ func (q *Querier) TraceByIDHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	resp, err := q.FindTraceByID(ctx, &tempopb.TraceByIDRequest{
+	if r.Method == http.MethodHead {
+		q.traceMayExist(ctx, w, byteID)
+		return
+	}
+
+	shard, err := parseShardParams(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// the frontend splits a lookup into shards of blocks plus one for the ingesters, which is the one recorded
+	if shard.mode != QueryModeBlocks {
+		if err := q.recordAccess(ctx, r, queryID, traceID); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	// large traces may be spilled to disk while they're assembled and are then streamed back from the spill file
+	asm := newTraceAssembler(q.cfg.TraceSpill)
+	defer func() {
+		if err := asm.close(); err != nil {
+			level.Warn(cortex_util.Logger).Log("msg", "failed to remove trace spill file", "traceID", traceID, "err", err)
+		}
+	}()
+
+	resp, err := q.findTraceByID(ctx, &tempopb.TraceByIDRequest{
 		TraceID: byteID,
+	}, shard, asm)
+
+	if err != nil {
+		q.writeQueryError(w, queryID, err)
+		return
+	}
+
+	if (resp.Trace == nil || len(resp.Trace.Batches) == 0) && asm.spilledBatches() == 0 {
+		http.Error(w, fmt.Sprintf("Unable to find %s", traceID), http.StatusNotFound)
+		return
+	}
+
+	var decryptTenant string
+	if q.encrypter != nil && q.encrypter.Authorized(r.Header.Get(q.encrypter.RoleHeader())) {
+		tenantIDs, err := q.tenantIDs(ctx)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		// the values of a trace found across several tenants are left encrypted
+		if len(tenantIDs) == 1 {
+			decryptTenant = tenantIDs[0]
+		}
+	}
+	prepare := func(trace *tempopb.Trace) {
+		if decryptTenant != "" {
+			if err := q.encrypter.DecryptTrace(decryptTenant, trace); err != nil {
+				level.Warn(cortex_util.Logger).Log("msg", "failed to decrypt attributes", "tenant", decryptTenant, "err", err)
+			}
+		}
+		addExternalLabels(trace, q.cfg.ExternalLabels)
+	}
+
+	prepare(resp.Trace)
+	w.Header().Set(CombinedFromHeader, strconv.Itoa(int(resp.CombinedFrom)))
+	if resp.Incomplete {
+		w.Header().Set(IncompleteHeader, "true")
+	}
+
+	forEachBatch := func(fn func(*v1.ResourceSpans) error) error {
+		err := asm.forEachSpilled(func(batch *v1.ResourceSpans) error {
+			prepare(&tempopb.Trace{Batches: []*v1.ResourceSpans{batch}})
+			return fn(batch)
+		})
+		if err != nil {
+			return err
+		}
+		return traceBatches(resp.Trace)(fn)
+	}
+
+	if summary {
+		s, err := summarizeTrace(forEachBatch)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		s.TraceID = traceID
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(s); err != nil {
+			level.Warn(cortex_util.Logger).Log("msg", "failed to write trace summary", "traceID", traceID, "err", err)
+		}
+		return
+	}
+
+	// the status can't be changed once the body is being written
+	err = writeTrace(w, forEachBatch)
+	if err != nil {
+		level.Warn(cortex_util.Logger).Log("msg", "failed to write trace", "traceID", traceID, "err", err)
+	}
+}
+
+// writeTrace writes the same JSON as jsonpb does for a trace of the batches passed by forEachBatch but marshals one
+// batch at a time.  The full JSON of a large trace is never held in memory, and since no Content-Length is set it is
+// sent with chunked transfer encoding.
+func writeTrace(w io.Writer, forEachBatch func(func(*v1.ResourceSpans) error) error) error {
+	if _, err := io.WriteString(w, `{"batches":[`); err != nil {
+		return err
+	}
+
+	marshaller := &jsonpb.Marshaler{}
+	first := true
+	err := forEachBatch(func(batch *v1.ResourceSpans) error {
+		if !first {
+			if _, err := io.WriteString(w, ","); err != nil {
+				return err
+			}
+		}
+		first = false
+		return marshaller.Marshal(w, batch)
 	})
+	if err != nil {
+		return err
+	}
+
+	_, err = io.WriteString(w, "]}")
+	return err
+}
+
+// traceBatches passes the batches of trace to writeTrace.
+func traceBatches(trace *tempopb.Trace) func(func(*v1.ResourceSpans) error) error {
+	return func(fn func(*v1.ResourceSpans) error) error {
+		if trace == nil {
+			return nil
+		}
+		for _, batch := range trace.Batches {
+			if err := fn(batch); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+func (q *Querier) traceMayExist(ctx context.Context, w http.ResponseWriter, traceID []byte) {
+	mayExist, err := q.TraceMayExist(ctx, traceID)
+	if status.Code(err) == codes.ResourceExhausted {
+		w.WriteHeader(http.StatusTooManyRequests)
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	if !mayExist {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
 
+// RecentTracesHandler is a http.HandlerFunc listing the most recently stored traces of a tenant.  The number of traces
+// is set with the limit query parameter.
+func (q *Querier) RecentTracesHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, queryID, done := q.queries.start(r.Context(), queryKindRecent, r)
+	defer done()
+	w.Header().Set(QueryIDHeader, queryID)
+
+	ctx, cancel := context.WithDeadline(ctx, time.Now().Add(q.cfg.QueryTimeout))
+	defer cancel()
+
+	q.setExternalLabelsHeader(w)
+
+	limit := defaultRecentTraces
+	if s := r.URL.Query().Get("limit"); s != "" {
+		var err error
+		limit, err = strconv.Atoi(s)
+		if err != nil || limit <= 0 || limit > maxRecentTraces {
+			http.Error(w, fmt.Sprintf("limit must be between 1 and %d", maxRecentTraces), http.StatusBadRequest)
+			return
+		}
+	}
+
+	traces, err := q.RecentTraces(ctx, limit)
+	if err != nil {
+		q.writeQueryError(w, queryID, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(struct {
+		Traces []RecentTrace `json:"traces"`
+	}{traces})
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+}
 
-	if resp.Trace == nil || len(resp.Trace.Batches) == 0 {
-		http.Error(w, fmt.Sprintf("Unable to find %s", traceID), http.StatusNotFound)
+// OverviewHandler is a http.HandlerFunc returning the services of the tenant with the most spans pushed over roughly the
+// last hour, with their error rates and p99 span durations.  The number of services is set with the limit query
+// parameter.
+func (q *Querier) OverviewHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, queryID, done := q.queries.start(r.Context(), queryKindOverview, r)
+	defer done()
+	w.Header().Set(QueryIDHeader, queryID)
+
+	ctx, cancel := context.WithDeadline(ctx, time.Now().Add(q.cfg.QueryTimeout))
+	defer cancel()
+
+	q.setExternalLabelsHeader(w)
+
+	limit := defaultOverviewServices
+	if s := r.URL.Query().Get("limit"); s != "" {
+		var err error
+		limit, err = strconv.Atoi(s)
+		if err != nil || limit <= 0 || limit > maxOverviewServices {
+			http.Error(w, fmt.Sprintf("limit must be between 1 and %d", maxOverviewServices), http.StatusBadRequest)
+			return
+		}
+	}
+
+	overview, err := q.Overview(ctx, limit)
+	if err != nil {
+		q.writeQueryError(w, queryID, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(overview)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// SearchHandler is a http.HandlerFunc finding traces by tag.  Tags are passed as key=value pairs in the tags query
+// parameter, separated by spaces or by repeating the parameter.  minDuration and maxDuration are durations such as 2s,
+// start and end are unix seconds.
+func (q *Querier) SearchHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, queryID, done := q.queries.start(r.Context(), queryKindSearch, r)
+	defer done()
+	w.Header().Set(QueryIDHeader, queryID)
+
+	ctx, cancel := context.WithDeadline(ctx, time.Now().Add(q.cfg.QueryTimeout))
+	defer cancel()
+
+	q.setExternalLabelsHeader(w)
+
+	req, err := parseSearchRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	resp, err := q.Search(ctx, req)
+	if err != nil {
+		q.writeQueryError(w, queryID, err)
 		return
 	}
 
+	w.Header().Set("Content-Type", "application/json")
 	marshaller := &jsonpb.Marshaler{}
-	err = marshaller.Marshal(w, resp.Trace)
+	err = marshaller.Marshal(w, resp)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 }
+
+// writeQueryError responds to a failed query.  Queries cancelled by an operator and searches of tenants with search
+// disabled are refused with a 403, which the query frontend doesn't retry, and shed queries with a 429.  Queries
+// rejected by a tenant's query limits get a 429 with a JSON body naming the limit.
+func (q *Querier) writeQueryError(w http.ResponseWriter, queryID string, err error) {
+	var limitErr *queryLimitError
+	switch {
+	case q.queries.wasCancelled(queryID):
+		http.Error(w, "query cancelled by an operator", http.StatusForbidden)
+	case errors.As(err, &limitErr):
+		limitErr.writeHTTP(w)
+	case status.Code(err) == codes.PermissionDenied:
+		http.Error(w, err.Error(), http.StatusForbidden)
+	case status.Code(err) == codes.ResourceExhausted:
+		http.Error(w, err.Error(), http.StatusTooManyRequests)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func parseSearchRequest(r *http.Request) (*tempopb.SearchRequest, error) {
+	params := r.URL.Query()
+	req := &tempopb.SearchRequest{
+		Tags: map[string]string{},
+	}
+
+	for _, tags := range params["tags"] {
+		for _, tag := range strings.Fields(tags) {
+			kv := strings.SplitN(tag, "=", 2)
+			if len(kv) != 2 || kv[0] == "" {
+				return nil, fmt.Errorf("invalid tag %q.  tags must be key=value", tag)
+			}
+			req.Tags[kv[0]] = kv[1]
+		}
+	}
+
+	for _, d := range []struct {
+		param string
+		ms    *uint32
+	}{
+		{"minDuration", &req.MinDurationMs},
+		{"maxDuration", &req.MaxDurationMs},
+	} {
+		s := params.Get(d.param)
+		if s == "" {
+			continue
+		}
+		duration, err := time.ParseDuration(s)
+		if err != nil || duration < 0 {
+			return nil, fmt.Errorf("invalid %s %q", d.param, s)
+		}
+		*d.ms = uint32(duration / time.Millisecond)
+	}
+	if req.MaxDurationMs != 0 && req.MinDurationMs > req.MaxDurationMs {
+		return nil, fmt.Errorf("minDuration must not be greater than maxDuration")
+	}
+
+	for _, u := range []struct {
+		param string
+		value *uint32
+		max   uint64
+	}{
+		{"limit", &req.Limit, maxSearchLimit},
+		{"start", &req.Start, math.MaxUint32},
+		{"end", &req.End, math.MaxUint32},
+	} {
+		s := params.Get(u.param)
+		if s == "" {
+			continue
+		}
+		v, err := strconv.ParseUint(s, 10, 32)
+		if err != nil || v > u.max {
+			return nil, fmt.Errorf("invalid %s %q", u.param, s)
+		}
+		*u.value = uint32(v)
+	}
+	if req.End != 0 && req.Start > req.End {
+		return nil, fmt.Errorf("start must not be after end")
+	}
+
+	if s := params.Get("mostRecent"); s != "" {
+		mostRecent, err := strconv.ParseBool(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid mostRecent %q", s)
+		}
+		req.MostRecent = mostRecent
+	}
+
+	return req, nil
+}
+
+// BlockStatsHandler is a http.HandlerFunc returning statistics of the tenant's blocks for capacity planning.  They
+// are computed from the blocklist so nothing is read from the backend.
+func (q *Querier) BlockStatsHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := user.ExtractOrgID(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(q.store.BlockStats(userID))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// lookupShard restricts a trace lookup to the ingesters or a range of blocks.
+type lookupShard struct {
+	mode       string
+	blockStart string
+	blockEnd   string
+	include    tempodb.BlockFilter
+}
+
+// parseShardParams reads the query mode and block range set by the query frontend and the time window and service a
+// client may know the trace by.  The block range only applies to QueryModeBlocks.  The window and service prune blocks
+// by their summaries before any bloom filter is fetched.
+func parseShardParams(r *http.Request) (lookupShard, error) {
+	mode := r.URL.Query().Get(QueryModeKey)
+	switch mode {
+	case "":
+		mode = QueryModeAll
+	case QueryModeAll, QueryModeIngesters, QueryModeBlocks:
+	default:
+		return lookupShard{}, fmt.Errorf("invalid %s %q", QueryModeKey, mode)
+	}
+
+	shard := lookupShard{mode: mode}
+	var filters []tempodb.BlockFilter
+
+	var window [2]time.Time
+	for i, param := range []string{"start", "end"} {
+		s := r.URL.Query().Get(param)
+		if s == "" {
+			continue
+		}
+		v, err := strconv.ParseUint(s, 10, 32)
+		if err != nil {
+			return lookupShard{}, fmt.Errorf("invalid %s %q", param, s)
+		}
+		window[i] = time.Unix(int64(v), 0)
+	}
+	if !window[0].IsZero() || !window[1].IsZero() {
+		if !window[1].IsZero() && window[0].After(window[1]) {
+			return lookupShard{}, fmt.Errorf("start must not be after end")
+		}
+		filters = append(filters, tempodb.TimeRangeFilter(window[0], window[1]))
+	}
+	if service := r.URL.Query().Get("service"); service != "" {
+		filters = append(filters, tempodb.ServiceFilter(service))
+	}
+
+	start, end := r.URL.Query().Get(BlockStartKey), r.URL.Query().Get(BlockEndKey)
+	if mode == QueryModeBlocks && (start != "" || end != "") {
+		startID, err := uuid.Parse(start)
+		if err != nil {
+			return lookupShard{}, fmt.Errorf("invalid %s: %w", BlockStartKey, err)
+		}
+		endID, err := uuid.Parse(end)
+		if err != nil {
+			return lookupShard{}, fmt.Errorf("invalid %s: %w", BlockEndKey, err)
+		}
+		shard.blockStart = startID.String()
+		shard.blockEnd = endID.String()
+		filters = append(filters, tempodb.BlockIDRangeFilter(startID, endID))
+	}
+
+	shard.include = tempodb.AllFilters(filters...)
+	return shard, nil
+}
@@ -0,0 +1,114 @@
+package querier
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/tempo/pkg/tempopb"
+	"github.com/grafana/tempo/pkg/util/test"
+	"github.com/grafana/tempo/tempodb/encoding"
+)
+
+func TestWriteTraceMatchesJSONPB(t *testing.T) {
+	for _, trace := range []*tempopb.Trace{
+		test.MakeTrace(1, []byte{0x01}),
+		test.MakeTrace(5, []byte{0x02}),
+	} {
+		expected := &bytes.Buffer{}
+		require.NoError(t, (&jsonpb.Marshaler{}).Marshal(expected, trace))
+
+		actual := &bytes.Buffer{}
+		require.NoError(t, writeTrace(actual, traceBatches(trace)))
+		assert.Equal(t, expected.String(), actual.String())
+
+		unmarshalled := &tempopb.Trace{}
+		require.NoError(t, jsonpb.Unmarshal(actual, unmarshalled))
+		assert.Equal(t, trace, unmarshalled)
+	}
+}
+
+func TestParseShardParams(t *testing.T) {
+	shard, err := parseShardParams(httptest.NewRequest("GET", "/api/traces/01", nil))
+	require.NoError(t, err)
+	assert.Equal(t, lookupShard{mode: QueryModeAll}, shard)
+
+	shard, err = parseShardParams(httptest.NewRequest("GET", "/api/traces/01?mode=ingesters", nil))
+	require.NoError(t, err)
+	assert.Equal(t, lookupShard{mode: QueryModeIngesters}, shard)
+
+	shard, err = parseShardParams(httptest.NewRequest("GET", "/api/traces/01?mode=blocks&blockStart=00000000-0000-0000-0000-000000000000&blockEnd=7fffffff-ffff-ffff-ffff-ffffffffffff", nil))
+	require.NoError(t, err)
+	assert.Equal(t, QueryModeBlocks, shard.mode)
+	assert.Equal(t, "00000000-0000-0000-0000-000000000000", shard.blockStart)
+	assert.Equal(t, "7fffffff-ffff-ffff-ffff-ffffffffffff", shard.blockEnd)
+	require.NotNil(t, shard.include)
+	assert.True(t, shard.include(&encoding.BlockMeta{BlockID: uuid.MustParse("12345678-0000-0000-0000-000000000000")}))
+	assert.False(t, shard.include(&encoding.BlockMeta{BlockID: uuid.MustParse("82345678-0000-0000-0000-000000000000")}))
+
+	_, err = parseShardParams(httptest.NewRequest("GET", "/api/traces/01?mode=foo", nil))
+	assert.Error(t, err)
+
+	_, err = parseShardParams(httptest.NewRequest("GET", "/api/traces/01?mode=blocks&blockStart=foo&blockEnd=bar", nil))
+	assert.Error(t, err)
+
+	// a known time window and service prune blocks by their summaries
+	shard, err = parseShardParams(httptest.NewRequest("GET", "/api/traces/01?start=100&end=200&service=frontend", nil))
+	require.NoError(t, err)
+	assert.Equal(t, QueryModeAll, shard.mode)
+	require.NotNil(t, shard.include)
+	summary := func(service string, start int64, end int64) *encoding.BlockMeta {
+		s := encoding.NewBlockSummary()
+		s.AddTrace([]string{service}, nil, 1, uint64(start)*1e9, uint64(end)*1e9)
+		return &encoding.BlockMeta{Summary: s}
+	}
+	assert.True(t, shard.include(&encoding.BlockMeta{}))
+	assert.True(t, shard.include(summary("frontend", 150, 160)))
+	assert.False(t, shard.include(summary("frontend", 201, 300)))
+	assert.False(t, shard.include(summary("db", 150, 160)))
+
+	_, err = parseShardParams(httptest.NewRequest("GET", "/api/traces/01?start=200&end=100", nil))
+	assert.Error(t, err)
+}
+
+func TestParseSearchRequest(t *testing.T) {
+	req, err := parseSearchRequest(httptest.NewRequest("GET", "/api/search?tags=service.name%3Dfoo+http.status_code%3D500&tags=a%3Db%3Dc&minDuration=2s&limit=5&start=10&end=20&mostRecent=true", nil))
+	require.NoError(t, err)
+	assert.Equal(t, &tempopb.SearchRequest{
+		Tags:          map[string]string{"service.name": "foo", "http.status_code": "500", "a": "b=c"},
+		MinDurationMs: 2000,
+		Limit:         5,
+		Start:         10,
+		End:           20,
+		MostRecent:    true,
+	}, req)
+
+	for _, query := range []string{
+		"tags=foo",
+		"tags=%3Dfoo",
+		"minDuration=2",
+		"minDuration=2s&maxDuration=1s",
+		"limit=-1",
+		"limit=100000",
+		"start=20&end=10",
+		"mostRecent=yes",
+	} {
+		_, err := parseSearchRequest(httptest.NewRequest("GET", "/api/search?"+query, nil))
+		assert.Error(t, err, query)
+	}
+}
+
+func TestAddSearchResult(t *testing.T) {
+	traces := map[string]*tempopb.TraceSearchMetadata{}
+	addSearchResult(traces, &tempopb.TraceSearchMetadata{TraceID: "01", StartTimeUnixNano: 2e6, DurationMs: 3})
+	addSearchResult(traces, &tempopb.TraceSearchMetadata{TraceID: "01", StartTimeUnixNano: 1e6, DurationMs: 2, RootServiceName: "svc", RootTraceName: "root"})
+
+	assert.Equal(t, map[string]*tempopb.TraceSearchMetadata{
+		"01": {TraceID: "01", StartTimeUnixNano: 1e6, DurationMs: 4, RootServiceName: "svc", RootTraceName: "root"},
+	}, traces)
+}
@@ -0,0 +1,71 @@
+package querier
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+
+	v1_common "github.com/open-telemetry/opentelemetry-proto/gen/go/common/v1"
+	v1_resource "github.com/open-telemetry/opentelemetry-proto/gen/go/resource/v1"
+
+	"github.com/grafana/tempo/pkg/tempopb"
+)
+
+// ExternalLabelsHeader lists the querier's external labels on query responses as comma separated key=value pairs.
+const ExternalLabelsHeader = "X-Tempo-External-Labels"
+
+// setExternalLabelsHeader sets the external labels header if any external labels are configured.
+func (q *Querier) setExternalLabelsHeader(w http.ResponseWriter) {
+	if len(q.cfg.ExternalLabels) == 0 {
+		return
+	}
+
+	pairs := make([]string, 0, len(q.cfg.ExternalLabels))
+	for _, k := range sortedKeys(q.cfg.ExternalLabels) {
+		pairs = append(pairs, k+"="+q.cfg.ExternalLabels[k])
+	}
+	w.Header().Set(ExternalLabelsHeader, strings.Join(pairs, ","))
+}
+
+// addExternalLabels adds the labels to the resource attributes of every batch.  Attributes the batch already has are
+// kept so labels set by the instrumented service win.
+func addExternalLabels(trace *tempopb.Trace, labels map[string]string) {
+	if trace == nil || len(labels) == 0 {
+		return
+	}
+
+	keys := sortedKeys(labels)
+	for _, batch := range trace.Batches {
+		if batch.Resource == nil {
+			batch.Resource = &v1_resource.Resource{}
+		}
+
+		for _, k := range keys {
+			if hasAttribute(batch.Resource.Attributes, k) {
+				continue
+			}
+			batch.Resource.Attributes = append(batch.Resource.Attributes, &v1_common.KeyValue{
+				Key:   k,
+				Value: &v1_common.AnyValue{Value: &v1_common.AnyValue_StringValue{StringValue: labels[k]}},
+			})
+		}
+	}
+}
+
+func hasAttribute(attrs []*v1_common.KeyValue, key string) bool {
+	for _, attr := range attrs {
+		if attr.Key == key {
+			return true
+		}
+	}
+	return false
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,48 @@
+package querier
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	v1_common "github.com/open-telemetry/opentelemetry-proto/gen/go/common/v1"
+	v1_resource "github.com/open-telemetry/opentelemetry-proto/gen/go/resource/v1"
+	v1_trace "github.com/open-telemetry/opentelemetry-proto/gen/go/trace/v1"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/grafana/tempo/pkg/tempopb"
+)
+
+func TestAddExternalLabels(t *testing.T) {
+	stringValue := func(s string) *v1_common.AnyValue {
+		return &v1_common.AnyValue{Value: &v1_common.AnyValue_StringValue{StringValue: s}}
+	}
+
+	// labels set by the service are kept
+	trace := &tempopb.Trace{
+		Batches: []*v1_trace.ResourceSpans{
+			{Resource: &v1_resource.Resource{Attributes: []*v1_common.KeyValue{{Key: "cluster", Value: stringValue("own")}}}},
+			{},
+		},
+	}
+
+	addExternalLabels(trace, map[string]string{"region": "eu", "cluster": "eu-1"})
+
+	assert.Equal(t, []*v1_common.KeyValue{
+		{Key: "cluster", Value: stringValue("own")},
+		{Key: "region", Value: stringValue("eu")},
+	}, trace.Batches[0].Resource.Attributes)
+	assert.Equal(t, []*v1_common.KeyValue{
+		{Key: "cluster", Value: stringValue("eu-1")},
+		{Key: "region", Value: stringValue("eu")},
+	}, trace.Batches[1].Resource.Attributes)
+}
+
+func TestSetExternalLabelsHeader(t *testing.T) {
+	w := httptest.NewRecorder()
+	(&Querier{}).setExternalLabelsHeader(w)
+	assert.Empty(t, w.Header().Values(ExternalLabelsHeader))
+
+	q := &Querier{cfg: Config{ExternalLabels: map[string]string{"region": "eu", "cluster": "eu-1"}}}
+	q.setExternalLabelsHeader(w)
+	assert.Equal(t, "cluster=eu-1,region=eu", w.Header().Get(ExternalLabelsHeader))
+}
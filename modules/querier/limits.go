@@ -0,0 +1,119 @@
+package querier
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/grafana/tempo/pkg/tenantmetrics"
+)
+
+const (
+	limitMaxBytesPerTraceQuery = "max_bytes_per_trace_query"
+	limitMaxConcurrentQueries  = "max_concurrent_queries"
+)
+
+var (
+	metricQueryLimitExceeded = tenantmetrics.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "tempo",
+		Name:      "querier_query_limit_exceeded_total",
+		Help:      "The total number of queries rejected because the tenant exceeded a query limit.",
+	}, []string{"tenant", "limit"})
+	metricConcurrentQueries = tenantmetrics.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "tempo",
+		Name:      "querier_concurrent_queries",
+		Help:      "The number of trace lookups and searches of the tenant running on this querier.",
+	}, []string{"tenant"})
+)
+
+// queryLimitError is returned when a query is rejected by one of the tenant's query limits.  It carries a
+// ResourceExhausted status over grpc and is written as a JSON body with a 429 over http.
+type queryLimitError struct {
+	Message string `json:"error"`
+	Tenant  string `json:"tenant"`
+	Limit   string `json:"limit"`
+	Max     int    `json:"max"`
+}
+
+func newQueryLimitError(tenant string, limit string, max int, format string, args ...interface{}) *queryLimitError {
+	metricQueryLimitExceeded.WithLabelValues(tenant, limit).Inc()
+	return &queryLimitError{
+		Message: fmt.Sprintf(format, args...),
+		Tenant:  tenant,
+		Limit:   limit,
+		Max:     max,
+	}
+}
+
+func (e *queryLimitError) Error() string {
+	return e.Message
+}
+
+// GRPCStatus is used by status.FromError and status.Code.
+func (e *queryLimitError) GRPCStatus() *status.Status {
+	return status.New(codes.ResourceExhausted, e.Message)
+}
+
+// writeHTTP responds with a 429 and the error as JSON.
+func (e *queryLimitError) writeHTTP(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusTooManyRequests)
+	_ = json.NewEncoder(w).Encode(e)
+}
+
+// unwrapQueryLimitError returns the queryLimitError wrapped in err so its status is kept over grpc, or err if there is
+// none.
+func unwrapQueryLimitError(err error) error {
+	var limitErr *queryLimitError
+	if errors.As(err, &limitErr) {
+		return limitErr
+	}
+	return err
+}
+
+// tenantConcurrency counts the queries of each tenant running on the querier so a single tenant can't take all of
+// its memory.  Queries over the limit are rejected rather than queued, leaving retries to the client.
+type tenantConcurrency struct {
+	mtx     sync.Mutex
+	running map[string]int
+}
+
+func newTenantConcurrency() *tenantConcurrency {
+	return &tenantConcurrency{
+		running: map[string]int{},
+	}
+}
+
+// acquire starts a query of the tenant and returns the func ending it.  It returns a queryLimitError if the tenant
+// already has max queries running.  A max of 0 disables the limit.
+func (c *tenantConcurrency) acquire(userID string, max int) (func(), error) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	if max > 0 && c.running[userID] >= max {
+		return nil, newQueryLimitError(userID, limitMaxConcurrentQueries, max, "tenant %s has too many queries running: max %d per querier", userID, max)
+	}
+
+	c.running[userID]++
+	metricConcurrentQueries.WithLabelValues(userID).Inc()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			c.mtx.Lock()
+			defer c.mtx.Unlock()
+
+			c.running[userID]--
+			if c.running[userID] == 0 {
+				delete(c.running, userID)
+			}
+			metricConcurrentQueries.WithLabelValues(userID).Dec()
+		})
+	}, nil
+}
@@ -0,0 +1,97 @@
+package querier
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	ingester_client "github.com/grafana/tempo/modules/ingester/client"
+	"github.com/grafana/tempo/pkg/tempopb"
+	"github.com/grafana/tempo/pkg/util/test"
+)
+
+func TestNewRequiresLimits(t *testing.T) {
+	_, err := New(Config{}, ingester_client.Config{}, nil, nil, nil, nil, nil)
+	assert.EqualError(t, err, "querier requires overrides")
+}
+
+func TestTenantConcurrency(t *testing.T) {
+	c := newTenantConcurrency()
+
+	// disabled
+	for i := 0; i < 10; i++ {
+		_, err := c.acquire("other", 0)
+		require.NoError(t, err)
+	}
+
+	release, err := c.acquire("test", 2)
+	require.NoError(t, err)
+	_, err = c.acquire("test", 2)
+	require.NoError(t, err)
+
+	_, err = c.acquire("test", 2)
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+
+	// released once however often it's called
+	release()
+	release()
+	release, err = c.acquire("test", 2)
+	require.NoError(t, err)
+	_, err = c.acquire("test", 2)
+	assert.Error(t, err)
+	release()
+}
+
+func TestTraceAssemblerLimit(t *testing.T) {
+	// traces are random so each replica has its own size
+	replicas := []*tempopb.Trace{
+		test.MakeTrace(10, []byte{0x01}),
+		test.MakeTrace(10, []byte{0x01}),
+		test.MakeTrace(10, []byte{0x01}),
+	}
+	size := replicas[0].Size()
+
+	asm := newTraceAssembler(TraceSpillConfig{})
+	asm.limit("test", size+replicas[1].Size())
+	require.NoError(t, asm.combine(&tempopb.TraceByIDResponse{Trace: replicas[0]}))
+	require.NoError(t, asm.combine(&tempopb.TraceByIDResponse{Trace: replicas[1]}))
+
+	// replicas count toward the limit
+	err := asm.combine(&tempopb.TraceByIDResponse{Trace: replicas[2]})
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+	assert.Error(t, asm.receive(0))
+
+	// traces read from the store count as well
+	asm = newTraceAssembler(TraceSpillConfig{})
+	asm.limit("test", size)
+	assert.NoError(t, asm.receive(size))
+	assert.Error(t, asm.receive(1))
+}
+
+func TestWriteQueryLimitError(t *testing.T) {
+	q := &Querier{queries: newActiveQueries()}
+
+	w := httptest.NewRecorder()
+	err := newQueryLimitError("test", limitMaxConcurrentQueries, 2, "too many queries")
+	q.writeQueryError(w, "", fmt.Errorf("error querying ingesters: %w", err))
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+
+	body := map[string]interface{}{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	assert.Equal(t, map[string]interface{}{
+		"error":  "too many queries",
+		"tenant": "test",
+		"limit":  "max_concurrent_queries",
+		"max":    float64(2),
+	}, body)
+
+	assert.Equal(t, err, unwrapQueryLimitError(fmt.Errorf("wrapped: %w", err)))
+}
@@ -0,0 +1,121 @@
+package querier
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/cortexproject/cortex/pkg/ring"
+	"github.com/opentracing/opentracing-go"
+	"github.com/pkg/errors"
+	"github.com/weaveworks/common/user"
+
+	"github.com/grafana/tempo/pkg/tempopb"
+	tempo_util "github.com/grafana/tempo/pkg/util"
+)
+
+const (
+	defaultOverviewServices = 10
+	maxOverviewServices     = 100
+)
+
+// Overview is the per tenant landing page data returned by the overview endpoint.
+type Overview struct {
+	WindowSeconds uint32            `json:"windowSeconds"`
+	Services      []ServiceOverview `json:"services"`
+}
+
+// ServiceOverview summarizes the spans of a service over the window of the overview.
+type ServiceOverview struct {
+	ServiceName    string  `json:"serviceName"`
+	Spans          uint64  `json:"spans"`
+	SpansPerSecond float64 `json:"spansPerSecond"`
+	ErrorRate      float64 `json:"errorRate"`
+	P99Ms          float64 `json:"p99Ms"`
+}
+
+// Overview returns the limit services with the most spans pushed over roughly the last hour, with their error rate and
+// p99 span duration.  It is computed by the ingesters from the spans they have received, so nothing is read from the
+// backend.  Every span is pushed to as many ingesters as the replication factor so the span counts are divided by it.
+// They are approximate: ingesters that restarted or were unreachable are missing and spans that failed to be pushed
+// to some replicas are undercounted.
+func (q *Querier) Overview(ctx context.Context, limit int) (*Overview, error) {
+	if _, err := user.ExtractOrgID(ctx); err != nil {
+		return nil, errors.Wrap(err, "error extracting org id in Querier.Overview")
+	}
+
+	span, ctx := opentracing.StartSpanFromContext(ctx, "Querier.Overview")
+	defer span.Finish()
+
+	replicationSet, err := q.ring.GetAll(ring.Read)
+	if err != nil {
+		return nil, errors.Wrap(err, "error finding ingesters in Querier.Overview")
+	}
+
+	responses, err := q.forGivenIngesters(ctx, replicationSet, func(client tempopb.QuerierClient) (interface{}, error) {
+		return client.Overview(ctx, &tempopb.OverviewRequest{})
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "error querying ingesters in Querier.Overview")
+	}
+
+	resps := make([]*tempopb.OverviewResponse, 0, len(responses))
+	for _, r := range responses {
+		resps = append(resps, r.response.(*tempopb.OverviewResponse))
+	}
+
+	return combineOverviews(resps, q.ring.ReplicationFactor(), limit), nil
+}
+
+// combineOverviews adds up the services of the ingesters' overviews and returns the limit services with the most
+// spans.  The window is the longest of the ingesters'.
+func combineOverviews(resps []*tempopb.OverviewResponse, replicationFactor int, limit int) *Overview {
+	overview := &Overview{}
+	services := map[string]*tempopb.ServiceOverview{}
+	for _, resp := range resps {
+		if resp.WindowSeconds > overview.WindowSeconds {
+			overview.WindowSeconds = resp.WindowSeconds
+		}
+		for _, s := range resp.Services {
+			combined, ok := services[s.Name]
+			if !ok {
+				combined = &tempopb.ServiceOverview{Name: s.Name}
+				services[s.Name] = combined
+			}
+			tempo_util.CombineServiceOverviews(combined, s)
+		}
+	}
+
+	if replicationFactor < 1 {
+		replicationFactor = 1
+	}
+	overview.Services = make([]ServiceOverview, 0, len(services))
+	for _, s := range services {
+		if s.Spans == 0 {
+			continue
+		}
+
+		service := ServiceOverview{
+			ServiceName: s.Name,
+			Spans:       s.Spans / uint64(replicationFactor),
+			ErrorRate:   float64(s.Errors) / float64(s.Spans),
+			P99Ms:       float64(tempo_util.OverviewQuantile(s, .99)) / float64(time.Millisecond),
+		}
+		if overview.WindowSeconds > 0 {
+			service.SpansPerSecond = float64(service.Spans) / float64(overview.WindowSeconds)
+		}
+		overview.Services = append(overview.Services, service)
+	}
+
+	sort.Slice(overview.Services, func(i, j int) bool {
+		if overview.Services[i].Spans != overview.Services[j].Spans {
+			return overview.Services[i].Spans > overview.Services[j].Spans
+		}
+		return overview.Services[i].ServiceName < overview.Services[j].ServiceName
+	})
+	if len(overview.Services) > limit {
+		overview.Services = overview.Services[:limit]
+	}
+
+	return overview
+}
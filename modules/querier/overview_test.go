@@ -0,0 +1,31 @@
+package querier
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/grafana/tempo/pkg/tempopb"
+	tempo_util "github.com/grafana/tempo/pkg/util"
+)
+
+func TestCombineOverviews(t *testing.T) {
+	service := func(name string, spans uint64, errors uint64) *tempopb.ServiceOverview {
+		buckets := make([]uint64, len(tempo_util.OverviewDurationBounds)+1)
+		buckets[1] = spans
+		return &tempopb.ServiceOverview{Name: name, Spans: spans, Errors: errors, DurationBuckets: buckets}
+	}
+
+	// every span was pushed to both ingesters
+	resps := []*tempopb.OverviewResponse{
+		{WindowSeconds: 100, Services: []*tempopb.ServiceOverview{service("a", 200, 20), service("b", 50, 0), service("c", 10, 10)}},
+		{WindowSeconds: 50, Services: []*tempopb.ServiceOverview{service("a", 200, 20), service("b", 50, 0)}},
+	}
+
+	overview := combineOverviews(resps, 2, 2)
+	assert.Equal(t, uint32(100), overview.WindowSeconds)
+	assert.Equal(t, []ServiceOverview{
+		{ServiceName: "a", Spans: 200, SpansPerSecond: 2, ErrorRate: .1, P99Ms: 1.99},
+		{ServiceName: "b", Spans: 50, SpansPerSecond: .5, ErrorRate: 0, P99Ms: 1.99},
+	}, overview.Services)
+}
@@ -3,6 +3,8 @@ package querier
 import (
 	"context"
 	"fmt"
+	"io"
+	"time"
 
 	"github.com/gogo/protobuf/proto"
 	"github.com/opentracing/opentracing-go"
@@ -10,6 +12,9 @@ import (
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
 	"github.com/weaveworks/common/user"
+	"go.uber.org/atomic"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 
 	"github.com/cortexproject/cortex/pkg/ring"
 	ring_client "github.com/cortexproject/cortex/pkg/ring/client"
@@ -19,9 +24,11 @@ import (
 	ingester_client "github.com/grafana/tempo/modules/ingester/client"
 	"github.com/grafana/tempo/modules/overrides"
 	"github.com/grafana/tempo/modules/storage"
+	"github.com/grafana/tempo/pkg/encryption"
 	"github.com/grafana/tempo/pkg/tempopb"
 	tempo_util "github.com/grafana/tempo/pkg/util"
 	"github.com/grafana/tempo/pkg/validation"
+	"github.com/grafana/tempo/pkg/watermark"
 )
 
 var (
@@ -42,17 +49,54 @@ var (
 		Name:      "querier_ingester_clients",
 		Help:      "The current number of ingester clients.",
 	})
+	metricBlockGatewayClients = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: "tempo",
+		Name:      "querier_block_gateway_clients",
+		Help:      "The current number of block gateway clients.",
+	})
+	metricDuplicateSpans = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: "tempo",
+		Name:      "querier_duplicate_spans_total",
+		Help:      "The total number of duplicate spans removed while combining partial traces.",
+	})
 )
 
 // Querier handlers queries.
 type Querier struct {
 	services.Service
 
-	cfg    Config
-	ring   ring.ReadRing
-	pool   *ring_client.Pool
-	store  storage.Store
-	limits *overrides.Overrides
+	cfg     Config
+	ring    ring.ReadRing
+	pool    *ring_client.Pool
+	store   storage.Store
+	limits  *overrides.Overrides
+	quotas  *scanQuotas
+	queries *activeQueries
+
+	// queries of each tenant running at once, bounded by the tenant's max concurrent queries
+	concurrency *tenantConcurrency
+
+	// tails running at once, bounded by the max concurrent tails
+	tails atomic.Int32
+
+	// set when encrypted attribute values are decrypted for authorized callers
+	encrypter *encryption.Encrypter
+
+	// set when the querier pulls trace lookups from query frontends
+	worker services.Service
+
+	// set when traces served from immutable blocks are cached
+	responseCache *responseCache
+
+	// set when trace lookups are written to an access log
+	accessLog *accessLog
+
+	// set when searches are shed above a memory watermark
+	watermarks *watermark.Guard
+
+	// set when the backend is searched through block gateways
+	gatewayRing ring.ReadRing
+	gatewayPool *ring_client.Pool
 
 	subservicesWatcher *services.FailureWatcher
 }
@@ -62,8 +106,14 @@ type responseFromIngesters struct {
 	response interface{}
 }
 
-// New makes a new Querier.
-func New(cfg Config, clientCfg ingester_client.Config, ring ring.ReadRing, store storage.Store, limits *overrides.Overrides) (*Querier, error) {
+// New makes a new Querier.  gatewayRing may be nil, in which case the store is searched directly.  worker may be nil
+// if the querier doesn't pull lookups from query frontends.
+func New(cfg Config, clientCfg ingester_client.Config, ring ring.ReadRing, gatewayRing ring.ReadRing, store storage.Store, limits *overrides.Overrides, worker services.Service) (*Querier, error) {
+	// every query reads the tenant's limits, so a querier without them would fail on its first query
+	if limits == nil {
+		return nil, errors.New("querier requires overrides")
+	}
+
 	factory := func(addr string) (ring_client.PoolClient, error) {
 		return ingester_client.New(addr, clientCfg)
 	}
@@ -77,13 +127,50 @@ func New(cfg Config, clientCfg ingester_client.Config, ring ring.ReadRing, store
 			factory,
 			metricIngesterClients,
 			util.Logger),
-		store:  store,
-		limits: limits,
+		store:   store,
+		limits:  limits,
+		quotas:  newScanQuotas(),
+		queries: newActiveQueries(),
+
+		concurrency: newTenantConcurrency(),
+	}
+
+	encrypter, err := encryption.New(cfg.AttributeEncryption)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize attribute encryption %w", err)
+	}
+	q.encrypter = encrypter
+	q.watermarks = watermark.New(cfg.MemoryWatermarks, "querier")
+
+	q.responseCache, err = newResponseCache(cfg.ResponseCache, prometheus.DefaultRegisterer, util.Logger)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize response cache %w", err)
+	}
+
+	q.accessLog, err = newAccessLog(cfg.AccessLog)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize access log %w", err)
 	}
 
 	q.subservicesWatcher = services.NewFailureWatcher()
 	q.subservicesWatcher.WatchService(q.pool)
 
+	if gatewayRing != nil {
+		q.gatewayRing = gatewayRing
+		q.gatewayPool = ring_client.NewPool("querier_block_gateway_pool",
+			clientCfg.PoolConfig,
+			ring_client.NewRingServiceDiscovery(gatewayRing),
+			factory,
+			metricBlockGatewayClients,
+			util.Logger)
+		q.subservicesWatcher.WatchService(q.gatewayPool)
+	}
+
+	if worker != nil {
+		q.worker = worker
+		q.subservicesWatcher.WatchService(q.worker)
+	}
+
 	q.Service = services.NewBasicService(q.starting, q.running, q.stopping)
 	return q, nil
 }
@@ -94,6 +181,20 @@ func (q *Querier) starting(ctx context.Context) error {
 		return fmt.Errorf("failed to start pool %w", err)
 	}
 
+	if q.gatewayPool != nil {
+		err = services.StartAndAwaitRunning(ctx, q.gatewayPool)
+		if err != nil {
+			return fmt.Errorf("failed to start block gateway pool %w", err)
+		}
+	}
+
+	if q.worker != nil {
+		err = services.StartAndAwaitRunning(ctx, q.worker)
+		if err != nil {
+			return fmt.Errorf("failed to start frontend worker %w", err)
+		}
+	}
+
 	return nil
 }
 
@@ -108,54 +209,150 @@ func (q *Querier) running(ctx context.Context) error {
 
 // Called after distributor is asked to stop via StopAsync.
 func (q *Querier) stopping(_ error) error {
+	if q.worker != nil {
+		err := services.StopAndAwaitTerminated(context.Background(), q.worker)
+		if err != nil {
+			return err
+		}
+	}
+
+	if q.gatewayPool != nil {
+		err := services.StopAndAwaitTerminated(context.Background(), q.gatewayPool)
+		if err != nil {
+			return err
+		}
+	}
+
+	if q.responseCache != nil {
+		q.responseCache.stop()
+	}
+
+	q.accessLog.stop()
+
 	return services.StopAndAwaitTerminated(context.Background(), q.pool)
 }
 
 // FindTraceByID implements tempopb.Querier.
 func (q *Querier) FindTraceByID(ctx context.Context, req *tempopb.TraceByIDRequest) (*tempopb.TraceByIDResponse, error) {
+	return q.findTraceByID(ctx, req, lookupShard{mode: QueryModeAll}, nil)
+}
+
+// FindTraceByIDStream implements tempopb.Querier.  The trace is sent in chunks of batches.
+func (q *Querier) FindTraceByIDStream(req *tempopb.TraceByIDRequest, stream tempopb.Querier_FindTraceByIDStreamServer) error {
+	resp, err := q.FindTraceByID(stream.Context(), req)
+	if err != nil {
+		return err
+	}
+
+	return tempo_util.SendTraceInChunks(resp, stream.Send)
+}
+
+// findTraceByID searches the ingesters, the store or both depending on the shard's mode.  In QueryModeAll the store
+// is only searched if no ingester has the trace.  The shard's block range is ignored when searching through block
+// gateways.  Traces found only in blocks past the response cache's immutable age are cached, and cached traces are
+// served without asking the ingesters.  Partial traces from the ingesters and block gateways are combined by asm, which
+// may spill part of the trace to disk.  The returned trace then only holds the batches that weren't spilled.  A nil asm
+// keeps the whole trace in memory.
+func (q *Querier) findTraceByID(ctx context.Context, req *tempopb.TraceByIDRequest, shard lookupShard, asm *traceAssembler) (*tempopb.TraceByIDResponse, error) {
 	if !validation.ValidTraceID(req.TraceID) {
 		return nil, fmt.Errorf("invalid trace id")
 	}
 
-	userID, err := user.ExtractOrgID(ctx)
+	tenantIDs, err := q.tenantIDs(ctx)
 	if err != nil {
 		return nil, errors.Wrap(err, "error extracting org id in Querier.FindTraceByID")
 	}
+	if len(tenantIDs) > 1 {
+		return q.findTraceByIDFederated(ctx, req, shard, tenantIDs)
+	}
+	if asm == nil {
+		asm = newTraceAssembler(TraceSpillConfig{})
+	}
+	userID := tenantIDs[0]
 
-	span, ctx := opentracing.StartSpanFromContext(ctx, "Querier.FindTraceByID")
-	defer span.Finish()
-
-	key := tempo_util.TokenFor(userID, req.TraceID)
-
-	const maxExpectedReplicationSet = 3 // 3.  b/c frigg it
-	var descs [maxExpectedReplicationSet]ring.IngesterDesc
-	replicationSet, err := q.ring.Get(key, ring.Read, descs[:0])
+	release, err := q.concurrency.acquire(userID, q.limits.MaxConcurrentQueries(userID))
 	if err != nil {
-		return nil, errors.Wrap(err, "error finding ingesters in Querier.FindTraceByID")
+		return nil, err
 	}
+	defer release()
+	asm.limit(userID, q.limits.MaxBytesPerTraceQuery(userID))
 
-	// get responses from all ingesters in parallel
-	responses, err := q.forGivenIngesters(ctx, replicationSet, func(client tempopb.QuerierClient) (interface{}, error) {
-		return client.FindTraceByID(opentracing.ContextWithSpan(ctx, span), req)
-	})
-	if err != nil {
-		return nil, errors.Wrap(err, "error querying ingesters in Querier.FindTraceByID")
+	span, ctx := opentracing.StartSpanFromContext(ctx, "Querier.FindTraceByID")
+	defer span.Finish()
+
+	var cacheKey string
+	if q.responseCache != nil && shard.mode != QueryModeIngesters {
+		cacheKey = responseCacheKey(userID, req.TraceID, shard)
+		if trace, ok := q.responseCache.fetch(ctx, cacheKey); ok {
+			return &tempopb.TraceByIDResponse{
+				Trace:        trace,
+				CombinedFrom: 1,
+			}, nil
+		}
 	}
 
 	var completeTrace *tempopb.Trace
-	for _, r := range responses {
-		trace := r.response.(*tempopb.TraceByIDResponse).Trace
-		if trace != nil {
-			completeTrace = tempo_util.CombineTraceProtos(completeTrace, trace)
+	var combinedFrom int
+	var incomplete bool
+	if shard.mode != QueryModeBlocks {
+		key := tempo_util.TokenFor(userID, req.TraceID)
+
+		const maxExpectedReplicationSet = 3 // 3.  b/c frigg it
+		var descs [maxExpectedReplicationSet]ring.IngesterDesc
+		tenantRing, err := tempo_util.TenantRing(q.ring, userID, q.limits.IngestionTenantShardSize(userID))
+		if err != nil {
+			return nil, errors.Wrap(err, "error finding ingesters in Querier.FindTraceByID")
+		}
+		replicationSet, err := tenantRing.Get(key, ring.Read, descs[:0])
+		if err != nil {
+			return nil, errors.Wrap(err, "error finding ingesters in Querier.FindTraceByID")
+		}
+
+		// get responses from all ingesters in parallel.  with a replication factor above 1 every ingester holding a
+		// replica returns the same spans, which asm removes
+		_, err = q.forGivenIngesters(ctx, replicationSet, func(client tempopb.QuerierClient) (interface{}, error) {
+			return nil, streamTraceByID(opentracing.ContextWithSpan(ctx, span), client, req, asm)
+		})
+		if err != nil {
+			return nil, unwrapQueryLimitError(errors.Wrap(err, "error querying ingesters in Querier.FindTraceByID"))
 		}
+
+		resp := asm.response()
+		completeTrace = resp.Trace
+		combinedFrom = int(resp.CombinedFrom)
+		incomplete = resp.Incomplete
 	}
 
 	// if the ingester didn't have it check the store.
-	if completeTrace == nil {
-		foundBytes, metrics, err := q.store.Find(opentracing.ContextWithSpan(ctx, span), userID, req.TraceID)
+	if completeTrace == nil && shard.mode != QueryModeIngesters {
+		// returned unwrapped to preserve the grpc status
+		err = q.quotas.check(userID, q.limits.MaxBytesScannedPerHour(userID), q.limits.MaxBytesScannedPerDay(userID), time.Now())
+		if err != nil {
+			return nil, err
+		}
+
+		if q.gatewayRing != nil {
+			err = q.findTraceInBlockGateways(opentracing.ContextWithSpan(ctx, span), req, asm)
+			if err != nil {
+				return nil, unwrapQueryLimitError(errors.Wrap(err, "error querying block gateways in Querier.FindTraceByID"))
+			}
+
+			resp := asm.response()
+			if resp.Trace == nil {
+				resp.Trace = &tempopb.Trace{}
+			}
+			return resp, nil
+		}
+
+		foundBytes, metrics, err := q.store.FindInBlocks(opentracing.ContextWithSpan(ctx, span), userID, req.TraceID, shard.include, q.cfg.QueryBackendWorkers)
+		q.quotas.add(userID, int64(metrics.BloomFilterBytesRead.Load()+metrics.IndexBytesRead.Load()+metrics.BlockBytesRead.Load()), time.Now())
 		if err != nil {
 			return nil, errors.Wrap(err, "error querying store in Querier.FindTraceByID")
 		}
+		err = asm.receive(len(foundBytes))
+		if err != nil {
+			return nil, err
+		}
 
 		out := &tempopb.Trace{}
 		err = proto.Unmarshal(foundBytes, out)
@@ -164,6 +361,17 @@ func (q *Querier) FindTraceByID(ctx context.Context, req *tempopb.TraceByIDReque
 		}
 
 		completeTrace = out
+		if len(out.Batches) > 0 {
+			combinedFrom = 1
+		}
+		if cacheKey != "" && len(out.Batches) > 0 {
+			var foundBlockEnd time.Time
+			if end := metrics.FoundBlockEnd.Load(); end > 0 {
+				foundBlockEnd = time.Unix(0, end)
+			}
+			q.responseCache.store(ctx, cacheKey, out, foundBlockEnd, time.Now())
+		}
+
 		metricQueryReads.WithLabelValues("bloom").Observe(float64(metrics.BloomFilterReads.Load()))
 		metricQueryBytesRead.WithLabelValues("bloom").Observe(float64(metrics.BloomFilterBytesRead.Load()))
 		metricQueryReads.WithLabelValues("index").Observe(float64(metrics.IndexReads.Load()))
@@ -173,10 +381,144 @@ func (q *Querier) FindTraceByID(ctx context.Context, req *tempopb.TraceByIDReque
 	}
 
 	return &tempopb.TraceByIDResponse{
-		Trace: completeTrace,
+		Trace:        completeTrace,
+		CombinedFrom: uint32(combinedFrom),
+		Incomplete:   incomplete,
 	}, nil
 }
 
+// TraceMayExist answers whether a trace is likely to exist without reading it from the backend.  Ingesters are asked
+// first since they hold recent traces in memory, after which only the bloom filters of the store's blocks are checked.
+// A false positive is possible, a false negative is not.
+func (q *Querier) TraceMayExist(ctx context.Context, traceID []byte) (bool, error) {
+	if !validation.ValidTraceID(traceID) {
+		return false, fmt.Errorf("invalid trace id")
+	}
+
+	userID, err := user.ExtractOrgID(ctx)
+	if err != nil {
+		return false, errors.Wrap(err, "error extracting org id in Querier.TraceMayExist")
+	}
+
+	span, ctx := opentracing.StartSpanFromContext(ctx, "Querier.TraceMayExist")
+	defer span.Finish()
+
+	key := tempo_util.TokenFor(userID, traceID)
+
+	const maxExpectedReplicationSet = 3
+	var descs [maxExpectedReplicationSet]ring.IngesterDesc
+	tenantRing, err := tempo_util.TenantRing(q.ring, userID, q.limits.IngestionTenantShardSize(userID))
+	if err != nil {
+		return false, errors.Wrap(err, "error finding ingesters in Querier.TraceMayExist")
+	}
+	replicationSet, err := tenantRing.Get(key, ring.Read, descs[:0])
+	if err != nil {
+		return false, errors.Wrap(err, "error finding ingesters in Querier.TraceMayExist")
+	}
+
+	req := &tempopb.TraceByIDRequest{
+		TraceID: traceID,
+	}
+	responses, err := q.forGivenIngesters(ctx, replicationSet, func(client tempopb.QuerierClient) (interface{}, error) {
+		return receiveTraceByID(opentracing.ContextWithSpan(ctx, span), client, req)
+	})
+	if err != nil {
+		return false, errors.Wrap(err, "error querying ingesters in Querier.TraceMayExist")
+	}
+
+	for _, r := range responses {
+		if r.response.(*tempopb.TraceByIDResponse).Trace != nil {
+			return true, nil
+		}
+	}
+
+	err = q.quotas.check(userID, q.limits.MaxBytesScannedPerHour(userID), q.limits.MaxBytesScannedPerDay(userID), time.Now())
+	if err != nil {
+		return false, err
+	}
+
+	mayContain, metrics, err := q.store.MayContain(opentracing.ContextWithSpan(ctx, span), userID, traceID)
+	q.quotas.add(userID, int64(metrics.BloomFilterBytesRead.Load()), time.Now())
+	if err != nil {
+		return false, errors.Wrap(err, "error querying store in Querier.TraceMayExist")
+	}
+	metricQueryReads.WithLabelValues("bloom").Observe(float64(metrics.BloomFilterReads.Load()))
+	metricQueryBytesRead.WithLabelValues("bloom").Observe(float64(metrics.BloomFilterBytesRead.Load()))
+
+	return mayContain, nil
+}
+
+// findTraceInBlockGateways asks every block gateway for the trace.  Each gateway only searches the blocks it owns so
+// the results are combined by asm, which counts the gateways that had the trace.  Gateways don't report the bytes they
+// read so these lookups don't count toward scan quotas.
+func (q *Querier) findTraceInBlockGateways(ctx context.Context, req *tempopb.TraceByIDRequest, asm *traceAssembler) error {
+	replicationSet, err := q.gatewayRing.GetAll(ring.Read)
+	if err != nil {
+		return err
+	}
+
+	_, err = replicationSet.Do(ctx, 0, func(gateway *ring.IngesterDesc) (interface{}, error) {
+		client, err := q.gatewayPool.GetClientFor(gateway.Addr)
+		if err != nil {
+			return nil, err
+		}
+
+		return nil, streamTraceByID(ctx, client.(tempopb.QuerierClient), req, asm)
+	})
+	return err
+}
+
+// receiveTraceByID streams a trace from an ingester or block gateway so a large trace doesn't have to fit in one
+// message.  Instances that don't implement the stream yet are asked with FindTraceByID.
+func receiveTraceByID(ctx context.Context, client tempopb.QuerierClient, req *tempopb.TraceByIDRequest) (*tempopb.TraceByIDResponse, error) {
+	stream, err := client.FindTraceByIDStream(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := tempo_util.ReceiveTrace(stream)
+	if status.Code(err) == codes.Unimplemented {
+		return client.FindTraceByID(ctx, req)
+	}
+	return resp, err
+}
+
+// streamTraceByID is receiveTraceByID combining each chunk of the trace into asm as it arrives instead of holding the
+// whole partial trace first.
+func streamTraceByID(ctx context.Context, client tempopb.QuerierClient, req *tempopb.TraceByIDRequest, asm *traceAssembler) error {
+	stream, err := client.FindTraceByIDStream(ctx, req)
+	if err != nil {
+		return err
+	}
+
+	recv := stream.Recv
+	found := false
+	for {
+		resp, err := recv()
+		if err == io.EOF {
+			break
+		}
+		if status.Code(err) == codes.Unimplemented {
+			// the whole trace is received at once from instances that don't implement the stream
+			resp, err = client.FindTraceByID(ctx, req)
+			recv = func() (*tempopb.TraceByIDResponse, error) { return nil, io.EOF }
+		}
+		if err != nil {
+			return err
+		}
+
+		found = found || resp.Trace != nil && len(resp.Trace.Batches) > 0
+		if err := asm.combine(resp); err != nil {
+			return err
+		}
+	}
+
+	if found {
+		asm.partial()
+	}
+	return nil
+}
+
 // forGivenIngesters runs f, in parallel, for given ingesters
 func (q *Querier) forGivenIngesters(ctx context.Context, replicationSet ring.ReplicationSet, f func(tempopb.QuerierClient) (interface{}, error)) ([]responseFromIngesters, error) {
 	results, err := replicationSet.Do(ctx, q.cfg.ExtraQueryDelay, func(ingester *ring.IngesterDesc) (interface{}, error) {
@@ -0,0 +1,180 @@
+package querier
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-kit/kit/log/level"
+	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/weaveworks/common/user"
+
+	cortex_util "github.com/cortexproject/cortex/pkg/util"
+
+	"github.com/grafana/tempo/pkg/tenantmetrics"
+)
+
+const (
+	// QueryIDHeader holds the id of the query on the querier that ran it.  It is the id the query is cancelled with.
+	QueryIDHeader = "X-Tempo-Query-ID"
+
+	queryKindTraceByID = "trace_by_id"
+	queryKindSearch    = "search"
+	queryKindRecent    = "recent_traces"
+	queryKindOverview  = "overview"
+)
+
+var (
+	metricQueriesCancelled = tenantmetrics.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "tempo",
+		Name:      "querier_queries_cancelled_total",
+		Help:      "The total number of in-flight queries cancelled through the queries endpoint.",
+	}, []string{"tenant"})
+)
+
+// activeQueries are the queries the querier is running.  They are listed and cancelled by operators through
+// ActiveQueriesHandler and CancelQueriesHandler when a query pattern is overloading the cluster.
+type activeQueries struct {
+	mtx     sync.Mutex
+	queries map[string]*activeQuery
+}
+
+// activeQuery is a query in flight.
+type activeQuery struct {
+	ID      string    `json:"id"`
+	Tenant  string    `json:"tenant"`
+	Kind    string    `json:"kind"`
+	Request string    `json:"request"`
+	Start   time.Time `json:"start"`
+
+	cancel    context.CancelFunc
+	cancelled bool // set when cancelled by an operator, guarded by activeQueries.mtx
+}
+
+func newActiveQueries() *activeQueries {
+	return &activeQueries{
+		queries: map[string]*activeQuery{},
+	}
+}
+
+// start registers the query of the request and returns the context it must run with, the query id and a func to call
+// once it has finished.  The context is cancelled when the query is cancelled.
+func (a *activeQueries) start(ctx context.Context, kind string, r *http.Request) (context.Context, string, func()) {
+	ctx, cancel := context.WithCancel(ctx)
+	tenant, _ := user.ExtractOrgID(ctx)
+
+	query := &activeQuery{
+		ID:      uuid.New().String(),
+		Tenant:  tenant,
+		Kind:    kind,
+		Request: r.URL.RequestURI(),
+		Start:   time.Now(),
+		cancel:  cancel,
+	}
+
+	a.mtx.Lock()
+	a.queries[query.ID] = query
+	a.mtx.Unlock()
+
+	return ctx, query.ID, func() {
+		a.mtx.Lock()
+		delete(a.queries, query.ID)
+		a.mtx.Unlock()
+		cancel()
+	}
+}
+
+// list returns the queries in flight, oldest first.
+func (a *activeQueries) list() []activeQuery {
+	a.mtx.Lock()
+	queries := make([]activeQuery, 0, len(a.queries))
+	for _, q := range a.queries {
+		queries = append(queries, *q)
+	}
+	a.mtx.Unlock()
+
+	sort.Slice(queries, func(i, j int) bool {
+		return queries[i].Start.Before(queries[j].Start)
+	})
+	return queries
+}
+
+// cancel cancels the query with the id, or if the id is empty every query of the tenant, and returns the number of
+// queries cancelled.  A federated query is cancelled by any of its tenants.
+func (a *activeQueries) cancel(id string, tenant string) int {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+
+	cancelled := 0
+	for _, q := range a.queries {
+		if q.cancelled || (id != "" && q.ID != id) || (id == "" && !hasTenant(q.Tenant, tenant)) {
+			continue
+		}
+
+		q.cancelled = true
+		q.cancel()
+		metricQueriesCancelled.WithLabelValues(q.Tenant).Inc()
+		level.Warn(cortex_util.Logger).Log("msg", "cancelled query", "id", q.ID, "tenant", q.Tenant, "kind", q.Kind, "request", q.Request)
+		cancelled++
+	}
+	return cancelled
+}
+
+// wasCancelled returns true if the query with the id was cancelled by an operator.
+func (a *activeQueries) wasCancelled(id string) bool {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+
+	q, ok := a.queries[id]
+	return ok && q.cancelled
+}
+
+func hasTenant(orgID string, tenant string) bool {
+	for _, t := range strings.Split(orgID, tenantSeparator) {
+		if t == tenant {
+			return true
+		}
+	}
+	return false
+}
+
+// ActiveQueriesHandler is a http.HandlerFunc listing the queries in flight on this querier, oldest first.
+func (q *Querier) ActiveQueriesHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	err := json.NewEncoder(w).Encode(struct {
+		Queries []activeQuery `json:"queries"`
+	}{q.queries.list()})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// CancelQueriesHandler is a http.HandlerFunc cancelling the query in flight on this querier with the id query
+// parameter, or every query of the tenant query parameter.  It responds with the number of queries cancelled.
+func (q *Querier) CancelQueriesHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "queries must be cancelled with POST", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id, tenant := r.URL.Query().Get("id"), r.URL.Query().Get("tenant")
+	if (id == "") == (tenant == "") {
+		http.Error(w, "please provide either an id or a tenant", http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	err := json.NewEncoder(w).Encode(struct {
+		Cancelled int `json:"cancelled"`
+	}{q.queries.cancel(id, tenant)})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
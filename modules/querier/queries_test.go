@@ -0,0 +1,137 @@
+package querier
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/weaveworks/common/user"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestActiveQueries(t *testing.T) {
+	a := newActiveQueries()
+
+	start := func(orgID string, kind string) (string, func(), func() error) {
+		r := httptest.NewRequest(http.MethodGet, "/api/search?tags=service.name%3Dapp", nil)
+		ctx, id, done := a.start(user.InjectOrgID(r.Context(), orgID), kind, r)
+		return id, done, ctx.Err
+	}
+
+	id1, done1, err1 := start("a", queryKindSearch)
+	id2, done2, err2 := start("a|b", queryKindTraceByID)
+	id3, done3, err3 := start("c", queryKindSearch)
+	defer done3()
+
+	queries := a.list()
+	require.Len(t, queries, 3)
+	for i := 1; i < len(queries); i++ {
+		assert.False(t, queries[i].Start.Before(queries[i-1].Start))
+	}
+	for _, q := range queries {
+		if q.ID == id1 {
+			assert.Equal(t, "a", q.Tenant)
+			assert.Equal(t, queryKindSearch, q.Kind)
+			assert.Equal(t, "/api/search?tags=service.name%3Dapp", q.Request)
+		}
+	}
+
+	// a federated query is cancelled by any of its tenants
+	assert.Equal(t, 1, a.cancel("", "b"))
+	assert.NoError(t, err1())
+	assert.Error(t, err2())
+	assert.True(t, a.wasCancelled(id2))
+
+	assert.Equal(t, 1, a.cancel(id1, ""))
+	assert.Error(t, err1())
+	assert.NoError(t, err3())
+	assert.False(t, a.wasCancelled(id3))
+
+	// already cancelled or unknown queries aren't counted
+	assert.Equal(t, 0, a.cancel("", "a"))
+	assert.Equal(t, 0, a.cancel("unknown", ""))
+
+	done1()
+	done2()
+	queries = a.list()
+	require.Len(t, queries, 1)
+	assert.Equal(t, id3, queries[0].ID)
+	assert.False(t, a.wasCancelled(id1))
+}
+
+func TestCancelQueriesHandler(t *testing.T) {
+	q := &Querier{queries: newActiveQueries()}
+
+	r := httptest.NewRequest(http.MethodGet, "/api/search", nil)
+	_, id, done := q.queries.start(user.InjectOrgID(r.Context(), "test"), queryKindSearch, r)
+	defer done()
+
+	tests := []struct {
+		method    string
+		url       string
+		code      int
+		cancelled int
+	}{
+		{http.MethodGet, "/querier/queries/cancel?tenant=test", http.StatusMethodNotAllowed, 0},
+		{http.MethodPost, "/querier/queries/cancel", http.StatusBadRequest, 0},
+		{http.MethodPost, "/querier/queries/cancel?tenant=test&id=" + id, http.StatusBadRequest, 0},
+		{http.MethodPost, "/querier/queries/cancel?tenant=other", http.StatusOK, 0},
+		{http.MethodPost, "/querier/queries/cancel?id=" + id, http.StatusOK, 1},
+	}
+
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		q.CancelQueriesHandler(w, httptest.NewRequest(tt.method, tt.url, nil))
+		require.Equal(t, tt.code, w.Code, tt.url)
+		if tt.code != http.StatusOK {
+			continue
+		}
+
+		var resp struct {
+			Cancelled int `json:"cancelled"`
+		}
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+		assert.Equal(t, tt.cancelled, resp.Cancelled, tt.url)
+	}
+
+	w := httptest.NewRecorder()
+	q.ActiveQueriesHandler(w, httptest.NewRequest(http.MethodGet, "/querier/queries", nil))
+	var resp struct {
+		Queries []activeQuery `json:"queries"`
+	}
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+	require.Len(t, resp.Queries, 1)
+	assert.Equal(t, id, resp.Queries[0].ID)
+	assert.Equal(t, "test", resp.Queries[0].Tenant)
+}
+
+func TestWriteQueryError(t *testing.T) {
+	q := &Querier{queries: newActiveQueries()}
+
+	r := httptest.NewRequest(http.MethodGet, "/api/search", nil)
+	_, cancelledID, done := q.queries.start(user.InjectOrgID(r.Context(), "test"), queryKindSearch, r)
+	defer done()
+	q.queries.cancel(cancelledID, "")
+
+	tests := []struct {
+		queryID string
+		err     error
+		code    int
+	}{
+		{cancelledID, errors.New("context canceled"), http.StatusForbidden},
+		{"", status.Error(codes.PermissionDenied, "search is disabled for tenant test"), http.StatusForbidden},
+		{"", status.Error(codes.ResourceExhausted, "shed"), http.StatusTooManyRequests},
+		{"", errors.New("failed"), http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		q.writeQueryError(w, tt.queryID, tt.err)
+		assert.Equal(t, tt.code, w.Code, tt.err.Error())
+	}
+}
@@ -0,0 +1,108 @@
+package querier
+
+import (
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/grafana/tempo/pkg/tenantmetrics"
+)
+
+const (
+	quotaWindowHour = "hour"
+	quotaWindowDay  = "day"
+)
+
+var (
+	metricBytesScanned = tenantmetrics.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "tempo",
+		Name:      "querier_bytes_scanned_total",
+		Help:      "The total number of bytes read from the backend by queries.",
+	}, []string{"tenant"})
+	metricScanQuotaExceeded = tenantmetrics.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "tempo",
+		Name:      "querier_scan_quota_exceeded_total",
+		Help:      "The total number of queries rejected because the tenant exceeded a bytes scanned quota.",
+	}, []string{"tenant", "window"})
+)
+
+// scanQuotas tracks the bytes each tenant has scanned in the current hour and day.  Windows are aligned to the
+// wall clock and usage is tracked per querier.
+type scanQuotas struct {
+	mtx     sync.Mutex
+	tenants map[string]*tenantScan
+}
+
+type tenantScan struct {
+	hourStart time.Time
+	hourBytes int64
+	dayStart  time.Time
+	dayBytes  int64
+}
+
+func newScanQuotas() *scanQuotas {
+	return &scanQuotas{
+		tenants: map[string]*tenantScan{},
+	}
+}
+
+// check returns a ResourceExhausted error if the tenant has already scanned more than either limit in the current
+// window.  A limit of 0 disables it.
+func (s *scanQuotas) check(userID string, maxPerHour int, maxPerDay int, now time.Time) error {
+	if maxPerHour <= 0 && maxPerDay <= 0 {
+		return nil
+	}
+
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	scan := s.tenant(userID, now)
+	if maxPerHour > 0 && scan.hourBytes >= int64(maxPerHour) {
+		metricScanQuotaExceeded.WithLabelValues(userID, quotaWindowHour).Inc()
+		return status.Errorf(codes.ResourceExhausted, "bytes scanned quota exceeded: %d of %d bytes per hour", scan.hourBytes, maxPerHour)
+	}
+	if maxPerDay > 0 && scan.dayBytes >= int64(maxPerDay) {
+		metricScanQuotaExceeded.WithLabelValues(userID, quotaWindowDay).Inc()
+		return status.Errorf(codes.ResourceExhausted, "bytes scanned quota exceeded: %d of %d bytes per day", scan.dayBytes, maxPerDay)
+	}
+
+	return nil
+}
+
+// add records bytes scanned by the tenant.
+func (s *scanQuotas) add(userID string, bytes int64, now time.Time) {
+	metricBytesScanned.WithLabelValues(userID).Add(float64(bytes))
+
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	scan := s.tenant(userID, now)
+	scan.hourBytes += bytes
+	scan.dayBytes += bytes
+}
+
+// tenant returns the tenant's usage, resetting any window that has passed.  Must be called under lock.
+func (s *scanQuotas) tenant(userID string, now time.Time) *tenantScan {
+	scan, ok := s.tenants[userID]
+	if !ok {
+		scan = &tenantScan{}
+		s.tenants[userID] = scan
+	}
+
+	hourStart := now.Truncate(time.Hour)
+	if !scan.hourStart.Equal(hourStart) {
+		scan.hourStart = hourStart
+		scan.hourBytes = 0
+	}
+
+	dayStart := now.Truncate(24 * time.Hour)
+	if !scan.dayStart.Equal(dayStart) {
+		scan.dayStart = dayStart
+		scan.dayBytes = 0
+	}
+
+	return scan
+}
@@ -0,0 +1,77 @@
+package querier
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/atomic"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/grafana/tempo/modules/overrides"
+	"github.com/grafana/tempo/modules/storage"
+	"github.com/grafana/tempo/pkg/tempopb"
+	"github.com/grafana/tempo/tempodb"
+	"github.com/grafana/tempo/tempodb/encoding"
+)
+
+func TestScanQuotas(t *testing.T) {
+	q := newScanQuotas()
+	now := time.Date(2020, 10, 1, 10, 30, 0, 0, time.UTC)
+
+	// disabled
+	q.add("test", 1000, now)
+	assert.NoError(t, q.check("test", 0, 0, now))
+
+	// hourly
+	assert.Equal(t, codes.ResourceExhausted, status.Code(q.check("test", 1000, 0, now)))
+	assert.NoError(t, q.check("test", 1001, 0, now))
+	assert.NoError(t, q.check("other", 1000, 0, now))
+
+	// next hour resets the hourly window but not the daily one
+	now = now.Add(time.Hour)
+	assert.NoError(t, q.check("test", 1000, 0, now))
+	q.add("test", 500, now)
+	assert.Equal(t, codes.ResourceExhausted, status.Code(q.check("test", 0, 1500, now)))
+
+	// next day resets both
+	now = now.Add(24 * time.Hour)
+	assert.NoError(t, q.check("test", 1, 1, now))
+}
+
+// searchStore is a store whose searches read bytesRead bytes.  Only Search is implemented.
+type searchStore struct {
+	storage.Store
+	bytesRead int32
+	searches  int
+}
+
+func (s *searchStore) Search(context.Context, string, *encoding.SearchQuery) ([]encoding.SearchTrace, tempodb.FindMetrics, error) {
+	s.searches++
+	return []encoding.SearchTrace{{TraceID: encoding.ID{0x01}}}, tempodb.FindMetrics{
+		IndexBytesRead: atomic.NewInt32(s.bytesRead),
+		BlockBytesRead: atomic.NewInt32(s.bytesRead),
+	}, nil
+}
+
+func TestSearchScanQuota(t *testing.T) {
+	limits, err := overrides.NewOverrides(overrides.Limits{MaxBytesScannedPerHour: 100})
+	require.NoError(t, err)
+	store := &searchStore{bytesRead: 50}
+	q := &Querier{store: store, limits: limits, quotas: newScanQuotas()}
+
+	found, err := q.searchStore(context.Background(), "test", &tempopb.SearchRequest{})
+	require.NoError(t, err)
+	assert.Len(t, found, 1)
+
+	// the search sections and blocks read used up the quota
+	_, err = q.searchStore(context.Background(), "test", &tempopb.SearchRequest{})
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+	assert.Equal(t, 1, store.searches)
+
+	_, err = q.searchStore(context.Background(), "other", &tempopb.SearchRequest{})
+	assert.NoError(t, err)
+}
@@ -0,0 +1,98 @@
+package querier
+
+import (
+	"context"
+	"encoding/hex"
+	"time"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/opentracing/opentracing-go"
+	"github.com/pkg/errors"
+	"github.com/weaveworks/common/user"
+
+	"github.com/grafana/tempo/pkg/tempopb"
+)
+
+const (
+	defaultRecentTraces = 20
+	maxRecentTraces     = 100
+
+	serviceNameAttribute = "service.name"
+)
+
+// RecentTrace summarizes a trace returned by RecentTraces.  The root fields are empty if the root span was not found.
+type RecentTrace struct {
+	TraceID         string `json:"traceID"`
+	RootServiceName string `json:"rootServiceName,omitempty"`
+	RootSpanName    string `json:"rootSpanName,omitempty"`
+	DurationMs      uint64 `json:"durationMs,omitempty"`
+}
+
+// RecentTraces returns up to limit traces from the tenant's most recently flushed blocks.  It is a cheap check that
+// data is reaching the backend and not a search: traces still held by the ingesters are not included.
+func (q *Querier) RecentTraces(ctx context.Context, limit int) ([]RecentTrace, error) {
+	userID, err := user.ExtractOrgID(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "error extracting org id in Querier.RecentTraces")
+	}
+
+	span, ctx := opentracing.StartSpanFromContext(ctx, "Querier.RecentTraces")
+	defer span.Finish()
+
+	// returned unwrapped to preserve the grpc status
+	err = q.quotas.check(userID, q.limits.MaxBytesScannedPerHour(userID), q.limits.MaxBytesScannedPerDay(userID), time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	ids, objects, metrics, err := q.store.RecentObjects(ctx, userID, limit)
+	q.quotas.add(userID, int64(metrics.IndexBytesRead.Load()+metrics.BlockBytesRead.Load()), time.Now())
+	if err != nil {
+		return nil, errors.Wrap(err, "error querying store in Querier.RecentTraces")
+	}
+
+	traces := make([]RecentTrace, 0, len(ids))
+	for i, id := range ids {
+		trace := &tempopb.Trace{}
+		err = proto.Unmarshal(objects[i], trace)
+		if err != nil {
+			return nil, err
+		}
+
+		recent := rootSummary(trace)
+		recent.TraceID = hex.EncodeToString(id)
+		traces = append(traces, recent)
+	}
+
+	return traces, nil
+}
+
+// rootSummary describes the trace by its root span, the span without a parent.
+func rootSummary(trace *tempopb.Trace) RecentTrace {
+	for _, batch := range trace.Batches {
+		for _, ils := range batch.InstrumentationLibrarySpans {
+			for _, span := range ils.Spans {
+				if len(span.ParentSpanId) != 0 {
+					continue
+				}
+
+				summary := RecentTrace{
+					RootSpanName: span.Name,
+				}
+				if span.EndTimeUnixNano > span.StartTimeUnixNano {
+					summary.DurationMs = (span.EndTimeUnixNano - span.StartTimeUnixNano) / uint64(time.Millisecond)
+				}
+				if batch.Resource != nil {
+					for _, kv := range batch.Resource.Attributes {
+						if kv.Key == serviceNameAttribute && kv.Value != nil {
+							summary.RootServiceName = kv.Value.GetStringValue()
+						}
+					}
+				}
+				return summary
+			}
+		}
+	}
+
+	return RecentTrace{}
+}
@@ -0,0 +1,45 @@
+package querier
+
+import (
+	"testing"
+	"time"
+
+	v1_common "github.com/open-telemetry/opentelemetry-proto/gen/go/common/v1"
+	v1_resource "github.com/open-telemetry/opentelemetry-proto/gen/go/resource/v1"
+	v1 "github.com/open-telemetry/opentelemetry-proto/gen/go/trace/v1"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/grafana/tempo/pkg/tempopb"
+)
+
+func TestRootSummary(t *testing.T) {
+	batch := func(service string, spans ...*v1.Span) *v1.ResourceSpans {
+		return &v1.ResourceSpans{
+			Resource: &v1_resource.Resource{
+				Attributes: []*v1_common.KeyValue{
+					{Key: serviceNameAttribute, Value: &v1_common.AnyValue{Value: &v1_common.AnyValue_StringValue{StringValue: service}}},
+				},
+			},
+			InstrumentationLibrarySpans: []*v1.InstrumentationLibrarySpans{{Spans: spans}},
+		}
+	}
+
+	child := &v1.Span{Name: "child", ParentSpanId: []byte{0x01}}
+	root := &v1.Span{Name: "root", StartTimeUnixNano: uint64(time.Second), EndTimeUnixNano: uint64(3 * time.Second)}
+
+	trace := &tempopb.Trace{
+		Batches: []*v1.ResourceSpans{
+			batch("backend", child),
+			batch("frontend", root),
+		},
+	}
+	assert.Equal(t, RecentTrace{
+		RootServiceName: "frontend",
+		RootSpanName:    "root",
+		DurationMs:      2000,
+	}, rootSummary(trace))
+
+	// root not received
+	trace.Batches = trace.Batches[:1]
+	assert.Equal(t, RecentTrace{}, rootSummary(trace))
+}
@@ -0,0 +1,180 @@
+package querier
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/cortexproject/cortex/pkg/ring"
+	"github.com/opentracing/opentracing-go"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/grafana/tempo/pkg/tempopb"
+	tempo_util "github.com/grafana/tempo/pkg/util"
+	"github.com/grafana/tempo/tempodb/encoding"
+)
+
+const (
+	defaultSearchLimit = 20
+	maxSearchLimit     = 1000
+)
+
+// Search returns traces with all of the requested tags from every ingester and the search sections of the store's
+// blocks.  Traces are returned newest first.  Blocks written before search was added have no search section and
+// their traces are only found if SearchScanUnindexedBlocks is set.  Searches with MostRecent set return as soon as the
+// limit is met, skipping the store if the ingesters found enough traces.  Searches of the store count toward the
+// tenant's bytes scanned quotas.
+func (q *Querier) Search(ctx context.Context, req *tempopb.SearchRequest) (*tempopb.SearchResponse, error) {
+	tenantIDs, err := q.tenantIDs(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "error extracting org id in Querier.Search")
+	}
+
+	span, ctx := opentracing.StartSpanFromContext(ctx, "Querier.Search")
+	defer span.Finish()
+
+	for _, tenantID := range tenantIDs {
+		if q.limits.SearchDisabled(tenantID) {
+			return nil, status.Errorf(codes.PermissionDenied, "search is disabled for tenant %s", tenantID)
+		}
+	}
+
+	if q.watermarks.RejectSearch() {
+		return nil, status.Error(codes.ResourceExhausted, "querier memory above search watermark, rejecting search")
+	}
+
+	if req.Limit == 0 {
+		req.Limit = defaultSearchLimit
+	}
+
+	if len(tenantIDs) > 1 {
+		return q.searchFederated(ctx, req, tenantIDs)
+	}
+	userID := tenantIDs[0]
+
+	release, err := q.concurrency.acquire(userID, q.limits.MaxConcurrentQueries(userID))
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	replicationSet, err := q.ring.GetAll(ring.Read)
+	if err != nil {
+		return nil, errors.Wrap(err, "error finding ingesters in Querier.Search")
+	}
+
+	responses, err := q.forGivenIngesters(ctx, replicationSet, func(client tempopb.QuerierClient) (interface{}, error) {
+		return client.Search(ctx, req)
+	})
+	if status.Code(err) == codes.ResourceExhausted {
+		// an ingester is shedding searches.  returned as is so the caller sees a 429
+		return nil, err
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "error querying ingesters in Querier.Search")
+	}
+
+	traces := map[string]*tempopb.TraceSearchMetadata{}
+	for _, r := range responses {
+		for _, t := range r.response.(*tempopb.SearchResponse).Traces {
+			addSearchResult(traces, t)
+		}
+	}
+
+	if req.MostRecent && len(traces) >= int(req.Limit) {
+		// the ingesters hold the newest data so the store can't have newer traces
+		return searchResponse(traces, int(req.Limit)), nil
+	}
+
+	found, err := q.searchStore(ctx, userID, req)
+	if err != nil {
+		return nil, err
+	}
+	for i := range found {
+		addSearchResult(traces, tempo_util.SearchMetadata(&found[i]))
+	}
+
+	return searchResponse(traces, int(req.Limit)), nil
+}
+
+// searchStore searches the tenant's blocks in the store.  The search sections and blocks read count toward the
+// tenant's bytes scanned quotas, and the search is refused once a quota is used up.
+func (q *Querier) searchStore(ctx context.Context, userID string, req *tempopb.SearchRequest) ([]encoding.SearchTrace, error) {
+	// returned unwrapped to preserve the grpc status
+	err := q.quotas.check(userID, q.limits.MaxBytesScannedPerHour(userID), q.limits.MaxBytesScannedPerDay(userID), time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	query := tempo_util.SearchQueryFromRequest(req)
+	if q.cfg.SearchScanUnindexedBlocks {
+		query.Matcher = tempo_util.TraceMatcher{}
+	}
+	found, metrics, err := q.store.Search(ctx, userID, query)
+	q.quotas.add(userID, int64(metrics.IndexBytesRead.Load()+metrics.BlockBytesRead.Load()), time.Now())
+	if err != nil {
+		return nil, errors.Wrap(err, "error querying store in Querier.Search")
+	}
+
+	return found, nil
+}
+
+// CombineSearchResponses merges the traces of several search responses and returns up to limit of them, newest first.
+// A limit of 0 uses the default search limit.
+func CombineSearchResponses(resps []*tempopb.SearchResponse, limit int) *tempopb.SearchResponse {
+	if limit == 0 {
+		limit = defaultSearchLimit
+	}
+
+	traces := map[string]*tempopb.TraceSearchMetadata{}
+	for _, resp := range resps {
+		for _, t := range resp.Traces {
+			addSearchResult(traces, t)
+		}
+	}
+
+	return searchResponse(traces, limit)
+}
+
+// searchResponse returns up to limit of the traces found, newest first.
+func searchResponse(traces map[string]*tempopb.TraceSearchMetadata, limit int) *tempopb.SearchResponse {
+	resp := &tempopb.SearchResponse{
+		Traces: make([]*tempopb.TraceSearchMetadata, 0, len(traces)),
+	}
+	for _, t := range traces {
+		resp.Traces = append(resp.Traces, t)
+	}
+	sort.Slice(resp.Traces, func(i, j int) bool {
+		return resp.Traces[i].StartTimeUnixNano > resp.Traces[j].StartTimeUnixNano
+	})
+	if len(resp.Traces) > limit {
+		resp.Traces = resp.Traces[:limit]
+	}
+
+	return resp
+}
+
+// addSearchResult adds a trace found by a search.  A trace found in several places is merged so it covers the earliest
+// start and longest duration seen.
+func addSearchResult(traces map[string]*tempopb.TraceSearchMetadata, t *tempopb.TraceSearchMetadata) {
+	existing, ok := traces[t.TraceID]
+	if !ok {
+		traces[t.TraceID] = t
+		return
+	}
+
+	end := existing.StartTimeUnixNano + uint64(existing.DurationMs)*1e6
+	if tEnd := t.StartTimeUnixNano + uint64(t.DurationMs)*1e6; tEnd > end {
+		end = tEnd
+	}
+	if t.StartTimeUnixNano < existing.StartTimeUnixNano {
+		existing.StartTimeUnixNano = t.StartTimeUnixNano
+	}
+	existing.DurationMs = uint32((end - existing.StartTimeUnixNano) / 1e6)
+	if existing.RootTraceName == "" {
+		existing.RootServiceName = t.RootServiceName
+		existing.RootTraceName = t.RootTraceName
+	}
+}
@@ -0,0 +1,255 @@
+package querier
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sync"
+
+	"github.com/grafana/tempo/pkg/tempopb"
+	tempo_util "github.com/grafana/tempo/pkg/util"
+	v1 "github.com/open-telemetry/opentelemetry-proto/gen/go/trace/v1"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	metricSpilledTraces = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: "tempo",
+		Name:      "querier_spilled_traces_total",
+		Help:      "Total number of traces whose assembly spilled to disk.",
+	})
+	metricSpilledBytes = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: "tempo",
+		Name:      "querier_spilled_bytes_total",
+		Help:      "Total number of bytes of trace batches spilled to disk.",
+	})
+)
+
+// TraceSpillConfig bounds the memory used to assemble a single trace.
+type TraceSpillConfig struct {
+	// ThresholdBytes is the size of combined batches held in memory after which they're written to a spill file.
+	// 0 disables spilling.
+	ThresholdBytes int `yaml:"threshold_bytes"`
+	// Path is the directory of the spill files.  Defaults to the system temp directory.
+	Path string `yaml:"path"`
+}
+
+// traceAssembler combines the partial traces of a lookup as they're received.  Once the combined batches held in memory
+// pass the threshold they're written to a spill file and released, so a rare multi-GB trace doesn't need every querier
+// sized for it.  Only the span ids of spilled batches stay in memory to recognise duplicates.  It's safe for concurrent
+// use.
+type traceAssembler struct {
+	threshold int
+	dir       string
+
+	mtx          sync.Mutex
+	tenant       string
+	maxBytes     int
+	received     int
+	limitErr     error
+	combiner     tempo_util.TraceCombiner
+	inMemory     int
+	combinedFrom int
+	incomplete   bool
+
+	file    *os.File
+	writer  *bufio.Writer
+	spilled int
+}
+
+// newTraceAssembler returns an assembler that never spills if cfg.ThresholdBytes is 0.
+func newTraceAssembler(cfg TraceSpillConfig) *traceAssembler {
+	return &traceAssembler{
+		threshold: cfg.ThresholdBytes,
+		dir:       cfg.Path,
+	}
+}
+
+// limit rejects the lookup with a queryLimitError once the partial traces received for the tenant pass maxBytes.  0
+// disables the limit.
+func (a *traceAssembler) limit(tenant string, maxBytes int) {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+
+	a.tenant = tenant
+	a.maxBytes = maxBytes
+}
+
+// receive counts the size of a partial trace toward the limit, whether or not it is combined by the assembler.
+func (a *traceAssembler) receive(size int) error {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+
+	return a.receiveLocked(size)
+}
+
+// receiveLocked is receive under lock.  The error is returned for every partial trace after the limit is passed, but
+// only counted once.
+func (a *traceAssembler) receiveLocked(size int) error {
+	a.received += size
+	if a.limitErr == nil && a.maxBytes > 0 && a.received > a.maxBytes {
+		a.limitErr = newQueryLimitError(a.tenant, limitMaxBytesPerTraceQuery, a.maxBytes, "trace lookup of tenant %s exceeded the max bytes per trace query (%d)", a.tenant, a.maxBytes)
+	}
+	return a.limitErr
+}
+
+// combine adds a partial trace, or a chunk of one.  It is destructive like TraceCombiner.Combine.  A partial trace is
+// counted toward combinedFrom by partial.
+func (a *traceAssembler) combine(resp *tempopb.TraceByIDResponse) error {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+
+	a.incomplete = a.incomplete || resp.Incomplete
+	if resp.Trace == nil || len(resp.Trace.Batches) == 0 {
+		return nil
+	}
+
+	// the size is counted before duplicates are removed so it's an upper bound
+	size := resp.Trace.Size()
+	if err := a.receiveLocked(size); err != nil {
+		return err
+	}
+	a.inMemory += size
+	a.combiner.Combine(resp.Trace)
+	if a.threshold <= 0 || a.inMemory <= a.threshold {
+		return nil
+	}
+
+	return a.spill()
+}
+
+// partial counts a partial trace that was found toward combinedFrom.  It's separate from combine since a partial
+// trace may be combined in several chunks.
+func (a *traceAssembler) partial() {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+
+	a.combinedFrom++
+}
+
+// spill writes the batches held in memory to the spill file, creating it first if needed.  Each batch is written as its
+// length followed by the marshalled batch.
+func (a *traceAssembler) spill() error {
+	if a.file == nil {
+		f, err := ioutil.TempFile(a.dir, "trace-spill-")
+		if err != nil {
+			return fmt.Errorf("failed to create trace spill file: %w", err)
+		}
+		a.file = f
+		a.writer = bufio.NewWriter(f)
+		metricSpilledTraces.Inc()
+	}
+
+	trace := a.combiner.Flush()
+	a.inMemory = 0
+	if trace == nil {
+		return nil
+	}
+
+	var header [binary.MaxVarintLen64]byte
+	for _, batch := range trace.Batches {
+		buff, err := batch.Marshal()
+		if err != nil {
+			return err
+		}
+
+		n := binary.PutUvarint(header[:], uint64(len(buff)))
+		if _, err := a.writer.Write(header[:n]); err != nil {
+			return fmt.Errorf("failed to write trace spill file: %w", err)
+		}
+		if _, err := a.writer.Write(buff); err != nil {
+			return fmt.Errorf("failed to write trace spill file: %w", err)
+		}
+		a.spilled++
+		metricSpilledBytes.Add(float64(n + len(buff)))
+	}
+
+	return nil
+}
+
+// response returns the combined batches still held in memory, which excludes any spilled batches, along with the
+// number of partial traces combined.  The trace is nil if nothing was combined.
+func (a *traceAssembler) response() *tempopb.TraceByIDResponse {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+
+	trace := a.combiner.Result()
+	if trace == nil && a.spilled > 0 {
+		trace = &tempopb.Trace{}
+	}
+
+	metricDuplicateSpans.Add(float64(a.combiner.DuplicateSpans()))
+	return &tempopb.TraceByIDResponse{
+		Trace:        trace,
+		CombinedFrom: uint32(a.combinedFrom),
+		Incomplete:   a.incomplete,
+	}
+}
+
+// spilledBatches returns the number of batches written to the spill file.
+func (a *traceAssembler) spilledBatches() int {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+
+	return a.spilled
+}
+
+// forEachSpilled reads the spilled batches back one at a time.
+func (a *traceAssembler) forEachSpilled(fn func(*v1.ResourceSpans) error) error {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+
+	if a.file == nil {
+		return nil
+	}
+	if err := a.writer.Flush(); err != nil {
+		return fmt.Errorf("failed to write trace spill file: %w", err)
+	}
+	if _, err := a.file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	r := bufio.NewReader(a.file)
+	for i := 0; i < a.spilled; i++ {
+		length, err := binary.ReadUvarint(r)
+		if err != nil {
+			return fmt.Errorf("failed to read trace spill file: %w", err)
+		}
+		buff := make([]byte, length)
+		if _, err := io.ReadFull(r, buff); err != nil {
+			return fmt.Errorf("failed to read trace spill file: %w", err)
+		}
+
+		batch := &v1.ResourceSpans{}
+		if err := batch.Unmarshal(buff); err != nil {
+			return err
+		}
+		if err := fn(batch); err != nil {
+			return err
+		}
+	}
+
+	_, err := a.file.Seek(0, io.SeekEnd)
+	return err
+}
+
+// close removes the spill file.
+func (a *traceAssembler) close() error {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+
+	if a.file == nil {
+		return nil
+	}
+
+	err := a.file.Close()
+	if removeErr := os.Remove(a.file.Name()); err == nil {
+		err = removeErr
+	}
+	a.file = nil
+	return err
+}
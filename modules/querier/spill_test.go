@@ -0,0 +1,91 @@
+package querier
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/golang/protobuf/jsonpb"
+	v1 "github.com/open-telemetry/opentelemetry-proto/gen/go/trace/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/tempo/pkg/tempopb"
+	"github.com/grafana/tempo/pkg/util/test"
+)
+
+func TestTraceAssemblerSpills(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	trace := test.MakeTrace(20, []byte{0x01})
+	asm := newTraceAssembler(TraceSpillConfig{
+		ThresholdBytes: trace.Size() / 4,
+		Path:           dir,
+	})
+
+	// two replicas of the trace, received in chunks of a batch
+	for replica := 0; replica < 2; replica++ {
+		for _, batch := range proto.Clone(trace).(*tempopb.Trace).Batches {
+			err = asm.combine(&tempopb.TraceByIDResponse{
+				Trace:      &tempopb.Trace{Batches: []*v1.ResourceSpans{batch}},
+				Incomplete: replica == 1,
+			})
+			require.NoError(t, err)
+		}
+		asm.partial()
+	}
+
+	assert.Greater(t, asm.spilledBatches(), 0)
+	files, err := ioutil.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Len(t, files, 1)
+
+	resp := asm.response()
+	assert.Equal(t, uint32(2), resp.CombinedFrom)
+	assert.True(t, resp.Incomplete)
+
+	// spilled batches and the ones held in memory make up the trace with each span once
+	actual := &bytes.Buffer{}
+	err = writeTrace(actual, func(fn func(*v1.ResourceSpans) error) error {
+		if err := asm.forEachSpilled(fn); err != nil {
+			return err
+		}
+		return traceBatches(resp.Trace)(fn)
+	})
+	require.NoError(t, err)
+
+	expected := &bytes.Buffer{}
+	require.NoError(t, (&jsonpb.Marshaler{}).Marshal(expected, trace))
+	assert.Equal(t, expected.String(), actual.String())
+
+	require.NoError(t, asm.close())
+	files, err = ioutil.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Empty(t, files)
+}
+
+func TestTraceAssemblerInMemory(t *testing.T) {
+	trace := test.MakeTrace(5, []byte{0x01})
+	asm := newTraceAssembler(TraceSpillConfig{})
+
+	resp := asm.response()
+	assert.Nil(t, resp.Trace)
+
+	require.NoError(t, asm.combine(&tempopb.TraceByIDResponse{Trace: proto.Clone(trace).(*tempopb.Trace)}))
+	asm.partial()
+
+	assert.Equal(t, 0, asm.spilledBatches())
+	resp = asm.response()
+	assert.Equal(t, trace, resp.Trace)
+	assert.Equal(t, uint32(1), resp.CombinedFrom)
+	assert.False(t, resp.Incomplete)
+	assert.NoError(t, asm.forEachSpilled(func(*v1.ResourceSpans) error {
+		t.Fatal("nothing was spilled")
+		return nil
+	}))
+	assert.NoError(t, asm.close())
+}
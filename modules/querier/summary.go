@@ -0,0 +1,78 @@
+package querier
+
+import (
+	"sort"
+	"time"
+
+	v1 "github.com/open-telemetry/opentelemetry-proto/gen/go/trace/v1"
+)
+
+// TraceSummarySuffix ends the path of a trace lookup returning a TraceSummary.
+const TraceSummarySuffix = "/summary"
+
+// TraceSummary describes a trace returned by TraceSummaryHandler without its spans.  The root fields are empty if the
+// root span was not found.
+type TraceSummary struct {
+	TraceID           string   `json:"traceID"`
+	SpanCount         int      `json:"spanCount"`
+	StartTimeUnixNano uint64   `json:"startTimeUnixNano,omitempty"`
+	DurationMs        uint64   `json:"durationMs"`
+	RootServiceName   string   `json:"rootServiceName,omitempty"`
+	RootSpanName      string   `json:"rootSpanName,omitempty"`
+	Services          []string `json:"services"`
+}
+
+// summarizeTrace summarizes the batches passed by forEachBatch one at a time, so a spilled trace is never held in
+// memory.  The duration runs from the earliest span start to the latest span end.
+func summarizeTrace(forEachBatch func(func(*v1.ResourceSpans) error) error) (TraceSummary, error) {
+	summary := TraceSummary{
+		Services: []string{},
+	}
+	services := map[string]struct{}{}
+	rootFound := false
+	var end uint64
+
+	err := forEachBatch(func(batch *v1.ResourceSpans) error {
+		var service string
+		if batch.Resource != nil {
+			for _, kv := range batch.Resource.Attributes {
+				if kv.Key == serviceNameAttribute && kv.Value != nil {
+					service = kv.Value.GetStringValue()
+				}
+			}
+		}
+		if service != "" {
+			if _, ok := services[service]; !ok {
+				services[service] = struct{}{}
+				summary.Services = append(summary.Services, service)
+			}
+		}
+
+		for _, ils := range batch.InstrumentationLibrarySpans {
+			summary.SpanCount += len(ils.Spans)
+			for _, span := range ils.Spans {
+				if span.StartTimeUnixNano != 0 && (summary.StartTimeUnixNano == 0 || span.StartTimeUnixNano < summary.StartTimeUnixNano) {
+					summary.StartTimeUnixNano = span.StartTimeUnixNano
+				}
+				if span.EndTimeUnixNano > end {
+					end = span.EndTimeUnixNano
+				}
+				if !rootFound && len(span.ParentSpanId) == 0 {
+					rootFound = true
+					summary.RootSpanName = span.Name
+					summary.RootServiceName = service
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return TraceSummary{}, err
+	}
+
+	if end > summary.StartTimeUnixNano {
+		summary.DurationMs = (end - summary.StartTimeUnixNano) / uint64(time.Millisecond)
+	}
+	sort.Strings(summary.Services)
+	return summary, nil
+}
@@ -0,0 +1,63 @@
+package querier
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	v1_common "github.com/open-telemetry/opentelemetry-proto/gen/go/common/v1"
+	v1_resource "github.com/open-telemetry/opentelemetry-proto/gen/go/resource/v1"
+	v1 "github.com/open-telemetry/opentelemetry-proto/gen/go/trace/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/tempo/pkg/tempopb"
+)
+
+func TestSummarizeTrace(t *testing.T) {
+	batch := func(service string, spans ...*v1.Span) *v1.ResourceSpans {
+		return &v1.ResourceSpans{
+			Resource: &v1_resource.Resource{
+				Attributes: []*v1_common.KeyValue{
+					{Key: serviceNameAttribute, Value: &v1_common.AnyValue{Value: &v1_common.AnyValue_StringValue{StringValue: service}}},
+				},
+			},
+			InstrumentationLibrarySpans: []*v1.InstrumentationLibrarySpans{{Spans: spans}},
+		}
+	}
+
+	root := &v1.Span{Name: "root", StartTimeUnixNano: uint64(2 * time.Second), EndTimeUnixNano: uint64(4 * time.Second)}
+	early := &v1.Span{Name: "early", ParentSpanId: []byte{0x01}, StartTimeUnixNano: uint64(time.Second), EndTimeUnixNano: uint64(2 * time.Second)}
+	late := &v1.Span{Name: "late", ParentSpanId: []byte{0x01}, StartTimeUnixNano: uint64(3 * time.Second), EndTimeUnixNano: uint64(5 * time.Second)}
+
+	trace := &tempopb.Trace{
+		Batches: []*v1.ResourceSpans{
+			batch("frontend", root),
+			batch("db", early, late),
+			batch("backend", late),
+		},
+	}
+	summary, err := summarizeTrace(traceBatches(trace))
+	require.NoError(t, err)
+	assert.Equal(t, TraceSummary{
+		SpanCount:         4,
+		StartTimeUnixNano: uint64(time.Second),
+		DurationMs:        4000,
+		RootServiceName:   "frontend",
+		RootSpanName:      "root",
+		Services:          []string{"backend", "db", "frontend"},
+	}, summary)
+
+	// root not received
+	trace.Batches = trace.Batches[1:]
+	summary, err = summarizeTrace(traceBatches(trace))
+	require.NoError(t, err)
+	assert.Equal(t, "", summary.RootSpanName)
+	assert.Equal(t, 3, summary.SpanCount)
+
+	// failing to read spilled batches fails the summary
+	_, err = summarizeTrace(func(func(*v1.ResourceSpans) error) error {
+		return errors.New("spill file gone")
+	})
+	assert.Error(t, err)
+}
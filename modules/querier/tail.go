@@ -0,0 +1,277 @@
+package querier
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cortexproject/cortex/pkg/ring"
+	cortex_util "github.com/cortexproject/cortex/pkg/util"
+	"github.com/go-kit/kit/log/level"
+	"github.com/gogo/protobuf/jsonpb"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/grafana/tempo/pkg/tempopb"
+	v1_trace "github.com/open-telemetry/opentelemetry-proto/gen/go/trace/v1"
+)
+
+// tailSeenSpans is the number of spans remembered per generation to drop the copies of spans sent by other ingesters.
+// Copies arrive within moments of each other, so two generations are plenty.
+const tailSeenSpans = 10000
+
+var errTooManyTails = status.Error(codes.ResourceExhausted, "too many tails running on this querier")
+
+// Tail calls fn with the spans pushed to the ingesters from now on whose span or resource has all of the tags of the
+// request, as they're pushed, until ctx is done or a stream fails.  Spans are received from every replica, so copies
+// are dropped.  Ingesters that join the ring after the tail started aren't tailed.
+func (q *Querier) Tail(ctx context.Context, req *tempopb.TailRequest, fn func(*tempopb.TailResponse) error) error {
+	tenantIDs, err := q.tenantIDs(ctx)
+	if err != nil {
+		return errors.Wrap(err, "error extracting org id in Querier.Tail")
+	}
+	if len(tenantIDs) > 1 {
+		return status.Error(codes.InvalidArgument, "several tenants can't be tailed at once")
+	}
+	if q.limits.SearchDisabled(tenantIDs[0]) {
+		return status.Errorf(codes.PermissionDenied, "search is disabled for tenant %s", tenantIDs[0])
+	}
+
+	defer q.tails.Dec()
+	if running := q.tails.Inc(); q.cfg.MaxConcurrentTails > 0 && running > int32(q.cfg.MaxConcurrentTails) {
+		return errTooManyTails
+	}
+
+	replicationSet, err := q.ring.GetAll(ring.Read)
+	if err != nil {
+		return errors.Wrap(err, "error finding ingesters in Querier.Tail")
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	streams := make([]tempopb.Querier_TailClient, 0, len(replicationSet.Ingesters))
+	for _, ingester := range replicationSet.Ingesters {
+		client, err := q.pool.GetClientFor(ingester.Addr)
+		if err != nil {
+			return errors.Wrap(err, "error finding ingesters in Querier.Tail")
+		}
+		stream, err := client.(tempopb.QuerierClient).Tail(ctx, req)
+		if err != nil {
+			return errors.Wrapf(err, "error tailing ingester %s", ingester.Addr)
+		}
+		streams = append(streams, stream)
+	}
+
+	resps := make(chan *tempopb.TailResponse)
+	errs := make(chan error, len(streams))
+	var wg sync.WaitGroup
+	for _, stream := range streams {
+		wg.Add(1)
+		go func(stream tempopb.Querier_TailClient) {
+			defer wg.Done()
+			for {
+				resp, err := stream.Recv()
+				if err != nil {
+					errs <- err
+					return
+				}
+				select {
+				case resps <- resp:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}(stream)
+	}
+	// the receivers are cancelled before they're waited for
+	defer wg.Wait()
+	defer cancel()
+
+	seen := newSeenSpans(tailSeenSpans)
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case err := <-errs:
+			if ctx.Err() != nil {
+				return nil
+			}
+			return errors.Wrap(err, "error tailing ingesters in Querier.Tail")
+		case resp := <-resps:
+			resp.Batches = seen.dropSeen(resp.Batches)
+			if len(resp.Batches) == 0 && resp.Dropped == 0 {
+				continue
+			}
+			if err := fn(resp); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// TailHandler streams matching spans as they're pushed, one JSON encoded tempopb.TailResponse per line.  The tail ends
+// after the duration parameter, capped by the max tail duration, or when the client goes away.
+func (q *Querier) TailHandler(w http.ResponseWriter, r *http.Request) {
+	req, duration, err := parseTailRequest(r, q.cfg.TailMaxDuration)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+	if duration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, duration)
+		defer cancel()
+	}
+
+	var decryptTenant string
+	if q.encrypter != nil && q.encrypter.Authorized(r.Header.Get(q.encrypter.RoleHeader())) {
+		tenantIDs, err := q.tenantIDs(ctx)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		decryptTenant = tenantIDs[0]
+	}
+
+	q.setExternalLabelsHeader(w)
+	flusher, _ := w.(http.Flusher)
+	marshaller := &jsonpb.Marshaler{}
+	started := false
+	err = q.Tail(ctx, req, func(resp *tempopb.TailResponse) error {
+		trace := &tempopb.Trace{Batches: resp.Batches}
+		if decryptTenant != "" {
+			if err := q.encrypter.DecryptTrace(decryptTenant, trace); err != nil {
+				level.Warn(cortex_util.Logger).Log("msg", "failed to decrypt attributes", "tenant", decryptTenant, "err", err)
+			}
+		}
+		addExternalLabels(trace, q.cfg.ExternalLabels)
+
+		if !started {
+			w.Header().Set("Content-Type", "application/x-ndjson")
+			started = true
+		}
+		if err := marshaller.Marshal(w, resp); err != nil {
+			return err
+		}
+		if _, err := w.Write([]byte("\n")); err != nil {
+			return err
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+		return nil
+	})
+	if err == nil {
+		return
+	}
+	if started {
+		// the status can't be changed once the body is being written
+		level.Warn(cortex_util.Logger).Log("msg", "tail failed", "err", err)
+		return
+	}
+	switch {
+	case status.Code(err) == codes.InvalidArgument:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case status.Code(err) == codes.PermissionDenied:
+		http.Error(w, err.Error(), http.StatusForbidden)
+	case status.Code(err) == codes.ResourceExhausted:
+		http.Error(w, err.Error(), http.StatusTooManyRequests)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// parseTailRequest returns the tags of the tail and how long it runs.  A tail without a duration runs for the max
+// duration, and forever if there's none.
+func parseTailRequest(r *http.Request, maxDuration time.Duration) (*tempopb.TailRequest, time.Duration, error) {
+	params := r.URL.Query()
+	req := &tempopb.TailRequest{
+		Tags: map[string]string{},
+	}
+
+	for _, tags := range params["tags"] {
+		for _, tag := range strings.Fields(tags) {
+			kv := strings.SplitN(tag, "=", 2)
+			if len(kv) != 2 || kv[0] == "" {
+				return nil, 0, fmt.Errorf("invalid tag %q.  tags must be key=value", tag)
+			}
+			req.Tags[kv[0]] = kv[1]
+		}
+	}
+
+	duration := maxDuration
+	if s := params.Get("duration"); s != "" {
+		d, err := time.ParseDuration(s)
+		if err != nil || d <= 0 {
+			return nil, 0, fmt.Errorf("invalid duration %q", s)
+		}
+		if maxDuration == 0 || d < maxDuration {
+			duration = d
+		}
+	}
+	return req, duration, nil
+}
+
+// seenSpans remembers the ids of recent spans in two generations.  When the current generation is full it replaces the
+// previous one, so between size and twice size spans are remembered.
+type seenSpans struct {
+	size     int
+	current  map[string]struct{}
+	previous map[string]struct{}
+}
+
+func newSeenSpans(size int) *seenSpans {
+	return &seenSpans{
+		size:    size,
+		current: make(map[string]struct{}, size),
+	}
+}
+
+// dropSeen returns the batches without the spans seen before, and remembers the others.  Batches are changed in place.
+func (s *seenSpans) dropSeen(batches []*v1_trace.ResourceSpans) []*v1_trace.ResourceSpans {
+	kept := batches[:0]
+	for _, batch := range batches {
+		ilsKept := batch.InstrumentationLibrarySpans[:0]
+		for _, ils := range batch.InstrumentationLibrarySpans {
+			spans := ils.Spans[:0]
+			for _, span := range ils.Spans {
+				if s.add(string(span.TraceId) + string(span.SpanId)) {
+					spans = append(spans, span)
+				}
+			}
+			if len(spans) > 0 {
+				ils.Spans = spans
+				ilsKept = append(ilsKept, ils)
+			}
+		}
+		if len(ilsKept) > 0 {
+			batch.InstrumentationLibrarySpans = ilsKept
+			kept = append(kept, batch)
+		}
+	}
+	return kept
+}
+
+// add remembers the id and returns false if it was seen before.
+func (s *seenSpans) add(id string) bool {
+	if _, ok := s.current[id]; ok {
+		return false
+	}
+	if _, ok := s.previous[id]; ok {
+		return false
+	}
+
+	if len(s.current) >= s.size {
+		s.previous = s.current
+		s.current = make(map[string]struct{}, s.size)
+	}
+	s.current[id] = struct{}{}
+	return true
+}
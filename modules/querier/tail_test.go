@@ -0,0 +1,62 @@
+package querier
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/tempo/pkg/tempopb"
+	"github.com/grafana/tempo/pkg/util/test"
+	v1_trace "github.com/open-telemetry/opentelemetry-proto/gen/go/trace/v1"
+)
+
+func TestParseTailRequest(t *testing.T) {
+	req, duration, err := parseTailRequest(httptest.NewRequest("GET", "/api/tail?tags=service.name%3Dfoo+a%3Db%3Dc&duration=1m", nil), time.Hour)
+	require.NoError(t, err)
+	assert.Equal(t, &tempopb.TailRequest{Tags: map[string]string{"service.name": "foo", "a": "b=c"}}, req)
+	assert.Equal(t, time.Minute, duration)
+
+	// capped by the max duration
+	_, duration, err = parseTailRequest(httptest.NewRequest("GET", "/api/tail?duration=2h", nil), time.Hour)
+	require.NoError(t, err)
+	assert.Equal(t, time.Hour, duration)
+	_, duration, err = parseTailRequest(httptest.NewRequest("GET", "/api/tail", nil), 0)
+	require.NoError(t, err)
+	assert.Equal(t, time.Duration(0), duration)
+
+	for _, query := range []string{
+		"tags=foo",
+		"duration=2",
+		"duration=-1s",
+	} {
+		_, _, err := parseTailRequest(httptest.NewRequest("GET", "/api/tail?"+query, nil), time.Hour)
+		assert.Error(t, err, query)
+	}
+}
+
+func TestSeenSpansDropSeen(t *testing.T) {
+	seen := newSeenSpans(5)
+
+	first := test.MakeRequest(3, nil).Batch
+	second := test.MakeRequest(2, nil).Batch
+	kept := seen.dropSeen([]*v1_trace.ResourceSpans{proto.Clone(first).(*v1_trace.ResourceSpans)})
+	assert.Equal(t, []*v1_trace.ResourceSpans{first}, kept)
+
+	// copies of the spans from other ingesters are dropped
+	kept = seen.dropSeen([]*v1_trace.ResourceSpans{
+		proto.Clone(first).(*v1_trace.ResourceSpans),
+		proto.Clone(second).(*v1_trace.ResourceSpans),
+	})
+	assert.Equal(t, []*v1_trace.ResourceSpans{second}, kept)
+	assert.Empty(t, seen.dropSeen([]*v1_trace.ResourceSpans{proto.Clone(second).(*v1_trace.ResourceSpans)}))
+
+	// spans are forgotten two generations later
+	seen.dropSeen([]*v1_trace.ResourceSpans{test.MakeRequest(5, nil).Batch})
+	assert.Empty(t, seen.dropSeen([]*v1_trace.ResourceSpans{proto.Clone(first).(*v1_trace.ResourceSpans)}))
+	seen.dropSeen([]*v1_trace.ResourceSpans{test.MakeRequest(5, nil).Batch})
+	assert.Equal(t, []*v1_trace.ResourceSpans{first}, seen.dropSeen([]*v1_trace.ResourceSpans{proto.Clone(first).(*v1_trace.ResourceSpans)}))
+}
@@ -6,44 +6,97 @@ import (
 
 	"github.com/grafana/tempo/pkg/util"
 	"github.com/grafana/tempo/tempodb"
+	"github.com/grafana/tempo/tempodb/backend/azure"
 	"github.com/grafana/tempo/tempodb/backend/gcs"
 	"github.com/grafana/tempo/tempodb/backend/local"
 	"github.com/grafana/tempo/tempodb/backend/s3"
+	"github.com/grafana/tempo/tempodb/encoding"
 	"github.com/grafana/tempo/tempodb/pool"
 	"github.com/grafana/tempo/tempodb/wal"
 )
 
 // Config is the Tempo storage configuration
 type Config struct {
-	Trace tempodb.Config `yaml:"trace"`
+	Trace       tempodb.Config    `yaml:"trace"`
+	FlushNotify FlushNotifyConfig `yaml:"flush_notify"`
 }
 
 var DefaultBlocklistPoll = 5 * time.Minute
 
 // RegisterFlagsAndApplyDefaults registers the flags.
 func (cfg *Config) RegisterFlagsAndApplyDefaults(prefix string, f *flag.FlagSet) {
-	f.StringVar(&cfg.Trace.Backend, util.PrefixConfig(prefix, "trace.backend"), "", "Trace backend (s3, gcs, local)")
+	f.StringVar(&cfg.Trace.Backend, util.PrefixConfig(prefix, "trace.backend"), "", "Trace backend (s3, gcs, azure, local, inmemory)")
 	f.DurationVar(&cfg.Trace.BlocklistPoll, util.PrefixConfig(prefix, "trace.maintenance-cycle"), DefaultBlocklistPoll, "Period at which to run the maintenance cycle.")
+	f.IntVar(&cfg.Trace.BlocklistMaxNewBlocks, util.PrefixConfig(prefix, "trace.blocklist-max-new-blocks"), 0, "Number of level 0 blocks a tenant may add between two maintenance cycles before the ingesters consolidate its writes. 0 disables.")
+	f.IntVar(&cfg.Trace.NegativeCacheSize, util.PrefixConfig(prefix, "trace.negative-cache-size"), 100000, "Number of trace id/block pairs known not to match to cache. 0 disables.")
+	f.BoolVar(&cfg.Trace.StartupProbe, util.PrefixConfig(prefix, "trace.startup-probe"), false, "Write, read and delete a marker object in the backend on startup and fail if any step fails.")
+	f.BoolVar(&cfg.Trace.VerifyFlushedBlocks, util.PrefixConfig(prefix, "trace.verify-flushed-blocks"), false, "Read back every block flushed by the ingesters and compare some of its data with the local copy before the local copy is discarded.")
+	f.IntVar(&cfg.Trace.VerifyFlushedPages, util.PrefixConfig(prefix, "trace.verify-flushed-pages"), 3, "Number of index pages of the data of a flushed block compared with the local copy when flushed blocks are verified.")
 
 	cfg.Trace.WAL = &wal.Config{}
 	f.StringVar(&cfg.Trace.WAL.Filepath, util.PrefixConfig(prefix, "trace.wal.path"), "/var/tempo/wal", "Path at which store WAL blocks.")
 	f.Float64Var(&cfg.Trace.WAL.BloomFP, util.PrefixConfig(prefix, "trace.wal.bloom-filter-false-positive"), .05, "Bloom False Positive.")
+	f.IntVar(&cfg.Trace.WAL.BloomShardSizeBytes, util.PrefixConfig(prefix, "trace.wal.bloom-filter-shard-size-bytes"), 0, "Target size of each bloom filter shard of a block. Lookups only fetch the shard of the trace id. 0 splits every bloom filter into 10 shards.")
 	f.IntVar(&cfg.Trace.WAL.IndexDownsample, util.PrefixConfig(prefix, "trace.wal.index-downsample"), 100, "Number of traces per index record.")
+	f.StringVar(&cfg.Trace.WAL.Version, util.PrefixConfig(prefix, "trace.wal.version"), encoding.CurrentVersion, "Block version to write.")
+	f.StringVar(&cfg.Trace.WAL.Encoding, util.PrefixConfig(prefix, "trace.wal.encoding"), encoding.EncNone, "Compression of the objects in completed and compacted blocks. Must be one of none, gzip, snappy, lz4 or zstd and supported by this build.")
+	f.StringVar(&cfg.Trace.WAL.EncodingFallback, util.PrefixConfig(prefix, "trace.wal.encoding-fallback"), "", "Compression used if this build doesn't support the WAL encoding. Empty to fail instead.")
 
 	cfg.Trace.S3 = &s3.Config{}
 	f.StringVar(&cfg.Trace.S3.Bucket, util.PrefixConfig(prefix, "trace.s3.bucket"), "", "s3 bucket to store blocks in.")
 	f.StringVar(&cfg.Trace.S3.Endpoint, util.PrefixConfig(prefix, "trace.s3.endpoint"), "", "s3 endpoint to push blocks to.")
 	f.StringVar(&cfg.Trace.S3.AccessKey, util.PrefixConfig(prefix, "trace.s3.access_key"), "", "s3 access key.")
 	f.StringVar(&cfg.Trace.S3.SecretKey, util.PrefixConfig(prefix, "trace.s3.secret_key"), "", "s3 secret key.")
+	f.BoolVar(&cfg.Trace.S3.ForcePathStyle, util.PrefixConfig(prefix, "trace.s3.forcepathstyle"), false, "address buckets by path instead of virtual host, as MinIO and Ceph usually require.")
+	f.Uint64Var(&cfg.Trace.S3.PartSize, util.PrefixConfig(prefix, "trace.s3.part-size"), 0, "size of the parts of multipart uploads in bytes, at least 5MiB. 0 lets the client choose.")
+	f.StringVar(&cfg.Trace.S3.SSE.Type, util.PrefixConfig(prefix, "trace.s3.sse.type"), "", "server side encryption of written objects, SSE-S3 or SSE-KMS. none if empty.")
+	f.StringVar(&cfg.Trace.S3.SSE.KMSKeyID, util.PrefixConfig(prefix, "trace.s3.sse.kms-key-id"), "", "kms key to encrypt with for SSE-KMS. the account's default key if empty.")
+	f.DurationVar(&cfg.Trace.S3.HedgeRequestsAt, util.PrefixConfig(prefix, "trace.s3.hedge-requests-at"), 0, "send another GET to s3 if the earlier ones haven't responded in this long. 0 disables hedging.")
+	f.IntVar(&cfg.Trace.S3.HedgeRequestsUpTo, util.PrefixConfig(prefix, "trace.s3.hedge-requests-up-to"), 2, "most GETs sent to s3 for a single read when hedging, including the first.")
 
 	cfg.Trace.GCS = &gcs.Config{}
 	f.StringVar(&cfg.Trace.GCS.BucketName, util.PrefixConfig(prefix, "trace.gcs.bucket"), "", "gcs bucket to store traces in.")
+	f.DurationVar(&cfg.Trace.GCS.HedgeRequestsAt, util.PrefixConfig(prefix, "trace.gcs.hedge-requests-at"), 0, "send another GET to gcs if the earlier ones haven't responded in this long. 0 disables hedging.")
+	f.IntVar(&cfg.Trace.GCS.HedgeRequestsUpTo, util.PrefixConfig(prefix, "trace.gcs.hedge-requests-up-to"), 2, "most GETs sent to gcs for a single read when hedging, including the first.")
 	cfg.Trace.GCS.ChunkBufferSize = 10 * 1024 * 1024
 
+	cfg.Trace.Azure = &azure.Config{}
+	f.StringVar(&cfg.Trace.Azure.ContainerName, util.PrefixConfig(prefix, "trace.azure.container-name"), "", "azure container to store blocks in.")
+	f.StringVar(&cfg.Trace.Azure.StorageAccountName, util.PrefixConfig(prefix, "trace.azure.storage-account-name"), "", "azure storage account name.")
+	f.StringVar(&cfg.Trace.Azure.StorageAccountKey, util.PrefixConfig(prefix, "trace.azure.storage-account-key"), "", "azure storage account key.")
+	f.StringVar(&cfg.Trace.Azure.SASToken, util.PrefixConfig(prefix, "trace.azure.sas-token"), "", "azure shared access signature token for the container.")
+	f.BoolVar(&cfg.Trace.Azure.UseManagedIdentity, util.PrefixConfig(prefix, "trace.azure.use-managed-identity"), false, "authenticate with the managed identity of the host.")
+	f.StringVar(&cfg.Trace.Azure.Endpoint, util.PrefixConfig(prefix, "trace.azure.endpoint"), "", "azure blob service endpoint. defaults to https://<storage-account-name>.blob.core.windows.net")
+	cfg.Trace.Azure.BufferSize = 4 * 1024 * 1024
+	cfg.Trace.Azure.Parallelism = 16
+
 	cfg.Trace.Local = &local.Config{}
-	f.StringVar(&cfg.Trace.Local.Path, util.PrefixConfig(prefix, "trace.local.path"), "", "path to store traces at.")
+	f.StringVar(&cfg.Trace.Local.Path, util.PrefixConfig(prefix, "trace.local.path"), "", "path to store traces at. defaults to the blocks directory of the wal path.")
+	f.BoolVar(&cfg.Trace.Local.Fsync, util.PrefixConfig(prefix, "trace.local.fsync"), false, "sync blocks to disk before they're committed so they survive a crash of the machine.")
+
+	cfg.Trace.Archive = &tempodb.ArchiveConfig{
+		Local: &local.Config{},
+		GCS:   &gcs.Config{ChunkBufferSize: 10 * 1024 * 1024},
+		S3:    &s3.Config{},
+		Azure: &azure.Config{BufferSize: 4 * 1024 * 1024, Parallelism: 16},
+	}
+	f.StringVar(&cfg.Trace.Archive.Backend, util.PrefixConfig(prefix, "trace.archive.backend"), "", "Archive backend flushed blocks are also written to (s3, gcs, azure, local, inmemory). Disabled if empty.")
+	f.DurationVar(&cfg.Trace.Archive.Retention, util.PrefixConfig(prefix, "trace.archive.retention"), 0, "Duration to keep archived blocks. 0 keeps them forever.")
+	f.BoolVar(&cfg.Trace.Archive.QueryFallback, util.PrefixConfig(prefix, "trace.archive.query-fallback"), false, "Search the archive backend for traces not found in the primary backend.")
+	f.StringVar(&cfg.Trace.Archive.S3.Bucket, util.PrefixConfig(prefix, "trace.archive.s3.bucket"), "", "s3 bucket to archive blocks in.")
+	f.StringVar(&cfg.Trace.Archive.S3.Endpoint, util.PrefixConfig(prefix, "trace.archive.s3.endpoint"), "", "s3 endpoint to archive blocks to.")
+	f.StringVar(&cfg.Trace.Archive.GCS.BucketName, util.PrefixConfig(prefix, "trace.archive.gcs.bucket"), "", "gcs bucket to archive blocks in.")
+	f.StringVar(&cfg.Trace.Archive.Azure.ContainerName, util.PrefixConfig(prefix, "trace.archive.azure.container-name"), "", "azure container to archive blocks in.")
+	f.StringVar(&cfg.Trace.Archive.Local.Path, util.PrefixConfig(prefix, "trace.archive.local.path"), "", "path to archive blocks at.")
+
+	f.BoolVar(&cfg.FlushNotify.Enabled, util.PrefixConfig(prefix, "flush-notify.enabled"), false, "Announce flushed blocks so they can be queried before the next blocklist poll.")
+	f.DurationVar(&cfg.FlushNotify.TTL, util.PrefixConfig(prefix, "flush-notify.ttl"), 2*DefaultBlocklistPoll, "Duration a flushed block is announced for. Should be longer than the maintenance cycle.")
+	cfg.FlushNotify.KVStore.Store = "memberlist"
 
 	cfg.Trace.Pool = &pool.Config{}
 	f.IntVar(&cfg.Trace.Pool.MaxWorkers, util.PrefixConfig(prefix, "trace.pool.max-workers"), 50, "Workers in the worker pool.")
 	f.IntVar(&cfg.Trace.Pool.QueueDepth, util.PrefixConfig(prefix, "trace.pool.queue-depth"), 200, "Work item queue depth.")
+	f.BoolVar(&cfg.Trace.Pool.AdaptiveConcurrency, util.PrefixConfig(prefix, "trace.pool.adaptive-concurrency"), true, "Adjust the number of jobs run at once between min and max workers based on their latency and errors.")
+	f.IntVar(&cfg.Trace.Pool.MinWorkers, util.PrefixConfig(prefix, "trace.pool.min-workers"), 5, "Minimum number of jobs run at once with adaptive concurrency.")
+	f.DurationVar(&cfg.Trace.Pool.TargetLatency, util.PrefixConfig(prefix, "trace.pool.target-latency"), 2*time.Second, "Jobs slower than this reduce the number of jobs run at once with adaptive concurrency.")
 }
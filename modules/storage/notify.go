@@ -0,0 +1,218 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/cortexproject/cortex/pkg/ring/kv"
+	"github.com/cortexproject/cortex/pkg/ring/kv/codec"
+	"github.com/cortexproject/cortex/pkg/ring/kv/memberlist"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"github.com/grafana/tempo/tempodb/encoding"
+)
+
+const (
+	flushNotifyKey     = "flushed-blocks"
+	flushNotifyCodecID = "flushedBlocks"
+)
+
+var (
+	metricFlushNotifyFailures = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: "tempo",
+		Name:      "storage_flush_notify_failures_total",
+		Help:      "Total number of flushed blocks that failed to be announced.",
+	})
+)
+
+// FlushNotifyConfig configures announcing flushed blocks through a kv store.  Every store watching the key adds the
+// announced blocks to its blocklist right away instead of waiting for the next poll.
+type FlushNotifyConfig struct {
+	Enabled bool          `yaml:"enabled"`
+	KVStore kv.Config     `yaml:"kvstore"`
+	TTL     time.Duration `yaml:"ttl"` // how long a block is announced for.  should be longer than the blocklist poll
+}
+
+// GetFlushNotifyCodec returns the codec of the flushed blocks value.  It must be registered with memberlist.
+func GetFlushNotifyCodec() codec.Codec {
+	return flushedBlocksCodec{}
+}
+
+// flushedBlocks is the value stored under flushNotifyKey.  Entries are only ever added, and are dropped once they
+// expire, so it merges without tombstones.
+type flushedBlocks struct {
+	Blocks map[string]*flushedBlock `json:"blocks"` // keyed by tenant/block id
+}
+
+type flushedBlock struct {
+	Meta    *encoding.BlockMeta `json:"meta"`
+	Expires int64               `json:"expires"` // unix seconds
+}
+
+func newFlushedBlocks() *flushedBlocks {
+	return &flushedBlocks{
+		Blocks: map[string]*flushedBlock{},
+	}
+}
+
+func flushedBlockKey(meta *encoding.BlockMeta) string {
+	return meta.TenantID + "/" + meta.BlockID.String()
+}
+
+// Merge implements memberlist.Mergeable
+func (b *flushedBlocks) Merge(other memberlist.Mergeable, _ bool) (memberlist.Mergeable, error) {
+	if other == nil {
+		return nil, nil
+	}
+
+	o, ok := other.(*flushedBlocks)
+	if !ok {
+		return nil, fmt.Errorf("expected *flushedBlocks, got %T", other)
+	}
+	if o == nil {
+		return nil, nil
+	}
+
+	now := time.Now().Unix()
+	b.removeExpired(now)
+	if b.Blocks == nil {
+		b.Blocks = map[string]*flushedBlock{}
+	}
+
+	change := newFlushedBlocks()
+	for k, v := range o.Blocks {
+		if v.Expires <= now {
+			continue
+		}
+		if cur, ok := b.Blocks[k]; ok && cur.Expires >= v.Expires {
+			continue
+		}
+
+		b.Blocks[k] = v
+		change.Blocks[k] = v
+	}
+
+	if len(change.Blocks) == 0 {
+		return nil, nil
+	}
+	return change, nil
+}
+
+// MergeContent implements memberlist.Mergeable
+func (b *flushedBlocks) MergeContent() []string {
+	keys := make([]string, 0, len(b.Blocks))
+	for k := range b.Blocks {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+// RemoveTombstones implements memberlist.Mergeable.  Expired entries are dropped when merging instead.
+func (b *flushedBlocks) RemoveTombstones(time.Time) {}
+
+func (b *flushedBlocks) removeExpired(now int64) {
+	for k, v := range b.Blocks {
+		if v.Expires <= now {
+			delete(b.Blocks, k)
+		}
+	}
+}
+
+type flushedBlocksCodec struct{}
+
+func (flushedBlocksCodec) CodecID() string {
+	return flushNotifyCodecID
+}
+
+// Decode implements codec.Codec
+func (flushedBlocksCodec) Decode(b []byte) (interface{}, error) {
+	out := newFlushedBlocks()
+	err := json.Unmarshal(b, out)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// Encode implements codec.Codec
+func (flushedBlocksCodec) Encode(v interface{}) ([]byte, error) {
+	return json.Marshal(v.(*flushedBlocks))
+}
+
+type flushNotifier struct {
+	client kv.Client
+	ttl    time.Duration
+}
+
+func newFlushNotifier(cfg FlushNotifyConfig) (*flushNotifier, error) {
+	client, err := kv.NewClient(cfg.KVStore, GetFlushNotifyCodec(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create flush notify kv client %w", err)
+	}
+
+	return &flushNotifier{
+		client: client,
+		ttl:    cfg.TTL,
+	}, nil
+}
+
+// notify announces a flushed block.
+func (n *flushNotifier) notify(ctx context.Context, meta *encoding.BlockMeta) error {
+	entry := &flushedBlock{
+		Meta:    meta,
+		Expires: time.Now().Add(n.ttl).Unix(),
+	}
+
+	err := n.client.CAS(ctx, flushNotifyKey, func(in interface{}) (interface{}, bool, error) {
+		out := newFlushedBlocks()
+		if cur, ok := in.(*flushedBlocks); ok && cur != nil {
+			for k, v := range cur.Blocks {
+				out.Blocks[k] = v
+			}
+		}
+		out.removeExpired(time.Now().Unix())
+		out.Blocks[flushedBlockKey(meta)] = entry
+
+		return out, true, nil
+	})
+	if err != nil {
+		metricFlushNotifyFailures.Inc()
+	}
+	return err
+}
+
+// watch calls f with the blocks announced since the last call until ctx is done.
+func (n *flushNotifier) watch(ctx context.Context, f func([]*encoding.BlockMeta)) {
+	seen := map[string]int64{}
+
+	n.client.WatchKey(ctx, flushNotifyKey, func(in interface{}) bool {
+		blocks, ok := in.(*flushedBlocks)
+		if !ok || blocks == nil {
+			return true
+		}
+
+		now := time.Now().Unix()
+		for k, expires := range seen {
+			if expires <= now {
+				delete(seen, k)
+			}
+		}
+
+		var metas []*encoding.BlockMeta
+		for k, v := range blocks.Blocks {
+			if _, ok := seen[k]; ok || v.Expires <= now || v.Meta == nil {
+				continue
+			}
+			seen[k] = v.Expires
+			metas = append(metas, v.Meta)
+		}
+
+		if len(metas) > 0 {
+			f(metas)
+		}
+		return true
+	})
+}
@@ -0,0 +1,75 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/cortexproject/cortex/pkg/ring/kv"
+	"github.com/cortexproject/cortex/pkg/ring/kv/consul"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/tempo/tempodb/encoding"
+)
+
+func TestFlushedBlocksMerge(t *testing.T) {
+	now := time.Now().Unix()
+	a := &flushedBlocks{Blocks: map[string]*flushedBlock{
+		"1/a": {Meta: &encoding.BlockMeta{}, Expires: now + 60},
+		"1/b": {Meta: &encoding.BlockMeta{}, Expires: now - 1},
+	}}
+	b := &flushedBlocks{Blocks: map[string]*flushedBlock{
+		"1/a": {Meta: &encoding.BlockMeta{}, Expires: now + 60},
+		"1/c": {Meta: &encoding.BlockMeta{}, Expires: now + 60},
+		"1/d": {Meta: &encoding.BlockMeta{}, Expires: now - 1},
+	}}
+
+	// only the new unexpired entry is a change and expired entries are dropped
+	change, err := a.Merge(b, false)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"1/c"}, change.MergeContent())
+	assert.ElementsMatch(t, []string{"1/a", "1/c"}, a.MergeContent())
+
+	// merging again is a no-op
+	change, err = a.Merge(b, false)
+	require.NoError(t, err)
+	assert.Nil(t, change)
+}
+
+func TestFlushNotifierWatch(t *testing.T) {
+	n, err := newFlushNotifier(FlushNotifyConfig{
+		KVStore: kv.Config{Mock: consul.NewInMemoryClient(GetFlushNotifyCodec())},
+		TTL:     time.Minute,
+	})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	announced := make(chan []*encoding.BlockMeta, 10)
+	go n.watch(ctx, func(metas []*encoding.BlockMeta) {
+		announced <- metas
+	})
+
+	first := &encoding.BlockMeta{BlockID: uuid.New(), TenantID: "1"}
+	second := &encoding.BlockMeta{BlockID: uuid.New(), TenantID: "2"}
+	require.NoError(t, n.notify(ctx, first))
+	require.NoError(t, n.notify(ctx, second))
+
+	// every block is delivered once no matter how many times the value changed
+	var received []uuid.UUID
+	timeout := time.After(5 * time.Second)
+	for len(received) < 2 {
+		select {
+		case metas := <-announced:
+			for _, m := range metas {
+				received = append(received, m.BlockID)
+			}
+		case <-timeout:
+			t.Fatal("blocks not announced")
+		}
+	}
+	assert.ElementsMatch(t, []uuid.UUID{first.BlockID, second.BlockID}, received)
+}
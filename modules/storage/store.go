@@ -5,7 +5,9 @@ import (
 
 	"github.com/cortexproject/cortex/pkg/util/services"
 	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
 	"github.com/grafana/tempo/tempodb"
+	"github.com/grafana/tempo/tempodb/wal"
 )
 
 // Store wraps the tempodb storage layer
@@ -20,7 +22,9 @@ type Store interface {
 type store struct {
 	services.Service
 
-	cfg Config
+	cfg      Config
+	logger   log.Logger
+	notifier *flushNotifier
 
 	tempodb.Reader
 	tempodb.Writer
@@ -36,12 +40,20 @@ func NewStore(cfg Config, logger log.Logger) (Store, error) {
 
 	s := &store{
 		cfg:       cfg,
+		logger:    logger,
 		Reader:    r,
 		Writer:    w,
 		Compactor: c,
 	}
 
-	s.Service = services.NewIdleService(s.starting, s.stopping)
+	if cfg.FlushNotify.Enabled {
+		s.notifier, err = newFlushNotifier(cfg.FlushNotify)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	s.Service = services.NewBasicService(s.starting, s.running, s.stopping)
 	return s, nil
 }
 
@@ -49,6 +61,32 @@ func (s *store) starting(_ context.Context) error {
 	return nil
 }
 
+// running adds blocks announced by the ingesters to the blocklist until the store is stopped.
+func (s *store) running(ctx context.Context) error {
+	if s.notifier != nil {
+		s.notifier.watch(ctx, s.Reader.AddBlocks)
+	}
+
+	<-ctx.Done()
+	return nil
+}
+
+// WriteBlock writes the block and, if enabled, announces it.  A failed announcement does not fail the flush.  The
+// block is still found after the next blocklist poll.
+func (s *store) WriteBlock(ctx context.Context, block wal.WriteableBlock) error {
+	err := s.Writer.WriteBlock(ctx, block)
+	if err != nil || s.notifier == nil {
+		return err
+	}
+
+	err = s.notifier.notify(ctx, block.BlockMeta())
+	if err != nil {
+		level.Warn(s.logger).Log("msg", "failed to announce flushed block", "blockID", block.BlockMeta().BlockID, "tenantID", block.BlockMeta().TenantID, "err", err)
+	}
+
+	return nil
+}
+
 func (s *store) stopping(_ error) error {
 	s.Reader.Shutdown()
 
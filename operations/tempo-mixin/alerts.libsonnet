@@ -67,6 +67,34 @@
               runbook_url: 'https://github.com/grafana/tempo/tree/master/operations/tempo-mixin/runbook.md#TempoDistributorUnhealthy'
             },
           },
+          {
+            alert: 'TempoBlocklistStale',
+            'for': '15m',
+            expr: |||
+              max by (cluster, namespace, job) (time() - tempodb_blocklist_last_poll_timestamp_seconds) > 1800
+            |||,
+            labels: {
+              severity: 'warning',
+            },
+            annotations: {
+              message: '{{ $labels.job }} has not polled a blocklist for {{ printf "%.0f" $value }}s.',
+              runbook_url: 'https://github.com/grafana/tempo/tree/master/operations/tempo-mixin/runbook.md#TempoBlocklistStale'
+            },
+          },
+          {
+            alert: 'TempoBlockCreationRateHigh',
+            'for': '30m',
+            expr: |||
+              max by (cluster, namespace, tenant) (increase(tempodb_blocklist_new_blocks_exceeded_total[15m])) > 0
+            |||,
+            labels: {
+              severity: 'warning',
+            },
+            annotations: {
+              message: 'Tenant {{ $labels.tenant }} is creating more blocks per maintenance cycle than blocklist_max_new_blocks.',
+              runbook_url: 'https://github.com/grafana/tempo/tree/master/operations/tempo-mixin/runbook.md#TempoBlockCreationRateHigh'
+            },
+          },
         ],
       },
     ],
@@ -0,0 +1,46 @@
+package v1
+
+import (
+	"fmt"
+
+	"github.com/grafana/tempo/pkg/tempopb"
+)
+
+// traceIDSize is the size of the trace ids of the internal API.
+const traceIDSize = 16
+
+// PushRequestsToInternal returns an internal push request for each batch of req, since the distributor pushes a
+// single batch at a time.
+func PushRequestsToInternal(req *PushRequest) []*tempopb.PushRequest {
+	reqs := make([]*tempopb.PushRequest, 0, len(req.Batches))
+	for _, batch := range req.Batches {
+		reqs = append(reqs, &tempopb.PushRequest{Batch: batch})
+	}
+	return reqs
+}
+
+// TraceByIDRequestToInternal pads the trace id of req to 16 bytes.  It returns an error if the id is empty or longer.
+func TraceByIDRequestToInternal(req *TraceByIDRequest) (*tempopb.TraceByIDRequest, error) {
+	size := len(req.TraceId)
+	if size == 0 || size > traceIDSize {
+		return nil, fmt.Errorf("trace id must be 1 to %d bytes, got %d", traceIDSize, size)
+	}
+
+	traceID := req.TraceId
+	if size < traceIDSize {
+		traceID = append(make([]byte, traceIDSize-size), traceID...)
+	}
+	return &tempopb.TraceByIDRequest{TraceID: traceID}, nil
+}
+
+// TraceByIDResponseFromInternal returns the batches of resp's trace.  Fields of the internal response that aren't
+// part of this API, such as the number of partial traces combined, are dropped.
+func TraceByIDResponseFromInternal(resp *tempopb.TraceByIDResponse) *TraceByIDResponse {
+	out := &TraceByIDResponse{
+		Incomplete: resp.Incomplete,
+	}
+	if resp.Trace != nil {
+		out.Batches = resp.Trace.Batches
+	}
+	return out
+}
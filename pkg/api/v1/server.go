@@ -0,0 +1,65 @@
+package v1
+
+import (
+	"context"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/grafana/tempo/pkg/tempopb"
+)
+
+type pusherServer struct {
+	pusher tempopb.PusherServer
+}
+
+// NewPusherServer serves Pusher with an internal pusher, i.e. the distributor.
+func NewPusherServer(pusher tempopb.PusherServer) PusherServer {
+	return &pusherServer{
+		pusher: pusher,
+	}
+}
+
+// Push pushes the batches one at a time and stops at the first that fails.  Batches pushed before it aren't rolled
+// back, so a client retrying the request may push them twice.
+func (s *pusherServer) Push(ctx context.Context, req *PushRequest) (*PushResponse, error) {
+	for _, r := range PushRequestsToInternal(req) {
+		if _, err := s.pusher.Push(ctx, r); err != nil {
+			return nil, err
+		}
+	}
+	return &PushResponse{}, nil
+}
+
+// TraceFinder finds traces through the internal API, e.g. the querier.
+type TraceFinder interface {
+	FindTraceByID(ctx context.Context, req *tempopb.TraceByIDRequest) (*tempopb.TraceByIDResponse, error)
+}
+
+type querierServer struct {
+	finder TraceFinder
+}
+
+// NewQuerierServer serves Querier with finder.
+func NewQuerierServer(finder TraceFinder) QuerierServer {
+	return &querierServer{
+		finder: finder,
+	}
+}
+
+func (s *querierServer) FindTraceByID(ctx context.Context, req *TraceByIDRequest) (*TraceByIDResponse, error) {
+	internalReq, err := TraceByIDRequestToInternal(req)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	resp, err := s.finder.FindTraceByID(ctx, internalReq)
+	if err != nil {
+		return nil, err
+	}
+	if resp.Trace == nil || len(resp.Trace.Batches) == 0 {
+		return nil, status.Errorf(codes.NotFound, "trace %x not found", internalReq.TraceID)
+	}
+
+	return TraceByIDResponseFromInternal(resp), nil
+}
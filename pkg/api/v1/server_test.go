@@ -0,0 +1,82 @@
+package v1
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	opentelemetry_proto_trace_v1 "github.com/open-telemetry/opentelemetry-proto/gen/go/trace/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/grafana/tempo/pkg/tempopb"
+	"github.com/grafana/tempo/pkg/util/test"
+)
+
+type fakePusher struct {
+	reqs []*tempopb.PushRequest
+	err  error
+}
+
+func (p *fakePusher) Push(_ context.Context, req *tempopb.PushRequest) (*tempopb.PushResponse, error) {
+	if p.err != nil {
+		return nil, p.err
+	}
+	p.reqs = append(p.reqs, req)
+	return &tempopb.PushResponse{}, nil
+}
+
+type fakeFinder struct {
+	req  *tempopb.TraceByIDRequest
+	resp *tempopb.TraceByIDResponse
+}
+
+func (f *fakeFinder) FindTraceByID(_ context.Context, req *tempopb.TraceByIDRequest) (*tempopb.TraceByIDResponse, error) {
+	f.req = req
+	return f.resp, nil
+}
+
+func TestPusherServer(t *testing.T) {
+	trace := test.MakeTrace(3, []byte{0x01})
+	pusher := &fakePusher{}
+
+	_, err := NewPusherServer(pusher).Push(context.Background(), &PushRequest{Batches: trace.Batches})
+	require.NoError(t, err)
+	require.Len(t, pusher.reqs, len(trace.Batches))
+	for i, req := range pusher.reqs {
+		assert.Equal(t, trace.Batches[i], req.Batch)
+	}
+
+	pusher.err = errors.New("push failed")
+	_, err = NewPusherServer(pusher).Push(context.Background(), &PushRequest{Batches: trace.Batches})
+	assert.Equal(t, pusher.err, err)
+}
+
+func TestQuerierServer(t *testing.T) {
+	trace := test.MakeTrace(3, []byte{0x01})
+	finder := &fakeFinder{resp: &tempopb.TraceByIDResponse{
+		Trace:        trace,
+		CombinedFrom: 2,
+		Incomplete:   true,
+	}}
+	s := NewQuerierServer(finder)
+
+	// short ids are padded
+	resp, err := s.FindTraceByID(context.Background(), &TraceByIDRequest{TraceId: []byte{0x01}})
+	require.NoError(t, err)
+	assert.Equal(t, append(make([]byte, 15), 0x01), finder.req.TraceID)
+	assert.Equal(t, &TraceByIDResponse{Batches: trace.Batches, Incomplete: true}, resp)
+
+	for _, traceID := range [][]byte{nil, make([]byte, 17)} {
+		_, err = s.FindTraceByID(context.Background(), &TraceByIDRequest{TraceId: traceID})
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	}
+
+	for _, notFound := range []*tempopb.TraceByIDResponse{{}, {Trace: &tempopb.Trace{Batches: []*opentelemetry_proto_trace_v1.ResourceSpans{}}}} {
+		finder.resp = notFound
+		_, err = s.FindTraceByID(context.Background(), &TraceByIDRequest{TraceId: []byte{0x01}})
+		assert.Equal(t, codes.NotFound, status.Code(err))
+	}
+}
@@ -0,0 +1,182 @@
+// Package blockwriter writes traces directly into Tempo blocks in the backend, bypassing the distributors and
+// ingesters.  It is meant for backfilling historical traces from batch jobs: traces are appended to a block in a local
+// working directory and the block is completed and uploaded when it is flushed.  The blocks written are the same as
+// the ones flushed by the ingesters, including bloom filters, index, summary and search data, and are picked up by
+// queriers and compactors once they poll the blocklist.  The time range of a block is the time range of the spans
+// written to it, so retention and compaction windows apply to historical traces by their timestamps.
+//
+// A Writer is safe for concurrent use, a Block is not.  Each Block should receive whole traces: the same trace
+// written twice to a block is combined, but written to two blocks it is only combined once the blocks are compacted.
+package blockwriter
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/gogo/protobuf/proto"
+	"github.com/google/uuid"
+
+	"github.com/grafana/tempo/pkg/tempopb"
+	"github.com/grafana/tempo/pkg/util"
+	"github.com/grafana/tempo/pkg/validation"
+	"github.com/grafana/tempo/tempodb"
+	"github.com/grafana/tempo/tempodb/encoding"
+	"github.com/grafana/tempo/tempodb/wal"
+)
+
+// Writer creates blocks and writes them to the backend configured in a tempodb.Config.
+type Writer struct {
+	w tempodb.Writer
+}
+
+// New returns a Writer for the backend of cfg.  cfg.WAL.Filepath is the working directory of blocks being written and
+// needs room for the largest block.  Blocklist polling is disabled since the writer never reads the blocklist.
+func New(cfg *tempodb.Config, logger log.Logger) (*Writer, error) {
+	if cfg.WAL == nil || cfg.WAL.Filepath == "" {
+		return nil, fmt.Errorf("a wal filepath is required as the working directory of blocks")
+	}
+
+	writerCfg := *cfg
+	writerCfg.BlocklistPoll = 0
+
+	_, w, _, err := tempodb.New(&writerCfg, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Writer{w: w}, nil
+}
+
+// FromWriter returns a Writer writing blocks with w, such as the store of a running component.
+func FromWriter(w tempodb.Writer) *Writer {
+	return &Writer{w: w}
+}
+
+// NewBlock starts a block for the tenant.  The block must be flushed or cleared to remove it from the working
+// directory.
+func (w *Writer) NewBlock(tenantID string) (*Block, error) {
+	if !validation.ValidTenantID(tenantID) {
+		return nil, fmt.Errorf("invalid tenant id %q", tenantID)
+	}
+
+	b, err := w.w.WAL().NewBlock(uuid.New(), tenantID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Block{w: w.w, appendBlock: b}, nil
+}
+
+// Block is a block being written.  Traces can be written in any order.
+type Block struct {
+	w           tempodb.Writer
+	appendBlock *wal.AppendBlock
+
+	// time range of the spans written, in unix nanoseconds
+	start uint64
+	end   uint64
+}
+
+// Write appends a trace to the block.  The trace id must be 16 bytes.
+func (b *Block) Write(traceID []byte, trace *tempopb.Trace) error {
+	if b.appendBlock == nil {
+		return fmt.Errorf("block was already flushed or cleared")
+	}
+	if !validation.ValidTraceID(traceID) {
+		return fmt.Errorf("trace ids must be 128 bit, got %d bytes", len(traceID))
+	}
+
+	buff, err := proto.Marshal(trace)
+	if err != nil {
+		return err
+	}
+
+	err = b.appendBlock.Write(traceID, buff)
+	if err != nil {
+		return err
+	}
+	entry := util.SearchEntryForTrace(trace)
+	b.appendBlock.Summarize(util.SummarizeTrace(trace))
+	b.appendBlock.AddSearch(traceID, entry)
+
+	if entry.StartTimeUnixNano != 0 && (b.start == 0 || entry.StartTimeUnixNano < b.start) {
+		b.start = entry.StartTimeUnixNano
+	}
+	if entry.EndTimeUnixNano > b.end {
+		b.end = entry.EndTimeUnixNano
+	}
+
+	return nil
+}
+
+// Length returns the number of traces written to the block.
+func (b *Block) Length() int {
+	if b.appendBlock == nil {
+		return 0
+	}
+	return b.appendBlock.Length()
+}
+
+// Flush completes the block, combining traces written more than once, and writes it to the backend.  The block is
+// removed from the working directory whether or not it was written and can't be written to afterwards.  The meta of
+// the block in the backend is returned.
+func (b *Block) Flush(ctx context.Context) (*encoding.BlockMeta, error) {
+	if b.appendBlock == nil {
+		return nil, fmt.Errorf("block was already flushed or cleared")
+	}
+	if b.appendBlock.Length() == 0 {
+		return nil, fmt.Errorf("block is empty")
+	}
+
+	appendBlock := b.appendBlock
+	b.appendBlock = nil
+	defer func() {
+		_ = appendBlock.Clear()
+	}()
+
+	// blocks of spans without timestamps keep the time they were written
+	if b.end != 0 {
+		appendBlock.SetTimeRange(time.Unix(0, int64(b.start)), time.Unix(0, int64(b.end)))
+	}
+
+	completeBlock, err := appendBlock.Complete(b.w.WAL(), combiner{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to complete block: %w", err)
+	}
+	defer func() {
+		_ = completeBlock.Clear()
+	}()
+
+	err = b.w.WriteBlock(ctx, completeBlock)
+	if err != nil {
+		return nil, fmt.Errorf("failed to write block: %w", err)
+	}
+
+	return completeBlock.BlockMeta(), nil
+}
+
+// Clear abandons the block and removes it from the working directory.
+func (b *Block) Clear() error {
+	if b.appendBlock == nil {
+		return nil
+	}
+
+	err := b.appendBlock.Clear()
+	b.appendBlock = nil
+	return err
+}
+
+// combiner combines the objects of a block as traces and builds its search data.
+type combiner struct{}
+
+// Combine implements encoding.ObjectCombiner
+func (combiner) Combine(objA []byte, objB []byte) []byte {
+	return util.CombineTraces(objA, objB)
+}
+
+// SearchEntry implements encoding.ObjectSearcher
+func (combiner) SearchEntry(obj []byte) (*encoding.SearchEntry, error) {
+	return util.SearchEntry(obj)
+}
@@ -0,0 +1,140 @@
+package blockwriter
+
+import (
+	"context"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/gogo/protobuf/proto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/tempo/pkg/tempopb"
+	"github.com/grafana/tempo/pkg/util/test"
+	"github.com/grafana/tempo/tempodb"
+	"github.com/grafana/tempo/tempodb/backend/local"
+	"github.com/grafana/tempo/tempodb/encoding"
+	"github.com/grafana/tempo/tempodb/wal"
+)
+
+const testTenantID = "fake"
+
+func TestWriteBlock(t *testing.T) {
+	tempDir, err := ioutil.TempDir("/tmp", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(tempDir)
+
+	cfg := &tempodb.Config{
+		Backend: "local",
+		Local: &local.Config{
+			Path: path.Join(tempDir, "traces"),
+		},
+		WAL: &wal.Config{
+			Filepath:        path.Join(tempDir, "wal"),
+			IndexDownsample: 3,
+			BloomFP:         .01,
+		},
+	}
+	w, err := New(cfg, log.NewNopLogger())
+	require.NoError(t, err)
+
+	_, err = w.NewBlock("../fake")
+	assert.Error(t, err)
+
+	block, err := w.NewBlock(testTenantID)
+	require.NoError(t, err)
+	assert.Error(t, block.Write([]byte{0x01}, &tempopb.Trace{}))
+
+	// historical traces, a second apart
+	base := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	ids := make([][]byte, 0, 10)
+	traces := make([]*tempopb.Trace, 0, 10)
+	for i := 0; i < 10; i++ {
+		id := make([]byte, 16)
+		rand.Read(id)
+		ids = append(ids, id)
+		traces = append(traces, test.MakeTrace(5, id))
+		span := traces[i].Batches[0].InstrumentationLibrarySpans[0].Spans[0]
+		span.StartTimeUnixNano = uint64(base.Add(time.Duration(i) * time.Second).UnixNano())
+		span.EndTimeUnixNano = uint64(base.Add(time.Duration(i+1) * time.Second).UnixNano())
+		require.NoError(t, block.Write(id, traces[i]))
+	}
+	// the second half of a trace written later is combined with the first
+	split := proto.Clone(traces[0]).(*tempopb.Trace)
+	require.NoError(t, block.Write(ids[0], &tempopb.Trace{Batches: split.Batches[1:]}))
+	assert.Equal(t, 11, block.Length())
+
+	meta, err := block.Flush(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 10, meta.TotalObjects)
+	assert.True(t, meta.Searchable)
+	assert.True(t, base.Equal(meta.StartTime), meta.StartTime)
+	assert.True(t, base.Add(10*time.Second).Equal(meta.EndTime), meta.EndTime)
+	assert.Equal(t, 0, block.Length())
+	_, err = block.Flush(context.Background())
+	assert.Error(t, err)
+
+	// only the block in the backend is left
+	files, err := ioutil.ReadDir(cfg.WAL.Filepath)
+	require.NoError(t, err)
+	for _, f := range files {
+		assert.True(t, f.IsDir(), f.Name())
+	}
+
+	r, _, c, err := tempodb.NewBackend(cfg)
+	require.NoError(t, err)
+	v := tempodb.VerifyBlock(context.Background(), r, c, testTenantID, meta.BlockID, true)
+	assert.Equal(t, tempodb.BlockStateOK, v.State, v.Problems)
+
+	reader, _, _, err := tempodb.New(cfg, log.NewNopLogger())
+	require.NoError(t, err)
+	reader.AddBlocks([]*encoding.BlockMeta{meta})
+	for i, id := range ids {
+		obj, _, err := reader.Find(context.Background(), testTenantID, id)
+		require.NoError(t, err)
+
+		actual := &tempopb.Trace{}
+		require.NoError(t, proto.Unmarshal(obj, actual))
+		assert.True(t, proto.Equal(traces[i], actual))
+	}
+}
+
+func TestClearBlock(t *testing.T) {
+	tempDir, err := ioutil.TempDir("/tmp", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(tempDir)
+
+	w, err := New(&tempodb.Config{
+		Backend: "local",
+		Local: &local.Config{
+			Path: path.Join(tempDir, "traces"),
+		},
+		WAL: &wal.Config{
+			Filepath:        path.Join(tempDir, "wal"),
+			IndexDownsample: 3,
+			BloomFP:         .01,
+		},
+	}, log.NewNopLogger())
+	require.NoError(t, err)
+
+	block, err := w.NewBlock(testTenantID)
+	require.NoError(t, err)
+	_, err = block.Flush(context.Background())
+	assert.Error(t, err)
+
+	id := make([]byte, 16)
+	require.NoError(t, block.Write(id, test.MakeTrace(1, id)))
+	require.NoError(t, block.Clear())
+	assert.Error(t, block.Write(id, test.MakeTrace(1, id)))
+
+	blocks, err := ioutil.ReadDir(path.Join(tempDir, "traces"))
+	if !os.IsNotExist(err) {
+		require.NoError(t, err)
+	}
+	assert.Empty(t, blocks)
+}
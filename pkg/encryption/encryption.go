@@ -0,0 +1,255 @@
+package encryption
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"sync"
+
+	v1_common "github.com/open-telemetry/opentelemetry-proto/gen/go/common/v1"
+	v1 "github.com/open-telemetry/opentelemetry-proto/gen/go/trace/v1"
+
+	"github.com/grafana/tempo/pkg/tempopb"
+)
+
+const (
+	// valuePrefix marks an encrypted attribute value.  The version allows the scheme to change.
+	valuePrefix = "tempo:enc:v1:"
+
+	minMasterKeyBytes = 32
+)
+
+// Config configures encryption of sensitive attribute values.  The distributors encrypt and the queriers decrypt, so
+// both must use the same master key.
+type Config struct {
+	// MasterKeyFile holds the secret every tenant key is derived from.  Encryption is disabled if empty.
+	MasterKeyFile string `yaml:"master_key_file"`
+	// RoleHeader is the request header the queriers read the caller's role from.  It must be set by a trusted proxy.
+	RoleHeader string `yaml:"role_header"`
+	// AuthorizedRoles may read decrypted values.  Everyone else receives the encrypted values.
+	AuthorizedRoles []string `yaml:"authorized_roles"`
+}
+
+// RegisterFlags registers the flags for the master key and role header.  Authorized roles are yaml only.
+func (cfg *Config) RegisterFlags(prefix string, f *flag.FlagSet) {
+	f.StringVar(&cfg.MasterKeyFile, prefix+".master-key-file", "", "File containing the master key tenant encryption keys are derived from.  At least 32 bytes.  Attribute encryption is disabled if empty.")
+	f.StringVar(&cfg.RoleHeader, prefix+".role-header", "X-Tempo-Role", "Request header holding the role of the caller.  Must be set by a trusted proxy.")
+}
+
+// Encrypter encrypts and decrypts attribute values with AES-GCM.  Every tenant has its own key derived from the master
+// key, and the tenant and attribute key are bound to the ciphertext so values can't be moved between them.
+type Encrypter struct {
+	cfg    Config
+	master []byte
+
+	mtx   sync.Mutex
+	aeads map[string]cipher.AEAD
+}
+
+// New returns nil if no master key is configured.
+func New(cfg Config) (*Encrypter, error) {
+	if cfg.MasterKeyFile == "" {
+		return nil, nil
+	}
+
+	master, err := ioutil.ReadFile(cfg.MasterKeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read master key: %w", err)
+	}
+	master = bytes.TrimSpace(master)
+	if len(master) < minMasterKeyBytes {
+		return nil, fmt.Errorf("master key must be at least %d bytes", minMasterKeyBytes)
+	}
+
+	return &Encrypter{
+		cfg:    cfg,
+		master: master,
+		aeads:  map[string]cipher.AEAD{},
+	}, nil
+}
+
+// Authorized returns true if the role may read decrypted values.
+func (e *Encrypter) Authorized(role string) bool {
+	if role == "" {
+		return false
+	}
+
+	for _, r := range e.cfg.AuthorizedRoles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// RoleHeader is the request header the caller's role is read from.
+func (e *Encrypter) RoleHeader() string {
+	return e.cfg.RoleHeader
+}
+
+// EncryptBatch encrypts the string values of the resource and span attributes with the passed keys and returns the
+// number of values encrypted.  Values that are already encrypted are left alone.
+func (e *Encrypter) EncryptBatch(tenantID string, batch *v1.ResourceSpans, keys []string) (int, error) {
+	if len(keys) == 0 {
+		return 0, nil
+	}
+
+	aead, err := e.aead(tenantID)
+	if err != nil {
+		return 0, err
+	}
+
+	encrypt := func(attrs []*v1_common.KeyValue) (int, error) {
+		count := 0
+		for _, attr := range attrs {
+			if !contains(keys, attr.Key) {
+				continue
+			}
+
+			sv, ok := attr.GetValue().GetValue().(*v1_common.AnyValue_StringValue)
+			if !ok || strings.HasPrefix(sv.StringValue, valuePrefix) {
+				continue
+			}
+
+			encrypted, err := seal(aead, tenantID, attr.Key, sv.StringValue)
+			if err != nil {
+				return count, err
+			}
+			sv.StringValue = encrypted
+			count++
+		}
+		return count, nil
+	}
+
+	total := 0
+	if batch.Resource != nil {
+		n, err := encrypt(batch.Resource.Attributes)
+		total += n
+		if err != nil {
+			return total, err
+		}
+	}
+	for _, ils := range batch.InstrumentationLibrarySpans {
+		for _, span := range ils.Spans {
+			n, err := encrypt(span.Attributes)
+			total += n
+			if err != nil {
+				return total, err
+			}
+		}
+	}
+
+	return total, nil
+}
+
+// DecryptTrace decrypts every encrypted attribute value in the trace.  Values that fail to decrypt are left encrypted
+// and the last error is returned.
+func (e *Encrypter) DecryptTrace(tenantID string, trace *tempopb.Trace) error {
+	aead, err := e.aead(tenantID)
+	if err != nil {
+		return err
+	}
+
+	var lastErr error
+	decrypt := func(attrs []*v1_common.KeyValue) {
+		for _, attr := range attrs {
+			sv, ok := attr.GetValue().GetValue().(*v1_common.AnyValue_StringValue)
+			if !ok || !strings.HasPrefix(sv.StringValue, valuePrefix) {
+				continue
+			}
+
+			decrypted, err := open(aead, tenantID, attr.Key, sv.StringValue)
+			if err != nil {
+				lastErr = err
+				continue
+			}
+			sv.StringValue = decrypted
+		}
+	}
+
+	for _, batch := range trace.Batches {
+		if batch.Resource != nil {
+			decrypt(batch.Resource.Attributes)
+		}
+		for _, ils := range batch.InstrumentationLibrarySpans {
+			for _, span := range ils.Spans {
+				decrypt(span.Attributes)
+			}
+		}
+	}
+
+	return lastErr
+}
+
+func (e *Encrypter) aead(tenantID string) (cipher.AEAD, error) {
+	e.mtx.Lock()
+	defer e.mtx.Unlock()
+
+	if aead, ok := e.aeads[tenantID]; ok {
+		return aead, nil
+	}
+
+	mac := hmac.New(sha256.New, e.master)
+	_, _ = mac.Write([]byte(tenantID))
+	block, err := aes.NewCipher(mac.Sum(nil))
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	e.aeads[tenantID] = aead
+	return aead, nil
+}
+
+func seal(aead cipher.AEAD, tenantID string, key string, value string) (string, error) {
+	nonce := make([]byte, aead.NonceSize())
+	_, err := rand.Read(nonce)
+	if err != nil {
+		return "", err
+	}
+
+	sealed := aead.Seal(nonce, nonce, []byte(value), additionalData(tenantID, key))
+	return valuePrefix + base64.RawStdEncoding.EncodeToString(sealed), nil
+}
+
+func open(aead cipher.AEAD, tenantID string, key string, value string) (string, error) {
+	sealed, err := base64.RawStdEncoding.DecodeString(strings.TrimPrefix(value, valuePrefix))
+	if err != nil {
+		return "", err
+	}
+	if len(sealed) < aead.NonceSize() {
+		return "", errors.New("encrypted value too short")
+	}
+
+	nonce := sealed[:aead.NonceSize()]
+	plain, err := aead.Open(nil, nonce, sealed[aead.NonceSize():], additionalData(tenantID, key))
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt %s: %w", key, err)
+	}
+	return string(plain), nil
+}
+
+func additionalData(tenantID string, key string) []byte {
+	return []byte(tenantID + "\x00" + key)
+}
+
+func contains(keys []string, key string) bool {
+	for _, k := range keys {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
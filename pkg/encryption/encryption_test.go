@@ -0,0 +1,109 @@
+package encryption
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	v1_common "github.com/open-telemetry/opentelemetry-proto/gen/go/common/v1"
+	v1_resource "github.com/open-telemetry/opentelemetry-proto/gen/go/resource/v1"
+	v1 "github.com/open-telemetry/opentelemetry-proto/gen/go/trace/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/tempo/pkg/tempopb"
+)
+
+func TestNew(t *testing.T) {
+	e, err := New(Config{})
+	require.NoError(t, err)
+	assert.Nil(t, e)
+
+	_, err = New(Config{MasterKeyFile: writeKey(t, "too short")})
+	assert.Error(t, err)
+
+	_, err = New(Config{MasterKeyFile: "/does/not/exist"})
+	assert.Error(t, err)
+}
+
+func TestEncryptDecrypt(t *testing.T) {
+	e := newEncrypter(t)
+
+	batch := &v1.ResourceSpans{
+		Resource: &v1_resource.Resource{
+			Attributes: []*v1_common.KeyValue{stringAttr("user.email", "a@example.com"), stringAttr("service.name", "svc")},
+		},
+		InstrumentationLibrarySpans: []*v1.InstrumentationLibrarySpans{
+			{
+				Spans: []*v1.Span{
+					{Attributes: []*v1_common.KeyValue{stringAttr("user.email", "b@example.com"), stringAttr("http.url", "/")}},
+				},
+			},
+		},
+	}
+
+	count, err := e.EncryptBatch("tenant", batch, []string{"user.email"})
+	require.NoError(t, err)
+	assert.Equal(t, 2, count)
+
+	resourceEmail := batch.Resource.Attributes[0].Value.GetStringValue()
+	spanEmail := batch.InstrumentationLibrarySpans[0].Spans[0].Attributes[0].Value.GetStringValue()
+	assert.True(t, strings.HasPrefix(resourceEmail, valuePrefix))
+	assert.True(t, strings.HasPrefix(spanEmail, valuePrefix))
+	assert.Equal(t, "svc", batch.Resource.Attributes[1].Value.GetStringValue())
+	assert.Equal(t, "/", batch.InstrumentationLibrarySpans[0].Spans[0].Attributes[1].Value.GetStringValue())
+
+	// encrypting again is a no-op
+	count, err = e.EncryptBatch("tenant", batch, []string{"user.email"})
+	require.NoError(t, err)
+	assert.Equal(t, 0, count)
+
+	// another tenant's key can't decrypt the values
+	trace := &tempopb.Trace{Batches: []*v1.ResourceSpans{batch}}
+	assert.Error(t, e.DecryptTrace("other", trace))
+	assert.Equal(t, resourceEmail, batch.Resource.Attributes[0].Value.GetStringValue())
+
+	require.NoError(t, e.DecryptTrace("tenant", trace))
+	assert.Equal(t, "a@example.com", batch.Resource.Attributes[0].Value.GetStringValue())
+	assert.Equal(t, "b@example.com", batch.InstrumentationLibrarySpans[0].Spans[0].Attributes[0].Value.GetStringValue())
+}
+
+func TestAuthorized(t *testing.T) {
+	e := newEncrypter(t)
+
+	assert.True(t, e.Authorized("admin"))
+	assert.False(t, e.Authorized("viewer"))
+	assert.False(t, e.Authorized(""))
+	assert.Equal(t, "X-Tempo-Role", e.RoleHeader())
+}
+
+func newEncrypter(t *testing.T) *Encrypter {
+	e, err := New(Config{
+		MasterKeyFile:   writeKey(t, strings.Repeat("k", minMasterKeyBytes)+"\n"),
+		RoleHeader:      "X-Tempo-Role",
+		AuthorizedRoles: []string{"admin"},
+	})
+	require.NoError(t, err)
+	require.NotNil(t, e)
+
+	return e
+}
+
+func writeKey(t *testing.T, key string) string {
+	dir, err := ioutil.TempDir("", "encryption")
+	require.NoError(t, err)
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	path := filepath.Join(dir, "key")
+	require.NoError(t, ioutil.WriteFile(path, []byte(key), 0600))
+	return path
+}
+
+func stringAttr(key string, value string) *v1_common.KeyValue {
+	return &v1_common.KeyValue{
+		Key:   key,
+		Value: &v1_common.AnyValue{Value: &v1_common.AnyValue_StringValue{StringValue: value}},
+	}
+}
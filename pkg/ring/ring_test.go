@@ -0,0 +1,117 @@
+package ring
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/cortexproject/cortex/pkg/ring"
+	"github.com/cortexproject/cortex/pkg/ring/kv/consul"
+	"github.com/cortexproject/cortex/pkg/util/services"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestZoneAwareReplication(t *testing.T) {
+	zones := []string{"zone-a", "zone-b", "zone-c"}
+
+	tests := []struct {
+		name              string
+		replicationFactor int
+		strategy          ring.ReplicationStrategy
+		replicas          int
+		replicasZoneDown  int
+	}{
+		{
+			name:              "quorum",
+			replicationFactor: 3,
+			strategy:          &ring.DefaultReplicationStrategy{},
+			replicas:          3,
+			replicasZoneDown:  2,
+		},
+		{
+			name:              "eventually consistent",
+			replicationFactor: 2,
+			strategy:          &EventuallyConsistentStrategy{},
+			replicas:          2,
+			replicasZoneDown:  1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			desc := ring.NewDesc()
+			takenTokens := []uint32{}
+			for i := 0; i < 9; i++ {
+				tokens := ring.GenerateTokens(128, takenTokens)
+				takenTokens = append(takenTokens, tokens...)
+				id := fmt.Sprintf("ingester-%d", i)
+				desc.AddIngester(id, id, zones[i%len(zones)], tokens, ring.ACTIVE)
+			}
+
+			r := newTestRing(t, desc, tt.replicationFactor, tt.strategy)
+
+			for i := 0; i < 1000; i++ {
+				for _, op := range []ring.Operation{ring.Read, ring.Write} {
+					rs, err := r.Get(rand.Uint32(), op, nil)
+					require.NoError(t, err)
+					assert.Len(t, rs.Ingesters, tt.replicas)
+					assert.Len(t, zonesOf(rs), tt.replicas)
+				}
+			}
+
+			// an outage of zone-c leaves one replica in each of the other zones
+			for id, ing := range desc.Ingesters {
+				if ing.Zone == "zone-c" {
+					ing.Timestamp = time.Now().Add(-time.Hour).Unix()
+					desc.Ingesters[id] = ing
+				}
+			}
+			r = newTestRing(t, desc, tt.replicationFactor, tt.strategy)
+
+			for i := 0; i < 1000; i++ {
+				for _, op := range []ring.Operation{ring.Read, ring.Write} {
+					rs, err := r.Get(rand.Uint32(), op, nil)
+					require.NoError(t, err)
+					assert.GreaterOrEqual(t, len(rs.Ingesters), tt.replicasZoneDown)
+					assert.NotContains(t, zonesOf(rs), "zone-c")
+					assert.Len(t, zonesOf(rs), len(rs.Ingesters))
+				}
+			}
+		})
+	}
+}
+
+func newTestRing(t *testing.T, desc *ring.Desc, replicationFactor int, strategy ring.ReplicationStrategy) *ring.Ring {
+	store := consul.NewInMemoryClient(ring.GetCodec())
+	err := store.CAS(context.Background(), ring.IngesterRingKey, func(_ interface{}) (interface{}, bool, error) {
+		return desc, false, nil
+	})
+	require.NoError(t, err)
+
+	r, err := ring.NewWithStoreClientAndStrategy(ring.Config{
+		HeartbeatTimeout:  time.Minute,
+		ReplicationFactor: replicationFactor,
+	}, "ingester", ring.IngesterRingKey, store, strategy)
+	require.NoError(t, err)
+	require.NoError(t, services.StartAndAwaitRunning(context.Background(), r))
+	t.Cleanup(func() {
+		_ = services.StopAndAwaitTerminated(context.Background(), r)
+	})
+
+	require.Eventually(t, func() bool {
+		return r.IngesterCount() == len(desc.Ingesters)
+	}, 5*time.Second, 10*time.Millisecond)
+
+	return r
+}
+
+func zonesOf(rs ring.ReplicationSet) map[string]struct{} {
+	zones := map[string]struct{}{}
+	for _, ing := range rs.Ingesters {
+		zones[ing.Zone] = struct{}{}
+	}
+	return zones
+}
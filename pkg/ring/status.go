@@ -0,0 +1,71 @@
+package ring
+
+import (
+	"bytes"
+	"html/template"
+	"net/http"
+	"time"
+)
+
+const settingsContent = `
+		<h2>Ring Settings</h2>
+		<table border="1">
+			<tr><td>Heartbeat Period</td><td>{{ .HeartbeatPeriod }}</td></tr>
+			<tr><td>Heartbeat Timeout</td><td>{{ .HeartbeatTimeout }}</td></tr>
+			<tr><td>Observe Period</td><td>{{ .ObservePeriod }}</td></tr>
+		</table>
+`
+
+var settingsTemplate = template.Must(template.New("settings").Parse(settingsContent))
+
+// Settings are the effective timing values of a ring shown on its status page.
+type Settings struct {
+	HeartbeatPeriod  time.Duration
+	HeartbeatTimeout time.Duration
+	ObservePeriod    time.Duration
+}
+
+// StatusHandler wraps a ring status page and appends the effective ring settings to it.
+func StatusHandler(next http.Handler, settings Settings) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodGet {
+			next.ServeHTTP(w, req)
+			return
+		}
+
+		rec := &bufferedResponseWriter{
+			header: w.Header(),
+			status: http.StatusOK,
+		}
+		next.ServeHTTP(rec, req)
+
+		body := rec.body.Bytes()
+		if i := bytes.LastIndex(body, []byte("</body>")); i >= 0 {
+			settingsBuf := &bytes.Buffer{}
+			if err := settingsTemplate.Execute(settingsBuf, settings); err == nil {
+				body = append(body[:i:i], append(settingsBuf.Bytes(), body[i:]...)...)
+			}
+		}
+
+		w.WriteHeader(rec.status)
+		_, _ = w.Write(body)
+	})
+}
+
+type bufferedResponseWriter struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (b *bufferedResponseWriter) Header() http.Header {
+	return b.header
+}
+
+func (b *bufferedResponseWriter) Write(p []byte) (int, error) {
+	return b.body.Write(p)
+}
+
+func (b *bufferedResponseWriter) WriteHeader(status int) {
+	b.status = status
+}
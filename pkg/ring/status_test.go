@@ -0,0 +1,39 @@
+package ring
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStatusHandler(t *testing.T) {
+	page := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method == http.MethodPost {
+			w.WriteHeader(http.StatusFound)
+			return
+		}
+		_, _ = w.Write([]byte("<html><body><h1>Ring</h1></body></html>"))
+	})
+
+	h := StatusHandler(page, Settings{
+		HeartbeatPeriod:  5 * time.Second,
+		HeartbeatTimeout: time.Minute,
+	})
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/ring", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	body := rec.Body.String()
+	assert.Contains(t, body, "<h1>Ring</h1>")
+	assert.Contains(t, body, "<td>Heartbeat Timeout</td><td>1m0s</td>")
+	assert.Contains(t, body, "<td>Observe Period</td><td>0s</td>")
+	assert.Regexp(t, "</table>\\s*</body></html>$", body)
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/ring", nil))
+	assert.Equal(t, http.StatusFound, rec.Code)
+	assert.Empty(t, rec.Body.String())
+}
@@ -72,7 +72,9 @@ func (m *TraceByIDRequest) GetTraceID() []byte {
 }
 
 type TraceByIDResponse struct {
-	Trace *Trace `protobuf:"bytes,1,opt,name=trace,proto3" json:"trace,omitempty"`
+	Trace        *Trace `protobuf:"bytes,1,opt,name=trace,proto3" json:"trace,omitempty"`
+	CombinedFrom uint32 `protobuf:"varint,2,opt,name=combinedFrom,proto3" json:"combinedFrom,omitempty"`
+	Incomplete   bool   `protobuf:"varint,3,opt,name=incomplete,proto3" json:"incomplete,omitempty"`
 }
 
 func (m *TraceByIDResponse) Reset()         { *m = TraceByIDResponse{} }
@@ -115,6 +117,20 @@ func (m *TraceByIDResponse) GetTrace() *Trace {
 	return nil
 }
 
+func (m *TraceByIDResponse) GetCombinedFrom() uint32 {
+	if m != nil {
+		return m.CombinedFrom
+	}
+	return 0
+}
+
+func (m *TraceByIDResponse) GetIncomplete() bool {
+	if m != nil {
+		return m.Incomplete
+	}
+	return false
+}
+
 type Trace struct {
 	Batches []*v1.ResourceSpans `protobuf:"bytes,1,rep,name=batches,proto3" json:"batches,omitempty"`
 }
@@ -239,433 +255,2962 @@ func (m *PushResponse) XXX_DiscardUnknown() {
 
 var xxx_messageInfo_PushResponse proto.InternalMessageInfo
 
-func init() {
-	proto.RegisterType((*TraceByIDRequest)(nil), "tempopb.TraceByIDRequest")
-	proto.RegisterType((*TraceByIDResponse)(nil), "tempopb.TraceByIDResponse")
-	proto.RegisterType((*Trace)(nil), "tempopb.Trace")
-	proto.RegisterType((*PushRequest)(nil), "tempopb.PushRequest")
-	proto.RegisterType((*PushResponse)(nil), "tempopb.PushResponse")
+type SearchRequest struct {
+	Tags          map[string]string `protobuf:"bytes,1,rep,name=tags,proto3" json:"tags,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+	MinDurationMs uint32            `protobuf:"varint,2,opt,name=minDurationMs,proto3" json:"minDurationMs,omitempty"`
+	MaxDurationMs uint32            `protobuf:"varint,3,opt,name=maxDurationMs,proto3" json:"maxDurationMs,omitempty"`
+	Limit         uint32            `protobuf:"varint,4,opt,name=limit,proto3" json:"limit,omitempty"`
+	Start         uint32            `protobuf:"varint,5,opt,name=start,proto3" json:"start,omitempty"`
+	End           uint32            `protobuf:"varint,6,opt,name=end,proto3" json:"end,omitempty"`
+	MostRecent    bool              `protobuf:"varint,7,opt,name=mostRecent,proto3" json:"mostRecent,omitempty"`
 }
 
-func init() { proto.RegisterFile("tempo.proto", fileDescriptor_b334b194b16825ec) }
-
-var fileDescriptor_b334b194b16825ec = []byte{
-	// 316 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x94, 0x50, 0x41, 0x4b, 0x33, 0x31,
-	0x14, 0xdc, 0xe5, 0xfb, 0xda, 0x85, 0xb7, 0xb5, 0x68, 0x50, 0x58, 0xf7, 0x10, 0x4a, 0xf0, 0x50,
-	0x50, 0x52, 0xba, 0xe2, 0xc1, 0x93, 0x58, 0xaa, 0xd8, 0x8b, 0xd4, 0xad, 0x7f, 0xa0, 0xad, 0x0f,
-	0x5a, 0xb0, 0x9b, 0x98, 0x64, 0x0b, 0xfd, 0x17, 0xfe, 0x2c, 0x8f, 0x3d, 0x7a, 0x94, 0xf6, 0x8f,
-	0x48, 0x93, 0x6e, 0xd9, 0x8a, 0x17, 0x6f, 0x6f, 0x32, 0x33, 0x2f, 0xf3, 0x06, 0x42, 0x83, 0x33,
-	0x29, 0xb8, 0x54, 0xc2, 0x08, 0x12, 0x58, 0x20, 0x47, 0x71, 0x53, 0x48, 0xcc, 0x0c, 0xbe, 0xe2,
-	0x0c, 0x8d, 0x5a, 0xb4, 0x2c, 0xdb, 0x32, 0x6a, 0x38, 0xc6, 0xd6, 0xbc, 0xed, 0x06, 0x67, 0x61,
-	0x17, 0x70, 0xf8, 0xbc, 0x81, 0x9d, 0x45, 0xaf, 0x9b, 0xe2, 0x5b, 0x8e, 0xda, 0x90, 0x08, 0x02,
-	0x2b, 0xe9, 0x75, 0x23, 0xbf, 0xe1, 0x37, 0x6b, 0x69, 0x01, 0xd9, 0x35, 0x1c, 0x95, 0xd4, 0x5a,
-	0x8a, 0x4c, 0x23, 0x39, 0x83, 0x8a, 0xe5, 0xad, 0x38, 0x4c, 0xea, 0x7c, 0x9b, 0x82, 0x5b, 0x69,
-	0xea, 0x48, 0xf6, 0x08, 0x15, 0x8b, 0xc9, 0x1d, 0x04, 0xa3, 0xa1, 0x19, 0x4f, 0x50, 0x47, 0x7e,
-	0xe3, 0x5f, 0x33, 0x4c, 0xce, 0xf9, 0x5e, 0x5a, 0x17, 0x8c, 0xbb, 0x90, 0xf3, 0x36, 0x4f, 0x51,
-	0x8b, 0x5c, 0x8d, 0x71, 0x20, 0x87, 0x99, 0x4e, 0x0b, 0x2f, 0xeb, 0x43, 0xd8, 0xcf, 0xf5, 0xa4,
-	0xc8, 0x7c, 0x0b, 0x15, 0xcb, 0x6c, 0x43, 0xfc, 0x69, 0xa7, 0x73, 0xb2, 0x3a, 0xd4, 0xdc, 0x46,
-	0x77, 0x57, 0x72, 0x03, 0xd5, 0x0d, 0x46, 0x45, 0xae, 0xe0, 0xff, 0x66, 0x22, 0xc7, 0xbb, 0xd3,
-	0x4a, 0x5f, 0xc7, 0x27, 0x3f, 0x5e, 0x9d, 0x9d, 0x79, 0xc9, 0x00, 0x82, 0xa7, 0x1c, 0xd5, 0x14,
-	0x15, 0x79, 0x80, 0x83, 0xfb, 0x69, 0xf6, 0xb2, 0x2b, 0x8f, 0x9c, 0xee, 0xb7, 0x54, 0xaa, 0x3f,
-	0x8e, 0x7f, 0xa3, 0x8a, 0xa5, 0x9d, 0xe8, 0x63, 0x45, 0xfd, 0xe5, 0x8a, 0xfa, 0x5f, 0x2b, 0xea,
-	0xbf, 0xaf, 0xa9, 0xb7, 0x5c, 0x53, 0xef, 0x73, 0x4d, 0xbd, 0x51, 0xd5, 0x1e, 0x79, 0xf9, 0x1d,
-	0x00, 0x00, 0xff, 0xff, 0x54, 0x74, 0x13, 0x0c, 0x13, 0x02, 0x00, 0x00,
+func (m *SearchRequest) Reset()         { *m = SearchRequest{} }
+func (m *SearchRequest) String() string { return proto.CompactTextString(m) }
+func (*SearchRequest) ProtoMessage()    {}
+func (*SearchRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_b334b194b16825ec, []int{5}
 }
-
-// Reference imports to suppress errors if they are not otherwise used.
-var _ context.Context
-var _ grpc.ClientConn
-
-// This is a compile-time assertion to ensure that this generated file
-// is compatible with the grpc package it is being compiled against.
-const _ = grpc.SupportPackageIsVersion4
-
-// PusherClient is the client API for Pusher service.
-//
-// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://godoc.org/google.golang.org/grpc#ClientConn.NewStream.
-type PusherClient interface {
-	Push(ctx context.Context, in *PushRequest, opts ...grpc.CallOption) (*PushResponse, error)
+func (m *SearchRequest) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
 }
-
-type pusherClient struct {
-	cc *grpc.ClientConn
+func (m *SearchRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_SearchRequest.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalToSizedBuffer(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	}
 }
-
-func NewPusherClient(cc *grpc.ClientConn) PusherClient {
-	return &pusherClient{cc}
+func (m *SearchRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_SearchRequest.Merge(m, src)
+}
+func (m *SearchRequest) XXX_Size() int {
+	return m.Size()
+}
+func (m *SearchRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_SearchRequest.DiscardUnknown(m)
 }
 
-func (c *pusherClient) Push(ctx context.Context, in *PushRequest, opts ...grpc.CallOption) (*PushResponse, error) {
-	out := new(PushResponse)
-	err := c.cc.Invoke(ctx, "/tempopb.Pusher/Push", in, out, opts...)
-	if err != nil {
-		return nil, err
+var xxx_messageInfo_SearchRequest proto.InternalMessageInfo
+
+func (m *SearchRequest) GetTags() map[string]string {
+	if m != nil {
+		return m.Tags
 	}
-	return out, nil
+	return nil
 }
 
-// PusherServer is the server API for Pusher service.
-type PusherServer interface {
-	Push(context.Context, *PushRequest) (*PushResponse, error)
+func (m *SearchRequest) GetMinDurationMs() uint32 {
+	if m != nil {
+		return m.MinDurationMs
+	}
+	return 0
 }
 
-// UnimplementedPusherServer can be embedded to have forward compatible implementations.
-type UnimplementedPusherServer struct {
+func (m *SearchRequest) GetMaxDurationMs() uint32 {
+	if m != nil {
+		return m.MaxDurationMs
+	}
+	return 0
 }
 
-func (*UnimplementedPusherServer) Push(ctx context.Context, req *PushRequest) (*PushResponse, error) {
-	return nil, status.Errorf(codes.Unimplemented, "method Push not implemented")
+func (m *SearchRequest) GetLimit() uint32 {
+	if m != nil {
+		return m.Limit
+	}
+	return 0
 }
 
-func RegisterPusherServer(s *grpc.Server, srv PusherServer) {
-	s.RegisterService(&_Pusher_serviceDesc, srv)
+func (m *SearchRequest) GetStart() uint32 {
+	if m != nil {
+		return m.Start
+	}
+	return 0
 }
 
-func _Pusher_Push_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(PushRequest)
-	if err := dec(in); err != nil {
-		return nil, err
-	}
-	if interceptor == nil {
-		return srv.(PusherServer).Push(ctx, in)
-	}
-	info := &grpc.UnaryServerInfo{
-		Server:     srv,
-		FullMethod: "/tempopb.Pusher/Push",
-	}
-	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(PusherServer).Push(ctx, req.(*PushRequest))
+func (m *SearchRequest) GetEnd() uint32 {
+	if m != nil {
+		return m.End
 	}
-	return interceptor(ctx, in, info, handler)
+	return 0
 }
 
-var _Pusher_serviceDesc = grpc.ServiceDesc{
-	ServiceName: "tempopb.Pusher",
-	HandlerType: (*PusherServer)(nil),
-	Methods: []grpc.MethodDesc{
-		{
-			MethodName: "Push",
-			Handler:    _Pusher_Push_Handler,
-		},
-	},
-	Streams:  []grpc.StreamDesc{},
-	Metadata: "tempo.proto",
+func (m *SearchRequest) GetMostRecent() bool {
+	if m != nil {
+		return m.MostRecent
+	}
+	return false
 }
 
-// QuerierClient is the client API for Querier service.
-//
-// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://godoc.org/google.golang.org/grpc#ClientConn.NewStream.
-type QuerierClient interface {
-	FindTraceByID(ctx context.Context, in *TraceByIDRequest, opts ...grpc.CallOption) (*TraceByIDResponse, error)
+type SearchResponse struct {
+	Traces []*TraceSearchMetadata `protobuf:"bytes,1,rep,name=traces,proto3" json:"traces,omitempty"`
 }
 
-type querierClient struct {
-	cc *grpc.ClientConn
+func (m *SearchResponse) Reset()         { *m = SearchResponse{} }
+func (m *SearchResponse) String() string { return proto.CompactTextString(m) }
+func (*SearchResponse) ProtoMessage()    {}
+func (*SearchResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_b334b194b16825ec, []int{6}
 }
-
-func NewQuerierClient(cc *grpc.ClientConn) QuerierClient {
-	return &querierClient{cc}
+func (m *SearchResponse) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
 }
-
-func (c *querierClient) FindTraceByID(ctx context.Context, in *TraceByIDRequest, opts ...grpc.CallOption) (*TraceByIDResponse, error) {
-	out := new(TraceByIDResponse)
-	err := c.cc.Invoke(ctx, "/tempopb.Querier/FindTraceByID", in, out, opts...)
-	if err != nil {
-		return nil, err
+func (m *SearchResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_SearchResponse.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalToSizedBuffer(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
 	}
-	return out, nil
 }
-
-// QuerierServer is the server API for Querier service.
-type QuerierServer interface {
-	FindTraceByID(context.Context, *TraceByIDRequest) (*TraceByIDResponse, error)
+func (m *SearchResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_SearchResponse.Merge(m, src)
 }
-
-// UnimplementedQuerierServer can be embedded to have forward compatible implementations.
-type UnimplementedQuerierServer struct {
+func (m *SearchResponse) XXX_Size() int {
+	return m.Size()
 }
-
-func (*UnimplementedQuerierServer) FindTraceByID(ctx context.Context, req *TraceByIDRequest) (*TraceByIDResponse, error) {
-	return nil, status.Errorf(codes.Unimplemented, "method FindTraceByID not implemented")
+func (m *SearchResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_SearchResponse.DiscardUnknown(m)
 }
 
-func RegisterQuerierServer(s *grpc.Server, srv QuerierServer) {
-	s.RegisterService(&_Querier_serviceDesc, srv)
-}
+var xxx_messageInfo_SearchResponse proto.InternalMessageInfo
 
-func _Querier_FindTraceByID_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(TraceByIDRequest)
-	if err := dec(in); err != nil {
-		return nil, err
-	}
-	if interceptor == nil {
-		return srv.(QuerierServer).FindTraceByID(ctx, in)
-	}
-	info := &grpc.UnaryServerInfo{
-		Server:     srv,
-		FullMethod: "/tempopb.Querier/FindTraceByID",
-	}
-	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(QuerierServer).FindTraceByID(ctx, req.(*TraceByIDRequest))
+func (m *SearchResponse) GetTraces() []*TraceSearchMetadata {
+	if m != nil {
+		return m.Traces
 	}
-	return interceptor(ctx, in, info, handler)
+	return nil
 }
 
-var _Querier_serviceDesc = grpc.ServiceDesc{
-	ServiceName: "tempopb.Querier",
-	HandlerType: (*QuerierServer)(nil),
-	Methods: []grpc.MethodDesc{
-		{
-			MethodName: "FindTraceByID",
-			Handler:    _Querier_FindTraceByID_Handler,
-		},
-	},
-	Streams:  []grpc.StreamDesc{},
-	Metadata: "tempo.proto",
+type TraceSearchMetadata struct {
+	TraceID           string `protobuf:"bytes,1,opt,name=traceID,proto3" json:"traceID,omitempty"`
+	RootServiceName   string `protobuf:"bytes,2,opt,name=rootServiceName,proto3" json:"rootServiceName,omitempty"`
+	RootTraceName     string `protobuf:"bytes,3,opt,name=rootTraceName,proto3" json:"rootTraceName,omitempty"`
+	StartTimeUnixNano uint64 `protobuf:"varint,4,opt,name=startTimeUnixNano,proto3" json:"startTimeUnixNano,omitempty"`
+	DurationMs        uint32 `protobuf:"varint,5,opt,name=durationMs,proto3" json:"durationMs,omitempty"`
 }
 
-func (m *TraceByIDRequest) Marshal() (dAtA []byte, err error) {
-	size := m.Size()
-	dAtA = make([]byte, size)
-	n, err := m.MarshalToSizedBuffer(dAtA[:size])
-	if err != nil {
-		return nil, err
+func (m *TraceSearchMetadata) Reset()         { *m = TraceSearchMetadata{} }
+func (m *TraceSearchMetadata) String() string { return proto.CompactTextString(m) }
+func (*TraceSearchMetadata) ProtoMessage()    {}
+func (*TraceSearchMetadata) Descriptor() ([]byte, []int) {
+	return fileDescriptor_b334b194b16825ec, []int{7}
+}
+func (m *TraceSearchMetadata) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *TraceSearchMetadata) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_TraceSearchMetadata.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalToSizedBuffer(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
 	}
-	return dAtA[:n], nil
 }
-
-func (m *TraceByIDRequest) MarshalTo(dAtA []byte) (int, error) {
-	size := m.Size()
-	return m.MarshalToSizedBuffer(dAtA[:size])
+func (m *TraceSearchMetadata) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_TraceSearchMetadata.Merge(m, src)
+}
+func (m *TraceSearchMetadata) XXX_Size() int {
+	return m.Size()
+}
+func (m *TraceSearchMetadata) XXX_DiscardUnknown() {
+	xxx_messageInfo_TraceSearchMetadata.DiscardUnknown(m)
 }
 
-func (m *TraceByIDRequest) MarshalToSizedBuffer(dAtA []byte) (int, error) {
-	i := len(dAtA)
-	_ = i
-	var l int
-	_ = l
-	if len(m.TraceID) > 0 {
-		i -= len(m.TraceID)
-		copy(dAtA[i:], m.TraceID)
-		i = encodeVarintTempo(dAtA, i, uint64(len(m.TraceID)))
-		i--
-		dAtA[i] = 0xa
+var xxx_messageInfo_TraceSearchMetadata proto.InternalMessageInfo
+
+func (m *TraceSearchMetadata) GetTraceID() string {
+	if m != nil {
+		return m.TraceID
 	}
-	return len(dAtA) - i, nil
+	return ""
 }
 
-func (m *TraceByIDResponse) Marshal() (dAtA []byte, err error) {
-	size := m.Size()
-	dAtA = make([]byte, size)
-	n, err := m.MarshalToSizedBuffer(dAtA[:size])
-	if err != nil {
-		return nil, err
+func (m *TraceSearchMetadata) GetRootServiceName() string {
+	if m != nil {
+		return m.RootServiceName
 	}
-	return dAtA[:n], nil
+	return ""
 }
 
-func (m *TraceByIDResponse) MarshalTo(dAtA []byte) (int, error) {
-	size := m.Size()
-	return m.MarshalToSizedBuffer(dAtA[:size])
+func (m *TraceSearchMetadata) GetRootTraceName() string {
+	if m != nil {
+		return m.RootTraceName
+	}
+	return ""
 }
 
-func (m *TraceByIDResponse) MarshalToSizedBuffer(dAtA []byte) (int, error) {
-	i := len(dAtA)
-	_ = i
-	var l int
-	_ = l
-	if m.Trace != nil {
-		{
-			size, err := m.Trace.MarshalToSizedBuffer(dAtA[:i])
-			if err != nil {
-				return 0, err
-			}
-			i -= size
-			i = encodeVarintTempo(dAtA, i, uint64(size))
-		}
-		i--
-		dAtA[i] = 0xa
+func (m *TraceSearchMetadata) GetStartTimeUnixNano() uint64 {
+	if m != nil {
+		return m.StartTimeUnixNano
 	}
-	return len(dAtA) - i, nil
+	return 0
 }
 
-func (m *Trace) Marshal() (dAtA []byte, err error) {
-	size := m.Size()
-	dAtA = make([]byte, size)
-	n, err := m.MarshalToSizedBuffer(dAtA[:size])
-	if err != nil {
-		return nil, err
+func (m *TraceSearchMetadata) GetDurationMs() uint32 {
+	if m != nil {
+		return m.DurationMs
 	}
-	return dAtA[:n], nil
+	return 0
 }
 
-func (m *Trace) MarshalTo(dAtA []byte) (int, error) {
-	size := m.Size()
-	return m.MarshalToSizedBuffer(dAtA[:size])
+type OverviewRequest struct {
 }
 
-func (m *Trace) MarshalToSizedBuffer(dAtA []byte) (int, error) {
-	i := len(dAtA)
-	_ = i
-	var l int
-	_ = l
-	if len(m.Batches) > 0 {
-		for iNdEx := len(m.Batches) - 1; iNdEx >= 0; iNdEx-- {
-			{
-				size, err := m.Batches[iNdEx].MarshalToSizedBuffer(dAtA[:i])
-				if err != nil {
-					return 0, err
-				}
-				i -= size
-				i = encodeVarintTempo(dAtA, i, uint64(size))
-			}
-			i--
-			dAtA[i] = 0xa
+func (m *OverviewRequest) Reset()         { *m = OverviewRequest{} }
+func (m *OverviewRequest) String() string { return proto.CompactTextString(m) }
+func (*OverviewRequest) ProtoMessage()    {}
+func (*OverviewRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_b334b194b16825ec, []int{8}
+}
+func (m *OverviewRequest) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *OverviewRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_OverviewRequest.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalToSizedBuffer(b)
+		if err != nil {
+			return nil, err
 		}
+		return b[:n], nil
 	}
-	return len(dAtA) - i, nil
+}
+func (m *OverviewRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_OverviewRequest.Merge(m, src)
+}
+func (m *OverviewRequest) XXX_Size() int {
+	return m.Size()
+}
+func (m *OverviewRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_OverviewRequest.DiscardUnknown(m)
 }
 
-func (m *PushRequest) Marshal() (dAtA []byte, err error) {
-	size := m.Size()
-	dAtA = make([]byte, size)
-	n, err := m.MarshalToSizedBuffer(dAtA[:size])
-	if err != nil {
-		return nil, err
+var xxx_messageInfo_OverviewRequest proto.InternalMessageInfo
+
+type OverviewResponse struct {
+	Services      []*ServiceOverview `protobuf:"bytes,1,rep,name=services,proto3" json:"services,omitempty"`
+	WindowSeconds uint32             `protobuf:"varint,2,opt,name=windowSeconds,proto3" json:"windowSeconds,omitempty"`
+}
+
+func (m *OverviewResponse) Reset()         { *m = OverviewResponse{} }
+func (m *OverviewResponse) String() string { return proto.CompactTextString(m) }
+func (*OverviewResponse) ProtoMessage()    {}
+func (*OverviewResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_b334b194b16825ec, []int{9}
+}
+func (m *OverviewResponse) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *OverviewResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_OverviewResponse.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalToSizedBuffer(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
 	}
-	return dAtA[:n], nil
+}
+func (m *OverviewResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_OverviewResponse.Merge(m, src)
+}
+func (m *OverviewResponse) XXX_Size() int {
+	return m.Size()
+}
+func (m *OverviewResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_OverviewResponse.DiscardUnknown(m)
 }
 
-func (m *PushRequest) MarshalTo(dAtA []byte) (int, error) {
-	size := m.Size()
-	return m.MarshalToSizedBuffer(dAtA[:size])
+var xxx_messageInfo_OverviewResponse proto.InternalMessageInfo
+
+func (m *OverviewResponse) GetServices() []*ServiceOverview {
+	if m != nil {
+		return m.Services
+	}
+	return nil
 }
 
-func (m *PushRequest) MarshalToSizedBuffer(dAtA []byte) (int, error) {
-	i := len(dAtA)
-	_ = i
-	var l int
-	_ = l
-	if m.Batch != nil {
-		{
-			size, err := m.Batch.MarshalToSizedBuffer(dAtA[:i])
-			if err != nil {
-				return 0, err
-			}
-			i -= size
-			i = encodeVarintTempo(dAtA, i, uint64(size))
+func (m *OverviewResponse) GetWindowSeconds() uint32 {
+	if m != nil {
+		return m.WindowSeconds
+	}
+	return 0
+}
+
+type ServiceOverview struct {
+	Name            string   `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Spans           uint64   `protobuf:"varint,2,opt,name=spans,proto3" json:"spans,omitempty"`
+	Errors          uint64   `protobuf:"varint,3,opt,name=errors,proto3" json:"errors,omitempty"`
+	DurationBuckets []uint64 `protobuf:"varint,4,rep,packed,name=durationBuckets,proto3" json:"durationBuckets,omitempty"`
+}
+
+func (m *ServiceOverview) Reset()         { *m = ServiceOverview{} }
+func (m *ServiceOverview) String() string { return proto.CompactTextString(m) }
+func (*ServiceOverview) ProtoMessage()    {}
+func (*ServiceOverview) Descriptor() ([]byte, []int) {
+	return fileDescriptor_b334b194b16825ec, []int{10}
+}
+func (m *ServiceOverview) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *ServiceOverview) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_ServiceOverview.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalToSizedBuffer(b)
+		if err != nil {
+			return nil, err
 		}
-		i--
-		dAtA[i] = 0xa
+		return b[:n], nil
 	}
-	return len(dAtA) - i, nil
+}
+func (m *ServiceOverview) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ServiceOverview.Merge(m, src)
+}
+func (m *ServiceOverview) XXX_Size() int {
+	return m.Size()
+}
+func (m *ServiceOverview) XXX_DiscardUnknown() {
+	xxx_messageInfo_ServiceOverview.DiscardUnknown(m)
 }
 
-func (m *PushResponse) Marshal() (dAtA []byte, err error) {
-	size := m.Size()
-	dAtA = make([]byte, size)
-	n, err := m.MarshalToSizedBuffer(dAtA[:size])
-	if err != nil {
-		return nil, err
+var xxx_messageInfo_ServiceOverview proto.InternalMessageInfo
+
+func (m *ServiceOverview) GetName() string {
+	if m != nil {
+		return m.Name
 	}
-	return dAtA[:n], nil
+	return ""
 }
 
-func (m *PushResponse) MarshalTo(dAtA []byte) (int, error) {
-	size := m.Size()
-	return m.MarshalToSizedBuffer(dAtA[:size])
+func (m *ServiceOverview) GetSpans() uint64 {
+	if m != nil {
+		return m.Spans
+	}
+	return 0
 }
 
-func (m *PushResponse) MarshalToSizedBuffer(dAtA []byte) (int, error) {
-	i := len(dAtA)
-	_ = i
-	var l int
-	_ = l
-	return len(dAtA) - i, nil
+func (m *ServiceOverview) GetErrors() uint64 {
+	if m != nil {
+		return m.Errors
+	}
+	return 0
 }
 
-func encodeVarintTempo(dAtA []byte, offset int, v uint64) int {
-	offset -= sovTempo(v)
-	base := offset
-	for v >= 1<<7 {
-		dAtA[offset] = uint8(v&0x7f | 0x80)
-		v >>= 7
-		offset++
+func (m *ServiceOverview) GetDurationBuckets() []uint64 {
+	if m != nil {
+		return m.DurationBuckets
 	}
-	dAtA[offset] = uint8(v)
-	return base
+	return nil
 }
-func (m *TraceByIDRequest) Size() (n int) {
-	if m == nil {
-		return 0
+
+type PushErrorDetails struct {
+	Reason  string `protobuf:"bytes,1,opt,name=reason,proto3" json:"reason,omitempty"`
+	TraceID []byte `protobuf:"bytes,2,opt,name=traceID,proto3" json:"traceID,omitempty"`
+	Spans   uint32 `protobuf:"varint,3,opt,name=spans,proto3" json:"spans,omitempty"`
+}
+
+func (m *PushErrorDetails) Reset()         { *m = PushErrorDetails{} }
+func (m *PushErrorDetails) String() string { return proto.CompactTextString(m) }
+func (*PushErrorDetails) ProtoMessage()    {}
+func (*PushErrorDetails) Descriptor() ([]byte, []int) {
+	return fileDescriptor_b334b194b16825ec, []int{11}
+}
+func (m *PushErrorDetails) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *PushErrorDetails) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_PushErrorDetails.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalToSizedBuffer(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
 	}
-	var l int
-	_ = l
-	l = len(m.TraceID)
-	if l > 0 {
-		n += 1 + l + sovTempo(uint64(l))
+}
+func (m *PushErrorDetails) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_PushErrorDetails.Merge(m, src)
+}
+func (m *PushErrorDetails) XXX_Size() int {
+	return m.Size()
+}
+func (m *PushErrorDetails) XXX_DiscardUnknown() {
+	xxx_messageInfo_PushErrorDetails.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_PushErrorDetails proto.InternalMessageInfo
+
+func (m *PushErrorDetails) GetReason() string {
+	if m != nil {
+		return m.Reason
 	}
-	return n
+	return ""
 }
 
-func (m *TraceByIDResponse) Size() (n int) {
-	if m == nil {
-		return 0
+func (m *PushErrorDetails) GetTraceID() []byte {
+	if m != nil {
+		return m.TraceID
 	}
-	var l int
-	_ = l
-	if m.Trace != nil {
-		l = m.Trace.Size()
-		n += 1 + l + sovTempo(uint64(l))
+	return nil
+}
+
+func (m *PushErrorDetails) GetSpans() uint32 {
+	if m != nil {
+		return m.Spans
 	}
-	return n
+	return 0
 }
 
-func (m *Trace) Size() (n int) {
-	if m == nil {
-		return 0
+type TailRequest struct {
+	Tags map[string]string `protobuf:"bytes,1,rep,name=tags,proto3" json:"tags,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+}
+
+func (m *TailRequest) Reset()         { *m = TailRequest{} }
+func (m *TailRequest) String() string { return proto.CompactTextString(m) }
+func (*TailRequest) ProtoMessage()    {}
+func (*TailRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_b334b194b16825ec, []int{12}
+}
+func (m *TailRequest) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *TailRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_TailRequest.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalToSizedBuffer(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
 	}
-	var l int
-	_ = l
-	if len(m.Batches) > 0 {
-		for _, e := range m.Batches {
-			l = e.Size()
-			n += 1 + l + sovTempo(uint64(l))
+}
+func (m *TailRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_TailRequest.Merge(m, src)
+}
+func (m *TailRequest) XXX_Size() int {
+	return m.Size()
+}
+func (m *TailRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_TailRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_TailRequest proto.InternalMessageInfo
+
+func (m *TailRequest) GetTags() map[string]string {
+	if m != nil {
+		return m.Tags
+	}
+	return nil
+}
+
+type TailResponse struct {
+	Batches []*v1.ResourceSpans `protobuf:"bytes,1,rep,name=batches,proto3" json:"batches,omitempty"`
+	Dropped uint32              `protobuf:"varint,2,opt,name=dropped,proto3" json:"dropped,omitempty"`
+}
+
+func (m *TailResponse) Reset()         { *m = TailResponse{} }
+func (m *TailResponse) String() string { return proto.CompactTextString(m) }
+func (*TailResponse) ProtoMessage()    {}
+func (*TailResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_b334b194b16825ec, []int{13}
+}
+func (m *TailResponse) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *TailResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_TailResponse.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalToSizedBuffer(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	}
+}
+func (m *TailResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_TailResponse.Merge(m, src)
+}
+func (m *TailResponse) XXX_Size() int {
+	return m.Size()
+}
+func (m *TailResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_TailResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_TailResponse proto.InternalMessageInfo
+
+func (m *TailResponse) GetBatches() []*v1.ResourceSpans {
+	if m != nil {
+		return m.Batches
+	}
+	return nil
+}
+
+func (m *TailResponse) GetDropped() uint32 {
+	if m != nil {
+		return m.Dropped
+	}
+	return 0
+}
+
+func init() {
+	proto.RegisterType((*TraceByIDRequest)(nil), "tempopb.TraceByIDRequest")
+	proto.RegisterType((*TraceByIDResponse)(nil), "tempopb.TraceByIDResponse")
+	proto.RegisterType((*Trace)(nil), "tempopb.Trace")
+	proto.RegisterType((*PushRequest)(nil), "tempopb.PushRequest")
+	proto.RegisterType((*PushResponse)(nil), "tempopb.PushResponse")
+	proto.RegisterType((*SearchRequest)(nil), "tempopb.SearchRequest")
+	proto.RegisterMapType((map[string]string)(nil), "tempopb.SearchRequest.TagsEntry")
+	proto.RegisterType((*SearchResponse)(nil), "tempopb.SearchResponse")
+	proto.RegisterType((*TraceSearchMetadata)(nil), "tempopb.TraceSearchMetadata")
+	proto.RegisterType((*OverviewRequest)(nil), "tempopb.OverviewRequest")
+	proto.RegisterType((*OverviewResponse)(nil), "tempopb.OverviewResponse")
+	proto.RegisterType((*ServiceOverview)(nil), "tempopb.ServiceOverview")
+	proto.RegisterType((*PushErrorDetails)(nil), "tempopb.PushErrorDetails")
+	proto.RegisterType((*TailRequest)(nil), "tempopb.TailRequest")
+	proto.RegisterMapType((map[string]string)(nil), "tempopb.TailRequest.TagsEntry")
+	proto.RegisterType((*TailResponse)(nil), "tempopb.TailResponse")
+}
+
+func init() { proto.RegisterFile("tempo.proto", fileDescriptor_b334b194b16825ec) }
+
+var fileDescriptor_b334b194b16825ec = []byte{
+	// 864 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xac, 0x55, 0xcd, 0x6e, 0xdb, 0x46,
+	0x10, 0x36, 0x25, 0x4a, 0xb2, 0x46, 0xfe, 0x91, 0x37, 0x3f, 0x65, 0x84, 0x42, 0x10, 0x88, 0x1c,
+	0x04, 0x34, 0x90, 0x1b, 0x35, 0x45, 0x8a, 0xf4, 0x50, 0xc4, 0xb0, 0xdd, 0xe6, 0x60, 0xd7, 0x5d,
+	0xb9, 0x97, 0xde, 0xd6, 0xe4, 0x20, 0x21, 0x22, 0xee, 0xb2, 0xcb, 0x95, 0x1c, 0x17, 0xc8, 0x3b,
+	0xf4, 0x31, 0xfa, 0x28, 0xbd, 0x14, 0xc8, 0xb1, 0xa7, 0xa2, 0xb0, 0x5f, 0xa4, 0xd8, 0x5d, 0xfe,
+	0xc7, 0x97, 0x18, 0xbd, 0xed, 0x7c, 0x33, 0x3b, 0x9c, 0xf9, 0xe6, 0x9b, 0x25, 0x0c, 0x14, 0xc6,
+	0x89, 0x98, 0x25, 0x52, 0x28, 0x41, 0x7a, 0xc6, 0x48, 0x2e, 0x46, 0x53, 0x91, 0x20, 0x57, 0xb8,
+	0xc4, 0x18, 0x95, 0xbc, 0xda, 0x37, 0xde, 0x7d, 0x25, 0x59, 0x80, 0xfb, 0xeb, 0xa7, 0xf6, 0x60,
+	0xaf, 0xf8, 0x4f, 0x60, 0x78, 0xae, 0xcd, 0x83, 0xab, 0x57, 0x87, 0x14, 0x7f, 0x5d, 0x61, 0xaa,
+	0x88, 0x07, 0x3d, 0x13, 0xf2, 0xea, 0xd0, 0x73, 0x26, 0xce, 0x74, 0x8b, 0xe6, 0xa6, 0xff, 0x1e,
+	0xf6, 0x2a, 0xd1, 0x69, 0x22, 0x78, 0x8a, 0xe4, 0x31, 0x74, 0x8c, 0xdf, 0x04, 0x0f, 0xe6, 0x3b,
+	0xb3, 0xac, 0x8a, 0x99, 0x09, 0xa5, 0xd6, 0x49, 0x7c, 0xd8, 0x0a, 0x44, 0x7c, 0x11, 0x71, 0x0c,
+	0x8f, 0xa5, 0x88, 0xbd, 0xd6, 0xc4, 0x99, 0x6e, 0xd3, 0x1a, 0x46, 0xc6, 0x00, 0x11, 0x0f, 0x44,
+	0x9c, 0x2c, 0x51, 0xa1, 0xd7, 0x9e, 0x38, 0xd3, 0x4d, 0x5a, 0x41, 0xfc, 0x53, 0xe8, 0x98, 0x9c,
+	0xe4, 0x08, 0x7a, 0x17, 0x4c, 0x05, 0x6f, 0x30, 0xf5, 0x9c, 0x49, 0x7b, 0x3a, 0x98, 0x7f, 0x31,
+	0xab, 0x75, 0x6c, 0x9b, 0x9b, 0xd9, 0x46, 0xd7, 0x4f, 0x67, 0x14, 0x53, 0xb1, 0x92, 0x01, 0x2e,
+	0x12, 0xc6, 0x53, 0x9a, 0xdf, 0xf5, 0xcf, 0x60, 0x70, 0xb6, 0x4a, 0xdf, 0xe4, 0x7d, 0xbf, 0x84,
+	0x8e, 0xf1, 0x64, 0x8d, 0x7c, 0x52, 0x4e, 0x7b, 0xd3, 0xdf, 0x81, 0x2d, 0x9b, 0xd1, 0x72, 0xe3,
+	0xff, 0xd1, 0x82, 0xed, 0x05, 0x32, 0x19, 0x14, 0x1f, 0x79, 0x06, 0xae, 0x62, 0xaf, 0xf3, 0xba,
+	0x27, 0x05, 0x59, 0xb5, 0xa8, 0xd9, 0x39, 0x7b, 0x9d, 0x1e, 0x71, 0x25, 0xaf, 0xa8, 0x89, 0x26,
+	0x8f, 0x61, 0x3b, 0x8e, 0xf8, 0xe1, 0x4a, 0x32, 0x15, 0x09, 0x7e, 0x92, 0x66, 0xf4, 0xd5, 0x41,
+	0x13, 0xc5, 0xde, 0x55, 0xa2, 0xda, 0x59, 0x54, 0x15, 0x24, 0xf7, 0xa1, 0xb3, 0x8c, 0xe2, 0x48,
+	0x79, 0xae, 0xf1, 0x5a, 0x43, 0xa3, 0xa9, 0x62, 0x52, 0x79, 0x1d, 0x8b, 0x1a, 0x83, 0x0c, 0xa1,
+	0x8d, 0x3c, 0xf4, 0xba, 0x06, 0xd3, 0x47, 0x3d, 0xa3, 0x58, 0xa4, 0x8a, 0x62, 0x80, 0x5c, 0x79,
+	0x3d, 0x3b, 0xa3, 0x12, 0x19, 0x3d, 0x87, 0x7e, 0x51, 0xbc, 0xbe, 0xfe, 0x16, 0xaf, 0x0c, 0x9f,
+	0x7d, 0xaa, 0x8f, 0xfa, 0x33, 0x6b, 0xb6, 0x5c, 0xa1, 0x69, 0xa0, 0x4f, 0xad, 0xf1, 0xa2, 0xf5,
+	0x8d, 0xe3, 0x1f, 0xc3, 0x4e, 0xce, 0x41, 0x26, 0xac, 0x67, 0xd0, 0x35, 0x6c, 0xe7, 0x64, 0x7d,
+	0x5e, 0x57, 0x96, 0x8d, 0x3e, 0x41, 0xc5, 0x42, 0xa6, 0x18, 0xcd, 0x62, 0xfd, 0xbf, 0x1c, 0xb8,
+	0x77, 0x8b, 0xbf, 0xa9, 0xea, 0x7e, 0xa1, 0x6a, 0x32, 0x85, 0x5d, 0x29, 0x84, 0x5a, 0xa0, 0x5c,
+	0x47, 0x01, 0x9e, 0xb2, 0x38, 0xaf, 0xae, 0x09, 0x6b, 0x82, 0x35, 0x64, 0xd2, 0x9b, 0xb8, 0xb6,
+	0x89, 0xab, 0x83, 0xe4, 0x09, 0xec, 0x19, 0xf6, 0xce, 0xa3, 0x18, 0x7f, 0xe6, 0xd1, 0xbb, 0x53,
+	0xc6, 0x85, 0x21, 0xdb, 0xa5, 0x1f, 0x3b, 0x34, 0xa1, 0x61, 0x39, 0x31, 0xcb, 0x7e, 0x05, 0xf1,
+	0xf7, 0x60, 0xf7, 0xc7, 0xb5, 0xae, 0x01, 0x2f, 0x33, 0x75, 0xf8, 0x1c, 0x86, 0x25, 0x54, 0x90,
+	0xb5, 0x99, 0xda, 0x4a, 0x73, 0xba, 0xbc, 0x8a, 0xb6, 0x8c, 0xa3, 0xb8, 0x53, 0x44, 0xea, 0x86,
+	0x2e, 0x23, 0x1e, 0x8a, 0xcb, 0x05, 0x06, 0x82, 0x87, 0x85, 0xae, 0x6a, 0xa0, 0xff, 0x1e, 0x76,
+	0x1b, 0x29, 0x08, 0x01, 0x97, 0x6b, 0x02, 0x2c, 0x95, 0xe6, 0x6c, 0x24, 0xa4, 0x97, 0xc1, 0x24,
+	0x71, 0xa9, 0x35, 0xc8, 0x43, 0xe8, 0xa2, 0x94, 0x42, 0x5a, 0x35, 0xba, 0x34, 0xb3, 0x34, 0xeb,
+	0x79, 0x97, 0x07, 0xab, 0xe0, 0x2d, 0xaa, 0xd4, 0x73, 0x27, 0xed, 0xa9, 0x4b, 0x9b, 0xb0, 0xff,
+	0x0b, 0x0c, 0xf5, 0x52, 0x1d, 0xe9, 0x7b, 0x87, 0xa8, 0x58, 0xb4, 0x34, 0x59, 0x25, 0xb2, 0x54,
+	0xf0, 0xac, 0x82, 0xcc, 0xaa, 0x4e, 0xb9, 0x55, 0x7b, 0xbb, 0xca, 0xea, 0xda, 0x99, 0xc0, 0xb5,
+	0xe1, 0xff, 0x06, 0x83, 0x73, 0x16, 0x2d, 0xf3, 0xed, 0x9c, 0xd7, 0xb6, 0x73, 0x5c, 0x0a, 0xae,
+	0x8c, 0x69, 0xee, 0xe6, 0xdd, 0x15, 0x2f, 0x60, 0xcb, 0xe6, 0xcd, 0x46, 0xf8, 0xff, 0xbc, 0x6a,
+	0x9a, 0x82, 0x50, 0x8a, 0x24, 0xc1, 0x30, 0x9b, 0x66, 0x6e, 0xce, 0xbf, 0x83, 0xae, 0x26, 0x12,
+	0x25, 0xf9, 0x1a, 0x5c, 0x7d, 0x22, 0xf7, 0x8b, 0x0e, 0x2b, 0x0f, 0xe1, 0xe8, 0x41, 0x03, 0xcd,
+	0x1e, 0xb3, 0x8d, 0xf9, 0x3f, 0x2d, 0xe8, 0xfd, 0xb4, 0x42, 0x19, 0xa1, 0x24, 0x3f, 0xc0, 0xf6,
+	0x71, 0xc4, 0xc3, 0xe2, 0x7f, 0x40, 0x1e, 0xd5, 0xd7, 0xb3, 0xf2, 0x47, 0x19, 0x8d, 0x6e, 0x73,
+	0xe5, 0x59, 0xc9, 0x19, 0xdc, 0xab, 0x65, 0x5a, 0x28, 0x89, 0x2c, 0xbe, 0x73, 0xbe, 0x2f, 0x1d,
+	0xf2, 0x2d, 0x74, 0xed, 0xf6, 0x93, 0x87, 0xb7, 0x3f, 0xb0, 0xa3, 0xcf, 0x3e, 0xc2, 0x8b, 0x72,
+	0x5e, 0xc2, 0x66, 0x21, 0xf3, 0x72, 0x87, 0x1a, 0x3b, 0x38, 0x7a, 0x74, 0x8b, 0xa7, 0x48, 0xf1,
+	0x1c, 0x5c, 0x3d, 0xd9, 0x0a, 0xbd, 0x15, 0x01, 0x8d, 0x1e, 0x34, 0xd0, 0xb2, 0xf0, 0xf9, 0x29,
+	0x0c, 0x4f, 0x50, 0xc9, 0x28, 0x48, 0xbf, 0x47, 0x8e, 0x92, 0x29, 0x21, 0xc9, 0x0b, 0xe8, 0xeb,
+	0x31, 0x98, 0x29, 0x7f, 0xe2, 0xc0, 0x0e, 0xbc, 0x3f, 0xaf, 0xc7, 0xce, 0x87, 0xeb, 0xb1, 0xf3,
+	0xef, 0xf5, 0xd8, 0xf9, 0xfd, 0x66, 0xbc, 0xf1, 0xe1, 0x66, 0xbc, 0xf1, 0xf7, 0xcd, 0x78, 0xe3,
+	0xa2, 0x6b, 0xc4, 0xf4, 0xd5, 0x7f, 0x03, 0x00, 0x46, 0xda, 0x26, 0x82, 0x41, 0x08, 0x00, 0x00,
+}
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ context.Context
+var _ grpc.ClientConn
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+const _ = grpc.SupportPackageIsVersion4
+
+// PusherClient is the client API for Pusher service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://godoc.org/google.golang.org/grpc#ClientConn.NewStream.
+type PusherClient interface {
+	Push(ctx context.Context, in *PushRequest, opts ...grpc.CallOption) (*PushResponse, error)
+}
+
+type pusherClient struct {
+	cc *grpc.ClientConn
+}
+
+func NewPusherClient(cc *grpc.ClientConn) PusherClient {
+	return &pusherClient{cc}
+}
+
+func (c *pusherClient) Push(ctx context.Context, in *PushRequest, opts ...grpc.CallOption) (*PushResponse, error) {
+	out := new(PushResponse)
+	err := c.cc.Invoke(ctx, "/tempopb.Pusher/Push", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// PusherServer is the server API for Pusher service.
+type PusherServer interface {
+	Push(context.Context, *PushRequest) (*PushResponse, error)
+}
+
+// UnimplementedPusherServer can be embedded to have forward compatible implementations.
+type UnimplementedPusherServer struct {
+}
+
+func (*UnimplementedPusherServer) Push(ctx context.Context, req *PushRequest) (*PushResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Push not implemented")
+}
+
+func RegisterPusherServer(s *grpc.Server, srv PusherServer) {
+	s.RegisterService(&_Pusher_serviceDesc, srv)
+}
+
+func _Pusher_Push_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PushRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PusherServer).Push(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/tempopb.Pusher/Push",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PusherServer).Push(ctx, req.(*PushRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+var _Pusher_serviceDesc = grpc.ServiceDesc{
+	ServiceName: "tempopb.Pusher",
+	HandlerType: (*PusherServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Push",
+			Handler:    _Pusher_Push_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "tempo.proto",
+}
+
+// QuerierClient is the client API for Querier service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://godoc.org/google.golang.org/grpc#ClientConn.NewStream.
+type QuerierClient interface {
+	FindTraceByID(ctx context.Context, in *TraceByIDRequest, opts ...grpc.CallOption) (*TraceByIDResponse, error)
+	FindTraceByIDStream(ctx context.Context, in *TraceByIDRequest, opts ...grpc.CallOption) (Querier_FindTraceByIDStreamClient, error)
+	Search(ctx context.Context, in *SearchRequest, opts ...grpc.CallOption) (*SearchResponse, error)
+	Overview(ctx context.Context, in *OverviewRequest, opts ...grpc.CallOption) (*OverviewResponse, error)
+	Tail(ctx context.Context, in *TailRequest, opts ...grpc.CallOption) (Querier_TailClient, error)
+}
+
+type querierClient struct {
+	cc *grpc.ClientConn
+}
+
+func NewQuerierClient(cc *grpc.ClientConn) QuerierClient {
+	return &querierClient{cc}
+}
+
+func (c *querierClient) FindTraceByID(ctx context.Context, in *TraceByIDRequest, opts ...grpc.CallOption) (*TraceByIDResponse, error) {
+	out := new(TraceByIDResponse)
+	err := c.cc.Invoke(ctx, "/tempopb.Querier/FindTraceByID", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *querierClient) FindTraceByIDStream(ctx context.Context, in *TraceByIDRequest, opts ...grpc.CallOption) (Querier_FindTraceByIDStreamClient, error) {
+	stream, err := c.cc.NewStream(ctx, &_Querier_serviceDesc.Streams[0], "/tempopb.Querier/FindTraceByIDStream", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &querierFindTraceByIDStreamClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Querier_FindTraceByIDStreamClient interface {
+	Recv() (*TraceByIDResponse, error)
+	grpc.ClientStream
+}
+
+type querierFindTraceByIDStreamClient struct {
+	grpc.ClientStream
+}
+
+func (x *querierFindTraceByIDStreamClient) Recv() (*TraceByIDResponse, error) {
+	m := new(TraceByIDResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *querierClient) Search(ctx context.Context, in *SearchRequest, opts ...grpc.CallOption) (*SearchResponse, error) {
+	out := new(SearchResponse)
+	err := c.cc.Invoke(ctx, "/tempopb.Querier/Search", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *querierClient) Overview(ctx context.Context, in *OverviewRequest, opts ...grpc.CallOption) (*OverviewResponse, error) {
+	out := new(OverviewResponse)
+	err := c.cc.Invoke(ctx, "/tempopb.Querier/Overview", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *querierClient) Tail(ctx context.Context, in *TailRequest, opts ...grpc.CallOption) (Querier_TailClient, error) {
+	stream, err := c.cc.NewStream(ctx, &_Querier_serviceDesc.Streams[1], "/tempopb.Querier/Tail", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &querierTailClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Querier_TailClient interface {
+	Recv() (*TailResponse, error)
+	grpc.ClientStream
+}
+
+type querierTailClient struct {
+	grpc.ClientStream
+}
+
+func (x *querierTailClient) Recv() (*TailResponse, error) {
+	m := new(TailResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// QuerierServer is the server API for Querier service.
+type QuerierServer interface {
+	FindTraceByID(context.Context, *TraceByIDRequest) (*TraceByIDResponse, error)
+	FindTraceByIDStream(*TraceByIDRequest, Querier_FindTraceByIDStreamServer) error
+	Search(context.Context, *SearchRequest) (*SearchResponse, error)
+	Overview(context.Context, *OverviewRequest) (*OverviewResponse, error)
+	Tail(*TailRequest, Querier_TailServer) error
+}
+
+// UnimplementedQuerierServer can be embedded to have forward compatible implementations.
+type UnimplementedQuerierServer struct {
+}
+
+func (*UnimplementedQuerierServer) FindTraceByID(ctx context.Context, req *TraceByIDRequest) (*TraceByIDResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method FindTraceByID not implemented")
+}
+func (*UnimplementedQuerierServer) FindTraceByIDStream(req *TraceByIDRequest, srv Querier_FindTraceByIDStreamServer) error {
+	return status.Errorf(codes.Unimplemented, "method FindTraceByIDStream not implemented")
+}
+func (*UnimplementedQuerierServer) Search(ctx context.Context, req *SearchRequest) (*SearchResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Search not implemented")
+}
+func (*UnimplementedQuerierServer) Overview(ctx context.Context, req *OverviewRequest) (*OverviewResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Overview not implemented")
+}
+func (*UnimplementedQuerierServer) Tail(req *TailRequest, srv Querier_TailServer) error {
+	return status.Errorf(codes.Unimplemented, "method Tail not implemented")
+}
+
+func RegisterQuerierServer(s *grpc.Server, srv QuerierServer) {
+	s.RegisterService(&_Querier_serviceDesc, srv)
+}
+
+func _Querier_FindTraceByID_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(TraceByIDRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(QuerierServer).FindTraceByID(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/tempopb.Querier/FindTraceByID",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(QuerierServer).FindTraceByID(ctx, req.(*TraceByIDRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Querier_FindTraceByIDStream_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(TraceByIDRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(QuerierServer).FindTraceByIDStream(m, &querierFindTraceByIDStreamServer{stream})
+}
+
+type Querier_FindTraceByIDStreamServer interface {
+	Send(*TraceByIDResponse) error
+	grpc.ServerStream
+}
+
+type querierFindTraceByIDStreamServer struct {
+	grpc.ServerStream
+}
+
+func (x *querierFindTraceByIDStreamServer) Send(m *TraceByIDResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _Querier_Search_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SearchRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(QuerierServer).Search(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/tempopb.Querier/Search",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(QuerierServer).Search(ctx, req.(*SearchRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Querier_Overview_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(OverviewRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(QuerierServer).Overview(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/tempopb.Querier/Overview",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(QuerierServer).Overview(ctx, req.(*OverviewRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Querier_Tail_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(TailRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(QuerierServer).Tail(m, &querierTailServer{stream})
+}
+
+type Querier_TailServer interface {
+	Send(*TailResponse) error
+	grpc.ServerStream
+}
+
+type querierTailServer struct {
+	grpc.ServerStream
+}
+
+func (x *querierTailServer) Send(m *TailResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+var _Querier_serviceDesc = grpc.ServiceDesc{
+	ServiceName: "tempopb.Querier",
+	HandlerType: (*QuerierServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "FindTraceByID",
+			Handler:    _Querier_FindTraceByID_Handler,
+		},
+		{
+			MethodName: "Search",
+			Handler:    _Querier_Search_Handler,
+		},
+		{
+			MethodName: "Overview",
+			Handler:    _Querier_Overview_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "FindTraceByIDStream",
+			Handler:       _Querier_FindTraceByIDStream_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "Tail",
+			Handler:       _Querier_Tail_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "tempo.proto",
+}
+
+// MetricsGeneratorClient is the client API for MetricsGenerator service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://godoc.org/google.golang.org/grpc#ClientConn.NewStream.
+type MetricsGeneratorClient interface {
+	PushSpans(ctx context.Context, in *PushRequest, opts ...grpc.CallOption) (*PushResponse, error)
+}
+
+type metricsGeneratorClient struct {
+	cc *grpc.ClientConn
+}
+
+func NewMetricsGeneratorClient(cc *grpc.ClientConn) MetricsGeneratorClient {
+	return &metricsGeneratorClient{cc}
+}
+
+func (c *metricsGeneratorClient) PushSpans(ctx context.Context, in *PushRequest, opts ...grpc.CallOption) (*PushResponse, error) {
+	out := new(PushResponse)
+	err := c.cc.Invoke(ctx, "/tempopb.MetricsGenerator/PushSpans", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// MetricsGeneratorServer is the server API for MetricsGenerator service.
+type MetricsGeneratorServer interface {
+	PushSpans(context.Context, *PushRequest) (*PushResponse, error)
+}
+
+// UnimplementedMetricsGeneratorServer can be embedded to have forward compatible implementations.
+type UnimplementedMetricsGeneratorServer struct {
+}
+
+func (*UnimplementedMetricsGeneratorServer) PushSpans(ctx context.Context, req *PushRequest) (*PushResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method PushSpans not implemented")
+}
+
+func RegisterMetricsGeneratorServer(s *grpc.Server, srv MetricsGeneratorServer) {
+	s.RegisterService(&_MetricsGenerator_serviceDesc, srv)
+}
+
+func _MetricsGenerator_PushSpans_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PushRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MetricsGeneratorServer).PushSpans(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/tempopb.MetricsGenerator/PushSpans",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MetricsGeneratorServer).PushSpans(ctx, req.(*PushRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+var _MetricsGenerator_serviceDesc = grpc.ServiceDesc{
+	ServiceName: "tempopb.MetricsGenerator",
+	HandlerType: (*MetricsGeneratorServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "PushSpans",
+			Handler:    _MetricsGenerator_PushSpans_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "tempo.proto",
+}
+
+func (m *TraceByIDRequest) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *TraceByIDRequest) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *TraceByIDRequest) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	if len(m.TraceID) > 0 {
+		i -= len(m.TraceID)
+		copy(dAtA[i:], m.TraceID)
+		i = encodeVarintTempo(dAtA, i, uint64(len(m.TraceID)))
+		i--
+		dAtA[i] = 0xa
+	}
+	return len(dAtA) - i, nil
+}
+
+func (m *TraceByIDResponse) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *TraceByIDResponse) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *TraceByIDResponse) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	if m.Incomplete {
+		i--
+		if m.Incomplete {
+			dAtA[i] = 1
+		} else {
+			dAtA[i] = 0
+		}
+		i--
+		dAtA[i] = 0x18
+	}
+	if m.CombinedFrom != 0 {
+		i = encodeVarintTempo(dAtA, i, uint64(m.CombinedFrom))
+		i--
+		dAtA[i] = 0x10
+	}
+	if m.Trace != nil {
+		{
+			size, err := m.Trace.MarshalToSizedBuffer(dAtA[:i])
+			if err != nil {
+				return 0, err
+			}
+			i -= size
+			i = encodeVarintTempo(dAtA, i, uint64(size))
+		}
+		i--
+		dAtA[i] = 0xa
+	}
+	return len(dAtA) - i, nil
+}
+
+func (m *Trace) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *Trace) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *Trace) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	if len(m.Batches) > 0 {
+		for iNdEx := len(m.Batches) - 1; iNdEx >= 0; iNdEx-- {
+			{
+				size, err := m.Batches[iNdEx].MarshalToSizedBuffer(dAtA[:i])
+				if err != nil {
+					return 0, err
+				}
+				i -= size
+				i = encodeVarintTempo(dAtA, i, uint64(size))
+			}
+			i--
+			dAtA[i] = 0xa
+		}
+	}
+	return len(dAtA) - i, nil
+}
+
+func (m *PushRequest) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *PushRequest) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *PushRequest) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	if m.Batch != nil {
+		{
+			size, err := m.Batch.MarshalToSizedBuffer(dAtA[:i])
+			if err != nil {
+				return 0, err
+			}
+			i -= size
+			i = encodeVarintTempo(dAtA, i, uint64(size))
+		}
+		i--
+		dAtA[i] = 0xa
+	}
+	return len(dAtA) - i, nil
+}
+
+func (m *PushResponse) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *PushResponse) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *PushResponse) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	return len(dAtA) - i, nil
+}
+
+func (m *SearchRequest) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *SearchRequest) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *SearchRequest) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	if m.MostRecent {
+		i--
+		if m.MostRecent {
+			dAtA[i] = 1
+		} else {
+			dAtA[i] = 0
+		}
+		i--
+		dAtA[i] = 0x38
+	}
+	if m.End != 0 {
+		i = encodeVarintTempo(dAtA, i, uint64(m.End))
+		i--
+		dAtA[i] = 0x30
+	}
+	if m.Start != 0 {
+		i = encodeVarintTempo(dAtA, i, uint64(m.Start))
+		i--
+		dAtA[i] = 0x28
+	}
+	if m.Limit != 0 {
+		i = encodeVarintTempo(dAtA, i, uint64(m.Limit))
+		i--
+		dAtA[i] = 0x20
+	}
+	if m.MaxDurationMs != 0 {
+		i = encodeVarintTempo(dAtA, i, uint64(m.MaxDurationMs))
+		i--
+		dAtA[i] = 0x18
+	}
+	if m.MinDurationMs != 0 {
+		i = encodeVarintTempo(dAtA, i, uint64(m.MinDurationMs))
+		i--
+		dAtA[i] = 0x10
+	}
+	if len(m.Tags) > 0 {
+		for k := range m.Tags {
+			v := m.Tags[k]
+			baseI := i
+			i -= len(v)
+			copy(dAtA[i:], v)
+			i = encodeVarintTempo(dAtA, i, uint64(len(v)))
+			i--
+			dAtA[i] = 0x12
+			i -= len(k)
+			copy(dAtA[i:], k)
+			i = encodeVarintTempo(dAtA, i, uint64(len(k)))
+			i--
+			dAtA[i] = 0xa
+			i = encodeVarintTempo(dAtA, i, uint64(baseI-i))
+			i--
+			dAtA[i] = 0xa
+		}
+	}
+	return len(dAtA) - i, nil
+}
+
+func (m *SearchResponse) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *SearchResponse) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *SearchResponse) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	if len(m.Traces) > 0 {
+		for iNdEx := len(m.Traces) - 1; iNdEx >= 0; iNdEx-- {
+			{
+				size, err := m.Traces[iNdEx].MarshalToSizedBuffer(dAtA[:i])
+				if err != nil {
+					return 0, err
+				}
+				i -= size
+				i = encodeVarintTempo(dAtA, i, uint64(size))
+			}
+			i--
+			dAtA[i] = 0xa
+		}
+	}
+	return len(dAtA) - i, nil
+}
+
+func (m *TraceSearchMetadata) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *TraceSearchMetadata) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *TraceSearchMetadata) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	if m.DurationMs != 0 {
+		i = encodeVarintTempo(dAtA, i, uint64(m.DurationMs))
+		i--
+		dAtA[i] = 0x28
+	}
+	if m.StartTimeUnixNano != 0 {
+		i = encodeVarintTempo(dAtA, i, uint64(m.StartTimeUnixNano))
+		i--
+		dAtA[i] = 0x20
+	}
+	if len(m.RootTraceName) > 0 {
+		i -= len(m.RootTraceName)
+		copy(dAtA[i:], m.RootTraceName)
+		i = encodeVarintTempo(dAtA, i, uint64(len(m.RootTraceName)))
+		i--
+		dAtA[i] = 0x1a
+	}
+	if len(m.RootServiceName) > 0 {
+		i -= len(m.RootServiceName)
+		copy(dAtA[i:], m.RootServiceName)
+		i = encodeVarintTempo(dAtA, i, uint64(len(m.RootServiceName)))
+		i--
+		dAtA[i] = 0x12
+	}
+	if len(m.TraceID) > 0 {
+		i -= len(m.TraceID)
+		copy(dAtA[i:], m.TraceID)
+		i = encodeVarintTempo(dAtA, i, uint64(len(m.TraceID)))
+		i--
+		dAtA[i] = 0xa
+	}
+	return len(dAtA) - i, nil
+}
+
+func (m *OverviewRequest) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *OverviewRequest) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *OverviewRequest) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	return len(dAtA) - i, nil
+}
+
+func (m *OverviewResponse) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *OverviewResponse) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *OverviewResponse) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	if m.WindowSeconds != 0 {
+		i = encodeVarintTempo(dAtA, i, uint64(m.WindowSeconds))
+		i--
+		dAtA[i] = 0x10
+	}
+	if len(m.Services) > 0 {
+		for iNdEx := len(m.Services) - 1; iNdEx >= 0; iNdEx-- {
+			{
+				size, err := m.Services[iNdEx].MarshalToSizedBuffer(dAtA[:i])
+				if err != nil {
+					return 0, err
+				}
+				i -= size
+				i = encodeVarintTempo(dAtA, i, uint64(size))
+			}
+			i--
+			dAtA[i] = 0xa
+		}
+	}
+	return len(dAtA) - i, nil
+}
+
+func (m *ServiceOverview) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *ServiceOverview) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *ServiceOverview) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	if len(m.DurationBuckets) > 0 {
+		dAtA4 := make([]byte, len(m.DurationBuckets)*10)
+		var j3 int
+		for _, num := range m.DurationBuckets {
+			for num >= 1<<7 {
+				dAtA4[j3] = uint8(uint64(num)&0x7f | 0x80)
+				num >>= 7
+				j3++
+			}
+			dAtA4[j3] = uint8(num)
+			j3++
+		}
+		i -= j3
+		copy(dAtA[i:], dAtA4[:j3])
+		i = encodeVarintTempo(dAtA, i, uint64(j3))
+		i--
+		dAtA[i] = 0x22
+	}
+	if m.Errors != 0 {
+		i = encodeVarintTempo(dAtA, i, uint64(m.Errors))
+		i--
+		dAtA[i] = 0x18
+	}
+	if m.Spans != 0 {
+		i = encodeVarintTempo(dAtA, i, uint64(m.Spans))
+		i--
+		dAtA[i] = 0x10
+	}
+	if len(m.Name) > 0 {
+		i -= len(m.Name)
+		copy(dAtA[i:], m.Name)
+		i = encodeVarintTempo(dAtA, i, uint64(len(m.Name)))
+		i--
+		dAtA[i] = 0xa
+	}
+	return len(dAtA) - i, nil
+}
+
+func (m *PushErrorDetails) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *PushErrorDetails) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *PushErrorDetails) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	if m.Spans != 0 {
+		i = encodeVarintTempo(dAtA, i, uint64(m.Spans))
+		i--
+		dAtA[i] = 0x18
+	}
+	if len(m.TraceID) > 0 {
+		i -= len(m.TraceID)
+		copy(dAtA[i:], m.TraceID)
+		i = encodeVarintTempo(dAtA, i, uint64(len(m.TraceID)))
+		i--
+		dAtA[i] = 0x12
+	}
+	if len(m.Reason) > 0 {
+		i -= len(m.Reason)
+		copy(dAtA[i:], m.Reason)
+		i = encodeVarintTempo(dAtA, i, uint64(len(m.Reason)))
+		i--
+		dAtA[i] = 0xa
+	}
+	return len(dAtA) - i, nil
+}
+
+func (m *TailRequest) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *TailRequest) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *TailRequest) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	if len(m.Tags) > 0 {
+		for k := range m.Tags {
+			v := m.Tags[k]
+			baseI := i
+			i -= len(v)
+			copy(dAtA[i:], v)
+			i = encodeVarintTempo(dAtA, i, uint64(len(v)))
+			i--
+			dAtA[i] = 0x12
+			i -= len(k)
+			copy(dAtA[i:], k)
+			i = encodeVarintTempo(dAtA, i, uint64(len(k)))
+			i--
+			dAtA[i] = 0xa
+			i = encodeVarintTempo(dAtA, i, uint64(baseI-i))
+			i--
+			dAtA[i] = 0xa
+		}
+	}
+	return len(dAtA) - i, nil
+}
+
+func (m *TailResponse) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *TailResponse) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *TailResponse) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	if m.Dropped != 0 {
+		i = encodeVarintTempo(dAtA, i, uint64(m.Dropped))
+		i--
+		dAtA[i] = 0x10
+	}
+	if len(m.Batches) > 0 {
+		for iNdEx := len(m.Batches) - 1; iNdEx >= 0; iNdEx-- {
+			{
+				size, err := m.Batches[iNdEx].MarshalToSizedBuffer(dAtA[:i])
+				if err != nil {
+					return 0, err
+				}
+				i -= size
+				i = encodeVarintTempo(dAtA, i, uint64(size))
+			}
+			i--
+			dAtA[i] = 0xa
+		}
+	}
+	return len(dAtA) - i, nil
+}
+
+func encodeVarintTempo(dAtA []byte, offset int, v uint64) int {
+	offset -= sovTempo(v)
+	base := offset
+	for v >= 1<<7 {
+		dAtA[offset] = uint8(v&0x7f | 0x80)
+		v >>= 7
+		offset++
+	}
+	dAtA[offset] = uint8(v)
+	return base
+}
+func (m *TraceByIDRequest) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	l = len(m.TraceID)
+	if l > 0 {
+		n += 1 + l + sovTempo(uint64(l))
+	}
+	return n
+}
+
+func (m *TraceByIDResponse) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	if m.Trace != nil {
+		l = m.Trace.Size()
+		n += 1 + l + sovTempo(uint64(l))
+	}
+	if m.CombinedFrom != 0 {
+		n += 1 + sovTempo(uint64(m.CombinedFrom))
+	}
+	if m.Incomplete {
+		n += 2
+	}
+	return n
+}
+
+func (m *Trace) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	if len(m.Batches) > 0 {
+		for _, e := range m.Batches {
+			l = e.Size()
+			n += 1 + l + sovTempo(uint64(l))
+		}
+	}
+	return n
+}
+
+func (m *PushRequest) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	if m.Batch != nil {
+		l = m.Batch.Size()
+		n += 1 + l + sovTempo(uint64(l))
+	}
+	return n
+}
+
+func (m *PushResponse) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	return n
+}
+
+func (m *SearchRequest) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	if len(m.Tags) > 0 {
+		for k, v := range m.Tags {
+			_ = k
+			_ = v
+			mapEntrySize := 1 + len(k) + sovTempo(uint64(len(k))) + 1 + len(v) + sovTempo(uint64(len(v)))
+			n += mapEntrySize + 1 + sovTempo(uint64(mapEntrySize))
+		}
+	}
+	if m.MinDurationMs != 0 {
+		n += 1 + sovTempo(uint64(m.MinDurationMs))
+	}
+	if m.MaxDurationMs != 0 {
+		n += 1 + sovTempo(uint64(m.MaxDurationMs))
+	}
+	if m.Limit != 0 {
+		n += 1 + sovTempo(uint64(m.Limit))
+	}
+	if m.Start != 0 {
+		n += 1 + sovTempo(uint64(m.Start))
+	}
+	if m.End != 0 {
+		n += 1 + sovTempo(uint64(m.End))
+	}
+	if m.MostRecent {
+		n += 2
+	}
+	return n
+}
+
+func (m *SearchResponse) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	if len(m.Traces) > 0 {
+		for _, e := range m.Traces {
+			l = e.Size()
+			n += 1 + l + sovTempo(uint64(l))
+		}
+	}
+	return n
+}
+
+func (m *TraceSearchMetadata) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	l = len(m.TraceID)
+	if l > 0 {
+		n += 1 + l + sovTempo(uint64(l))
+	}
+	l = len(m.RootServiceName)
+	if l > 0 {
+		n += 1 + l + sovTempo(uint64(l))
+	}
+	l = len(m.RootTraceName)
+	if l > 0 {
+		n += 1 + l + sovTempo(uint64(l))
+	}
+	if m.StartTimeUnixNano != 0 {
+		n += 1 + sovTempo(uint64(m.StartTimeUnixNano))
+	}
+	if m.DurationMs != 0 {
+		n += 1 + sovTempo(uint64(m.DurationMs))
+	}
+	return n
+}
+
+func (m *OverviewRequest) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	return n
+}
+
+func (m *OverviewResponse) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	if len(m.Services) > 0 {
+		for _, e := range m.Services {
+			l = e.Size()
+			n += 1 + l + sovTempo(uint64(l))
+		}
+	}
+	if m.WindowSeconds != 0 {
+		n += 1 + sovTempo(uint64(m.WindowSeconds))
+	}
+	return n
+}
+
+func (m *ServiceOverview) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	l = len(m.Name)
+	if l > 0 {
+		n += 1 + l + sovTempo(uint64(l))
+	}
+	if m.Spans != 0 {
+		n += 1 + sovTempo(uint64(m.Spans))
+	}
+	if m.Errors != 0 {
+		n += 1 + sovTempo(uint64(m.Errors))
+	}
+	if len(m.DurationBuckets) > 0 {
+		l = 0
+		for _, e := range m.DurationBuckets {
+			l += sovTempo(uint64(e))
+		}
+		n += 1 + sovTempo(uint64(l)) + l
+	}
+	return n
+}
+
+func (m *PushErrorDetails) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	l = len(m.Reason)
+	if l > 0 {
+		n += 1 + l + sovTempo(uint64(l))
+	}
+	l = len(m.TraceID)
+	if l > 0 {
+		n += 1 + l + sovTempo(uint64(l))
+	}
+	if m.Spans != 0 {
+		n += 1 + sovTempo(uint64(m.Spans))
+	}
+	return n
+}
+
+func (m *TailRequest) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	if len(m.Tags) > 0 {
+		for k, v := range m.Tags {
+			_ = k
+			_ = v
+			mapEntrySize := 1 + len(k) + sovTempo(uint64(len(k))) + 1 + len(v) + sovTempo(uint64(len(v)))
+			n += mapEntrySize + 1 + sovTempo(uint64(mapEntrySize))
+		}
+	}
+	return n
+}
+
+func (m *TailResponse) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	if len(m.Batches) > 0 {
+		for _, e := range m.Batches {
+			l = e.Size()
+			n += 1 + l + sovTempo(uint64(l))
+		}
+	}
+	if m.Dropped != 0 {
+		n += 1 + sovTempo(uint64(m.Dropped))
+	}
+	return n
+}
+
+func sovTempo(x uint64) (n int) {
+	return (math_bits.Len64(x|1) + 6) / 7
+}
+func sozTempo(x uint64) (n int) {
+	return sovTempo(uint64((x << 1) ^ uint64((int64(x) >> 63))))
+}
+func (m *TraceByIDRequest) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowTempo
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: TraceByIDRequest: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: TraceByIDRequest: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field TraceID", wireType)
+			}
+			var byteLen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowTempo
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				byteLen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if byteLen < 0 {
+				return ErrInvalidLengthTempo
+			}
+			postIndex := iNdEx + byteLen
+			if postIndex < 0 {
+				return ErrInvalidLengthTempo
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.TraceID = append(m.TraceID[:0], dAtA[iNdEx:postIndex]...)
+			if m.TraceID == nil {
+				m.TraceID = []byte{}
+			}
+			iNdEx = postIndex
+		default:
+			iNdEx = preIndex
+			skippy, err := skipTempo(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthTempo
+			}
+			if (iNdEx + skippy) < 0 {
+				return ErrInvalidLengthTempo
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *TraceByIDResponse) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowTempo
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: TraceByIDResponse: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: TraceByIDResponse: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Trace", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowTempo
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthTempo
+			}
+			postIndex := iNdEx + msglen
+			if postIndex < 0 {
+				return ErrInvalidLengthTempo
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if m.Trace == nil {
+				m.Trace = &Trace{}
+			}
+			if err := m.Trace.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		case 2:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field CombinedFrom", wireType)
+			}
+			m.CombinedFrom = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowTempo
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.CombinedFrom |= uint32(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 3:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Incomplete", wireType)
+			}
+			var v int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowTempo
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				v |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			m.Incomplete = bool(v != 0)
+		default:
+			iNdEx = preIndex
+			skippy, err := skipTempo(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthTempo
+			}
+			if (iNdEx + skippy) < 0 {
+				return ErrInvalidLengthTempo
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *Trace) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowTempo
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: Trace: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: Trace: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Batches", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowTempo
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthTempo
+			}
+			postIndex := iNdEx + msglen
+			if postIndex < 0 {
+				return ErrInvalidLengthTempo
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Batches = append(m.Batches, &v1.ResourceSpans{})
+			if err := m.Batches[len(m.Batches)-1].Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		default:
+			iNdEx = preIndex
+			skippy, err := skipTempo(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthTempo
+			}
+			if (iNdEx + skippy) < 0 {
+				return ErrInvalidLengthTempo
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *PushRequest) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowTempo
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: PushRequest: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: PushRequest: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Batch", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowTempo
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthTempo
+			}
+			postIndex := iNdEx + msglen
+			if postIndex < 0 {
+				return ErrInvalidLengthTempo
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if m.Batch == nil {
+				m.Batch = &v1.ResourceSpans{}
+			}
+			if err := m.Batch.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		default:
+			iNdEx = preIndex
+			skippy, err := skipTempo(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthTempo
+			}
+			if (iNdEx + skippy) < 0 {
+				return ErrInvalidLengthTempo
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *PushResponse) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowTempo
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: PushResponse: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: PushResponse: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		default:
+			iNdEx = preIndex
+			skippy, err := skipTempo(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthTempo
+			}
+			if (iNdEx + skippy) < 0 {
+				return ErrInvalidLengthTempo
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *SearchRequest) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowTempo
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: SearchRequest: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: SearchRequest: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Tags", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowTempo
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthTempo
+			}
+			postIndex := iNdEx + msglen
+			if postIndex < 0 {
+				return ErrInvalidLengthTempo
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if m.Tags == nil {
+				m.Tags = make(map[string]string)
+			}
+			var mapkey string
+			var mapvalue string
+			for iNdEx < postIndex {
+				entryPreIndex := iNdEx
+				var wire uint64
+				for shift := uint(0); ; shift += 7 {
+					if shift >= 64 {
+						return ErrIntOverflowTempo
+					}
+					if iNdEx >= l {
+						return io.ErrUnexpectedEOF
+					}
+					b := dAtA[iNdEx]
+					iNdEx++
+					wire |= uint64(b&0x7F) << shift
+					if b < 0x80 {
+						break
+					}
+				}
+				fieldNum := int32(wire >> 3)
+				if fieldNum == 1 {
+					var stringLenmapkey uint64
+					for shift := uint(0); ; shift += 7 {
+						if shift >= 64 {
+							return ErrIntOverflowTempo
+						}
+						if iNdEx >= l {
+							return io.ErrUnexpectedEOF
+						}
+						b := dAtA[iNdEx]
+						iNdEx++
+						stringLenmapkey |= uint64(b&0x7F) << shift
+						if b < 0x80 {
+							break
+						}
+					}
+					intStringLenmapkey := int(stringLenmapkey)
+					if intStringLenmapkey < 0 {
+						return ErrInvalidLengthTempo
+					}
+					postStringIndexmapkey := iNdEx + intStringLenmapkey
+					if postStringIndexmapkey < 0 {
+						return ErrInvalidLengthTempo
+					}
+					if postStringIndexmapkey > l {
+						return io.ErrUnexpectedEOF
+					}
+					mapkey = string(dAtA[iNdEx:postStringIndexmapkey])
+					iNdEx = postStringIndexmapkey
+				} else if fieldNum == 2 {
+					var stringLenmapvalue uint64
+					for shift := uint(0); ; shift += 7 {
+						if shift >= 64 {
+							return ErrIntOverflowTempo
+						}
+						if iNdEx >= l {
+							return io.ErrUnexpectedEOF
+						}
+						b := dAtA[iNdEx]
+						iNdEx++
+						stringLenmapvalue |= uint64(b&0x7F) << shift
+						if b < 0x80 {
+							break
+						}
+					}
+					intStringLenmapvalue := int(stringLenmapvalue)
+					if intStringLenmapvalue < 0 {
+						return ErrInvalidLengthTempo
+					}
+					postStringIndexmapvalue := iNdEx + intStringLenmapvalue
+					if postStringIndexmapvalue < 0 {
+						return ErrInvalidLengthTempo
+					}
+					if postStringIndexmapvalue > l {
+						return io.ErrUnexpectedEOF
+					}
+					mapvalue = string(dAtA[iNdEx:postStringIndexmapvalue])
+					iNdEx = postStringIndexmapvalue
+				} else {
+					iNdEx = entryPreIndex
+					skippy, err := skipTempo(dAtA[iNdEx:])
+					if err != nil {
+						return err
+					}
+					if skippy < 0 {
+						return ErrInvalidLengthTempo
+					}
+					if (iNdEx + skippy) > postIndex {
+						return io.ErrUnexpectedEOF
+					}
+					iNdEx += skippy
+				}
+			}
+			m.Tags[mapkey] = mapvalue
+			iNdEx = postIndex
+		case 2:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field MinDurationMs", wireType)
+			}
+			m.MinDurationMs = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowTempo
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.MinDurationMs |= uint32(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 3:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field MaxDurationMs", wireType)
+			}
+			m.MaxDurationMs = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowTempo
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.MaxDurationMs |= uint32(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 4:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Limit", wireType)
+			}
+			m.Limit = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowTempo
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.Limit |= uint32(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 5:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Start", wireType)
+			}
+			m.Start = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowTempo
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.Start |= uint32(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 6:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field End", wireType)
+			}
+			m.End = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowTempo
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.End |= uint32(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 7:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field MostRecent", wireType)
+			}
+			var v int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowTempo
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				v |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			m.MostRecent = bool(v != 0)
+		default:
+			iNdEx = preIndex
+			skippy, err := skipTempo(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthTempo
+			}
+			if (iNdEx + skippy) < 0 {
+				return ErrInvalidLengthTempo
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *SearchResponse) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowTempo
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: SearchResponse: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: SearchResponse: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Traces", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowTempo
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthTempo
+			}
+			postIndex := iNdEx + msglen
+			if postIndex < 0 {
+				return ErrInvalidLengthTempo
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Traces = append(m.Traces, &TraceSearchMetadata{})
+			if err := m.Traces[len(m.Traces)-1].Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		default:
+			iNdEx = preIndex
+			skippy, err := skipTempo(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthTempo
+			}
+			if (iNdEx + skippy) < 0 {
+				return ErrInvalidLengthTempo
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *TraceSearchMetadata) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowTempo
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: TraceSearchMetadata: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: TraceSearchMetadata: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field TraceID", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowTempo
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthTempo
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthTempo
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.TraceID = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 2:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field RootServiceName", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowTempo
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthTempo
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthTempo
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.RootServiceName = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 3:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field RootTraceName", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowTempo
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthTempo
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthTempo
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.RootTraceName = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 4:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field StartTimeUnixNano", wireType)
+			}
+			m.StartTimeUnixNano = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowTempo
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.StartTimeUnixNano |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 5:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field DurationMs", wireType)
+			}
+			m.DurationMs = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowTempo
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.DurationMs |= uint32(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		default:
+			iNdEx = preIndex
+			skippy, err := skipTempo(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthTempo
+			}
+			if (iNdEx + skippy) < 0 {
+				return ErrInvalidLengthTempo
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *OverviewRequest) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowTempo
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: OverviewRequest: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: OverviewRequest: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		default:
+			iNdEx = preIndex
+			skippy, err := skipTempo(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthTempo
+			}
+			if (iNdEx + skippy) < 0 {
+				return ErrInvalidLengthTempo
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
 		}
 	}
-	return n
-}
-
-func (m *PushRequest) Size() (n int) {
-	if m == nil {
-		return 0
-	}
-	var l int
-	_ = l
-	if m.Batch != nil {
-		l = m.Batch.Size()
-		n += 1 + l + sovTempo(uint64(l))
-	}
-	return n
-}
 
-func (m *PushResponse) Size() (n int) {
-	if m == nil {
-		return 0
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
 	}
-	var l int
-	_ = l
-	return n
-}
-
-func sovTempo(x uint64) (n int) {
-	return (math_bits.Len64(x|1) + 6) / 7
-}
-func sozTempo(x uint64) (n int) {
-	return sovTempo(uint64((x << 1) ^ uint64((int64(x) >> 63))))
+	return nil
 }
-func (m *TraceByIDRequest) Unmarshal(dAtA []byte) error {
+func (m *OverviewResponse) Unmarshal(dAtA []byte) error {
 	l := len(dAtA)
 	iNdEx := 0
 	for iNdEx < l {
@@ -688,17 +3233,17 @@ func (m *TraceByIDRequest) Unmarshal(dAtA []byte) error {
 		fieldNum := int32(wire >> 3)
 		wireType := int(wire & 0x7)
 		if wireType == 4 {
-			return fmt.Errorf("proto: TraceByIDRequest: wiretype end group for non-group")
+			return fmt.Errorf("proto: OverviewResponse: wiretype end group for non-group")
 		}
 		if fieldNum <= 0 {
-			return fmt.Errorf("proto: TraceByIDRequest: illegal tag %d (wire type %d)", fieldNum, wire)
+			return fmt.Errorf("proto: OverviewResponse: illegal tag %d (wire type %d)", fieldNum, wire)
 		}
 		switch fieldNum {
 		case 1:
 			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field TraceID", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field Services", wireType)
 			}
-			var byteLen int
+			var msglen int
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowTempo
@@ -708,26 +3253,45 @@ func (m *TraceByIDRequest) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				byteLen |= int(b&0x7F) << shift
+				msglen |= int(b&0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
-			if byteLen < 0 {
+			if msglen < 0 {
 				return ErrInvalidLengthTempo
 			}
-			postIndex := iNdEx + byteLen
+			postIndex := iNdEx + msglen
 			if postIndex < 0 {
 				return ErrInvalidLengthTempo
 			}
 			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			m.TraceID = append(m.TraceID[:0], dAtA[iNdEx:postIndex]...)
-			if m.TraceID == nil {
-				m.TraceID = []byte{}
+			m.Services = append(m.Services, &ServiceOverview{})
+			if err := m.Services[len(m.Services)-1].Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
 			}
 			iNdEx = postIndex
+		case 2:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field WindowSeconds", wireType)
+			}
+			m.WindowSeconds = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowTempo
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.WindowSeconds |= uint32(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
 		default:
 			iNdEx = preIndex
 			skippy, err := skipTempo(dAtA[iNdEx:])
@@ -752,7 +3316,7 @@ func (m *TraceByIDRequest) Unmarshal(dAtA []byte) error {
 	}
 	return nil
 }
-func (m *TraceByIDResponse) Unmarshal(dAtA []byte) error {
+func (m *ServiceOverview) Unmarshal(dAtA []byte) error {
 	l := len(dAtA)
 	iNdEx := 0
 	for iNdEx < l {
@@ -775,17 +3339,17 @@ func (m *TraceByIDResponse) Unmarshal(dAtA []byte) error {
 		fieldNum := int32(wire >> 3)
 		wireType := int(wire & 0x7)
 		if wireType == 4 {
-			return fmt.Errorf("proto: TraceByIDResponse: wiretype end group for non-group")
+			return fmt.Errorf("proto: ServiceOverview: wiretype end group for non-group")
 		}
 		if fieldNum <= 0 {
-			return fmt.Errorf("proto: TraceByIDResponse: illegal tag %d (wire type %d)", fieldNum, wire)
+			return fmt.Errorf("proto: ServiceOverview: illegal tag %d (wire type %d)", fieldNum, wire)
 		}
 		switch fieldNum {
 		case 1:
 			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Trace", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field Name", wireType)
 			}
-			var msglen int
+			var stringLen uint64
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowTempo
@@ -795,28 +3359,138 @@ func (m *TraceByIDResponse) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				msglen |= int(b&0x7F) << shift
+				stringLen |= uint64(b&0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
-			if msglen < 0 {
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
 				return ErrInvalidLengthTempo
 			}
-			postIndex := iNdEx + msglen
+			postIndex := iNdEx + intStringLen
 			if postIndex < 0 {
 				return ErrInvalidLengthTempo
 			}
 			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			if m.Trace == nil {
-				m.Trace = &Trace{}
+			m.Name = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 2:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Spans", wireType)
 			}
-			if err := m.Trace.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
-				return err
+			m.Spans = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowTempo
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.Spans |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 3:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Errors", wireType)
+			}
+			m.Errors = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowTempo
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.Errors |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 4:
+			if wireType == 0 {
+				var v uint64
+				for shift := uint(0); ; shift += 7 {
+					if shift >= 64 {
+						return ErrIntOverflowTempo
+					}
+					if iNdEx >= l {
+						return io.ErrUnexpectedEOF
+					}
+					b := dAtA[iNdEx]
+					iNdEx++
+					v |= uint64(b&0x7F) << shift
+					if b < 0x80 {
+						break
+					}
+				}
+				m.DurationBuckets = append(m.DurationBuckets, v)
+			} else if wireType == 2 {
+				var packedLen int
+				for shift := uint(0); ; shift += 7 {
+					if shift >= 64 {
+						return ErrIntOverflowTempo
+					}
+					if iNdEx >= l {
+						return io.ErrUnexpectedEOF
+					}
+					b := dAtA[iNdEx]
+					iNdEx++
+					packedLen |= int(b&0x7F) << shift
+					if b < 0x80 {
+						break
+					}
+				}
+				if packedLen < 0 {
+					return ErrInvalidLengthTempo
+				}
+				postIndex := iNdEx + packedLen
+				if postIndex < 0 {
+					return ErrInvalidLengthTempo
+				}
+				if postIndex > l {
+					return io.ErrUnexpectedEOF
+				}
+				var elementCount int
+				var count int
+				for _, integer := range dAtA[iNdEx:postIndex] {
+					if integer < 128 {
+						count++
+					}
+				}
+				elementCount = count
+				if elementCount != 0 && len(m.DurationBuckets) == 0 {
+					m.DurationBuckets = make([]uint64, 0, elementCount)
+				}
+				for iNdEx < postIndex {
+					var v uint64
+					for shift := uint(0); ; shift += 7 {
+						if shift >= 64 {
+							return ErrIntOverflowTempo
+						}
+						if iNdEx >= l {
+							return io.ErrUnexpectedEOF
+						}
+						b := dAtA[iNdEx]
+						iNdEx++
+						v |= uint64(b&0x7F) << shift
+						if b < 0x80 {
+							break
+						}
+					}
+					m.DurationBuckets = append(m.DurationBuckets, v)
+				}
+			} else {
+				return fmt.Errorf("proto: wrong wireType = %d for field DurationBuckets", wireType)
 			}
-			iNdEx = postIndex
 		default:
 			iNdEx = preIndex
 			skippy, err := skipTempo(dAtA[iNdEx:])
@@ -841,7 +3515,7 @@ func (m *TraceByIDResponse) Unmarshal(dAtA []byte) error {
 	}
 	return nil
 }
-func (m *Trace) Unmarshal(dAtA []byte) error {
+func (m *PushErrorDetails) Unmarshal(dAtA []byte) error {
 	l := len(dAtA)
 	iNdEx := 0
 	for iNdEx < l {
@@ -864,17 +3538,17 @@ func (m *Trace) Unmarshal(dAtA []byte) error {
 		fieldNum := int32(wire >> 3)
 		wireType := int(wire & 0x7)
 		if wireType == 4 {
-			return fmt.Errorf("proto: Trace: wiretype end group for non-group")
+			return fmt.Errorf("proto: PushErrorDetails: wiretype end group for non-group")
 		}
 		if fieldNum <= 0 {
-			return fmt.Errorf("proto: Trace: illegal tag %d (wire type %d)", fieldNum, wire)
+			return fmt.Errorf("proto: PushErrorDetails: illegal tag %d (wire type %d)", fieldNum, wire)
 		}
 		switch fieldNum {
 		case 1:
 			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Batches", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field Reason", wireType)
 			}
-			var msglen int
+			var stringLen uint64
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowTempo
@@ -884,26 +3558,77 @@ func (m *Trace) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				msglen |= int(b&0x7F) << shift
+				stringLen |= uint64(b&0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
-			if msglen < 0 {
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
 				return ErrInvalidLengthTempo
 			}
-			postIndex := iNdEx + msglen
+			postIndex := iNdEx + intStringLen
 			if postIndex < 0 {
 				return ErrInvalidLengthTempo
 			}
 			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			m.Batches = append(m.Batches, &v1.ResourceSpans{})
-			if err := m.Batches[len(m.Batches)-1].Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
-				return err
+			m.Reason = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 2:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field TraceID", wireType)
+			}
+			var byteLen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowTempo
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				byteLen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if byteLen < 0 {
+				return ErrInvalidLengthTempo
+			}
+			postIndex := iNdEx + byteLen
+			if postIndex < 0 {
+				return ErrInvalidLengthTempo
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.TraceID = append(m.TraceID[:0], dAtA[iNdEx:postIndex]...)
+			if m.TraceID == nil {
+				m.TraceID = []byte{}
 			}
 			iNdEx = postIndex
+		case 3:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Spans", wireType)
+			}
+			m.Spans = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowTempo
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.Spans |= uint32(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
 		default:
 			iNdEx = preIndex
 			skippy, err := skipTempo(dAtA[iNdEx:])
@@ -928,7 +3653,7 @@ func (m *Trace) Unmarshal(dAtA []byte) error {
 	}
 	return nil
 }
-func (m *PushRequest) Unmarshal(dAtA []byte) error {
+func (m *TailRequest) Unmarshal(dAtA []byte) error {
 	l := len(dAtA)
 	iNdEx := 0
 	for iNdEx < l {
@@ -951,15 +3676,15 @@ func (m *PushRequest) Unmarshal(dAtA []byte) error {
 		fieldNum := int32(wire >> 3)
 		wireType := int(wire & 0x7)
 		if wireType == 4 {
-			return fmt.Errorf("proto: PushRequest: wiretype end group for non-group")
+			return fmt.Errorf("proto: TailRequest: wiretype end group for non-group")
 		}
 		if fieldNum <= 0 {
-			return fmt.Errorf("proto: PushRequest: illegal tag %d (wire type %d)", fieldNum, wire)
+			return fmt.Errorf("proto: TailRequest: illegal tag %d (wire type %d)", fieldNum, wire)
 		}
 		switch fieldNum {
 		case 1:
 			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Batch", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field Tags", wireType)
 			}
 			var msglen int
 			for shift := uint(0); ; shift += 7 {
@@ -986,12 +3711,103 @@ func (m *PushRequest) Unmarshal(dAtA []byte) error {
 			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			if m.Batch == nil {
-				m.Batch = &v1.ResourceSpans{}
-			}
-			if err := m.Batch.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
-				return err
+			if m.Tags == nil {
+				m.Tags = make(map[string]string)
+			}
+			var mapkey string
+			var mapvalue string
+			for iNdEx < postIndex {
+				entryPreIndex := iNdEx
+				var wire uint64
+				for shift := uint(0); ; shift += 7 {
+					if shift >= 64 {
+						return ErrIntOverflowTempo
+					}
+					if iNdEx >= l {
+						return io.ErrUnexpectedEOF
+					}
+					b := dAtA[iNdEx]
+					iNdEx++
+					wire |= uint64(b&0x7F) << shift
+					if b < 0x80 {
+						break
+					}
+				}
+				fieldNum := int32(wire >> 3)
+				if fieldNum == 1 {
+					var stringLenmapkey uint64
+					for shift := uint(0); ; shift += 7 {
+						if shift >= 64 {
+							return ErrIntOverflowTempo
+						}
+						if iNdEx >= l {
+							return io.ErrUnexpectedEOF
+						}
+						b := dAtA[iNdEx]
+						iNdEx++
+						stringLenmapkey |= uint64(b&0x7F) << shift
+						if b < 0x80 {
+							break
+						}
+					}
+					intStringLenmapkey := int(stringLenmapkey)
+					if intStringLenmapkey < 0 {
+						return ErrInvalidLengthTempo
+					}
+					postStringIndexmapkey := iNdEx + intStringLenmapkey
+					if postStringIndexmapkey < 0 {
+						return ErrInvalidLengthTempo
+					}
+					if postStringIndexmapkey > l {
+						return io.ErrUnexpectedEOF
+					}
+					mapkey = string(dAtA[iNdEx:postStringIndexmapkey])
+					iNdEx = postStringIndexmapkey
+				} else if fieldNum == 2 {
+					var stringLenmapvalue uint64
+					for shift := uint(0); ; shift += 7 {
+						if shift >= 64 {
+							return ErrIntOverflowTempo
+						}
+						if iNdEx >= l {
+							return io.ErrUnexpectedEOF
+						}
+						b := dAtA[iNdEx]
+						iNdEx++
+						stringLenmapvalue |= uint64(b&0x7F) << shift
+						if b < 0x80 {
+							break
+						}
+					}
+					intStringLenmapvalue := int(stringLenmapvalue)
+					if intStringLenmapvalue < 0 {
+						return ErrInvalidLengthTempo
+					}
+					postStringIndexmapvalue := iNdEx + intStringLenmapvalue
+					if postStringIndexmapvalue < 0 {
+						return ErrInvalidLengthTempo
+					}
+					if postStringIndexmapvalue > l {
+						return io.ErrUnexpectedEOF
+					}
+					mapvalue = string(dAtA[iNdEx:postStringIndexmapvalue])
+					iNdEx = postStringIndexmapvalue
+				} else {
+					iNdEx = entryPreIndex
+					skippy, err := skipTempo(dAtA[iNdEx:])
+					if err != nil {
+						return err
+					}
+					if skippy < 0 {
+						return ErrInvalidLengthTempo
+					}
+					if (iNdEx + skippy) > postIndex {
+						return io.ErrUnexpectedEOF
+					}
+					iNdEx += skippy
+				}
 			}
+			m.Tags[mapkey] = mapvalue
 			iNdEx = postIndex
 		default:
 			iNdEx = preIndex
@@ -1017,7 +3833,7 @@ func (m *PushRequest) Unmarshal(dAtA []byte) error {
 	}
 	return nil
 }
-func (m *PushResponse) Unmarshal(dAtA []byte) error {
+func (m *TailResponse) Unmarshal(dAtA []byte) error {
 	l := len(dAtA)
 	iNdEx := 0
 	for iNdEx < l {
@@ -1040,12 +3856,65 @@ func (m *PushResponse) Unmarshal(dAtA []byte) error {
 		fieldNum := int32(wire >> 3)
 		wireType := int(wire & 0x7)
 		if wireType == 4 {
-			return fmt.Errorf("proto: PushResponse: wiretype end group for non-group")
+			return fmt.Errorf("proto: TailResponse: wiretype end group for non-group")
 		}
 		if fieldNum <= 0 {
-			return fmt.Errorf("proto: PushResponse: illegal tag %d (wire type %d)", fieldNum, wire)
+			return fmt.Errorf("proto: TailResponse: illegal tag %d (wire type %d)", fieldNum, wire)
 		}
 		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Batches", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowTempo
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthTempo
+			}
+			postIndex := iNdEx + msglen
+			if postIndex < 0 {
+				return ErrInvalidLengthTempo
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Batches = append(m.Batches, &v1.ResourceSpans{})
+			if err := m.Batches[len(m.Batches)-1].Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		case 2:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Dropped", wireType)
+			}
+			m.Dropped = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowTempo
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.Dropped |= uint32(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
 		default:
 			iNdEx = preIndex
 			skippy, err := skipTempo(dAtA[iNdEx:])
@@ -0,0 +1,291 @@
+package tenantmetrics
+
+import (
+	"flag"
+	"fmt"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const (
+	// OverflowTenant is the tenant label of the metrics of tenants seen after MaxTenants others.
+	OverflowTenant = "__overflow__"
+	// AggregatedTenant is the tenant label of metrics whose per tenant series are disabled.
+	AggregatedTenant = "__all__"
+
+	tenantLabel = "tenant"
+)
+
+var (
+	metricTrackedTenants = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: "tempo",
+		Name:      "tenant_metrics_tracked_tenants",
+		Help:      "The number of tenants that metrics are labelled with.",
+	})
+	metricOverflowed = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: "tempo",
+		Name:      "tenant_metrics_overflowed_total",
+		Help:      "The total number of metric updates of tenants over the max tenants, labelled with the overflow tenant.",
+	})
+)
+
+// Config limits the series of metrics labelled with tenants.
+type Config struct {
+	// MaxTenants is the number of tenants that metrics are labelled with.  Tenants seen after them are labelled with
+	// OverflowTenant.  0 labels every tenant.
+	MaxTenants int `yaml:"max_tenants"`
+	// DisabledMetrics are the metrics without per tenant series, which are labelled with AggregatedTenant.  Yaml only.
+	DisabledMetrics []string `yaml:"disabled_metrics"`
+}
+
+// RegisterFlags registers the flags for tenant metrics.
+func (cfg *Config) RegisterFlags(prefix string, f *flag.FlagSet) {
+	f.IntVar(&cfg.MaxTenants, prefix+".max-tenants", 0, "Number of tenants that metrics are labelled with. Metrics of later tenants are labelled with "+OverflowTenant+". 0 to label every tenant.")
+}
+
+// Validate returns an error if a disabled metric isn't labelled with tenants.
+func (cfg *Config) Validate() error {
+	if cfg.MaxTenants < 0 {
+		return fmt.Errorf("max_tenants: must not be negative, got %d", cfg.MaxTenants)
+	}
+
+	known.Lock()
+	defer known.Unlock()
+	for _, m := range cfg.DisabledMetrics {
+		if !known.metrics[m] {
+			return fmt.Errorf("disabled_metrics: %s isn't a metric labelled with tenants", m)
+		}
+	}
+	return nil
+}
+
+// discardedGauge is the unregistered gauge of the tenants whose gauges aren't exported.
+var discardedGauge = prometheus.NewGauge(prometheus.GaugeOpts{Name: "discarded"})
+
+// known are the metrics built by this package.
+var known = struct {
+	sync.Mutex
+	metrics map[string]bool
+}{metrics: map[string]bool{}}
+
+// Configure applies the config to the metrics of every module.  Tenants labelled so far are forgotten.
+func Configure(cfg Config) {
+	l := &labeler{
+		cfg:      cfg,
+		disabled: make(map[string]bool, len(cfg.DisabledMetrics)),
+		tenants:  map[string]struct{}{},
+	}
+	for _, m := range cfg.DisabledMetrics {
+		l.disabled[m] = true
+	}
+
+	current.Lock()
+	current.labeler = l
+	current.Unlock()
+	metricTrackedTenants.Set(0)
+}
+
+var current = struct {
+	sync.RWMutex
+	*labeler
+}{labeler: &labeler{}}
+
+func currentLabeler() *labeler {
+	current.RLock()
+	defer current.RUnlock()
+	return current.labeler
+}
+
+// labeler assigns tenant labels.  Tenants are labelled in the order they're first seen, so a tenant keeps its series
+// until the process restarts.
+type labeler struct {
+	cfg      Config
+	disabled map[string]bool
+
+	mtx     sync.RWMutex
+	tenants map[string]struct{}
+}
+
+// tenant returns the label of the tenant in the metric, and false if it isn't the tenant itself.  Unless track is set,
+// tenants that weren't seen yet aren't labelled with themselves.
+func (l *labeler) tenant(metric string, tenant string, track bool) (string, bool) {
+	if l.disabled[metric] {
+		return AggregatedTenant, false
+	}
+	if l.cfg.MaxTenants <= 0 {
+		return tenant, true
+	}
+
+	l.mtx.RLock()
+	_, ok := l.tenants[tenant]
+	l.mtx.RUnlock()
+	if ok {
+		return tenant, true
+	}
+	if !track {
+		return OverflowTenant, false
+	}
+
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+	if _, ok := l.tenants[tenant]; ok {
+		return tenant, true
+	}
+	if len(l.tenants) >= l.cfg.MaxTenants {
+		metricOverflowed.Inc()
+		return OverflowTenant, false
+	}
+	l.tenants[tenant] = struct{}{}
+	metricTrackedTenants.Set(float64(len(l.tenants)))
+	return tenant, true
+}
+
+// metric is the name and tenant label of a metric vector.
+type metric struct {
+	name   string
+	tenant int
+}
+
+func newMetric(namespace, subsystem, name string, labels []string) metric {
+	m := metric{name: prometheus.BuildFQName(namespace, subsystem, name), tenant: -1}
+	for i, l := range labels {
+		if l == tenantLabel {
+			m.tenant = i
+		}
+	}
+	if m.tenant < 0 {
+		panic(fmt.Sprintf("metric %s has no %s label", m.name, tenantLabel))
+	}
+
+	known.Lock()
+	known.metrics[m.name] = true
+	known.Unlock()
+	return m
+}
+
+// labelValues replaces the tenant of the label values with its label.  The label values are returned as is if the
+// tenant is labelled with itself.
+func (m metric) labelValues(lvs []string, track bool) ([]string, bool) {
+	if m.tenant >= len(lvs) {
+		return lvs, true
+	}
+	tenant, own := currentLabeler().tenant(m.name, lvs[m.tenant], track)
+	if own {
+		return lvs, true
+	}
+	replaced := append([]string(nil), lvs...)
+	replaced[m.tenant] = tenant
+	return replaced, false
+}
+
+// CounterVec is a prometheus.CounterVec whose tenant label is limited by the Config.  Counters of tenants over the
+// limit, or of a disabled metric, are summed in one series.
+type CounterVec struct {
+	*prometheus.CounterVec
+	metric metric
+}
+
+// NewCounterVec registers a CounterVec with the default registerer.  labels must hold a tenant label.
+func NewCounterVec(opts prometheus.CounterOpts, labels []string) *CounterVec {
+	return &CounterVec{
+		CounterVec: promauto.NewCounterVec(opts, labels),
+		metric:     newMetric(opts.Namespace, opts.Subsystem, opts.Name, labels),
+	}
+}
+
+// WithLabelValues implements prometheus.CounterVec.WithLabelValues.
+func (v *CounterVec) WithLabelValues(lvs ...string) prometheus.Counter {
+	lvs, _ = v.metric.labelValues(lvs, true)
+	return v.CounterVec.WithLabelValues(lvs...)
+}
+
+// DeleteLabelValues deletes the series of a tenant.  Shared series aren't deleted.
+func (v *CounterVec) DeleteLabelValues(lvs ...string) bool {
+	if _, own := v.metric.labelValues(lvs, false); !own {
+		return false
+	}
+	return v.CounterVec.DeleteLabelValues(lvs...)
+}
+
+// HistogramVec is a prometheus.HistogramVec whose tenant label is limited by the Config.  Observations of tenants over
+// the limit, or of a disabled metric, are recorded in one series.
+type HistogramVec struct {
+	*prometheus.HistogramVec
+	metric metric
+}
+
+// NewHistogramVec registers a HistogramVec with the default registerer.  labels must hold a tenant label.
+func NewHistogramVec(opts prometheus.HistogramOpts, labels []string) *HistogramVec {
+	return &HistogramVec{
+		HistogramVec: promauto.NewHistogramVec(opts, labels),
+		metric:       newMetric(opts.Namespace, opts.Subsystem, opts.Name, labels),
+	}
+}
+
+// WithLabelValues implements prometheus.HistogramVec.WithLabelValues.
+func (v *HistogramVec) WithLabelValues(lvs ...string) prometheus.Observer {
+	lvs, _ = v.metric.labelValues(lvs, true)
+	return v.HistogramVec.WithLabelValues(lvs...)
+}
+
+// DeleteLabelValues deletes the series of a tenant.  Shared series aren't deleted.
+func (v *HistogramVec) DeleteLabelValues(lvs ...string) bool {
+	if _, own := v.metric.labelValues(lvs, false); !own {
+		return false
+	}
+	return v.HistogramVec.DeleteLabelValues(lvs...)
+}
+
+// ConstMetric limits the tenants of a metric that a collector builds when it's collected.  Like gauges, the values of
+// different tenants can't be combined, so the series of tenants over the limit, or of a disabled metric, aren't
+// exported.
+type ConstMetric struct {
+	metric metric
+}
+
+// NewConstMetric returns the limits of the metric with the fully qualified name.  labels must hold a tenant label.
+func NewConstMetric(name string, labels []string) ConstMetric {
+	return ConstMetric{
+		metric: newMetric("", "", name, labels),
+	}
+}
+
+// Exported returns true if the collector may export series of the tenant.
+func (m ConstMetric) Exported(tenant string) bool {
+	_, own := currentLabeler().tenant(m.metric.name, tenant, true)
+	return own
+}
+
+// GaugeVec is a prometheus.GaugeVec whose tenant label is limited by the Config.  The values of different tenants can't
+// be combined, so gauges of tenants over the limit, or of a disabled metric, aren't exported.
+type GaugeVec struct {
+	*prometheus.GaugeVec
+	metric metric
+}
+
+// NewGaugeVec registers a GaugeVec with the default registerer.  labels must hold a tenant label.
+func NewGaugeVec(opts prometheus.GaugeOpts, labels []string) *GaugeVec {
+	return &GaugeVec{
+		GaugeVec: promauto.NewGaugeVec(opts, labels),
+		metric:   newMetric(opts.Namespace, opts.Subsystem, opts.Name, labels),
+	}
+}
+
+// WithLabelValues implements prometheus.GaugeVec.WithLabelValues.  The gauges of tenants that aren't exported are
+// discarded.
+func (v *GaugeVec) WithLabelValues(lvs ...string) prometheus.Gauge {
+	if _, own := v.metric.labelValues(lvs, true); !own {
+		return discardedGauge
+	}
+	return v.GaugeVec.WithLabelValues(lvs...)
+}
+
+// DeleteLabelValues deletes the series of a tenant.
+func (v *GaugeVec) DeleteLabelValues(lvs ...string) bool {
+	if _, own := v.metric.labelValues(lvs, false); !own {
+		return false
+	}
+	return v.GaugeVec.DeleteLabelValues(lvs...)
+}
@@ -0,0 +1,106 @@
+package tenantmetrics
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	testCounter = NewCounterVec(prometheus.CounterOpts{
+		Namespace: "tempo",
+		Name:      "tenant_metrics_test_total",
+	}, []string{"reason", "tenant"})
+	testGauge = NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "tempo",
+		Name:      "tenant_metrics_test",
+	}, []string{"tenant"})
+	testHistogram = NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "tempo",
+		Name:      "tenant_metrics_test_seconds",
+	}, []string{"tenant"})
+	testConst = NewConstMetric("tempo_tenant_metrics_test_const", []string{"tenant"})
+)
+
+// series returns the value of every series of the vector by its tenant label.
+func series(t *testing.T, c prometheus.Collector) map[string]float64 {
+	ch := make(chan prometheus.Metric, 100)
+	c.Collect(ch)
+	close(ch)
+
+	values := map[string]float64{}
+	for m := range ch {
+		var metric dto.Metric
+		require.NoError(t, m.Write(&metric))
+		for _, l := range metric.GetLabel() {
+			if l.GetName() == tenantLabel {
+				values[l.GetValue()] = metric.GetCounter().GetValue() + metric.GetGauge().GetValue() + float64(metric.GetHistogram().GetSampleCount())
+			}
+		}
+	}
+	return values
+}
+
+func TestMaxTenants(t *testing.T) {
+	Configure(Config{MaxTenants: 2})
+	defer Configure(Config{})
+	testCounter.Reset()
+	testGauge.Reset()
+	testHistogram.Reset()
+
+	for _, tenant := range []string{"a", "b", "c", "a", "d"} {
+		testCounter.WithLabelValues("reason", tenant).Inc()
+		testGauge.WithLabelValues(tenant).Set(5)
+		testHistogram.WithLabelValues(tenant).Observe(1)
+	}
+
+	assert.Equal(t, map[string]float64{"a": 2, "b": 1, OverflowTenant: 2}, series(t, testCounter))
+	assert.Equal(t, map[string]float64{"a": 2, "b": 1, OverflowTenant: 2}, series(t, testHistogram))
+	// gauges of different tenants can't be combined
+	assert.Equal(t, map[string]float64{"a": 5, "b": 5}, series(t, testGauge))
+
+	// the overflow series is shared
+	assert.False(t, testCounter.DeleteLabelValues("reason", "c"))
+	assert.True(t, testCounter.DeleteLabelValues("reason", "b"))
+	assert.Equal(t, map[string]float64{"a": 2, OverflowTenant: 2}, series(t, testCounter))
+}
+
+func TestDisabledMetrics(t *testing.T) {
+	cfg := Config{DisabledMetrics: []string{"tempo_tenant_metrics_test_total", "tempo_tenant_metrics_test"}}
+	require.NoError(t, cfg.Validate())
+	Configure(cfg)
+	defer Configure(Config{})
+	testCounter.Reset()
+	testGauge.Reset()
+	testHistogram.Reset()
+
+	for _, tenant := range []string{"a", "b"} {
+		testCounter.WithLabelValues("reason", tenant).Inc()
+		testGauge.WithLabelValues(tenant).Set(5)
+		testHistogram.WithLabelValues(tenant).Observe(1)
+	}
+
+	assert.Equal(t, map[string]float64{AggregatedTenant: 2}, series(t, testCounter))
+	assert.Empty(t, series(t, testGauge))
+	assert.Equal(t, map[string]float64{"a": 1, "b": 1}, series(t, testHistogram))
+
+	cfg.DisabledMetrics = []string{"tempo_not_a_metric"}
+	assert.EqualError(t, cfg.Validate(), "disabled_metrics: tempo_not_a_metric isn't a metric labelled with tenants")
+}
+
+func TestConstMetric(t *testing.T) {
+	Configure(Config{MaxTenants: 1})
+	defer Configure(Config{})
+
+	assert.True(t, testConst.Exported("a"))
+	assert.False(t, testConst.Exported("b"))
+	assert.True(t, testConst.Exported("a"))
+
+	cfg := Config{DisabledMetrics: []string{"tempo_tenant_metrics_test_const"}}
+	require.NoError(t, cfg.Validate())
+	Configure(cfg)
+	assert.False(t, testConst.Exported("a"))
+}
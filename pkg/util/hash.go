@@ -16,6 +16,13 @@ func TokenFor(userID string, b []byte) uint32 {
 	return h.Sum32()
 }
 
+// TokenForTenant generates a hashed value for a tenant.  It picks the ingesters of the tenant's shard.
+func TokenForTenant(userID string) uint32 {
+	h := fnv.New32()
+	_, _ = h.Write([]byte(userID))
+	return h.Sum32()
+}
+
 // TokenForTraceID generates a hashed value for a trace id
 func TokenForTraceID(b []byte) uint32 {
 	h := fnv.New32()
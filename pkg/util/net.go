@@ -0,0 +1,52 @@
+package util
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+)
+
+// UnixPrefix marks an address as the path of a unix domain socket, e.g. unix:/var/run/tempo/grpc.sock
+const UnixPrefix = "unix:"
+
+// ListenHost returns host in a form that can be joined with a port.  Bare IPv6 literals such as :: are bracketed.
+// Listening on [::] accepts both IPv4 and IPv6 connections on dual-stack hosts.
+func ListenHost(host string) string {
+	if strings.Contains(host, ":") && !strings.HasPrefix(host, "[") {
+		return "[" + host + "]"
+	}
+	return host
+}
+
+// ListenUnix listens on the unix domain socket at path.  A socket left behind by a previous process is removed first.
+func ListenUnix(path string) (net.Listener, error) {
+	if fi, err := os.Stat(path); err == nil {
+		if fi.Mode()&os.ModeSocket == 0 {
+			return nil, fmt.Errorf("%s exists and is not a socket", path)
+		}
+		if err := os.Remove(path); err != nil {
+			return nil, fmt.Errorf("failed to remove stale socket %s: %w", path, err)
+		}
+	}
+
+	return net.Listen("unix", path)
+}
+
+// DialContext dials addresses prefixed with UnixPrefix over a unix domain socket and all others over tcp.  Addresses
+// built by joining an unbracketed IPv6 host with a port, as ring addresses are, are split on their last colon.
+func DialContext(ctx context.Context, addr string) (net.Conn, error) {
+	var d net.Dialer
+	if strings.HasPrefix(addr, UnixPrefix) {
+		return d.DialContext(ctx, "unix", strings.TrimPrefix(addr, UnixPrefix))
+	}
+
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		if i := strings.LastIndex(addr, ":"); i > 0 {
+			addr = net.JoinHostPort(addr[:i], addr[i+1:])
+		}
+	}
+
+	return d.DialContext(ctx, "tcp", addr)
+}
@@ -0,0 +1,79 @@
+package util
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestListenHost(t *testing.T) {
+	assert.Equal(t, "", ListenHost(""))
+	assert.Equal(t, "0.0.0.0", ListenHost("0.0.0.0"))
+	assert.Equal(t, "[::]", ListenHost("::"))
+	assert.Equal(t, "[::1]", ListenHost("[::1]"))
+	assert.Equal(t, "[fe80::1]", ListenHost("fe80::1"))
+}
+
+func TestListenAndDialUnix(t *testing.T) {
+	dir, err := ioutil.TempDir("", "socket")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "grpc.sock")
+
+	// a stale socket is replaced
+	stale, err := net.Listen("unix", path)
+	require.NoError(t, err)
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	require.NoError(t, stale.Close())
+
+	l, err := ListenUnix(path)
+	require.NoError(t, err)
+	defer l.Close()
+	go func() {
+		conn, err := l.Accept()
+		if err == nil {
+			_, _ = conn.Write([]byte("ok"))
+			conn.Close()
+		}
+	}()
+
+	conn, err := DialContext(context.Background(), UnixPrefix+path)
+	require.NoError(t, err)
+	b, err := ioutil.ReadAll(conn)
+	require.NoError(t, err)
+	assert.Equal(t, "ok", string(b))
+	conn.Close()
+
+	// regular files are never removed
+	file := filepath.Join(dir, "file")
+	require.NoError(t, ioutil.WriteFile(file, nil, 0644))
+	_, err = ListenUnix(file)
+	assert.EqualError(t, err, file+" exists and is not a socket")
+}
+
+func TestDialIPv6(t *testing.T) {
+	l, err := net.Listen("tcp", "[::1]:0")
+	if err != nil {
+		t.Skip("ipv6 loopback not available")
+	}
+	defer l.Close()
+	go func() {
+		conn, err := l.Accept()
+		if err == nil {
+			conn.Close()
+		}
+	}()
+
+	// joined the way ring addresses are, without brackets
+	_, port, err := net.SplitHostPort(l.Addr().String())
+	require.NoError(t, err)
+	conn, err := DialContext(context.Background(), "::1:"+port)
+	require.NoError(t, err)
+	conn.Close()
+}
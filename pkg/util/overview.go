@@ -0,0 +1,88 @@
+package util
+
+import (
+	"time"
+
+	"github.com/grafana/tempo/pkg/tempopb"
+	v1_trace "github.com/open-telemetry/opentelemetry-proto/gen/go/trace/v1"
+)
+
+// OverviewDurationBounds are the upper bounds of the span duration buckets of tempopb.ServiceOverview, doubling from
+// 1ms.  The last bucket counts every span longer than the last bound.
+var OverviewDurationBounds = func() []time.Duration {
+	bounds := make([]time.Duration, 20)
+	for i := range bounds {
+		bounds[i] = time.Millisecond << i
+	}
+	return bounds
+}()
+
+// AddOverviewSpan counts the span in the service overview.  Spans with any status other than ok are errors.
+func AddOverviewSpan(o *tempopb.ServiceOverview, span *v1_trace.Span) {
+	if len(o.DurationBuckets) != len(OverviewDurationBounds)+1 {
+		o.DurationBuckets = make([]uint64, len(OverviewDurationBounds)+1)
+	}
+
+	o.Spans++
+	if span.Status != nil && span.Status.Code != v1_trace.Status_Ok {
+		o.Errors++
+	}
+
+	var duration time.Duration
+	if span.EndTimeUnixNano > span.StartTimeUnixNano {
+		duration = time.Duration(span.EndTimeUnixNano - span.StartTimeUnixNano)
+	}
+	bucket := len(OverviewDurationBounds)
+	for i, bound := range OverviewDurationBounds {
+		if duration <= bound {
+			bucket = i
+			break
+		}
+	}
+	o.DurationBuckets[bucket]++
+}
+
+// CombineServiceOverviews adds the counts of b to a.
+func CombineServiceOverviews(a *tempopb.ServiceOverview, b *tempopb.ServiceOverview) {
+	a.Spans += b.Spans
+	a.Errors += b.Errors
+	if len(a.DurationBuckets) < len(b.DurationBuckets) {
+		a.DurationBuckets = append(a.DurationBuckets, make([]uint64, len(b.DurationBuckets)-len(a.DurationBuckets))...)
+	}
+	for i, n := range b.DurationBuckets {
+		a.DurationBuckets[i] += n
+	}
+}
+
+// OverviewQuantile estimates the q quantile of the span durations of a service overview by interpolating within the
+// bucket it falls in.  Quantiles falling in the last bucket return the last bound.
+func OverviewQuantile(o *tempopb.ServiceOverview, q float64) time.Duration {
+	var total uint64
+	for _, n := range o.DurationBuckets {
+		total += n
+	}
+	if total == 0 {
+		return 0
+	}
+
+	rank := q * float64(total)
+	var seen uint64
+	for i, n := range o.DurationBuckets {
+		if n == 0 || float64(seen+n) < rank {
+			seen += n
+			continue
+		}
+		if i >= len(OverviewDurationBounds) {
+			break
+		}
+
+		var lower time.Duration
+		if i > 0 {
+			lower = OverviewDurationBounds[i-1]
+		}
+		upper := OverviewDurationBounds[i]
+		return lower + time.Duration(float64(upper-lower)*(rank-float64(seen))/float64(n))
+	}
+
+	return OverviewDurationBounds[len(OverviewDurationBounds)-1]
+}
@@ -0,0 +1,42 @@
+package util
+
+import (
+	"testing"
+	"time"
+
+	"github.com/grafana/tempo/pkg/tempopb"
+	v1_trace "github.com/open-telemetry/opentelemetry-proto/gen/go/trace/v1"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServiceOverview(t *testing.T) {
+	span := func(duration time.Duration, code v1_trace.Status_StatusCode) *v1_trace.Span {
+		return &v1_trace.Span{
+			StartTimeUnixNano: 1000,
+			EndTimeUnixNano:   1000 + uint64(duration),
+			Status:            &v1_trace.Status{Code: code},
+		}
+	}
+
+	a := &tempopb.ServiceOverview{Name: "svc"}
+	for i := 0; i < 98; i++ {
+		AddOverviewSpan(a, span(3*time.Millisecond, v1_trace.Status_Ok))
+	}
+	b := &tempopb.ServiceOverview{Name: "svc"}
+	AddOverviewSpan(b, span(100*time.Millisecond, v1_trace.Status_InternalError))
+	AddOverviewSpan(b, &v1_trace.Span{StartTimeUnixNano: 1000, EndTimeUnixNano: 1000 + uint64(time.Hour)})
+
+	CombineServiceOverviews(a, b)
+	assert.Equal(t, uint64(100), a.Spans)
+	assert.Equal(t, uint64(1), a.Errors)
+	assert.Equal(t, uint64(98), a.DurationBuckets[2])
+	assert.Equal(t, uint64(1), a.DurationBuckets[7])
+	assert.Equal(t, uint64(1), a.DurationBuckets[len(OverviewDurationBounds)])
+
+	// the median falls in the 2-4ms bucket and the p99 in the 64-128ms one
+	median := OverviewQuantile(a, .5)
+	assert.True(t, median > 2*time.Millisecond && median <= 4*time.Millisecond, median)
+	assert.Equal(t, 128*time.Millisecond, OverviewQuantile(a, .99))
+	assert.Equal(t, OverviewDurationBounds[len(OverviewDurationBounds)-1], OverviewQuantile(a, 1))
+	assert.Equal(t, time.Duration(0), OverviewQuantile(&tempopb.ServiceOverview{}, .99))
+}
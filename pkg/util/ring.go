@@ -0,0 +1,20 @@
+package util
+
+import (
+	"github.com/cortexproject/cortex/pkg/ring"
+)
+
+// TenantRing returns the ingesters a tenant's traces are spread across.  With a shard size of 0 that's every ingester
+// in r, otherwise the tenant is shuffle sharded onto a subring of shardSize ingesters picked by the tenant's token, so
+// a noisy tenant only affects the ingesters it shares a shard with.  Shards are never smaller than the replication
+// factor.
+func TenantRing(r ring.ReadRing, userID string, shardSize int) (ring.ReadRing, error) {
+	if shardSize <= 0 {
+		return r, nil
+	}
+	if rf := r.ReplicationFactor(); shardSize < rf {
+		shardSize = rf
+	}
+
+	return r.Subring(TokenForTenant(userID), shardSize)
+}
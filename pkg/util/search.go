@@ -0,0 +1,170 @@
+package util
+
+import (
+	"encoding/hex"
+	"strconv"
+	"time"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/grafana/tempo/pkg/tempopb"
+	"github.com/grafana/tempo/tempodb/encoding"
+	v1_common "github.com/open-telemetry/opentelemetry-proto/gen/go/common/v1"
+)
+
+const serviceNameAttribute = "service.name"
+
+// SearchEntry returns the search entry of a marshalled trace.
+func SearchEntry(obj []byte) (*encoding.SearchEntry, error) {
+	trace := &tempopb.Trace{}
+	err := proto.Unmarshal(obj, trace)
+	if err != nil {
+		return nil, err
+	}
+
+	return SearchEntryForTrace(trace), nil
+}
+
+// SearchEntryForTrace returns the tags, time range and root span of a trace.  Tags are built from resource and span
+// attributes with string, int or bool values.  Values longer than encoding.MaxSearchTagValueLength are skipped and
+// at most encoding.MaxSearchTagsPerTrace tags are recorded.
+func SearchEntryForTrace(trace *tempopb.Trace) *encoding.SearchEntry {
+	entry := &encoding.SearchEntry{}
+	seen := map[string]struct{}{}
+	addTags := func(attrs []*v1_common.KeyValue) {
+		for _, attr := range attrs {
+			if len(seen) >= encoding.MaxSearchTagsPerTrace {
+				return
+			}
+
+			value, ok := searchValue(attr.Value)
+			if !ok || len(value) > encoding.MaxSearchTagValueLength {
+				continue
+			}
+
+			tag := encoding.SearchTag(attr.Key, value)
+			if _, ok := seen[tag]; ok {
+				continue
+			}
+			seen[tag] = struct{}{}
+			entry.Tags = append(entry.Tags, tag)
+		}
+	}
+
+	for _, batch := range trace.Batches {
+		serviceName := ""
+		if batch.Resource != nil {
+			addTags(batch.Resource.Attributes)
+			for _, attr := range batch.Resource.Attributes {
+				if attr.Key == serviceNameAttribute {
+					serviceName = attr.Value.GetStringValue()
+				}
+			}
+		}
+
+		for _, ils := range batch.InstrumentationLibrarySpans {
+			for _, span := range ils.Spans {
+				addTags(span.Attributes)
+
+				if span.StartTimeUnixNano != 0 && (entry.StartTimeUnixNano == 0 || span.StartTimeUnixNano < entry.StartTimeUnixNano) {
+					entry.StartTimeUnixNano = span.StartTimeUnixNano
+				}
+				if span.EndTimeUnixNano > entry.EndTimeUnixNano {
+					entry.EndTimeUnixNano = span.EndTimeUnixNano
+				}
+				if len(span.ParentSpanId) == 0 && entry.RootSpanName == "" {
+					entry.RootServiceName = serviceName
+					entry.RootSpanName = span.Name
+				}
+			}
+		}
+	}
+
+	return entry
+}
+
+// SummarizeTrace returns the distinct services and span names of the trace, its number of spans and the earliest start
+// and latest end of its spans.
+func SummarizeTrace(t *tempopb.Trace) (services []string, operations []string, spans int, start uint64, end uint64) {
+	seenServices := map[string]struct{}{}
+	seenOperations := map[string]struct{}{}
+
+	for _, batch := range t.Batches {
+		if batch.Resource != nil {
+			for _, attr := range batch.Resource.Attributes {
+				if attr.Key != serviceNameAttribute {
+					continue
+				}
+				if name := attr.Value.GetStringValue(); name != "" {
+					if _, ok := seenServices[name]; !ok {
+						seenServices[name] = struct{}{}
+						services = append(services, name)
+					}
+				}
+			}
+		}
+
+		for _, ils := range batch.InstrumentationLibrarySpans {
+			spans += len(ils.Spans)
+			for _, span := range ils.Spans {
+				if _, ok := seenOperations[span.Name]; !ok {
+					seenOperations[span.Name] = struct{}{}
+					operations = append(operations, span.Name)
+				}
+				if span.StartTimeUnixNano != 0 && (start == 0 || span.StartTimeUnixNano < start) {
+					start = span.StartTimeUnixNano
+				}
+				if span.EndTimeUnixNano > end {
+					end = span.EndTimeUnixNano
+				}
+			}
+		}
+	}
+
+	return services, operations, spans, start, end
+}
+
+// SearchQueryFromRequest converts a search request to a query.
+func SearchQueryFromRequest(req *tempopb.SearchRequest) *encoding.SearchQuery {
+	q := &encoding.SearchQuery{
+		Tags:        req.Tags,
+		MinDuration: time.Duration(req.MinDurationMs) * time.Millisecond,
+		MaxDuration: time.Duration(req.MaxDurationMs) * time.Millisecond,
+		Limit:       int(req.Limit),
+		MostRecent:  req.MostRecent,
+	}
+	if req.Start != 0 {
+		q.Start = time.Unix(int64(req.Start), 0)
+	}
+	if req.End != 0 {
+		q.End = time.Unix(int64(req.End), 0)
+	}
+	return q
+}
+
+// SearchMetadata converts a trace found by a search to its response.
+func SearchMetadata(t *encoding.SearchTrace) *tempopb.TraceSearchMetadata {
+	return &tempopb.TraceSearchMetadata{
+		TraceID:           hex.EncodeToString(t.TraceID),
+		RootServiceName:   t.RootServiceName,
+		RootTraceName:     t.RootSpanName,
+		StartTimeUnixNano: t.StartTimeUnixNano,
+		DurationMs:        uint32(t.Duration() / time.Millisecond),
+	}
+}
+
+func searchValue(v *v1_common.AnyValue) (string, bool) {
+	if v == nil {
+		return "", false
+	}
+
+	switch val := v.Value.(type) {
+	case *v1_common.AnyValue_StringValue:
+		return val.StringValue, true
+	case *v1_common.AnyValue_IntValue:
+		return strconv.FormatInt(val.IntValue, 10), true
+	case *v1_common.AnyValue_BoolValue:
+		return strconv.FormatBool(val.BoolValue), true
+	}
+
+	return "", false
+}
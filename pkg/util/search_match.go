@@ -0,0 +1,365 @@
+package util
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"strconv"
+
+	"github.com/grafana/tempo/pkg/tempopb"
+	"github.com/grafana/tempo/tempodb/encoding"
+	v1_common "github.com/open-telemetry/opentelemetry-proto/gen/go/common/v1"
+)
+
+// Field numbers of the messages read by TraceMatcher.
+const (
+	fieldTraceBatches       = 1
+	fieldBatchResource      = 1
+	fieldBatchLibrarySpans  = 2
+	fieldResourceAttributes = 1
+	fieldLibrarySpansSpans  = 2
+	fieldSpanParentSpanID   = 4
+	fieldSpanName           = 5
+	fieldSpanStartTime      = 7
+	fieldSpanEndTime        = 8
+	fieldSpanAttributes     = 9
+	fieldKeyValueKey        = 1
+	fieldKeyValueValue      = 2
+	fieldAnyValueString     = 1
+	fieldAnyValueBool       = 2
+	fieldAnyValueInt        = 3
+)
+
+var (
+	errNoMatch        = errors.New("trace doesn't match")
+	errMalformedTrace = errors.New("malformed trace")
+)
+
+// TraceMatcher is an encoding.ObjectMatcher for objects that are marshalled tempopb.Traces.  Objects that can't hold
+// the query's tags are skipped without being decoded.  Otherwise the trace is walked without unmarshalling it, and
+// attributes are no longer read once every tag was found.  Tags are matched like the tags of search data, except that
+// they aren't bounded by encoding.MaxSearchTagsPerTrace.
+type TraceMatcher struct{}
+
+// MatchObject implements encoding.ObjectMatcher.
+func (TraceMatcher) MatchObject(id encoding.ID, obj []byte, q *encoding.SearchQuery) (*encoding.SearchTrace, error) {
+	if !mayContainTags(obj, q.Tags) {
+		return nil, nil
+	}
+
+	m := newTraceMatch(q)
+	err := protoFields(obj, func(field uint64, _ uint64, value []byte) error {
+		if field == fieldTraceBatches {
+			return m.batch(value)
+		}
+		return nil
+	})
+	if err == errNoMatch {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return m.result(id, q), nil
+}
+
+// MatchTrace returns the search trace of a trace if it matches the query, and nil otherwise.  Like TraceMatcher it
+// stops reading attributes once every tag was found, instead of building the trace's search entry.
+func MatchTrace(id encoding.ID, trace *tempopb.Trace, q *encoding.SearchQuery) *encoding.SearchTrace {
+	m := newTraceMatch(q)
+	for _, batch := range trace.Batches {
+		serviceName := ""
+		if batch.Resource != nil {
+			for _, attr := range batch.Resource.Attributes {
+				if attr.Key == serviceNameAttribute {
+					serviceName = attr.Value.GetStringValue()
+				}
+			}
+			m.attributes(batch.Resource.Attributes)
+		}
+
+		for _, ils := range batch.InstrumentationLibrarySpans {
+			for _, span := range ils.Spans {
+				m.attributes(span.Attributes)
+				if m.span(len(span.ParentSpanId) == 0, span.Name, span.StartTimeUnixNano, span.EndTimeUnixNano, serviceName) != nil {
+					return nil
+				}
+			}
+		}
+	}
+
+	return m.result(id, q)
+}
+
+// traceMatch is the state of a query evaluated against a trace.
+type traceMatch struct {
+	remaining   map[string]string // tags not found yet
+	maxDuration uint64
+
+	start           uint64
+	end             uint64
+	rootServiceName string
+	rootSpanName    string
+}
+
+func newTraceMatch(q *encoding.SearchQuery) *traceMatch {
+	m := &traceMatch{
+		remaining:   make(map[string]string, len(q.Tags)),
+		maxDuration: uint64(q.MaxDuration),
+	}
+	for k, v := range q.Tags {
+		m.remaining[k] = v
+	}
+	return m
+}
+
+// tag marks the tag of an attribute as found.
+func (m *traceMatch) tag(key string, value string) {
+	if want, ok := m.remaining[key]; ok && want == value {
+		delete(m.remaining, key)
+	}
+}
+
+func (m *traceMatch) attributes(attrs []*v1_common.KeyValue) {
+	for _, attr := range attrs {
+		if len(m.remaining) == 0 {
+			return
+		}
+		if _, ok := m.remaining[attr.Key]; !ok {
+			continue
+		}
+		if value, ok := searchValue(attr.Value); ok && len(value) <= encoding.MaxSearchTagValueLength {
+			m.tag(attr.Key, value)
+		}
+	}
+}
+
+// span records the time range and name of a span.  The duration of the trace only grows with its spans, so errNoMatch
+// is returned as soon as it's past the query's max duration.
+func (m *traceMatch) span(root bool, name string, start uint64, end uint64, serviceName string) error {
+	if start != 0 && (m.start == 0 || start < m.start) {
+		m.start = start
+	}
+	if end > m.end {
+		m.end = end
+	}
+	if root && m.rootSpanName == "" {
+		m.rootServiceName = serviceName
+		m.rootSpanName = name
+	}
+
+	if m.maxDuration > 0 && m.end > m.start && m.end-m.start > m.maxDuration {
+		return errNoMatch
+	}
+	return nil
+}
+
+func (m *traceMatch) result(id encoding.ID, q *encoding.SearchQuery) *encoding.SearchTrace {
+	if len(m.remaining) > 0 {
+		return nil
+	}
+
+	t := &encoding.SearchTrace{
+		TraceID:           append(encoding.ID(nil), id...),
+		StartTimeUnixNano: m.start,
+		EndTimeUnixNano:   m.end,
+		RootServiceName:   m.rootServiceName,
+		RootSpanName:      m.rootSpanName,
+	}
+	if !q.Matches(t) {
+		return nil
+	}
+	return t
+}
+
+// batch walks a marshalled ResourceSpans.  Spans are walked after the resource so their root service is known.
+func (m *traceMatch) batch(b []byte) error {
+	serviceName := ""
+	var librarySpans [][]byte
+	err := protoFields(b, func(field uint64, _ uint64, value []byte) error {
+		switch field {
+		case fieldBatchResource:
+			return protoFields(value, func(field uint64, _ uint64, value []byte) error {
+				if field != fieldResourceAttributes {
+					return nil
+				}
+				key, v, ok := m.attribute(value, serviceNameAttribute)
+				if ok && key == serviceNameAttribute {
+					serviceName = v
+				}
+				return nil
+			})
+		case fieldBatchLibrarySpans:
+			librarySpans = append(librarySpans, value)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, ils := range librarySpans {
+		err = protoFields(ils, func(field uint64, _ uint64, value []byte) error {
+			if field != fieldLibrarySpansSpans {
+				return nil
+			}
+			return m.marshalledSpan(value, serviceName)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *traceMatch) marshalledSpan(b []byte, serviceName string) error {
+	var (
+		root       = true
+		name       []byte
+		start, end uint64
+	)
+	err := protoFields(b, func(field uint64, num uint64, value []byte) error {
+		switch field {
+		case fieldSpanParentSpanID:
+			root = len(value) == 0
+		case fieldSpanName:
+			name = value
+		case fieldSpanStartTime:
+			start = num
+		case fieldSpanEndTime:
+			end = num
+		case fieldSpanAttributes:
+			if len(m.remaining) > 0 {
+				m.attribute(value, "")
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// only the name of the root span is kept
+	rootName := ""
+	if root && m.rootSpanName == "" {
+		rootName = string(name)
+	}
+	return m.span(root, rootName, start, end, serviceName)
+}
+
+// attribute marks the tag of a marshalled KeyValue as found.  The value is only decoded if the key is one of the tags
+// not found yet or is also wanted, and is returned with its key.
+func (m *traceMatch) attribute(b []byte, also string) (string, string, bool) {
+	var key, value []byte
+	_ = protoFields(b, func(field uint64, _ uint64, v []byte) error {
+		switch field {
+		case fieldKeyValueKey:
+			key = v
+		case fieldKeyValueValue:
+			value = v
+		}
+		return nil
+	})
+
+	_, wanted := m.remaining[string(key)]
+	if !wanted && (also == "" || string(key) != also) {
+		return "", "", false
+	}
+
+	v, ok := marshalledSearchValue(value)
+	if !ok || len(v) > encoding.MaxSearchTagValueLength {
+		return "", "", false
+	}
+	if wanted {
+		m.tag(string(key), v)
+	}
+	return string(key), v, true
+}
+
+// marshalledSearchValue is searchValue for a marshalled AnyValue.
+func marshalledSearchValue(b []byte) (string, bool) {
+	var value string
+	ok := false
+	_ = protoFields(b, func(field uint64, num uint64, v []byte) error {
+		switch field {
+		case fieldAnyValueString:
+			value, ok = string(v), true
+		case fieldAnyValueBool:
+			value, ok = strconv.FormatBool(num != 0), true
+		case fieldAnyValueInt:
+			value, ok = strconv.FormatInt(int64(num), 10), true
+		}
+		return nil
+	})
+	return value, ok
+}
+
+// mayContainTags returns false if the marshalled trace can't hold the tags.  Keys and string values are written as is,
+// so a trace missing their bytes doesn't have the tags.  Values that may be ints or bools aren't checked.
+func mayContainTags(obj []byte, tags map[string]string) bool {
+	for k, v := range tags {
+		if !bytes.Contains(obj, []byte(k)) {
+			return false
+		}
+		if _, err := strconv.ParseInt(v, 10, 64); err == nil {
+			continue
+		}
+		if _, err := strconv.ParseBool(v); err == nil {
+			continue
+		}
+		if !bytes.Contains(obj, []byte(v)) {
+			return false
+		}
+	}
+	return true
+}
+
+// protoFields calls fn with every field of the marshalled message b, in order.  Varint and fixed size fields are
+// passed as num and length delimited fields as value.  Errors returned by fn stop the walk and are returned.
+func protoFields(b []byte, fn func(field uint64, num uint64, value []byte) error) error {
+	for len(b) > 0 {
+		tag, n := binary.Uvarint(b)
+		if n <= 0 {
+			return errMalformedTrace
+		}
+		b = b[n:]
+
+		var num uint64
+		var value []byte
+		switch tag & 7 {
+		case 0: // varint
+			num, n = binary.Uvarint(b)
+			if n <= 0 {
+				return errMalformedTrace
+			}
+			b = b[n:]
+		case 1: // fixed64
+			if len(b) < 8 {
+				return errMalformedTrace
+			}
+			num = binary.LittleEndian.Uint64(b)
+			b = b[8:]
+		case 2: // length delimited
+			length, n := binary.Uvarint(b)
+			if n <= 0 || length > uint64(len(b)-n) {
+				return errMalformedTrace
+			}
+			value = b[n : n+int(length)]
+			b = b[n+int(length):]
+		case 5: // fixed32
+			if len(b) < 4 {
+				return errMalformedTrace
+			}
+			num = uint64(binary.LittleEndian.Uint32(b))
+			b = b[4:]
+		default:
+			return errMalformedTrace
+		}
+
+		if err := fn(tag>>3, num, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
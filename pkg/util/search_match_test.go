@@ -0,0 +1,119 @@
+package util
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/grafana/tempo/pkg/tempopb"
+	"github.com/grafana/tempo/tempodb/encoding"
+	v1_common "github.com/open-telemetry/opentelemetry-proto/gen/go/common/v1"
+	v1_resource "github.com/open-telemetry/opentelemetry-proto/gen/go/resource/v1"
+	v1_trace "github.com/open-telemetry/opentelemetry-proto/gen/go/trace/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTraceMatcher(t *testing.T) {
+	stringValue := func(s string) *v1_common.AnyValue {
+		return &v1_common.AnyValue{Value: &v1_common.AnyValue_StringValue{StringValue: s}}
+	}
+
+	trace := &tempopb.Trace{
+		Batches: []*v1_trace.ResourceSpans{
+			{
+				Resource: &v1_resource.Resource{
+					Attributes: []*v1_common.KeyValue{
+						{Key: "service.name", Value: stringValue("child")},
+					},
+				},
+				InstrumentationLibrarySpans: []*v1_trace.InstrumentationLibrarySpans{
+					{
+						Spans: []*v1_trace.Span{
+							{
+								Name:              "child-span",
+								ParentSpanId:      []byte{0x01},
+								StartTimeUnixNano: uint64(20 * time.Millisecond),
+								EndTimeUnixNano:   uint64(300 * time.Millisecond),
+								Attributes: []*v1_common.KeyValue{
+									{Key: "http.status_code", Value: &v1_common.AnyValue{Value: &v1_common.AnyValue_IntValue{IntValue: 500}}},
+									{Key: "error", Value: &v1_common.AnyValue{Value: &v1_common.AnyValue_BoolValue{BoolValue: true}}},
+									{Key: "long", Value: stringValue(strings.Repeat("a", encoding.MaxSearchTagValueLength+1))},
+								},
+							},
+						},
+					},
+				},
+			},
+			{
+				Resource: &v1_resource.Resource{
+					Attributes: []*v1_common.KeyValue{
+						{Key: "service.name", Value: stringValue("root")},
+					},
+				},
+				InstrumentationLibrarySpans: []*v1_trace.InstrumentationLibrarySpans{
+					{
+						Spans: []*v1_trace.Span{
+							{
+								Name:              "root-span",
+								StartTimeUnixNano: uint64(10 * time.Millisecond),
+								EndTimeUnixNano:   uint64(200 * time.Millisecond),
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+	obj, err := proto.Marshal(trace)
+	require.NoError(t, err)
+	id := encoding.ID{0x01, 0x02}
+
+	expected := &encoding.SearchTrace{
+		TraceID:           id,
+		StartTimeUnixNano: uint64(10 * time.Millisecond),
+		EndTimeUnixNano:   uint64(300 * time.Millisecond),
+		RootServiceName:   "root",
+		RootSpanName:      "root-span",
+	}
+
+	tests := []struct {
+		name    string
+		q       *encoding.SearchQuery
+		matches bool
+	}{
+		{name: "no tags", q: &encoding.SearchQuery{}, matches: true},
+		{name: "resource and span tags", q: &encoding.SearchQuery{Tags: map[string]string{"service.name": "child", "http.status_code": "500", "error": "true"}}, matches: true},
+		{name: "tags of different spans", q: &encoding.SearchQuery{Tags: map[string]string{"service.name": "root", "http.status_code": "500"}}, matches: true},
+		{name: "wrong value", q: &encoding.SearchQuery{Tags: map[string]string{"service.name": "other"}}},
+		{name: "wrong int value", q: &encoding.SearchQuery{Tags: map[string]string{"http.status_code": "404"}}},
+		{name: "missing key", q: &encoding.SearchQuery{Tags: map[string]string{"missing": "true"}}},
+		{name: "long value", q: &encoding.SearchQuery{Tags: map[string]string{"long": strings.Repeat("a", encoding.MaxSearchTagValueLength+1)}}},
+		{name: "in duration", q: &encoding.SearchQuery{MinDuration: 200 * time.Millisecond, MaxDuration: 300 * time.Millisecond}, matches: true},
+		{name: "above max duration", q: &encoding.SearchQuery{MaxDuration: 100 * time.Millisecond}},
+		{name: "below min duration", q: &encoding.SearchQuery{MinDuration: time.Second}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// the indexed search data finds the same traces
+			d := encoding.NewSearchData()
+			d.Add(id, SearchEntryForTrace(trace))
+			assert.Equal(t, tt.matches, len(d.Search(tt.q)) == 1)
+
+			found, err := TraceMatcher{}.MatchObject(id, obj, tt.q)
+			require.NoError(t, err)
+			if tt.matches {
+				assert.Equal(t, expected, found)
+				assert.Equal(t, expected, MatchTrace(id, trace, tt.q))
+			} else {
+				assert.Nil(t, found)
+				assert.Nil(t, MatchTrace(id, trace, tt.q))
+			}
+		})
+	}
+
+	_, err = TraceMatcher{}.MatchObject(id, obj[:len(obj)-1], &encoding.SearchQuery{})
+	assert.Error(t, err)
+}
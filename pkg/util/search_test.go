@@ -0,0 +1,145 @@
+package util
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/grafana/tempo/pkg/tempopb"
+	"github.com/grafana/tempo/tempodb/encoding"
+	v1_common "github.com/open-telemetry/opentelemetry-proto/gen/go/common/v1"
+	v1_resource "github.com/open-telemetry/opentelemetry-proto/gen/go/resource/v1"
+	v1_trace "github.com/open-telemetry/opentelemetry-proto/gen/go/trace/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSearchEntry(t *testing.T) {
+	stringValue := func(s string) *v1_common.AnyValue {
+		return &v1_common.AnyValue{Value: &v1_common.AnyValue_StringValue{StringValue: s}}
+	}
+
+	trace := &tempopb.Trace{
+		Batches: []*v1_trace.ResourceSpans{
+			{
+				Resource: &v1_resource.Resource{
+					Attributes: []*v1_common.KeyValue{
+						{Key: "service.name", Value: stringValue("child")},
+					},
+				},
+				InstrumentationLibrarySpans: []*v1_trace.InstrumentationLibrarySpans{
+					{
+						Spans: []*v1_trace.Span{
+							{
+								Name:              "child-span",
+								ParentSpanId:      []byte{0x01},
+								StartTimeUnixNano: 20,
+								EndTimeUnixNano:   300,
+								Attributes: []*v1_common.KeyValue{
+									{Key: "http.status_code", Value: &v1_common.AnyValue{Value: &v1_common.AnyValue_IntValue{IntValue: 500}}},
+									{Key: "error", Value: &v1_common.AnyValue{Value: &v1_common.AnyValue_BoolValue{BoolValue: true}}},
+									{Key: "ratio", Value: &v1_common.AnyValue{Value: &v1_common.AnyValue_DoubleValue{DoubleValue: 0.5}}},
+									{Key: "long", Value: stringValue(strings.Repeat("a", encoding.MaxSearchTagValueLength+1))},
+								},
+							},
+						},
+					},
+				},
+			},
+			{
+				Resource: &v1_resource.Resource{
+					Attributes: []*v1_common.KeyValue{
+						{Key: "service.name", Value: stringValue("root")},
+					},
+				},
+				InstrumentationLibrarySpans: []*v1_trace.InstrumentationLibrarySpans{
+					{
+						Spans: []*v1_trace.Span{
+							{
+								Name:              "root-span",
+								StartTimeUnixNano: 10,
+								EndTimeUnixNano:   200,
+								Attributes: []*v1_common.KeyValue{
+									{Key: "error", Value: &v1_common.AnyValue{Value: &v1_common.AnyValue_BoolValue{BoolValue: true}}},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	b, err := proto.Marshal(trace)
+	require.NoError(t, err)
+
+	entry, err := SearchEntry(b)
+	require.NoError(t, err)
+
+	assert.Equal(t, &encoding.SearchEntry{
+		Tags:              []string{"service.name=child", "http.status_code=500", "error=true", "service.name=root"},
+		StartTimeUnixNano: 10,
+		EndTimeUnixNano:   300,
+		RootServiceName:   "root",
+		RootSpanName:      "root-span",
+	}, entry)
+
+	_, err = SearchEntry([]byte{0x01})
+	assert.Error(t, err)
+}
+
+func TestSearchQueryFromRequest(t *testing.T) {
+	q := SearchQueryFromRequest(&tempopb.SearchRequest{
+		Tags:          map[string]string{"service.name": "foo"},
+		MinDurationMs: 2000,
+		Limit:         10,
+		End:           100,
+	})
+
+	assert.Equal(t, map[string]string{"service.name": "foo"}, q.Tags)
+	assert.Equal(t, "2s", q.MinDuration.String())
+	assert.Zero(t, q.MaxDuration)
+	assert.Equal(t, 10, q.Limit)
+	assert.True(t, q.Start.IsZero())
+	assert.Equal(t, int64(100), q.End.Unix())
+}
+
+func TestSummarizeTrace(t *testing.T) {
+	service := func(name string) *v1_resource.Resource {
+		return &v1_resource.Resource{
+			Attributes: []*v1_common.KeyValue{{
+				Key:   serviceNameAttribute,
+				Value: &v1_common.AnyValue{Value: &v1_common.AnyValue_StringValue{StringValue: name}},
+			}},
+		}
+	}
+
+	trace := &tempopb.Trace{
+		Batches: []*v1_trace.ResourceSpans{
+			{
+				Resource: service("frontend"),
+				InstrumentationLibrarySpans: []*v1_trace.InstrumentationLibrarySpans{{
+					Spans: []*v1_trace.Span{{Name: "GET /", StartTimeUnixNano: 200, EndTimeUnixNano: 500}, {Name: "render"}},
+				}},
+			},
+			{
+				Resource: service("frontend"),
+				InstrumentationLibrarySpans: []*v1_trace.InstrumentationLibrarySpans{{
+					Spans: []*v1_trace.Span{{Name: "GET /"}},
+				}},
+			},
+			{
+				InstrumentationLibrarySpans: []*v1_trace.InstrumentationLibrarySpans{{
+					Spans: []*v1_trace.Span{{Name: "SELECT", StartTimeUnixNano: 100, EndTimeUnixNano: 300}},
+				}},
+			},
+		},
+	}
+
+	services, operations, spans, start, end := SummarizeTrace(trace)
+	assert.Equal(t, []string{"frontend"}, services)
+	assert.Equal(t, []string{"GET /", "render", "SELECT"}, operations)
+	assert.Equal(t, 4, spans)
+	assert.Equal(t, uint64(100), start)
+	assert.Equal(t, uint64(500), end)
+}
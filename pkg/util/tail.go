@@ -0,0 +1,88 @@
+package util
+
+import (
+	v1_common "github.com/open-telemetry/opentelemetry-proto/gen/go/common/v1"
+	v1_trace "github.com/open-telemetry/opentelemetry-proto/gen/go/trace/v1"
+)
+
+// MatchingSpans returns the spans of the batch whose span or resource attributes have all of the tags, in a batch with
+// the resource of batch, and the number of spans returned.  Values are matched like the tags of searches.  It returns
+// nil if no span matches.  The spans aren't copied.
+func MatchingSpans(batch *v1_trace.ResourceSpans, tags map[string]string) (*v1_trace.ResourceSpans, int) {
+	if len(tags) == 0 {
+		return batch, countSpans(batch)
+	}
+
+	// tags found on the resource hold for every span
+	remaining := make(map[string]string, len(tags))
+	for k, v := range tags {
+		remaining[k] = v
+	}
+	if batch.Resource != nil {
+		removeMatches(remaining, batch.Resource.Attributes)
+	}
+
+	var matched *v1_trace.ResourceSpans
+	count := 0
+	for _, ils := range batch.InstrumentationLibrarySpans {
+		var spans []*v1_trace.Span
+		for _, span := range ils.Spans {
+			if hasTags(span.Attributes, remaining) {
+				spans = append(spans, span)
+			}
+		}
+		if len(spans) == 0 {
+			continue
+		}
+
+		if matched == nil {
+			matched = &v1_trace.ResourceSpans{Resource: batch.Resource}
+		}
+		matched.InstrumentationLibrarySpans = append(matched.InstrumentationLibrarySpans, &v1_trace.InstrumentationLibrarySpans{
+			InstrumentationLibrary: ils.InstrumentationLibrary,
+			Spans:                  spans,
+		})
+		count += len(spans)
+	}
+	return matched, count
+}
+
+// hasTags returns true if the attributes have all of the tags.
+func hasTags(attrs []*v1_common.KeyValue, tags map[string]string) bool {
+	for k, want := range tags {
+		found := false
+		for _, attr := range attrs {
+			if attr.Key != k {
+				continue
+			}
+			if v, ok := searchValue(attr.Value); ok && v == want {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+func removeMatches(tags map[string]string, attrs []*v1_common.KeyValue) {
+	for _, attr := range attrs {
+		want, ok := tags[attr.Key]
+		if !ok {
+			continue
+		}
+		if v, ok := searchValue(attr.Value); ok && v == want {
+			delete(tags, attr.Key)
+		}
+	}
+}
+
+func countSpans(batch *v1_trace.ResourceSpans) int {
+	count := 0
+	for _, ils := range batch.InstrumentationLibrarySpans {
+		count += len(ils.Spans)
+	}
+	return count
+}
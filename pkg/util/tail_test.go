@@ -0,0 +1,55 @@
+package util
+
+import (
+	"testing"
+
+	v1_common "github.com/open-telemetry/opentelemetry-proto/gen/go/common/v1"
+	v1_resource "github.com/open-telemetry/opentelemetry-proto/gen/go/resource/v1"
+	v1_trace "github.com/open-telemetry/opentelemetry-proto/gen/go/trace/v1"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMatchingSpans(t *testing.T) {
+	stringValue := func(s string) *v1_common.AnyValue {
+		return &v1_common.AnyValue{Value: &v1_common.AnyValue_StringValue{StringValue: s}}
+	}
+	failed := &v1_trace.Span{
+		Name: "failed",
+		Attributes: []*v1_common.KeyValue{
+			{Key: "http.status_code", Value: &v1_common.AnyValue{Value: &v1_common.AnyValue_IntValue{IntValue: 500}}},
+		},
+	}
+	ok := &v1_trace.Span{
+		Name: "ok",
+		Attributes: []*v1_common.KeyValue{
+			{Key: "http.status_code", Value: &v1_common.AnyValue{Value: &v1_common.AnyValue_IntValue{IntValue: 200}}},
+		},
+	}
+	batch := &v1_trace.ResourceSpans{
+		Resource: &v1_resource.Resource{
+			Attributes: []*v1_common.KeyValue{{Key: "service.name", Value: stringValue("api")}},
+		},
+		InstrumentationLibrarySpans: []*v1_trace.InstrumentationLibrarySpans{
+			{Spans: []*v1_trace.Span{ok}},
+			{Spans: []*v1_trace.Span{failed, ok}},
+		},
+	}
+
+	matched, count := MatchingSpans(batch, nil)
+	assert.Equal(t, batch, matched)
+	assert.Equal(t, 3, count)
+
+	matched, count = MatchingSpans(batch, map[string]string{"service.name": "api"})
+	assert.Equal(t, 3, count)
+	assert.Len(t, matched.InstrumentationLibrarySpans, 2)
+
+	// resource and span tags
+	matched, count = MatchingSpans(batch, map[string]string{"service.name": "api", "http.status_code": "500"})
+	assert.Equal(t, 1, count)
+	assert.Equal(t, batch.Resource, matched.Resource)
+	assert.Equal(t, []*v1_trace.InstrumentationLibrarySpans{{Spans: []*v1_trace.Span{failed}}}, matched.InstrumentationLibrarySpans)
+
+	matched, count = MatchingSpans(batch, map[string]string{"service.name": "web"})
+	assert.Nil(t, matched)
+	assert.Equal(t, 0, count)
+}
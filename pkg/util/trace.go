@@ -2,6 +2,7 @@ package util
 
 import (
 	"hash/fnv"
+	"io"
 
 	"github.com/cortexproject/cortex/pkg/util"
 	"github.com/go-kit/kit/log/level"
@@ -110,3 +111,154 @@ func CombineTraceProtos(traceA, traceB *tempopb.Trace) *tempopb.Trace {
 
 	return traceA
 }
+
+// TraceCombiner merges the partial traces of a trace returned by several ingesters, block gateways or query shards.
+// Unlike CombineTraceProtos spans are matched on their full span id and repeats within a single partial trace are
+// removed too, so a trace replicated to several ingesters is returned with each span once.  Spans without a span id
+// can't be matched and are always kept.  The zero value is ready to use.
+type TraceCombiner struct {
+	trace          *tempopb.Trace
+	spanIDs        map[string]struct{}
+	combinedFrom   int
+	duplicateSpans int
+}
+
+// Combine adds a partial trace.  It is destructive: the batches of trace are moved into the combined trace.  nil and
+// empty traces are ignored.
+func (c *TraceCombiner) Combine(trace *tempopb.Trace) {
+	if trace == nil || len(trace.Batches) == 0 {
+		return
+	}
+	if c.spanIDs == nil {
+		c.spanIDs = make(map[string]struct{})
+	}
+	if c.trace == nil {
+		c.trace = &tempopb.Trace{}
+	}
+	c.combinedFrom++
+
+	for _, batch := range trace.Batches {
+		newILS := batch.InstrumentationLibrarySpans[:0]
+		for _, ils := range batch.InstrumentationLibrarySpans {
+			newSpans := ils.Spans[:0]
+			for _, span := range ils.Spans {
+				if len(span.SpanId) > 0 {
+					if _, ok := c.spanIDs[string(span.SpanId)]; ok {
+						c.duplicateSpans++
+						continue
+					}
+					c.spanIDs[string(span.SpanId)] = struct{}{}
+				}
+				newSpans = append(newSpans, span)
+			}
+
+			if len(newSpans) > 0 {
+				ils.Spans = newSpans
+				newILS = append(newILS, ils)
+			}
+		}
+
+		if len(newILS) > 0 {
+			batch.InstrumentationLibrarySpans = newILS
+			c.trace.Batches = append(c.trace.Batches, batch)
+		}
+	}
+}
+
+// Result returns the combined trace.  It's nil if nothing was combined.
+func (c *TraceCombiner) Result() *tempopb.Trace {
+	return c.trace
+}
+
+// Flush returns the spans combined since the last flush and releases them.  Spans already returned are still
+// recognised as duplicates.  It's nil if nothing was combined since.
+func (c *TraceCombiner) Flush() *tempopb.Trace {
+	trace := c.trace
+	c.trace = nil
+	return trace
+}
+
+// CombinedFrom returns the number of partial traces combined.
+func (c *TraceCombiner) CombinedFrom() int {
+	return c.combinedFrom
+}
+
+// DuplicateSpans returns the number of spans removed as duplicates.
+func (c *TraceCombiner) DuplicateSpans() int {
+	return c.duplicateSpans
+}
+
+// TraceChunkBytes is the most batch bytes sent in one response when streaming a trace.  A larger batch is sent in a
+// response of its own.
+const TraceChunkBytes = 1 << 20
+
+// SendTraceInChunks sends the batches of the trace of resp over several responses of up to TraceChunkBytes.  Every
+// chunk carries the other fields of resp.  Nothing is sent for a nil trace.
+func SendTraceInChunks(resp *tempopb.TraceByIDResponse, send func(*tempopb.TraceByIDResponse) error) error {
+	trace := resp.Trace
+	if trace == nil {
+		return nil
+	}
+	newChunk := func(chunk *tempopb.Trace) *tempopb.TraceByIDResponse {
+		return &tempopb.TraceByIDResponse{
+			Trace:        chunk,
+			CombinedFrom: resp.CombinedFrom,
+			Incomplete:   resp.Incomplete,
+		}
+	}
+
+	chunk := &tempopb.Trace{}
+	chunkBytes := 0
+	for _, batch := range trace.Batches {
+		size := batch.Size()
+		if len(chunk.Batches) > 0 && chunkBytes+size > TraceChunkBytes {
+			if err := send(newChunk(chunk)); err != nil {
+				return err
+			}
+			chunk = &tempopb.Trace{}
+			chunkBytes = 0
+		}
+		chunk.Batches = append(chunk.Batches, batch)
+		chunkBytes += size
+	}
+
+	if len(chunk.Batches) == 0 {
+		return nil
+	}
+	return send(newChunk(chunk))
+}
+
+// TraceReceiver is the receiving side of a trace stream, e.g. tempopb.Querier_FindTraceByIDStreamClient.
+type TraceReceiver interface {
+	Recv() (*tempopb.TraceByIDResponse, error)
+}
+
+// ReceiveTrace reassembles a response sent with SendTraceInChunks.  The trace is nil if the stream held no batches.
+func ReceiveTrace(stream TraceReceiver) (*tempopb.TraceByIDResponse, error) {
+	var trace *tempopb.Trace
+	var combinedFrom uint32
+	var incomplete bool
+	for {
+		resp, err := stream.Recv()
+		if err == io.EOF {
+			return &tempopb.TraceByIDResponse{
+				Trace:        trace,
+				CombinedFrom: combinedFrom,
+				Incomplete:   incomplete,
+			}, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if resp.Trace == nil || len(resp.Trace.Batches) == 0 {
+			continue
+		}
+
+		combinedFrom = resp.CombinedFrom
+		incomplete = incomplete || resp.Incomplete
+		if trace == nil {
+			trace = &tempopb.Trace{}
+		}
+		trace.Batches = append(trace.Batches, resp.Trace.Batches...)
+	}
+}
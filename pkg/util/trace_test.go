@@ -2,6 +2,7 @@ package util
 
 import (
 	"bytes"
+	"io"
 	"math/rand"
 	"sort"
 	"testing"
@@ -9,7 +10,9 @@ import (
 	"github.com/golang/protobuf/proto"
 	"github.com/grafana/tempo/pkg/tempopb"
 	"github.com/grafana/tempo/pkg/util/test"
+	v1 "github.com/open-telemetry/opentelemetry-proto/gen/go/trace/v1"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestCombine(t *testing.T) {
@@ -89,6 +92,99 @@ func TestCombine(t *testing.T) {
 	}
 }
 
+func TestTraceCombiner(t *testing.T) {
+	trace := test.MakeTrace(5, []byte{0x01, 0x02})
+	spans := spanCount(trace)
+
+	c := &TraceCombiner{}
+	assert.Nil(t, c.Result())
+
+	// replicas of the same trace
+	for i := 0; i < 3; i++ {
+		c.Combine(proto.Clone(trace).(*tempopb.Trace))
+	}
+	c.Combine(nil)
+	c.Combine(&tempopb.Trace{})
+
+	// a partial trace with a span repeated within it and a span without an id
+	partial := proto.Clone(trace).(*tempopb.Trace)
+	ils := partial.Batches[0].InstrumentationLibrarySpans[0]
+	newSpan := proto.Clone(ils.Spans[0]).(*v1.Span)
+	newSpan.SpanId = []byte{0xff}
+	noID := proto.Clone(ils.Spans[0]).(*v1.Span)
+	noID.SpanId = nil
+	ils.Spans = append(ils.Spans, newSpan, proto.Clone(newSpan).(*v1.Span), noID, proto.Clone(noID).(*v1.Span))
+	c.Combine(partial)
+
+	assert.Equal(t, spans+3, spanCount(c.Result()))
+	assert.Equal(t, 4, c.CombinedFrom())
+	assert.Equal(t, 3*spans+1, c.DuplicateSpans())
+
+	// flushed spans are released but still deduplicated
+	flushed := c.Flush()
+	assert.Equal(t, spans+3, spanCount(flushed))
+	assert.Nil(t, c.Flush())
+	c.Combine(proto.Clone(trace).(*tempopb.Trace))
+	assert.Zero(t, spanCount(c.Result()))
+	assert.Equal(t, 4*spans+1, c.DuplicateSpans())
+}
+
+func spanCount(trace *tempopb.Trace) int {
+	count := 0
+	for _, b := range trace.Batches {
+		for _, ils := range b.InstrumentationLibrarySpans {
+			count += len(ils.Spans)
+		}
+	}
+	return count
+}
+
+type sliceReceiver []*tempopb.TraceByIDResponse
+
+func (r *sliceReceiver) Recv() (*tempopb.TraceByIDResponse, error) {
+	if len(*r) == 0 {
+		return nil, io.EOF
+	}
+	resp := (*r)[0]
+	*r = (*r)[1:]
+	return resp, nil
+}
+
+func TestSendTraceInChunks(t *testing.T) {
+	trace := test.MakeTrace(10000, []byte{0x01, 0x02})
+	require.Greater(t, trace.Size(), 2*TraceChunkBytes)
+
+	var responses sliceReceiver
+	err := SendTraceInChunks(&tempopb.TraceByIDResponse{Trace: trace, CombinedFrom: 2, Incomplete: true}, func(resp *tempopb.TraceByIDResponse) error {
+		responses = append(responses, resp)
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Greater(t, len(responses), 2)
+	for _, resp := range responses {
+		assert.LessOrEqual(t, resp.Trace.Size(), TraceChunkBytes+len(resp.Trace.Batches)*5)
+	}
+
+	actual, err := ReceiveTrace(&responses)
+	require.NoError(t, err)
+	assert.Equal(t, trace, actual.Trace)
+	assert.Equal(t, uint32(2), actual.CombinedFrom)
+	assert.True(t, actual.Incomplete)
+
+	// nothing is sent for a missing trace and nothing received is no trace
+	responses = nil
+	err = SendTraceInChunks(&tempopb.TraceByIDResponse{}, func(resp *tempopb.TraceByIDResponse) error {
+		responses = append(responses, resp)
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Empty(t, responses)
+
+	actual, err = ReceiveTrace(&responses)
+	require.NoError(t, err)
+	assert.Nil(t, actual.Trace)
+}
+
 func sortTrace(t *tempopb.Trace) {
 	sort.Slice(t.Batches, func(i, j int) bool {
 		return bytes.Compare(t.Batches[i].InstrumentationLibrarySpans[0].Spans[0].SpanId, t.Batches[j].InstrumentationLibrarySpans[0].Spans[0].SpanId) == 1
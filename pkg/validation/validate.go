@@ -1,6 +1,13 @@
 package validation
 
+import "strings"
+
 // ValidTraceID confirms that trace ids are 128 bits
 func ValidTraceID(id []byte) bool {
 	return len(id) == 16
 }
+
+// ValidTenantID confirms that a tenant id can be used as a path segment of the backend
+func ValidTenantID(id string) bool {
+	return id != "" && id != "." && id != ".." && !strings.ContainsAny(id, `/\`)
+}
@@ -0,0 +1,128 @@
+package watermark
+
+import (
+	"flag"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const (
+	workSearch    = "search"
+	workPush      = "push"
+	workPushLimit = "push_limit"
+)
+
+var (
+	metricHeapBytes = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "tempo",
+		Name:      "memory_watermark_heap_bytes",
+		Help:      "The heap in use when the memory watermarks were last checked.",
+	}, []string{"component"})
+	metricRejected = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "tempo",
+		Name:      "memory_watermark_rejected_total",
+		Help:      "The total number of requests rejected because the heap was above a memory watermark.",
+	}, []string{"component", "work"})
+)
+
+// Config configures the heap sizes above which a component sheds its lowest priority work.  Searches are shed first so
+// the search watermark is expected to be below the push watermark.
+type Config struct {
+	// SearchBytes is the heap size above which searches are rejected.  0 disables it.
+	SearchBytes uint64 `yaml:"search_bytes"`
+	// PushBytes is the heap size above which pushes for best effort tenants are rejected.  0 disables it.
+	PushBytes uint64 `yaml:"push_bytes"`
+	// PushLimitBytes is the heap size above which every push is rejected.  0 disables it.
+	PushLimitBytes uint64        `yaml:"push_limit_bytes"`
+	CheckPeriod    time.Duration `yaml:"check_period"`
+}
+
+// RegisterFlags registers the flags for the watermarks.
+func (cfg *Config) RegisterFlags(prefix string, f *flag.FlagSet) {
+	f.Uint64Var(&cfg.SearchBytes, prefix+".search-bytes", 0, "Heap size in bytes above which searches are rejected. 0 to disable.")
+	f.Uint64Var(&cfg.PushBytes, prefix+".push-bytes", 0, "Heap size in bytes above which pushes for best effort tenants are rejected. 0 to disable.")
+	f.Uint64Var(&cfg.PushLimitBytes, prefix+".push-limit-bytes", 0, "Heap size in bytes above which every push is rejected. 0 to disable.")
+	f.DurationVar(&cfg.CheckPeriod, prefix+".check-period", time.Second, "How often the heap size is read.")
+}
+
+// Guard rejects work while the heap is above the configured watermarks.  The heap is read at most once per check period,
+// when work is admitted, since reading it briefly stops the world.  A nil Guard admits all work.
+type Guard struct {
+	cfg       Config
+	component string
+	readHeap  func() uint64
+
+	mtx       sync.Mutex
+	heap      uint64
+	checkedAt time.Time
+}
+
+// New returns nil if no watermark is configured.
+func New(cfg Config, component string) *Guard {
+	if cfg.SearchBytes == 0 && cfg.PushBytes == 0 && cfg.PushLimitBytes == 0 {
+		return nil
+	}
+
+	return &Guard{
+		cfg:       cfg,
+		component: component,
+		readHeap:  readHeap,
+	}
+}
+
+// RejectSearch returns true if the heap is above the search watermark.
+func (g *Guard) RejectSearch() bool {
+	if g == nil {
+		return false
+	}
+	return g.reject(g.cfg.SearchBytes, workSearch)
+}
+
+// RejectPush returns true if the heap is above the push watermark.  Only pushes for best effort tenants should be
+// checked.
+func (g *Guard) RejectPush() bool {
+	if g == nil {
+		return false
+	}
+	return g.reject(g.cfg.PushBytes, workPush)
+}
+
+// RejectAnyPush returns true if the heap is above the push limit.  Unlike the push watermark it applies to all tenants.
+func (g *Guard) RejectAnyPush() bool {
+	if g == nil {
+		return false
+	}
+	return g.reject(g.cfg.PushLimitBytes, workPushLimit)
+}
+
+func (g *Guard) reject(watermark uint64, work string) bool {
+	if watermark == 0 || g.heapBytes() < watermark {
+		return false
+	}
+
+	metricRejected.WithLabelValues(g.component, work).Inc()
+	return true
+}
+
+func (g *Guard) heapBytes() uint64 {
+	g.mtx.Lock()
+	defer g.mtx.Unlock()
+
+	if now := time.Now(); now.Sub(g.checkedAt) >= g.cfg.CheckPeriod {
+		g.heap = g.readHeap()
+		g.checkedAt = now
+		metricHeapBytes.WithLabelValues(g.component).Set(float64(g.heap))
+	}
+
+	return g.heap
+}
+
+func readHeap() uint64 {
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+	return stats.HeapInuse
+}
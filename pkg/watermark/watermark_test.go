@@ -0,0 +1,64 @@
+package watermark
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGuard(t *testing.T) {
+	heap := uint64(0)
+	g := New(Config{SearchBytes: 100, PushBytes: 200}, "test")
+	g.readHeap = func() uint64 { return heap }
+
+	assert.False(t, g.RejectSearch())
+	assert.False(t, g.RejectPush())
+
+	heap = 150
+	assert.True(t, g.RejectSearch())
+	assert.False(t, g.RejectPush())
+
+	heap = 250
+	assert.True(t, g.RejectSearch())
+	assert.True(t, g.RejectPush())
+
+	heap = 0
+	assert.False(t, g.RejectSearch())
+}
+
+func TestGuardPushLimit(t *testing.T) {
+	heap := uint64(150)
+	g := New(Config{PushLimitBytes: 100}, "test")
+	g.readHeap = func() uint64 { return heap }
+
+	assert.True(t, g.RejectAnyPush())
+	assert.False(t, g.RejectPush())
+	assert.False(t, g.RejectSearch())
+
+	heap = 50
+	assert.False(t, g.RejectAnyPush())
+}
+
+func TestGuardCheckPeriod(t *testing.T) {
+	heap := uint64(150)
+	g := New(Config{SearchBytes: 100, CheckPeriod: time.Hour}, "test")
+	g.readHeap = func() uint64 { return heap }
+
+	assert.True(t, g.RejectSearch())
+
+	// the heap isn't read again until the check period has passed
+	heap = 0
+	assert.True(t, g.RejectSearch())
+
+	// a disabled watermark never rejects
+	assert.False(t, g.RejectPush())
+}
+
+func TestGuardDisabled(t *testing.T) {
+	g := New(Config{CheckPeriod: time.Second}, "test")
+	assert.Nil(t, g)
+	assert.False(t, g.RejectSearch())
+	assert.False(t, g.RejectPush())
+	assert.False(t, g.RejectAnyPush())
+}
@@ -0,0 +1,240 @@
+package tempodb
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"github.com/grafana/tempo/pkg/tenantmetrics"
+	"github.com/grafana/tempo/tempodb/backend"
+	"github.com/grafana/tempo/tempodb/encoding"
+)
+
+var (
+	metricArchiveWriteFailures = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: "tempodb",
+		Name:      "archive_write_failures_total",
+		Help:      "Total number of blocks that failed to be written to the archive backend.",
+	})
+	metricArchiveRetentionErrors = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: "tempodb",
+		Name:      "archive_retention_errors_total",
+		Help:      "Total number of times an error occurred while performing retention on the archive backend.",
+	})
+	metricArchiveDeleted = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: "tempodb",
+		Name:      "archive_retention_deleted_total",
+		Help:      "Total number of blocks deleted from the archive backend.",
+	})
+	metricArchiveFallbacks = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "tempodb",
+		Name:      "archive_find_fallbacks_total",
+		Help:      "Total number of finds that searched the archive backend after missing in the primary backend.",
+	}, []string{"found"})
+	metricArchiveBlocklistLength = tenantmetrics.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "tempodb",
+		Name:      "archive_blocklist_length",
+		Help:      "Total number of archived blocks per tenant.",
+	}, []string{"tenant"})
+)
+
+// archive is a second backend flushed blocks are copied to.  Archived blocks are never compacted.  Its blocklist is
+// only polled, and its blocks only searched, if query fallback is enabled.
+type archive struct {
+	r   backend.Reader
+	w   backend.Writer
+	c   backend.Compactor
+	cfg *ArchiveConfig
+
+	blockIDRanges    map[string]*idRangeIndex
+	blockIDRangesMtx sync.Mutex
+}
+
+// newArchive returns nil if no archive backend is configured.
+func newArchive(cfg *ArchiveConfig) (*archive, error) {
+	if cfg == nil || cfg.Backend == "" {
+		return nil, nil
+	}
+
+	r, w, c, err := newBackend(cfg.Backend, cfg.Local, cfg.GCS, cfg.S3, cfg.Azure, cfg.InMemory)
+	if err != nil {
+		return nil, fmt.Errorf("archive: %w", err)
+	}
+
+	return &archive{
+		r:   r,
+		w:   w,
+		c:   c,
+		cfg: cfg,
+
+		blockIDRanges: make(map[string]*idRangeIndex),
+	}, nil
+}
+
+func (a *archive) write(ctx context.Context, meta *encoding.BlockMeta, bloom [][]byte, index []byte, objectFilePath string) error {
+	err := a.w.Write(ctx, meta, bloom, index, objectFilePath)
+	if err != nil {
+		metricArchiveWriteFailures.Inc()
+		return fmt.Errorf("archive: %w", err)
+	}
+
+	return nil
+}
+
+// doRetention deletes archived blocks that ended before the archive retention.  Blocks are listed from the archive
+// backend on every run as it is not part of the polled blocklist.  Only blocks owned by the sharder are checked.
+func (a *archive) doRetention(sharder CompactorSharder, logger log.Logger) {
+	if a.cfg.Retention == 0 {
+		return
+	}
+
+	ctx := context.Background()
+	cutoff := time.Now().Add(-a.cfg.Retention)
+
+	tenants, err := a.r.Tenants(ctx)
+	if err != nil {
+		level.Error(logger).Log("msg", "failed to list archive tenants during retention", "err", err)
+		metricArchiveRetentionErrors.Inc()
+		return
+	}
+
+	for _, tenantID := range tenants {
+		blockIDs, err := a.r.Blocks(ctx, tenantID)
+		if err != nil {
+			level.Error(logger).Log("msg", "failed to list archive blocks during retention", "tenantID", tenantID, "err", err)
+			metricArchiveRetentionErrors.Inc()
+			continue
+		}
+
+		for _, blockID := range blockIDs {
+			if !sharder.Owns(blockHashString(tenantID, blockID)) {
+				continue
+			}
+
+			meta, err := a.r.BlockMeta(ctx, blockID, tenantID)
+			if err == backend.ErrMetaDoesNotExist {
+				// not committed yet
+				continue
+			}
+			if err != nil {
+				level.Error(logger).Log("msg", "failed to read archive block meta during retention", "blockID", blockID, "tenantID", tenantID, "err", err)
+				metricArchiveRetentionErrors.Inc()
+				continue
+			}
+
+			if !meta.EndTime.Before(cutoff) {
+				continue
+			}
+
+			level.Info(logger).Log("msg", "deleting archived block", "blockID", blockID, "tenantID", tenantID)
+			err = a.c.ClearBlock(blockID, tenantID)
+			if err != nil {
+				level.Error(logger).Log("msg", "failed to clear archived block during retention", "blockID", blockID, "tenantID", tenantID, "err", err)
+				metricArchiveRetentionErrors.Inc()
+			} else {
+				metricArchiveDeleted.Inc()
+			}
+		}
+	}
+}
+
+// pollArchiveBlocklist reads the metas of every archived block.  Unlike the primary blocklist there are no compacted
+// blocks to track, so only the id range index used to pick blocks during a find is kept.
+func (rw *readerWriter) pollArchiveBlocklist() {
+	ctx := context.Background()
+	a := rw.archive
+
+	tenants, err := a.r.Tenants(ctx)
+	if err != nil {
+		level.Error(rw.logger).Log("msg", "error retrieving tenants while polling archive blocklist", "err", err)
+		return
+	}
+
+	for _, tenantID := range tenants {
+		blockIDs, err := a.r.Blocks(ctx, tenantID)
+		if err != nil {
+			level.Error(rw.logger).Log("msg", "error polling archive blocklist", "tenantID", tenantID, "err", err)
+		}
+
+		jobs := make([]interface{}, 0, len(blockIDs))
+		for _, id := range blockIDs {
+			jobs = append(jobs, id)
+		}
+
+		listMutex := sync.Mutex{}
+		blocklist := make([]*encoding.BlockMeta, 0, len(blockIDs))
+		_, err = rw.pool.RunJobs(ctx, jobs, func(ctx context.Context, payload interface{}) ([]byte, error) {
+			blockID := payload.(uuid.UUID)
+
+			meta, err := a.r.BlockMeta(ctx, blockID, tenantID)
+			if err == backend.ErrMetaDoesNotExist {
+				return nil, nil
+			}
+			if err != nil {
+				level.Error(rw.logger).Log("msg", "failed to retrieve archive block meta", "tenantID", tenantID, "blockID", blockID, "err", err)
+				return nil, nil
+			}
+			if !encoding.ReadableVersion(meta.Version) {
+				return nil, nil
+			}
+
+			listMutex.Lock()
+			blocklist = append(blocklist, meta)
+			listMutex.Unlock()
+
+			return nil, nil
+		})
+		if err != nil {
+			level.Error(rw.logger).Log("msg", "run archive blocklist jobs", "tenantID", tenantID, "err", err)
+			continue
+		}
+
+		metricArchiveBlocklistLength.WithLabelValues(tenantID).Set(float64(len(blocklist)))
+
+		a.blockIDRangesMtx.Lock()
+		a.blockIDRanges[tenantID] = newIDRangeIndex(blocklist)
+		a.blockIDRangesMtx.Unlock()
+	}
+}
+
+// findInArchive searches the archived blocks whose id range covers id, newest first.
+func (rw *readerWriter) findInArchive(ctx context.Context, tenantID string, id encoding.ID, include BlockFilter, workers int, metrics FindMetrics) ([]byte, error) {
+	a := rw.archive
+
+	a.blockIDRangesMtx.Lock()
+	var inRange []*encoding.BlockMeta
+	if index, ok := a.blockIDRanges[tenantID]; ok {
+		inRange = index.find(id)
+	}
+	a.blockIDRangesMtx.Unlock()
+
+	sort.Slice(inRange, func(i, j int) bool {
+		return inRange[i].EndTime.After(inRange[j].EndTime)
+	})
+	blocks := make([]interface{}, 0, len(inRange))
+	for _, b := range inRange {
+		if include != nil && !include(b) {
+			continue
+		}
+		blocks = append(blocks, b)
+	}
+	if len(blocks) == 0 {
+		return nil, nil
+	}
+
+	foundBytes, err := rw.findInBlocks(ctx, a.r, a.cfg.Backend, blocks, tenantID, id, workers, metrics)
+	if err == nil {
+		metricArchiveFallbacks.WithLabelValues(strconv.FormatBool(foundBytes != nil)).Inc()
+	}
+
+	return foundBytes, err
+}
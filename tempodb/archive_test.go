@@ -0,0 +1,160 @@
+package tempodb
+
+import (
+	"context"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/golang/protobuf/proto"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/tempo/pkg/tempopb"
+	"github.com/grafana/tempo/pkg/util/test"
+	"github.com/grafana/tempo/tempodb/backend/local"
+	"github.com/grafana/tempo/tempodb/encoding"
+	"github.com/grafana/tempo/tempodb/wal"
+)
+
+func TestArchive(t *testing.T) {
+	tempDir, err := ioutil.TempDir("/tmp", "")
+	defer os.RemoveAll(tempDir)
+	require.NoError(t, err, "unexpected error creating temp dir")
+
+	archiveCfg := &ArchiveConfig{
+		Backend: "local",
+		Local: &local.Config{
+			Path: path.Join(tempDir, "archive"),
+		},
+	}
+	r, w, _, err := New(&Config{
+		Backend: "local",
+		Local: &local.Config{
+			Path: path.Join(tempDir, "traces"),
+		},
+		WAL: &wal.Config{
+			Filepath:        path.Join(tempDir, "wal"),
+			IndexDownsample: 17,
+			BloomFP:         .01,
+		},
+		Archive: archiveCfg,
+	}, log.NewNopLogger())
+	require.NoError(t, err)
+
+	head, err := w.WAL().NewBlock(uuid.New(), testTenantID)
+	require.NoError(t, err)
+
+	id := make([]byte, 16)
+	rand.Read(id)
+	bReq, err := proto.Marshal(test.MakeRequest(10, id))
+	require.NoError(t, err)
+	require.NoError(t, head.Write(id, bReq))
+
+	complete, err := head.Complete(w.WAL(), &mockSharder{})
+	require.NoError(t, err)
+	require.NoError(t, w.WriteBlock(context.Background(), complete))
+	blockID := complete.BlockMeta().BlockID
+
+	// the block is in both backends
+	rw := r.(*readerWriter)
+	rw.pollBlocklist()
+	assert.Len(t, rw.blocklist(testTenantID), 1)
+
+	archived, err := rw.archive.r.BlockMeta(context.Background(), blockID, testTenantID)
+	require.NoError(t, err)
+	assert.Equal(t, blockID, archived.BlockID)
+
+	// retention only removes the archived copy once it has expired
+	archiveCfg.Retention = time.Hour
+	rw.archive.doRetention(&mockSharder{}, log.NewNopLogger())
+	blocks, err := rw.archive.r.Blocks(context.Background(), testTenantID)
+	require.NoError(t, err)
+	assert.Len(t, blocks, 1)
+
+	archiveCfg.Retention = time.Nanosecond
+	rw.archive.doRetention(&mockSharder{}, log.NewNopLogger())
+	blocks, err = rw.archive.r.Blocks(context.Background(), testTenantID)
+	require.NoError(t, err)
+	assert.Len(t, blocks, 0)
+
+	rw.pollBlocklist()
+	assert.Len(t, rw.blocklist(testTenantID), 1)
+}
+
+func TestArchiveQueryFallback(t *testing.T) {
+	tempDir, err := ioutil.TempDir("/tmp", "")
+	defer os.RemoveAll(tempDir)
+	require.NoError(t, err, "unexpected error creating temp dir")
+
+	archiveCfg := &ArchiveConfig{
+		Backend: "local",
+		Local: &local.Config{
+			Path: path.Join(tempDir, "archive"),
+		},
+		QueryFallback: true,
+	}
+	r, w, c, err := New(&Config{
+		Backend: "local",
+		Local: &local.Config{
+			Path: path.Join(tempDir, "traces"),
+		},
+		WAL: &wal.Config{
+			Filepath:        path.Join(tempDir, "wal"),
+			IndexDownsample: 17,
+			BloomFP:         .01,
+		},
+		Archive: archiveCfg,
+	}, log.NewNopLogger())
+	require.NoError(t, err)
+
+	head, err := w.WAL().NewBlock(uuid.New(), testTenantID)
+	require.NoError(t, err)
+
+	id := make([]byte, 16)
+	rand.Read(id)
+	req := test.MakeRequest(10, id)
+	bReq, err := proto.Marshal(req)
+	require.NoError(t, err)
+	require.NoError(t, head.Write(id, bReq))
+
+	complete, err := head.Complete(w.WAL(), &mockSharder{})
+	require.NoError(t, err)
+	require.NoError(t, w.WriteBlock(context.Background(), complete))
+
+	// remove the block from the primary backend as retention would
+	rw := c.(*readerWriter)
+	require.NoError(t, rw.c.ClearBlock(complete.BlockMeta().BlockID, testTenantID))
+	rw.pollBlocklists()
+	assert.Len(t, rw.blocklist(testTenantID), 0)
+
+	bFound, _, err := r.Find(context.Background(), testTenantID, id)
+	require.NoError(t, err)
+	out := &tempopb.PushRequest{}
+	require.NoError(t, proto.Unmarshal(bFound, out))
+	assert.True(t, proto.Equal(req, out))
+
+	// the filter applies to archived blocks too
+	bFound, _, err = r.FindInBlocks(context.Background(), testTenantID, id, func(*encoding.BlockMeta) bool { return false }, 0)
+	require.NoError(t, err)
+	assert.Nil(t, bFound)
+
+	archiveCfg.QueryFallback = false
+	bFound, _, err = r.Find(context.Background(), testTenantID, id)
+	require.NoError(t, err)
+	assert.Nil(t, bFound)
+}
+
+func TestArchiveUnknownBackend(t *testing.T) {
+	_, err := newArchive(&ArchiveConfig{Backend: "nope"})
+	assert.Error(t, err)
+
+	a, err := newArchive(&ArchiveConfig{})
+	assert.NoError(t, err)
+	assert.Nil(t, a)
+}
@@ -0,0 +1,358 @@
+package azure
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-storage-blob-go/azblob"
+	"github.com/Azure/go-autorest/autorest/adal"
+	"github.com/cortexproject/cortex/pkg/util"
+	"github.com/go-kit/kit/log/level"
+	"github.com/google/uuid"
+	"github.com/opentracing/opentracing-go"
+
+	"github.com/grafana/tempo/tempodb/backend"
+	tempo_util "github.com/grafana/tempo/tempodb/backend/util"
+	"github.com/grafana/tempo/tempodb/encoding"
+)
+
+const (
+	storageResource = "https://storage.azure.com/"
+
+	maxRetries = 3
+)
+
+type readerWriter struct {
+	cfg       *Config
+	container azblob.ContainerURL
+}
+
+func New(cfg *Config) (backend.Reader, backend.Writer, backend.Compactor, error) {
+	container, err := containerURL(cfg)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	rw := &readerWriter{
+		cfg:       cfg,
+		container: container,
+	}
+
+	return rw, rw, rw, nil
+}
+
+func (rw *readerWriter) Write(ctx context.Context, meta *encoding.BlockMeta, bBloom [][]byte, bIndex []byte, objectFilePath string) error {
+	blockID := meta.BlockID
+	tenantID := meta.TenantID
+
+	src, err := os.Open(objectFilePath)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	_, err = azblob.UploadFileToBlockBlob(ctx, src, rw.container.NewBlockBlobURL(tempo_util.ObjectFileName(blockID, tenantID)), azblob.UploadToBlockBlobOptions{
+		BlockSize:   int64(rw.cfg.BufferSize),
+		Parallelism: uint16(rw.cfg.Parallelism),
+	})
+	if err != nil {
+		return err
+	}
+
+	return rw.WriteBlockMeta(ctx, nil, meta, bBloom, bIndex)
+}
+
+func (rw *readerWriter) WriteBlockMeta(ctx context.Context, _ backend.AppendTracker, meta *encoding.BlockMeta, bBloom [][]byte, bIndex []byte) error {
+	blockID := meta.BlockID
+	tenantID := meta.TenantID
+
+	// appended blocks are committed as they are written so there is nothing to close
+	for i, b := range bBloom {
+		err := rw.writeAll(ctx, tempo_util.BloomFileName(blockID, tenantID, i), b)
+		if err != nil {
+			return err
+		}
+	}
+
+	err := rw.writeAll(ctx, tempo_util.IndexFileName(blockID, tenantID), bIndex)
+	if err != nil {
+		return err
+	}
+
+	bMeta, err := json.Marshal(meta)
+	if err != nil {
+		return err
+	}
+
+	// write meta last.  this will prevent blocklist from returning a partial block
+	return rw.writeAll(ctx, tempo_util.MetaFileName(blockID, tenantID), bMeta)
+}
+
+func (rw *readerWriter) WriteSearch(ctx context.Context, meta *encoding.BlockMeta, bSearch []byte) error {
+	return rw.writeAll(ctx, tempo_util.SearchFileName(meta.BlockID, meta.TenantID), bSearch)
+}
+
+// AppendObject writes the object file of a block as an append blob.  The first call creates the blob and returns it
+// as the tracker.
+func (rw *readerWriter) AppendObject(ctx context.Context, tracker backend.AppendTracker, meta *encoding.BlockMeta, bObject []byte) (backend.AppendTracker, error) {
+	var blob azblob.AppendBlobURL
+	if tracker == nil {
+		blob = rw.container.NewAppendBlobURL(tempo_util.ObjectFileName(meta.BlockID, meta.TenantID))
+		_, err := blob.Create(ctx, azblob.BlobHTTPHeaders{}, azblob.Metadata{}, azblob.BlobAccessConditions{})
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		blob = tracker.(azblob.AppendBlobURL)
+	}
+
+	// a single append is limited to 4MB
+	for len(bObject) > 0 {
+		n := len(bObject)
+		if n > azblob.AppendBlobMaxAppendBlockBytes {
+			n = azblob.AppendBlobMaxAppendBlockBytes
+		}
+
+		_, err := blob.AppendBlock(ctx, bytes.NewReader(bObject[:n]), azblob.AppendBlobAccessConditions{}, nil)
+		if err != nil {
+			return nil, err
+		}
+		bObject = bObject[n:]
+	}
+
+	return blob, nil
+}
+
+func (rw *readerWriter) Tenants(ctx context.Context) ([]string, error) {
+	prefixes, err := rw.listPrefixes(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+
+	tenants := make([]string, 0, len(prefixes))
+	for _, p := range prefixes {
+		tenants = append(tenants, strings.TrimSuffix(p, "/"))
+	}
+
+	return tenants, nil
+}
+
+func (rw *readerWriter) Blocks(ctx context.Context, tenantID string) ([]uuid.UUID, error) {
+	var warning error
+
+	prefixes, err := rw.listPrefixes(ctx, tenantID+"/")
+	if err != nil {
+		return nil, err
+	}
+
+	blocks := make([]uuid.UUID, 0, len(prefixes))
+	for _, p := range prefixes {
+		idString := strings.TrimSuffix(strings.TrimPrefix(p, tenantID+"/"), "/")
+		blockID, err := uuid.Parse(idString)
+		if err != nil {
+			warning = fmt.Errorf("failed parse on blockID %s: %v", idString, err)
+			continue
+		}
+
+		blocks = append(blocks, blockID)
+	}
+
+	return blocks, warning
+}
+
+func (rw *readerWriter) BlockMeta(ctx context.Context, blockID uuid.UUID, tenantID string) (*encoding.BlockMeta, error) {
+	name := tempo_util.MetaFileName(blockID, tenantID)
+
+	b, _, err := rw.readAll(ctx, name)
+	if isNotFound(err) {
+		return nil, backend.ErrMetaDoesNotExist
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	out := &encoding.BlockMeta{}
+	err = json.Unmarshal(b, out)
+	if err != nil {
+		return nil, err
+	}
+
+	return out, nil
+}
+
+func (rw *readerWriter) Bloom(ctx context.Context, blockID uuid.UUID, tenantID string, shardNum int) ([]byte, error) {
+	span, derivedCtx := opentracing.StartSpanFromContext(ctx, "azure.Bloom")
+	defer span.Finish()
+
+	b, _, err := rw.readAll(derivedCtx, tempo_util.BloomFileName(blockID, tenantID, shardNum))
+	return b, err
+}
+
+func (rw *readerWriter) Index(ctx context.Context, blockID uuid.UUID, tenantID string) ([]byte, error) {
+	span, derivedCtx := opentracing.StartSpanFromContext(ctx, "azure.Index")
+	defer span.Finish()
+
+	b, _, err := rw.readAll(derivedCtx, tempo_util.IndexFileName(blockID, tenantID))
+	return b, err
+}
+
+func (rw *readerWriter) Search(ctx context.Context, blockID uuid.UUID, tenantID string) ([]byte, error) {
+	span, derivedCtx := opentracing.StartSpanFromContext(ctx, "azure.Search")
+	defer span.Finish()
+
+	b, _, err := rw.readAll(derivedCtx, tempo_util.SearchFileName(blockID, tenantID))
+	return b, err
+}
+
+func (rw *readerWriter) Object(ctx context.Context, blockID uuid.UUID, tenantID string, start uint64, buffer []byte) error {
+	span, derivedCtx := opentracing.StartSpanFromContext(ctx, "azure.Object")
+	defer span.Finish()
+
+	blob := rw.container.NewBlobURL(tempo_util.ObjectFileName(blockID, tenantID))
+	return azblob.DownloadBlobToBuffer(derivedCtx, blob, int64(start), int64(len(buffer)), buffer, azblob.DownloadFromBlobOptions{
+		RetryReaderOptionsPerBlock: azblob.RetryReaderOptions{MaxRetryRequests: maxRetries},
+	})
+}
+
+func (rw *readerWriter) Shutdown() {
+
+}
+
+func (rw *readerWriter) writeAll(ctx context.Context, name string, b []byte) error {
+	_, err := azblob.UploadBufferToBlockBlob(ctx, b, rw.container.NewBlockBlobURL(name), azblob.UploadToBlockBlobOptions{})
+	return err
+}
+
+func (rw *readerWriter) readAll(ctx context.Context, name string) ([]byte, time.Time, error) {
+	resp, err := rw.container.NewBlobURL(name).Download(ctx, 0, azblob.CountToEnd, azblob.BlobAccessConditions{}, false)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+
+	body := resp.Body(azblob.RetryReaderOptions{MaxRetryRequests: maxRetries})
+	defer body.Close()
+
+	b, err := ioutil.ReadAll(body)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+
+	return b, resp.LastModified(), nil
+}
+
+// listPrefixes enumerates the virtual directories directly below prefix
+func (rw *readerWriter) listPrefixes(ctx context.Context, prefix string) ([]string, error) {
+	var prefixes []string
+
+	for marker := (azblob.Marker{}); marker.NotDone(); {
+		resp, err := rw.container.ListBlobsHierarchySegment(ctx, marker, "/", azblob.ListBlobsSegmentOptions{
+			Prefix: prefix,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		for _, p := range resp.Segment.BlobPrefixes {
+			prefixes = append(prefixes, p.Name)
+		}
+		marker = resp.NextMarker
+	}
+
+	return prefixes, nil
+}
+
+func containerURL(cfg *Config) (azblob.ContainerURL, error) {
+	if cfg.ContainerName == "" {
+		return azblob.ContainerURL{}, fmt.Errorf("azure container name must be set")
+	}
+
+	endpoint := cfg.Endpoint
+	if endpoint == "" {
+		endpoint = fmt.Sprintf("https://%s.blob.core.windows.net", cfg.StorageAccountName)
+	}
+
+	u, err := url.Parse(strings.TrimSuffix(endpoint, "/") + "/" + cfg.ContainerName)
+	if err != nil {
+		return azblob.ContainerURL{}, fmt.Errorf("invalid azure endpoint %s: %w", endpoint, err)
+	}
+
+	var credential azblob.Credential
+	switch {
+	case cfg.StorageAccountKey != "":
+		credential, err = azblob.NewSharedKeyCredential(cfg.StorageAccountName, cfg.StorageAccountKey)
+		if err != nil {
+			return azblob.ContainerURL{}, err
+		}
+	case cfg.SASToken != "":
+		// the sas token authenticates every request made to urls derived from the container url
+		u.RawQuery = strings.TrimPrefix(cfg.SASToken, "?")
+		credential = azblob.NewAnonymousCredential()
+	case cfg.UseManagedIdentity:
+		credential, err = managedIdentityCredential(cfg.UserAssignedID)
+		if err != nil {
+			return azblob.ContainerURL{}, err
+		}
+	default:
+		return azblob.ContainerURL{}, fmt.Errorf("one of azure storage_account_key, sas_token or use_managed_identity must be set")
+	}
+
+	p := azblob.NewPipeline(credential, azblob.PipelineOptions{
+		Retry: azblob.RetryOptions{MaxTries: maxRetries},
+	})
+	return azblob.NewContainerURL(*u, p), nil
+}
+
+// managedIdentityCredential returns a credential that keeps a token for the managed identity of the host fresh.
+func managedIdentityCredential(userAssignedID string) (azblob.Credential, error) {
+	msiEndpoint, err := adal.GetMSIVMEndpoint()
+	if err != nil {
+		return nil, err
+	}
+
+	var spt *adal.ServicePrincipalToken
+	if userAssignedID != "" {
+		spt, err = adal.NewServicePrincipalTokenFromMSIWithUserAssignedID(msiEndpoint, storageResource, userAssignedID)
+	} else {
+		spt, err = adal.NewServicePrincipalTokenFromMSI(msiEndpoint, storageResource)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	err = spt.Refresh()
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch managed identity token: %w", err)
+	}
+
+	return azblob.NewTokenCredential(spt.Token().AccessToken, func(tc azblob.TokenCredential) time.Duration {
+		err := spt.Refresh()
+		if err != nil {
+			level.Error(util.Logger).Log("msg", "failed to refresh azure managed identity token", "err", err)
+			return time.Minute
+		}
+
+		token := spt.Token()
+		tc.SetToken(token.AccessToken)
+
+		// refresh a couple of minutes before the token expires
+		next := time.Until(token.Expires()) - 2*time.Minute
+		if next < time.Minute {
+			next = time.Minute
+		}
+		return next
+	}), nil
+}
+
+func isNotFound(err error) bool {
+	if serr, ok := err.(azblob.StorageError); ok {
+		return serr.ServiceCode() == azblob.ServiceCodeBlobNotFound
+	}
+	return false
+}
@@ -0,0 +1,271 @@
+package azure
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/tempo/tempodb/backend"
+	"github.com/grafana/tempo/tempodb/encoding"
+)
+
+const (
+	testAccount   = "account"
+	testContainer = "traces"
+	testSAS       = "sv=2019-02-02&sig=secret"
+)
+
+// fakeBlobService is an in memory blob container that supports the subset of the blob api the backend uses
+type fakeBlobService struct {
+	t     *testing.T
+	mtx   sync.Mutex
+	blobs map[string][]byte
+}
+
+func (f *fakeBlobService) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+
+	assert.Equal(f.t, "secret", r.URL.Query().Get("sig"), "sas token missing")
+
+	prefix := "/" + testAccount + "/" + testContainer
+	if !strings.HasPrefix(r.URL.Path, prefix) {
+		writeError(w, http.StatusNotFound, "ContainerNotFound")
+		return
+	}
+	name := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, prefix), "/")
+	query := r.URL.Query()
+
+	w.Header().Set("Last-Modified", time.Now().UTC().Format(http.TimeFormat))
+	w.Header().Set("ETag", `"etag"`)
+
+	switch {
+	case r.Method == http.MethodGet && query.Get("comp") == "list":
+		f.list(w, query.Get("prefix"), query.Get("delimiter"))
+	case r.Method == http.MethodPut && query.Get("comp") == "appendblock":
+		if _, ok := f.blobs[name]; !ok {
+			writeError(w, http.StatusNotFound, "BlobNotFound")
+			return
+		}
+		body, _ := ioutil.ReadAll(r.Body)
+		f.blobs[name] = append(f.blobs[name], body...)
+		w.WriteHeader(http.StatusCreated)
+	case r.Method == http.MethodPut:
+		body, _ := ioutil.ReadAll(r.Body)
+		f.blobs[name] = body
+		w.WriteHeader(http.StatusCreated)
+	case r.Method == http.MethodGet:
+		b, ok := f.blobs[name]
+		if !ok {
+			writeError(w, http.StatusNotFound, "BlobNotFound")
+			return
+		}
+		status := http.StatusOK
+		if rng := r.Header.Get("x-ms-range"); rng != "" {
+			var start, end int
+			if n, _ := fmt.Sscanf(rng, "bytes=%d-%d", &start, &end); n == 2 {
+				b = b[start : end+1]
+			} else {
+				b = b[start:]
+			}
+			status = http.StatusPartialContent
+		}
+		w.Header().Set("Content-Length", strconv.Itoa(len(b)))
+		w.WriteHeader(status)
+		_, _ = w.Write(b)
+	case r.Method == http.MethodDelete:
+		if _, ok := f.blobs[name]; !ok {
+			writeError(w, http.StatusNotFound, "BlobNotFound")
+			return
+		}
+		delete(f.blobs, name)
+		w.WriteHeader(http.StatusAccepted)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (f *fakeBlobService) list(w http.ResponseWriter, prefix string, delimiter string) {
+	type blob struct {
+		Name string `xml:"Name"`
+	}
+	type results struct {
+		XMLName      xml.Name `xml:"EnumerationResults"`
+		Blobs        []blob   `xml:"Blobs>Blob"`
+		BlobPrefixes []blob   `xml:"Blobs>BlobPrefix"`
+		NextMarker   string   `xml:"NextMarker"`
+	}
+
+	res := results{}
+	seen := map[string]bool{}
+	names := make([]string, 0, len(f.blobs))
+	for name := range f.blobs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		rest := strings.TrimPrefix(name, prefix)
+		if i := strings.Index(rest, delimiter); delimiter != "" && i >= 0 {
+			p := prefix + rest[:i+1]
+			if !seen[p] {
+				seen[p] = true
+				res.BlobPrefixes = append(res.BlobPrefixes, blob{Name: p})
+			}
+			continue
+		}
+		res.Blobs = append(res.Blobs, blob{Name: name})
+	}
+
+	b, _ := xml.Marshal(res)
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(b)
+}
+
+func writeError(w http.ResponseWriter, status int, code string) {
+	w.Header().Set("x-ms-error-code", code)
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	_, _ = fmt.Fprintf(w, `<?xml version="1.0" encoding="utf-8"?><Error><Code>%s</Code><Message>%s</Message></Error>`, code, code)
+}
+
+func TestReadWrite(t *testing.T) {
+	fake := &fakeBlobService{t: t, blobs: map[string][]byte{}}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	r, w, c, err := New(&Config{
+		StorageAccountName: testAccount,
+		ContainerName:      testContainer,
+		Endpoint:           server.URL + "/" + testAccount,
+		SASToken:           "?" + testSAS,
+	})
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	tenantID := "tenant"
+	meta := encoding.NewBlockMeta(tenantID, uuid.New(), "v0")
+
+	// larger than a single append
+	object := make([]byte, 5*1024*1024)
+	_, err = rand.Read(object)
+	require.NoError(t, err)
+
+	tracker, err := w.AppendObject(ctx, nil, meta, object[:1024])
+	require.NoError(t, err)
+	tracker, err = w.AppendObject(ctx, tracker, meta, object[1024:])
+	require.NoError(t, err)
+
+	blooms := make([][]byte, encoding.LegacyBloomShardCount)
+	for i := range blooms {
+		blooms[i] = []byte{byte(i)}
+	}
+	err = w.WriteBlockMeta(ctx, tracker, meta, blooms, []byte("index"))
+	require.NoError(t, err)
+
+	tenants, err := r.Tenants(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []string{tenantID}, tenants)
+
+	blocks, err := r.Blocks(ctx, tenantID)
+	require.NoError(t, err)
+	assert.Equal(t, []uuid.UUID{meta.BlockID}, blocks)
+
+	actualMeta, err := r.BlockMeta(ctx, meta.BlockID, tenantID)
+	require.NoError(t, err)
+	assert.Equal(t, meta.BlockID, actualMeta.BlockID)
+
+	actualBloom, err := r.Bloom(ctx, meta.BlockID, tenantID, 1)
+	require.NoError(t, err)
+	assert.Equal(t, blooms[1], actualBloom)
+
+	actualIndex, err := r.Index(ctx, meta.BlockID, tenantID)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("index"), actualIndex)
+
+	buffer := make([]byte, 100)
+	err = r.Object(ctx, meta.BlockID, tenantID, 4*1024*1024-50, buffer)
+	require.NoError(t, err)
+	assert.Equal(t, object[4*1024*1024-50:4*1024*1024+50], buffer)
+
+	// compaction
+	_, err = c.CompactedBlockMeta(meta.BlockID, tenantID)
+	assert.Equal(t, backend.ErrMetaDoesNotExist, err)
+
+	err = c.MarkBlockCompacted(meta.BlockID, tenantID)
+	require.NoError(t, err)
+
+	_, err = r.BlockMeta(ctx, meta.BlockID, tenantID)
+	assert.Equal(t, backend.ErrMetaDoesNotExist, err)
+
+	compactedMeta, err := c.CompactedBlockMeta(meta.BlockID, tenantID)
+	require.NoError(t, err)
+	assert.Equal(t, meta.BlockID, compactedMeta.BlockID)
+	assert.False(t, compactedMeta.CompactedTime.IsZero())
+
+	err = c.ClearBlock(meta.BlockID, tenantID)
+	require.NoError(t, err)
+	assert.Empty(t, fake.blobs)
+}
+
+func TestProbe(t *testing.T) {
+	fake := &fakeBlobService{t: t, blobs: map[string][]byte{}}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	_, w, _, err := New(&Config{
+		ContainerName: testContainer,
+		Endpoint:      server.URL + "/" + testAccount,
+		SASToken:      testSAS,
+	})
+	require.NoError(t, err)
+	require.NoError(t, backend.Probe(context.Background(), w.(backend.Prober)))
+
+	_, w, _, err = New(&Config{
+		ContainerName: "missing",
+		Endpoint:      server.URL + "/" + testAccount,
+		SASToken:      testSAS,
+	})
+	require.NoError(t, err)
+	err = backend.Probe(context.Background(), w.(backend.Prober))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "container missing does not exist")
+}
+
+func TestConfig(t *testing.T) {
+	_, _, _, err := New(&Config{StorageAccountName: testAccount})
+	assert.EqualError(t, err, "azure container name must be set")
+
+	_, _, _, err = New(&Config{StorageAccountName: testAccount, ContainerName: testContainer})
+	assert.EqualError(t, err, "one of azure storage_account_key, sas_token or use_managed_identity must be set")
+
+	container, err := containerURL(&Config{StorageAccountName: testAccount, ContainerName: testContainer, SASToken: testSAS})
+	require.NoError(t, err)
+	u := container.URL()
+	assert.Equal(t, "https://account.blob.core.windows.net/traces?"+testSAS, u.String())
+
+	_, err = containerURL(&Config{StorageAccountName: testAccount, ContainerName: testContainer, StorageAccountKey: "not base64!"})
+	assert.Error(t, err)
+
+	_, err = containerURL(&Config{StorageAccountName: testAccount, ContainerName: testContainer, StorageAccountKey: "a2V5"})
+	assert.NoError(t, err)
+
+}
@@ -0,0 +1,128 @@
+package azure
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/Azure/azure-storage-blob-go/azblob"
+	"github.com/google/uuid"
+
+	"github.com/grafana/tempo/tempodb/backend"
+	"github.com/grafana/tempo/tempodb/backend/util"
+	"github.com/grafana/tempo/tempodb/encoding"
+)
+
+func (rw *readerWriter) MarkBlockCompacted(blockID uuid.UUID, tenantID string) error {
+	// move meta file to a new location.  meta files are small so rewrite it instead of waiting on an async server side copy
+	metaFilename := util.MetaFileName(blockID, tenantID)
+	compactedMetaFilename := util.CompactedMetaFileName(blockID, tenantID)
+
+	ctx := context.TODO()
+	b, _, err := rw.readAll(ctx, metaFilename)
+	if err != nil {
+		return err
+	}
+
+	err = rw.writeAll(ctx, compactedMetaFilename, b)
+	if err != nil {
+		return err
+	}
+
+	_, err = rw.container.NewBlobURL(metaFilename).Delete(ctx, azblob.DeleteSnapshotsOptionInclude, azblob.BlobAccessConditions{})
+	return err
+}
+
+func (rw *readerWriter) ClearBlock(blockID uuid.UUID, tenantID string) error {
+	if len(tenantID) == 0 {
+		return fmt.Errorf("empty tenant id")
+	}
+
+	if blockID == uuid.Nil {
+		return fmt.Errorf("empty block id")
+	}
+
+	ctx := context.TODO()
+	for marker := (azblob.Marker{}); marker.NotDone(); {
+		resp, err := rw.container.ListBlobsFlatSegment(ctx, marker, azblob.ListBlobsSegmentOptions{
+			Prefix: util.RootPath(blockID, tenantID),
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, blob := range resp.Segment.BlobItems {
+			_, err = rw.container.NewBlobURL(blob.Name).Delete(ctx, azblob.DeleteSnapshotsOptionInclude, azblob.BlobAccessConditions{})
+			if err != nil && !isNotFound(err) {
+				return err
+			}
+		}
+		marker = resp.NextMarker
+	}
+
+	return nil
+}
+
+func (rw *readerWriter) CompactedBlockMeta(blockID uuid.UUID, tenantID string) (*encoding.CompactedBlockMeta, error) {
+	name := util.CompactedMetaFileName(blockID, tenantID)
+
+	bytes, modTime, err := rw.readAll(context.Background(), name)
+	if isNotFound(err) {
+		return nil, backend.ErrMetaDoesNotExist
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	out := &encoding.CompactedBlockMeta{}
+	err = json.Unmarshal(bytes, out)
+	if err != nil {
+		return nil, err
+	}
+	out.CompactedTime = modTime
+
+	return out, nil
+}
+
+func (rw *readerWriter) MarkTenantDeleted(tenantID string, mark *backend.TenantDeletionMark) error {
+	if len(tenantID) == 0 {
+		return backend.ErrEmptyTenantID
+	}
+
+	bytes, err := json.Marshal(mark)
+	if err != nil {
+		return err
+	}
+
+	return rw.writeAll(context.TODO(), util.TenantDeletionMarkFileName(tenantID), bytes)
+}
+
+func (rw *readerWriter) TenantDeletionMark(tenantID string) (*backend.TenantDeletionMark, error) {
+	bytes, _, err := rw.readAll(context.TODO(), util.TenantDeletionMarkFileName(tenantID))
+	if isNotFound(err) {
+		return nil, backend.ErrDeletionMarkDoesNotExist
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	out := &backend.TenantDeletionMark{}
+	err = json.Unmarshal(bytes, out)
+	if err != nil {
+		return nil, err
+	}
+
+	return out, nil
+}
+
+func (rw *readerWriter) ClearTenantDeletionMark(tenantID string) error {
+	if len(tenantID) == 0 {
+		return backend.ErrEmptyTenantID
+	}
+
+	_, err := rw.container.NewBlobURL(util.TenantDeletionMarkFileName(tenantID)).Delete(context.TODO(), azblob.DeleteSnapshotsOptionInclude, azblob.BlobAccessConditions{})
+	if err != nil && !isNotFound(err) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,17 @@
+package azure
+
+type Config struct {
+	StorageAccountName string `yaml:"storage_account_name"`
+	ContainerName      string `yaml:"container_name"`
+	// Endpoint is the blob service url.  Defaults to https://<storage_account_name>.blob.core.windows.net
+	Endpoint string `yaml:"endpoint"`
+
+	// exactly one of the key, the sas token or managed identity is used to authenticate.  in that order
+	StorageAccountKey  string `yaml:"storage_account_key"`
+	SASToken           string `yaml:"sas_token"`
+	UseManagedIdentity bool   `yaml:"use_managed_identity"`
+	UserAssignedID     string `yaml:"user_assigned_id"` // client id of a user assigned identity.  the system assigned identity is used if empty
+
+	BufferSize  int `yaml:"buffer_size"` // block size used to upload object files
+	Parallelism int `yaml:"parallelism"` // blocks uploaded in parallel
+}
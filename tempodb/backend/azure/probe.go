@@ -0,0 +1,43 @@
+package azure
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/Azure/azure-storage-blob-go/azblob"
+)
+
+// WriteProbe implements backend.Prober
+func (rw *readerWriter) WriteProbe(ctx context.Context, name string, b []byte) error {
+	return rw.probeError(rw.WriteObject(ctx, name, b))
+}
+
+// WriteObject implements backend.ObjectWriter
+func (rw *readerWriter) WriteObject(ctx context.Context, name string, b []byte) error {
+	return rw.writeAll(ctx, name, b)
+}
+
+// ReadProbe implements backend.Prober
+func (rw *readerWriter) ReadProbe(ctx context.Context, name string) ([]byte, time.Time, error) {
+	b, modTime, err := rw.readAll(ctx, name)
+	return b, modTime, rw.probeError(err)
+}
+
+// DeleteProbe implements backend.Prober
+func (rw *readerWriter) DeleteProbe(ctx context.Context, name string) error {
+	_, err := rw.container.NewBlobURL(name).Delete(ctx, azblob.DeleteSnapshotsOptionInclude, azblob.BlobAccessConditions{})
+	return rw.probeError(err)
+}
+
+func (rw *readerWriter) probeError(err error) error {
+	if serr, ok := err.(azblob.StorageError); ok {
+		switch serr.ServiceCode() {
+		case azblob.ServiceCodeContainerNotFound:
+			return fmt.Errorf("container %s does not exist: %w", rw.cfg.ContainerName, err)
+		case azblob.ServiceCodeAuthenticationFailed, azblob.ServiceCodeInsufficientAccountPermissions:
+			return fmt.Errorf("access to container %s denied: %w", rw.cfg.ContainerName, err)
+		}
+	}
+	return err
+}
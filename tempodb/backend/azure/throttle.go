@@ -0,0 +1,22 @@
+package azure
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/Azure/azure-storage-blob-go/azblob"
+)
+
+// Throttled implements backend.ThrottleDetector
+func (rw *readerWriter) Throttled(err error) bool {
+	var serr azblob.StorageError
+	if !errors.As(err, &serr) {
+		return false
+	}
+
+	if serr.ServiceCode() == azblob.ServiceCodeServerBusy {
+		return true
+	}
+	resp := serr.Response()
+	return resp != nil && (resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable)
+}
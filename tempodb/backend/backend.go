@@ -3,6 +3,7 @@ package backend
 import (
 	"context"
 	"fmt"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/grafana/tempo/tempodb/encoding"
@@ -12,15 +13,23 @@ var (
 	ErrMetaDoesNotExist = fmt.Errorf("meta does not exist")
 	ErrEmptyTenantID    = fmt.Errorf("empty tenant id")
 	ErrEmptyBlockID     = fmt.Errorf("empty block id")
+
+	ErrDeletionMarkDoesNotExist = fmt.Errorf("tenant deletion mark does not exist")
 )
 
 type AppendTracker interface{}
 
+// Writer writes blocks.  A block is committed by writing its meta last: until then its data, index, bloom and search
+// are staged and the block is invisible to readers, which read the meta first.  The local backend stages blocks in a
+// separate directory and moves them into place, object stores stage them under the block prefix.  Blocks whose write
+// was abandoned are listed by Reader.Blocks without a meta and cleared by the compactor's orphan gc.
 type Writer interface {
 	Write(ctx context.Context, meta *encoding.BlockMeta, bBloom [][]byte, bIndex []byte, objectFilePath string) error
 
 	WriteBlockMeta(ctx context.Context, tracker AppendTracker, meta *encoding.BlockMeta, bBloom [][]byte, bIndex []byte) error
 	AppendObject(ctx context.Context, tracker AppendTracker, meta *encoding.BlockMeta, bObject []byte) (AppendTracker, error)
+	// WriteSearch writes the search section of a block.  It is written before the block meta.
+	WriteSearch(ctx context.Context, meta *encoding.BlockMeta, bSearch []byte) error
 }
 
 type Reader interface {
@@ -29,13 +38,41 @@ type Reader interface {
 	BlockMeta(ctx context.Context, blockID uuid.UUID, tenantID string) (*encoding.BlockMeta, error)
 	Bloom(ctx context.Context, blockID uuid.UUID, tenantID string, bloomShard int) ([]byte, error)
 	Index(ctx context.Context, blockID uuid.UUID, tenantID string) ([]byte, error)
+	Search(ctx context.Context, blockID uuid.UUID, tenantID string) ([]byte, error)
 	Object(ctx context.Context, blockID uuid.UUID, tenantID string, offset uint64, buffer []byte) error
 
 	Shutdown()
 }
 
+// ThrottleDetector is implemented by backends that can tell a request was rejected because the backend is
+// throttling, e.g. an S3 SlowDown or an HTTP 429.
+type ThrottleDetector interface {
+	Throttled(err error) bool
+}
+
+// OrphanCleaner is implemented by backends that can leave objects behind that aren't part of any listed block, such as
+// the parts of an S3 multipart upload that was never completed.
+type OrphanCleaner interface {
+	// ClearOrphans removes the orphaned objects started before cutoff and returns how many were found.  Nothing is
+	// removed if dryRun is set.
+	ClearOrphans(ctx context.Context, cutoff time.Time, dryRun bool) (int, error)
+}
+
 type Compactor interface {
 	MarkBlockCompacted(blockID uuid.UUID, tenantID string) error
 	ClearBlock(blockID uuid.UUID, tenantID string) error
 	CompactedBlockMeta(blockID uuid.UUID, tenantID string) (*encoding.CompactedBlockMeta, error)
+
+	// MarkTenantDeleted writes the deletion mark of the tenant, replacing an earlier one.  The mark is written under the
+	// tenant but outside of its blocks, so it keeps the tenant listed without being listed as a block.
+	MarkTenantDeleted(tenantID string, mark *TenantDeletionMark) error
+	// TenantDeletionMark returns ErrDeletionMarkDoesNotExist if the tenant isn't marked for deletion.
+	TenantDeletionMark(tenantID string) (*TenantDeletionMark, error)
+	// ClearTenantDeletionMark removes the deletion mark once the blocks of the tenant are deleted.
+	ClearTenantDeletionMark(tenantID string) error
+}
+
+// TenantDeletionMark marks a tenant whose blocks the compactor deletes.
+type TenantDeletionMark struct {
+	RequestedTime time.Time `json:"requestedTime"`
 }
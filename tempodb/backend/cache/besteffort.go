@@ -0,0 +1,307 @@
+package cache
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/bradfitz/gomemcache/memcache"
+	cortex_cache "github.com/cortexproject/cortex/pkg/chunk/cache"
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const (
+	DefaultTimeout                = 250 * time.Millisecond
+	DefaultCircuitBreakerFailures = 10
+	DefaultCircuitBreakerDuration = 30 * time.Second
+
+	// maxPendingStores bounds the items waiting to be written to a cache.  Items are dropped when it's full.
+	maxPendingStores = 256
+)
+
+var (
+	metricCacheFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "tempodb",
+		Name:      "cache_failures_total",
+		Help:      "Total number of failed cache requests by reason.  Failed reads are treated as misses.",
+	}, []string{"name", "reason"})
+	metricCacheDropped = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "tempodb",
+		Name:      "cache_dropped_total",
+		Help:      "Total number of cache requests not sent to the cache by operation and reason.",
+	}, []string{"name", "operation", "reason"})
+	metricCacheCircuitOpen = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "tempodb",
+		Name:      "cache_circuit_open",
+		Help:      "1 while requests to the cache are stopped after repeated failures.",
+	}, []string{"name"})
+)
+
+// BestEffortConfig limits the impact of a remote cache outage.  Reads that take longer than Timeout are treated as
+// misses.  After CircuitBreakerFailures failures in a row the cache isn't used for CircuitBreakerDuration, then a
+// single request is let through to test it.  Zero values use the defaults.
+type BestEffortConfig struct {
+	Timeout                time.Duration `yaml:"timeout"`
+	CircuitBreakerFailures int           `yaml:"circuit_breaker_failures"`
+	CircuitBreakerDuration time.Duration `yaml:"circuit_breaker_duration"`
+}
+
+// BestEffort wraps a remote cache so it can only add latency up to a timeout and never fails a caller.  Writes are
+// queued and made in the background.
+type BestEffort struct {
+	next    cortex_cache.Cache
+	timeout time.Duration
+	breaker *CircuitBreaker
+
+	stores chan storeRequest
+	done   sync.WaitGroup
+}
+
+type storeRequest struct {
+	keys []string
+	bufs [][]byte
+}
+
+// NewBestEffort wraps next.  Caches sharing a client should share a breaker, and it should be the one passed to the
+// client so client errors open the circuit.  Metrics are labelled with the name of the breaker.
+func NewBestEffort(next cortex_cache.Cache, timeout time.Duration, breaker *CircuitBreaker) *BestEffort {
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+
+	c := &BestEffort{
+		next:    next,
+		timeout: timeout,
+		breaker: breaker,
+		stores:  make(chan storeRequest, maxPendingStores),
+	}
+
+	c.done.Add(1)
+	go c.storeLoop()
+
+	return c
+}
+
+// Fetch returns every key as missed if the cache is unavailable or doesn't answer within the timeout.
+func (c *BestEffort) Fetch(ctx context.Context, keys []string) (found []string, bufs [][]byte, missed []string) {
+	if !c.breaker.Allow() {
+		metricCacheDropped.WithLabelValues(c.breaker.name, "fetch", "circuit_open").Inc()
+		return nil, nil, keys
+	}
+
+	type result struct {
+		found  []string
+		bufs   [][]byte
+		missed []string
+	}
+
+	failures := c.breaker.Failures()
+	resultCh := make(chan result, 1)
+	go func() {
+		var res result
+		res.found, res.bufs, res.missed = c.next.Fetch(ctx, keys)
+		resultCh <- res
+	}()
+
+	timer := time.NewTimer(c.timeout)
+	defer timer.Stop()
+
+	select {
+	case res := <-resultCh:
+		c.breaker.SucceededSince(failures)
+		return res.found, res.bufs, res.missed
+	case <-timer.C:
+		metricCacheFailures.WithLabelValues(c.breaker.name, "timeout").Inc()
+		c.breaker.Fail()
+	case <-ctx.Done():
+		c.breaker.cancel()
+	}
+
+	return nil, nil, keys
+}
+
+// Store queues the items to be written and returns immediately.  Items are dropped if the queue is full or the cache
+// is unavailable.
+func (c *BestEffort) Store(_ context.Context, keys []string, bufs [][]byte) {
+	if c.breaker.Open() {
+		metricCacheDropped.WithLabelValues(c.breaker.name, "store", "circuit_open").Inc()
+		return
+	}
+
+	select {
+	case c.stores <- storeRequest{keys: keys, bufs: bufs}:
+	default:
+		metricCacheDropped.WithLabelValues(c.breaker.name, "store", "queue_full").Inc()
+	}
+}
+
+// Stop waits for queued writes and stops the wrapped cache.
+func (c *BestEffort) Stop() {
+	close(c.stores)
+	c.done.Wait()
+	c.next.Stop()
+}
+
+func (c *BestEffort) storeLoop() {
+	defer c.done.Done()
+
+	for req := range c.stores {
+		if !c.breaker.Allow() {
+			metricCacheDropped.WithLabelValues(c.breaker.name, "store", "circuit_open").Inc()
+			continue
+		}
+
+		failures := c.breaker.Failures()
+		// the caller's context may be gone by now
+		c.next.Store(context.Background(), req.keys, req.bufs)
+		c.breaker.SucceededSince(failures)
+	}
+}
+
+// CircuitBreaker stops requests to a cache after a number of failures in a row.  Failures are reported by BestEffort on
+// timeouts and by memcached clients on errors.  Redis errors are logged by the cortex client and not seen here.
+type CircuitBreaker struct {
+	name      string
+	threshold int
+	duration  time.Duration
+	logger    log.Logger
+
+	mtx         sync.Mutex
+	failures    uint64 // total, to tell if a request failed
+	consecutive int
+	openUntil   time.Time
+	probing     bool
+}
+
+// NewCircuitBreaker creates a breaker.  Zero values use the defaults.
+func NewCircuitBreaker(name string, failures int, duration time.Duration, logger log.Logger) *CircuitBreaker {
+	if failures <= 0 {
+		failures = DefaultCircuitBreakerFailures
+	}
+	if duration <= 0 {
+		duration = DefaultCircuitBreakerDuration
+	}
+
+	metricCacheCircuitOpen.WithLabelValues(name).Set(0)
+	return &CircuitBreaker{
+		name:      name,
+		threshold: failures,
+		duration:  duration,
+		logger:    logger,
+	}
+}
+
+// Allow returns false while the circuit is open.  Once it has been open for the duration one request at a time is
+// allowed until one succeeds.
+func (b *CircuitBreaker) Allow() bool {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	if b.consecutive < b.threshold {
+		return true
+	}
+	if time.Now().Before(b.openUntil) || b.probing {
+		return false
+	}
+
+	b.probing = true
+	return true
+}
+
+// Open returns true while requests are stopped.  Unlike Allow it doesn't let a request through to test the cache.
+func (b *CircuitBreaker) Open() bool {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	return b.consecutive >= b.threshold && time.Now().Before(b.openUntil)
+}
+
+// cancel lets another request test the cache if an allowed request was abandoned without a result.
+func (b *CircuitBreaker) cancel() {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	b.probing = false
+}
+
+// Fail records a failed request.
+func (b *CircuitBreaker) Fail() {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	b.failures++
+	b.consecutive++
+	if b.consecutive < b.threshold {
+		return
+	}
+
+	if b.consecutive == b.threshold || b.probing {
+		level.Warn(b.logger).Log("msg", "cache is failing.  stopping requests", "name", b.name, "duration", b.duration)
+	}
+	b.probing = false
+	b.openUntil = time.Now().Add(b.duration)
+	metricCacheCircuitOpen.WithLabelValues(b.name).Set(1)
+}
+
+// Failures returns the total number of failures for use with SucceededSince.
+func (b *CircuitBreaker) Failures() uint64 {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	return b.failures
+}
+
+// SucceededSince records a successful request if there have been no failures since Failures returned failures.
+func (b *CircuitBreaker) SucceededSince(failures uint64) {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	if b.failures != failures {
+		return
+	}
+
+	if b.consecutive >= b.threshold {
+		level.Info(b.logger).Log("msg", "cache recovered.  resuming requests", "name", b.name)
+	}
+	b.consecutive = 0
+	b.probing = false
+	metricCacheCircuitOpen.WithLabelValues(b.name).Set(0)
+}
+
+// NewMemcachedClient creates a memcached client that reports errors to breaker.
+func NewMemcachedClient(cfg cortex_cache.MemcachedClientConfig, name string, breaker *CircuitBreaker, reg prometheus.Registerer, logger log.Logger) cortex_cache.MemcachedClient {
+	return &memcachedClient{
+		MemcachedClient: cortex_cache.NewMemcachedClient(cfg, name, reg, logger),
+		breaker:         breaker,
+	}
+}
+
+// memcachedClient reports errors to the circuit breaker.
+type memcachedClient struct {
+	cortex_cache.MemcachedClient
+	breaker *CircuitBreaker
+}
+
+func (c *memcachedClient) GetMulti(keys []string) (map[string]*memcache.Item, error) {
+	items, err := c.MemcachedClient.GetMulti(keys)
+	c.report(err)
+	return items, err
+}
+
+func (c *memcachedClient) Set(item *memcache.Item) error {
+	err := c.MemcachedClient.Set(item)
+	if err != memcache.ErrNotStored {
+		c.report(err)
+	}
+	return err
+}
+
+func (c *memcachedClient) report(err error) {
+	if err != nil && err != memcache.ErrCacheMiss {
+		metricCacheFailures.WithLabelValues(c.breaker.name, "error").Inc()
+		c.breaker.Fail()
+	}
+}
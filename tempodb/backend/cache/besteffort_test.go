@@ -0,0 +1,173 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bradfitz/gomemcache/memcache"
+	cortex_cache "github.com/cortexproject/cortex/pkg/chunk/cache"
+	"github.com/go-kit/kit/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// slowCache delays every request until unblocked
+type slowCache struct {
+	cortex_cache.Cache
+	unblock chan struct{}
+
+	mtx     sync.Mutex
+	fetches int
+}
+
+func (c *slowCache) Fetch(ctx context.Context, keys []string) ([]string, [][]byte, []string) {
+	c.mtx.Lock()
+	c.fetches++
+	c.mtx.Unlock()
+
+	<-c.unblock
+	return c.Cache.Fetch(ctx, keys)
+}
+
+func (c *slowCache) Store(ctx context.Context, keys []string, bufs [][]byte) {
+	<-c.unblock
+	c.Cache.Store(ctx, keys, bufs)
+}
+
+func (c *slowCache) Stop() {}
+
+func (c *slowCache) fetchCount() int {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	return c.fetches
+}
+
+func TestBestEffortTimeouts(t *testing.T) {
+	next := &slowCache{
+		Cache:   cortex_cache.NewMockCache(),
+		unblock: make(chan struct{}),
+	}
+	breaker := NewCircuitBreaker("test", 3, 50*time.Millisecond, log.NewNopLogger())
+	c := NewBestEffort(next, 10*time.Millisecond, breaker)
+
+	// slow reads are misses and open the circuit
+	for i := 0; i < 3; i++ {
+		found, _, missed := c.Fetch(context.Background(), []string{"key"})
+		assert.Empty(t, found)
+		assert.Equal(t, []string{"key"}, missed)
+	}
+	assert.True(t, breaker.Open())
+	assert.Equal(t, 3, next.fetchCount())
+
+	_, _, missed := c.Fetch(context.Background(), []string{"key"})
+	assert.Equal(t, []string{"key"}, missed)
+	assert.Equal(t, 3, next.fetchCount())
+
+	// once the cache recovers a single read tests it and closes the circuit
+	close(next.unblock)
+	time.Sleep(60 * time.Millisecond)
+	assert.False(t, breaker.Open())
+
+	c.Store(context.Background(), []string{"key"}, [][]byte{[]byte("value")})
+	c.Stop()
+
+	found, bufs, _ := c.Fetch(context.Background(), []string{"key"})
+	assert.Equal(t, []string{"key"}, found)
+	assert.Equal(t, [][]byte{[]byte("value")}, bufs)
+	assert.True(t, breaker.Allow())
+}
+
+func TestBestEffortStoreDoesNotBlock(t *testing.T) {
+	next := &slowCache{
+		Cache:   cortex_cache.NewMockCache(),
+		unblock: make(chan struct{}),
+	}
+	c := NewBestEffort(next, 10*time.Millisecond, NewCircuitBreaker("test", 3, time.Minute, log.NewNopLogger()))
+
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < maxPendingStores*2; i++ {
+			c.Store(context.Background(), []string{"key"}, [][]byte{[]byte("value")})
+		}
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("store blocked on the cache")
+	}
+
+	close(next.unblock)
+	c.Stop()
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	b := NewCircuitBreaker("test", 2, 20*time.Millisecond, log.NewNopLogger())
+
+	// a success resets the failures
+	b.Fail()
+	b.SucceededSince(b.Failures())
+	b.Fail()
+	assert.True(t, b.Allow())
+
+	// a request that saw a failure doesn't count as a success
+	failures := b.Failures()
+	b.Fail()
+	b.SucceededSince(failures)
+	assert.True(t, b.Open())
+	assert.False(t, b.Allow())
+
+	// one request at a time tests the cache
+	time.Sleep(30 * time.Millisecond)
+	assert.False(t, b.Open())
+	assert.True(t, b.Allow())
+	assert.False(t, b.Allow())
+
+	// a failed test reopens the circuit
+	b.Fail()
+	assert.True(t, b.Open())
+
+	time.Sleep(30 * time.Millisecond)
+	require.True(t, b.Allow())
+	b.SucceededSince(b.Failures())
+	assert.False(t, b.Open())
+	assert.True(t, b.Allow())
+	assert.True(t, b.Allow())
+}
+
+type mockMemcachedClient struct {
+	err error
+}
+
+func (m *mockMemcachedClient) GetMulti(keys []string) (map[string]*memcache.Item, error) {
+	return nil, m.err
+}
+
+func (m *mockMemcachedClient) Set(item *memcache.Item) error {
+	return m.err
+}
+
+func TestMemcachedClientReportsErrors(t *testing.T) {
+	b := NewCircuitBreaker("test", 10, time.Minute, log.NewNopLogger())
+	next := &mockMemcachedClient{}
+	client := &memcachedClient{
+		MemcachedClient: next,
+		breaker:         b,
+	}
+
+	_, _ = client.GetMulti([]string{"key"})
+	next.err = memcache.ErrCacheMiss
+	_, _ = client.GetMulti([]string{"key"})
+	next.err = memcache.ErrNotStored
+	_ = client.Set(&memcache.Item{})
+	assert.Equal(t, uint64(0), b.Failures())
+
+	next.err = errors.New("connection refused")
+	_, _ = client.GetMulti([]string{"key"})
+	_ = client.Set(&memcache.Item{})
+	assert.Equal(t, uint64(2), b.Failures())
+}
@@ -0,0 +1,265 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	cortex_cache "github.com/cortexproject/cortex/pkg/chunk/cache"
+	"github.com/go-kit/kit/log"
+	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"github.com/grafana/tempo/tempodb/backend"
+	"github.com/grafana/tempo/tempodb/encoding"
+)
+
+const (
+	typeBloom  = "bloom"
+	typeIndex  = "index"
+	typeObject = "object"
+)
+
+var (
+	metricCache = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "tempodb",
+		Name:      "backend_cache_total",
+		Help:      "Total number of times the backend cache was queried.",
+	}, []string{"type", "status"})
+	metricCacheSkipped = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "tempodb",
+		Name:      "backend_cache_skipped_total",
+		Help:      "Total number of items not cached because they exceeded the max item size.",
+	}, []string{"type"})
+)
+
+type readerWriter struct {
+	nextReader backend.Reader
+	nextWriter backend.Writer
+
+	blooms  cortex_cache.Cache
+	indexes cortex_cache.Cache
+	objects cortex_cache.Cache // nil if objects aren't cached
+
+	maxItemSize int
+	logger      log.Logger
+}
+
+// New wraps the backend with a cache of bloom filters, indexes and optionally object pages.  Bloom filters and
+// indexes are also cached as blocks are written.
+func New(nextReader backend.Reader, nextWriter backend.Writer, cfg *Config, logger log.Logger) (backend.Reader, backend.Writer, error) {
+	newCache, err := cacheFactory(cfg, logger)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	rw := &readerWriter{
+		nextReader:  nextReader,
+		nextWriter:  nextWriter,
+		blooms:      newCache(typeBloom, cfg.BloomTTL),
+		indexes:     newCache(typeIndex, cfg.IndexTTL),
+		maxItemSize: cfg.MaxItemSize,
+		logger:      logger,
+	}
+	if cfg.CacheObjects {
+		rw.objects = newCache(typeObject, cfg.ObjectTTL)
+	}
+
+	return rw, rw, nil
+}
+
+// cacheFactory returns a func creating a cache for one type of item.  Memcached caches share a client.  The caches of a
+// backend share a circuit breaker so an outage stops requests for every type of item.
+func cacheFactory(cfg *Config, logger log.Logger) (func(t string, ttl time.Duration) cortex_cache.Cache, error) {
+	switch cfg.Backend {
+	case BackendMemcached:
+		if cfg.Memcached == nil {
+			return nil, fmt.Errorf("cache backend %s requires memcached config", cfg.Backend)
+		}
+
+		clientCfg := *cfg.Memcached
+		if clientCfg.MaxIdleConns == 0 {
+			clientCfg.MaxIdleConns = 16
+		}
+		if clientCfg.Timeout == 0 {
+			clientCfg.Timeout = 100 * time.Millisecond
+		}
+		if clientCfg.UpdateInterval == 0 {
+			clientCfg.UpdateInterval = time.Minute
+		}
+		breaker := NewCircuitBreaker(cfg.Backend, cfg.BestEffort.CircuitBreakerFailures, cfg.BestEffort.CircuitBreakerDuration, logger)
+		client := NewMemcachedClient(clientCfg, "tempo", breaker, prometheus.DefaultRegisterer, logger)
+
+		return func(t string, ttl time.Duration) cortex_cache.Cache {
+			memcachedCfg := cortex_cache.MemcachedConfig{
+				Expiration:  ttl,
+				BatchSize:   0, // one key is requested at a time
+				Parallelism: 0,
+			}
+			memcached := cortex_cache.NewMemcached(memcachedCfg, client, "tempo-"+t, prometheus.DefaultRegisterer, logger)
+			return NewBestEffort(memcached, cfg.BestEffort.Timeout, breaker)
+		}, nil
+
+	case BackendRedis:
+		if cfg.Redis == nil {
+			return nil, fmt.Errorf("cache backend %s requires redis config", cfg.Backend)
+		}
+		ttls := []time.Duration{cfg.BloomTTL, cfg.IndexTTL}
+		if cfg.CacheObjects {
+			ttls = append(ttls, cfg.ObjectTTL)
+		}
+		for _, ttl := range ttls {
+			if ttl < time.Second {
+				return nil, fmt.Errorf("cache backend %s requires ttls of at least 1s", cfg.Backend)
+			}
+		}
+
+		// each cache has its own connection pool
+		breaker := NewCircuitBreaker(cfg.Backend, cfg.BestEffort.CircuitBreakerFailures, cfg.BestEffort.CircuitBreakerDuration, logger)
+		return func(t string, ttl time.Duration) cortex_cache.Cache {
+			redisCfg := *cfg.Redis
+			redisCfg.Expiration = ttl
+			return NewBestEffort(cortex_cache.NewRedisCache(redisCfg, "tempo-"+t, nil, logger), cfg.BestEffort.Timeout, breaker)
+		}, nil
+	}
+
+	return nil, fmt.Errorf("unknown cache backend %s", cfg.Backend)
+}
+
+// Reader
+func (r *readerWriter) Tenants(ctx context.Context) ([]string, error) {
+	return r.nextReader.Tenants(ctx)
+}
+
+func (r *readerWriter) Blocks(ctx context.Context, tenantID string) ([]uuid.UUID, error) {
+	return r.nextReader.Blocks(ctx, tenantID)
+}
+
+func (r *readerWriter) BlockMeta(ctx context.Context, blockID uuid.UUID, tenantID string) (*encoding.BlockMeta, error) {
+	return r.nextReader.BlockMeta(ctx, blockID, tenantID)
+}
+
+func (r *readerWriter) Bloom(ctx context.Context, blockID uuid.UUID, tenantID string, shardNum int) ([]byte, error) {
+	key := bloomKey(blockID, tenantID, shardNum)
+	val := r.get(ctx, r.blooms, typeBloom, key)
+	if val != nil {
+		return val, nil
+	}
+
+	val, err := r.nextReader.Bloom(ctx, blockID, tenantID, shardNum)
+	if err == nil {
+		r.set(ctx, r.blooms, typeBloom, key, val)
+	}
+
+	return val, err
+}
+
+func (r *readerWriter) Index(ctx context.Context, blockID uuid.UUID, tenantID string) ([]byte, error) {
+	key := key(blockID, tenantID, typeIndex)
+	val := r.get(ctx, r.indexes, typeIndex, key)
+	if val != nil {
+		return val, nil
+	}
+
+	val, err := r.nextReader.Index(ctx, blockID, tenantID)
+	if err == nil {
+		r.set(ctx, r.indexes, typeIndex, key, val)
+	}
+
+	return val, err
+}
+
+func (r *readerWriter) Search(ctx context.Context, blockID uuid.UUID, tenantID string) ([]byte, error) {
+	// search sections are only read by searches and are not cached
+	return r.nextReader.Search(ctx, blockID, tenantID)
+}
+
+func (r *readerWriter) Object(ctx context.Context, blockID uuid.UUID, tenantID string, start uint64, buffer []byte) error {
+	if r.objects == nil {
+		return r.nextReader.Object(ctx, blockID, tenantID, start, buffer)
+	}
+
+	key := objectKey(blockID, tenantID, start, len(buffer))
+	val := r.get(ctx, r.objects, typeObject, key)
+	if len(val) == len(buffer) {
+		copy(buffer, val)
+		return nil
+	}
+
+	err := r.nextReader.Object(ctx, blockID, tenantID, start, buffer)
+	if err == nil {
+		// buffer belongs to the caller
+		r.set(ctx, r.objects, typeObject, key, append([]byte(nil), buffer...))
+	}
+
+	return err
+}
+
+func (r *readerWriter) Shutdown() {
+	r.nextReader.Shutdown()
+	r.blooms.Stop()
+	r.indexes.Stop()
+	if r.objects != nil {
+		r.objects.Stop()
+	}
+}
+
+// Writer
+func (r *readerWriter) Write(ctx context.Context, meta *encoding.BlockMeta, bBloom [][]byte, bIndex []byte, objectFilePath string) error {
+	r.setBlock(ctx, meta, bBloom, bIndex)
+
+	return r.nextWriter.Write(ctx, meta, bBloom, bIndex, objectFilePath)
+}
+
+func (r *readerWriter) WriteBlockMeta(ctx context.Context, tracker backend.AppendTracker, meta *encoding.BlockMeta, bBloom [][]byte, bIndex []byte) error {
+	r.setBlock(ctx, meta, bBloom, bIndex)
+
+	return r.nextWriter.WriteBlockMeta(ctx, tracker, meta, bBloom, bIndex)
+}
+
+func (r *readerWriter) WriteSearch(ctx context.Context, meta *encoding.BlockMeta, bSearch []byte) error {
+	return r.nextWriter.WriteSearch(ctx, meta, bSearch)
+}
+
+func (r *readerWriter) AppendObject(ctx context.Context, tracker backend.AppendTracker, meta *encoding.BlockMeta, bObject []byte) (backend.AppendTracker, error) {
+	return r.nextWriter.AppendObject(ctx, tracker, meta, bObject)
+}
+
+func (r *readerWriter) setBlock(ctx context.Context, meta *encoding.BlockMeta, bBloom [][]byte, bIndex []byte) {
+	for i, b := range bBloom {
+		r.set(ctx, r.blooms, typeBloom, bloomKey(meta.BlockID, meta.TenantID, i), b)
+	}
+	r.set(ctx, r.indexes, typeIndex, key(meta.BlockID, meta.TenantID, typeIndex), bIndex)
+}
+
+func (r *readerWriter) get(ctx context.Context, c cortex_cache.Cache, t string, key string) []byte {
+	found, vals, _ := c.Fetch(ctx, []string{key})
+	if len(found) > 0 {
+		metricCache.WithLabelValues(t, "hit").Inc()
+		return vals[0]
+	}
+	metricCache.WithLabelValues(t, "miss").Inc()
+	return nil
+}
+
+func (r *readerWriter) set(ctx context.Context, c cortex_cache.Cache, t string, key string, val []byte) {
+	if r.maxItemSize > 0 && len(val) > r.maxItemSize {
+		metricCacheSkipped.WithLabelValues(t).Inc()
+		return
+	}
+	c.Store(ctx, []string{key}, [][]byte{val})
+}
+
+func key(blockID uuid.UUID, tenantID string, t string) string {
+	return blockID.String() + ":" + tenantID + ":" + t
+}
+
+func bloomKey(blockID uuid.UUID, tenantID string, shardNum int) string {
+	return key(blockID, tenantID, typeBloom) + strconv.Itoa(shardNum)
+}
+
+func objectKey(blockID uuid.UUID, tenantID string, start uint64, length int) string {
+	return key(blockID, tenantID, typeObject) + ":" + strconv.FormatUint(start, 10) + ":" + strconv.Itoa(length)
+}
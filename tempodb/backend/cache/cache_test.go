@@ -1,17 +1,18 @@
-package memcached
+package cache
 
 import (
 	"context"
 	"testing"
 
-	"github.com/bradfitz/gomemcache/memcache"
-	"github.com/cortexproject/cortex/pkg/chunk/cache"
+	"time"
+
+	cortex_cache "github.com/cortexproject/cortex/pkg/chunk/cache"
 	"github.com/go-kit/kit/log"
 	"github.com/google/uuid"
 	"github.com/grafana/tempo/tempodb/backend"
 	"github.com/grafana/tempo/tempodb/encoding"
-	"github.com/prometheus/client_golang/prometheus"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 type mockReader struct {
@@ -38,6 +39,9 @@ func (m *mockReader) Bloom(ctx context.Context, blockID uuid.UUID, tenantID stri
 func (m *mockReader) Index(ctx context.Context, blockID uuid.UUID, tenantID string) ([]byte, error) {
 	return m.index, nil
 }
+func (m *mockReader) Search(ctx context.Context, blockID uuid.UUID, tenantID string) ([]byte, error) {
+	return nil, nil
+}
 func (m *mockReader) Object(ctx context.Context, blockID uuid.UUID, tenantID string, offset uint64, buffer []byte) error {
 	copy(buffer, m.object)
 	return nil
@@ -53,28 +57,23 @@ func (m *mockWriter) Write(ctx context.Context, meta *encoding.BlockMeta, bBloom
 func (m *mockWriter) WriteBlockMeta(ctx context.Context, tracker backend.AppendTracker, meta *encoding.BlockMeta, bBloom [][]byte, bIndex []byte) error {
 	return nil
 }
+func (m *mockWriter) WriteSearch(ctx context.Context, meta *encoding.BlockMeta, bSearch []byte) error {
+	return nil
+}
 func (m *mockWriter) AppendObject(ctx context.Context, tracker backend.AppendTracker, meta *encoding.BlockMeta, bObject []byte) (backend.AppendTracker, error) {
 	return nil, nil
 }
 
-type mockCache struct {
-	stuff map[string]*memcache.Item
-}
-
-func (m *mockCache) GetMulti(keys []string) (map[string]*memcache.Item, error) {
-	retStuff := make(map[string]*memcache.Item)
-	for k, v := range m.stuff {
-		if k == keys[0] { // we only ever request one key at a time :(
-			retStuff[k] = v
-			break
-		}
+func newTestReaderWriter(r backend.Reader, maxItemSize int) *readerWriter {
+	return &readerWriter{
+		nextReader:  r,
+		nextWriter:  &mockWriter{},
+		blooms:      cortex_cache.NewMockCache(),
+		indexes:     cortex_cache.NewMockCache(),
+		objects:     cortex_cache.NewMockCache(),
+		maxItemSize: maxItemSize,
+		logger:      log.NewNopLogger(),
 	}
-
-	return m.stuff, nil
-}
-func (m *mockCache) Set(item *memcache.Item) error {
-	m.stuff[item.Key] = item
-	return nil
 }
 
 func TestCache(t *testing.T) {
@@ -134,18 +133,7 @@ func TestCache(t *testing.T) {
 				index:   tt.readerIndex,
 				object:  tt.readerObject,
 			}
-			mockW := &mockWriter{}
-			mockC := &mockCache{
-				stuff: make(map[string]*memcache.Item),
-			}
-
-			logger := log.NewNopLogger()
-			rw := &readerWriter{
-				client:     cache.NewMemcached(cache.MemcachedConfig{}, mockC, "tempo", prometheus.NewRegistry(), logger),
-				nextReader: mockR,
-				nextWriter: mockW,
-				logger:     logger,
-			}
+			rw := newTestReaderWriter(mockR, 0)
 
 			ctx := context.Background()
 			tenants, _ := rw.Tenants(ctx)
@@ -168,6 +156,7 @@ func TestCache(t *testing.T) {
 			// clear reader and re-request.  things should be cached!
 			mockR.bloom = nil
 			mockR.index = nil
+			mockR.object = nil
 			mockR.tenants = nil
 			mockR.blocks = nil
 			mockR.meta = nil
@@ -176,6 +165,11 @@ func TestCache(t *testing.T) {
 			assert.Equal(t, tt.expectedBloom, bloom)
 			index, _ = rw.Index(ctx, blockID, tenantID)
 			assert.Equal(t, tt.expectedIndex, index)
+			if tt.expectedObject != nil {
+				object := make([]byte, 1)
+				_ = rw.Object(ctx, blockID, tenantID, 0, object)
+				assert.Equal(t, tt.expectedObject, object)
+			}
 
 			// others should be nil
 			tenants, _ = rw.Tenants(ctx)
@@ -187,3 +181,84 @@ func TestCache(t *testing.T) {
 		})
 	}
 }
+
+func TestCacheMaxItemSize(t *testing.T) {
+	ctx := context.Background()
+	blockID := uuid.New()
+	mockR := &mockReader{
+		bloom: []byte{0x01},
+		index: []byte{0x01, 0x02},
+	}
+	rw := newTestReaderWriter(mockR, 1)
+
+	_, _ = rw.Bloom(ctx, blockID, "test", 0)
+	_, _ = rw.Index(ctx, blockID, "test")
+	mockR.bloom = nil
+	mockR.index = nil
+
+	bloom, _ := rw.Bloom(ctx, blockID, "test", 0)
+	assert.Equal(t, []byte{0x01}, bloom)
+	index, _ := rw.Index(ctx, blockID, "test")
+	assert.Nil(t, index)
+}
+
+func TestCacheObjectPages(t *testing.T) {
+	ctx := context.Background()
+	blockID := uuid.New()
+	mockR := &mockReader{
+		object: []byte{0x01, 0x02, 0x03},
+	}
+	rw := newTestReaderWriter(mockR, 0)
+
+	buffer := make([]byte, 3)
+	require.NoError(t, rw.Object(ctx, blockID, "test", 10, buffer))
+	buffer[0] = 0xff // the cached page is a copy
+
+	// the same page is cached, a page of a different length is not
+	mockR.object = []byte{0x04, 0x05, 0x06}
+	buffer = make([]byte, 3)
+	require.NoError(t, rw.Object(ctx, blockID, "test", 10, buffer))
+	assert.Equal(t, []byte{0x01, 0x02, 0x03}, buffer)
+
+	buffer = make([]byte, 2)
+	require.NoError(t, rw.Object(ctx, blockID, "test", 10, buffer))
+	assert.Equal(t, []byte{0x04, 0x05}, buffer)
+
+	// objects aren't cached unless enabled
+	rw.objects = nil
+	buffer = make([]byte, 3)
+	require.NoError(t, rw.Object(ctx, blockID, "test", 10, buffer))
+	assert.Equal(t, []byte{0x04, 0x05, 0x06}, buffer)
+}
+
+func TestNewConfigErrors(t *testing.T) {
+	logger := log.NewNopLogger()
+
+	_, _, err := New(&mockReader{}, &mockWriter{}, &Config{Backend: "nope"}, logger)
+	assert.EqualError(t, err, "unknown cache backend nope")
+
+	_, _, err = New(&mockReader{}, &mockWriter{}, &Config{Backend: BackendMemcached}, logger)
+	assert.EqualError(t, err, "cache backend memcached requires memcached config")
+
+	_, _, err = New(&mockReader{}, &mockWriter{}, &Config{
+		Backend:  BackendRedis,
+		Redis:    &cortex_cache.RedisConfig{},
+		BloomTTL: time.Hour,
+		IndexTTL: time.Hour,
+		// the object ttl is only checked when objects are cached
+		CacheObjects: true,
+	}, logger)
+	assert.EqualError(t, err, "cache backend redis requires ttls of at least 1s")
+}
+
+func TestMemcachedConfig(t *testing.T) {
+	legacy := &MemcachedConfig{TTL: time.Hour}
+	legacy.ClientConfig.Host = "memcached"
+
+	cfg := legacy.Config()
+	assert.Equal(t, BackendMemcached, cfg.Backend)
+	assert.Equal(t, "memcached", cfg.Memcached.Host)
+	assert.Equal(t, time.Hour, cfg.BloomTTL)
+	assert.Equal(t, time.Hour, cfg.IndexTTL)
+	assert.False(t, cfg.CacheObjects)
+}
@@ -0,0 +1,51 @@
+package cache
+
+import (
+	"time"
+
+	cortex_cache "github.com/cortexproject/cortex/pkg/chunk/cache"
+)
+
+const (
+	BackendMemcached = "memcached"
+	BackendRedis     = "redis"
+)
+
+// Config caches reads of bloom filters, indexes and object pages from the backend in memcached or redis.  A TTL of 0
+// keeps items until they are evicted, which redis doesn't support.
+type Config struct {
+	Backend   string                              `yaml:"backend"`
+	Memcached *cortex_cache.MemcachedClientConfig `yaml:"memcached"`
+	Redis     *cortex_cache.RedisConfig           `yaml:"redis"`
+
+	BloomTTL  time.Duration `yaml:"bloom_ttl"`
+	IndexTTL  time.Duration `yaml:"index_ttl"`
+	ObjectTTL time.Duration `yaml:"object_ttl"`
+
+	// CacheObjects caches the pages of objects read when finding a trace, so repeated lookups of the same trace don't
+	// read the backend.
+	CacheObjects bool `yaml:"cache_objects"`
+	// MaxItemSize is the largest item in bytes stored.  0 stores items of any size.  Memcached rejects items over
+	// 1MB by default.
+	MaxItemSize int `yaml:"max_item_size"`
+
+	BestEffort BestEffortConfig `yaml:",inline"`
+}
+
+// MemcachedConfig is the deprecated top level memcached section of the storage config.  It caches bloom filters and
+// indexes with one TTL.
+type MemcachedConfig struct {
+	ClientConfig cortex_cache.MemcachedClientConfig `yaml:",inline"`
+
+	TTL time.Duration `yaml:"ttl"`
+}
+
+// Config returns the equivalent cache config.
+func (cfg *MemcachedConfig) Config() *Config {
+	return &Config{
+		Backend:   BackendMemcached,
+		Memcached: &cfg.ClientConfig,
+		BloomTTL:  cfg.TTL,
+		IndexTTL:  cfg.TTL,
+	}
+}
@@ -123,6 +123,10 @@ func (r *reader) Index(ctx context.Context, blockID uuid.UUID, tenantID string)
 	return b, err
 }
 
+func (r *reader) Search(ctx context.Context, blockID uuid.UUID, tenantID string) ([]byte, error) {
+	return r.next.Search(ctx, blockID, tenantID)
+}
+
 func (r *reader) Object(ctx context.Context, blockID uuid.UUID, tenantID string, start uint64, buffer []byte) error {
 	// not attempting to cache these...yet...
 	return r.next.Object(ctx, blockID, tenantID, start, buffer)
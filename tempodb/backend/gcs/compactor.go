@@ -84,3 +84,46 @@ func (rw *readerWriter) CompactedBlockMeta(blockID uuid.UUID, tenantID string) (
 
 	return out, err
 }
+
+func (rw *readerWriter) MarkTenantDeleted(tenantID string, mark *backend.TenantDeletionMark) error {
+	if len(tenantID) == 0 {
+		return backend.ErrEmptyTenantID
+	}
+
+	bytes, err := json.Marshal(mark)
+	if err != nil {
+		return err
+	}
+
+	return rw.writeAll(context.TODO(), util.TenantDeletionMarkFileName(tenantID), bytes)
+}
+
+func (rw *readerWriter) TenantDeletionMark(tenantID string) (*backend.TenantDeletionMark, error) {
+	bytes, err := rw.readAll(context.TODO(), util.TenantDeletionMarkFileName(tenantID))
+	if err == storage.ErrObjectNotExist {
+		return nil, backend.ErrDeletionMarkDoesNotExist
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	out := &backend.TenantDeletionMark{}
+	err = json.Unmarshal(bytes, out)
+	if err != nil {
+		return nil, err
+	}
+
+	return out, nil
+}
+
+func (rw *readerWriter) ClearTenantDeletionMark(tenantID string) error {
+	if len(tenantID) == 0 {
+		return backend.ErrEmptyTenantID
+	}
+
+	err := rw.bucket.Object(util.TenantDeletionMarkFileName(tenantID)).Delete(context.TODO())
+	if err == storage.ErrObjectNotExist {
+		return nil
+	}
+	return err
+}
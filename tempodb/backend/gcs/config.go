@@ -1,6 +1,10 @@
 package gcs
 
+import "time"
+
 type Config struct {
-	BucketName      string `yaml:"bucket_name"`
-	ChunkBufferSize int    `yaml:"chunk_buffer_size"`
+	BucketName        string        `yaml:"bucket_name"`
+	ChunkBufferSize   int           `yaml:"chunk_buffer_size"`
+	HedgeRequestsAt   time.Duration `yaml:"hedge_requests_at"`    // send another GET if the earlier ones haven't responded in this long.  0 disables hedging
+	HedgeRequestsUpTo int           `yaml:"hedge_requests_up_to"` // most GETs sent for a single read when hedging, including the first
 }
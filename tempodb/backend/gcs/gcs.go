@@ -11,7 +11,6 @@ import (
 	"time"
 
 	"github.com/grafana/tempo/tempodb/backend/util"
-	"github.com/grafana/tempo/tempodb/encoding/bloom"
 
 	"cloud.google.com/go/storage"
 	"github.com/google/uuid"
@@ -30,7 +29,7 @@ type readerWriter struct {
 func New(cfg *Config) (backend.Reader, backend.Writer, backend.Compactor, error) {
 	ctx := context.Background()
 
-	option, err := instrumentation(ctx, storage.ScopeReadWrite)
+	option, err := instrumentation(ctx, storage.ScopeReadWrite, cfg)
 	if err != nil {
 		return nil, nil, nil, err
 	}
@@ -90,8 +89,8 @@ func (rw *readerWriter) WriteBlockMeta(ctx context.Context, tracker backend.Appe
 	blockID := meta.BlockID
 	tenantID := meta.TenantID
 
-	for i := 0; i < bloom.GetShardNum(); i++ {
-		err := rw.writeAll(ctx, util.BloomFileName(blockID, tenantID, i), bBloom[i])
+	for i, b := range bBloom {
+		err := rw.writeAll(ctx, util.BloomFileName(blockID, tenantID, i), b)
 		if err != nil {
 			return err
 		}
@@ -116,6 +115,10 @@ func (rw *readerWriter) WriteBlockMeta(ctx context.Context, tracker backend.Appe
 	return nil
 }
 
+func (rw *readerWriter) WriteSearch(ctx context.Context, meta *encoding.BlockMeta, bSearch []byte) error {
+	return rw.writeAll(ctx, util.SearchFileName(meta.BlockID, meta.TenantID), bSearch)
+}
+
 func (rw *readerWriter) AppendObject(ctx context.Context, tracker backend.AppendTracker, meta *encoding.BlockMeta, bObject []byte) (backend.AppendTracker, error) {
 	var w *storage.Writer
 	if tracker == nil {
@@ -179,6 +182,11 @@ func (rw *readerWriter) Blocks(ctx context.Context, tenantID string) ([]uuid.UUI
 			continue
 		}
 
+		// objects directly under the tenant, like its deletion mark, aren't blocks
+		if attrs.Prefix == "" {
+			continue
+		}
+
 		idString := strings.TrimSuffix(strings.TrimPrefix(attrs.Prefix, tenantID+"/"), "/")
 		blockID, err := uuid.Parse(idString)
 		if err != nil {
@@ -228,6 +236,14 @@ func (rw *readerWriter) Index(ctx context.Context, blockID uuid.UUID, tenantID s
 	return rw.readAll(derivedCtx, name)
 }
 
+func (rw *readerWriter) Search(ctx context.Context, blockID uuid.UUID, tenantID string) ([]byte, error) {
+	span, derivedCtx := opentracing.StartSpanFromContext(ctx, "gcs.Search")
+	defer span.Finish()
+
+	name := util.SearchFileName(blockID, tenantID)
+	return rw.readAll(derivedCtx, name)
+}
+
 func (rw *readerWriter) Object(ctx context.Context, blockID uuid.UUID, tenantID string, start uint64, buffer []byte) error {
 	span, derivedCtx := opentracing.StartSpanFromContext(ctx, "gcs.Object")
 	defer span.Finish()
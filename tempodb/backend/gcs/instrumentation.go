@@ -6,6 +6,7 @@ import (
 	"strconv"
 	"time"
 
+	"github.com/grafana/tempo/tempodb/backend"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
 	"google.golang.org/api/option"
@@ -29,7 +30,7 @@ type instrumentedTransport struct {
 	next     http.RoundTripper
 }
 
-func instrumentation(ctx context.Context, scope string) (option.ClientOption, error) {
+func instrumentation(ctx context.Context, scope string, cfg *Config) (option.ClientOption, error) {
 	transport, err := google_http.NewTransport(ctx, http.DefaultTransport, option.WithScopes(scope))
 	if err != nil {
 		return nil, err
@@ -37,7 +38,7 @@ func instrumentation(ctx context.Context, scope string) (option.ClientOption, er
 	client := &http.Client{
 		Transport: instrumentedTransport{
 			observer: gcsRequestDuration,
-			next:     transport,
+			next:     backend.NewHedgedTransport("gcs", cfg.HedgeRequestsAt, cfg.HedgeRequestsUpTo, backend.NewTracingTransport("gcs", transport)),
 		},
 	}
 	return option.WithHTTPClient(client), nil
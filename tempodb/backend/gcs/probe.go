@@ -0,0 +1,45 @@
+package gcs
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"cloud.google.com/go/storage"
+)
+
+// WriteProbe implements backend.Prober
+func (rw *readerWriter) WriteProbe(ctx context.Context, name string, b []byte) error {
+	return rw.probeError(rw.WriteObject(ctx, name, b))
+}
+
+// WriteObject implements backend.ObjectWriter
+func (rw *readerWriter) WriteObject(ctx context.Context, name string, b []byte) error {
+	w := rw.writer(ctx, name)
+	_, err := w.Write(b)
+	if err != nil {
+		_ = w.Close()
+		return err
+	}
+
+	// gcs writes are committed on close
+	return w.Close()
+}
+
+// ReadProbe implements backend.Prober
+func (rw *readerWriter) ReadProbe(ctx context.Context, name string) ([]byte, time.Time, error) {
+	b, modTime, err := rw.readAllWithModTime(ctx, name)
+	return b, modTime, rw.probeError(err)
+}
+
+// DeleteProbe implements backend.Prober
+func (rw *readerWriter) DeleteProbe(ctx context.Context, name string) error {
+	return rw.probeError(rw.bucket.Object(name).Delete(ctx))
+}
+
+func (rw *readerWriter) probeError(err error) error {
+	if err == storage.ErrBucketNotExist {
+		return fmt.Errorf("bucket %s does not exist: %w", rw.cfg.BucketName, err)
+	}
+	return err
+}
@@ -0,0 +1,135 @@
+package backend
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var metricHedgedRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "tempodb",
+	Name:      "backend_hedged_roundtrips_total",
+	Help:      "Total number of hedged requests sent to the backend because the earlier ones were slow.",
+}, []string{"backend"})
+
+// hedgedTransport sends a duplicate GET to the backend if the earlier ones haven't responded within at, up to upTo
+// requests in total, and returns whichever response arrives first.  The other requests are cancelled.  Only GETs are
+// hedged as they have no body and no side effects.
+type hedgedTransport struct {
+	component string
+	at        time.Duration
+	upTo      int
+	next      http.RoundTripper
+}
+
+type hedgedResult struct {
+	resp *http.Response
+	err  error
+	idx  int
+}
+
+// NewHedgedTransport wraps next so slow GETs are hedged.  next is returned unchanged if at isn't positive or upTo is
+// less than 2.
+func NewHedgedTransport(component string, at time.Duration, upTo int, next http.RoundTripper) http.RoundTripper {
+	if at <= 0 || upTo < 2 {
+		return next
+	}
+
+	return &hedgedTransport{
+		component: component,
+		at:        at,
+		upTo:      upTo,
+		next:      next,
+	}
+}
+
+func (t *hedgedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method != http.MethodGet || req.Body != nil && req.Body != http.NoBody {
+		return t.next.RoundTrip(req)
+	}
+
+	results := make(chan hedgedResult, t.upTo)
+	cancels := make([]context.CancelFunc, 0, t.upTo)
+	send := func() {
+		ctx, cancel := context.WithCancel(req.Context())
+		idx := len(cancels)
+		cancels = append(cancels, cancel)
+		go func() {
+			resp, err := t.next.RoundTrip(req.Clone(ctx))
+			results <- hedgedResult{resp: resp, err: err, idx: idx}
+		}()
+	}
+
+	send()
+	pending := 1
+
+	timer := time.NewTimer(t.at)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-timer.C:
+			if len(cancels) < t.upTo {
+				metricHedgedRequests.WithLabelValues(t.component).Inc()
+				send()
+				pending++
+				timer.Reset(t.at)
+			}
+
+		case r := <-results:
+			pending--
+			if r.err != nil {
+				cancels[r.idx]()
+				// slow requests are hedged, failed ones are left to the retries of the client
+				if pending == 0 {
+					return nil, r.err
+				}
+				continue
+			}
+
+			// the requests that lost the race are cancelled
+			for i, cancel := range cancels {
+				if i != r.idx {
+					cancel()
+				}
+			}
+			go closeResponses(results, pending)
+
+			r.resp.Body = &cancelOnClose{ReadCloser: r.resp.Body, cancel: cancels[r.idx]}
+			return r.resp, nil
+
+		case <-req.Context().Done():
+			for _, cancel := range cancels {
+				cancel()
+			}
+			go closeResponses(results, pending)
+			return nil, req.Context().Err()
+		}
+	}
+}
+
+// closeResponses closes the responses of cancelled requests that still arrive.
+func closeResponses(results <-chan hedgedResult, pending int) {
+	for ; pending > 0; pending-- {
+		r := <-results
+		if r.resp != nil {
+			_ = r.resp.Body.Close()
+		}
+	}
+}
+
+// cancelOnClose releases the context of the winning request once its body is closed.
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (c *cancelOnClose) Close() error {
+	err := c.ReadCloser.Close()
+	c.cancel()
+	return err
+}
@@ -0,0 +1,80 @@
+package backend
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestHedgedTransport(t *testing.T) {
+	var mtx sync.Mutex
+	requests := 0
+	cancelled := make(chan struct{})
+
+	// the first request hangs until it's cancelled, the others answer right away
+	next := roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		mtx.Lock()
+		requests++
+		n := requests
+		mtx.Unlock()
+
+		if n == 1 {
+			<-req.Context().Done()
+			close(cancelled)
+			return nil, req.Context().Err()
+		}
+		return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(strings.NewReader("hedged"))}, nil
+	})
+
+	client := &http.Client{Transport: NewHedgedTransport("test", 10*time.Millisecond, 2, next)}
+	resp, err := client.Get("http://bucket/object")
+	require.NoError(t, err)
+	body, err := ioutil.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+	assert.Equal(t, "hedged", string(body))
+
+	select {
+	case <-cancelled:
+	case <-time.After(time.Second):
+		t.Fatal("slow request wasn't cancelled")
+	}
+	assert.Equal(t, 2, requests)
+
+	// other methods aren't hedged
+	resp, err = client.Post("http://bucket/object", "text/plain", strings.NewReader("object"))
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+	assert.Equal(t, 3, requests)
+}
+
+func TestHedgedTransportErrors(t *testing.T) {
+	requests := 0
+	next := roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		requests++
+		return nil, errors.New("unavailable")
+	})
+
+	// failed requests aren't hedged
+	client := &http.Client{Transport: NewHedgedTransport("test", time.Second, 3, next)}
+	_, err := client.Get("http://bucket/object")
+	assert.Error(t, err)
+	assert.Equal(t, 1, requests)
+
+	// hedging is disabled without a delay or with a single request
+	assert.IsType(t, next, NewHedgedTransport("test", 0, 3, next))
+	assert.IsType(t, next, NewHedgedTransport("test", time.Second, 1, next))
+}
@@ -0,0 +1,145 @@
+package inmemory
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/grafana/tempo/tempodb/backend"
+	"github.com/grafana/tempo/tempodb/encoding"
+)
+
+func (rw *readerWriter) MarkBlockCompacted(blockID uuid.UUID, tenantID string) error {
+	err := rw.faults.write(context.Background())
+	if err != nil {
+		return err
+	}
+
+	rw.mtx.Lock()
+	defer rw.mtx.Unlock()
+
+	// move meta to a new location
+	meta, err := rw.object(blockID, tenantID, metaFile)
+	if err != nil {
+		return err
+	}
+
+	b := rw.block(blockID, tenantID)
+	b.objects[compactedMetaFile] = meta
+	b.compactedTime = time.Now()
+	delete(b.objects, metaFile)
+
+	return nil
+}
+
+func (rw *readerWriter) ClearBlock(blockID uuid.UUID, tenantID string) error {
+	if len(tenantID) == 0 {
+		return backend.ErrEmptyTenantID
+	}
+
+	if blockID == uuid.Nil {
+		return backend.ErrEmptyBlockID
+	}
+
+	err := rw.faults.write(context.Background())
+	if err != nil {
+		return err
+	}
+
+	rw.mtx.Lock()
+	defer rw.mtx.Unlock()
+
+	delete(rw.tenants[tenantID], blockID)
+	if len(rw.tenants[tenantID]) == 0 {
+		delete(rw.tenants, tenantID)
+	}
+
+	return nil
+}
+
+func (rw *readerWriter) CompactedBlockMeta(blockID uuid.UUID, tenantID string) (*encoding.CompactedBlockMeta, error) {
+	err := rw.faults.read(context.Background())
+	if err != nil {
+		return nil, err
+	}
+
+	rw.mtx.RLock()
+	defer rw.mtx.RUnlock()
+
+	bytes, err := rw.object(blockID, tenantID, compactedMetaFile)
+	if err != nil {
+		return nil, err
+	}
+
+	out := &encoding.CompactedBlockMeta{}
+	err = json.Unmarshal(bytes, out)
+	if err != nil {
+		return nil, err
+	}
+	out.CompactedTime = rw.tenants[tenantID][blockID].compactedTime
+
+	return out, nil
+}
+
+func (rw *readerWriter) MarkTenantDeleted(tenantID string, mark *backend.TenantDeletionMark) error {
+	if len(tenantID) == 0 {
+		return backend.ErrEmptyTenantID
+	}
+
+	err := rw.faults.write(context.Background())
+	if err != nil {
+		return err
+	}
+
+	bytes, err := json.Marshal(mark)
+	if err != nil {
+		return err
+	}
+
+	rw.mtx.Lock()
+	defer rw.mtx.Unlock()
+
+	rw.deletionMarks[tenantID] = bytes
+	return nil
+}
+
+func (rw *readerWriter) TenantDeletionMark(tenantID string) (*backend.TenantDeletionMark, error) {
+	err := rw.faults.read(context.Background())
+	if err != nil {
+		return nil, err
+	}
+
+	rw.mtx.RLock()
+	bytes, ok := rw.deletionMarks[tenantID]
+	rw.mtx.RUnlock()
+	if !ok {
+		return nil, backend.ErrDeletionMarkDoesNotExist
+	}
+
+	out := &backend.TenantDeletionMark{}
+	err = json.Unmarshal(bytes, out)
+	if err != nil {
+		return nil, err
+	}
+
+	return out, nil
+}
+
+func (rw *readerWriter) ClearTenantDeletionMark(tenantID string) error {
+	if len(tenantID) == 0 {
+		return backend.ErrEmptyTenantID
+	}
+
+	err := rw.faults.write(context.Background())
+	if err != nil {
+		return err
+	}
+
+	rw.mtx.Lock()
+	defer rw.mtx.Unlock()
+
+	delete(rw.deletionMarks, tenantID)
+	return nil
+}
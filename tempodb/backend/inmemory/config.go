@@ -0,0 +1,14 @@
+package inmemory
+
+import "time"
+
+// Config configures the faults the inmemory backend injects.  The zero value injects none.
+type Config struct {
+	Latency          time.Duration `yaml:"latency"`            // added to every request
+	LatencyJitter    time.Duration `yaml:"latency_jitter"`     // up to this much random latency is added on top
+	ReadErrorRate    float64       `yaml:"read_error_rate"`    // fraction of reads that fail, 0 to 1
+	WriteErrorRate   float64       `yaml:"write_error_rate"`   // fraction of writes, compactions and deletes that fail, 0 to 1
+	PartialReadRate  float64       `yaml:"partial_read_rate"`  // fraction of successful reads that only return the first half of the data
+	CorruptWriteRate float64       `yaml:"corrupt_write_rate"` // fraction of successful writes of block data that silently store a flipped byte
+	Seed             int64         `yaml:"seed"`               // seed of the fault injection.  0 picks a random seed
+}
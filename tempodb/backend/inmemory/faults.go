@@ -0,0 +1,97 @@
+package inmemory
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// ErrInjected is returned by requests that failed because of an injected fault.
+var ErrInjected = errors.New("inmemory backend injected fault")
+
+// faults decides which requests are slowed down, fail or return partial data.
+type faults struct {
+	cfg Config
+
+	mtx  sync.Mutex
+	rand *rand.Rand
+}
+
+func newFaults(cfg Config) *faults {
+	seed := cfg.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+
+	return &faults{
+		cfg:  cfg,
+		rand: rand.New(rand.NewSource(seed)),
+	}
+}
+
+// read delays a read and returns ErrInjected if it should fail.
+func (f *faults) read(ctx context.Context) error {
+	return f.request(ctx, f.cfg.ReadErrorRate)
+}
+
+// write delays a write and returns ErrInjected if it should fail.
+func (f *faults) write(ctx context.Context) error {
+	return f.request(ctx, f.cfg.WriteErrorRate)
+}
+
+// partial returns true if a successful read should only return part of the data.
+func (f *faults) partial() bool {
+	return f.chance(f.cfg.PartialReadRate)
+}
+
+// corrupt returns the data with a random byte flipped if a successful write should store corrupt data.  The data isn't
+// changed in place.
+func (f *faults) corrupt(data []byte) []byte {
+	if len(data) == 0 || !f.chance(f.cfg.CorruptWriteRate) {
+		return data
+	}
+
+	f.mtx.Lock()
+	i := f.rand.Intn(len(data))
+	f.mtx.Unlock()
+
+	corrupted := append([]byte(nil), data...)
+	corrupted[i] ^= 0xff
+	return corrupted
+}
+
+func (f *faults) request(ctx context.Context, errorRate float64) error {
+	latency := f.cfg.Latency
+	if f.cfg.LatencyJitter > 0 {
+		f.mtx.Lock()
+		latency += time.Duration(f.rand.Int63n(int64(f.cfg.LatencyJitter)))
+		f.mtx.Unlock()
+	}
+
+	if latency > 0 {
+		timer := time.NewTimer(latency)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		}
+	}
+
+	if f.chance(errorRate) {
+		return ErrInjected
+	}
+	return nil
+}
+
+func (f *faults) chance(rate float64) bool {
+	if rate <= 0 {
+		return false
+	}
+
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+	return f.rand.Float64() < rate
+}
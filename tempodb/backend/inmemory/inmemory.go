@@ -0,0 +1,306 @@
+package inmemory
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/grafana/tempo/tempodb/backend"
+	"github.com/grafana/tempo/tempodb/encoding"
+)
+
+const (
+	metaFile          = "meta.json"
+	compactedMetaFile = "meta.compacted.json"
+	indexFile         = "index"
+	searchFile        = "search"
+	tracesFile        = "traces"
+)
+
+// readerWriter keeps blocks in memory.  It's meant for tests and single binary experiments: nothing survives a restart
+// and separate processes don't share blocks.  Like the other backends a block is only visible to readers once its meta
+// is written, and blocks without a meta are still listed.
+type readerWriter struct {
+	faults *faults
+
+	mtx           sync.RWMutex
+	tenants       map[string]map[uuid.UUID]*block
+	deletionMarks map[string][]byte
+	probes        map[string]probeObject
+}
+
+// block holds the objects of a block by name.
+type block struct {
+	objects       map[string][]byte
+	compactedTime time.Time
+}
+
+type probeObject struct {
+	data    []byte
+	modTime time.Time
+}
+
+// New returns an inmemory backend injecting the faults in cfg.  A nil cfg injects none.
+func New(cfg *Config) (backend.Reader, backend.Writer, backend.Compactor, error) {
+	if cfg == nil {
+		cfg = &Config{}
+	}
+	if cfg.ReadErrorRate < 0 || cfg.ReadErrorRate > 1 || cfg.WriteErrorRate < 0 || cfg.WriteErrorRate > 1 || cfg.PartialReadRate < 0 || cfg.PartialReadRate > 1 ||
+		cfg.CorruptWriteRate < 0 || cfg.CorruptWriteRate > 1 {
+		return nil, nil, nil, fmt.Errorf("inmemory backend fault rates must be between 0 and 1")
+	}
+
+	rw := &readerWriter{
+		faults:        newFaults(*cfg),
+		tenants:       map[string]map[uuid.UUID]*block{},
+		deletionMarks: map[string][]byte{},
+		probes:        map[string]probeObject{},
+	}
+
+	return rw, rw, rw, nil
+}
+
+// Write stores the block and commits it by writing its meta last.
+func (rw *readerWriter) Write(ctx context.Context, meta *encoding.BlockMeta, bBloom [][]byte, bIndex []byte, tracesFilePath string) error {
+	bTraces, err := ioutil.ReadFile(tracesFilePath)
+	if err != nil {
+		return err
+	}
+
+	err = rw.faults.write(ctx)
+	if err != nil {
+		return err
+	}
+
+	rw.putObject(meta.BlockID, meta.TenantID, tracesFile, rw.faults.corrupt(bTraces))
+
+	return rw.writeBlockMeta(meta, bBloom, bIndex)
+}
+
+// WriteBlockMeta writes the bloom, index and meta of a block whose objects were appended and commits it.
+func (rw *readerWriter) WriteBlockMeta(ctx context.Context, _ backend.AppendTracker, meta *encoding.BlockMeta, bBloom [][]byte, bIndex []byte) error {
+	err := rw.faults.write(ctx)
+	if err != nil {
+		return err
+	}
+
+	return rw.writeBlockMeta(meta, bBloom, bIndex)
+}
+
+func (rw *readerWriter) writeBlockMeta(meta *encoding.BlockMeta, bBloom [][]byte, bIndex []byte) error {
+	bMeta, err := json.Marshal(meta)
+	if err != nil {
+		return err
+	}
+
+	for i, b := range bBloom {
+		rw.putObject(meta.BlockID, meta.TenantID, bloomFile(i), b)
+	}
+	rw.putObject(meta.BlockID, meta.TenantID, indexFile, bIndex)
+
+	// write meta last.  it's what makes the block visible to readers
+	rw.putObject(meta.BlockID, meta.TenantID, metaFile, bMeta)
+
+	return nil
+}
+
+// WriteSearch writes the search section of a block.
+func (rw *readerWriter) WriteSearch(ctx context.Context, meta *encoding.BlockMeta, bSearch []byte) error {
+	err := rw.faults.write(ctx)
+	if err != nil {
+		return err
+	}
+
+	rw.putObject(meta.BlockID, meta.TenantID, searchFile, bSearch)
+	return nil
+}
+
+// AppendObject appends to the traces of a block.  The first append of a write, without a tracker, replaces any traces
+// written before.
+func (rw *readerWriter) AppendObject(ctx context.Context, tracker backend.AppendTracker, meta *encoding.BlockMeta, bObject []byte) (backend.AppendTracker, error) {
+	err := rw.faults.write(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	rw.mtx.Lock()
+	defer rw.mtx.Unlock()
+
+	b := rw.block(meta.BlockID, meta.TenantID)
+	if tracker == nil {
+		b.objects[tracesFile] = nil
+	}
+	b.objects[tracesFile] = append(b.objects[tracesFile], rw.faults.corrupt(bObject)...)
+
+	return meta.BlockID, nil
+}
+
+// Tenants lists the tenants with blocks or a deletion mark.
+func (rw *readerWriter) Tenants(ctx context.Context) ([]string, error) {
+	err := rw.faults.read(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	rw.mtx.RLock()
+	defer rw.mtx.RUnlock()
+
+	tenants := make([]string, 0, len(rw.tenants))
+	for tenantID := range rw.tenants {
+		tenants = append(tenants, tenantID)
+	}
+	for tenantID := range rw.deletionMarks {
+		if _, ok := rw.tenants[tenantID]; !ok {
+			tenants = append(tenants, tenantID)
+		}
+	}
+	return tenants, nil
+}
+
+// Blocks lists the blocks of the tenant, including the ones whose meta hasn't been written.
+func (rw *readerWriter) Blocks(ctx context.Context, tenantID string) ([]uuid.UUID, error) {
+	err := rw.faults.read(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	rw.mtx.RLock()
+	defer rw.mtx.RUnlock()
+
+	blocks := make([]uuid.UUID, 0, len(rw.tenants[tenantID]))
+	for blockID := range rw.tenants[tenantID] {
+		blocks = append(blocks, blockID)
+	}
+	return blocks, nil
+}
+
+func (rw *readerWriter) BlockMeta(ctx context.Context, blockID uuid.UUID, tenantID string) (*encoding.BlockMeta, error) {
+	bytes, err := rw.readObject(ctx, blockID, tenantID, metaFile)
+	if err != nil {
+		return nil, err
+	}
+
+	out := &encoding.BlockMeta{}
+	err = json.Unmarshal(bytes, out)
+	if err != nil {
+		return nil, err
+	}
+
+	return out, nil
+}
+
+func (rw *readerWriter) Bloom(ctx context.Context, blockID uuid.UUID, tenantID string, bloomShard int) ([]byte, error) {
+	return rw.readObject(ctx, blockID, tenantID, bloomFile(bloomShard))
+}
+
+func (rw *readerWriter) Index(ctx context.Context, blockID uuid.UUID, tenantID string) ([]byte, error) {
+	return rw.readObject(ctx, blockID, tenantID, indexFile)
+}
+
+func (rw *readerWriter) Search(ctx context.Context, blockID uuid.UUID, tenantID string) ([]byte, error) {
+	return rw.readObject(ctx, blockID, tenantID, searchFile)
+}
+
+// Object fills buffer with the traces starting at start.  A partial read only fills the first half of buffer and
+// zeroes the rest.
+func (rw *readerWriter) Object(ctx context.Context, blockID uuid.UUID, tenantID string, start uint64, buffer []byte) error {
+	err := rw.faults.read(ctx)
+	if err != nil {
+		return err
+	}
+
+	rw.mtx.RLock()
+	defer rw.mtx.RUnlock()
+
+	traces, err := rw.object(blockID, tenantID, tracesFile)
+	if err != nil {
+		return err
+	}
+	if start+uint64(len(buffer)) > uint64(len(traces)) {
+		return io.EOF
+	}
+
+	n := len(buffer)
+	if rw.faults.partial() {
+		n /= 2
+	}
+	copy(buffer, traces[start:start+uint64(n)])
+	for i := n; i < len(buffer); i++ {
+		buffer[i] = 0
+	}
+	return nil
+}
+
+func (rw *readerWriter) Shutdown() {
+
+}
+
+// readObject returns a copy of the object.  A partial read only returns the first half of it.
+func (rw *readerWriter) readObject(ctx context.Context, blockID uuid.UUID, tenantID string, name string) ([]byte, error) {
+	err := rw.faults.read(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	rw.mtx.RLock()
+	defer rw.mtx.RUnlock()
+
+	o, err := rw.object(blockID, tenantID, name)
+	if err != nil {
+		return nil, err
+	}
+	if rw.faults.partial() {
+		o = o[:len(o)/2]
+	}
+	return append([]byte(nil), o...), nil
+}
+
+// object returns the object without copying it.  Callers must hold mtx.  A missing meta is reported as
+// backend.ErrMetaDoesNotExist.
+func (rw *readerWriter) object(blockID uuid.UUID, tenantID string, name string) ([]byte, error) {
+	b, ok := rw.tenants[tenantID][blockID]
+	var o []byte
+	if ok {
+		o, ok = b.objects[name]
+	}
+	if !ok {
+		if name == metaFile || name == compactedMetaFile {
+			return nil, backend.ErrMetaDoesNotExist
+		}
+		return nil, fmt.Errorf("object %s of block %s of tenant %s does not exist", name, blockID, tenantID)
+	}
+	return o, nil
+}
+
+func (rw *readerWriter) putObject(blockID uuid.UUID, tenantID string, name string, o []byte) {
+	rw.mtx.Lock()
+	defer rw.mtx.Unlock()
+
+	rw.block(blockID, tenantID).objects[name] = append([]byte(nil), o...)
+}
+
+// block returns the block, creating it if it doesn't exist.  Callers must hold mtx.
+func (rw *readerWriter) block(blockID uuid.UUID, tenantID string) *block {
+	blocks, ok := rw.tenants[tenantID]
+	if !ok {
+		blocks = map[uuid.UUID]*block{}
+		rw.tenants[tenantID] = blocks
+	}
+
+	b, ok := blocks[blockID]
+	if !ok {
+		b = &block{objects: map[string][]byte{}}
+		blocks[blockID] = b
+	}
+	return b
+}
+
+func bloomFile(bloomShard int) string {
+	return "bloom-" + strconv.Itoa(bloomShard)
+}
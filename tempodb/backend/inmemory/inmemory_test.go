@@ -0,0 +1,189 @@
+package inmemory
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/grafana/tempo/tempodb/backend"
+	"github.com/grafana/tempo/tempodb/encoding"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadWrite(t *testing.T) {
+	r, w, c, err := New(nil)
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	meta := &encoding.BlockMeta{
+		BlockID:  uuid.New(),
+		TenantID: "fake",
+	}
+	traces := []byte("0123456789")
+
+	// appended blocks are listed but invisible until their meta is written
+	tracker, err := w.AppendObject(ctx, nil, meta, traces[:5])
+	require.NoError(t, err)
+	_, err = w.AppendObject(ctx, tracker, meta, traces[5:])
+	require.NoError(t, err)
+
+	blocks, err := r.Blocks(ctx, "fake")
+	require.NoError(t, err)
+	assert.Equal(t, []uuid.UUID{meta.BlockID}, blocks)
+	_, err = r.BlockMeta(ctx, meta.BlockID, "fake")
+	assert.Equal(t, backend.ErrMetaDoesNotExist, err)
+
+	err = w.WriteSearch(ctx, meta, []byte("search"))
+	require.NoError(t, err)
+	err = w.WriteBlockMeta(ctx, tracker, meta, [][]byte{[]byte("bloom")}, []byte("index"))
+	require.NoError(t, err)
+
+	tenants, err := r.Tenants(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"fake"}, tenants)
+
+	actualMeta, err := r.BlockMeta(ctx, meta.BlockID, "fake")
+	require.NoError(t, err)
+	assert.Equal(t, meta, actualMeta)
+
+	bloom, err := r.Bloom(ctx, meta.BlockID, "fake", 0)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("bloom"), bloom)
+	_, err = r.Bloom(ctx, meta.BlockID, "fake", 1)
+	assert.Error(t, err)
+
+	index, err := r.Index(ctx, meta.BlockID, "fake")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("index"), index)
+
+	search, err := r.Search(ctx, meta.BlockID, "fake")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("search"), search)
+
+	object := make([]byte, 4)
+	err = r.Object(ctx, meta.BlockID, "fake", 3, object)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("3456"), object)
+	assert.Error(t, r.Object(ctx, meta.BlockID, "fake", 8, object))
+
+	// written blocks are read back from the traces file
+	tracesFile, err := ioutil.TempFile("/tmp", "")
+	require.NoError(t, err)
+	defer os.Remove(tracesFile.Name())
+	_, err = tracesFile.Write(traces)
+	require.NoError(t, err)
+
+	written := &encoding.BlockMeta{
+		BlockID:  uuid.New(),
+		TenantID: "fake",
+	}
+	err = w.Write(ctx, written, nil, []byte("index"), tracesFile.Name())
+	require.NoError(t, err)
+	err = r.Object(ctx, written.BlockID, "fake", 0, object)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("0123"), object)
+
+	// compaction moves the meta
+	_, err = c.CompactedBlockMeta(meta.BlockID, "fake")
+	assert.Equal(t, backend.ErrMetaDoesNotExist, err)
+	require.NoError(t, c.MarkBlockCompacted(meta.BlockID, "fake"))
+	_, err = r.BlockMeta(ctx, meta.BlockID, "fake")
+	assert.Equal(t, backend.ErrMetaDoesNotExist, err)
+	compacted, err := c.CompactedBlockMeta(meta.BlockID, "fake")
+	require.NoError(t, err)
+	assert.Equal(t, *meta, compacted.BlockMeta)
+	assert.WithinDuration(t, time.Now(), compacted.CompactedTime, time.Minute)
+
+	require.NoError(t, c.ClearBlock(meta.BlockID, "fake"))
+	require.NoError(t, c.ClearBlock(written.BlockID, "fake"))
+	tenants, err = r.Tenants(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, tenants)
+
+	// probes
+	require.NoError(t, backend.Probe(ctx, w.(backend.Prober)))
+}
+
+func TestFaults(t *testing.T) {
+	ctx := context.Background()
+	meta := &encoding.BlockMeta{
+		BlockID:  uuid.New(),
+		TenantID: "fake",
+	}
+
+	_, w, c, err := New(&Config{WriteErrorRate: 1})
+	require.NoError(t, err)
+	assert.Equal(t, ErrInjected, w.WriteBlockMeta(ctx, nil, meta, nil, []byte("index")))
+	assert.Equal(t, ErrInjected, c.ClearBlock(meta.BlockID, "fake"))
+
+	r, w, _, err := New(&Config{ReadErrorRate: 1})
+	require.NoError(t, err)
+	require.NoError(t, w.WriteBlockMeta(ctx, nil, meta, nil, []byte("index")))
+	_, err = r.Index(ctx, meta.BlockID, "fake")
+	assert.Equal(t, ErrInjected, err)
+
+	r, w, _, err = New(&Config{PartialReadRate: 1})
+	require.NoError(t, err)
+	require.NoError(t, w.WriteBlockMeta(ctx, nil, meta, nil, []byte("index!")))
+	_, err = w.AppendObject(ctx, nil, meta, []byte("0123"))
+	require.NoError(t, err)
+	index, err := r.Index(ctx, meta.BlockID, "fake")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("ind"), index)
+	_, err = r.BlockMeta(ctx, meta.BlockID, "fake")
+	assert.Error(t, err)
+	object := []byte("xxxx")
+	require.NoError(t, r.Object(ctx, meta.BlockID, "fake", 0, object))
+	assert.Equal(t, []byte{'0', '1', 0, 0}, object)
+
+	// corrupt writes succeed
+	r, w, _, err = New(&Config{CorruptWriteRate: 1})
+	require.NoError(t, err)
+	_, err = w.AppendObject(ctx, nil, meta, []byte("0123"))
+	require.NoError(t, err)
+	require.NoError(t, w.WriteBlockMeta(ctx, nil, meta, nil, []byte("index")))
+	require.NoError(t, r.Object(ctx, meta.BlockID, "fake", 0, object))
+	assert.NotEqual(t, []byte("0123"), object)
+
+	// latency is cut short by the context
+	r, _, _, err = New(&Config{Latency: time.Hour})
+	require.NoError(t, err)
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	_, err = r.Tenants(ctx)
+	assert.Equal(t, context.DeadlineExceeded, err)
+
+	// rates are validated
+	_, _, _, err = New(&Config{ReadErrorRate: 2})
+	assert.Error(t, err)
+}
+
+func TestTenantDeletionMark(t *testing.T) {
+	r, _, c, err := New(nil)
+	require.NoError(t, err)
+
+	_, err = c.TenantDeletionMark("fake")
+	assert.Equal(t, backend.ErrDeletionMarkDoesNotExist, err)
+
+	// a marked tenant is listed without blocks
+	mark := &backend.TenantDeletionMark{RequestedTime: time.Unix(10, 0).UTC()}
+	require.NoError(t, c.MarkTenantDeleted("fake", mark))
+	actual, err := c.TenantDeletionMark("fake")
+	require.NoError(t, err)
+	assert.Equal(t, mark, actual)
+
+	tenants, err := r.Tenants(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []string{"fake"}, tenants)
+
+	require.NoError(t, c.ClearTenantDeletionMark("fake"))
+	_, err = c.TenantDeletionMark("fake")
+	assert.Equal(t, backend.ErrDeletionMarkDoesNotExist, err)
+	tenants, err = r.Tenants(context.Background())
+	require.NoError(t, err)
+	assert.Empty(t, tenants)
+}
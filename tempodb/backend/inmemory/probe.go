@@ -0,0 +1,60 @@
+package inmemory
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// WriteProbe implements backend.Prober
+func (rw *readerWriter) WriteProbe(ctx context.Context, name string, b []byte) error {
+	return rw.WriteObject(ctx, name, b)
+}
+
+// WriteObject implements backend.ObjectWriter.  Objects are kept with the probes.
+func (rw *readerWriter) WriteObject(ctx context.Context, name string, b []byte) error {
+	err := rw.faults.write(ctx)
+	if err != nil {
+		return err
+	}
+
+	rw.mtx.Lock()
+	defer rw.mtx.Unlock()
+
+	rw.probes[name] = probeObject{
+		data:    append([]byte(nil), b...),
+		modTime: time.Now(),
+	}
+	return nil
+}
+
+// ReadProbe implements backend.Prober
+func (rw *readerWriter) ReadProbe(ctx context.Context, name string) ([]byte, time.Time, error) {
+	err := rw.faults.read(ctx)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+
+	rw.mtx.RLock()
+	defer rw.mtx.RUnlock()
+
+	p, ok := rw.probes[name]
+	if !ok {
+		return nil, time.Time{}, fmt.Errorf("probe %s does not exist", name)
+	}
+	return append([]byte(nil), p.data...), p.modTime, nil
+}
+
+// DeleteProbe implements backend.Prober
+func (rw *readerWriter) DeleteProbe(ctx context.Context, name string) error {
+	err := rw.faults.write(ctx)
+	if err != nil {
+		return err
+	}
+
+	rw.mtx.Lock()
+	defer rw.mtx.Unlock()
+
+	delete(rw.probes, name)
+	return nil
+}
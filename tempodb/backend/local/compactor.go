@@ -10,6 +10,7 @@ import (
 	"github.com/google/uuid"
 
 	"github.com/grafana/tempo/tempodb/backend"
+	"github.com/grafana/tempo/tempodb/backend/util"
 	"github.com/grafana/tempo/tempodb/encoding"
 )
 
@@ -18,7 +19,12 @@ func (rw *readerWriter) MarkBlockCompacted(blockID uuid.UUID, tenantID string) e
 	metaFilename := rw.metaFileName(blockID, tenantID)
 	compactedMetaFilename := rw.compactedMetaFileName(blockID, tenantID)
 
-	return os.Rename(metaFilename, compactedMetaFilename)
+	err := os.Rename(metaFilename, compactedMetaFilename)
+	if err != nil {
+		return err
+	}
+
+	return rw.syncDir(rw.rootPath(blockID, tenantID))
 }
 
 func (rw *readerWriter) ClearBlock(blockID uuid.UUID, tenantID string) error {
@@ -30,6 +36,11 @@ func (rw *readerWriter) ClearBlock(blockID uuid.UUID, tenantID string) error {
 		return fmt.Errorf("empty block id")
 	}
 
+	err := os.RemoveAll(rw.stagingPath(blockID, tenantID))
+	if err != nil {
+		return err
+	}
+
 	return os.RemoveAll(rw.rootPath(blockID, tenantID))
 }
 
@@ -62,3 +73,62 @@ func (rw *readerWriter) CompactedBlockMeta(blockID uuid.UUID, tenantID string) (
 func (rw *readerWriter) compactedMetaFileName(blockID uuid.UUID, tenantID string) string {
 	return path.Join(rw.rootPath(blockID, tenantID), "meta.compacted.json")
 }
+
+func (rw *readerWriter) MarkTenantDeleted(tenantID string, mark *backend.TenantDeletionMark) error {
+	if len(tenantID) == 0 {
+		return backend.ErrEmptyTenantID
+	}
+
+	bytes, err := json.Marshal(mark)
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(path.Join(rw.cfg.Path, tenantID), os.ModePerm)
+	if err != nil {
+		return err
+	}
+
+	return rw.writeFile(rw.tenantDeletionMarkFileName(tenantID), bytes)
+}
+
+func (rw *readerWriter) TenantDeletionMark(tenantID string) (*backend.TenantDeletionMark, error) {
+	bytes, err := ioutil.ReadFile(rw.tenantDeletionMarkFileName(tenantID))
+	if os.IsNotExist(err) {
+		return nil, backend.ErrDeletionMarkDoesNotExist
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	out := &backend.TenantDeletionMark{}
+	err = json.Unmarshal(bytes, out)
+	if err != nil {
+		return nil, err
+	}
+
+	return out, nil
+}
+
+// ClearTenantDeletionMark also removes the directories of the tenant unless a block was written since its blocks were
+// deleted.
+func (rw *readerWriter) ClearTenantDeletionMark(tenantID string) error {
+	if len(tenantID) == 0 {
+		return backend.ErrEmptyTenantID
+	}
+
+	err := os.Remove(rw.tenantDeletionMarkFileName(tenantID))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	// removing a directory that isn't empty fails
+	_ = os.Remove(path.Join(rw.cfg.Path, stagingDir, tenantID))
+	_ = os.Remove(path.Join(rw.cfg.Path, tenantID))
+
+	return nil
+}
+
+func (rw *readerWriter) tenantDeletionMarkFileName(tenantID string) string {
+	return path.Join(rw.cfg.Path, util.TenantDeletionMarkFileName(tenantID))
+}
@@ -1,5 +1,9 @@
 package local
 
 type Config struct {
+	// Path of the blocks.  If empty when the local backend stores the blocks, it's the blocks directory of the WAL path.
 	Path string `yaml:"path"`
+	// Fsync syncs the files of a block to disk before it's committed, and the directories it's committed to after.
+	// Committed blocks then survive a crash of the machine, at the cost of slower flushes and compactions.
+	Fsync bool `yaml:"fsync"`
 }
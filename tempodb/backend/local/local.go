@@ -10,13 +10,26 @@ import (
 	"path"
 	"strconv"
 
+	log_util "github.com/cortexproject/cortex/pkg/util"
+	"github.com/go-kit/kit/log"
 	"github.com/google/uuid"
 	"github.com/grafana/tempo/tempodb/backend"
 	"github.com/grafana/tempo/tempodb/encoding"
 )
 
+const (
+	// stagingDir holds blocks that are being written.  It's skipped when listing tenants.
+	stagingDir = ".staging"
+
+	metaFile   = "meta.json"
+	indexFile  = "index"
+	searchFile = "search"
+	tracesFile = "traces"
+)
+
 type readerWriter struct {
-	cfg *Config
+	cfg    *Config
+	logger log.Logger
 }
 
 func New(cfg *Config) (backend.Reader, backend.Writer, backend.Compactor, error) {
@@ -26,94 +39,100 @@ func New(cfg *Config) (backend.Reader, backend.Writer, backend.Compactor, error)
 	}
 
 	rw := &readerWriter{
-		cfg: cfg,
+		cfg:    cfg,
+		logger: log_util.Logger,
 	}
 
 	return rw, rw, rw, nil
 }
 
+// Write stages the block and commits it.  Blocks are written to the staging directory with the meta last and then
+// moved into place with a rename, so readers never see a partially written block.
 func (rw *readerWriter) Write(ctx context.Context, meta *encoding.BlockMeta, bBloom [][]byte, bIndex []byte, tracesFilePath string) error {
-	err := rw.WriteBlockMeta(ctx, nil, meta, bBloom, bIndex)
-	if err != nil {
-		return err
-	}
-
 	blockID := meta.BlockID
 	tenantID := meta.TenantID
-	blockFolder := rw.rootPath(blockID, tenantID)
+	stagingFolder := rw.stagingPath(blockID, tenantID)
 
 	if !fileExists(tracesFilePath) {
-		os.RemoveAll(blockFolder)
 		return fmt.Errorf("traces file not found %s", tracesFilePath)
 	}
 
-	// copy traces file.
-	src, err := os.Open(tracesFilePath)
+	err := os.MkdirAll(stagingFolder, os.ModePerm)
 	if err != nil {
-		os.RemoveAll(blockFolder)
 		return err
 	}
-	defer src.Close()
 
-	tracesFileName := rw.tracesFileName(blockID, tenantID)
-	dst, err := os.Create(tracesFileName)
+	err = copyFile(tracesFilePath, path.Join(stagingFolder, tracesFile), rw.cfg.Fsync)
 	if err != nil {
-		os.RemoveAll(blockFolder)
+		os.RemoveAll(stagingFolder)
 		return err
 	}
-	defer dst.Close()
 
-	_, err = io.Copy(dst, src)
-	if err != nil {
-		os.RemoveAll(blockFolder)
-	}
-	return err
+	return rw.WriteBlockMeta(ctx, nil, meta, bBloom, bIndex)
 }
 
+// WriteBlockMeta writes the bloom, index and meta of a staged block and commits it.
 func (rw *readerWriter) WriteBlockMeta(_ context.Context, tracker backend.AppendTracker, meta *encoding.BlockMeta, bBloom [][]byte, bIndex []byte) error {
 	blockID := meta.BlockID
 	tenantID := meta.TenantID
 
 	if tracker != nil {
 		var dst *os.File = tracker.(*os.File)
+		if rw.cfg.Fsync {
+			err := dst.Sync()
+			if err != nil {
+				_ = dst.Close()
+				return err
+			}
+		}
 		_ = dst.Close()
 	}
 
-	blockFolder := rw.rootPath(blockID, tenantID)
-	err := os.MkdirAll(blockFolder, os.ModePerm)
+	stagingFolder := rw.stagingPath(blockID, tenantID)
+	err := os.MkdirAll(stagingFolder, os.ModePerm)
 	if err != nil {
 		return err
 	}
 
-	bMeta, err := json.Marshal(meta)
+	for i, b := range bBloom {
+		err = rw.writeFile(path.Join(stagingFolder, bloomFile(i)), b)
+		if err != nil {
+			os.RemoveAll(stagingFolder)
+			return err
+		}
+	}
+
+	err = rw.writeFile(path.Join(stagingFolder, indexFile), bIndex)
 	if err != nil {
+		os.RemoveAll(stagingFolder)
 		return err
 	}
 
-	metaFileName := rw.metaFileName(blockID, tenantID)
-	err = ioutil.WriteFile(metaFileName, bMeta, 0644)
+	bMeta, err := json.Marshal(meta)
 	if err != nil {
-		os.RemoveAll(blockFolder)
+		os.RemoveAll(stagingFolder)
 		return err
 	}
 
-	for i, b := range bBloom {
-		bloomFileName := rw.bloomFileName(blockID, tenantID, i)
-		err = ioutil.WriteFile(bloomFileName, b, 0644)
-		if err != nil {
-			os.RemoveAll(blockFolder)
-			return err
-		}
+	// write meta last.  it's what makes the block visible to readers
+	err = rw.writeFile(path.Join(stagingFolder, metaFile), bMeta)
+	if err != nil {
+		os.RemoveAll(stagingFolder)
+		return err
 	}
 
-	indexFileName := rw.indexFileName(blockID, tenantID)
-	err = ioutil.WriteFile(indexFileName, bIndex, 0644)
+	return rw.commit(blockID, tenantID)
+}
+
+// WriteSearch writes the search section of a block to the staging directory.  It's committed with the rest of the block.
+func (rw *readerWriter) WriteSearch(_ context.Context, meta *encoding.BlockMeta, bSearch []byte) error {
+	stagingFolder := rw.stagingPath(meta.BlockID, meta.TenantID)
+	err := os.MkdirAll(stagingFolder, os.ModePerm)
 	if err != nil {
-		os.RemoveAll(blockFolder)
 		return err
 	}
 
-	return nil
+	return rw.writeFile(path.Join(stagingFolder, searchFile), bSearch)
 }
 
 func (rw *readerWriter) AppendObject(ctx context.Context, tracker backend.AppendTracker, meta *encoding.BlockMeta, bObject []byte) (backend.AppendTracker, error) {
@@ -122,14 +141,13 @@ func (rw *readerWriter) AppendObject(ctx context.Context, tracker backend.Append
 
 	var dst *os.File
 	if tracker == nil {
-		blockFolder := rw.rootPath(blockID, tenantID)
-		err := os.MkdirAll(blockFolder, os.ModePerm)
+		stagingFolder := rw.stagingPath(blockID, tenantID)
+		err := os.MkdirAll(stagingFolder, os.ModePerm)
 		if err != nil {
 			return nil, err
 		}
 
-		tracesFileName := rw.tracesFileName(blockID, tenantID)
-		dst, err = os.Create(tracesFileName)
+		dst, err = os.Create(path.Join(stagingFolder, tracesFile))
 		if err != nil {
 			return nil, err
 		}
@@ -145,33 +163,103 @@ func (rw *readerWriter) AppendObject(ctx context.Context, tracker backend.Append
 	return dst, nil
 }
 
+// commit moves a staged block into place.  A block that is written again, e.g. when it's reindexed or a flush is
+// retried, keeps the files of the earlier copy that weren't staged again.
+func (rw *readerWriter) commit(blockID uuid.UUID, tenantID string) error {
+	stagingFolder := rw.stagingPath(blockID, tenantID)
+	blockFolder := rw.rootPath(blockID, tenantID)
+
+	err := os.MkdirAll(path.Dir(blockFolder), os.ModePerm)
+	if err != nil {
+		os.RemoveAll(stagingFolder)
+		return err
+	}
+
+	existing, err := ioutil.ReadDir(blockFolder)
+	if err != nil && !os.IsNotExist(err) {
+		os.RemoveAll(stagingFolder)
+		return err
+	}
+	for _, f := range existing {
+		staged := path.Join(stagingFolder, f.Name())
+		if fileExists(staged) {
+			continue
+		}
+		// linked rather than moved so the committed block is untouched if the commit fails
+		err = os.Link(path.Join(blockFolder, f.Name()), staged)
+		if err != nil {
+			os.RemoveAll(stagingFolder)
+			return err
+		}
+	}
+
+	err = os.RemoveAll(blockFolder)
+	if err != nil {
+		os.RemoveAll(stagingFolder)
+		return err
+	}
+
+	err = os.Rename(stagingFolder, blockFolder)
+	if err != nil {
+		os.RemoveAll(stagingFolder)
+		return err
+	}
+
+	return rw.syncDir(path.Dir(blockFolder))
+}
+
+// Tenants lists the tenants with committed or staged blocks.
 func (rw *readerWriter) Tenants(ctx context.Context) ([]string, error) {
 	folders, err := ioutil.ReadDir(rw.cfg.Path)
 	if err != nil {
 		return nil, err
 	}
+	rw.updateFilesystemUsage()
+
+	stagedFolders, err := ioutil.ReadDir(path.Join(rw.cfg.Path, stagingDir))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
 
 	tenants := make([]string, 0, len(folders))
-	for _, f := range folders {
-		if !f.IsDir() {
+	seen := map[string]struct{}{}
+	for _, f := range append(folders, stagedFolders...) {
+		if !f.IsDir() || f.Name() == stagingDir {
+			continue
+		}
+		if _, ok := seen[f.Name()]; ok {
 			continue
 		}
+		seen[f.Name()] = struct{}{}
 		tenants = append(tenants, f.Name())
 	}
 
 	return tenants, nil
 }
 
+// Blocks lists the committed and staged blocks of the tenant.  Like a block prefix without a meta in an object store,
+// a staged block has no meta until it's committed and can be cleared with ClearBlock if its write was abandoned.  The
+// size of the committed blocks is exported as the tenant's disk usage.
 func (rw *readerWriter) Blocks(ctx context.Context, tenantID string) ([]uuid.UUID, error) {
 	var warning error
-	path := path.Join(rw.cfg.Path, tenantID)
-	folders, err := ioutil.ReadDir(path)
-	if err != nil {
+	folders, err := ioutil.ReadDir(path.Join(rw.cfg.Path, tenantID))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	notFound := os.IsNotExist(err)
+	rw.updateTenantUsage(tenantID, folders)
+
+	stagedFolders, err := ioutil.ReadDir(path.Join(rw.cfg.Path, stagingDir, tenantID))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if notFound && os.IsNotExist(err) {
 		return nil, err
 	}
 
-	blocks := make([]uuid.UUID, 0, len(folders))
-	for _, f := range folders {
+	blocks := make([]uuid.UUID, 0, len(folders)+len(stagedFolders))
+	seen := map[uuid.UUID]struct{}{}
+	for _, f := range append(folders, stagedFolders...) {
 		if !f.IsDir() {
 			continue
 		}
@@ -180,6 +268,10 @@ func (rw *readerWriter) Blocks(ctx context.Context, tenantID string) ([]uuid.UUI
 			warning = err
 			continue
 		}
+		if _, ok := seen[blockID]; ok {
+			continue
+		}
+		seen[blockID] = struct{}{}
 		blocks = append(blocks, blockID)
 	}
 
@@ -215,6 +307,11 @@ func (rw *readerWriter) Index(ctx context.Context, blockID uuid.UUID, tenantID s
 	return ioutil.ReadFile(filename)
 }
 
+func (rw *readerWriter) Search(ctx context.Context, blockID uuid.UUID, tenantID string) ([]byte, error) {
+	filename := rw.searchFileName(blockID, tenantID)
+	return ioutil.ReadFile(filename)
+}
+
 func (rw *readerWriter) Object(ctx context.Context, blockID uuid.UUID, tenantID string, start uint64, buffer []byte) error {
 	filename := rw.tracesFileName(blockID, tenantID)
 
@@ -237,25 +334,100 @@ func (rw *readerWriter) Shutdown() {
 }
 
 func (rw *readerWriter) metaFileName(blockID uuid.UUID, tenantID string) string {
-	return path.Join(rw.rootPath(blockID, tenantID), "meta.json")
+	return path.Join(rw.rootPath(blockID, tenantID), metaFile)
 }
 
 func (rw *readerWriter) bloomFileName(blockID uuid.UUID, tenantID string, bloomShard int) string {
-	return path.Join(rw.rootPath(blockID, tenantID), "bloom-"+strconv.Itoa(bloomShard))
+	return path.Join(rw.rootPath(blockID, tenantID), bloomFile(bloomShard))
 }
 
 func (rw *readerWriter) indexFileName(blockID uuid.UUID, tenantID string) string {
-	return path.Join(rw.rootPath(blockID, tenantID), "index")
+	return path.Join(rw.rootPath(blockID, tenantID), indexFile)
+}
+
+func (rw *readerWriter) searchFileName(blockID uuid.UUID, tenantID string) string {
+	return path.Join(rw.rootPath(blockID, tenantID), searchFile)
 }
 
 func (rw *readerWriter) tracesFileName(blockID uuid.UUID, tenantID string) string {
-	return path.Join(rw.rootPath(blockID, tenantID), "traces")
+	return path.Join(rw.rootPath(blockID, tenantID), tracesFile)
 }
 
 func (rw *readerWriter) rootPath(blockID uuid.UUID, tenantID string) string {
 	return path.Join(rw.cfg.Path, tenantID, blockID.String())
 }
 
+// stagingPath is where a block is written until it's committed.
+func (rw *readerWriter) stagingPath(blockID uuid.UUID, tenantID string) string {
+	return path.Join(rw.cfg.Path, stagingDir, tenantID, blockID.String())
+}
+
+func bloomFile(bloomShard int) string {
+	return "bloom-" + strconv.Itoa(bloomShard)
+}
+
+// writeFile writes a file of a block, synced to disk if configured.
+func (rw *readerWriter) writeFile(filename string, b []byte) error {
+	if !rw.cfg.Fsync {
+		return ioutil.WriteFile(filename, b, 0644)
+	}
+
+	f, err := os.OpenFile(filename, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(b)
+	if err == nil {
+		err = f.Sync()
+	}
+	if err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// syncDir syncs a directory so files created, renamed or removed in it survive a crash, if configured.
+func (rw *readerWriter) syncDir(dir string) error {
+	if !rw.cfg.Fsync {
+		return nil
+	}
+
+	f, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	err = f.Sync()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func copyFile(src string, dst string, sync bool) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(out, in)
+	if err == nil && sync {
+		err = out.Sync()
+	}
+	if err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
 func fileExists(filename string) bool {
 	_, err := os.Stat(filename)
 	return !os.IsNotExist(err)
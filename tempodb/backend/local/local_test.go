@@ -2,6 +2,7 @@ package local
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"math/rand"
@@ -12,7 +13,8 @@ import (
 	"github.com/google/uuid"
 	"github.com/grafana/tempo/tempodb/backend"
 	"github.com/grafana/tempo/tempodb/encoding"
-	"github.com/grafana/tempo/tempodb/encoding/bloom"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -41,7 +43,7 @@ func TestReadWrite(t *testing.T) {
 		BlockID: blockID,
 	}
 
-	shardNum := bloom.GetShardNum()
+	shardNum := encoding.LegacyBloomShardCount
 	fakeBloom := make([][]byte, shardNum)
 	fakeIndex := make([]byte, 20)
 	fakeTraces := make([]byte, 200)
@@ -118,6 +120,121 @@ func TestWriteFail(t *testing.T) {
 	assert.True(t, os.IsNotExist(err))
 }
 
+func TestStagedBlock(t *testing.T) {
+	tempDir, err := ioutil.TempDir("/tmp", "")
+	defer os.RemoveAll(tempDir)
+	assert.NoError(t, err, "unexpected error creating temp dir")
+
+	r, w, c, err := New(&Config{
+		Path: tempDir,
+	})
+	assert.NoError(t, err, "unexpected error creating local backend")
+
+	ctx := context.Background()
+	blockID := uuid.New()
+	tenantID := "fake"
+	fakeMeta := &encoding.BlockMeta{
+		BlockID:  blockID,
+		TenantID: tenantID,
+	}
+
+	tracker, err := w.AppendObject(ctx, nil, fakeMeta, []byte{0x01, 0x02})
+	assert.NoError(t, err)
+	err = w.WriteSearch(ctx, fakeMeta, []byte{0x03})
+	assert.NoError(t, err)
+
+	// a staged block is listed but has no meta until it's committed
+	tenants, err := r.Tenants(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{tenantID}, tenants)
+	blocks, err := r.Blocks(ctx, tenantID)
+	assert.NoError(t, err)
+	assert.Equal(t, []uuid.UUID{blockID}, blocks)
+	_, err = r.BlockMeta(ctx, blockID, tenantID)
+	assert.Equal(t, backend.ErrMetaDoesNotExist, err)
+
+	err = w.WriteBlockMeta(ctx, tracker, fakeMeta, [][]byte{{0x04}}, []byte{0x05})
+	assert.NoError(t, err)
+
+	actualMeta, err := r.BlockMeta(ctx, blockID, tenantID)
+	assert.NoError(t, err)
+	assert.Equal(t, fakeMeta, actualMeta)
+	actualSearch, err := r.Search(ctx, blockID, tenantID)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{0x03}, actualSearch)
+	actualObject := make([]byte, 2)
+	err = r.Object(ctx, blockID, tenantID, 0, actualObject)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{0x01, 0x02}, actualObject)
+	blocks, err = r.Blocks(ctx, tenantID)
+	assert.NoError(t, err)
+	assert.Equal(t, []uuid.UUID{blockID}, blocks)
+
+	// an abandoned block is cleared like any other
+	abandonedID := uuid.New()
+	tracker, err = w.AppendObject(ctx, nil, &encoding.BlockMeta{BlockID: abandonedID, TenantID: tenantID}, []byte{0x01})
+	assert.NoError(t, err)
+	assert.NoError(t, tracker.(*os.File).Close())
+
+	err = c.ClearBlock(abandonedID, tenantID)
+	assert.NoError(t, err)
+	blocks, err = r.Blocks(ctx, tenantID)
+	assert.NoError(t, err)
+	assert.Equal(t, []uuid.UUID{blockID}, blocks)
+}
+
+func TestFsyncAndUsage(t *testing.T) {
+	tempDir, err := ioutil.TempDir("/tmp", "")
+	defer os.RemoveAll(tempDir)
+	assert.NoError(t, err, "unexpected error creating temp dir")
+
+	r, w, c, err := New(&Config{
+		Path:  tempDir,
+		Fsync: true,
+	})
+	assert.NoError(t, err, "unexpected error creating local backend")
+
+	ctx := context.Background()
+	blockID := uuid.New()
+	tenantID := "fake"
+	fakeMeta := &encoding.BlockMeta{
+		BlockID:  blockID,
+		TenantID: tenantID,
+	}
+
+	tracker, err := w.AppendObject(ctx, nil, fakeMeta, []byte{0x01, 0x02})
+	assert.NoError(t, err)
+	err = w.WriteSearch(ctx, fakeMeta, []byte{0x03})
+	assert.NoError(t, err)
+	err = w.WriteBlockMeta(ctx, tracker, fakeMeta, [][]byte{{0x04}}, []byte{0x05})
+	assert.NoError(t, err)
+	err = c.MarkBlockCompacted(blockID, tenantID)
+	assert.NoError(t, err)
+
+	_, err = r.Tenants(ctx)
+	assert.NoError(t, err)
+	assert.Greater(t, gaugeValue(t, metricFilesystemBytes.WithLabelValues(tempDir, "size")), float64(0))
+
+	// the tenant's usage is the size of its committed files
+	bMeta, err := json.Marshal(fakeMeta)
+	assert.NoError(t, err)
+	_, err = r.Blocks(ctx, tenantID)
+	assert.NoError(t, err)
+	assert.Equal(t, float64(5+len(bMeta)), gaugeValue(t, metricTenantBytes.WithLabelValues(tempDir, tenantID)))
+
+	err = c.ClearBlock(blockID, tenantID)
+	assert.NoError(t, err)
+	_, err = r.Blocks(ctx, tenantID)
+	assert.NoError(t, err)
+	assert.Equal(t, float64(0), gaugeValue(t, metricTenantBytes.WithLabelValues(tempDir, tenantID)))
+}
+
+func gaugeValue(t *testing.T, g prometheus.Gauge) float64 {
+	m := &dto.Metric{}
+	assert.NoError(t, g.Write(m))
+	return m.GetGauge().GetValue()
+}
+
 func TestCompaction(t *testing.T) {
 	tempDir, err := ioutil.TempDir("/tmp", "")
 	defer os.RemoveAll(tempDir)
@@ -143,7 +260,7 @@ func TestCompaction(t *testing.T) {
 		BlockID: blockID,
 	}
 
-	shardNum := bloom.GetShardNum()
+	shardNum := encoding.LegacyBloomShardCount
 	fakeBloom := make([][]byte, shardNum)
 	fakeIndex := make([]byte, 20)
 	fakeTraces := make([]byte, 200)
@@ -194,3 +311,33 @@ func TestCompaction(t *testing.T) {
 		assert.Nil(t, meta)
 	}
 }
+
+func TestProbe(t *testing.T) {
+	tempDir, err := ioutil.TempDir("/tmp", "")
+	defer os.RemoveAll(tempDir)
+	assert.NoError(t, err, "unexpected error creating temp dir")
+
+	_, w, _, err := New(&Config{
+		Path: tempDir,
+	})
+	assert.NoError(t, err, "unexpected error creating local backend")
+
+	err = backend.Probe(context.Background(), w.(backend.Prober))
+	assert.NoError(t, err)
+
+	files, err := ioutil.ReadDir(tempDir)
+	assert.NoError(t, err)
+	assert.Empty(t, files)
+
+	_, w, _, err = New(&Config{
+		Path: path.Join(tempDir, "readonly"),
+	})
+	assert.NoError(t, err, "unexpected error creating local backend")
+	err = os.Chmod(path.Join(tempDir, "readonly"), 0555)
+	assert.NoError(t, err)
+
+	if os.Geteuid() != 0 {
+		err = backend.Probe(context.Background(), w.(backend.Prober))
+		assert.Error(t, err)
+	}
+}
@@ -0,0 +1,45 @@
+package local
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path"
+	"time"
+)
+
+// WriteProbe implements backend.Prober
+func (rw *readerWriter) WriteProbe(ctx context.Context, name string, b []byte) error {
+	return rw.WriteObject(ctx, name, b)
+}
+
+// WriteObject implements backend.ObjectWriter
+func (rw *readerWriter) WriteObject(_ context.Context, name string, b []byte) error {
+	filename := path.Join(rw.cfg.Path, name)
+	err := os.MkdirAll(path.Dir(filename), os.ModePerm)
+	if err != nil {
+		return err
+	}
+	return rw.writeFile(filename, b)
+}
+
+// ReadProbe implements backend.Prober
+func (rw *readerWriter) ReadProbe(_ context.Context, name string) ([]byte, time.Time, error) {
+	filename := path.Join(rw.cfg.Path, name)
+	info, err := os.Stat(filename)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+
+	b, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+
+	return b, info.ModTime(), nil
+}
+
+// DeleteProbe implements backend.Prober
+func (rw *readerWriter) DeleteProbe(_ context.Context, name string) error {
+	return os.Remove(path.Join(rw.cfg.Path, name))
+}
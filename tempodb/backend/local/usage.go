@@ -0,0 +1,64 @@
+package local
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+
+	"github.com/go-kit/kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"github.com/grafana/tempo/pkg/tenantmetrics"
+)
+
+var (
+	metricTenantBytes = tenantmetrics.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "tempodb",
+		Name:      "local_backend_tenant_bytes",
+		Help:      "Size of the committed blocks of a tenant in the local backend, as of the last time its blocks were listed.",
+	}, []string{"path", "tenant"})
+	metricFilesystemBytes = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "tempodb",
+		Name:      "local_backend_filesystem_bytes",
+		Help:      "Size and free space of the filesystem holding the local backend, as of the last time its tenants were listed.",
+	}, []string{"path", "state"})
+)
+
+// updateFilesystemUsage exports the size and free space of the filesystem of the backend.
+func (rw *readerWriter) updateFilesystemUsage() {
+	size, free, err := filesystemUsage(rw.cfg.Path)
+	if err != nil {
+		level.Warn(rw.logger).Log("msg", "failed to read filesystem usage of local backend", "path", rw.cfg.Path, "err", err)
+		return
+	}
+
+	metricFilesystemBytes.WithLabelValues(rw.cfg.Path, "size").Set(float64(size))
+	metricFilesystemBytes.WithLabelValues(rw.cfg.Path, "free").Set(float64(free))
+}
+
+// updateTenantUsage exports the size of the committed blocks of a tenant.  Files of blocks that are removed while
+// they're read are skipped.
+func (rw *readerWriter) updateTenantUsage(tenantID string, folders []os.FileInfo) {
+	if len(folders) == 0 {
+		metricTenantBytes.DeleteLabelValues(rw.cfg.Path, tenantID)
+		return
+	}
+
+	var bytes int64
+	for _, f := range folders {
+		if !f.IsDir() {
+			bytes += f.Size()
+			continue
+		}
+		files, err := ioutil.ReadDir(path.Join(rw.cfg.Path, tenantID, f.Name()))
+		if err != nil {
+			continue
+		}
+		for _, file := range files {
+			bytes += file.Size()
+		}
+	}
+
+	metricTenantBytes.WithLabelValues(rw.cfg.Path, tenantID).Set(float64(bytes))
+}
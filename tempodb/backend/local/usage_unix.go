@@ -0,0 +1,16 @@
+// +build linux darwin
+
+package local
+
+import "syscall"
+
+// filesystemUsage returns the size and the bytes available to unprivileged users of the filesystem holding dir.
+func filesystemUsage(dir string) (uint64, uint64, error) {
+	var s syscall.Statfs_t
+	err := syscall.Statfs(dir, &s)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return s.Blocks * uint64(s.Bsize), s.Bavail * uint64(s.Bsize), nil
+}
@@ -0,0 +1,68 @@
+package backend
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	probePrefix = "tempo_probe_"
+
+	// MaxProbeClockSkew is the largest difference allowed between the local clock and the modification time the
+	// backend records for the probe object.
+	MaxProbeClockSkew = time.Minute
+)
+
+// Prober is implemented by backends that can write, read and delete an arbitrary object outside of any tenant.
+type Prober interface {
+	WriteProbe(ctx context.Context, name string, b []byte) error
+	ReadProbe(ctx context.Context, name string) ([]byte, time.Time, error)
+	DeleteProbe(ctx context.Context, name string) error
+}
+
+// ObjectWriter is implemented by backends that can write an arbitrary object outside of any tenant.  name may contain
+// slashes.  Objects shouldn't be written to the bucket of the blocks since their top level prefix would be listed as a
+// tenant.
+type ObjectWriter interface {
+	WriteObject(ctx context.Context, name string, b []byte) error
+}
+
+// Probe verifies a backend is usable by writing, reading back and deleting a marker object.  The returned error
+// names the step that failed so permission problems, missing buckets and clock skew are reported at startup
+// instead of during the first flush.
+func Probe(ctx context.Context, p Prober) error {
+	name := probePrefix + uuid.New().String()
+	content := []byte(name)
+
+	start := time.Now()
+	err := p.WriteProbe(ctx, name, content)
+	if err != nil {
+		return fmt.Errorf("backend probe failed to write %s.  check the bucket exists and write permissions: %w", name, err)
+	}
+
+	read, modTime, err := p.ReadProbe(ctx, name)
+	if err != nil {
+		return fmt.Errorf("backend probe failed to read %s.  check read permissions: %w", name, err)
+	}
+	if !bytes.Equal(read, content) {
+		return fmt.Errorf("backend probe read back unexpected content for %s", name)
+	}
+
+	err = p.DeleteProbe(ctx, name)
+	if err != nil {
+		return fmt.Errorf("backend probe failed to delete %s.  check delete permissions: %w", name, err)
+	}
+
+	// the modification time is recorded by the backend somewhere between the start of the write and now
+	if !modTime.IsZero() {
+		if modTime.Before(start.Add(-MaxProbeClockSkew)) || modTime.After(time.Now().Add(MaxProbeClockSkew)) {
+			return fmt.Errorf("backend probe detected clock skew. backend recorded %v, local time %v", modTime, start)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,55 @@
+package backend
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type mockProber struct {
+	objects  map[string][]byte
+	modTime  time.Time
+	writeErr error
+}
+
+func (m *mockProber) WriteProbe(_ context.Context, name string, b []byte) error {
+	if m.writeErr != nil {
+		return m.writeErr
+	}
+	m.objects[name] = b
+	return nil
+}
+
+func (m *mockProber) ReadProbe(_ context.Context, name string) ([]byte, time.Time, error) {
+	b, ok := m.objects[name]
+	if !ok {
+		return nil, time.Time{}, fmt.Errorf("not found")
+	}
+	return b, m.modTime, nil
+}
+
+func (m *mockProber) DeleteProbe(_ context.Context, name string) error {
+	delete(m.objects, name)
+	return nil
+}
+
+func TestProbe(t *testing.T) {
+	p := &mockProber{
+		objects: map[string][]byte{},
+		modTime: time.Now(),
+	}
+	assert.NoError(t, Probe(context.Background(), p))
+	assert.Empty(t, p.objects)
+
+	p.modTime = time.Now().Add(-2 * MaxProbeClockSkew)
+	assert.Error(t, Probe(context.Background(), p))
+
+	p.modTime = time.Time{}
+	p.writeErr = fmt.Errorf("access denied")
+	err := Probe(context.Background(), p)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to write")
+}
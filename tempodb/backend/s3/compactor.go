@@ -1,10 +1,12 @@
 package s3
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"strings"
+	"time"
 
-	"github.com/grafana/tempo/tempodb/encoding/bloom"
 	"github.com/minio/minio-go/v7"
 
 	"github.com/go-kit/kit/log/level"
@@ -31,7 +33,7 @@ func (rw *readerWriter) MarkBlockCompacted(blockID uuid.UUID, tenantID string) e
 		metaFileName,
 		rw.cfg.Bucket,
 		util.CompactedMetaFileName(blockID, tenantID),
-		nil,
+		sseMetadata(rw.sse),
 	)
 	if err != nil {
 		return errors.Wrap(err, "error copying obj meta to compacted obj meta")
@@ -54,9 +56,15 @@ func (rw *readerWriter) ClearBlock(blockID uuid.UUID, tenantID string) error {
 	// list of objects that need to be deleted
 	var delObjects []string
 	delObjects = append(delObjects, util.CompactedMetaFileName(blockID, tenantID))
-	for i := 0; i < bloom.GetShardNum(); i++ {
-		delObjects = append(delObjects, util.BloomFileName(blockID, tenantID, i))
+	// abandoned uploads have no compacted meta and may have a search section
+	delObjects = append(delObjects, util.MetaFileName(blockID, tenantID))
+	delObjects = append(delObjects, util.SearchFileName(blockID, tenantID))
+	// the number of bloom shards differs per block
+	blooms, err := rw.listObjects(util.BloomFilePrefix(blockID, tenantID))
+	if err != nil {
+		return errors.Wrap(err, "error listing bloom shards in s3")
 	}
+	delObjects = append(delObjects, blooms...)
 	delObjects = append(delObjects, util.IndexFileName(blockID, tenantID))
 	delObjects = append(delObjects, util.ObjectFileName(blockID, tenantID))
 	for _, obj := range delObjects {
@@ -94,3 +102,126 @@ func (rw *readerWriter) CompactedBlockMeta(blockID uuid.UUID, tenantID string) (
 
 	return out, err
 }
+
+func (rw *readerWriter) MarkTenantDeleted(tenantID string, mark *backend.TenantDeletionMark) error {
+	if len(tenantID) == 0 {
+		return backend.ErrEmptyTenantID
+	}
+
+	b, err := json.Marshal(mark)
+	if err != nil {
+		return err
+	}
+
+	_, err = rw.core.Client.PutObject(
+		context.TODO(),
+		rw.cfg.Bucket,
+		util.TenantDeletionMarkFileName(tenantID),
+		bytes.NewReader(b),
+		int64(len(b)),
+		rw.putObjectOptions(),
+	)
+	return errors.Wrap(err, "error uploading tenant deletion mark to s3")
+}
+
+func (rw *readerWriter) TenantDeletionMark(tenantID string) (*backend.TenantDeletionMark, error) {
+	if len(tenantID) == 0 {
+		return nil, backend.ErrEmptyTenantID
+	}
+
+	name := util.TenantDeletionMarkFileName(tenantID)
+	b, _, err := rw.readAllWithObjInfo(context.TODO(), name)
+	if err == backend.ErrMetaDoesNotExist {
+		return nil, backend.ErrDeletionMarkDoesNotExist
+	} else if err != nil {
+		return nil, errors.Wrapf(err, "error fetching tenant deletion mark %s", name)
+	}
+
+	out := &backend.TenantDeletionMark{}
+	err = json.Unmarshal(b, out)
+	if err != nil {
+		return nil, err
+	}
+
+	return out, nil
+}
+
+// ClearTenantDeletionMark implements backend.Compactor.  Removing a key that doesn't exist succeeds.
+func (rw *readerWriter) ClearTenantDeletionMark(tenantID string) error {
+	if len(tenantID) == 0 {
+		return backend.ErrEmptyTenantID
+	}
+
+	return rw.core.RemoveObject(context.TODO(), rw.cfg.Bucket, util.TenantDeletionMarkFileName(tenantID), minio.RemoveObjectOptions{})
+}
+
+// ClearOrphans implements backend.OrphanCleaner.  Compacted blocks are written with multipart uploads that are only
+// completed once the block is done.  If the compactor crashes first the parts are kept and billed, but aren't listed
+// with the objects of the bucket.  Uploads of other objects in the bucket are left alone.
+func (rw *readerWriter) ClearOrphans(ctx context.Context, cutoff time.Time, dryRun bool) (int, error) {
+	found := 0
+	keyMarker, uploadIDMarker := "", ""
+	for {
+		result, err := rw.core.ListMultipartUploads(ctx, rw.cfg.Bucket, "", keyMarker, uploadIDMarker, "", 1000)
+		if err != nil {
+			return found, errors.Wrap(err, "error listing multipart uploads")
+		}
+
+		for _, upload := range result.Uploads {
+			if !isObjectFileName(upload.Key) || !upload.Initiated.Before(cutoff) {
+				continue
+			}
+
+			found++
+			if dryRun {
+				level.Info(rw.logger).Log("msg", "found abandoned multipart upload", "objectName", upload.Key, "uploadID", upload.UploadID, "initiated", upload.Initiated, "dryRun", true)
+				continue
+			}
+
+			level.Info(rw.logger).Log("msg", "aborting abandoned multipart upload", "objectName", upload.Key, "uploadID", upload.UploadID, "initiated", upload.Initiated)
+			err = rw.core.AbortMultipartUpload(ctx, rw.cfg.Bucket, upload.Key, upload.UploadID)
+			if err != nil {
+				return found, errors.Wrapf(err, "error aborting multipart upload, object: %s", upload.Key)
+			}
+		}
+
+		if !result.IsTruncated {
+			return found, nil
+		}
+		keyMarker, uploadIDMarker = result.NextKeyMarker, result.NextUploadIDMarker
+	}
+}
+
+// listObjects returns the names of every object starting with prefix.
+func (rw *readerWriter) listObjects(prefix string) ([]string, error) {
+	var names []string
+	marker := ""
+	for {
+		// ListObjects(bucket, prefix, marker, delimiter string, maxKeys int)
+		res, err := rw.core.ListObjects(rw.cfg.Bucket, prefix, marker, "", 0)
+		if err != nil {
+			return nil, err
+		}
+		for _, obj := range res.Contents {
+			names = append(names, obj.Key)
+		}
+
+		if !res.IsTruncated || len(res.Contents) == 0 {
+			return names, nil
+		}
+		marker = res.Contents[len(res.Contents)-1].Key
+	}
+}
+
+// isObjectFileName returns whether key is the data object of a block.
+func isObjectFileName(key string) bool {
+	parts := strings.Split(key, "/")
+	if len(parts) != 3 || parts[0] == "" {
+		return false
+	}
+	blockID, err := uuid.Parse(parts[1])
+	if err != nil {
+		return false
+	}
+	return key == util.ObjectFileName(blockID, parts[0])
+}
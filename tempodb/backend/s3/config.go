@@ -1,5 +1,27 @@
 package s3
 
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/encrypt"
+)
+
+// Server side encryption types.
+const (
+	SSETypeS3  = "SSE-S3"
+	SSETypeKMS = "SSE-KMS"
+)
+
+const (
+	minPartSize = 5 * 1024 * 1024
+	maxPartSize = 5 * 1024 * 1024 * 1024
+)
+
 type Config struct {
 	Bucket    string `yaml:"bucket"`
 	Endpoint  string `yaml:"endpoint"`
@@ -7,5 +29,97 @@ type Config struct {
 	AccessKey string `yaml:"access_key"`
 	SecretKey string `yaml:"secret_key"`
 	Insecure  bool   `yaml:"insecure"`
-	PartSize  uint64 `yaml:"part_size"`
+	// PartSize is the size of the parts of multipart uploads.  0 lets the client choose.
+	PartSize uint64 `yaml:"part_size"`
+	// ForcePathStyle addresses buckets as <endpoint>/<bucket> instead of <bucket>.<endpoint>, as MinIO and Ceph
+	// usually require.
+	ForcePathStyle bool      `yaml:"forcepathstyle"`
+	SSE            SSEConfig `yaml:"sse"`
+	// HedgeRequestsAt sends another GET if the earlier ones haven't responded in this long.  0 disables hedging.
+	HedgeRequestsAt time.Duration `yaml:"hedge_requests_at"`
+	// HedgeRequestsUpTo is the most GETs sent for a single read when hedging, including the first.
+	HedgeRequestsUpTo int `yaml:"hedge_requests_up_to"`
+}
+
+// SSEConfig configures server side encryption of the objects written.
+type SSEConfig struct {
+	// Type is SSE-S3 or SSE-KMS.  Objects are written without requesting encryption if empty.
+	Type string `yaml:"type"`
+	// KMSKeyID is the KMS key SSE-KMS encrypts with.  The account's default key is used if empty.
+	KMSKeyID string `yaml:"kms_key_id"`
+	// KMSEncryptionContext is passed to KMS with SSE-KMS.
+	KMSEncryptionContext map[string]string `yaml:"kms_encryption_context"`
+}
+
+func (cfg *Config) validate() error {
+	if cfg.PartSize != 0 && (cfg.PartSize < minPartSize || cfg.PartSize > maxPartSize) {
+		return fmt.Errorf("s3 part size must be 0 or between %d and %d bytes", uint64(minPartSize), uint64(maxPartSize))
+	}
+	return nil
+}
+
+func (cfg *Config) bucketLookup() minio.BucketLookupType {
+	if cfg.ForcePathStyle {
+		return minio.BucketLookupPath
+	}
+	return minio.BucketLookupAuto
+}
+
+// serverSide returns the encryption to request for written objects, or nil if none is configured.
+func (cfg *SSEConfig) serverSide() (encrypt.ServerSide, error) {
+	switch cfg.Type {
+	case "":
+		return nil, nil
+	case SSETypeS3:
+		return encrypt.NewSSE(), nil
+	case SSETypeKMS:
+		sse := &kmsServerSide{keyID: cfg.KMSKeyID}
+		if len(cfg.KMSEncryptionContext) > 0 {
+			context, err := json.Marshal(cfg.KMSEncryptionContext)
+			if err != nil {
+				return nil, err
+			}
+			sse.context = base64.StdEncoding.EncodeToString(context)
+		}
+		return sse, nil
+	default:
+		return nil, fmt.Errorf("unsupported s3 sse type %q, must be %s or %s", cfg.Type, SSETypeS3, SSETypeKMS)
+	}
+}
+
+// kmsServerSide requests SSE-KMS.  encrypt.NewSSEKMS of the vendored client sends the encryption context in a header
+// s3 doesn't know.
+type kmsServerSide struct {
+	keyID   string
+	context string // base64 encoded json
+}
+
+func (s *kmsServerSide) Type() encrypt.Type {
+	return encrypt.KMS
+}
+
+func (s *kmsServerSide) Marshal(h http.Header) {
+	h.Set("X-Amz-Server-Side-Encryption", "aws:kms")
+	if s.keyID != "" {
+		h.Set("X-Amz-Server-Side-Encryption-Aws-Kms-Key-Id", s.keyID)
+	}
+	if s.context != "" {
+		h.Set("X-Amz-Server-Side-Encryption-Context", s.context)
+	}
+}
+
+// sseMetadata returns the headers requesting sse as the metadata of a copy.  Copies aren't encrypted by the settings
+// of the source.
+func sseMetadata(sse encrypt.ServerSide) map[string]string {
+	if sse == nil {
+		return nil
+	}
+
+	h := http.Header{}
+	sse.Marshal(h)
+	metadata := make(map[string]string, len(h))
+	for k := range h {
+		metadata[k] = h.Get(k)
+	}
+	return metadata
 }
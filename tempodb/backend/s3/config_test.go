@@ -0,0 +1,68 @@
+package s3
+
+import (
+	"testing"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfigValidate(t *testing.T) {
+	for _, partSize := range []uint64{0, minPartSize, maxPartSize} {
+		assert.NoError(t, (&Config{PartSize: partSize}).validate())
+	}
+	for _, partSize := range []uint64{1, minPartSize - 1, maxPartSize + 1} {
+		assert.Error(t, (&Config{PartSize: partSize}).validate())
+	}
+
+	assert.Equal(t, minio.BucketLookupAuto, (&Config{}).bucketLookup())
+	assert.Equal(t, minio.BucketLookupPath, (&Config{ForcePathStyle: true}).bucketLookup())
+}
+
+func TestSSE(t *testing.T) {
+	tests := []struct {
+		name     string
+		cfg      SSEConfig
+		expected map[string]string
+	}{
+		{
+			name: "none",
+		},
+		{
+			name: "s3",
+			cfg:  SSEConfig{Type: SSETypeS3},
+			expected: map[string]string{
+				"X-Amz-Server-Side-Encryption": "AES256",
+			},
+		},
+		{
+			name: "kms",
+			cfg:  SSEConfig{Type: SSETypeKMS, KMSKeyID: "key"},
+			expected: map[string]string{
+				"X-Amz-Server-Side-Encryption":                "aws:kms",
+				"X-Amz-Server-Side-Encryption-Aws-Kms-Key-Id": "key",
+			},
+		},
+		{
+			name: "kms with context",
+			cfg:  SSEConfig{Type: SSETypeKMS, KMSKeyID: "key", KMSEncryptionContext: map[string]string{"app": "tempo"}},
+			expected: map[string]string{
+				"X-Amz-Server-Side-Encryption":                "aws:kms",
+				"X-Amz-Server-Side-Encryption-Aws-Kms-Key-Id": "key",
+				"X-Amz-Server-Side-Encryption-Context":        "eyJhcHAiOiJ0ZW1wbyJ9",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sse, err := tt.cfg.serverSide()
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, sseMetadata(sse))
+		})
+	}
+
+	_, err := (&SSEConfig{Type: "SSE-C"}).serverSide()
+	assert.Error(t, err)
+}
@@ -0,0 +1,56 @@
+package s3
+
+import (
+	"bytes"
+	"context"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/pkg/errors"
+)
+
+// WriteProbe implements backend.Prober
+func (rw *readerWriter) WriteProbe(ctx context.Context, name string, b []byte) error {
+	return rw.probeError(rw.WriteObject(ctx, name, b))
+}
+
+// WriteObject implements backend.ObjectWriter
+func (rw *readerWriter) WriteObject(ctx context.Context, name string, b []byte) error {
+	_, err := rw.core.Client.PutObject(
+		ctx,
+		rw.cfg.Bucket,
+		name,
+		bytes.NewReader(b),
+		int64(len(b)),
+		rw.putObjectOptions(),
+	)
+	return err
+}
+
+// ReadProbe implements backend.Prober
+func (rw *readerWriter) ReadProbe(ctx context.Context, name string) ([]byte, time.Time, error) {
+	b, info, err := rw.readAllWithObjInfo(ctx, name)
+	return b, info.LastModified, rw.probeError(errors.Cause(err))
+}
+
+// DeleteProbe implements backend.Prober
+func (rw *readerWriter) DeleteProbe(ctx context.Context, name string) error {
+	return rw.probeError(rw.core.RemoveObject(ctx, rw.cfg.Bucket, name, minio.RemoveObjectOptions{}))
+}
+
+func (rw *readerWriter) probeError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	resp := minio.ToErrorResponse(err)
+	switch resp.Code {
+	case "NoSuchBucket":
+		return errors.Wrapf(err, "bucket %s does not exist", rw.cfg.Bucket)
+	case "AccessDenied":
+		return errors.Wrapf(err, "access denied to bucket %s", rw.cfg.Bucket)
+	case "RequestTimeTooSkewed":
+		return errors.Wrap(err, "clock skew between this host and s3")
+	}
+	return err
+}
@@ -18,6 +18,7 @@ import (
 	"github.com/grafana/tempo/tempodb/encoding"
 	"github.com/minio/minio-go/v7"
 	"github.com/minio/minio-go/v7/pkg/credentials"
+	"github.com/minio/minio-go/v7/pkg/encrypt"
 	"github.com/pkg/errors"
 )
 
@@ -30,10 +31,21 @@ type readerWriter struct {
 	logger log.Logger
 	cfg    *Config
 	core   *minio.Core
+	sse    encrypt.ServerSide // nil if sse isn't configured
 }
 
 func New(cfg *Config) (backend.Reader, backend.Writer, backend.Compactor, error) {
 	l := log_util.Logger
+	if err := cfg.validate(); err != nil {
+		return nil, nil, nil, err
+	}
+	sse, err := cfg.SSE.serverSide()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	// the IAM provider also covers IRSA, assuming the role in AWS_ROLE_ARN with the token in
+	// AWS_WEB_IDENTITY_TOKEN_FILE, both of which EKS sets for pods of annotated service accounts
 	creds := credentials.NewChainCredentials([]credentials.Provider{
 		&credentials.EnvAWS{},
 		&credentials.Static{
@@ -51,19 +63,22 @@ func New(cfg *Config) (backend.Reader, backend.Writer, backend.Compactor, error)
 			},
 		},
 	})
+	transport, err := minio.DefaultTransport(!cfg.Insecure)
+	if err != nil {
+		return nil, nil, nil, err
+	}
 	opts := &minio.Options{
-		Secure: !cfg.Insecure,
-		Creds:  creds,
-		Region: cfg.Region,
+		Secure:       !cfg.Insecure,
+		Creds:        creds,
+		Region:       cfg.Region,
+		BucketLookup: cfg.bucketLookup(),
+		Transport:    backend.NewHedgedTransport("s3", cfg.HedgeRequestsAt, cfg.HedgeRequestsUpTo, backend.NewTracingTransport("s3", transport)),
 	}
 	core, err := minio.NewCore(cfg.Endpoint, opts)
 	if err != nil {
 		return nil, nil, nil, err
 	}
 
-	// TODO: add custom transport with instrumentation.
-	//client.SetCustomTransport(minio.DefaultTransport(!cfg.Insecure))
-
 	// make bucket name if doesn't exist already
 	err = core.MakeBucket(context.Background(), cfg.Bucket, minio.MakeBucketOptions{Region: cfg.Region})
 	if err != nil {
@@ -83,6 +98,7 @@ func New(cfg *Config) (backend.Reader, backend.Writer, backend.Compactor, error)
 		logger: l,
 		cfg:    cfg,
 		core:   core,
+		sse:    sse,
 	}
 	return rw, rw, rw, nil
 }
@@ -99,7 +115,7 @@ func (rw *readerWriter) Write(ctx context.Context, meta *encoding.BlockMeta, bBl
 		rw.cfg.Bucket,
 		objName,
 		objectFilePath,
-		minio.PutObjectOptions{PartSize: rw.cfg.PartSize},
+		rw.putObjectOptions(),
 	)
 	if err != nil {
 		return errors.Wrapf(err, "error writing object to s3 backend, object %s", objName)
@@ -115,6 +131,24 @@ func (rw *readerWriter) Write(ctx context.Context, meta *encoding.BlockMeta, bBl
 	return nil
 }
 
+// WriteSearch implements backend.Writer
+func (rw *readerWriter) WriteSearch(ctx context.Context, meta *encoding.BlockMeta, bSearch []byte) error {
+	size, err := rw.core.Client.PutObject(
+		ctx,
+		rw.cfg.Bucket,
+		util.SearchFileName(meta.BlockID, meta.TenantID),
+		bytes.NewReader(bSearch),
+		int64(len(bSearch)),
+		rw.putObjectOptions(),
+	)
+	if err != nil {
+		return errors.Wrap(err, "error uploading search to s3")
+	}
+	level.Debug(rw.logger).Log("msg", "block search uploaded to s3", "size", size)
+
+	return nil
+}
+
 // WriteBlockMeta implements backend.Writer
 func (rw *readerWriter) WriteBlockMeta(ctx context.Context, tracker backend.AppendTracker, meta *encoding.BlockMeta, bBloom [][]byte, bIndex []byte) error {
 	if tracker != nil {
@@ -142,9 +176,7 @@ func (rw *readerWriter) WriteBlockMeta(ctx context.Context, tracker backend.Appe
 
 	blockID := meta.BlockID
 	tenantID := meta.TenantID
-	options := minio.PutObjectOptions{
-		PartSize: rw.cfg.PartSize,
-	}
+	options := rw.putObjectOptions()
 
 	for i, b := range bBloom {
 		size, err := rw.core.Client.PutObject(
@@ -205,9 +237,7 @@ type AppenderTracker struct {
 // AppendObject implements backend.Writer
 func (rw *readerWriter) AppendObject(ctx context.Context, tracker backend.AppendTracker, meta *encoding.BlockMeta, bObject []byte) (backend.AppendTracker, error) {
 	var a AppenderTracker
-	options := minio.PutObjectOptions{
-		PartSize: rw.cfg.PartSize,
-	}
+	options := rw.putObjectOptions()
 	if tracker != nil {
 		a = tracker.(AppenderTracker)
 	} else {
@@ -236,7 +266,7 @@ func (rw *readerWriter) AppendObject(ctx context.Context, tracker backend.Append
 		int64(len(bObject)),
 		"",
 		"",
-		nil,
+		rw.sse,
 	)
 	if err != nil {
 		return a, errors.Wrap(err, "error in multipart upload")
@@ -246,6 +276,14 @@ func (rw *readerWriter) AppendObject(ctx context.Context, tracker backend.Append
 	return a, nil
 }
 
+// putObjectOptions returns the options objects are written with.
+func (rw *readerWriter) putObjectOptions() minio.PutObjectOptions {
+	return minio.PutObjectOptions{
+		PartSize:             rw.cfg.PartSize,
+		ServerSideEncryption: rw.sse,
+	}
+}
+
 // Tenants implements backend.Reader
 func (rw *readerWriter) Tenants(ctx context.Context) ([]string, error) {
 	// ListObjects(bucket, prefix, marker, delimiter string, maxKeys int)
@@ -298,6 +336,12 @@ func (rw *readerWriter) BlockMeta(ctx context.Context, blockID uuid.UUID, tenant
 	return out, nil
 }
 
+// Search implements backend.Reader
+func (rw *readerWriter) Search(ctx context.Context, blockID uuid.UUID, tenantID string) ([]byte, error) {
+	searchFileName := util.SearchFileName(blockID, tenantID)
+	return rw.readAll(ctx, searchFileName)
+}
+
 // Bloom implements backend.Reader
 func (rw *readerWriter) Bloom(ctx context.Context, blockID uuid.UUID, tenantID string, bloomShard int) ([]byte, error) {
 	bloomFileName := util.BloomFileName(blockID, tenantID, bloomShard)
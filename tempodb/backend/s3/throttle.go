@@ -0,0 +1,18 @@
+package s3
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/minio/minio-go/v7"
+)
+
+// Throttled implements backend.ThrottleDetector
+func (rw *readerWriter) Throttled(err error) bool {
+	var resp minio.ErrorResponse
+	if !errors.As(err, &resp) {
+		return false
+	}
+
+	return resp.Code == "SlowDown" || resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable
+}
@@ -0,0 +1,61 @@
+package backend
+
+import (
+	"net/http"
+
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+)
+
+// tracingTransport creates a span for every request made on behalf of a traced operation and propagates its context
+// in the request headers.  This allows a slow query to be matched to the exact object store requests it made, both in
+// Tempo's own traces and in any proxy or store that records the headers.
+type tracingTransport struct {
+	component string
+	next      http.RoundTripper
+}
+
+// NewTracingTransport wraps next so requests with a span in their context are traced.  Requests without a parent span,
+// such as blocklist polling, are passed through untouched to avoid creating a root span per request.
+func NewTracingTransport(component string, next http.RoundTripper) http.RoundTripper {
+	return &tracingTransport{
+		component: component,
+		next:      next,
+	}
+}
+
+func (t *tracingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	parent := opentracing.SpanFromContext(req.Context())
+	if parent == nil {
+		return t.next.RoundTrip(req)
+	}
+
+	span := parent.Tracer().StartSpan(t.component+" "+req.Method, opentracing.ChildOf(parent.Context()))
+	defer span.Finish()
+
+	ext.SpanKindRPCClient.Set(span)
+	ext.Component.Set(span, t.component)
+	ext.HTTPMethod.Set(span, req.Method)
+	ext.HTTPUrl.Set(span, req.URL.Path)
+	if r := req.Header.Get("Range"); r != "" {
+		span.SetTag("http.range", r)
+	}
+
+	// a RoundTripper must not modify the passed request
+	req = req.Clone(req.Context())
+	_ = span.Tracer().Inject(span.Context(), opentracing.HTTPHeaders, opentracing.HTTPHeadersCarrier(req.Header))
+
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		ext.Error.Set(span, true)
+		span.SetTag("error.message", err.Error())
+		return nil, err
+	}
+
+	ext.HTTPStatusCode.Set(span, uint16(resp.StatusCode))
+	if resp.StatusCode >= 500 {
+		ext.Error.Set(span, true)
+	}
+
+	return resp, nil
+}
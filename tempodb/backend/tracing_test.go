@@ -0,0 +1,57 @@
+package backend
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/opentracing/opentracing-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/uber/jaeger-client-go"
+)
+
+func TestTracingTransport(t *testing.T) {
+	var traceHeader string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceHeader = r.Header.Get(jaeger.TraceContextHeaderName)
+	}))
+	defer server.Close()
+
+	reporter := jaeger.NewInMemoryReporter()
+	tracer, closer := jaeger.NewTracer("test", jaeger.NewConstSampler(true), reporter)
+	defer closer.Close()
+
+	client := &http.Client{
+		Transport: NewTracingTransport("test", http.DefaultTransport),
+	}
+
+	// no parent span
+	req, err := http.NewRequest(http.MethodGet, server.URL+"/bucket/object", nil)
+	assert.NoError(t, err)
+	resp, err := client.Do(req)
+	assert.NoError(t, err)
+	resp.Body.Close()
+	assert.Empty(t, traceHeader)
+	assert.Equal(t, 0, reporter.SpansSubmitted())
+
+	// parent span
+	parent := tracer.StartSpan("query")
+	ctx := opentracing.ContextWithSpan(context.Background(), parent)
+	req, err = http.NewRequestWithContext(ctx, http.MethodGet, server.URL+"/bucket/object", nil)
+	assert.NoError(t, err)
+	resp, err = client.Do(req)
+	assert.NoError(t, err)
+	resp.Body.Close()
+	parent.Finish()
+
+	assert.NotEmpty(t, traceHeader)
+	assert.Empty(t, req.Header.Get(jaeger.TraceContextHeaderName), "passed request must not be modified")
+
+	spans := reporter.GetSpans()
+	assert.Len(t, spans, 2)
+	child := spans[0].(*jaeger.Span)
+	assert.Equal(t, "test GET", child.OperationName())
+	assert.Equal(t, parent.(*jaeger.Span).SpanContext().TraceID(), child.SpanContext().TraceID())
+	assert.Contains(t, traceHeader, child.SpanContext().SpanID().String())
+}
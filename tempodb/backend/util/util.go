@@ -13,13 +13,22 @@ func MetaFileName(blockID uuid.UUID, tenantID string) string {
 }
 
 func BloomFileName(blockID uuid.UUID, tenantID string, bloomShard int) string {
-	return path.Join(RootPath(blockID, tenantID), "bloom-"+strconv.Itoa(bloomShard))
+	return BloomFilePrefix(blockID, tenantID) + strconv.Itoa(bloomShard)
+}
+
+// BloomFilePrefix is the prefix of the names of every bloom shard of a block.
+func BloomFilePrefix(blockID uuid.UUID, tenantID string) string {
+	return path.Join(RootPath(blockID, tenantID), "bloom-")
 }
 
 func IndexFileName(blockID uuid.UUID, tenantID string) string {
 	return path.Join(RootPath(blockID, tenantID), "index")
 }
 
+func SearchFileName(blockID uuid.UUID, tenantID string) string {
+	return path.Join(RootPath(blockID, tenantID), "search")
+}
+
 func ObjectFileName(blockID uuid.UUID, tenantID string) string {
 	return path.Join(RootPath(blockID, tenantID), "data")
 }
@@ -28,6 +37,11 @@ func CompactedMetaFileName(blockID uuid.UUID, tenantID string) string {
 	return path.Join(RootPath(blockID, tenantID), "meta.compacted.json")
 }
 
+// TenantDeletionMarkFileName is a file rather than a prefix so it isn't listed as a block of the tenant.
+func TenantDeletionMarkFileName(tenantID string) string {
+	return path.Join(tenantID, "deletion-mark.json")
+}
+
 func BlockFileName(blockID uuid.UUID, tenantID string) string {
 	return RootPath(blockID, tenantID) + "/"
 }
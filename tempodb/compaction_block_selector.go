@@ -59,15 +59,24 @@ type timeWindowBlockSelector struct {
 	blocklist            []*encoding.BlockMeta
 	MaxCompactionRange   time.Duration // Size of the time window - say 6 hours
 	MaxCompactionObjects int           // maximum size of compacted objects
+	MaxBlockBytes        uint64        // maximum size of compacted blocks in bytes.  0 is unlimited
 }
 
 var _ (CompactionBlockSelector) = (*timeWindowBlockSelector)(nil)
 
-func newTimeWindowBlockSelector(blocklist []*encoding.BlockMeta, maxCompactionRange time.Duration, maxCompactionObjects int) CompactionBlockSelector {
+func newTimeWindowBlockSelector(blocklist []*encoding.BlockMeta, maxCompactionRange time.Duration, maxCompactionObjects int, maxBlockBytes uint64) CompactionBlockSelector {
 	twbs := &timeWindowBlockSelector{
-		blocklist:            append([]*encoding.BlockMeta(nil), blocklist...),
 		MaxCompactionRange:   maxCompactionRange,
 		MaxCompactionObjects: maxCompactionObjects,
+		MaxBlockBytes:        maxBlockBytes,
+	}
+
+	// full blocks are never compacted again.  otherwise the blocks split by the level limits would be combined and
+	// split over and over
+	for _, b := range blocklist {
+		if !twbs.full(b) {
+			twbs.blocklist = append(twbs.blocklist, b)
+		}
 	}
 
 	// sort by compaction window, level, and then size
@@ -101,7 +110,10 @@ func newTimeWindowBlockSelector(blocklist []*encoding.BlockMeta, maxCompactionRa
 			}
 		}
 
-		return bi.TotalObjects < bj.TotalObjects
+		if bi.TotalObjects != bj.TotalObjects {
+			return bi.TotalObjects < bj.TotalObjects
+		}
+		return bi.Size < bj.Size
 	})
 
 	return twbs
@@ -135,38 +147,24 @@ func (twbs *timeWindowBlockSelector) BlocksToCompact() ([]*encoding.BlockMeta, s
 			blockWindow := twbs.windowForBlock(windowBlocks[0])
 
 			hashString := fmt.Sprintf("%v", windowBlocks[0].TenantID)
-			compact := true
 
 			// the active window should be compacted by level
 			if activeWindow <= blockWindow {
-				// search forward for inputBlocks in a row that have the same compaction level
+				// search forward for inputBlocks in a row that have the same compaction level and are small enough
 				for i := 0; i+inputBlocks-1 < len(windowBlocks); i++ {
-					if windowBlocks[i].CompactionLevel == windowBlocks[i+inputBlocks-1].CompactionLevel {
-						compactBlocks = windowBlocks[i : i+inputBlocks]
+					candidates := windowBlocks[i : i+inputBlocks]
+					if candidates[0].CompactionLevel == candidates[inputBlocks-1].CompactionLevel && twbs.fits(candidates) {
+						compactBlocks = candidates
+						hashString = fmt.Sprintf("%v-%v-%v", compactBlocks[0].TenantID, compactBlocks[0].CompactionLevel, currentWindow)
 						break
 					}
 				}
-
-				compact = false
-				if len(compactBlocks) > 0 {
-					compact = true
-					hashString = fmt.Sprintf("%v-%v-%v", compactBlocks[0].TenantID, compactBlocks[0].CompactionLevel, currentWindow)
-				}
-			} else { // all other windows will be compacted using their two smallest blocks
+			} else if twbs.fits(windowBlocks[:inputBlocks]) { // all other windows will be compacted using their two smallest blocks
 				compactBlocks = windowBlocks[:inputBlocks]
 				hashString = fmt.Sprintf("%v-%v", compactBlocks[0].TenantID, currentWindow)
 			}
 
-			// are they small enough
-			totalObjects := 0
-			for _, block := range compactBlocks {
-				totalObjects += block.TotalObjects
-			}
-			if totalObjects > twbs.MaxCompactionObjects {
-				compact = false
-			}
-
-			if compact {
+			if len(compactBlocks) > 0 {
 				// remove the blocks we are returning so we don't consider them again
 				//   this is horribly inefficient as it's written
 				for _, blockToCompact := range compactBlocks {
@@ -191,6 +189,23 @@ func (twbs *timeWindowBlockSelector) BlocksToCompact() ([]*encoding.BlockMeta, s
 	return nil, ""
 }
 
+// full returns true if the block is as large as a compacted block may be.
+func (twbs *timeWindowBlockSelector) full(meta *encoding.BlockMeta) bool {
+	return meta.TotalObjects >= twbs.MaxCompactionObjects || (twbs.MaxBlockBytes > 0 && meta.Size >= twbs.MaxBlockBytes)
+}
+
+// fits returns true if the blocks compacted together stay within the max objects and bytes of a compacted block.
+func (twbs *timeWindowBlockSelector) fits(blocks []*encoding.BlockMeta) bool {
+	totalObjects := 0
+	var totalBytes uint64
+	for _, block := range blocks {
+		totalObjects += block.TotalObjects
+		totalBytes += block.Size
+	}
+
+	return totalObjects <= twbs.MaxCompactionObjects && (twbs.MaxBlockBytes == 0 || totalBytes <= twbs.MaxBlockBytes)
+}
+
 func (twbs *timeWindowBlockSelector) windowForBlock(meta *encoding.BlockMeta) int64 {
 	return twbs.windowForTime(meta.EndTime)
 }
@@ -299,7 +299,7 @@ func TestTimeWindowBlockSelectorBlocksToCompact(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			selector := newTimeWindowBlockSelector(tt.blocklist, time.Second, 100)
+			selector := newTimeWindowBlockSelector(tt.blocklist, time.Second, 100, 0)
 
 			actual, _ := selector.BlocksToCompact()
 			assert.Equal(t, tt.expected, actual)
@@ -310,6 +310,58 @@ func TestTimeWindowBlockSelectorBlocksToCompact(t *testing.T) {
 	}
 }
 
+func TestTimeWindowBlockSelectorMaxBlockSize(t *testing.T) {
+	now := time.Now()
+
+	full := &encoding.BlockMeta{
+		BlockID:         uuid.MustParse("00000000-0000-0000-0000-000000000001"),
+		EndTime:         now,
+		CompactionLevel: 1,
+		TotalObjects:    10,
+		Size:            1000,
+	}
+	fullObjects := &encoding.BlockMeta{
+		BlockID:         uuid.MustParse("00000000-0000-0000-0000-000000000002"),
+		EndTime:         now,
+		CompactionLevel: 1,
+		TotalObjects:    100,
+		Size:            10,
+	}
+	large := &encoding.BlockMeta{
+		BlockID:      uuid.MustParse("00000000-0000-0000-0000-000000000003"),
+		EndTime:      now,
+		TotalObjects: 1,
+		Size:         900,
+	}
+	small := []*encoding.BlockMeta{
+		{
+			BlockID:      uuid.MustParse("00000000-0000-0000-0000-000000000004"),
+			EndTime:      now,
+			TotalObjects: 2,
+			Size:         200,
+		},
+		{
+			BlockID:      uuid.MustParse("00000000-0000-0000-0000-000000000005"),
+			EndTime:      now,
+			TotalObjects: 3,
+			Size:         300,
+		},
+	}
+
+	// full blocks are never selected, even with another of their level.  the large block doesn't fit with any other so
+	// the next two blocks of its level are selected
+	selector := newTimeWindowBlockSelector([]*encoding.BlockMeta{full, fullObjects, large, small[0], small[1]}, time.Second, 100, 1000)
+	actual, _ := selector.BlocksToCompact()
+	assert.Equal(t, small, actual)
+	actual, _ = selector.BlocksToCompact()
+	assert.Nil(t, actual)
+
+	// unlimited bytes
+	selector = newTimeWindowBlockSelector([]*encoding.BlockMeta{full, fullObjects, large, small[0]}, time.Second, 100, 0)
+	actual, _ = selector.BlocksToCompact()
+	assert.Equal(t, []*encoding.BlockMeta{large, small[0]}, actual)
+}
+
 func TestTimeWindowBlockSelectorSort(t *testing.T) {
 	now := time.Now()
 	timeWindow := 12 * time.Hour
@@ -487,7 +539,7 @@ func TestTimeWindowBlockSelectorSort(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			selector := newTimeWindowBlockSelector(tt.blocklist, timeWindow, 100)
+			selector := newTimeWindowBlockSelector(tt.blocklist, timeWindow, 100, 0)
 			actual := selector.(*timeWindowBlockSelector).blocklist
 			assert.Equal(t, tt.expected, actual)
 		})
@@ -0,0 +1,177 @@
+package tempodb
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/grafana/tempo/tempodb/encoding"
+)
+
+const (
+	reasonNotEnoughBlocks = "not enough blocks in compaction window"
+	reasonNoLevelMatch    = "not enough blocks at the same compaction level in the active window"
+	reasonTooManyObjects  = "combined objects or bytes exceed max_compaction_objects or max_block_bytes"
+	reasonBlockFull       = "block is at max_compaction_objects or max_block_bytes and won't be compacted again"
+)
+
+// CompactionPlan describes the work the compactor would perform for a tenant if a compaction cycle started now.
+type CompactionPlan struct {
+	TenantID string               `json:"tenantID"`
+	Disabled bool                 `json:"disabled,omitempty"` // compaction of the tenant is disabled by its overrides
+	Jobs     []*CompactionJob     `json:"jobs"`
+	Skipped  []*CompactionSkipped `json:"skipped"`
+}
+
+// CompactionJob is a set of blocks the block selector would hand to the compactor together.
+type CompactionJob struct {
+	HashString   string      `json:"hash"`
+	Owned        bool        `json:"owned"`
+	Level        uint8       `json:"level"`
+	Window       int64       `json:"window"`
+	TotalObjects int         `json:"totalObjects"`
+	Blocks       []uuid.UUID `json:"blocks"`
+}
+
+// CompactionSkipped is a block that would not be compacted this cycle and the reason why.
+type CompactionSkipped struct {
+	BlockID      uuid.UUID `json:"blockID"`
+	Level        uint8     `json:"level"`
+	Window       int64     `json:"window"`
+	TotalObjects int       `json:"totalObjects"`
+	Reason       string    `json:"reason"`
+}
+
+// PlanCompaction runs the block selector over the passed blocklist without compacting anything.  If sharder is nil
+// every job is considered owned.
+func PlanCompaction(tenantID string, blocklist []*encoding.BlockMeta, cfg *CompactorConfig, sharder CompactorSharder) *CompactionPlan {
+	plan := &CompactionPlan{
+		TenantID: tenantID,
+		Jobs:     []*CompactionJob{},
+		Skipped:  []*CompactionSkipped{},
+	}
+
+	twbs := &timeWindowBlockSelector{
+		MaxCompactionRange:   cfg.MaxCompactionRange,
+		MaxCompactionObjects: cfg.MaxCompactionObjects,
+		MaxBlockBytes:        cfg.MaxBlockBytes,
+	}
+	selector := newTimeWindowBlockSelector(blocklist, cfg.MaxCompactionRange, cfg.MaxCompactionObjects, cfg.MaxBlockBytes)
+
+	selected := map[uuid.UUID]struct{}{}
+	for {
+		toBeCompacted, hashString := selector.BlocksToCompact()
+		if len(toBeCompacted) == 0 {
+			break
+		}
+
+		job := &CompactionJob{
+			HashString: hashString,
+			Owned:      sharder == nil || sharder.Owns(hashString),
+			Level:      compactionLevelForBlocks(toBeCompacted),
+			Window:     twbs.windowForBlock(toBeCompacted[0]),
+		}
+		for _, meta := range toBeCompacted {
+			job.TotalObjects += meta.TotalObjects
+			job.Blocks = append(job.Blocks, meta.BlockID)
+			selected[meta.BlockID] = struct{}{}
+		}
+		plan.Jobs = append(plan.Jobs, job)
+	}
+
+	// everything left over was skipped.  group by window to explain why
+	remaining := map[int64][]*encoding.BlockMeta{}
+	for _, meta := range blocklist {
+		if _, ok := selected[meta.BlockID]; ok {
+			continue
+		}
+		w := twbs.windowForBlock(meta)
+		remaining[w] = append(remaining[w], meta)
+	}
+
+	activeWindow := twbs.windowForTime(time.Now().Add(-activeWindowDuration))
+	for window, metas := range remaining {
+		for _, meta := range metas {
+			plan.Skipped = append(plan.Skipped, &CompactionSkipped{
+				BlockID:      meta.BlockID,
+				Level:        meta.CompactionLevel,
+				Window:       window,
+				TotalObjects: meta.TotalObjects,
+				Reason:       twbs.skippedReason(meta, metas, window >= activeWindow),
+			})
+		}
+	}
+
+	sort.Slice(plan.Skipped, func(i, j int) bool {
+		if plan.Skipped[i].Window != plan.Skipped[j].Window {
+			return plan.Skipped[i].Window > plan.Skipped[j].Window
+		}
+		return plan.Skipped[i].Level < plan.Skipped[j].Level
+	})
+
+	return plan
+}
+
+// CompactionPlan returns the current compaction plan for every tenant in the blocklist.
+func (rw *readerWriter) CompactionPlan() ([]*CompactionPlan, error) {
+	if rw.compactorCfg == nil {
+		return nil, fmt.Errorf("compaction is not enabled")
+	}
+
+	tenants := rw.blocklistTenants()
+	plans := make([]*CompactionPlan, 0, len(tenants))
+	for _, tenant := range tenants {
+		tenantID := tenant.(string)
+		if rw.compactorOverrides.CompactionDisabledForTenant(tenantID) {
+			plans = append(plans, &CompactionPlan{
+				TenantID: tenantID,
+				Disabled: true,
+				Jobs:     []*CompactionJob{},
+				Skipped:  []*CompactionSkipped{},
+			})
+			continue
+		}
+
+		cfg := *rw.compactorCfg
+		cfg.MaxCompactionRange = rw.compactionWindow(tenantID)
+		plans = append(plans, PlanCompaction(tenantID, rw.blocklist(tenantID), &cfg, rw.compactorSharder))
+	}
+
+	sort.Slice(plans, func(i, j int) bool { return plans[i].TenantID < plans[j].TenantID })
+
+	return plans, nil
+}
+
+func (twbs *timeWindowBlockSelector) skippedReason(meta *encoding.BlockMeta, windowBlocks []*encoding.BlockMeta, active bool) string {
+	if twbs.full(meta) {
+		return reasonBlockFull
+	}
+
+	// only the blocks that aren't full can be compacted
+	candidates := make([]*encoding.BlockMeta, 0, len(windowBlocks))
+	for _, b := range windowBlocks {
+		if !twbs.full(b) {
+			candidates = append(candidates, b)
+		}
+	}
+	windowBlocks = candidates
+
+	if len(windowBlocks) < inputBlocks {
+		return reasonNotEnoughBlocks
+	}
+
+	if active {
+		sameLevel := 0
+		for _, b := range windowBlocks {
+			if b.CompactionLevel == meta.CompactionLevel {
+				sameLevel++
+			}
+		}
+		if sameLevel < inputBlocks {
+			return reasonNoLevelMatch
+		}
+	}
+
+	return reasonTooManyObjects
+}
@@ -0,0 +1,115 @@
+package tempodb
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/grafana/tempo/tempodb/encoding"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPlanCompaction(t *testing.T) {
+	now := time.Now()
+	cfg := &CompactorConfig{
+		MaxCompactionRange:   time.Hour,
+		MaxCompactionObjects: 100,
+		MaxBlockBytes:        1000,
+	}
+
+	blocklist := []*encoding.BlockMeta{
+		{
+			BlockID: uuid.MustParse("00000000-0000-0000-0000-000000000000"),
+			EndTime: now,
+		},
+		{
+			BlockID: uuid.MustParse("00000000-0000-0000-0000-000000000001"),
+			EndTime: now,
+		},
+		{
+			BlockID:         uuid.MustParse("00000000-0000-0000-0000-000000000002"),
+			EndTime:         now,
+			CompactionLevel: 1,
+		},
+		{
+			BlockID:      uuid.MustParse("00000000-0000-0000-0000-000000000003"),
+			EndTime:      now.Add(-48 * time.Hour),
+			TotalObjects: 60,
+		},
+		{
+			BlockID:      uuid.MustParse("00000000-0000-0000-0000-000000000004"),
+			EndTime:      now.Add(-48 * time.Hour),
+			TotalObjects: 60,
+		},
+		{
+			BlockID: uuid.MustParse("00000000-0000-0000-0000-000000000005"),
+			EndTime: now.Add(-96 * time.Hour),
+		},
+		{
+			BlockID: uuid.MustParse("00000000-0000-0000-0000-000000000006"),
+			EndTime: now.Add(-96 * time.Hour),
+			Size:    1000,
+		},
+	}
+
+	plan := PlanCompaction("test", blocklist, cfg, nil)
+
+	assert.Equal(t, "test", plan.TenantID)
+	assert.Len(t, plan.Jobs, 1)
+	assert.True(t, plan.Jobs[0].Owned)
+	assert.ElementsMatch(t, []uuid.UUID{blocklist[0].BlockID, blocklist[1].BlockID}, plan.Jobs[0].Blocks)
+
+	reasons := map[uuid.UUID]string{}
+	for _, s := range plan.Skipped {
+		reasons[s.BlockID] = s.Reason
+	}
+	assert.Equal(t, map[uuid.UUID]string{
+		blocklist[2].BlockID: reasonNotEnoughBlocks,
+		blocklist[3].BlockID: reasonTooManyObjects,
+		blocklist[4].BlockID: reasonTooManyObjects,
+		blocklist[5].BlockID: reasonNotEnoughBlocks,
+		blocklist[6].BlockID: reasonBlockFull,
+	}, reasons)
+}
+
+func TestCompactionPlanTenantOverrides(t *testing.T) {
+	now := time.Now()
+	window := 24 * time.Hour
+	// the blocks are in separate hours of the same day
+	start := time.Unix(now.Unix()/int64(window/time.Second)*int64(window/time.Second), 0)
+
+	overrides := &mockOverrides{}
+	rw := &readerWriter{
+		compactorCfg: &CompactorConfig{
+			MaxCompactionRange:   time.Hour,
+			MaxCompactionObjects: 100,
+			MaxBlockBytes:        1000,
+		},
+		compactorOverrides: overrides,
+		blockLists: map[string][]*encoding.BlockMeta{
+			"test": {
+				{BlockID: uuid.New(), EndTime: start.Add(time.Minute)},
+				{BlockID: uuid.New(), EndTime: start.Add(2 * time.Hour)},
+			},
+		},
+	}
+
+	plans, err := rw.CompactionPlan()
+	assert.NoError(t, err)
+	assert.Len(t, plans, 1)
+	assert.Empty(t, plans[0].Jobs)
+
+	overrides.compactionWindow = window
+	plans, err = rw.CompactionPlan()
+	assert.NoError(t, err)
+	assert.Len(t, plans, 1)
+	assert.Len(t, plans[0].Jobs, 1)
+
+	overrides.compactionDisabled = true
+	plans, err = rw.CompactionPlan()
+	assert.NoError(t, err)
+	assert.Len(t, plans, 1)
+	assert.True(t, plans[0].Disabled)
+	assert.Empty(t, plans[0].Jobs)
+	assert.Empty(t, plans[0].Skipped)
+}
@@ -0,0 +1,126 @@
+package tempodb
+
+import (
+	"sync"
+
+	"github.com/google/uuid"
+	"github.com/grafana/tempo/tempodb/encoding"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const (
+	poolLevel0 = "level0"
+	poolHigher = "higher"
+)
+
+var (
+	metricCompactionJobsSkipped = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "tempodb",
+		Name:      "compaction_jobs_skipped_total",
+		Help:      "Total number of compaction jobs not started because their worker pool was busy or their blocks were already being compacted.",
+	}, []string{"pool"})
+)
+
+type compactionJob struct {
+	tenantID   string
+	blockMetas []*encoding.BlockMeta
+}
+
+// compactionPools runs level 0 compactions and higher level compactions on separate workers so a backlog of
+// small, latency sensitive level 0 jobs never waits behind a large merge of higher level blocks.
+type compactionPools struct {
+	level0        chan *compactionJob
+	higher        chan *compactionJob
+	level0Workers int
+	higherWorkers int
+
+	mtx      sync.Mutex
+	inFlight map[uuid.UUID]struct{}
+}
+
+func newCompactionPools(level0Workers int, higherWorkers int, compact func(*compactionJob)) *compactionPools {
+	if level0Workers <= 0 {
+		level0Workers = 1
+	}
+	if higherWorkers <= 0 {
+		higherWorkers = 1
+	}
+
+	p := &compactionPools{
+		level0:        make(chan *compactionJob, level0Workers),
+		higher:        make(chan *compactionJob, higherWorkers),
+		level0Workers: level0Workers,
+		higherWorkers: higherWorkers,
+		inFlight:      map[uuid.UUID]struct{}{},
+	}
+
+	work := func(jobs <-chan *compactionJob) {
+		for job := range jobs {
+			compact(job)
+			p.release(job)
+		}
+	}
+	for i := 0; i < level0Workers; i++ {
+		go work(p.level0)
+	}
+	for i := 0; i < higherWorkers; i++ {
+		go work(p.higher)
+	}
+
+	return p
+}
+
+// submit queues the job on the pool for its compaction level.  It does not block and returns false if the pool is
+// busy or any of the job's blocks are already being compacted.  Skipped jobs will be selected again next cycle.
+func (p *compactionPools) submit(job *compactionJob) bool {
+	pool, jobs := poolHigher, p.higher
+	if compactionLevelForBlocks(job.blockMetas) == 0 {
+		pool, jobs = poolLevel0, p.level0
+	}
+
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	for _, meta := range job.blockMetas {
+		if _, ok := p.inFlight[meta.BlockID]; ok {
+			metricCompactionJobsSkipped.WithLabelValues(pool).Inc()
+			return false
+		}
+	}
+
+	select {
+	case jobs <- job:
+	default:
+		metricCompactionJobsSkipped.WithLabelValues(pool).Inc()
+		return false
+	}
+
+	for _, meta := range job.blockMetas {
+		p.inFlight[meta.BlockID] = struct{}{}
+	}
+	return true
+}
+
+func (p *compactionPools) release(job *compactionJob) {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	for _, meta := range job.blockMetas {
+		delete(p.inFlight, meta.BlockID)
+	}
+}
+
+// workers returns the number of compactions the pools run at once.
+func (p *compactionPools) workers() int {
+	return p.level0Workers + p.higherWorkers
+}
+
+// busy returns true if the block is part of a queued or running job.
+func (p *compactionPools) busy(blockID uuid.UUID) bool {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	_, ok := p.inFlight[blockID]
+	return ok
+}
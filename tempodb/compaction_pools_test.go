@@ -0,0 +1,44 @@
+package tempodb
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/grafana/tempo/tempodb/encoding"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompactionPools(t *testing.T) {
+	block := make(chan struct{})
+	started := make(chan *compactionJob, 10)
+
+	pools := newCompactionPools(1, 1, func(job *compactionJob) {
+		started <- job
+		<-block
+	})
+
+	level0 := func() *compactionJob {
+		return &compactionJob{blockMetas: []*encoding.BlockMeta{{BlockID: uuid.New()}, {BlockID: uuid.New()}}}
+	}
+	higher := func() *compactionJob {
+		return &compactionJob{blockMetas: []*encoding.BlockMeta{{BlockID: uuid.New(), CompactionLevel: 2}, {BlockID: uuid.New(), CompactionLevel: 2}}}
+	}
+
+	// a long running higher level job doesn't stop level 0 jobs
+	busy := higher()
+	assert.True(t, pools.submit(busy))
+	assert.Equal(t, busy, <-started)
+
+	l0 := level0()
+	assert.True(t, pools.submit(l0))
+	assert.Equal(t, l0, <-started)
+
+	// blocks already being compacted are not submitted twice
+	assert.False(t, pools.submit(&compactionJob{blockMetas: busy.blockMetas}))
+
+	// one queued job per worker, then the pool is full
+	assert.True(t, pools.submit(higher()))
+	assert.False(t, pools.submit(higher()))
+
+	close(block)
+}
@@ -0,0 +1,136 @@
+package tempodb
+
+import (
+	"sync"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"github.com/grafana/tempo/tempodb/backend"
+)
+
+var (
+	metricCompactionThrottled = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: "tempodb",
+		Name:      "compaction_throttled_total",
+		Help:      "Total number of compactions that failed because the backend was throttling requests.",
+	})
+	metricCompactionConcurrencyLimit = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: "tempodb",
+		Name:      "compaction_concurrency_limit",
+		Help:      "The number of compactions currently allowed to run at once.",
+	})
+)
+
+// compactionThrottle backs compaction off when the backend throttles requests so the compactors don't take
+// throughput from queries.  A throttled compaction halves the number of compactions allowed to run at once and
+// pauses all new compactions for a backoff that doubles up to a maximum.  Each successful compaction allows one more
+// concurrent compaction and resets the backoff.
+type compactionThrottle struct {
+	detector   backend.ThrottleDetector
+	maxRunning int
+	minBackoff time.Duration
+	maxBackoff time.Duration
+	logger     log.Logger
+
+	mtx         sync.Mutex
+	cond        *sync.Cond
+	running     int
+	limit       int
+	backoff     time.Duration
+	pausedUntil time.Time
+}
+
+func newCompactionThrottle(detector backend.ThrottleDetector, maxRunning int, minBackoff time.Duration, maxBackoff time.Duration, logger log.Logger) *compactionThrottle {
+	if maxRunning <= 0 {
+		maxRunning = 1
+	}
+	if maxBackoff < minBackoff {
+		maxBackoff = minBackoff
+	}
+
+	t := &compactionThrottle{
+		detector:   detector,
+		maxRunning: maxRunning,
+		minBackoff: minBackoff,
+		maxBackoff: maxBackoff,
+		logger:     logger,
+		limit:      maxRunning,
+	}
+	t.cond = sync.NewCond(&t.mtx)
+	metricCompactionConcurrencyLimit.Set(float64(t.limit))
+
+	return t
+}
+
+// acquire blocks until a compaction may start.  Every acquire must be followed by a release.
+func (t *compactionThrottle) acquire() {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	for {
+		if wait := time.Until(t.pausedUntil); wait > 0 {
+			t.mtx.Unlock()
+			time.Sleep(wait)
+			t.mtx.Lock()
+			continue
+		}
+
+		if t.running < t.limit {
+			t.running++
+			return
+		}
+		t.cond.Wait()
+	}
+}
+
+// release records the result of a compaction started with acquire.  Errors other than throttling don't change the
+// throttle.
+func (t *compactionThrottle) release(err error) {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	t.running--
+	defer t.cond.Broadcast()
+
+	if err == nil {
+		if t.limit < t.maxRunning {
+			t.limit++
+		}
+		t.backoff = 0
+		metricCompactionConcurrencyLimit.Set(float64(t.limit))
+		return
+	}
+
+	if !t.detector.Throttled(err) {
+		return
+	}
+
+	t.limit /= 2
+	if t.limit < 1 {
+		t.limit = 1
+	}
+	t.backoff *= 2
+	if t.backoff < t.minBackoff {
+		t.backoff = t.minBackoff
+	}
+	if t.backoff > t.maxBackoff {
+		t.backoff = t.maxBackoff
+	}
+	t.pausedUntil = time.Now().Add(t.backoff)
+
+	metricCompactionThrottled.Inc()
+	metricCompactionConcurrencyLimit.Set(float64(t.limit))
+	level.Warn(t.logger).Log("msg", "backend is throttling compaction.  backing off", "backoff", t.backoff, "concurrency", t.limit, "err", err)
+}
+
+// paused returns true while compactions are backing off.
+func (t *compactionThrottle) paused() bool {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	return time.Now().Before(t.pausedUntil)
+}
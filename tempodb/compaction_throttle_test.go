@@ -0,0 +1,92 @@
+package tempodb
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/stretchr/testify/assert"
+)
+
+var errThrottled = errors.New("slow down")
+
+type mockThrottleDetector struct{}
+
+func (m *mockThrottleDetector) Throttled(err error) bool {
+	return errors.Is(err, errThrottled)
+}
+
+func TestCompactionThrottleConcurrency(t *testing.T) {
+	throttle := newCompactionThrottle(&mockThrottleDetector{}, 4, time.Millisecond, 4*time.Millisecond, log.NewNopLogger())
+
+	// throttling halves the allowed compactions.  other errors change nothing
+	throttle.acquire()
+	throttle.release(errThrottled)
+	assert.Equal(t, 2, throttle.limit)
+	throttle.acquire()
+	throttle.release(errors.New("other"))
+	assert.Equal(t, 2, throttle.limit)
+	throttle.acquire()
+	throttle.release(errThrottled)
+	assert.Equal(t, 1, throttle.limit)
+	throttle.acquire()
+	throttle.release(errThrottled)
+	assert.Equal(t, 1, throttle.limit)
+
+	// only one compaction runs until one succeeds
+	throttle.acquire()
+	acquired := make(chan struct{})
+	go func() {
+		throttle.acquire()
+		close(acquired)
+	}()
+
+	select {
+	case <-acquired:
+		t.Fatal("acquired past the limit")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	throttle.release(nil)
+	select {
+	case <-acquired:
+	case <-time.After(5 * time.Second):
+		t.Fatal("release did not wake the waiting compaction")
+	}
+	assert.Equal(t, 2, throttle.limit)
+
+	// the limit recovers to the number of workers
+	for i := 0; i < 3; i++ {
+		throttle.release(nil)
+		throttle.acquire()
+	}
+	throttle.release(nil)
+	assert.Equal(t, 4, throttle.limit)
+	assert.Equal(t, 0, throttle.running)
+}
+
+func TestCompactionThrottleBackoff(t *testing.T) {
+	throttle := newCompactionThrottle(&mockThrottleDetector{}, 1, 20*time.Millisecond, 50*time.Millisecond, log.NewNopLogger())
+
+	throttle.acquire()
+	throttle.release(errThrottled)
+	assert.Equal(t, 20*time.Millisecond, throttle.backoff)
+	assert.True(t, throttle.paused())
+
+	// acquire waits out the pause
+	start := time.Now()
+	throttle.acquire()
+	assert.GreaterOrEqual(t, int64(time.Since(start)), int64(10*time.Millisecond))
+	assert.False(t, throttle.paused())
+
+	// the backoff doubles up to the max and resets on success
+	throttle.release(errThrottled)
+	assert.Equal(t, 40*time.Millisecond, throttle.backoff)
+	throttle.acquire()
+	throttle.release(errThrottled)
+	assert.Equal(t, 50*time.Millisecond, throttle.backoff)
+	throttle.acquire()
+	throttle.release(nil)
+	assert.Equal(t, time.Duration(0), throttle.backoff)
+}
@@ -45,12 +45,24 @@ const (
 func (rw *readerWriter) compactionLoop() {
 	ticker := time.NewTicker(compactionCycle)
 	for range ticker.C {
+		if rw.compactionThrottle != nil && rw.compactionThrottle.paused() {
+			level.Info(rw.logger).Log("msg", "backing off from backend throttling.  skipping compaction cycle")
+			continue
+		}
+
 		rw.doCompaction()
+		rw.doReindex()
 	}
 }
 
 func (rw *readerWriter) doCompaction() {
-	tenants := rw.blocklistTenants()
+	var tenants []interface{}
+	for _, tenant := range rw.blocklistTenants() {
+		if rw.compactorOverrides.CompactionDisabledForTenant(tenant.(string)) {
+			continue
+		}
+		tenants = append(tenants, tenant)
+	}
 	if len(tenants) == 0 {
 		return
 	}
@@ -58,8 +70,12 @@ func (rw *readerWriter) doCompaction() {
 	// pick a random tenant and find some blocks to compact
 	rand.Seed(time.Now().Unix())
 	tenantID := tenants[rand.Intn(len(tenants))].(string)
+	if rw.tenantDeleting(tenantID) {
+		level.Info(rw.logger).Log("msg", "skipping compaction of tenant marked for deletion", "tenantID", tenantID)
+		return
+	}
 	blocklist := rw.blocklist(tenantID)
-	blockSelector := newTimeWindowBlockSelector(blocklist, rw.compactorCfg.MaxCompactionRange, rw.compactorCfg.MaxCompactionObjects)
+	blockSelector := newTimeWindowBlockSelector(blocklist, rw.compactionWindow(tenantID), rw.compactorCfg.MaxCompactionObjects, rw.compactorCfg.MaxBlockBytes)
 
 	start := time.Now()
 
@@ -74,14 +90,13 @@ func (rw *readerWriter) doCompaction() {
 			// continue on this tenant until we find something we own
 			continue
 		}
-		level.Info(rw.logger).Log("msg", "Compacting hash", "hashString", hashString)
-		err := rw.compact(toBeCompacted, tenantID)
-
-		if err == backend.ErrMetaDoesNotExist {
-			level.Warn(rw.logger).Log("msg", "unable to find meta during compaction.  trying again on this block list", "err", err)
-		} else if err != nil {
-			level.Error(rw.logger).Log("msg", "error during compaction cycle", "err", err)
-			metricCompactionErrors.Inc()
+		if rw.compactionPools != nil {
+			if rw.compactionPools.submit(&compactionJob{tenantID: tenantID, blockMetas: toBeCompacted}) {
+				level.Info(rw.logger).Log("msg", "Queued hash for compaction", "hashString", hashString)
+			}
+		} else {
+			level.Info(rw.logger).Log("msg", "Compacting hash", "hashString", hashString)
+			rw.runCompaction(toBeCompacted, tenantID)
 		}
 
 		// after a maintenance cycle bail out
@@ -92,6 +107,37 @@ func (rw *readerWriter) doCompaction() {
 	}
 }
 
+func (rw *readerWriter) runCompaction(blockMetas []*encoding.BlockMeta, tenantID string) {
+	// compaction may have been disabled while the job was queued
+	if rw.compactorOverrides.CompactionDisabledForTenant(tenantID) {
+		level.Info(rw.logger).Log("msg", "skipping compaction of tenant with compaction disabled", "tenantID", tenantID)
+		return
+	}
+
+	if rw.compactionThrottle != nil {
+		rw.compactionThrottle.acquire()
+	}
+	err := rw.compact(blockMetas, tenantID)
+	if rw.compactionThrottle != nil {
+		rw.compactionThrottle.release(err)
+	}
+
+	if err == backend.ErrMetaDoesNotExist {
+		level.Warn(rw.logger).Log("msg", "unable to find meta during compaction.  trying again on this block list", "err", err)
+	} else if err != nil {
+		level.Error(rw.logger).Log("msg", "error during compaction cycle", "err", err)
+		metricCompactionErrors.Inc()
+	}
+}
+
+// compactionWindow returns the time window across which blocks of the tenant are compacted.
+func (rw *readerWriter) compactionWindow(tenantID string) time.Duration {
+	if window := rw.compactorOverrides.CompactionWindowForTenant(tenantID); window != 0 {
+		return window
+	}
+	return rw.compactorCfg.MaxCompactionRange
+}
+
 // todo : this method is brittle and has weird failure conditions.  if it fails after it has written a new block then it will not clean up the old
 //   in these cases it's possible that the compact method actually will start making more blocks.
 func (rw *readerWriter) compact(blockMetas []*encoding.BlockMeta, tenantID string) error {
@@ -113,15 +159,25 @@ func (rw *readerWriter) compact(blockMetas []*encoding.BlockMeta, tenantID strin
 	var err error
 	bookmarks := make([]*bookmark, 0, len(blockMetas))
 
+	// stops any prefetching iterators if we bail out early
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
 	var totalRecords int
 	for _, blockMeta := range blockMetas {
 		level.Info(rw.logger).Log("msg", "compacting block", "block", fmt.Sprintf("%+v", blockMeta))
 		totalRecords += blockMeta.TotalObjects
 
-		iter, err := encoding.NewBackendIterator(tenantID, blockMeta.BlockID, rw.compactorCfg.ChunkSizeBytes, rw.r)
+		var iter encoding.Iterator
+		iter, err = encoding.NewBackendIterator(tenantID, blockMeta.BlockID, rw.compactorCfg.ChunkSizeBytes, rw.r)
 		if err != nil {
 			return err
 		}
+		// objects are decoded to be combined and encoded again with the encoding of the new block
+		iter = encoding.NewDecodingIterator(iter, blockMeta.Encoding)
+		if rw.compactorCfg.IteratorBufferSize > 0 {
+			iter = encoding.NewPrefetchIterator(ctx, iter, rw.compactorCfg.IteratorBufferSize)
+		}
 
 		bookmarks = append(bookmarks, newBookmark(iter))
 
@@ -132,9 +188,19 @@ func (rw *readerWriter) compact(blockMetas []*encoding.BlockMeta, tenantID strin
 	}
 
 	recordsPerBlock := (totalRecords / outputBlocks)
+	levelCfg := rw.compactorCfg.levelConfig(nextCompactionLevel)
+	estimatedObjects := recordsPerBlock
+	if levelCfg.MaxBlockObjects > 0 && levelCfg.MaxBlockObjects < estimatedObjects {
+		estimatedObjects = levelCfg.MaxBlockObjects
+	}
 	var currentBlock *wal.CompactorBlock
 	var tracker backend.AppendTracker
 
+	var ingestedSize uint64
+	for _, meta := range blockMetas {
+		ingestedSize += meta.IngestedSize
+	}
+
 	for !allDone(bookmarks) {
 		var lowestID []byte
 		var lowestObject []byte
@@ -165,11 +231,16 @@ func (rw *readerWriter) compact(blockMetas []*encoding.BlockMeta, tenantID strin
 
 		// make a new block if necessary
 		if currentBlock == nil {
-			currentBlock, err = rw.wal.NewCompactorBlock(uuid.New(), tenantID, blockMetas, recordsPerBlock)
+			currentBlock, err = rw.wal.NewCompactorBlock(uuid.New(), tenantID, blockMetas, estimatedObjects)
 			if err != nil {
 				return errors.Wrap(err, "error making new compacted block")
 			}
+			if searcher, ok := rw.compactorSharder.(encoding.ObjectSearcher); ok {
+				currentBlock.EnableSearch(searcher)
+			}
 			currentBlock.BlockMeta().CompactionLevel = nextCompactionLevel
+			currentBlock.BlockMeta().IngestedSize = ingestedSize
+			ingestedSize = 0
 		}
 
 		// writing to the current block will cause the id to escape the iterator so we need to make a copy of it
@@ -189,7 +260,7 @@ func (rw *readerWriter) compact(blockMetas []*encoding.BlockMeta, tenantID strin
 		}
 
 		// ship block to backend if done
-		if currentBlock.Length() >= recordsPerBlock {
+		if currentBlock.Length() >= recordsPerBlock || levelCfg.full(currentBlock) {
 			err = finishBlock(rw, tracker, currentBlock)
 			if err != nil {
 				return errors.Wrap(err, "error shipping block to backend")
@@ -258,6 +329,14 @@ func allDone(bookmarks []*bookmark) bool {
 	return true
 }
 
+func (l CompactionLevelConfig) full(block *wal.CompactorBlock) bool {
+	if l.MaxBlockObjects > 0 && block.Length() >= l.MaxBlockObjects {
+		return true
+	}
+
+	return l.MaxBlockBytes > 0 && block.DataLength() >= l.MaxBlockBytes
+}
+
 func compactionLevelForBlocks(blockMetas []*encoding.BlockMeta) uint8 {
 	level := uint8(0)
 
@@ -37,6 +37,24 @@ func (m *mockSharder) Combine(objA []byte, objB []byte) []byte {
 	return objB
 }
 
+type mockOverrides struct {
+	blockRetention     time.Duration
+	compactionWindow   time.Duration
+	compactionDisabled bool
+}
+
+func (m *mockOverrides) BlockRetentionForTenant(_ string) time.Duration {
+	return m.blockRetention
+}
+
+func (m *mockOverrides) CompactionWindowForTenant(_ string) time.Duration {
+	return m.compactionWindow
+}
+
+func (m *mockOverrides) CompactionDisabledForTenant(_ string) bool {
+	return m.compactionDisabled
+}
+
 func TestCompaction(t *testing.T) {
 	tempDir, err := ioutil.TempDir("/tmp", "")
 	defer os.RemoveAll(tempDir)
@@ -68,7 +86,7 @@ func TestCompaction(t *testing.T) {
 		MaxCompactionRange:      24 * time.Hour,
 		BlockRetention:          0,
 		CompactedBlockRetention: 0,
-	}, &mockSharder{})
+	}, &mockSharder{}, &mockOverrides{})
 
 	wal := w.WAL()
 	assert.NoError(t, err)
@@ -122,7 +140,7 @@ func TestCompaction(t *testing.T) {
 	rw.pollBlocklist()
 
 	blocklist := rw.blocklist(testTenantID)
-	blockSelector := newTimeWindowBlockSelector(blocklist, rw.compactorCfg.MaxCompactionRange, 10000)
+	blockSelector := newTimeWindowBlockSelector(blocklist, rw.compactorCfg.MaxCompactionRange, 10000, 0)
 
 	expectedCompactions := len(blocklist) / inputBlocks
 	compactions := 0
@@ -192,7 +210,7 @@ func TestSameIDCompaction(t *testing.T) {
 		MaxCompactionRange:      24 * time.Hour,
 		BlockRetention:          0,
 		CompactedBlockRetention: 0,
-	}, &mockSharder{})
+	}, &mockSharder{}, &mockOverrides{})
 
 	wal := w.WAL()
 	assert.NoError(t, err)
@@ -224,7 +242,7 @@ func TestSameIDCompaction(t *testing.T) {
 
 	var blocks []*encoding.BlockMeta
 	blocklist := rw.blocklist(testTenantID)
-	blockSelector := newTimeWindowBlockSelector(blocklist, rw.compactorCfg.MaxCompactionRange, 10000)
+	blockSelector := newTimeWindowBlockSelector(blocklist, rw.compactorCfg.MaxCompactionRange, 10000, 0)
 	blocks, _ = blockSelector.BlocksToCompact()
 	assert.Len(t, blocks, inputBlocks)
 
@@ -240,3 +258,174 @@ func TestSameIDCompaction(t *testing.T) {
 	}
 	assert.Equal(t, blockCount-blocksPerCompaction, records)
 }
+
+func TestMixedEncodingCompaction(t *testing.T) {
+	tempDir, err := ioutil.TempDir("/tmp", "")
+	defer os.RemoveAll(tempDir)
+	assert.NoError(t, err, "unexpected error creating temp dir")
+
+	walCfg := &wal.Config{
+		Filepath:        path.Join(tempDir, "wal"),
+		IndexDownsample: 3,
+		BloomFP:         .01,
+	}
+	r, w, c, err := New(&Config{
+		Backend: "local",
+		Pool: &pool.Config{
+			MaxWorkers: 10,
+			QueueDepth: 100,
+		},
+		Local: &local.Config{
+			Path: path.Join(tempDir, "traces"),
+		},
+		WAL:           walCfg,
+		BlocklistPoll: 0,
+	}, log.NewNopLogger())
+	assert.NoError(t, err)
+
+	c.EnableCompaction(&CompactorConfig{
+		ChunkSizeBytes:          10,
+		MaxCompactionRange:      24 * time.Hour,
+		BlockRetention:          0,
+		CompactedBlockRetention: 0,
+	}, &mockSharder{}, &mockOverrides{})
+
+	wal := w.WAL()
+
+	// the encoding is changed between blocks as it would be during a rollout
+	var allReqs []*tempopb.PushRequest
+	var allIds [][]byte
+	for _, enc := range []string{encoding.EncNone, encoding.EncGZIP} {
+		walCfg.Encoding = enc
+
+		head, err := wal.NewBlock(uuid.New(), testTenantID)
+		assert.NoError(t, err)
+
+		for i := 0; i < 10; i++ {
+			id := make([]byte, 16)
+			_, err = rand.Read(id)
+			assert.NoError(t, err, "unexpected creating random id")
+
+			req := test.MakeRequest(10, id)
+			allReqs = append(allReqs, req)
+			allIds = append(allIds, id)
+
+			bReq, err := proto.Marshal(req)
+			assert.NoError(t, err)
+			err = head.Write(id, bReq)
+			assert.NoError(t, err, "unexpected error writing req")
+		}
+
+		complete, err := head.Complete(wal, &mockSharder{})
+		assert.NoError(t, err)
+		assert.Equal(t, enc, complete.BlockMeta().Encoding)
+
+		err = w.WriteBlock(context.Background(), complete)
+		assert.NoError(t, err)
+	}
+
+	rw := r.(*readerWriter)
+	checkBlocklists(t, uuid.Nil, 2, 0, rw)
+
+	findAll := func() {
+		for i, id := range allIds {
+			b, _, err := rw.Find(context.Background(), testTenantID, id)
+			assert.NoError(t, err)
+
+			out := &tempopb.PushRequest{}
+			err = proto.Unmarshal(b, out)
+			assert.NoError(t, err)
+
+			assert.True(t, proto.Equal(allReqs[i], out))
+		}
+	}
+	findAll()
+
+	err = rw.compact(rw.blocklist(testTenantID), testTenantID)
+	assert.NoError(t, err)
+	checkBlocklists(t, uuid.Nil, 1, 2, rw)
+
+	// the compacted block is written with the current encoding
+	assert.Equal(t, encoding.EncGZIP, rw.blocklist(testTenantID)[0].Encoding)
+	findAll()
+}
+
+func TestCompactionLevelLimits(t *testing.T) {
+	tempDir, err := ioutil.TempDir("/tmp", "")
+	defer os.RemoveAll(tempDir)
+	assert.NoError(t, err, "unexpected error creating temp dir")
+
+	r, w, c, err := New(&Config{
+		Backend: "local",
+		Pool: &pool.Config{
+			MaxWorkers: 10,
+			QueueDepth: 100,
+		},
+		Local: &local.Config{
+			Path: path.Join(tempDir, "traces"),
+		},
+		WAL: &wal.Config{
+			Filepath:        path.Join(tempDir, "wal"),
+			IndexDownsample: 11,
+			BloomFP:         .01,
+		},
+		BlocklistPoll: 0,
+	}, log.NewNopLogger())
+	assert.NoError(t, err)
+
+	c.EnableCompaction(&CompactorConfig{
+		ChunkSizeBytes:     10,
+		MaxCompactionRange: 24 * time.Hour,
+		IteratorBufferSize: 10,
+		Levels: []CompactionLevelConfig{
+			{
+				Level:           1,
+				MaxBlockObjects: 30,
+			},
+		},
+	}, &mockSharder{}, &mockOverrides{})
+
+	wal := w.WAL()
+	blockCount := 2
+	recordCount := 50
+
+	for i := 0; i < blockCount; i++ {
+		head, err := wal.NewBlock(uuid.New(), testTenantID)
+		assert.NoError(t, err)
+
+		for j := 0; j < recordCount; j++ {
+			id := make([]byte, 16)
+			_, err = rand.Read(id)
+			assert.NoError(t, err)
+
+			bReq, err := proto.Marshal(test.MakeRequest(1, id))
+			assert.NoError(t, err)
+			err = head.Write(id, bReq)
+			assert.NoError(t, err)
+		}
+
+		complete, err := head.Complete(wal, &mockSharder{})
+		assert.NoError(t, err)
+		err = w.WriteBlock(context.Background(), complete)
+		assert.NoError(t, err)
+	}
+
+	rw := r.(*readerWriter)
+	rw.pollBlocklist()
+
+	err = rw.compact(rw.blocklist(testTenantID), testTenantID)
+	assert.NoError(t, err)
+
+	// 100 objects at 30 per block
+	rw.pollBlocklist()
+	blocklist := rw.blocklist(testTenantID)
+	assert.Len(t, blocklist, 4)
+
+	var records int
+	for _, meta := range blocklist {
+		assert.LessOrEqual(t, meta.TotalObjects, 30)
+		assert.Equal(t, uint8(1), meta.CompactionLevel)
+		records += meta.TotalObjects
+	}
+	assert.Equal(t, blockCount*recordCount, records)
+}
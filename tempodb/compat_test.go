@@ -0,0 +1,192 @@
+package tempodb
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/go-kit/kit/log"
+	"github.com/golang/protobuf/proto"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/tempo/pkg/tempopb"
+	"github.com/grafana/tempo/pkg/util/test"
+	"github.com/grafana/tempo/tempodb/backend/local"
+	"github.com/grafana/tempo/tempodb/encoding"
+	"github.com/grafana/tempo/tempodb/wal"
+)
+
+// Compatibility fixtures are blocks and WALs written by earlier builds.  Each readable block version has a directory
+// under testdata/compat holding a backend in the local layout, a WAL with one unflushed block and the expected
+// objects.  Fixtures are generated once, when a version is introduced, and then never regenerated so that every later
+// build is tested against data written by the build that introduced the version:
+//
+//	go test ./tempodb -run TestCompatibility -generate-compat-fixtures
+var generateCompatFixtures = flag.Bool("generate-compat-fixtures", false, "write compatibility fixtures for the current block version. existing fixtures are never overwritten.")
+
+const (
+	compatDir          = "testdata/compat"
+	compatTenantID     = "compat"
+	compatExpectedFile = "expected.json"
+	compatObjects      = 10
+)
+
+// compatExpected maps hex encoded ids to the expected objects.
+type compatExpected struct {
+	Block map[string][]byte `json:"block"`
+	WAL   map[string][]byte `json:"wal"`
+}
+
+func TestCompatibility(t *testing.T) {
+	if *generateCompatFixtures {
+		writeCompatFixtures(t, encoding.CurrentVersion)
+	}
+
+	for _, version := range encoding.ReadableVersions() {
+		t.Run(version, func(t *testing.T) {
+			dir := path.Join(compatDir, version)
+			_, err := os.Stat(dir)
+			require.NoError(t, err, "readable version %s has no compatibility fixtures. generate them with -generate-compat-fixtures", version)
+
+			testCompatFixtures(t, dir, version)
+		})
+	}
+}
+
+func testCompatFixtures(t *testing.T, fixtureDir string, version string) {
+	expectedBytes, err := ioutil.ReadFile(path.Join(fixtureDir, compatExpectedFile))
+	require.NoError(t, err)
+	expected := compatExpected{}
+	require.NoError(t, json.Unmarshal(expectedBytes, &expected))
+
+	// replaying and flushing modify the WAL so tests run against a copy
+	tempDir, err := ioutil.TempDir("/tmp", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(tempDir)
+	require.NoError(t, copyDir(fixtureDir, tempDir))
+
+	r, w, _, err := New(&Config{
+		Backend: "local",
+		Local: &local.Config{
+			Path: path.Join(tempDir, "traces"),
+		},
+		WAL: &wal.Config{
+			Filepath:        path.Join(tempDir, "wal"),
+			IndexDownsample: 17,
+			BloomFP:         .01,
+		},
+		BlocklistPoll: 0,
+	}, log.NewNopLogger())
+	require.NoError(t, err)
+
+	// blocks in the backend
+	rw := r.(*readerWriter)
+	rw.pollBlocklist()
+	metas := rw.blocklist(compatTenantID)
+	require.Len(t, metas, 1)
+	assert.Equal(t, version, metas[0].Version)
+	assert.Equal(t, len(expected.Block), metas[0].TotalObjects)
+
+	for id, obj := range expected.Block {
+		byteID, err := hex.DecodeString(id)
+		require.NoError(t, err)
+
+		found, _, err := r.Find(context.Background(), compatTenantID, byteID)
+		require.NoError(t, err)
+		assertEqualRequests(t, obj, found)
+	}
+
+	// blocks replayed from the wal
+	replayBlocks, err := w.WAL().AllBlocks()
+	require.NoError(t, err)
+	require.Len(t, replayBlocks, 1)
+	assert.Equal(t, compatTenantID, replayBlocks[0].TenantID())
+
+	iter, err := replayBlocks[0].Iterator()
+	require.NoError(t, err)
+	replayed := 0
+	for {
+		id, obj, err := iter.Next()
+		require.NoError(t, err)
+		if id == nil {
+			break
+		}
+
+		expectedObj, ok := expected.WAL[hex.EncodeToString(id)]
+		require.True(t, ok, "unexpected id %x replayed from the wal", id)
+		assertEqualRequests(t, expectedObj, obj)
+		replayed++
+	}
+	assert.Equal(t, len(expected.WAL), replayed)
+}
+
+func writeCompatFixtures(t *testing.T, version string) {
+	dir := path.Join(compatDir, version)
+	_, err := os.Stat(dir)
+	require.True(t, os.IsNotExist(err), "compatibility fixtures for %s already exist and must not be regenerated", version)
+
+	_, w, _, err := New(&Config{
+		Backend: "local",
+		Local: &local.Config{
+			Path: path.Join(dir, "traces"),
+		},
+		WAL: &wal.Config{
+			Filepath:        path.Join(dir, "wal"),
+			IndexDownsample: 3,
+			BloomFP:         .01,
+			Version:         version,
+		},
+		BlocklistPoll: 0,
+	}, log.NewNopLogger())
+	require.NoError(t, err)
+
+	expected := compatExpected{}
+	writeObjects := func(block *wal.AppendBlock) map[string][]byte {
+		objects := map[string][]byte{}
+		for i := 0; i < compatObjects; i++ {
+			id := make([]byte, 16)
+			rand.Read(id)
+
+			obj, err := proto.Marshal(test.MakeRequest(rand.Int()%10+1, id))
+			require.NoError(t, err)
+			require.NoError(t, block.Write(id, obj))
+			objects[hex.EncodeToString(id)] = obj
+		}
+		return objects
+	}
+
+	// one block stays in the wal
+	walBlock, err := w.WAL().NewBlock(uuid.New(), compatTenantID)
+	require.NoError(t, err)
+	expected.WAL = writeObjects(walBlock)
+
+	// and one is flushed to the backend
+	head, err := w.WAL().NewBlock(uuid.New(), compatTenantID)
+	require.NoError(t, err)
+	expected.Block = writeObjects(head)
+	complete, err := head.Complete(w.WAL(), &mockSharder{})
+	require.NoError(t, err)
+	require.NoError(t, w.WriteBlock(context.Background(), complete))
+	require.NoError(t, os.RemoveAll(path.Join(dir, "wal", "completed")))
+
+	expectedBytes, err := json.MarshalIndent(expected, "", "  ")
+	require.NoError(t, err)
+	require.NoError(t, ioutil.WriteFile(path.Join(dir, compatExpectedFile), expectedBytes, 0644))
+}
+
+func assertEqualRequests(t *testing.T, expected []byte, actual []byte) {
+	expectedReq := &tempopb.PushRequest{}
+	require.NoError(t, proto.Unmarshal(expected, expectedReq))
+	actualReq := &tempopb.PushRequest{}
+	require.NoError(t, proto.Unmarshal(actual, actualReq))
+
+	assert.True(t, proto.Equal(expectedReq, actualReq))
+}
@@ -3,27 +3,62 @@ package tempodb
 import (
 	"time"
 
+	"github.com/grafana/tempo/tempodb/backend/azure"
+	"github.com/grafana/tempo/tempodb/backend/cache"
 	"github.com/grafana/tempo/tempodb/backend/diskcache"
 	"github.com/grafana/tempo/tempodb/backend/gcs"
+	"github.com/grafana/tempo/tempodb/backend/inmemory"
 	"github.com/grafana/tempo/tempodb/backend/local"
-	"github.com/grafana/tempo/tempodb/backend/memcached"
 	"github.com/grafana/tempo/tempodb/backend/s3"
 	"github.com/grafana/tempo/tempodb/pool"
 	"github.com/grafana/tempo/tempodb/wal"
 )
 
 type Config struct {
-	Backend string        `yaml:"backend"`
-	Local   *local.Config `yaml:"local"`
-	GCS     *gcs.Config   `yaml:"gcs"`
-	S3      *s3.Config    `yaml:"s3"`
-	Pool    *pool.Config  `yaml:"pool,omitempty"`
-	WAL     *wal.Config   `yaml:"wal"`
+	Backend  string           `yaml:"backend"`
+	Local    *local.Config    `yaml:"local"`
+	GCS      *gcs.Config      `yaml:"gcs"`
+	S3       *s3.Config       `yaml:"s3"`
+	Azure    *azure.Config    `yaml:"azure"`
+	InMemory *inmemory.Config `yaml:"inmemory"` // fault injection of the inmemory backend, used for testing
+	Pool     *pool.Config     `yaml:"pool,omitempty"`
+	WAL      *wal.Config      `yaml:"wal"`
 
 	Diskcache *diskcache.Config `yaml:"disk_cache"`
-	Memcached *memcached.Config `yaml:"memcached"`
+	Cache     *cache.Config     `yaml:"cache"`
+	// Memcached is deprecated in favor of Cache.
+	Memcached *cache.MemcachedConfig `yaml:"memcached"`
 
 	BlocklistPoll time.Duration `yaml:"blocklist_poll"`
+	// BlocklistMaxNewBlocks is the number of level 0 blocks a tenant may add between two polls before its block creation
+	// rate is flagged.  0 disables
+	BlocklistMaxNewBlocks int `yaml:"blocklist_max_new_blocks"`
+
+	NegativeCacheSize int `yaml:"negative_cache_size"` // number of trace id/block pairs known not to match.  0 disables
+
+	StartupProbe bool `yaml:"startup_probe"` // write, read and delete a marker object on startup to fail fast on a misconfigured backend
+
+	// VerifyFlushedBlocks reads back the meta, bloom and index of every block the ingesters flush, and compares
+	// VerifyFlushedPages random pages of its data with the local copy, before the block is marked flushed.
+	VerifyFlushedBlocks bool `yaml:"verify_flushed_blocks"`
+	VerifyFlushedPages  int  `yaml:"verify_flushed_pages"`
+
+	Archive *ArchiveConfig `yaml:"archive,omitempty"`
+}
+
+// ArchiveConfig configures a second backend every block flushed by the ingesters is also written to.  Archived blocks
+// are never compacted, are only queried if QueryFallback is set and are deleted by the compactors after their own
+// retention.
+type ArchiveConfig struct {
+	Backend  string           `yaml:"backend"`
+	Local    *local.Config    `yaml:"local"`
+	GCS      *gcs.Config      `yaml:"gcs"`
+	S3       *s3.Config       `yaml:"s3"`
+	Azure    *azure.Config    `yaml:"azure"`
+	InMemory *inmemory.Config `yaml:"inmemory"`
+
+	Retention     time.Duration `yaml:"retention"`      // 0 keeps archived blocks forever
+	QueryFallback bool          `yaml:"query_fallback"` // search the archive for traces not found in the primary backend
 }
 
 type CompactorConfig struct {
@@ -31,6 +66,40 @@ type CompactorConfig struct {
 	FlushSizeBytes          uint32        `yaml:"flush_size_bytes"`
 	MaxCompactionRange      time.Duration `yaml:"compaction_window"`
 	MaxCompactionObjects    int           `yaml:"max_compaction_objects"`
+	MaxBlockBytes           uint64        `yaml:"max_block_bytes"` // blocks this large aren't compacted again and compactions that would produce larger blocks are skipped.  0 is unlimited
 	BlockRetention          time.Duration `yaml:"block_retention"`
 	CompactedBlockRetention time.Duration `yaml:"compacted_block_retention"`
+	OrphanGCInterval        time.Duration `yaml:"orphan_gc_interval"`       // how often incomplete blocks and orphaned objects are looked for.  0 disables
+	OrphanRetention         time.Duration `yaml:"orphan_retention"`         // how long a block can go without a meta, or an upload can go uncompleted, before it's considered abandoned
+	OrphanGCDryRun          bool          `yaml:"orphan_gc_dry_run"`        // log and count abandoned blocks and objects without deleting them
+	IteratorBufferSize      int           `yaml:"iterator_buffer_size"`     // objects to read ahead per input block.  0 disables prefetching
+	Level0Concurrency       int           `yaml:"level0_concurrency"`       // workers compacting level 0 blocks.  if both concurrencies are 0 jobs run one at a time in the compaction loop
+	HigherLevelConcurrency  int           `yaml:"higher_level_concurrency"` // workers compacting level 1 and above blocks
+	ReindexBlocksPerCycle   int           `yaml:"reindex_blocks_per_cycle"` // blocks to rebuild the bloom and index for each compaction cycle.  0 disables reindexing
+	ReindexBloomFP          float64       `yaml:"reindex_bloom_filter_false_positive"`
+	ReindexIndexDownsample  int           `yaml:"reindex_index_downsample"`
+	ThrottleBackoff         time.Duration `yaml:"throttle_backoff"`     // pause after the backend throttles a compaction.  0 disables throttling
+	ThrottleMaxBackoff      time.Duration `yaml:"throttle_max_backoff"` // the pause doubles with every throttled compaction up to this
+
+	TenantDeletionGracePeriod time.Duration `yaml:"tenant_deletion_grace_period"` // how long after a tenant is marked for deletion newly flushed blocks are still deleted before the mark is cleared
+
+	Levels []CompactionLevelConfig `yaml:"levels,omitempty"`
+}
+
+// CompactionLevelConfig limits the size of the blocks produced when compacting into Level.  When either limit is
+// reached the output block is shipped and a new one started.  Zero means unlimited.
+type CompactionLevelConfig struct {
+	Level           uint8  `yaml:"level"`
+	MaxBlockBytes   uint64 `yaml:"max_block_bytes"`
+	MaxBlockObjects int    `yaml:"max_block_objects"` // objects are traces
+}
+
+func (cfg *CompactorConfig) levelConfig(level uint8) CompactionLevelConfig {
+	for _, l := range cfg.Levels {
+		if l.Level == level {
+			return l
+		}
+	}
+
+	return CompactionLevelConfig{Level: level}
 }
@@ -23,12 +23,40 @@ type BlockMeta struct {
 	EndTime         time.Time `json:"endTime"`
 	TotalObjects    int       `json:"totalObjects"`
 	CompactionLevel uint8     `json:"compactionLevel"`
+	BloomFP         float64   `json:"bloomFP,omitempty"`         // false positive rate the bloom filter was built with
+	BloomShardCount int       `json:"bloomShards,omitempty"`     // number of bloom shards. 0 for blocks written with LegacyBloomShardCount
+	IndexDownsample int       `json:"indexDownsample,omitempty"` // objects per index record
+	Size            uint64    `json:"size,omitempty"`            // bytes of the data object
+	Encoding        string    `json:"encoding,omitempty"`        // compression of each object. empty for blocks written before encodings
+	// IngestedSize is the size of the blocks flushed by ingesters that were compacted into this block.  Compaction
+	// carries the sum of its inputs to the first block it writes so the total over a blocklist is preserved.
+	IngestedSize uint64 `json:"ingestedSize,omitempty"`
+
+	// Labels are operator defined key/value pairs used for cost attribution.
+	Labels map[string]string `json:"labels,omitempty"`
+
+	// Summary describes the traces in the block.  It is nil if any object in the block was written without one.
+	Summary *BlockSummary `json:"summary,omitempty"`
+
+	// Searchable is set if the block was written with a search section.
+	Searchable bool `json:"searchable,omitempty"`
+}
+
+// LegacyBloomShardCount is the number of bloom shards of blocks written before the count was recorded in the meta.
+const LegacyBloomShardCount = 10
+
+// BloomShards returns the number of bloom shards of the block.
+func (b *BlockMeta) BloomShards() int {
+	if b.BloomShardCount == 0 {
+		return LegacyBloomShardCount
+	}
+	return b.BloomShardCount
 }
 
-func NewBlockMeta(tenantID string, blockID uuid.UUID) *BlockMeta {
+func NewBlockMeta(tenantID string, blockID uuid.UUID, version string) *BlockMeta {
 	now := time.Now()
 	b := &BlockMeta{
-		Version:   "v0",
+		Version:   version,
 		BlockID:   blockID,
 		MinID:     []byte{},
 		MaxID:     []byte{},
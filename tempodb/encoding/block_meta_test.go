@@ -15,7 +15,7 @@ const (
 
 func TestBlockMeta(t *testing.T) {
 	id := uuid.New()
-	b := NewBlockMeta(testTenantID, id)
+	b := NewBlockMeta(testTenantID, id, CurrentVersion)
 
 	assert.Equal(t, id, b.BlockID)
 	assert.Equal(t, testTenantID, b.TenantID)
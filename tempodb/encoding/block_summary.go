@@ -0,0 +1,149 @@
+package encoding
+
+import (
+	"sort"
+	"time"
+)
+
+const (
+	maxSummaryServices   = 50
+	maxSummaryOperations = 100
+)
+
+// summarySpanBuckets are the upper bounds of the span count histogram.  The last bucket counts everything larger.
+var summarySpanBuckets = []int{1, 10, 100, 1000, 10000}
+
+// BlockSummary describes the traces of a block so blocks can be pruned by service and time before they are read.  Only
+// the most common services and operations are kept.  If any were dropped Truncated is set and a name missing from the
+// summary may still be in the block.  The time range spans from the earliest span start to the latest span end and is
+// 0 if unknown, e.g. for blocks summarized before it was recorded.
+type BlockSummary struct {
+	Traces     int            `json:"traces"`               // number of traces summarized
+	Services   map[string]int `json:"services,omitempty"`   // traces with at least one span of the service
+	Operations map[string]int `json:"operations,omitempty"` // traces with at least one span of the name
+	SpanCounts []int          `json:"spanCounts,omitempty"` // traces per bucket of spans per trace
+	Truncated  bool           `json:"truncated,omitempty"`
+
+	StartTimeUnixNano uint64 `json:"startTimeUnixNano,omitempty"`
+	EndTimeUnixNano   uint64 `json:"endTimeUnixNano,omitempty"`
+}
+
+func NewBlockSummary() *BlockSummary {
+	return &BlockSummary{
+		Services:   map[string]int{},
+		Operations: map[string]int{},
+		SpanCounts: make([]int, len(summarySpanBuckets)+1),
+	}
+}
+
+// AddTrace adds a trace with the passed distinct services and operations whose spans start and end within start and
+// end.
+func (s *BlockSummary) AddTrace(services []string, operations []string, spans int, start uint64, end uint64) {
+	s.widenTimeRange(start, end)
+	s.Traces++
+	for _, name := range services {
+		s.Services[name]++
+	}
+	for _, name := range operations {
+		s.Operations[name]++
+	}
+	s.SpanCounts[sort.SearchInts(summarySpanBuckets, spans)]++
+
+	// bound memory while the block is written.  the final summary is trimmed again by Trim
+	if len(s.Services) > 4*maxSummaryServices || len(s.Operations) > 4*maxSummaryOperations {
+		s.Trim()
+	}
+}
+
+// Merge adds the traces of other to the summary.
+func (s *BlockSummary) Merge(other *BlockSummary) {
+	if s.hasTimeRange() && other.hasTimeRange() {
+		s.widenTimeRange(other.StartTimeUnixNano, other.EndTimeUnixNano)
+	} else {
+		s.StartTimeUnixNano, s.EndTimeUnixNano = 0, 0
+	}
+	s.Traces += other.Traces
+	for name, n := range other.Services {
+		s.Services[name] += n
+	}
+	for name, n := range other.Operations {
+		s.Operations[name] += n
+	}
+	for i, n := range other.SpanCounts {
+		if i < len(s.SpanCounts) {
+			s.SpanCounts[i] += n
+		}
+	}
+	s.Truncated = s.Truncated || other.Truncated
+}
+
+// Trim drops all but the most common services and operations.
+func (s *BlockSummary) Trim() {
+	if trimCounts(s.Services, maxSummaryServices) {
+		s.Truncated = true
+	}
+	if trimCounts(s.Operations, maxSummaryOperations) {
+		s.Truncated = true
+	}
+}
+
+// MayContainService returns false only if the block is known to have no spans of the service.
+func (s *BlockSummary) MayContainService(service string) bool {
+	return s.Truncated || s.Services[service] > 0
+}
+
+// MayOverlap returns false only if no span of the block is known to fall between start and end.  Zero times are
+// unbounded.
+func (s *BlockSummary) MayOverlap(start time.Time, end time.Time) bool {
+	if s.Traces == 0 || s.EndTimeUnixNano == 0 {
+		return true
+	}
+	if !start.IsZero() && s.EndTimeUnixNano < uint64(start.UnixNano()) {
+		return false
+	}
+	if !end.IsZero() && s.StartTimeUnixNano > uint64(end.UnixNano()) {
+		return false
+	}
+	return true
+}
+
+// hasTimeRange returns false if traces were summarized without their time range.
+func (s *BlockSummary) hasTimeRange() bool {
+	return s.Traces == 0 || s.EndTimeUnixNano != 0
+}
+
+func (s *BlockSummary) widenTimeRange(start uint64, end uint64) {
+	if end == 0 {
+		return
+	}
+	if s.StartTimeUnixNano == 0 || start < s.StartTimeUnixNano {
+		s.StartTimeUnixNano = start
+	}
+	if end > s.EndTimeUnixNano {
+		s.EndTimeUnixNano = end
+	}
+}
+
+// trimCounts removes all but the max largest counts and returns true if any were removed.  Ties are broken by name
+// so summaries are stable.
+func trimCounts(counts map[string]int, max int) bool {
+	if len(counts) <= max {
+		return false
+	}
+
+	names := make([]string, 0, len(counts))
+	for name := range counts {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if counts[names[i]] != counts[names[j]] {
+			return counts[names[i]] > counts[names[j]]
+		}
+		return names[i] < names[j]
+	})
+
+	for _, name := range names[max:] {
+		delete(counts, name)
+	}
+	return true
+}
@@ -0,0 +1,73 @@
+package encoding
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBlockSummary(t *testing.T) {
+	s := NewBlockSummary()
+	s.AddTrace([]string{"frontend", "db"}, []string{"GET /", "SELECT"}, 1, 200, 300)
+	s.AddTrace([]string{"frontend"}, []string{"GET /"}, 50, 100, 150)
+	s.AddTrace([]string{"frontend"}, nil, 20000, 0, 0)
+
+	assert.Equal(t, 3, s.Traces)
+	assert.Equal(t, map[string]int{"frontend": 3, "db": 1}, s.Services)
+	assert.Equal(t, map[string]int{"GET /": 2, "SELECT": 1}, s.Operations)
+	assert.Equal(t, []int{1, 0, 1, 0, 0, 1}, s.SpanCounts)
+	assert.Equal(t, uint64(100), s.StartTimeUnixNano)
+	assert.Equal(t, uint64(300), s.EndTimeUnixNano)
+
+	assert.True(t, s.MayContainService("db"))
+	assert.False(t, s.MayContainService("cache"))
+
+	other := NewBlockSummary()
+	other.AddTrace([]string{"cache"}, []string{"GET"}, 5, 50, 400)
+	s.Merge(other)
+	assert.Equal(t, 4, s.Traces)
+	assert.True(t, s.MayContainService("cache"))
+	assert.Equal(t, []int{1, 1, 1, 0, 0, 1}, s.SpanCounts)
+	assert.Equal(t, uint64(50), s.StartTimeUnixNano)
+	assert.Equal(t, uint64(400), s.EndTimeUnixNano)
+}
+
+func TestBlockSummaryMayOverlap(t *testing.T) {
+	s := NewBlockSummary()
+	s.AddTrace(nil, nil, 1, uint64(time.Unix(100, 0).UnixNano()), uint64(time.Unix(200, 0).UnixNano()))
+
+	assert.True(t, s.MayOverlap(time.Time{}, time.Time{}))
+	assert.True(t, s.MayOverlap(time.Unix(150, 0), time.Unix(300, 0)))
+	assert.True(t, s.MayOverlap(time.Unix(0, 0), time.Unix(100, 0)))
+	assert.False(t, s.MayOverlap(time.Unix(201, 0), time.Time{}))
+	assert.False(t, s.MayOverlap(time.Time{}, time.Unix(99, 0)))
+
+	// a summary written without a time range makes the merged range unknown
+	old := &BlockSummary{Traces: 1}
+	s.Merge(old)
+	assert.Zero(t, s.EndTimeUnixNano)
+	assert.True(t, s.MayOverlap(time.Unix(201, 0), time.Time{}))
+
+	merged := NewBlockSummary()
+	merged.Merge(old)
+	merged.Merge(NewBlockSummary())
+	assert.True(t, merged.MayOverlap(time.Unix(201, 0), time.Time{}))
+}
+
+func TestBlockSummaryTrim(t *testing.T) {
+	s := NewBlockSummary()
+	s.AddTrace([]string{"common"}, nil, 1, 0, 0)
+	for i := 0; i < maxSummaryServices; i++ {
+		s.AddTrace([]string{"common", fmt.Sprintf("service-%03d", i)}, nil, 1, 0, 0)
+	}
+
+	s.Trim()
+	assert.Len(t, s.Services, maxSummaryServices)
+	assert.Equal(t, maxSummaryServices+1, s.Services["common"])
+	assert.True(t, s.Truncated)
+
+	// once truncated a missing service may still be in the block
+	assert.True(t, s.MayContainService("service-999"))
+}
@@ -4,39 +4,67 @@ import (
 	"bytes"
 
 	"github.com/grafana/tempo/pkg/util"
+	"github.com/grafana/tempo/tempodb/encoding"
 	"github.com/willf/bloom"
 )
 
-const shardNum = 10
+// maxShardCount bounds the number of bloom objects of a block.
+const maxShardCount = 1000
 
 type ShardedBloomFilter struct {
 	blooms []*bloom.BloomFilter
 }
 
-func NewWithEstimates(n uint, fp float64) *ShardedBloomFilter {
+// NewWithEstimates returns a filter for n trace ids split into shards of about shardSizeBytes each.  A shardSizeBytes of
+// 0 writes encoding.LegacyBloomShardCount shards.
+func NewWithEstimates(n uint, fp float64, shardSizeBytes int) *ShardedBloomFilter {
+	return NewWithShardCount(n, fp, ShardCountForEstimates(n, fp, shardSizeBytes))
+}
+
+// NewWithShardCount returns a filter for n trace ids split into shardCount shards.
+func NewWithShardCount(n uint, fp float64, shardCount int) *ShardedBloomFilter {
 	b := &ShardedBloomFilter{
-		blooms: make([]*bloom.BloomFilter, shardNum),
+		blooms: make([]*bloom.BloomFilter, shardCount),
 	}
 
-	itemsPerBloom := n / shardNum
+	itemsPerBloom := n / uint(shardCount)
 	if itemsPerBloom == 0 {
 		itemsPerBloom = 1
 	}
-	for i := 0; i < shardNum; i++ {
+	for i := 0; i < shardCount; i++ {
 		b.blooms[i] = bloom.NewWithEstimates(itemsPerBloom, fp)
 	}
 
 	return b
 }
 
+// ShardCountForEstimates returns the number of shards of about shardSizeBytes the filter for n trace ids needs, at
+// least encoding.LegacyBloomShardCount and at most maxShardCount.
+func ShardCountForEstimates(n uint, fp float64, shardSizeBytes int) int {
+	if shardSizeBytes <= 0 {
+		return encoding.LegacyBloomShardCount
+	}
+
+	bits, _ := bloom.EstimateParameters(n, fp)
+	shardSizeBits := uint(shardSizeBytes) * 8
+	shards := int((bits + shardSizeBits - 1) / shardSizeBits)
+	if shards < encoding.LegacyBloomShardCount {
+		return encoding.LegacyBloomShardCount
+	}
+	if shards > maxShardCount {
+		return maxShardCount
+	}
+	return shards
+}
+
 func (b *ShardedBloomFilter) Add(traceID []byte) {
-	shardKey := ShardKeyForTraceID(traceID)
+	shardKey := ShardKeyForTraceID(traceID, len(b.blooms))
 	b.blooms[shardKey].Add(traceID)
 }
 
 // WriteTo is a wrapper around bloom.WriteTo
 func (b *ShardedBloomFilter) WriteTo() ([][]byte, error) {
-	bloomBytes := make([][]byte, shardNum)
+	bloomBytes := make([][]byte, len(b.blooms))
 	for i, f := range b.blooms {
 		bloomBuffer := &bytes.Buffer{}
 		_, err := f.WriteTo(bloomBuffer)
@@ -48,16 +76,18 @@ func (b *ShardedBloomFilter) WriteTo() ([][]byte, error) {
 	return bloomBytes, nil
 }
 
-func ShardKeyForTraceID(traceID []byte) int {
-	return int(util.TokenForTraceID(traceID)) % shardNum
+// ShardCount returns the number of shards of the filter.
+func (b *ShardedBloomFilter) ShardCount() int {
+	return len(b.blooms)
+}
+
+// ShardKeyForTraceID returns the shard of a filter of shardCount shards that holds the trace id.
+func ShardKeyForTraceID(traceID []byte, shardCount int) int {
+	return int(util.TokenForTraceID(traceID) % uint32(shardCount))
 }
 
 // Test implements bloom.Test -> required only for testing
 func (b *ShardedBloomFilter) Test(traceID []byte) bool {
-	shardKey := ShardKeyForTraceID(traceID)
+	shardKey := ShardKeyForTraceID(traceID, len(b.blooms))
 	return b.blooms[shardKey].Test(traceID)
 }
-
-func GetShardNum() int {
-	return shardNum
-}
@@ -7,6 +7,8 @@ import (
 
 	"github.com/stretchr/testify/assert"
 	willf_bloom "github.com/willf/bloom"
+
+	"github.com/grafana/tempo/tempodb/encoding"
 )
 
 func TestShardedBloom(t *testing.T) {
@@ -23,7 +25,7 @@ func TestShardedBloom(t *testing.T) {
 
 	// create sharded bloom filter
 	const bloomFP = .01
-	b := NewWithEstimates(uint(numTraces), bloomFP)
+	b := NewWithEstimates(uint(numTraces), bloomFP, 0)
 
 	// add traceIDs to sharded bloom filter
 	for _, traceID := range traceIDs {
@@ -33,11 +35,11 @@ func TestShardedBloom(t *testing.T) {
 	// get byte representation
 	bloomBytes, err := b.WriteTo()
 	assert.NoError(t, err)
-	assert.Len(t, bloomBytes, shardNum)
+	assert.Len(t, bloomBytes, encoding.LegacyBloomShardCount)
 
 	// parse byte representation into willf_bloom.Bloomfilter
 	var filters []*willf_bloom.BloomFilter
-	for i := 0; i < shardNum; i++ {
+	for i := 0; i < encoding.LegacyBloomShardCount; i++ {
 		filters = append(filters, &willf_bloom.BloomFilter{})
 	}
 	for i, singleBloom := range bloomBytes {
@@ -52,9 +54,23 @@ func TestShardedBloom(t *testing.T) {
 		if !found {
 			missingCount++
 		}
-		assert.Equal(t, found, filters[ShardKeyForTraceID(traceID)].Test(traceID))
+		assert.Equal(t, found, filters[ShardKeyForTraceID(traceID, len(filters))].Test(traceID))
 	}
 
 	// check that missingCount is less than bloomFP
 	assert.LessOrEqual(t, float64(missingCount), bloomFP*numTraces)
 }
+
+func TestShardCountForEstimates(t *testing.T) {
+	assert.Equal(t, encoding.LegacyBloomShardCount, ShardCountForEstimates(1000000, .01, 0))
+	assert.Equal(t, encoding.LegacyBloomShardCount, ShardCountForEstimates(1000, .01, 1024*1024))
+
+	// a filter of 1M ids at 1% is about 1.2MB
+	assert.Equal(t, 12, ShardCountForEstimates(1000000, .01, 100*1024))
+	assert.Equal(t, maxShardCount, ShardCountForEstimates(1000000000, .01, 1024))
+
+	b := NewWithEstimates(1000000, .01, 100*1024)
+	assert.Equal(t, 12, b.ShardCount())
+	b.Add([]byte{0x01})
+	assert.True(t, b.Test([]byte{0x01}))
+}
@@ -0,0 +1,147 @@
+package encoding
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Object encodings.  Each object in the data of a block is compressed on its own so index records still point at
+// object boundaries and a trace can be read without decompressing its neighbours.
+const (
+	EncNone   = "none"
+	EncGZIP   = "gzip"
+	EncSnappy = "snappy"
+	EncLZ4    = "lz4"
+	EncZstd   = "zstd"
+)
+
+// Codec compresses and decompresses the objects of an encoding.
+type Codec interface {
+	Encode(object []byte) ([]byte, error)
+	Decode(object []byte) ([]byte, error)
+}
+
+// knownEncodings are the encodings a block may be written with.  Blocks written with an encoding that has no codec in
+// this build can't be read.
+var knownEncodings = []string{EncNone, EncGZIP, EncSnappy, EncLZ4, EncZstd}
+
+// codecs are the codecs of this build by encoding.  Codecs register themselves from the init of their file, so a codec
+// depending on cgo or a platform can be kept behind a build tag without changing any other code.  The default build
+// only registers pure Go codecs so it cross compiles with CGO_ENABLED=0 for every GOARCH.
+var codecs = map[string]Codec{}
+
+// RegisterCodec makes enc readable and writable by this build.  It's meant to be called from init.
+func RegisterCodec(enc string, codec Codec) {
+	if enc == "" || enc == EncNone {
+		panic("codec registered for no encoding")
+	}
+	codecs[enc] = codec
+}
+
+// ValidateEncoding returns an error if objects can't be written with the passed encoding.
+func ValidateEncoding(enc string) error {
+	if !knownEncoding(enc) {
+		return fmt.Errorf("unknown block encoding %s. must be one of %s", enc, strings.Join(supportedEncodings(), ", "))
+	}
+	if !supportedEncoding(enc) {
+		return fmt.Errorf("block encoding %s is not supported by this build. must be one of %s", enc, strings.Join(supportedEncodings(), ", "))
+	}
+	return nil
+}
+
+// ResolveEncoding returns enc if this build supports it and fallback otherwise, so one config can be shared by builds
+// with different codecs.  An empty fallback means enc must be supported.
+func ResolveEncoding(enc string, fallback string) (string, error) {
+	err := ValidateEncoding(enc)
+	if err == nil || fallback == "" || !knownEncoding(enc) {
+		return enc, err
+	}
+
+	if err := ValidateEncoding(fallback); err != nil {
+		return "", fmt.Errorf("fallback: %w", err)
+	}
+	return fallback, nil
+}
+
+func knownEncoding(enc string) bool {
+	for _, known := range knownEncodings {
+		if enc == known {
+			return true
+		}
+	}
+	return false
+}
+
+func supportedEncoding(enc string) bool {
+	_, ok := codecs[enc]
+	return enc == EncNone || ok
+}
+
+func supportedEncodings() []string {
+	var supported []string
+	for _, enc := range knownEncodings {
+		if supportedEncoding(enc) {
+			supported = append(supported, enc)
+		}
+	}
+	return supported
+}
+
+// EncodeObject compresses an object with the encoding.  Objects are returned as is for EncNone or an empty encoding.
+func EncodeObject(enc string, object []byte) ([]byte, error) {
+	if enc == "" || enc == EncNone {
+		return object, nil
+	}
+
+	codec, ok := codecs[enc]
+	if !ok {
+		return nil, fmt.Errorf("block encoding %s is not supported by this build", enc)
+	}
+	return codec.Encode(object)
+}
+
+// DecodeObject decompresses an object written with the encoding.  Blocks written before encodings were recorded in
+// the meta have an empty encoding and are not compressed.
+func DecodeObject(enc string, object []byte) ([]byte, error) {
+	if enc == "" || enc == EncNone {
+		return object, nil
+	}
+
+	codec, ok := codecs[enc]
+	if !ok {
+		return nil, fmt.Errorf("block encoding %s is not supported by this build", enc)
+	}
+	return codec.Decode(object)
+}
+
+type decodingIterator struct {
+	iter     Iterator
+	encoding string
+}
+
+// NewDecodingIterator decodes the objects returned by iter.  iter is returned as is for unencoded blocks.  Unlike iter
+// the objects returned for encoded blocks are owned by the caller.
+func NewDecodingIterator(iter Iterator, enc string) Iterator {
+	if enc == "" || enc == EncNone {
+		return iter
+	}
+
+	return &decodingIterator{
+		iter:     iter,
+		encoding: enc,
+	}
+}
+
+func (i *decodingIterator) Next() (ID, []byte, error) {
+	id, object, err := i.iter.Next()
+	if id == nil || err != nil {
+		return id, object, err
+	}
+
+	object, err = DecodeObject(i.encoding, object)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error decoding object: %w", err)
+	}
+
+	return id, object, nil
+}
@@ -0,0 +1,35 @@
+package encoding
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+)
+
+func init() {
+	RegisterCodec(EncGZIP, gzipCodec{})
+}
+
+// gzipCodec is the standard library gzip, available in every build.
+type gzipCodec struct{}
+
+func (gzipCodec) Encode(object []byte) ([]byte, error) {
+	buf := bytes.NewBuffer(make([]byte, 0, len(object)/2))
+	w := gzip.NewWriter(buf)
+	if _, err := w.Write(object); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (gzipCodec) Decode(object []byte) ([]byte, error) {
+	r, err := gzip.NewReader(bytes.NewReader(object))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return ioutil.ReadAll(r)
+}
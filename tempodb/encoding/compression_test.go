@@ -0,0 +1,110 @@
+package encoding
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateEncoding(t *testing.T) {
+	assert.NoError(t, ValidateEncoding(EncNone))
+	assert.NoError(t, ValidateEncoding(EncGZIP))
+	assert.Error(t, ValidateEncoding(EncZstd))
+	assert.Error(t, ValidateEncoding("brotli"))
+	assert.Error(t, ValidateEncoding(""))
+}
+
+func TestResolveEncoding(t *testing.T) {
+	enc, err := ResolveEncoding(EncGZIP, EncNone)
+	assert.NoError(t, err)
+	assert.Equal(t, EncGZIP, enc)
+
+	// zstd has no codec in this build
+	enc, err = ResolveEncoding(EncZstd, EncGZIP)
+	assert.NoError(t, err)
+	assert.Equal(t, EncGZIP, enc)
+
+	_, err = ResolveEncoding(EncZstd, "")
+	assert.Error(t, err)
+	_, err = ResolveEncoding(EncZstd, EncLZ4)
+	assert.Error(t, err)
+	_, err = ResolveEncoding("brotli", EncGZIP)
+	assert.Error(t, err, "unknown encodings aren't a build difference")
+}
+
+type reverseCodec struct{}
+
+func (reverseCodec) Encode(object []byte) ([]byte, error) {
+	reversed := make([]byte, len(object))
+	for i, b := range object {
+		reversed[len(object)-1-i] = b
+	}
+	return reversed, nil
+}
+
+func (c reverseCodec) Decode(object []byte) ([]byte, error) {
+	return c.Encode(object)
+}
+
+func TestRegisterCodec(t *testing.T) {
+	RegisterCodec(EncSnappy, reverseCodec{})
+	defer delete(codecs, EncSnappy)
+
+	assert.NoError(t, ValidateEncoding(EncSnappy))
+	assert.Equal(t, []string{EncNone, EncGZIP, EncSnappy}, supportedEncodings())
+
+	encoded, err := EncodeObject(EncSnappy, []byte("abc"))
+	require.NoError(t, err)
+	assert.Equal(t, []byte("cba"), encoded)
+	decoded, err := DecodeObject(EncSnappy, encoded)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("abc"), decoded)
+}
+
+func TestEncodeDecodeObject(t *testing.T) {
+	object := bytes.Repeat([]byte("abcdefgh"), 100)
+
+	for _, enc := range []string{"", EncNone, EncGZIP} {
+		encoded, err := EncodeObject(enc, object)
+		require.NoError(t, err)
+		if enc == EncGZIP {
+			assert.Less(t, len(encoded), len(object))
+		}
+
+		decoded, err := DecodeObject(enc, encoded)
+		require.NoError(t, err)
+		assert.Equal(t, object, decoded)
+	}
+
+	_, err := EncodeObject(EncLZ4, object)
+	assert.Error(t, err)
+	_, err = DecodeObject(EncLZ4, object)
+	assert.Error(t, err)
+}
+
+func TestDecodingIterator(t *testing.T) {
+	ids := []ID{{0x01}, {0x02}}
+	objects := [][]byte{[]byte("foo"), []byte("bar")}
+
+	buff := &bytes.Buffer{}
+	for i := range ids {
+		encoded, err := EncodeObject(EncGZIP, objects[i])
+		require.NoError(t, err)
+		_, err = marshalObjectToWriter(ids[i], encoded, buff)
+		require.NoError(t, err)
+	}
+
+	iter := NewDecodingIterator(NewIterator(bytes.NewReader(buff.Bytes())), EncGZIP)
+	for i := range ids {
+		id, object, err := iter.Next()
+		require.NoError(t, err)
+		assert.Equal(t, ids[i], id)
+		assert.Equal(t, objects[i], object)
+	}
+
+	id, _, err := iter.Next()
+	assert.Nil(t, id)
+	assert.NoError(t, err)
+}
@@ -9,13 +9,17 @@ import (
 type dedupingFinder struct {
 	ra            io.ReaderAt
 	sortedRecords []*Record
+	encoding      string
 	combiner      ObjectCombiner
 }
 
-func NewDedupingFinder(sortedRecords []*Record, ra io.ReaderAt, combiner ObjectCombiner) Finder {
+// NewDedupingFinder finds objects in data written with the passed encoding.  Objects are decoded before they are
+// combined.
+func NewDedupingFinder(sortedRecords []*Record, ra io.ReaderAt, enc string, combiner ObjectCombiner) Finder {
 	return &dedupingFinder{
 		ra:            ra,
 		sortedRecords: sortedRecords,
+		encoding:      enc,
 		combiner:      combiner,
 	}
 }
@@ -62,7 +66,7 @@ func (f *dedupingFinder) findOne(id ID, record *Record) ([]byte, error) {
 		return nil, err
 	}
 
-	iter := NewIterator(bytes.NewReader(buff))
+	iter := NewDecodingIterator(NewIterator(bytes.NewReader(buff)), f.encoding)
 	iter, err = NewDedupingIterator(iter, f.combiner)
 	if err != nil {
 		return nil, err
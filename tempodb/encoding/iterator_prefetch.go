@@ -0,0 +1,71 @@
+package encoding
+
+import (
+	"context"
+	"io"
+)
+
+type prefetchResult struct {
+	id     ID
+	object []byte
+	err    error
+}
+
+type prefetchIterator struct {
+	resultsCh <-chan prefetchResult
+	err       error
+}
+
+// NewPrefetchIterator reads ahead from iter on its own goroutine, buffering up to bufferSize objects.  Unlike most
+// iterators the ID and object slices returned are copies and may be retained by the caller.  The goroutine exits
+// after iter returns an error (including io.EOF) or ctx is cancelled.
+func NewPrefetchIterator(ctx context.Context, iter Iterator, bufferSize int) Iterator {
+	resultsCh := make(chan prefetchResult, bufferSize)
+
+	go func() {
+		defer close(resultsCh)
+
+		for {
+			id, object, err := iter.Next()
+
+			res := prefetchResult{err: err}
+			if err == nil && id != nil {
+				res.id = append([]byte(nil), id...)
+				res.object = append([]byte(nil), object...)
+			}
+
+			select {
+			case resultsCh <- res:
+			case <-ctx.Done():
+				return
+			}
+
+			// some iterators signal the end with a nil id instead of io.EOF.  these are returned as io.EOF
+			if err != nil || id == nil {
+				return
+			}
+		}
+	}()
+
+	return &prefetchIterator{
+		resultsCh: resultsCh,
+	}
+}
+
+func (i *prefetchIterator) Next() (ID, []byte, error) {
+	if i.err != nil {
+		return nil, nil, i.err
+	}
+
+	res, ok := <-i.resultsCh
+	if !ok {
+		i.err = context.Canceled
+		return nil, nil, i.err
+	}
+	if res.err == nil && res.id == nil {
+		res.err = io.EOF
+	}
+	i.err = res.err
+
+	return res.id, res.object, res.err
+}
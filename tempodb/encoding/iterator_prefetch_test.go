@@ -0,0 +1,51 @@
+package encoding
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPrefetchIterator(t *testing.T) {
+	buffer := &bytes.Buffer{}
+	ids := [][]byte{{0x01}, {0x02}, {0x03}}
+	for _, id := range ids {
+		_, err := marshalObjectToWriter(id, []byte{0x0A, id[0]}, buffer)
+		assert.NoError(t, err)
+	}
+
+	iter := NewPrefetchIterator(context.Background(), NewIterator(buffer), 2)
+	for _, expected := range ids {
+		id, obj, err := iter.Next()
+		assert.NoError(t, err)
+		assert.Equal(t, ID(expected), id)
+		assert.Equal(t, []byte{0x0A, expected[0]}, obj)
+	}
+
+	_, _, err := iter.Next()
+	assert.Equal(t, io.EOF, err)
+	_, _, err = iter.Next()
+	assert.Equal(t, io.EOF, err)
+}
+
+type infiniteIterator struct{}
+
+func (infiniteIterator) Next() (ID, []byte, error) {
+	return []byte{0x01}, []byte{0x0A}, nil
+}
+
+func TestPrefetchIteratorCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	iter := NewPrefetchIterator(ctx, infiniteIterator{}, 0)
+	_, _, err := iter.Next()
+	assert.NoError(t, err)
+
+	cancel()
+	for err == nil {
+		_, _, err = iter.Next()
+	}
+	assert.Equal(t, context.Canceled, err)
+}
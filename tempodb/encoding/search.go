@@ -0,0 +1,289 @@
+package encoding
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"sort"
+	"time"
+)
+
+const (
+	// MaxSearchTagValueLength is the longest tag value recorded in search data.  Traces can't be found by longer values.
+	MaxSearchTagValueLength = 256
+	// MaxSearchTagsPerTrace bounds the distinct tags recorded for one trace.
+	MaxSearchTagsPerTrace = 200
+)
+
+// SearchEntry is what the search data of a block records about a trace.
+type SearchEntry struct {
+	Tags              []string // distinct tags built with SearchTag
+	StartTimeUnixNano uint64
+	EndTimeUnixNano   uint64
+	RootServiceName   string
+	RootSpanName      string
+}
+
+// ObjectSearcher returns the search entry of an object.  Objects are opaque to tempodb so search data is only built
+// for blocks completed or compacted with an ObjectCombiner that also implements ObjectSearcher.
+type ObjectSearcher interface {
+	SearchEntry(obj []byte) (*SearchEntry, error)
+}
+
+// ObjectMatcher evaluates a search query while decoding an object, so objects of blocks without search data can be
+// searched without materializing them.  Decoding stops as soon as the object can't match, and attributes are only read
+// until the query's tags are found.
+type ObjectMatcher interface {
+	// MatchObject returns the search trace of the object if it matches the query, and nil otherwise.
+	MatchObject(id ID, obj []byte, q *SearchQuery) (*SearchTrace, error)
+}
+
+// SearchTag returns the tag recorded for an attribute.
+func SearchTag(key string, value string) string {
+	return key + "=" + value
+}
+
+// SearchTrace is a trace in the search data of a block.
+type SearchTrace struct {
+	TraceID           ID     `json:"id"`
+	StartTimeUnixNano uint64 `json:"start"`
+	EndTimeUnixNano   uint64 `json:"end"`
+	RootServiceName   string `json:"rootService,omitempty"`
+	RootSpanName      string `json:"rootSpan,omitempty"`
+}
+
+// Duration returns the time between the first span start and the last span end of the trace.
+func (t *SearchTrace) Duration() time.Duration {
+	if t.EndTimeUnixNano < t.StartTimeUnixNano {
+		return 0
+	}
+	return time.Duration(t.EndTimeUnixNano - t.StartTimeUnixNano)
+}
+
+// SearchData is the search section of a block.  It maps the tags of the block's traces to the traces that have them.
+type SearchData struct {
+	Traces   []SearchTrace       `json:"traces"`
+	Postings map[string][]uint32 `json:"postings"` // tag -> indexes into Traces in ascending order
+}
+
+func NewSearchData() *SearchData {
+	return &SearchData{
+		Postings: map[string][]uint32{},
+	}
+}
+
+// Add records a trace.  An entry for the same id as the last added trace is merged into it.
+func (d *SearchData) Add(id ID, entry *SearchEntry) {
+	idx := len(d.Traces) - 1
+	if idx < 0 || !bytes.Equal(d.Traces[idx].TraceID, id) {
+		d.Traces = append(d.Traces, SearchTrace{
+			TraceID:           append(ID(nil), id...),
+			StartTimeUnixNano: entry.StartTimeUnixNano,
+			EndTimeUnixNano:   entry.EndTimeUnixNano,
+			RootServiceName:   entry.RootServiceName,
+			RootSpanName:      entry.RootSpanName,
+		})
+		idx++
+	} else {
+		t := &d.Traces[idx]
+		if entry.StartTimeUnixNano != 0 && (t.StartTimeUnixNano == 0 || entry.StartTimeUnixNano < t.StartTimeUnixNano) {
+			t.StartTimeUnixNano = entry.StartTimeUnixNano
+		}
+		if entry.EndTimeUnixNano > t.EndTimeUnixNano {
+			t.EndTimeUnixNano = entry.EndTimeUnixNano
+		}
+		if t.RootSpanName == "" {
+			t.RootServiceName = entry.RootServiceName
+			t.RootSpanName = entry.RootSpanName
+		}
+	}
+
+	for _, tag := range entry.Tags {
+		postings := d.Postings[tag]
+		if len(postings) > 0 && postings[len(postings)-1] == uint32(idx) {
+			continue
+		}
+		d.Postings[tag] = append(postings, uint32(idx))
+	}
+}
+
+// SearchQuery selects traces with all of the tags.  Zero values are unbounded.  Traces overlapping Start and End match.
+type SearchQuery struct {
+	Tags        map[string]string
+	MinDuration time.Duration
+	MaxDuration time.Duration
+	Start       time.Time
+	End         time.Time
+	Limit       int
+	// MostRecent returns the newest matching traces instead of the first found up to Limit.  Every match of a block
+	// is read to find them.
+	MostRecent bool
+
+	// Matcher scans the objects of blocks without search data.  Those blocks are skipped if nil.
+	Matcher ObjectMatcher
+}
+
+// Matches returns true if the trace is in the time and duration bounds of the query.  Tags aren't checked.
+func (q *SearchQuery) Matches(t *SearchTrace) bool {
+	duration := t.Duration()
+	if q.MinDuration > 0 && duration < q.MinDuration {
+		return false
+	}
+	if q.MaxDuration > 0 && duration > q.MaxDuration {
+		return false
+	}
+	if !q.Start.IsZero() && t.EndTimeUnixNano < uint64(q.Start.UnixNano()) {
+		return false
+	}
+	if !q.End.IsZero() && t.StartTimeUnixNano > uint64(q.End.UnixNano()) {
+		return false
+	}
+	return true
+}
+
+// Search returns up to q.Limit traces matching the query in id order, or the newest of them newest first if
+// q.MostRecent is set.
+func (d *SearchData) Search(q *SearchQuery) []SearchTrace {
+	if q.MostRecent {
+		all := *q
+		all.Limit = 0
+		all.MostRecent = false
+		return MostRecent(d.Search(&all), q.Limit)
+	}
+
+	var results []SearchTrace
+	add := func(idx uint32) bool {
+		t := &d.Traces[idx]
+		if !q.Matches(t) {
+			return true
+		}
+		results = append(results, *t)
+		return q.Limit <= 0 || len(results) < q.Limit
+	}
+
+	if len(q.Tags) == 0 {
+		for i := range d.Traces {
+			if !add(uint32(i)) {
+				break
+			}
+		}
+		return results
+	}
+
+	lists := make([][]uint32, 0, len(q.Tags))
+	for k, v := range q.Tags {
+		postings := d.Postings[SearchTag(k, v)]
+		if len(postings) == 0 {
+			return nil
+		}
+		lists = append(lists, postings)
+	}
+	sort.Slice(lists, func(i, j int) bool {
+		return len(lists[i]) < len(lists[j])
+	})
+
+	for _, idx := range lists[0] {
+		if !containsAll(lists[1:], idx) {
+			continue
+		}
+		if !add(idx) {
+			break
+		}
+	}
+	return results
+}
+
+// SearchObjects returns up to q.Limit traces of the iterator's objects matching the query, evaluated by q.Matcher.
+// Objects of the same trace are matched on their own, so a trace whose tags are split across objects may be missed.
+// If q.MostRecent is set every object is read and the newest traces are returned.
+func SearchObjects(iter Iterator, q *SearchQuery) ([]SearchTrace, error) {
+	var results []SearchTrace
+	seen := map[string]struct{}{}
+	for q.MostRecent || q.Limit <= 0 || len(results) < q.Limit {
+		id, obj, err := iter.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := seen[string(id)]; ok {
+			continue
+		}
+
+		t, err := q.Matcher.MatchObject(id, obj, q)
+		if err != nil {
+			return nil, err
+		}
+		if t == nil {
+			continue
+		}
+		seen[string(id)] = struct{}{}
+		results = append(results, *t)
+	}
+	if q.MostRecent {
+		results = MostRecent(results, q.Limit)
+	}
+	return results, nil
+}
+
+// MostRecent sorts traces newest first by start time and returns up to limit of them.  A limit of 0 returns all.
+func MostRecent(traces []SearchTrace, limit int) []SearchTrace {
+	sort.SliceStable(traces, func(i, j int) bool {
+		return traces[i].StartTimeUnixNano > traces[j].StartTimeUnixNano
+	})
+	if limit > 0 && len(traces) > limit {
+		traces = traces[:limit]
+	}
+	return traces
+}
+
+func containsAll(lists [][]uint32, idx uint32) bool {
+	for _, l := range lists {
+		i := sort.Search(len(l), func(i int) bool {
+			return l[i] >= idx
+		})
+		if i == len(l) || l[i] != idx {
+			return false
+		}
+	}
+	return true
+}
+
+// MarshalSearchData encodes search data as gzipped json.
+func MarshalSearchData(d *SearchData) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	w := gzip.NewWriter(buf)
+	err := json.NewEncoder(w).Encode(d)
+	if err != nil {
+		return nil, err
+	}
+	err = w.Close()
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// UnmarshalSearchData decodes search data encoded by MarshalSearchData.
+func UnmarshalSearchData(b []byte) (*SearchData, error) {
+	r, err := gzip.NewReader(bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	buf, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	d := NewSearchData()
+	err = json.Unmarshal(buf, d)
+	if err != nil {
+		return nil, err
+	}
+	return d, nil
+}
@@ -0,0 +1,114 @@
+package encoding
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSearchData(t *testing.T) {
+	d := NewSearchData()
+	d.Add(ID{0x01}, &SearchEntry{
+		Tags:              []string{SearchTag("service.name", "foo"), SearchTag("http.status_code", "200")},
+		StartTimeUnixNano: 10,
+		EndTimeUnixNano:   uint64(time.Second),
+	})
+	// a second entry for the same trace is merged
+	d.Add(ID{0x01}, &SearchEntry{
+		Tags:              []string{SearchTag("service.name", "bar")},
+		StartTimeUnixNano: 5,
+		EndTimeUnixNano:   uint64(3 * time.Second),
+		RootServiceName:   "bar",
+		RootSpanName:      "root",
+	})
+	d.Add(ID{0x02}, &SearchEntry{
+		Tags:              []string{SearchTag("service.name", "foo"), SearchTag("http.status_code", "500")},
+		StartTimeUnixNano: uint64(time.Second),
+		EndTimeUnixNano:   uint64(2 * time.Second),
+	})
+
+	require.Len(t, d.Traces, 2)
+	assert.Equal(t, uint64(5), d.Traces[0].StartTimeUnixNano)
+	assert.Equal(t, "root", d.Traces[0].RootSpanName)
+
+	tests := []struct {
+		name     string
+		q        *SearchQuery
+		expected []ID
+	}{
+		{
+			name:     "tag",
+			q:        &SearchQuery{Tags: map[string]string{"service.name": "foo"}},
+			expected: []ID{{0x01}, {0x02}},
+		},
+		{
+			name:     "all tags",
+			q:        &SearchQuery{Tags: map[string]string{"service.name": "bar", "http.status_code": "200"}},
+			expected: []ID{{0x01}},
+		},
+		{
+			name: "unknown tag",
+			q:    &SearchQuery{Tags: map[string]string{"service.name": "foo", "http.status_code": "404"}},
+		},
+		{
+			name:     "min duration",
+			q:        &SearchQuery{Tags: map[string]string{"service.name": "foo"}, MinDuration: 2 * time.Second},
+			expected: []ID{{0x01}},
+		},
+		{
+			name:     "max duration",
+			q:        &SearchQuery{MaxDuration: 2 * time.Second},
+			expected: []ID{{0x02}},
+		},
+		{
+			name:     "time range",
+			q:        &SearchQuery{Start: time.Unix(0, int64(4*time.Second)), End: time.Unix(0, int64(5*time.Second))},
+			expected: nil,
+		},
+		{
+			name:     "limit",
+			q:        &SearchQuery{Tags: map[string]string{"service.name": "foo"}, Limit: 1},
+			expected: []ID{{0x01}},
+		},
+		{
+			name:     "most recent",
+			q:        &SearchQuery{Tags: map[string]string{"service.name": "foo"}, MostRecent: true},
+			expected: []ID{{0x02}, {0x01}},
+		},
+		{
+			name:     "most recent limit",
+			q:        &SearchQuery{Tags: map[string]string{"service.name": "foo"}, Limit: 1, MostRecent: true},
+			expected: []ID{{0x02}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var ids []ID
+			for _, found := range d.Search(tt.q) {
+				ids = append(ids, found.TraceID)
+			}
+			assert.Equal(t, tt.expected, ids)
+		})
+	}
+}
+
+func TestSearchDataMarshal(t *testing.T) {
+	d := NewSearchData()
+	d.Add(ID{0x01}, &SearchEntry{
+		Tags:              []string{SearchTag("service.name", "foo")},
+		StartTimeUnixNano: 1,
+		EndTimeUnixNano:   2,
+		RootServiceName:   "foo",
+		RootSpanName:      "root",
+	})
+
+	b, err := MarshalSearchData(d)
+	require.NoError(t, err)
+
+	actual, err := UnmarshalSearchData(b)
+	require.NoError(t, err)
+	assert.Equal(t, d, actual)
+}
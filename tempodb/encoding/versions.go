@@ -0,0 +1,55 @@
+package encoding
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// CurrentVersion is the block version written by default.
+const CurrentVersion = "v0"
+
+type versionSupport struct {
+	readable bool
+	writable bool
+}
+
+// versionMatrix lists every block version this build understands.  When a new format is introduced it is first
+// added as readable only.  Once every component in a cluster runs a build that can read it, it is made writable and
+// selected with the wal version setting.  Older versions stay readable until all blocks in that format have aged out
+// or been compacted into a newer one.  This allows format changes to roll out without stopping the world.  Every
+// readable version needs compatibility fixtures written by the build that introduced it.  See tempodb/compat_test.go.
+var versionMatrix = map[string]versionSupport{
+	"v0": {readable: true, writable: true},
+}
+
+// ValidateWriteVersion returns an error if blocks of the passed version can not be written by this build.
+func ValidateWriteVersion(version string) error {
+	support, ok := versionMatrix[version]
+	if !ok || !support.writable {
+		return fmt.Errorf("block version %s can not be written. must be one of %s", version, strings.Join(versions(func(s versionSupport) bool { return s.writable }), ", "))
+	}
+
+	return nil
+}
+
+// ReadableVersion returns true if blocks of the passed version can be read by this build.
+func ReadableVersion(version string) bool {
+	return versionMatrix[version].readable
+}
+
+// ReadableVersions returns every block version this build can read.
+func ReadableVersions() []string {
+	return versions(func(s versionSupport) bool { return s.readable })
+}
+
+func versions(include func(versionSupport) bool) []string {
+	vs := make([]string, 0, len(versionMatrix))
+	for v, support := range versionMatrix {
+		if include(support) {
+			vs = append(vs, v)
+		}
+	}
+	sort.Strings(vs)
+	return vs
+}
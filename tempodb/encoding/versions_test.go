@@ -0,0 +1,18 @@
+package encoding
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVersions(t *testing.T) {
+	assert.NoError(t, ValidateWriteVersion(CurrentVersion))
+	assert.True(t, ReadableVersion(CurrentVersion))
+
+	assert.Error(t, ValidateWriteVersion("v99"))
+	assert.False(t, ReadableVersion("v99"))
+	assert.False(t, ReadableVersion(""))
+
+	assert.Contains(t, ReadableVersions(), CurrentVersion)
+}
@@ -0,0 +1,103 @@
+package tempodb
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math/rand"
+	"os"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"github.com/grafana/tempo/tempodb/encoding"
+	"github.com/grafana/tempo/tempodb/wal"
+)
+
+var metricFlushVerificationFailures = promauto.NewCounter(prometheus.CounterOpts{
+	Namespace: "tempodb",
+	Name:      "flush_verification_failures_total",
+	Help:      "Total number of flushed blocks that didn't match the local copy when read back.",
+})
+
+// verifyFlushedBlock reads back a block written from c and returns an error if it doesn't match c.  The meta, bloom
+// and index are checked like VerifyBlock does, the meta must describe the same objects as the local meta, and
+// VerifyFlushedPages random pages of the data are compared byte for byte with the local object file.  Reads bypass the
+// caches, which would return what was written rather than what the backend stored.
+func (rw *readerWriter) verifyFlushedBlock(ctx context.Context, c wal.WriteableBlock, records []*encoding.Record) error {
+	meta := c.BlockMeta()
+
+	v := VerifyBlock(ctx, rw.backendReader, rw.c, meta.TenantID, meta.BlockID, false)
+	problems := v.Problems
+	if v.State != BlockStateOK && len(problems) == 0 {
+		problems = append(problems, "block is "+v.State)
+	}
+
+	if len(problems) == 0 {
+		problems = append(problems, rw.verifyFlushedMeta(ctx, meta)...)
+	}
+	if len(problems) == 0 {
+		problems = append(problems, rw.verifyFlushedPages(ctx, meta, c.ObjectFilePath(), records)...)
+	}
+
+	if len(problems) > 0 {
+		metricFlushVerificationFailures.Inc()
+		return fmt.Errorf("flushed block %s of tenant %s doesn't match the local copy: %s", meta.BlockID, meta.TenantID, strings.Join(problems, ", "))
+	}
+	return nil
+}
+
+// verifyFlushedMeta compares the meta read back with the meta written.
+func (rw *readerWriter) verifyFlushedMeta(ctx context.Context, meta *encoding.BlockMeta) []string {
+	stored, err := rw.backendReader.BlockMeta(ctx, meta.BlockID, meta.TenantID)
+	if err != nil {
+		return []string{fmt.Sprintf("meta: %v", err)}
+	}
+
+	var problems []string
+	if stored.TotalObjects != meta.TotalObjects {
+		problems = append(problems, fmt.Sprintf("meta: %d objects, expected %d", stored.TotalObjects, meta.TotalObjects))
+	}
+	if stored.Size != meta.Size {
+		problems = append(problems, fmt.Sprintf("meta: size %d, expected %d", stored.Size, meta.Size))
+	}
+	if !bytes.Equal(stored.MinID, meta.MinID) || !bytes.Equal(stored.MaxID, meta.MaxID) {
+		problems = append(problems, "meta: ids don't match")
+	}
+	return problems
+}
+
+// verifyFlushedPages compares random pages of the data read back with the local object file.
+func (rw *readerWriter) verifyFlushedPages(ctx context.Context, meta *encoding.BlockMeta, objectFilePath string, records []*encoding.Record) []string {
+	pages := rw.cfg.VerifyFlushedPages
+	if pages <= 0 || len(records) == 0 {
+		return nil
+	}
+	if pages > len(records) {
+		pages = len(records)
+	}
+
+	f, err := os.Open(objectFilePath)
+	if err != nil {
+		return []string{fmt.Sprintf("data: %v", err)}
+	}
+	defer f.Close()
+
+	for _, i := range rand.Perm(len(records))[:pages] {
+		record := records[i]
+		local := make([]byte, record.Length)
+		if _, err := f.ReadAt(local, int64(record.Start)); err != nil {
+			return []string{fmt.Sprintf("data: page %d: reading local copy: %v", i, err)}
+		}
+
+		stored := make([]byte, record.Length)
+		if err := rw.backendReader.Object(ctx, meta.BlockID, meta.TenantID, record.Start, stored); err != nil {
+			return []string{fmt.Sprintf("data: page %d: %v", i, err)}
+		}
+		if !bytes.Equal(local, stored) {
+			return []string{fmt.Sprintf("data: page %d differs from the local copy", i)}
+		}
+	}
+	return nil
+}
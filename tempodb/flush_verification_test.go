@@ -0,0 +1,67 @@
+package tempodb
+
+import (
+	"context"
+	"math/rand"
+	"path"
+	"testing"
+
+	"github.com/go-kit/kit/log"
+	"github.com/golang/protobuf/proto"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/tempo/pkg/util/test"
+	"github.com/grafana/tempo/tempodb/backend/inmemory"
+	"github.com/grafana/tempo/tempodb/wal"
+)
+
+func TestVerifyFlushedBlocks(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		faults  inmemory.Config
+		flushed bool
+	}{
+		{name: "intact", flushed: true},
+		{name: "corrupt", faults: inmemory.Config{CorruptWriteRate: 1}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			faults := tc.faults
+			_, w, _, err := New(&Config{
+				Backend:  "inmemory",
+				InMemory: &faults,
+				WAL: &wal.Config{
+					Filepath:        path.Join(t.TempDir(), "wal"),
+					IndexDownsample: 3,
+					BloomFP:         .01,
+				},
+				VerifyFlushedBlocks: true,
+				VerifyFlushedPages:  100,
+			}, log.NewNopLogger())
+			require.NoError(t, err)
+
+			head, err := w.WAL().NewBlock(uuid.New(), testTenantID)
+			require.NoError(t, err)
+			for i := 0; i < 10; i++ {
+				id := make([]byte, 16)
+				rand.Read(id)
+				bReq, err := proto.Marshal(test.MakeRequest(5, id))
+				require.NoError(t, err)
+				require.NoError(t, head.Write(id, bReq))
+			}
+
+			complete, err := head.Complete(w.WAL(), &mockSharder{})
+			require.NoError(t, err)
+
+			err = w.WriteBlock(context.Background(), complete)
+			if tc.flushed {
+				require.NoError(t, err)
+			} else {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), "doesn't match the local copy")
+			}
+			assert.Equal(t, tc.flushed, !complete.FlushedTime().IsZero())
+		})
+	}
+}
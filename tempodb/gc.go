@@ -0,0 +1,107 @@
+package tempodb
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-kit/kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const (
+	orphanKindBlock  = "block"
+	orphanKindObject = "object"
+
+	// orphanObjectsHash is the hash a compactor must own to clear orphaned objects.  They can't be attributed to a
+	// block so a single compactor looks for them.
+	orphanObjectsHash = "orphaned-objects"
+)
+
+var (
+	metricOrphansFound = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "tempodb",
+		Name:      "orphan_gc_found",
+		Help:      "Number of abandoned blocks and objects past the orphan retention found by the last orphan gc run.",
+	}, []string{"kind"})
+	metricOrphansDeleted = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "tempodb",
+		Name:      "orphan_gc_deleted_total",
+		Help:      "Total number of abandoned blocks and objects deleted.",
+	}, []string{"kind"})
+	metricOrphanGCErrors = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: "tempodb",
+		Name:      "orphan_gc_errors_total",
+		Help:      "Total number of errors occurring while deleting abandoned blocks and objects.",
+	})
+	metricOrphanGCDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: "tempodb",
+		Name:      "orphan_gc_duration_seconds",
+		Help:      "Records the amount of time to look for and delete abandoned blocks and objects.",
+		Buckets:   prometheus.ExponentialBuckets(.25, 2, 6),
+	})
+)
+
+func (rw *readerWriter) orphanGCLoop() {
+	ticker := time.NewTicker(rw.compactorCfg.OrphanGCInterval)
+	for range ticker.C {
+		rw.doOrphanGC()
+	}
+}
+
+// doOrphanGC deletes what crashed writers leave behind: blocks that have gone without a meta for longer than the orphan
+// retention and objects the backend reports as orphaned, like the parts of S3 multipart uploads that were never
+// completed.  Blocks are aged from the poll they were first seen without a meta, so a restart delays their deletion
+// but never hastens it.  With dry run they are only logged and counted.
+func (rw *readerWriter) doOrphanGC() {
+	start := time.Now()
+	defer func() { metricOrphanGCDuration.Observe(time.Since(start).Seconds()) }()
+
+	cutoff := start.Add(-rw.compactorCfg.OrphanRetention)
+	dryRun := rw.compactorCfg.OrphanGCDryRun
+
+	foundBlocks := 0
+	for _, t := range rw.blocklistTenants() {
+		tenantID := t.(string)
+
+		for blockID, firstSeen := range rw.incompleteBlocklist(tenantID) {
+			if !rw.compactorSharder.Owns(blockHashString(tenantID, blockID)) {
+				continue
+			}
+			if !firstSeen.Before(cutoff) {
+				continue
+			}
+
+			foundBlocks++
+			if dryRun {
+				level.Info(rw.logger).Log("msg", "found abandoned block", "blockID", blockID, "tenantID", tenantID, "firstSeen", firstSeen, "dryRun", true)
+				continue
+			}
+
+			level.Info(rw.logger).Log("msg", "deleting abandoned block", "blockID", blockID, "tenantID", tenantID, "firstSeen", firstSeen)
+			err := rw.c.ClearBlock(blockID, tenantID)
+			if err != nil {
+				level.Error(rw.logger).Log("msg", "failed to clear abandoned block", "blockID", blockID, "tenantID", tenantID, "err", err)
+				metricOrphanGCErrors.Inc()
+				continue
+			}
+			metricOrphansDeleted.WithLabelValues(orphanKindBlock).Inc()
+			rw.clearIncompleteBlock(tenantID, blockID)
+		}
+	}
+	metricOrphansFound.WithLabelValues(orphanKindBlock).Set(float64(foundBlocks))
+
+	if rw.orphanCleaner == nil || !rw.compactorSharder.Owns(orphanObjectsHash) {
+		return
+	}
+
+	foundObjects, err := rw.orphanCleaner.ClearOrphans(context.Background(), cutoff, dryRun)
+	if err != nil {
+		level.Error(rw.logger).Log("msg", "failed to clear orphaned objects", "err", err)
+		metricOrphanGCErrors.Inc()
+	}
+	metricOrphansFound.WithLabelValues(orphanKindObject).Set(float64(foundObjects))
+	if !dryRun && err == nil {
+		metricOrphansDeleted.WithLabelValues(orphanKindObject).Add(float64(foundObjects))
+	}
+}
@@ -0,0 +1,105 @@
+package tempodb
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/tempo/tempodb/backend/local"
+	"github.com/grafana/tempo/tempodb/encoding"
+	"github.com/grafana/tempo/tempodb/wal"
+)
+
+type mockOrphanCleaner struct {
+	cutoff time.Time
+	dryRun bool
+	calls  int
+}
+
+func (m *mockOrphanCleaner) ClearOrphans(_ context.Context, cutoff time.Time, dryRun bool) (int, error) {
+	m.cutoff = cutoff
+	m.dryRun = dryRun
+	m.calls++
+	return 2, nil
+}
+
+func TestOrphanGC(t *testing.T) {
+	tempDir, err := ioutil.TempDir("/tmp", "")
+	defer os.RemoveAll(tempDir)
+	assert.NoError(t, err, "unexpected error creating temp dir")
+
+	r, _, c, err := New(&Config{
+		Backend: "local",
+		Local: &local.Config{
+			Path: path.Join(tempDir, "traces"),
+		},
+		WAL: &wal.Config{
+			Filepath:        path.Join(tempDir, "wal"),
+			IndexDownsample: 17,
+			BloomFP:         .01,
+		},
+		BlocklistPoll: 0,
+	}, log.NewNopLogger())
+	assert.NoError(t, err)
+
+	cfg := &CompactorConfig{
+		ChunkSizeBytes:          10,
+		MaxCompactionRange:      time.Hour,
+		BlockRetention:          time.Hour,
+		CompactedBlockRetention: time.Hour,
+		OrphanRetention:         time.Hour,
+		OrphanGCDryRun:          true,
+	}
+	c.EnableCompaction(cfg, &mockSharder{}, &mockOverrides{})
+
+	rw := r.(*readerWriter)
+	cleaner := &mockOrphanCleaner{}
+	rw.orphanCleaner = cleaner
+
+	// a block whose write was abandoned before its meta was written
+	meta := encoding.NewBlockMeta(testTenantID, uuid.New(), "v0")
+	tracker, err := rw.w.AppendObject(context.Background(), nil, meta, []byte{0x01})
+	require.NoError(t, err)
+	require.NoError(t, tracker.(io.Closer).Close())
+
+	// it's left out of the blocklist
+	checkBlocklists(t, uuid.Nil, 0, 0, rw)
+	require.Len(t, rw.incompleteBlocklist(testTenantID), 1)
+
+	// and kept until it has been incomplete for longer than the retention
+	rw.doOrphanGC()
+	rw.pollBlocklist()
+	require.Len(t, rw.incompleteBlocklist(testTenantID), 1)
+
+	rw.blockListsMtx.Lock()
+	rw.incompleteBlocks[testTenantID][meta.BlockID] = time.Now().Add(-2 * time.Hour)
+	rw.blockListsMtx.Unlock()
+
+	// a dry run keeps it
+	rw.doOrphanGC()
+	rw.pollBlocklist()
+	require.Len(t, rw.incompleteBlocklist(testTenantID), 1)
+	assert.True(t, cleaner.dryRun)
+
+	cfg.OrphanGCDryRun = false
+	rw.doOrphanGC()
+	rw.pollBlocklist()
+	assert.Len(t, rw.incompleteBlocklist(testTenantID), 0)
+	blockIDs, err := rw.r.Blocks(context.Background(), testTenantID)
+	assert.NoError(t, err)
+	assert.Len(t, blockIDs, 0)
+
+	// orphaned objects are cleared with the same retention
+	assert.Equal(t, 3, cleaner.calls)
+	assert.False(t, cleaner.dryRun)
+	assert.WithinDuration(t, time.Now().Add(-time.Hour), cleaner.cutoff, time.Minute)
+}
@@ -0,0 +1,60 @@
+package tempodb
+
+import (
+	"bytes"
+	"sort"
+
+	"github.com/grafana/tempo/tempodb/encoding"
+)
+
+// idRangeIndex finds the blocks whose [MinID, MaxID] range covers a trace id without testing every block.  Objects
+// are sorted by id within a block and the range is recorded in the meta.  Fully random ids give every large block a
+// range close to the whole id space, but ids with a meaningful prefix (time based ids, 64 bit ids padded with zeros)
+// produce narrow ranges and most blocks can be skipped before their bloom filters are fetched.
+type idRangeIndex struct {
+	metas []*encoding.BlockMeta // sorted by MinID
+	// maxIDs[i] is the largest MaxID of metas[:i+1] and lets a lookup stop as soon as no earlier block can match
+	maxIDs []encoding.ID
+}
+
+func newIDRangeIndex(blocklist []*encoding.BlockMeta) *idRangeIndex {
+	metas := make([]*encoding.BlockMeta, len(blocklist))
+	copy(metas, blocklist)
+	sort.Slice(metas, func(i, j int) bool {
+		return bytes.Compare(metas[i].MinID, metas[j].MinID) == -1
+	})
+
+	maxIDs := make([]encoding.ID, len(metas))
+	for i, m := range metas {
+		maxIDs[i] = m.MaxID
+		if i > 0 && bytes.Compare(maxIDs[i-1], m.MaxID) == 1 {
+			maxIDs[i] = maxIDs[i-1]
+		}
+	}
+
+	return &idRangeIndex{
+		metas:  metas,
+		maxIDs: maxIDs,
+	}
+}
+
+// find returns the blocks whose id range covers id in no particular order.
+func (x *idRangeIndex) find(id encoding.ID) []*encoding.BlockMeta {
+	// first block that starts after the id.  nothing from here on can contain it
+	end := sort.Search(len(x.metas), func(i int) bool {
+		return bytes.Compare(x.metas[i].MinID, id) == 1
+	})
+
+	var found []*encoding.BlockMeta
+	for i := end - 1; i >= 0 && bytes.Compare(x.maxIDs[i], id) != -1; i-- {
+		if bytes.Compare(id, x.metas[i].MaxID) != 1 {
+			found = append(found, x.metas[i])
+		}
+	}
+
+	return found
+}
+
+func (x *idRangeIndex) len() int {
+	return len(x.metas)
+}
@@ -0,0 +1,44 @@
+package tempodb
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/grafana/tempo/tempodb/encoding"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIDRangeIndex(t *testing.T) {
+	meta := func(min, max byte) *encoding.BlockMeta {
+		m := encoding.NewBlockMeta(testTenantID, uuid.New(), encoding.CurrentVersion)
+		m.ObjectAdded([]byte{min})
+		m.ObjectAdded([]byte{max})
+		return m
+	}
+
+	a := meta(0x01, 0x10)
+	b := meta(0x05, 0x06)
+	c := meta(0x08, 0x40)
+	d := meta(0x20, 0x30)
+	index := newIDRangeIndex([]*encoding.BlockMeta{d, c, b, a})
+
+	tests := []struct {
+		id       byte
+		expected []*encoding.BlockMeta
+	}{
+		{0x00, nil},
+		{0x01, []*encoding.BlockMeta{a}},
+		{0x05, []*encoding.BlockMeta{a, b}},
+		{0x07, []*encoding.BlockMeta{a}},
+		{0x09, []*encoding.BlockMeta{a, c}},
+		{0x25, []*encoding.BlockMeta{c, d}},
+		{0x40, []*encoding.BlockMeta{c}},
+		{0x41, nil},
+	}
+
+	for _, tc := range tests {
+		assert.ElementsMatch(t, tc.expected, index.find([]byte{tc.id}), "id %x", tc.id)
+	}
+	assert.Equal(t, 4, index.len())
+	assert.Empty(t, newIDRangeIndex(nil).find([]byte{0x01}))
+}
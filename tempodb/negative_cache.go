@@ -0,0 +1,87 @@
+package tempodb
+
+import (
+	"container/list"
+	"encoding/hex"
+	"strconv"
+	"sync"
+
+	"github.com/google/uuid"
+	"github.com/grafana/tempo/tempodb/encoding"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	metricNegativeCache = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "tempodb",
+		Name:      "negative_cache_total",
+		Help:      "Total number of times the negative lookup cache was queried.",
+	}, []string{"status"})
+)
+
+// negativeCache is a bounded LRU of trace ids known not to exist in a block.  Blocks are immutable so a negative
+// result never goes stale.  This saves repeatedly fetching blooms for ids that have not landed in the backend yet.
+// A nil *negativeCache is valid and caches nothing.
+type negativeCache struct {
+	mtx   sync.Mutex
+	size  int
+	ll    *list.List
+	items map[string]*list.Element
+}
+
+func newNegativeCache(size int) *negativeCache {
+	if size <= 0 {
+		return nil
+	}
+
+	return &negativeCache{
+		size:  size,
+		ll:    list.New(),
+		items: make(map[string]*list.Element, size),
+	}
+}
+
+func negativeCacheKey(blockID uuid.UUID, shard int, id encoding.ID) string {
+	return blockID.String() + ":" + strconv.Itoa(shard) + ":" + hex.EncodeToString(id)
+}
+
+func (c *negativeCache) has(key string) bool {
+	if c == nil {
+		return false
+	}
+
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	e, ok := c.items[key]
+	if !ok {
+		metricNegativeCache.WithLabelValues("miss").Inc()
+		return false
+	}
+
+	c.ll.MoveToFront(e)
+	metricNegativeCache.WithLabelValues("hit").Inc()
+	return true
+}
+
+func (c *negativeCache) add(key string) {
+	if c == nil {
+		return
+	}
+
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	if e, ok := c.items[key]; ok {
+		c.ll.MoveToFront(e)
+		return
+	}
+
+	c.items[key] = c.ll.PushFront(key)
+	if c.ll.Len() > c.size {
+		oldest := c.ll.Back()
+		c.ll.Remove(oldest)
+		delete(c.items, oldest.Value.(string))
+	}
+}
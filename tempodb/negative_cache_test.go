@@ -0,0 +1,33 @@
+package tempodb
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNegativeCache(t *testing.T) {
+	c := newNegativeCache(2)
+
+	a := negativeCacheKey(uuid.New(), 0, []byte{0x01})
+	b := negativeCacheKey(uuid.New(), 1, []byte{0x02})
+	d := negativeCacheKey(uuid.New(), 2, []byte{0x03})
+
+	assert.False(t, c.has(a))
+	c.add(a)
+	c.add(b)
+	assert.True(t, c.has(a))
+	assert.True(t, c.has(b))
+
+	// a was used least recently so it is evicted
+	c.add(d)
+	assert.False(t, c.has(a))
+	assert.True(t, c.has(b))
+	assert.True(t, c.has(d))
+
+	// disabled
+	disabled := newNegativeCache(0)
+	disabled.add(a)
+	assert.False(t, disabled.has(a))
+}
@@ -1,6 +1,14 @@
 package pool
 
+import "time"
+
 type Config struct {
 	MaxWorkers int `yaml:"max_workers"`
 	QueueDepth int `yaml:"queue_depth"`
+
+	// AdaptiveConcurrency limits the jobs running at once between MinWorkers and MaxWorkers based on how quickly and
+	// reliably they complete.
+	AdaptiveConcurrency bool          `yaml:"adaptive_concurrency"`
+	MinWorkers          int           `yaml:"min_workers"`
+	TargetLatency       time.Duration `yaml:"target_latency"`
 }
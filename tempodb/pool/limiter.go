@@ -0,0 +1,113 @@
+package pool
+
+import (
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// backoffRatio is what the concurrency limit is multiplied by when a job fails or is slow.
+const backoffRatio = 0.75
+
+var (
+	metricConcurrencyLimit = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: "tempodb",
+		Name:      "work_concurrency_limit",
+		Help:      "Current limit of jobs the worker pool runs at once.",
+	})
+)
+
+// aimdLimiter bounds the number of jobs running at once.  The limit grows additively, by one for every limit jobs that
+// complete within the target latency, and shrinks multiplicatively when a job fails or is slower.  It settles at the
+// concurrency the backend serves without slowing down instead of a fixed number of workers.
+type aimdLimiter struct {
+	min    float64
+	max    float64
+	target time.Duration
+	now    func() time.Time
+
+	mtx          sync.Mutex
+	cond         *sync.Cond
+	limit        float64
+	inflight     int
+	lastDecrease time.Time
+}
+
+// newAIMDLimiter starts at the max so a healthy backend is used as before.
+func newAIMDLimiter(min int, max int, target time.Duration) *aimdLimiter {
+	if min < 1 {
+		min = 1
+	}
+	if max < min {
+		max = min
+	}
+
+	l := &aimdLimiter{
+		min:    float64(min),
+		max:    float64(max),
+		target: target,
+		now:    time.Now,
+		limit:  float64(max),
+	}
+	l.cond = sync.NewCond(&l.mtx)
+	metricConcurrencyLimit.Set(l.limit)
+
+	return l
+}
+
+// acquire blocks until fewer jobs than the limit are running.
+func (l *aimdLimiter) acquire() {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	for l.inflight >= int(l.limit) {
+		l.cond.Wait()
+	}
+	l.inflight++
+}
+
+// release adjusts the limit with the outcome of a job.  The jobs running when the limit is decreased were started under
+// the old limit, so it's decreased at most once per target latency.
+func (l *aimdLimiter) release(latency time.Duration, failed bool) {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	l.inflight--
+
+	if failed || latency > l.target {
+		now := l.now()
+		if now.Sub(l.lastDecrease) >= l.target {
+			l.limit *= backoffRatio
+			if l.limit < l.min {
+				l.limit = l.min
+			}
+			l.lastDecrease = now
+		}
+	} else {
+		l.limit += 1 / l.limit
+		if l.limit > l.max {
+			l.limit = l.max
+		}
+	}
+	metricConcurrencyLimit.Set(l.limit)
+
+	l.cond.Broadcast()
+}
+
+// cancel releases a job that didn't run without adjusting the limit.
+func (l *aimdLimiter) cancel() {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	l.inflight--
+	l.cond.Broadcast()
+}
+
+func (l *aimdLimiter) currentLimit() int {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	return int(l.limit)
+}
@@ -0,0 +1,99 @@
+package pool
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/goleak"
+)
+
+func TestAIMDLimiter(t *testing.T) {
+	now := time.Unix(0, 0)
+	l := newAIMDLimiter(2, 10, time.Second)
+	l.now = func() time.Time { return now }
+	assert.Equal(t, 10, l.currentLimit())
+
+	// failures and slow jobs decrease the limit once per target latency
+	l.acquire()
+	l.release(time.Millisecond, true)
+	assert.Equal(t, 7, l.currentLimit())
+	l.acquire()
+	l.release(2*time.Second, false)
+	assert.Equal(t, 7, l.currentLimit())
+	now = now.Add(time.Second)
+	l.acquire()
+	l.release(2*time.Second, false)
+	assert.Equal(t, 5, l.currentLimit())
+
+	// but never below the min
+	for i := 0; i < 10; i++ {
+		now = now.Add(time.Second)
+		l.acquire()
+		l.release(time.Millisecond, true)
+	}
+	assert.Equal(t, 2, l.currentLimit())
+
+	// fast jobs increase it by one per limit jobs
+	l.acquire()
+	l.release(time.Millisecond, false)
+	l.acquire()
+	l.release(time.Millisecond, false)
+	assert.Equal(t, 2, l.currentLimit())
+	l.acquire()
+	l.release(time.Millisecond, false)
+	assert.Equal(t, 3, l.currentLimit())
+
+	// up to the max
+	for i := 0; i < 100; i++ {
+		l.acquire()
+		l.release(time.Millisecond, false)
+	}
+	assert.Equal(t, 10, l.currentLimit())
+}
+
+func TestAdaptiveConcurrency(t *testing.T) {
+	prePoolOpts := goleak.IgnoreCurrent()
+
+	p := NewPool(&Config{
+		MaxWorkers:          10,
+		QueueDepth:          100,
+		AdaptiveConcurrency: true,
+		MinWorkers:          2,
+		TargetLatency:       0, // every job is slow
+	})
+
+	payloads := make([]interface{}, 20)
+	_, err := p.RunJobs(context.Background(), payloads, func(ctx context.Context, payload interface{}) ([]byte, error) {
+		return nil, errors.New("failed")
+	})
+	require.Error(t, err)
+	assert.Equal(t, 2, p.limiter.currentLimit())
+
+	mtx := sync.Mutex{}
+	running, maxRunning := 0, 0
+	_, err = p.RunJobs(context.Background(), payloads, func(ctx context.Context, payload interface{}) ([]byte, error) {
+		mtx.Lock()
+		running++
+		if running > maxRunning {
+			maxRunning = running
+		}
+		mtx.Unlock()
+
+		time.Sleep(5 * time.Millisecond)
+
+		mtx.Lock()
+		running--
+		mtx.Unlock()
+		return nil, nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 2, maxRunning)
+
+	p.Shutdown()
+	goleak.VerifyNone(t, prePoolOpts)
+}
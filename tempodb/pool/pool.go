@@ -38,14 +38,16 @@ type job struct {
 	fn      JobFunc
 
 	wg        *sync.WaitGroup
+	sem       chan struct{} // nil unless the caller limited its jobs
 	resultsCh chan []byte
 	stop      *atomic.Bool
 	err       *atomic.Error
 }
 
 type Pool struct {
-	cfg  *Config
-	size *atomic.Int32
+	cfg     *Config
+	size    *atomic.Int32
+	limiter *aimdLimiter // nil unless concurrency is adaptive
 
 	workQueue  chan *job
 	shutdownCh chan struct{}
@@ -63,6 +65,9 @@ func NewPool(cfg *Config) *Pool {
 		size:       atomic.NewInt32(0),
 		shutdownCh: make(chan struct{}),
 	}
+	if cfg.AdaptiveConcurrency {
+		p.limiter = newAIMDLimiter(cfg.MinWorkers, cfg.MaxWorkers, cfg.TargetLatency)
+	}
 
 	for i := 0; i < cfg.MaxWorkers; i++ {
 		go p.worker(q)
@@ -75,15 +80,29 @@ func NewPool(cfg *Config) *Pool {
 	return p
 }
 
+// RunJobs runs fn for every payload on the pool's workers and returns the first result.  Jobs not yet started when a
+// result is returned are skipped.
 func (p *Pool) RunJobs(ctx context.Context, payloads []interface{}, fn JobFunc) ([]byte, error) {
+	return p.RunJobsLimited(ctx, payloads, 0, fn)
+}
+
+// RunJobsLimited is RunJobs with at most limit of the jobs queued or running at once, so a call with many payloads
+// leaves workers for other callers.  Further jobs are queued as earlier ones complete, and no more are queued once a
+// job has returned a result or ctx is done.  A limit of 0 queues every job at once.
+func (p *Pool) RunJobsLimited(ctx context.Context, payloads []interface{}, limit int, fn JobFunc) ([]byte, error) {
 	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
 
 	totalJobs := len(payloads)
+	var sem chan struct{}
+	if limit > 0 && limit < totalJobs {
+		totalJobs = limit
+		sem = make(chan struct{}, limit)
+	}
 
 	// sanity check before we even attempt to start adding jobs
 	if int(p.size.Load())+totalJobs > p.cfg.QueueDepth {
-		return nil, fmt.Errorf("queue doesn't have room for %d jobs", len(payloads))
+		return nil, fmt.Errorf("queue doesn't have room for %d jobs", totalJobs)
 	}
 
 	resultsCh := make(chan []byte, 1) // way for jobs to send back results
@@ -92,7 +111,13 @@ func (p *Pool) RunJobs(ctx context.Context, payloads []interface{}, fn JobFunc)
 	wg := &sync.WaitGroup{}           // way to wait for all jobs to complete
 
 	// add each job one at a time.  even though we checked length above these might still fail
+	skipped := false
 	for _, payload := range payloads {
+		if sem != nil && !acquire(ctx, sem, stop) {
+			skipped = true
+			break
+		}
+
 		wg.Add(1)
 		j := &job{
 			ctx:       ctx,
@@ -100,6 +125,7 @@ func (p *Pool) RunJobs(ctx context.Context, payloads []interface{}, fn JobFunc)
 			fn:        fn,
 			payload:   payload,
 			wg:        wg,
+			sem:       sem,
 			resultsCh: resultsCh,
 			stop:      stop,
 			err:       err,
@@ -109,6 +135,9 @@ func (p *Pool) RunJobs(ctx context.Context, payloads []interface{}, fn JobFunc)
 		case p.workQueue <- j:
 			p.size.Inc()
 		default:
+			if sem != nil {
+				<-sem
+			}
 			wg.Done()
 			stop.Store(true)
 			return nil, fmt.Errorf("failed to add a job to work queue")
@@ -130,10 +159,33 @@ func (p *Pool) RunJobs(ctx context.Context, payloads []interface{}, fn JobFunc)
 	if msg != nil {
 		return msg, nil
 	}
+	// jobs that were never queued because the caller gave up weren't searched
+	if skipped && err.Load() == nil && ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
 
 	return nil, err.Load()
 }
 
+// acquire waits for a slot of sem.  It returns false if ctx is done or a job has returned a result first.
+func acquire(ctx context.Context, sem chan struct{}, stop *atomic.Bool) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+
+	select {
+	case sem <- struct{}{}:
+	case <-ctx.Done():
+		return false
+	}
+
+	if stop.Load() {
+		<-sem
+		return false
+	}
+	return true
+}
+
 func (p *Pool) Shutdown() {
 	close(p.workQueue)
 	close(p.shutdownCh)
@@ -148,7 +200,7 @@ func (p *Pool) worker(j <-chan *job) {
 			if !ok {
 				return
 			}
-			runJob(j)
+			p.runJob(j)
 			p.size.Dec()
 		}
 	}
@@ -169,14 +221,32 @@ func (p *Pool) reportQueueLength() {
 	}()
 }
 
-func runJob(job *job) {
+func (p *Pool) runJob(job *job) {
 	defer job.wg.Done()
+	if job.sem != nil {
+		defer func() { <-job.sem }()
+	}
 
 	if job.stop.Load() {
 		return
 	}
 
+	if p.limiter != nil {
+		p.limiter.acquire()
+
+		// another job may have succeeded while this one waited
+		if job.stop.Load() {
+			p.limiter.cancel()
+			return
+		}
+	}
+
+	start := time.Now()
 	msg, err := job.fn(job.ctx, job.payload)
+	if p.limiter != nil {
+		// jobs cancelled by the caller say nothing about the backend
+		p.limiter.release(time.Since(start), err != nil && job.ctx.Err() == nil)
+	}
 	if msg != nil {
 		job.stop.Store(true) // one job was successful.  stop all others
 		// Commenting out job cancellations for now because of a resource leak suspected in the GCS golang client.
@@ -292,3 +292,63 @@ func TestShutdown(t *testing.T) {
 	assert.Error(t, err)
 	goleak.VerifyNone(t, opts)
 }
+
+func TestRunJobsLimited(t *testing.T) {
+	prePoolOpts := goleak.IgnoreCurrent()
+
+	p := NewPool(&Config{
+		MaxWorkers: 10,
+		QueueDepth: 5,
+	})
+	opts := goleak.IgnoreCurrent()
+
+	var (
+		mtx          sync.Mutex
+		running, max int
+		ran          []int
+	)
+	fn := func(ctx context.Context, payload interface{}) ([]byte, error) {
+		mtx.Lock()
+		running++
+		if running > max {
+			max = running
+		}
+		ran = append(ran, payload.(int))
+		mtx.Unlock()
+
+		time.Sleep(5 * time.Millisecond)
+
+		mtx.Lock()
+		running--
+		mtx.Unlock()
+
+		if payload.(int) == 10 {
+			return []byte{0x01}, nil
+		}
+		return nil, nil
+	}
+
+	// more payloads than the queue depth are fine as only the limit is queued at once
+	payloads := make([]interface{}, 0, 20)
+	for i := 0; i < 20; i++ {
+		payloads = append(payloads, i)
+	}
+
+	msg, err := p.RunJobsLimited(context.Background(), payloads, 2, fn)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{0x01}, msg)
+	assert.LessOrEqual(t, max, 2)
+	// payloads after the hit are not queued once it's found
+	assert.Less(t, len(ran), 20)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	msg, err = p.RunJobsLimited(ctx, payloads, 2, fn)
+	assert.Nil(t, msg)
+	assert.Equal(t, context.Canceled, err)
+
+	goleak.VerifyNone(t, opts)
+
+	p.Shutdown()
+	goleak.VerifyNone(t, prePoolOpts)
+}
@@ -0,0 +1,100 @@
+package tempodb
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/opentracing/opentracing-go"
+	"go.uber.org/atomic"
+
+	"github.com/grafana/tempo/tempodb/encoding"
+)
+
+// RecentObjects returns up to limit objects from the tenant's most recently written blocks.  Blocks are sorted by id
+// and not by time so the objects are a sample of the newest blocks rather than the very last traces received.  Pages
+// are read newest block first, last page first, until limit objects have been collected.
+func (rw *readerWriter) RecentObjects(ctx context.Context, tenantID string, limit int) ([]encoding.ID, [][]byte, FindMetrics, error) {
+	metrics := FindMetrics{
+		BloomFilterReads:     atomic.NewInt32(0),
+		BloomFilterBytesRead: atomic.NewInt32(0),
+		IndexReads:           atomic.NewInt32(0),
+		IndexBytesRead:       atomic.NewInt32(0),
+		BlockReads:           atomic.NewInt32(0),
+		BlockBytesRead:       atomic.NewInt32(0),
+		FoundBlockEnd:        atomic.NewInt64(0),
+	}
+
+	span, ctx := opentracing.StartSpanFromContext(ctx, "store.RecentObjects")
+	defer span.Finish()
+
+	blocklist := rw.blocklist(tenantID)
+	sort.Slice(blocklist, func(i, j int) bool {
+		return blocklist[i].EndTime.After(blocklist[j].EndTime)
+	})
+
+	ids := make([]encoding.ID, 0, limit)
+	objects := make([][]byte, 0, limit)
+	seen := map[string]struct{}{}
+	for _, meta := range blocklist {
+		if len(ids) >= limit {
+			break
+		}
+
+		start := time.Now()
+		fetchSpan, fetchCtx := startFetchSpan(ctx, fetchTypeIndex, meta)
+		indexBytes, err := rw.r.Index(fetchCtx, meta.BlockID, tenantID)
+		fetchSpan.Finish()
+		metricFindFetchDuration.WithLabelValues(rw.cfg.Backend, fetchTypeIndex).Observe(time.Since(start).Seconds())
+		metrics.IndexReads.Inc()
+		metrics.IndexBytesRead.Add(int32(len(indexBytes)))
+		if err != nil {
+			return nil, nil, metrics, fmt.Errorf("error reading index %v", err)
+		}
+
+		records, err := encoding.UnmarshalRecords(indexBytes)
+		if err != nil {
+			return nil, nil, metrics, fmt.Errorf("error unmarshalling index %v", err)
+		}
+
+		for r := len(records) - 1; r >= 0 && len(ids) < limit; r-- {
+			record := records[r]
+
+			objectBytes := make([]byte, record.Length)
+			start = time.Now()
+			fetchSpan, fetchCtx = startFetchSpan(ctx, fetchTypeObject, meta)
+			err = rw.r.Object(fetchCtx, meta.BlockID, tenantID, record.Start, objectBytes)
+			fetchSpan.Finish()
+			metricFindFetchDuration.WithLabelValues(rw.cfg.Backend, fetchTypeObject).Observe(time.Since(start).Seconds())
+			metrics.BlockReads.Inc()
+			metrics.BlockBytesRead.Add(int32(len(objectBytes)))
+			if err != nil {
+				return nil, nil, metrics, fmt.Errorf("error reading object %v", err)
+			}
+
+			iter := encoding.NewDecodingIterator(encoding.NewIterator(bytes.NewReader(objectBytes)), meta.Encoding)
+			for len(ids) < limit {
+				id, object, err := iter.Next()
+				if id == nil {
+					break
+				}
+				if err != nil {
+					return nil, nil, metrics, err
+				}
+
+				// level 0 blocks may hold more than one object for a trace
+				if _, ok := seen[string(id)]; ok {
+					continue
+				}
+				seen[string(id)] = struct{}{}
+
+				ids = append(ids, id)
+				objects = append(objects, object)
+			}
+		}
+	}
+
+	return ids, objects, metrics, nil
+}
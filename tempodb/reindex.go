@@ -0,0 +1,123 @@
+package tempodb
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+
+	"github.com/go-kit/kit/log/level"
+	"github.com/grafana/tempo/tempodb/encoding"
+	"github.com/grafana/tempo/tempodb/encoding/bloom"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	metricReindexedBlocks = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: "tempodb",
+		Name:      "reindex_blocks_total",
+		Help:      "Total number of blocks whose bloom filter and index were rebuilt.",
+	})
+	metricReindexErrors = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: "tempodb",
+		Name:      "reindex_errors_total",
+		Help:      "Total number of errors occurring while rebuilding a block's bloom filter and index.",
+	})
+)
+
+// doReindex rebuilds the bloom filters and indexes of blocks that were built with settings other than the configured
+// reindex settings.  The data object is only read, never rewritten, so this is far cheaper than compaction.  The
+// number of bloom shards is fixed as readers and caches locate shards by shard number.
+func (rw *readerWriter) doReindex() {
+	if rw.compactorCfg.ReindexBlocksPerCycle <= 0 {
+		return
+	}
+
+	reindexed := 0
+	for _, t := range rw.blocklistTenants() {
+		tenantID := t.(string)
+		if rw.compactorOverrides.CompactionDisabledForTenant(tenantID) {
+			continue
+		}
+
+		for _, meta := range rw.blocklist(tenantID) {
+			if reindexed >= rw.compactorCfg.ReindexBlocksPerCycle {
+				return
+			}
+
+			if !rw.needsReindex(meta) || !rw.compactorSharder.Owns(blockHashString(tenantID, meta.BlockID)) {
+				continue
+			}
+			if rw.compactionPools != nil && rw.compactionPools.busy(meta.BlockID) {
+				continue
+			}
+
+			reindexed++
+			err := rw.reindex(context.Background(), meta)
+			if err != nil {
+				metricReindexErrors.Inc()
+				level.Error(rw.logger).Log("msg", "failed to reindex block", "tenantID", tenantID, "blockID", meta.BlockID, "err", err)
+				continue
+			}
+
+			metricReindexedBlocks.Inc()
+			level.Info(rw.logger).Log("msg", "reindexed block", "tenantID", tenantID, "blockID", meta.BlockID)
+		}
+	}
+}
+
+func (rw *readerWriter) needsReindex(meta *encoding.BlockMeta) bool {
+	return meta.BloomFP != rw.compactorCfg.ReindexBloomFP || meta.IndexDownsample != rw.compactorCfg.ReindexIndexDownsample
+}
+
+// reindex streams every object in the block to rebuild its bloom filter and index and then replaces them along with
+// the meta.  Readers that see the old index or bloom in the meantime still get correct results as the data is
+// unchanged.
+func (rw *readerWriter) reindex(ctx context.Context, meta *encoding.BlockMeta) error {
+	iter, err := encoding.NewBackendIterator(meta.TenantID, meta.BlockID, rw.compactorCfg.ChunkSizeBytes, rw.r)
+	if err != nil {
+		return err
+	}
+
+	b := bloom.NewWithShardCount(uint(meta.TotalObjects), rw.compactorCfg.ReindexBloomFP, meta.BloomShards())
+	// object offsets are recalculated by appending to a writer that discards everything
+	appender := encoding.NewBufferedAppender(ioutil.Discard, rw.compactorCfg.ReindexIndexDownsample, meta.TotalObjects)
+	for {
+		id, object, err := iter.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return err
+		}
+
+		// the id escapes the iterator in the index records and needs to be copied
+		writeID := append([]byte(nil), id...)
+		b.Add(writeID)
+		err = appender.Append(writeID, object)
+		if err != nil {
+			return err
+		}
+	}
+	appender.Complete()
+
+	index, err := encoding.MarshalRecords(appender.Records())
+	if err != nil {
+		return err
+	}
+	blooms, err := b.WriteTo()
+	if err != nil {
+		return err
+	}
+
+	// the block may have been compacted while it was read.  writing the meta would bring it back
+	_, err = rw.r.BlockMeta(ctx, meta.BlockID, meta.TenantID)
+	if err != nil {
+		return err
+	}
+
+	newMeta := *meta
+	newMeta.BloomFP = rw.compactorCfg.ReindexBloomFP
+	newMeta.IndexDownsample = rw.compactorCfg.ReindexIndexDownsample
+
+	return rw.w.WriteBlockMeta(ctx, nil, &newMeta, blooms, index)
+}
@@ -0,0 +1,114 @@
+package tempodb
+
+import (
+	"context"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/golang/protobuf/proto"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/grafana/tempo/pkg/tempopb"
+	"github.com/grafana/tempo/pkg/util/test"
+	"github.com/grafana/tempo/tempodb/backend/local"
+	"github.com/grafana/tempo/tempodb/encoding"
+	"github.com/grafana/tempo/tempodb/pool"
+	"github.com/grafana/tempo/tempodb/wal"
+)
+
+func TestReindex(t *testing.T) {
+	tempDir, err := ioutil.TempDir("/tmp", "")
+	defer os.RemoveAll(tempDir)
+	assert.NoError(t, err, "unexpected error creating temp dir")
+
+	r, w, c, err := New(&Config{
+		Backend: "local",
+		Pool: &pool.Config{
+			MaxWorkers: 10,
+			QueueDepth: 100,
+		},
+		Local: &local.Config{
+			Path: path.Join(tempDir, "traces"),
+		},
+		WAL: &wal.Config{
+			Filepath:        path.Join(tempDir, "wal"),
+			IndexDownsample: 11,
+			BloomFP:         .01,
+		},
+		BlocklistPoll: 0,
+	}, log.NewNopLogger())
+	assert.NoError(t, err)
+
+	c.EnableCompaction(&CompactorConfig{
+		ChunkSizeBytes:         10,
+		MaxCompactionRange:     24 * time.Hour,
+		ReindexBlocksPerCycle:  1,
+		ReindexBloomFP:         .05,
+		ReindexIndexDownsample: 5,
+	}, &mockSharder{}, &mockOverrides{})
+
+	recordCount := 100
+	head, err := w.WAL().NewBlock(uuid.New(), testTenantID)
+	assert.NoError(t, err)
+
+	reqs := make([]*tempopb.PushRequest, 0, recordCount)
+	ids := make([][]byte, 0, recordCount)
+	for i := 0; i < recordCount; i++ {
+		id := make([]byte, 16)
+		_, err = rand.Read(id)
+		assert.NoError(t, err, "unexpected creating random id")
+
+		req := test.MakeRequest(10, id)
+		reqs = append(reqs, req)
+		ids = append(ids, id)
+
+		bReq, err := proto.Marshal(req)
+		assert.NoError(t, err)
+		err = head.Write(id, bReq)
+		assert.NoError(t, err, "unexpected error writing req")
+	}
+
+	complete, err := head.Complete(w.WAL(), &mockSharder{})
+	assert.NoError(t, err)
+	err = w.WriteBlock(context.Background(), complete)
+	assert.NoError(t, err)
+
+	rw := r.(*readerWriter)
+	rw.pollBlocklist()
+
+	blocklist := rw.blocklist(testTenantID)
+	assert.Len(t, blocklist, 1)
+	assert.Equal(t, .01, blocklist[0].BloomFP)
+	assert.Equal(t, 11, blocklist[0].IndexDownsample)
+
+	rw.doReindex()
+	rw.pollBlocklist()
+
+	blocklist = rw.blocklist(testTenantID)
+	assert.Len(t, blocklist, 1)
+	meta := blocklist[0]
+	assert.Equal(t, .05, meta.BloomFP)
+	assert.Equal(t, 5, meta.IndexDownsample)
+	assert.Equal(t, recordCount, meta.TotalObjects)
+	assert.False(t, rw.needsReindex(meta))
+
+	index, err := rw.r.Index(context.Background(), meta.BlockID, testTenantID)
+	assert.NoError(t, err)
+	assert.Equal(t, recordCount/5, encoding.RecordCount(index))
+
+	for i, id := range ids {
+		b, _, err := rw.Find(context.Background(), testTenantID, id)
+		assert.NoError(t, err)
+
+		out := &tempopb.PushRequest{}
+		err = proto.Unmarshal(b, out)
+		assert.NoError(t, err)
+		assert.True(t, proto.Equal(out, reqs[i]))
+	}
+}
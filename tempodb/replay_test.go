@@ -0,0 +1,221 @@
+package tempodb
+
+import (
+	"context"
+	"flag"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/tempo/tempodb/backend/local"
+	"github.com/grafana/tempo/tempodb/encoding"
+	"github.com/grafana/tempo/tempodb/pool"
+	"github.com/grafana/tempo/tempodb/wal"
+)
+
+// The replay benchmarks run a directory of recorded blocks (a local backend, as written by the "local" backend or
+// copied from a bucket with tempo-cli) through the query and compaction paths.  All randomness is derived from
+// -replay.seed so two runs against the same directory exercise the exact same trace ids and block sets.
+//
+//   go test ./tempodb -run=none -bench=BenchmarkReplay -replay.path=/data/blocks -replay.tenant=single-tenant
+var (
+	replayPath    = flag.String("replay.path", "", "Path to a local backend directory containing recorded blocks. Replay benchmarks are skipped if unset.")
+	replayTenant  = flag.String("replay.tenant", "single-tenant", "Tenant to replay.")
+	replaySeed    = flag.Int64("replay.seed", 1, "Seed used to select trace ids and blocks.")
+	replayQueries = flag.Int("replay.queries", 1000, "Number of trace ids to query per iteration.")
+)
+
+func BenchmarkReplayFind(b *testing.B) {
+	rw, ids := setupReplay(b)
+	defer rw.Shutdown()
+
+	ctx := context.Background()
+	latencies := make([]time.Duration, 0, len(ids)*b.N)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, id := range ids {
+			start := time.Now()
+			_, _, err := rw.Find(ctx, *replayTenant, id)
+			latencies = append(latencies, time.Since(start))
+			require.NoError(b, err)
+		}
+	}
+	b.StopTimer()
+
+	reportLatencies(b, latencies)
+}
+
+func BenchmarkReplayCompaction(b *testing.B) {
+	rw, _ := setupReplay(b)
+	defer rw.Shutdown()
+
+	rw.compactorCfg = &CompactorConfig{
+		ChunkSizeBytes:       10 * 1024 * 1024,
+		FlushSizeBytes:       30 * 1024 * 1024,
+		MaxCompactionRange:   24 * time.Hour,
+		MaxCompactionObjects: 6000000,
+	}
+	rw.compactorSharder = &mockSharder{}
+
+	var objects int
+	var elapsed time.Duration
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		// every iteration compacts the original recorded blocks
+		b.StopTimer()
+		err := os.RemoveAll(rw.cfg.Local.Path)
+		require.NoError(b, err)
+		err = copyDir(*replayPath, rw.cfg.Local.Path)
+		require.NoError(b, err)
+
+		rw.pollBlocklist()
+		blocklist := rw.blocklist(*replayTenant)
+		rand.New(rand.NewSource(*replaySeed)).Shuffle(len(blocklist), func(i, j int) {
+			blocklist[i], blocklist[j] = blocklist[j], blocklist[i]
+		})
+		selector := newTimeWindowBlockSelector(blocklist, rw.compactorCfg.MaxCompactionRange, rw.compactorCfg.MaxCompactionObjects, rw.compactorCfg.MaxBlockBytes)
+		b.StartTimer()
+
+		for {
+			toBeCompacted, _ := selector.BlocksToCompact()
+			if len(toBeCompacted) == 0 {
+				break
+			}
+
+			start := time.Now()
+			err := rw.compact(toBeCompacted, *replayTenant)
+			elapsed += time.Since(start)
+			require.NoError(b, err)
+
+			for _, m := range toBeCompacted {
+				objects += m.TotalObjects
+			}
+		}
+	}
+	b.StopTimer()
+
+	if elapsed > 0 {
+		b.ReportMetric(float64(objects)/elapsed.Seconds(), "objects/s")
+	}
+}
+
+// setupReplay copies the recorded blocks into a scratch backend so compaction can't damage the originals and
+// returns a readerWriter with a polled blocklist plus a seeded selection of trace ids known to exist.
+func setupReplay(b *testing.B) (*readerWriter, []encoding.ID) {
+	if *replayPath == "" {
+		b.Skip("-replay.path not set")
+	}
+
+	tempDir, err := ioutil.TempDir("/tmp", "")
+	require.NoError(b, err)
+	b.Cleanup(func() { os.RemoveAll(tempDir) })
+
+	err = copyDir(*replayPath, path.Join(tempDir, "traces"))
+	require.NoError(b, err)
+
+	r, _, _, err := New(&Config{
+		Backend: "local",
+		Pool: &pool.Config{
+			MaxWorkers: 30,
+			QueueDepth: 10000,
+		},
+		Local: &local.Config{
+			Path: path.Join(tempDir, "traces"),
+		},
+		WAL: &wal.Config{
+			Filepath:        path.Join(tempDir, "wal"),
+			IndexDownsample: 100,
+			BloomFP:         .05,
+		},
+		BlocklistPoll: 0,
+	}, log.NewNopLogger())
+	require.NoError(b, err)
+
+	rw := r.(*readerWriter)
+	rw.pollBlocklist()
+
+	blocklist := rw.blocklist(*replayTenant)
+	require.NotEmpty(b, blocklist, "no blocks found for tenant %s", *replayTenant)
+
+	// index records contain the last id of every page which are guaranteed to exist in the block
+	var candidates []encoding.ID
+	for _, meta := range blocklist {
+		indexBytes, err := rw.r.Index(context.Background(), meta.BlockID, *replayTenant)
+		require.NoError(b, err)
+
+		records, err := encoding.UnmarshalRecords(indexBytes)
+		require.NoError(b, err)
+
+		for _, rec := range records {
+			candidates = append(candidates, rec.ID)
+		}
+	}
+	require.NotEmpty(b, candidates)
+
+	rnd := rand.New(rand.NewSource(*replaySeed))
+	ids := make([]encoding.ID, 0, *replayQueries)
+	for i := 0; i < *replayQueries; i++ {
+		ids = append(ids, candidates[rnd.Intn(len(candidates))])
+	}
+
+	return rw, ids
+}
+
+func reportLatencies(b *testing.B, latencies []time.Duration) {
+	if len(latencies) == 0 {
+		return
+	}
+
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	quantile := func(q float64) float64 {
+		return float64(latencies[int(float64(len(latencies)-1)*q)]) / float64(time.Millisecond)
+	}
+
+	b.ReportMetric(quantile(.5), "p50-ms")
+	b.ReportMetric(quantile(.9), "p90-ms")
+	b.ReportMetric(quantile(.99), "p99-ms")
+}
+
+func copyDir(src string, dst string) error {
+	return filepath.Walk(src, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(src, p)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+
+		if info.IsDir() {
+			return os.MkdirAll(target, os.ModePerm)
+		}
+
+		in, err := os.Open(p)
+		if err != nil {
+			return err
+		}
+		defer in.Close()
+
+		out, err := os.Create(target)
+		if err != nil {
+			return err
+		}
+		defer out.Close()
+
+		_, err = io.Copy(out, in)
+		return err
+	})
+}
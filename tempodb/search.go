@@ -0,0 +1,145 @@
+package tempodb
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/opentracing/opentracing-go"
+	"go.uber.org/atomic"
+
+	"github.com/grafana/tempo/tempodb/encoding"
+)
+
+const (
+	searchServiceNameTag = "service.name"
+	// searchScanChunkSizeBytes is the size of the pages of objects read when scanning a block without search data.
+	searchScanChunkSizeBytes = 4 * 1024 * 1024
+)
+
+// Search returns up to q.Limit traces matching the query from the search sections of the tenant's blocks.  Blocks are
+// read newest first and blocks written without a search section are skipped.  Level 0 blocks may hold the same trace
+// so a trace may be returned more than once.  Search sections are counted as index reads and the pages of scanned
+// blocks as block reads.
+func (rw *readerWriter) Search(ctx context.Context, tenantID string, q *encoding.SearchQuery) ([]encoding.SearchTrace, FindMetrics, error) {
+	metrics := FindMetrics{
+		BloomFilterReads:     atomic.NewInt32(0),
+		BloomFilterBytesRead: atomic.NewInt32(0),
+		IndexReads:           atomic.NewInt32(0),
+		IndexBytesRead:       atomic.NewInt32(0),
+		BlockReads:           atomic.NewInt32(0),
+		BlockBytesRead:       atomic.NewInt32(0),
+		FoundBlockEnd:        atomic.NewInt64(0),
+	}
+
+	span, ctx := opentracing.StartSpanFromContext(ctx, "store.Search")
+	defer span.Finish()
+
+	blocklist := rw.blocklist(tenantID)
+	sort.Slice(blocklist, func(i, j int) bool {
+		return blocklist[i].EndTime.After(blocklist[j].EndTime)
+	})
+
+	var include BlockFilter
+	if service, ok := q.Tags[searchServiceNameTag]; ok {
+		include = ServiceFilter(service)
+	}
+
+	var results []encoding.SearchTrace
+	for _, meta := range blocklist {
+		if q.Limit > 0 && len(results) >= q.Limit {
+			break
+		}
+		if (!meta.Searchable && q.Matcher == nil) || (include != nil && !include(meta)) {
+			continue
+		}
+		if !q.Start.IsZero() && meta.EndTime.Before(q.Start) {
+			continue
+		}
+		if !q.End.IsZero() && meta.StartTime.After(q.End) {
+			continue
+		}
+		if meta.Summary != nil && !meta.Summary.MayOverlap(q.Start, q.End) {
+			continue
+		}
+
+		if !meta.Searchable {
+			found, err := rw.scanBlock(ctx, tenantID, meta, q, len(results), metrics)
+			if err != nil {
+				return nil, metrics, fmt.Errorf("error scanning block %v", err)
+			}
+			results = append(results, found...)
+			continue
+		}
+
+		start := time.Now()
+		fetchSpan, fetchCtx := startFetchSpan(ctx, fetchTypeSearch, meta)
+		searchBytes, err := rw.r.Search(fetchCtx, meta.BlockID, tenantID)
+		fetchSpan.Finish()
+		metricFindFetchDuration.WithLabelValues(rw.cfg.Backend, fetchTypeSearch).Observe(time.Since(start).Seconds())
+		metrics.IndexReads.Inc()
+		metrics.IndexBytesRead.Add(int32(len(searchBytes)))
+		if err != nil {
+			return nil, metrics, fmt.Errorf("error reading search %v", err)
+		}
+
+		search, err := encoding.UnmarshalSearchData(searchBytes)
+		if err != nil {
+			return nil, metrics, fmt.Errorf("error unmarshalling search %v", err)
+		}
+
+		found := search.Search(q)
+		if q.Limit > 0 && len(results)+len(found) > q.Limit {
+			found = found[:q.Limit-len(results)]
+		}
+		results = append(results, found...)
+	}
+
+	return results, metrics, nil
+}
+
+// scanBlock returns the traces of a block without search data matching the query.  Objects are read page by page and
+// evaluated by q.Matcher while they're decoded, so only the traces that match are materialized.  The index and pages
+// read are counted in metrics.
+func (rw *readerWriter) scanBlock(ctx context.Context, tenantID string, meta *encoding.BlockMeta, q *encoding.SearchQuery, found int, metrics FindMetrics) ([]encoding.SearchTrace, error) {
+	span, _ := startFetchSpan(ctx, fetchTypeScan, meta)
+	defer span.Finish()
+
+	start := time.Now()
+	defer func() {
+		metricFindFetchDuration.WithLabelValues(rw.cfg.Backend, fetchTypeScan).Observe(time.Since(start).Seconds())
+	}()
+
+	iter, err := encoding.NewBackendIterator(tenantID, meta.BlockID, searchScanChunkSizeBytes, &countingReader{r: rw.r, metrics: metrics})
+	if err != nil {
+		return nil, err
+	}
+
+	scanQuery := *q
+	if q.Limit > 0 {
+		scanQuery.Limit = q.Limit - found
+	}
+	return encoding.SearchObjects(encoding.NewDecodingIterator(iter, meta.Encoding), &scanQuery)
+}
+
+// countingReader counts the index and object reads of a backend iterator.
+type countingReader struct {
+	r       encoding.Reader
+	metrics FindMetrics
+}
+
+func (c *countingReader) Index(ctx context.Context, blockID uuid.UUID, tenantID string) ([]byte, error) {
+	index, err := c.r.Index(ctx, blockID, tenantID)
+	c.metrics.IndexReads.Inc()
+	c.metrics.IndexBytesRead.Add(int32(len(index)))
+	return index, err
+}
+
+func (c *countingReader) Object(ctx context.Context, blockID uuid.UUID, tenantID string, start uint64, buffer []byte) error {
+	err := c.r.Object(ctx, blockID, tenantID, start, buffer)
+	c.metrics.BlockReads.Inc()
+	c.metrics.BlockBytesRead.Add(int32(len(buffer)))
+	return err
+}
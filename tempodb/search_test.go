@@ -0,0 +1,130 @@
+package tempodb
+
+import (
+	"context"
+	"encoding/hex"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/tempo/tempodb/backend/local"
+	"github.com/grafana/tempo/tempodb/encoding"
+	"github.com/grafana/tempo/tempodb/wal"
+)
+
+// mockSearcher tags every object with its content
+type mockSearcher struct {
+	mockSharder
+}
+
+func (m *mockSearcher) SearchEntry(obj []byte) (*encoding.SearchEntry, error) {
+	return &encoding.SearchEntry{
+		Tags:              []string{encoding.SearchTag("obj", hex.EncodeToString(obj)), encoding.SearchTag("all", "true")},
+		StartTimeUnixNano: 1,
+		EndTimeUnixNano:   uint64(time.Second),
+	}, nil
+}
+
+// mockMatcher matches objects by their content like mockSearcher tags them
+type mockMatcher struct{}
+
+func (m *mockMatcher) MatchObject(id encoding.ID, obj []byte, q *encoding.SearchQuery) (*encoding.SearchTrace, error) {
+	if v, ok := q.Tags["obj"]; ok && v != hex.EncodeToString(obj) {
+		return nil, nil
+	}
+	return &encoding.SearchTrace{TraceID: append(encoding.ID(nil), id...)}, nil
+}
+
+func TestSearch(t *testing.T) {
+	tempDir, err := ioutil.TempDir("/tmp", "")
+	defer os.RemoveAll(tempDir)
+	require.NoError(t, err)
+
+	r, w, _, err := New(&Config{
+		Backend: "local",
+		Local: &local.Config{
+			Path: path.Join(tempDir, "traces"),
+		},
+		WAL: &wal.Config{
+			Filepath:        path.Join(tempDir, "wal"),
+			IndexDownsample: 17,
+			BloomFP:         .01,
+		},
+		BlocklistPoll: 0,
+	}, log.NewNopLogger())
+	require.NoError(t, err)
+
+	// one block with search data and one without
+	var ids [][]byte
+	for _, combiner := range []encoding.ObjectCombiner{&mockSearcher{}, &mockSharder{}} {
+		head, err := w.WAL().NewBlock(uuid.New(), testTenantID)
+		require.NoError(t, err)
+
+		for i := 0; i < 10; i++ {
+			id := make([]byte, 16)
+			rand.Read(id)
+			require.NoError(t, head.Write(id, id))
+			ids = append(ids, id)
+		}
+
+		complete, err := head.Complete(w.WAL(), combiner)
+		require.NoError(t, err)
+		require.NoError(t, w.WriteBlock(context.Background(), complete))
+	}
+
+	r.(*readerWriter).pollBlocklist()
+
+	found, metrics, err := r.Search(context.Background(), testTenantID, &encoding.SearchQuery{
+		Tags: map[string]string{"obj": hex.EncodeToString(ids[3]), "all": "true"},
+	})
+	require.NoError(t, err)
+	require.Len(t, found, 1)
+	assert.Equal(t, encoding.ID(ids[3]), found[0].TraceID)
+	assert.NotZero(t, metrics.IndexBytesRead.Load())
+	assert.Zero(t, metrics.BlockBytesRead.Load())
+
+	found, _, err = r.Search(context.Background(), testTenantID, &encoding.SearchQuery{
+		Tags: map[string]string{"obj": hex.EncodeToString(ids[13])},
+	})
+	require.NoError(t, err)
+	assert.Empty(t, found)
+
+	found, _, err = r.Search(context.Background(), testTenantID, &encoding.SearchQuery{
+		Tags:  map[string]string{"all": "true"},
+		Limit: 4,
+	})
+	require.NoError(t, err)
+	assert.Len(t, found, 4)
+
+	found, _, err = r.Search(context.Background(), testTenantID, &encoding.SearchQuery{
+		Tags:        map[string]string{"all": "true"},
+		MinDuration: 2 * time.Second,
+	})
+	require.NoError(t, err)
+	assert.Empty(t, found)
+
+	// the block without search data is scanned with a matcher
+	found, metrics, err = r.Search(context.Background(), testTenantID, &encoding.SearchQuery{
+		Tags:    map[string]string{"obj": hex.EncodeToString(ids[13])},
+		Matcher: &mockMatcher{},
+	})
+	require.NoError(t, err)
+	require.Len(t, found, 1)
+	assert.Equal(t, encoding.ID(ids[13]), found[0].TraceID)
+	assert.NotZero(t, metrics.BlockBytesRead.Load())
+
+	found, _, err = r.Search(context.Background(), testTenantID, &encoding.SearchQuery{
+		Matcher: &mockMatcher{},
+		Limit:   15,
+	})
+	require.NoError(t, err)
+	assert.Len(t, found, 15)
+}
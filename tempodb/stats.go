@@ -0,0 +1,94 @@
+package tempodb
+
+import (
+	"sort"
+
+	"github.com/grafana/tempo/tempodb/encoding"
+)
+
+const blockStatsDateFormat = "2006-01-02"
+
+// BlockStats aggregates the metas of a tenant's blocks.  Only sizes recorded in block metas are used so nothing is
+// read from the backend.
+type BlockStats struct {
+	Blocks       int    `json:"blocks"`
+	TotalObjects int    `json:"totalObjects"`
+	TotalBytes   uint64 `json:"totalBytes"`
+	// UnsizedBlocks were written before block sizes were recorded and don't count toward any of the bytes.
+	UnsizedBlocks int               `json:"unsizedBlocks"`
+	Levels        []BlockLevelStats `json:"levels"`
+	Days          []BlockDayStats   `json:"days"`
+}
+
+// BlockLevelStats describes the blocks at one compaction level.
+type BlockLevelStats struct {
+	CompactionLevel uint8  `json:"compactionLevel"`
+	Blocks          int    `json:"blocks"`
+	TotalObjects    int    `json:"totalObjects"`
+	TotalBytes      uint64 `json:"totalBytes"`
+}
+
+// BlockDayStats compares the bytes flushed by the ingesters with the bytes stored after compaction for the blocks
+// starting on a UTC date.  The ingested bytes include every replica written by the ingesters.
+type BlockDayStats struct {
+	Date          string `json:"date"`
+	IngestedBytes uint64 `json:"ingestedBytes"`
+	StoredBytes   uint64 `json:"storedBytes"`
+}
+
+// BlockStats returns statistics of the tenant's blocklist as of the last poll.
+func (rw *readerWriter) BlockStats(tenantID string) *BlockStats {
+	return newBlockStats(rw.blocklist(tenantID))
+}
+
+func newBlockStats(metas []*encoding.BlockMeta) *BlockStats {
+	stats := &BlockStats{
+		Levels: []BlockLevelStats{},
+		Days:   []BlockDayStats{},
+	}
+	levels := map[uint8]*BlockLevelStats{}
+	days := map[string]*BlockDayStats{}
+
+	for _, meta := range metas {
+		stats.Blocks++
+		stats.TotalObjects += meta.TotalObjects
+		stats.TotalBytes += meta.Size
+		if meta.Size == 0 {
+			stats.UnsizedBlocks++
+		}
+
+		level, ok := levels[meta.CompactionLevel]
+		if !ok {
+			level = &BlockLevelStats{CompactionLevel: meta.CompactionLevel}
+			levels[meta.CompactionLevel] = level
+		}
+		level.Blocks++
+		level.TotalObjects += meta.TotalObjects
+		level.TotalBytes += meta.Size
+
+		date := meta.StartTime.UTC().Format(blockStatsDateFormat)
+		day, ok := days[date]
+		if !ok {
+			day = &BlockDayStats{Date: date}
+			days[date] = day
+		}
+		day.IngestedBytes += meta.IngestedSize
+		day.StoredBytes += meta.Size
+	}
+
+	for _, level := range levels {
+		stats.Levels = append(stats.Levels, *level)
+	}
+	sort.Slice(stats.Levels, func(i, j int) bool {
+		return stats.Levels[i].CompactionLevel < stats.Levels[j].CompactionLevel
+	})
+
+	for _, day := range days {
+		stats.Days = append(stats.Days, *day)
+	}
+	sort.Slice(stats.Days, func(i, j int) bool {
+		return stats.Days[i].Date < stats.Days[j].Date
+	})
+
+	return stats
+}
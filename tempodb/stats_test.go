@@ -0,0 +1,127 @@
+package tempodb
+
+import (
+	"context"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/golang/protobuf/proto"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/tempo/pkg/util/test"
+	"github.com/grafana/tempo/tempodb/backend/local"
+	"github.com/grafana/tempo/tempodb/encoding"
+	"github.com/grafana/tempo/tempodb/pool"
+	"github.com/grafana/tempo/tempodb/wal"
+)
+
+func TestBlockStats(t *testing.T) {
+	tempDir, err := ioutil.TempDir("/tmp", "")
+	defer os.RemoveAll(tempDir)
+	require.NoError(t, err, "unexpected error creating temp dir")
+
+	r, w, c, err := New(&Config{
+		Backend: "local",
+		Pool: &pool.Config{
+			MaxWorkers: 10,
+			QueueDepth: 100,
+		},
+		Local: &local.Config{
+			Path: path.Join(tempDir, "traces"),
+		},
+		WAL: &wal.Config{
+			Filepath:        path.Join(tempDir, "wal"),
+			IndexDownsample: 11,
+			BloomFP:         .01,
+		},
+		BlocklistPoll: 0,
+	}, log.NewNopLogger())
+	require.NoError(t, err)
+
+	c.EnableCompaction(&CompactorConfig{
+		ChunkSizeBytes:          10,
+		MaxCompactionRange:      24 * time.Hour,
+		BlockRetention:          0,
+		CompactedBlockRetention: 0,
+	}, &mockSharder{}, &mockOverrides{})
+
+	for i := 0; i < inputBlocks; i++ {
+		head, err := w.WAL().NewBlock(uuid.New(), testTenantID)
+		require.NoError(t, err)
+
+		for j := 0; j < 10; j++ {
+			id := make([]byte, 16)
+			_, err = rand.Read(id)
+			require.NoError(t, err)
+
+			bReq, err := proto.Marshal(test.MakeRequest(10, id))
+			require.NoError(t, err)
+			require.NoError(t, head.Write(id, bReq))
+		}
+
+		complete, err := head.Complete(w.WAL(), &mockSharder{})
+		require.NoError(t, err)
+		require.NoError(t, w.WriteBlock(context.Background(), complete))
+	}
+
+	rw := r.(*readerWriter)
+	rw.pollBlocklist()
+
+	stats := r.BlockStats(testTenantID)
+	assert.Equal(t, inputBlocks, stats.Blocks)
+	assert.Equal(t, inputBlocks*10, stats.TotalObjects)
+	assert.Zero(t, stats.UnsizedBlocks)
+	assert.NotZero(t, stats.TotalBytes)
+	require.Len(t, stats.Levels, 1)
+	assert.Equal(t, BlockLevelStats{CompactionLevel: 0, Blocks: inputBlocks, TotalObjects: inputBlocks * 10, TotalBytes: stats.TotalBytes}, stats.Levels[0])
+	require.Len(t, stats.Days, 1)
+	assert.Equal(t, stats.TotalBytes, stats.Days[0].IngestedBytes)
+	assert.Equal(t, stats.TotalBytes, stats.Days[0].StoredBytes)
+	ingested := stats.TotalBytes
+
+	// compaction keeps the ingested bytes of its inputs
+	require.NoError(t, rw.compact(rw.blocklist(testTenantID), testTenantID))
+	rw.pollBlocklist()
+
+	stats = r.BlockStats(testTenantID)
+	assert.Equal(t, outputBlocks, stats.Blocks)
+	assert.Zero(t, stats.UnsizedBlocks)
+	require.Len(t, stats.Levels, 1)
+	assert.Equal(t, uint8(1), stats.Levels[0].CompactionLevel)
+	require.Len(t, stats.Days, 1)
+	assert.Equal(t, ingested, stats.Days[0].IngestedBytes)
+	assert.Equal(t, stats.TotalBytes, stats.Days[0].StoredBytes)
+}
+
+func TestNewBlockStats(t *testing.T) {
+	day1 := time.Date(2021, 1, 1, 23, 0, 0, 0, time.UTC)
+	day2 := day1.Add(2 * time.Hour)
+
+	stats := newBlockStats([]*encoding.BlockMeta{
+		{CompactionLevel: 2, StartTime: day1, TotalObjects: 10, Size: 100, IngestedSize: 300},
+		{CompactionLevel: 0, StartTime: day2, TotalObjects: 1, Size: 20, IngestedSize: 20},
+		{CompactionLevel: 0, StartTime: day2, TotalObjects: 2},
+	})
+
+	assert.Equal(t, &BlockStats{
+		Blocks:        3,
+		TotalObjects:  13,
+		TotalBytes:    120,
+		UnsizedBlocks: 1,
+		Levels: []BlockLevelStats{
+			{CompactionLevel: 0, Blocks: 2, TotalObjects: 3, TotalBytes: 20},
+			{CompactionLevel: 2, Blocks: 1, TotalObjects: 10, TotalBytes: 100},
+		},
+		Days: []BlockDayStats{
+			{Date: "2021-01-01", IngestedBytes: 300, StoredBytes: 100},
+			{Date: "2021-01-02", IngestedBytes: 20, StoredBytes: 20},
+		},
+	}, stats)
+}
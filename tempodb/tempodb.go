@@ -5,7 +5,9 @@ import (
 	"context"
 	"encoding/hex"
 	"fmt"
+	"path"
 	"sort"
+	"strings"
 	"sync"
 	"time"
 
@@ -21,11 +23,14 @@ import (
 	"github.com/opentracing/opentracing-go"
 	ot_log "github.com/opentracing/opentracing-go/log"
 
+	"github.com/grafana/tempo/pkg/tenantmetrics"
 	"github.com/grafana/tempo/tempodb/backend"
+	"github.com/grafana/tempo/tempodb/backend/azure"
+	"github.com/grafana/tempo/tempodb/backend/cache"
 	"github.com/grafana/tempo/tempodb/backend/diskcache"
 	"github.com/grafana/tempo/tempodb/backend/gcs"
+	"github.com/grafana/tempo/tempodb/backend/inmemory"
 	"github.com/grafana/tempo/tempodb/backend/local"
-	"github.com/grafana/tempo/tempodb/backend/memcached"
 	"github.com/grafana/tempo/tempodb/backend/s3"
 	"github.com/grafana/tempo/tempodb/encoding"
 	"github.com/grafana/tempo/tempodb/encoding/bloom"
@@ -34,7 +39,7 @@ import (
 )
 
 var (
-	metricBlocklistErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+	metricBlocklistErrors = tenantmetrics.NewCounterVec(prometheus.CounterOpts{
 		Namespace: "tempodb",
 		Name:      "blocklist_poll_errors_total",
 		Help:      "Total number of times an error occurred while polling the blocklist.",
@@ -45,11 +50,56 @@ var (
 		Help:      "Records the amount of time to poll and update the blocklist.",
 		Buckets:   prometheus.ExponentialBuckets(.25, 2, 6),
 	})
-	metricBlocklistLength = promauto.NewGaugeVec(prometheus.GaugeOpts{
+	metricBlocklistLength = tenantmetrics.NewGaugeVec(prometheus.GaugeOpts{
 		Namespace: "tempodb",
 		Name:      "blocklist_length",
 		Help:      "Total number of blocks per tenant.",
 	}, []string{"tenant"})
+	metricFindBlocksSkippedByIDRange = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: "tempodb",
+		Name:      "find_blocks_skipped_by_id_range_total",
+		Help:      "Total number of blocks skipped during a find because their trace id range did not cover the requested id.",
+	})
+	metricBlocklistLabelObjects = tenantmetrics.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "tempodb",
+		Name:      "blocklist_label_objects",
+		Help:      "Total number of objects in blocks per tenant and cost attribution label.",
+	}, []string{"tenant", "label", "value"})
+	metricBlocklistUnreadable = tenantmetrics.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "tempodb",
+		Name:      "blocklist_unsupported_version_total",
+		Help:      "Total number of times a block was left out of the blocklist because its version can not be read.",
+	}, []string{"tenant", "version"})
+	metricBlocklistAdded = tenantmetrics.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "tempodb",
+		Name:      "blocklist_added_blocks_total",
+		Help:      "Total number of announced blocks added to the blocklist ahead of the next poll.",
+	}, []string{"tenant"})
+	metricBlocklistIncomplete = tenantmetrics.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "tempodb",
+		Name:      "blocklist_incomplete_blocks",
+		Help:      "Total number of blocks per tenant that are being written or whose write was abandoned.",
+	}, []string{"tenant"})
+	metricBlocklistLastPoll = tenantmetrics.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "tempodb",
+		Name:      "blocklist_last_poll_timestamp_seconds",
+		Help:      "Unix time the blocklist of a tenant was last polled without errors.  Queries and compactions use a blocklist as old as this.",
+	}, []string{"tenant"})
+	metricBlocklistCachedMetas = tenantmetrics.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "tempodb",
+		Name:      "blocklist_poll_cached_metas_total",
+		Help:      "Total number of compacted block metas reused from the previous poll instead of read from the backend.",
+	}, []string{"tenant"})
+	metricBlocklistNewBlocks = tenantmetrics.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "tempodb",
+		Name:      "blocklist_new_blocks",
+		Help:      "Number of level 0 blocks per tenant that appeared since the previous poll.",
+	}, []string{"tenant"})
+	metricBlocklistNewBlocksExceeded = tenantmetrics.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "tempodb",
+		Name:      "blocklist_new_blocks_exceeded_total",
+		Help:      "Total number of polls at which more level 0 blocks than blocklist_max_new_blocks appeared for a tenant.",
+	}, []string{"tenant"})
 	metricRetentionDuration = promauto.NewHistogram(prometheus.HistogramOpts{
 		Namespace: "tempodb",
 		Name:      "retention_duration_seconds",
@@ -71,6 +121,22 @@ var (
 		Name:      "retention_deleted_total",
 		Help:      "Total number of blocks deleted.",
 	})
+	metricFindFetchDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "tempodb",
+		Name:      "find_fetch_duration_seconds",
+		Help:      "Time spent fetching block data from the backend while searching for a trace.",
+		Buckets:   prometheus.ExponentialBuckets(0.001, 4, 8),
+	}, []string{"backend", "type"})
+)
+
+const (
+	fetchTypeBloom  = "bloom"
+	fetchTypeIndex  = "index"
+	fetchTypeObject = "object"
+	fetchTypeSearch = "search"
+	fetchTypeScan   = "scan"
+
+	probeTimeout = 30 * time.Second
 )
 
 type Writer interface {
@@ -80,11 +146,67 @@ type Writer interface {
 
 type Reader interface {
 	Find(ctx context.Context, tenantID string, id encoding.ID) ([]byte, FindMetrics, error)
+	FindInBlocks(ctx context.Context, tenantID string, id encoding.ID, include BlockFilter, workers int) ([]byte, FindMetrics, error)
+	WarmBlocks(ctx context.Context, include BlockFilter) error
+	MayContain(ctx context.Context, tenantID string, id encoding.ID) (bool, FindMetrics, error)
+	RecentObjects(ctx context.Context, tenantID string, limit int) ([]encoding.ID, [][]byte, FindMetrics, error)
+	Search(ctx context.Context, tenantID string, q *encoding.SearchQuery) ([]encoding.SearchTrace, FindMetrics, error)
+	BlockStats(tenantID string) *BlockStats
+	AddBlocks(metas []*encoding.BlockMeta)
+	NewBlocksExceeded(tenantID string) bool
 	Shutdown()
 }
 
 type Compactor interface {
-	EnableCompaction(cfg *CompactorConfig, sharder CompactorSharder)
+	EnableCompaction(cfg *CompactorConfig, sharder CompactorSharder, overrides CompactorOverrides)
+	CompactionPlan() ([]*CompactionPlan, error)
+	MarkTenantDeleted(tenantID string) error
+}
+
+// BlockFilter returns true if the block should be included in an operation.
+type BlockFilter func(meta *encoding.BlockMeta) bool
+
+// ServiceFilter accepts blocks that may contain spans of the service.  Blocks without a summary are always accepted.
+func ServiceFilter(service string) BlockFilter {
+	return func(meta *encoding.BlockMeta) bool {
+		return meta.Summary == nil || meta.Summary.MayContainService(service)
+	}
+}
+
+// TimeRangeFilter accepts blocks that may contain spans between start and end.  Blocks without a summary or whose
+// summary has no time range are always accepted.
+func TimeRangeFilter(start time.Time, end time.Time) BlockFilter {
+	return func(meta *encoding.BlockMeta) bool {
+		return meta.Summary == nil || meta.Summary.MayOverlap(start, end)
+	}
+}
+
+// AllFilters accepts blocks accepted by every filter.  nil filters are ignored and nil is returned if none are left.
+func AllFilters(filters ...BlockFilter) BlockFilter {
+	var set []BlockFilter
+	for _, f := range filters {
+		if f != nil {
+			set = append(set, f)
+		}
+	}
+	if len(set) == 0 {
+		return nil
+	}
+	return func(meta *encoding.BlockMeta) bool {
+		for _, f := range set {
+			if !f(meta) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// BlockIDRangeFilter accepts blocks whose ids fall between start and end inclusive.
+func BlockIDRangeFilter(start uuid.UUID, end uuid.UUID) BlockFilter {
+	return func(meta *encoding.BlockMeta) bool {
+		return bytes.Compare(meta.BlockID[:], start[:]) >= 0 && bytes.Compare(meta.BlockID[:], end[:]) <= 0
+	}
 }
 
 type CompactorSharder interface {
@@ -92,6 +214,23 @@ type CompactorSharder interface {
 	Owns(hash string) bool
 }
 
+// blockHashString is the hash a compactor must own to work on a single block, such as applying retention to it.  Work
+// on single blocks is spread across compactors independently of the compaction jobs.
+func blockHashString(tenantID string, blockID uuid.UUID) string {
+	return fmt.Sprintf("%v-%v", tenantID, blockID)
+}
+
+// CompactorOverrides provides per tenant compaction settings.
+type CompactorOverrides interface {
+	// BlockRetentionForTenant returns how long blocks of the tenant are kept.  0 keeps them for CompactorConfig.BlockRetention.
+	BlockRetentionForTenant(tenantID string) time.Duration
+	// CompactionWindowForTenant returns the time window across which blocks of the tenant are compacted.  0 uses
+	// CompactorConfig.MaxCompactionRange.
+	CompactionWindowForTenant(tenantID string) time.Duration
+	// CompactionDisabledForTenant returns true if blocks of the tenant must be left as they are, except for retention.
+	CompactionDisabledForTenant(tenantID string) bool
+}
+
 type FindMetrics struct {
 	BloomFilterReads     *atomic.Int32
 	BloomFilterBytesRead *atomic.Int32
@@ -99,10 +238,15 @@ type FindMetrics struct {
 	IndexBytesRead       *atomic.Int32
 	BlockReads           *atomic.Int32
 	BlockBytesRead       *atomic.Int32
+
+	// FoundBlockEnd is the end time in unix nanoseconds of the newest block the object was found in.  0 if not found.
+	FoundBlockEnd *atomic.Int64
 }
 
 type readerWriter struct {
 	r backend.Reader
+	// backendReader reads the backend without the caches of r
+	backendReader backend.Reader
 	w backend.Writer
 	c backend.Compactor
 
@@ -112,34 +256,65 @@ type readerWriter struct {
 	logger        log.Logger
 	cfg           *Config
 	blockLists    map[string][]*encoding.BlockMeta
+	blockIDRanges map[string]*idRangeIndex
 	blockListsMtx sync.Mutex
 
+	// incompleteBlocks holds when each block without a meta was first polled.  they are cleared by the orphan gc once
+	// they have been incomplete for longer than CompactorConfig.OrphanRetention
+	incompleteBlocks map[string]map[uuid.UUID]time.Time
+	// polledBlocks are the level 0 blocks of each tenant at the previous poll.  newBlocksExceeded holds the tenants that
+	// added more than BlocklistMaxNewBlocks since then
+	polledBlocks      map[string]map[uuid.UUID]struct{}
+	newBlocksExceeded map[string]bool
+	// deletingTenants are the tenants marked for deletion at the last tenant deletion run.  they aren't compacted
+	deletingTenants map[string]struct{}
+
+	negativeCache *negativeCache
+	archive       *archive
+
 	compactorCfg        *CompactorConfig
 	compactedBlockLists map[string][]*encoding.CompactedBlockMeta
 	compactorSharder    CompactorSharder
+	compactorOverrides  CompactorOverrides
+	compactionPools     *compactionPools
+	throttleDetector    backend.ThrottleDetector
+	orphanCleaner       backend.OrphanCleaner
+	compactionThrottle  *compactionThrottle
 }
 
 func New(cfg *Config, logger log.Logger) (Reader, Writer, Compactor, error) {
-	var err error
-	var r backend.Reader
-	var w backend.Writer
-	var c backend.Compactor
+	if cfg.Backend == "local" {
+		err := resolveLocalPath(cfg.Local, cfg.WAL)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+	}
 
-	switch cfg.Backend {
-	case "local":
-		r, w, c, err = local.New(cfg.Local)
-	case "gcs":
-		r, w, c, err = gcs.New(cfg.GCS)
-	case "s3":
-		r, w, c, err = s3.New(cfg.S3)
-	default:
-		err = fmt.Errorf("unknown backend %s", cfg.Backend)
+	r, w, c, err := newBackend(cfg.Backend, cfg.Local, cfg.GCS, cfg.S3, cfg.Azure, cfg.InMemory)
+	if err != nil {
+		return nil, nil, nil, err
 	}
 
+	// detected before the backend is wrapped by caches
+	backendReader := r
+	throttleDetector, _ := w.(backend.ThrottleDetector)
+	orphanCleaner, _ := c.(backend.OrphanCleaner)
+
+	archive, err := newArchive(cfg.Archive)
 	if err != nil {
 		return nil, nil, nil, err
 	}
 
+	if cfg.StartupProbe {
+		err = probe(w)
+		if err == nil && archive != nil {
+			err = probe(archive.w)
+		}
+		if err != nil {
+			return nil, nil, nil, err
+		}
+	}
+
 	if cfg.Diskcache != nil {
 		r, err = diskcache.New(r, cfg.Diskcache, logger)
 
@@ -148,8 +323,15 @@ func New(cfg *Config, logger log.Logger) (Reader, Writer, Compactor, error) {
 		}
 	}
 
+	cacheCfg := cfg.Cache
 	if cfg.Memcached != nil {
-		r, w, err = memcached.New(r, w, cfg.Memcached, logger)
+		if cacheCfg != nil {
+			return nil, nil, nil, fmt.Errorf("memcached and cache can't both be configured")
+		}
+		cacheCfg = cfg.Memcached.Config()
+	}
+	if cacheCfg != nil {
+		r, w, err = cache.New(r, w, cacheCfg, logger)
 
 		if err != nil {
 			return nil, nil, nil, err
@@ -160,11 +342,20 @@ func New(cfg *Config, logger log.Logger) (Reader, Writer, Compactor, error) {
 		c:                   c,
 		compactedBlockLists: make(map[string][]*encoding.CompactedBlockMeta),
 		r:                   r,
+		backendReader:       backendReader,
 		w:                   w,
 		cfg:                 cfg,
 		logger:              logger,
 		pool:                pool.NewPool(cfg.Pool),
 		blockLists:          make(map[string][]*encoding.BlockMeta),
+		blockIDRanges:       make(map[string]*idRangeIndex),
+		incompleteBlocks:    make(map[string]map[uuid.UUID]time.Time),
+		polledBlocks:        make(map[string]map[uuid.UUID]struct{}),
+		newBlocksExceeded:   make(map[string]bool),
+		negativeCache:       newNegativeCache(cfg.NegativeCacheSize),
+		archive:             archive,
+		throttleDetector:    throttleDetector,
+		orphanCleaner:       orphanCleaner,
 	}
 
 	rw.wal, err = wal.New(rw.cfg.WAL)
@@ -177,6 +368,73 @@ func New(cfg *Config, logger log.Logger) (Reader, Writer, Compactor, error) {
 	return rw, rw, rw, nil
 }
 
+func newBackend(name string, localCfg *local.Config, gcsCfg *gcs.Config, s3Cfg *s3.Config, azureCfg *azure.Config, inmemoryCfg *inmemory.Config) (backend.Reader, backend.Writer, backend.Compactor, error) {
+	switch name {
+	case "local":
+		return local.New(localCfg)
+	case "gcs":
+		return gcs.New(gcsCfg)
+	case "s3":
+		return s3.New(s3Cfg)
+	case "azure":
+		return azure.New(azureCfg)
+	case "inmemory":
+		return inmemory.New(inmemoryCfg)
+	}
+
+	return nil, nil, nil, fmt.Errorf("unknown backend %s", name)
+}
+
+// resolveLocalPath defaults the path of the local backend to the blocks directory of the WAL path, so a single node
+// keeps all of its data on one volume.  The blocks can't be within the WAL's completed blocks, which are removed on
+// startup.
+func resolveLocalPath(localCfg *local.Config, walCfg *wal.Config) error {
+	if localCfg == nil || walCfg == nil {
+		return nil
+	}
+	if localCfg.Path == "" {
+		localCfg.Path = path.Join(walCfg.Filepath, wal.BlocksDir)
+		return nil
+	}
+
+	if pathWithin(localCfg.Path, walCfg.CompletedPath()) {
+		return fmt.Errorf("local backend path %s is within the completed blocks of the wal, which are removed on startup", localCfg.Path)
+	}
+	return nil
+}
+
+// pathWithin returns true if p is dir or below it.
+func pathWithin(p string, dir string) bool {
+	p, dir = path.Clean(p), path.Clean(dir)
+	return p == dir || strings.HasPrefix(p, dir+"/")
+}
+
+// NewObjectWriter returns a writer of arbitrary objects to a backend configured like the backend of the blocks, for data
+// kept apart from the blocks such as access logs.
+func NewObjectWriter(name string, localCfg *local.Config, gcsCfg *gcs.Config, s3Cfg *s3.Config, azureCfg *azure.Config) (backend.ObjectWriter, error) {
+	_, w, _, err := newBackend(name, localCfg, gcsCfg, s3Cfg, azureCfg, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	ow, ok := w.(backend.ObjectWriter)
+	if !ok {
+		return nil, fmt.Errorf("backend %s can't write objects", name)
+	}
+	return ow, nil
+}
+
+func probe(w backend.Writer) error {
+	p, ok := w.(backend.Prober)
+	if !ok {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), probeTimeout)
+	defer cancel()
+	return backend.Probe(ctx, p)
+}
+
 func (rw *readerWriter) WriteBlock(ctx context.Context, c wal.WriteableBlock) error {
 	records := c.Records()
 	indexBytes, err := encoding.MarshalRecords(records)
@@ -190,11 +448,32 @@ func (rw *readerWriter) WriteBlock(ctx context.Context, c wal.WriteableBlock) er
 	}
 
 	meta := c.BlockMeta()
+	err = rw.writeSearch(ctx, meta, c)
+	if err != nil {
+		return err
+	}
+
 	err = rw.w.Write(ctx, meta, bloomBuffers, indexBytes, c.ObjectFilePath())
 	if err != nil {
 		return err
 	}
 
+	// a block that fails verification isn't marked flushed, so the ingester keeps it and rewrites it on retry
+	if rw.cfg.VerifyFlushedBlocks {
+		err = rw.verifyFlushedBlock(ctx, c, records)
+		if err != nil {
+			return err
+		}
+	}
+
+	// the block is only marked flushed once it is in both backends.  a failed archive write rewrites the primary on retry
+	if rw.archive != nil {
+		err = rw.archive.write(ctx, meta, bloomBuffers, indexBytes, c.ObjectFilePath())
+		if err != nil {
+			return err
+		}
+	}
+
 	err = c.Flushed()
 	if err != nil {
 		return err
@@ -216,6 +495,11 @@ func (rw *readerWriter) WriteBlockMeta(ctx context.Context, tracker backend.Appe
 	}
 
 	meta := c.BlockMeta()
+	err = rw.writeSearch(ctx, meta, c)
+	if err != nil {
+		return err
+	}
+
 	err = rw.w.WriteBlockMeta(ctx, tracker, meta, bloomBuffers, indexBytes)
 	if err != nil {
 		return err
@@ -224,11 +508,34 @@ func (rw *readerWriter) WriteBlockMeta(ctx context.Context, tracker backend.Appe
 	return nil
 }
 
+// writeSearch writes the search section of a searchable block.  It is written ahead of the meta so a block in the
+// blocklist marked searchable always has one.
+func (rw *readerWriter) writeSearch(ctx context.Context, meta *encoding.BlockMeta, c wal.WriteableBlock) error {
+	search := c.SearchData()
+	if !meta.Searchable || search == nil {
+		return nil
+	}
+
+	searchBytes, err := encoding.MarshalSearchData(search)
+	if err != nil {
+		return err
+	}
+
+	return rw.w.WriteSearch(ctx, meta, searchBytes)
+}
+
 func (rw *readerWriter) WAL() *wal.WAL {
 	return rw.wal
 }
 
 func (rw *readerWriter) Find(ctx context.Context, tenantID string, id encoding.ID) ([]byte, FindMetrics, error) {
+	return rw.FindInBlocks(ctx, tenantID, id, nil, 0)
+}
+
+// FindInBlocks searches only the blocks accepted by include.  A nil filter searches every block.  At most workers blocks
+// are searched at once, newest first, and no more are started once the trace is found.  0 searches as many at once as
+// the pool has workers.
+func (rw *readerWriter) FindInBlocks(ctx context.Context, tenantID string, id encoding.ID, include BlockFilter, workers int) ([]byte, FindMetrics, error) {
 	metrics := FindMetrics{
 		BloomFilterReads:     atomic.NewInt32(0),
 		BloomFilterBytesRead: atomic.NewInt32(0),
@@ -236,51 +543,53 @@ func (rw *readerWriter) Find(ctx context.Context, tenantID string, id encoding.I
 		IndexBytesRead:       atomic.NewInt32(0),
 		BlockReads:           atomic.NewInt32(0),
 		BlockBytesRead:       atomic.NewInt32(0),
+		FoundBlockEnd:        atomic.NewInt64(0),
 	}
 
 	// tracing instrumentation
-	logger := util.WithContext(ctx, util.Logger)
 	span, derivedCtx := opentracing.StartSpanFromContext(ctx, "store.Find")
 	defer span.Finish()
 
-	rw.blockListsMtx.Lock()
-	blocklist, found := rw.blockLists[tenantID]
-	copiedBlocklist := make([]interface{}, 0, len(blocklist))
-	for _, b := range blocklist {
-		// if in range copy
-		if bytes.Compare(id, b.MinID) != -1 && bytes.Compare(id, b.MaxID) != 1 {
-			copiedBlocklist = append(copiedBlocklist, b)
-		}
+	var foundBytes []byte
+	var err error
+	copiedBlocklist, found := rw.blocksForID(tenantID, id, include)
+	if found {
+		foundBytes, err = rw.findInBlocks(derivedCtx, rw.r, rw.cfg.Backend, copiedBlocklist, tenantID, id, workers, metrics)
 	}
-	rw.blockListsMtx.Unlock()
 
-	if !found {
-		return nil, metrics, nil
+	// traces past the primary retention or in blocks not yet polled may still be archived
+	if foundBytes == nil && err == nil && rw.archive != nil && rw.archive.cfg.QueryFallback {
+		foundBytes, err = rw.findInArchive(derivedCtx, tenantID, id, include, workers, metrics)
 	}
 
-	foundBytes, err := rw.pool.RunJobs(derivedCtx, copiedBlocklist, func(ctx context.Context, payload interface{}) ([]byte, error) {
+	return foundBytes, metrics, err
+}
+
+// findInBlocks searches the passed blocks of r for id, at most workers at once, and returns the first object found.  ctx
+// must carry the span of the find.
+func (rw *readerWriter) findInBlocks(ctx context.Context, r backend.Reader, backendName string, blocks []interface{}, tenantID string, id encoding.ID, workers int, metrics FindMetrics) ([]byte, error) {
+	logger := util.WithContext(ctx, util.Logger)
+	span := opentracing.SpanFromContext(ctx)
+
+	return rw.pool.RunJobsLimited(ctx, blocks, workers, func(ctx context.Context, payload interface{}) ([]byte, error) {
 		meta := payload.(*encoding.BlockMeta)
 
-		shardKey := bloom.ShardKeyForTraceID(id)
+		shardKey := bloom.ShardKeyForTraceID(id, meta.BloomShards())
+		negativeKey := negativeCacheKey(meta.BlockID, shardKey, id)
 		level.Debug(logger).Log("msg", "fetching bloom", "shardKey", shardKey)
-		bloomBytes, err := rw.r.Bloom(ctx, meta.BlockID, tenantID, shardKey)
-		if err != nil {
-			return nil, fmt.Errorf("error retrieving bloom %v", err)
-		}
-
-		filter := &willf_bloom.BloomFilter{}
-		_, err = filter.ReadFrom(bytes.NewReader(bloomBytes))
+		mayContain, err := rw.testBloom(ctx, r, backendName, meta, tenantID, id, metrics)
 		if err != nil {
-			return nil, fmt.Errorf("error parsing bloom %v", err)
+			return nil, err
 		}
-
-		metrics.BloomFilterReads.Inc()
-		metrics.BloomFilterBytesRead.Add(int32(len(bloomBytes)))
-		if !filter.Test(id) {
+		if !mayContain {
 			return nil, nil
 		}
 
-		indexBytes, err := rw.r.Index(ctx, meta.BlockID, tenantID)
+		start := time.Now()
+		fetchSpan, fetchCtx := startFetchSpan(ctx, fetchTypeIndex, meta)
+		indexBytes, err := r.Index(fetchCtx, meta.BlockID, tenantID)
+		fetchSpan.Finish()
+		metricFindFetchDuration.WithLabelValues(backendName, fetchTypeIndex).Observe(time.Since(start).Seconds())
 		metrics.IndexReads.Inc()
 		metrics.IndexBytesRead.Add(int32(len(indexBytes)))
 		if err != nil {
@@ -293,11 +602,16 @@ func (rw *readerWriter) Find(ctx context.Context, tenantID string, id encoding.I
 		}
 
 		if record == nil {
+			rw.negativeCache.add(negativeKey)
 			return nil, nil
 		}
 
 		objectBytes := make([]byte, record.Length)
-		err = rw.r.Object(ctx, meta.BlockID, tenantID, record.Start, objectBytes)
+		start = time.Now()
+		fetchSpan, fetchCtx = startFetchSpan(ctx, fetchTypeObject, meta)
+		err = r.Object(fetchCtx, meta.BlockID, tenantID, record.Start, objectBytes)
+		fetchSpan.Finish()
+		metricFindFetchDuration.WithLabelValues(backendName, fetchTypeObject).Observe(time.Since(start).Seconds())
 		metrics.BlockReads.Inc()
 		metrics.BlockBytesRead.Add(int32(len(objectBytes)))
 		if err != nil {
@@ -315,7 +629,10 @@ func (rw *readerWriter) Find(ctx context.Context, tenantID string, id encoding.I
 				return nil, err
 			}
 			if bytes.Equal(iterID, id) {
-				foundObject = iterObject
+				foundObject, err = encoding.DecodeObject(meta.Encoding, iterObject)
+				if err != nil {
+					return nil, fmt.Errorf("error decoding object %v", err)
+				}
 				break
 			}
 		}
@@ -323,11 +640,149 @@ func (rw *readerWriter) Find(ctx context.Context, tenantID string, id encoding.I
 		span.LogFields(ot_log.String("msg", "searching for trace in block"), ot_log.String("traceID", hex.EncodeToString(id)), ot_log.String("block", meta.BlockID.String()), ot_log.Bool("found", foundObject != nil))
 		if foundObject != nil {
 			span.SetTag("object bytes", len(foundObject))
+			recordFoundBlockEnd(metrics, meta)
+		} else {
+			rw.negativeCache.add(negativeKey)
 		}
 		return foundObject, nil
 	})
+}
 
-	return foundBytes, metrics, err
+// recordFoundBlockEnd keeps the latest end time of the blocks an object was found in.
+func recordFoundBlockEnd(metrics FindMetrics, meta *encoding.BlockMeta) {
+	end := meta.EndTime.UnixNano()
+	for {
+		current := metrics.FoundBlockEnd.Load()
+		if current >= end || metrics.FoundBlockEnd.CAS(current, end) {
+			return
+		}
+	}
+}
+
+// MayContain returns true if a bloom filter of any block for the tenant tests positive for the passed id.  It never
+// reads an index or object so it is much cheaper than Find, but like any bloom check it may return false positives.
+func (rw *readerWriter) MayContain(ctx context.Context, tenantID string, id encoding.ID) (bool, FindMetrics, error) {
+	metrics := FindMetrics{
+		BloomFilterReads:     atomic.NewInt32(0),
+		BloomFilterBytesRead: atomic.NewInt32(0),
+		IndexReads:           atomic.NewInt32(0),
+		IndexBytesRead:       atomic.NewInt32(0),
+		BlockReads:           atomic.NewInt32(0),
+		BlockBytesRead:       atomic.NewInt32(0),
+		FoundBlockEnd:        atomic.NewInt64(0),
+	}
+
+	span, derivedCtx := opentracing.StartSpanFromContext(ctx, "store.MayContain")
+	defer span.Finish()
+
+	copiedBlocklist, found := rw.blocksForID(tenantID, id, nil)
+	if !found {
+		return false, metrics, nil
+	}
+
+	foundBytes, err := rw.pool.RunJobs(derivedCtx, copiedBlocklist, func(ctx context.Context, payload interface{}) ([]byte, error) {
+		mayContain, err := rw.testBloom(ctx, rw.r, rw.cfg.Backend, payload.(*encoding.BlockMeta), tenantID, id, metrics)
+		if err != nil || !mayContain {
+			return nil, err
+		}
+		// the pool stops at the first non-nil result
+		return []byte{1}, nil
+	})
+
+	return foundBytes != nil, metrics, err
+}
+
+// WarmBlocks reads the index and bloom filters of every block accepted by include.  When a cache is configured this
+// keeps them resident so later finds don't go to the backend.  Failures are logged and the remaining blocks are still
+// warmed.
+func (rw *readerWriter) WarmBlocks(ctx context.Context, include BlockFilter) error {
+	for _, t := range rw.blocklistTenants() {
+		tenantID := t.(string)
+
+		for _, meta := range rw.blocklist(tenantID) {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if include != nil && !include(meta) {
+				continue
+			}
+
+			_, err := rw.r.Index(ctx, meta.BlockID, tenantID)
+			if err != nil {
+				level.Error(rw.logger).Log("msg", "failed to warm index", "tenantID", tenantID, "blockID", meta.BlockID, "err", err)
+				continue
+			}
+
+			for shard := 0; shard < meta.BloomShards(); shard++ {
+				_, err = rw.r.Bloom(ctx, meta.BlockID, tenantID, shard)
+				if err != nil {
+					level.Error(rw.logger).Log("msg", "failed to warm bloom", "tenantID", tenantID, "blockID", meta.BlockID, "shard", shard, "err", err)
+					break
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// blocksForID returns the tenant's blocks whose id range covers the passed id and are accepted by include, newest first.  Most lookups are for
+// recent traces and the pool stops starting new jobs as soon as one finds the trace.
+func (rw *readerWriter) blocksForID(tenantID string, id encoding.ID, include BlockFilter) ([]interface{}, bool) {
+	rw.blockListsMtx.Lock()
+	_, found := rw.blockLists[tenantID]
+	var inRange []*encoding.BlockMeta
+	if index, ok := rw.blockIDRanges[tenantID]; ok {
+		inRange = index.find(id)
+		metricFindBlocksSkippedByIDRange.Add(float64(index.len() - len(inRange)))
+	}
+	rw.blockListsMtx.Unlock()
+
+	sort.Slice(inRange, func(i, j int) bool {
+		return inRange[i].EndTime.After(inRange[j].EndTime)
+	})
+	copiedBlocklist := make([]interface{}, 0, len(inRange))
+	for _, b := range inRange {
+		if include != nil && !include(b) {
+			continue
+		}
+		copiedBlocklist = append(copiedBlocklist, b)
+	}
+
+	return copiedBlocklist, found
+}
+
+// testBloom fetches the bloom shard for id from the block in r and tests it.  Negative results are cached.
+func (rw *readerWriter) testBloom(ctx context.Context, r backend.Reader, backendName string, meta *encoding.BlockMeta, tenantID string, id encoding.ID, metrics FindMetrics) (bool, error) {
+	shardKey := bloom.ShardKeyForTraceID(id, meta.BloomShards())
+	negativeKey := negativeCacheKey(meta.BlockID, shardKey, id)
+	if rw.negativeCache.has(negativeKey) {
+		return false, nil
+	}
+
+	start := time.Now()
+	fetchSpan, fetchCtx := startFetchSpan(ctx, fetchTypeBloom, meta)
+	bloomBytes, err := r.Bloom(fetchCtx, meta.BlockID, tenantID, shardKey)
+	fetchSpan.Finish()
+	metricFindFetchDuration.WithLabelValues(backendName, fetchTypeBloom).Observe(time.Since(start).Seconds())
+	if err != nil {
+		return false, fmt.Errorf("error retrieving bloom %v", err)
+	}
+
+	filter := &willf_bloom.BloomFilter{}
+	_, err = filter.ReadFrom(bytes.NewReader(bloomBytes))
+	if err != nil {
+		return false, fmt.Errorf("error parsing bloom %v", err)
+	}
+
+	metrics.BloomFilterReads.Inc()
+	metrics.BloomFilterBytesRead.Add(int32(len(bloomBytes)))
+	if !filter.Test(id) {
+		rw.negativeCache.add(negativeKey)
+		return false, nil
+	}
+
+	return true, nil
 }
 
 func (rw *readerWriter) Shutdown() {
@@ -336,9 +791,10 @@ func (rw *readerWriter) Shutdown() {
 	rw.r.Shutdown()
 }
 
-func (rw *readerWriter) EnableCompaction(cfg *CompactorConfig, c CompactorSharder) {
+func (rw *readerWriter) EnableCompaction(cfg *CompactorConfig, c CompactorSharder, overrides CompactorOverrides) {
 	rw.compactorCfg = cfg
 	rw.compactorSharder = c
+	rw.compactorOverrides = overrides
 
 	if rw.cfg.BlocklistPoll == 0 {
 		level.Info(rw.logger).Log("msg", "maintenance cycle unset.  compaction and retention disabled.")
@@ -346,9 +802,24 @@ func (rw *readerWriter) EnableCompaction(cfg *CompactorConfig, c CompactorSharde
 	}
 
 	if cfg != nil {
+		maxRunning := 1
+		if cfg.Level0Concurrency > 0 || cfg.HigherLevelConcurrency > 0 {
+			rw.compactionPools = newCompactionPools(cfg.Level0Concurrency, cfg.HigherLevelConcurrency, func(job *compactionJob) {
+				rw.runCompaction(job.blockMetas, job.tenantID)
+			})
+			maxRunning = rw.compactionPools.workers()
+		}
+
+		if cfg.ThrottleBackoff > 0 && rw.throttleDetector != nil {
+			rw.compactionThrottle = newCompactionThrottle(rw.throttleDetector, maxRunning, cfg.ThrottleBackoff, cfg.ThrottleMaxBackoff, rw.logger)
+		}
+
 		level.Info(rw.logger).Log("msg", "compaction and retention enabled.")
 		go rw.compactionLoop()
 		go rw.retentionLoop()
+		if cfg.OrphanGCInterval > 0 {
+			go rw.orphanGCLoop()
+		}
 	}
 }
 
@@ -358,11 +829,18 @@ func (rw *readerWriter) maintenanceLoop() {
 		return
 	}
 
-	rw.pollBlocklist()
+	rw.pollBlocklists()
 
 	ticker := time.NewTicker(rw.cfg.BlocklistPoll)
 	for range ticker.C {
-		rw.pollBlocklist()
+		rw.pollBlocklists()
+	}
+}
+
+func (rw *readerWriter) pollBlocklists() {
+	rw.pollBlocklist()
+	if rw.archive != nil && rw.archive.cfg.QueryFallback {
+		rw.pollArchiveBlocklist()
 	}
 }
 
@@ -377,6 +855,15 @@ func (rw *readerWriter) pollBlocklist() {
 		level.Error(rw.logger).Log("msg", "error retrieving tenants while polling blocklist", "err", err)
 	}
 
+	// objects per tenant/label/value. labels come and go with overrides so the gauge is rebuilt every poll
+	labelObjects := map[[3]string]int{}
+	defer func() {
+		metricBlocklistLabelObjects.Reset()
+		for k, v := range labelObjects {
+			metricBlocklistLabelObjects.WithLabelValues(k[0], k[1], k[2]).Set(float64(v))
+		}
+	}()
+
 	for _, tenantID := range tenants {
 		blockIDs, err := rw.r.Blocks(ctx, tenantID)
 		if err != nil {
@@ -389,12 +876,32 @@ func (rw *readerWriter) pollBlocklist() {
 			interfaceSlice = append(interfaceSlice, id)
 		}
 
+		// a compacted block stays compacted until it's deleted, so its meta from the previous poll is still current.  metas
+		// of blocks that aren't compacted are read every poll since another process may have compacted them
+		rw.blockListsMtx.Lock()
+		knownCompacted := make(map[uuid.UUID]*encoding.CompactedBlockMeta, len(rw.compactedBlockLists[tenantID]))
+		for _, b := range rw.compactedBlockLists[tenantID] {
+			knownCompacted[b.BlockID] = b
+		}
+		rw.blockListsMtx.Unlock()
+
 		listMutex := sync.Mutex{}
+		cachedMetas := 0
+		pollErrors := err != nil
 		blocklist := make([]*encoding.BlockMeta, 0, len(blockIDs))
 		compactedBlocklist := make([]*encoding.CompactedBlockMeta, 0, len(blockIDs))
+		incompleteBlocklist := make([]uuid.UUID, 0)
 		_, err = rw.pool.RunJobs(ctx, interfaceSlice, func(ctx context.Context, payload interface{}) ([]byte, error) {
 			blockID := payload.(uuid.UUID)
 
+			if compactedBlockMeta, ok := knownCompacted[blockID]; ok {
+				listMutex.Lock()
+				compactedBlocklist = append(compactedBlocklist, compactedBlockMeta)
+				cachedMetas++
+				listMutex.Unlock()
+				return nil, nil
+			}
+
 			var compactedBlockMeta *encoding.CompactedBlockMeta
 			blockMeta, err := rw.r.BlockMeta(ctx, blockID, tenantID)
 			// if the normal meta doesn't exist maybe it's compacted.
@@ -403,9 +910,27 @@ func (rw *readerWriter) pollBlocklist() {
 				compactedBlockMeta, err = rw.c.CompactedBlockMeta(blockID, tenantID)
 			}
 
+			// a block without either meta is being written or its write was abandoned.  it's left out until committed
+			if err == backend.ErrMetaDoesNotExist {
+				listMutex.Lock()
+				incompleteBlocklist = append(incompleteBlocklist, blockID)
+				listMutex.Unlock()
+				return nil, nil
+			}
+
 			if err != nil {
 				metricBlocklistErrors.WithLabelValues(tenantID).Inc()
 				level.Error(rw.logger).Log("msg", "failed to retrieve block meta", "tenantID", tenantID, "blockID", blockID, "err", err)
+				listMutex.Lock()
+				pollErrors = true
+				listMutex.Unlock()
+				return nil, nil
+			}
+
+			// blocks written by a newer build are ignored until this one is upgraded
+			if blockMeta != nil && !encoding.ReadableVersion(blockMeta.Version) {
+				metricBlocklistUnreadable.WithLabelValues(tenantID, blockMeta.Version).Inc()
+				level.Warn(rw.logger).Log("msg", "skipping block with unsupported version", "tenantID", tenantID, "blockID", blockID, "version", blockMeta.Version)
 				return nil, nil
 			}
 
@@ -430,6 +955,20 @@ func (rw *readerWriter) pollBlocklist() {
 		}
 
 		metricBlocklistLength.WithLabelValues(tenantID).Set(float64(len(blocklist)))
+		metricBlocklistIncomplete.WithLabelValues(tenantID).Set(float64(len(incompleteBlocklist)))
+		metricBlocklistCachedMetas.WithLabelValues(tenantID).Add(float64(cachedMetas))
+		if !pollErrors {
+			metricBlocklistLastPoll.WithLabelValues(tenantID).Set(float64(start.Unix()))
+		}
+		for _, b := range blocklist {
+			for k, v := range b.Labels {
+				labelObjects[[3]string{tenantID, k, v}] += b.TotalObjects
+			}
+		}
+		// a blocklist missing metas would count them as new at the next poll
+		if !pollErrors {
+			rw.countNewBlocks(tenantID, blocklist)
+		}
 
 		sort.Slice(blocklist, func(i, j int) bool {
 			return blocklist[i].StartTime.Before(blocklist[j].StartTime)
@@ -440,17 +979,128 @@ func (rw *readerWriter) pollBlocklist() {
 
 		rw.blockListsMtx.Lock()
 		rw.blockLists[tenantID] = blocklist
+		rw.blockIDRanges[tenantID] = newIDRangeIndex(blocklist)
 		rw.compactedBlockLists[tenantID] = compactedBlocklist
+		incompleteBlocks := make(map[uuid.UUID]time.Time, len(incompleteBlocklist))
+		for _, id := range incompleteBlocklist {
+			firstSeen, ok := rw.incompleteBlocks[tenantID][id]
+			if !ok {
+				firstSeen = start
+			}
+			incompleteBlocks[id] = firstSeen
+		}
+		rw.incompleteBlocks[tenantID] = incompleteBlocks
 		rw.blockListsMtx.Unlock()
 	}
 }
 
+// countNewBlocks counts the level 0 blocks of the tenant that weren't in the blocklist at its previous poll.  A tenant
+// whose clients create far more blocks than usual, e.g. a block per trace, is flagged until a poll is back under the
+// limit so the ingesters can consolidate its writes.  Nothing is counted at the first poll.
+func (rw *readerWriter) countNewBlocks(tenantID string, blocklist []*encoding.BlockMeta) {
+	blocks := make(map[uuid.UUID]struct{})
+	for _, b := range blocklist {
+		if b.CompactionLevel == 0 {
+			blocks[b.BlockID] = struct{}{}
+		}
+	}
+
+	rw.blockListsMtx.Lock()
+	previous, polled := rw.polledBlocks[tenantID]
+	rw.polledBlocks[tenantID] = blocks
+	rw.blockListsMtx.Unlock()
+
+	if !polled {
+		return
+	}
+
+	newBlocks := 0
+	for id := range blocks {
+		if _, ok := previous[id]; !ok {
+			newBlocks++
+		}
+	}
+	metricBlocklistNewBlocks.WithLabelValues(tenantID).Set(float64(newBlocks))
+
+	exceeded := rw.cfg.BlocklistMaxNewBlocks > 0 && newBlocks > rw.cfg.BlocklistMaxNewBlocks
+	if exceeded {
+		metricBlocklistNewBlocksExceeded.WithLabelValues(tenantID).Inc()
+		level.Warn(rw.logger).Log("msg", "tenant created more new blocks than allowed since the previous poll", "tenantID", tenantID, "newBlocks", newBlocks, "max", rw.cfg.BlocklistMaxNewBlocks)
+	}
+
+	rw.blockListsMtx.Lock()
+	rw.newBlocksExceeded[tenantID] = exceeded
+	rw.blockListsMtx.Unlock()
+}
+
+// NewBlocksExceeded returns true if more level 0 blocks than BlocklistMaxNewBlocks appeared for the tenant at its last
+// poll.
+func (rw *readerWriter) NewBlocksExceeded(tenantID string) bool {
+	rw.blockListsMtx.Lock()
+	defer rw.blockListsMtx.Unlock()
+
+	return rw.newBlocksExceeded[tenantID]
+}
+
+// AddBlocks adds blocks announced by their writer to the blocklist so they can be found before the next poll.  Blocks
+// already in the blocklist, compacted or of an unreadable version are ignored.  The next poll replaces the blocklist
+// as usual.
+func (rw *readerWriter) AddBlocks(metas []*encoding.BlockMeta) {
+	rw.blockListsMtx.Lock()
+	defer rw.blockListsMtx.Unlock()
+
+	changed := map[string]struct{}{}
+	for _, meta := range metas {
+		if meta.TenantID == "" || !encoding.ReadableVersion(meta.Version) {
+			continue
+		}
+		if hasBlock(rw.blockLists[meta.TenantID], meta) || hasCompactedBlock(rw.compactedBlockLists[meta.TenantID], meta) {
+			continue
+		}
+
+		rw.blockLists[meta.TenantID] = append(rw.blockLists[meta.TenantID], meta)
+		changed[meta.TenantID] = struct{}{}
+		metricBlocklistAdded.WithLabelValues(meta.TenantID).Inc()
+	}
+
+	for tenantID := range changed {
+		blocklist := rw.blockLists[tenantID]
+		sort.Slice(blocklist, func(i, j int) bool {
+			return blocklist[i].StartTime.Before(blocklist[j].StartTime)
+		})
+		rw.blockIDRanges[tenantID] = newIDRangeIndex(blocklist)
+		metricBlocklistLength.WithLabelValues(tenantID).Set(float64(len(blocklist)))
+	}
+}
+
+func hasBlock(blocklist []*encoding.BlockMeta, meta *encoding.BlockMeta) bool {
+	for _, b := range blocklist {
+		if b.BlockID == meta.BlockID {
+			return true
+		}
+	}
+	return false
+}
+
+func hasCompactedBlock(blocklist []*encoding.CompactedBlockMeta, meta *encoding.BlockMeta) bool {
+	for _, b := range blocklist {
+		if b.BlockID == meta.BlockID {
+			return true
+		}
+	}
+	return false
+}
+
 // todo: pass a context/chan in to cancel this cleanly
 //  once a maintenance cycle cleanup any blocks
 func (rw *readerWriter) retentionLoop() {
 	ticker := time.NewTicker(rw.cfg.BlocklistPoll)
 	for range ticker.C {
 		rw.doRetention()
+		rw.doTenantDeletion()
+		if rw.archive != nil {
+			rw.archive.doRetention(rw.compactorSharder, rw.logger)
+		}
 	}
 }
 
@@ -464,12 +1114,20 @@ func (rw *readerWriter) doRetention() {
 
 		tenantID := payload.(string)
 
+		retention := rw.compactorCfg.BlockRetention
+		if tenantRetention := rw.compactorOverrides.BlockRetentionForTenant(tenantID); tenantRetention != 0 {
+			retention = tenantRetention
+		}
+
 		// iterate through block list.  make compacted anything that is past retention.
-		cutoff := time.Now().Add(-rw.compactorCfg.BlockRetention)
+		cutoff := time.Now().Add(-retention)
 		blocklist := rw.blocklist(tenantID)
 		for _, b := range blocklist {
+			if !rw.compactorSharder.Owns(blockHashString(tenantID, b.BlockID)) {
+				continue
+			}
 			if b.EndTime.Before(cutoff) {
-				level.Info(rw.logger).Log("msg", "marking block for deletion", "blockID", b.BlockID, "tenantID", tenantID)
+				level.Info(rw.logger).Log("msg", "marking block for deletion", "blockID", b.BlockID, "tenantID", tenantID, "retention", retention)
 				err := rw.c.MarkBlockCompacted(b.BlockID, tenantID)
 				if err != nil {
 					level.Error(rw.logger).Log("msg", "failed to mark block compacted during retention", "blockID", b.BlockID, "tenantID", tenantID, "err", err)
@@ -484,6 +1142,9 @@ func (rw *readerWriter) doRetention() {
 		cutoff = time.Now().Add(-rw.compactorCfg.CompactedBlockRetention)
 		compactedBlocklist := rw.compactedBlocklist(tenantID)
 		for _, b := range compactedBlocklist {
+			if !rw.compactorSharder.Owns(blockHashString(tenantID, b.BlockID)) {
+				continue
+			}
 			if b.CompactedTime.Before(cutoff) {
 				level.Info(rw.logger).Log("msg", "deleting block", "blockID", b.BlockID, "tenantID", tenantID)
 				err := rw.c.ClearBlock(b.BlockID, tenantID)
@@ -544,3 +1205,31 @@ func (rw *readerWriter) compactedBlocklist(tenantID string) []*encoding.Compacte
 
 	return copiedBlocklist
 }
+
+func (rw *readerWriter) incompleteBlocklist(tenantID string) map[uuid.UUID]time.Time {
+	rw.blockListsMtx.Lock()
+	defer rw.blockListsMtx.Unlock()
+
+	copied := make(map[uuid.UUID]time.Time, len(rw.incompleteBlocks[tenantID]))
+	for id, firstSeen := range rw.incompleteBlocks[tenantID] {
+		copied[id] = firstSeen
+	}
+
+	return copied
+}
+
+func (rw *readerWriter) clearIncompleteBlock(tenantID string, blockID uuid.UUID) {
+	rw.blockListsMtx.Lock()
+	defer rw.blockListsMtx.Unlock()
+
+	delete(rw.incompleteBlocks[tenantID], blockID)
+}
+
+// startFetchSpan starts a child span for a single backend read.  The backend propagates it into its requests so the
+// read can be matched to the object store request that served it.
+func startFetchSpan(ctx context.Context, fetchType string, meta *encoding.BlockMeta) (opentracing.Span, context.Context) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "store.fetch "+fetchType)
+	span.SetTag("block", meta.BlockID.String())
+	span.SetTag("tenant", meta.TenantID)
+	return span, ctx
+}
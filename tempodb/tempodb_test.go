@@ -15,9 +15,13 @@ import (
 	"github.com/google/uuid"
 	"github.com/grafana/tempo/pkg/tempopb"
 	"github.com/grafana/tempo/pkg/util/test"
+	"github.com/grafana/tempo/tempodb/backend"
+	"github.com/grafana/tempo/tempodb/backend/inmemory"
 	"github.com/grafana/tempo/tempodb/backend/local"
+	"github.com/grafana/tempo/tempodb/encoding"
 	"github.com/grafana/tempo/tempodb/wal"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 const (
@@ -48,7 +52,7 @@ func TestDB(t *testing.T) {
 		MaxCompactionRange:      time.Hour,
 		BlockRetention:          0,
 		CompactedBlockRetention: 0,
-	}, &mockSharder{})
+	}, &mockSharder{}, &mockOverrides{})
 
 	blockID := uuid.New()
 
@@ -86,17 +90,236 @@ func TestDB(t *testing.T) {
 
 	// read
 	for i, id := range ids {
-		bFound, _, err := r.Find(context.Background(), testTenantID, id)
+		bFound, metrics, err := r.Find(context.Background(), testTenantID, id)
 		assert.NoError(t, err)
+		assert.Equal(t, complete.BlockMeta().EndTime.UnixNano(), metrics.FoundBlockEnd.Load())
 
 		out := &tempopb.PushRequest{}
 		err = proto.Unmarshal(bFound, out)
 		assert.NoError(t, err)
 
 		assert.True(t, proto.Equal(out, reqs[i]))
+
+		mayContain, _, err := r.MayContain(context.Background(), testTenantID, id)
+		assert.NoError(t, err)
+		assert.True(t, mayContain)
+
+		// limited to one block at a time
+		limitedFound, _, err := r.FindInBlocks(context.Background(), testTenantID, id, nil, 1)
+		assert.NoError(t, err)
+		assert.Equal(t, bFound, limitedFound)
+
+		// filtered out
+		bFound, _, err = r.FindInBlocks(context.Background(), testTenantID, id, func(*encoding.BlockMeta) bool { return false }, 0)
+		assert.NoError(t, err)
+		assert.Nil(t, bFound)
+	}
+
+	// warming with a backend and no cache only checks the reads succeed
+	assert.NoError(t, r.WarmBlocks(context.Background(), nil))
+
+	// recent objects span several index pages and stop at the limit
+	recentIDs, recentObjects, _, err := r.RecentObjects(context.Background(), testTenantID, 3)
+	assert.NoError(t, err)
+	assert.Len(t, recentIDs, 3)
+	assert.Len(t, recentObjects, 3)
+
+	recentIDs, _, _, err = r.RecentObjects(context.Background(), testTenantID, 100)
+	assert.NoError(t, err)
+	assert.Len(t, recentIDs, len(ids))
+	for _, id := range ids {
+		assert.Contains(t, recentIDs, encoding.ID(id))
 	}
 }
 
+func TestDBInMemory(t *testing.T) {
+	for _, writeErrorRate := range []float64{1, 0} {
+		tempDir, err := ioutil.TempDir("/tmp", "")
+		defer os.RemoveAll(tempDir)
+		assert.NoError(t, err, "unexpected error creating temp dir")
+
+		r, w, _, err := New(&Config{
+			Backend:  "inmemory",
+			InMemory: &inmemory.Config{WriteErrorRate: writeErrorRate},
+			WAL: &wal.Config{
+				Filepath:        path.Join(tempDir, "wal"),
+				IndexDownsample: 17,
+				BloomFP:         .01,
+			},
+			BlocklistPoll: 0,
+		}, log.NewNopLogger())
+		require.NoError(t, err)
+
+		head, err := w.WAL().NewBlock(uuid.New(), testTenantID)
+		require.NoError(t, err)
+
+		id := make([]byte, 16)
+		rand.Read(id)
+		req := test.MakeRequest(10, id)
+		bReq, err := proto.Marshal(req)
+		require.NoError(t, err)
+		require.NoError(t, head.Write(id, bReq))
+
+		complete, err := head.Complete(w.WAL(), &mockSharder{})
+		require.NoError(t, err)
+
+		err = w.WriteBlock(context.Background(), complete)
+		if writeErrorRate == 1 {
+			assert.Equal(t, inmemory.ErrInjected, err)
+			continue
+		}
+		require.NoError(t, err)
+
+		r.(*readerWriter).pollBlocklist()
+
+		bFound, _, err := r.Find(context.Background(), testTenantID, id)
+		require.NoError(t, err)
+		out := &tempopb.PushRequest{}
+		require.NoError(t, proto.Unmarshal(bFound, out))
+		assert.True(t, proto.Equal(out, req))
+	}
+}
+
+func TestAddBlocks(t *testing.T) {
+	tempDir, err := ioutil.TempDir("/tmp", "")
+	defer os.RemoveAll(tempDir)
+	assert.NoError(t, err, "unexpected error creating temp dir")
+
+	r, _, _, err := New(&Config{
+		Backend: "local",
+		Local: &local.Config{
+			Path: path.Join(tempDir, "traces"),
+		},
+		WAL: &wal.Config{
+			Filepath:        path.Join(tempDir, "wal"),
+			IndexDownsample: 17,
+			BloomFP:         .01,
+		},
+	}, log.NewNopLogger())
+	require.NoError(t, err)
+
+	rw := r.(*readerWriter)
+	now := time.Now()
+	older := encoding.NewBlockMeta(testTenantID, uuid.New(), encoding.CurrentVersion)
+	older.StartTime = now.Add(-time.Hour)
+	newer := encoding.NewBlockMeta(testTenantID, uuid.New(), encoding.CurrentVersion)
+	newer.StartTime = now
+	compacted := encoding.NewBlockMeta(testTenantID, uuid.New(), encoding.CurrentVersion)
+	unreadable := encoding.NewBlockMeta(testTenantID, uuid.New(), "v99")
+
+	rw.compactedBlockLists[testTenantID] = []*encoding.CompactedBlockMeta{{BlockMeta: *compacted}}
+
+	r.AddBlocks([]*encoding.BlockMeta{newer, compacted, unreadable})
+	r.AddBlocks([]*encoding.BlockMeta{older, newer})
+
+	// sorted by start time with duplicates, compacted and unreadable blocks ignored
+	blocklist := rw.blocklist(testTenantID)
+	assert.Len(t, blocklist, 2)
+	assert.Equal(t, older.BlockID, blocklist[0].BlockID)
+	assert.Equal(t, newer.BlockID, blocklist[1].BlockID)
+}
+
+type countingCompactor struct {
+	backend.Compactor
+	compactedMetaReads int
+}
+
+func (c *countingCompactor) CompactedBlockMeta(blockID uuid.UUID, tenantID string) (*encoding.CompactedBlockMeta, error) {
+	c.compactedMetaReads++
+	return c.Compactor.CompactedBlockMeta(blockID, tenantID)
+}
+
+func TestPollBlocklistReusesCompactedMetas(t *testing.T) {
+	tempDir, err := ioutil.TempDir("/tmp", "")
+	defer os.RemoveAll(tempDir)
+	assert.NoError(t, err, "unexpected error creating temp dir")
+
+	r, w, _, err := New(&Config{
+		Backend: "local",
+		Local: &local.Config{
+			Path: path.Join(tempDir, "traces"),
+		},
+		WAL: &wal.Config{
+			Filepath:        path.Join(tempDir, "wal"),
+			IndexDownsample: 17,
+			BloomFP:         .01,
+		},
+	}, log.NewNopLogger())
+	require.NoError(t, err)
+
+	rw := r.(*readerWriter)
+	compactor := &countingCompactor{Compactor: rw.c}
+	rw.c = compactor
+
+	head, err := w.WAL().NewBlock(uuid.New(), testTenantID)
+	require.NoError(t, err)
+	complete, err := head.Complete(w.WAL(), &mockSharder{})
+	require.NoError(t, err)
+	blockID := complete.BlockMeta().BlockID
+	require.NoError(t, w.WriteBlock(context.Background(), complete))
+
+	checkBlocklists(t, blockID, 1, 0, rw)
+	assert.Equal(t, 0, compactor.compactedMetaReads)
+
+	require.NoError(t, rw.c.MarkBlockCompacted(blockID, testTenantID))
+	checkBlocklists(t, blockID, 0, 1, rw)
+	assert.Equal(t, 1, compactor.compactedMetaReads)
+	compacted := rw.compactedBlockLists[testTenantID][0]
+
+	// the compacted meta is reused until the block is cleared
+	checkBlocklists(t, blockID, 0, 1, rw)
+	assert.Equal(t, 1, compactor.compactedMetaReads)
+	assert.Same(t, compacted, rw.compactedBlockLists[testTenantID][0])
+
+	require.NoError(t, rw.c.ClearBlock(blockID, testTenantID))
+	checkBlocklists(t, blockID, 0, 0, rw)
+}
+
+func TestPollBlocklistNewBlocksExceeded(t *testing.T) {
+	tempDir, err := ioutil.TempDir("/tmp", "")
+	defer os.RemoveAll(tempDir)
+	assert.NoError(t, err, "unexpected error creating temp dir")
+
+	r, w, _, err := New(&Config{
+		Backend: "local",
+		Local: &local.Config{
+			Path: path.Join(tempDir, "traces"),
+		},
+		WAL: &wal.Config{
+			Filepath:        path.Join(tempDir, "wal"),
+			IndexDownsample: 17,
+			BloomFP:         .01,
+		},
+		BlocklistMaxNewBlocks: 2,
+	}, log.NewNopLogger())
+	require.NoError(t, err)
+
+	rw := r.(*readerWriter)
+	writeBlocks := func(n int) {
+		for i := 0; i < n; i++ {
+			head, err := w.WAL().NewBlock(uuid.New(), testTenantID)
+			require.NoError(t, err)
+			complete, err := head.Complete(w.WAL(), &mockSharder{})
+			require.NoError(t, err)
+			require.NoError(t, w.WriteBlock(context.Background(), complete))
+		}
+	}
+
+	// the blocks found at the first poll aren't new
+	writeBlocks(3)
+	rw.pollBlocklist()
+	assert.False(t, r.NewBlocksExceeded(testTenantID))
+
+	writeBlocks(3)
+	rw.pollBlocklist()
+	assert.True(t, r.NewBlocksExceeded(testTenantID))
+	assert.False(t, r.NewBlocksExceeded("other"))
+
+	writeBlocks(2)
+	rw.pollBlocklist()
+	assert.False(t, r.NewBlocksExceeded(testTenantID))
+}
+
 func TestNilOnUnknownTenantID(t *testing.T) {
 	tempDir, err := ioutil.TempDir("/tmp", "")
 	defer os.RemoveAll(tempDir)
@@ -119,6 +342,10 @@ func TestNilOnUnknownTenantID(t *testing.T) {
 	buff, _, err := r.Find(context.Background(), "unknown", []byte{0x01})
 	assert.Nil(t, buff)
 	assert.Nil(t, err)
+
+	mayContain, _, err := r.MayContain(context.Background(), "unknown", []byte{0x01})
+	assert.False(t, mayContain)
+	assert.Nil(t, err)
 }
 
 func TestRetention(t *testing.T) {
@@ -145,7 +372,7 @@ func TestRetention(t *testing.T) {
 		MaxCompactionRange:      time.Hour,
 		BlockRetention:          0,
 		CompactedBlockRetention: 0,
-	}, &mockSharder{})
+	}, &mockSharder{}, &mockOverrides{})
 
 	blockID := uuid.New()
 
@@ -175,6 +402,119 @@ func TestRetention(t *testing.T) {
 	checkBlocklists(t, blockID, 0, 0, rw)
 }
 
+func TestRetentionPerTenant(t *testing.T) {
+	tempDir, err := ioutil.TempDir("/tmp", "")
+	defer os.RemoveAll(tempDir)
+	assert.NoError(t, err, "unexpected error creating temp dir")
+
+	r, w, c, err := New(&Config{
+		Backend: "local",
+		Local: &local.Config{
+			Path: path.Join(tempDir, "traces"),
+		},
+		WAL: &wal.Config{
+			Filepath:        path.Join(tempDir, "wal"),
+			IndexDownsample: 17,
+			BloomFP:         .01,
+		},
+		BlocklistPoll: 0,
+	}, log.NewNopLogger())
+	assert.NoError(t, err)
+
+	overrides := &mockOverrides{blockRetention: time.Hour}
+	c.EnableCompaction(&CompactorConfig{
+		ChunkSizeBytes:          10,
+		MaxCompactionRange:      time.Hour,
+		BlockRetention:          0,
+		CompactedBlockRetention: 0,
+	}, &mockSharder{}, overrides)
+
+	wal := w.WAL()
+	head, err := wal.NewBlock(uuid.New(), testTenantID)
+	assert.NoError(t, err)
+	complete, err := head.Complete(wal, &mockSharder{})
+	assert.NoError(t, err)
+	blockID := complete.BlockMeta().BlockID
+
+	err = w.WriteBlock(context.Background(), complete)
+	assert.NoError(t, err)
+
+	rw := r.(*readerWriter)
+	checkBlocklists(t, blockID, 1, 0, rw)
+
+	// the tenant's retention is longer than the global retention
+	rw.doRetention()
+	checkBlocklists(t, blockID, 1, 0, rw)
+
+	// without an override the global retention applies
+	overrides.blockRetention = 0
+	rw.doRetention()
+	checkBlocklists(t, blockID, 0, 1, rw)
+}
+
+// ownedSharder owns only the listed hashes
+type ownedSharder struct {
+	mockSharder
+	owned map[string]bool
+}
+
+func (m *ownedSharder) Owns(hash string) bool {
+	return m.owned[hash]
+}
+
+func TestRetentionSharded(t *testing.T) {
+	tempDir, err := ioutil.TempDir("/tmp", "")
+	defer os.RemoveAll(tempDir)
+	assert.NoError(t, err, "unexpected error creating temp dir")
+
+	r, w, c, err := New(&Config{
+		Backend: "local",
+		Local: &local.Config{
+			Path: path.Join(tempDir, "traces"),
+		},
+		WAL: &wal.Config{
+			Filepath:        path.Join(tempDir, "wal"),
+			IndexDownsample: 17,
+			BloomFP:         .01,
+		},
+		BlocklistPoll: 0,
+	}, log.NewNopLogger())
+	assert.NoError(t, err)
+
+	var blockIDs []uuid.UUID
+	for i := 0; i < 2; i++ {
+		head, err := w.WAL().NewBlock(uuid.New(), testTenantID)
+		assert.NoError(t, err)
+		complete, err := head.Complete(w.WAL(), &mockSharder{})
+		assert.NoError(t, err)
+		blockIDs = append(blockIDs, complete.BlockMeta().BlockID)
+
+		err = w.WriteBlock(context.Background(), complete)
+		assert.NoError(t, err)
+	}
+
+	// this compactor only owns the first block
+	sharder := &ownedSharder{owned: map[string]bool{blockHashString(testTenantID, blockIDs[0]): true}}
+	c.EnableCompaction(&CompactorConfig{
+		ChunkSizeBytes:          10,
+		MaxCompactionRange:      time.Hour,
+		BlockRetention:          0,
+		CompactedBlockRetention: 0,
+	}, sharder, &mockOverrides{})
+
+	rw := r.(*readerWriter)
+	rw.pollBlocklist()
+	rw.doRetention()
+	rw.pollBlocklist()
+
+	blocklist := rw.blocklist(testTenantID)
+	compactedBlocklist := rw.compactedBlocklist(testTenantID)
+	assert.Len(t, blocklist, 1)
+	assert.Len(t, compactedBlocklist, 1)
+	assert.Equal(t, blockIDs[1], blocklist[0].BlockID)
+	assert.Equal(t, blockIDs[0], compactedBlocklist[0].BlockID)
+}
+
 func checkBlocklists(t *testing.T, expectedID uuid.UUID, expectedB int, expectedCB int, rw *readerWriter) {
 	rw.pollBlocklist()
 
@@ -203,3 +543,55 @@ func checkBlocklists(t *testing.T, expectedID uuid.UUID, expectedB int, expected
 		lastTime = b.StartTime
 	}
 }
+
+func TestBlockIDRangeFilter(t *testing.T) {
+	start := uuid.MustParse("40000000-0000-0000-0000-000000000000")
+	end := uuid.MustParse("7fffffff-ffff-ffff-ffff-ffffffffffff")
+	filter := BlockIDRangeFilter(start, end)
+
+	for id, expected := range map[string]bool{
+		"00000000-0000-0000-0000-000000000000": false,
+		"40000000-0000-0000-0000-000000000000": true,
+		"5a3c0000-0000-0000-0000-000000000001": true,
+		"7fffffff-ffff-ffff-ffff-ffffffffffff": true,
+		"80000000-0000-0000-0000-000000000000": false,
+	} {
+		assert.Equal(t, expected, filter(&encoding.BlockMeta{BlockID: uuid.MustParse(id)}), id)
+	}
+}
+
+func TestTimeRangeFilter(t *testing.T) {
+	summary := encoding.NewBlockSummary()
+	summary.AddTrace(nil, nil, 1, uint64(time.Unix(100, 0).UnixNano()), uint64(time.Unix(200, 0).UnixNano()))
+	filter := TimeRangeFilter(time.Unix(300, 0), time.Time{})
+
+	assert.False(t, filter(&encoding.BlockMeta{Summary: summary}))
+	assert.True(t, filter(&encoding.BlockMeta{}))
+	assert.True(t, filter(&encoding.BlockMeta{Summary: &encoding.BlockSummary{Traces: 1}}))
+
+	all := AllFilters(nil, filter, ServiceFilter("frontend"))
+	assert.False(t, all(&encoding.BlockMeta{Summary: summary}))
+	assert.True(t, all(&encoding.BlockMeta{}))
+	assert.Nil(t, AllFilters(nil))
+}
+
+func TestResolveLocalPath(t *testing.T) {
+	walCfg := &wal.Config{Filepath: "/var/tempo/wal"}
+
+	localCfg := &local.Config{}
+	assert.NoError(t, resolveLocalPath(localCfg, walCfg))
+	assert.Equal(t, "/var/tempo/wal/blocks", localCfg.Path)
+
+	for p, ok := range map[string]bool{
+		"/var/tempo/traces":               true,
+		"/var/tempo/wal/traces":           true,
+		"/var/tempo/wal/completed2":       true,
+		"/var/tempo/wal/":                 true,
+		"/var/tempo":                      true,
+		"/var/tempo/wal/completed":        false,
+		"/var/tempo/wal/completed/blocks": false,
+	} {
+		err := resolveLocalPath(&local.Config{Path: p}, walCfg)
+		assert.Equal(t, ok, err == nil, p)
+	}
+}
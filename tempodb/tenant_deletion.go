@@ -0,0 +1,144 @@
+package tempodb
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-kit/kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"github.com/grafana/tempo/pkg/tenantmetrics"
+	"github.com/grafana/tempo/tempodb/backend"
+)
+
+var (
+	metricTenantDeletionBlocksRemaining = tenantmetrics.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "tempodb",
+		Name:      "tenant_deletion_blocks_remaining",
+		Help:      "Number of blocks left to delete of each tenant marked for deletion.",
+	}, []string{"tenant"})
+	metricTenantDeletionBlocksDeleted = tenantmetrics.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "tempodb",
+		Name:      "tenant_deletion_blocks_deleted_total",
+		Help:      "Total number of blocks deleted of tenants marked for deletion.",
+	}, []string{"tenant"})
+	metricTenantDeletionCompleted = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: "tempodb",
+		Name:      "tenant_deletion_completed_total",
+		Help:      "Total number of tenants whose blocks were all deleted and whose deletion mark was cleared.",
+	})
+	metricTenantDeletionErrors = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: "tempodb",
+		Name:      "tenant_deletion_errors_total",
+		Help:      "Total number of errors occurring while deleting the blocks of tenants marked for deletion.",
+	})
+)
+
+// tenantDeletionHash is the hash a compactor must own to delete the blocks of a tenant marked for deletion.
+func tenantDeletionHash(tenantID string) string {
+	return "tenant-deletion-" + tenantID
+}
+
+// MarkTenantDeleted marks the tenant for deletion.  The compactors delete its blocks asynchronously.  Marking a tenant
+// again keeps the time of the first request.
+func (rw *readerWriter) MarkTenantDeleted(tenantID string) error {
+	_, err := rw.c.TenantDeletionMark(tenantID)
+	if err == nil {
+		return nil
+	}
+	if err != backend.ErrDeletionMarkDoesNotExist {
+		return err
+	}
+
+	level.Info(rw.logger).Log("msg", "marking tenant for deletion", "tenantID", tenantID)
+	return rw.c.MarkTenantDeleted(tenantID, &backend.TenantDeletionMark{
+		RequestedTime: time.Now(),
+	})
+}
+
+// doTenantDeletion deletes the blocks of the tenants marked for deletion and remembers which tenants are being deleted
+// so they aren't compacted.
+func (rw *readerWriter) doTenantDeletion() {
+	deleting := map[string]struct{}{}
+	for _, t := range rw.blocklistTenants() {
+		tenantID := t.(string)
+		if !rw.compactorSharder.Owns(tenantDeletionHash(tenantID)) {
+			continue
+		}
+
+		mark, err := rw.c.TenantDeletionMark(tenantID)
+		if err == backend.ErrDeletionMarkDoesNotExist {
+			continue
+		}
+		if err != nil {
+			level.Error(rw.logger).Log("msg", "failed to read tenant deletion mark", "tenantID", tenantID, "err", err)
+			metricTenantDeletionErrors.Inc()
+			continue
+		}
+
+		if !rw.deleteTenant(tenantID, mark) {
+			deleting[tenantID] = struct{}{}
+		}
+	}
+
+	rw.blockListsMtx.Lock()
+	rw.deletingTenants = deleting
+	rw.blockListsMtx.Unlock()
+}
+
+// deleteTenant deletes every block of the tenant and returns true once the tenant is deleted.  Blocks are listed from
+// the backend rather than the blocklist so blocks without a meta are deleted too.  The mark is only cleared once no
+// blocks are left and the grace period after the request has passed, since ingesters may still flush blocks of the
+// tenant until then.
+func (rw *readerWriter) deleteTenant(tenantID string, mark *backend.TenantDeletionMark) bool {
+	blockIDs, err := rw.r.Blocks(context.Background(), tenantID)
+	if err != nil {
+		level.Error(rw.logger).Log("msg", "failed to list blocks of tenant marked for deletion", "tenantID", tenantID, "err", err)
+		metricTenantDeletionErrors.Inc()
+		return false
+	}
+
+	remaining := len(blockIDs)
+	metricTenantDeletionBlocksRemaining.WithLabelValues(tenantID).Set(float64(remaining))
+	for _, blockID := range blockIDs {
+		err := rw.c.ClearBlock(blockID, tenantID)
+		if err != nil {
+			level.Error(rw.logger).Log("msg", "failed to clear block of tenant marked for deletion", "blockID", blockID, "tenantID", tenantID, "err", err)
+			metricTenantDeletionErrors.Inc()
+			continue
+		}
+
+		remaining--
+		metricTenantDeletionBlocksRemaining.WithLabelValues(tenantID).Set(float64(remaining))
+		metricTenantDeletionBlocksDeleted.WithLabelValues(tenantID).Inc()
+	}
+	if len(blockIDs) > 0 {
+		level.Info(rw.logger).Log("msg", "deleted blocks of tenant marked for deletion", "tenantID", tenantID, "deleted", len(blockIDs)-remaining, "remaining", remaining)
+	}
+
+	if remaining > 0 || time.Since(mark.RequestedTime) < rw.compactorCfg.TenantDeletionGracePeriod {
+		return false
+	}
+
+	err = rw.c.ClearTenantDeletionMark(tenantID)
+	if err != nil {
+		level.Error(rw.logger).Log("msg", "failed to clear tenant deletion mark", "tenantID", tenantID, "err", err)
+		metricTenantDeletionErrors.Inc()
+		return false
+	}
+
+	level.Info(rw.logger).Log("msg", "tenant deleted", "tenantID", tenantID, "requested", mark.RequestedTime)
+	metricTenantDeletionBlocksRemaining.DeleteLabelValues(tenantID)
+	metricTenantDeletionCompleted.Inc()
+	return true
+}
+
+// tenantDeleting returns whether the tenant was marked for deletion at the last tenant deletion run.
+func (rw *readerWriter) tenantDeleting(tenantID string) bool {
+	rw.blockListsMtx.Lock()
+	defer rw.blockListsMtx.Unlock()
+
+	_, ok := rw.deletingTenants[tenantID]
+	return ok
+}
@@ -0,0 +1,98 @@
+package tempodb
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/tempo/tempodb/backend"
+	"github.com/grafana/tempo/tempodb/backend/local"
+	"github.com/grafana/tempo/tempodb/encoding"
+	"github.com/grafana/tempo/tempodb/wal"
+)
+
+func TestTenantDeletion(t *testing.T) {
+	tempDir, err := ioutil.TempDir("/tmp", "")
+	defer os.RemoveAll(tempDir)
+	assert.NoError(t, err, "unexpected error creating temp dir")
+
+	r, w, c, err := New(&Config{
+		Backend: "local",
+		Local: &local.Config{
+			Path: path.Join(tempDir, "traces"),
+		},
+		WAL: &wal.Config{
+			Filepath:        path.Join(tempDir, "wal"),
+			IndexDownsample: 17,
+			BloomFP:         .01,
+		},
+		BlocklistPoll: 0,
+	}, log.NewNopLogger())
+	assert.NoError(t, err)
+
+	c.EnableCompaction(&CompactorConfig{
+		ChunkSizeBytes:            10,
+		MaxCompactionRange:        time.Hour,
+		BlockRetention:            time.Hour,
+		CompactedBlockRetention:   time.Hour,
+		TenantDeletionGracePeriod: time.Hour,
+	}, &mockSharder{}, &mockOverrides{})
+	rw := r.(*readerWriter)
+
+	for i := 0; i < 2; i++ {
+		head, err := w.WAL().NewBlock(uuid.New(), testTenantID)
+		require.NoError(t, err)
+		complete, err := head.Complete(w.WAL(), &mockSharder{})
+		require.NoError(t, err)
+		require.NoError(t, w.WriteBlock(context.Background(), complete))
+	}
+
+	// and a block whose write was abandoned
+	meta := encoding.NewBlockMeta(testTenantID, uuid.New(), "v0")
+	tracker, err := rw.w.AppendObject(context.Background(), nil, meta, []byte{0x01})
+	require.NoError(t, err)
+	require.NoError(t, tracker.(io.Closer).Close())
+
+	checkBlocklists(t, uuid.Nil, 2, 0, rw)
+
+	// nothing is deleted until the tenant is marked
+	rw.doTenantDeletion()
+	checkBlocklists(t, uuid.Nil, 2, 0, rw)
+	assert.False(t, rw.tenantDeleting(testTenantID))
+
+	require.NoError(t, c.MarkTenantDeleted(testTenantID))
+	mark, err := rw.c.TenantDeletionMark(testTenantID)
+	require.NoError(t, err)
+
+	// every block is deleted but the mark is kept for the grace period
+	rw.doTenantDeletion()
+	checkBlocklists(t, uuid.Nil, 0, 0, rw)
+	assert.Empty(t, rw.incompleteBlocklist(testTenantID))
+	assert.True(t, rw.tenantDeleting(testTenantID))
+
+	// marking the tenant again keeps the time of the first request
+	require.NoError(t, c.MarkTenantDeleted(testTenantID))
+	again, err := rw.c.TenantDeletionMark(testTenantID)
+	require.NoError(t, err)
+	assert.True(t, mark.RequestedTime.Equal(again.RequestedTime))
+
+	// after the grace period the mark and the tenant are gone
+	rw.compactorCfg.TenantDeletionGracePeriod = 0
+	rw.doTenantDeletion()
+	_, err = rw.c.TenantDeletionMark(testTenantID)
+	assert.Equal(t, backend.ErrDeletionMarkDoesNotExist, err)
+	assert.False(t, rw.tenantDeleting(testTenantID))
+
+	tenants, err := rw.r.Tenants(context.Background())
+	require.NoError(t, err)
+	assert.NotContains(t, tenants, testTenantID)
+}
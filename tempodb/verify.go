@@ -0,0 +1,227 @@
+package tempodb
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/google/uuid"
+	willf_bloom "github.com/willf/bloom"
+
+	"github.com/grafana/tempo/tempodb/backend"
+	"github.com/grafana/tempo/tempodb/encoding"
+	"github.com/grafana/tempo/tempodb/encoding/bloom"
+)
+
+// Block states reported by VerifyBlock.
+const (
+	BlockStateOK         = "ok"
+	BlockStateCompacted  = "compacted"
+	BlockStateOrphaned   = "orphaned"
+	BlockStateUnreadable = "unreadable"
+)
+
+const verifyChunkSizeBytes = 10 * 1024 * 1024
+
+// BlockVerification is the result of verifying a single block.
+type BlockVerification struct {
+	TenantID string
+	BlockID  uuid.UUID
+	State    string
+	Problems []string
+}
+
+// NewBackend opens the backend configured in cfg without the wal, caches and blocklist polling of New.  It's used by
+// tools that inspect the backend directly.
+func NewBackend(cfg *Config) (backend.Reader, backend.Writer, backend.Compactor, error) {
+	return newBackend(cfg.Backend, cfg.Local, cfg.GCS, cfg.S3, cfg.Azure, cfg.InMemory)
+}
+
+// VerifyBlocks verifies every block of the tenant.  An error is only returned if the blocks can't be listed.
+func VerifyBlocks(ctx context.Context, r backend.Reader, c backend.Compactor, tenantID string, readData bool) ([]*BlockVerification, error) {
+	blockIDs, err := r.Blocks(ctx, tenantID)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]*BlockVerification, 0, len(blockIDs))
+	for _, blockID := range blockIDs {
+		results = append(results, VerifyBlock(ctx, r, c, tenantID, blockID, readData))
+	}
+	return results, nil
+}
+
+// VerifyBlock checks that a block can be read the way queries and compaction read it.  The meta must be readable and
+// written with a supported version and encoding, every bloom shard must parse, and the index records must be ordered,
+// cover the data object without gaps and end at the meta's max id.  The ids of the index records must pass the bloom
+// filter.  If readData is set every object is also read and decoded and its id checked against the meta and the
+// bloom filter.  Blocks that have been compacted are only checked for a compacted meta, and blocks with no meta at all
+// are reported as orphaned: they were abandoned or are still being written.
+func VerifyBlock(ctx context.Context, r backend.Reader, c backend.Compactor, tenantID string, blockID uuid.UUID, readData bool) *BlockVerification {
+	v := &BlockVerification{
+		TenantID: tenantID,
+		BlockID:  blockID,
+		State:    BlockStateOK,
+	}
+
+	meta, err := r.BlockMeta(ctx, blockID, tenantID)
+	if err == backend.ErrMetaDoesNotExist {
+		_, err = c.CompactedBlockMeta(blockID, tenantID)
+		switch {
+		case err == nil:
+			v.State = BlockStateCompacted
+		case err == backend.ErrMetaDoesNotExist:
+			v.State = BlockStateOrphaned
+		default:
+			v.problem("compacted meta: %v", err)
+		}
+		return v
+	}
+	if err != nil {
+		v.problem("meta: %v", err)
+		return v
+	}
+
+	if !encoding.ReadableVersion(meta.Version) {
+		v.problem("meta: unreadable version %s", meta.Version)
+	}
+	if meta.Encoding != "" {
+		if err := encoding.ValidateEncoding(meta.Encoding); err != nil {
+			v.problem("meta: %v", err)
+		}
+	}
+
+	filters := v.verifyBloom(ctx, r, meta)
+	records := v.verifyIndex(ctx, r, meta, filters)
+
+	if readData && records != nil {
+		v.verifyData(ctx, r, meta, filters)
+	}
+
+	return v
+}
+
+func (v *BlockVerification) problem(format string, args ...interface{}) {
+	v.State = BlockStateUnreadable
+	v.Problems = append(v.Problems, fmt.Sprintf(format, args...))
+}
+
+// verifyBloom reads every bloom shard.  Shards that can't be read are nil.
+func (v *BlockVerification) verifyBloom(ctx context.Context, r backend.Reader, meta *encoding.BlockMeta) []*willf_bloom.BloomFilter {
+	filters := make([]*willf_bloom.BloomFilter, meta.BloomShards())
+	for shard := range filters {
+		bloomBytes, err := r.Bloom(ctx, meta.BlockID, meta.TenantID, shard)
+		if err != nil {
+			v.problem("bloom shard %d: %v", shard, err)
+			continue
+		}
+
+		filter := &willf_bloom.BloomFilter{}
+		if _, err := filter.ReadFrom(bytes.NewReader(bloomBytes)); err != nil {
+			v.problem("bloom shard %d: %v", shard, err)
+			continue
+		}
+		filters[shard] = filter
+	}
+	return filters
+}
+
+// verifyIndex reads the index and checks its records.  It returns nil if the index can't be read.
+func (v *BlockVerification) verifyIndex(ctx context.Context, r backend.Reader, meta *encoding.BlockMeta, filters []*willf_bloom.BloomFilter) []*encoding.Record {
+	indexBytes, err := r.Index(ctx, meta.BlockID, meta.TenantID)
+	if err != nil {
+		v.problem("index: %v", err)
+		return nil
+	}
+
+	records, err := encoding.UnmarshalRecords(indexBytes)
+	if err != nil {
+		v.problem("index: %v", err)
+		return nil
+	}
+	if len(records) == 0 {
+		if meta.TotalObjects > 0 {
+			v.problem("index: no records for %d objects", meta.TotalObjects)
+		}
+		return records
+	}
+
+	var offset uint64
+	for i, record := range records {
+		if record.Start != offset {
+			v.problem("index: record %d starts at %d, expected %d", i, record.Start, offset)
+			break
+		}
+		if i > 0 && bytes.Compare(records[i-1].ID, record.ID) > 0 {
+			v.problem("index: record %d is out of order", i)
+			break
+		}
+		if !testBloomFilters(filters, record.ID) {
+			v.problem("bloom: missing id %x of index record %d", []byte(record.ID), i)
+			break
+		}
+		offset += uint64(record.Length)
+	}
+
+	if meta.Size > 0 && offset != meta.Size {
+		v.problem("index: records cover %d bytes, data is %d bytes", offset, meta.Size)
+	}
+	if last := records[len(records)-1].ID; len(meta.MaxID) > 0 && !bytes.Equal(last, meta.MaxID) {
+		v.problem("index: last record id %x doesn't match the meta max id %x", []byte(last), []byte(meta.MaxID))
+	}
+
+	return records
+}
+
+// verifyData reads and decodes every object of the block.
+func (v *BlockVerification) verifyData(ctx context.Context, r backend.Reader, meta *encoding.BlockMeta, filters []*willf_bloom.BloomFilter) {
+	iter, err := encoding.NewBackendIterator(meta.TenantID, meta.BlockID, verifyChunkSizeBytes, r)
+	if err != nil {
+		v.problem("data: %v", err)
+		return
+	}
+
+	objects := 0
+	var prevID encoding.ID
+	for {
+		id, object, err := iter.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			v.problem("data: object %d: %v", objects, err)
+			return
+		}
+
+		if prevID != nil && bytes.Compare(prevID, id) > 0 {
+			v.problem("data: object %d is out of order", objects)
+			return
+		}
+		if bytes.Compare(id, meta.MinID) < 0 || bytes.Compare(id, meta.MaxID) > 0 {
+			v.problem("data: id %x of object %d is outside of the meta's ids", []byte(id), objects)
+			return
+		}
+		if !testBloomFilters(filters, id) {
+			v.problem("bloom: missing id %x of object %d", []byte(id), objects)
+			return
+		}
+		if _, err := encoding.DecodeObject(meta.Encoding, object); err != nil {
+			v.problem("data: object %d: %v", objects, err)
+			return
+		}
+
+		prevID = append(prevID[:0], id...)
+		objects++
+	}
+
+	if objects != meta.TotalObjects {
+		v.problem("data: found %d objects, meta has %d", objects, meta.TotalObjects)
+	}
+}
+
+// testBloomFilters returns true if the filter of the id's shard may contain it or the shard couldn't be read.
+func testBloomFilters(filters []*willf_bloom.BloomFilter, id encoding.ID) bool {
+	filter := filters[bloom.ShardKeyForTraceID(id, len(filters))]
+	return filter == nil || filter.Test(id)
+}
@@ -0,0 +1,85 @@
+package tempodb
+
+import (
+	"context"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/go-kit/kit/log"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/tempo/tempodb/backend/local"
+	"github.com/grafana/tempo/tempodb/wal"
+)
+
+func TestVerifyBlocks(t *testing.T) {
+	tempDir, err := ioutil.TempDir("/tmp", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(tempDir)
+
+	cfg := &Config{
+		Backend: "local",
+		Local: &local.Config{
+			Path: path.Join(tempDir, "traces"),
+		},
+		WAL: &wal.Config{
+			Filepath:        path.Join(tempDir, "wal"),
+			IndexDownsample: 3,
+			BloomFP:         .01,
+		},
+		BlocklistPoll: 0,
+	}
+	_, w, _, err := New(cfg, log.NewNopLogger())
+	require.NoError(t, err)
+
+	writeBlock := func() uuid.UUID {
+		head, err := w.WAL().NewBlock(uuid.New(), testTenantID)
+		require.NoError(t, err)
+		for i := 0; i < 10; i++ {
+			id := make([]byte, 16)
+			rand.Read(id)
+			require.NoError(t, head.Write(id, []byte{0x01, 0x02}))
+		}
+		complete, err := head.Complete(w.WAL(), &mockSharder{})
+		require.NoError(t, err)
+		require.NoError(t, w.WriteBlock(context.Background(), complete))
+		return complete.BlockMeta().BlockID
+	}
+
+	okID := writeBlock()
+	compactedID := writeBlock()
+	corruptIndexID := writeBlock()
+	orphanedID := uuid.New()
+
+	r, _, c, err := NewBackend(cfg)
+	require.NoError(t, err)
+
+	require.NoError(t, c.MarkBlockCompacted(compactedID, testTenantID))
+	blockPath := path.Join(cfg.Local.Path, testTenantID, corruptIndexID.String())
+	require.NoError(t, ioutil.WriteFile(path.Join(blockPath, "index"), make([]byte, 28*2), 0644))
+	require.NoError(t, os.MkdirAll(path.Join(cfg.Local.Path, testTenantID, orphanedID.String()), 0700))
+
+	results, err := VerifyBlocks(context.Background(), r, c, testTenantID, true)
+	require.NoError(t, err)
+
+	states := map[uuid.UUID]string{}
+	for _, result := range results {
+		states[result.BlockID] = result.State
+		if result.State == BlockStateUnreadable {
+			assert.NotEmpty(t, result.Problems)
+		} else {
+			assert.Empty(t, result.Problems)
+		}
+	}
+	assert.Equal(t, map[uuid.UUID]string{
+		okID:           BlockStateOK,
+		compactedID:    BlockStateCompacted,
+		corruptIndexID: BlockStateUnreadable,
+		orphanedID:     BlockStateOrphaned,
+	}, states)
+}
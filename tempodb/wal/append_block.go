@@ -2,6 +2,7 @@ package wal
 
 import (
 	"os"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/grafana/tempo/tempodb/encoding"
@@ -15,12 +16,19 @@ type AppendBlock struct {
 
 	appendFile *os.File
 	appender   encoding.Appender
+	search     *encoding.SearchData
+
+	// set by SetTimeRange
+	objectsStart time.Time
+	objectsEnd   time.Time
+
+	dataLength int
 }
 
-func newAppendBlock(id uuid.UUID, tenantID string, filepath string) (*AppendBlock, error) {
+func newAppendBlock(id uuid.UUID, tenantID string, filepath string, version string) (*AppendBlock, error) {
 	h := &AppendBlock{
 		block: block{
-			meta:     encoding.NewBlockMeta(tenantID, id),
+			meta:     encoding.NewBlockMeta(tenantID, id, version),
 			filepath: filepath,
 		},
 	}
@@ -47,13 +55,54 @@ func (h *AppendBlock) Write(id encoding.ID, b []byte) error {
 		return err
 	}
 	h.meta.ObjectAdded(id)
+	h.dataLength += len(b)
 	return nil
 }
 
+// Summarize adds a written trace to the block summary.  The summary is only kept when the block is completed if every
+// written object was summarized, so blocks replayed from the wal have none.
+func (h *AppendBlock) Summarize(services []string, operations []string, spans int, start uint64, end uint64) {
+	if h.meta.Summary == nil {
+		h.meta.Summary = encoding.NewBlockSummary()
+	}
+	h.meta.Summary.AddTrace(services, operations, spans, start, end)
+}
+
+// AddSearch records a written trace so it can be found by Search before the block is completed.  The search section
+// written with the block is rebuilt from its objects when it is completed.
+func (h *AppendBlock) AddSearch(id encoding.ID, entry *encoding.SearchEntry) {
+	if h.search == nil {
+		h.search = encoding.NewSearchData()
+	}
+	h.search.Add(id, entry)
+}
+
+// Search returns the traces added with AddSearch that match the query.  A trace written more than once may be
+// returned more than once.
+func (h *AppendBlock) Search(q *encoding.SearchQuery) []encoding.SearchTrace {
+	if h.search == nil {
+		return nil
+	}
+	return h.search.Search(q)
+}
+
+// SetTimeRange sets the time range of the block completed from this one to the time range of its objects.  By default
+// it is the time the block was created to the time it was last written, which doesn't suit blocks of historical
+// objects since retention and compaction windows go by the end of the range.
+func (h *AppendBlock) SetTimeRange(start time.Time, end time.Time) {
+	h.objectsStart = start
+	h.objectsEnd = end
+}
+
 func (h *AppendBlock) Length() int {
 	return h.appender.Length()
 }
 
+// DataLength returns the total size of the objects written to the block.
+func (h *AppendBlock) DataLength() int {
+	return h.dataLength
+}
+
 // Complete should be called when you are done with the block.  This method will write and return a new CompleteBlock which
 // includes an on disk file containing all objects in order.
 // Note that calling this method leaves the original file on disk.  This file is still considered to be part of the WAL
@@ -75,15 +124,26 @@ func (h *AppendBlock) Complete(w *WAL, combiner encoding.ObjectCombiner) (*Compl
 	records := h.appender.Records()
 	orderedBlock := NewCompleteBlock()
 	orderedBlock.block = block{
-		meta:     encoding.NewBlockMeta(h.meta.TenantID, uuid.New()),
+		meta:     encoding.NewBlockMeta(h.meta.TenantID, uuid.New(), walConfig.Version),
 		filepath: walConfig.CompletedFilepath,
 	}
-	orderedBlock.bloom = bloom.NewWithEstimates(uint(len(records)), walConfig.BloomFP)
+	orderedBlock.bloom = bloom.NewWithEstimates(uint(len(records)), walConfig.BloomFP, walConfig.BloomShardSizeBytes)
 	orderedBlock.meta.StartTime = h.meta.StartTime
 	orderedBlock.meta.EndTime = h.meta.EndTime
+	if !h.objectsEnd.IsZero() {
+		orderedBlock.meta.StartTime = h.objectsStart
+		orderedBlock.meta.EndTime = h.objectsEnd
+	}
 	orderedBlock.meta.MinID = h.meta.MinID
 	orderedBlock.meta.MaxID = h.meta.MaxID
-	orderedBlock.meta.TotalObjects = h.meta.TotalObjects
+	orderedBlock.meta.BloomFP = walConfig.BloomFP
+	orderedBlock.meta.BloomShardCount = orderedBlock.bloom.ShardCount()
+	orderedBlock.meta.IndexDownsample = walConfig.IndexDownsample
+	orderedBlock.meta.Encoding = walConfig.Encoding
+	if h.meta.Summary != nil && h.meta.Summary.Traces == h.meta.TotalObjects {
+		orderedBlock.meta.Summary = h.meta.Summary
+		orderedBlock.meta.Summary.Trim()
+	}
 
 	_, err := os.Create(orderedBlock.fullFilename())
 	if err != nil {
@@ -110,6 +170,12 @@ func (h *AppendBlock) Complete(w *WAL, combiner encoding.ObjectCombiner) (*Compl
 		return nil, err
 	}
 	appender := encoding.NewBufferedAppender(appendFile, walConfig.IndexDownsample, len(records))
+	searcher, _ := combiner.(encoding.ObjectSearcher)
+	if searcher != nil {
+		orderedBlock.search = encoding.NewSearchData()
+	}
+	// objects written more than once are combined so the meta counts what is actually written
+	objects := 0
 	for {
 		bytesID, bytesObject, err := iterator.Next()
 		if bytesID == nil {
@@ -124,16 +190,40 @@ func (h *AppendBlock) Complete(w *WAL, combiner encoding.ObjectCombiner) (*Compl
 		orderedBlock.bloom.Add(bytesID)
 		// obj gets written to disk immediately but the id escapes the iterator and needs to be copied
 		writeID := append([]byte(nil), bytesID...)
-		err = appender.Append(writeID, bytesObject)
+		encoded, err := encoding.EncodeObject(walConfig.Encoding, bytesObject)
+		if err != nil {
+			_ = appendFile.Close()
+			_ = os.Remove(orderedBlock.fullFilename())
+			return nil, err
+		}
+		err = appender.Append(writeID, encoded)
 		if err != nil {
 			_ = appendFile.Close()
 			_ = os.Remove(orderedBlock.fullFilename())
 			return nil, err
 		}
+		objects++
+
+		if orderedBlock.search != nil {
+			entry, err := searcher.SearchEntry(bytesObject)
+			if err != nil {
+				// search data is only written if it covers every object
+				orderedBlock.search = nil
+			} else {
+				orderedBlock.search.Add(writeID, entry)
+			}
+		}
 	}
 	appender.Complete()
 	appendFile.Close()
 	orderedBlock.records = appender.Records()
+	orderedBlock.meta.TotalObjects = objects
+	if len(orderedBlock.records) > 0 {
+		last := orderedBlock.records[len(orderedBlock.records)-1]
+		orderedBlock.meta.Size = last.Start + uint64(last.Length)
+	}
+	orderedBlock.meta.IngestedSize = orderedBlock.meta.Size
+	orderedBlock.meta.Searchable = orderedBlock.search != nil
 	orderedBlock.walFilename = h.fullFilename() // pass the filename to the complete block for cleanup when it's flusehd
 
 	return orderedBlock, nil
@@ -146,7 +236,7 @@ func (h *AppendBlock) Find(id encoding.ID, combiner encoding.ObjectCombiner) ([]
 		return nil, err
 	}
 
-	finder := encoding.NewDedupingFinder(records, file, combiner)
+	finder := encoding.NewDedupingFinder(records, file, encoding.EncNone, combiner)
 
 	return finder.Find(id)
 }
@@ -14,6 +14,8 @@ type WriteableBlock interface {
 	BloomFilter() *bloom.ShardedBloomFilter
 	Records() []*encoding.Record
 	ObjectFilePath() string
+	// SearchData returns the search section to write with the block or nil if the block isn't searchable.
+	SearchData() *encoding.SearchData
 
 	Flushed() error
 }
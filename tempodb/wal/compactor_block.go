@@ -19,9 +19,13 @@ type CompactorBlock struct {
 
 	appendBuffer *bytes.Buffer
 	appender     encoding.Appender
+	bytesWritten uint64
+
+	searcher encoding.ObjectSearcher
+	search   *encoding.SearchData
 }
 
-func newCompactorBlock(id uuid.UUID, tenantID string, bloomFP float64, indexDownsample int, metas []*encoding.BlockMeta, filepath string, estimatedObjects int) (*CompactorBlock, error) {
+func newCompactorBlock(id uuid.UUID, tenantID string, bloomFP float64, bloomShardSizeBytes int, indexDownsample int, version string, enc string, metas []*encoding.BlockMeta, filepath string, estimatedObjects int) (*CompactorBlock, error) {
 	if len(metas) == 0 {
 		return nil, fmt.Errorf("empty block meta list")
 	}
@@ -32,19 +36,49 @@ func newCompactorBlock(id uuid.UUID, tenantID string, bloomFP float64, indexDown
 
 	c := &CompactorBlock{
 		block: block{
-			meta:     encoding.NewBlockMeta(tenantID, id),
+			meta:     encoding.NewBlockMeta(tenantID, id, version),
 			filepath: filepath,
 		},
-		bloom: bloom.NewWithEstimates(uint(estimatedObjects), bloomFP),
+		bloom: bloom.NewWithEstimates(uint(estimatedObjects), bloomFP, bloomShardSizeBytes),
 		metas: metas,
 	}
 
+	c.meta.BloomFP = bloomFP
+	c.meta.BloomShardCount = c.bloom.ShardCount()
+	c.meta.IndexDownsample = indexDownsample
+	c.meta.Encoding = enc
+
 	name := c.fullFilename()
 	_, err := os.Create(name)
 	if err != nil {
 		return nil, err
 	}
 
+	// compacted blocks carry the labels of their inputs. later blocks win on conflict
+	for _, m := range metas {
+		for k, v := range m.Labels {
+			if c.meta.Labels == nil {
+				c.meta.Labels = map[string]string{}
+			}
+			c.meta.Labels[k] = v
+		}
+	}
+
+	// the summary is only known if every input has one.  blocks split by size all carry the summary of every input
+	if len(metas) > 0 {
+		c.meta.Summary = encoding.NewBlockSummary()
+		for _, m := range metas {
+			if m.Summary == nil {
+				c.meta.Summary = nil
+				break
+			}
+			c.meta.Summary.Merge(m.Summary)
+		}
+		if c.meta.Summary != nil {
+			c.meta.Summary.Trim()
+		}
+	}
+
 	c.appendBuffer = &bytes.Buffer{}
 	c.appender = encoding.NewBufferedAppender(c.appendBuffer, indexDownsample, estimatedObjects)
 
@@ -52,15 +86,38 @@ func newCompactorBlock(id uuid.UUID, tenantID string, bloomFP float64, indexDown
 }
 
 func (c *CompactorBlock) Write(id encoding.ID, object []byte) error {
-	err := c.appender.Append(id, object)
+	encoded, err := encoding.EncodeObject(c.meta.Encoding, object)
 	if err != nil {
 		return err
 	}
+
+	before := c.appendBuffer.Len()
+	err = c.appender.Append(id, encoded)
+	if err != nil {
+		return err
+	}
+	c.bytesWritten += uint64(c.appendBuffer.Len() - before)
 	c.meta.ObjectAdded(id)
 	c.bloom.Add(id)
+
+	if c.search != nil {
+		entry, err := c.searcher.SearchEntry(object)
+		if err != nil {
+			// search data is only written if it covers every object
+			c.search = nil
+		} else {
+			c.search.Add(id, entry)
+		}
+	}
 	return nil
 }
 
+// EnableSearch builds the search section of the block from the objects written after it is called.
+func (c *CompactorBlock) EnableSearch(searcher encoding.ObjectSearcher) {
+	c.searcher = searcher
+	c.search = encoding.NewSearchData()
+}
+
 func (c *CompactorBlock) CurrentBuffer() []byte {
 	return c.appendBuffer.Bytes()
 }
@@ -77,6 +134,11 @@ func (c *CompactorBlock) Length() int {
 	return c.appender.Length()
 }
 
+// DataLength returns the total number of object bytes written to the block, including those already flushed.
+func (c *CompactorBlock) DataLength() uint64 {
+	return c.bytesWritten
+}
+
 func (c *CompactorBlock) Complete() {
 	c.appender.Complete()
 }
@@ -91,6 +153,8 @@ func (c *CompactorBlock) BlockMeta() *encoding.BlockMeta {
 
 	meta.StartTime = c.metas[0].StartTime
 	meta.EndTime = c.metas[0].EndTime
+	meta.Size = c.bytesWritten
+	meta.Searchable = c.search != nil
 
 	// everything should be correct here except the start/end times which we will get from the passed in metas
 	for _, m := range c.metas[1:] {
@@ -125,3 +189,8 @@ func (c *CompactorBlock) Records() []*encoding.Record {
 func (c *CompactorBlock) ObjectFilePath() string {
 	return ""
 }
+
+// implements WriteableBlock
+func (c *CompactorBlock) SearchData() *encoding.SearchData {
+	return c.search
+}
@@ -16,10 +16,32 @@ import (
 )
 
 func TestCompactorBlockError(t *testing.T) {
-	_, err := newCompactorBlock(uuid.New(), "", 0, 0, nil, "", 0)
+	_, err := newCompactorBlock(uuid.New(), "", 0, 0, 0, encoding.CurrentVersion, encoding.EncNone, nil, "", 0)
 	assert.Error(t, err)
 }
 
+func TestCompactorBlockSummary(t *testing.T) {
+	tempDir, err := ioutil.TempDir("/tmp", "")
+	defer os.RemoveAll(tempDir)
+	assert.NoError(t, err, "unexpected error creating temp dir")
+
+	summaryA := encoding.NewBlockSummary()
+	summaryA.AddTrace([]string{"a"}, nil, 1, 100, 200)
+	summaryB := encoding.NewBlockSummary()
+	summaryB.AddTrace([]string{"b"}, nil, 1, 300, 400)
+
+	cb, err := newCompactorBlock(uuid.New(), testTenantID, .01, 0, 3, encoding.CurrentVersion, encoding.EncNone, []*encoding.BlockMeta{{Summary: summaryA}, {Summary: summaryB}}, tempDir, 10)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]int{"a": 1, "b": 1}, cb.BlockMeta().Summary.Services)
+	assert.Equal(t, uint64(100), cb.BlockMeta().Summary.StartTimeUnixNano)
+	assert.Equal(t, uint64(400), cb.BlockMeta().Summary.EndTimeUnixNano)
+
+	// an input without a summary makes the output unknown
+	cb, err = newCompactorBlock(uuid.New(), testTenantID, .01, 0, 3, encoding.CurrentVersion, encoding.EncNone, []*encoding.BlockMeta{{Summary: summaryA}, {}}, tempDir, 10)
+	assert.NoError(t, err)
+	assert.Nil(t, cb.BlockMeta().Summary)
+}
+
 func TestCompactorBlockWrite(t *testing.T) {
 	tempDir, err := ioutil.TempDir("/tmp", "")
 	defer os.RemoveAll(tempDir)
@@ -37,10 +59,12 @@ func TestCompactorBlockWrite(t *testing.T) {
 		{
 			StartTime: time.Unix(10000, 0),
 			EndTime:   time.Unix(20000, 0),
+			Labels:    map[string]string{"team": "a", "env": "prod"},
 		},
 		{
 			StartTime: time.Unix(15000, 0),
 			EndTime:   time.Unix(25000, 0),
+			Labels:    map[string]string{"team": "b"},
 		},
 	}
 
@@ -86,6 +110,7 @@ func TestCompactorBlockWrite(t *testing.T) {
 	assert.Equal(t, maxID, meta.MaxID)
 	assert.Equal(t, testTenantID, meta.TenantID)
 	assert.Equal(t, numObjects, meta.TotalObjects)
+	assert.Equal(t, map[string]string{"team": "b", "env": "prod"}, meta.Labels)
 
 	// bloom
 	bloom := cb.BloomFilter()
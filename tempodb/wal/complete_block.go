@@ -17,6 +17,7 @@ type CompleteBlock struct {
 
 	bloom   *bloom.ShardedBloomFilter
 	records []*encoding.Record
+	search  *encoding.SearchData
 
 	flushedTime atomic.Int64 // protecting flushedTime b/c it's accessed from the store on flush and from the ingester instance checking flush time
 	walFilename string
@@ -43,7 +44,7 @@ func (c *CompleteBlock) Find(id encoding.ID, combiner encoding.ObjectCombiner) (
 		return nil, err
 	}
 
-	finder := encoding.NewDedupingFinder(c.records, file, combiner)
+	finder := encoding.NewDedupingFinder(c.records, file, c.meta.Encoding, combiner)
 
 	return finder.Find(id)
 }
@@ -55,7 +56,7 @@ func (c *CompleteBlock) Iterator() (encoding.Iterator, error) {
 		return nil, err
 	}
 
-	return encoding.NewIterator(f), nil
+	return encoding.NewDecodingIterator(encoding.NewIterator(f), c.meta.Encoding), nil
 }
 
 func (c *CompleteBlock) Clear() error {
@@ -87,3 +88,7 @@ func (c *CompleteBlock) BlockMeta() *encoding.BlockMeta {
 func (c *CompleteBlock) BloomFilter() *bloom.ShardedBloomFilter {
 	return c.bloom
 }
+
+func (c *CompleteBlock) SearchData() *encoding.SearchData {
+	return c.search
+}
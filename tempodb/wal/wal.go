@@ -13,6 +13,9 @@ import (
 
 const (
 	completedDir = "completed"
+	// BlocksDir is the directory of the WAL path that holds the blocks of the local backend by default.  The WAL
+	// ignores directories, so blocks there are never replayed.
+	BlocksDir = "blocks"
 )
 
 type WAL struct {
@@ -24,6 +27,12 @@ type Config struct {
 	CompletedFilepath string
 	IndexDownsample   int     `yaml:"index_downsample"`
 	BloomFP           float64 `yaml:"bloom_filter_false_positive"`
+	// BloomShardSizeBytes is the target size of each bloom shard of a block.  Lookups only fetch the shard of the trace
+	// id, so large blocks split into more shards.  0 splits every bloom into encoding.LegacyBloomShardCount shards.
+	BloomShardSizeBytes int    `yaml:"bloom_filter_shard_size_bytes"`
+	Version             string `yaml:"version"`
+	Encoding            string `yaml:"encoding"`          // compression of the objects in completed and compacted blocks
+	EncodingFallback    string `yaml:"encoding_fallback"` // encoding used if this build doesn't support Encoding
 }
 
 func New(c *Config) (*WAL, error) {
@@ -39,14 +48,30 @@ func New(c *Config) (*WAL, error) {
 		return nil, fmt.Errorf("invalid bloom filter fp rate %v", c.BloomFP)
 	}
 
+	if c.Version == "" {
+		c.Version = encoding.CurrentVersion
+	}
+	err := encoding.ValidateWriteVersion(c.Version)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.Encoding == "" {
+		c.Encoding = encoding.EncNone
+	}
+	c.Encoding, err = encoding.ResolveEncoding(c.Encoding, c.EncodingFallback)
+	if err != nil {
+		return nil, err
+	}
+
 	// make folder
-	err := os.MkdirAll(c.Filepath, os.ModePerm)
+	err = os.MkdirAll(c.Filepath, os.ModePerm)
 	if err != nil {
 		return nil, err
 	}
 
 	if c.CompletedFilepath == "" {
-		completedFilepath := path.Join(c.Filepath, completedDir)
+		completedFilepath := c.CompletedPath()
 		err = os.RemoveAll(completedFilepath)
 		if err != nil {
 			return nil, err
@@ -64,6 +89,15 @@ func New(c *Config) (*WAL, error) {
 	}, nil
 }
 
+// CompletedPath is the directory completed blocks are written to before they're flushed.  It's emptied when the WAL
+// is created.
+func (c *Config) CompletedPath() string {
+	if c.CompletedFilepath != "" {
+		return c.CompletedFilepath
+	}
+	return path.Join(c.Filepath, completedDir)
+}
+
 func (w *WAL) AllBlocks() ([]*ReplayBlock, error) {
 	files, err := ioutil.ReadDir(w.c.Filepath)
 	if err != nil {
@@ -84,7 +118,7 @@ func (w *WAL) AllBlocks() ([]*ReplayBlock, error) {
 
 		blocks = append(blocks, &ReplayBlock{
 			block: block{
-				meta:     encoding.NewBlockMeta(tenantID, blockID),
+				meta:     encoding.NewBlockMeta(tenantID, blockID, w.c.Version),
 				filepath: w.c.Filepath,
 			},
 		})
@@ -94,11 +128,11 @@ func (w *WAL) AllBlocks() ([]*ReplayBlock, error) {
 }
 
 func (w *WAL) NewBlock(id uuid.UUID, tenantID string) (*AppendBlock, error) {
-	return newAppendBlock(id, tenantID, w.c.Filepath)
+	return newAppendBlock(id, tenantID, w.c.Filepath, w.c.Version)
 }
 
 func (w *WAL) NewCompactorBlock(id uuid.UUID, tenantID string, metas []*encoding.BlockMeta, estimatedObjects int) (*CompactorBlock, error) {
-	return newCompactorBlock(id, tenantID, w.c.BloomFP, w.c.IndexDownsample, metas, w.c.CompletedFilepath, estimatedObjects)
+	return newCompactorBlock(id, tenantID, w.c.BloomFP, w.c.BloomShardSizeBytes, w.c.IndexDownsample, w.c.Version, w.c.Encoding, metas, w.c.CompletedFilepath, estimatedObjects)
 }
 
 func (w *WAL) config() *Config {
@@ -56,6 +56,31 @@ func TestCreateBlock(t *testing.T) {
 	assert.Equal(t, block.fullFilename(), blocks[0].fullFilename())
 }
 
+func TestWriteVersion(t *testing.T) {
+	tempDir, err := ioutil.TempDir("/tmp", "")
+	defer os.RemoveAll(tempDir)
+	assert.NoError(t, err, "unexpected error creating temp dir")
+
+	_, err = New(&Config{
+		Filepath:        tempDir,
+		IndexDownsample: 2,
+		BloomFP:         0.1,
+		Version:         "v99",
+	})
+	assert.Error(t, err)
+
+	wal, err := New(&Config{
+		Filepath:        tempDir,
+		IndexDownsample: 2,
+		BloomFP:         0.1,
+	})
+	assert.NoError(t, err, "unexpected error creating temp wal")
+
+	block, err := wal.NewBlock(uuid.New(), testTenantID)
+	assert.NoError(t, err, "unexpected error creating block")
+	assert.Equal(t, encoding.CurrentVersion, block.meta.Version)
+}
+
 func TestReadWrite(t *testing.T) {
 	tempDir, err := ioutil.TempDir("/tmp", "")
 	defer os.RemoveAll(tempDir)
@@ -257,6 +282,119 @@ func TestCompleteBlock(t *testing.T) {
 	}
 }
 
+func TestCompleteBlockEncoding(t *testing.T) {
+	tempDir, err := ioutil.TempDir("/tmp", "")
+	defer os.RemoveAll(tempDir)
+	assert.NoError(t, err, "unexpected error creating temp dir")
+
+	_, err = New(&Config{
+		Filepath:        tempDir,
+		IndexDownsample: 2,
+		BloomFP:         .01,
+		Encoding:        encoding.EncZstd,
+	})
+	assert.Error(t, err)
+
+	wal, err := New(&Config{
+		Filepath:        tempDir,
+		IndexDownsample: 2,
+		BloomFP:         .01,
+		Encoding:        encoding.EncGZIP,
+	})
+	assert.NoError(t, err, "unexpected error creating temp wal")
+
+	block, err := wal.NewBlock(uuid.New(), testTenantID)
+	assert.NoError(t, err, "unexpected error creating block")
+
+	numMsgs := 10
+	reqs := make([]*tempopb.PushRequest, 0, numMsgs)
+	ids := make([][]byte, 0, numMsgs)
+	for i := 0; i < numMsgs; i++ {
+		id := make([]byte, 16)
+		rand.Read(id)
+		req := test.MakeRequest(10, id)
+		reqs = append(reqs, req)
+		ids = append(ids, id)
+		bReq, err := proto.Marshal(req)
+		assert.NoError(t, err)
+		err = block.Write(id, bReq)
+		assert.NoError(t, err, "unexpected error writing req")
+	}
+
+	complete, err := block.Complete(wal, &mockCombiner{})
+	assert.NoError(t, err, "unexpected error completing block")
+	assert.Equal(t, encoding.EncGZIP, complete.BlockMeta().Encoding)
+
+	// objects are decoded when found
+	for i, id := range ids {
+		out := &tempopb.PushRequest{}
+		foundBytes, err := complete.Find(id, &mockCombiner{})
+		assert.NoError(t, err)
+
+		err = proto.Unmarshal(foundBytes, out)
+		assert.NoError(t, err)
+		assert.True(t, proto.Equal(out, reqs[i]))
+	}
+
+	// and iterated
+	iterator, err := complete.Iterator()
+	assert.NoError(t, err)
+	count := 0
+	for {
+		id, obj, err := iterator.Next()
+		if id == nil {
+			break
+		}
+		assert.NoError(t, err)
+
+		out := &tempopb.PushRequest{}
+		assert.NoError(t, proto.Unmarshal(obj, out))
+		count++
+	}
+	assert.Equal(t, numMsgs, count)
+}
+
+func TestCompleteBlockSummary(t *testing.T) {
+	tempDir, err := ioutil.TempDir("/tmp", "")
+	defer os.RemoveAll(tempDir)
+	assert.NoError(t, err, "unexpected error creating temp dir")
+
+	wal, err := New(&Config{
+		Filepath:        tempDir,
+		IndexDownsample: 2,
+		BloomFP:         0.1,
+	})
+	assert.NoError(t, err, "unexpected error creating temp wal")
+
+	writeBlock := func(summarize bool) *CompleteBlock {
+		block, err := wal.NewBlock(uuid.New(), testTenantID)
+		assert.NoError(t, err, "unexpected error creating block")
+
+		for i := 0; i < 3; i++ {
+			id := make([]byte, 16)
+			rand.Read(id)
+			assert.NoError(t, block.Write(id, []byte{0x01}))
+			// only the first object is summarized when summarize is false
+			if summarize || i == 0 {
+				block.Summarize([]string{"svc"}, []string{"op"}, 1, 0, 0)
+			}
+		}
+
+		complete, err := block.Complete(wal, &mockCombiner{})
+		assert.NoError(t, err, "unexpected error completing block")
+		return complete
+	}
+
+	summary := writeBlock(true).BlockMeta().Summary
+	if assert.NotNil(t, summary) {
+		assert.Equal(t, 3, summary.Traces)
+		assert.Equal(t, map[string]int{"svc": 3}, summary.Services)
+	}
+
+	// a partial summary would wrongly prune the block
+	assert.Nil(t, writeBlock(false).BlockMeta().Summary)
+}
+
 func TestWorkDir(t *testing.T) {
 	tempDir, err := ioutil.TempDir("/tmp", "")
 	defer os.RemoveAll(tempDir)